@@ -0,0 +1,1747 @@
+// Code generated by go run ./cmd/tsclient from docs/openapi.yaml; DO NOT EDIT.
+
+/** Options for a CalCardClient. */
+export interface ClientOptions {
+  /** Base URL of the server, such as https://calcard.example.com. */
+  baseUrl: string;
+  /** Email address the app password belongs to. */
+  username?: string;
+  /** App password, sent with the username using Basic authentication. */
+  password?: string;
+  /** Replaces the global fetch, for tests or other runtimes. */
+  fetch?: typeof fetch;
+}
+
+/** Per-request options, such as If-Match headers or an abort signal. */
+export interface RequestOptions {
+  headers?: Record<string, string>;
+  signal?: AbortSignal;
+}
+
+/** Raised when the server answers with an error status. */
+export class CalCardError extends Error {
+  readonly status: number;
+  readonly body: string;
+
+  constructor(status: number, body: string) {
+    super(`CalCard request failed with status ${status}: ${body.trim()}`);
+    this.name = "CalCardError";
+    this.status = status;
+    this.body = body;
+  }
+}
+
+/** Client for the CalCard REST API. */
+export class CalCardClient {
+  private readonly baseUrl: string;
+  private readonly fetchFn: typeof fetch;
+  private readonly authorization?: string;
+
+  constructor(options: ClientOptions) {
+    this.baseUrl = options.baseUrl.replace(/\/+$/, "");
+    this.fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+    if (options.username !== undefined && options.password !== undefined) {
+      const bytes = new TextEncoder().encode(`${options.username}:${options.password}`);
+      this.authorization = "Basic " + btoa(String.fromCharCode(...bytes));
+    }
+  }
+
+  private async request<T>(
+    method: string,
+    path: string,
+    query: Record<string, unknown> | undefined,
+    body: unknown,
+    contentType: string | undefined,
+    options: RequestOptions | undefined,
+    kind: "json" | "text" | "none",
+  ): Promise<T> {
+    const url = new URL(this.baseUrl + path);
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null) {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    const headers: Record<string, string> = { ...options?.headers };
+    if (this.authorization !== undefined) {
+      headers["Authorization"] = this.authorization;
+    }
+    let payload: string | undefined;
+    if (body !== undefined && contentType !== undefined) {
+      headers["Content-Type"] = contentType;
+      payload = contentType === "application/json" ? JSON.stringify(body) : String(body);
+    }
+    const response = await this.fetchFn(url, { method, headers, body: payload, signal: options?.signal });
+    if (!response.ok) {
+      throw new CalCardError(response.status, await response.text());
+    }
+    if (kind === "json") {
+      return (await response.json()) as T;
+    }
+    if (kind === "text") {
+      return (await response.text()) as T;
+    }
+    return undefined as T;
+  }
+
+  /** List address books */
+  listAddressBooks(options?: RequestOptions): Promise<AddressBook[]> {
+    return this.request("GET", `/api/addressbooks`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Get an address book */
+  getAddressBook(id: number, options?: RequestOptions): Promise<AddressBook> {
+    return this.request("GET", `/api/addressbooks/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List contacts in an address book */
+  listContacts(id: number, query?: { name?: string; email?: string; q?: string; limit?: number; offset?: number }, options?: RequestOptions): Promise<Contact[]> {
+    return this.request("GET", `/api/addressbooks/${encodeURIComponent(String(id))}/contacts`, query, undefined, undefined, options, "json");
+  }
+
+  /** Create a contact */
+  createContact(id: number, body: ContactWriteRequest, options?: RequestOptions): Promise<Contact> {
+    return this.request("POST", `/api/addressbooks/${encodeURIComponent(String(id))}/contacts`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Get a contact */
+  getContact(id: number, uid: string, options?: RequestOptions): Promise<Contact> {
+    return this.request("GET", `/api/addressbooks/${encodeURIComponent(String(id))}/contacts/${encodeURIComponent(String(uid))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Update a contact */
+  updateContact(id: number, uid: string, body: ContactWriteRequest, options?: RequestOptions): Promise<Contact> {
+    return this.request("PUT", `/api/addressbooks/${encodeURIComponent(String(id))}/contacts/${encodeURIComponent(String(uid))}`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Delete a contact */
+  deleteContact(id: number, uid: string, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/addressbooks/${encodeURIComponent(String(id))}/contacts/${encodeURIComponent(String(uid))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Report contact field problems */
+  getContactQuality(id: number, options?: RequestOptions): Promise<ContactQuality> {
+    return this.request("GET", `/api/addressbooks/${encodeURIComponent(String(id))}/quality`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List the users an address book is shared with */
+  listAddressBookShares(id: number, options?: RequestOptions): Promise<AddressBookShare[]> {
+    return this.request("GET", `/api/addressbooks/${encodeURIComponent(String(id))}/shares`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Share an address book with another user */
+  shareAddressBook(id: number, body: {
+    userId: number;
+    role?: "read" | "editor";
+  }, options?: RequestOptions): Promise<void> {
+    return this.request("POST", `/api/addressbooks/${encodeURIComponent(String(id))}/shares`, undefined, body, "application/json", options, "none");
+  }
+
+  /** Revoke a share, or leave a book shared with you */
+  unshareAddressBook(id: number, userId: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/addressbooks/${encodeURIComponent(String(id))}/shares/${encodeURIComponent(String(userId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get backup status and available snapshots */
+  getBackupStatus(options?: RequestOptions): Promise<BackupStatus> {
+    return this.request("GET", `/api/admin/backups`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Start a snapshot now */
+  runBackup(options?: RequestOptions): Promise<BackupStatus> {
+    return this.request("POST", `/api/admin/backups`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Get a snapshot manifest */
+  getBackupSnapshot(snapshot: string, options?: RequestOptions): Promise<BackupManifest> {
+    return this.request("GET", `/api/admin/backups/${encodeURIComponent(String(snapshot))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Restore a collection from a snapshot */
+  restoreBackup(snapshot: string, body: RestoreBackupRequest, options?: RequestOptions): Promise<RestoreResult> {
+    return this.request("POST", `/api/admin/backups/${encodeURIComponent(String(snapshot))}/restore`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Make another user a calendar's owner */
+  transferCalendar(id: number, body: TransferCalendarRequest, options?: RequestOptions): Promise<CalendarTransfer> {
+    return this.request("POST", `/api/admin/calendars/${encodeURIComponent(String(id))}/transfer`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List a calendar's past transfers */
+  listCalendarTransfers(id: number, options?: RequestOptions): Promise<CalendarTransfer[]> {
+    return this.request("GET", `/api/admin/calendars/${encodeURIComponent(String(id))}/transfers`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Reload the configuration without restarting */
+  reloadConfig(options?: RequestOptions): Promise<ReloadResult> {
+    return this.request("POST", `/api/admin/config/reload`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Report whether the server is draining */
+  getDrainStatus(options?: RequestOptions): Promise<DrainStatus> {
+    return this.request("GET", `/api/admin/drain`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Drain the server ahead of a shutdown */
+  startDrain(options?: RequestOptions): Promise<DrainStatus> {
+    return this.request("POST", `/api/admin/drain`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List feature flags and their overrides */
+  listFeatureFlags(options?: RequestOptions): Promise<FeatureFlag[]> {
+    return this.request("GET", `/api/admin/feature-flags`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Turn a feature flag on or off for a user or group */
+  setFeatureFlagOverride(name: "push" | "caldav-repair" | "focus-time", scope: "users" | "groups", id: number, body: FeatureFlagOverrideRequest, options?: RequestOptions): Promise<FeatureFlagOverride> {
+    return this.request("PUT", `/api/admin/feature-flags/${encodeURIComponent(String(name))}/${encodeURIComponent(String(scope))}/${encodeURIComponent(String(id))}`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Return a user or group to the instance-wide setting */
+  deleteFeatureFlagOverride(name: "push" | "caldav-repair" | "focus-time", scope: "users" | "groups", id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/admin/feature-flags/${encodeURIComponent(String(name))}/${encodeURIComponent(String(scope))}/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get consistency check status and the last report */
+  getFsckStatus(options?: RequestOptions): Promise<FsckStatus> {
+    return this.request("GET", `/api/admin/fsck`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Start a consistency check now */
+  runFsck(body?: RunFsckRequest, options?: RequestOptions): Promise<FsckStatus> {
+    return this.request("POST", `/api/admin/fsck`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List impersonations */
+  listImpersonations(query?: { limit?: number }, options?: RequestOptions): Promise<Impersonation[]> {
+    return this.request("GET", `/api/admin/impersonations`, query, undefined, undefined, options, "json");
+  }
+
+  /** Act as another user for a limited time */
+  startImpersonation(body: StartImpersonationRequest, options?: RequestOptions): Promise<Impersonation> {
+    return this.request("POST", `/api/admin/impersonations`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Get an impersonation and the requests made with it */
+  getImpersonation(impersonationId: number, options?: RequestOptions): Promise<Impersonation> {
+    return this.request("GET", `/api/admin/impersonations/${encodeURIComponent(String(impersonationId))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** End an impersonation before it expires */
+  endImpersonation(impersonationId: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/admin/impersonations/${encodeURIComponent(String(impersonationId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** List the collections in maintenance */
+  listMaintenance(options?: RequestOptions): Promise<CollectionMaintenance[]> {
+    return this.request("GET", `/api/admin/maintenance`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Put a collection into maintenance */
+  startMaintenance(collectionType: "calendar" | "addressbook", collectionId: number, body: StartMaintenanceRequest, options?: RequestOptions): Promise<CollectionMaintenance> {
+    return this.request("PUT", `/api/admin/maintenance/${encodeURIComponent(String(collectionType))}/${encodeURIComponent(String(collectionId))}`, undefined, body, "application/json", options, "json");
+  }
+
+  /** End a collection's maintenance */
+  endMaintenance(collectionType: "calendar" | "addressbook", collectionId: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/admin/maintenance/${encodeURIComponent(String(collectionType))}/${encodeURIComponent(String(collectionId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Show the database schema version */
+  getMigrationStatus(options?: RequestOptions): Promise<MigrationStatus> {
+    return this.request("GET", `/api/admin/migrations`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Apply pending database migrations */
+  runMigrations(options?: RequestOptions): Promise<MigrationStatus> {
+    return this.request("POST", `/api/admin/migrations`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List anonymous usage snapshots */
+  getUsageStats(query?: { limit?: number }, options?: RequestOptions): Promise<UsageStats> {
+    return this.request("GET", `/api/admin/usage`, query, undefined, undefined, options, "json");
+  }
+
+  /** Create an account ahead of its owner's first sign-in */
+  createUser(body: CreateUserRequest, options?: RequestOptions): Promise<User> {
+    return this.request("POST", `/api/admin/users`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List the caller's agenda with travel-time warnings */
+  getAgenda(query?: { days?: number; speedKmh?: number }, options?: RequestOptions): Promise<Agenda> {
+    return this.request("GET", `/api/agenda`, query, undefined, undefined, options, "json");
+  }
+
+  /** Get the caller's agenda as plain text */
+  getAgendaText(query?: { days?: number }, options?: RequestOptions): Promise<string> {
+    return this.request("GET", `/api/agenda.txt`, query, undefined, undefined, options, "text");
+  }
+
+  /** List recent DAV sign-ins and refused passwords */
+  listAuthEvents(query?: { limit?: number }, options?: RequestOptions): Promise<AuthEvent[]> {
+    return this.request("GET", `/api/auth-events`, query, undefined, undefined, options, "json");
+  }
+
+  /** Show how busy each hour of the week usually is */
+  getAvailability(query?: { days?: number; tz?: string; group?: number }, options?: RequestOptions): Promise<Availability> {
+    return this.request("GET", `/api/availability`, query, undefined, undefined, options, "json");
+  }
+
+  /** List accessible calendars */
+  listCalendars(query?: { limit?: number; offset?: number }, options?: RequestOptions): Promise<Calendar[]> {
+    return this.request("GET", `/api/calendars`, query, undefined, undefined, options, "json");
+  }
+
+  /** Get a calendar */
+  getCalendar(id: number, options?: RequestOptions): Promise<Calendar> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** View a calendar as it stood at a past time */
+  getCalendarAsOf(id: number, query: { at: string }, options?: RequestOptions): Promise<CalendarAsOf> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/as-of`, query, undefined, undefined, options, "json");
+  }
+
+  /** Get a calendar's conferencing webhook */
+  getConferenceHook(id: number, options?: RequestOptions): Promise<ConferenceHook> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/conference-hook`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Set a calendar's conferencing webhook */
+  setConferenceHook(id: number, body: {
+    /** Absolute http or https URL of the webhook on a public host. */
+    url: string;
+    /** Optional key for signing webhook requests. */
+    secret?: string;
+  }, options?: RequestOptions): Promise<ConferenceHook> {
+    return this.request("PUT", `/api/calendars/${encodeURIComponent(String(id))}/conference-hook`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Remove a calendar's conferencing webhook */
+  deleteConferenceHook(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/calendars/${encodeURIComponent(String(id))}/conference-hook`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Copy a calendar and its events */
+  duplicateCalendar(id: number, body: DuplicateCalendarRequest, options?: RequestOptions): Promise<DuplicateCalendarResult> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/duplicate`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List events in a calendar */
+  listEvents(id: number, query?: { start?: string; end?: string; title?: string; description?: string; location?: string; q?: string; limit?: number; offset?: number }, options?: RequestOptions): Promise<Event[]> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/events`, query, undefined, undefined, options, "json");
+  }
+
+  /** Create or upsert an event */
+  createEvent(id: number, body: EventWriteRequest, options?: RequestOptions): Promise<Event> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Delete many events */
+  batchDeleteEvents(id: number, body: BatchDeleteRequest, options?: RequestOptions): Promise<BatchDeleteResult> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events/batch-delete`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Move events by a date offset */
+  shiftEvents(id: number, body: ShiftEventsRequest, options?: RequestOptions): Promise<ShiftEventsResult> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events/shift`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Get an event */
+  getEvent(id: number, uid: string, options?: RequestOptions): Promise<Event> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Update an event */
+  updateEvent(id: number, uid: string, body: EventWriteRequest, options?: RequestOptions): Promise<Event> {
+    return this.request("PUT", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Delete an event */
+  deleteEvent(id: number, uid: string, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Dismiss an alarm of an event */
+  acknowledgeAlarm(id: number, uid: string, alarmId: string, options?: RequestOptions): Promise<Event> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}/alarms/${encodeURIComponent(String(alarmId))}/acknowledge`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Snooze an alarm of an event */
+  snoozeAlarm(id: number, uid: string, alarmId: string, body: {
+    until?: string;
+    minutes?: number;
+  }, options?: RequestOptions): Promise<Event> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}/alarms/${encodeURIComponent(String(alarmId))}/snooze`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Expand a recurring event into its instances */
+  listEventInstances(id: number, uid: string, query: { start: string; end: string }, options?: RequestOptions): Promise<EventInstance[]> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}/instances`, query, undefined, undefined, options, "json");
+  }
+
+  /** List your unexpired links to an event */
+  listEventLinks(id: number, uid: string, options?: RequestOptions): Promise<EventLink[]> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}/links`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Create an expiring read-only link to an event */
+  createEventLink(id: number, uid: string, body?: {
+    /** Defaults to 168 (7 days). */
+    expiresInHours?: number;
+  }, options?: RequestOptions): Promise<EventLink> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/events/${encodeURIComponent(String(uid))}/links`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Import an iCalendar file in the background */
+  importCalendar(id: number, body: string, options?: RequestOptions): Promise<Job> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/import`, undefined, body, "text/calendar", options, "json");
+  }
+
+  /** List journal entries */
+  listJournals(id: number, query?: { from?: string; to?: string; date?: string }, options?: RequestOptions): Promise<Journal[]> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/journals`, query, undefined, undefined, options, "json");
+  }
+
+  /** Add a journal entry */
+  createJournal(id: number, body: {
+    date: string;
+    summary?: string;
+    description?: string;
+    categories?: string[];
+  }, options?: RequestOptions): Promise<Journal> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/journals`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Merge a calendar into another */
+  mergeCalendar(id: number, body: MergeCalendarRequest, options?: RequestOptions): Promise<MergeCalendarResult> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/merge`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Get a calendar's room or resource settings */
+  getSchedulingResource(id: number, options?: RequestOptions): Promise<SchedulingResource> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/resource`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Make a calendar a bookable room or resource */
+  setSchedulingResource(id: number, body: {
+    email: string;
+    kind?: "ROOM" | "RESOURCE";
+    policy?: "first-come" | "priority";
+    priorityOrganizers?: string[];
+  }, options?: RequestOptions): Promise<SchedulingResource> {
+    return this.request("PUT", `/api/calendars/${encodeURIComponent(String(id))}/resource`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Stop a calendar from being a bookable resource */
+  deleteSchedulingResource(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/calendars/${encodeURIComponent(String(id))}/resource`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get a calendar's retention policy */
+  getRetentionPolicy(id: number, options?: RequestOptions): Promise<RetentionPolicy> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/retention`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Delete events a number of months after they end */
+  setRetentionPolicy(id: number, body: {
+    months: number;
+  }, options?: RequestOptions): Promise<RetentionPolicy> {
+    return this.request("PUT", `/api/calendars/${encodeURIComponent(String(id))}/retention`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Stop deleting old events from a calendar */
+  deleteRetentionPolicy(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/calendars/${encodeURIComponent(String(id))}/retention`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** List events deleted by a calendar's retention policy */
+  listRetentionDeletions(id: number, query?: { limit?: number }, options?: RequestOptions): Promise<RetentionDeletion[]> {
+    return this.request("GET", `/api/calendars/${encodeURIComponent(String(id))}/retention/deletions`, query, undefined, undefined, options, "json");
+  }
+
+  /** Split events into a new calendar */
+  splitCalendar(id: number, body: SplitCalendarRequest, options?: RequestOptions): Promise<SplitCalendarResult> {
+    return this.request("POST", `/api/calendars/${encodeURIComponent(String(id))}/split`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List event and contact changes across all readable collections */
+  listChanges(query?: { cursor?: string; limit?: number }, options?: RequestOptions): Promise<ChangeFeed> {
+    return this.request("GET", `/api/changes`, query, undefined, undefined, options, "json");
+  }
+
+  /** List repairs made to calendar data each device uploaded */
+  getClientQuality(options?: RequestOptions): Promise<ClientQualityDevice[]> {
+    return this.request("GET", `/api/client-quality`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Find contacts by email address or phone number */
+  lookupContacts(query?: { email?: string; phone?: string }, options?: RequestOptions): Promise<Contact[]> {
+    return this.request("GET", `/api/contacts/lookup`, query, undefined, undefined, options, "json");
+  }
+
+  /** List recently changed contacts */
+  listRecentContactChanges(query?: { days?: number }, options?: RequestOptions): Promise<RecentContactChanges> {
+    return this.request("GET", `/api/contacts/recent`, query, undefined, undefined, options, "json");
+  }
+
+  /** List app passwords with where each was last used */
+  listDevices(options?: RequestOptions): Promise<Device[]> {
+    return this.request("GET", `/api/devices`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Revoke a device's app password */
+  revokeDevice(id: number, options?: RequestOptions): Promise<RevokeDeviceResult> {
+    return this.request("POST", `/api/devices/${encodeURIComponent(String(id))}/revoke`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Replace a device's app password */
+  rotateDevice(id: number, options?: RequestOptions): Promise<RotatedDevice> {
+    return this.request("POST", `/api/devices/${encodeURIComponent(String(id))}/rotate`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Find probable duplicate events */
+  listDuplicateEvents(options?: RequestOptions): Promise<DuplicateGroups> {
+    return this.request("GET", `/api/duplicates`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Delete duplicate events */
+  cleanupDuplicateEvents(body?: DuplicateCleanupRequest, options?: RequestOptions): Promise<DuplicateCleanupResult> {
+    return this.request("POST", `/api/duplicates/cleanup`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Revoke an event link */
+  revokeEventLink(linkId: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/event-links/${encodeURIComponent(String(linkId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** List the user's sharing groups */
+  listGroups(options?: RequestOptions): Promise<Group[]> {
+    return this.request("GET", `/api/groups`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Create a sharing group */
+  createGroup(body: {
+    name: string;
+    memberIds?: number[];
+  }, options?: RequestOptions): Promise<Group> {
+    return this.request("POST", `/api/groups`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Delete a sharing group */
+  deleteGroup(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/groups/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Add a user to a group */
+  addGroupMember(id: number, userId: number, options?: RequestOptions): Promise<void> {
+    return this.request("PUT", `/api/groups/${encodeURIComponent(String(id))}/members/${encodeURIComponent(String(userId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Remove a user from a group */
+  removeGroupMember(id: number, userId: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/groups/${encodeURIComponent(String(id))}/members/${encodeURIComponent(String(userId))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** List recent jobs */
+  listJobs(query?: { limit?: number }, options?: RequestOptions): Promise<Job[]> {
+    return this.request("GET", `/api/jobs`, query, undefined, undefined, options, "json");
+  }
+
+  /** Get a job's progress and outcome */
+  getJob(jobId: string, options?: RequestOptions): Promise<Job> {
+    return this.request("GET", `/api/jobs/${encodeURIComponent(String(jobId))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Stop a running or interrupted job */
+  abortJob(jobId: string, options?: RequestOptions): Promise<Job> {
+    return this.request("POST", `/api/jobs/${encodeURIComponent(String(jobId))}/abort`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Resume an interrupted import */
+  resumeJob(jobId: string, options?: RequestOptions): Promise<Job> {
+    return this.request("POST", `/api/jobs/${encodeURIComponent(String(jobId))}/resume`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List saved locations */
+  listLocations(options?: RequestOptions): Promise<Location[]> {
+    return this.request("GET", `/api/locations`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Save a location */
+  createLocation(body: LocationInput, options?: RequestOptions): Promise<Location> {
+    return this.request("POST", `/api/locations`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Autocomplete a location */
+  suggestLocations(query?: { q?: string }, options?: RequestOptions): Promise<{
+    name: string;
+    /** Set for saved locations. */
+    locationId?: number;
+  }[]> {
+    return this.request("GET", `/api/locations/suggest`, query, undefined, undefined, options, "json");
+  }
+
+  /** Update a saved location */
+  updateLocation(id: number, body: LocationInput, options?: RequestOptions): Promise<Location> {
+    return this.request("PUT", `/api/locations/${encodeURIComponent(String(id))}`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Delete a saved location */
+  deleteLocation(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/locations/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get the user's regional preferences */
+  getPreferences(options?: RequestOptions): Promise<Preferences> {
+    return this.request("GET", `/api/preferences`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Replace the user's regional preferences */
+  updatePreferences(body: {
+    /** BCP 47 language tag. */
+    locale?: string;
+    /** IANA timezone name. */
+    timezone?: string;
+    /** First day of the week, 0 for Sunday. */
+    weekStart?: number | null;
+  }, options?: RequestOptions): Promise<Preferences> {
+    return this.request("PUT", `/api/preferences`, undefined, body, "application/json", options, "json");
+  }
+
+  /** List the user's recent alarm deliveries */
+  listAlarmDeliveries(query?: { limit?: number }, options?: RequestOptions): Promise<AlarmDelivery[]> {
+    return this.request("GET", `/api/preferences/alarm-deliveries`, query, undefined, undefined, options, "json");
+  }
+
+  /** List the services the user's alarms are pushed to */
+  listAlarmProviders(options?: RequestOptions): Promise<AlarmProvider[]> {
+    return this.request("GET", `/api/preferences/alarm-providers`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Push the user's event alarms to Gotify, ntfy, Pushover or a webhook */
+  createAlarmProvider(body: AlarmProviderRequest, options?: RequestOptions): Promise<AlarmProvider> {
+    return this.request("POST", `/api/preferences/alarm-providers`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Stop pushing alarms to a provider */
+  deleteAlarmProvider(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/preferences/alarm-providers/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Send a test message through a provider */
+  testAlarmProvider(id: number, options?: RequestOptions): Promise<void> {
+    return this.request("POST", `/api/preferences/alarm-providers/${encodeURIComponent(String(id))}/test`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get the user's agenda digest schedule */
+  getDigest(options?: RequestOptions): Promise<Digest> {
+    return this.request("GET", `/api/preferences/digest`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Schedule the user's agenda digest email */
+  updateDigest(body: {
+    frequency: "daily" | "weekly";
+    /** Local time of day, as HH:MM. */
+    sendTime: string;
+    /** Day weekly digests are sent, 0 for Sunday. Defaults to Monday. */
+    weekday?: number;
+    /** Skip daily digests on the weekend of the user's locale. */
+    workingDaysOnly?: boolean;
+  }, options?: RequestOptions): Promise<Digest> {
+    return this.request("PUT", `/api/preferences/digest`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Stop the user's agenda digest */
+  deleteDigest(options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/preferences/digest`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Get the user's focus time settings */
+  getFocus(options?: RequestOptions): Promise<Focus> {
+    return this.request("GET", `/api/preferences/focus`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Opt in to focus time or change its settings */
+  updateFocus(body: {
+    /** Shortest free stretch that is blocked. */
+    minMinutes: number;
+    /** Local time of day protected hours start, as HH:MM. */
+    dayStart: string;
+    /** Local time of day protected hours end, as HH:MM or 24:00. */
+    dayEnd: string;
+  }, options?: RequestOptions): Promise<Focus> {
+    return this.request("PUT", `/api/preferences/focus`, undefined, body, "application/json", options, "json");
+  }
+
+  /** Opt out of focus time */
+  deleteFocus(options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/preferences/focus`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Remove the user's upcoming focus time blocks */
+  removeFocusBlocks(options?: RequestOptions): Promise<{
+    removed: number;
+  }> {
+    return this.request("DELETE", `/api/preferences/focus/blocks`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List the collections the user gets change emails for */
+  listNotifications(options?: RequestOptions): Promise<{
+    /** False when the server cannot send email, so nothing is sent. */
+    emailEnabled: boolean;
+    following: CollectionNotification[];
+  }> {
+    return this.request("GET", `/api/preferences/notifications`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Email the user about changes other users make in a collection */
+  followCollection(type: "calendar" | "addressbook", id: number, options?: RequestOptions): Promise<CollectionNotification> {
+    return this.request("PUT", `/api/preferences/notifications/${encodeURIComponent(String(type))}/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Stop change emails for a collection */
+  unfollowCollection(type: "calendar" | "addressbook", id: number, options?: RequestOptions): Promise<void> {
+    return this.request("DELETE", `/api/preferences/notifications/${encodeURIComponent(String(type))}/${encodeURIComponent(String(id))}`, undefined, undefined, undefined, options, "none");
+  }
+
+  /** Run the synthetic monitoring probe */
+  runProbe(options?: RequestOptions): Promise<ProbeResult> {
+    return this.request("GET", `/api/probe`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Get the user's storage quota */
+  getQuota(options?: RequestOptions): Promise<Quota> {
+    return this.request("GET", `/api/quota`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** Get the server's compliance matrix */
+  getServerInfo(options?: RequestOptions): Promise<ServerInfo> {
+    return this.request("GET", `/api/server-info`, undefined, undefined, undefined, options, "json");
+  }
+
+  /** List devices that recently synced each collection */
+  listSyncActivity(query?: { days?: number }, options?: RequestOptions): Promise<SyncActivity> {
+    return this.request("GET", `/api/sync-activity`, query, undefined, undefined, options, "json");
+  }
+
+  /** Issue UIDs for new events */
+  issueUIDs(query?: { count?: number }, options?: RequestOptions): Promise<{
+    uids: string[];
+  }> {
+    return this.request("POST", `/api/uids`, query, undefined, undefined, options, "json");
+  }
+}
+
+export interface AddressBook {
+  id: number;
+  name: string;
+  description?: string;
+  checksum?: string;
+}
+
+export interface AddressBookShare {
+  userId: number;
+  email: string;
+  role: "read" | "editor";
+}
+
+export interface Agenda {
+  start: string;
+  end: string;
+  /** Travel speed assumed for the warnings, in km/h. */
+  speedKmh: number;
+  items: AgendaItem[];
+}
+
+export interface AgendaItem {
+  calendarId: number;
+  calendarName: string;
+  uid: string;
+  summary: string;
+  location?: string;
+  start: string;
+  end: string;
+  allDay: boolean;
+  latitude?: number;
+  longitude?: number;
+  travelWarning?: TravelWarning;
+}
+
+export interface Alarm {
+  /** Names the alarm in the acknowledge and snooze endpoints. */
+  id: string;
+  /** RFC 9074 alarm UID, if the alarm has one. */
+  uid?: string;
+  action: string;
+  /** Raw TRIGGER value, such as -PT15M. */
+  trigger: string;
+  /** When the alarm was last dismissed (RFC 9074 ACKNOWLEDGED). Omitted until it has been. */
+  acknowledged?: string;
+  /** For an RFC 9074 snooze alarm, the UID of the alarm it stands in for. */
+  snoozes?: string;
+  /** RFC 9074 PROXIMITY of a location-based alarm. */
+  proximity?: string;
+}
+
+export interface AlarmDelivery {
+  id: number;
+  providerId: number;
+  calendarId: number;
+  uid: string;
+  alarmId: string;
+  fireAt: string;
+  title: string;
+  status: "pending" | "sent" | "failed";
+  attempts: number;
+  lastError?: string;
+  sentAt?: string;
+}
+
+export interface AlarmProvider {
+  id: number;
+  kind: "gotify" | "ntfy" | "pushover" | "webhook";
+  name: string;
+  url?: string;
+  /** Whether a token is set. Tokens and Pushover user keys are never returned. */
+  hasToken: boolean;
+  createdAt: string;
+}
+
+export interface AlarmProviderRequest {
+  kind: "gotify" | "ntfy" | "pushover" | "webhook";
+  /** Defaults to the kind. */
+  name?: string;
+  /** Gotify server, ntfy topic or webhook URL. Not used for Pushover. */
+  url?: string;
+  /** Gotify or Pushover application token, or ntfy or webhook bearer token. */
+  token?: string;
+  /** Pushover user or group key. */
+  recipient?: string;
+}
+
+/**
+ * Counts of the event's attendees by PARTSTAT, computed from the stored
+ * copy so it follows every response the organizer's copy records.
+ * Attendees with ROLE=NON-PARTICIPANT are not counted. Omitted when the
+ * event has no attendees.
+ */
+export interface AttendeeSummary {
+  accepted: number;
+  declined: number;
+  tentative: number;
+  /** Attendees that have not answered (NEEDS-ACTION, no PARTSTAT, or DELEGATED). */
+  pending: number;
+  total: number;
+}
+
+export interface AuthEvent {
+  id: number;
+  outcome: "success" | "failure" | "locked";
+  /** True for the first successful sign-in from this address. */
+  newAddress: boolean;
+  ip: string;
+  userAgent?: string;
+  client?: string;
+  appPasswordId?: number;
+  appPasswordLabel?: string;
+  createdAt: string;
+}
+
+export interface Availability {
+  start: string;
+  end: string;
+  timezone: string;
+  /** How many people the density is averaged over. */
+  members: number;
+  /** Seven rows, Sunday first, of 24 busy shares from 0 to 1, one per hour of the day. */
+  density: number[][];
+}
+
+export interface BackupCollection {
+  kind: "calendar" | "addressbook";
+  id: number;
+  ownerId: number;
+  ownerEmail: string;
+  name: string;
+  description?: string;
+  timezone?: string;
+  color?: string;
+  /** Events or contacts in the collection when the snapshot was taken. */
+  items: number;
+  /** Object key of the gzip-compressed ICS or VCF file. */
+  key: string;
+}
+
+export interface BackupManifest {
+  id: string;
+  createdAt: string;
+  collections: BackupCollection[];
+}
+
+export interface BackupStatus {
+  enabled: boolean;
+  target?: string;
+  interval?: string;
+  /** Number of completed snapshots kept. */
+  retention?: number;
+  running: boolean;
+  lastRunAt?: string;
+  lastSuccessAt?: string;
+  lastSnapshot?: string;
+  lastError?: string;
+  nextRunAt?: string;
+  /** Job tracking the snapshot, on the response to starting one. */
+  jobId?: string;
+  snapshots: {
+    id: string;
+    createdAt: string;
+    collections: number;
+  }[];
+}
+
+/** Set `uids`, or one or both of `before` and `category`. */
+export interface BatchDeleteRequest {
+  uids?: string[];
+  /** Date (YYYY-MM-DD) or RFC 3339 time. Matches events that end before it; recurring events match only when their rule ends before it. */
+  before?: string;
+  /** Case-insensitive category match. */
+  category?: string;
+}
+
+export interface BatchDeleteResult {
+  deleted: string[];
+  held: string[];
+  skipped: ({
+    uid: string;
+    reason: "not found" | "forbidden";
+  })[];
+  /** The query matched more events than one batch deletes. */
+  hasMore: boolean;
+}
+
+export interface Calendar {
+  id: number;
+  name: string;
+  description?: string;
+  timezone?: string;
+  color?: string;
+  ownerEmail: string;
+  shared: boolean;
+  capabilities: CalendarPrivileges;
+  /** Number of events in the calendar. Only present in paged listings of calendars the user can read. */
+  eventCount?: number;
+  checksum?: string;
+}
+
+export interface CalendarAsOf {
+  calendarId: number;
+  at: string;
+  /** The earliest time the calendar can be viewed as of. */
+  historySince: string;
+  events: EventRevision[];
+}
+
+export interface CalendarPrivileges {
+  read: boolean;
+  readFreeBusy: boolean;
+  write: boolean;
+  writeContent: boolean;
+  writeProperties: boolean;
+  bind: boolean;
+  unbind: boolean;
+}
+
+export interface CalendarTransfer {
+  id: number;
+  calendarId: number;
+  fromUserId: number;
+  fromEmail: string;
+  toUserId: number;
+  toEmail: string;
+  /** Email of the admin who made the transfer. */
+  transferredBy: string;
+  transferredAt: string;
+}
+
+export interface Change {
+  type: "event" | "contact" | "calendar" | "addressbook";
+  /** Calendar or address book ID. */
+  collectionId: number;
+  /** Empty for calendar and address book changes. */
+  uid: string;
+  /** Empty for calendar and address book changes. */
+  resourceName: string;
+  operation: "created" | "updated" | "deleted";
+  /** Omitted for deletions. */
+  etag?: string;
+  changedAt: string;
+}
+
+export interface ChangeFeed {
+  changes: Change[];
+  /** Pass back as `cursor` to read the changes after this page. */
+  cursor: string;
+  /** More changes may be available right away. */
+  hasMore: boolean;
+}
+
+export interface ClientQualityDevice {
+  appPasswordId?: number;
+  appPasswordLabel?: string;
+  userAgent: string;
+  client: string;
+  repairs: ClientRepair[];
+}
+
+export interface ClientRepair {
+  repair: "characters" | "exchange" | "line-endings" | "prodid";
+  description: string;
+  count: number;
+  /** DAV path of the most recent repaired upload. */
+  lastResource: string;
+  firstSeenAt: string;
+  lastSeenAt: string;
+}
+
+export interface CollectionMaintenance {
+  collectionType: "calendar" | "addressbook";
+  collectionId: number;
+  reason: string;
+  /** Email of the admin who started it. */
+  startedBy: string;
+  startedAt: string;
+  expiresAt: string;
+}
+
+export interface CollectionNotification {
+  collectionType: "calendar" | "addressbook";
+  collectionId: number;
+  lastNotifiedAt: string | null;
+}
+
+export interface ConferenceHook {
+  calendarId: number;
+  url: string;
+  /** Whether webhook requests are signed. The secret is never returned. */
+  hasSecret: boolean;
+  updatedAt: string;
+}
+
+export interface Contact {
+  uid: string;
+  addressBookId: number;
+  /** Stored resource name for the contact object. */
+  resourceName: string;
+  displayName?: string;
+  /** Primary email address parsed from the vCard. */
+  email?: string;
+  birthday?: string;
+  etag: string;
+  lastModified: string;
+  /** Raw vCard data for the contact. */
+  rawVcard: string;
+}
+
+export interface ContactChange {
+  addressBookId: number;
+  uid: string;
+  displayName?: string;
+  change: "created" | "updated" | "deleted";
+  /** vCard properties the change set or altered; empty for deletions. */
+  fields: string[];
+  /** ID of the user who made the change, when it was made through calcard. */
+  changedBy?: number;
+  changedByEmail?: string;
+  changedAt: string;
+}
+
+export interface ContactWriteRequest {
+  /** Defaults to `structured` when omitted. */
+  inputMode?: "structured" | "raw_vcard";
+  /** Required when `inputMode` is `raw_vcard`. */
+  rawVcard?: string;
+  structured?: StructuredContactInput;
+}
+
+export interface CreateUserRequest {
+  email: string;
+}
+
+export interface Device {
+  id: number;
+  label: string;
+  /** What the app password may do through the REST API. */
+  apiRole: "viewer" | "editor" | "admin";
+  createdAt: string;
+  expiresAt?: string;
+  revokedAt?: string;
+  lastSeenAt?: string;
+  lastUserAgent?: string;
+  lastIp?: string;
+  /** Client family derived from the last User-Agent. */
+  client?: string;
+  /** True for the app password that authenticated this request. */
+  current: boolean;
+}
+
+export interface Digest {
+  frequency: "daily" | "weekly";
+  sendTime: string;
+  weekday: number;
+  workingDaysOnly: boolean;
+  lastSentAt: string | null;
+}
+
+export interface DrainStatus {
+  draining: boolean;
+  /** When draining started. */
+  since?: string;
+}
+
+export interface DuplicateCalendarRequest {
+  name: string;
+  /** ISO 8601 duration of years, months, weeks and days, such as `P1Y` or `-P7D`, added to every date of the copied events. */
+  shift?: string;
+  /** Copy only the calendar, without its events. */
+  skipEvents?: boolean;
+}
+
+export interface DuplicateCalendarResult {
+  calendar: Calendar;
+  copied: number;
+}
+
+export interface DuplicateCleanupRequest {
+  remove?: EventRef[];
+}
+
+export interface DuplicateCleanupResult {
+  deleted: EventRef[];
+  skipped: ({
+    calendarId: number;
+    uid: string;
+    reason: "not a duplicate" | "last copy";
+  })[];
+}
+
+export interface DuplicateGroups {
+  groups: ({
+    reason: "same uid" | "same summary and start";
+    events: {
+      calendarId: number;
+      calendarName: string;
+      uid: string;
+      summary?: string;
+      /** RFC 3339 time, or a date for all-day events. */
+      start?: string;
+      allDay: boolean;
+      lastModified: string;
+      /** Set on the copy a cleanup keeps. */
+      keep: boolean;
+    }[];
+  })[];
+}
+
+/** Plain-text error response produced by `http.Error`. */
+export type ErrorText = string;
+
+export interface Event {
+  uid: string;
+  calendarId: number;
+  /** Stored resource name for the calendar object. */
+  resourceName: string;
+  summary?: string;
+  /** Event description, parsed from the iCalendar DESCRIPTION property. */
+  description?: string;
+  /** Set when the event has a formatted description. For `markdown`, `description` is the Markdown source; for `html`, it is the plain text version. */
+  descriptionFormat?: "markdown" | "html";
+  /** Sanitized HTML description, from the iCalendar X-ALT-DESC property. Safe to insert into a page. */
+  descriptionHtml?: string;
+  /** Event location, parsed from the iCalendar LOCATION property. */
+  location?: string;
+  dtstart?: string;
+  dtend?: string;
+  allDay: boolean;
+  /** RFC 7986 COLOR of the event, a CSS3 color name. */
+  color?: string;
+  /** First URI-valued RFC 7986 IMAGE of the event. */
+  image?: string;
+  /** First RFC 7986 CONFERENCE URI of the event. */
+  conference?: string;
+  joinLink?: JoinLink;
+  attendeeSummary?: AttendeeSummary;
+  /** VALARMs of the event's first component. */
+  alarms?: Alarm[];
+  /** When any of the event's alarms was last dismissed, from X-MOZ-LASTACK. Omitted when never. */
+  lastAcknowledged?: string;
+  etag: string;
+  lastModified: string;
+  /** Raw iCalendar data for the event. */
+  rawIcal: string;
+}
+
+export interface EventInstance {
+  /** Start the recurrence rule gives the occurrence; identifies it after it is moved. */
+  recurrenceId: string;
+  start: string;
+  end: string;
+  allDay: boolean;
+  /** A component with this RECURRENCE-ID replaces the occurrence. */
+  overridden: boolean;
+  /** Removed by EXDATE or overridden with STATUS:CANCELLED. */
+  cancelled: boolean;
+  /** An override moved the start or end. */
+  rescheduled: boolean;
+  /** The override's summary, when it differs from the series. */
+  summary?: string;
+}
+
+export interface EventLink {
+  id: number;
+  /** Public `<base-url>/event-links/<token>.ics` address. */
+  url: string;
+  expiresAt: string;
+  accessCount: number;
+  lastAccessedAt?: string | null;
+  createdAt: string;
+}
+
+export interface EventRef {
+  calendarId: number;
+  uid: string;
+}
+
+export interface EventRevision {
+  uid: string;
+  etag: string;
+  summary?: string;
+  location?: string;
+  start?: string;
+  end?: string;
+  allDay: boolean;
+  rawIcal: string;
+  /** User whose change produced this version, when known. */
+  changedBy?: number;
+  validFrom: string;
+  /** Absent while this version is still current. */
+  validTo?: string;
+}
+
+export interface EventWriteRequest {
+  /** Defaults to `structured` when omitted. */
+  inputMode?: "structured" | "raw_ical";
+  /** Required when `inputMode` is `raw_ical`. */
+  rawIcal?: string;
+  structured?: StructuredEventInput;
+}
+
+export interface FeatureFlag {
+  name: string;
+  description: string;
+  /** Instance-wide setting. */
+  enabled: boolean;
+  overrides: FeatureFlagOverride[];
+}
+
+export interface FeatureFlagOverride {
+  /** Set for an override of one user. */
+  userId?: number;
+  /** Set for an override of a group. */
+  groupId?: number;
+  enabled: boolean;
+  updatedAt: string;
+}
+
+export interface FeatureFlagOverrideRequest {
+  enabled: boolean;
+}
+
+export interface Focus {
+  minMinutes: number;
+  dayStart: string;
+  dayEnd: string;
+  /** End of the last day planned. */
+  plannedUntil: string | null;
+  /** Focus time blocks that have not yet ended. */
+  blocks: number;
+}
+
+export interface FsckIssue {
+  kind: "invalid_data" | "etag_mismatch" | "uid_mismatch" | "orphaned_tombstone" | "orphaned_resource" | "stale_timezone";
+  resourceType: "event" | "contact";
+  collectionId: number;
+  uid: string;
+  resourceName?: string;
+  detail?: string;
+  repaired: boolean;
+}
+
+export interface FsckReport {
+  startedAt: string;
+  finishedAt: string;
+  repair: boolean;
+  calendars: number;
+  events: number;
+  addressBooks: number;
+  contacts: number;
+  issues: FsckIssue[];
+  repaired: number;
+  /** Resources whose ETag is still the hash of their raw bytes. */
+  legacyETags: number;
+  /** Resources a repairing check migrated to canonical ETags. */
+  canonicalized: number;
+}
+
+export interface FsckStatus {
+  /** Time between scheduled checks; absent when the schedule is off. */
+  interval?: string;
+  /** Whether scheduled checks repair issues. */
+  autoRepair: boolean;
+  running: boolean;
+  lastRunAt?: string;
+  lastError?: string;
+  nextRunAt?: string;
+  lastReport?: FsckReport;
+}
+
+export interface Group {
+  id: number;
+  name: string;
+  /** The ACL principal calendars are shared with. */
+  principal: string;
+  members: {
+    userId: number;
+    email: string;
+  }[];
+  createdAt: string;
+}
+
+export interface Impersonation {
+  id: number;
+  adminEmail: string;
+  userId: number;
+  scope: "read" | "write";
+  reason: string;
+  createdAt: string;
+  expiresAt: string;
+  endedAt?: string;
+  active: boolean;
+  /** Only returned when the impersonation starts. */
+  username?: string;
+  /** Only returned when the impersonation starts. */
+  token?: string;
+  /** Only returned for a single impersonation. */
+  requests?: ImpersonationRequest[];
+}
+
+export interface ImpersonationRequest {
+  method: string;
+  path: string;
+  status: number;
+  ip: string;
+  userAgent?: string;
+  at: string;
+}
+
+export interface Job {
+  id: string;
+  kind: "calendar-import" | "backup";
+  status: "running" | "succeeded" | "failed" | "interrupted" | "aborted";
+  /** Items to process, once known. */
+  total: number;
+  processed: number;
+  /** Items that failed without failing the job; at most 1000 are kept. */
+  errors: {
+    item: string;
+    error: string;
+  }[];
+  /** Outcome of a succeeded job: `created`, `updated` and `failed` counts for an import, `snapshot` and `collections` for a backup. */
+  result?: Record<string, unknown>;
+  /** Why a failed job failed. */
+  error?: string;
+  createdAt: string;
+  updatedAt: string;
+  finishedAt?: string;
+}
+
+/** Online meeting link detected from the CONFERENCE, X-GOOGLE-CONFERENCE, URL, LOCATION or DESCRIPTION properties. Omitted when none was found. */
+export interface JoinLink {
+  url: string;
+  provider: "zoom" | "meet" | "teams" | "webex" | "jitsi" | "other";
+}
+
+export interface Journal {
+  calendarId: number;
+  uid: string;
+  /** Day of DTSTART, or null for an undated entry. */
+  date: string | null;
+  summary: string;
+  /** Several DESCRIPTION properties are joined by a blank line. */
+  description: string;
+  status?: string;
+  categories: string[];
+  etag: string;
+  lastModified: string;
+}
+
+export interface Location {
+  id: number;
+  name: string;
+  address: string;
+  latitude?: number;
+  longitude?: number;
+  updatedAt: string;
+}
+
+export interface LocationInput {
+  name: string;
+  address?: string;
+  /** Given together with `longitude`. */
+  latitude?: number;
+  longitude?: number;
+}
+
+export interface MergeCalendarRequest {
+  targetId: number;
+}
+
+export interface MergeCalendarResult {
+  moved: number;
+}
+
+export interface MigrationStatus {
+  databaseVersion: string;
+  /** The version before this run, when migrations were applied. */
+  previousVersion?: string;
+}
+
+export interface Preferences {
+  locale: string;
+  timezone: string;
+  weekStart: number | null;
+  /** The preferences in use, with defaults filled in. */
+  effective: {
+    locale: string;
+    timezone: string;
+    weekStart: number;
+    /** Days outside the locale's weekend, 0 for Sunday, in week order. */
+    workingDays: number[];
+  };
+}
+
+export interface ProbeResult {
+  ok: boolean;
+  startedAt: string;
+  durationMs: number;
+  steps: ({
+    name: "put" | "report" | "delete";
+    /** HTTP status the DAV handler answered. */
+    status: number;
+    durationMs: number;
+    error?: string;
+  })[];
+  /** Why the run could not start, such as the probe account failing to load. */
+  error?: string;
+}
+
+export interface Quota {
+  enabled: boolean;
+  usedBytes: number;
+  quotaBytes: number;
+  availableBytes: number;
+  percent: number;
+  level: "ok" | "warning" | "critical";
+}
+
+/** Raw iCalendar VCALENDAR data. */
+export type RawICalendar = string;
+
+/** Raw vCard (VCARD) data. */
+export type RawVCard = string;
+
+export interface RecentContactChanges {
+  since: string;
+  changes: ContactChange[];
+}
+
+export interface ReloadResult {
+  reloadedAt: string;
+  /** Changed settings now in effect. */
+  applied: string[];
+  /** Changed settings that take effect on the next restart. */
+  restartRequired: string[];
+}
+
+export interface RestoreBackupRequest {
+  kind: "calendar" | "addressbook";
+  /** Collection ID as recorded in the snapshot manifest. */
+  collectionId: number;
+  /** Replace resources that still exist with their snapshot copy. */
+  overwrite?: boolean;
+}
+
+export interface RestoreResult {
+  kind: string;
+  /** ID of the restored collection; differs from the request when it was recreated. */
+  collectionId: number;
+  recreated: boolean;
+  restored: number;
+  unchanged: number;
+  failed: number;
+}
+
+export interface RetentionDeletion {
+  uid: string;
+  summary?: string;
+  start?: string;
+  end?: string;
+  cutoff: string;
+  deletedAt: string;
+}
+
+export interface RetentionPolicy {
+  calendarId: number;
+  months: number;
+  /** Events that ended before this time are deleted on the next run. */
+  cutoff: string;
+  lastRunAt?: string;
+  updatedAt: string;
+}
+
+export interface RevokeDeviceResult {
+  revoked: boolean;
+  /** Number of in-flight requests that were aborted. */
+  disconnected: number;
+}
+
+export interface RotatedDevice {
+  id: number;
+  label: string;
+  apiRole: "viewer" | "editor" | "admin";
+  createdAt: string;
+  expiresAt?: string;
+  current: boolean;
+  /** The new app password, shown only once. */
+  password: string;
+}
+
+export interface RunFsckRequest {
+  repair?: boolean;
+}
+
+export interface SchedulingResource {
+  calendarId: number;
+  email: string;
+  kind: "ROOM" | "RESOURCE";
+  policy: "first-come" | "priority";
+  priorityOrganizers: string[];
+  updatedAt: string;
+}
+
+export interface ServerInfo {
+  /** Module version of the running build, or `devel`. */
+  version: string;
+  features: {
+    name: string;
+    spec: string;
+    supported: boolean;
+    /** Compliance class advertised in the DAV header. */
+    davClass?: string;
+    notes?: string;
+  }[];
+  methods: string[];
+  reports: string[];
+  collations: string[];
+  limits: {
+    /** Largest calendar object or vCard accepted, in bytes. */
+    maxResourceSize: number;
+    maxAttendeesPerInstance: number;
+    /** Largest RRULE COUNT accepted. */
+    maxInstances: number;
+    minDateTime: string;
+    maxDateTime: string;
+    /** Members per sync-collection page once an account is paged. */
+    syncPageSize: number;
+  };
+  /** Feature flags as they are set for the caller. */
+  flags: Record<string, boolean>;
+}
+
+export interface ShiftEventsRequest {
+  /** Events to move; every event when omitted. */
+  uids?: string[];
+  /** ISO 8601 duration of years, months, weeks and days, such as `P1Y` or `-P7D`. */
+  shift?: string;
+  /** Day the earliest selected event moves to. Cannot be combined with `shift`. */
+  startDate?: string;
+  /** Calendar to create shifted copies in instead of moving the events. */
+  copyTo?: number;
+}
+
+export interface ShiftEventsResult {
+  events: {
+    uid: string;
+    /** UID of the copy, when `copyTo` was given. */
+    newUid?: string;
+  }[];
+}
+
+export interface SplitCalendarRequest {
+  name: string;
+  /** Case-insensitive match on any of the event's categories. */
+  category?: string;
+  /** Events starting at or after this date (YYYY-MM-DD) or RFC 3339 time. */
+  from?: string;
+  /** Events ending before this date (YYYY-MM-DD) or RFC 3339 time. */
+  to?: string;
+}
+
+export interface SplitCalendarResult {
+  calendar: Calendar;
+  moved: number;
+}
+
+export interface StartImpersonationRequest {
+  userId: number;
+  scope: "read" | "write";
+  /** Why the impersonation is needed; recorded with it. */
+  reason: string;
+  /** Go duration between 1m and 1h (default 15m). */
+  duration?: string;
+}
+
+export interface StartMaintenanceRequest {
+  /** Shown to clients whose writes are refused. */
+  reason: string;
+  /** Go duration such as `30m`; default `1h`, at most `24h`. */
+  duration?: string;
+}
+
+/** Structured contact fields assembled into a vCard server-side. */
+export interface StructuredContactInput {
+  /** Optional. Generated when omitted; on update must match the path UID. */
+  uid?: string;
+  displayName: string;
+  firstName?: string;
+  lastName?: string;
+  email?: string;
+  phone?: string;
+  /** ISO date (YYYY-MM-DD) or vCard --MM-DD form. */
+  birthday?: string;
+  notes?: string;
+  company?: string;
+}
+
+export interface StructuredEventInput {
+  /** Optional event UID. On path-based updates, it must match the path UID when supplied. */
+  uid?: string;
+  summary: string;
+  /** Event start. Accepted formats include local date-time and all-day date values. */
+  dtstart: string;
+  /** Event end. Accepted formats include local date-time and all-day date values. */
+  dtend: string;
+  allDay?: boolean;
+  location?: string;
+  /** A saved location, written into the event as LOCATION, GEO and X-APPLE-STRUCTURED-LOCATION in place of `location`. */
+  locationId?: number;
+  description?: string;
+  /** `markdown` stores `description` as the source and adds the rendered HTML as X-ALT-DESC. `html` sanitizes `descriptionHtml` into X-ALT-DESC and, when `description` is empty, derives the plain text DESCRIPTION from it. */
+  descriptionFormat?: "text" | "markdown" | "html";
+  /** HTML description, required when `descriptionFormat` is `html`. */
+  descriptionHtml?: string;
+  timezone?: string;
+  url?: string;
+  /** Meeting join URL, stored as an RFC 7986 CONFERENCE property. */
+  conference?: string;
+  /** When `conference` is empty, ask the calendar's conferencing webhook for a meeting link. Ignored when the calendar has no webhook. */
+  requestConference?: boolean;
+  /** CSS3 color name or `#RRGGBB` value, stored as an RFC 7986 COLOR property. Hex values map to the nearest named color. */
+  color?: string;
+  /** Badge image URL, stored as an RFC 7986 IMAGE property. */
+  image?: string;
+  /** iCalendar status value. */
+  status?: string;
+  categories?: string[];
+  /** iCalendar CLASS value. */
+  class?: string;
+  /** iCalendar TRANSP value. */
+  transparency?: string;
+  organizer?: string;
+  attendees?: string[];
+  attachments?: string[];
+  reminders?: number[];
+  recurrence?: StructuredRecurrence;
+}
+
+export interface StructuredRecurrence {
+  frequency?: string;
+  interval?: number;
+  count?: number;
+  until?: string;
+  byDay?: string[];
+  byMonth?: number;
+  byMonthDay?: number;
+}
+
+export interface SyncActivity {
+  since: string;
+  collections: ({
+    type: "calendar" | "addressbook";
+    id: number;
+    name: string;
+    devices: SyncDevice[];
+  })[];
+}
+
+export interface SyncDevice {
+  appPasswordId?: number;
+  appPasswordLabel?: string;
+  userAgent: string;
+  /** Client family derived from the User-Agent. */
+  client: string;
+  lastSyncedAt: string;
+  syncCount: number;
+}
+
+export interface TransferCalendarRequest {
+  /** The calendar's new owner. */
+  userId: number;
+}
+
+/** The event cannot be reached in time from the one before it. */
+export interface TravelWarning {
+  fromUid: string;
+  fromSummary: string;
+  /** Straight-line distance between the two events. */
+  distanceKm: number;
+  /** Time needed to cover the distance at the assumed speed. */
+  travelMinutes: number;
+  /** Time from the end of the previous event to the start of this one; zero when they overlap. */
+  gapMinutes: number;
+}
+
+export interface UsageSnapshot {
+  collectedAt: string;
+  users: number;
+  /** Users who signed in or synced in the 30 days before the snapshot. */
+  activeUsers: number;
+  calendars: number;
+  addressBooks: number;
+  events: number;
+  contacts: number;
+  /** Devices that synced in the last 30 days, by client family such as `ios` or `davx5`. */
+  clientFamilies: Record<string, number>;
+}
+
+export interface UsageStats {
+  enabled: boolean;
+  /** Time between snapshots; absent when snapshots are off. */
+  interval?: string;
+  snapshots: UsageSnapshot[];
+}
+
+export interface User {
+  id: number;
+  email: string;
+  createdAt: string;
+}
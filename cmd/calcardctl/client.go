@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// pageSize matches the REST API's maximum list limit.
+	pageSize = 1000
+	// auditLogLimit matches the sign-in history's maximum limit.
+	auditLogLimit = 500
+)
+
+// client talks to the CalCard REST API using app-password Basic auth.
+type client struct {
+	baseURL  *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	body := strings.TrimSpace(e.Body)
+	if body == "" {
+		return fmt.Sprintf("server returned %d %s", e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, body)
+}
+
+type calendar struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	OwnerEmail  string  `json:"ownerEmail"`
+	Shared      bool    `json:"shared"`
+}
+
+type event struct {
+	UID    string `json:"uid"`
+	RawICS string `json:"rawIcal"`
+}
+
+type addressBook struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Shared   bool   `json:"shared"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+type contact struct {
+	UID      string `json:"uid"`
+	RawVCard string `json:"rawVcard"`
+}
+
+type user struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+type migrationStatus struct {
+	DatabaseVersion string `json:"databaseVersion"`
+	PreviousVersion string `json:"previousVersion,omitempty"`
+}
+
+type device struct {
+	ID         int64   `json:"id"`
+	Label      string  `json:"label"`
+	APIRole    string  `json:"apiRole"`
+	ExpiresAt  *string `json:"expiresAt,omitempty"`
+	RevokedAt  *string `json:"revokedAt,omitempty"`
+	LastSeenAt *string `json:"lastSeenAt,omitempty"`
+	Current    bool    `json:"current"`
+	// Password is only set by rotateDevice.
+	Password string `json:"password,omitempty"`
+}
+
+type authEvent struct {
+	ID               int64  `json:"id"`
+	Outcome          string `json:"outcome"`
+	NewAddress       bool   `json:"newAddress"`
+	IP               string `json:"ip"`
+	Client           string `json:"client,omitempty"`
+	AppPasswordLabel string `json:"appPasswordLabel,omitempty"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+func newClient(rawURL, username, password string) (*client, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return nil, fmt.Errorf("server URL is required (set -url or CALCARD_URL)")
+	}
+	base, err := url.Parse(rawURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", rawURL)
+	}
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("username and app password are required (set -user/-password or CALCARD_USER/CALCARD_APP_PASSWORD)")
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return &client{
+		baseURL:  base,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (c *client) endpoint(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path = c.baseURL.Path + "/api" + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (c *client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(path, query), body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "calcardctl")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &apiError{Status: resp.StatusCode, Body: string(msg)}
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) listCalendars(ctx context.Context) ([]calendar, error) {
+	var out []calendar
+	err := c.do(ctx, http.MethodGet, "/calendars", nil, "", nil, &out)
+	return out, err
+}
+
+func (c *client) getCalendar(ctx context.Context, id int64) (*calendar, error) {
+	var out calendar
+	if err := c.do(ctx, http.MethodGet, "/calendars/"+strconv.FormatInt(id, 10), nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) listEvents(ctx context.Context, calendarID int64) ([]event, error) {
+	var all []event
+	for offset := 0; ; offset += pageSize {
+		var page []event
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}, "offset": {strconv.Itoa(offset)}}
+		if err := c.do(ctx, http.MethodGet, "/calendars/"+strconv.FormatInt(calendarID, 10)+"/events", query, "", nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+func (c *client) putEvent(ctx context.Context, calendarID int64, rawICS string) error {
+	return c.do(ctx, http.MethodPost, "/calendars/"+strconv.FormatInt(calendarID, 10)+"/events", nil, "text/calendar; charset=utf-8", strings.NewReader(rawICS), nil)
+}
+
+func (c *client) listAddressBooks(ctx context.Context) ([]addressBook, error) {
+	var out []addressBook
+	err := c.do(ctx, http.MethodGet, "/addressbooks", nil, "", nil, &out)
+	return out, err
+}
+
+func (c *client) listContacts(ctx context.Context, bookID int64) ([]contact, error) {
+	var all []contact
+	for offset := 0; ; offset += pageSize {
+		var page []contact
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}, "offset": {strconv.Itoa(offset)}}
+		if err := c.do(ctx, http.MethodGet, "/addressbooks/"+strconv.FormatInt(bookID, 10)+"/contacts", query, "", nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+func (c *client) putContact(ctx context.Context, bookID int64, rawVCard string) error {
+	return c.do(ctx, http.MethodPost, "/addressbooks/"+strconv.FormatInt(bookID, 10)+"/contacts", nil, "text/vcard; charset=utf-8", strings.NewReader(rawVCard), nil)
+}
+
+func (c *client) createUser(ctx context.Context, email string) (*user, error) {
+	body, err := json.Marshal(map[string]string{"email": email})
+	if err != nil {
+		return nil, err
+	}
+	var out user
+	if err := c.do(ctx, http.MethodPost, "/admin/users", nil, "application/json", bytes.NewReader(body), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) migrationStatus(ctx context.Context) (*migrationStatus, error) {
+	var out migrationStatus
+	if err := c.do(ctx, http.MethodGet, "/admin/migrations", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) runMigrations(ctx context.Context) (*migrationStatus, error) {
+	var out migrationStatus
+	if err := c.do(ctx, http.MethodPost, "/admin/migrations", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) listDevices(ctx context.Context) ([]device, error) {
+	var out []device
+	err := c.do(ctx, http.MethodGet, "/devices", nil, "", nil, &out)
+	return out, err
+}
+
+func (c *client) rotateDevice(ctx context.Context, id int64) (*device, error) {
+	var out device
+	if err := c.do(ctx, http.MethodPost, "/devices/"+strconv.FormatInt(id, 10)+"/rotate", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) listAuthEvents(ctx context.Context, limit int) ([]authEvent, error) {
+	var out []authEvent
+	err := c.do(ctx, http.MethodGet, "/auth-events", url.Values{"limit": {strconv.Itoa(limit)}}, "", nil, &out)
+	return out, err
+}
@@ -0,0 +1,415 @@
+// Command calcardctl is a scripting client for the CalCard REST API. It
+// authenticates with an app password, the same credential DAV clients use.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const usage = `Usage: calcardctl [flags] <command> [args]
+
+Commands:
+  calendars                          List accessible calendars
+  addressbooks                       List accessible address books
+  export-ics <calendar-id> [file]    Write a calendar as a single .ics (stdout by default)
+  import-ics <calendar-id> <file>    Import every event in an .ics file ("-" reads stdin)
+  export-vcf <book-id> [file]        Write an address book as a .vcf (stdout by default)
+  import-vcf <book-id> <file>        Import every contact in a .vcf file ("-" reads stdin)
+  devices                            List your app passwords
+  rotate-password <device-id>        Replace an app password with a new one and print it
+  audit-log [-f]                     Show your recent sign-ins; -f keeps printing new ones
+  create-user <email>                Create an account its owner claims on first sign-in (admin)
+  migrations                         Show the database schema version (admin)
+  migrate                            Apply database migrations the server has not run (admin)
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("calcardctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	serverURL := flags.String("url", os.Getenv("CALCARD_URL"), "server base URL (env CALCARD_URL)")
+	username := flags.String("user", os.Getenv("CALCARD_USER"), "account email (env CALCARD_USER)")
+	password := flags.String("password", os.Getenv("CALCARD_APP_PASSWORD"), "app password (env CALCARD_APP_PASSWORD)")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "calcardctl: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+	cmdArgs := flags.Args()[1:]
+	if len(cmdArgs) < cmd.minArgs || len(cmdArgs) > cmd.maxArgs {
+		fmt.Fprintf(stderr, "calcardctl: wrong number of arguments for %s\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+
+	c, err := newClient(*serverURL, *username, *password)
+	if err != nil {
+		fmt.Fprintf(stderr, "calcardctl: %v\n", err)
+		return 2
+	}
+	env := &cmdEnv{client: c, stdin: stdin, stdout: stdout, stderr: stderr}
+	if err := cmd.run(ctx, env, cmdArgs); err != nil {
+		fmt.Fprintf(stderr, "calcardctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type cmdEnv struct {
+	client *client
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	minArgs int
+	maxArgs int
+	run     func(ctx context.Context, env *cmdEnv, args []string) error
+}
+
+var commands = map[string]command{
+	"calendars":    {0, 0, listCalendarsCmd},
+	"addressbooks": {0, 0, listAddressBooksCmd},
+	"export-ics":   {1, 2, exportICSCmd},
+	"import-ics":   {2, 2, importICSCmd},
+	"export-vcf":   {1, 2, exportVCFCmd},
+	"import-vcf":   {2, 2, importVCFCmd},
+
+	"devices":         {0, 0, listDevicesCmd},
+	"rotate-password": {1, 1, rotatePasswordCmd},
+	"audit-log":       {0, 1, auditLogCmd},
+	"create-user":     {1, 1, createUserCmd},
+	"migrations":      {0, 0, migrationsCmd},
+	"migrate":         {0, 0, migrateCmd},
+}
+
+// auditPollInterval is how often audit-log -f asks for new sign-ins.
+var auditPollInterval = 5 * time.Second
+
+func listCalendarsCmd(ctx context.Context, env *cmdEnv, _ []string) error {
+	cals, err := env.client.listCalendars(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tOWNER\tSHARED")
+	for _, cal := range cals {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\n", cal.ID, cal.Name, cal.OwnerEmail, cal.Shared)
+	}
+	return tw.Flush()
+}
+
+func listAddressBooksCmd(ctx context.Context, env *cmdEnv, _ []string) error {
+	books, err := env.client.listAddressBooks(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSHARED\tREAD-ONLY")
+	for _, book := range books {
+		fmt.Fprintf(tw, "%d\t%s\t%t\t%t\n", book.ID, book.Name, book.Shared, book.ReadOnly)
+	}
+	return tw.Flush()
+}
+
+func exportICSCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	calendarID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	cal, err := env.client.getCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+	events, err := env.client.listEvents(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+	raw := make([]string, 0, len(events))
+	for _, ev := range events {
+		raw = append(raw, ev.RawICS)
+	}
+	info := utils.CalendarExportInfo{Name: cal.Name}
+	if cal.Description != nil {
+		info.Description = *cal.Description
+	}
+	if cal.Color != nil {
+		info.Color = *cal.Color
+	}
+	if err := writeOutput(env, args[1:], utils.BuildCalendarExport(info, raw)); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "exported %d event(s)\n", len(events))
+	return nil
+}
+
+func importICSCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	calendarID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	content, err := readInput(env, args[1])
+	if err != nil {
+		return err
+	}
+	events, err := utils.ParseICSFile(content)
+	if err != nil {
+		return fmt.Errorf("invalid ICS file: %w", err)
+	}
+	return importEach(env, "event", events, func(raw string) error {
+		if utils.ExtractUID(raw) == "" {
+			raw = utils.EnsureUID(raw, utils.GenerateUID())
+		}
+		return env.client.putEvent(ctx, calendarID, stripICalMethod(raw))
+	})
+}
+
+func exportVCFCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	bookID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	contacts, err := env.client.listContacts(ctx, bookID)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, c := range contacts {
+		b.WriteString(c.RawVCard)
+		if !strings.HasSuffix(c.RawVCard, "\n") {
+			b.WriteString("\r\n")
+		}
+	}
+	if err := writeOutput(env, args[1:], b.String()); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "exported %d contact(s)\n", len(contacts))
+	return nil
+}
+
+func importVCFCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	bookID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	content, err := readInput(env, args[1])
+	if err != nil {
+		return err
+	}
+	cards, err := utils.ParseVCFFile(content)
+	if err != nil {
+		return fmt.Errorf("invalid VCF file: %w", err)
+	}
+	return importEach(env, "contact", cards, func(raw string) error {
+		return env.client.putContact(ctx, bookID, raw)
+	})
+}
+
+func listDevicesCmd(ctx context.Context, env *cmdEnv, _ []string) error {
+	devices, err := env.client.listDevices(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLABEL\tROLE\tLAST SEEN\tSTATUS")
+	for _, d := range devices {
+		status := "active"
+		switch {
+		case d.RevokedAt != nil:
+			status = "revoked"
+		case d.Current:
+			status = "current"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", d.ID, d.Label, d.APIRole, optional(d.LastSeenAt), status)
+	}
+	return tw.Flush()
+}
+
+func rotatePasswordCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	d, err := env.client.rotateDevice(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.stdout, d.Password)
+	fmt.Fprintf(env.stderr, "replaced device %d with %d (%s); the old password no longer works\n", id, d.ID, d.Label)
+	return nil
+}
+
+// auditLogCmd prints the caller's sign-ins oldest first. With -f it keeps
+// polling and prints new ones until interrupted.
+func auditLogCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	follow := false
+	if len(args) == 1 {
+		if args[0] != "-f" && args[0] != "--follow" {
+			return fmt.Errorf("unknown audit-log option %q", args[0])
+		}
+		follow = true
+	}
+	tw := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tOUTCOME\tIP\tCLIENT\tDEVICE")
+	var lastID int64
+	for {
+		events, err := env.client.listAuthEvents(ctx, auditLogLimit)
+		if err != nil {
+			if follow && ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			e := events[i]
+			if e.ID <= lastID {
+				continue
+			}
+			outcome := e.Outcome
+			if e.NewAddress {
+				outcome += " (new address)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.CreatedAt, outcome, e.IP, e.Client, e.AppPasswordLabel)
+			lastID = e.ID
+		}
+		if err := tw.Flush(); err != nil || !follow {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(auditPollInterval):
+		}
+	}
+}
+
+func createUserCmd(ctx context.Context, env *cmdEnv, args []string) error {
+	u, err := env.client.createUser(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "%d\t%s\n", u.ID, u.Email)
+	return nil
+}
+
+func migrationsCmd(ctx context.Context, env *cmdEnv, _ []string) error {
+	status, err := env.client.migrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "database version %s\n", status.DatabaseVersion)
+	return nil
+}
+
+func migrateCmd(ctx context.Context, env *cmdEnv, _ []string) error {
+	status, err := env.client.runMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if status.PreviousVersion == "" {
+		fmt.Fprintf(env.stdout, "database is up to date (version %s)\n", status.DatabaseVersion)
+		return nil
+	}
+	fmt.Fprintf(env.stdout, "migrated database from %s to %s\n", status.PreviousVersion, status.DatabaseVersion)
+	return nil
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return *s
+}
+
+// importEach uploads every item, reporting failures per item so one bad
+// object does not abort a large import.
+func importEach(env *cmdEnv, kind string, items []string, upload func(string) error) error {
+	failed := 0
+	for i, raw := range items {
+		if err := upload(raw); err != nil {
+			failed++
+			fmt.Fprintf(env.stderr, "%s %d: %v\n", kind, i+1, err)
+		}
+	}
+	fmt.Fprintf(env.stderr, "imported %d %s(s), %d failed\n", len(items)-failed, kind, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s(s) failed to import", failed, len(items), kind)
+	}
+	return nil
+}
+
+// stripICalMethod drops iTIP METHOD lines, which the server rejects on
+// stored calendar objects (RFC 4791 section 4.1).
+func stripICalMethod(raw string) string {
+	lines := utils.UnfoldLines(raw)
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "METHOD:") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\r\n")
+}
+
+func parseID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid id %q", raw)
+	}
+	return id, nil
+}
+
+func readInput(env *cmdEnv, name string) (string, error) {
+	if name == "-" {
+		data, err := io.ReadAll(env.stdin)
+		return string(data), err
+	}
+	data, err := os.ReadFile(name)
+	return string(data), err
+}
+
+func writeOutput(env *cmdEnv, args []string, content string) error {
+	if len(args) == 0 || args[0] == "-" {
+		_, err := io.WriteString(env.stdout, content)
+		return err
+	}
+	return os.WriteFile(args[0], []byte(content), 0o600)
+}
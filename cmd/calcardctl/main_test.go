@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAPI struct {
+	mu         sync.Mutex
+	events     []event
+	uploaded   []string
+	reject     string
+	authEvents []authEvent
+	polled     chan struct{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "owner@example.com" || pass != "secret" {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/calendars":
+		_ = json.NewEncoder(w).Encode([]calendar{{ID: 7, Name: "Family", OwnerEmail: "owner@example.com"}})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/calendars/7":
+		_ = json.NewEncoder(w).Encode(calendar{ID: 7, Name: "Family"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/calendars/7/events":
+		var offset int
+		fmt.Sscanf(r.URL.Query().Get("offset"), "%d", &offset)
+		end := offset + pageSize
+		if end > len(f.events) {
+			end = len(f.events)
+		}
+		page := []event{}
+		if offset < len(f.events) {
+			page = f.events[offset:end]
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/calendars/7/events":
+		body, _ := io.ReadAll(r.Body)
+		if f.reject != "" && strings.Contains(string(body), f.reject) {
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
+		f.uploaded = append(f.uploaded, string(body))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/admin/users":
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(user{ID: 12, Email: req["email"]})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/admin/migrations":
+		_ = json.NewEncoder(w).Encode(migrationStatus{DatabaseVersion: "1.1.45", PreviousVersion: "1.1.43"})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/devices/3/rotate":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(device{ID: 9, Label: "Laptop", Password: "new-secret"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/auth-events":
+		_ = json.NewEncoder(w).Encode(f.authEvents)
+		if f.polled != nil {
+			f.polled <- struct{}{}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func runCLI(t *testing.T, srv *httptest.Server, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	full := append([]string{"-url", srv.URL, "-user", "owner@example.com", "-password", "secret"}, args...)
+	code := run(context.Background(), full, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func testEvent(uid string) event {
+	return event{UID: uid, RawICS: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART:20260101T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"}
+}
+
+func TestListCalendars(t *testing.T) {
+	srv := httptest.NewServer(&fakeAPI{})
+	defer srv.Close()
+
+	code, stdout, stderr := runCLI(t, srv, "", "calendars")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "7   Family  owner@example.com  false") {
+		t.Fatalf("unexpected output:\n%s", stdout)
+	}
+}
+
+func TestExportICSPagesThroughEvents(t *testing.T) {
+	api := &fakeAPI{}
+	for i := 0; i < pageSize+3; i++ {
+		api.events = append(api.events, testEvent(fmt.Sprintf("ev-%d", i)))
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	code, stdout, stderr := runCLI(t, srv, "", "export-ics", "7")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if got := strings.Count(stdout, "BEGIN:VEVENT"); got != pageSize+3 {
+		t.Fatalf("exported %d events, want %d", got, pageSize+3)
+	}
+	if !strings.Contains(stdout, "X-WR-CALNAME:Family") || strings.Count(stdout, "BEGIN:VCALENDAR") != 1 {
+		t.Fatalf("expected a single named VCALENDAR, got:\n%s", stdout[:200])
+	}
+}
+
+func TestImportICS(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:PUBLISH\r\n" +
+		"BEGIN:VEVENT\r\nUID:one\r\nDTSTART:20260101T100000Z\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:two\r\nDTSTART:20260102T100000Z\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	tests := []struct {
+		name         string
+		reject       string
+		wantCode     int
+		wantUploaded int
+	}{
+		{name: "all succeed", wantCode: 0, wantUploaded: 2},
+		{name: "partial failure", reject: "UID:two", wantCode: 1, wantUploaded: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{reject: tt.reject}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			code, _, stderr := runCLI(t, srv, ics, "import-ics", "7", "-")
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d (stderr %s)", code, tt.wantCode, stderr)
+			}
+			if len(api.uploaded) != tt.wantUploaded {
+				t.Fatalf("uploaded %d events, want %d", len(api.uploaded), tt.wantUploaded)
+			}
+			for _, body := range api.uploaded {
+				if strings.Contains(body, "METHOD:") {
+					t.Fatalf("expected METHOD to be stripped, got:\n%s", body)
+				}
+			}
+		})
+	}
+}
+
+func TestRunRejectsBadInvocations(t *testing.T) {
+	srv := httptest.NewServer(&fakeAPI{})
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "unknown command", args: []string{"-url", srv.URL, "-user", "u", "-password", "p", "frobnicate"}, wantCode: 2, wantErr: "unknown command"},
+		{name: "missing args", args: []string{"-url", srv.URL, "-user", "u", "-password", "p", "import-ics", "7"}, wantCode: 2, wantErr: "wrong number of arguments"},
+		{name: "missing credentials", args: []string{"-url", srv.URL, "-user", "", "-password", "", "calendars"}, wantCode: 2, wantErr: "app password are required"},
+		{name: "bad credentials", args: []string{"-url", srv.URL, "-user", "u", "-password", "p", "calendars"}, wantCode: 1, wantErr: "server returned 401"},
+		{name: "invalid id", args: []string{"-url", srv.URL, "-user", "u", "-password", "p", "export-ics", "abc"}, wantCode: 1, wantErr: `invalid id "abc"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), tt.args, strings.NewReader(""), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d (stderr %s)", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Fatalf("stderr = %q, want substring %q", stderr.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	srv := httptest.NewServer(&fakeAPI{})
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"create-user", "new@example.com"}, want: "12\tnew@example.com\n"},
+		{args: []string{"migrate"}, want: "migrated database from 1.1.43 to 1.1.45\n"},
+		{args: []string{"rotate-password", "3"}, want: "new-secret\n"},
+	} {
+		code, stdout, stderr := runCLI(t, srv, "", tt.args...)
+		if code != 0 || stdout != tt.want {
+			t.Errorf("%v = %d %q (stderr %s), want %q", tt.args, code, stdout, stderr, tt.want)
+		}
+	}
+}
+
+func TestAuditLogFollowPrintsNewSignInsOnce(t *testing.T) {
+	api := &fakeAPI{
+		authEvents: []authEvent{{ID: 2, Outcome: "success", IP: "203.0.113.7"}, {ID: 1, Outcome: "failure", IP: "198.51.100.1"}},
+		polled:     make(chan struct{}),
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	defer func(d time.Duration) { auditPollInterval = d }(auditPollInterval)
+	auditPollInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr bytes.Buffer
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"-url", srv.URL, "-user", "owner@example.com", "-password", "secret", "audit-log", "-f"}, strings.NewReader(""), &stdout, &stderr)
+	}()
+	<-api.polled
+	api.mu.Lock()
+	api.authEvents = append([]authEvent{{ID: 3, Outcome: "success", IP: "192.0.2.4"}}, api.authEvents...)
+	api.mu.Unlock()
+	<-api.polled
+	<-api.polled
+	cancel()
+	go func() {
+		for range api.polled {
+		}
+	}()
+	if code := <-done; code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if strings.Count(out, "198.51.100.1") != 1 || strings.Count(out, "192.0.2.4") != 1 ||
+		strings.Index(out, "198.51.100.1") > strings.Index(out, "203.0.113.7") || strings.Index(out, "203.0.113.7") > strings.Index(out, "192.0.2.4") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
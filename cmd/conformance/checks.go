@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errSkipped marks a check that could not run because an earlier one failed.
+var errSkipped = errors.New("skipped")
+
+// check is one entry of the battery. Checks run in order and share the
+// suite's state, so a later check can use what an earlier one created.
+type check struct {
+	area string
+	name string
+	run  func(ctx context.Context, s *suite) (string, error)
+}
+
+type result struct {
+	check
+	note string
+	err  error
+}
+
+func (r result) failed() bool { return r.err != nil && !errors.Is(r.err, errSkipped) }
+
+func (r result) outcome() string {
+	switch {
+	case r.err == nil:
+		return "PASS"
+	case errors.Is(r.err, errSkipped):
+		return "SKIP"
+	default:
+		return "FAIL"
+	}
+}
+
+func (r result) detail() string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	return r.note
+}
+
+// suite is the state built up by the checks: the discovered collections,
+// the test calendar and the event written to it.
+type suite struct {
+	client    *client
+	runID     string
+	root      string
+	principal string
+	home      string
+	calendar  string
+	uid       string
+	event     string
+	etag      string
+	syncToken string
+	start     time.Time
+}
+
+func newSuite(c *client) *suite {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	start := time.Now().UTC().Truncate(time.Hour).Add(48 * time.Hour)
+	return &suite{client: c, runID: id, uid: "conformance-" + id, start: start}
+}
+
+var battery = []check{
+	{"discovery", "well-known redirect", checkWellKnown},
+	{"discovery", "current-user-principal", checkPrincipal},
+	{"discovery", "calendar-home-set", checkHomeSet},
+	{"discovery", "calendar-access advertised", checkOptions},
+	{"collections", "MKCALENDAR", checkMkcalendar},
+	{"collections", "calendar resourcetype", checkResourceType},
+	{"objects", "PUT new event", checkPutCreate},
+	{"objects", "If-None-Match on existing", checkPutExisting},
+	{"objects", "GET event", checkGet},
+	{"objects", "If-Match with stale ETag", checkStaleIfMatch},
+	{"objects", "PUT update", checkPutUpdate},
+	{"reports", "calendar-query time-range", checkCalendarQuery},
+	{"reports", "calendar-multiget", checkMultiget},
+	{"sync", "sync-collection initial", checkSyncInitial},
+	{"objects", "DELETE event", checkDelete},
+	{"sync", "sync-collection after delete", checkSyncDelta},
+	{"collections", "DELETE calendar", checkDeleteCalendar},
+}
+
+// run executes the battery. The test calendar is deleted by the last check
+// even when others fail.
+func (s *suite) run(ctx context.Context) []result {
+	results := make([]result, 0, len(battery))
+	for _, c := range battery {
+		note, err := c.run(ctx, s)
+		results = append(results, result{check: c, note: note, err: err})
+	}
+	return results
+}
+
+func (s *suite) eventBody(summary string) string {
+	const layout = "20060102T150405Z"
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//CalCard//conformance//EN\r\nBEGIN:VEVENT\r\n" +
+		"UID:" + s.uid + "\r\nDTSTAMP:" + time.Now().UTC().Format(layout) + "\r\n" +
+		"DTSTART:" + s.start.Format(layout) + "\r\nDTEND:" + s.start.Add(time.Hour).Format(layout) + "\r\n" +
+		"SUMMARY:" + summary + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+func (s *suite) putEvent(ctx context.Context, summary string, header map[string]string) (*response, error) {
+	h := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	for name, value := range header {
+		h[name] = value
+	}
+	return s.client.do(ctx, http.MethodPut, s.event, h, s.eventBody(summary))
+}
+
+func wantStatus(resp *response, want ...int) error {
+	for _, status := range want {
+		if resp.Status == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("status %d, want %d", resp.Status, want[0])
+}
+
+func checkWellKnown(ctx context.Context, s *suite) (string, error) {
+	s.root = "/dav/"
+	resp, err := s.client.do(ctx, "PROPFIND", "/.well-known/caldav", map[string]string{"Depth": "0"}, "")
+	if err != nil {
+		return "", err
+	}
+	location := resp.Header.Get("Location")
+	if resp.Status < 300 || resp.Status > 399 || location == "" {
+		return "", fmt.Errorf("status %d without a redirect", resp.Status)
+	}
+	s.root = location
+	return "redirects to " + location, nil
+}
+
+func checkPrincipal(ctx context.Context, s *suite) (string, error) {
+	_, ms, err := s.client.xmlRequest(ctx, "PROPFIND", s.root, "0",
+		`<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop><D:current-user-principal/></D:prop></D:propfind>`)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range ms.Responses {
+		if p := r.prop().CurrentUserPrincipal; p != nil && p.Href != "" {
+			s.principal = strings.TrimSpace(p.Href)
+			return s.principal, nil
+		}
+	}
+	return "", errors.New("no current-user-principal in the response")
+}
+
+func checkHomeSet(ctx context.Context, s *suite) (string, error) {
+	if s.principal == "" {
+		return "", errSkipped
+	}
+	_, ms, err := s.client.xmlRequest(ctx, "PROPFIND", s.principal, "0",
+		`<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><C:calendar-home-set/></D:prop></D:propfind>`)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range ms.Responses {
+		if p := r.prop().CalendarHomeSet; p != nil && p.Href != "" {
+			s.home = strings.TrimSpace(p.Href)
+			if !strings.HasSuffix(s.home, "/") {
+				s.home += "/"
+			}
+			return s.home, nil
+		}
+	}
+	return "", errors.New("no calendar-home-set in the response")
+}
+
+func checkOptions(ctx context.Context, s *suite) (string, error) {
+	if s.home == "" {
+		return "", errSkipped
+	}
+	resp, err := s.client.do(ctx, http.MethodOptions, s.home, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(resp.Header.Get("DAV"), "calendar-access") {
+		return "", fmt.Errorf("DAV header %q lacks calendar-access", resp.Header.Get("DAV"))
+	}
+	return "", nil
+}
+
+func checkMkcalendar(ctx context.Context, s *suite) (string, error) {
+	if s.home == "" {
+		return "", errSkipped
+	}
+	target := s.home + "conformance-" + s.runID + "/"
+	resp, err := s.client.do(ctx, "MKCALENDAR", target, map[string]string{"Content-Type": `application/xml; charset="utf-8"`},
+		`<?xml version="1.0" encoding="utf-8"?><C:mkcalendar xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:set><D:prop><D:displayname>Conformance `+s.runID+`</D:displayname></D:prop></D:set></C:mkcalendar>`)
+	if err != nil {
+		return "", err
+	}
+	if err := wantStatus(resp, http.StatusCreated); err != nil {
+		return "", err
+	}
+	s.calendar = target
+	if location := resp.Header.Get("Location"); location != "" {
+		s.calendar = location
+	}
+	if !strings.HasSuffix(s.calendar, "/") {
+		s.calendar += "/"
+	}
+	s.event = s.calendar + s.uid + ".ics"
+	return s.calendar, nil
+}
+
+func checkResourceType(ctx context.Context, s *suite) (string, error) {
+	if s.calendar == "" {
+		return "", errSkipped
+	}
+	_, ms, err := s.client.xmlRequest(ctx, "PROPFIND", s.calendar, "0",
+		`<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range ms.Responses {
+		if rt := r.prop().ResourceType; rt != nil && rt.Calendar != nil {
+			return "", nil
+		}
+	}
+	return "", errors.New("resourcetype lacks calendar")
+}
+
+func checkPutCreate(ctx context.Context, s *suite) (string, error) {
+	if s.calendar == "" {
+		return "", errSkipped
+	}
+	resp, err := s.putEvent(ctx, "Conformance", map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		return "", err
+	}
+	if err := wantStatus(resp, http.StatusCreated); err != nil {
+		s.event = ""
+		return "", err
+	}
+	s.etag = resp.Header.Get("ETag")
+	if s.etag == "" {
+		return "no ETag returned", nil
+	}
+	return "", nil
+}
+
+func checkPutExisting(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	resp, err := s.putEvent(ctx, "Overwritten", map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		return "", err
+	}
+	return "", wantStatus(resp, http.StatusPreconditionFailed)
+}
+
+func checkGet(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	resp, err := s.client.do(ctx, http.MethodGet, s.event, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err := wantStatus(resp, http.StatusOK); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+		return "", fmt.Errorf("Content-Type %q, want text/calendar", resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(resp.Body, "UID:"+s.uid) || !strings.Contains(resp.Body, "SUMMARY:Conformance") {
+		return "", errors.New("body is not the event that was stored")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", errors.New("no ETag")
+	}
+	if s.etag != "" && etag != s.etag {
+		return "", fmt.Errorf("ETag %s differs from the PUT's %s", etag, s.etag)
+	}
+	s.etag = etag
+	return "", nil
+}
+
+func checkStaleIfMatch(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	resp, err := s.putEvent(ctx, "Stale", map[string]string{"If-Match": `"conformance-stale"`})
+	if err != nil {
+		return "", err
+	}
+	return "", wantStatus(resp, http.StatusPreconditionFailed)
+}
+
+func checkPutUpdate(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" || s.etag == "" {
+		return "", errSkipped
+	}
+	resp, err := s.putEvent(ctx, "Conformance updated", map[string]string{"If-Match": s.etag})
+	if err != nil {
+		return "", err
+	}
+	if err := wantStatus(resp, http.StatusNoContent, http.StatusOK, http.StatusCreated); err != nil {
+		return "", err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if etag == s.etag {
+			return "", errors.New("ETag unchanged after update")
+		}
+		s.etag = etag
+	}
+	return "", nil
+}
+
+func checkCalendarQuery(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	const layout = "20060102T150405Z"
+	_, ms, err := s.client.xmlRequest(ctx, "REPORT", s.calendar, "1",
+		`<?xml version="1.0" encoding="utf-8"?><C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop>`+
+			`<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT"><C:time-range start="`+s.start.Add(-time.Hour).Format(layout)+`" end="`+s.start.Add(2*time.Hour).Format(layout)+`"/></C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ms.find(s.uid + ".ics"); !ok {
+		return "", errors.New("event missing from a range covering it")
+	}
+	_, ms, err = s.client.xmlRequest(ctx, "REPORT", s.calendar, "1",
+		`<?xml version="1.0" encoding="utf-8"?><C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop>`+
+			`<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT"><C:time-range start="`+s.start.Add(24*time.Hour).Format(layout)+`" end="`+s.start.Add(48*time.Hour).Format(layout)+`"/></C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ms.find(s.uid + ".ics"); ok {
+		return "", errors.New("event returned for a range outside it")
+	}
+	return "", nil
+}
+
+func checkMultiget(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	_, ms, err := s.client.xmlRequest(ctx, "REPORT", s.calendar, "1",
+		`<?xml version="1.0" encoding="utf-8"?><C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/><C:calendar-data/></D:prop><D:href>`+xmlEscape(s.event)+`</D:href></C:calendar-multiget>`)
+	if err != nil {
+		return "", err
+	}
+	r, ok := ms.find(s.uid + ".ics")
+	if !ok {
+		return "", errors.New("event missing from the response")
+	}
+	if !strings.Contains(r.prop().CalendarData, "SUMMARY:Conformance updated") {
+		return "", errors.New("calendar-data is not the updated event")
+	}
+	return "", nil
+}
+
+func checkSyncInitial(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	ms, err := s.syncCollection(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	if ms.SyncToken == "" {
+		return "", errors.New("no sync-token")
+	}
+	s.syncToken = ms.SyncToken
+	if _, ok := ms.find(s.uid + ".ics"); !ok {
+		return "", errors.New("event missing from the initial sync")
+	}
+	return "", nil
+}
+
+func checkDelete(ctx context.Context, s *suite) (string, error) {
+	if s.event == "" {
+		return "", errSkipped
+	}
+	resp, err := s.client.do(ctx, http.MethodDelete, s.event, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err := wantStatus(resp, http.StatusNoContent, http.StatusOK); err != nil {
+		return "", err
+	}
+	resp, err = s.client.do(ctx, http.MethodGet, s.event, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != http.StatusNotFound {
+		return "", fmt.Errorf("GET after DELETE returned %d, want 404", resp.Status)
+	}
+	return "", nil
+}
+
+func checkSyncDelta(ctx context.Context, s *suite) (string, error) {
+	if s.syncToken == "" {
+		return "", errSkipped
+	}
+	ms, err := s.syncCollection(ctx, s.syncToken)
+	if err != nil {
+		return "", err
+	}
+	r, ok := ms.find(s.uid + ".ics")
+	if !ok {
+		return "", errors.New("deleted event missing from the delta")
+	}
+	if !strings.Contains(r.Status, " 404 ") {
+		return "", fmt.Errorf("deleted event reported as %q, want 404", r.Status)
+	}
+	if ms.SyncToken == "" || ms.SyncToken == s.syncToken {
+		return "", errors.New("sync-token did not advance")
+	}
+	return "", nil
+}
+
+func checkDeleteCalendar(ctx context.Context, s *suite) (string, error) {
+	if s.calendar == "" {
+		return "", errSkipped
+	}
+	resp, err := s.client.do(ctx, http.MethodDelete, s.calendar, nil, "")
+	if err != nil {
+		return "", err
+	}
+	return "", wantStatus(resp, http.StatusNoContent, http.StatusOK)
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func (s *suite) syncCollection(ctx context.Context, token string) (*multistatus, error) {
+	_, ms, err := s.client.xmlRequest(ctx, "REPORT", s.calendar, "",
+		`<?xml version="1.0" encoding="utf-8"?><D:sync-collection xmlns:D="DAV:"><D:sync-token>`+xmlEscape(token)+`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+	return ms, err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxBody bounds how much of a response is read.
+const maxBody = 4 << 20
+
+// client sends DAV requests with app-password Basic auth. Redirects are not
+// followed, so discovery checks can see them.
+type client struct {
+	baseURL  *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+// response is a DAV response with its body read.
+type response struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+func newClient(rawURL, username, password string) (*client, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return nil, fmt.Errorf("server URL is required (set -url or CALCARD_URL)")
+	}
+	base, err := url.Parse(rawURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", rawURL)
+	}
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("username and app password are required (set -user/-password or CALCARD_USER/CALCARD_APP_PASSWORD)")
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return &client{
+		baseURL:  base,
+		username: username,
+		password: password,
+		http: &http.Client{
+			Timeout: 60 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// resolve turns an href or absolute path from the server into a URL on it.
+func (c *client) resolve(ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return c.baseURL.String() + ref
+	}
+	if u.IsAbs() {
+		return u.String()
+	}
+	if !strings.HasPrefix(u.Path, c.baseURL.Path+"/") {
+		u.Path = c.baseURL.Path + u.Path
+	}
+	return c.baseURL.ResolveReference(u).String()
+}
+
+func (c *client) do(ctx context.Context, method, ref string, header map[string]string, body string) (*response, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.resolve(ref), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("User-Agent", "calcard-conformance")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	return &response{Status: resp.StatusCode, Header: resp.Header, Body: string(data)}, nil
+}
+
+// xmlRequest sends an XML body and parses the multistatus it returns.
+func (c *client) xmlRequest(ctx context.Context, method, ref, depth, body string) (*response, *multistatus, error) {
+	header := map[string]string{"Content-Type": `application/xml; charset="utf-8"`}
+	if depth != "" {
+		header["Depth"] = depth
+	}
+	resp, err := c.do(ctx, method, ref, header, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.Status != http.StatusMultiStatus {
+		return resp, nil, fmt.Errorf("%s returned %d, want 207", method, resp.Status)
+	}
+	var ms multistatus
+	if err := xml.Unmarshal([]byte(resp.Body), &ms); err != nil {
+		return resp, nil, fmt.Errorf("%s returned invalid XML: %v", method, err)
+	}
+	return resp, &ms, nil
+}
+
+type multistatus struct {
+	Responses []msResponse `xml:"DAV: response"`
+	SyncToken string       `xml:"DAV: sync-token"`
+}
+
+type msResponse struct {
+	Href      string     `xml:"DAV: href"`
+	Status    string     `xml:"DAV: status"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	CurrentUserPrincipal *hrefProp `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      *hrefProp `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	ResourceType         *struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	GetETag      string `xml:"DAV: getetag"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type hrefProp struct {
+	Href string `xml:"DAV: href"`
+}
+
+// prop merges the properties of r's propstats that were found.
+func (r msResponse) prop() davProp {
+	var merged davProp
+	for _, ps := range r.Propstats {
+		if !strings.Contains(ps.Status, " 200 ") {
+			continue
+		}
+		p := ps.Prop
+		if p.CurrentUserPrincipal != nil {
+			merged.CurrentUserPrincipal = p.CurrentUserPrincipal
+		}
+		if p.CalendarHomeSet != nil {
+			merged.CalendarHomeSet = p.CalendarHomeSet
+		}
+		if p.ResourceType != nil {
+			merged.ResourceType = p.ResourceType
+		}
+		if p.GetETag != "" {
+			merged.GetETag = p.GetETag
+		}
+		if p.CalendarData != "" {
+			merged.CalendarData = p.CalendarData
+		}
+	}
+	return merged
+}
+
+// find returns the response whose href names the resource name, if any.
+func (m *multistatus) find(name string) (msResponse, bool) {
+	for _, r := range m.Responses {
+		href := r.Href
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		if strings.HasSuffix(strings.TrimSuffix(href, "/"), "/"+name) {
+			return r, true
+		}
+	}
+	return msResponse{}, false
+}
@@ -0,0 +1,87 @@
+// Command conformance runs a CalDAV client battery against a running CalCard
+// server and prints a compliance matrix. It signs in with an app password,
+// works in a calendar it creates for the run, and deletes that calendar when
+// done, so it is safe to point at a production account after an upgrade.
+//
+// Given interop recordings made with an "interop:<profile>" app password, it
+// replays those instead and reports where the server's answers drifted.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+const usage = `Usage: conformance [flags] [recording.jsonl ...]
+
+Runs discovery, MKCALENDAR, PUT/GET/DELETE, REPORT and sync-collection checks
+against a CalCard server and prints one line per check. Exits 1 when any
+check fails.
+
+With recordings, replays the requests of each one in order instead and
+prints one line per recording. Replay against a test account in the same
+state as the recorded one was when recording started.
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	serverURL := flags.String("url", os.Getenv("CALCARD_URL"), "server base URL (env CALCARD_URL)")
+	username := flags.String("user", os.Getenv("CALCARD_USER"), "account email (env CALCARD_USER)")
+	password := flags.String("password", os.Getenv("CALCARD_APP_PASSWORD"), "app password (env CALCARD_APP_PASSWORD)")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	c, err := newClient(*serverURL, *username, *password)
+	if err != nil {
+		fmt.Fprintf(stderr, "conformance: %v\n", err)
+		return 2
+	}
+	var results []result
+	if flags.NArg() > 0 {
+		results = replay(ctx, c, flags.Args())
+	} else {
+		results = newSuite(c).run(ctx)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AREA\tCHECK\tRESULT\tDETAIL")
+	failed := 0
+	for _, res := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.area, res.name, res.outcome(), res.detail())
+		if res.failed() {
+			failed++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "conformance: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "%d of %d check(s) failed\n", failed, len(results))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDAV is just enough of a CalDAV server for the battery: one principal,
+// calendars under /dav/calendars/ and a change log for sync-collection.
+type fakeDAV struct {
+	mu        sync.Mutex
+	calendars map[string]bool
+	objects   map[string]string
+	deleted   []string
+	changes   int
+	// ignoreIfMatch breaks conditional PUTs, as a faulty server would.
+	ignoreIfMatch bool
+}
+
+func newFakeDAV() *fakeDAV {
+	return &fakeDAV{calendars: map[string]bool{}, objects: map[string]string{}}
+}
+
+func (f *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "owner@example.com" || pass != "secret" {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	p := r.URL.Path
+	multistatus := func(responses string) {
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">%s<D:sync-token>tok-%d</D:sync-token></D:multistatus>`, responses, f.changes)
+	}
+	found := func(href, props string) string {
+		return `<D:response><D:href>` + href + `</D:href><D:propstat><D:prop>` + props + `</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`
+	}
+	etag := func(raw string) string { return fmt.Sprintf(`"%d"`, len(raw)) }
+	switch {
+	case p == "/.well-known/caldav":
+		http.Redirect(w, r, "/dav/", http.StatusMovedPermanently)
+	case r.Method == "PROPFIND" && p == "/dav/":
+		multistatus(found(p, `<D:current-user-principal><D:href>/dav/principals/1/</D:href></D:current-user-principal>`))
+	case r.Method == "PROPFIND" && p == "/dav/principals/1/":
+		multistatus(found(p, `<C:calendar-home-set><D:href>/dav/calendars/</D:href></C:calendar-home-set>`))
+	case r.Method == http.MethodOptions:
+		w.Header().Set("DAV", "1, 2, 3, calendar-access")
+	case r.Method == "MKCALENDAR":
+		f.calendars[p] = true
+		w.Header().Set("Location", strings.TrimSuffix(p, "/"))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PROPFIND" && f.calendars[p]:
+		multistatus(found(p, `<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>`))
+	case r.Method == http.MethodPut:
+		current, exists := f.objects[p]
+		if (r.Header.Get("If-None-Match") == "*" && exists) || (!f.ignoreIfMatch && r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag(current)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.objects[p] = string(body)
+		f.changes++
+		w.Header().Set("ETag", etag(string(body)))
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case r.Method == http.MethodGet:
+		raw, ok := f.objects[p]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("ETag", etag(raw))
+		_, _ = io.WriteString(w, raw)
+	case r.Method == http.MethodDelete && f.calendars[p]:
+		delete(f.calendars, p)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, p)
+		f.deleted = append(f.deleted, p)
+		f.changes++
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "REPORT":
+		var responses strings.Builder
+		for href, raw := range f.objects {
+			if inTimeRange(string(body), raw) {
+				responses.WriteString(found(href, `<D:getetag>`+etag(raw)+`</D:getetag><C:calendar-data>`+raw+`</C:calendar-data>`))
+			}
+		}
+		if bytes.Contains(body, []byte("<D:sync-token>tok-")) {
+			for _, href := range f.deleted {
+				responses.WriteString(`<D:response><D:href>` + href + `</D:href><D:status>HTTP/1.1 404 Not Found</D:status></D:response>`)
+			}
+		}
+		multistatus(responses.String())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// inTimeRange reports whether the event starts inside the report's
+// time-range, or the report has none. UTC values compare as strings.
+func inTimeRange(report, raw string) bool {
+	attr := func(name string) string {
+		_, rest, _ := strings.Cut(report, name+`="`)
+		value, _, _ := strings.Cut(rest, `"`)
+		return value
+	}
+	start, end := attr("start"), attr("end")
+	if start == "" {
+		return true
+	}
+	_, rest, _ := strings.Cut(raw, "DTSTART:")
+	dtstart, _, _ := strings.Cut(rest, "\r\n")
+	return dtstart >= start && dtstart < end
+}
+
+func runBattery(t *testing.T, srv *httptest.Server) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-url", srv.URL, "-user", "owner@example.com", "-password", "secret"}, &stdout, &stderr)
+	return code, stdout.String()
+}
+
+func TestBatteryPassesAgainstConformingServer(t *testing.T) {
+	dav := newFakeDAV()
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+
+	code, out := runBattery(t, srv)
+	if code != 0 || strings.Contains(out, "FAIL") || strings.Contains(out, "SKIP") {
+		t.Fatalf("exit code = %d, matrix:\n%s", code, out)
+	}
+	if got := strings.Count(out, "PASS"); got != len(battery) {
+		t.Fatalf("%d checks passed, want %d:\n%s", got, len(battery), out)
+	}
+	if len(dav.calendars) != 0 || len(dav.objects) != 0 {
+		t.Fatalf("test calendar left behind: %v %v", dav.calendars, dav.objects)
+	}
+}
+
+func TestBatteryReportsFailures(t *testing.T) {
+	dav := newFakeDAV()
+	dav.ignoreIfMatch = true
+	srv := httptest.NewServer(dav)
+	defer srv.Close()
+
+	code, out := runBattery(t, srv)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "If-Match with stale ETag") && !strings.Contains(line, "FAIL") {
+			t.Fatalf("stale If-Match not reported as failing:\n%s", out)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"-url", srv.URL}, &stdout, &stderr); code != 2 {
+		t.Fatalf("missing credentials exit code = %d, want 2", code)
+	}
+}
+
+func TestReplayReportsDriftFromRecording(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"method":"MKCALENDAR","path":"/dav/calendars/replay/","status":201,"responseHeader":{"Location":"/dav/calendars/replay"}}`,
+		`{"method":"PUT","path":"/dav/calendars/replay/a.ics","header":{"If-None-Match":"*","User-Agent":"iOS/17.4 (21E219) dataaccessd/1.0"},"body":"BEGIN:VCALENDAR\r\nDTSTAMP:20260101T000000Z\r\nEND:VCALENDAR\r\n","status":201,"responseHeader":{"ETag":"\"{etag1}\""}}`,
+		`{"method":"PUT","path":"/dav/calendars/replay/a.ics","header":{"If-Match":"\"{etag1}\""},"body":"BEGIN:VCALENDAR\r\nDTSTAMP:20260102T000000Z\r\nEND:VCALENDAR\r\n","status":204,"responseHeader":{"ETag":"\"{etag1}\""}}`,
+		`{"method":"GET","path":"/dav/calendars/replay/a.ics","status":200,"responseHeader":{"ETag":"\"{etag1}\""},"responseBody":"BEGIN:VCALENDAR\r\nDTSTAMP:{*}\r\nEND:VCALENDAR\r\n"}`,
+		`{"method":"PUT","path":"/dav/calendars/replay/a.ics","header":{"If-Match":"\"stale\""},"body":"BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n","status":412}`,
+	}, "\n") + "\n"
+	file := t.TempDir() + "/ios17-initial-setup.jsonl"
+	if err := os.WriteFile(file, []byte(recording), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	replayAgainst := func(dav *fakeDAV) (int, string) {
+		srv := httptest.NewServer(dav)
+		defer srv.Close()
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), []string{"-url", srv.URL, "-user", "owner@example.com", "-password", "secret", file}, &stdout, &stderr)
+		return code, stdout.String()
+	}
+
+	if code, out := replayAgainst(newFakeDAV()); code != 0 || !strings.Contains(out, "ios17-initial-setup") || !strings.Contains(out, "PASS") {
+		t.Fatalf("exit code = %d, matrix:\n%s", code, out)
+	}
+
+	drifted := newFakeDAV()
+	drifted.ignoreIfMatch = true
+	code, out := replayAgainst(drifted)
+	if code != 1 || !strings.Contains(out, "request 5 (PUT /dav/calendars/replay/a.ics): status 204, recorded 412") {
+		t.Fatalf("exit code = %d, matrix:\n%s", code, out)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/interop"
+)
+
+// replay sends the requests of each interop recording to the server and
+// returns one result per recording, named after its profile.
+func replay(ctx context.Context, c *client, files []string) []result {
+	results := make([]result, 0, len(files))
+	for _, file := range files {
+		profile := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		res := result{check: check{area: "replay", name: profile}}
+		exchanges, err := readSession(file)
+		if err == nil {
+			bindings := map[string]string{"base": c.baseURL.String(), "email": c.username}
+			err = interop.Replay(ctx, exchanges, bindings, func(ctx context.Context, method, path string, header map[string]string, body string) (*interop.Response, error) {
+				resp, err := c.do(ctx, method, path, header, body)
+				if err != nil {
+					return nil, err
+				}
+				return &interop.Response{Status: resp.Status, Header: resp.Header, Body: resp.Body}, nil
+			})
+			res.note = fmt.Sprintf("%d request(s) matched", len(exchanges))
+		}
+		res.err = err
+		results = append(results, res)
+	}
+	return results
+}
+
+func readSession(file string) ([]interop.Exchange, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	exchanges, err := interop.ReadSession(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("%s: no requests recorded", file)
+	}
+	return exchanges, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jw6ventures/calcard/internal/bootstrap"
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+// configCheck implements `calcard config check [file]`: it loads the
+// configuration the server would start with, and the bootstrap file it
+// names, and reports what is wrong. A file argument takes the place of
+// APP_CONFIG_FILE.
+func configCheck(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "check" || len(args) > 2 {
+		return errors.New("usage: calcard config check [file]")
+	}
+	if len(args) == 2 {
+		if err := os.Setenv("APP_CONFIG_FILE", args[1]); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.BootstrapFile != "" {
+		if _, err := bootstrap.Load(cfg.BootstrapFile); err != nil {
+			return err
+		}
+	}
+	if path := os.Getenv("APP_CONFIG_FILE"); path != "" {
+		fmt.Fprintf(out, "configuration OK (environment and %s)\n", path)
+	} else {
+		fmt.Fprintln(out, "configuration OK (environment)")
+	}
+	return nil
+}
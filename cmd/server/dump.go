@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/jw6-go-utils/database"
+)
+
+// dumpCommand implements `calcard dump [file]`: it writes all CalCard data
+// to file, or to stdout, as an archive `calcard restore` reads. The summary
+// goes to log.
+func dumpCommand(ctx context.Context, args []string, stdout, log io.Writer) (returnedErr error) {
+	if len(args) > 1 {
+		return errors.New("usage: calcard dump [file]")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("postgres", cfg.DB.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out := stdout
+	if len(args) == 1 {
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := f.Close(); returnedErr == nil && err != nil {
+				returnedErr = err
+			}
+			if returnedErr != nil {
+				os.Remove(args[0])
+			}
+		}()
+		out = f
+	}
+	summary, err := store.New(db).Dump(ctx, out)
+	if err != nil {
+		return err
+	}
+	printDumpSummary(log, "dumped", summary)
+	return nil
+}
+
+// restoreCommand implements `calcard restore <file>`: it creates the schema
+// in an empty database and loads a `calcard dump` archive into it.
+func restoreCommand(ctx context.Context, args []string, log io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: calcard restore <file>")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+	}
+	if err := ensureDBSchema(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to create database schema %s: %w", cfg.DB.Schema, err)
+	}
+	dbManager := database.NewManager(database.Config{
+		Driver:           "postgres",
+		ConnString:       cfg.DB.DSN,
+		MigrationsPath:   "migrations",
+		AppVersion:       version,
+		SchemaPath:       "db.sql",
+		SchemaCheckQuery: store.SchemaExistsQuery,
+	})
+	if err := dbManager.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer dbManager.Close()
+
+	summary, err := store.New(dbManager.DB).Restore(ctx, f)
+	if err != nil {
+		return err
+	}
+	printDumpSummary(log, "restored", summary)
+	return nil
+}
+
+func printDumpSummary(w io.Writer, verb string, summary *store.DumpSummary) {
+	var total int64
+	for _, n := range summary.Rows {
+		total += n
+	}
+	fmt.Fprintf(w, "%s %d rows from %d tables (schema %s, taken %s)\n", verb, total, len(summary.Rows), summary.SchemaVersion, summary.CreatedAt.Format("2006-01-02 15:04:05Z07:00"))
+}
@@ -2,20 +2,39 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strings"
 	"syscall"
 	"time"
 	_ "time/tzdata"
 
 	appauth "github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/backup"
+	"github.com/jw6ventures/calcard/internal/bootstrap"
 	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/digest"
+	"github.com/jw6ventures/calcard/internal/focus"
+	"github.com/jw6ventures/calcard/internal/fsck"
 	httpserver "github.com/jw6ventures/calcard/internal/http"
+	"github.com/jw6ventures/calcard/internal/http/drain"
+	"github.com/jw6ventures/calcard/internal/http/listener"
+	"github.com/jw6ventures/calcard/internal/jobs"
+	"github.com/jw6ventures/calcard/internal/ldap"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/notify"
+	"github.com/jw6ventures/calcard/internal/preflight"
+	"github.com/jw6ventures/calcard/internal/push"
+	"github.com/jw6ventures/calcard/internal/redis"
+	"github.com/jw6ventures/calcard/internal/retention"
+	"github.com/jw6ventures/calcard/internal/scan"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/telemetry"
 	jw6_utils "github.com/jw6ventures/jw6-go-utils"
 	"github.com/jw6ventures/jw6-go-utils/database"
 )
@@ -32,11 +51,49 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := configCheck(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "dump" {
+		if err := dumpCommand(ctx, os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := restoreCommand(ctx, os.Args[2:], os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := runServer(ctx, ServerOptions{}); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// ensureDBSchema creates the schema APP_DB_SCHEMA names, if it is set and
+// missing, before the database manager creates its tables there.
+func ensureDBSchema(ctx context.Context, cfg *config.Config) error {
+	if cfg.DB.Schema == "" {
+		return nil
+	}
+	db, err := sql.Open("postgres", cfg.DB.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return store.EnsureSchema(ctx, db, cfg.DB.Schema)
+}
+
 type ServerOptions struct {
 	Router httpserver.RouterOptions
 }
@@ -48,47 +105,195 @@ func runServer(ctx context.Context, opts ServerOptions) error {
 	}
 	logLevel := jw6_utils.LogLevelFromString(logLevelString)
 
-	jw6utils := jw6_utils.Utils{LogLevel: logLevel}
+	// The sink filters by level itself so a reload can change it.
+	jw6utils := jw6_utils.Utils{LogLevel: jw6_utils.Trace}
+	logSink := logging.NewLevelSink(&jw6utils, logLevel)
 	version := "devel"
 	if info, ok := debug.ReadBuildInfo(); ok {
 		version = info.Main.Version
 	}
 	jw6utils.PrintBanner("CalCard", version, "2026", 3, "JW6 Ventures LLC")
 
-	jw6utils.Log("Main", "runServer-mainLoop", jw6_utils.Info, "Starting CalCard server...")
+	logSink.Log("Main", "runServer-mainLoop", jw6_utils.Info, "Starting CalCard server...")
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	logSink.SetLevel(jw6_utils.LogLevelFromString(cfg.LogLevel))
+	logSink.SetMaskEmails(cfg.LogPrivacy == config.LogPrivacyStrict)
 
+	if cfg.Preflight {
+		findings := preflight.Run(ctx, cfg, preflight.Options{MigrationsPath: "migrations", AppVersion: version})
+		for _, f := range findings {
+			if f.Warning {
+				logSink.Log("Main", "runServer-preflight", jw6_utils.Warn, f.String())
+			}
+		}
+		if fatal := preflight.Fatal(findings); len(fatal) > 0 {
+			problems := make([]string, len(fatal))
+			for i, f := range fatal {
+				problems[i] = "  " + f.String()
+			}
+			return fmt.Errorf("preflight checks failed; set APP_PREFLIGHT=false to start anyway:\n%s", strings.Join(problems, "\n"))
+		}
+	}
+
+	if err := ensureDBSchema(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to create database schema %s: %w", cfg.DB.Schema, err)
+	}
 	dbManager := database.NewManager(database.Config{
 		Driver:           "postgres",
 		ConnString:       cfg.DB.DSN,
 		MigrationsPath:   "migrations",
 		AppVersion:       version,
 		SchemaPath:       "db.sql",
-		SchemaCheckTable: "users",
-		Logger:           &jw6utils,
+		SchemaCheckQuery: store.SchemaExistsQuery,
+		Logger:           logSink,
 	})
 	if err := dbManager.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer dbManager.Close()
 
-	store.SetLogger(&jw6utils)
+	store.SetLogger(logSink)
+
+	var replica *sql.DB
+	if cfg.DB.ReplicaDSN != "" {
+		if replica, err = sql.Open("postgres", cfg.DB.ReplicaDSN); err != nil {
+			return fmt.Errorf("failed to open read replica: %w", err)
+		}
+		defer replica.Close()
+		if err := replica.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to reach read replica: %w", err)
+		}
+	}
+
+	stor := store.NewWithReplica(dbManager.DB, replica)
+	if stor.Blobs, err = store.NewBlobStore(cfg.Blob); err != nil {
+		return fmt.Errorf("failed to initialize blob storage: %w", err)
+	}
+	stor.Scanner = scan.New(cfg)
+	if cfg.BootstrapFile != "" {
+		file, err := bootstrap.Load(cfg.BootstrapFile)
+		if err != nil {
+			return err
+		}
+		result, err := bootstrap.Apply(ctx, stor, file, logSink)
+		if err != nil {
+			return err
+		}
+		logSink.Log("Main", "runServer-bootstrap", jw6_utils.Info, fmt.Sprintf("applied bootstrap file %s: %d users created, %d aliases added, %d calendars created, %d calendars updated, %d shares set, %d org shares set, %d events imported", cfg.BootstrapFile, result.UsersCreated, result.AliasesAdded, result.CalendarsCreated, result.CalendarsUpdated, result.SharesSet, result.OrgSharesSet, result.EventsImported))
+	}
 
-	stor := store.New(dbManager.DB)
 	sessionManager := appauth.NewSessionManager(cfg, stor)
 	authService, err := appauth.NewService(cfg, stor, sessionManager)
 	if err != nil {
 		return fmt.Errorf("failed to initialize auth service: %w", err)
 	}
 
+	if cfg.Redis.URL != "" {
+		shared, err := redis.New(cfg.Redis.URL)
+		if err != nil {
+			return fmt.Errorf("failed to configure Redis: %w", err)
+		}
+		defer shared.Close()
+		if _, err := shared.Do(ctx, "PING"); err != nil {
+			logSink.Log("Main", "runServer-redis", jw6_utils.Warn, fmt.Sprintf("Redis is unreachable, limiting each replica on its own until it is back: %v", err))
+		}
+		authService.SetPeers(shared)
+		go authService.FollowPeers(ctx)
+		if opts.Router.RateLimits == nil {
+			opts.Router.RateLimits = shared
+		}
+	}
+
 	go store.StartLockCleanup(ctx, stor.Locks, 5*time.Minute)
+	go sessionManager.StartCleanup(ctx)
+	go store.StartAuthEventCleanup(ctx, stor.AuthEvents, time.Hour)
+	go store.StartJobCleanup(ctx, stor.Jobs, time.Hour)
+	go store.StartEventLinkCleanup(ctx, stor.EventLinks, time.Hour)
+	go store.StartPushSubscriptionCleanup(ctx, stor.PushSubscriptions, time.Hour)
+	go store.StartIdempotencyKeyCleanup(ctx, stor.IdempotencyKeys, time.Hour)
+	go store.StartRetentionLogCleanup(ctx, stor.Retention, time.Hour)
+	go store.StartEventHistoryCleanup(ctx, stor.EventRevisions, time.Hour)
+	go store.StartContactHistoryCleanup(ctx, stor.ContactRevisions, time.Hour)
+	go store.StartClientRepairCleanup(ctx, stor.ClientRepairs, time.Hour)
+
+	if opts.Router.Jobs == nil {
+		runner := jobs.NewRunner(stor.Jobs, logSink)
+		if err := runner.Recover(ctx); err != nil {
+			logSink.Log("Main", "runServer-jobs", jw6_utils.Warn, fmt.Sprintf("failed to mark interrupted jobs: %v", err))
+		}
+		opts.Router.Jobs = runner
+	}
+
+	backups, err := backup.New(cfg, stor, logSink)
+	if err != nil {
+		return fmt.Errorf("failed to initialize backups: %w", err)
+	}
+	if backups != nil {
+		go backups.Start(ctx)
+	}
+	if opts.Router.Backups == nil {
+		opts.Router.Backups = backups
+	}
+
+	go digest.New(cfg, stor, logSink).Start(ctx)
+	focusTime := focus.New(cfg, stor, logSink)
+	go focusTime.Start(ctx)
+	go notify.New(cfg, stor, logSink).Start(ctx)
+	go notify.NewAlarms(cfg, stor, logSink).Start(ctx)
+	go notify.NewQuota(cfg, stor, logSink).Start(ctx)
+	pusher := push.New(cfg, stor, logSink)
+	go pusher.Start(ctx)
+	go retention.New(stor, logSink).Start(ctx)
+	go telemetry.New(cfg, stor, logSink).Start(ctx)
 
+	checker := fsck.New(cfg, stor, logSink)
+	go checker.Start(ctx)
+	if opts.Router.Fsck == nil {
+		opts.Router.Fsck = checker
+	}
+
+	ldapServer, err := ldap.New(cfg, stor, authService, logSink)
+	if err != nil {
+		return fmt.Errorf("failed to initialize LDAP gateway: %w", err)
+	}
+	if ldapServer != nil {
+		if err := ldapServer.Listen(); err != nil {
+			return err
+		}
+		go ldapServer.Serve(ctx)
+	}
+
+	if opts.Router.Reloader == nil {
+		reloader := config.NewReloader()
+		reloader.OnReload(func(next *config.Config) {
+			logSink.SetLevel(jw6_utils.LogLevelFromString(next.LogLevel))
+			logSink.SetMaskEmails(next.LogPrivacy == config.LogPrivacyStrict)
+		})
+		go reloader.WatchSignals(ctx, func(result *config.ReloadResult, err error) {
+			if err != nil {
+				logSink.Log("Main", "runServer-reload", jw6_utils.Error, fmt.Sprintf("configuration reload rejected: %v", err))
+				return
+			}
+			logSink.Log("Main", "runServer-reload", jw6_utils.Info, fmt.Sprintf("configuration reloaded; applied %v, restart required for %v", result.Applied, result.RestartRequired))
+		})
+		opts.Router.Reloader = reloader
+	}
+	opts.Router.Reloader.OnReload(focusTime.Reconfigure)
+	opts.Router.Reloader.OnReload(pusher.Reconfigure)
+
+	if opts.Router.Migrations == nil && dbManager.MigrationManager != nil {
+		opts.Router.Migrations = dbManager.MigrationManager
+	}
 	if opts.Router.Logger == nil {
-		opts.Router.Logger = &jw6utils
+		opts.Router.Logger = logSink
 	}
+	if opts.Router.Drainer == nil {
+		opts.Router.Drainer = drain.New()
+	}
+	drainer := opts.Router.Drainer
 	r := httpserver.NewRouterWithOptions(cfg, stor, authService, opts.Router)
 
 	srv := &http.Server{
@@ -99,20 +304,36 @@ func runServer(ctx context.Context, opts ServerOptions) error {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	ln, err := listener.Listen(ctx, cfg.ListenAddr, cfg.ListenReusePort)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
 	go func() {
-		jw6utils.Log("Main", "runServer-mainLoop", jw6_utils.Info, fmt.Sprintf("server listening on %s", cfg.ListenAddr))
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logSink.Log("Main", "runServer-mainLoop", jw6_utils.Info, fmt.Sprintf("server listening on %s", ln.Addr()))
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 			// jw6_utils Fatal does not exit the process, so do it explicitly:
 			// a dead listener must surface as a non-zero exit for restart logic.
-			jw6utils.Log("Main", "runServer-mainLoop", jw6_utils.Fatal, fmt.Sprintf("server error: %v", err))
+			logSink.Log("Main", "runServer-mainLoop", jw6_utils.Fatal, fmt.Sprintf("server error: %v", err))
 			os.Exit(1)
 		}
 	}()
 
-	<-ctx.Done()
-	jw6utils.Log("Main", "runServer", jw6_utils.Info, "shutting down...")
+	select {
+	case <-ctx.Done():
+		drainer.Drain()
+	case <-drainer.Done():
+	}
+	// Stop reusing connections and let load balancers see /readyz fail
+	// before the listener closes.
+	srv.SetKeepAlivesEnabled(false)
+	if delay := cfg.Shutdown.DrainDelay; delay > 0 {
+		logSink.Log("Main", "runServer", jw6_utils.Info, fmt.Sprintf("draining for %s...", delay))
+		time.Sleep(delay)
+	}
+	logSink.Log("Main", "runServer", jw6_utils.Info, "shutting down...")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
 	defer cancel()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
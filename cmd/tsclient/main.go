@@ -0,0 +1,576 @@
+// Command tsclient generates the TypeScript client for the CalCard REST API
+// from its OpenAPI description. Run it from the repository root after
+// changing docs/openapi.yaml:
+//
+//	go run ./cmd/tsclient
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tsclient", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	specPath := fs.String("spec", "docs/openapi.yaml", "OpenAPI description to read")
+	outPath := fs.String("out", "clients/typescript/calcard.ts", "TypeScript file to write")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	raw, err := os.ReadFile(*specPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "tsclient: %v\n", err)
+		return 1
+	}
+	out, err := generate(raw)
+	if err != nil {
+		fmt.Fprintf(stderr, "tsclient: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*outPath, []byte(out), 0o644); err != nil {
+		fmt.Fprintf(stderr, "tsclient: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type spec struct {
+	Paths      map[string]pathItem `yaml:"paths"`
+	Components struct {
+		Schemas       map[string]*schema      `yaml:"schemas"`
+		Parameters    map[string]*parameter   `yaml:"parameters"`
+		RequestBodies map[string]*requestBody `yaml:"requestBodies"`
+		Responses     map[string]*response    `yaml:"responses"`
+	} `yaml:"components"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Put        *operation   `yaml:"put"`
+	Post       *operation   `yaml:"post"`
+	Patch      *operation   `yaml:"patch"`
+	Delete     *operation   `yaml:"delete"`
+}
+
+type operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Parameters  []*parameter         `yaml:"parameters"`
+	RequestBody *requestBody         `yaml:"requestBody"`
+	Responses   map[string]*response `yaml:"responses"`
+}
+
+type parameter struct {
+	Ref         string  `yaml:"$ref"`
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Required    bool    `yaml:"required"`
+	Description string  `yaml:"description"`
+	Schema      *schema `yaml:"schema"`
+}
+
+type requestBody struct {
+	Ref      string               `yaml:"$ref"`
+	Required bool                 `yaml:"required"`
+	Content  map[string]mediaType `yaml:"content"`
+}
+
+type response struct {
+	Ref     string               `yaml:"$ref"`
+	Content map[string]mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref                  string     `yaml:"$ref"`
+	Type                 string     `yaml:"type"`
+	Description          string     `yaml:"description"`
+	Enum                 []any      `yaml:"enum"`
+	Const                any        `yaml:"const"`
+	Nullable             bool       `yaml:"nullable"`
+	Items                *schema    `yaml:"items"`
+	Properties           properties `yaml:"properties"`
+	Required             []string   `yaml:"required"`
+	AdditionalProperties yaml.Node  `yaml:"additionalProperties"`
+}
+
+type property struct {
+	Name   string
+	Schema *schema
+}
+
+// properties keeps object properties in the order the spec lists them.
+type properties []property
+
+func (p *properties) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: properties must be a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var s schema
+		if err := node.Content[i+1].Decode(&s); err != nil {
+			return err
+		}
+		*p = append(*p, property{Name: node.Content[i].Value, Schema: &s})
+	}
+	return nil
+}
+
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	pathParam  = regexp.MustCompile(`\{([^}]+)\}`)
+	methods    = []string{"get", "put", "post", "patch", "delete"}
+)
+
+func generate(raw []byte) (string, error) {
+	var doc spec
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return "", fmt.Errorf("parse spec: %w", err)
+	}
+	g := &generator{spec: &doc}
+	g.line("// Code generated by go run ./cmd/tsclient from docs/openapi.yaml; DO NOT EDIT.")
+	g.line("")
+	g.line(clientPrelude)
+	if err := g.client(); err != nil {
+		return "", err
+	}
+	if err := g.schemas(); err != nil {
+		return "", err
+	}
+	return g.sb.String(), nil
+}
+
+type generator struct {
+	spec *spec
+	sb   strings.Builder
+}
+
+func (g *generator) line(format string, args ...any) {
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
+	}
+	g.sb.WriteString(format)
+	g.sb.WriteString("\n")
+}
+
+func (g *generator) comment(indent, text string) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "*/", "*\\/"))
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		g.line("%s/** %s */", indent, lines[0])
+		return
+	}
+	g.line("%s/**", indent)
+	for _, l := range lines {
+		g.line("%s%s", indent, strings.TrimRight(" * "+l, " "))
+	}
+	g.line("%s */", indent)
+}
+
+func (g *generator) schemas() error {
+	names := make([]string, 0, len(g.spec.Components.Schemas))
+	for name := range g.spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := g.spec.Components.Schemas[name]
+		g.line("")
+		g.comment("", s.Description)
+		if len(s.Properties) > 0 && !s.Nullable {
+			g.line("export interface %s %s", name, objectType(s, ""))
+			continue
+		}
+		g.line("export type %s = %s;", name, tsType(s, ""))
+	}
+	return nil
+}
+
+// tsType returns the TypeScript type of s; indent is the indentation of the
+// line the type starts on.
+func tsType(s *schema, indent string) string {
+	if s == nil {
+		return "unknown"
+	}
+	t := baseType(s, indent)
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+func baseType(s *schema, indent string) string {
+	switch {
+	case s.Ref != "":
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case s.Const != nil:
+		return literal(s.Const)
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = literal(v)
+		}
+		return strings.Join(values, " | ")
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := tsType(s.Items, indent)
+		if strings.Contains(item, " | ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	}
+	if len(s.Properties) > 0 {
+		return objectType(s, indent)
+	}
+	if ap := s.AdditionalProperties; ap.Kind == yaml.MappingNode {
+		var value schema
+		if err := ap.Decode(&value); err == nil {
+			return "Record<string, " + tsType(&value, indent) + ">"
+		}
+	}
+	if s.Type == "object" {
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+func objectType(s *schema, indent string) string {
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	inner := indent + "  "
+	for _, p := range s.Properties {
+		if d := strings.TrimSpace(p.Schema.Description); d != "" && !strings.Contains(d, "\n") {
+			sb.WriteString(inner + "/** " + strings.ReplaceAll(d, "*/", "*\\/") + " */\n")
+		}
+		optional := "?"
+		if required[p.Name] {
+			optional = ""
+		}
+		sb.WriteString(inner + propertyName(p.Name) + optional + ": " + tsType(p.Schema, inner) + ";\n")
+	}
+	sb.WriteString(indent + "}")
+	return sb.String()
+}
+
+func propertyName(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	return literal(name)
+}
+
+func literal(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "unknown"
+	}
+	return string(b)
+}
+
+func camelCase(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == ' ' })
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func (g *generator) client() error {
+	paths := make([]string, 0, len(g.spec.Paths))
+	for p := range g.spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		item := g.spec.Paths[path]
+		ops := map[string]*operation{"get": item.Get, "put": item.Put, "post": item.Post, "patch": item.Patch, "delete": item.Delete}
+		for _, method := range methods {
+			op := ops[method]
+			if op == nil {
+				continue
+			}
+			g.line("")
+			if err := g.method(path, method, item.Parameters, op); err != nil {
+				return err
+			}
+		}
+	}
+	g.line("}")
+	return nil
+}
+
+type argument struct {
+	decl     string
+	optional bool
+}
+
+func (g *generator) method(path, method string, shared []*parameter, op *operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("%s %s has no operationId", strings.ToUpper(method), path)
+	}
+	params := make(map[string]*parameter)
+	var order []string
+	for _, p := range append(append([]*parameter{}, shared...), op.Parameters...) {
+		p, err := g.parameter(p)
+		if err != nil {
+			return err
+		}
+		key := p.In + ":" + p.Name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = p
+	}
+
+	var args []argument
+	urlExpr := pathParam.ReplaceAllStringFunc(path, func(m string) string {
+		p := params["path:"+m[1:len(m)-1]]
+		name := camelCase(m[1 : len(m)-1])
+		t := "string"
+		if p != nil {
+			t = tsType(p.Schema, "  ")
+		}
+		args = append(args, argument{decl: name + ": " + t})
+		return "${encodeURIComponent(String(" + name + "))}"
+	})
+
+	bodyArg, bodyType, bodyRequired, contentType, err := g.body(op.RequestBody)
+	if err != nil {
+		return err
+	}
+	if bodyArg {
+		args = append(args, argument{decl: "body" + optionalMark(!bodyRequired) + ": " + bodyType, optional: !bodyRequired})
+	}
+
+	var query []string
+	queryRequired := false
+	for _, key := range order {
+		p := params[key]
+		if p.In != "query" {
+			continue
+		}
+		if p.Required {
+			queryRequired = true
+		}
+		query = append(query, propertyName(p.Name)+optionalMark(!p.Required)+": "+tsType(p.Schema, "  "))
+	}
+	queryExpr := "undefined"
+	if len(query) > 0 {
+		args = append(args, argument{decl: "query" + optionalMark(!queryRequired) + ": { " + strings.Join(query, "; ") + " }", optional: !queryRequired})
+		queryExpr = "query"
+	}
+	args = append(args, argument{decl: "options?: RequestOptions", optional: true})
+	sort.SliceStable(args, func(i, j int) bool { return !args[i].optional && args[j].optional })
+
+	result, kind, err := g.result(op.Responses)
+	if err != nil {
+		return err
+	}
+	decls := make([]string, len(args))
+	for i, a := range args {
+		decls[i] = a.decl
+	}
+	bodyExpr := "undefined"
+	if bodyArg {
+		bodyExpr = "body"
+	}
+	contentExpr := "undefined"
+	if contentType != "" {
+		contentExpr = literal(contentType)
+	}
+	g.comment("  ", op.Summary)
+	g.line("  %s(%s): Promise<%s> {", op.OperationID, strings.Join(decls, ", "), result)
+	g.line("    return this.request(%s, `%s`, %s, %s, %s, options, %s);", literal(strings.ToUpper(method)), urlExpr, queryExpr, bodyExpr, contentExpr, literal(kind))
+	g.line("  }")
+	return nil
+}
+
+func optionalMark(optional bool) string {
+	if optional {
+		return "?"
+	}
+	return ""
+}
+
+func (g *generator) parameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	resolved := g.spec.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+	if resolved == nil {
+		return nil, fmt.Errorf("unresolved parameter %s", p.Ref)
+	}
+	return resolved, nil
+}
+
+// body returns how an operation's request body is passed: its TypeScript
+// type, whether it is required and the content type it is sent as. JSON is
+// preferred when the operation accepts it.
+func (g *generator) body(rb *requestBody) (ok bool, tsT string, required bool, contentType string, err error) {
+	if rb == nil {
+		return false, "", false, "", nil
+	}
+	if rb.Ref != "" {
+		resolved := g.spec.Components.RequestBodies[strings.TrimPrefix(rb.Ref, "#/components/requestBodies/")]
+		if resolved == nil {
+			return false, "", false, "", fmt.Errorf("unresolved request body %s", rb.Ref)
+		}
+		rb = resolved
+	}
+	if media, ok := rb.Content["application/json"]; ok {
+		return true, tsType(media.Schema, "  "), rb.Required, "application/json", nil
+	}
+	types := make([]string, 0, len(rb.Content))
+	for ct := range rb.Content {
+		types = append(types, ct)
+	}
+	if len(types) == 0 {
+		return false, "", false, "", nil
+	}
+	sort.Strings(types)
+	return true, "string", rb.Required, types[0], nil
+}
+
+// result returns the TypeScript type an operation resolves to and how its
+// response body is read, from its first successful response.
+func (g *generator) result(responses map[string]*response) (string, string, error) {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "void", "none", nil
+	}
+	sort.Strings(codes)
+	r := responses[codes[0]]
+	if r.Ref != "" {
+		resolved := g.spec.Components.Responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+		if resolved == nil {
+			return "", "", fmt.Errorf("unresolved response %s", r.Ref)
+		}
+		r = resolved
+	}
+	if media, ok := r.Content["application/json"]; ok {
+		return tsType(media.Schema, "  "), "json", nil
+	}
+	if len(r.Content) > 0 {
+		return "string", "text", nil
+	}
+	return "void", "none", nil
+}
+
+const clientPrelude = `/** Options for a CalCardClient. */
+export interface ClientOptions {
+  /** Base URL of the server, such as https://calcard.example.com. */
+  baseUrl: string;
+  /** Email address the app password belongs to. */
+  username?: string;
+  /** App password, sent with the username using Basic authentication. */
+  password?: string;
+  /** Replaces the global fetch, for tests or other runtimes. */
+  fetch?: typeof fetch;
+}
+
+/** Per-request options, such as If-Match headers or an abort signal. */
+export interface RequestOptions {
+  headers?: Record<string, string>;
+  signal?: AbortSignal;
+}
+
+/** Raised when the server answers with an error status. */
+export class CalCardError extends Error {
+  readonly status: number;
+  readonly body: string;
+
+  constructor(status: number, body: string) {
+    super(` + "`CalCard request failed with status ${status}: ${body.trim()}`" + `);
+    this.name = "CalCardError";
+    this.status = status;
+    this.body = body;
+  }
+}
+
+/** Client for the CalCard REST API. */
+export class CalCardClient {
+  private readonly baseUrl: string;
+  private readonly fetchFn: typeof fetch;
+  private readonly authorization?: string;
+
+  constructor(options: ClientOptions) {
+    this.baseUrl = options.baseUrl.replace(/\/+$/, "");
+    this.fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+    if (options.username !== undefined && options.password !== undefined) {
+      const bytes = new TextEncoder().encode(` + "`${options.username}:${options.password}`" + `);
+      this.authorization = "Basic " + btoa(String.fromCharCode(...bytes));
+    }
+  }
+
+  private async request<T>(
+    method: string,
+    path: string,
+    query: Record<string, unknown> | undefined,
+    body: unknown,
+    contentType: string | undefined,
+    options: RequestOptions | undefined,
+    kind: "json" | "text" | "none",
+  ): Promise<T> {
+    const url = new URL(this.baseUrl + path);
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null) {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    const headers: Record<string, string> = { ...options?.headers };
+    if (this.authorization !== undefined) {
+      headers["Authorization"] = this.authorization;
+    }
+    let payload: string | undefined;
+    if (body !== undefined && contentType !== undefined) {
+      headers["Content-Type"] = contentType;
+      payload = contentType === "application/json" ? JSON.stringify(body) : String(body);
+    }
+    const response = await this.fetchFn(url, { method, headers, body: payload, signal: options?.signal });
+    if (!response.ok) {
+      throw new CalCardError(response.status, await response.text());
+    }
+    if (kind === "json") {
+      return (await response.json()) as T;
+    }
+    if (kind === "text") {
+      return (await response.text()) as T;
+    }
+    return undefined as T;
+  }`
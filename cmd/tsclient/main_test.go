@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestGeneratedClientIsCurrent fails when docs/openapi.yaml changed without
+// regenerating the client.
+func TestGeneratedClientIsCurrent(t *testing.T) {
+	raw, err := os.ReadFile("../../docs/openapi.yaml")
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	want, err := generate(raw)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	got, err := os.ReadFile("../../clients/typescript/calcard.ts")
+	if err != nil {
+		t.Fatalf("read client: %v", err)
+	}
+	if string(got) != want {
+		t.Fatal("clients/typescript/calcard.ts is out of date; run go run ./cmd/tsclient")
+	}
+}
+
+func TestTSType(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{"type: string", "string"},
+		{"type: integer\nnullable: true", "number | null"},
+		{"$ref: '#/components/schemas/Event'", "Event"},
+		{"type: string\nenum: [read, editor]", `"read" | "editor"`},
+		{"type: array\nitems:\n  type: string\n  enum: [a, b]", `("a" | "b")[]`},
+		{"type: object\nadditionalProperties:\n  type: integer", "Record<string, number>"},
+		{"type: object\nadditionalProperties: true", "Record<string, unknown>"},
+		{"type: object\nrequired: [id]\nproperties:\n  id:\n    type: integer\n  first-name:\n    type: string", "{\n  id: number;\n  \"first-name\"?: string;\n}"},
+	}
+	for _, tt := range tests {
+		var s schema
+		if err := yaml.Unmarshal([]byte(tt.yaml), &s); err != nil {
+			t.Fatalf("unmarshal %q: %v", tt.yaml, err)
+		}
+		if got := tsType(&s, ""); got != tt.want {
+			t.Errorf("tsType(%q) = %q, want %q", tt.yaml, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateMethod(t *testing.T) {
+	spec := `
+paths:
+  /api/calendars/{id}/as-of:
+    parameters:
+      - $ref: "#/components/parameters/CalendarID"
+    get:
+      operationId: getCalendarAsOf
+      summary: View a calendar
+      parameters:
+        - name: at
+          in: query
+          required: true
+          schema:
+            type: string
+        - name: If-Match
+          in: header
+          schema:
+            type: string
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Event"
+        "400":
+          $ref: "#/components/responses/BadRequest"
+  /api/calendars/{id}/import:
+    post:
+      operationId: importCalendar
+      requestBody:
+        required: true
+        content:
+          text/calendar:
+            schema:
+              type: string
+      responses:
+        "204":
+          description: Imported.
+components:
+  parameters:
+    CalendarID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: integer
+  schemas:
+    Event:
+      type: object
+      properties:
+        uid:
+          type: string
+`
+	out, err := generate([]byte(spec))
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	for _, want := range []string{
+		"  getCalendarAsOf(id: number, query: { at: string }, options?: RequestOptions): Promise<Event[]> {\n" +
+			"    return this.request(\"GET\", `/api/calendars/${encodeURIComponent(String(id))}/as-of`, query, undefined, undefined, options, \"json\");",
+		"  importCalendar(id: string, body: string, options?: RequestOptions): Promise<void> {\n" +
+			"    return this.request(\"POST\", `/api/calendars/${encodeURIComponent(String(id))}/import`, undefined, body, \"text/calendar\", options, \"none\");",
+		"export interface Event {\n  uid?: string;\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generate() missing %q in:\n%s", want, out)
+		}
+	}
+
+	if _, err := generate([]byte("paths:\n  /api/x:\n    get:\n      responses: {}\n")); err == nil {
+		t.Error("generate() accepted an operation without operationId")
+	}
+}
+
+func TestRunWritesClient(t *testing.T) {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "openapi.yaml")
+	outPath := filepath.Join(dir, "client.ts")
+	if err := os.WriteFile(specPath, []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	if code := run([]string{"-spec", specPath, "-out", outPath}, &stderr); code != 0 {
+		t.Fatalf("run() = %d, stderr = %s", code, stderr.String())
+	}
+	out, err := os.ReadFile(outPath)
+	if err != nil || !strings.Contains(string(out), "export class CalCardClient") {
+		t.Fatalf("client = %q, err = %v", out, err)
+	}
+}
@@ -16,7 +16,13 @@ CREATE TABLE users (
     primary_email TEXT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     last_login_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    onboarding_completed_at TIMESTAMPTZ NULL
+    onboarding_completed_at TIMESTAMPTZ NULL,
+    sync_hide_cancelled BOOLEAN NOT NULL DEFAULT FALSE,
+    sync_prefs_updated_at TIMESTAMPTZ NULL,
+    locale TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    week_start SMALLINT NULL CHECK (week_start BETWEEN 0 AND 6),
+    internal BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE TABLE calendars (
@@ -63,7 +69,11 @@ CREATE TABLE app_passwords (
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     expires_at TIMESTAMPTZ NULL,
     revoked_at TIMESTAMPTZ NULL,
-    last_used_at TIMESTAMPTZ NULL
+    last_used_at TIMESTAMPTZ NULL,
+    last_used_user_agent TEXT NULL,
+    last_used_ip TEXT NULL,
+    -- api_role limits what the password may do through the REST API.
+    api_role TEXT NOT NULL DEFAULT 'editor' CHECK (api_role IN ('viewer', 'editor', 'admin'))
 );
 
 CREATE INDEX idx_events_calendar_id ON events(calendar_id);
@@ -331,3 +341,972 @@ DROP TRIGGER IF EXISTS trg_contacts_increment_ctag ON contacts;
 CREATE TRIGGER trg_contacts_increment_ctag
 AFTER INSERT OR UPDATE OR DELETE ON contacts
 FOR EACH ROW EXECUTE FUNCTION increment_address_book_ctag();
+
+-- Deletions held back by mass-deletion protection until the owner reviews them
+CREATE TABLE IF NOT EXISTS held_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    resource_type TEXT NOT NULL,
+    collection_id BIGINT NOT NULL,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL DEFAULT '',
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    deleted_by BIGINT NOT NULL,
+    client TEXT NOT NULL DEFAULT '',
+    held_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (resource_type, collection_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_held_deletions_owner ON held_deletions(owner_id, held_at DESC);
+
+-- Which devices sync each collection, for sync statistics
+CREATE TABLE IF NOT EXISTS collection_syncs (
+    id BIGSERIAL PRIMARY KEY,
+    collection_type TEXT NOT NULL,
+    collection_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_key TEXT NOT NULL,
+    app_password_id BIGINT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    last_synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sync_count BIGINT NOT NULL DEFAULT 1,
+    UNIQUE (collection_type, collection_id, user_id, device_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_syncs_user ON collection_syncs(user_id, last_synced_at DESC);
+
+-- Public free/busy links, one per user
+CREATE TABLE IF NOT EXISTS freebusy_links (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    window_days INT NOT NULL DEFAULT 60,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Public appointment booking pages
+CREATE TABLE IF NOT EXISTS booking_pages (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    duration_minutes INT NOT NULL,
+    buffer_minutes INT NOT NULL DEFAULT 0,
+    notice_minutes INT NOT NULL DEFAULT 60,
+    window_days INT NOT NULL DEFAULT 30,
+    day_start_minute INT NOT NULL DEFAULT 540,
+    day_end_minute INT NOT NULL DEFAULT 1020,
+    weekdays INT NOT NULL DEFAULT 62,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_pages_user ON booking_pages(user_id);
+
+-- Canonical payload forms used for ETags; NULL until written or backfilled
+ALTER TABLE events ADD COLUMN IF NOT EXISTS canonical_ical TEXT;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS canonical_vcard TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_canonical_pending ON events(id) WHERE canonical_ical IS NULL;
+CREATE INDEX IF NOT EXISTS idx_contacts_canonical_pending ON contacts(id) WHERE canonical_vcard IS NULL;
+
+-- Change feed positions: the writing transaction and a shared sequence
+CREATE SEQUENCE IF NOT EXISTS change_seq;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_created BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_created BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+
+CREATE OR REPLACE FUNCTION record_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_txid = pg_current_xact_id();
+    NEW.change_seq = nextval('change_seq');
+    NEW.change_created = (TG_OP = 'INSERT');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_events_record_change ON events;
+CREATE TRIGGER trg_events_record_change
+BEFORE INSERT OR UPDATE ON events
+FOR EACH ROW EXECUTE FUNCTION record_change();
+
+DROP TRIGGER IF EXISTS trg_contacts_record_change ON contacts;
+CREATE TRIGGER trg_contacts_record_change
+BEFORE INSERT OR UPDATE ON contacts
+FOR EACH ROW EXECUTE FUNCTION record_change();
+
+CREATE INDEX IF NOT EXISTS idx_events_change ON events(change_txid, change_seq);
+CREATE INDEX IF NOT EXISTS idx_contacts_change ON contacts(change_txid, change_seq);
+CREATE INDEX IF NOT EXISTS idx_deleted_resources_change ON deleted_resources(change_txid, change_seq);
+
+-- Conferencing webhooks, one per calendar
+CREATE TABLE IF NOT EXISTS conference_hooks (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Calendars that stand for bookable rooms and resources
+CREATE TABLE IF NOT EXISTS scheduling_resources (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    email TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL DEFAULT 'ROOM',
+    policy TEXT NOT NULL DEFAULT 'first-come',
+    priority_organizers TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Normalized email addresses and phone numbers for contact lookup
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS email_keys TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS phone_keys TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_contacts_email_keys ON contacts USING GIN (email_keys);
+CREATE INDEX IF NOT EXISTS idx_contacts_phone_keys ON contacts USING GIN (phone_keys);
+
+-- Task feed links, one per user
+CREATE TABLE IF NOT EXISTS task_feed_links (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Batch event deletes bump the ctag once themselves
+CREATE OR REPLACE FUNCTION increment_calendar_ctag()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF current_setting('calcard.batch_delete', true) IS DISTINCT FROM 'on' THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = OLD.calendar_id;
+        END IF;
+        INSERT INTO deleted_resources (resource_type, collection_id, uid, resource_name)
+        VALUES ('event', OLD.calendar_id, OLD.uid, OLD.resource_name);
+        RETURN OLD;
+    ELSE
+        UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = NEW.calendar_id;
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Batched event writes (deletes, merges, splits) bump the ctag once themselves
+CREATE OR REPLACE FUNCTION increment_calendar_ctag()
+RETURNS TRIGGER AS $$
+DECLARE
+    batched BOOLEAN := current_setting('calcard.batch_ctag', true) IS NOT DISTINCT FROM 'on';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF NOT batched THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = OLD.calendar_id;
+        END IF;
+        INSERT INTO deleted_resources (resource_type, collection_id, uid, resource_name)
+        VALUES ('event', OLD.calendar_id, OLD.uid, OLD.resource_name);
+        RETURN OLD;
+    ELSE
+        IF NOT batched THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = NEW.calendar_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- DAV sign-in history for new-address notifications and the soft lockout
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    app_password_id BIGINT REFERENCES app_passwords(id) ON DELETE SET NULL,
+    outcome TEXT NOT NULL,
+    new_address BOOLEAN NOT NULL DEFAULT FALSE,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_user_created ON auth_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_events_created ON auth_events(created_at);
+
+-- Background jobs and their progress
+CREATE TABLE IF NOT EXISTS jobs (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    item_errors JSONB NOT NULL DEFAULT '[]',
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_created ON jobs(user_id, created_at DESC);
+
+-- Groups of users that calendars and address books can be shared with
+CREATE TABLE IF NOT EXISTS user_groups (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (owner_user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS user_group_members (
+    group_id BIGINT NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_group_members_user ON user_group_members(user_id);
+
+-- Find the resources granted to a principal without scanning every ACL entry
+CREATE INDEX IF NOT EXISTS idx_acl_grants_principal ON acl_entries(principal_href, resource_path) WHERE is_grant = TRUE;
+
+-- Saved places users reuse across events
+CREATE TABLE IF NOT EXISTS locations (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name),
+    CHECK ((latitude IS NULL) = (longitude IS NULL))
+);
+
+-- Agenda digest email schedules, one per user
+CREATE TABLE IF NOT EXISTS digest_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    send_minute INT NOT NULL CHECK (send_minute BETWEEN 0 AND 1439),
+    weekday SMALLINT NOT NULL DEFAULT 1 CHECK (weekday BETWEEN 0 AND 6),
+    working_days_only BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sent_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Expiring read-only links to single events
+CREATE TABLE IF NOT EXISTS event_links (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    access_count BIGINT NOT NULL DEFAULT 0,
+    last_accessed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_links_event ON event_links(calendar_id, uid);
+
+-- Calendars and address books each user lost, for home-set sync
+CREATE TABLE IF NOT EXISTS collection_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    owner_user_id BIGINT NOT NULL,
+    slug TEXT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    change_txid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+    change_seq BIGINT NOT NULL DEFAULT nextval('change_seq')
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_tombstones_user ON collection_tombstones(user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_collection_tombstones_change ON collection_tombstones(user_id, change_txid, change_seq);
+
+-- The users an ACL principal stands for: a user, or the members of a group.
+-- Users being deleted are left out.
+CREATE OR REPLACE FUNCTION collection_tombstone_users(principals TEXT[])
+RETURNS TABLE (user_id BIGINT) AS $$
+    SELECT u.id FROM unnest(principals) p
+    JOIN users u ON u.id = substring(p FROM '^/dav/principals/([0-9]+)/$')::bigint
+    UNION
+    SELECT gm.user_id FROM unnest(principals) p
+    JOIN user_group_members gm ON gm.group_id = substring(p FROM '^/dav/principals/groups/([0-9]+)/$')::bigint
+    JOIN users u ON u.id = gm.user_id
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION record_calendar_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', OLD.id, t.user_id, OLD.user_id, OLD.slug
+    FROM collection_tombstone_users(ARRAY['/dav/principals/' || OLD.user_id::text || '/'] || ARRAY(
+        SELECT principal_href FROM acl_entries
+        WHERE resource_path = '/dav/calendars/' || OLD.id::text AND is_grant
+    )) t;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_calendars_tombstones ON calendars;
+CREATE TRIGGER trg_calendars_tombstones
+AFTER DELETE ON calendars
+FOR EACH ROW EXECUTE FUNCTION record_calendar_tombstones();
+
+CREATE OR REPLACE FUNCTION record_address_book_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id)
+    SELECT 'addressbook', OLD.id, t.user_id, OLD.user_id
+    FROM collection_tombstone_users(ARRAY['/dav/principals/' || OLD.user_id::text || '/'] || ARRAY(
+        SELECT principal_href FROM acl_entries
+        WHERE resource_path = '/dav/addressbooks/' || OLD.id::text AND is_grant
+    )) t;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_address_books_tombstones ON address_books;
+CREATE TRIGGER trg_address_books_tombstones
+AFTER DELETE ON address_books
+FOR EACH ROW EXECUTE FUNCTION record_address_book_tombstones();
+
+-- Revoked collection grants. Readers skip tombstones for collections the
+-- user can still reach, so ACL rewrites that keep a grant are harmless.
+CREATE OR REPLACE FUNCTION record_acl_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT OLD.is_grant THEN
+        RETURN OLD;
+    END IF;
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', c.id, t.user_id, c.user_id, c.slug
+    FROM calendars c, collection_tombstone_users(ARRAY[OLD.principal_href]) t
+    WHERE c.id = substring(OLD.resource_path FROM '^/dav/calendars/([0-9]+)/?$')::bigint AND t.user_id <> c.user_id
+    UNION ALL
+    SELECT 'addressbook', b.id, t.user_id, b.user_id, NULL
+    FROM address_books b, collection_tombstone_users(ARRAY[OLD.principal_href]) t
+    WHERE b.id = substring(OLD.resource_path FROM '^/dav/addressbooks/([0-9]+)/?$')::bigint AND t.user_id <> b.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_acl_entries_tombstones ON acl_entries;
+CREATE TRIGGER trg_acl_entries_tombstones
+AFTER DELETE ON acl_entries
+FOR EACH ROW EXECUTE FUNCTION record_acl_tombstones();
+
+-- Users leaving a sharing group lose the collections granted to it.
+CREATE OR REPLACE FUNCTION record_group_member_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', c.id, OLD.user_id, c.user_id, c.slug
+    FROM acl_entries a
+    JOIN calendars c ON c.id = substring(a.resource_path FROM '^/dav/calendars/([0-9]+)/?$')::bigint
+    WHERE a.principal_href = '/dav/principals/groups/' || OLD.group_id::text || '/' AND a.is_grant
+      AND c.user_id <> OLD.user_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id)
+    UNION ALL
+    SELECT 'addressbook', b.id, OLD.user_id, b.user_id, NULL
+    FROM acl_entries a
+    JOIN address_books b ON b.id = substring(a.resource_path FROM '^/dav/addressbooks/([0-9]+)/?$')::bigint
+    WHERE a.principal_href = '/dav/principals/groups/' || OLD.group_id::text || '/' AND a.is_grant
+      AND b.user_id <> OLD.user_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_user_group_members_tombstones ON user_group_members;
+CREATE TRIGGER trg_user_group_members_tombstones
+AFTER DELETE ON user_group_members
+FOR EACH ROW EXECUTE FUNCTION record_group_member_tombstones();
+
+-- Anonymous usage snapshots, written only when telemetry is enabled
+CREATE TABLE IF NOT EXISTS usage_stats (
+    id BIGSERIAL PRIMARY KEY,
+    collected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    users BIGINT NOT NULL DEFAULT 0,
+    active_users BIGINT NOT NULL DEFAULT 0,
+    calendars BIGINT NOT NULL DEFAULT 0,
+    address_books BIGINT NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    contacts BIGINT NOT NULL DEFAULT 0,
+    client_families JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_stats_collected ON usage_stats(collected_at DESC);
+
+-- Alias email addresses, usable for sign-in and scheduling once verified
+CREATE TABLE IF NOT EXISTS user_email_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    email_key TEXT NOT NULL,
+    token_hash TEXT NULL,
+    verified_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, email_key)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_aliases_verified ON user_email_aliases(email_key) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_aliases_token ON user_email_aliases(token_hash) WHERE token_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_primary_email_lower ON users(lower(primary_email));
+
+-- Who made each change, from the transaction-local calcard.actor setting
+ALTER TABLE events ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL;
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL DEFAULT NULLIF(current_setting('calcard.actor', true), '')::bigint;
+
+-- Writes that leave the content as it was, such as the touches that follow
+-- ACL changes, are not attributed to anyone.
+CREATE OR REPLACE FUNCTION record_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_txid = pg_current_xact_id();
+    NEW.change_seq = nextval('change_seq');
+    NEW.change_created = (TG_OP = 'INSERT');
+    IF TG_OP = 'INSERT' THEN
+        NEW.changed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint;
+    ELSIF NEW.etag IS DISTINCT FROM OLD.etag THEN
+        NEW.changed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint;
+    ELSE
+        NEW.changed_by = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Per-collection email notifications of changes made by other users
+CREATE TABLE IF NOT EXISTS collection_notifications (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    cursor_txid BIGINT NOT NULL DEFAULT 0,
+    cursor_seq BIGINT NOT NULL DEFAULT 0,
+    last_notified_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, collection_type, collection_id)
+);
+
+-- Writes bulk jobs intend to make, journaled before they are applied
+CREATE TABLE IF NOT EXISTS job_journal (
+    job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    collection_id BIGINT NOT NULL,
+    payload TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NULL,
+    PRIMARY KEY (job_id, seq)
+);
+
+-- Event and contact bodies, stored once per distinct content
+CREATE TABLE IF NOT EXISTS content_bodies (
+    hash TEXT PRIMARY KEY,
+    body TEXT NOT NULL,
+    ref_count BIGINT NOT NULL DEFAULT 0
+);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS raw_ical_hash TEXT NULL;
+ALTER TABLE events ALTER COLUMN raw_ical DROP NOT NULL;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS raw_vcard_hash TEXT NULL;
+ALTER TABLE contacts ALTER COLUMN raw_vcard DROP NOT NULL;
+
+-- content_body_put stores body once and returns its hash. The no-op update
+-- locks an existing row until the caller commits, so a concurrent release
+-- cannot delete it before the caller's reference is counted.
+CREATE OR REPLACE FUNCTION content_body_put(body TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    h TEXT := encode(sha256(convert_to(body, 'UTF8')), 'hex');
+BEGIN
+    INSERT INTO content_bodies (hash, body) VALUES (h, body)
+    ON CONFLICT (hash) DO UPDATE SET ref_count = content_bodies.ref_count;
+    RETURN h;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION content_body_acquire(h TEXT)
+RETURNS VOID AS $$
+BEGIN
+    UPDATE content_bodies SET ref_count = ref_count + 1 WHERE hash = h;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION content_body_release(h TEXT)
+RETURNS VOID AS $$
+BEGIN
+    UPDATE content_bodies SET ref_count = ref_count - 1 WHERE hash = h;
+    DELETE FROM content_bodies WHERE hash = h AND ref_count <= 0;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Bodies written to raw_ical or raw_vcard move to content_bodies, leaving
+-- the column NULL and its hash behind.
+CREATE OR REPLACE FUNCTION store_event_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.raw_ical IS NOT NULL THEN
+        NEW.raw_ical_hash = content_body_put(NEW.raw_ical);
+        NEW.raw_ical = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION store_contact_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.raw_vcard IS NOT NULL THEN
+        NEW.raw_vcard_hash = content_body_put(NEW.raw_vcard);
+        NEW.raw_vcard = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- References are counted once the row is written, so an upsert that ends
+-- up updating counts only the body it keeps.
+CREATE OR REPLACE FUNCTION count_event_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    ELSIF NEW.raw_ical_hash IS DISTINCT FROM OLD.raw_ical_hash THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION count_contact_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    ELSIF NEW.raw_vcard_hash IS DISTINCT FROM OLD.raw_vcard_hash THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_events_store_body ON events;
+CREATE TRIGGER trg_events_store_body
+BEFORE INSERT OR UPDATE OF raw_ical ON events
+FOR EACH ROW EXECUTE FUNCTION store_event_body();
+
+DROP TRIGGER IF EXISTS trg_events_count_body ON events;
+CREATE TRIGGER trg_events_count_body
+AFTER INSERT OR UPDATE OF raw_ical_hash OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION count_event_body();
+
+DROP TRIGGER IF EXISTS trg_contacts_store_body ON contacts;
+CREATE TRIGGER trg_contacts_store_body
+BEFORE INSERT OR UPDATE OF raw_vcard ON contacts
+FOR EACH ROW EXECUTE FUNCTION store_contact_body();
+
+DROP TRIGGER IF EXISTS trg_contacts_count_body ON contacts;
+CREATE TRIGGER trg_contacts_count_body
+AFTER INSERT OR UPDATE OF raw_vcard_hash OR DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION count_contact_body();
+
+-- Time-limited admin impersonation grants and the requests made with them
+CREATE TABLE IF NOT EXISTS impersonations (
+    id BIGSERIAL PRIMARY KEY,
+    admin_user_id BIGINT NULL REFERENCES users(id) ON DELETE SET NULL,
+    admin_email TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'write')),
+    reason TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_created ON impersonations(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS impersonation_requests (
+    id BIGSERIAL PRIMARY KEY,
+    impersonation_id BIGINT NOT NULL REFERENCES impersonations(id) ON DELETE CASCADE,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_requests_grant ON impersonation_requests(impersonation_id, created_at);
+
+-- Per-calendar retention policies and the events they deleted
+CREATE TABLE IF NOT EXISTS calendar_retention (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    months INTEGER NOT NULL CHECK (months BETWEEN 1 AND 1200),
+    last_run_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS retention_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    summary TEXT NULL,
+    dtstart TIMESTAMPTZ NULL,
+    dtend TIMESTAMPTZ NULL,
+    cutoff TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_deletions_calendar ON retention_deletions(calendar_id, deleted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_retention_deletions_deleted ON retention_deletions(deleted_at);
+
+-- Event revisions, for viewing a calendar as it stood at a past time
+CREATE TABLE IF NOT EXISTS event_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    raw_ical TEXT NULL,
+    raw_ical_hash TEXT NULL,
+    summary TEXT NULL,
+    location TEXT NULL,
+    dtstart TIMESTAMPTZ NULL,
+    dtend TIMESTAMPTZ NULL,
+    all_day BOOLEAN NOT NULL DEFAULT FALSE,
+    changed_by BIGINT NULL,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_revisions_calendar ON event_revisions(calendar_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_event_revisions_open ON event_revisions(calendar_id, uid) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_revisions_closed ON event_revisions(valid_to) WHERE valid_to IS NOT NULL;
+
+-- Each write that changes an event's content or location closes its open
+-- revision and opens a new one. Restores load the archived history instead.
+CREATE OR REPLACE FUNCTION record_event_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('calcard.restoring', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.etag IS NOT DISTINCT FROM OLD.etag
+        AND NEW.calendar_id = OLD.calendar_id AND NEW.uid = OLD.uid
+        AND NEW.resource_name = OLD.resource_name THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE event_revisions SET valid_to = NOW()
+        WHERE calendar_id = OLD.calendar_id AND uid = OLD.uid AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO event_revisions (calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+            summary, location, dtstart, dtend, all_day, changed_by)
+        VALUES (NEW.calendar_id, NEW.uid, NEW.resource_name, NEW.etag, NEW.raw_ical, NEW.raw_ical_hash,
+            NEW.summary, NEW.location, NEW.dtstart, NEW.dtend, NEW.all_day, NEW.changed_by);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revisions hold a reference to their body, so it outlives the event.
+CREATE OR REPLACE FUNCTION count_event_revision_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+    ELSE
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_events_record_revision ON events;
+CREATE TRIGGER trg_events_record_revision
+AFTER INSERT OR UPDATE OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION record_event_revision();
+
+DROP TRIGGER IF EXISTS trg_event_revisions_count_body ON event_revisions;
+CREATE TRIGGER trg_event_revisions_count_body
+AFTER INSERT OR DELETE ON event_revisions
+FOR EACH ROW EXECUTE FUNCTION count_event_revision_body();
+
+-- History starts now: existing events open their first revision, and
+-- earlier times cannot be viewed.
+INSERT INTO event_revisions (calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by)
+SELECT calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by
+FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM event_revisions r WHERE r.calendar_id = e.calendar_id AND r.uid = e.uid AND r.valid_to IS NULL);
+
+INSERT INTO application (key, value)
+VALUES ('event_history_since', NOW()::text)
+ON CONFLICT (key) DO NOTHING;
+
+-- Repairs made to data written by each user's clients
+CREATE TABLE IF NOT EXISTS client_repairs (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_key TEXT NOT NULL,
+    app_password_id BIGINT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    repair TEXT NOT NULL,
+    repair_count BIGINT NOT NULL DEFAULT 1,
+    last_resource TEXT NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, device_key, repair)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_repairs_last_seen ON client_repairs(last_seen_at);
+
+-- WebDAV-Push subscriptions to calendar and address book changes
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    push_resource TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    auth_secret TEXT NOT NULL,
+    cursor_txid BIGINT NOT NULL DEFAULT 0,
+    cursor_seq BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_pushed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (collection_type, collection_id, push_resource)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_expires ON push_subscriptions(expires_at);
+
+-- Idempotency keys of REST writes, with the response to replay; status is
+-- NULL while the first request is still running
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status INT NULL,
+    headers TEXT NULL,
+    body BYTEA NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+-- Contact revisions, for the feed of recently changed contacts
+CREATE TABLE IF NOT EXISTS contact_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    address_book_id BIGINT NOT NULL REFERENCES address_books(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    raw_vcard TEXT NULL,
+    raw_vcard_hash TEXT NULL,
+    display_name TEXT NULL,
+    changed_by BIGINT NULL,
+    closed_by BIGINT NULL,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_book_from ON contact_revisions(address_book_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_book_to ON contact_revisions(address_book_id, valid_to) WHERE valid_to IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_open ON contact_revisions(address_book_id, uid) WHERE valid_to IS NULL;
+
+-- Each write that changes a contact closes its open revision, recording who
+-- closed it, and opens a new one. Restores load the archived history
+-- instead.
+CREATE OR REPLACE FUNCTION record_contact_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('calcard.restoring', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.etag IS NOT DISTINCT FROM OLD.etag
+        AND NEW.address_book_id = OLD.address_book_id AND NEW.uid = OLD.uid
+        AND NEW.resource_name = OLD.resource_name THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE contact_revisions
+        SET valid_to = NOW(), closed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint
+        WHERE address_book_id = OLD.address_book_id AND uid = OLD.uid AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO contact_revisions (address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+            display_name, changed_by)
+        VALUES (NEW.address_book_id, NEW.uid, NEW.resource_name, NEW.etag, NEW.raw_vcard, NEW.raw_vcard_hash,
+            NEW.display_name, NEW.changed_by);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revisions hold a reference to their body, so it outlives the contact.
+CREATE OR REPLACE FUNCTION count_contact_revision_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+    ELSE
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_contacts_record_revision ON contacts;
+CREATE TRIGGER trg_contacts_record_revision
+AFTER INSERT OR UPDATE OR DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION record_contact_revision();
+
+DROP TRIGGER IF EXISTS trg_contact_revisions_count_body ON contact_revisions;
+CREATE TRIGGER trg_contact_revisions_count_body
+AFTER INSERT OR DELETE ON contact_revisions
+FOR EACH ROW EXECUTE FUNCTION count_contact_revision_body();
+
+-- History starts now: existing contacts open a first revision, dated before
+-- any change is listed from, so their next change can be compared with it.
+INSERT INTO contact_revisions (address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, valid_from)
+SELECT address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, '-infinity'
+FROM contacts c
+WHERE NOT EXISTS (SELECT 1 FROM contact_revisions r WHERE r.address_book_id = c.address_book_id AND r.uid = c.uid AND r.valid_to IS NULL);
+
+-- Collections in maintenance: readable, but refusing client writes while an
+-- admin runs a bulk operation on them.
+CREATE TABLE IF NOT EXISTS collection_maintenance (
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    started_by TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (collection_type, collection_id)
+);
+
+-- Calendars handed from one owner to another, kept as an audit record.
+-- Users are recorded by ID and email so the record outlives their accounts.
+CREATE TABLE IF NOT EXISTS calendar_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    from_user_id BIGINT NOT NULL,
+    from_email TEXT NOT NULL,
+    to_user_id BIGINT NOT NULL,
+    to_email TEXT NOT NULL,
+    transferred_by TEXT NOT NULL,
+    transferred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_transfers_calendar ON calendar_transfers(calendar_id, transferred_at);
+
+-- Where users have their event alarms pushed, and each alarm delivered to
+-- them, kept so failed deliveries are retried and users can see what was
+-- sent.
+CREATE TABLE IF NOT EXISTS notification_providers (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('gotify', 'ntfy', 'pushover', 'webhook')),
+    name TEXT NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_providers_user ON notification_providers(user_id);
+
+CREATE TABLE IF NOT EXISTS alarm_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    provider_id BIGINT NOT NULL REFERENCES notification_providers(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL,
+    uid TEXT NOT NULL,
+    alarm_id TEXT NOT NULL,
+    fire_at TIMESTAMPTZ NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider_id, calendar_id, uid, alarm_id, fire_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alarm_deliveries_pending ON alarm_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_alarm_deliveries_user ON alarm_deliveries(user_id, fire_at);
+
+-- The highest storage quota warning each user has been sent, so that each
+-- threshold is reported once.
+CREATE TABLE IF NOT EXISTS quota_warnings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    level TEXT NOT NULL CHECK (level IN ('warning', 'critical')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Focus time: the protected hours users have their free time blocked in,
+-- and the tentative blocks created, so they can be removed in one go.
+CREATE TABLE IF NOT EXISTS focus_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    min_minutes INT NOT NULL CHECK (min_minutes BETWEEN 15 AND 480),
+    day_start_minute INT NOT NULL CHECK (day_start_minute BETWEEN 0 AND 1439),
+    day_end_minute INT NOT NULL CHECK (day_end_minute BETWEEN 1 AND 1440),
+    planned_until TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (day_start_minute < day_end_minute)
+);
+
+CREATE TABLE IF NOT EXISTS focus_blocks (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (calendar_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_focus_blocks_user ON focus_blocks(user_id, end_at);
+
+-- Feature flags turned on or off for one user or group, ahead of or
+-- instead of the instance-wide setting in APP_FEATURES
+CREATE TABLE IF NOT EXISTS feature_flag_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    flag TEXT NOT NULL,
+    user_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id BIGINT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (group_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_overrides_user ON feature_flag_overrides(flag, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_overrides_group ON feature_flag_overrides(flag, group_id) WHERE group_id IS NOT NULL;
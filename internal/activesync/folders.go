@@ -0,0 +1,163 @@
+package activesync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Folder types (MS-ASCMD 2.2.3.186.3).
+const (
+	folderTypeCalendar     = "8"
+	folderTypeContacts     = "9"
+	folderTypeUserCalendar = "13"
+	folderTypeUserContacts = "14"
+)
+
+// FolderSync status codes.
+const (
+	folderStatusOK          = "1"
+	folderStatusServerError = "6"
+	folderStatusInvalidKey  = "9"
+)
+
+// folder is a calendar ("c<id>") or address book ("a<id>") as a device sees
+// it.
+type folder struct {
+	id   string
+	name string
+	typ  string
+}
+
+// isCalendar reports whether the collection ID names a calendar.
+func isCalendar(collectionID string) bool {
+	return strings.HasPrefix(collectionID, "c")
+}
+
+// parseCollectionID returns the calendar or address book ID of a collection.
+func parseCollectionID(collectionID string) (int64, bool) {
+	if len(collectionID) < 2 || (collectionID[0] != 'c' && collectionID[0] != 'a') {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(collectionID[1:], 10, 64)
+	return id, err == nil && id > 0
+}
+
+// loadFolders lists the user's calendars and address books. Devices expect one
+// default folder of each kind, so the first owned one is marked as default.
+func (h *Handler) loadFolders(r *http.Request, req *request) ([]folder, error) {
+	var cals []store.CalendarAccess
+	var books []contacts.AddressBookAccess
+	var err error
+	if !h.noCalendars {
+		if cals, err = h.events.ListCalendars(r.Context(), req.user); err != nil {
+			return nil, err
+		}
+	}
+	if !h.noContacts {
+		if books, err = h.contacts.ListAccessibleAddressBooks(r.Context(), req.user); err != nil {
+			return nil, err
+		}
+	}
+	folders := make([]folder, 0, len(cals)+len(books))
+	typ := folderTypeCalendar
+	for _, cal := range cals {
+		f := folder{id: "c" + strconv.FormatInt(cal.ID, 10), name: cal.Name, typ: folderTypeUserCalendar}
+		if cal.Shared && cal.OwnerEmail != "" {
+			f.name += " (" + cal.OwnerEmail + ")"
+		} else if !cal.Shared && typ == folderTypeCalendar {
+			f.typ, typ = folderTypeCalendar, ""
+		}
+		folders = append(folders, f)
+	}
+	typ = folderTypeContacts
+	for _, book := range books {
+		f := folder{id: "a" + strconv.FormatInt(book.ID, 10), name: book.Name, typ: folderTypeUserContacts}
+		if !book.Shared && typ == folderTypeContacts {
+			f.typ, typ = folderTypeContacts, ""
+		}
+		folders = append(folders, f)
+	}
+	return folders, nil
+}
+
+// hierarchyKey is the FolderSync key for a folder list. It only changes when
+// the list does, so devices that poll FolderSync see no changes.
+func hierarchyKey(folders []folder) string {
+	sum := sha256.New()
+	for _, f := range folders {
+		sum.Write([]byte(f.id + "\x00" + f.name + "\x00" + f.typ + "\x00"))
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:32]
+}
+
+// folderSync sends the folder hierarchy: all of it for key 0, the changes
+// since the list the device last received otherwise. A key the server no
+// longer recognizes makes the device start over.
+func (h *Handler) folderSync(r *http.Request, req *request) *node {
+	resp := el(pageFolderHierarchy, "FolderSync")
+	folders, err := h.loadFolders(r, req)
+	if err != nil {
+		h.log.Error("folderSync", "failed to load folders for user %d: %v", req.user.ID, err)
+		return resp.add(textEl(pageFolderHierarchy, "Status", folderStatusServerError))
+	}
+	key := hierarchyKey(folders)
+	clientKey := req.body.value(pageFolderHierarchy, "SyncKey")
+
+	h.mu.Lock()
+	var previous []folder
+	switch {
+	case clientKey == "0":
+	case clientKey == key:
+		previous = folders
+	case clientKey == req.device.folderKey:
+		previous = req.device.folders
+	default:
+		h.mu.Unlock()
+		return resp.add(textEl(pageFolderHierarchy, "Status", folderStatusInvalidKey))
+	}
+	req.device.folderKey, req.device.folders = key, folders
+	h.mu.Unlock()
+
+	changes := el(pageFolderHierarchy, "Changes")
+	before := make(map[string]folder, len(previous))
+	for _, f := range previous {
+		before[f.id] = f
+	}
+	current := make(map[string]bool, len(folders))
+	for _, f := range folders {
+		current[f.id] = true
+		old, existed := before[f.id]
+		switch {
+		case !existed:
+			changes.add(folderChange("Add", f))
+		case old != f:
+			changes.add(folderChange("Update", f))
+		}
+	}
+	for _, f := range previous {
+		if !current[f.id] {
+			changes.add(el(pageFolderHierarchy, "Delete", textEl(pageFolderHierarchy, "ServerId", f.id)))
+		}
+	}
+	changes.children = append([]*node{textEl(pageFolderHierarchy, "Count", strconv.Itoa(len(changes.children)))}, changes.children...)
+	return resp.add(
+		textEl(pageFolderHierarchy, "Status", folderStatusOK),
+		textEl(pageFolderHierarchy, "SyncKey", key),
+		changes,
+	)
+}
+
+func folderChange(op string, f folder) *node {
+	return el(pageFolderHierarchy, op,
+		textEl(pageFolderHierarchy, "ServerId", f.id),
+		textEl(pageFolderHierarchy, "ParentId", "0"),
+		textEl(pageFolderHierarchy, "DisplayName", f.name),
+		textEl(pageFolderHierarchy, "Type", f.typ),
+	)
+}
@@ -0,0 +1,175 @@
+// Package activesync is a minimal Exchange ActiveSync bridge for devices that
+// cannot use CalDAV or CardDAV, such as older Android mail apps and car
+// systems. It supports the FolderSync, Sync and Ping commands over the same
+// calendars and address books the DAV server exposes, and is read-only:
+// changes made on a device are rejected.
+package activesync
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Path is where devices look for the ActiveSync endpoint.
+const Path = "/Microsoft-Server-ActiveSync"
+
+const (
+	logClass         = "ActiveSync"
+	contentType      = "application/vnd.ms-sync.wbxml"
+	serverVersion    = "14.0"
+	protocolVersions = "12.0,12.1,14.0"
+	protocolCommands = "FolderSync,Sync,Ping"
+	maxRequestBytes  = 4 << 20
+)
+
+// Handler serves the ActiveSync endpoint. Requests must already be
+// authenticated; see auth.Service.RequireDAVAuth.
+type Handler struct {
+	store    *store.Store
+	events   *events.Service
+	contacts *contacts.Service
+	log      *logging.Logger
+	// pollInterval is how often a Ping checks its folders for changes.
+	pollInterval time.Duration
+	// noCalendars and noContacts hide the half of the server the
+	// configuration turns off; see SetCollections.
+	noCalendars, noContacts bool
+
+	mu      sync.Mutex
+	devices map[string]*deviceState
+}
+
+// deviceState is what the server remembers about a device between requests.
+// It only saves work: losing it on restart makes a device resync its folder
+// list or repeat its last Ping, never miss changes.
+type deviceState struct {
+	// folderKey and folders are the hierarchy last sent by FolderSync.
+	folderKey string
+	folders   []folder
+	// cursors are the change feed positions last sent for each collection.
+	cursors map[string]store.ChangeCursor
+	// pingFolders and heartbeat are reused by a Ping with an empty body.
+	pingFolders []string
+	heartbeat   time.Duration
+}
+
+// request is one decoded ActiveSync command.
+type request struct {
+	user   *store.User
+	device *deviceState
+	body   *node
+}
+
+// NewHandler returns an ActiveSync handler backed by st.
+func NewHandler(st *store.Store, sink logging.Sink) *Handler {
+	return &Handler{
+		store:        st,
+		events:       events.NewService(st),
+		contacts:     contacts.NewService(st),
+		log:          logging.New(sink, logClass),
+		pollInterval: 30 * time.Second,
+		devices:      map[string]*deviceState{},
+	}
+}
+
+// SetCollections limits the folders devices see to calendars, address
+// books or both. A hidden kind is left out of FolderSync and treated as
+// unknown by Sync and Ping.
+func (h *Handler) SetCollections(calendars, contacts bool) {
+	h.noCalendars, h.noContacts = !calendars, !contacts
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("MS-Server-ActiveSync", serverVersion)
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", "OPTIONS, POST")
+		w.Header().Set("MS-ASProtocolVersions", protocolVersions)
+		w.Header().Set("MS-ASProtocolCommands", protocolCommands)
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "OPTIONS, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	deviceID := query.Get("DeviceId")
+	if deviceID == "" {
+		http.Error(w, "missing DeviceId", http.StatusBadRequest)
+		return
+	}
+	version := r.Header.Get("MS-ASProtocolVersion")
+	if version == "" {
+		version = serverVersion
+	}
+	if !strings.Contains(","+protocolVersions+",", ","+version+",") {
+		http.Error(w, "unsupported protocol version", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req := &request{user: user, device: h.device(user.ID, deviceID)}
+	if len(data) > 0 {
+		if req.body, err = decodeWBXML(data); err != nil {
+			http.Error(w, "invalid WBXML", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var resp *node
+	switch cmd := query.Get("Cmd"); cmd {
+	case "FolderSync":
+		resp = h.folderSync(r, req)
+	case "Sync":
+		resp = h.sync(r, req)
+	case "Ping":
+		resp = h.ping(w, r, req)
+	default:
+		h.log.Debug("ServeHTTP", "unsupported command %q from device %s", cmd, deviceID)
+		http.Error(w, "command not supported", http.StatusNotImplemented)
+		return
+	}
+	if resp == nil {
+		// The client went away during a Ping.
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(encodeWBXML(resp))
+}
+
+// device returns the state kept for a user's device, creating it on first
+// use. Fields of the returned state are guarded by h.mu.
+func (h *Handler) device(userID int64, deviceID string) *deviceState {
+	key := strconv.FormatInt(userID, 10) + "/" + deviceID
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d, ok := h.devices[key]
+	if !ok {
+		d = &deviceState{cursors: map[string]store.ChangeCursor{}}
+		h.devices[key] = d
+	}
+	return d
+}
@@ -0,0 +1,455 @@
+package activesync
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeCalendars struct {
+	store.CalendarRepository
+	cals []store.Calendar
+}
+
+func (f *fakeCalendars) GetByID(_ context.Context, id int64) (*store.Calendar, error) {
+	for i := range f.cals {
+		if f.cals[i].ID == id {
+			return &f.cals[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeCalendars) ListAccessible(_ context.Context, userID int64) ([]store.CalendarAccess, error) {
+	var out []store.CalendarAccess
+	for _, c := range f.cals {
+		if c.UserID == userID {
+			out = append(out, store.CalendarAccess{Calendar: c, Editor: true})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCalendars) GetAccessible(ctx context.Context, id, userID int64) (*store.CalendarAccess, error) {
+	c, _ := f.GetByID(ctx, id)
+	if c == nil || c.UserID != userID {
+		return nil, nil
+	}
+	return &store.CalendarAccess{Calendar: *c, Editor: true}, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+	mu     sync.Mutex
+	events []store.Event
+}
+
+func (f *fakeEvents) ListForCalendar(_ context.Context, calendarID int64) ([]store.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []store.Event
+	for _, ev := range f.events {
+		if ev.CalendarID == calendarID {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEvents) GetByUID(_ context.Context, calendarID int64, uid string) (*store.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.events {
+		if f.events[i].CalendarID == calendarID && f.events[i].UID == uid {
+			ev := f.events[i]
+			return &ev, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeBooks struct {
+	store.AddressBookRepository
+	books []store.AddressBook
+}
+
+func (f *fakeBooks) GetByID(_ context.Context, id int64) (*store.AddressBook, error) {
+	for i := range f.books {
+		if f.books[i].ID == id {
+			return &f.books[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeBooks) ListByUser(_ context.Context, userID int64) ([]store.AddressBook, error) {
+	var out []store.AddressBook
+	for _, b := range f.books {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+type fakeContacts struct {
+	store.ContactRepository
+	items []store.Contact
+}
+
+func (f *fakeContacts) ListForBookFiltered(_ context.Context, bookID int64, _ store.ContactFilter) ([]store.Contact, error) {
+	var out []store.Contact
+	for _, c := range f.items {
+		if c.AddressBookID == bookID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+type fakeChanges struct {
+	store.ChangeRepository
+	mu      sync.Mutex
+	changes []store.Change
+}
+
+func (f *fakeChanges) append(c store.Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c.Cursor = store.ChangeCursor{TxID: int64(len(f.changes) + 1), Seq: int64(len(f.changes) + 1)}
+	f.changes = append(f.changes, c)
+}
+
+func (f *fakeChanges) ListSince(_ context.Context, calendarIDs, bookIDs []int64, after store.ChangeCursor, limit int) ([]store.Change, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	in := func(ids []int64, id int64) bool {
+		for _, v := range ids {
+			if v == id {
+				return true
+			}
+		}
+		return false
+	}
+	var out []store.Change
+	for _, c := range f.changes {
+		if c.Cursor.Seq <= after.Seq {
+			continue
+		}
+		if (c.ResourceType == "event" && in(calendarIDs, c.CollectionID)) || (c.ResourceType == "contact" && in(bookIDs, c.CollectionID)) {
+			out = append(out, c)
+		}
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeChanges) Latest(context.Context) (store.ChangeCursor, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.changes) == 0 {
+		return store.ChangeCursor{}, nil
+	}
+	return f.changes[len(f.changes)-1].Cursor, nil
+}
+
+type testEnv struct {
+	handler *Handler
+	user    *store.User
+	events  *fakeEvents
+	changes *fakeChanges
+}
+
+func newTestEnv() *testEnv {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	summary := "Standup"
+	env := &testEnv{
+		user: &store.User{ID: 1, PrimaryEmail: "alice@example.com"},
+		events: &fakeEvents{events: []store.Event{
+			{CalendarID: 1, UID: "b-event", Summary: &summary, DTStart: &start, DTEnd: &end,
+				RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:b-event\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+			{CalendarID: 1, UID: "a-event", Summary: &summary, DTStart: &start, DTEnd: &end},
+		}},
+		changes: &fakeChanges{},
+	}
+	st := &store.Store{
+		Calendars:    &fakeCalendars{cals: []store.Calendar{{ID: 1, UserID: 1, Name: "Home"}}},
+		Events:       env.events,
+		AddressBooks: &fakeBooks{books: []store.AddressBook{{ID: 2, UserID: 1, Name: "Contacts"}}},
+		Contacts: &fakeContacts{items: []store.Contact{{AddressBookID: 2, UID: "c1",
+			RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:c1\r\nFN:Carol Jones\r\nN:Jones;Carol;;;\r\nEMAIL:carol@example.com\r\nTEL;TYPE=CELL:+15550100\r\nEND:VCARD\r\n"}}},
+		Changes: env.changes,
+	}
+	env.handler = NewHandler(st, nil)
+	env.handler.pollInterval = 5 * time.Millisecond
+	return env
+}
+
+func (e *testEnv) post(t *testing.T, cmd string, body *node) *node {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data = encodeWBXML(body)
+	}
+	req := httptest.NewRequest(http.MethodPost, Path+"?Cmd="+cmd+"&User=alice&DeviceId=dev1&DeviceType=Android", bytes.NewReader(data))
+	req.Header.Set("MS-ASProtocolVersion", "14.0")
+	req = req.WithContext(auth.WithUser(req.Context(), e.user))
+	rec := httptest.NewRecorder()
+	e.handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", cmd, rec.Code, rec.Body.String())
+	}
+	resp, err := decodeWBXML(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("%s: decode response: %v", cmd, err)
+	}
+	return resp
+}
+
+func syncRequest(collectionID, key string, window string, extra ...*node) *node {
+	c := el(pageAirSync, "Collection",
+		textEl(pageAirSync, "SyncKey", key),
+		textEl(pageAirSync, "CollectionId", collectionID),
+	)
+	if window != "" {
+		c.add(textEl(pageAirSync, "WindowSize", window))
+	}
+	c.add(extra...)
+	return el(pageAirSync, "Sync", el(pageAirSync, "Collections", c))
+}
+
+// collection returns the only collection of a Sync response.
+func collection(t *testing.T, resp *node) *node {
+	t.Helper()
+	c := resp.child(pageAirSync, "Collections").child(pageAirSync, "Collection")
+	if c == nil {
+		t.Fatalf("Sync response has no collection")
+	}
+	return c
+}
+
+func TestWBXMLRoundTrip(t *testing.T) {
+	doc := el(pageAirSync, "Sync",
+		el(pageAirSync, "Collections",
+			el(pageAirSync, "Collection",
+				textEl(pageAirSync, "SyncKey", "C1.2"),
+				el(pageAirSync, "GetChanges"),
+				el(pageAirSync, "ApplicationData",
+					textEl(pageCalendar, "Subject", "Grüße"),
+					el(pageAirSyncBase, "Body", textEl(pageAirSyncBase, "Data", "line\nbreak")),
+				),
+			),
+		),
+	)
+	got, err := decodeWBXML(encodeWBXML(doc))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	c := got.child(pageAirSync, "Collections").child(pageAirSync, "Collection")
+	if c.value(pageAirSync, "SyncKey") != "C1.2" || c.child(pageAirSync, "GetChanges") == nil {
+		t.Fatalf("collection did not round-trip: %+v", c)
+	}
+	data := c.child(pageAirSync, "ApplicationData")
+	if data.value(pageCalendar, "Subject") != "Grüße" {
+		t.Errorf("subject = %q", data.value(pageCalendar, "Subject"))
+	}
+	if data.child(pageAirSyncBase, "Body").value(pageAirSyncBase, "Data") != "line\nbreak" {
+		t.Errorf("body did not round-trip")
+	}
+
+	if _, err := decodeWBXML([]byte{0x03, 0x01, 0x6a, 0x00, 0x45, 0x4b}); err == nil {
+		t.Errorf("expected error for unterminated document")
+	}
+}
+
+func TestOptionsAdvertisesCommands(t *testing.T) {
+	env := newTestEnv()
+	rec := httptest.NewRecorder()
+	env.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, Path, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("MS-ASProtocolCommands") != protocolCommands {
+		t.Fatalf("OPTIONS: status %d, commands %q", rec.Code, rec.Header().Get("MS-ASProtocolCommands"))
+	}
+}
+
+func TestFolderSyncListsCollections(t *testing.T) {
+	env := newTestEnv()
+	resp := env.post(t, "FolderSync", el(pageFolderHierarchy, "FolderSync", textEl(pageFolderHierarchy, "SyncKey", "0")))
+	if resp.value(pageFolderHierarchy, "Status") != folderStatusOK {
+		t.Fatalf("status = %q", resp.value(pageFolderHierarchy, "Status"))
+	}
+	changes := resp.child(pageFolderHierarchy, "Changes")
+	adds := changes.all(pageFolderHierarchy, "Add")
+	if changes.value(pageFolderHierarchy, "Count") != "2" || len(adds) != 2 {
+		t.Fatalf("expected two folders, got %+v", changes)
+	}
+	if adds[0].value(pageFolderHierarchy, "ServerId") != "c1" || adds[0].value(pageFolderHierarchy, "Type") != folderTypeCalendar {
+		t.Errorf("calendar folder = %+v", adds[0])
+	}
+	if adds[1].value(pageFolderHierarchy, "ServerId") != "a2" || adds[1].value(pageFolderHierarchy, "Type") != folderTypeContacts {
+		t.Errorf("address book folder = %+v", adds[1])
+	}
+
+	key := resp.value(pageFolderHierarchy, "SyncKey")
+	resp = env.post(t, "FolderSync", el(pageFolderHierarchy, "FolderSync", textEl(pageFolderHierarchy, "SyncKey", key)))
+	if resp.child(pageFolderHierarchy, "Changes").value(pageFolderHierarchy, "Count") != "0" {
+		t.Errorf("unchanged hierarchy reported changes")
+	}
+	resp = env.post(t, "FolderSync", el(pageFolderHierarchy, "FolderSync", textEl(pageFolderHierarchy, "SyncKey", "stale")))
+	if resp.value(pageFolderHierarchy, "Status") != folderStatusInvalidKey {
+		t.Errorf("unknown key status = %q", resp.value(pageFolderHierarchy, "Status"))
+	}
+}
+
+func TestSetCollectionsHidesADisabledHalf(t *testing.T) {
+	env := newTestEnv()
+	env.handler.SetCollections(false, true)
+	resp := env.post(t, "FolderSync", el(pageFolderHierarchy, "FolderSync", textEl(pageFolderHierarchy, "SyncKey", "0")))
+	adds := resp.child(pageFolderHierarchy, "Changes").all(pageFolderHierarchy, "Add")
+	if len(adds) != 1 || adds[0].value(pageFolderHierarchy, "ServerId") != "a2" {
+		t.Fatalf("folders with calendars hidden = %+v", adds)
+	}
+	if c := collection(t, env.post(t, "Sync", syncRequest("c1", "0", ""))); c.value(pageAirSync, "Status") != syncStatusFolderChanged {
+		t.Errorf("hidden calendar sync status = %q", c.value(pageAirSync, "Status"))
+	}
+	if c := collection(t, env.post(t, "Sync", syncRequest("a2", "0", ""))); c.value(pageAirSync, "Status") != syncStatusOK {
+		t.Errorf("address book sync status = %q", c.value(pageAirSync, "Status"))
+	}
+}
+
+func TestSyncPagesListingThenFollowsChanges(t *testing.T) {
+	env := newTestEnv()
+	c := collection(t, env.post(t, "Sync", syncRequest("c1", "0", "")))
+	key := c.value(pageAirSync, "SyncKey")
+	if c.value(pageAirSync, "Status") != syncStatusOK || c.child(pageAirSync, "Commands") != nil {
+		t.Fatalf("initial sync: %+v", c)
+	}
+
+	c = collection(t, env.post(t, "Sync", syncRequest("c1", key, "1")))
+	adds := c.child(pageAirSync, "Commands").all(pageAirSync, "Add")
+	if len(adds) != 1 || c.child(pageAirSync, "MoreAvailable") == nil {
+		t.Fatalf("first page: %d adds, more=%v", len(adds), c.child(pageAirSync, "MoreAvailable") != nil)
+	}
+	if adds[0].value(pageAirSync, "ServerId") != itemID("c1", "a-event") {
+		t.Errorf("first page is not ordered by UID")
+	}
+	c = collection(t, env.post(t, "Sync", syncRequest("c1", c.value(pageAirSync, "SyncKey"), "1")))
+	adds = c.child(pageAirSync, "Commands").all(pageAirSync, "Add")
+	if len(adds) != 1 || c.child(pageAirSync, "MoreAvailable") != nil {
+		t.Fatalf("second page: %d adds", len(adds))
+	}
+	rec := adds[0].child(pageAirSync, "ApplicationData").child(pageCalendar, "Recurrence")
+	if rec.value(pageCalendar, "Type") != "1" || rec.value(pageCalendar, "DayOfWeek") != "10" || rec.value(pageCalendar, "Occurrences") != "10" {
+		t.Errorf("recurrence = %+v", rec)
+	}
+	key = c.value(pageAirSync, "SyncKey")
+	if !strings.HasPrefix(key, "C") {
+		t.Fatalf("listing finished with key %q", key)
+	}
+
+	env.changes.append(store.Change{ResourceType: "event", CollectionID: 1, UID: "a-event"})
+	env.changes.append(store.Change{ResourceType: "event", CollectionID: 1, UID: "gone", Deleted: true})
+	c = collection(t, env.post(t, "Sync", syncRequest("c1", key, "",
+		el(pageAirSync, "Commands", el(pageAirSync, "Add", textEl(pageAirSync, "ClientId", "tmp1"))))))
+	commands := c.child(pageAirSync, "Commands")
+	if len(commands.all(pageAirSync, "Change")) != 1 || len(commands.all(pageAirSync, "Delete")) != 1 {
+		t.Fatalf("incremental sync commands = %+v", commands)
+	}
+	response := c.child(pageAirSync, "Responses").child(pageAirSync, "Add")
+	if response.value(pageAirSync, "ClientId") != "tmp1" || response.value(pageAirSync, "Status") != syncStatusConversionError {
+		t.Errorf("device add was not rejected: %+v", response)
+	}
+
+	c = collection(t, env.post(t, "Sync", syncRequest("c1", "C99", "")))
+	if c.value(pageAirSync, "Status") != syncStatusInvalidKey {
+		t.Errorf("malformed key status = %q", c.value(pageAirSync, "Status"))
+	}
+	c = collection(t, env.post(t, "Sync", syncRequest("c9", "0", "")))
+	if c.value(pageAirSync, "Status") != syncStatusFolderChanged {
+		t.Errorf("unknown collection status = %q", c.value(pageAirSync, "Status"))
+	}
+}
+
+func TestPingReturnsChangedFolders(t *testing.T) {
+	env := newTestEnv()
+	ping := func(heartbeat string) *node {
+		return el(pagePing, "Ping",
+			textEl(pagePing, "HeartbeatInterval", heartbeat),
+			el(pagePing, "Folders",
+				el(pagePing, "Folder", textEl(pagePing, "Id", "c1"), textEl(pagePing, "Class", "Calendar")),
+				el(pagePing, "Folder", textEl(pagePing, "Id", "a2"), textEl(pagePing, "Class", "Contacts")),
+			),
+		)
+	}
+	resp := env.post(t, "Ping", ping("30"))
+	if resp.value(pagePing, "Status") != pingStatusBadHeartbeat || resp.value(pagePing, "HeartbeatInterval") != "60" {
+		t.Fatalf("short heartbeat: %+v", resp)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		env.changes.append(store.Change{ResourceType: "contact", CollectionID: 2, UID: "c1"})
+	}()
+	resp = env.post(t, "Ping", ping("120"))
+	if resp.value(pagePing, "Status") != pingStatusChanges {
+		t.Fatalf("ping status = %q", resp.value(pagePing, "Status"))
+	}
+	folders := resp.child(pagePing, "Folders").all(pagePing, "Folder")
+	if len(folders) != 1 || folders[0].text != "a2" {
+		t.Errorf("changed folders = %+v", folders)
+	}
+
+	// An empty Ping repeats the previous one; the change is still unsynced.
+	resp = env.post(t, "Ping", nil)
+	if resp.value(pagePing, "Status") != pingStatusChanges {
+		t.Errorf("repeated ping status = %q", resp.value(pagePing, "Status"))
+	}
+}
+
+func TestContactData(t *testing.T) {
+	data := contactData(store.Contact{RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Dr. Carol Jones\r\nN:Jones;Carol;Ann;Dr.;\r\n" +
+		"EMAIL;TYPE=WORK:carol@work.example\r\nEMAIL:carol@home.example\r\nTEL;TYPE=WORK,VOICE:+1 555 0100\r\nTEL:+1 555 0199\r\n" +
+		"ADR;TYPE=WORK:;;1 Main St;Springfield;IL;62701;USA\r\nORG:Acme\\, Inc.;Research\r\nCATEGORIES:Friends,Work\r\nNOTE:Met at\\nconference\r\nEND:VCARD\r\n"})
+	want := map[string]string{
+		"FileAs": "Dr. Carol Jones", "LastName": "Jones", "FirstName": "Carol", "MiddleName": "Ann", "Title": "Dr.",
+		"Email1Address": "carol@work.example", "Email2Address": "carol@home.example",
+		"BusinessPhoneNumber": "+1 555 0100", "HomePhoneNumber": "+1 555 0199",
+		"BusinessStreet": "1 Main St", "BusinessCity": "Springfield", "BusinessPostalCode": "62701",
+		"CompanyName": "Acme, Inc.", "Department": "Research",
+	}
+	for name, v := range want {
+		if got := data.value(pageContacts, name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+	if cats := data.child(pageContacts, "Categories").all(pageContacts, "Category"); len(cats) != 2 {
+		t.Errorf("categories = %+v", cats)
+	}
+	if note := data.child(pageAirSyncBase, "Body").value(pageAirSyncBase, "Data"); note != "Met at\nconference" {
+		t.Errorf("note = %q", note)
+	}
+}
+
+func TestRecurrenceRelativeMonthly(t *testing.T) {
+	start := time.Date(2026, 1, 13, 9, 0, 0, 0, time.UTC)
+	rec := recurrence("FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20261231", start)
+	if rec.value(pageCalendar, "Type") != "3" || rec.value(pageCalendar, "WeekOfMonth") != "5" || rec.value(pageCalendar, "DayOfWeek") != "32" {
+		t.Errorf("recurrence = %+v", rec)
+	}
+	if rec.value(pageCalendar, "Until") != "20261231T235959Z" {
+		t.Errorf("until = %q", rec.value(pageCalendar, "Until"))
+	}
+	if recurrence("FREQ=HOURLY", start) != nil {
+		t.Errorf("hourly rule should not map")
+	}
+}
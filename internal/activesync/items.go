@@ -0,0 +1,437 @@
+package activesync
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const easTime = "20060102T150405Z"
+
+// utcTimeZone is an all-zero TIME_ZONE_INFORMATION structure, which devices
+// read as UTC. Event times are sent in UTC, so no other zone is needed.
+var utcTimeZone = base64.StdEncoding.EncodeToString(make([]byte, 172))
+
+// eventData converts an event to Calendar ApplicationData. Recurrence rules
+// are sent as written; exceptions to a series are not.
+func eventData(ev store.Event) *node {
+	props := masterProperties(ev.RawICAL)
+	data := el(pageAirSync, "ApplicationData", textEl(pageCalendar, "TimeZone", utcTimeZone))
+
+	allDay := "0"
+	if ev.AllDay {
+		allDay = "1"
+	}
+	data.add(textEl(pageCalendar, "AllDayEvent", allDay))
+	if ev.Description != nil && *ev.Description != "" {
+		data.add(body(*ev.Description))
+	}
+	busy := "2"
+	if strings.EqualFold(props["TRANSP"], "TRANSPARENT") {
+		busy = "0"
+	}
+	data.add(
+		textEl(pageCalendar, "BusyStatus", busy),
+		textEl(pageCalendar, "DtStamp", ev.LastModified.UTC().Format(easTime)),
+	)
+	if ev.DTStart != nil {
+		end := ev.DTStart.Add(time.Hour)
+		switch {
+		case ev.DTEnd != nil:
+			end = *ev.DTEnd
+		case ev.AllDay:
+			end = ev.DTStart.AddDate(0, 0, 1)
+		}
+		data.add(
+			textEl(pageCalendar, "StartTime", ev.DTStart.UTC().Format(easTime)),
+			textEl(pageCalendar, "EndTime", end.UTC().Format(easTime)),
+		)
+	}
+	if ev.Location != nil && *ev.Location != "" {
+		data.add(textEl(pageCalendar, "Location", *ev.Location))
+	}
+	sensitivity := "0"
+	switch strings.ToUpper(props["CLASS"]) {
+	case "PRIVATE":
+		sensitivity = "2"
+	case "CONFIDENTIAL":
+		sensitivity = "3"
+	}
+	data.add(
+		textEl(pageCalendar, "MeetingStatus", "0"),
+		textEl(pageCalendar, "Sensitivity", sensitivity),
+	)
+	if ev.Summary != nil {
+		data.add(textEl(pageCalendar, "Subject", *ev.Summary))
+	}
+	data.add(textEl(pageCalendar, "UID", ev.UID))
+	if ev.DTStart != nil {
+		data.add(recurrence(props["RRULE"], ev.DTStart.UTC()))
+	}
+	return data
+}
+
+// masterProperties returns the values of the series VEVENT's own properties,
+// ignoring overrides and nested components such as VALARM.
+func masterProperties(raw string) map[string]string {
+	props := map[string]string{}
+	for _, comp := range utils.ICalComponents(raw, "VEVENT") {
+		depth := 0
+		master := true
+		values := map[string]string{}
+		for _, line := range utils.UnfoldLines(comp) {
+			name, _, value := splitProperty(line)
+			switch name {
+			case "BEGIN":
+				depth++
+				continue
+			case "END":
+				depth--
+				continue
+			}
+			if depth != 1 {
+				continue
+			}
+			if name == "RECURRENCE-ID" {
+				master = false
+			}
+			if _, seen := values[name]; !seen {
+				values[name] = value
+			}
+		}
+		if master {
+			return values
+		}
+	}
+	return props
+}
+
+var weekdayBits = map[string]int{"SU": 1, "MO": 2, "TU": 4, "WE": 8, "TH": 16, "FR": 32, "SA": 64}
+
+// recurrence maps an RRULE onto the Recurrence element, or returns nil for
+// rules ActiveSync cannot express.
+func recurrence(rule string, start time.Time) *node {
+	if rule == "" {
+		return nil
+	}
+	parts := map[string]string{}
+	for _, p := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			parts[strings.ToUpper(k)] = strings.ToUpper(v)
+		}
+	}
+	// BYDAY entries may carry an ordinal, as in 2TU or -1FR.
+	days, week := 0, 0
+	for _, d := range strings.Split(parts["BYDAY"], ",") {
+		if d == "" {
+			continue
+		}
+		ord, day := d[:len(d)-min(2, len(d))], d[len(d)-min(2, len(d)):]
+		days |= weekdayBits[day]
+		if n, err := strconv.Atoi(ord); err == nil {
+			week = n
+		}
+	}
+	if n, err := strconv.Atoi(parts["BYSETPOS"]); err == nil {
+		week = n
+	}
+	if week < 0 {
+		// ActiveSync's week 5 is the last week of the month.
+		week = 5
+	}
+
+	rec := el(pageCalendar, "Recurrence")
+	var typ string
+	switch parts["FREQ"] {
+	case "DAILY":
+		typ = "0"
+		if days != 0 {
+			rec.add(textEl(pageCalendar, "DayOfWeek", strconv.Itoa(days)))
+		}
+	case "WEEKLY":
+		typ = "1"
+		if days == 0 {
+			days = 1 << int(start.Weekday())
+		}
+		rec.add(textEl(pageCalendar, "DayOfWeek", strconv.Itoa(days)))
+	case "MONTHLY", "YEARLY":
+		relative := days != 0 && week != 0
+		switch {
+		case parts["FREQ"] == "MONTHLY" && relative:
+			typ = "3"
+		case parts["FREQ"] == "MONTHLY":
+			typ = "2"
+		case relative:
+			typ = "6"
+		default:
+			typ = "5"
+		}
+		if relative {
+			rec.add(
+				textEl(pageCalendar, "WeekOfMonth", strconv.Itoa(week)),
+				textEl(pageCalendar, "DayOfWeek", strconv.Itoa(days)),
+			)
+		} else {
+			day := strconv.Itoa(start.Day())
+			if v := parts["BYMONTHDAY"]; v != "" && !strings.Contains(v, ",") {
+				day = v
+			}
+			rec.add(textEl(pageCalendar, "DayOfMonth", day))
+		}
+		if parts["FREQ"] == "YEARLY" {
+			month := strconv.Itoa(int(start.Month()))
+			if v := parts["BYMONTH"]; v != "" && !strings.Contains(v, ",") {
+				month = v
+			}
+			rec.add(textEl(pageCalendar, "MonthOfYear", month))
+		}
+	default:
+		return nil
+	}
+	rec.children = append([]*node{textEl(pageCalendar, "Type", typ)}, rec.children...)
+	if v := parts["INTERVAL"]; v != "" {
+		rec.add(textEl(pageCalendar, "Interval", v))
+	}
+	if v := parts["COUNT"]; v != "" {
+		rec.add(textEl(pageCalendar, "Occurrences", v))
+	}
+	if v := parts["UNTIL"]; v != "" {
+		if t, err := time.Parse(easTime, v); err == nil {
+			rec.add(textEl(pageCalendar, "Until", t.Format(easTime)))
+		} else if t, err := time.Parse("20060102", v); err == nil {
+			rec.add(textEl(pageCalendar, "Until", t.Add(24*time.Hour-time.Second).Format(easTime)))
+		}
+	}
+	return rec
+}
+
+// contactData converts a vCard to Contacts ApplicationData.
+func contactData(c store.Contact) *node {
+	data := el(pageAirSync, "ApplicationData")
+	fields := map[string]string{}
+	set := func(name, value string) bool {
+		if value == "" || fields[name] != "" {
+			return false
+		}
+		fields[name] = value
+		return true
+	}
+	var categories []string
+	var note string
+	emails := 0
+	var untypedPhones []string
+	for _, line := range utils.UnfoldLines(c.RawVCard) {
+		name, params, value := splitProperty(line)
+		switch name {
+		case "FN":
+			set("FileAs", unescape(value))
+		case "N":
+			n := splitStructured(value)
+			for len(n) < 5 {
+				n = append(n, "")
+			}
+			set("LastName", n[0])
+			set("FirstName", n[1])
+			set("MiddleName", n[2])
+			set("Title", n[3])
+			set("Suffix", n[4])
+		case "EMAIL":
+			if emails < 3 && value != "" {
+				emails++
+				set("Email"+strconv.Itoa(emails)+"Address", value)
+			}
+		case "TEL":
+			value = strings.TrimPrefix(value, "tel:")
+			switch {
+			case hasType(params, "CELL"):
+				if set("MobilePhoneNumber", value) {
+					continue
+				}
+			case hasType(params, "FAX"):
+				if hasType(params, "WORK") && set("BusinessFaxNumber", value) || set("HomeFaxNumber", value) {
+					continue
+				}
+			case hasType(params, "PAGER"):
+				if set("PagerNumber", value) {
+					continue
+				}
+			case hasType(params, "CAR"):
+				if set("CarPhoneNumber", value) {
+					continue
+				}
+			case hasType(params, "WORK"):
+				if set("BusinessPhoneNumber", value) || set("Business2PhoneNumber", value) {
+					continue
+				}
+			case hasType(params, "HOME"):
+				if set("HomePhoneNumber", value) || set("Home2PhoneNumber", value) {
+					continue
+				}
+			}
+			untypedPhones = append(untypedPhones, value)
+		case "ADR":
+			prefix := "Other"
+			switch {
+			case hasType(params, "WORK"):
+				prefix = "Business"
+			case hasType(params, "HOME"):
+				prefix = "Home"
+			}
+			adr := splitStructured(value)
+			for len(adr) < 7 {
+				adr = append(adr, "")
+			}
+			street := strings.TrimSpace(strings.Join(nonEmpty(adr[0], adr[1], adr[2]), "\n"))
+			set(prefix+"Street", street)
+			set(prefix+"City", adr[3])
+			set(prefix+"State", adr[4])
+			set(prefix+"PostalCode", adr[5])
+			set(prefix+"Country", adr[6])
+		case "ORG":
+			org := splitStructured(value)
+			set("CompanyName", org[0])
+			if len(org) > 1 {
+				set("Department", org[1])
+			}
+		case "TITLE":
+			set("JobTitle", unescape(value))
+		case "URL":
+			set("WebPage", value)
+		case "NOTE":
+			if note == "" {
+				note = unescape(value)
+			}
+		case "CATEGORIES":
+			for _, cat := range splitList(value) {
+				if cat != "" {
+					categories = append(categories, cat)
+				}
+			}
+		}
+	}
+	for _, phone := range untypedPhones {
+		_ = set("HomePhoneNumber", phone) || set("BusinessPhoneNumber", phone) ||
+			set("MobilePhoneNumber", phone) || set("Home2PhoneNumber", phone) || set("Business2PhoneNumber", phone)
+	}
+	if c.Birthday != nil {
+		fields["Birthday"] = c.Birthday.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+	if note != "" {
+		data.add(body(note))
+	}
+	// Tags are emitted in code page order.
+	for _, tag := range pageTags[pageContacts] {
+		if v := fields[tag]; v != "" {
+			data.add(textEl(pageContacts, tag, v))
+		}
+		if tag == "Categories" && len(categories) > 0 {
+			cats := el(pageContacts, "Categories")
+			for _, cat := range categories {
+				cats.add(textEl(pageContacts, "Category", cat))
+			}
+			data.add(cats)
+		}
+	}
+	return data
+}
+
+// body is a plain-text AirSyncBase Body.
+func body(text string) *node {
+	return el(pageAirSyncBase, "Body",
+		textEl(pageAirSyncBase, "Type", "1"),
+		textEl(pageAirSyncBase, "EstimatedDataSize", strconv.Itoa(len(text))),
+		textEl(pageAirSyncBase, "Data", text),
+	)
+}
+
+// splitProperty returns the upper-cased name, without any group, the
+// upper-cased parameters and the value of an unfolded content line.
+func splitProperty(line string) (string, string, string) {
+	inQuotes := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ':' && !inQuotes:
+			head, params, _ := strings.Cut(line[:i], ";")
+			if dot := strings.LastIndex(head, "."); dot >= 0 {
+				head = head[dot+1:]
+			}
+			return strings.ToUpper(strings.TrimSpace(head)), strings.ToUpper(params), strings.TrimSpace(line[i+1:])
+		}
+	}
+	return "", "", ""
+}
+
+// hasType reports whether params carry typ, as TYPE=typ, in a TYPE list, or
+// as a bare vCard 2.1 parameter.
+func hasType(params, typ string) bool {
+	for _, p := range strings.Split(params, ";") {
+		name, values, ok := strings.Cut(p, "=")
+		if !ok {
+			if p == typ {
+				return true
+			}
+			continue
+		}
+		if name != "TYPE" {
+			continue
+		}
+		for _, v := range strings.Split(strings.Trim(values, `"`), ",") {
+			if v == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unescape(v string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(v)
+}
+
+// splitStructured splits a structured value on unescaped semicolons.
+func splitStructured(v string) []string {
+	return splitUnescaped(v, ';')
+}
+
+// splitList splits a list value on unescaped commas.
+func splitList(v string) []string {
+	return splitUnescaped(v, ',')
+}
+
+func splitUnescaped(v string, sep byte) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v):
+			i++
+			if v[i] == 'n' || v[i] == 'N' {
+				cur.WriteByte('\n')
+			} else {
+				cur.WriteByte(v[i])
+			}
+		case v[i] == sep:
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(v[i])
+		}
+	}
+	return append(parts, strings.TrimSpace(cur.String()))
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package activesync
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Ping status codes (MS-ASCMD 2.2.3.177.10).
+const (
+	pingStatusExpired         = "1"
+	pingStatusChanges         = "2"
+	pingStatusMissingParams   = "3"
+	pingStatusBadHeartbeat    = "5"
+	pingStatusTooManyFolders  = "6"
+	pingStatusFolderSyncFirst = "7"
+	pingStatusServerError     = "8"
+)
+
+const (
+	minHeartbeat   = 60 * time.Second
+	maxHeartbeat   = 3540 * time.Second
+	maxPingFolders = 200
+)
+
+// ping holds the request open until one of the folders changes or the
+// heartbeat interval ends. A folder has changed when the change feed has
+// entries after the cursor last sent to the device for it, or after the
+// start of the first Ping that watched it when the server has no record of
+// one.
+func (h *Handler) ping(w http.ResponseWriter, r *http.Request, req *request) *node {
+	resp := el(pagePing, "Ping")
+	status := func(code string, rest ...*node) *node {
+		return resp.add(append([]*node{textEl(pagePing, "Status", code)}, rest...)...)
+	}
+
+	h.mu.Lock()
+	heartbeat, folderIDs := req.device.heartbeat, req.device.pingFolders
+	h.mu.Unlock()
+	if raw := req.body.value(pagePing, "HeartbeatInterval"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			return status(pingStatusMissingParams)
+		}
+		heartbeat = time.Duration(seconds) * time.Second
+	}
+	if folders := req.body.child(pagePing, "Folders"); folders != nil {
+		folderIDs = nil
+		for _, f := range folders.all(pagePing, "Folder") {
+			folderIDs = append(folderIDs, f.value(pagePing, "Id"))
+		}
+	}
+	switch {
+	case heartbeat == 0 || len(folderIDs) == 0:
+		return status(pingStatusMissingParams)
+	case heartbeat < minHeartbeat:
+		return status(pingStatusBadHeartbeat, textEl(pagePing, "HeartbeatInterval", strconv.Itoa(int(minHeartbeat.Seconds()))))
+	case heartbeat > maxHeartbeat:
+		return status(pingStatusBadHeartbeat, textEl(pagePing, "HeartbeatInterval", strconv.Itoa(int(maxHeartbeat.Seconds()))))
+	case len(folderIDs) > maxPingFolders:
+		return status(pingStatusTooManyFolders, textEl(pagePing, "MaxFolders", strconv.Itoa(maxPingFolders)))
+	}
+
+	latest, err := h.store.Changes.Latest(r.Context())
+	if err != nil {
+		h.log.Error("ping", "failed to read change feed position: %v", err)
+		return status(pingStatusServerError)
+	}
+	type watched struct {
+		collectionID string
+		calendar     bool
+		id           int64
+		cursor       store.ChangeCursor
+	}
+	watch := make([]watched, 0, len(folderIDs))
+	h.mu.Lock()
+	req.device.heartbeat, req.device.pingFolders = heartbeat, folderIDs
+	for _, collectionID := range folderIDs {
+		id, ok := parseCollectionID(collectionID)
+		if !ok {
+			h.mu.Unlock()
+			return status(pingStatusFolderSyncFirst)
+		}
+		cursor, ok := req.device.cursors[collectionID]
+		if !ok {
+			cursor = latest
+			req.device.cursors[collectionID] = latest
+		}
+		watch = append(watch, watched{collectionID: collectionID, calendar: isCalendar(collectionID), id: id, cursor: cursor})
+	}
+	h.mu.Unlock()
+	for _, f := range watch {
+		if err := h.checkCollection(r, req, f.collectionID, f.id); err != nil {
+			return status(pingStatusFolderSyncFirst)
+		}
+	}
+
+	// The write timeout of the HTTP server is far shorter than a heartbeat.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(heartbeat + time.Minute))
+	deadline := time.NewTimer(heartbeat)
+	defer deadline.Stop()
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+	for {
+		changed := el(pagePing, "Folders")
+		for _, f := range watch {
+			var calendarIDs, bookIDs []int64
+			if f.calendar {
+				calendarIDs = []int64{f.id}
+			} else {
+				bookIDs = []int64{f.id}
+			}
+			changes, err := h.store.Changes.ListSince(r.Context(), calendarIDs, bookIDs, f.cursor, 1)
+			if err != nil {
+				if r.Context().Err() != nil {
+					return nil
+				}
+				h.log.Error("ping", "failed to check collection %s for changes: %v", f.collectionID, err)
+				return status(pingStatusServerError)
+			}
+			if len(changes) > 0 {
+				changed.add(textEl(pagePing, "Folder", f.collectionID))
+			}
+		}
+		if len(changed.children) > 0 {
+			return status(pingStatusChanges, changed)
+		}
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-deadline.C:
+			return status(pingStatusExpired)
+		case <-ticker.C:
+		}
+	}
+}
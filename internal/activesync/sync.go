@@ -0,0 +1,342 @@
+package activesync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Sync status codes (MS-ASCMD 2.2.3.177.17).
+const (
+	syncStatusOK              = "1"
+	syncStatusInvalidKey      = "3"
+	syncStatusServerError     = "5"
+	syncStatusConversionError = "6"
+	syncStatusNotFound        = "8"
+	syncStatusFolderChanged   = "12"
+	syncStatusEmptyRequest    = "13"
+)
+
+const (
+	defaultWindowSize = 100
+	maxWindowSize     = 512
+)
+
+// syncKey is a collection's position as last sent to a device. The key holds
+// all of it, so retried requests get the same answer and the server keeps
+// no per-device sync state. "F<offset>-<tx>.<seq>" pages through the initial
+// listing of a collection, which started at change feed cursor tx.seq;
+// "C<tx>.<seq>" follows the change feed from that cursor.
+type syncKey struct {
+	full   bool
+	offset int
+	cursor store.ChangeCursor
+}
+
+func (k syncKey) String() string {
+	if k.full {
+		return fmt.Sprintf("F%d-%d.%d", k.offset, k.cursor.TxID, k.cursor.Seq)
+	}
+	return fmt.Sprintf("C%d.%d", k.cursor.TxID, k.cursor.Seq)
+}
+
+func parseSyncKey(s string) (syncKey, bool) {
+	var k syncKey
+	var n int
+	var err error
+	switch {
+	case len(s) > 1 && s[0] == 'F':
+		k.full = true
+		n, err = fmt.Sscanf(s, "F%d-%d.%d", &k.offset, &k.cursor.TxID, &k.cursor.Seq)
+		if n != 3 {
+			return k, false
+		}
+	case len(s) > 1 && s[0] == 'C':
+		n, err = fmt.Sscanf(s, "C%d.%d", &k.cursor.TxID, &k.cursor.Seq)
+		if n != 2 {
+			return k, false
+		}
+	default:
+		return k, false
+	}
+	ok := err == nil && k.offset >= 0 && k.cursor.TxID >= 0 && k.cursor.Seq >= 0
+	return k, ok && k.String() == s
+}
+
+// itemID is the ServerId of a resource. UIDs can exceed the 64 characters
+// ActiveSync allows, so they are hashed.
+func itemID(collectionID, uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return collectionID + ":" + hex.EncodeToString(sum[:16])
+}
+
+// sync answers a Sync request collection by collection.
+func (h *Handler) sync(r *http.Request, req *request) *node {
+	resp := el(pageAirSync, "Sync")
+	collections := req.body.child(pageAirSync, "Collections")
+	if collections == nil {
+		// Devices may send an empty Sync to repeat the previous one; that
+		// needs state the server does not keep, so ask for the full request.
+		return resp.add(textEl(pageAirSync, "Status", syncStatusEmptyRequest))
+	}
+	out := el(pageAirSync, "Collections")
+	for _, c := range collections.all(pageAirSync, "Collection") {
+		out.add(h.syncCollection(r, req, c))
+	}
+	return resp.add(out)
+}
+
+func (h *Handler) syncCollection(r *http.Request, req *request, c *node) *node {
+	collectionID := c.value(pageAirSync, "CollectionId")
+	clientKey := c.value(pageAirSync, "SyncKey")
+	out := el(pageAirSync, "Collection")
+	if class := c.value(pageAirSync, "Class"); class != "" {
+		out.add(textEl(pageAirSync, "Class", class))
+	}
+	reply := func(key, status string, rest ...*node) *node {
+		out.add(
+			textEl(pageAirSync, "SyncKey", key),
+			textEl(pageAirSync, "CollectionId", collectionID),
+			textEl(pageAirSync, "Status", status),
+		)
+		return out.add(rest...)
+	}
+
+	id, ok := parseCollectionID(collectionID)
+	if !ok {
+		return reply(clientKey, syncStatusFolderChanged)
+	}
+	if err := h.checkCollection(r, req, collectionID, id); err != nil {
+		if errors.Is(err, events.ErrNotFound) || errors.Is(err, contacts.ErrNotFound) ||
+			errors.Is(err, events.ErrForbidden) || errors.Is(err, contacts.ErrForbidden) {
+			return reply(clientKey, syncStatusFolderChanged)
+		}
+		h.log.Error("syncCollection", "failed to load collection %s for user %d: %v", collectionID, req.user.ID, err)
+		return reply(clientKey, syncStatusServerError)
+	}
+
+	if clientKey == "0" {
+		cursor, err := h.store.Changes.Latest(r.Context())
+		if err != nil {
+			h.log.Error("syncCollection", "failed to read change feed position: %v", err)
+			return reply(clientKey, syncStatusServerError)
+		}
+		key := syncKey{full: true, cursor: cursor}
+		h.rememberCursor(req, collectionID, cursor)
+		return reply(key.String(), syncStatusOK)
+	}
+	key, ok := parseSyncKey(clientKey)
+	if !ok {
+		return reply("0", syncStatusInvalidKey)
+	}
+
+	responses := rejectClientCommands(c.child(pageAirSync, "Commands"))
+	window := defaultWindowSize
+	if v, err := strconv.Atoi(c.value(pageAirSync, "WindowSize")); err == nil && v > 0 {
+		window = min(v, maxWindowSize)
+	}
+	var commands []*node
+	more := false
+	var err error
+	// GetChanges defaults to on once a collection has been synced.
+	if getChanges := c.child(pageAirSync, "GetChanges"); getChanges == nil || getChanges.text != "0" {
+		if key.full {
+			commands, more, key, err = h.listCollection(r, req, collectionID, id, key, window)
+		} else {
+			commands, more, key, err = h.collectionChanges(r, req, collectionID, id, key, window)
+		}
+		if err != nil {
+			h.log.Error("syncCollection", "failed to sync collection %s for user %d: %v", collectionID, req.user.ID, err)
+			return reply(clientKey, syncStatusServerError)
+		}
+	}
+	h.rememberCursor(req, collectionID, key.cursor)
+
+	var rest []*node
+	if responses != nil {
+		rest = append(rest, responses)
+	}
+	if more {
+		rest = append(rest, el(pageAirSync, "MoreAvailable"))
+	}
+	if len(commands) > 0 {
+		rest = append(rest, el(pageAirSync, "Commands", commands...))
+	}
+	return reply(key.String(), syncStatusOK, rest...)
+}
+
+// checkCollection confirms the user can still read the collection and that
+// its kind is not hidden.
+func (h *Handler) checkCollection(r *http.Request, req *request, collectionID string, id int64) error {
+	if isCalendar(collectionID) {
+		if h.noCalendars {
+			return events.ErrNotFound
+		}
+		_, err := h.events.GetCalendar(r.Context(), req.user, id)
+		return err
+	}
+	if h.noContacts {
+		return contacts.ErrNotFound
+	}
+	_, err := h.contacts.GetAddressBook(r.Context(), req.user, id)
+	return err
+}
+
+func (h *Handler) rememberCursor(req *request, collectionID string, cursor store.ChangeCursor) {
+	h.mu.Lock()
+	req.device.cursors[collectionID] = cursor
+	h.mu.Unlock()
+}
+
+// listCollection sends the next page of the initial listing as Adds. Once
+// the listing is done the key moves on to the change feed, from the cursor
+// read before the listing began, so nothing written meanwhile is lost.
+func (h *Handler) listCollection(r *http.Request, req *request, collectionID string, id int64, key syncKey, window int) ([]*node, bool, syncKey, error) {
+	items, err := h.listItems(r, req, collectionID, id)
+	if err != nil {
+		return nil, false, key, err
+	}
+	start := min(key.offset, len(items))
+	end := min(start+window, len(items))
+	commands := make([]*node, 0, end-start)
+	for _, item := range items[start:end] {
+		commands = append(commands, el(pageAirSync, "Add",
+			textEl(pageAirSync, "ServerId", itemID(collectionID, item.uid)),
+			item.data(),
+		))
+	}
+	if end < len(items) {
+		return commands, true, syncKey{full: true, offset: end, cursor: key.cursor}, nil
+	}
+	return commands, false, syncKey{cursor: key.cursor}, nil
+}
+
+// syncItem is a resource awaiting conversion, so a page converts only the
+// items it sends.
+type syncItem struct {
+	uid  string
+	data func() *node
+}
+
+// listItems returns every readable resource in the collection, ordered by
+// UID so pages are stable between requests.
+func (h *Handler) listItems(r *http.Request, req *request, collectionID string, id int64) ([]syncItem, error) {
+	var items []syncItem
+	if isCalendar(collectionID) {
+		evs, err := h.events.ListEvents(r.Context(), req.user, id, store.EventFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range evs {
+			items = append(items, syncItem{uid: ev.UID, data: func() *node { return eventData(ev) }})
+		}
+	} else {
+		cards, err := h.contacts.ListContacts(r.Context(), req.user, id, store.ContactFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cards {
+			items = append(items, syncItem{uid: c.UID, data: func() *node { return contactData(c) }})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].uid < items[j].uid })
+	return items, nil
+}
+
+// collectionChanges sends the changes after the key's cursor. Resources the
+// user can no longer read are sent as deletions.
+func (h *Handler) collectionChanges(r *http.Request, req *request, collectionID string, id int64, key syncKey, window int) ([]*node, bool, syncKey, error) {
+	var calendarIDs, bookIDs []int64
+	if isCalendar(collectionID) {
+		calendarIDs = []int64{id}
+	} else {
+		bookIDs = []int64{id}
+	}
+	changes, err := h.store.Changes.ListSince(r.Context(), calendarIDs, bookIDs, key.cursor, window)
+	if err != nil {
+		return nil, false, key, err
+	}
+	commands := make([]*node, 0, len(changes))
+	for _, change := range changes {
+		serverID := textEl(pageAirSync, "ServerId", itemID(collectionID, change.UID))
+		var data *node
+		if !change.Deleted {
+			if data, err = h.loadItem(r, req, collectionID, id, change.UID); err != nil {
+				return nil, false, key, err
+			}
+		}
+		switch {
+		case data == nil:
+			commands = append(commands, el(pageAirSync, "Delete", serverID))
+		case change.Created:
+			commands = append(commands, el(pageAirSync, "Add", serverID, data))
+		default:
+			commands = append(commands, el(pageAirSync, "Change", serverID, data))
+		}
+	}
+	if len(changes) > 0 {
+		key.cursor = changes[len(changes)-1].Cursor
+	}
+	return commands, len(changes) == window, key, nil
+}
+
+// loadItem converts one resource, or returns nil when the user cannot read
+// it.
+func (h *Handler) loadItem(r *http.Request, req *request, collectionID string, id int64, uid string) (*node, error) {
+	if isCalendar(collectionID) {
+		ev, err := h.events.GetEvent(r.Context(), req.user, id, uid)
+		if errors.Is(err, events.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return eventData(*ev), nil
+	}
+	c, err := h.contacts.GetContact(r.Context(), req.user, id, uid)
+	if errors.Is(err, contacts.ErrNotFound) || errors.Is(err, contacts.ErrForbidden) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return contactData(*c), nil
+}
+
+// rejectClientCommands answers the changes a device made. The bridge is
+// read-only, so each is refused; the device keeps the server's copy once
+// the item next changes, or after a resync.
+func rejectClientCommands(commands *node) *node {
+	if commands == nil || len(commands.children) == 0 {
+		return nil
+	}
+	responses := el(pageAirSync, "Responses")
+	for _, cmd := range commands.children {
+		switch cmd.name {
+		case "Add":
+			responses.add(el(pageAirSync, "Add",
+				textEl(pageAirSync, "ClientId", cmd.value(pageAirSync, "ClientId")),
+				textEl(pageAirSync, "Status", syncStatusConversionError)))
+		case "Change", "Delete":
+			responses.add(el(pageAirSync, cmd.name,
+				textEl(pageAirSync, "ServerId", cmd.value(pageAirSync, "ServerId")),
+				textEl(pageAirSync, "Status", syncStatusConversionError)))
+		case "Fetch":
+			responses.add(el(pageAirSync, "Fetch",
+				textEl(pageAirSync, "ServerId", cmd.value(pageAirSync, "ServerId")),
+				textEl(pageAirSync, "Status", syncStatusNotFound)))
+		}
+	}
+	if len(responses.children) == 0 {
+		return nil
+	}
+	return responses
+}
@@ -0,0 +1,311 @@
+package activesync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Code pages of the ActiveSync WBXML vocabulary (MS-ASWBXML) used by the
+// supported commands.
+const (
+	pageAirSync         byte = 0
+	pageContacts        byte = 1
+	pageCalendar        byte = 4
+	pageFolderHierarchy byte = 7
+	pagePing            byte = 13
+	pageAirSyncBase     byte = 17
+)
+
+// pageTags lists each code page's tags in token order, starting at 0x05. An
+// empty name is an unassigned token.
+var pageTags = map[byte][]string{
+	pageAirSync: {
+		"Sync", "Responses", "Add", "Change", "Delete", "Fetch", "SyncKey", "ClientId",
+		"ServerId", "Status", "Collection", "Class", "Version", "CollectionId", "GetChanges",
+		"MoreAvailable", "WindowSize", "Commands", "Options", "FilterType", "Truncation",
+		"RTFTruncation", "Conflict", "Collections", "ApplicationData", "DeletesAsMoves",
+		"NotifyGUID", "Supported", "SoftDelete", "MIMESupport", "MIMETruncation", "Wait",
+		"Limit", "Partial", "ConversationMode", "MaxItems", "HeartbeatInterval",
+	},
+	pageContacts: {
+		"Anniversary", "AssistantName", "AssistantTelephoneNumber", "Birthday", "Body",
+		"BodySize", "BodyTruncated", "Business2PhoneNumber", "BusinessCity", "BusinessCountry",
+		"BusinessPostalCode", "BusinessState", "BusinessStreet", "BusinessFaxNumber",
+		"BusinessPhoneNumber", "CarPhoneNumber", "Categories", "Category", "Children", "Child",
+		"CompanyName", "Department", "Email1Address", "Email2Address", "Email3Address", "FileAs",
+		"FirstName", "Home2PhoneNumber", "HomeCity", "HomeCountry", "HomePostalCode", "HomeState",
+		"HomeStreet", "HomeFaxNumber", "HomePhoneNumber", "JobTitle", "LastName", "MiddleName",
+		"MobilePhoneNumber", "OfficeLocation", "OtherCity", "OtherCountry", "OtherPostalCode",
+		"OtherState", "OtherStreet", "PagerNumber", "RadioPhoneNumber", "Spouse", "Suffix",
+		"Title", "WebPage", "YomiCompanyName", "YomiFirstName", "YomiLastName", "CompressedRTF",
+		"Picture", "Alias", "WeightedRank",
+	},
+	pageCalendar: {
+		"TimeZone", "AllDayEvent", "Attendees", "Attendee", "Email", "Name", "Body",
+		"BodyTruncated", "BusyStatus", "Categories", "Category", "CompressedRTF", "DtStamp",
+		"EndTime", "Exception", "Exceptions", "Deleted", "ExceptionStartTime", "Location",
+		"MeetingStatus", "OrganizerEmail", "OrganizerName", "Recurrence", "Type", "Until",
+		"Occurrences", "Interval", "DayOfWeek", "DayOfMonth", "WeekOfMonth", "MonthOfYear",
+		"Reminder", "Sensitivity", "Subject", "StartTime", "UID", "AttendeeStatus", "AttendeeType",
+	},
+	pageFolderHierarchy: {
+		"Folders", "Folder", "DisplayName", "ServerId", "ParentId", "Type", "Response", "Status",
+		"ContentClass", "Changes", "Add", "Delete", "Update", "SyncKey", "FolderCreate",
+		"FolderDelete", "FolderUpdate", "FolderSync", "Count", "Version",
+	},
+	pagePing: {
+		"Ping", "AutdState", "Status", "HeartbeatInterval", "Folders", "Folder", "Id", "Class",
+		"MaxFolders",
+	},
+	pageAirSyncBase: {
+		"BodyPreference", "Type", "TruncationSize", "AllOrNone", "", "Body", "Data",
+		"EstimatedDataSize", "Truncated", "Attachments", "Attachment", "DisplayName",
+		"FileReference", "Method", "ContentId", "ContentLocation", "IsInline", "NativeBodyType",
+		"ContentType", "Preview",
+	},
+}
+
+// Global WBXML tokens.
+const (
+	tokSwitchPage = 0x00
+	tokEnd        = 0x01
+	tokStrI       = 0x03
+	tokOpaque     = 0xc3
+)
+
+// maxDepth bounds element nesting in a decoded document.
+const maxDepth = 32
+
+var errMalformedWBXML = errors.New("activesync: malformed WBXML")
+
+// node is one element of a WBXML document. Elements carry either text or
+// children.
+type node struct {
+	page     byte
+	name     string
+	text     string
+	children []*node
+}
+
+func el(page byte, name string, children ...*node) *node {
+	return &node{page: page, name: name, children: children}
+}
+
+func textEl(page byte, name, text string) *node {
+	return &node{page: page, name: name, text: text}
+}
+
+// add appends the non-nil children.
+func (n *node) add(children ...*node) *node {
+	for _, c := range children {
+		if c != nil {
+			n.children = append(n.children, c)
+		}
+	}
+	return n
+}
+
+func (n *node) child(page byte, name string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.page == page && c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) all(page byte, name string) []*node {
+	if n == nil {
+		return nil
+	}
+	var out []*node
+	for _, c := range n.children {
+		if c.page == page && c.name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// value returns the text of the named child, or "" when it is absent.
+func (n *node) value(page byte, name string) string {
+	if c := n.child(page, name); c != nil {
+		return c.text
+	}
+	return ""
+}
+
+// encodeWBXML writes root as a WBXML 1.3 document in UTF-8.
+func encodeWBXML(root *node) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x03, 0x01, 0x6a, 0x00})
+	page := byte(0)
+	var write func(n *node)
+	write = func(n *node) {
+		if n.page != page {
+			buf.WriteByte(tokSwitchPage)
+			buf.WriteByte(n.page)
+			page = n.page
+		}
+		token := tagToken(n.page, n.name)
+		if n.text == "" && len(n.children) == 0 {
+			buf.WriteByte(token)
+			return
+		}
+		buf.WriteByte(token | 0x40)
+		if n.text != "" {
+			buf.WriteByte(tokStrI)
+			buf.WriteString(n.text)
+			buf.WriteByte(0)
+		}
+		for _, c := range n.children {
+			write(c)
+		}
+		buf.WriteByte(tokEnd)
+	}
+	write(root)
+	return buf.Bytes()
+}
+
+func tagToken(page byte, name string) byte {
+	for i, tag := range pageTags[page] {
+		if tag == name {
+			return byte(i + 5)
+		}
+	}
+	panic(fmt.Sprintf("activesync: no tag %q on code page %d", name, page))
+}
+
+// decodeWBXML parses a WBXML document. Tags outside the known code pages
+// decode with an empty name so callers skip them.
+func decodeWBXML(data []byte) (*node, error) {
+	d := &decoder{data: data}
+	if _, err := d.byte(); err != nil { // version
+		return nil, err
+	}
+	if _, err := d.mbUint(); err != nil { // public identifier
+		return nil, err
+	}
+	if _, err := d.mbUint(); err != nil { // charset; clients send UTF-8
+		return nil, err
+	}
+	tableLen, err := d.mbUint()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.skip(tableLen); err != nil {
+		return nil, err
+	}
+
+	var root *node
+	var stack []*node
+	page := byte(0)
+	for d.pos < len(d.data) {
+		b, _ := d.byte()
+		switch b {
+		case tokSwitchPage:
+			if page, err = d.byte(); err != nil {
+				return nil, err
+			}
+		case tokEnd:
+			if len(stack) == 0 {
+				return nil, errMalformedWBXML
+			}
+			stack = stack[:len(stack)-1]
+		case tokStrI:
+			s, err := d.cstring()
+			if err != nil || len(stack) == 0 {
+				return nil, errMalformedWBXML
+			}
+			stack[len(stack)-1].text += s
+		case tokOpaque:
+			n, err := d.mbUint()
+			if err != nil || len(stack) == 0 || n > len(d.data)-d.pos {
+				return nil, errMalformedWBXML
+			}
+			stack[len(stack)-1].text += string(d.data[d.pos : d.pos+n])
+			d.pos += n
+		default:
+			if b&0x80 != 0 || b&0x3f < 5 {
+				// Attributes and the remaining global tokens are not used
+				// by ActiveSync.
+				return nil, errMalformedWBXML
+			}
+			n := &node{page: page}
+			if tags := pageTags[page]; int(b&0x3f)-5 < len(tags) {
+				n.name = tags[b&0x3f-5]
+			}
+			switch {
+			case len(stack) > 0:
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			case root == nil:
+				root = n
+			default:
+				return nil, errMalformedWBXML
+			}
+			if b&0x40 != 0 {
+				if len(stack) == maxDepth {
+					return nil, errMalformedWBXML
+				}
+				stack = append(stack, n)
+			}
+		}
+	}
+	if root == nil || len(stack) != 0 {
+		return nil, errMalformedWBXML
+	}
+	return root, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, errMalformedWBXML
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+// mbUint reads a multi-byte unsigned integer.
+func (d *decoder) mbUint() (int, error) {
+	v := 0
+	for i := 0; i < 5; i++ {
+		b, err := d.byte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<7 | int(b&0x7f)
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errMalformedWBXML
+}
+
+func (d *decoder) skip(n int) error {
+	if n > len(d.data)-d.pos {
+		return errMalformedWBXML
+	}
+	d.pos += n
+	return nil
+}
+
+func (d *decoder) cstring() (string, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		return "", errMalformedWBXML
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	d.pos += end + 1
+	return s, nil
+}
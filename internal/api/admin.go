@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/backup"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/fsck"
+	"github.com/jw6ventures/calcard/internal/http/drain"
+	"github.com/jw6ventures/calcard/internal/jobs"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type backupStatusResponse struct {
+	backup.Status
+	Snapshots []backupSnapshotSummary `json:"snapshots"`
+}
+
+type backupSnapshotSummary struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"createdAt"`
+	Collections int    `json:"collections"`
+}
+
+type runFsckRequest struct {
+	Repair bool `json:"repair"`
+}
+
+type restoreBackupRequest struct {
+	Kind         string `json:"kind"`
+	CollectionID int64  `json:"collectionId"`
+	Overwrite    bool   `json:"overwrite"`
+}
+
+// SetBackups attaches the backup service used by the admin endpoints; nil
+// leaves backups reported as disabled.
+func (h *Handler) SetBackups(svc *backup.Service) {
+	h.backups = svc
+}
+
+// SetFsck attaches the consistency check service used by the admin
+// endpoints; nil leaves them unavailable.
+func (h *Handler) SetFsck(svc *fsck.Service) {
+	h.fsck = svc
+}
+
+// SetReloader attaches the configuration reloader used by the admin reload
+// endpoint; nil leaves it unavailable.
+func (h *Handler) SetReloader(r *config.Reloader) {
+	h.reloader = r
+}
+
+// Reconfigure applies a reloaded configuration.
+func (h *Handler) Reconfigure(cfg *config.Config) {
+	h.live.Store(cfg)
+}
+
+// config returns the most recently reloaded configuration, or the one the
+// handler was created with.
+func (h *Handler) config() *config.Config {
+	if cfg := h.live.Load(); cfg != nil {
+		return cfg
+	}
+	return h.cfg
+}
+
+// requireAdmin allows only users listed in APP_ADMIN_EMAILS, signed in as
+// themselves.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return false
+	}
+	if h.actingAdmin(r, user) {
+		return true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
+// actingAdmin reports whether user is an admin acting as themselves. An
+// admin impersonating someone never gets admin rights, even as another
+// admin.
+func (h *Handler) actingAdmin(r *http.Request, user *store.User) bool {
+	if _, impersonated := auth.ImpersonationFromContext(r.Context()); impersonated {
+		return false
+	}
+	return h.isAdmin(user)
+}
+
+// isAdmin reports whether user is listed in APP_ADMIN_EMAILS.
+func (h *Handler) isAdmin(user *store.User) bool {
+	if cfg := h.config(); cfg != nil {
+		for _, email := range cfg.AdminEmails {
+			if strings.EqualFold(email, user.PrimaryEmail) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *Handler) requireBackups(w http.ResponseWriter) bool {
+	if h.backups == nil {
+		http.Error(w, "backups are not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// GetBackupStatus reports the backup schedule, the last run, and the
+// completed snapshots available for restore.
+func (h *Handler) GetBackupStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	resp := backupStatusResponse{Status: h.backups.Status(), Snapshots: []backupSnapshotSummary{}}
+	if h.backups != nil {
+		manifests, err := h.backups.Snapshots(r.Context())
+		if err != nil {
+			http.Error(w, "failed to list snapshots", http.StatusInternalServerError)
+			return
+		}
+		for _, m := range manifests {
+			resp.Snapshots = append(resp.Snapshots, backupSnapshotSummary{
+				ID:          m.ID,
+				CreatedAt:   m.CreatedAt.UTC().Format(time.RFC3339),
+				Collections: len(m.Collections),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// backupRunResponse is the backup status with the job that tracks the run.
+type backupRunResponse struct {
+	backup.Status
+	JobID string `json:"jobId,omitempty"`
+}
+
+// RunBackup starts an out-of-schedule snapshot in the background. With a job
+// runner the snapshot is tracked as a job of the admin who started it.
+func (h *Handler) RunBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireBackups(w) {
+		return
+	}
+	if h.jobs != nil && h.store != nil && h.store.Jobs != nil {
+		h.runBackupJob(w, r)
+		return
+	}
+	if err := h.backups.Trigger(); err != nil {
+		if errors.Is(err, backup.ErrBusy) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to start backup", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, h.backups.Status())
+}
+
+func (h *Handler) runBackupJob(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	if h.backups.Status().Running {
+		http.Error(w, backup.ErrBusy.Error(), http.StatusConflict)
+		return
+	}
+	job, err := h.jobs.Start(r.Context(), user.ID, jobs.KindBackup, func(ctx context.Context, _ *jobs.Progress) (any, error) {
+		manifest, err := h.backups.Run(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"snapshot": manifest.ID, "collections": len(manifest.Collections)}, nil
+	})
+	if err != nil {
+		http.Error(w, "failed to start backup", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	status := h.backups.Status()
+	status.Running = true
+	writeJSON(w, http.StatusAccepted, backupRunResponse{Status: status, JobID: job.ID})
+}
+
+// GetBackupSnapshot returns the manifest of one snapshot.
+func (h *Handler) GetBackupSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireBackups(w) {
+		return
+	}
+	manifest, err := h.backups.Snapshot(r.Context(), chi.URLParam(r, "snapshot"))
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, manifest)
+}
+
+// RestoreBackup restores one calendar or address book from a snapshot.
+func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireBackups(w) {
+		return
+	}
+	var req restoreBackupRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != backup.KindCalendar && req.Kind != backup.KindAddressBook {
+		http.Error(w, "kind must be calendar or addressbook", http.StatusBadRequest)
+		return
+	}
+	result, err := h.backups.Restore(r.Context(), chi.URLParam(r, "snapshot"), req.Kind, req.CollectionID, req.Overwrite)
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) requireFsck(w http.ResponseWriter) bool {
+	if h.fsck == nil {
+		http.Error(w, "consistency checks are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// GetFsckStatus reports the consistency check schedule and the report of
+// the last completed check.
+func (h *Handler) GetFsckStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireFsck(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.fsck.Status())
+}
+
+// RunFsck starts a consistency check in the background, optionally
+// repairing what can be fixed automatically.
+func (h *Handler) RunFsck(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireFsck(w) {
+		return
+	}
+	var req runFsckRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.fsck.Trigger(req.Repair); err != nil {
+		if errors.Is(err, fsck.ErrBusy) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to start check", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, h.fsck.Status())
+}
+
+// ReloadConfig re-reads the configuration and applies the settings that can
+// change without a restart. An invalid configuration is rejected and the
+// running one kept.
+func (h *Handler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	if h.reloader == nil {
+		http.Error(w, "configuration reload is not available", http.StatusServiceUnavailable)
+		return
+	}
+	result, err := h.reloader.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Migrator applies the database migrations shipped with the server;
+// *migration.Manager from jw6-go-utils implements it.
+type Migrator interface {
+	GetDBVersion() (string, error)
+	MigrateDatabase() error
+}
+
+type migrationStatusResponse struct {
+	DatabaseVersion string `json:"databaseVersion"`
+	// PreviousVersion is set when migrations ran.
+	PreviousVersion string `json:"previousVersion,omitempty"`
+}
+
+// SetMigrator attaches the migrations behind the admin migration endpoints;
+// nil leaves them unavailable.
+func (h *Handler) SetMigrator(m Migrator) {
+	h.migrator = m
+}
+
+func (h *Handler) requireMigrator(w http.ResponseWriter) bool {
+	if h.migrator == nil {
+		http.Error(w, "migrations are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// GetMigrations reports the schema version of the database.
+func (h *Handler) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireMigrator(w) {
+		return
+	}
+	version, err := h.migrator.GetDBVersion()
+	if err != nil {
+		http.Error(w, "failed to read database version", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, migrationStatusResponse{DatabaseVersion: version})
+}
+
+// RunMigrations applies the migrations the database is missing, as the
+// server does at startup, for a database restored or rolled back while it
+// ran. One run at a time is allowed.
+func (h *Handler) RunMigrations(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireMigrator(w) {
+		return
+	}
+	if !h.migrating.TryLock() {
+		http.Error(w, "migrations are already running", http.StatusConflict)
+		return
+	}
+	defer h.migrating.Unlock()
+	before, err := h.migrator.GetDBVersion()
+	if err != nil {
+		http.Error(w, "failed to read database version", http.StatusInternalServerError)
+		return
+	}
+	if err := h.migrator.MigrateDatabase(); err != nil {
+		http.Error(w, "migration failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after, err := h.migrator.GetDBVersion()
+	if err != nil {
+		http.Error(w, "failed to read database version", http.StatusInternalServerError)
+		return
+	}
+	resp := migrationStatusResponse{DatabaseVersion: after}
+	if after != before {
+		resp.PreviousVersion = before
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type drainStatusResponse struct {
+	Draining bool    `json:"draining"`
+	Since    *string `json:"since,omitempty"`
+}
+
+// SetDrainer attaches the drain state behind the admin drain endpoints.
+func (h *Handler) SetDrainer(d *drain.Drainer) {
+	h.drainer = d
+}
+
+// GetDrainStatus reports whether the server is draining.
+func (h *Handler) GetDrainStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireDrainer(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.drainStatus())
+}
+
+// StartDrain starts draining the server ahead of a shutdown, as SIGTERM
+// does. In-flight requests, this one included, are allowed to finish.
+func (h *Handler) StartDrain(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireDrainer(w) {
+		return
+	}
+	h.drainer.Drain()
+	writeJSON(w, http.StatusAccepted, h.drainStatus())
+}
+
+func (h *Handler) requireDrainer(w http.ResponseWriter) bool {
+	if h.drainer == nil {
+		http.Error(w, "draining is not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) drainStatus() drainStatusResponse {
+	since, ok := h.drainer.Since()
+	if !ok {
+		return drainStatusResponse{}
+	}
+	return drainStatusResponse{Draining: true, Since: formatOptionalTime(&since)}
+}
+
+func writeBackupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, backup.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
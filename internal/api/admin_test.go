@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/backup"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/fsck"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func adminRequest(method, target, body, email, snapshot string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1, PrimaryEmail: email}))
+	routeCtx := chi.NewRouteContext()
+	if snapshot != "" {
+		routeCtx.URLParams.Add("snapshot", snapshot)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestBackupAdminEndpointsRequireAdmin(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"Admin@example.com"}}
+	h := NewHandler(cfg, &store.Store{})
+
+	rec := httptest.NewRecorder()
+	h.GetBackupStatus(rec, adminRequest(http.MethodGet, "/api/admin/backups", "", "user@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetBackupStatus(rec, adminRequest(http.MethodGet, "/api/admin/backups", "", "admin@example.com", ""))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Fatalf("admin status = %d body=%s, want disabled backups", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.RunBackup(rec, adminRequest(http.MethodPost, "/api/admin/backups", "", "admin@example.com", ""))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("run without backups = %d, want 503", rec.Code)
+	}
+}
+
+func TestBackupAdminStatusAndRestore(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"admin@example.com"}}
+	cfg.Backup.Dir = t.TempDir()
+	cfg.Backup.Interval = time.Hour
+	cfg.Backup.Retention = 1
+	st := &store.Store{Users: &fakeUserRepo{users: map[int64]*store.User{}}}
+	svc, err := backup.New(cfg, st, nil)
+	if err != nil {
+		t.Fatalf("backup.New: %v", err)
+	}
+	manifest, err := svc.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	h := NewHandler(cfg, st)
+	h.SetBackups(svc)
+
+	rec := httptest.NewRecorder()
+	h.GetBackupStatus(rec, adminRequest(http.MethodGet, "/api/admin/backups", "", "admin@example.com", ""))
+	var status backupStatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if !status.Enabled || status.LastSnapshot != manifest.ID || len(status.Snapshots) != 1 || status.Snapshots[0].ID != manifest.ID {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RestoreBackup(rec, adminRequest(http.MethodPost, "/api/admin/backups/x/restore", `{"kind":"calendar","collectionId":5}`, "admin@example.com", manifest.ID))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("restore of unknown collection = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RestoreBackup(rec, adminRequest(http.MethodPost, "/api/admin/backups/x/restore", `{"kind":"tasks","collectionId":5}`, "admin@example.com", manifest.ID))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("restore with invalid kind = %d, want 400", rec.Code)
+	}
+}
+
+func TestFsckAdminEndpoints(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"admin@example.com"}}
+	cfg.Fsck.Repair = true
+	h := NewHandler(cfg, &store.Store{})
+
+	rec := httptest.NewRecorder()
+	h.RunFsck(rec, adminRequest(http.MethodPost, "/api/admin/fsck", "", "admin@example.com", ""))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("run without service = %d, want 503", rec.Code)
+	}
+
+	st := &store.Store{
+		Users:            &fakeUserRepo{users: map[int64]*store.User{}},
+		DeletedResources: &fakeOrphanedTombstones{},
+	}
+	h.SetFsck(fsck.New(cfg, st, nil))
+
+	rec = httptest.NewRecorder()
+	h.GetFsckStatus(rec, adminRequest(http.MethodGet, "/api/admin/fsck", "", "user@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RunFsck(rec, adminRequest(http.MethodPost, "/api/admin/fsck", `{"repair":"yes"}`, "admin@example.com", ""))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid body = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RunFsck(rec, adminRequest(http.MethodPost, "/api/admin/fsck", `{"repair":true}`, "admin@example.com", ""))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("run = %d body=%s, want 202", rec.Code, rec.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec = httptest.NewRecorder()
+		h.GetFsckStatus(rec, adminRequest(http.MethodGet, "/api/admin/fsck", "", "admin@example.com", ""))
+		var status fsck.Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		if status.LastReport != nil {
+			if !status.AutoRepair || !status.LastReport.Repair || len(status.LastReport.Issues) != 1 || !status.LastReport.Issues[0].Repaired {
+				t.Fatalf("unexpected status %+v", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("check did not finish: %s", rec.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type fakeOrphanedTombstones struct {
+	store.DeletedResourceRepository
+	removed bool
+}
+
+func (f *fakeOrphanedTombstones) ListOrphaned(ctx context.Context) ([]store.DeletedResource, error) {
+	if f.removed {
+		return nil, nil
+	}
+	return []store.DeletedResource{{ID: 1, ResourceType: "event", CollectionID: 42, UID: "gone", ResourceName: "gone"}}, nil
+}
+
+func (f *fakeOrphanedTombstones) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
+	f.removed = true
+	return nil
+}
+
+func TestReloadConfigEndpoint(t *testing.T) {
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{})
+
+	rec := httptest.NewRecorder()
+	h.ReloadConfig(rec, adminRequest(http.MethodPost, "/api/admin/config/reload", "", "admin@example.com", ""))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("reload without reloader = %d, want 503", rec.Code)
+	}
+
+	h.SetReloader(config.NewReloader())
+	rec = httptest.NewRecorder()
+	h.ReloadConfig(rec, adminRequest(http.MethodPost, "/api/admin/config/reload", "", "user@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin reload = %d, want 403", rec.Code)
+	}
+
+	h.Reconfigure(&config.Config{AdminEmails: []string{"user@example.com"}})
+	rec = httptest.NewRecorder()
+	h.GetFsckStatus(rec, adminRequest(http.MethodGet, "/api/admin/fsck", "", "admin@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("removed admin status = %d, want 403", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.GetFsckStatus(rec, adminRequest(http.MethodGet, "/api/admin/fsck", "", "user@example.com", ""))
+	if rec.Code == http.StatusForbidden {
+		t.Fatal("expected the reloaded admin list to apply")
+	}
+}
+
+type fakeMigrator struct {
+	version string
+	pending string
+}
+
+func (f *fakeMigrator) GetDBVersion() (string, error) { return f.version, nil }
+
+func (f *fakeMigrator) MigrateDatabase() error {
+	if f.pending != "" {
+		f.version, f.pending = f.pending, ""
+	}
+	return nil
+}
+
+func TestMigrationEndpoints(t *testing.T) {
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{})
+	rec := httptest.NewRecorder()
+	h.RunMigrations(rec, adminRequest(http.MethodPost, "/api/admin/migrations", "", "admin@example.com", ""))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("migrate without migrator = %d, want 503", rec.Code)
+	}
+
+	h.SetMigrator(&fakeMigrator{version: "1.1.43", pending: "1.1.45"})
+	run := func() migrationStatusResponse {
+		rec := httptest.NewRecorder()
+		h.RunMigrations(rec, adminRequest(http.MethodPost, "/api/admin/migrations", "", "admin@example.com", ""))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("migrate = %d %s", rec.Code, rec.Body.String())
+		}
+		var resp migrationStatusResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if got := run(); got != (migrationStatusResponse{DatabaseVersion: "1.1.45", PreviousVersion: "1.1.43"}) {
+		t.Fatalf("first run = %+v", got)
+	}
+	if got := run(); got != (migrationStatusResponse{DatabaseVersion: "1.1.45"}) {
+		t.Fatalf("second run = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetMigrations(rec, adminRequest(http.MethodGet, "/api/admin/migrations", "", "user@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want 403", rec.Code)
+	}
+}
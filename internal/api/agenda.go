@@ -0,0 +1,176 @@
+package api
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/digest"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultAgendaDays = 1
+	maxAgendaDays     = 31
+)
+
+type agendaItemResponse struct {
+	CalendarID    int64                  `json:"calendarId"`
+	CalendarName  string                 `json:"calendarName"`
+	UID           string                 `json:"uid"`
+	Summary       string                 `json:"summary"`
+	Location      string                 `json:"location,omitempty"`
+	Start         string                 `json:"start"`
+	End           string                 `json:"end"`
+	AllDay        bool                   `json:"allDay"`
+	Latitude      *float64               `json:"latitude,omitempty"`
+	Longitude     *float64               `json:"longitude,omitempty"`
+	TravelWarning *travelWarningResponse `json:"travelWarning,omitempty"`
+}
+
+// travelWarningResponse says an item cannot be reached in time from the one
+// before it.
+type travelWarningResponse struct {
+	FromUID       string  `json:"fromUid"`
+	FromSummary   string  `json:"fromSummary"`
+	DistanceKm    float64 `json:"distanceKm"`
+	TravelMinutes int     `json:"travelMinutes"`
+	GapMinutes    int     `json:"gapMinutes"`
+}
+
+type agendaResponse struct {
+	Start    string               `json:"start"`
+	End      string               `json:"end"`
+	SpeedKmh float64              `json:"speedKmh"`
+	Items    []agendaItemResponse `json:"items"`
+}
+
+// Agenda returns the occurrences of events on the caller's own calendars
+// for the next days days (default 1, at most 31), from the start of today
+// in their timezone. An item that the caller cannot get to in time from the
+// one before it, going in a straight line between their coordinates at
+// speedKmh (default APP_TRAVEL_SPEED_KMH), carries a travel warning.
+func (h *Handler) Agenda(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	_, start, end, ok := agendaWindow(w, r, user)
+	if !ok {
+		return
+	}
+	speed := 30.0
+	if cfg := h.config(); cfg != nil && cfg.TravelSpeedKmh > 0 {
+		speed = float64(cfg.TravelSpeedKmh)
+	}
+	if raw := r.URL.Query().Get("speedKmh"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || v <= 0 || v > 1000 {
+			http.Error(w, "invalid speedKmh", http.StatusBadRequest)
+			return
+		}
+		speed = v
+	}
+	items, err := h.events.Agenda(r.Context(), user, start, end)
+	if err != nil {
+		http.Error(w, "failed to load agenda", http.StatusInternalServerError)
+		return
+	}
+	resp := agendaResponse{
+		Start:    start.Format(time.RFC3339),
+		End:      end.Format(time.RFC3339),
+		SpeedKmh: speed,
+		Items:    make([]agendaItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		resp.Items = append(resp.Items, agendaItemResponseFor(item))
+	}
+	for _, c := range events.TravelConflicts(items, speed) {
+		from := items[c.From]
+		resp.Items[c.To].TravelWarning = &travelWarningResponse{
+			FromUID:       from.UID,
+			FromSummary:   from.Summary,
+			DistanceKm:    math.Round(c.DistanceKm*10) / 10,
+			TravelMinutes: int(math.Ceil(c.Travel.Minutes())),
+			GapMinutes:    int(c.Gap.Minutes()),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func agendaItemResponseFor(item events.AgendaItem) agendaItemResponse {
+	resp := agendaItemResponse{
+		CalendarID:   item.CalendarID,
+		CalendarName: item.CalendarName,
+		UID:          item.UID,
+		Summary:      item.Summary,
+		Location:     item.Location,
+		Start:        item.Start.Format(time.RFC3339),
+		End:          item.End.Format(time.RFC3339),
+		AllDay:       item.AllDay,
+	}
+	if item.Geo != nil {
+		lat, lon := item.Geo.Latitude, item.Geo.Longitude
+		resp.Latitude, resp.Longitude = &lat, &lon
+	}
+	return resp
+}
+
+// AgendaText returns the caller's agenda for the next days days (default 1,
+// at most 31), from the start of today, as plain text for scripts and small
+// devices: the occurrences of events on their own calendars under a heading
+// per day, then their open tasks due by the end, including overdue ones.
+// Dates, times and headings follow the caller's locale and timezone.
+func (h *Handler) AgendaText(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	prefs, start, end, ok := agendaWindow(w, r, user)
+	if !ok {
+		return
+	}
+	items, err := h.events.Agenda(r.Context(), user, start, end)
+	if err != nil {
+		http.Error(w, "failed to load agenda", http.StatusInternalServerError)
+		return
+	}
+	tasks, err := h.events.OpenTasks(r.Context(), user, events.TaskFilter{DueBefore: &end})
+	if err != nil {
+		http.Error(w, "failed to load agenda", http.StatusInternalServerError)
+		return
+	}
+	text := digest.Text(prefs, start, items, tasks)
+	if text == "" {
+		text = prefs.T("digest.empty") + "\n"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.WriteString(w, text)
+}
+
+// agendaWindow returns the caller's preferences and the span of the days
+// query parameter from the start of today in their timezone, writing a 400
+// when it is out of range.
+func agendaWindow(w http.ResponseWriter, r *http.Request, user *store.User) (locale.Preferences, time.Time, time.Time, bool) {
+	prefs := locale.ForUser(user)
+	days := defaultAgendaDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxAgendaDays {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return prefs, time.Time{}, time.Time{}, false
+		}
+		days = v
+	}
+	now := time.Now().In(prefs.Location)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, prefs.Location)
+	return prefs, start, start.AddDate(0, 0, days), true
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestAgendaWarnsOfUnreachableEvents(t *testing.T) {
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	event := func(uid, geo string, hour int) string {
+		start := day.Add(time.Duration(hour) * time.Hour)
+		return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:" + uid + "\r\nGEO:" + geo +
+			"\r\nDTSTART:" + start.Format("20060102T150405Z") + "\r\nDTEND:" + start.Add(time.Hour).Format("20060102T150405Z") +
+			"\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	}
+	office, visit := "Office", "Visit"
+	h := NewHandler(&config.Config{TravelSpeedKmh: 30}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events: &fakeEventRepo{events: map[string]store.Event{
+			"1:office": {CalendarID: 1, UID: "office", RawICAL: event("office", "52.5219;13.4132", 9), Summary: &office},
+			"1:visit":  {CalendarID: 1, UID: "visit", RawICAL: event("visit", "52.3906;13.0645", 10), Summary: &visit},
+		}},
+	})
+	user := &store.User{ID: 1, Timezone: "UTC"}
+	get := func(query string) (*httptest.ResponseRecorder, agendaResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/agenda"+query, nil)
+		rec := httptest.NewRecorder()
+		h.Agenda(rec, req.WithContext(auth.WithUser(req.Context(), user)))
+		var resp agendaResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec, resp
+	}
+
+	rec, resp := get("")
+	if rec.Code != http.StatusOK || resp.SpeedKmh != 30 || len(resp.Items) != 2 {
+		t.Fatalf("Agenda() = %d %+v", rec.Code, resp)
+	}
+	if resp.Items[0].TravelWarning != nil || resp.Items[0].Latitude == nil {
+		t.Fatalf("first item = %+v", resp.Items[0])
+	}
+	warning := resp.Items[1].TravelWarning
+	if warning == nil || warning.FromUID != "office" || warning.GapMinutes != 0 || warning.TravelMinutes < 50 {
+		t.Fatalf("travel warning = %+v", warning)
+	}
+
+	if _, resp := get("?speedKmh=500"); len(resp.Items) != 2 || resp.Items[1].TravelWarning == nil {
+		t.Fatalf("back-to-back events must always warn: %+v", resp.Items)
+	}
+	for _, query := range []string{"?days=0", "?speedKmh=0", "?speedKmh=fast"} {
+		if rec, _ := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("Agenda(%s) status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestAgendaTextListsTodayInUserLocale(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone data unavailable")
+	}
+	now := time.Now().In(berlin)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, berlin).UTC()
+	summary := "Planung"
+	raw := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:plan\r\nSUMMARY:Planung\r\n" +
+		"DTSTART:" + start.Format("20060102T150405Z") + "\r\nDTEND:" + start.Add(time.Hour).Format("20060102T150405Z") +
+		"\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{
+		"1:plan": {CalendarID: 1, UID: "plan", RawICAL: raw, Summary: &summary},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Arbeit"}, Editor: true},
+		}},
+		Events: eventRepo,
+	})
+	user := &store.User{ID: 1, Locale: "de-DE", Timezone: "Europe/Berlin"}
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/agenda.txt"+query, nil)
+		rec := httptest.NewRecorder()
+		h.AgendaText(rec, req.WithContext(auth.WithUser(req.Context(), user)))
+		return rec
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("AgendaText() = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "09:00") || !strings.Contains(body, "Planung (Arbeit)") {
+		t.Fatalf("AgendaText() body:\n%s", body)
+	}
+
+	if rec := get("?days=32"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("days=32 status = %d, want 400", rec.Code)
+	}
+
+	delete(eventRepo.events, "1:plan")
+	if rec := get("?days=7"); rec.Body.String() != "Keine Termine\n" {
+		t.Fatalf("empty agenda = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type alarmProviderRequest struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	Token     string `json:"token"`
+	Recipient string `json:"recipient"`
+}
+
+type alarmProviderResponse struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+	// HasToken reports whether a token is set; tokens and Pushover user
+	// keys are never returned.
+	HasToken  bool   `json:"hasToken"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type alarmDeliveryResponse struct {
+	ID         int64   `json:"id"`
+	ProviderID int64   `json:"providerId"`
+	CalendarID int64   `json:"calendarId"`
+	UID        string  `json:"uid"`
+	AlarmID    string  `json:"alarmId"`
+	FireAt     string  `json:"fireAt"`
+	Title      string  `json:"title"`
+	Status     string  `json:"status"`
+	Attempts   int     `json:"attempts"`
+	LastError  string  `json:"lastError,omitempty"`
+	SentAt     *string `json:"sentAt,omitempty"`
+}
+
+func (h *Handler) requireAlarmProviders(w http.ResponseWriter) bool {
+	if h.store == nil || h.store.Providers == nil || h.store.AlarmDeliveries == nil {
+		http.Error(w, "alarm providers are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListAlarmProviders returns the services the user has their alarms pushed
+// to.
+func (h *Handler) ListAlarmProviders(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireAlarmProviders(w) {
+		return
+	}
+	list, err := h.store.Providers.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load alarm providers", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]alarmProviderResponse, 0, len(list))
+	for _, p := range list {
+		resp = append(resp, alarmProviderResponseFor(p))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateAlarmProvider adds a Gotify, ntfy, Pushover or webhook provider
+// that the user's event alarms are pushed to from then on.
+func (h *Handler) CreateAlarmProvider(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireAlarmProviders(w) {
+		return
+	}
+	var req alarmProviderRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	p := store.NotificationProvider{
+		UserID:    user.ID,
+		Kind:      req.Kind,
+		Name:      req.Name,
+		URL:       req.URL,
+		Token:     req.Token,
+		Recipient: req.Recipient,
+	}
+	if err := h.alarms.Normalize(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saved, err := h.store.Providers.Create(r.Context(), p)
+	if err != nil {
+		http.Error(w, "failed to save alarm provider", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, alarmProviderResponseFor(*saved))
+}
+
+// DeleteAlarmProvider stops pushing alarms to a provider and forgets its
+// deliveries.
+func (h *Handler) DeleteAlarmProvider(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireAlarmProviders(w) {
+		return
+	}
+	id, ok := parseAlarmProviderID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := h.store.Providers.Delete(r.Context(), user.ID, id)
+	if err != nil {
+		http.Error(w, "failed to delete alarm provider", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "alarm provider not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestAlarmProvider sends a test message through a provider, answering 502
+// with the provider's complaint when it fails.
+func (h *Handler) TestAlarmProvider(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireAlarmProviders(w) {
+		return
+	}
+	id, ok := parseAlarmProviderID(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.store.Providers.Get(r.Context(), user.ID, id)
+	if err != nil {
+		http.Error(w, "failed to load alarm provider", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "alarm provider not found", http.StatusNotFound)
+		return
+	}
+	if err := h.alarms.SendTest(r.Context(), user, *p); err != nil {
+		http.Error(w, "test message failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlarmDeliveries returns the user's most recent alarm deliveries,
+// newest first: up to limit (default 50, at most 200), with whether each
+// was sent, is waiting for a retry, or was given up on.
+func (h *Handler) ListAlarmDeliveries(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireAlarmProviders(w) {
+		return
+	}
+	limit := defaultDeliveryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxDeliveryLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	list, err := h.store.AlarmDeliveries.ListByUser(r.Context(), user.ID, limit)
+	if err != nil {
+		http.Error(w, "failed to load alarm deliveries", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]alarmDeliveryResponse, 0, len(list))
+	for _, d := range list {
+		item := alarmDeliveryResponse{
+			ID:         d.ID,
+			ProviderID: d.ProviderID,
+			CalendarID: d.CalendarID,
+			UID:        d.UID,
+			AlarmID:    d.AlarmID,
+			FireAt:     d.FireAt.UTC().Format(time.RFC3339),
+			Title:      d.Title,
+			Status:     d.Status,
+			Attempts:   d.Attempts,
+			LastError:  d.LastError,
+		}
+		if d.SentAt != nil {
+			sent := d.SentAt.UTC().Format(time.RFC3339)
+			item.SentAt = &sent
+		}
+		resp = append(resp, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func parseAlarmProviderID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid alarm provider id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func alarmProviderResponseFor(p store.NotificationProvider) alarmProviderResponse {
+	return alarmProviderResponse{
+		ID:        p.ID,
+		Kind:      p.Kind,
+		Name:      p.Name,
+		URL:       p.URL,
+		HasToken:  p.Token != "",
+		CreatedAt: p.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestAlarmProvidersHideTokensAndSendTests(t *testing.T) {
+	var tested []string
+	gotify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tested = append(tested, r.URL.Path+" "+r.Header.Get("X-Gotify-Key"))
+	}))
+	defer gotify.Close()
+
+	cfg := &config.Config{}
+	cfg.Alarms.AllowPrivateProviders = true
+	providers := &storetest.Providers{}
+	h := NewHandler(cfg, &store.Store{Providers: providers, AlarmDeliveries: &storetest.AlarmDeliveries{}})
+	serve := func(method, path, id, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		req = req.WithContext(auth.WithUser(ctx, &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/api/preferences/alarm-providers", "", `{"kind":"gotify","url":"`+gotify.URL+`"}`, h.CreateAlarmProvider); rec.Code != http.StatusBadRequest {
+		t.Fatalf("POST without token = %d, want 400", rec.Code)
+	}
+	rec := serve(http.MethodPost, "/api/preferences/alarm-providers", "", `{"kind":"gotify","name":"Phone","url":"`+gotify.URL+`","token":"app-token"}`, h.CreateAlarmProvider)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"hasToken":true`) || strings.Contains(rec.Body.String(), "app-token") {
+		t.Fatalf("POST = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodGet, "/api/preferences/alarm-providers", "", "", h.ListAlarmProviders); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "app-token") {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(http.MethodPost, "/api/preferences/alarm-providers/1/test", "1", "", h.TestAlarmProvider); rec.Code != http.StatusNoContent || len(tested) != 1 || tested[0] != "/message app-token" {
+		t.Fatalf("POST test = %d %s, sent %v", rec.Code, rec.Body.String(), tested)
+	}
+	if rec := serve(http.MethodPost, "/api/preferences/alarm-providers/2/test", "2", "", h.TestAlarmProvider); rec.Code != http.StatusNotFound {
+		t.Fatalf("POST test of a missing provider = %d, want 404", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/api/preferences/alarm-deliveries?limit=500", "", "", h.ListAlarmDeliveries); rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET deliveries with a bad limit = %d, want 400", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/api/preferences/alarm-providers/1", "1", "", h.DeleteAlarmProvider); rec.Code != http.StatusNoContent || len(providers.List) != 0 {
+		t.Fatalf("DELETE = %d, left %+v", rec.Code, providers.List)
+	}
+}
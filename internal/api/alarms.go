@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type snoozeRequest struct {
+	// Until is when the alarm fires again; Minutes is the same, from now.
+	Until   *time.Time `json:"until"`
+	Minutes int        `json:"minutes"`
+}
+
+// AcknowledgeAlarm dismisses one alarm of an event, so no other device
+// rings it again.
+func (h *Handler) AcknowledgeAlarm(w http.ResponseWriter, r *http.Request) {
+	h.editAlarm(w, r, func(user *store.User, calendarID int64, uid, alarmID string) (*store.Event, error) {
+		return h.events.AcknowledgeAlarm(r.Context(), user, calendarID, uid, alarmID)
+	})
+}
+
+// SnoozeAlarm dismisses one alarm of an event and adds an RFC 9074 snooze
+// alarm that fires again later.
+func (h *Handler) SnoozeAlarm(w http.ResponseWriter, r *http.Request) {
+	var req snoozeRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var until time.Time
+	switch {
+	case req.Until != nil && req.Minutes == 0:
+		until = *req.Until
+	case req.Until == nil && req.Minutes > 0:
+		until = time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	default:
+		http.Error(w, "one of until or minutes is required", http.StatusBadRequest)
+		return
+	}
+	h.editAlarm(w, r, func(user *store.User, calendarID int64, uid, alarmID string) (*store.Event, error) {
+		return h.events.SnoozeAlarm(r.Context(), user, calendarID, uid, alarmID, until)
+	})
+}
+
+func (h *Handler) editAlarm(w http.ResponseWriter, r *http.Request, edit func(user *store.User, calendarID int64, uid, alarmID string) (*store.Event, error)) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, uid, ok := parseCalendarIDAndUID(w, r)
+	if !ok {
+		return
+	}
+	rawAlarmID := chi.URLParam(r, "alarmId")
+	alarmID, err := url.PathUnescape(rawAlarmID)
+	if err != nil || alarmID == "" {
+		alarmID = rawAlarmID
+	}
+	if alarmID == "" {
+		http.Error(w, "invalid alarm id", http.StatusBadRequest)
+		return
+	}
+	ev, err := edit(user, calendarID, uid, alarmID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+ev.ETag+`"`)
+	writeJSON(w, http.StatusOK, toEventResponse(*ev))
+}
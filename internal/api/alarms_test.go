@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func alarmRequest(t *testing.T, h *Handler, action, alarmID, body string, calendarID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/calendars/"+calendarID+"/events/event-1/alarms/"+alarmID+"/"+action, strings.NewReader(body))
+	req = withUserAndRoute(req, calendarID, "event-1")
+	chi.RouteContext(req.Context()).URLParams.Add("alarmId", alarmID)
+	rec := httptest.NewRecorder()
+	if action == "snooze" {
+		h.SnoozeAlarm(rec, req)
+	} else {
+		h.AcknowledgeAlarm(rec, req)
+	}
+	return rec
+}
+
+func TestSnoozeAndAcknowledgeAlarm(t *testing.T) {
+	raw := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:event-1\r\nSUMMARY:Stand-up\r\nDTSTART:20260320T100000Z\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{
+		"1:event-1": {CalendarID: 1, UID: "event-1", ResourceName: "event-1", RawICAL: raw, ETag: "e1"},
+		"2:event-1": {CalendarID: 2, UID: "event-1", ResourceName: "event-1", RawICAL: raw, ETag: "e1"},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 9, Name: "Team"}, Shared: true},
+		}},
+		Events:     eventRepo,
+		ACLEntries: &fakeACLRepo{},
+	})
+
+	rec := alarmRequest(t, h, "snooze", "0", `{"minutes":10}`, "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SnoozeAlarm() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp eventResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Alarms) != 2 || resp.Alarms[0].Acknowledged == nil || resp.Alarms[1].Snoozes != resp.Alarms[0].UID || resp.LastAcknowledged == nil {
+		t.Fatalf("unexpected alarms after snooze: %+v", resp.Alarms)
+	}
+	if stored := eventRepo.events["1:event-1"].RawICAL; !strings.Contains(stored, "RELATED-TO;RELTYPE=SNOOZE:"+resp.Alarms[0].UID) {
+		t.Fatalf("expected a stored snooze alarm, got %s", stored)
+	}
+
+	rec = alarmRequest(t, h, "acknowledge", url.PathEscape(resp.Alarms[1].ID), "", "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("AcknowledgeAlarm() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	resp = eventResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Alarms) != 1 || resp.Alarms[0].Acknowledged == nil {
+		t.Fatalf("unexpected alarms after acknowledgment: %+v", resp.Alarms)
+	}
+
+	for _, tc := range []struct {
+		action, alarmID, body, calendarID string
+		want                              int
+	}{
+		{"acknowledge", "missing", "", "1", http.StatusNotFound},
+		{"snooze", resp.Alarms[0].ID, `{}`, "1", http.StatusBadRequest},
+		{"snooze", resp.Alarms[0].ID, `{"until":"2020-01-01T00:00:00Z"}`, "1", http.StatusBadRequest},
+		{"snooze", resp.Alarms[0].ID, `{"minutes":100000}`, "1", http.StatusBadRequest},
+		{"acknowledge", "0", "", "2", http.StatusForbidden},
+	} {
+		if rec := alarmRequest(t, h, tc.action, tc.alarmID, tc.body, tc.calendarID); rec.Code != tc.want {
+			t.Fatalf("%s %s on calendar %s: status = %d, want %d: %s", tc.action, tc.alarmID, tc.calendarID, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+}
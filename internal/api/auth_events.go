@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
+)
+
+const (
+	defaultAuthEventLimit = 50
+	maxAuthEventLimit     = 500
+)
+
+type authEventResponse struct {
+	ID               int64  `json:"id"`
+	Outcome          string `json:"outcome"`
+	NewAddress       bool   `json:"newAddress"`
+	IP               string `json:"ip"`
+	UserAgent        string `json:"userAgent,omitempty"`
+	Client           string `json:"client,omitempty"`
+	AppPasswordID    *int64 `json:"appPasswordId,omitempty"`
+	AppPasswordLabel string `json:"appPasswordLabel,omitempty"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+// ListAuthEvents returns the caller's recent DAV sign-ins and refused
+// passwords, newest first, up to `limit` (default 50).
+func (h *Handler) ListAuthEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if h.store == nil || h.store.AuthEvents == nil {
+		http.Error(w, "sign-in history not available", http.StatusServiceUnavailable)
+		return
+	}
+	limit := defaultAuthEventLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(v, maxAuthEventLimit)
+	}
+	events, err := h.store.AuthEvents.ListByUser(r.Context(), user.ID, limit)
+	if err != nil {
+		http.Error(w, "failed to load sign-in history", http.StatusInternalServerError)
+		return
+	}
+	labels := map[int64]string{}
+	if h.store.AppPasswords != nil {
+		passwords, err := h.store.AppPasswords.ListByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load devices", http.StatusInternalServerError)
+			return
+		}
+		for _, p := range passwords {
+			labels[p.ID] = p.Label
+		}
+	}
+
+	resp := make([]authEventResponse, 0, len(events))
+	for _, e := range events {
+		item := authEventResponse{
+			ID:            e.ID,
+			Outcome:       e.Outcome,
+			NewAddress:    e.NewAddress,
+			IP:            e.IPAddress,
+			UserAgent:     e.UserAgent,
+			AppPasswordID: e.AppPasswordID,
+			CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if e.UserAgent != "" {
+			item.Client = metrics.ClientFamily(e.UserAgent)
+		}
+		if e.AppPasswordID != nil {
+			item.AppPasswordLabel = labels[*e.AppPasswordID]
+		}
+		resp = append(resp, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
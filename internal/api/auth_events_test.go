@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeAuthEventRepo struct {
+	store.AuthEventRepository
+	events    []store.AuthEvent
+	gotUserID int64
+	gotLimit  int
+}
+
+func (f *fakeAuthEventRepo) ListByUser(_ context.Context, userID int64, limit int) ([]store.AuthEvent, error) {
+	f.gotUserID, f.gotLimit = userID, limit
+	return f.events, nil
+}
+
+func TestListAuthEvents(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	phone := int64(3)
+	events := &fakeAuthEventRepo{events: []store.AuthEvent{
+		{ID: 9, UserID: 1, AppPasswordID: &phone, Outcome: store.AuthOutcomeSuccess, NewAddress: true, IPAddress: "198.51.100.4", UserAgent: "DAVx5/4.3 (Android)", CreatedAt: at},
+		{ID: 8, UserID: 1, Outcome: store.AuthOutcomeFailure, IPAddress: "203.0.113.5", CreatedAt: at.Add(-time.Minute)},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		AuthEvents:   events,
+		AppPasswords: &fakeAppPasswordRepo{passwords: map[int64]*store.AppPassword{3: {ID: 3, UserID: 1, Label: "Phone"}}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth-events?limit=1000", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec := httptest.NewRecorder()
+	h.ListAuthEvents(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if events.gotUserID != 1 || events.gotLimit != maxAuthEventLimit {
+		t.Fatalf("listed user %d with limit %d", events.gotUserID, events.gotLimit)
+	}
+	var resp []authEventResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 2 || resp[0].AppPasswordLabel != "Phone" || resp[0].Client != "davx5" || !resp[0].NewAddress || resp[0].CreatedAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected events %+v", resp)
+	}
+	if resp[1].Outcome != "failure" || resp[1].AppPasswordID != nil {
+		t.Fatalf("unexpected failure event %+v", resp[1])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/auth-events?limit=0", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec = httptest.NewRecorder()
+	h.ListAuthEvents(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid limit status = %d", rec.Code)
+	}
+}
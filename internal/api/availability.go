@@ -0,0 +1,134 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultAvailabilityDays = 90
+	maxAvailabilityDays     = 365
+)
+
+type availabilityResponse struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+	// Members is how many people the density is averaged over.
+	Members int `json:"members"`
+	// Density is the busy share of each hour, indexed by weekday (Sunday
+	// first) and then hour of the day.
+	Density [7][24]float64 `json:"density"`
+}
+
+// Availability returns how busy the caller, or with group the members of a
+// group they own, usually are in each hour of the week over the last days
+// days (default 90, at most 365), in the tz timezone or the caller's own.
+// Group members are only counted when they publish free/busy or are the
+// caller, since the density would otherwise reveal their schedule.
+func (h *Handler) Availability(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	days := defaultAvailabilityDays
+	if raw := query.Get("days"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxAvailabilityDays {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	loc := locale.ForUser(user).Location
+	if raw := query.Get("tz"); raw != "" {
+		var err error
+		if loc, err = time.LoadLocation(raw); err != nil {
+			http.Error(w, "invalid tz", http.StatusBadRequest)
+			return
+		}
+	}
+	users := []*store.User{user}
+	if raw := query.Get("group"); raw != "" {
+		var ok bool
+		if users, ok = h.availabilityGroupMembers(w, r, user, raw); !ok {
+			return
+		}
+	}
+
+	end := time.Now().UTC().Truncate(time.Hour)
+	start := end.AddDate(0, 0, -days)
+	density, err := h.events.BusyHeatmap(r.Context(), users, start, end, loc)
+	if err != nil {
+		http.Error(w, "failed to load availability", http.StatusInternalServerError)
+		return
+	}
+	for day := range density {
+		for hour := range density[day] {
+			density[day][hour] = math.Round(density[day][hour]*1000) / 1000
+		}
+	}
+	writeJSON(w, http.StatusOK, availabilityResponse{
+		Start:    start.Format(time.RFC3339),
+		End:      end.Format(time.RFC3339),
+		Timezone: loc.String(),
+		Members:  len(users),
+		Density:  density,
+	})
+}
+
+func (h *Handler) availabilityGroupMembers(w http.ResponseWriter, r *http.Request, user *store.User, raw string) ([]*store.User, bool) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid group id", http.StatusBadRequest)
+		return nil, false
+	}
+	if h.store.UserGroups == nil {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return nil, false
+	}
+	group, err := h.store.UserGroups.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load group", http.StatusInternalServerError)
+		return nil, false
+	}
+	if group == nil || group.OwnerUserID != user.ID {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return nil, false
+	}
+	members := make([]*store.User, 0, len(group.MemberIDs))
+	for _, memberID := range group.MemberIDs {
+		if memberID == user.ID {
+			members = append(members, user)
+			continue
+		}
+		if h.store.FreeBusyLinks == nil {
+			continue
+		}
+		link, err := h.store.FreeBusyLinks.GetByUser(r.Context(), memberID)
+		if err != nil {
+			http.Error(w, "failed to load availability", http.StatusInternalServerError)
+			return nil, false
+		}
+		if link == nil {
+			continue
+		}
+		member, err := h.store.Users.GetByID(r.Context(), memberID)
+		if err != nil {
+			http.Error(w, "failed to load availability", http.StatusInternalServerError)
+			return nil, false
+		}
+		if member != nil {
+			members = append(members, member)
+		}
+	}
+	return members, true
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeFreeBusyLinkRepo struct {
+	store.FreeBusyLinkRepository
+	links map[int64]*store.FreeBusyLink
+}
+
+func (f *fakeFreeBusyLinkRepo) GetByUser(_ context.Context, userID int64) (*store.FreeBusyLink, error) {
+	return f.links[userID], nil
+}
+
+func dailyEvent(calendarID int64, uid string, hours int) store.Event {
+	start := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Duration(hours) * time.Hour)
+	return store.Event{
+		CalendarID: calendarID,
+		UID:        uid,
+		DTStart:    &start,
+		DTEnd:      &end,
+		RawICAL:    "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART:20200101T090000Z\r\nRRULE:FREQ=DAILY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	events := &fakeEventRepo{events: map[string]store.Event{}}
+	for i, ev := range []store.Event{dailyEvent(1, "mine", 1), dailyEvent(2, "published", 2), dailyEvent(3, "private", 3)} {
+		events.events[key(int64(i+1), ev.UID)] = ev
+	}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Work"}},
+			3: {Calendar: store.Calendar{ID: 3, UserID: 3, Name: "Work"}},
+		}},
+		Events: events,
+		Users: &fakeUserRepo{users: map[int64]*store.User{
+			2: {ID: 2, PrimaryEmail: "two@example.com"},
+			3: {ID: 3, PrimaryEmail: "three@example.com"},
+		}},
+		UserGroups: &fakeUserGroupRepo{groups: map[int64]*store.UserGroup{
+			1: {ID: 1, OwnerUserID: 1, Name: "Team", MemberIDs: []int64{1, 2, 3}},
+			2: {ID: 2, OwnerUserID: 2, Name: "Other", MemberIDs: []int64{2}},
+		}},
+		FreeBusyLinks: &fakeFreeBusyLinkRepo{links: map[int64]*store.FreeBusyLink{2: {UserID: 2}}},
+	})
+
+	get := func(query string) (*httptest.ResponseRecorder, availabilityResponse) {
+		rec := httptest.NewRecorder()
+		h.Availability(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/availability"+query, nil), "", ""))
+		var resp availabilityResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec, resp
+	}
+
+	rec, resp := get("?tz=UTC&days=28")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Availability() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if resp.Members != 1 || resp.Timezone != "UTC" || resp.Density[1][9] != 1 || resp.Density[1][10] != 0 {
+		t.Fatalf("unexpected own availability %s", rec.Body.String())
+	}
+
+	// Member 3 does not publish free/busy, so only 1 and 2 are counted.
+	rec, resp = get("?tz=UTC&group=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Availability(group) status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if resp.Members != 2 || resp.Density[3][9] != 1 || resp.Density[3][10] != 0.5 || resp.Density[3][11] != 0 {
+		t.Fatalf("unexpected group availability %s", rec.Body.String())
+	}
+
+	for query, want := range map[string]int{
+		"?days=0":        http.StatusBadRequest,
+		"?days=366":      http.StatusBadRequest,
+		"?tz=Nowhere/At": http.StatusBadRequest,
+		"?group=x":       http.StatusBadRequest,
+		"?group=2":       http.StatusNotFound,
+		"?group=9":       http.StatusNotFound,
+	} {
+		if rec, _ := get(query); rec.Code != want {
+			t.Fatalf("Availability(%q) status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+const maxBatchDeleteBodyBytes = 1 << 20
+
+type batchDeleteRequest struct {
+	UIDs []string `json:"uids"`
+	// Before is a date (YYYY-MM-DD, midnight UTC) or an RFC 3339 time.
+	Before   string `json:"before"`
+	Category string `json:"category"`
+}
+
+type batchDeleteResponse struct {
+	Deleted []string          `json:"deleted"`
+	Held    []string          `json:"held"`
+	Skipped []batchDeleteSkip `json:"skipped"`
+	HasMore bool              `json:"hasMore"`
+}
+
+type batchDeleteSkip struct {
+	UID    string `json:"uid"`
+	Reason string `json:"reason"`
+}
+
+// BatchDeleteEvents serves POST /api/calendars/{id}/events/batch-delete. It
+// deletes up to events.MaxBatchDelete events named by uid, or matched by
+// before and category, bumping the calendar's ctag once. Deletions past the
+// mass-deletion threshold are held for the owner like DAV deletions are.
+func (h *Handler) BatchDeleteEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBatchDeleteBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBatchDeleteBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req batchDeleteRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	input := events.BatchDeleteInput{UIDs: req.UIDs, Category: req.Category, Client: r.UserAgent()}
+	if req.Before != "" {
+		before, err := parseBatchDeleteTime(req.Before)
+		if err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		input.Before = &before
+	}
+
+	var policy events.MassDeletePolicy
+	if cfg := h.config(); cfg != nil {
+		policy = events.MassDeletePolicy{
+			Percent:  cfg.DAV.MassDeletePercent,
+			Window:   cfg.DAV.MassDeleteWindow,
+			MinItems: cfg.DAV.MassDeleteMinItems,
+		}
+	}
+	result, err := h.events.DeleteEvents(r.Context(), user, calendarID, input, policy)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+
+	resp := batchDeleteResponse{
+		Deleted: nonNil(result.Deleted),
+		Held:    nonNil(result.Held),
+		Skipped: make([]batchDeleteSkip, 0, len(result.Skipped)),
+		HasMore: result.More,
+	}
+	for _, s := range result.Skipped {
+		resp.Skipped = append(resp.Skipped, batchDeleteSkip{UID: s.UID, Reason: s.Reason})
+	}
+	status := http.StatusOK
+	if len(resp.Held) > 0 {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, resp)
+}
+
+func parseBatchDeleteTime(raw string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("expected YYYY-MM-DD or RFC 3339")
+	}
+	return t, nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
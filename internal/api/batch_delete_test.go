@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeHeldDeletionRepo struct {
+	store.HeldDeletionRepository
+	held []store.HeldDeletion
+}
+
+func (f *fakeHeldDeletionRepo) IsHeld(ctx context.Context, resourceType string, collectionID int64, uid string) (bool, error) {
+	for _, d := range f.held {
+		if d.ResourceType == resourceType && d.CollectionID == collectionID && d.UID == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeHeldDeletionRepo) Hold(ctx context.Context, held store.HeldDeletion) error {
+	f.held = append(f.held, held)
+	return nil
+}
+
+func batchDeleteTestEvent(uid string, start time.Time, props ...string) store.Event {
+	end := start.Add(time.Hour)
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n"
+	for _, p := range props {
+		raw += p + "\r\n"
+	}
+	raw += "END:VEVENT\r\nEND:VCALENDAR\r\n"
+	return store.Event{CalendarID: 1, UID: uid, ResourceName: uid, RawICAL: raw, DTStart: &start, DTEnd: &end}
+}
+
+func postBatchDelete(handler *Handler, body string) (*httptest.ResponseRecorder, batchDeleteResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/api/calendars/1/events/batch-delete", strings.NewReader(body))
+	req = withUserAndRoute(req, "1", "")
+	rec := httptest.NewRecorder()
+	handler.BatchDeleteEvents(rec, req)
+	var resp batchDeleteResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestBatchDeleteEventsByUIDsAndQuery(t *testing.T) {
+	old := time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC)
+	recent := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := &fakeEventRepo{events: map[string]store.Event{
+		"1:a":        batchDeleteTestEvent("a", old),
+		"1:b":        batchDeleteTestEvent("b", old, "CATEGORIES:Travel,Work"),
+		"1:c":        batchDeleteTestEvent("c", old.AddDate(0, 1, 0), "CATEGORIES:Travel"),
+		"1:series":   batchDeleteTestEvent("series", old, "RRULE:FREQ=WEEKLY", "CATEGORIES:Travel"),
+		"1:upcoming": batchDeleteTestEvent("upcoming", recent, "CATEGORIES:Travel"),
+	}}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events: events,
+	})
+
+	rec, resp := postBatchDelete(handler, `{"uids":["a","missing"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if strings.Join(resp.Deleted, ",") != "a" || len(resp.Skipped) != 1 || resp.Skipped[0] != (batchDeleteSkip{UID: "missing", Reason: "not found"}) {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if _, ok := events.events["1:a"]; ok {
+		t.Fatal("expected event a deleted")
+	}
+
+	// The open-ended series and the upcoming event still have instances
+	// after the cutoff.
+	rec, resp = postBatchDelete(handler, `{"before":"2025-01-01","category":"travel"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := strings.Join(resp.Deleted, ","); got != "b,c" || resp.HasMore {
+		t.Fatalf("deleted = %s (hasMore %v), want b,c", got, resp.HasMore)
+	}
+	for _, uid := range []string{"series", "upcoming"} {
+		if _, ok := events.events["1:"+uid]; !ok {
+			t.Fatalf("expected %s kept", uid)
+		}
+	}
+
+	for _, body := range []string{`{}`, `{"uids":["x"],"category":"Travel"}`, `{"before":"soon"}`} {
+		if rec, _ := postBatchDelete(handler, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s status = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestBatchDeleteEventsHoldsMassDeletions(t *testing.T) {
+	events := &fakeEventRepo{events: map[string]store.Event{}}
+	for i := 0; i < 30; i++ {
+		uid := fmt.Sprintf("ev-%02d", i)
+		events.events["1:"+uid] = batchDeleteTestEvent(uid, time.Date(2020, 1, 1+i, 9, 0, 0, 0, time.UTC))
+	}
+	held := &fakeHeldDeletionRepo{}
+	cfg := &config.Config{}
+	cfg.DAV.MassDeletePercent = 50
+	cfg.DAV.MassDeleteWindow = 10 * time.Minute
+	cfg.DAV.MassDeleteMinItems = 20
+	handler := NewHandler(cfg, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events:        events,
+		HeldDeletions: held,
+	})
+
+	rec, resp := postBatchDelete(handler, `{"uids":["ev-00","ev-01","ev-02"]}`)
+	if rec.Code != http.StatusOK || len(resp.Deleted) != 3 {
+		t.Fatalf("small batch: status %d, response %+v", rec.Code, resp)
+	}
+
+	rec, resp = postBatchDelete(handler, `{"before":"2021-01-01"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("mass batch status = %d, want 202", rec.Code)
+	}
+	if len(resp.Held) != 27 || len(resp.Deleted) != 0 {
+		t.Fatalf("expected all 27 held, got %+v", resp)
+	}
+	if len(held.held) != 27 || held.held[0].OwnerID != 1 || held.held[0].DeletedBy != 1 {
+		t.Fatalf("unexpected held deletions %+v", held.held)
+	}
+	if len(events.events) != 27 {
+		t.Fatalf("expected held events kept, %d left", len(events.events))
+	}
+}
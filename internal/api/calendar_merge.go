@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const maxCalendarMergeBodyBytes = 64 << 10
+
+type mergeCalendarRequest struct {
+	TargetID int64 `json:"targetId"`
+}
+
+type mergeCalendarResponse struct {
+	Moved int `json:"moved"`
+}
+
+type splitCalendarRequest struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// From and To are dates (YYYY-MM-DD, midnight UTC) or RFC 3339 times.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type splitCalendarResponse struct {
+	Calendar calendarResponse `json:"calendar"`
+	Moved    int              `json:"moved"`
+}
+
+type duplicateCalendarRequest struct {
+	Name string `json:"name"`
+	// Shift is an ISO 8601 duration of years, months, weeks and days, such
+	// as "P1Y", that every copied event is moved by.
+	Shift      string `json:"shift"`
+	SkipEvents bool   `json:"skipEvents"`
+}
+
+type duplicateCalendarResponse struct {
+	Calendar calendarResponse `json:"calendar"`
+	Copied   int              `json:"copied"`
+}
+
+// MergeCalendar serves POST /api/calendars/{id}/merge: it moves every event
+// of the calendar into the owner's calendar targetId, keeping UIDs.
+func (h *Handler) MergeCalendar(w http.ResponseWriter, r *http.Request) {
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req mergeCalendarRequest
+	if !decodeCalendarMergeBody(w, r, &req) {
+		return
+	}
+	owner, ok := h.calendarOwnerActor(w, r, calendarID)
+	if !ok {
+		return
+	}
+	moved, err := h.events.MergeCalendars(r.Context(), owner, calendarID, req.TargetID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mergeCalendarResponse{Moved: moved})
+}
+
+// SplitCalendar serves POST /api/calendars/{id}/split: it moves the events
+// matching category and the from/to range into a new calendar.
+func (h *Handler) SplitCalendar(w http.ResponseWriter, r *http.Request) {
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req splitCalendarRequest
+	if !decodeCalendarMergeBody(w, r, &req) {
+		return
+	}
+	input := events.SplitInput{Name: req.Name, Category: req.Category}
+	for _, bound := range []struct {
+		name string
+		raw  string
+		dst  **time.Time
+	}{{"from", req.From, &input.From}, {"to", req.To, &input.To}} {
+		if bound.raw == "" {
+			continue
+		}
+		t, err := parseBatchDeleteTime(bound.raw)
+		if err != nil {
+			http.Error(w, "invalid "+bound.name, http.StatusBadRequest)
+			return
+		}
+		*bound.dst = &t
+	}
+	owner, ok := h.calendarOwnerActor(w, r, calendarID)
+	if !ok {
+		return
+	}
+	cal, moved, err := h.events.SplitCalendar(r.Context(), owner, calendarID, input)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, splitCalendarResponse{
+		Calendar: calendarResponseForAccess(store.CalendarAccess{Calendar: *cal, OwnerEmail: owner.PrimaryEmail}),
+		Moved:    moved,
+	})
+}
+
+// DuplicateCalendar serves POST /api/calendars/{id}/duplicate: it copies a
+// calendar the user can read, with new event UIDs, into a new calendar of
+// theirs.
+func (h *Handler) DuplicateCalendar(w http.ResponseWriter, r *http.Request) {
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req duplicateCalendarRequest
+	if !decodeCalendarMergeBody(w, r, &req) {
+		return
+	}
+	input := events.DuplicateInput{Name: req.Name, SkipEvents: req.SkipEvents}
+	if req.Shift != "" {
+		shift, err := events.ParseDateShift(req.Shift)
+		if err != nil {
+			http.Error(w, "invalid shift", http.StatusBadRequest)
+			return
+		}
+		input.Shift = shift
+	}
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	cal, copied, err := h.events.DuplicateCalendar(r.Context(), user, calendarID, input)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, duplicateCalendarResponse{
+		Calendar: calendarResponseForAccess(store.CalendarAccess{Calendar: *cal, OwnerEmail: user.PrimaryEmail}),
+		Copied:   copied,
+	})
+}
+
+// calendarOwnerActor returns the user to merge or split calendarID as: the
+// signed-in user, or for an admin, the calendar's owner.
+func (h *Handler) calendarOwnerActor(w http.ResponseWriter, r *http.Request, calendarID int64) (*store.User, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !h.actingAdmin(r, user) {
+		return user, true
+	}
+	cal, err := h.store.Calendars.GetByID(r.Context(), calendarID)
+	if err != nil {
+		http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+		return nil, false
+	}
+	if cal == nil || cal.UserID == user.ID {
+		return user, true
+	}
+	owner, err := h.store.Users.GetByID(r.Context(), cal.UserID)
+	if err != nil {
+		http.Error(w, "failed to load calendar owner", http.StatusInternalServerError)
+		return nil, false
+	}
+	if owner == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return owner, true
+}
+
+func decodeCalendarMergeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCalendarMergeBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return false
+	}
+	if len(body) > maxCalendarMergeBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
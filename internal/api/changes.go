@@ -0,0 +1,235 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultChangeLimit = 100
+	maxChangeLimit     = 1000
+)
+
+type changeFeedResponse struct {
+	Changes []changeResponse `json:"changes"`
+	// Cursor is passed back to fetch the changes after this page.
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"hasMore"`
+}
+
+type changeResponse struct {
+	Type         string `json:"type"`
+	CollectionID int64  `json:"collectionId"`
+	UID          string `json:"uid"`
+	ResourceName string `json:"resourceName"`
+	Operation    string `json:"operation"`
+	ETag         string `json:"etag,omitempty"`
+	ChangedAt    string `json:"changedAt"`
+}
+
+// ListChanges returns the event and contact changes in every collection the
+// caller can read, and the calendars and address books they lost by deletion
+// or unsharing, oldest first, after the opaque cursor from the previous
+// page. Without a cursor it starts at the beginning of the feed; the cursor
+// "latest" returns no changes and the current position, for consumers that
+// have just listed everything.
+func (h *Handler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if h.store == nil || h.store.Changes == nil {
+		http.Error(w, "change feed not available", http.StatusServiceUnavailable)
+		return
+	}
+	limit := defaultChangeLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(v, maxChangeLimit)
+	}
+
+	var after store.ChangeCursor
+	switch raw := r.URL.Query().Get("cursor"); raw {
+	case "":
+	case "latest":
+		cursor, err := h.store.Changes.Latest(r.Context())
+		if err != nil {
+			http.Error(w, "failed to load changes", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, changeFeedResponse{Changes: []changeResponse{}, Cursor: encodeChangeCursor(cursor)})
+		return
+	default:
+		cursor, err := decodeChangeCursor(raw)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = cursor
+	}
+
+	cals, err := h.events.ListCalendars(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+		return
+	}
+	calendars := make(map[int64]*store.CalendarAccess, len(cals))
+	calendarIDs := make([]int64, 0, len(cals))
+	for i := range cals {
+		calendars[cals[i].ID] = &cals[i]
+		calendarIDs = append(calendarIDs, cals[i].ID)
+	}
+	books, err := h.contacts.ListAccessibleAddressBooks(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to load address books", http.StatusInternalServerError)
+		return
+	}
+	bookIDs := make([]int64, 0, len(books))
+	readableBooks := make(map[int64]bool, len(books))
+	for _, book := range books {
+		bookIDs = append(bookIDs, book.ID)
+		readableBooks[book.ID] = true
+	}
+
+	resp := changeFeedResponse{Changes: []changeResponse{}, Cursor: encodeChangeCursor(after)}
+	var changes []store.Change
+	if len(calendarIDs) > 0 || len(bookIDs) > 0 {
+		if changes, err = h.store.Changes.ListSince(r.Context(), calendarIDs, bookIDs, after, limit); err != nil {
+			http.Error(w, "failed to load changes", http.StatusInternalServerError)
+			return
+		}
+	}
+	hasMore := len(changes) == limit
+	if h.store.Tombstones != nil {
+		tombstones, err := h.store.Tombstones.ListAfter(r.Context(), user.ID, after, limit)
+		if err != nil {
+			http.Error(w, "failed to load changes", http.StatusInternalServerError)
+			return
+		}
+		hasMore = hasMore || len(tombstones) == limit
+		var removals []store.Change
+		for _, t := range tombstones {
+			// Collections shared again are still in the feed.
+			if (t.CollectionType == "calendar" && calendars[t.CollectionID] != nil) || (t.CollectionType == "addressbook" && readableBooks[t.CollectionID]) {
+				continue
+			}
+			removals = append(removals, store.Change{Cursor: t.Cursor, ResourceType: t.CollectionType, CollectionID: t.CollectionID, Deleted: true, ChangedAt: t.DeletedAt})
+		}
+		changes = mergeChanges(changes, removals)
+		if len(changes) > limit {
+			changes, hasMore = changes[:limit], true
+		}
+	}
+	if len(changes) == 0 {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// Shared calendars can hide single events through ACLs.
+	namesByCalendar := map[int64][]string{}
+	for _, c := range changes {
+		if c.ResourceType == "event" && calendars[c.CollectionID].UserID != user.ID {
+			namesByCalendar[c.CollectionID] = append(namesByCalendar[c.CollectionID], changeResourceName(c))
+		}
+	}
+	readable := map[int64]map[string]bool{}
+	for calID, names := range namesByCalendar {
+		if readable[calID], err = h.events.ReadableResources(r.Context(), user, calendars[calID], names); err != nil {
+			http.Error(w, "failed to load changes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, c := range changes {
+		if names, filtered := readable[c.CollectionID]; filtered && c.ResourceType == "event" && !names[changeResourceName(c)] {
+			continue
+		}
+		resp.Changes = append(resp.Changes, changeResponseFor(c))
+	}
+	// The cursor moves past hidden changes too, so the next page does not
+	// scan them again.
+	if len(changes) > 0 {
+		resp.Cursor = encodeChangeCursor(changes[len(changes)-1].Cursor)
+	}
+	resp.HasMore = hasMore
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// mergeChanges merges two lists of changes, each in feed order.
+func mergeChanges(a, b []store.Change) []store.Change {
+	if len(b) == 0 {
+		return a
+	}
+	merged := make([]store.Change, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if changeCursorLess(b[0].Cursor, a[0].Cursor) {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+func changeCursorLess(a, b store.ChangeCursor) bool {
+	return a.TxID < b.TxID || (a.TxID == b.TxID && a.Seq < b.Seq)
+}
+
+func changeResourceName(c store.Change) string {
+	if c.ResourceName != "" {
+		return c.ResourceName
+	}
+	return c.UID
+}
+
+func changeResponseFor(c store.Change) changeResponse {
+	op := "updated"
+	switch {
+	case c.Deleted:
+		op = "deleted"
+	case c.Created:
+		op = "created"
+	}
+	typ := "event"
+	switch c.ResourceType {
+	case "contact", "calendar", "addressbook":
+		typ = c.ResourceType
+	}
+	return changeResponse{
+		Type:         typ,
+		CollectionID: c.CollectionID,
+		UID:          c.UID,
+		ResourceName: changeResourceName(c),
+		Operation:    op,
+		ETag:         c.ETag,
+		ChangedAt:    c.ChangedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func encodeChangeCursor(c store.ChangeCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", c.TxID, c.Seq)))
+}
+
+func decodeChangeCursor(raw string) (store.ChangeCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return store.ChangeCursor{}, err
+	}
+	var c store.ChangeCursor
+	if n, err := fmt.Sscanf(string(data), "%d.%d", &c.TxID, &c.Seq); err != nil || n != 2 || c.TxID < 0 || c.Seq < 0 {
+		return store.ChangeCursor{}, fmt.Errorf("invalid cursor %q", raw)
+	}
+	return c, nil
+}
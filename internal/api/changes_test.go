@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeChangeRepo struct {
+	store.ChangeRepository
+	changes        []store.Change
+	latest         store.ChangeCursor
+	calendarIDs    []int64
+	addressBookIDs []int64
+	after          store.ChangeCursor
+	limit          int
+}
+
+func (f *fakeChangeRepo) ListSince(ctx context.Context, calendarIDs, addressBookIDs []int64, after store.ChangeCursor, limit int) ([]store.Change, error) {
+	f.calendarIDs, f.addressBookIDs, f.after, f.limit = calendarIDs, addressBookIDs, after, limit
+	return f.changes, nil
+}
+
+func (f *fakeChangeRepo) Latest(ctx context.Context) (store.ChangeCursor, error) {
+	return f.latest, nil
+}
+
+func listChanges(t *testing.T, h *Handler, user *store.User, query string) (int, changeFeedResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/changes"+query, nil)
+	req = req.WithContext(auth.WithUser(req.Context(), user))
+	rec := httptest.NewRecorder()
+	h.ListChanges(rec, req)
+	var resp changeFeedResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestListChangesFiltersHiddenEventsAndAdvancesCursor(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	changes := &fakeChangeRepo{changes: []store.Change{
+		{Cursor: store.ChangeCursor{TxID: 10, Seq: 1}, ResourceType: "event", CollectionID: 1, UID: "own", ResourceName: "own", ETag: "e1", Created: true, ChangedAt: changed},
+		{Cursor: store.ChangeCursor{TxID: 10, Seq: 2}, ResourceType: "event", CollectionID: 2, UID: "visible", ResourceName: "visible", ETag: "e2", ChangedAt: changed},
+		{Cursor: store.ChangeCursor{TxID: 11, Seq: 3}, ResourceType: "contact", CollectionID: 5, UID: "card", ResourceName: "card", Deleted: true, ChangedAt: changed},
+		{Cursor: store.ChangeCursor{TxID: 12, Seq: 4}, ResourceType: "event", CollectionID: 2, UID: "hidden", ResourceName: "hidden", ETag: "e3", ChangedAt: changed},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Mine"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Shared"}, Shared: true},
+			3: {Calendar: store.Calendar{ID: 3, UserID: 3, Name: "Other"}},
+		}},
+		AddressBooks: &fakeAddressBookRepo{books: map[int64]*store.AddressBook{5: {ID: 5, UserID: 1, Name: "Personal"}}},
+		ACLEntries: &fakeACLRepo{entries: []store.ACLEntry{
+			{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
+			{ResourcePath: "/dav/calendars/2/hidden", PrincipalHref: "/dav/principals/1/", IsGrant: false, Privilege: "read"},
+		}},
+		Changes: changes,
+	})
+
+	code, resp := listChanges(t, h, &store.User{ID: 1}, "?limit=4&cursor="+encodeChangeCursor(store.ChangeCursor{TxID: 9, Seq: 7}))
+	if code != http.StatusOK {
+		t.Fatalf("ListChanges() status = %d", code)
+	}
+	sort.Slice(changes.calendarIDs, func(i, j int) bool { return changes.calendarIDs[i] < changes.calendarIDs[j] })
+	if len(changes.calendarIDs) != 2 || changes.calendarIDs[0] != 1 || changes.calendarIDs[1] != 2 {
+		t.Fatalf("expected accessible calendars 1 and 2, got %v", changes.calendarIDs)
+	}
+	if len(changes.addressBookIDs) != 1 || changes.addressBookIDs[0] != 5 {
+		t.Fatalf("expected address book 5, got %v", changes.addressBookIDs)
+	}
+	if changes.after != (store.ChangeCursor{TxID: 9, Seq: 7}) || changes.limit != 4 {
+		t.Fatalf("unexpected cursor %+v or limit %d", changes.after, changes.limit)
+	}
+	if len(resp.Changes) != 3 {
+		t.Fatalf("expected the hidden event to be filtered, got %+v", resp.Changes)
+	}
+	if c := resp.Changes[0]; c.Type != "event" || c.Operation != "created" || c.ETag != "e1" || c.ChangedAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected created change %+v", c)
+	}
+	if resp.Changes[1].Operation != "updated" || resp.Changes[2].Type != "contact" || resp.Changes[2].Operation != "deleted" {
+		t.Fatalf("unexpected changes %+v", resp.Changes)
+	}
+	// The cursor moves past the hidden change.
+	if resp.Cursor != encodeChangeCursor(store.ChangeCursor{TxID: 12, Seq: 4}) || !resp.HasMore {
+		t.Fatalf("unexpected cursor %q hasMore=%v", resp.Cursor, resp.HasMore)
+	}
+}
+
+func TestListChangesCursors(t *testing.T) {
+	changes := &fakeChangeRepo{latest: store.ChangeCursor{TxID: 41, Seq: 99}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Mine"}, Editor: true},
+		}},
+		AddressBooks: &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}},
+		ACLEntries:   &fakeACLRepo{},
+		Changes:      changes,
+	})
+	user := &store.User{ID: 1}
+
+	code, resp := listChanges(t, h, user, "?cursor=latest")
+	if code != http.StatusOK || len(resp.Changes) != 0 || resp.Cursor != encodeChangeCursor(changes.latest) {
+		t.Fatalf("latest: status=%d resp=%+v", code, resp)
+	}
+	decoded, err := decodeChangeCursor(resp.Cursor)
+	if err != nil || decoded != changes.latest {
+		t.Fatalf("cursor round trip = %+v, %v", decoded, err)
+	}
+
+	code, resp = listChanges(t, h, user, "?cursor="+encodeChangeCursor(store.ChangeCursor{TxID: 3, Seq: 4}))
+	if code != http.StatusOK || resp.HasMore || resp.Cursor != encodeChangeCursor(store.ChangeCursor{TxID: 3, Seq: 4}) {
+		t.Fatalf("empty page should keep the cursor: status=%d resp=%+v", code, resp)
+	}
+
+	for _, query := range []string{"?cursor=not-a-cursor", "?cursor=" + encodeChangeCursor(store.ChangeCursor{TxID: -1}), "?limit=0"} {
+		if code, _ := listChanges(t, h, user, query); code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", query, code)
+		}
+	}
+
+	if code, _ := listChanges(t, NewHandler(&config.Config{}, &store.Store{}), user, ""); code != http.StatusServiceUnavailable {
+		t.Fatalf("without a change repository status = %d, want 503", code)
+	}
+}
+
+type fakeTombstoneRepo struct {
+	store.CollectionTombstoneRepository
+	tombstones []store.CollectionTombstone
+}
+
+func (f *fakeTombstoneRepo) ListAfter(ctx context.Context, userID int64, after store.ChangeCursor, limit int) ([]store.CollectionTombstone, error) {
+	var out []store.CollectionTombstone
+	for _, t := range f.tombstones {
+		if t.UserID == userID && len(out) < limit {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func TestListChangesReportsLostCollections(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	changes := &fakeChangeRepo{changes: []store.Change{
+		{Cursor: store.ChangeCursor{TxID: 10, Seq: 1}, ResourceType: "event", CollectionID: 1, UID: "a", ResourceName: "a", ETag: "e1", ChangedAt: changed},
+		{Cursor: store.ChangeCursor{TxID: 12, Seq: 5}, ResourceType: "event", CollectionID: 1, UID: "b", ResourceName: "b", ETag: "e2", ChangedAt: changed},
+	}}
+	tombstones := &fakeTombstoneRepo{tombstones: []store.CollectionTombstone{
+		{Cursor: store.ChangeCursor{TxID: 11, Seq: 2}, CollectionType: "calendar", CollectionID: 4, UserID: 1, OwnerUserID: 2, DeletedAt: changed},
+		// Shared again since: not lost.
+		{Cursor: store.ChangeCursor{TxID: 11, Seq: 3}, CollectionType: "calendar", CollectionID: 1, UserID: 1, OwnerUserID: 1, DeletedAt: changed},
+		{Cursor: store.ChangeCursor{TxID: 13, Seq: 6}, CollectionType: "addressbook", CollectionID: 9, UserID: 1, OwnerUserID: 1, DeletedAt: changed},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Mine"}, Editor: true},
+		}},
+		AddressBooks: &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}},
+		ACLEntries:   &fakeACLRepo{},
+		Changes:      changes,
+		Tombstones:   tombstones,
+	})
+
+	code, resp := listChanges(t, h, &store.User{ID: 1}, "?limit=2")
+	if code != http.StatusOK {
+		t.Fatalf("ListChanges() status = %d", code)
+	}
+	if len(resp.Changes) != 2 || resp.Changes[0].UID != "a" {
+		t.Fatalf("expected the first two changes in feed order, got %+v", resp.Changes)
+	}
+	if c := resp.Changes[1]; c.Type != "calendar" || c.CollectionID != 4 || c.Operation != "deleted" || c.ChangedAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected collection removal %+v", c)
+	}
+	if resp.Cursor != encodeChangeCursor(store.ChangeCursor{TxID: 11, Seq: 2}) || !resp.HasMore {
+		t.Fatalf("unexpected cursor %q hasMore=%v", resp.Cursor, resp.HasMore)
+	}
+
+	// A user who lost every collection still hears about it.
+	h = NewHandler(&config.Config{}, &store.Store{
+		Calendars:    &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{}},
+		AddressBooks: &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}},
+		ACLEntries:   &fakeACLRepo{},
+		Changes:      &fakeChangeRepo{},
+		Tombstones:   tombstones,
+	})
+	code, resp = listChanges(t, h, &store.User{ID: 1}, "")
+	if code != http.StatusOK || len(resp.Changes) != 3 || resp.Changes[2].Type != "addressbook" || resp.HasMore {
+		t.Fatalf("status=%d resp=%+v", code, resp)
+	}
+}
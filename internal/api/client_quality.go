@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+type clientQualityDeviceResponse struct {
+	AppPasswordID    *int64                 `json:"appPasswordId,omitempty"`
+	AppPasswordLabel string                 `json:"appPasswordLabel,omitempty"`
+	UserAgent        string                 `json:"userAgent"`
+	Client           string                 `json:"client"`
+	Repairs          []clientRepairResponse `json:"repairs"`
+}
+
+type clientRepairResponse struct {
+	Repair       string `json:"repair"`
+	Description  string `json:"description"`
+	Count        int64  `json:"count"`
+	LastResource string `json:"lastResource"`
+	FirstSeenAt  string `json:"firstSeenAt"`
+	LastSeenAt   string `json:"lastSeenAt"`
+}
+
+// ClientQuality reports which of the caller's devices sent calendar data
+// that had to be repaired, and how, most recently seen first, so clients
+// producing broken data stand out.
+func (h *Handler) ClientQuality(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	var repairs []store.ClientRepair
+	if h.store.ClientRepairs != nil {
+		var err error
+		repairs, err = h.store.ClientRepairs.ListByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load client quality", http.StatusInternalServerError)
+			return
+		}
+	}
+	resp := []clientQualityDeviceResponse{}
+	index := make(map[string]int)
+	for _, c := range repairs {
+		i, ok := index[c.DeviceKey]
+		if !ok {
+			resp = append(resp, clientQualityDeviceResponse{
+				AppPasswordID:    c.AppPasswordID,
+				AppPasswordLabel: c.AppPasswordLabel,
+				UserAgent:        c.UserAgent,
+				Client:           metrics.ClientFamily(c.UserAgent),
+				Repairs:          []clientRepairResponse{},
+			})
+			i = len(resp) - 1
+			index[c.DeviceKey] = i
+		}
+		resp[i].Repairs = append(resp[i].Repairs, clientRepairResponse{
+			Repair:       c.Repair,
+			Description:  utils.RepairDescriptions[c.Repair],
+			Count:        c.Count,
+			LastResource: c.LastResource,
+			FirstSeenAt:  c.FirstSeenAt.UTC().Format(time.RFC3339),
+			LastSeenAt:   c.LastSeenAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeClientRepairRepo struct {
+	store.ClientRepairRepository
+	repairs []store.ClientRepair
+}
+
+func (f *fakeClientRepairRepo) ListByUser(ctx context.Context, userID int64) ([]store.ClientRepair, error) {
+	return f.repairs, nil
+}
+
+func TestClientQualityGroupsRepairsByDevice(t *testing.T) {
+	seen := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tablet := int64(7)
+	h := NewHandler(&config.Config{}, &store.Store{ClientRepairs: &fakeClientRepairRepo{repairs: []store.ClientRepair{
+		{DeviceKey: "app-password:7", AppPasswordID: &tablet, AppPasswordLabel: "Tablet", UserAgent: "DAVx5/4.3 (Android)", Repair: "prodid", Count: 4, LastResource: "/dav/calendars/1/a.ics", FirstSeenAt: seen.Add(-time.Hour), LastSeenAt: seen},
+		{DeviceKey: "ua:SloppyCal/1.0", UserAgent: "SloppyCal/1.0", Repair: "line-endings", Count: 1, FirstSeenAt: seen, LastSeenAt: seen},
+		{DeviceKey: "app-password:7", AppPasswordID: &tablet, AppPasswordLabel: "Tablet", UserAgent: "DAVx5/4.3 (Android)", Repair: "line-endings", Count: 2, FirstSeenAt: seen, LastSeenAt: seen},
+	}}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/client-quality", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec := httptest.NewRecorder()
+	h.ClientQuality(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ClientQuality() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp []clientQualityDeviceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 2 || resp[0].AppPasswordLabel != "Tablet" || resp[0].Client != "davx5" || len(resp[0].Repairs) != 2 || len(resp[1].Repairs) != 1 {
+		t.Fatalf("unexpected report %s", rec.Body.String())
+	}
+	if r := resp[0].Repairs[0]; r.Repair != "prodid" || r.Count != 4 || r.Description == "" || r.LastSeenAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected repair %+v", r)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type conferenceHookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type conferenceHookResponse struct {
+	CalendarID int64  `json:"calendarId"`
+	URL        string `json:"url"`
+	// HasSecret reports whether requests are signed; the secret itself is
+	// never returned.
+	HasSecret bool   `json:"hasSecret"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// GetConferenceHook returns the conferencing webhook of an owned calendar.
+func (h *Handler) GetConferenceHook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	hook, err := h.events.ConferenceHook(r.Context(), user, calendarID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	if hook == nil {
+		http.Error(w, "conference hook not set", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, conferenceHookResponseFor(*hook))
+}
+
+// SetConferenceHook sets the webhook an owned calendar calls to provision
+// meeting links.
+func (h *Handler) SetConferenceHook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req conferenceHookRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	hook, err := h.events.SetConferenceHook(r.Context(), user, calendarID, req.URL, req.Secret)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conferenceHookResponseFor(*hook))
+}
+
+// DeleteConferenceHook stops an owned calendar from provisioning meeting
+// links.
+func (h *Handler) DeleteConferenceHook(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.events.DeleteConferenceHook(r.Context(), user, calendarID); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func conferenceHookResponseFor(hook store.ConferenceHook) conferenceHookResponse {
+	return conferenceHookResponse{
+		CalendarID: hook.CalendarID,
+		URL:        hook.URL,
+		HasSecret:  hook.Secret != "",
+		UpdatedAt:  hook.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeConferenceHookRepo struct {
+	hooks map[int64]store.ConferenceHook
+}
+
+func (f *fakeConferenceHookRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.ConferenceHook, error) {
+	if hook, ok := f.hooks[calendarID]; ok {
+		return &hook, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeConferenceHookRepo) Upsert(ctx context.Context, hook store.ConferenceHook) (*store.ConferenceHook, error) {
+	f.hooks[hook.CalendarID] = hook
+	return &hook, nil
+}
+
+func (f *fakeConferenceHookRepo) Delete(ctx context.Context, calendarID int64) error {
+	delete(f.hooks, calendarID)
+	return nil
+}
+
+func TestConferenceHookEndpoints(t *testing.T) {
+	hooks := &fakeConferenceHookRepo{hooks: map[int64]store.ConferenceHook{}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Mine"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Shared"}, Shared: true, Editor: true},
+		}},
+		ConferenceHooks: hooks,
+	})
+
+	rec := httptest.NewRecorder()
+	h.GetConferenceHook(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/conference-hook", nil), "1", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GetConferenceHook() before setting status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	body := `{"url":"https://meet.example.com/hook","secret":"s3cret"}`
+	h.SetConferenceHook(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/1/conference-hook", strings.NewReader(body)), "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SetConferenceHook() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp conferenceHookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.CalendarID != 1 || resp.URL != "https://meet.example.com/hook" || !resp.HasSecret || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"2", body, http.StatusForbidden},
+		{"1", `{"url":"not a url"}`, http.StatusBadRequest},
+		{"1", `{"url":"https://meet.example.com","extra":true}`, http.StatusBadRequest},
+	} {
+		rec = httptest.NewRecorder()
+		h.SetConferenceHook(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/"+tc.id+"/conference-hook", strings.NewReader(tc.body)), tc.id, ""))
+		if rec.Code != tc.want {
+			t.Fatalf("SetConferenceHook(%s, %s) status = %d, want %d", tc.id, tc.body, rec.Code, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.DeleteConferenceHook(rec, withUserAndRoute(httptest.NewRequest(http.MethodDelete, "/api/calendars/1/conference-hook", nil), "1", ""))
+	if rec.Code != http.StatusNoContent || len(hooks.hooks) != 0 {
+		t.Fatalf("DeleteConferenceHook() status = %d, hooks = %v", rec.Code, hooks.hooks)
+	}
+}
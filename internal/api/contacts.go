@@ -14,6 +14,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/maintenance"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -33,6 +34,9 @@ type addressBookResponse struct {
 	Description *string `json:"description,omitempty"`
 	Shared      bool    `json:"shared"`
 	ReadOnly    bool    `json:"readOnly"`
+	// Checksum hashes the UID and ETag of every contact; only
+	// single-address-book responses carry it.
+	Checksum string `json:"checksum,omitempty"`
 }
 
 type addressBookShareResponse struct {
@@ -58,6 +62,39 @@ type contactResponse struct {
 	RawVCard      string  `json:"rawVcard"`
 }
 
+type contactValidationErrorResponse struct {
+	Error  string           `json:"error"`
+	Issues []contacts.Issue `json:"issues"`
+}
+
+type contactQualityEntry struct {
+	UID         string           `json:"uid"`
+	DisplayName *string          `json:"displayName,omitempty"`
+	Issues      []contacts.Issue `json:"issues"`
+}
+
+type contactChangeResponse struct {
+	AddressBookID  int64     `json:"addressBookId"`
+	UID            string    `json:"uid"`
+	DisplayName    *string   `json:"displayName,omitempty"`
+	Change         string    `json:"change"`
+	Fields         []string  `json:"fields"`
+	ChangedBy      *int64    `json:"changedBy,omitempty"`
+	ChangedByEmail string    `json:"changedByEmail,omitempty"`
+	ChangedAt      time.Time `json:"changedAt"`
+}
+
+type recentContactChangesResponse struct {
+	Since   time.Time               `json:"since"`
+	Changes []contactChangeResponse `json:"changes"`
+}
+
+type contactQualityResponse struct {
+	AddressBookID int64                 `json:"addressBookId"`
+	Checked       int                   `json:"checked"`
+	Contacts      []contactQualityEntry `json:"contacts"`
+}
+
 func (h *Handler) ListAddressBooks(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -96,7 +133,14 @@ func (h *Handler) GetAddressBook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to resolve access", http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, http.StatusOK, toAddressBookAccessResponse(access))
+	resp := toAddressBookAccessResponse(access)
+	if h.store != nil && h.store.CollectionSyncs != nil {
+		if resp.Checksum, err = h.store.CollectionSyncs.Checksum(r.Context(), "addressbook", book.ID); err != nil {
+			http.Error(w, "failed to compute checksum", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (h *Handler) ListContacts(w http.ResponseWriter, r *http.Request) {
@@ -126,6 +170,108 @@ func (h *Handler) ListContacts(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// LookupContacts finds contacts by email address or phone number across the
+// user's address books, for caller ID and address completion.
+func (h *Handler) LookupContacts(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	email, phone := strings.TrimSpace(q.Get("email")), strings.TrimSpace(q.Get("phone"))
+	if len(email) > maxContactSearchLen || len(phone) > maxContactSearchLen {
+		http.Error(w, "lookup value too long", http.StatusBadRequest)
+		return
+	}
+	items, err := h.contacts.LookupContacts(r.Context(), user, email, phone)
+	if err != nil {
+		writeContactError(w, err)
+		return
+	}
+	resp := make([]contactResponse, 0, len(items))
+	for _, c := range items {
+		resp = append(resp, toContactResponse(c))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// defaultRecentChangeDays is the window RecentContactChanges covers when the
+// request does not pass days.
+const defaultRecentChangeDays = 7
+
+// RecentContactChanges lists the contacts created, updated or deleted in the
+// user's address books within the last `days` days (default 7), newest
+// first, with the fields each change touched.
+func (h *Handler) RecentContactChanges(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	days := defaultRecentChangeDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	changes, err := h.contacts.RecentChanges(r.Context(), user, days)
+	if err != nil {
+		writeContactError(w, err)
+		return
+	}
+	resp := recentContactChangesResponse{Since: since, Changes: make([]contactChangeResponse, 0, len(changes))}
+	for _, c := range changes {
+		fields := c.Fields
+		if fields == nil {
+			fields = []string{}
+		}
+		resp.Changes = append(resp.Changes, contactChangeResponse{
+			AddressBookID:  c.AddressBookID,
+			UID:            c.UID,
+			DisplayName:    c.DisplayName,
+			Change:         c.Kind,
+			Fields:         fields,
+			ChangedBy:      c.ChangedBy,
+			ChangedByEmail: c.ChangedByEmail,
+			ChangedAt:      c.ChangedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ContactQuality reports the invalid or unnormalized TEL, EMAIL and URL
+// values of every contact in an address book.
+func (h *Handler) ContactQuality(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	bookID, ok := parseAddressBookID(w, r)
+	if !ok {
+		return
+	}
+	report, checked, err := h.contacts.ContactQuality(r.Context(), user, bookID)
+	if err != nil {
+		writeContactError(w, err)
+		return
+	}
+	resp := contactQualityResponse{AddressBookID: bookID, Checked: checked, Contacts: make([]contactQualityEntry, 0, len(report))}
+	for _, entry := range report {
+		resp.Contacts = append(resp.Contacts, contactQualityEntry{
+			UID:         entry.Contact.UID,
+			DisplayName: entry.Contact.DisplayName,
+			Issues:      entry.Issues,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (h *Handler) GetContact(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -457,6 +603,14 @@ func toContactResponse(c store.Contact) contactResponse {
 }
 
 func writeContactError(w http.ResponseWriter, err error) {
+	if maintenance.WriteError(w, err) {
+		return
+	}
+	var invalid *contacts.ValidationError
+	if errors.As(err, &invalid) {
+		writeJSON(w, http.StatusUnprocessableEntity, contactValidationErrorResponse{Error: invalid.Error(), Issues: invalid.Issues})
+		return
+	}
 	status := contacts.StatusCode(err)
 	if status == http.StatusInternalServerError {
 		http.Error(w, "internal server error", status)
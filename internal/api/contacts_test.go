@@ -14,6 +14,7 @@ import (
 
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/util"
 )
 
 type fakeAddressBookRepo struct {
@@ -167,6 +168,21 @@ func (f *fakeContactRepo) CopyToAddressBook(ctx context.Context, from, to int64,
 	return nil, nil
 }
 
+func (f *fakeContactRepo) FindByLookupKey(ctx context.Context, bookIDs []int64, email, phone string, limit int) ([]store.Contact, error) {
+	var out []store.Contact
+	for _, id := range bookIDs {
+		for _, c := range f.contacts {
+			if c.AddressBookID != id {
+				continue
+			}
+			if (email != "" && util.EmailLookupKey(vcardField(c.RawVCard, "EMAIL:")) == email) || (phone != "" && util.PhoneLookupKey(vcardField(c.RawVCard, "TEL:")) == phone) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out, nil
+}
+
 // vcardField returns the value of the first line beginning with prefix.
 func vcardField(vcard, prefix string) string {
 	for _, line := range strings.Split(vcard, "\n") {
@@ -406,3 +422,112 @@ func TestCreateContactRawVCard(t *testing.T) {
 		t.Fatal("expected CRLF-normalized vCard body")
 	}
 }
+
+func TestContactQualityAndStrictValidation(t *testing.T) {
+	h := newContactsHandler(
+		map[int64]*store.AddressBook{1: {ID: 1, UserID: 1, Name: "Personal"}},
+		map[string]store.Contact{
+			contactKey(1, "ok"):  {AddressBookID: 1, UID: "ok", RawVCard: "BEGIN:VCARD\r\nUID:ok\r\nFN:Ok\r\nEMAIL:ok@example.com\r\nEND:VCARD\r\n"},
+			contactKey(1, "bad"): {AddressBookID: 1, UID: "bad", DisplayName: strptr("Bad"), RawVCard: "BEGIN:VCARD\r\nUID:bad\r\nFN:Bad\r\nTEL:555-CALL-NOW\r\nEND:VCARD\r\n"},
+		},
+	)
+	req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/addressbooks/1/quality", nil), "1", "")
+	rec := httptest.NewRecorder()
+	h.ContactQuality(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("quality status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var report contactQualityResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 2 || len(report.Contacts) != 1 || report.Contacts[0].UID != "bad" || report.Contacts[0].Issues[0].Property != "TEL" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	h.contacts.SetStrictValidation(true)
+	req = withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/addressbooks/1/contacts", strings.NewReader(`{"structured":{"displayName":"x","email":"x@localhost"}}`)), "1", "")
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	h.CreateContact(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("strict create status=%d, want 422", rec.Code)
+	}
+	var invalid contactValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &invalid); err != nil {
+		t.Fatal(err)
+	}
+	if len(invalid.Issues) != 1 || invalid.Issues[0].Property != "EMAIL" {
+		t.Fatalf("unexpected issues: %+v", invalid)
+	}
+}
+
+func TestLookupContacts(t *testing.T) {
+	h := newContactsHandler(
+		map[int64]*store.AddressBook{1: {ID: 1, UserID: 1, Name: "Personal"}, 2: {ID: 2, UserID: 99, Name: "Other"}},
+		map[string]store.Contact{
+			contactKey(1, "a"): {AddressBookID: 1, UID: "a", RawVCard: "BEGIN:VCARD\r\nUID:a\r\nFN:A\r\nTEL:(415) 555-0100\r\nEND:VCARD\r\n"},
+			contactKey(2, "b"): {AddressBookID: 2, UID: "b", RawVCard: "BEGIN:VCARD\r\nUID:b\r\nFN:B\r\nTEL:415-555-0100\r\nEND:VCARD\r\n"},
+		},
+	)
+	req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/contacts/lookup?phone=415.555.0100", nil), "", "")
+	rec := httptest.NewRecorder()
+	h.LookupContacts(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lookup status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out []contactResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].UID != "a" {
+		t.Fatalf("expected only the owned match, got %+v", out)
+	}
+
+	req = withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/contacts/lookup", nil), "", "")
+	rec = httptest.NewRecorder()
+	h.LookupContacts(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("lookup without key status=%d, want 400", rec.Code)
+	}
+}
+
+type fakeContactRevisions struct{ changes []store.ContactChange }
+
+func (f *fakeContactRevisions) ListChangedSince(context.Context, []int64, time.Time, int) ([]store.ContactChange, error) {
+	return f.changes, nil
+}
+func (f *fakeContactRevisions) DeleteClosedBefore(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestRecentContactChanges(t *testing.T) {
+	h := newContactsHandler(map[int64]*store.AddressBook{1: {ID: 1, UserID: 1, Name: "Personal"}}, map[string]store.Contact{})
+	h.store.ContactRevisions = &fakeContactRevisions{changes: []store.ContactChange{
+		{AddressBookID: 1, UID: "a", DisplayName: strptr("A"), Before: "BEGIN:VCARD\r\nUID:a\r\nFN:A\r\nEND:VCARD\r\n", After: "BEGIN:VCARD\r\nUID:a\r\nFN:A\r\nTEL:123\r\nEND:VCARD\r\n", ChangedAt: time.Now()},
+		{AddressBookID: 1, UID: "b", Before: "BEGIN:VCARD\r\nUID:b\r\nFN:B\r\nEND:VCARD\r\n", ChangedAt: time.Now()},
+	}}
+	req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/contacts/recent?days=3", nil), "", "")
+	rec := httptest.NewRecorder()
+	h.RecentContactChanges(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recent status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	var out recentContactChangesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Changes) != 2 || out.Changes[0].Change != "updated" || len(out.Changes[0].Fields) != 1 || out.Changes[0].Fields[0] != "TEL" ||
+		out.Changes[1].Change != "deleted" || out.Changes[1].Fields == nil {
+		t.Fatalf("unexpected changes: %+v", out.Changes)
+	}
+
+	for _, days := range []string{"0", "91", "soon"} {
+		req = withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/contacts/recent?days="+days, nil), "", "")
+		rec = httptest.NewRecorder()
+		h.RecentContactChanges(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("days=%s status=%d, want 400", days, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type deviceResponse struct {
+	ID            int64   `json:"id"`
+	Label         string  `json:"label"`
+	APIRole       string  `json:"apiRole"`
+	CreatedAt     string  `json:"createdAt"`
+	ExpiresAt     *string `json:"expiresAt,omitempty"`
+	RevokedAt     *string `json:"revokedAt,omitempty"`
+	LastSeenAt    *string `json:"lastSeenAt,omitempty"`
+	LastUserAgent string  `json:"lastUserAgent,omitempty"`
+	LastIP        string  `json:"lastIp,omitempty"`
+	Client        string  `json:"client,omitempty"`
+	Current       bool    `json:"current"`
+}
+
+type rotateDeviceResponse struct {
+	deviceResponse
+	// Password is the new app password, shown only this once.
+	Password string `json:"password"`
+}
+
+type revokeDeviceResponse struct {
+	Revoked      bool `json:"revoked"`
+	Disconnected int  `json:"disconnected"`
+}
+
+// SetAuthService attaches the auth service used to disconnect revoked
+// devices; without it revocation only takes effect on the next request.
+func (h *Handler) SetAuthService(svc *auth.Service) {
+	h.authService = svc
+}
+
+// ListDevices returns the caller's app passwords with the User-Agent and IP
+// address each was last used from.
+func (h *Handler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	passwords, err := h.store.AppPasswords.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load devices", http.StatusInternalServerError)
+		return
+	}
+	currentID, _ := auth.AppPasswordIDFromContext(r.Context())
+
+	resp := make([]deviceResponse, 0, len(passwords))
+	for _, p := range passwords {
+		device := deviceResponse{
+			ID:         p.ID,
+			Label:      p.Label,
+			APIRole:    p.APIRole,
+			CreatedAt:  p.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt:  formatOptionalTime(p.ExpiresAt),
+			RevokedAt:  formatOptionalTime(p.RevokedAt),
+			LastSeenAt: formatOptionalTime(p.LastUsedAt),
+			Current:    p.ID == currentID,
+		}
+		if p.LastUsedUserAgent != nil {
+			device.LastUserAgent = *p.LastUsedUserAgent
+			device.Client = metrics.ClientFamily(*p.LastUsedUserAgent)
+		}
+		if p.LastUsedIP != nil {
+			device.LastIP = *p.LastUsedIP
+		}
+		resp = append(resp, device)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeDevice revokes one of the caller's app passwords and aborts the
+// requests its device still has in flight, for lost or stolen devices.
+func (h *Handler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid device id", http.StatusBadRequest)
+		return
+	}
+	token, err := h.store.AppPasswords.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load device", http.StatusInternalServerError)
+		return
+	}
+	if token == nil || token.UserID != user.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if token.RevokedAt == nil {
+		if err := h.store.AppPasswords.Revoke(r.Context(), id); err != nil {
+			http.Error(w, "failed to revoke device", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, revokeDeviceResponse{Revoked: true, Disconnected: h.authService.DisconnectAppPassword(id)})
+}
+
+// RotateDevice replaces one of the caller's app passwords with a new one
+// under the same label, role and expiry, and revokes the old one as
+// RevokeDevice does. The new password is returned only in this response.
+func (h *Handler) RotateDevice(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid device id", http.StatusBadRequest)
+		return
+	}
+	old, err := h.store.AppPasswords.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load device", http.StatusInternalServerError)
+		return
+	}
+	if old == nil || old.UserID != user.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if old.RevokedAt != nil || (old.ExpiresAt != nil && !old.ExpiresAt.After(time.Now())) {
+		http.Error(w, "device is revoked or expired", http.StatusConflict)
+		return
+	}
+	plaintext, hash, err := auth.NewAppPasswordToken()
+	if err != nil {
+		http.Error(w, "failed to rotate device", http.StatusInternalServerError)
+		return
+	}
+	created, err := h.store.AppPasswords.Create(r.Context(), store.AppPassword{
+		UserID:    user.ID,
+		Label:     old.Label,
+		TokenHash: hash,
+		ExpiresAt: old.ExpiresAt,
+		APIRole:   old.APIRole,
+	})
+	if err != nil {
+		http.Error(w, "failed to rotate device", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.AppPasswords.Revoke(r.Context(), id); err != nil {
+		http.Error(w, "failed to revoke device", http.StatusInternalServerError)
+		return
+	}
+	h.authService.DisconnectAppPassword(id)
+	writeJSON(w, http.StatusCreated, rotateDeviceResponse{
+		deviceResponse: deviceResponse{
+			ID:        created.ID,
+			Label:     created.Label,
+			APIRole:   created.APIRole,
+			CreatedAt: created.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt: formatOptionalTime(created.ExpiresAt),
+		},
+		Password: plaintext,
+	})
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	v := t.UTC().Format(time.RFC3339)
+	return &v
+}
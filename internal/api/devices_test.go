@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeAppPasswordRepo struct {
+	store.AppPasswordRepository
+	passwords map[int64]*store.AppPassword
+	revoked   []int64
+}
+
+func (f *fakeAppPasswordRepo) ListByUser(ctx context.Context, userID int64) ([]store.AppPassword, error) {
+	var out []store.AppPassword
+	for _, p := range f.passwords {
+		if p.UserID == userID {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeAppPasswordRepo) GetByID(ctx context.Context, id int64) (*store.AppPassword, error) {
+	return f.passwords[id], nil
+}
+
+func (f *fakeAppPasswordRepo) Create(ctx context.Context, token store.AppPassword) (*store.AppPassword, error) {
+	token.ID = int64(len(f.passwords) + 100)
+	token.CreatedAt = time.Now()
+	f.passwords[token.ID] = &token
+	return &token, nil
+}
+
+func (f *fakeAppPasswordRepo) Revoke(ctx context.Context, id int64) error {
+	f.revoked = append(f.revoked, id)
+	return nil
+}
+
+func TestListDevicesReportsLastUse(t *testing.T) {
+	seen := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	ua, ip := "DAVx5/4.3 (Android)", "203.0.113.7"
+	repo := &fakeAppPasswordRepo{passwords: map[int64]*store.AppPassword{
+		3: {ID: 3, UserID: 1, Label: "Phone", CreatedAt: seen.Add(-time.Hour), LastUsedAt: &seen, LastUsedUserAgent: &ua, LastUsedIP: &ip},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{AppPasswords: repo})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/devices", nil)
+	req = req.WithContext(auth.WithAppPasswordID(auth.WithUser(req.Context(), &store.User{ID: 1}), 3))
+	rec := httptest.NewRecorder()
+	h.ListDevices(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListDevices() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp []deviceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 1 {
+		t.Fatalf("expected one device, got %+v", resp)
+	}
+	d := resp[0]
+	if d.LastUserAgent != ua || d.LastIP != ip || d.Client != "davx5" || !d.Current || d.LastSeenAt == nil || *d.LastSeenAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected device %+v", d)
+	}
+}
+
+func TestRevokeDeviceOnlyRevokesOwnPasswords(t *testing.T) {
+	repo := &fakeAppPasswordRepo{passwords: map[int64]*store.AppPassword{
+		3: {ID: 3, UserID: 1, Label: "Phone"},
+		4: {ID: 4, UserID: 2, Label: "Someone else"},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{AppPasswords: repo})
+
+	revoke := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/devices/"+id+"/revoke", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		req = req.WithContext(auth.WithUser(ctx, &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		h.RevokeDevice(rec, req)
+		return rec
+	}
+
+	if rec := revoke("4"); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoking another user's device status = %d, want 404", rec.Code)
+	}
+	rec := revoke("3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RevokeDevice() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if len(repo.revoked) != 1 || repo.revoked[0] != 3 {
+		t.Fatalf("expected device 3 revoked, got %v", repo.revoked)
+	}
+}
+
+func TestRotateDeviceReplacesThePassword(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+	repo := &fakeAppPasswordRepo{passwords: map[int64]*store.AppPassword{
+		3: {ID: 3, UserID: 1, Label: "Laptop", APIRole: store.APIRoleViewer, ExpiresAt: &future},
+		4: {ID: 4, UserID: 2, Label: "Someone else"},
+		5: {ID: 5, UserID: 1, Label: "Old", ExpiresAt: &past},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{AppPasswords: repo})
+	rotate := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/devices/"+id+"/rotate", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(auth.WithUser(req.Context(), &store.User{ID: 1}), chi.RouteCtxKey, rctx)
+		rec := httptest.NewRecorder()
+		h.RotateDevice(rec, req.WithContext(ctx))
+		return rec
+	}
+
+	if rec := rotate("4"); rec.Code != http.StatusNotFound {
+		t.Fatalf("rotating another user's device = %d, want 404", rec.Code)
+	}
+	if rec := rotate("5"); rec.Code != http.StatusConflict {
+		t.Fatalf("rotating an expired device = %d, want 409", rec.Code)
+	}
+	rec := rotate("3")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("rotate = %d %s", rec.Code, rec.Body.String())
+	}
+	var resp rotateDeviceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	created := repo.passwords[resp.ID]
+	if resp.Password == "" || created == nil || created.Label != "Laptop" || created.APIRole != store.APIRoleViewer || created.ExpiresAt == nil || !created.ExpiresAt.Equal(future) {
+		t.Fatalf("rotated device = %+v, response %+v", created, resp)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(created.TokenHash), []byte(resp.Password)) != nil {
+		t.Fatal("stored hash does not match the returned password")
+	}
+	if len(repo.revoked) != 1 || repo.revoked[0] != 3 {
+		t.Fatalf("revoked = %v, want [3]", repo.revoked)
+	}
+}
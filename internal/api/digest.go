@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/digest"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type digestRequest struct {
+	Frequency string `json:"frequency"`
+	// SendTime is the local time of day, as HH:MM, the digest is sent.
+	SendTime string `json:"sendTime"`
+	// Weekday is 0 (Sunday) to 6, the day weekly digests are sent.
+	Weekday         *int `json:"weekday"`
+	WorkingDaysOnly bool `json:"workingDaysOnly"`
+}
+
+type digestResponse struct {
+	Frequency       string     `json:"frequency"`
+	SendTime        string     `json:"sendTime"`
+	Weekday         int        `json:"weekday"`
+	WorkingDaysOnly bool       `json:"workingDaysOnly"`
+	LastSentAt      *time.Time `json:"lastSentAt"`
+}
+
+// GetDigest returns the user's agenda digest schedule, or 404 when they
+// have none.
+func (h *Handler) GetDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	settings, err := h.store.Digests.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load digest", http.StatusInternalServerError)
+		return
+	}
+	if settings == nil {
+		http.Error(w, "no digest scheduled", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, digestResponseFor(*settings))
+}
+
+// UpdateDigest schedules the user's agenda digest, replacing any schedule
+// they had.
+func (h *Handler) UpdateDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	var req digestRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	settings := store.DigestSettings{UserID: user.ID, Frequency: req.Frequency, WorkingDaysOnly: req.WorkingDaysOnly, Weekday: time.Monday}
+	if req.Weekday != nil {
+		settings.Weekday = time.Weekday(*req.Weekday)
+	}
+	minute, err := parseSendTime(req.SendTime)
+	if err == nil {
+		settings.SendMinute = minute
+		err = digest.Normalize(&settings)
+	}
+	if err != nil {
+		http.Error(w, strings.TrimPrefix(err.Error(), digest.ErrInvalidSettings.Error()+": "), http.StatusBadRequest)
+		return
+	}
+	saved, err := h.store.Digests.Upsert(r.Context(), settings)
+	if err != nil {
+		http.Error(w, "failed to save digest", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, digestResponseFor(*saved))
+}
+
+// DeleteDigest stops the user's agenda digest.
+func (h *Handler) DeleteDigest(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store.Digests.Delete(r.Context(), user.ID); err != nil {
+		http.Error(w, "failed to delete digest", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseSendTime parses an HH:MM time of day into minutes past midnight.
+func parseSendTime(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%w: send time must be HH:MM", digest.ErrInvalidSettings)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func digestResponseFor(settings store.DigestSettings) digestResponse {
+	return digestResponse{
+		Frequency:       settings.Frequency,
+		SendTime:        fmt.Sprintf("%02d:%02d", settings.SendMinute/60, settings.SendMinute%60),
+		Weekday:         int(settings.Weekday),
+		WorkingDaysOnly: settings.WorkingDaysOnly,
+		LastSentAt:      settings.LastSentAt,
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+const maxDuplicateCleanupBodyBytes = 1 << 20
+
+type duplicatesResponse struct {
+	Groups []duplicateGroupResponse `json:"groups"`
+}
+
+type duplicateGroupResponse struct {
+	Reason string `json:"reason"`
+	// Events lists the copies; the first is the one a cleanup keeps.
+	Events []duplicateEventResponse `json:"events"`
+}
+
+type duplicateEventResponse struct {
+	CalendarID   int64   `json:"calendarId"`
+	CalendarName string  `json:"calendarName"`
+	UID          string  `json:"uid"`
+	Summary      string  `json:"summary,omitempty"`
+	Start        *string `json:"start,omitempty"`
+	AllDay       bool    `json:"allDay"`
+	LastModified string  `json:"lastModified"`
+	Keep         bool    `json:"keep"`
+}
+
+type eventRefRequest struct {
+	CalendarID int64  `json:"calendarId"`
+	UID        string `json:"uid"`
+}
+
+type duplicateCleanupRequest struct {
+	// Remove names the copies to delete; empty removes every copy but the
+	// first of each group.
+	Remove []eventRefRequest `json:"remove"`
+}
+
+type duplicateCleanupResponse struct {
+	Deleted []eventRefRequest      `json:"deleted"`
+	Skipped []duplicateCleanupSkip `json:"skipped"`
+}
+
+type duplicateCleanupSkip struct {
+	CalendarID int64  `json:"calendarId"`
+	UID        string `json:"uid"`
+	Reason     string `json:"reason"`
+}
+
+// ListDuplicates serves GET /api/duplicates: the probable duplicate events
+// across the caller's own calendars.
+func (h *Handler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	groups, err := h.events.FindDuplicates(r.Context(), user)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := duplicatesResponse{Groups: make([]duplicateGroupResponse, 0, len(groups))}
+	for _, g := range groups {
+		group := duplicateGroupResponse{Reason: g.Reason, Events: make([]duplicateEventResponse, 0, len(g.Events))}
+		for i, ev := range g.Events {
+			item := duplicateEventResponse{
+				CalendarID:   ev.CalendarID,
+				CalendarName: ev.CalendarName,
+				UID:          ev.UID,
+				Summary:      ev.Summary,
+				AllDay:       ev.AllDay,
+				LastModified: ev.LastModified.UTC().Format(time.RFC3339),
+				Keep:         i == 0,
+			}
+			if ev.Start != nil {
+				start := ev.Start.UTC().Format(time.RFC3339)
+				if ev.AllDay {
+					start = ev.Start.Format("2006-01-02")
+				}
+				item.Start = &start
+			}
+			group.Events = append(group.Events, item)
+		}
+		resp.Groups = append(resp.Groups, group)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CleanupDuplicates serves POST /api/duplicates/cleanup. It deletes the
+// named copies, or with an empty body every copy but the one to keep, and
+// tombstones each so syncing clients drop them.
+func (h *Handler) CleanupDuplicates(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDuplicateCleanupBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxDuplicateCleanupBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req duplicateCleanupRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	refs := make([]events.EventRef, 0, len(req.Remove))
+	for _, ref := range req.Remove {
+		refs = append(refs, events.EventRef{CalendarID: ref.CalendarID, UID: ref.UID})
+	}
+	result, err := h.events.RemoveDuplicates(r.Context(), user, refs)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := duplicateCleanupResponse{
+		Deleted: make([]eventRefRequest, 0, len(result.Deleted)),
+		Skipped: make([]duplicateCleanupSkip, 0, len(result.Skipped)),
+	}
+	for _, ref := range result.Deleted {
+		resp.Deleted = append(resp.Deleted, eventRefRequest{CalendarID: ref.CalendarID, UID: ref.UID})
+	}
+	for _, s := range result.Skipped {
+		resp.Skipped = append(resp.Skipped, duplicateCleanupSkip{CalendarID: s.CalendarID, UID: s.UID, Reason: s.Reason})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
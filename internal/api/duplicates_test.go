@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func duplicateTestEvent(calendarID int64, uid, summary string, start time.Time, modified time.Time) store.Event {
+	ev := batchDeleteTestEvent(uid, start)
+	ev.CalendarID = calendarID
+	ev.Summary = &summary
+	ev.LastModified = modified
+	return ev
+}
+
+func TestListAndCleanupDuplicates(t *testing.T) {
+	start := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	older := time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	events := &fakeEventRepo{events: map[string]store.Event{
+		"1:standup":   duplicateTestEvent(1, "standup", "Standup", start, older),
+		"2:standup":   duplicateTestEvent(2, "standup", "Standup", start, newer),
+		"1:lunch":     duplicateTestEvent(1, "lunch", "Team lunch", start.Add(3*time.Hour), older),
+		"2:lunch-imp": duplicateTestEvent(2, "lunch-imp", "team  LUNCH", start.Add(3*time.Hour), older),
+		"2:review":    duplicateTestEvent(2, "review", "Review", start, older),
+		"3:standup":   duplicateTestEvent(3, "standup", "Standup", start, newer),
+	}}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Imported"}, Editor: true},
+			3: {Calendar: store.Calendar{ID: 3, UserID: 2, Name: "Theirs"}, Editor: true},
+		}},
+		Events: events,
+	})
+
+	req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/duplicates", nil), "", "")
+	rec := httptest.NewRecorder()
+	handler.ListDuplicates(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var list duplicatesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", list.Groups)
+	}
+	byUID := list.Groups[0]
+	if byUID.Reason != "same uid" || len(byUID.Events) != 2 || byUID.Events[0].CalendarID != 2 || !byUID.Events[0].Keep {
+		t.Fatalf("expected the newer copy in calendar 2 kept, got %+v", byUID)
+	}
+	bySummary := list.Groups[1]
+	if bySummary.Reason != "same summary and start" || len(bySummary.Events) != 2 || bySummary.Events[0].UID != "lunch" {
+		t.Fatalf("unexpected summary group %+v", bySummary)
+	}
+
+	req = withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/duplicates/cleanup",
+		strings.NewReader(`{"remove":[{"calendarId":1,"uid":"lunch"},{"calendarId":2,"uid":"lunch-imp"},{"calendarId":2,"uid":"review"}]}`)), "", "")
+	rec = httptest.NewRecorder()
+	handler.CleanupDuplicates(rec, req)
+	var cleanup duplicateCleanupResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &cleanup)
+	if rec.Code != http.StatusOK || len(cleanup.Deleted) != 1 || len(cleanup.Skipped) != 2 {
+		t.Fatalf("status = %d, response %+v", rec.Code, cleanup)
+	}
+	if cleanup.Skipped[0].Reason != "last copy" || cleanup.Skipped[1].Reason != "not a duplicate" {
+		t.Fatalf("unexpected skips %+v", cleanup.Skipped)
+	}
+	if _, ok := events.events["1:lunch"]; ok {
+		t.Fatal("expected lunch removed")
+	}
+
+	req = withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/duplicates/cleanup", nil), "", "")
+	rec = httptest.NewRecorder()
+	handler.CleanupDuplicates(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := events.events["1:standup"]; ok {
+		t.Fatal("expected the older standup copy removed")
+	}
+	for _, key := range []string{"2:standup", "2:lunch-imp", "2:review", "3:standup"} {
+		if _, ok := events.events[key]; !ok {
+			t.Fatalf("expected %s kept", key)
+		}
+	}
+}
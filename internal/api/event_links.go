@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// eventLinkPath is where event links point, followed by the token and
+// ".ics".
+const eventLinkPath = "/event-links/"
+
+type eventLinkRequest struct {
+	// ExpiresInHours is how long the link lasts; 0 uses the default.
+	ExpiresInHours int `json:"expiresInHours"`
+}
+
+type eventLinkResponse struct {
+	ID             int64      `json:"id"`
+	URL            string     `json:"url"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	AccessCount    int64      `json:"accessCount"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// CreateEventLink issues an expiring read-only .ics link to one event.
+func (h *Handler) CreateEventLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req eventLinkRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	link, err := h.events.CreateEventLink(r.Context(), user, calendarID, chi.URLParam(r, "uid"), time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, h.eventLinkResponseFor(*link))
+}
+
+// ListEventLinks returns the caller's unexpired links to one event.
+func (h *Handler) ListEventLinks(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	links, err := h.events.ListEventLinks(r.Context(), user, calendarID, chi.URLParam(r, "uid"))
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]eventLinkResponse, 0, len(links))
+	for _, link := range links {
+		resp = append(resp, h.eventLinkResponseFor(link))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RevokeEventLink deletes one of the caller's event links; its URL stops
+// working at once.
+func (h *Handler) RevokeEventLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "linkId"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	if err := h.events.RevokeEventLink(r.Context(), user, id); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) eventLinkResponseFor(link store.EventLink) eventLinkResponse {
+	base := ""
+	if cfg := h.config(); cfg != nil {
+		base = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	return eventLinkResponse{
+		ID:             link.ID,
+		URL:            base + eventLinkPath + link.Token + ".ics",
+		ExpiresAt:      link.ExpiresAt,
+		AccessCount:    link.AccessCount,
+		LastAccessedAt: link.LastAccessedAt,
+		CreatedAt:      link.CreatedAt,
+	}
+}
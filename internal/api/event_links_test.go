@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeEventLinkRepo struct {
+	store.EventLinkRepository
+	links  []store.EventLink
+	nextID int64
+}
+
+func (f *fakeEventLinkRepo) Create(ctx context.Context, link store.EventLink) (*store.EventLink, error) {
+	f.nextID++
+	link.ID = f.nextID
+	link.CreatedAt = time.Now()
+	f.links = append(f.links, link)
+	return &link, nil
+}
+
+func (f *fakeEventLinkRepo) GetByToken(ctx context.Context, token string) (*store.EventLink, error) {
+	for _, link := range f.links {
+		if link.Token == token && link.ExpiresAt.After(time.Now()) {
+			return &link, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeEventLinkRepo) ListForEvent(ctx context.Context, userID, calendarID int64, uid string) ([]store.EventLink, error) {
+	var out []store.EventLink
+	for _, link := range f.links {
+		if link.UserID == userID && link.CalendarID == calendarID && link.UID == uid {
+			out = append(out, link)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEventLinkRepo) Delete(ctx context.Context, userID, id int64) error {
+	for i, link := range f.links {
+		if link.ID == id && link.UserID == userID {
+			f.links = append(f.links[:i], f.links[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (f *fakeEventLinkRepo) RecordAccess(ctx context.Context, id int64) error {
+	for i := range f.links {
+		if f.links[i].ID == id {
+			now := time.Now()
+			f.links[i].AccessCount++
+			f.links[i].LastAccessedAt = &now
+		}
+	}
+	return nil
+}
+
+func TestEventLinksCreateOpenAndRevoke(t *testing.T) {
+	links := &fakeEventLinkRepo{}
+	handler := NewHandler(&config.Config{BaseURL: "https://cal.example.com/"}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Personal"}, Editor: true},
+		}},
+		Events: &fakeEventRepo{events: map[string]store.Event{
+			"1:dinner": batchDeleteTestEvent("dinner", time.Date(2030, 5, 1, 18, 0, 0, 0, time.UTC), "DTSTART:20300501T180000Z"),
+		}},
+		Users:      &fakeUserRepo{users: map[int64]*store.User{1: {ID: 1, PrimaryEmail: "user@example.com"}}},
+		EventLinks: links,
+	})
+
+	create := func(uid, body string) *httptest.ResponseRecorder {
+		req := withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/calendars/1/events/"+uid+"/links", strings.NewReader(body)), "1", uid)
+		rec := httptest.NewRecorder()
+		handler.CreateEventLink(rec, req)
+		return rec
+	}
+	if rec := create("dinner", `{"expiresInHours":5000}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("over-long link status = %d, want 400", rec.Code)
+	}
+	if rec := create("missing", ``); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing event status = %d, want 404", rec.Code)
+	}
+	rec := create("dinner", ``)
+	var created eventLinkResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.HasPrefix(created.URL, "https://cal.example.com/event-links/") || !strings.HasSuffix(created.URL, ".ics") {
+		t.Fatalf("unexpected link URL %q", created.URL)
+	}
+	if lifetime := time.Until(created.ExpiresAt); lifetime < events.DefaultEventLinkLifetime-time.Minute || lifetime > events.DefaultEventLinkLifetime {
+		t.Fatalf("link expires in %v, want the default lifetime", lifetime)
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(created.URL, "https://cal.example.com/event-links/"), ".ics")
+	ev, err := handler.events.OpenEventLink(context.Background(), token)
+	if err != nil || ev.UID != "dinner" {
+		t.Fatalf("OpenEventLink = %v, %v", ev, err)
+	}
+
+	req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/events/dinner/links", nil), "1", "dinner")
+	rec = httptest.NewRecorder()
+	handler.ListEventLinks(rec, req)
+	var listed []eventLinkResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].AccessCount != 1 || listed[0].LastAccessedAt == nil {
+		t.Fatalf("list status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	revoke := func(id string) int {
+		req := withUserAndRoute(httptest.NewRequest(http.MethodDelete, "/api/event-links/"+id, nil), "", "")
+		chi.RouteContext(req.Context()).URLParams.Add("linkId", id)
+		rec := httptest.NewRecorder()
+		handler.RevokeEventLink(rec, req)
+		return rec.Code
+	}
+	if code := revoke("99"); code != http.StatusNotFound {
+		t.Fatalf("revoke unknown link status = %d, want 404", code)
+	}
+	if code := revoke("1"); code != http.StatusNoContent {
+		t.Fatalf("revoke status = %d, want 204", code)
+	}
+	if _, err := handler.events.OpenEventLink(context.Background(), token); err != events.ErrNotFound {
+		t.Fatalf("revoked link opened: %v", err)
+	}
+
+	links.links = append(links.links, store.EventLink{ID: 2, UserID: 1, CalendarID: 1, UID: "dinner", Token: "old", ExpiresAt: time.Now().Add(-time.Minute)})
+	if _, err := handler.events.OpenEventLink(context.Background(), "old"); err != events.ErrNotFound {
+		t.Fatalf("expired link opened: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/flags"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type featureFlagOverrideRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type featureFlagOverrideResponse struct {
+	UserID    *int64 `json:"userId,omitempty"`
+	GroupID   *int64 `json:"groupId,omitempty"`
+	Enabled   bool   `json:"enabled"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type featureFlagResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Enabled is the instance-wide setting, from APP_FEATURES.
+	Enabled   bool                          `json:"enabled"`
+	Overrides []featureFlagOverrideResponse `json:"overrides"`
+}
+
+func newFeatureFlagOverrideResponse(o store.FeatureFlagOverride) featureFlagOverrideResponse {
+	return featureFlagOverrideResponse{
+		UserID:    o.UserID,
+		GroupID:   o.GroupID,
+		Enabled:   o.Enabled,
+		UpdatedAt: o.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (h *Handler) requireFeatureFlags(w http.ResponseWriter) bool {
+	if h.store == nil || h.store.FeatureFlags == nil {
+		http.Error(w, "feature flags are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListFeatureFlags returns every feature flag with its instance-wide
+// setting and the users and groups it is overridden for.
+func (h *Handler) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireFeatureFlags(w) {
+		return
+	}
+	overrides, err := h.store.FeatureFlags.List(r.Context())
+	if err != nil {
+		http.Error(w, "failed to load feature flags", http.StatusInternalServerError)
+		return
+	}
+	cfg := h.config()
+	resp := make([]featureFlagResponse, 0, len(flags.All))
+	for _, f := range flags.All {
+		item := featureFlagResponse{
+			Name:        f.Name,
+			Description: f.Description,
+			Enabled:     cfg != nil && cfg.FeatureEnabled(f.Name),
+			Overrides:   []featureFlagOverrideResponse{},
+		}
+		for _, o := range overrides {
+			if o.Flag == f.Name {
+				item.Overrides = append(item.Overrides, newFeatureFlagOverrideResponse(o))
+			}
+		}
+		resp = append(resp, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// SetFeatureFlagOverride turns a feature flag on or off for one user or
+// group, whatever the instance-wide setting.
+func (h *Handler) SetFeatureFlagOverride(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireFeatureFlags(w) {
+		return
+	}
+	name, scope, id, ok := parseFeatureFlagTarget(w, r)
+	if !ok {
+		return
+	}
+	var req featureFlagOverrideRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	override := store.FeatureFlagOverride{Flag: name, Enabled: *req.Enabled}
+	var found bool
+	var err error
+	target := "user"
+	if scope == "users" {
+		var user *store.User
+		user, err = h.store.Users.GetByID(r.Context(), id)
+		found = user != nil
+		override.UserID = &id
+	} else {
+		var group *store.UserGroup
+		group, err = h.store.UserGroups.GetByID(r.Context(), id)
+		found = group != nil
+		override.GroupID = &id
+		target = "group"
+	}
+	if err != nil {
+		http.Error(w, "failed to load "+target, http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	saved, err := h.store.FeatureFlags.Set(r.Context(), override)
+	if err != nil {
+		http.Error(w, "failed to save feature flag", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newFeatureFlagOverrideResponse(*saved))
+}
+
+// DeleteFeatureFlagOverride returns a user or group to the instance-wide
+// setting of a feature flag.
+func (h *Handler) DeleteFeatureFlagOverride(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireFeatureFlags(w) {
+		return
+	}
+	name, scope, id, ok := parseFeatureFlagTarget(w, r)
+	if !ok {
+		return
+	}
+	var userID, groupID int64
+	if scope == "users" {
+		userID = id
+	} else {
+		groupID = id
+	}
+	deleted, err := h.store.FeatureFlags.Delete(r.Context(), name, userID, groupID)
+	if err != nil {
+		http.Error(w, "failed to delete feature flag override", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseFeatureFlagTarget(w http.ResponseWriter, r *http.Request) (string, string, int64, bool) {
+	name := chi.URLParam(r, "name")
+	if !flags.Known(name) {
+		http.Error(w, "unknown feature flag", http.StatusNotFound)
+		return "", "", 0, false
+	}
+	scope := chi.URLParam(r, "scope")
+	if scope != "users" && scope != "groups" {
+		http.Error(w, "scope must be users or groups", http.StatusBadRequest)
+		return "", "", 0, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return "", "", 0, false
+	}
+	return name, scope, id, true
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestFeatureFlagOverridesReachTheTestAccountOnly(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"admin@example.com"}}
+	tester := store.User{ID: 1, PrimaryEmail: "tester@example.com"}
+	overrides := &storetest.FeatureFlags{}
+	h := NewHandler(cfg, &store.Store{
+		Users:        &focusUsers{user: tester},
+		UserGroups:   &fakeUserGroupRepo{groups: map[int64]*store.UserGroup{}},
+		FeatureFlags: overrides,
+	})
+	h.SetDAVServer(dav.NewServer(dav.Options{Config: cfg}))
+	admin := &store.User{ID: 9, PrimaryEmail: "admin@example.com"}
+	do := func(user *store.User, method, name, scope, id, body string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/admin/feature-flags/"+name+"/"+scope+"/"+id, strings.NewReader(body))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("name", name)
+		routeCtx.URLParams.Add("scope", scope)
+		routeCtx.URLParams.Add("id", id)
+		ctx := auth.WithUser(req.Context(), user)
+		rec := httptest.NewRecorder()
+		handle(rec, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx)))
+		return rec
+	}
+	flagsFor := func(user *store.User) map[string]bool {
+		t.Helper()
+		rec := do(user, http.MethodGet, "", "", "", "", h.GetServerInfo)
+		var resp serverInfoResponse
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+			t.Fatalf("server info = %d %s", rec.Code, rec.Body.String())
+		}
+		return resp.Flags
+	}
+
+	if got := flagsFor(&tester); got[config.FeaturePush] || !got[config.FeatureFocusTime] {
+		t.Fatalf("flags before any override = %v", got)
+	}
+	if rec := do(&tester, http.MethodPut, "push", "users", "1", `{"enabled":true}`, h.SetFeatureFlagOverride); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin PUT = %d", rec.Code)
+	}
+	if rec := do(admin, http.MethodPut, "new-parser", "users", "1", `{"enabled":true}`, h.SetFeatureFlagOverride); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown flag PUT = %d", rec.Code)
+	}
+	if rec := do(admin, http.MethodPut, "push", "users", "2", `{"enabled":true}`, h.SetFeatureFlagOverride); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown user PUT = %d", rec.Code)
+	}
+	if rec := do(admin, http.MethodPut, "push", "users", "1", `{}`, h.SetFeatureFlagOverride); rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT without enabled = %d", rec.Code)
+	}
+	for _, body := range []string{`{"enabled":false}`, `{"enabled":true}`} {
+		if rec := do(admin, http.MethodPut, "push", "users", "1", body, h.SetFeatureFlagOverride); rec.Code != http.StatusOK {
+			t.Fatalf("PUT %s = %d %s", body, rec.Code, rec.Body.String())
+		}
+	}
+	if len(overrides.Overrides) != 1 {
+		t.Fatalf("overrides = %+v, want the one replaced", overrides.Overrides)
+	}
+
+	if got := flagsFor(&tester); !got[config.FeaturePush] {
+		t.Fatalf("tester flags = %v, want push on", got)
+	}
+	if got := flagsFor(&store.User{ID: 3}); got[config.FeaturePush] {
+		t.Fatalf("other user flags = %v, want push off", got)
+	}
+
+	rec := do(admin, http.MethodGet, "", "", "", "", h.ListFeatureFlags)
+	var list []featureFlagResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &list) != nil {
+		t.Fatalf("list = %d %s", rec.Code, rec.Body.String())
+	}
+	for _, f := range list {
+		if f.Name == config.FeaturePush && (f.Enabled || len(f.Overrides) != 1 || *f.Overrides[0].UserID != 1 || !f.Overrides[0].Enabled) {
+			t.Fatalf("push = %+v", f)
+		}
+	}
+
+	if rec := do(admin, http.MethodDelete, "push", "users", "1", "", h.DeleteFeatureFlagOverride); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", rec.Code)
+	}
+	if rec := do(admin, http.MethodDelete, "push", "users", "1", "", h.DeleteFeatureFlagOverride); rec.Code != http.StatusNotFound {
+		t.Fatalf("second DELETE = %d", rec.Code)
+	}
+	if got := flagsFor(&tester); got[config.FeaturePush] {
+		t.Fatalf("tester flags after DELETE = %v", got)
+	}
+}
+
+func TestUpdateFocusNeedsTheFeatureFlag(t *testing.T) {
+	user := store.User{ID: 1}
+	cfg := &config.Config{Features: map[string]bool{config.FeatureFocusTime: false}}
+	h := NewHandler(cfg, &store.Store{Users: &focusUsers{user: user}, Focus: &storetest.Focus{}})
+	req := httptest.NewRequest(http.MethodPut, "/api/preferences/focus", strings.NewReader(`{"minMinutes":30,"dayStart":"09:00","dayEnd":"17:00"}`))
+	rec := httptest.NewRecorder()
+	h.UpdateFocus(rec, req.WithContext(auth.WithUser(req.Context(), &user)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("PUT with focus time off = %d %s", rec.Code, rec.Body.String())
+	}
+}
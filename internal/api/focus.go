@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/flags"
+	"github.com/jw6ventures/calcard/internal/focus"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type focusRequest struct {
+	MinMinutes int `json:"minMinutes"`
+	// DayStart and DayEnd bound the protected hours, as local HH:MM; DayEnd
+	// may be 24:00.
+	DayStart string `json:"dayStart"`
+	DayEnd   string `json:"dayEnd"`
+}
+
+type focusResponse struct {
+	MinMinutes   int        `json:"minMinutes"`
+	DayStart     string     `json:"dayStart"`
+	DayEnd       string     `json:"dayEnd"`
+	PlannedUntil *time.Time `json:"plannedUntil"`
+	// Blocks counts the focus time blocks that have not yet ended.
+	Blocks int `json:"blocks"`
+}
+
+type focusRemovedResponse struct {
+	Removed int `json:"removed"`
+}
+
+// GetFocus returns the user's focus time settings, or 404 when they have
+// not opted in.
+func (h *Handler) GetFocus(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	settings, err := h.store.Focus.GetSettings(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load focus time", http.StatusInternalServerError)
+		return
+	}
+	if settings == nil {
+		http.Error(w, "focus time is off", http.StatusNotFound)
+		return
+	}
+	h.writeFocus(w, r, user, *settings)
+}
+
+// UpdateFocus opts the user in to focus time, or changes their settings.
+// The upcoming blocks are replaced by ones planned with the new settings.
+// Users the focus-time feature flag is off for cannot opt in.
+func (h *Handler) UpdateFocus(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !flags.Enabled(r.Context(), h.config(), h.store, user, config.FeatureFocusTime) {
+		http.Error(w, "focus time is not enabled for this account", http.StatusForbidden)
+		return
+	}
+	var req focusRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	settings := store.FocusSettings{UserID: user.ID, MinMinutes: req.MinMinutes}
+	var err error
+	if settings.DayStartMinute, err = parseFocusTime(req.DayStart, "day start"); err == nil {
+		if settings.DayEndMinute, err = parseFocusTime(req.DayEnd, "day end"); err == nil {
+			err = focus.Normalize(&settings)
+		}
+	}
+	if err != nil {
+		http.Error(w, strings.TrimPrefix(err.Error(), focus.ErrInvalidSettings.Error()+": "), http.StatusBadRequest)
+		return
+	}
+	if _, err := h.focus.Remove(r.Context(), user); err != nil {
+		http.Error(w, "failed to remove focus time", http.StatusInternalServerError)
+		return
+	}
+	saved, err := h.store.Focus.UpsertSettings(r.Context(), settings)
+	if err != nil {
+		http.Error(w, "failed to save focus time", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.focus.Plan(r.Context(), *saved); err != nil {
+		http.Error(w, "failed to plan focus time", http.StatusInternalServerError)
+		return
+	}
+	if saved, err = h.store.Focus.GetSettings(r.Context(), user.ID); err != nil || saved == nil {
+		http.Error(w, "failed to load focus time", http.StatusInternalServerError)
+		return
+	}
+	h.writeFocus(w, r, user, *saved)
+}
+
+// DeleteFocus opts the user out of focus time and removes their upcoming
+// blocks.
+func (h *Handler) DeleteFocus(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if err := h.store.Focus.DeleteSettings(r.Context(), user.ID); err != nil {
+		http.Error(w, "failed to delete focus time", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.focus.Remove(r.Context(), user); err != nil {
+		http.Error(w, "failed to remove focus time", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveFocusBlocks deletes the user's upcoming focus time blocks in one
+// go. The days they covered are not planned again; days coming within the
+// week later are, until the user opts out.
+func (h *Handler) RemoveFocusBlocks(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	removed, err := h.focus.Remove(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to remove focus time", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, focusRemovedResponse{Removed: removed})
+}
+
+func (h *Handler) writeFocus(w http.ResponseWriter, r *http.Request, user *store.User, settings store.FocusSettings) {
+	blocks, err := h.store.Focus.ListBlocks(r.Context(), user.ID, time.Now())
+	if err != nil {
+		http.Error(w, "failed to load focus time", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, focusResponse{
+		MinMinutes:   settings.MinMinutes,
+		DayStart:     formatFocusTime(settings.DayStartMinute),
+		DayEnd:       formatFocusTime(settings.DayEndMinute),
+		PlannedUntil: settings.PlannedUntil,
+		Blocks:       len(blocks),
+	})
+}
+
+// parseFocusTime parses an HH:MM time of day, or 24:00, into minutes past
+// midnight.
+func parseFocusTime(value, field string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be HH:MM", focus.ErrInvalidSettings, field)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatFocusTime(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
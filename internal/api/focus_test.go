@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type focusUsers struct {
+	store.UserRepository
+	user store.User
+}
+
+func (f *focusUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if id != f.user.ID {
+		return nil, nil
+	}
+	return &f.user, nil
+}
+
+func TestFocusPlansOnSaveAndRemovesInOneGo(t *testing.T) {
+	user := store.User{ID: 1, PrimaryEmail: "ann@example.com"}
+	f := storetest.New()
+	f.AddCalendar(storetest.OwnedCalendar(5, 1, "Personal"))
+	f.Store.Users = &focusUsers{user: user}
+	f.Store.Focus = &storetest.Focus{}
+	h := NewHandler(&config.Config{}, f.Store)
+	do := func(method, path, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), &user))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/preferences/focus", "", h.GetFocus); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET before opting in = %d", rec.Code)
+	}
+	rec := do(http.MethodPut, "/api/preferences/focus", `{"minMinutes":10,"dayStart":"09:00","dayEnd":"17:00"}`, h.UpdateFocus)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "minimum block") {
+		t.Fatalf("PUT with a 10 minute block = %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Whole days leave every working day of the week free to block.
+	rec = do(http.MethodPut, "/api/preferences/focus", `{"minMinutes":15,"dayStart":"00:00","dayEnd":"24:00"}`, h.UpdateFocus)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	var saved focusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.DayEnd != "24:00" || saved.PlannedUntil == nil || saved.Blocks == 0 || saved.Blocks != len(f.Events.Events) {
+		t.Fatalf("PUT = %+v with %d events", saved, len(f.Events.Events))
+	}
+
+	rec = do(http.MethodDelete, "/api/preferences/focus/blocks", "", h.RemoveFocusBlocks)
+	var removed focusRemovedResponse
+	if err := json.NewDecoder(rec.Body).Decode(&removed); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("DELETE blocks = %d %v", rec.Code, err)
+	}
+	if removed.Removed != saved.Blocks || len(f.Events.Events) != 0 {
+		t.Fatalf("removed %d of %d blocks, %d events left", removed.Removed, saved.Blocks, len(f.Events.Events))
+	}
+
+	if rec := do(http.MethodDelete, "/api/preferences/focus", "", h.DeleteFocus); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/preferences/focus", "", h.GetFocus); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after opting out = %d", rec.Code)
+	}
+}
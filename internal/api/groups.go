@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const maxGroupNameLength = 100
+
+type groupMemberResponse struct {
+	UserID int64  `json:"userId"`
+	Email  string `json:"email"`
+}
+
+type groupResponse struct {
+	ID        int64                 `json:"id"`
+	Name      string                `json:"name"`
+	Principal string                `json:"principal"`
+	Members   []groupMemberResponse `json:"members"`
+	CreatedAt string                `json:"createdAt"`
+}
+
+type createGroupRequest struct {
+	Name      string  `json:"name"`
+	MemberIDs []int64 `json:"memberIds"`
+}
+
+// ListGroups returns the caller's sharing groups and their members.
+func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	groups, err := h.store.UserGroups.ListByOwner(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load groups", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]groupResponse, 0, len(groups))
+	for _, g := range groups {
+		resp = append(resp, h.groupResponse(r, g))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateGroup creates a sharing group, optionally with its first members.
+func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	var req createGroupRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxGroupNameLength {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	for _, id := range req.MemberIDs {
+		if !h.userExists(w, r, id) {
+			return
+		}
+	}
+	group, err := h.store.UserGroups.Create(r.Context(), user.ID, name)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "a group with that name already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to create group", http.StatusInternalServerError)
+		return
+	}
+	for _, id := range req.MemberIDs {
+		if err := h.store.UserGroups.AddMember(r.Context(), group.ID, id); err != nil {
+			http.Error(w, "failed to add group member", http.StatusInternalServerError)
+			return
+		}
+		group.MemberIDs = append(group.MemberIDs, id)
+	}
+	writeJSON(w, http.StatusCreated, h.groupResponse(r, *group))
+}
+
+// DeleteGroup deletes one of the caller's groups. Everything shared with the
+// group stops being shared with its members.
+func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	user, group, ok := h.ownedGroup(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.UserGroups.Delete(r.Context(), user.ID, group.ID); err != nil {
+		http.Error(w, "failed to delete group", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddGroupMember adds a user to one of the caller's groups, giving them
+// access to everything already shared with it.
+func (h *Handler) AddGroupMember(w http.ResponseWriter, r *http.Request) {
+	_, group, ok := h.ownedGroup(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := parseGroupMemberID(w, r)
+	if !ok || !h.userExists(w, r, memberID) {
+		return
+	}
+	if err := h.store.UserGroups.AddMember(r.Context(), group.ID, memberID); err != nil {
+		http.Error(w, "failed to add group member", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveGroupMember removes a user from one of the caller's groups.
+func (h *Handler) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	_, group, ok := h.ownedGroup(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := parseGroupMemberID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.UserGroups.RemoveMember(r.Context(), group.ID, memberID); err != nil {
+		http.Error(w, "failed to remove group member", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedGroup loads the group named in the URL, answering 404 unless the
+// caller owns it.
+func (h *Handler) ownedGroup(w http.ResponseWriter, r *http.Request) (*store.User, *store.UserGroup, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid group id", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	group, err := h.store.UserGroups.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load group", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if group == nil || group.OwnerUserID != user.ID {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return user, group, true
+}
+
+func parseGroupMemberID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *Handler) userExists(w http.ResponseWriter, r *http.Request, id int64) bool {
+	u, err := h.store.Users.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return false
+	}
+	if u == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) groupResponse(r *http.Request, g store.UserGroup) groupResponse {
+	resp := groupResponse{
+		ID:        g.ID,
+		Name:      g.Name,
+		Principal: store.GroupPrincipalHref(g.ID),
+		Members:   make([]groupMemberResponse, 0, len(g.MemberIDs)),
+		CreatedAt: g.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	for _, id := range g.MemberIDs {
+		member := groupMemberResponse{UserID: id}
+		if u, err := h.store.Users.GetByID(r.Context(), id); err == nil && u != nil {
+			member.Email = u.PrimaryEmail
+		}
+		resp.Members = append(resp.Members, member)
+	}
+	return resp
+}
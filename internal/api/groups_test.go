@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeUserGroupRepo struct {
+	store.UserGroupRepository
+	groups map[int64]*store.UserGroup
+	nextID int64
+}
+
+func (f *fakeUserGroupRepo) Create(_ context.Context, ownerUserID int64, name string) (*store.UserGroup, error) {
+	for _, g := range f.groups {
+		if g.OwnerUserID == ownerUserID && g.Name == name {
+			return nil, store.ErrConflict
+		}
+	}
+	f.nextID++
+	g := &store.UserGroup{ID: f.nextID, OwnerUserID: ownerUserID, Name: name}
+	f.groups[g.ID] = g
+	cp := *g
+	return &cp, nil
+}
+
+func (f *fakeUserGroupRepo) GetByID(_ context.Context, id int64) (*store.UserGroup, error) {
+	if g, ok := f.groups[id]; ok {
+		cp := *g
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeUserGroupRepo) AddMember(_ context.Context, groupID, userID int64) error {
+	g := f.groups[groupID]
+	for _, id := range g.MemberIDs {
+		if id == userID {
+			return nil
+		}
+	}
+	g.MemberIDs = append(g.MemberIDs, userID)
+	return nil
+}
+
+func (f *fakeUserGroupRepo) RemoveMember(_ context.Context, groupID, userID int64) error {
+	g := f.groups[groupID]
+	kept := g.MemberIDs[:0]
+	for _, id := range g.MemberIDs {
+		if id != userID {
+			kept = append(kept, id)
+		}
+	}
+	g.MemberIDs = kept
+	return nil
+}
+
+func groupRequest(method, target, body string, userID int64, params map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(auth.WithUser(ctx, &store.User{ID: userID}))
+}
+
+func TestCreateGroupAndManageMembers(t *testing.T) {
+	groups := &fakeUserGroupRepo{groups: map[int64]*store.UserGroup{}}
+	users := &fakeUserRepo{users: map[int64]*store.User{
+		2: {ID: 2, PrimaryEmail: "kid@example.com"},
+		3: {ID: 3, PrimaryEmail: "partner@example.com"},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{Users: users, UserGroups: groups})
+
+	rec := httptest.NewRecorder()
+	h.CreateGroup(rec, groupRequest(http.MethodPost, "/api/groups", `{"name":" Family ","memberIds":[2]}`, 1, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateGroup() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var created groupResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.Name != "Family" || created.Principal != "/dav/principals/groups/1/" || len(created.Members) != 1 || created.Members[0].Email != "kid@example.com" {
+		t.Fatalf("unexpected group %+v", created)
+	}
+
+	rec = httptest.NewRecorder()
+	h.CreateGroup(rec, groupRequest(http.MethodPost, "/api/groups", `{"name":"Family"}`, 1, nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate CreateGroup() status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.AddGroupMember(rec, groupRequest(http.MethodPut, "/api/groups/1/members/3", "", 1, map[string]string{"id": "1", "userId": "3"}))
+	if rec.Code != http.StatusNoContent || len(groups.groups[1].MemberIDs) != 2 {
+		t.Fatalf("AddGroupMember() status = %d members=%v", rec.Code, groups.groups[1].MemberIDs)
+	}
+
+	rec = httptest.NewRecorder()
+	h.AddGroupMember(rec, groupRequest(http.MethodPut, "/api/groups/1/members/9", "", 1, map[string]string{"id": "1", "userId": "9"}))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("AddGroupMember() for an unknown user status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RemoveGroupMember(rec, groupRequest(http.MethodDelete, "/api/groups/1/members/2", "", 1, map[string]string{"id": "1", "userId": "2"}))
+	if rec.Code != http.StatusNoContent || len(groups.groups[1].MemberIDs) != 1 || groups.groups[1].MemberIDs[0] != 3 {
+		t.Fatalf("RemoveGroupMember() status = %d members=%v", rec.Code, groups.groups[1].MemberIDs)
+	}
+}
+
+func TestGroupMembersOnlyManagedByOwner(t *testing.T) {
+	groups := &fakeUserGroupRepo{groups: map[int64]*store.UserGroup{
+		1: {ID: 1, OwnerUserID: 1, Name: "Family"},
+	}}
+	users := &fakeUserRepo{users: map[int64]*store.User{5: {ID: 5}}}
+	h := NewHandler(&config.Config{}, &store.Store{Users: users, UserGroups: groups})
+
+	rec := httptest.NewRecorder()
+	h.AddGroupMember(rec, groupRequest(http.MethodPut, "/api/groups/1/members/5", "", 5, map[string]string{"id": "1", "userId": "5"}))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("AddGroupMember() by non-owner status = %d", rec.Code)
+	}
+	if len(groups.groups[1].MemberIDs) != 0 {
+		t.Fatalf("non-owner changed members: %v", groups.groups[1].MemberIDs)
+	}
+}
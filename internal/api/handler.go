@@ -9,30 +9,68 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/backup"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/dav"
 	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/focus"
+	"github.com/jw6ventures/calcard/internal/fsck"
+	"github.com/jw6ventures/calcard/internal/http/drain"
+	"github.com/jw6ventures/calcard/internal/jobs"
+	"github.com/jw6ventures/calcard/internal/maintenance"
+	"github.com/jw6ventures/calcard/internal/notify"
+	"github.com/jw6ventures/calcard/internal/probe"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
 
 type Handler struct {
-	cfg      *config.Config
-	store    *store.Store
-	events   *events.Service
-	contacts *contacts.Service
+	cfg         *config.Config
+	store       *store.Store
+	events      *events.Service
+	contacts    *contacts.Service
+	backups     *backup.Service
+	fsck        *fsck.Service
+	notify      *notify.Service
+	alarms      *notify.Alarms
+	focus       *focus.Service
+	authService *auth.Service
+	reloader    *config.Reloader
+	jobs        *jobs.Runner
+	drainer     *drain.Drainer
+	davServer   *dav.Server
+	prober      *probe.Prober
+	migrator    Migrator
+	// migrating is held while the admin migration endpoint runs.
+	migrating sync.Mutex
+	// live holds the most recently reloaded configuration, if any.
+	live atomic.Pointer[config.Config]
 }
 
 func NewHandler(cfg *config.Config, st *store.Store) *Handler {
-	return &Handler{
+	h := &Handler{
 		cfg:      cfg,
 		store:    st,
 		events:   events.NewService(st),
 		contacts: contacts.NewService(st),
+		notify:   notify.New(cfg, st, nil),
+		alarms:   notify.NewAlarms(cfg, st, nil),
+		focus:    focus.New(cfg, st, nil),
+	}
+	if cfg != nil {
+		h.contacts.SetStrictValidation(cfg.ContactValidation == config.ContactValidationStrict)
+		if cfg.RequireServerUIDs {
+			h.events.RequireServerUIDs(events.UIDHost(cfg.BaseURL))
+		}
 	}
+	return h
 }
 
 type eventWriteRequest struct {
@@ -47,13 +85,67 @@ type eventResponse struct {
 	ResourceName string  `json:"resourceName"`
 	Summary      *string `json:"summary,omitempty"`
 	Description  *string `json:"description,omitempty"`
-	Location     *string `json:"location,omitempty"`
-	DTStart      *string `json:"dtstart,omitempty"`
-	DTEnd        *string `json:"dtend,omitempty"`
-	AllDay       bool    `json:"allDay"`
-	ETag         string  `json:"etag"`
-	LastModified string  `json:"lastModified"`
-	RawICS       string  `json:"rawIcal"`
+	// DescriptionFormat and DescriptionHTML are set for events with a
+	// formatted description; the HTML is sanitized.
+	DescriptionFormat string           `json:"descriptionFormat,omitempty"`
+	DescriptionHTML   string           `json:"descriptionHtml,omitempty"`
+	Location          *string          `json:"location,omitempty"`
+	DTStart           *string          `json:"dtstart,omitempty"`
+	DTEnd             *string          `json:"dtend,omitempty"`
+	AllDay            bool             `json:"allDay"`
+	Color             *string          `json:"color,omitempty"`
+	Image             *string          `json:"image,omitempty"`
+	Conference        *string          `json:"conference,omitempty"`
+	JoinLink          *joinLink        `json:"joinLink,omitempty"`
+	Attendees         *attendeeSummary `json:"attendeeSummary,omitempty"`
+	Alarms            []alarmResponse  `json:"alarms,omitempty"`
+	// LastAcknowledged is when any of the event's alarms was last
+	// dismissed, from X-MOZ-LASTACK.
+	LastAcknowledged *string `json:"lastAcknowledged,omitempty"`
+	ETag             string  `json:"etag"`
+	LastModified     string  `json:"lastModified"`
+	RawICS           string  `json:"rawIcal"`
+}
+
+// instanceResponse is one occurrence of a recurring event.
+type instanceResponse struct {
+	RecurrenceID string `json:"recurrenceId"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	AllDay       bool   `json:"allDay"`
+	Overridden   bool   `json:"overridden"`
+	Cancelled    bool   `json:"cancelled"`
+	Rescheduled  bool   `json:"rescheduled"`
+	Summary      string `json:"summary,omitempty"`
+}
+
+// alarmResponse is one VALARM of an event. Acknowledged is set once the
+// alarm has been dismissed on some device.
+type alarmResponse struct {
+	// ID names the alarm in the acknowledge and snooze endpoints.
+	ID           string  `json:"id"`
+	UID          string  `json:"uid,omitempty"`
+	Action       string  `json:"action"`
+	Trigger      string  `json:"trigger"`
+	Acknowledged *string `json:"acknowledged,omitempty"`
+	// Snoozes is the UID of the alarm this snooze alarm stands in for.
+	Snoozes   string `json:"snoozes,omitempty"`
+	Proximity string `json:"proximity,omitempty"`
+}
+
+// joinLink is the online meeting link detected in an event, if any.
+type joinLink struct {
+	URL      string `json:"url"`
+	Provider string `json:"provider"`
+}
+
+// attendeeSummary counts an event's attendees by response.
+type attendeeSummary struct {
+	Accepted  int `json:"accepted"`
+	Declined  int `json:"declined"`
+	Tentative int `json:"tentative"`
+	Pending   int `json:"pending"`
+	Total     int `json:"total"`
 }
 
 type calendarResponse struct {
@@ -65,6 +157,11 @@ type calendarResponse struct {
 	OwnerEmail   string                   `json:"ownerEmail"`
 	Shared       bool                     `json:"shared"`
 	Capabilities store.CalendarPrivileges `json:"capabilities"`
+	EventCount   *int                     `json:"eventCount,omitempty"`
+	// Checksum hashes the UID and ETag of every event, so two replicas can
+	// be compared without fetching them. Only single-calendar responses
+	// carry it.
+	Checksum string `json:"checksum,omitempty"`
 }
 
 func calendarMetadataVisible(cal store.CalendarAccess) bool {
@@ -88,6 +185,9 @@ func calendarResponseForAccess(cal store.CalendarAccess) calendarResponse {
 	resp.Timezone = cal.Timezone
 	resp.Color = cal.Color
 	resp.OwnerEmail = cal.OwnerEmail
+	if cal.EffectivePrivileges().Read {
+		resp.EventCount = cal.EventCount
+	}
 	return resp
 }
 
@@ -97,11 +197,27 @@ func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing user", http.StatusUnauthorized)
 		return
 	}
-	cals, err := h.events.ListCalendars(r.Context(), user)
+	limit, offset, paged, err := parseCalendarPage(r)
 	if err != nil {
-		http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	var cals []store.CalendarAccess
+	if paged {
+		page, err := h.events.ListCalendarsPage(r.Context(), user, limit, offset)
+		if err != nil {
+			http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.TotalCount))
+		cals = page.Items
+	} else {
+		cals, err = h.events.ListCalendars(r.Context(), user)
+		if err != nil {
+			http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+			return
+		}
+	}
 	resp := make([]calendarResponse, 0, len(cals))
 	for _, cal := range cals {
 		resp = append(resp, calendarResponseForAccess(cal))
@@ -109,6 +225,36 @@ func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// maxCalendarPageSize bounds the limit a paged calendar listing may ask for.
+const maxCalendarPageSize = 500
+
+// parseCalendarPage reads the optional limit and offset of a calendar
+// listing. Without either the whole list is returned.
+func parseCalendarPage(r *http.Request) (limit, offset int, paged bool, err error) {
+	q := r.URL.Query()
+	rawLimit, rawOffset := q.Get("limit"), q.Get("offset")
+	if rawLimit == "" && rawOffset == "" {
+		return 0, 0, false, nil
+	}
+	limit = 100
+	if rawLimit != "" {
+		limit, err = strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 {
+			return 0, 0, false, errors.New("invalid limit")
+		}
+		if limit > maxCalendarPageSize {
+			limit = maxCalendarPageSize
+		}
+	}
+	if rawOffset != "" {
+		offset, err = strconv.Atoi(rawOffset)
+		if err != nil || offset < 0 {
+			return 0, 0, false, errors.New("invalid offset")
+		}
+	}
+	return limit, offset, true, nil
+}
+
 func (h *Handler) GetCalendar(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -124,7 +270,14 @@ func (h *Handler) GetCalendar(w http.ResponseWriter, r *http.Request) {
 		writeEventError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, calendarResponseForAccess(*cal))
+	resp := calendarResponseForAccess(*cal)
+	if cal.EffectivePrivileges().Read && h.store != nil && h.store.CollectionSyncs != nil {
+		if resp.Checksum, err = h.store.CollectionSyncs.Checksum(r.Context(), "calendar", cal.ID); err != nil {
+			http.Error(w, "failed to compute checksum", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
@@ -173,6 +326,60 @@ func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, toEventResponse(*ev))
 }
 
+// maxInstanceRange bounds the window ListEventInstances expands.
+const maxInstanceRange = 366 * 24 * time.Hour
+
+// ListEventInstances expands a recurring event over [start, end), flagging
+// occurrences that were overridden, cancelled or moved.
+func (h *Handler) ListEventInstances(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, uid, ok := parseCalendarIDAndUID(w, r)
+	if !ok {
+		return
+	}
+	start, err := parseTimeParam(r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid start: %v", err), http.StatusBadRequest)
+		return
+	}
+	end, err := parseTimeParam(r.URL.Query().Get("end"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid end: %v", err), http.StatusBadRequest)
+		return
+	}
+	if start == nil || end == nil || !end.After(*start) {
+		http.Error(w, "start and end are required, with end after start", http.StatusBadRequest)
+		return
+	}
+	if end.Sub(*start) > maxInstanceRange {
+		http.Error(w, "range must not exceed 366 days", http.StatusBadRequest)
+		return
+	}
+	instances, err := h.events.EventInstances(r.Context(), user, calendarID, uid, *start, *end)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]instanceResponse, 0, len(instances))
+	for _, inst := range instances {
+		resp = append(resp, instanceResponse{
+			RecurrenceID: inst.RecurrenceID.UTC().Format(time.RFC3339),
+			Start:        inst.Start.UTC().Format(time.RFC3339),
+			End:          inst.End.UTC().Format(time.RFC3339),
+			AllDay:       inst.AllDay,
+			Overridden:   inst.Overridden,
+			Cancelled:    inst.Cancelled,
+			Rescheduled:  inst.Rescheduled,
+			Summary:      inst.Summary,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -409,6 +616,34 @@ func toEventResponse(ev store.Event) eventResponse {
 		v := ev.DTEnd.UTC().Format(time.RFC3339)
 		dtend = &v
 	}
+	var join *joinLink
+	if link, provider := utils.ExtractJoinLink(ev.RawICAL); link != "" {
+		join = &joinLink{URL: link, Provider: provider}
+	}
+	var attendees *attendeeSummary
+	if summary := utils.SummarizeAttendees(ev.RawICAL); summary != nil {
+		attendees = &attendeeSummary{
+			Accepted:  summary.Accepted,
+			Declined:  summary.Declined,
+			Tentative: summary.Tentative,
+			Pending:   summary.Pending,
+			Total:     summary.Total(),
+		}
+	}
+	props := utils.ParseEventProperties(ev.RawICAL)
+	alt := utils.ParseAltDescription(ev.RawICAL)
+	var alarms []alarmResponse
+	for _, a := range utils.ParseAlarms(ev.RawICAL) {
+		alarms = append(alarms, alarmResponse{
+			ID:           a.ID,
+			UID:          a.UID,
+			Action:       a.Action,
+			Trigger:      a.Trigger,
+			Acknowledged: optionalTime(a.Acknowledged),
+			Snoozes:      a.Snoozes,
+			Proximity:    a.Proximity,
+		})
+	}
 	return eventResponse{
 		UID:          ev.UID,
 		CalendarID:   ev.CalendarID,
@@ -416,16 +651,29 @@ func toEventResponse(ev store.Event) eventResponse {
 		Summary:      ev.Summary,
 		Description:  ev.Description,
 		Location:     ev.Location,
-		DTStart:      dtstart,
-		DTEnd:        dtend,
-		AllDay:       ev.AllDay,
-		ETag:         ev.ETag,
-		LastModified: ev.LastModified.UTC().Format(time.RFC3339),
-		RawICS:       ev.RawICAL,
+
+		DescriptionFormat: alt.Format,
+		DescriptionHTML:   alt.HTML,
+		DTStart:           dtstart,
+		DTEnd:             dtend,
+		AllDay:            ev.AllDay,
+		Color:             optionalString(props.Color),
+		Image:             optionalString(props.Image),
+		Conference:        optionalString(props.Conference),
+		JoinLink:          join,
+		Attendees:         attendees,
+		Alarms:            alarms,
+		LastAcknowledged:  optionalTime(utils.LastAcknowledged(ev.RawICAL)),
+		ETag:              ev.ETag,
+		LastModified:      ev.LastModified.UTC().Format(time.RFC3339),
+		RawICS:            ev.RawICAL,
 	}
 }
 
 func writeEventError(w http.ResponseWriter, err error) {
+	if maintenance.WriteError(w, err) {
+		return
+	}
 	status := events.StatusCode(err)
 	if status == http.StatusInternalServerError {
 		http.Error(w, "internal server error", status)
@@ -443,3 +691,18 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 func fmtBadRequest(err error) error {
 	return errors.Join(events.ErrBadRequest, err)
 }
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func optionalTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	v := t.UTC().Format(time.RFC3339)
+	return &v
+}
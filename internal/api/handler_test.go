@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -61,6 +62,74 @@ func TestCreateEventStructured(t *testing.T) {
 	}
 }
 
+func TestToEventResponseSummarizesAttendees(t *testing.T) {
+	raw := strings.Join([]string{
+		"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:sync",
+		"ORGANIZER:mailto:me@example.com",
+		"ATTENDEE;PARTSTAT=ACCEPTED:mailto:a@example.com",
+		"ATTENDEE;PARTSTAT=DECLINED:mailto:b@example.com",
+		"ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:c@example.com",
+		"ATTENDEE:mailto:d@example.com",
+		"END:VEVENT", "END:VCALENDAR", "",
+	}, "\r\n")
+	resp := toEventResponse(store.Event{UID: "sync", RawICAL: raw})
+	want := attendeeSummary{Accepted: 1, Declined: 1, Pending: 2, Total: 4}
+	if resp.Attendees == nil || *resp.Attendees != want {
+		t.Fatalf("attendeeSummary = %+v, want %+v", resp.Attendees, want)
+	}
+	if resp := toEventResponse(store.Event{UID: "solo", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:solo\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"}); resp.Attendees != nil {
+		t.Fatalf("attendeeSummary without attendees = %+v, want nil", resp.Attendees)
+	}
+}
+
+func TestCreateEventStructuredRFC7986Properties(t *testing.T) {
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{
+			calendars: map[int64]*store.CalendarAccess{
+				1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			},
+		},
+		Events: &fakeEventRepo{events: map[string]store.Event{}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/calendars/1/events", strings.NewReader(`{
+		"inputMode":"structured",
+		"structured":{
+			"summary":"Standup",
+			"dtstart":"2026-03-20T10:00",
+			"dtend":"2026-03-20T10:15",
+			"conference":"https://zoom.us/j/123456",
+			"color":"teal",
+			"image":"https://example.com/standup.png"
+		}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withUserAndRoute(req, "1", "")
+
+	rec := httptest.NewRecorder()
+	handler.CreateEvent(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateEvent() status = %d, want %d, body=%s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var body eventResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !strings.Contains(body.RawICS, "CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:https://zoom.us/j/123456") {
+		t.Fatalf("expected CONFERENCE property in ICS, got %s", body.RawICS)
+	}
+	if body.JoinLink == nil || body.JoinLink.URL != "https://zoom.us/j/123456" || body.JoinLink.Provider != "zoom" {
+		t.Fatalf("joinLink = %+v, want zoom link", body.JoinLink)
+	}
+	if body.Color == nil || *body.Color != "teal" || body.Image == nil || *body.Image != "https://example.com/standup.png" {
+		t.Fatalf("color/image = %v/%v, want teal and image URL", body.Color, body.Image)
+	}
+	if body.Conference == nil || *body.Conference != "https://zoom.us/j/123456" {
+		t.Fatalf("conference = %v, want zoom link", body.Conference)
+	}
+}
+
 func TestCreateEventRawICSRejectsMethod(t *testing.T) {
 	handler := NewHandler(&config.Config{}, &store.Store{
 		Calendars: &fakeCalendarRepo{
@@ -168,6 +237,58 @@ func TestGetEventReturnsJSON(t *testing.T) {
 	}
 }
 
+func TestListEventInstances(t *testing.T) {
+	ev := store.Event{
+		CalendarID:   1,
+		UID:          "weekly",
+		ResourceName: "weekly",
+		RawICAL: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:weekly\r\nSUMMARY:Sync\r\nDTSTART:20260302T100000Z\r\nDTEND:20260302T110000Z\r\n" +
+			"RRULE:FREQ=WEEKLY\r\nEXDATE:20260309T100000Z\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:weekly\r\nSUMMARY:Sync\r\nRECURRENCE-ID:20260316T100000Z\r\nDTSTART:20260317T100000Z\r\nDTEND:20260317T110000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{
+			calendars: map[int64]*store.CalendarAccess{
+				1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			},
+		},
+		Events: &fakeEventRepo{events: map[string]store.Event{"1:weekly": ev}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/calendars/1/events/weekly/instances?start=2026-03-01&end=2026-03-22", nil)
+	req = withUserAndRoute(req, "1", "weekly")
+	rec := httptest.NewRecorder()
+	handler.ListEventInstances(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListEventInstances() status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	var body []instanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(body) != 3 {
+		t.Fatalf("expected 3 instances, got %+v", body)
+	}
+	if body[0].Start != "2026-03-02T10:00:00Z" || body[0].Overridden || body[0].Cancelled {
+		t.Fatalf("unexpected first instance %+v", body[0])
+	}
+	if !body[1].Cancelled || body[1].Overridden {
+		t.Fatalf("expected EXDATE to cancel the second instance, got %+v", body[1])
+	}
+	if m := body[2]; !m.Overridden || !m.Rescheduled || m.RecurrenceID != "2026-03-16T10:00:00Z" || m.Start != "2026-03-17T10:00:00Z" {
+		t.Fatalf("expected the third instance moved a day, got %+v", m)
+	}
+
+	for _, query := range []string{"", "?start=2026-03-01", "?start=2026-03-22&end=2026-03-01", "?start=2026-01-01&end=2027-06-01", "?start=soon&end=2026-03-22"} {
+		req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/events/weekly/instances"+query, nil), "1", "weekly")
+		rec := httptest.NewRecorder()
+		handler.ListEventInstances(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
 func TestListCalendarsUnauthorized(t *testing.T) {
 	handler := NewHandler(&config.Config{}, &store.Store{})
 	req := httptest.NewRequest(http.MethodGet, "/api/calendars", nil)
@@ -197,6 +318,43 @@ func TestListCalendarsSuccess(t *testing.T) {
 	}
 }
 
+func TestListCalendarsPaged(t *testing.T) {
+	calendars := map[int64]*store.CalendarAccess{}
+	for id := int64(1); id <= 5; id++ {
+		calendars[id] = &store.CalendarAccess{Calendar: store.Calendar{ID: id, UserID: 1, Name: fmt.Sprintf("Cal %d", id)}, Privileges: store.CalendarPrivileges{Read: true}, PrivilegesResolved: true}
+	}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: calendars, eventCounts: map[int64]int{3: 42}},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/calendars?limit=2&offset=2", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec := httptest.NewRecorder()
+
+	handler.ListCalendars(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListCalendars() status = %d, body=%s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "5" {
+		t.Fatalf("X-Total-Count = %q, want 5", got)
+	}
+	var resp []calendarResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp) != 2 || resp[0].ID != 3 || resp[0].EventCount == nil || *resp[0].EventCount != 42 || resp[1].ID != 4 {
+		t.Fatalf("unexpected page %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/calendars?limit=zero", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec = httptest.NewRecorder()
+	handler.ListCalendars(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("ListCalendars() with a bad limit status = %d", rec.Code)
+	}
+}
+
 func TestListCalendarsIncludesObjectOnlyGrantWithoutCollectionCapabilities(t *testing.T) {
 	handler := NewHandler(&config.Config{}, &store.Store{
 		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
@@ -694,6 +852,56 @@ func TestUpdateEventUnauthorizedAndSuccess(t *testing.T) {
 	}
 }
 
+func TestUpdateEventStructuredKeepsAlarmAcknowledgments(t *testing.T) {
+	existing := store.Event{
+		CalendarID:   1,
+		UID:          "event-1",
+		ResourceName: "event-1",
+		RawICAL: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:event-1\r\nSUMMARY:Old\r\n" +
+			"DTSTART:20260320T100000Z\r\nDTEND:20260320T110000Z\r\nX-MOZ-LASTACK:20260320T094500Z\r\n" +
+			"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nTRIGGER:-PT15M\r\nACKNOWLEDGED:20260320T094500Z\r\nEND:VALARM\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n",
+		ETag:         "current",
+		LastModified: time.Now().UTC(),
+	}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{"1:event-1": existing}}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events: eventRepo,
+	})
+	req := httptest.NewRequest(http.MethodPut, "/api/calendars/1/events/event-1", strings.NewReader(`{
+		"inputMode":"structured",
+		"structured":{"uid":"event-1","summary":"Updated","dtstart":"2026-03-20T10:00","dtend":"2026-03-20T11:00","reminders":[15,60]}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withUserAndRoute(req, "1", "event-1")
+	rec := httptest.NewRecorder()
+	handler.UpdateEvent(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateEvent() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	stored := eventRepo.events["1:event-1"].RawICAL
+	if !strings.Contains(stored, "X-MOZ-LASTACK:20260320T094500Z") || strings.Count(stored, "ACKNOWLEDGED:20260320T094500Z") != 1 {
+		t.Fatalf("expected the acknowledgment to survive the edit, got %s", stored)
+	}
+
+	var resp eventResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.LastAcknowledged == nil || *resp.LastAcknowledged != "2026-03-20T09:45:00Z" || len(resp.Alarms) != 2 {
+		t.Fatalf("unexpected alarm state in response: %+v", resp)
+	}
+	for _, alarm := range resp.Alarms {
+		acked := alarm.Acknowledged != nil && *alarm.Acknowledged == "2026-03-20T09:45:00Z"
+		if acked != (alarm.Trigger == "-PT15M") {
+			t.Fatalf("unexpected alarm %+v", alarm)
+		}
+	}
+}
+
 func TestDeleteEventSuccess(t *testing.T) {
 	handler := NewHandler(&config.Config{}, &store.Store{
 		Calendars: &fakeCalendarRepo{
@@ -893,6 +1101,7 @@ func withUserAndRoute(req *http.Request, calendarID, uid string) *http.Request {
 
 type fakeCalendarRepo struct {
 	calendars         map[int64]*store.CalendarAccess
+	eventCounts       map[int64]int
 	listAccessibleErr error
 	getAccessibleErr  error
 }
@@ -925,6 +1134,20 @@ func (f *fakeCalendarRepo) ListAccessible(ctx context.Context, userID int64) ([]
 	}
 	return out, nil
 }
+func (f *fakeCalendarRepo) ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*store.PaginatedResult[store.CalendarAccess], error) {
+	all, err := f.ListAccessible(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	page := &store.PaginatedResult[store.CalendarAccess]{TotalCount: len(all), Limit: limit, Offset: offset}
+	for i := offset; i < len(all) && i < offset+limit; i++ {
+		count := f.eventCounts[all[i].ID]
+		all[i].EventCount = &count
+		page.Items = append(page.Items, all[i])
+	}
+	return page, nil
+}
 func (f *fakeCalendarRepo) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
 	if f.getAccessibleErr != nil {
 		return nil, f.getAccessibleErr
@@ -968,6 +1191,16 @@ func (f *fakeEventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid s
 	delete(f.events, key(calendarID, uid))
 	return nil
 }
+func (f *fakeEventRepo) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	deleted := 0
+	for _, uid := range uids {
+		if err := f.DeleteByUID(ctx, calendarID, uid); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
 func (f *fakeEventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
 	ev, ok := f.events[key(calendarID, uid)]
 	if !ok {
@@ -985,6 +1218,16 @@ func (f *fakeEventRepo) GetByResourceName(ctx context.Context, calendarID int64,
 	}
 	return nil, nil
 }
+
+func (f *fakeEventRepo) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, *store.Body, error) {
+	ev, err := f.GetByResourceName(ctx, calendarID, resourceName)
+	if err != nil || ev == nil {
+		return nil, nil, err
+	}
+	body := store.NewBody(ev.RawICAL)
+	ev.RawICAL = ""
+	return ev, body, nil
+}
 func (f *fakeEventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
 	var out []store.Event
 	for _, ev := range f.events {
@@ -1045,10 +1288,17 @@ func (f *fakeEventRepo) ListForCalendarFiltered(ctx context.Context, calendarID
 	return out, nil
 }
 func (f *fakeEventRepo) ListForCalendarPaginated(ctx context.Context, calendarID int64, limit, offset int) (*store.PaginatedResult[store.Event], error) {
-	return nil, nil
+	all, _ := f.ListForCalendar(ctx, calendarID)
+	return &store.PaginatedResult[store.Event]{TotalCount: len(all)}, nil
 }
 func (f *fakeEventRepo) ListByUIDs(ctx context.Context, calendarID int64, uids []string) ([]store.Event, error) {
-	return nil, nil
+	var out []store.Event
+	for _, uid := range uids {
+		if ev, ok := f.events[key(calendarID, uid)]; ok {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
 }
 func (f *fakeEventRepo) ListModifiedSince(ctx context.Context, calendarID int64, since time.Time) ([]store.Event, error) {
 	return nil, nil
@@ -1065,6 +1315,12 @@ func (f *fakeEventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toCa
 func (f *fakeEventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
 	return nil, nil
 }
+func (f *fakeEventRepo) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	return 0, nil
+}
+func (f *fakeEventRepo) SplitToNewCalendar(ctx context.Context, cal store.Calendar, fromCalendarID int64, uids []string) (*store.Calendar, int, error) {
+	return nil, 0, nil
+}
 
 func key(calendarID int64, uid string) string {
 	return strconv.FormatInt(calendarID, 10) + ":" + uid
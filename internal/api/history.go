@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+)
+
+type calendarAsOfResponse struct {
+	CalendarID int64  `json:"calendarId"`
+	At         string `json:"at"`
+	// HistorySince is the earliest time the calendar can be viewed as of.
+	HistorySince string                  `json:"historySince"`
+	Events       []eventRevisionResponse `json:"events"`
+}
+
+type eventRevisionResponse struct {
+	UID       string  `json:"uid"`
+	ETag      string  `json:"etag"`
+	Summary   *string `json:"summary,omitempty"`
+	Location  *string `json:"location,omitempty"`
+	Start     *string `json:"start,omitempty"`
+	End       *string `json:"end,omitempty"`
+	AllDay    bool    `json:"allDay"`
+	RawICS    string  `json:"rawIcal"`
+	ChangedBy *int64  `json:"changedBy,omitempty"`
+	// ValidFrom and ValidTo bound the time this version was current;
+	// ValidTo is absent while it still is.
+	ValidFrom string  `json:"validFrom"`
+	ValidTo   *string `json:"validTo,omitempty"`
+}
+
+// GetCalendarAsOf returns the events of a readable calendar, each in the
+// version it had, as they stood at the RFC 3339 time in the at parameter.
+func (h *Handler) GetCalendarAsOf(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		http.Error(w, "invalid at", http.StatusBadRequest)
+		return
+	}
+	revisions, err := h.events.CalendarAsOf(r.Context(), user, calendarID, at)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	since, err := h.events.HistorySince(r.Context(), time.Now())
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := calendarAsOfResponse{
+		CalendarID:   calendarID,
+		At:           at.UTC().Format(time.RFC3339),
+		HistorySince: since.UTC().Format(time.RFC3339),
+		Events:       make([]eventRevisionResponse, 0, len(revisions)),
+	}
+	for _, rev := range revisions {
+		resp.Events = append(resp.Events, eventRevisionResponse{
+			UID:       rev.UID,
+			ETag:      rev.ETag,
+			Summary:   rev.Summary,
+			Location:  rev.Location,
+			Start:     formatOptionalTime(rev.DTStart),
+			End:       formatOptionalTime(rev.DTEnd),
+			AllDay:    rev.AllDay,
+			RawICS:    rev.RawICAL,
+			ChangedBy: rev.ChangedBy,
+			ValidFrom: rev.ValidFrom.UTC().Format(time.RFC3339),
+			ValidTo:   formatOptionalTime(rev.ValidTo),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
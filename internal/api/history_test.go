@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeEventRevisionRepo struct {
+	store.EventRevisionRepository
+	since     time.Time
+	revisions []store.EventRevision
+	at        time.Time
+}
+
+func (f *fakeEventRevisionRepo) HistorySince(ctx context.Context) (time.Time, error) {
+	return f.since, nil
+}
+
+func (f *fakeEventRevisionRepo) ListAsOf(ctx context.Context, calendarID int64, at time.Time) ([]store.EventRevision, error) {
+	f.at = at
+	return f.revisions, nil
+}
+
+func TestGetCalendarAsOf(t *testing.T) {
+	since := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	summary := "Planning"
+	repo := &fakeEventRevisionRepo{since: since, revisions: []store.EventRevision{
+		{CalendarID: 1, UID: "planning", ResourceName: "planning", ETag: "v1", Summary: &summary, RawICAL: "BEGIN:VCALENDAR", ValidFrom: since},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		EventRevisions: repo,
+	})
+	at := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	rec := httptest.NewRecorder()
+	h.GetCalendarAsOf(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/as-of?at="+at.Format(time.RFC3339), nil), "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetCalendarAsOf() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp calendarAsOfResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !repo.at.Equal(at) || resp.HistorySince != since.UTC().Format(time.RFC3339) || len(resp.Events) != 1 ||
+		resp.Events[0].ETag != "v1" || resp.Events[0].RawICS != "BEGIN:VCALENDAR" || resp.Events[0].ValidTo != nil {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+
+	for _, query := range []string{"", "?at=yesterday", "?at=" + since.Add(-time.Hour).Format(time.RFC3339)} {
+		rec = httptest.NewRecorder()
+		h.GetCalendarAsOf(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/as-of"+query, nil), "1", ""))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("GetCalendarAsOf(%q) status = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultImpersonationListLimit = 50
+	maxImpersonationRequests      = 1000
+)
+
+type startImpersonationRequest struct {
+	UserID   int64  `json:"userId"`
+	Scope    string `json:"scope"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"`
+}
+
+type impersonationResponse struct {
+	ID         int64   `json:"id"`
+	AdminEmail string  `json:"adminEmail"`
+	UserID     int64   `json:"userId"`
+	Scope      string  `json:"scope"`
+	Reason     string  `json:"reason"`
+	CreatedAt  string  `json:"createdAt"`
+	ExpiresAt  string  `json:"expiresAt"`
+	EndedAt    *string `json:"endedAt,omitempty"`
+	Active     bool    `json:"active"`
+	// Username and Token are only returned when the impersonation starts.
+	Username string                         `json:"username,omitempty"`
+	Token    string                         `json:"token,omitempty"`
+	Requests []impersonationRequestResponse `json:"requests,omitempty"`
+}
+
+type impersonationRequestResponse struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent,omitempty"`
+	At        string `json:"at"`
+}
+
+func newImpersonationResponse(imp store.Impersonation, now time.Time) impersonationResponse {
+	return impersonationResponse{
+		ID:         imp.ID,
+		AdminEmail: imp.AdminEmail,
+		UserID:     imp.UserID,
+		Scope:      imp.Scope,
+		Reason:     imp.Reason,
+		CreatedAt:  imp.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:  imp.ExpiresAt.UTC().Format(time.RFC3339),
+		EndedAt:    formatOptionalTime(imp.EndedAt),
+		Active:     imp.Active(now),
+	}
+}
+
+func (h *Handler) requireImpersonation(w http.ResponseWriter) bool {
+	if h.store == nil || h.store.Impersonations == nil {
+		http.Error(w, "impersonation is not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) requireAuthService(w http.ResponseWriter) bool {
+	if h.authService == nil {
+		http.Error(w, "impersonation is not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// StartImpersonation lets the calling admin act as another user for a
+// limited time. The response carries the only copy of the token, used as
+// the password with the user's email over DAV or the API.
+func (h *Handler) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireImpersonation(w) || !h.requireAuthService(w) {
+		return
+	}
+	admin, _ := auth.UserFromContext(r.Context())
+	var req startImpersonationRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if req.Duration != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.Duration); err != nil {
+			http.Error(w, "invalid duration", http.StatusBadRequest)
+			return
+		}
+	}
+	token, imp, err := h.authService.Impersonate(r, admin, req.UserID, req.Scope, req.Reason, ttl)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	case errors.Is(err, auth.ErrImpersonationReason), errors.Is(err, auth.ErrImpersonationScope),
+		errors.Is(err, auth.ErrImpersonationTTL), errors.Is(err, auth.ErrImpersonateSelf):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+	target, err := h.store.Users.GetByID(r.Context(), imp.UserID)
+	if err != nil || target == nil {
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return
+	}
+	resp := newImpersonationResponse(*imp, time.Now())
+	resp.Username = target.PrimaryEmail
+	resp.Token = token
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// ListImpersonations returns the newest impersonations first, up to `limit`
+// (default 50).
+func (h *Handler) ListImpersonations(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireImpersonation(w) {
+		return
+	}
+	limit := defaultImpersonationListLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(v, maxAuthEventLimit)
+	}
+	list, err := h.store.Impersonations.List(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "failed to load impersonations", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	resp := make([]impersonationResponse, 0, len(list))
+	for _, imp := range list {
+		resp = append(resp, newImpersonationResponse(imp, now))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetImpersonation returns one impersonation and the requests made with it.
+func (h *Handler) GetImpersonation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireImpersonation(w) {
+		return
+	}
+	imp, ok := h.loadImpersonation(w, r)
+	if !ok {
+		return
+	}
+	requests, err := h.store.Impersonations.ListRequests(r.Context(), imp.ID, maxImpersonationRequests)
+	if err != nil {
+		http.Error(w, "failed to load impersonation requests", http.StatusInternalServerError)
+		return
+	}
+	resp := newImpersonationResponse(*imp, time.Now())
+	resp.Requests = make([]impersonationRequestResponse, 0, len(requests))
+	for _, req := range requests {
+		resp.Requests = append(resp.Requests, impersonationRequestResponse{
+			Method:    req.Method,
+			Path:      req.Path,
+			Status:    req.Status,
+			IP:        req.IPAddress,
+			UserAgent: req.UserAgent,
+			At:        req.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// EndImpersonation ends an impersonation before it expires and aborts the
+// requests still running on it.
+func (h *Handler) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireImpersonation(w) || !h.requireAuthService(w) {
+		return
+	}
+	admin, _ := auth.UserFromContext(r.Context())
+	imp, ok := h.loadImpersonation(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.authService.EndImpersonation(r.Context(), admin, imp.ID); err != nil {
+		http.Error(w, "failed to end impersonation", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) loadImpersonation(w http.ResponseWriter, r *http.Request) (*store.Impersonation, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid impersonation id", http.StatusBadRequest)
+		return nil, false
+	}
+	imp, err := h.store.Impersonations.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "failed to load impersonation", http.StatusInternalServerError)
+		return nil, false
+	}
+	if imp == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return imp, true
+}
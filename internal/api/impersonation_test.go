@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeImpersonationRepo struct {
+	store.ImpersonationRepository
+	grants   map[int64]store.Impersonation
+	requests []store.ImpersonationRequest
+}
+
+func (f *fakeImpersonationRepo) GetByID(_ context.Context, id int64) (*store.Impersonation, error) {
+	imp, ok := f.grants[id]
+	if !ok {
+		return nil, nil
+	}
+	return &imp, nil
+}
+
+func (f *fakeImpersonationRepo) ListRequests(context.Context, int64, int) ([]store.ImpersonationRequest, error) {
+	return f.requests, nil
+}
+
+func TestGetImpersonationReturnsAuditTrail(t *testing.T) {
+	now := time.Now()
+	repo := &fakeImpersonationRepo{
+		grants: map[int64]store.Impersonation{
+			4: {ID: 4, AdminEmail: "admin@example.com", UserID: 7, Scope: store.ImpersonationRead, Reason: "ticket 12", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		},
+		requests: []store.ImpersonationRequest{{ImpersonationID: 4, Method: "REPORT", Path: "/dav/calendars/2/", Status: 207, IPAddress: "198.51.100.9", CreatedAt: now}},
+	}
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{Impersonations: repo})
+
+	get := func(email, id string, imp *store.Impersonation) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/impersonations/"+id, nil)
+		ctx := auth.WithUser(req.Context(), &store.User{ID: 1, PrimaryEmail: email})
+		if imp != nil {
+			ctx = auth.WithImpersonation(ctx, imp)
+		}
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		rec := httptest.NewRecorder()
+		h.GetImpersonation(rec, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx)))
+		return rec
+	}
+
+	rec := get("admin@example.com", "4", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetImpersonation() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp impersonationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !resp.Active || resp.Token != "" || len(resp.Requests) != 1 || resp.Requests[0].Status != 207 {
+		t.Fatalf("GetImpersonation() = %+v", resp)
+	}
+
+	if rec := get("admin@example.com", "5", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown impersonation status = %d, want 404", rec.Code)
+	}
+	if rec := get("user@example.com", "4", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want 403", rec.Code)
+	}
+	// An admin impersonating another admin does not get admin rights.
+	if rec := get("admin@example.com", "4", &store.Impersonation{ID: 9}); rec.Code != http.StatusForbidden {
+		t.Fatalf("impersonated admin status = %d, want 403", rec.Code)
+	}
+}
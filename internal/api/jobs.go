@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/jobs"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultJobLimit = 20
+	maxJobLimit     = 100
+)
+
+type jobResponse struct {
+	ID         string               `json:"id"`
+	Kind       string               `json:"kind"`
+	Status     string               `json:"status"`
+	Total      int                  `json:"total"`
+	Processed  int                  `json:"processed"`
+	Errors     []store.JobItemError `json:"errors"`
+	Result     any                  `json:"result,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	CreatedAt  string               `json:"createdAt"`
+	UpdatedAt  string               `json:"updatedAt"`
+	FinishedAt *string              `json:"finishedAt,omitempty"`
+}
+
+type calendarImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+// SetJobs attaches the runner that runs imports and backups in the
+// background; nil leaves the job endpoints unavailable.
+func (h *Handler) SetJobs(runner *jobs.Runner) {
+	h.jobs = runner
+}
+
+func (h *Handler) requireJobs(w http.ResponseWriter) bool {
+	if h.jobs == nil || h.store == nil || h.store.Jobs == nil || h.store.JobJournal == nil {
+		http.Error(w, "background jobs are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListJobs returns the caller's most recent jobs, newest first, up to
+// `limit` (default 20).
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireJobs(w) {
+		return
+	}
+	limit := defaultJobLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(v, maxJobLimit)
+	}
+	list, err := h.store.Jobs.ListByUser(r.Context(), user.ID, limit)
+	if err != nil {
+		http.Error(w, "failed to load jobs", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]jobResponse, 0, len(list))
+	for _, job := range list {
+		resp = append(resp, toJobResponse(job))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetJob reports one of the caller's jobs: its progress, the items that
+// failed, and once finished its result or error.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	_, job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toJobResponse(*job))
+}
+
+// ImportCalendar serves POST /api/calendars/{id}/import. The body is an ICS
+// file whose events are created, or replace the calendar's events with the
+// same UID, in a background job; the response is 202 with the job to poll.
+// The events are journaled before any is written, so an import the server
+// restarted in the middle of can be resumed with ResumeJob.
+func (h *Handler) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok || !h.requireJobs(w) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, events.MaxBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > events.MaxBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := h.events.GetCalendar(r.Context(), user, calendarID); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	objects, err := events.SplitImport(string(body))
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	job, err := h.jobs.Start(r.Context(), user.ID, jobs.KindCalendarImport, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		entries := make([]store.JournalEntry, len(objects))
+		for i, object := range objects {
+			entries[i] = store.JournalEntry{Seq: i + 1, CollectionID: calendarID, Payload: object}
+		}
+		if err := h.store.JobJournal.Append(ctx, p.ID(), entries); err != nil {
+			return nil, fmt.Errorf("failed to journal import: %w", err)
+		}
+		p.SetTotal(len(objects))
+		return h.applyCalendarImport(ctx, user, p, entries)
+	})
+	if err != nil {
+		http.Error(w, "failed to start import", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, toJobResponse(*job))
+}
+
+// applyCalendarImport writes the journaled events in order, marking each
+// applied once written or failed. Importing replaces events by UID, so an
+// event written just before a crash is written again harmlessly on resume.
+// The journal is discarded when the job finishes or is aborted.
+func (h *Handler) applyCalendarImport(ctx context.Context, user *store.User, p *jobs.Progress, entries []store.JournalEntry) (calendarImportResult, error) {
+	var result calendarImportResult
+	// Bookkeeping still runs after an abort cancels ctx.
+	keep := context.WithoutCancel(ctx)
+	// A journal left behind is deleted with its job.
+	defer h.store.JobJournal.Discard(keep, p.ID())
+	ctx = store.WithActor(ctx, user.ID)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		uid, created, err := h.events.ImportEvent(ctx, user, entry.CollectionID, entry.Payload)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return result, ctx.Err()
+		case err != nil:
+			result.Failed++
+			item := uid
+			if item == "" {
+				item = fmt.Sprintf("event %d", entry.Seq)
+			}
+			p.ItemFailed(item, err)
+		case created:
+			result.Created++
+			p.Advance()
+		default:
+			result.Updated++
+			p.Advance()
+		}
+		if err := h.store.JobJournal.MarkApplied(keep, p.ID(), entry.Seq); err != nil {
+			return result, fmt.Errorf("failed to update import journal: %w", err)
+		}
+	}
+	if result.Failed > 0 && result.Created+result.Updated == 0 {
+		return result, errors.New("no events could be imported")
+	}
+	return result, nil
+}
+
+// ResumeJob continues an import the server was restarted in the middle of,
+// from the first event it had not written. Its result counts the events
+// written after resuming.
+func (h *Handler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	user, job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Kind != jobs.KindCalendarImport || job.Status != store.JobInterrupted {
+		http.Error(w, "only interrupted imports can be resumed", http.StatusConflict)
+		return
+	}
+	pending, err := h.store.JobJournal.ListPending(r.Context(), job.ID)
+	if err != nil {
+		http.Error(w, "failed to load import journal", http.StatusInternalServerError)
+		return
+	}
+	resumed, err := h.jobs.Resume(r.Context(), *job, func(ctx context.Context, p *jobs.Progress) (any, error) {
+		return h.applyCalendarImport(ctx, user, p, pending)
+	})
+	if errors.Is(err, jobs.ErrNotResumable) {
+		http.Error(w, "only interrupted imports can be resumed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to resume job", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, toJobResponse(*resumed))
+}
+
+// AbortJob stops one of the caller's jobs. A running job stops after the
+// item it is on and is then reported as aborted; an interrupted one is
+// marked aborted at once and its journal discarded. What was already
+// written stays.
+func (h *Handler) AbortJob(w http.ResponseWriter, r *http.Request) {
+	_, job, ok := h.ownJob(w, r)
+	if !ok {
+		return
+	}
+	switch {
+	case job.Status == store.JobRunning && h.jobs.Abort(job.ID):
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, toJobResponse(*job))
+	case job.Status == store.JobInterrupted:
+		finished := time.Now().UTC()
+		job.Status, job.Error, job.FinishedAt = store.JobAborted, "aborted", &finished
+		if err := h.store.Jobs.Update(r.Context(), *job); err != nil {
+			http.Error(w, "failed to abort job", http.StatusInternalServerError)
+			return
+		}
+		if err := h.store.JobJournal.Discard(r.Context(), job.ID); err != nil {
+			http.Error(w, "failed to discard import journal", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, toJobResponse(*job))
+	default:
+		http.Error(w, "job is not running", http.StatusConflict)
+	}
+}
+
+// ownJob loads the caller's job named in the URL, writing the error
+// response when there is none.
+func (h *Handler) ownJob(w http.ResponseWriter, r *http.Request) (*store.User, *store.Job, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	if !h.requireJobs(w) {
+		return nil, nil, false
+	}
+	job, err := h.store.Jobs.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "failed to load job", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if job == nil || job.UserID != user.ID {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return user, job, true
+}
+
+func toJobResponse(job store.Job) jobResponse {
+	resp := jobResponse{
+		ID:         job.ID,
+		Kind:       job.Kind,
+		Status:     job.Status,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Errors:     job.Errors,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:  job.UpdatedAt.UTC().Format(time.RFC3339),
+		FinishedAt: formatOptionalTime(job.FinishedAt),
+	}
+	if resp.Errors == nil {
+		resp.Errors = []store.JobItemError{}
+	}
+	if len(job.Result) > 0 {
+		resp.Result = job.Result
+	}
+	return resp
+}
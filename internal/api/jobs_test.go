@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/jobs"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeJobRepo struct {
+	store.JobRepository
+	mu   sync.Mutex
+	jobs map[string]store.Job
+}
+
+func (f *fakeJobRepo) Create(_ context.Context, job store.Job) (*store.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	f.jobs[job.ID] = job
+	return &job, nil
+}
+
+func (f *fakeJobRepo) Update(_ context.Context, job store.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs[job.ID] = job
+	return nil
+}
+
+func (f *fakeJobRepo) GetByID(_ context.Context, id string) (*store.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+type fakeJobJournalRepo struct {
+	store.JobJournalRepository
+	mu      sync.Mutex
+	entries map[string][]store.JournalEntry
+}
+
+func (f *fakeJobJournalRepo) Append(_ context.Context, jobID string, entries []store.JournalEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range entries {
+		e.JobID = jobID
+		f.entries[jobID] = append(f.entries[jobID], e)
+	}
+	return nil
+}
+
+func (f *fakeJobJournalRepo) ListPending(_ context.Context, jobID string) ([]store.JournalEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var pending []store.JournalEntry
+	for _, e := range f.entries[jobID] {
+		if e.AppliedAt == nil {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeJobJournalRepo) MarkApplied(_ context.Context, jobID string, seq int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for i := range f.entries[jobID] {
+		if f.entries[jobID][i].Seq == seq {
+			f.entries[jobID][i].AppliedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeJobJournalRepo) Discard(_ context.Context, jobID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.entries, jobID)
+	return nil
+}
+
+func TestImportCalendarRunsAsJob(t *testing.T) {
+	repo := &fakeJobRepo{jobs: map[string]store.Job{}}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{
+		key(1, "existing"): {CalendarID: 1, UID: "existing", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events:     eventRepo,
+		Jobs:       repo,
+		JobJournal: &fakeJobJournalRepo{entries: map[string][]store.JournalEntry{}},
+	})
+	runner := jobs.NewRunner(repo, nil)
+	h.SetJobs(runner)
+
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:existing\r\nDTSTAMP:20260301T090000Z\r\nDTSTART:20260302T090000Z\r\nSUMMARY:Updated\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:new\r\nDTSTAMP:20260301T090000Z\r\nDTSTART:20260303T090000Z\r\nSUMMARY:New\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:broken\r\nDTSTAMP:20260301T090000Z\r\nDTSTART:20260304T090000Z\r\nSUMMARY:Unsupported part\r\nBEGIN:VLOCATION\r\nUID:room\r\nEND:VLOCATION\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	req := withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/calendars/1/import", strings.NewReader(ics)), "1", "")
+	rec := httptest.NewRecorder()
+	h.ImportCalendar(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var started jobResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if started.Kind != jobs.KindCalendarImport || started.Status != store.JobRunning || rec.Header().Get("Location") != "/api/jobs/"+started.ID {
+		t.Fatalf("unexpected started job %+v, Location %q", started, rec.Header().Get("Location"))
+	}
+	runner.Wait()
+
+	get := func(id string, userID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: userID}))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+		rec := httptest.NewRecorder()
+		h.GetJob(rec, req)
+		return rec
+	}
+	rec = get(started.ID, 1)
+	var done jobResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &done); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if done.Status != store.JobSucceeded || done.Total != 3 || done.Processed != 3 || done.FinishedAt == nil {
+		t.Fatalf("unexpected finished job %+v", done)
+	}
+	if len(done.Errors) != 1 || done.Errors[0].Item != "broken" {
+		t.Fatalf("item errors = %+v, want one for broken", done.Errors)
+	}
+	var result calendarImportResult
+	raw, _ := json.Marshal(done.Result)
+	if err := json.Unmarshal(raw, &result); err != nil || result != (calendarImportResult{Created: 1, Updated: 1, Failed: 1}) {
+		t.Fatalf("result = %s, want 1 created, 1 updated, 1 failed", raw)
+	}
+	if !strings.Contains(eventRepo.events[key(1, "existing")].RawICAL, "SUMMARY:Updated") {
+		t.Fatal("existing event was not replaced")
+	}
+
+	if rec := get(started.ID, 2); rec.Code != http.StatusNotFound {
+		t.Fatalf("other user's job status = %d, want 404", rec.Code)
+	}
+
+	req = withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/calendars/1/import", strings.NewReader("not a calendar")), "1", "")
+	rec = httptest.NewRecorder()
+	h.ImportCalendar(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid file status = %d", rec.Code)
+	}
+}
+
+func TestResumeAndAbortInterruptedImport(t *testing.T) {
+	applied := time.Now()
+	repo := &fakeJobRepo{jobs: map[string]store.Job{
+		"crashed": {ID: "crashed", UserID: 1, Kind: jobs.KindCalendarImport, Status: store.JobInterrupted, Total: 2, Processed: 1},
+		"stale":   {ID: "stale", UserID: 1, Kind: jobs.KindCalendarImport, Status: store.JobInterrupted, Total: 1},
+		"done":    {ID: "done", UserID: 1, Kind: jobs.KindCalendarImport, Status: store.JobSucceeded},
+	}}
+	journal := &fakeJobJournalRepo{entries: map[string][]store.JournalEntry{
+		"crashed": {
+			{JobID: "crashed", Seq: 1, CollectionID: 1, Payload: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:first\r\nDTSTAMP:20260301T090000Z\r\nDTSTART:20260302T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", AppliedAt: &applied},
+			{JobID: "crashed", Seq: 2, CollectionID: 1, Payload: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:second\r\nDTSTAMP:20260301T090000Z\r\nDTSTART:20260303T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		},
+		"stale": {{JobID: "stale", Seq: 1, CollectionID: 1, Payload: "unused"}},
+	}}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events:     eventRepo,
+		Jobs:       repo,
+		JobJournal: journal,
+	})
+	runner := jobs.NewRunner(repo, nil)
+	h.SetJobs(runner)
+	post := func(id, action string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs/"+id+"/"+action, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := post("done", "resume", h.ResumeJob); rec.Code != http.StatusConflict {
+		t.Fatalf("resuming a finished job status = %d, want 409", rec.Code)
+	}
+	if rec := post("crashed", "resume", h.ResumeJob); rec.Code != http.StatusAccepted {
+		t.Fatalf("resume status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	runner.Wait()
+	if _, ok := eventRepo.events[key(1, "first")]; ok {
+		t.Fatal("resume rewrote an event the journal marked applied")
+	}
+	if _, ok := eventRepo.events[key(1, "second")]; !ok {
+		t.Fatal("resume did not write the pending event")
+	}
+	if job := repo.jobs["crashed"]; job.Status != store.JobSucceeded || job.Processed != 2 || len(journal.entries["crashed"]) != 0 {
+		t.Fatalf("resumed job = %+v, journal %+v", job, journal.entries["crashed"])
+	}
+
+	if rec := post("stale", "abort", h.AbortJob); rec.Code != http.StatusOK {
+		t.Fatalf("abort status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if job := repo.jobs["stale"]; job.Status != store.JobAborted || job.FinishedAt == nil || len(journal.entries["stale"]) != 0 {
+		t.Fatalf("aborted job = %+v, journal %+v", job, journal.entries["stale"])
+	}
+	if rec := post("stale", "abort", h.AbortJob); rec.Code != http.StatusConflict {
+		t.Fatalf("aborting an aborted job status = %d, want 409", rec.Code)
+	}
+}
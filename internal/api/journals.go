@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+)
+
+type journalResponse struct {
+	CalendarID   int64    `json:"calendarId"`
+	UID          string   `json:"uid"`
+	Date         *string  `json:"date"`
+	Summary      string   `json:"summary"`
+	Description  string   `json:"description"`
+	Status       string   `json:"status,omitempty"`
+	Categories   []string `json:"categories"`
+	ETag         string   `json:"etag"`
+	LastModified string   `json:"lastModified"`
+}
+
+// ListJournals returns a calendar's VJOURNAL entries, newest day first. The
+// optional from and to query parameters are inclusive YYYY-MM-DD days, and
+// date is shorthand for both. UTC start times are dated in the user's time
+// zone.
+func (h *Handler) ListJournals(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := events.JournalFilter{
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		Location: locale.ForUser(user).Location,
+	}
+	if date := query.Get("date"); date != "" {
+		filter.From, filter.To = date, date
+	}
+	journals, err := h.events.ListJournals(r.Context(), user, calendarID, filter)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]journalResponse, 0, len(journals))
+	for _, j := range journals {
+		resp = append(resp, toJournalResponse(j))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateJournal adds a dated journal entry to a calendar.
+func (h *Handler) CreateJournal(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var input events.JournalInput
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	journal, err := h.events.CreateJournal(r.Context(), user, calendarID, input)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+journal.ETag+`"`)
+	writeJSON(w, http.StatusCreated, toJournalResponse(*journal))
+}
+
+func toJournalResponse(j events.Journal) journalResponse {
+	categories := j.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	return journalResponse{
+		CalendarID:   j.CalendarID,
+		UID:          j.UID,
+		Date:         optionalString(j.Date),
+		Summary:      j.Summary,
+		Description:  j.Description,
+		Status:       j.Status,
+		Categories:   categories,
+		ETag:         j.ETag,
+		LastModified: j.LastModified.UTC().Format(time.RFC3339),
+	}
+}
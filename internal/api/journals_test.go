@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestJournals(t *testing.T) {
+	journal := func(uid, dtstart string) string {
+		return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VJOURNAL\r\nUID:" + uid + "\r\nDTSTART" + dtstart + "\r\nSUMMARY:" + uid + "\r\nEND:VJOURNAL\r\nEND:VCALENDAR\r\n"
+	}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{
+		"1:dated":    {CalendarID: 1, UID: "dated", ResourceName: "dated", RawICAL: journal("dated", ";VALUE=DATE:20260320"), ETag: "e1"},
+		"1:late-utc": {CalendarID: 1, UID: "late-utc", ResourceName: "late-utc", RawICAL: journal("late-utc", ":20260320T230000Z"), ETag: "e2"},
+		"1:meeting":  {CalendarID: 1, UID: "meeting", ResourceName: "meeting", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:meeting\r\nDTSTART:20260320T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e3"},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Notes"}, Editor: true},
+		}},
+		Events:     eventRepo,
+		ACLEntries: &fakeACLRepo{},
+	})
+
+	list := func(query string) []journalResponse {
+		t.Helper()
+		req := withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/journals"+query, nil), "1", "")
+		rec := httptest.NewRecorder()
+		h.ListJournals(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ListJournals(%q) status = %d body=%s", query, rec.Code, rec.Body.String())
+		}
+		var resp []journalResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return resp
+	}
+
+	// The user's time zone is UTC, so the late UTC entry stays on the 20th.
+	if got := list("?date=2026-03-20"); len(got) != 2 {
+		t.Fatalf("ListJournals(date) = %+v, want 2 entries", got)
+	}
+	if got := list("?from=2026-03-21"); len(got) != 0 {
+		t.Fatalf("ListJournals(from) = %+v, want none", got)
+	}
+
+	req := withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/calendars/1/journals", strings.NewReader(`{"date":"2026-03-21","summary":"Retro","description":"Went well","categories":["work"]}`)), "1", "")
+	rec := httptest.NewRecorder()
+	h.CreateJournal(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateJournal() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var created journalResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if created.Date == nil || *created.Date != "2026-03-21" || created.Summary != "Retro" || created.Status != "FINAL" || len(created.Categories) != 1 {
+		t.Fatalf("unexpected created entry: %+v", created)
+	}
+	if got := list("?from=2026-03-21&to=2026-03-21"); len(got) != 1 || got[0].UID != created.UID {
+		t.Fatalf("ListJournals() after create = %+v", got)
+	}
+
+	for _, body := range []string{`{"date":"21/03/2026","summary":"x"}`, `{"date":"2026-03-21"}`} {
+		req := withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/calendars/1/journals", strings.NewReader(body)), "1", "")
+		rec := httptest.NewRecorder()
+		h.CreateJournal(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("CreateJournal(%s) status = %d, want 400", body, rec.Code)
+		}
+	}
+}
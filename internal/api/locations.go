@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type locationRequest struct {
+	Name      string   `json:"name"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+type locationResponse struct {
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type locationSuggestionResponse struct {
+	Name string `json:"name"`
+	// LocationID is set for saved places.
+	LocationID int64 `json:"locationId,omitempty"`
+}
+
+// ListLocations returns the caller's saved places.
+func (h *Handler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	locs, err := h.events.ListLocations(r.Context(), user)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]locationResponse, 0, len(locs))
+	for _, loc := range locs {
+		resp = append(resp, locationResponseFor(loc))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateLocation saves a place for reuse in events.
+func (h *Handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	req, ok := decodeLocationRequest(w, r)
+	if !ok {
+		return
+	}
+	loc, err := h.events.CreateLocation(r.Context(), user, events.LocationInput(req))
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, locationResponseFor(*loc))
+}
+
+// UpdateLocation replaces the details of a saved place.
+func (h *Handler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	id, ok := parseLocationID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeLocationRequest(w, r)
+	if !ok {
+		return
+	}
+	loc, err := h.events.UpdateLocation(r.Context(), user, id, events.LocationInput(req))
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, locationResponseFor(*loc))
+}
+
+// DeleteLocation removes a saved place. Events keep the location they were
+// given.
+func (h *Handler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	id, ok := parseLocationID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.events.DeleteLocation(r.Context(), user, id); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SuggestLocations serves GET /api/locations/suggest?q=: saved places and
+// past event locations matching q, for autocomplete.
+func (h *Handler) SuggestLocations(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	suggestions, err := h.events.SuggestLocations(r.Context(), user, r.URL.Query().Get("q"))
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]locationSuggestionResponse, 0, len(suggestions))
+	for _, s := range suggestions {
+		resp = append(resp, locationSuggestionResponse{Name: s.Name, LocationID: s.LocationID})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func decodeLocationRequest(w http.ResponseWriter, r *http.Request) (locationRequest, bool) {
+	var req locationRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func parseLocationID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid location id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func locationResponseFor(loc store.Location) locationResponse {
+	return locationResponse{
+		ID:        loc.ID,
+		Name:      loc.Name,
+		Address:   loc.Address,
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		UpdatedAt: loc.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
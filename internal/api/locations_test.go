@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeLocationRepo struct {
+	locations map[int64]*store.Location
+	history   []string
+}
+
+func (f *fakeLocationRepo) Create(ctx context.Context, loc store.Location) (*store.Location, error) {
+	for _, existing := range f.locations {
+		if existing.UserID == loc.UserID && existing.Name == loc.Name {
+			return nil, store.ErrConflict
+		}
+	}
+	loc.ID = int64(len(f.locations) + 1)
+	f.locations[loc.ID] = &loc
+	created := loc
+	return &created, nil
+}
+
+func (f *fakeLocationRepo) GetByID(ctx context.Context, id int64) (*store.Location, error) {
+	if loc, ok := f.locations[id]; ok {
+		copy := *loc
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeLocationRepo) ListByUser(ctx context.Context, userID int64) ([]store.Location, error) {
+	var locs []store.Location
+	for _, loc := range f.locations {
+		if loc.UserID == userID {
+			locs = append(locs, *loc)
+		}
+	}
+	return locs, nil
+}
+
+func (f *fakeLocationRepo) Update(ctx context.Context, loc store.Location) (*store.Location, error) {
+	existing, ok := f.locations[loc.ID]
+	if !ok || existing.UserID != loc.UserID {
+		return nil, store.ErrNotFound
+	}
+	*existing = loc
+	updated := loc
+	return &updated, nil
+}
+
+func (f *fakeLocationRepo) Delete(ctx context.Context, userID, id int64) error {
+	if loc, ok := f.locations[id]; ok && loc.UserID == userID {
+		delete(f.locations, id)
+	}
+	return nil
+}
+
+func (f *fakeLocationRepo) History(ctx context.Context, userID int64, query string, limit int) ([]string, error) {
+	var out []string
+	for _, h := range f.history {
+		if strings.Contains(strings.ToLower(h), strings.ToLower(query)) {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func TestLocationsCRUDSuggestAndUseInEvent(t *testing.T) {
+	locations := &fakeLocationRepo{locations: map[int64]*store.Location{
+		9: {ID: 9, UserID: 2, Name: "Someone else's office"},
+	}, history: []string{"Café Central", "Office kitchen"}}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{}}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events:    eventRepo,
+		Locations: locations,
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := withUserAndRoute(httptest.NewRequest(http.MethodPost, "/api/locations", strings.NewReader(body)), "", "")
+		rec := httptest.NewRecorder()
+		handler.CreateLocation(rec, req)
+		return rec
+	}
+	if rec := post(`{"name":"HQ","latitude":52.5}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("latitude without longitude status = %d, want 400", rec.Code)
+	}
+	rec := post(`{"name":"Office","address":"Unter den Linden 1, Berlin","latitude":52.5163,"longitude":13.3777}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var created locationResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec := post(`{"name":"Office"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate name status = %d, want 409", rec.Code)
+	}
+
+	req := withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/locations/9", strings.NewReader(`{"name":"Mine now"}`)), "9", "")
+	rec = httptest.NewRecorder()
+	handler.UpdateLocation(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("update of another user's place status = %d, want 404", rec.Code)
+	}
+
+	req = withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/locations/suggest?q=off", nil), "", "")
+	rec = httptest.NewRecorder()
+	handler.SuggestLocations(rec, req)
+	var suggestions []locationSuggestionResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &suggestions)
+	if len(suggestions) != 2 || suggestions[0] != (locationSuggestionResponse{Name: "Office", LocationID: created.ID}) || suggestions[1].Name != "Office kitchen" {
+		t.Fatalf("suggestions = %+v", suggestions)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/calendars/1/events", strings.NewReader(`{
+		"inputMode":"structured",
+		"structured":{"summary":"Planning","dtstart":"2026-03-20T10:00","dtend":"2026-03-20T11:00","location":"typed","locationId":`+strconv.FormatInt(created.ID, 10)+`}
+	}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = withUserAndRoute(req, "1", "")
+	rec = httptest.NewRecorder()
+	handler.CreateEvent(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateEvent() status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	for _, ev := range eventRepo.events {
+		for _, want := range []string{
+			`LOCATION:Office\nUnter den Linden 1\, Berlin`,
+			"GEO:52.5163;13.3777",
+			`X-APPLE-STRUCTURED-LOCATION;VALUE=URI;X-ADDRESS="Unter den Linden 1, Berlin";X-APPLE-RADIUS=70;X-TITLE="Office":geo:52.5163,13.3777`,
+		} {
+			if !strings.Contains(ev.RawICAL, want+"\r\n") {
+				t.Errorf("event missing %q:\n%s", want, ev.RawICAL)
+			}
+		}
+		if strings.Contains(ev.RawICAL, "LOCATION:typed") {
+			t.Errorf("expected the saved place to replace the typed location:\n%s", ev.RawICAL)
+		}
+	}
+}
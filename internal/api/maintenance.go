@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/maintenance"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultMaintenanceDuration = time.Hour
+	maxMaintenanceDuration     = 24 * time.Hour
+	maxMaintenanceReasonLength = 500
+)
+
+type startMaintenanceRequest struct {
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"`
+}
+
+type maintenanceResponse struct {
+	CollectionType string `json:"collectionType"`
+	CollectionID   int64  `json:"collectionId"`
+	Reason         string `json:"reason"`
+	StartedBy      string `json:"startedBy"`
+	StartedAt      string `json:"startedAt"`
+	ExpiresAt      string `json:"expiresAt"`
+}
+
+func newMaintenanceResponse(m store.CollectionMaintenance) maintenanceResponse {
+	return maintenanceResponse{
+		CollectionType: m.CollectionType,
+		CollectionID:   m.CollectionID,
+		Reason:         m.Reason,
+		StartedBy:      m.StartedBy,
+		StartedAt:      m.StartedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:      m.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (h *Handler) requireMaintenance(w http.ResponseWriter) bool {
+	if h.store == nil || h.store.Maintenance == nil {
+		http.Error(w, "maintenance mode is not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// ListMaintenance returns the collections in maintenance, oldest first.
+func (h *Handler) ListMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireMaintenance(w) {
+		return
+	}
+	list, err := h.store.Maintenance.ListActive(r.Context())
+	if err != nil {
+		http.Error(w, "failed to load maintenance", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]maintenanceResponse, 0, len(list))
+	for _, m := range list {
+		resp = append(resp, newMaintenanceResponse(m))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// StartMaintenance puts a calendar or address book into maintenance for
+// `duration` (default 1h), or changes the reason and expiry of maintenance
+// already running on it. Clients can still read the collection, but their
+// writes are refused with 503 and the reason until it ends.
+func (h *Handler) StartMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireMaintenance(w) {
+		return
+	}
+	admin, _ := auth.UserFromContext(r.Context())
+	collectionType, collectionID, ok := h.loadMaintenanceCollection(w, r)
+	if !ok {
+		return
+	}
+	var req startMaintenanceRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || len(reason) > maxMaintenanceReasonLength {
+		http.Error(w, "a reason of at most 500 bytes is required", http.StatusBadRequest)
+		return
+	}
+	duration := defaultMaintenanceDuration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 || duration > maxMaintenanceDuration {
+			http.Error(w, "duration must be positive and at most 24h", http.StatusBadRequest)
+			return
+		}
+	}
+	m, err := h.store.Maintenance.Start(r.Context(), store.CollectionMaintenance{
+		CollectionType: collectionType,
+		CollectionID:   collectionID,
+		Reason:         reason,
+		StartedBy:      admin.PrimaryEmail,
+		ExpiresAt:      time.Now().Add(duration),
+	})
+	if err != nil {
+		http.Error(w, "failed to start maintenance", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newMaintenanceResponse(*m))
+}
+
+// EndMaintenance lets clients write to a collection again.
+func (h *Handler) EndMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireMaintenance(w) {
+		return
+	}
+	collectionType, collectionID, ok := parseMaintenanceCollection(w, r)
+	if !ok {
+		return
+	}
+	ended, err := h.store.Maintenance.End(r.Context(), collectionType, collectionID)
+	if err != nil {
+		http.Error(w, "failed to end maintenance", http.StatusInternalServerError)
+		return
+	}
+	if !ended {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadMaintenanceCollection parses the collection from the URL and checks
+// that it exists.
+func (h *Handler) loadMaintenanceCollection(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	collectionType, collectionID, ok := parseMaintenanceCollection(w, r)
+	if !ok {
+		return "", 0, false
+	}
+	var found bool
+	var err error
+	if collectionType == maintenance.Calendar {
+		var cal *store.Calendar
+		cal, err = h.store.Calendars.GetByID(r.Context(), collectionID)
+		found = cal != nil
+	} else {
+		var book *store.AddressBook
+		book, err = h.store.AddressBooks.GetByID(r.Context(), collectionID)
+		found = book != nil
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "failed to load collection", http.StatusInternalServerError)
+		return "", 0, false
+	}
+	if !found {
+		http.Error(w, "not found", http.StatusNotFound)
+		return "", 0, false
+	}
+	return collectionType, collectionID, true
+}
+
+func parseMaintenanceCollection(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	collectionType := chi.URLParam(r, "type")
+	if collectionType != maintenance.Calendar && collectionType != maintenance.AddressBook {
+		http.Error(w, "type must be calendar or addressbook", http.StatusBadRequest)
+		return "", 0, false
+	}
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || collectionID <= 0 {
+		http.Error(w, "invalid collection id", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return collectionType, collectionID, true
+}
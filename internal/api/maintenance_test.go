@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestMaintenanceRefusesWritesUntilEnded(t *testing.T) {
+	running := &storetest.Maintenance{}
+	eventRepo := &fakeEventRepo{events: map[string]store.Event{}}
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{
+		Calendars: &fakeCalendarRepo{
+			calendars: map[int64]*store.CalendarAccess{
+				1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			},
+		},
+		Events:      eventRepo,
+		Maintenance: running,
+	})
+	admin := func(method, collectionType, id, body string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/maintenance/"+collectionType+"/"+id, strings.NewReader(body))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("type", collectionType)
+		routeCtx.URLParams.Add("id", id)
+		ctx := auth.WithUser(req.Context(), &store.User{ID: 9, PrimaryEmail: "admin@example.com"})
+		rec := httptest.NewRecorder()
+		handle(rec, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx)))
+		return rec
+	}
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/calendars/1/events", strings.NewReader(`{"inputMode":"structured","structured":{"summary":"Planning","dtstart":"2026-03-20T10:00","dtend":"2026-03-20T11:00"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.CreateEvent(rec, withUserAndRoute(req, "1", ""))
+		return rec
+	}
+
+	if rec := admin(http.MethodPut, "calendar", "1", `{"reason":"merging calendars","duration":"48h"}`, h.StartMaintenance); rec.Code != http.StatusBadRequest {
+		t.Fatalf("too long maintenance status = %d, want 400", rec.Code)
+	}
+	if rec := admin(http.MethodPut, "calendar", "2", `{"reason":"merging calendars"}`, h.StartMaintenance); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown calendar status = %d, want 404", rec.Code)
+	}
+	rec := admin(http.MethodPut, "calendar", "1", `{"reason":"merging calendars","duration":"30m"}`, h.StartMaintenance)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("StartMaintenance() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var started maintenanceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil || started.StartedBy != "admin@example.com" || started.CollectionID != 1 {
+		t.Fatalf("StartMaintenance() = %+v, %v", started, err)
+	}
+
+	rec = create()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "300" || !strings.Contains(rec.Body.String(), "merging calendars") {
+		t.Fatalf("write during maintenance = %d %q (Retry-After %q)", rec.Code, rec.Body.String(), rec.Header().Get("Retry-After"))
+	}
+	if len(eventRepo.events) != 0 {
+		t.Fatalf("write during maintenance stored %d events", len(eventRepo.events))
+	}
+
+	if rec := admin(http.MethodDelete, "calendar", "1", "", h.EndMaintenance); rec.Code != http.StatusNoContent {
+		t.Fatalf("EndMaintenance() status = %d", rec.Code)
+	}
+	if rec := admin(http.MethodDelete, "calendar", "1", "", h.EndMaintenance); rec.Code != http.StatusNotFound {
+		t.Fatalf("EndMaintenance() twice status = %d, want 404", rec.Code)
+	}
+	if rec := create(); rec.Code != http.StatusCreated {
+		t.Fatalf("write after maintenance = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/notify"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type notificationListResponse struct {
+	// EmailEnabled is false when the server cannot send email, so followed
+	// collections send nothing.
+	EmailEnabled bool                   `json:"emailEnabled"`
+	Following    []notificationResponse `json:"following"`
+}
+
+type notificationResponse struct {
+	CollectionType string     `json:"collectionType"`
+	CollectionID   int64      `json:"collectionId"`
+	LastNotifiedAt *time.Time `json:"lastNotifiedAt"`
+}
+
+// ListNotifications returns the calendars and address books the user gets
+// change emails for.
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	subs, err := h.store.Notifications.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load notifications", http.StatusInternalServerError)
+		return
+	}
+	resp := notificationListResponse{EmailEnabled: h.notify.Enabled(), Following: []notificationResponse{}}
+	for _, sub := range subs {
+		resp.Following = append(resp.Following, notificationResponseFor(sub))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// FollowCollection emails the user about later changes other users make
+// in a calendar or address book they can read.
+func (h *Handler) FollowCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	collectionType, collectionID, ok := parseFollowedCollection(w, r)
+	if !ok {
+		return
+	}
+	sub, err := h.notify.Follow(r.Context(), user, collectionType, collectionID)
+	if err != nil {
+		writeNotifyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notificationResponseFor(*sub))
+}
+
+// UnfollowCollection stops change emails for a calendar or address book.
+func (h *Handler) UnfollowCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	collectionType, collectionID, ok := parseFollowedCollection(w, r)
+	if !ok {
+		return
+	}
+	if err := h.notify.Unfollow(r.Context(), user, collectionType, collectionID); err != nil {
+		writeNotifyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseFollowedCollection(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid collection id", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return chi.URLParam(r, "type"), id, true
+}
+
+func writeNotifyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notify.ErrInvalidType):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, notify.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "failed to update notifications", http.StatusInternalServerError)
+	}
+}
+
+func notificationResponseFor(sub store.CollectionNotification) notificationResponse {
+	return notificationResponse{
+		CollectionType: sub.CollectionType,
+		CollectionID:   sub.CollectionID,
+		LastNotifiedAt: sub.LastNotifiedAt,
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeNotificationRepo struct {
+	store.CollectionNotificationRepository
+	subs []store.CollectionNotification
+}
+
+func (f *fakeNotificationRepo) ListByUser(_ context.Context, userID int64) ([]store.CollectionNotification, error) {
+	var out []store.CollectionNotification
+	for _, sub := range f.subs {
+		if sub.UserID == userID {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNotificationRepo) Subscribe(_ context.Context, n store.CollectionNotification) (*store.CollectionNotification, error) {
+	f.subs = append(f.subs, n)
+	return &n, nil
+}
+
+func (f *fakeNotificationRepo) Unsubscribe(_ context.Context, userID int64, collectionType string, collectionID int64) error {
+	var kept []store.CollectionNotification
+	for _, sub := range f.subs {
+		if sub.UserID != userID || sub.CollectionType != collectionType || sub.CollectionID != collectionID {
+			kept = append(kept, sub)
+		}
+	}
+	f.subs = kept
+	return nil
+}
+
+func TestFollowAndUnfollowCollection(t *testing.T) {
+	repo := &fakeNotificationRepo{}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Notifications: repo,
+		Changes:       &fakeChangeRepo{latest: store.ChangeCursor{TxID: 12, Seq: 3}},
+		Calendars:     &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{5: {Calendar: store.Calendar{ID: 5, UserID: 1, Name: "Family"}}}},
+		AddressBooks:  &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}},
+	})
+	serve := func(method, collectionType, id string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/preferences/notifications/"+collectionType+"/"+id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("type", collectionType)
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		req = req.WithContext(auth.WithUser(ctx, &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	for _, tc := range []struct {
+		collectionType, id string
+		want               int
+	}{{"calendar", "x", http.StatusBadRequest}, {"tasks", "5", http.StatusBadRequest}, {"calendar", "6", http.StatusNotFound}} {
+		if rec := serve(http.MethodPut, tc.collectionType, tc.id, h.FollowCollection); rec.Code != tc.want {
+			t.Errorf("PUT %s/%s = %d, want %d", tc.collectionType, tc.id, rec.Code, tc.want)
+		}
+	}
+	rec := serve(http.MethodPut, "calendar", "5", h.FollowCollection)
+	if rec.Code != http.StatusOK || len(repo.subs) != 1 || repo.subs[0].Cursor != (store.ChangeCursor{TxID: 12, Seq: 3}) {
+		t.Fatalf("PUT calendar/5 = %d %s, stored %+v", rec.Code, rec.Body.String(), repo.subs)
+	}
+
+	rec = serve(http.MethodGet, "", "0", h.ListNotifications)
+	var list notificationListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+	}
+	if list.EmailEnabled || len(list.Following) != 1 || list.Following[0].CollectionID != 5 || list.Following[0].CollectionType != "calendar" {
+		t.Fatalf("GET = %+v", list)
+	}
+
+	if rec := serve(http.MethodDelete, "calendar", "5", h.UnfollowCollection); rec.Code != http.StatusNoContent || len(repo.subs) != 0 {
+		t.Fatalf("DELETE = %d, left %+v", rec.Code, repo.subs)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type preferencesRequest struct {
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	// WeekStart is 0 (Sunday) to 6; null follows the locale.
+	WeekStart *int `json:"weekStart"`
+}
+
+type preferencesResponse struct {
+	Locale    string `json:"locale"`
+	Timezone  string `json:"timezone"`
+	WeekStart *int   `json:"weekStart"`
+	// Effective are the preferences in use, with defaults filled in.
+	Effective effectivePreferences `json:"effective"`
+}
+
+type effectivePreferences struct {
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+	WeekStart   int    `json:"weekStart"`
+	WorkingDays []int  `json:"workingDays"`
+}
+
+// GetPreferences returns the user's regional preferences.
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	current, err := h.store.Users.GetByID(r.Context(), user.ID)
+	if err != nil || current == nil {
+		http.Error(w, "failed to load preferences", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, preferencesResponseFor(current.Locale, current.Timezone, current.WeekStart))
+}
+
+// UpdatePreferences replaces the user's regional preferences. Empty fields
+// fall back to the defaults.
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	var req preferencesRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var weekStart *time.Weekday
+	if req.WeekStart != nil {
+		day := time.Weekday(*req.WeekStart)
+		weekStart = &day
+	}
+	lang, timezone, err := locale.Normalize(req.Locale, req.Timezone, weekStart)
+	if err != nil {
+		http.Error(w, strings.TrimPrefix(err.Error(), locale.ErrInvalid.Error()+": "), http.StatusBadRequest)
+		return
+	}
+	if err := h.store.Users.SetPreferences(r.Context(), user.ID, lang, timezone, weekStart); err != nil {
+		http.Error(w, "failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, preferencesResponseFor(lang, timezone, weekStart))
+}
+
+func preferencesResponseFor(lang, timezone string, weekStart *time.Weekday) preferencesResponse {
+	resp := preferencesResponse{Locale: lang, Timezone: timezone}
+	if weekStart != nil {
+		d := int(*weekStart)
+		resp.WeekStart = &d
+	}
+	prefs := locale.ForUser(&store.User{Locale: lang, Timezone: timezone, WeekStart: weekStart})
+	resp.Effective = effectivePreferences{
+		Locale:    prefs.Tag.String(),
+		Timezone:  prefs.Location.String(),
+		WeekStart: int(prefs.WeekStart),
+	}
+	working := prefs.WorkingDays()
+	for _, d := range prefs.Weekdays() {
+		if working&(1<<d) != 0 {
+			resp.Effective.WorkingDays = append(resp.Effective.WorkingDays, int(d))
+		}
+	}
+	return resp
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestPreferencesRoundTripWithEffectiveDefaults(t *testing.T) {
+	users := &fakeUserRepo{users: map[int64]*store.User{1: {ID: 1}}}
+	h := NewHandler(&config.Config{}, &store.Store{Users: users})
+	serve := func(method, body string, handler http.HandlerFunc) (*httptest.ResponseRecorder, preferencesResponse) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/preferences", strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp preferencesResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec, resp
+	}
+
+	rec, resp := serve(http.MethodGet, "", h.GetPreferences)
+	if rec.Code != http.StatusOK || resp.Effective.Locale != "en-US" || resp.Effective.Timezone != "UTC" || resp.Effective.WeekStart != 0 {
+		t.Fatalf("default preferences = %d %+v", rec.Code, resp)
+	}
+
+	rec, resp = serve(http.MethodPut, `{"locale":"he-il","timezone":"Asia/Jerusalem"}`, h.UpdatePreferences)
+	if rec.Code != http.StatusOK || resp.Locale != "he-IL" || resp.WeekStart != nil {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	if got := resp.Effective.WorkingDays; len(got) != 5 || got[0] != 0 || got[4] != 4 {
+		t.Fatalf("Israeli working days = %v, want Sunday to Thursday", got)
+	}
+	if u := users.users[1]; u.Locale != "he-IL" || u.Timezone != "Asia/Jerusalem" {
+		t.Fatalf("stored %+v", u)
+	}
+
+	rec, resp = serve(http.MethodPut, `{"locale":"en-US","weekStart":1}`, h.UpdatePreferences)
+	if rec.Code != http.StatusOK || resp.Effective.WeekStart != 1 || resp.Effective.Timezone != "UTC" {
+		t.Fatalf("week start override = %d %+v", rec.Code, resp)
+	}
+
+	for _, body := range []string{`{"timezone":"Mars/Olympus"}`, `{"locale":"not a locale!"}`, `{"weekStart":7}`, `{"language":"en"}`} {
+		if rec, _ := serve(http.MethodPut, body, h.UpdatePreferences); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+type fakeDigestRepo struct {
+	store.DigestRepository
+	settings map[int64]store.DigestSettings
+}
+
+func (f *fakeDigestRepo) GetByUser(_ context.Context, userID int64) (*store.DigestSettings, error) {
+	settings, ok := f.settings[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &settings, nil
+}
+
+func (f *fakeDigestRepo) Upsert(_ context.Context, settings store.DigestSettings) (*store.DigestSettings, error) {
+	f.settings[settings.UserID] = settings
+	return &settings, nil
+}
+
+func (f *fakeDigestRepo) Delete(_ context.Context, userID int64) error {
+	delete(f.settings, userID)
+	return nil
+}
+
+func TestDigestScheduleRoundTrip(t *testing.T) {
+	digests := &fakeDigestRepo{settings: map[int64]store.DigestSettings{}}
+	h := NewHandler(&config.Config{}, &store.Store{Digests: digests})
+	serve := func(method, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/preferences/digest", strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := serve(http.MethodGet, "", h.GetDigest); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET without a schedule = %d, want 404", rec.Code)
+	}
+	rec := serve(http.MethodPut, `{"frequency":"Weekly","sendTime":"07:30","weekday":5}`, h.UpdateDigest)
+	var resp digestResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.Frequency != "weekly" || resp.SendTime != "07:30" || resp.Weekday != 5 {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body.String())
+	}
+	if got := digests.settings[1]; got.SendMinute != 450 || got.Weekday != time.Friday {
+		t.Fatalf("stored %+v", got)
+	}
+	for _, body := range []string{`{"frequency":"hourly","sendTime":"07:30"}`, `{"frequency":"daily","sendTime":"25:00"}`, `{"frequency":"weekly","sendTime":"07:30","weekday":9}`} {
+		if rec := serve(http.MethodPut, body, h.UpdateDigest); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := serve(http.MethodDelete, "", h.DeleteDigest); rec.Code != http.StatusNoContent || len(digests.settings) != 0 {
+		t.Fatalf("DELETE = %d, left %v", rec.Code, digests.settings)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type probeStepResponse struct {
+	Name       string  `json:"name"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type probeResponse struct {
+	OK         bool                `json:"ok"`
+	StartedAt  string              `json:"startedAt"`
+	DurationMs float64             `json:"durationMs"`
+	Steps      []probeStepResponse `json:"steps"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// RunProbe runs the synthetic probe for uptime monitors, which send
+// APP_PROBE_TOKEN as a bearer token. It answers 200 when every step
+// succeeded and 503 otherwise, with the latency of each step either way.
+func (h *Handler) RunProbe(w http.ResponseWriter, r *http.Request) {
+	token := ""
+	if cfg := h.config(); cfg != nil {
+		token = cfg.ProbeToken
+	}
+	if token == "" || h.prober == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="probe"`)
+		http.Error(w, "invalid probe token", http.StatusUnauthorized)
+		return
+	}
+	res := h.prober.Run(r.Context())
+	resp := probeResponse{
+		OK:         res.OK,
+		StartedAt:  res.StartedAt.UTC().Format(time.RFC3339),
+		DurationMs: milliseconds(res.Duration),
+		Steps:      make([]probeStepResponse, 0, len(res.Steps)),
+	}
+	if res.Err != nil {
+		resp.Error = res.Err.Error()
+	}
+	for _, s := range res.Steps {
+		step := probeStepResponse{Name: s.Name, Status: s.Status, DurationMs: milliseconds(s.Duration)}
+		if s.Err != nil {
+			step.Error = s.Err.Error()
+		}
+		resp.Steps = append(resp.Steps, step)
+	}
+	status := http.StatusOK
+	if !res.OK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, resp)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestRunProbeNeedsTheProbeToken(t *testing.T) {
+	token := strings.Repeat("t", 32)
+	cfg := &config.Config{}
+	f := storetest.New()
+	f.Store.Users = storetest.NewUsers()
+	h := NewHandler(cfg, f.Store)
+	h.SetDAVServer(dav.NewServer(dav.Options{Config: cfg, Store: f.Store}))
+	run := func(auth string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/probe", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.RunProbe(rec, req)
+		return rec
+	}
+
+	if rec := run("Bearer " + token); rec.Code != http.StatusNotFound {
+		t.Fatalf("without APP_PROBE_TOKEN = %d", rec.Code)
+	}
+	cfg.ProbeToken = token
+	if rec := run("Bearer " + strings.Repeat("x", 32)); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("wrong token = %d", rec.Code)
+	}
+	if rec := run("Basic " + token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token as Basic credentials = %d", rec.Code)
+	}
+
+	rec := run("Bearer " + token)
+	var resp probeResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+		t.Fatalf("probe = %d %s", rec.Code, rec.Body.String())
+	}
+	if !resp.OK || len(resp.Steps) != 3 || resp.Steps[0].Name != "put" || resp.Steps[0].Status != http.StatusCreated || resp.Error != "" {
+		t.Fatalf("probe = %+v", resp)
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/quota"
+)
+
+type quotaResponse struct {
+	// Enabled reports whether a quota is configured; without one only
+	// usedBytes is meaningful.
+	Enabled        bool   `json:"enabled"`
+	UsedBytes      int64  `json:"usedBytes"`
+	QuotaBytes     int64  `json:"quotaBytes"`
+	AvailableBytes int64  `json:"availableBytes"`
+	Percent        int    `json:"percent"`
+	Level          string `json:"level"`
+}
+
+// GetQuota returns how much of their storage quota the user has used, and
+// whether they are past the 80% warning or 95% critical threshold.
+func (h *Handler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if h.store == nil || h.store.Quota == nil {
+		http.Error(w, "quota is not available", http.StatusServiceUnavailable)
+		return
+	}
+	status, err := quota.Check(r.Context(), h.config(), h.store, user.ID)
+	if err != nil {
+		http.Error(w, "failed to load quota", http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		used, err := h.store.Quota.Usage(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load quota", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, quotaResponse{UsedBytes: used, Level: quota.LevelOK})
+		return
+	}
+	writeJSON(w, http.StatusOK, quotaResponse{
+		Enabled:        true,
+		UsedBytes:      status.UsedBytes,
+		QuotaBytes:     status.QuotaBytes,
+		AvailableBytes: status.AvailableBytes(),
+		Percent:        status.Percent(),
+		Level:          status.Level,
+	})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestGetQuotaReportsLevel(t *testing.T) {
+	st := &store.Store{Quota: &storetest.Quota{Used: map[int64]int64{1: 960 << 20}}}
+	get := func(h *Handler) quotaResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/quota", nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rec := httptest.NewRecorder()
+		h.GetQuota(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/quota = %d %s", rec.Code, rec.Body.String())
+		}
+		var resp quotaResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := get(NewHandler(&config.Config{}, st)); resp.Enabled || resp.UsedBytes != 960<<20 || resp.Level != "ok" {
+		t.Fatalf("without a quota = %+v", resp)
+	}
+
+	cfg := &config.Config{}
+	cfg.Quota.UserBytes = 1000 << 20
+	resp := get(NewHandler(cfg, st))
+	want := quotaResponse{Enabled: true, UsedBytes: 960 << 20, QuotaBytes: 1000 << 20, AvailableBytes: 40 << 20, Percent: 96, Level: "critical"}
+	if resp != want {
+		t.Fatalf("with a quota = %+v, want %+v", resp, want)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type schedulingResourceRequest struct {
+	Email              string   `json:"email"`
+	Kind               string   `json:"kind"`
+	Policy             string   `json:"policy"`
+	PriorityOrganizers []string `json:"priorityOrganizers"`
+}
+
+type schedulingResourceResponse struct {
+	CalendarID         int64    `json:"calendarId"`
+	Email              string   `json:"email"`
+	Kind               string   `json:"kind"`
+	Policy             string   `json:"policy"`
+	PriorityOrganizers []string `json:"priorityOrganizers"`
+	UpdatedAt          string   `json:"updatedAt"`
+}
+
+// GetSchedulingResource returns the resource settings of an owned calendar.
+func (h *Handler) GetSchedulingResource(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.events.SchedulingResource(r.Context(), user, calendarID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	if res == nil {
+		http.Error(w, "calendar is not a resource", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedulingResourceResponseFor(*res))
+}
+
+// SetSchedulingResource makes an owned calendar a bookable room or resource.
+func (h *Handler) SetSchedulingResource(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req schedulingResourceRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	res, err := h.events.SetSchedulingResource(r.Context(), user, calendarID, store.SchedulingResource{
+		Email:              req.Email,
+		Kind:               req.Kind,
+		Policy:             req.Policy,
+		PriorityOrganizers: req.PriorityOrganizers,
+	})
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedulingResourceResponseFor(*res))
+}
+
+// DeleteSchedulingResource turns an owned resource calendar back into a
+// plain calendar.
+func (h *Handler) DeleteSchedulingResource(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.events.DeleteSchedulingResource(r.Context(), user, calendarID); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func schedulingResourceResponseFor(res store.SchedulingResource) schedulingResourceResponse {
+	organizers := res.PriorityOrganizers
+	if organizers == nil {
+		organizers = []string{}
+	}
+	return schedulingResourceResponse{
+		CalendarID:         res.CalendarID,
+		Email:              res.Email,
+		Kind:               res.Kind,
+		Policy:             res.Policy,
+		PriorityOrganizers: organizers,
+		UpdatedAt:          res.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeResourceRepo struct {
+	store.SchedulingResourceRepository
+	resources map[int64]store.SchedulingResource
+}
+
+func (f *fakeResourceRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.SchedulingResource, error) {
+	if res, ok := f.resources[calendarID]; ok {
+		return &res, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeResourceRepo) Upsert(ctx context.Context, res store.SchedulingResource) (*store.SchedulingResource, error) {
+	for id, other := range f.resources {
+		if id != res.CalendarID && other.Email == res.Email {
+			return nil, store.ErrConflict
+		}
+	}
+	f.resources[res.CalendarID] = res
+	return &res, nil
+}
+
+func (f *fakeResourceRepo) Delete(ctx context.Context, calendarID int64) error {
+	delete(f.resources, calendarID)
+	return nil
+}
+
+func TestSchedulingResourceEndpoints(t *testing.T) {
+	resources := &fakeResourceRepo{resources: map[int64]store.SchedulingResource{
+		3: {CalendarID: 3, Email: "room-b@example.com", Kind: "ROOM", Policy: store.ResourcePolicyFirstCome},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Room A"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Shared"}, Shared: true, Editor: true},
+		}},
+		Resources: resources,
+	})
+
+	rec := httptest.NewRecorder()
+	h.GetSchedulingResource(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/resource", nil), "1", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GetSchedulingResource() before setting status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	body := `{"email":"Room-A@example.com","policy":"priority","priorityOrganizers":["ceo@example.com"]}`
+	h.SetSchedulingResource(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/1/resource", strings.NewReader(body)), "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SetSchedulingResource() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp schedulingResourceResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.CalendarID != 1 || resp.Email != "room-a@example.com" || resp.Kind != "ROOM" || resp.Policy != "priority" || len(resp.PriorityOrganizers) != 1 {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"2", body, http.StatusForbidden},
+		{"1", `{"email":"not an address"}`, http.StatusBadRequest},
+		{"1", `{"email":"room-a@example.com","kind":"CAR"}`, http.StatusBadRequest},
+		{"1", `{"email":"room-b@example.com"}`, http.StatusConflict},
+	} {
+		rec = httptest.NewRecorder()
+		h.SetSchedulingResource(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/"+tc.id+"/resource", strings.NewReader(tc.body)), tc.id, ""))
+		if rec.Code != tc.want {
+			t.Fatalf("SetSchedulingResource(%s, %s) status = %d, want %d", tc.id, tc.body, rec.Code, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.DeleteSchedulingResource(rec, withUserAndRoute(httptest.NewRequest(http.MethodDelete, "/api/calendars/1/resource", nil), "1", ""))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteSchedulingResource() status = %d", rec.Code)
+	}
+	if _, ok := resources.resources[1]; ok {
+		t.Fatal("expected the resource settings to be removed")
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultRetentionDeletionLimit = 100
+	maxRetentionDeletionLimit     = 1000
+)
+
+type retentionPolicyRequest struct {
+	Months int `json:"months"`
+}
+
+type retentionPolicyResponse struct {
+	CalendarID int64 `json:"calendarId"`
+	Months     int   `json:"months"`
+	// Cutoff is the time events must have ended by to be deleted now.
+	Cutoff    string  `json:"cutoff"`
+	LastRunAt *string `json:"lastRunAt,omitempty"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+type retentionDeletionResponse struct {
+	UID       string  `json:"uid"`
+	Summary   *string `json:"summary,omitempty"`
+	Start     *string `json:"start,omitempty"`
+	End       *string `json:"end,omitempty"`
+	Cutoff    string  `json:"cutoff"`
+	DeletedAt string  `json:"deletedAt"`
+}
+
+// GetRetentionPolicy returns the retention policy of an owned calendar.
+func (h *Handler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	policy, err := h.events.RetentionPolicy(r.Context(), user, calendarID)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	if policy == nil {
+		http.Error(w, "retention policy not set", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, retentionPolicyResponseFor(*policy))
+}
+
+// SetRetentionPolicy makes an owned calendar delete events that ended more
+// than the given number of months ago.
+func (h *Handler) SetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req retentionPolicyRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy, err := h.events.SetRetentionPolicy(r.Context(), user, calendarID, req.Months)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, retentionPolicyResponseFor(*policy))
+}
+
+// DeleteRetentionPolicy stops an owned calendar from deleting old events.
+func (h *Handler) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.events.DeleteRetentionPolicy(r.Context(), user, calendarID); err != nil {
+		writeEventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRetentionDeletions lists the events an owned calendar's retention
+// policy deleted, newest first.
+func (h *Handler) ListRetentionDeletions(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultRetentionDeletionLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(v, maxRetentionDeletionLimit)
+	}
+	list, err := h.events.RetentionDeletions(r.Context(), user, calendarID, limit)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := make([]retentionDeletionResponse, 0, len(list))
+	for _, d := range list {
+		resp = append(resp, retentionDeletionResponse{
+			UID:       d.UID,
+			Summary:   d.Summary,
+			Start:     formatOptionalTime(d.DTStart),
+			End:       formatOptionalTime(d.DTEnd),
+			Cutoff:    d.Cutoff.UTC().Format(time.RFC3339),
+			DeletedAt: d.DeletedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func retentionPolicyResponseFor(policy store.RetentionPolicy) retentionPolicyResponse {
+	return retentionPolicyResponse{
+		CalendarID: policy.CalendarID,
+		Months:     policy.Months,
+		Cutoff:     events.RetentionCutoff(policy.Months, time.Now()).Format(time.RFC3339),
+		LastRunAt:  formatOptionalTime(policy.LastRunAt),
+		UpdatedAt:  policy.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
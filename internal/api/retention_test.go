@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeRetentionRepo struct {
+	store.RetentionRepository
+	policies  map[int64]store.RetentionPolicy
+	deletions []store.RetentionDeletion
+}
+
+func (f *fakeRetentionRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.RetentionPolicy, error) {
+	if policy, ok := f.policies[calendarID]; ok {
+		return &policy, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeRetentionRepo) Upsert(ctx context.Context, policy store.RetentionPolicy) (*store.RetentionPolicy, error) {
+	policy.UpdatedAt = time.Now()
+	f.policies[policy.CalendarID] = policy
+	return &policy, nil
+}
+
+func (f *fakeRetentionRepo) Delete(ctx context.Context, calendarID int64) error {
+	delete(f.policies, calendarID)
+	return nil
+}
+
+func (f *fakeRetentionRepo) ListDeletions(ctx context.Context, calendarID int64, limit int) ([]store.RetentionDeletion, error) {
+	return f.deletions, nil
+}
+
+func TestRetentionEndpoints(t *testing.T) {
+	summary := "Night shift"
+	repo := &fakeRetentionRepo{
+		policies:  map[int64]store.RetentionPolicy{},
+		deletions: []store.RetentionDeletion{{CalendarID: 1, UID: "shift-1", Summary: &summary, Cutoff: time.Now(), DeletedAt: time.Now()}},
+	}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Shifts"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Shared"}, Shared: true, Editor: true},
+		}},
+		Retention: repo,
+	})
+
+	rec := httptest.NewRecorder()
+	h.GetRetentionPolicy(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/retention", nil), "1", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GetRetentionPolicy() before setting status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.SetRetentionPolicy(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/1/retention", strings.NewReader(`{"months":3}`)), "1", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SetRetentionPolicy() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp retentionPolicyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.CalendarID != 1 || resp.Months != 3 || resp.Cutoff == "" || resp.LastRunAt != nil {
+		t.Fatalf("unexpected response %s", rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"2", `{"months":3}`, http.StatusForbidden},
+		{"1", `{"months":0}`, http.StatusBadRequest},
+		{"1", `{"months":3,"extra":true}`, http.StatusBadRequest},
+	} {
+		rec = httptest.NewRecorder()
+		h.SetRetentionPolicy(rec, withUserAndRoute(httptest.NewRequest(http.MethodPut, "/api/calendars/"+tc.id+"/retention", strings.NewReader(tc.body)), tc.id, ""))
+		if rec.Code != tc.want {
+			t.Fatalf("SetRetentionPolicy(%s, %s) status = %d, want %d", tc.id, tc.body, rec.Code, tc.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListRetentionDeletions(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1/retention/deletions", nil), "1", ""))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"uid":"shift-1"`) || !strings.Contains(rec.Body.String(), "Night shift") {
+		t.Fatalf("ListRetentionDeletions() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ListRetentionDeletions(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/2/retention/deletions", nil), "2", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("ListRetentionDeletions() on a shared calendar status = %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.DeleteRetentionPolicy(rec, withUserAndRoute(httptest.NewRequest(http.MethodDelete, "/api/calendars/1/retention", nil), "1", ""))
+	if rec.Code != http.StatusNoContent || len(repo.policies) != 0 {
+		t.Fatalf("DeleteRetentionPolicy() status = %d, policies = %v", rec.Code, repo.policies)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/flags"
+	"github.com/jw6ventures/calcard/internal/probe"
+)
+
+type serverInfoResponse struct {
+	Version string `json:"version"`
+	dav.Info
+	// Flags are the feature flags as they are set for the caller.
+	Flags map[string]bool `json:"flags"`
+}
+
+// SetDAVServer attaches the DAV server whose compliance matrix
+// GET /api/server-info reports, and which GET /api/probe goes through.
+func (h *Handler) SetDAVServer(s *dav.Server) {
+	h.davServer = s
+	h.prober = probe.New(h.store, s)
+}
+
+// GetServerInfo returns the protocols, reports, collations and limits this
+// deployment supports, from the same tables that produce the DAV and Allow
+// headers, and which feature flags are on for the caller.
+func (h *Handler) GetServerInfo(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	if h.davServer == nil {
+		http.Error(w, "server info is not available", http.StatusServiceUnavailable)
+		return
+	}
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	enabled, err := flags.ForUser(r.Context(), h.config(), h.store, user)
+	if err != nil {
+		http.Error(w, "failed to load feature flags", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, serverInfoResponse{Version: version, Info: h.davServer.Info(), Flags: enabled})
+}
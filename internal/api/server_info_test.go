@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestServerInfoReportsFeatureMatrix(t *testing.T) {
+	cfg := &config.Config{ActiveSyncEnabled: true}
+	h := NewHandler(cfg, &store.Store{})
+	h.SetDAVServer(dav.NewServer(dav.Options{Config: cfg}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/server-info", nil)
+	rec := httptest.NewRecorder()
+	h.GetServerInfo(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous status = %d", rec.Code)
+	}
+
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec = httptest.NewRecorder()
+	h.GetServerInfo(rec, req)
+	var resp serverInfoResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil {
+		t.Fatalf("status = %d body = %s", rec.Code, rec.Body.String())
+	}
+	supported := map[string]bool{}
+	for _, f := range resp.Features {
+		supported[f.Name] = f.Supported
+	}
+	if !supported["caldav"] || !supported["sync-collection"] || !supported["activesync"] || supported["caldav-scheduling"] || supported["imip"] {
+		t.Fatalf("features = %+v", resp.Features)
+	}
+	if resp.Limits.MaxResourceSize <= 0 || len(resp.Collations) == 0 || resp.Version == "" {
+		t.Fatalf("response = %+v", resp)
+	}
+}
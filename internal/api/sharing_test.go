@@ -83,7 +83,15 @@ func (f *fakeUserRepo) GetByID(_ context.Context, id int64) (*store.User, error)
 }
 func (f *fakeUserRepo) GetByEmail(context.Context, string) (*store.User, error) { return nil, nil }
 func (f *fakeUserRepo) ListActive(context.Context) ([]store.User, error)        { return nil, nil }
+func (f *fakeUserRepo) MarkInternal(context.Context, int64) error               { return nil }
 func (f *fakeUserRepo) MarkOnboardingComplete(context.Context, int64) error     { return nil }
+func (f *fakeUserRepo) SetSyncHideCancelled(context.Context, int64, bool) error { return nil }
+func (f *fakeUserRepo) SetPreferences(_ context.Context, id int64, locale, timezone string, weekStart *time.Weekday) error {
+	if u, ok := f.users[id]; ok {
+		u.Locale, u.Timezone, u.WeekStart = locale, timezone, weekStart
+	}
+	return nil
+}
 
 func newSharingHandler() (*Handler, *fakeACLRepo) {
 	acl := &fakeACLRepo{}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+type shiftEventsRequest struct {
+	UIDs []string `json:"uids"`
+	// Shift is an ISO 8601 duration of years, months, weeks and days, such
+	// as "P1Y". StartDate (YYYY-MM-DD) is the alternative: the earliest
+	// event moves to that day and the rest follow.
+	Shift     string `json:"shift"`
+	StartDate string `json:"startDate"`
+	CopyTo    int64  `json:"copyTo"`
+}
+
+type shiftEventsResponse struct {
+	Events []shiftedEvent `json:"events"`
+}
+
+type shiftedEvent struct {
+	UID    string `json:"uid"`
+	NewUID string `json:"newUid,omitempty"`
+}
+
+// ShiftEvents serves POST /api/calendars/{id}/events/shift: it moves the
+// events named by uid, or all of them, by a date offset, or copies them
+// shifted into the calendar copyTo.
+func (h *Handler) ShiftEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	calendarID, ok := parseCalendarID(w, r)
+	if !ok {
+		return
+	}
+	var req shiftEventsRequest
+	if !decodeCalendarMergeBody(w, r, &req) {
+		return
+	}
+	input := events.ShiftInput{UIDs: req.UIDs, CopyTo: req.CopyTo}
+	if req.Shift != "" {
+		shift, err := events.ParseDateShift(req.Shift)
+		if err != nil {
+			http.Error(w, "invalid shift", http.StatusBadRequest)
+			return
+		}
+		input.Shift = shift
+	}
+	if req.StartDate != "" {
+		start, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			http.Error(w, "invalid startDate", http.StatusBadRequest)
+			return
+		}
+		input.StartDate = &start
+	}
+	shifted, err := h.events.ShiftEvents(r.Context(), user, calendarID, input)
+	if err != nil {
+		writeEventError(w, err)
+		return
+	}
+	resp := shiftEventsResponse{Events: make([]shiftedEvent, 0, len(shifted))}
+	for _, ev := range shifted {
+		resp.Events = append(resp.Events, shiftedEvent{UID: ev.UID, NewUID: ev.NewUID})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
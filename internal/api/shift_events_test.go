@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func postShiftEvents(handler *Handler, body string) (*httptest.ResponseRecorder, shiftEventsResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/api/calendars/1/events/shift", strings.NewReader(body))
+	req = withUserAndRoute(req, "1", "")
+	rec := httptest.NewRecorder()
+	handler.ShiftEvents(rec, req)
+	var resp shiftEventsResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestShiftEventsMovesOrCopiesToStartDate(t *testing.T) {
+	first := time.Date(2030, 9, 2, 18, 0, 0, 0, time.UTC)
+	events := &fakeEventRepo{events: map[string]store.Event{
+		"1:kickoff":  batchDeleteTestEvent("kickoff", first, "DTSTART:20300902T180000Z", "BEGIN:VALARM", "TRIGGER:-PT30M", "END:VALARM"),
+		"1:training": batchDeleteTestEvent("training", first.AddDate(0, 0, 2), "DTSTART:20300904T180000Z", "RRULE:FREQ=WEEKLY;UNTIL=20301216T000000Z"),
+	}}
+	handler := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Season"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Next season"}, Editor: true},
+		}},
+		Events: events,
+	})
+
+	if rec, _ := postShiftEvents(handler, `{"shift":"P1Y","startDate":"2031-09-01"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("shift with startDate status = %d, want 400", rec.Code)
+	}
+	if rec, _ := postShiftEvents(handler, `{"uids":["kickoff","missing"],"shift":"P1Y"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing uid status = %d, want 404", rec.Code)
+	}
+
+	rec, resp := postShiftEvents(handler, `{"startDate":"2031-09-01","copyTo":2}`)
+	if rec.Code != http.StatusOK || len(resp.Events) != 2 {
+		t.Fatalf("copy status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	for _, ev := range resp.Events {
+		if ev.NewUID == "" || ev.NewUID == ev.UID {
+			t.Fatalf("expected copies with new UIDs, got %+v", resp.Events)
+		}
+		copied, ok := events.events["2:"+ev.NewUID]
+		if !ok {
+			t.Fatalf("expected copy of %s in calendar 2", ev.UID)
+		}
+		switch ev.UID {
+		case "kickoff":
+			if !strings.Contains(copied.RawICAL, "DTSTART:20310901T180000Z") || !strings.Contains(copied.RawICAL, "TRIGGER:-PT30M") {
+				t.Fatalf("unexpected kickoff copy:\n%s", copied.RawICAL)
+			}
+		case "training":
+			if !strings.Contains(copied.RawICAL, "DTSTART:20310903T180000Z") || !strings.Contains(copied.RawICAL, "UNTIL=20311215T000000Z") {
+				t.Fatalf("unexpected training copy:\n%s", copied.RawICAL)
+			}
+		}
+	}
+	if !strings.Contains(events.events["1:kickoff"].RawICAL, "DTSTART:20300902T180000Z") {
+		t.Fatal("expected copying to leave the original alone")
+	}
+
+	rec, resp = postShiftEvents(handler, `{"uids":["kickoff"],"shift":"-P1W"}`)
+	if rec.Code != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].NewUID != "" {
+		t.Fatalf("move status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(events.events["1:kickoff"].RawICAL, "DTSTART:20300826T180000Z") {
+		t.Fatalf("expected kickoff moved a week earlier:\n%s", events.events["1:kickoff"].RawICAL)
+	}
+}
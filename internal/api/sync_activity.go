@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultSyncActivityDays = 30
+	maxSyncActivityDays     = 365
+)
+
+type syncActivityResponse struct {
+	Since       string                   `json:"since"`
+	Collections []collectionSyncResponse `json:"collections"`
+}
+
+type collectionSyncResponse struct {
+	Type    string               `json:"type"`
+	ID      int64                `json:"id"`
+	Name    string               `json:"name"`
+	Devices []syncDeviceResponse `json:"devices"`
+}
+
+type syncDeviceResponse struct {
+	AppPasswordID    *int64 `json:"appPasswordId,omitempty"`
+	AppPasswordLabel string `json:"appPasswordLabel,omitempty"`
+	UserAgent        string `json:"userAgent"`
+	Client           string `json:"client"`
+	LastSyncedAt     string `json:"lastSyncedAt"`
+	SyncCount        int64  `json:"syncCount"`
+}
+
+// ListSyncActivity reports which of the caller's devices synced each
+// collection within the last `days` days (default 30), so stale devices
+// stand out.
+func (h *Handler) ListSyncActivity(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "missing user", http.StatusUnauthorized)
+		return
+	}
+	days := defaultSyncActivityDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		if v > maxSyncActivityDays {
+			v = maxSyncActivityDays
+		}
+		days = v
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	names := map[string]map[int64]string{"calendar": {}, "addressbook": {}}
+	cals, err := h.events.ListCalendars(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+		return
+	}
+	for _, cal := range cals {
+		names["calendar"][cal.ID] = calendarResponseForAccess(cal).Name
+	}
+	books, err := h.contacts.ListAccessibleAddressBooks(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to load address books", http.StatusInternalServerError)
+		return
+	}
+	for _, book := range books {
+		names["addressbook"][book.ID] = book.Name
+	}
+
+	var syncs []store.CollectionSync
+	if h.store.CollectionSyncs != nil {
+		syncs, err = h.store.CollectionSyncs.ListByUser(r.Context(), user.ID, since)
+		if err != nil {
+			http.Error(w, "failed to load sync activity", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := syncActivityResponse{Since: since.Format(time.RFC3339), Collections: []collectionSyncResponse{}}
+	index := make(map[string]int)
+	for _, s := range syncs {
+		// Collections deleted or unshared since the sync are left out.
+		name, ok := names[s.CollectionType][s.CollectionID]
+		if !ok {
+			continue
+		}
+		key := s.CollectionType + ":" + strconv.FormatInt(s.CollectionID, 10)
+		i, ok := index[key]
+		if !ok {
+			resp.Collections = append(resp.Collections, collectionSyncResponse{Type: s.CollectionType, ID: s.CollectionID, Name: name, Devices: []syncDeviceResponse{}})
+			i = len(resp.Collections) - 1
+			index[key] = i
+		}
+		resp.Collections[i].Devices = append(resp.Collections[i].Devices, syncDeviceResponse{
+			AppPasswordID:    s.AppPasswordID,
+			AppPasswordLabel: s.AppPasswordLabel,
+			UserAgent:        s.UserAgent,
+			Client:           metrics.ClientFamily(s.UserAgent),
+			LastSyncedAt:     s.LastSyncedAt.UTC().Format(time.RFC3339),
+			SyncCount:        s.SyncCount,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
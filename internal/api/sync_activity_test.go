@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeCollectionSyncRepo struct {
+	store.CollectionSyncRepository
+	syncs []store.CollectionSync
+	since time.Time
+}
+
+func (f *fakeCollectionSyncRepo) ListByUser(ctx context.Context, userID int64, since time.Time) ([]store.CollectionSync, error) {
+	f.since = since
+	return f.syncs, nil
+}
+
+func TestListSyncActivityGroupsDevicesByCollection(t *testing.T) {
+	synced := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tablet := int64(7)
+	syncs := &fakeCollectionSyncRepo{syncs: []store.CollectionSync{
+		{CollectionType: "calendar", CollectionID: 1, UserID: 1, AppPasswordID: &tablet, AppPasswordLabel: "Tablet", UserAgent: "DAVx5/4.3 (Android)", LastSyncedAt: synced, SyncCount: 12},
+		{CollectionType: "calendar", CollectionID: 1, UserID: 1, UserAgent: "Thunderbird/128", LastSyncedAt: synced.Add(-time.Hour), SyncCount: 3},
+		{CollectionType: "addressbook", CollectionID: 5, UserID: 1, UserAgent: "DAVx5/4.3 (Android)", LastSyncedAt: synced, SyncCount: 1},
+		// A calendar that was deleted after it synced.
+		{CollectionType: "calendar", CollectionID: 99, UserID: 1, UserAgent: "curl/8", LastSyncedAt: synced, SyncCount: 1},
+	}}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		AddressBooks:    &fakeAddressBookRepo{books: map[int64]*store.AddressBook{5: {ID: 5, UserID: 1, Name: "Personal"}}},
+		ACLEntries:      &fakeACLRepo{},
+		CollectionSyncs: syncs,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sync-activity?days=7", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rec := httptest.NewRecorder()
+	h.ListSyncActivity(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListSyncActivity() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if age := time.Since(syncs.since); age < 7*24*time.Hour-time.Minute || age > 7*24*time.Hour+time.Minute {
+		t.Fatalf("expected a 7 day window, got since=%v", syncs.since)
+	}
+	var resp syncActivityResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Collections) != 2 {
+		t.Fatalf("expected calendar and address book, got %+v", resp.Collections)
+	}
+	cal := resp.Collections[0]
+	if cal.Type != "calendar" || cal.Name != "Work" || len(cal.Devices) != 2 {
+		t.Fatalf("unexpected calendar activity %+v", cal)
+	}
+	if d := cal.Devices[0]; d.AppPasswordLabel != "Tablet" || d.Client != "davx5" || d.SyncCount != 12 || d.LastSyncedAt != "2026-03-01T09:00:00Z" {
+		t.Fatalf("unexpected device %+v", d)
+	}
+	if resp.Collections[1].Type != "addressbook" || resp.Collections[1].Name != "Personal" {
+		t.Fatalf("unexpected address book activity %+v", resp.Collections[1])
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/sync-activity?days=0", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	h.ListSyncActivity(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("days=0 status = %d, want 400", rec.Code)
+	}
+}
+
+func (f *fakeCollectionSyncRepo) Checksum(ctx context.Context, collectionType string, collectionID int64) (string, error) {
+	return collectionType + "-checksum", nil
+}
+
+func TestGetCalendarIncludesChecksum(t *testing.T) {
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		CollectionSyncs: &fakeCollectionSyncRepo{},
+	})
+	rec := httptest.NewRecorder()
+	h.GetCalendar(rec, withUserAndRoute(httptest.NewRequest(http.MethodGet, "/api/calendars/1", nil), "1", ""))
+	var resp calendarResponse
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.Checksum != "calendar-checksum" {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body.String())
+	}
+}
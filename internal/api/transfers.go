@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type transferCalendarRequest struct {
+	UserID int64 `json:"userId"`
+}
+
+type calendarTransferResponse struct {
+	ID            int64  `json:"id"`
+	CalendarID    int64  `json:"calendarId"`
+	FromUserID    int64  `json:"fromUserId"`
+	FromEmail     string `json:"fromEmail"`
+	ToUserID      int64  `json:"toUserId"`
+	ToEmail       string `json:"toEmail"`
+	TransferredBy string `json:"transferredBy"`
+	TransferredAt string `json:"transferredAt"`
+}
+
+func newCalendarTransferResponse(t store.CalendarTransfer) calendarTransferResponse {
+	return calendarTransferResponse{
+		ID:            t.ID,
+		CalendarID:    t.CalendarID,
+		FromUserID:    t.FromUserID,
+		FromEmail:     t.FromEmail,
+		ToUserID:      t.ToUserID,
+		ToEmail:       t.ToEmail,
+		TransferredBy: t.TransferredBy,
+		TransferredAt: t.TransferredAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (h *Handler) requireTransfers(w http.ResponseWriter) bool {
+	if h.store == nil || h.store.Transfers == nil || h.store.Users == nil {
+		http.Error(w, "calendar transfers are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// TransferCalendar makes another user the owner of a calendar, such as when
+// its owner leaves. The calendar keeps its ID, events and shares, so other
+// users' clients carry on syncing it unchanged; the old owner loses access.
+func (h *Handler) TransferCalendar(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireTransfers(w) {
+		return
+	}
+	admin, _ := auth.UserFromContext(r.Context())
+	calendarID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || calendarID <= 0 {
+		http.Error(w, "invalid calendar id", http.StatusBadRequest)
+		return
+	}
+	var req transferCalendarRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	target, err := h.store.Users.GetByID(r.Context(), req.UserID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	transfer, err := h.store.Transfers.Transfer(r.Context(), calendarID, target.ID, admin.PrimaryEmail)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "calendar not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, "the user already owns this calendar or one with the same slug", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to transfer calendar", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newCalendarTransferResponse(*transfer))
+}
+
+// ListCalendarTransfers returns a calendar's past transfers, oldest first.
+func (h *Handler) ListCalendarTransfers(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) || !h.requireTransfers(w) {
+		return
+	}
+	calendarID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || calendarID <= 0 {
+		http.Error(w, "invalid calendar id", http.StatusBadRequest)
+		return
+	}
+	list, err := h.store.Transfers.ListByCalendar(r.Context(), calendarID)
+	if err != nil {
+		http.Error(w, "failed to load transfers", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]calendarTransferResponse, 0, len(list))
+	for _, t := range list {
+		resp = append(resp, newCalendarTransferResponse(t))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestTransferCalendarRecordsNewOwner(t *testing.T) {
+	transfers := &storetest.Transfers{Owners: map[int64]int64{5: 1}}
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{
+		Users: &fakeUserRepo{users: map[int64]*store.User{
+			1: {ID: 1, PrimaryEmail: "leaver@example.com"},
+			2: {ID: 2, PrimaryEmail: "successor@example.com"},
+			9: {ID: 9, PrimaryEmail: "admin@example.com"},
+		}},
+		Transfers: transfers,
+	})
+	call := func(caller *store.User, id, body string, handle http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/calendars/"+id+"/transfer", strings.NewReader(body))
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("id", id)
+		ctx := auth.WithUser(req.Context(), caller)
+		rec := httptest.NewRecorder()
+		handle(rec, req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx)))
+		return rec
+	}
+	admin := &store.User{ID: 9, PrimaryEmail: "admin@example.com"}
+
+	if rec := call(&store.User{ID: 1, PrimaryEmail: "leaver@example.com"}, "5", `{"userId":2}`, h.TransferCalendar); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin transfer status = %d, want 403", rec.Code)
+	}
+	if rec := call(admin, "5", `{"userId":3}`, h.TransferCalendar); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown user status = %d, want 404", rec.Code)
+	}
+	if rec := call(admin, "6", `{"userId":2}`, h.TransferCalendar); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown calendar status = %d, want 404", rec.Code)
+	}
+	if rec := call(admin, "5", `{"userId":1}`, h.TransferCalendar); rec.Code != http.StatusConflict {
+		t.Fatalf("transfer to the owner status = %d, want 409", rec.Code)
+	}
+
+	rec := call(admin, "5", `{"userId":2}`, h.TransferCalendar)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("TransferCalendar() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var transfer calendarTransferResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &transfer); err != nil || transfer.FromUserID != 1 || transfer.ToUserID != 2 || transfer.TransferredBy != "admin@example.com" {
+		t.Fatalf("TransferCalendar() = %+v, %v", transfer, err)
+	}
+	if transfers.Owners[5] != 2 {
+		t.Fatalf("owner after transfer = %d, want 2", transfers.Owners[5])
+	}
+
+	rec = call(admin, "5", "", h.ListCalendarTransfers)
+	var list []calendarTransferResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].ToUserID != 2 {
+		t.Fatalf("ListCalendarTransfers() = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+const maxIssuedUIDs = 100
+
+type issuedUIDsResponse struct {
+	UIDs []string `json:"uids"`
+}
+
+// IssueUIDs returns count (default 1, at most 100) new UIDs in the form
+// uuid@host, for clients to use in events they create. Nothing is
+// reserved; the UUIDs are random, so they do not collide.
+func (h *Handler) IssueUIDs(w http.ResponseWriter, r *http.Request) {
+	count := 1
+	if raw := r.URL.Query().Get("count"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxIssuedUIDs {
+			http.Error(w, "invalid count", http.StatusBadRequest)
+			return
+		}
+		count = v
+	}
+	host := events.UIDHost("")
+	if h.cfg != nil {
+		host = events.UIDHost(h.cfg.BaseURL)
+	}
+	resp := issuedUIDsResponse{UIDs: make([]string, count)}
+	for i := range resp.UIDs {
+		resp.UIDs[i] = events.NewUID(host)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
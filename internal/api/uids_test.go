@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestIssueUIDs(t *testing.T) {
+	h := NewHandler(&config.Config{BaseURL: "https://cal.example.com"}, &store.Store{})
+
+	rec := httptest.NewRecorder()
+	h.IssueUIDs(rec, httptest.NewRequest(http.MethodPost, "/api/uids?count=3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("IssueUIDs() status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var resp issuedUIDsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.UIDs) != 3 || resp.UIDs[0] == resp.UIDs[1] {
+		t.Fatalf("IssueUIDs() = %v", resp.UIDs)
+	}
+	for _, uid := range resp.UIDs {
+		if !events.IsServerUID(uid, "cal.example.com") {
+			t.Fatalf("IssueUIDs() issued %q", uid)
+		}
+	}
+
+	for _, count := range []string{"0", "101", "many"} {
+		rec = httptest.NewRecorder()
+		h.IssueUIDs(rec, httptest.NewRequest(http.MethodPost, "/api/uids?count="+count, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("IssueUIDs(count=%s) status = %d, want 400", count, rec.Code)
+		}
+	}
+}
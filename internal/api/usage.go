@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// maxUsageSnapshots bounds one usage stats response, about a year of daily
+// snapshots.
+const maxUsageSnapshots = 400
+
+type usageStatsResponse struct {
+	Enabled   bool                    `json:"enabled"`
+	Interval  string                  `json:"interval,omitempty"`
+	Snapshots []usageSnapshotResponse `json:"snapshots"`
+}
+
+type usageSnapshotResponse struct {
+	CollectedAt    string           `json:"collectedAt"`
+	Users          int64            `json:"users"`
+	ActiveUsers    int64            `json:"activeUsers"`
+	Calendars      int64            `json:"calendars"`
+	AddressBooks   int64            `json:"addressBooks"`
+	Events         int64            `json:"events"`
+	Contacts       int64            `json:"contacts"`
+	ClientFamilies map[string]int64 `json:"clientFamilies"`
+}
+
+// GetUsageStats returns the anonymous usage snapshots, newest first. limit
+// caps how many, 30 by default.
+func (h *Handler) GetUsageStats(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	limit := 30
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxUsageSnapshots {
+			http.Error(w, "limit must be between 1 and 400", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	snapshots, err := h.store.UsageStats.List(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "failed to load usage stats", http.StatusInternalServerError)
+		return
+	}
+	cfg := h.config()
+	resp := usageStatsResponse{Enabled: cfg.Telemetry.Enabled, Snapshots: make([]usageSnapshotResponse, 0, len(snapshots))}
+	if resp.Enabled {
+		resp.Interval = cfg.Telemetry.Interval.String()
+	}
+	for _, s := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, toUsageSnapshotResponse(s))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toUsageSnapshotResponse(s store.UsageSnapshot) usageSnapshotResponse {
+	families := s.ClientFamilies
+	if families == nil {
+		families = map[string]int64{}
+	}
+	return usageSnapshotResponse{
+		CollectedAt:    s.CollectedAt.UTC().Format(time.RFC3339),
+		Users:          s.Users,
+		ActiveUsers:    s.ActiveUsers,
+		Calendars:      s.Calendars,
+		AddressBooks:   s.AddressBooks,
+		Events:         s.Events,
+		Contacts:       s.Contacts,
+		ClientFamilies: families,
+	}
+}
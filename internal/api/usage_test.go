@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeUsageStatsRepo struct {
+	store.UsageStatsRepository
+	snapshots []store.UsageSnapshot
+	limit     int
+}
+
+func (f *fakeUsageStatsRepo) List(ctx context.Context, limit int) ([]store.UsageSnapshot, error) {
+	f.limit = limit
+	return f.snapshots, nil
+}
+
+func TestGetUsageStats(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"admin@example.com"}}
+	cfg.Telemetry.Enabled = true
+	cfg.Telemetry.Interval = 24 * time.Hour
+	repo := &fakeUsageStatsRepo{snapshots: []store.UsageSnapshot{{
+		ID: 2, CollectedAt: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), Users: 5, ActiveUsers: 3,
+		ClientFamilies: map[string]int64{"ios": 2},
+	}}}
+	h := NewHandler(cfg, &store.Store{UsageStats: repo})
+
+	rec := httptest.NewRecorder()
+	h.GetUsageStats(rec, adminRequest(http.MethodGet, "/api/admin/usage", "", "user@example.com", ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetUsageStats(rec, adminRequest(http.MethodGet, "/api/admin/usage?limit=0", "", "admin@example.com", ""))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0 status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetUsageStats(rec, adminRequest(http.MethodGet, "/api/admin/usage?limit=7", "", "admin@example.com", ""))
+	var resp usageStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+	}
+	if repo.limit != 7 || !resp.Enabled || resp.Interval != "24h0m0s" || len(resp.Snapshots) != 1 {
+		t.Fatalf("unexpected response %+v (limit %d)", resp, repo.limit)
+	}
+	if s := resp.Snapshots[0]; s.CollectedAt != "2026-03-20T00:00:00Z" || s.Users != 5 || s.ActiveUsers != 3 || s.ClientFamilies["ios"] != 2 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+}
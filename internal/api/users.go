@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type createUserRequest struct {
+	Email string `json:"email"`
+}
+
+type userResponse struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// CreateUser provisions an account for an email address before its owner
+// first signs in, as the bootstrap file does: signing in with that address
+// claims the account, with anything already shared with it.
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var req createUserRequest
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	addr, err := netmail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil || addr.Name != "" {
+		http.Error(w, "email must be an email address", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.store.Users.GetByEmail(r.Context(), addr.Address)
+	if err != nil {
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		http.Error(w, "a user with this email already exists", http.StatusConflict)
+		return
+	}
+	user, err := h.store.Users.UpsertOAuthUser(r.Context(), store.BootstrapSubject(addr.Address), addr.Address)
+	if err != nil {
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, userResponse{
+		ID:        user.ID,
+		Email:     user.PrimaryEmail,
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+	})
+}
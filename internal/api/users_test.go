@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestCreateUserProvisionsAnAccountToClaim(t *testing.T) {
+	users := storetest.NewUsers(store.User{ID: 1, OAuthSubject: "sub-admin", PrimaryEmail: "admin@example.com"})
+	h := NewHandler(&config.Config{AdminEmails: []string{"admin@example.com"}}, &store.Store{Users: users})
+	create := func(email, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.CreateUser(rec, adminRequest(http.MethodPost, "/api/admin/users", body, email, ""))
+		return rec
+	}
+
+	if rec := create("user@example.com", `{"email":"new@example.com"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin create = %d, want 403", rec.Code)
+	}
+	if rec := create("admin@example.com", `{"email":"Someone <new@example.com>"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("named address = %d, want 400", rec.Code)
+	}
+	rec := create("admin@example.com", `{"email":"new@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", rec.Code, rec.Body.String())
+	}
+	var created userResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if u := users.Users[created.ID]; u == nil || u.OAuthSubject != store.BootstrapSubject("new@example.com") {
+		t.Fatalf("created user = %+v", u)
+	}
+	if rec := create("admin@example.com", `{"email":"NEW@example.com"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate create = %d, want 409", rec.Code)
+	}
+
+	claimed, _ := users.UpsertOAuthUser(t.Context(), "sub-new", "new@example.com")
+	if claimed.ID != created.ID {
+		t.Fatalf("first sign-in created user %d, want it to claim %d", claimed.ID, created.ID)
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// aliasVerificationTTL is how long an alias verification link works.
+const aliasVerificationTTL = 48 * time.Hour
+
+var (
+	// ErrInvalidAlias is returned for an address that cannot be an alias.
+	ErrInvalidAlias = errors.New("invalid alias")
+	// ErrAliasTaken is returned when another account already uses the address.
+	ErrAliasTaken = errors.New("address is used by another account")
+	// ErrAliasEmailDisabled is returned when no verification email can be sent.
+	ErrAliasEmailDisabled = errors.New("email is not configured")
+)
+
+// AddEmailAlias claims email as an alias of user and emails a verification
+// link to it. The alias signs in and matches scheduling addresses only once
+// the link has been opened by the same user.
+func (s *Service) AddEmailAlias(ctx context.Context, user *store.User, email string) (*store.EmailAlias, error) {
+	addr, err := netmail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return nil, fmt.Errorf("%w: %q is not an email address", ErrInvalidAlias, email)
+	}
+	if user.HasEmail(addr.Address) {
+		return nil, fmt.Errorf("%w: %s is already yours", ErrInvalidAlias, addr.Address)
+	}
+	if s.notifier == nil {
+		return nil, ErrAliasEmailDisabled
+	}
+	owner, err := s.store.Users.GetByEmail(ctx, addr.Address)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		return nil, ErrAliasTaken
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	alias, err := s.store.EmailAliases.Create(ctx, user.ID, addr.Address, aliasTokenHash(token), false)
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return nil, ErrAliasTaken
+		}
+		return nil, err
+	}
+	link := strings.TrimRight(s.cfg.BaseURL, "/") + "/email-aliases/verify?token=" + url.QueryEscape(token)
+	prefs := locale.ForUser(user)
+	err = s.notifier.Send(mail.Message{
+		To:      []string{addr.Address},
+		Subject: prefs.T("alias.subject"),
+		Text:    prefs.Plural("alias.text", int(aliasVerificationTTL.Hours()), user.PrimaryEmail, addr.Address, link),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send verification email: %w", err)
+	}
+	return alias, nil
+}
+
+// VerifyEmailAlias confirms the alias the token was sent for. It returns
+// nil when the token is unknown, expired or belongs to another user.
+func (s *Service) VerifyEmailAlias(ctx context.Context, userID int64, token string) (*store.EmailAlias, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, nil
+	}
+	alias, err := s.store.EmailAliases.Verify(ctx, userID, aliasTokenHash(token), time.Now().Add(-aliasVerificationTTL))
+	if errors.Is(err, store.ErrConflict) {
+		return nil, ErrAliasTaken
+	}
+	return alias, err
+}
+
+func aliasTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
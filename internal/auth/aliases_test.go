@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeEmailAliasRepo struct {
+	store.EmailAliasRepository
+	userID    int64
+	email     string
+	tokenHash string
+	verified  bool
+}
+
+func (f *fakeEmailAliasRepo) Create(_ context.Context, userID int64, email, tokenHash string, verified bool) (*store.EmailAlias, error) {
+	f.userID, f.email, f.tokenHash, f.verified = userID, email, tokenHash, verified
+	return &store.EmailAlias{ID: 1, UserID: userID, Email: email}, nil
+}
+
+func (f *fakeEmailAliasRepo) Verify(_ context.Context, userID int64, tokenHash string, notBefore time.Time) (*store.EmailAlias, error) {
+	if userID != f.userID || tokenHash != f.tokenHash || f.verified {
+		return nil, nil
+	}
+	f.verified = true
+	now := time.Now()
+	return &store.EmailAlias{ID: 1, UserID: userID, Email: f.email, VerifiedAt: &now}, nil
+}
+
+func TestAddAndVerifyEmailAlias(t *testing.T) {
+	alice := &store.User{ID: 7, PrimaryEmail: "alice@example.com"}
+	bob := &store.User{ID: 8, PrimaryEmail: "bob@example.com", EmailAliases: []string{"team@example.com"}}
+	aliases := &fakeEmailAliasRepo{}
+	notifier := make(fakeNotifier, 1)
+	service := &Service{
+		cfg: &config.Config{BaseURL: "https://calcard.example/"},
+		store: &store.Store{
+			Users: &userRepoMock{getByEmailFn: func(_ context.Context, email string) (*store.User, error) {
+				if bob.HasEmail(email) {
+					return bob, nil
+				}
+				return nil, nil
+			}},
+			EmailAliases: aliases,
+		},
+		notifier: notifier,
+	}
+	ctx := context.Background()
+
+	for email, want := range map[string]error{
+		"not an address":    ErrInvalidAlias,
+		"ALICE@example.com": ErrInvalidAlias,
+		"Team@Example.com":  ErrAliasTaken,
+	} {
+		if _, err := service.AddEmailAlias(ctx, alice, email); !errors.Is(err, want) {
+			t.Errorf("AddEmailAlias(%q) error = %v, want %v", email, err, want)
+		}
+	}
+
+	if _, err := service.AddEmailAlias(ctx, alice, "alice@work.example"); err != nil {
+		t.Fatalf("AddEmailAlias() error = %v", err)
+	}
+	msg := <-notifier
+	if len(msg.To) != 1 || msg.To[0] != "alice@work.example" || aliases.verified {
+		t.Fatalf("verification mail = %+v, verified %v", msg, aliases.verified)
+	}
+	_, link, ok := strings.Cut(msg.Text, "https://calcard.example/email-aliases/verify?token=")
+	if !ok {
+		t.Fatalf("verification mail has no link: %s", msg.Text)
+	}
+	token, err := url.QueryUnescape(strings.Fields(link)[0])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+
+	if alias, err := service.VerifyEmailAlias(ctx, bob.ID, token); err != nil || alias != nil {
+		t.Fatalf("VerifyEmailAlias() by another user = %+v, %v", alias, err)
+	}
+	if alias, err := service.VerifyEmailAlias(ctx, alice.ID, token); err != nil || alias == nil || alias.VerifiedAt == nil {
+		t.Fatalf("VerifyEmailAlias() = %+v, %v", alias, err)
+	}
+
+	service.notifier = nil
+	if _, err := service.AddEmailAlias(ctx, alice, "alice@home.example"); !errors.Is(err, ErrAliasEmailDisabled) {
+		t.Fatalf("AddEmailAlias() without SMTP error = %v", err)
+	}
+}
@@ -9,11 +9,19 @@ import (
 type contextKey string
 
 const (
-	contextKeyUser      contextKey = "user"
-	contextKeySessionID contextKey = "session_id"
+	contextKeyUser          contextKey = "user"
+	contextKeySessionID     contextKey = "session_id"
+	contextKeyAppPassID     contextKey = "app_password_id"
+	contextKeyImpersonation contextKey = "impersonation"
+	contextKeyAPIRole       contextKey = "api_role"
 )
 
+// WithUser records the signed-in user. Their writes are attributed to them
+// in the change feed.
 func WithUser(ctx context.Context, user *store.User) context.Context {
+	if user != nil {
+		ctx = store.WithActor(ctx, user.ID)
+	}
 	return context.WithValue(ctx, contextKeyUser, user)
 }
 
@@ -30,3 +38,44 @@ func SessionIDFromContext(ctx context.Context) string {
 	s, _ := ctx.Value(contextKeySessionID).(string)
 	return s
 }
+
+// WithAppPasswordID records which app password authenticated the request.
+func WithAppPasswordID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, contextKeyAppPassID, id)
+}
+
+// AppPasswordIDFromContext returns the app password that authenticated the
+// request, if it was authenticated with one.
+func AppPasswordIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(contextKeyAppPassID).(int64)
+	return id, ok
+}
+
+// WithAPIRole records the REST API role of the app password that
+// authenticated the request.
+func WithAPIRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, contextKeyAPIRole, role)
+}
+
+// APIRoleFromContext returns the REST API role the request is limited to.
+// Authentication records it for every credential; a request it did not
+// record one for is only allowed to read.
+func APIRoleFromContext(ctx context.Context) string {
+	if role, ok := ctx.Value(contextKeyAPIRole).(string); ok && role != "" {
+		return role
+	}
+	return store.APIRoleViewer
+}
+
+// WithImpersonation records that an admin is making the request as the
+// context's user.
+func WithImpersonation(ctx context.Context, imp *store.Impersonation) context.Context {
+	return context.WithValue(ctx, contextKeyImpersonation, imp)
+}
+
+// ImpersonationFromContext returns the impersonation the request is made
+// under, if any.
+func ImpersonationFromContext(ctx context.Context) (*store.Impersonation, bool) {
+	imp, ok := ctx.Value(contextKeyImpersonation).(*store.Impersonation)
+	return imp, ok && imp != nil
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// maxCachedCredentials bounds the credential cache; once it is full and
+// nothing has expired, new credentials are verified but not cached.
+const maxCachedCredentials = 10000
+
+// credentialCache remembers recently verified app-password credentials so
+// Basic-auth clients, which send them on every request, are not bcrypt-checked
+// each time. Entries are keyed by a hash of the username and password, never
+// the password itself, and live for at most ttl. A zero ttl disables caching.
+type credentialCache struct {
+	ttl time.Duration
+	// longestTTL is the longest ttl the cache has had, which bounds how long
+	// a revocation has to be remembered.
+	longestTTL time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cachedCredential
+	// revoked holds when app passwords were invalidated, so a put from a
+	// verification that was in flight cannot resurrect them. Entries are
+	// dropped once they are older than any ttl.
+	revoked map[int64]time.Time
+}
+
+type cachedCredential struct {
+	userID        int64
+	appPasswordID int64
+	apiRole       string
+	expiresAt     time.Time
+}
+
+func credentialKey(username, password string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(username + "\x00" + password))
+}
+
+func (c *credentialCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// enabled reports whether credentials are cached at all.
+func (c *credentialCache) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl > 0
+}
+
+// setTTL changes how long new entries live. Disabling the cache drops every
+// entry; shortening the ttl leaves existing entries to expire on their own
+// schedule.
+func (c *credentialCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.longestTTL = max(c.longestTTL, ttl)
+	if ttl <= 0 {
+		c.entries = nil
+	}
+}
+
+// get returns the cached principal for the credentials, if any.
+func (c *credentialCache) get(username, password string) (cachedCredential, bool) {
+	key := credentialKey(username, password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return cachedCredential{}, false
+	}
+	entry, ok := c.entries[key]
+	if !ok {
+		return cachedCredential{}, false
+	}
+	if !c.clock().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return cachedCredential{}, false
+	}
+	return entry, true
+}
+
+// put caches verified credentials until the ttl passes or the app password
+// expires, whichever comes first.
+func (c *credentialCache) put(username, password string, userID, appPasswordID int64, apiRole string, passwordExpiresAt *time.Time) {
+	now := c.clock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	expiresAt := now.Add(c.ttl)
+	if passwordExpiresAt != nil && passwordExpiresAt.Before(expiresAt) {
+		expiresAt = *passwordExpiresAt
+	}
+	c.pruneRevoked(now)
+	if _, ok := c.revoked[appPasswordID]; ok {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]cachedCredential)
+	}
+	if len(c.entries) >= maxCachedCredentials {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxCachedCredentials {
+			return
+		}
+	}
+	c.entries[credentialKey(username, password)] = cachedCredential{userID: userID, appPasswordID: appPasswordID, apiRole: apiRole, expiresAt: expiresAt}
+}
+
+// invalidate drops every cached credential for the app password, which must
+// no longer be accepted.
+func (c *credentialCache) invalidate(appPasswordID int64) {
+	now := c.clock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneRevoked(now)
+	if c.revoked == nil {
+		c.revoked = make(map[int64]time.Time)
+	}
+	c.revoked[appPasswordID] = now
+	for key, entry := range c.entries {
+		if entry.appPasswordID == appPasswordID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// pruneRevoked forgets revocations older than the longest ttl; a
+// verification still in flight by then has long since finished.
+func (c *credentialCache) pruneRevoked(now time.Time) {
+	window := max(c.ttl, c.longestTTL)
+	for id, revokedAt := range c.revoked {
+		if now.Sub(revokedAt) > window {
+			delete(c.revoked, id)
+		}
+	}
+}
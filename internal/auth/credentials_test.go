@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequireDAVAuthCachesVerifiedCredentials(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("app-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	lookups := 0
+	user := &store.User{ID: 7, PrimaryEmail: "user@example.com"}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	service := &Service{
+		store: &store.Store{
+			Users: &userRepoMock{
+				getByEmailFn: func(context.Context, string) (*store.User, error) { return user, nil },
+				getByIDFn:    func(context.Context, int64) (*store.User, error) { return user, nil },
+			},
+			AppPasswords: &appPasswordRepoMock{
+				findValidByUserFn: func(context.Context, int64) ([]store.AppPassword, error) {
+					lookups++
+					return []store.AppPassword{{ID: 3, UserID: 7, TokenHash: string(hash)}}, nil
+				},
+				touchLastUsedFn: func(context.Context, int64, string, string) error { return nil },
+			},
+		},
+		creds: credentialCache{ttl: time.Minute, now: func() time.Time { return now }},
+	}
+
+	var gotPasswordID int64
+	handler := service.RequireDAVAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPasswordID, _ = AppPasswordIDFromContext(r.Context())
+	}))
+	serve := func(password string) int {
+		req := httptest.NewRequest("PROPFIND", "/dav/", nil)
+		req.SetBasicAuth("user@example.com", password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := serve("app-secret"); code != http.StatusOK || gotPasswordID != 3 {
+			t.Fatalf("request %d: status %d, app password %d", i, code, gotPasswordID)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("verified the password %d times, want 1", lookups)
+	}
+	if code := serve("wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password status = %d", code)
+	}
+
+	now = now.Add(2 * time.Minute)
+	serve("app-secret")
+	if lookups != 3 {
+		t.Fatalf("expected an expired entry to be verified again, lookups = %d", lookups)
+	}
+
+	service.DisconnectAppPassword(3)
+	serve("app-secret")
+	if lookups != 4 {
+		t.Fatalf("expected revocation to drop the cached credentials, lookups = %d", lookups)
+	}
+}
+
+func TestCredentialCacheHonoursPasswordExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := credentialCache{ttl: time.Hour, now: func() time.Time { return now }}
+	expires := now.Add(time.Minute)
+	cache.put("user@example.com", "secret", 7, 3, store.APIRoleAdmin, &expires)
+
+	if _, ok := cache.get("user@example.com", "secret"); !ok {
+		t.Fatal("expected a cache hit")
+	}
+	if _, ok := cache.get("other@example.com", "secret"); ok {
+		t.Fatal("credentials must be keyed by username too")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("user@example.com", "secret"); ok {
+		t.Fatal("expected the entry to expire with the app password")
+	}
+
+	var disabled credentialCache
+	disabled.put("user@example.com", "secret", 7, 3, store.APIRoleAdmin, nil)
+	if _, ok := disabled.get("user@example.com", "secret"); ok {
+		t.Fatal("a zero ttl must disable caching")
+	}
+}
+
+func TestCredentialCacheForgetsOldRevocations(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := credentialCache{now: func() time.Time { return now }}
+	cache.setTTL(time.Hour)
+	cache.invalidate(3)
+	cache.put("user@example.com", "secret", 7, 3, store.APIRoleAdmin, nil)
+	if _, ok := cache.get("user@example.com", "secret"); ok {
+		t.Fatal("a put racing the revocation must not be cached")
+	}
+
+	cache.setTTL(time.Minute)
+	now = now.Add(time.Hour)
+	cache.invalidate(4)
+	if _, ok := cache.revoked[3]; !ok {
+		t.Fatal("a revocation must be kept for the longest ttl, not the current one")
+	}
+	now = now.Add(time.Second)
+	cache.put("other@example.com", "secret", 8, 5, store.APIRoleAdmin, nil)
+	if len(cache.revoked) != 1 {
+		t.Fatalf("revoked = %v, want only the recent revocation kept", cache.revoked)
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// appPasswordRequests tracks the requests each app password is serving so
+// revoking a lost device can abort its open syncs, not just reject its next
+// request.
+type appPasswordRequests struct {
+	mu      sync.Mutex
+	nextID  uint64
+	active  map[int64]map[uint64]context.CancelFunc
+	revoked map[int64]struct{}
+}
+
+// track registers a request's cancel func under the app password that
+// authenticated it and returns the func that unregisters it. A request that
+// authenticated just before its password was revoked is cancelled at once.
+func (a *appPasswordRequests) track(appPasswordID int64, cancel context.CancelFunc) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.revoked[appPasswordID]; ok {
+		cancel()
+		return func() {}
+	}
+	if a.active == nil {
+		a.active = make(map[int64]map[uint64]context.CancelFunc)
+	}
+	if a.active[appPasswordID] == nil {
+		a.active[appPasswordID] = make(map[uint64]context.CancelFunc)
+	}
+	a.nextID++
+	id := a.nextID
+	a.active[appPasswordID][id] = cancel
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.active[appPasswordID], id)
+		if len(a.active[appPasswordID]) == 0 {
+			delete(a.active, appPasswordID)
+		}
+	}
+}
+
+// cancel aborts every request running on the app password, and any that
+// slip in afterwards, and reports how many were running.
+func (a *appPasswordRequests) cancel(appPasswordID int64) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.revoked == nil {
+		a.revoked = make(map[int64]struct{})
+	}
+	a.revoked[appPasswordID] = struct{}{}
+	requests := a.active[appPasswordID]
+	for _, cancel := range requests {
+		cancel()
+	}
+	delete(a.active, appPasswordID)
+	return len(requests)
+}
+
+// DisconnectAppPassword aborts the in-flight requests of an app password that
+// was just revoked, drops its cached credentials, and returns how many
+// requests there were. Other replicas are told to do the same when peers
+// are set; their requests are not counted. It is safe to call on a nil
+// Service.
+func (s *Service) DisconnectAppPassword(id int64) int {
+	if s == nil {
+		return 0
+	}
+	n := s.disconnect(id)
+	if s.peers != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := s.peers.Publish(ctx, revokedChannel, strconv.FormatInt(id, 10)); err != nil {
+			log.Printf("failed to tell other replicas app password %d was revoked: %v", id, err)
+		}
+	}
+	return n
+}
+
+func (s *Service) disconnect(id int64) int {
+	s.creds.invalidate(id)
+	return s.requests.cancel(id)
+}
+
+// revokedChannel carries the IDs of revoked app passwords between replicas.
+const revokedChannel = "calcard:app-password-revoked"
+
+// Peers connects the replicas of a deployment; *redis.Client implements it.
+type Peers interface {
+	Publish(ctx context.Context, channel, message string) error
+	Subscribe(ctx context.Context, channel string, fn func(message string)) error
+}
+
+// SetPeers makes revocations reach the other replicas, which otherwise
+// keep accepting a revoked app password until their credential cache
+// expires. Call it before serving requests.
+func (s *Service) SetPeers(peers Peers) {
+	s.peers = peers
+}
+
+// FollowPeers applies the revocations other replicas announce until ctx is
+// cancelled.
+func (s *Service) FollowPeers(ctx context.Context) {
+	if s.peers == nil {
+		return
+	}
+	err := s.peers.Subscribe(ctx, revokedChannel, func(message string) {
+		if id, err := strconv.ParseInt(message, 10, 64); err == nil {
+			s.disconnect(id)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("stopped following app password revocations: %v", err)
+	}
+}
+
+// clientIP returns the address a DAV request came from, honouring trusted
+// proxies the same way sessions do.
+func (s *Service) clientIP(r *http.Request) string {
+	if s.sessions != nil {
+		return s.sessions.getClientIP(r)
+	}
+	ip, host := parseRemoteAddr(r.RemoteAddr)
+	if ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequireDAVAuthRecordsDeviceAndRevocationAbortsRequest(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("app-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	var touchedUA, touchedIP string
+	service := &Service{
+		store: &store.Store{
+			Users: &userRepoMock{
+				getByEmailFn: func(_ context.Context, email string) (*store.User, error) {
+					return &store.User{ID: 7, PrimaryEmail: email}, nil
+				},
+			},
+			AppPasswords: &appPasswordRepoMock{
+				findValidByUserFn: func(_ context.Context, userID int64) ([]store.AppPassword, error) {
+					return []store.AppPassword{{ID: 3, TokenHash: string(hash)}}, nil
+				},
+				touchLastUsedFn: func(_ context.Context, id int64, userAgent, ipAddress string) error {
+					touchedUA, touchedIP = userAgent, ipAddress
+					return nil
+				},
+			},
+		},
+	}
+
+	started := make(chan struct{})
+	aborted := make(chan error, 1)
+	handler := service.RequireDAVAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			aborted <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			aborted <- nil
+		}
+	}))
+
+	req := httptest.NewRequest("PROPFIND", "/dav/", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "DAVx5/4.3")
+	req.SetBasicAuth("user@example.com", "app-secret")
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	<-started
+	if touchedUA != "DAVx5/4.3" || touchedIP != "203.0.113.7" {
+		t.Fatalf("TouchLastUsed() got %q %q", touchedUA, touchedIP)
+	}
+	if n := service.DisconnectAppPassword(3); n != 1 {
+		t.Fatalf("DisconnectAppPassword() = %d, want 1", n)
+	}
+	if err := <-aborted; err != context.Canceled {
+		t.Fatalf("expected the in-flight request to be cancelled, got %v", err)
+	}
+	<-done
+	if n := service.DisconnectAppPassword(3); n != 0 {
+		t.Fatalf("expected no requests left, got %d", n)
+	}
+}
+
+func TestAppPasswordRequestsCancelsLateArrivals(t *testing.T) {
+	var requests appPasswordRequests
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests.cancel(5)
+
+	untrack := requests.track(5, cancel)
+	defer untrack()
+	if ctx.Err() == nil {
+		t.Fatal("expected a request on a revoked password to be cancelled")
+	}
+	if (*Service)(nil).DisconnectAppPassword(5) != 0 {
+		t.Fatal("expected a nil service to disconnect nothing")
+	}
+}
+
+// fakePeers delivers published messages to every subscriber in-process.
+type fakePeers struct {
+	subscribers []func(string)
+	subscribed  chan struct{}
+}
+
+func (f *fakePeers) Publish(ctx context.Context, channel, message string) error {
+	for _, fn := range f.subscribers {
+		fn(message)
+	}
+	return nil
+}
+
+func (f *fakePeers) Subscribe(ctx context.Context, channel string, fn func(string)) error {
+	f.subscribers = append(f.subscribers, fn)
+	f.subscribed <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDisconnectAppPasswordReachesOtherReplicas(t *testing.T) {
+	peers := &fakePeers{subscribed: make(chan struct{})}
+	local, remote := &Service{}, &Service{}
+	local.SetPeers(peers)
+	remote.SetPeers(peers)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remote.FollowPeers(ctx)
+	<-peers.subscribed
+
+	remote.creds.ttl = time.Minute
+	remote.creds.put("user@example.com", "app-secret", 7, 3, store.APIRoleAdmin, nil)
+	reqCtx, reqCancel := context.WithCancel(context.Background())
+	defer reqCancel()
+	untrack := remote.requests.track(3, reqCancel)
+	defer untrack()
+
+	local.DisconnectAppPassword(3)
+	if _, ok := remote.creds.get("user@example.com", "app-secret"); ok {
+		t.Fatal("the other replica still accepts the revoked password from its cache")
+	}
+	if reqCtx.Err() == nil {
+		t.Fatal("the other replica's request was not cancelled")
+	}
+}
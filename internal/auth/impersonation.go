@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ImpersonationTokenPrefix starts every impersonation token, so one is never
+// mistaken for an app password or a directory password.
+const ImpersonationTokenPrefix = "calcard-imp-"
+
+const (
+	// DefaultImpersonationTTL is how long an impersonation lasts when the
+	// admin does not say.
+	DefaultImpersonationTTL = 15 * time.Minute
+	// MaxImpersonationTTL bounds how long an impersonation can last.
+	MaxImpersonationTTL = time.Hour
+)
+
+// Errors returned by Impersonate.
+var (
+	ErrImpersonationReason = errors.New("a reason is required")
+	ErrImpersonationScope  = errors.New(`scope must be "read" or "write"`)
+	ErrImpersonationTTL    = fmt.Errorf("duration must be between 1m and %s", MaxImpersonationTTL)
+	ErrImpersonateSelf     = errors.New("admins cannot impersonate themselves")
+)
+
+// Impersonate lets admin act as the user with targetID for ttl, over DAV and
+// the JSON API, by signing in with the user's email and the returned token.
+// The token is shown once; only its hash is stored. Starting, every request
+// and ending are logged and kept in the audit trail, and the start appears
+// in the user's own sign-in history. r is the admin's request.
+func (s *Service) Impersonate(r *http.Request, admin *store.User, targetID int64, scope, reason string, ttl time.Duration) (string, *store.Impersonation, error) {
+	ctx := r.Context()
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", nil, ErrImpersonationReason
+	}
+	if scope == "" {
+		scope = store.ImpersonationRead
+	}
+	if scope != store.ImpersonationRead && scope != store.ImpersonationWrite {
+		return "", nil, ErrImpersonationScope
+	}
+	if ttl == 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	if ttl < time.Minute || ttl > MaxImpersonationTTL {
+		return "", nil, ErrImpersonationTTL
+	}
+	if targetID == admin.ID {
+		return "", nil, ErrImpersonateSelf
+	}
+	target, err := s.store.Users.GetByID(ctx, targetID)
+	if err != nil {
+		return "", nil, err
+	}
+	if target == nil {
+		return "", nil, store.ErrNotFound
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := ImpersonationTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	adminID := admin.ID
+	imp, err := s.store.Impersonations.Create(ctx, store.Impersonation{
+		AdminUserID: &adminID,
+		AdminEmail:  admin.PrimaryEmail,
+		UserID:      target.ID,
+		Scope:       scope,
+		Reason:      reason,
+		TokenHash:   aliasTokenHash(token),
+		ExpiresAt:   time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	ip := s.clientIP(r)
+	log.Printf("IMPERSONATION %d STARTED: admin %q is acting as %q (%s) until %s from %s: %s",
+		imp.ID, admin.PrimaryEmail, target.PrimaryEmail, scope, imp.ExpiresAt.UTC().Format(time.RFC3339), ip, reason)
+	if s.store.AuthEvents != nil {
+		s.recordAuthEvent(ctx, store.AuthEvent{UserID: target.ID, Outcome: store.AuthOutcomeImpersonated, IPAddress: ip, UserAgent: r.UserAgent()})
+	}
+	return token, imp, nil
+}
+
+// EndImpersonation ends an impersonation before it expires and aborts the
+// requests still running on it. It reports whether it was still active.
+func (s *Service) EndImpersonation(ctx context.Context, admin *store.User, id int64) (bool, error) {
+	ended, err := s.store.Impersonations.End(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if ended {
+		n := s.impersonating.cancel(id)
+		log.Printf("IMPERSONATION %d ENDED by admin %q, %d requests aborted", id, admin.PrimaryEmail, n)
+	}
+	return ended, nil
+}
+
+// serveImpersonated authenticates a request made with an impersonation
+// token and serves it as the impersonated user. Read-only impersonations
+// are refused anything but reads. Writes are attributed to the admin in the
+// change feed.
+func (s *Service) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, username, token string) {
+	ctx := r.Context()
+	ip := s.clientIP(r)
+	imp, user, err := s.validateImpersonation(ctx, username, token)
+	if err != nil {
+		log.Printf("IMPERSONATION REFUSED for %q from %s: %v", username, ip, err)
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if imp.Scope == store.ImpersonationRead && !readOnlyMethod(r.Method) {
+		s.auditImpersonation(imp, r, ip, http.StatusForbidden)
+		http.Error(w, "impersonation is read-only", http.StatusForbidden)
+		return
+	}
+
+	ctx = WithUser(ctx, user)
+	if imp.AdminUserID != nil {
+		ctx = store.WithActor(ctx, *imp.AdminUserID)
+	}
+	ctx = WithImpersonation(ctx, imp)
+	// The scope, checked above, is what limits an impersonation.
+	ctx = WithAPIRole(ctx, store.APIRoleAdmin)
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithDeadline(ctx, imp.ExpiresAt)
+	defer cancel()
+	defer s.impersonating.track(imp.ID, cancel)()
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(sw, r.WithContext(ctx))
+	s.auditImpersonation(imp, r, ip, sw.status)
+}
+
+func (s *Service) validateImpersonation(ctx context.Context, username, token string) (*store.Impersonation, *store.User, error) {
+	if s.store.Impersonations == nil {
+		return nil, nil, errors.New("impersonation is not available")
+	}
+	imp, err := s.store.Impersonations.GetByTokenHash(ctx, aliasTokenHash(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if imp == nil {
+		return nil, nil, errors.New("unknown token")
+	}
+	if !imp.Active(time.Now()) {
+		return nil, nil, fmt.Errorf("impersonation %d has ended", imp.ID)
+	}
+	user, err := s.store.Users.GetByID(ctx, imp.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || !strings.EqualFold(user.PrimaryEmail, username) {
+		return nil, nil, fmt.Errorf("impersonation %d is for another user", imp.ID)
+	}
+	return imp, user, nil
+}
+
+// auditImpersonation logs a request made while impersonating and adds it to
+// the audit trail.
+func (s *Service) auditImpersonation(imp *store.Impersonation, r *http.Request, ip string, status int) {
+	log.Printf("IMPERSONATION %d REQUEST: admin %q as user %d: %s %s -> %d", imp.ID, imp.AdminEmail, imp.UserID, r.Method, r.URL.Path, status)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	defer cancel()
+	err := s.store.Impersonations.RecordRequest(ctx, store.ImpersonationRequest{
+		ImpersonationID: imp.ID,
+		Method:          r.Method,
+		Path:            r.URL.Path,
+		Status:          status,
+		IPAddress:       ip,
+		UserAgent:       r.UserAgent(),
+	})
+	if err != nil {
+		log.Printf("failed to record request of impersonation %d: %v", imp.ID, err)
+	}
+}
+
+// readOnlyMethod reports whether method only reads, over HTTP or WebDAV.
+func readOnlyMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "REPORT":
+		return true
+	}
+	return false
+}
+
+// statusWriter remembers the status written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeImpersonationRepo struct {
+	store.ImpersonationRepository
+	mu       sync.Mutex
+	grants   []store.Impersonation
+	requests []store.ImpersonationRequest
+}
+
+func (f *fakeImpersonationRepo) Create(_ context.Context, imp store.Impersonation) (*store.Impersonation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	imp.ID = int64(len(f.grants) + 1)
+	imp.CreatedAt = time.Now()
+	f.grants = append(f.grants, imp)
+	return &imp, nil
+}
+
+func (f *fakeImpersonationRepo) GetByTokenHash(_ context.Context, hash string) (*store.Impersonation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, imp := range f.grants {
+		if imp.TokenHash == hash {
+			return &imp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeImpersonationRepo) End(_ context.Context, id int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	imp := &f.grants[id-1]
+	if !imp.Active(time.Now()) {
+		return false, nil
+	}
+	now := time.Now()
+	imp.EndedAt = &now
+	return true, nil
+}
+
+func (f *fakeImpersonationRepo) RecordRequest(_ context.Context, req store.ImpersonationRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	return nil
+}
+
+func TestImpersonationServesReadsAsUserAndAuditsThem(t *testing.T) {
+	admin := &store.User{ID: 1, PrimaryEmail: "admin@example.com"}
+	target := &store.User{ID: 7, PrimaryEmail: "user@example.com"}
+	grants := &fakeImpersonationRepo{}
+	events := &fakeAuthEventRepo{}
+	service := &Service{store: &store.Store{
+		Users: &userRepoMock{getByIDFn: func(_ context.Context, id int64) (*store.User, error) {
+			if id == target.ID {
+				return target, nil
+			}
+			return nil, nil
+		}},
+		Impersonations: grants,
+		AuthEvents:     events,
+	}}
+
+	start := httptest.NewRequest(http.MethodPost, "/api/admin/impersonations", nil)
+	start.RemoteAddr = "198.51.100.9:4000"
+	if _, _, err := service.Impersonate(start, admin, target.ID, store.ImpersonationRead, " ", 0); !errors.Is(err, ErrImpersonationReason) {
+		t.Fatalf("Impersonate() without a reason = %v", err)
+	}
+	if _, _, err := service.Impersonate(start, admin, target.ID, "admin", "ticket 12", 0); !errors.Is(err, ErrImpersonationScope) {
+		t.Fatalf("Impersonate() with a bad scope = %v", err)
+	}
+	if _, _, err := service.Impersonate(start, admin, target.ID, "", "ticket 12", 2*time.Hour); !errors.Is(err, ErrImpersonationTTL) {
+		t.Fatalf("Impersonate() for too long = %v", err)
+	}
+	if _, _, err := service.Impersonate(start, admin, 99, "", "ticket 12", 0); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Impersonate() of an unknown user = %v", err)
+	}
+	token, imp, err := service.Impersonate(start, admin, target.ID, "", "ticket 12", 0)
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	if imp.Scope != store.ImpersonationRead || imp.TokenHash == token || time.Until(imp.ExpiresAt) > DefaultImpersonationTTL {
+		t.Fatalf("Impersonate() stored %+v", imp)
+	}
+	if e := events.last(); e.UserID != target.ID || e.Outcome != store.AuthOutcomeImpersonated || e.IPAddress != "198.51.100.9" {
+		t.Fatalf("sign-in history got %+v", e)
+	}
+
+	var servedAs *store.User
+	var servedImp *store.Impersonation
+	handler := service.RequireDAVAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servedAs, _ = UserFromContext(r.Context())
+		servedImp, _ = ImpersonationFromContext(r.Context())
+		w.WriteHeader(http.StatusMultiStatus)
+	}))
+	serve := func(method, username, password string) int {
+		req := httptest.NewRequest(method, "/dav/calendars/2/", nil)
+		req.SetBasicAuth(username, password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("REPORT", target.PrimaryEmail, token); code != http.StatusMultiStatus || servedAs != target || servedImp == nil || servedImp.ID != imp.ID {
+		t.Fatalf("REPORT = %d as %+v, %+v", code, servedAs, servedImp)
+	}
+	if code := serve(http.MethodPut, target.PrimaryEmail, token); code != http.StatusForbidden {
+		t.Fatalf("PUT with a read-only impersonation = %d, want 403", code)
+	}
+	if code := serve("REPORT", "someone@example.com", token); code != http.StatusUnauthorized {
+		t.Fatalf("REPORT as another user = %d, want 401", code)
+	}
+	if len(grants.requests) != 2 || grants.requests[0].Status != http.StatusMultiStatus || grants.requests[1].Method != http.MethodPut || grants.requests[1].Status != http.StatusForbidden {
+		t.Fatalf("audit trail = %+v", grants.requests)
+	}
+
+	if ended, err := service.EndImpersonation(context.Background(), admin, imp.ID); err != nil || !ended {
+		t.Fatalf("EndImpersonation() = %v, %v", ended, err)
+	}
+	if code := serve("REPORT", target.PrimaryEmail, token); code != http.StatusUnauthorized {
+		t.Fatalf("REPORT after the impersonation ended = %d, want 401", code)
+	}
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/ldap"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ErrInvalidPassword is returned by a PasswordVerifier that rejected the
+// credentials, as opposed to one that could not be asked.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// PasswordVerifier checks DAV Basic-auth credentials against an external
+// directory. On success it may return the email the user is known by;
+// empty leaves it to be derived from the username.
+type PasswordVerifier interface {
+	VerifyPassword(ctx context.Context, username, password string) (email string, err error)
+}
+
+// newPasswordVerifier returns the verifier cfg.PasswordAuth configures, or
+// nil when directory passwords are disabled.
+func newPasswordVerifier(cfg *config.Config) PasswordVerifier {
+	pa := cfg.PasswordAuth
+	switch pa.Backend {
+	case config.PasswordAuthLDAP:
+		return ldapBindVerifier{url: pa.LDAPURL, bindDN: pa.LDAPBindDN}
+	case config.PasswordAuthCommand:
+		return commandVerifier{args: strings.Fields(pa.Command)}
+	case config.PasswordAuthHTTP:
+		return httpVerifier{url: pa.HTTPURL, client: &http.Client{Timeout: pa.Timeout}}
+	}
+	return nil
+}
+
+// ldapBindVerifier accepts credentials the directory accepts a simple bind
+// with.
+type ldapBindVerifier struct {
+	url    string
+	bindDN string
+}
+
+func (v ldapBindVerifier) VerifyPassword(ctx context.Context, username, password string) (string, error) {
+	err := ldap.Bind(ctx, v.url, ldap.BindDN(v.bindDN, username), password)
+	if errors.Is(err, ldap.ErrInvalidCredentials) {
+		return "", ErrInvalidPassword
+	}
+	return "", err
+}
+
+// commandVerifier runs a checker such as pwauth, which reads the username
+// and password from standard input and exits 0 when they are valid. This
+// is how PAM is reached.
+type commandVerifier struct {
+	args []string
+}
+
+func (v commandVerifier) VerifyPassword(ctx context.Context, username, password string) (string, error) {
+	if strings.ContainsAny(username, "\r\n") || strings.ContainsAny(password, "\r\n") {
+		return "", ErrInvalidPassword
+	}
+	cmd := exec.CommandContext(ctx, v.args[0], v.args[1:]...)
+	cmd.Stdin = strings.NewReader(username + "\n" + password + "\n")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "", nil
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		return "", ErrInvalidPassword
+	default:
+		return "", fmt.Errorf("password command: %w", err)
+	}
+}
+
+// httpVerifier posts the credentials as JSON to a verification endpoint. A
+// 2xx response accepts them and may name the user's email; 401 and 403
+// reject them.
+type httpVerifier struct {
+	url    string
+	client *http.Client
+}
+
+func (v httpVerifier) VerifyPassword(ctx context.Context, username, password string) (string, error) {
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", ErrInvalidPassword
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", fmt.Errorf("password endpoint returned %s", resp.Status)
+	}
+	var result struct {
+		Email string `json:"email"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(raw, &result)
+	return strings.TrimSpace(result.Email), nil
+}
+
+// validateDirectoryPassword checks the credentials with the configured
+// PasswordVerifier and returns the user they belong to, creating it when
+// provisioning is enabled. It returns ErrInvalidPassword when the
+// directory rejects them or they map to no user.
+func (s *Service) validateDirectoryPassword(ctx context.Context, username, password string) (*store.User, error) {
+	if s.dirCreds.enabled() {
+		if cached, ok := s.dirCreds.get(username, password); ok {
+			user, err := s.store.Users.GetByID(ctx, cached.userID)
+			if err != nil {
+				return nil, err
+			}
+			if user != nil {
+				return user, nil
+			}
+		}
+	}
+
+	timeout := s.cfg.PasswordAuth.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	verifyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	email, err := s.passwords.VerifyPassword(verifyCtx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	if email == "" {
+		email = directoryEmail(username, s.cfg.PasswordAuth.EmailDomain)
+	}
+	if email == "" {
+		return nil, ErrInvalidPassword
+	}
+
+	user, err := s.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if !s.cfg.PasswordAuth.Provision {
+			return nil, ErrInvalidPassword
+		}
+		user, err = s.store.Users.UpsertOAuthUser(ctx, "directory:"+strings.ToLower(email), email)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.store.EnsureDefaultCollections(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		log.Printf("provisioned user %q from %s password authentication", email, s.cfg.PasswordAuth.Backend)
+	}
+	s.dirCreds.put(username, password, user.ID, 0, "", nil)
+	return user, nil
+}
+
+// directoryEmail is the email a directory sign-in name stands for: the name
+// itself when it is an address, or the name at domain.
+func directoryEmail(username, domain string) string {
+	username = strings.TrimSpace(username)
+	if strings.Contains(username, "@") {
+		return username
+	}
+	if domain == "" {
+		return ""
+	}
+	return username + "@" + domain
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeVerifier struct {
+	calls int
+	err   error
+}
+
+func (f *fakeVerifier) VerifyPassword(_ context.Context, username, password string) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	if password != "directory-secret" {
+		return "", ErrInvalidPassword
+	}
+	return "", nil
+}
+
+func TestRequireDAVAuthAcceptsDirectoryPasswords(t *testing.T) {
+	alice := &store.User{ID: 4, PrimaryEmail: "alice@example.com"}
+	cfg := &config.Config{}
+	cfg.PasswordAuth.Backend = config.PasswordAuthCommand
+	cfg.PasswordAuth.EmailDomain = "example.com"
+	verifier := &fakeVerifier{}
+	service := &Service{
+		cfg: cfg,
+		store: &store.Store{
+			Users: &userRepoMock{
+				getByEmailFn: func(_ context.Context, email string) (*store.User, error) {
+					if email == alice.PrimaryEmail {
+						return alice, nil
+					}
+					return nil, nil
+				},
+				getByIDFn: func(context.Context, int64) (*store.User, error) { return alice, nil },
+			},
+			AppPasswords: &appPasswordRepoMock{
+				findValidByUserFn: func(context.Context, int64) ([]store.AppPassword, error) { return nil, nil },
+			},
+		},
+		passwords: verifier,
+		dirCreds:  credentialCache{ttl: time.Minute},
+	}
+
+	var gotUser *store.User
+	var hasPasswordID bool
+	var role string
+	handler := service.RequireDAVAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = UserFromContext(r.Context())
+		_, hasPasswordID = AppPasswordIDFromContext(r.Context())
+		role = APIRoleFromContext(r.Context())
+	}))
+	serve := func(username, password string) int {
+		req := httptest.NewRequest("PROPFIND", "/dav/", nil)
+		req.SetBasicAuth(username, password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve("alice", "directory-secret"); code != http.StatusOK || gotUser != alice || hasPasswordID || role != store.APIRoleAdmin {
+			t.Fatalf("request %d: status %d, user %+v, app password set %v, role %q", i, code, gotUser, hasPasswordID, role)
+		}
+	}
+	if verifier.calls != 1 {
+		t.Fatalf("asked the directory %d times, want 1", verifier.calls)
+	}
+	if code := serve("alice", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password status = %d", code)
+	}
+	// Without provisioning, directory users need an existing account.
+	if code := serve("bob", "directory-secret"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown user status = %d", code)
+	}
+
+	verifier.err = errors.New("directory unreachable")
+	if code := serve("carol@example.com", "directory-secret"); code != http.StatusUnauthorized {
+		t.Fatalf("unreachable directory status = %d", code)
+	}
+}
+
+func TestHTTPVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var creds map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&creds)
+		switch {
+		case creds["username"] == "alice" && creds["password"] == "secret":
+			_ = json.NewEncoder(w).Encode(map[string]string{"email": "alice@corp.example"})
+		case creds["username"] == "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	v := httpVerifier{url: srv.URL, client: srv.Client()}
+
+	if email, err := v.VerifyPassword(context.Background(), "alice", "secret"); err != nil || email != "alice@corp.example" {
+		t.Fatalf("VerifyPassword() = %q, %v", email, err)
+	}
+	if _, err := v.VerifyPassword(context.Background(), "alice", "nope"); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("VerifyPassword() with a bad password error = %v, want ErrInvalidPassword", err)
+	}
+	if _, err := v.VerifyPassword(context.Background(), "broken", "secret"); err == nil || errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("VerifyPassword() on a server error = %v, want an unavailability error", err)
+	}
+}
+
+func TestCommandVerifier(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	script := filepath.Join(t.TempDir(), "check")
+	body := "#!/bin/sh\nread user\nread pass\n[ \"$user\" = alice ] && [ \"$pass\" = secret ]\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	v := commandVerifier{args: []string{script}}
+
+	if _, err := v.VerifyPassword(context.Background(), "alice", "secret"); err != nil {
+		t.Fatalf("VerifyPassword() error = %v", err)
+	}
+	for _, password := range []string{"wrong", "secret\nalice"} {
+		if _, err := v.VerifyPassword(context.Background(), "alice", password); !errors.Is(err, ErrInvalidPassword) {
+			t.Fatalf("VerifyPassword(%q) error = %v, want ErrInvalidPassword", password, err)
+		}
+	}
+	missing := commandVerifier{args: []string{filepath.Join(t.TempDir(), "missing")}}
+	if _, err := missing.VerifyPassword(context.Background(), "alice", "secret"); err == nil || errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("VerifyPassword() with a missing command error = %v, want an unavailability error", err)
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ErrAPIRole reports an unknown REST API role.
+var ErrAPIRole = errors.New(`API role must be "viewer", "editor" or "admin"`)
+
+// apiRoleRank orders the REST API roles; each allows what those below it do.
+var apiRoleRank = map[string]int{
+	store.APIRoleViewer: 1,
+	store.APIRoleEditor: 2,
+	store.APIRoleAdmin:  3,
+}
+
+// ValidAPIRole reports whether role is a REST API role.
+func ValidAPIRole(role string) bool {
+	_, ok := apiRoleRank[role]
+	return ok
+}
+
+// APIRoleAllows reports whether a credential with role may make requests
+// that need the role need. Unknown roles allow nothing.
+func APIRoleAllows(role, need string) bool {
+	have, ok := apiRoleRank[role]
+	return ok && have >= apiRoleRank[need]
+}
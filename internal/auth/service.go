@@ -10,11 +10,14 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/metrics"
 	"github.com/jw6ventures/calcard/internal/store"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
@@ -29,6 +32,22 @@ type Service struct {
 	userinfo string
 	provider *oidc.Provider
 	verifier *oidc.IDTokenVerifier
+	requests appPasswordRequests
+	// impersonating tracks the requests made under each impersonation, so
+	// ending one early aborts them.
+	impersonating appPasswordRequests
+	creds         credentialCache
+	// peers tells other replicas about revoked app passwords; nil on a
+	// single node.
+	peers Peers
+	// passwords verifies directory passwords; nil when only app passwords
+	// are accepted. dirCreds caches the credentials it accepted.
+	passwords PasswordVerifier
+	dirCreds  credentialCache
+	// signIns tracks DAV sign-ins for new-address alerts and the soft
+	// lockout; notifier sends the alerts and is nil without SMTP.
+	signIns  signInMonitor
+	notifier signInNotifier
 }
 
 func NewService(cfg *config.Config, st *store.Store, sessions *SessionManager) (*Service, error) {
@@ -55,13 +74,22 @@ func NewService(cfg *config.Config, st *store.Store, sessions *SessionManager) (
 
 	verifier := provider.Verifier(&oidc.Config{ClientID: cfg.OAuth.ClientID})
 
+	var notifier signInNotifier
+	if mailer := mail.New(cfg); mailer != nil {
+		notifier = mailer
+	}
+
 	return &Service{cfg: cfg, store: st, sessions: sessions, userinfo: oidcConfig.UserinfoEndpoint, provider: provider, verifier: verifier, oauthCfg: &oauth2.Config{
 		ClientID:     cfg.OAuth.ClientID,
 		ClientSecret: cfg.OAuth.ClientSecret,
 		RedirectURL:  redirectURL,
 		Endpoint:     provider.Endpoint(),
 		Scopes:       []string{"openid", "email", "profile"},
-	}}, nil
+	}, creds: credentialCache{ttl: cfg.DAV.AuthCacheTTL},
+		passwords: newPasswordVerifier(cfg),
+		dirCreds:  credentialCache{ttl: cfg.DAV.AuthCacheTTL},
+		notifier:  notifier,
+	}, nil
 }
 
 func (s *Service) BeginOAuth(w http.ResponseWriter, r *http.Request) {
@@ -131,19 +159,37 @@ func (s *Service) HandleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
+// NewAppPasswordToken generates a random app password and returns it with
+// the bcrypt hash to store in its place.
+func NewAppPasswordToken() (plaintext, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	plaintext = base64.RawURLEncoding.EncodeToString(buf)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return plaintext, string(hashed), nil
+}
+
 // CreateAppPassword generates a random token, hashes it, stores it, and returns the plaintext.
-func (s *Service) CreateAppPassword(ctx context.Context, userID int64, label string, expiresAt *time.Time) (string, *store.AppPassword, error) {
+// apiRole limits what the token may do through the REST API; empty means
+// store.APIRoleEditor, so a password only manages shares, groups and
+// devices when asked to.
+func (s *Service) CreateAppPassword(ctx context.Context, userID int64, label, apiRole string, expiresAt *time.Time) (string, *store.AppPassword, error) {
 	if label == "" {
 		return "", nil, errors.New("label required")
 	}
-
-	buf := make([]byte, 32)
-	if _, err := rand.Read(buf); err != nil {
-		return "", nil, err
+	if apiRole == "" {
+		apiRole = store.APIRoleEditor
+	}
+	if !ValidAPIRole(apiRole) {
+		return "", nil, ErrAPIRole
 	}
-	plaintext := base64.RawURLEncoding.EncodeToString(buf)
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.DefaultCost)
+	plaintext, hash, err := NewAppPasswordToken()
 	if err != nil {
 		return "", nil, err
 	}
@@ -151,8 +197,9 @@ func (s *Service) CreateAppPassword(ctx context.Context, userID int64, label str
 	created, err := s.store.AppPasswords.Create(ctx, store.AppPassword{
 		UserID:    userID,
 		Label:     label,
-		TokenHash: string(hash),
+		TokenHash: hash,
 		ExpiresAt: expiresAt,
+		APIRole:   apiRole,
 	})
 	if err != nil {
 		return "", nil, err
@@ -162,17 +209,41 @@ func (s *Service) CreateAppPassword(ctx context.Context, userID int64, label str
 }
 
 func (s *Service) ValidateAppPassword(ctx context.Context, username, password string) (*store.User, error) {
+	user, _, err := s.validateAppPassword(ctx, username, password, "", "")
+	return user, err
+}
+
+// validateAppPassword also returns the matching app password so callers can
+// tell which device made the request, and records the device's User-Agent and
+// IP address as the password's last use. Recently verified credentials are
+// served from the credential cache without a bcrypt comparison.
+func (s *Service) validateAppPassword(ctx context.Context, username, password, userAgent, ipAddress string) (*store.User, *store.AppPassword, error) {
+	if s.creds.enabled() {
+		if cached, ok := s.creds.get(username, password); ok {
+			user, err := s.store.Users.GetByID(ctx, cached.userID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if user != nil {
+				metrics.IncDAVAuthCache("hit")
+				_ = s.store.AppPasswords.TouchLastUsed(ctx, cached.appPasswordID, userAgent, ipAddress)
+				return user, &store.AppPassword{ID: cached.appPasswordID, UserID: user.ID, APIRole: cached.apiRole}, nil
+			}
+		}
+		metrics.IncDAVAuthCache("miss")
+	}
+
 	user, err := s.store.Users.GetByEmail(ctx, username)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if user == nil {
-		return nil, errors.New("unknown user")
+		return nil, nil, errors.New("unknown user")
 	}
 
 	tokens, err := s.store.AppPasswords.FindValidByUser(ctx, user.ID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, t := range tokens {
@@ -183,12 +254,40 @@ func (s *Service) ValidateAppPassword(ctx context.Context, username, password st
 			continue
 		}
 		if bcrypt.CompareHashAndPassword([]byte(t.TokenHash), []byte(password)) == nil {
-			_ = s.store.AppPasswords.TouchLastUsed(ctx, t.ID)
-			return user, nil
+			_ = s.store.AppPasswords.TouchLastUsed(ctx, t.ID, userAgent, ipAddress)
+			s.creds.put(username, password, user.ID, t.ID, t.APIRole, t.ExpiresAt)
+			token := t
+			return user, &token, nil
+		}
+	}
+
+	return nil, nil, errors.New("invalid app password")
+}
+
+// authenticateBasic checks Basic-auth credentials against the user's app
+// passwords and then, when one is configured, the password directory. App
+// passwords go first so devices set up with them are unaffected by a
+// directory outage and never count as failed directory logins. The app
+// password is nil for a directory sign-in.
+func (s *Service) authenticateBasic(ctx context.Context, username, password, userAgent, ipAddress string) (*store.User, *store.AppPassword, error) {
+	user, token, err := s.validateAppPassword(ctx, username, password, userAgent, ipAddress)
+	if err == nil || s.passwords == nil {
+		return user, token, err
+	}
+	user, dirErr := s.validateDirectoryPassword(ctx, username, password)
+	if dirErr != nil {
+		if !errors.Is(dirErr, ErrInvalidPassword) {
+			log.Printf("%s password authentication for %q failed: %v", s.cfg.PasswordAuth.Backend, username, dirErr)
 		}
+		return nil, nil, err
 	}
+	return user, nil, nil
+}
 
-	return nil, errors.New("invalid app password")
+// Reconfigure applies a reloaded DAV credential cache lifetime.
+func (s *Service) Reconfigure(cfg *config.Config) {
+	s.creds.setTTL(cfg.DAV.AuthCacheTTL)
+	s.dirCreds.setTTL(cfg.DAV.AuthCacheTTL)
 }
 
 func (s *Service) RequireSession(next http.Handler) http.Handler {
@@ -224,14 +323,39 @@ func (s *Service) RequireDAVAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(password, ImpersonationTokenPrefix) {
+			s.serveImpersonated(w, r, next, username, password)
+			return
+		}
+
 		ctx := r.Context()
-		user, err := s.ValidateAppPassword(ctx, username, password)
+		ip := s.clientIP(r)
+		user, token, err := s.authenticateBasic(ctx, username, password, r.UserAgent(), ip)
 		if err != nil {
+			s.recordFailedSignIn(ctx, username, ip, r.UserAgent())
 			http.Error(w, "invalid credentials", http.StatusUnauthorized)
 			return
 		}
+		if !s.admitSignIn(ctx, user, token, ip, r.UserAgent()) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.cfg.SignIn.LockoutWindow.Seconds())))
+			http.Error(w, "too many failed sign-ins; new addresses are refused for now", http.StatusTooManyRequests)
+			return
+		}
 
 		ctx = WithUser(ctx, user)
+		if token != nil {
+			// Revoking the password cancels this context, aborting the request.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+			defer s.requests.track(token.ID, cancel)()
+			ctx = WithAppPasswordID(ctx, token.ID)
+			ctx = WithAPIRole(ctx, token.APIRole)
+		} else {
+			// Directory passwords sign in as the user, with every role
+			// the user has.
+			ctx = WithAPIRole(ctx, store.APIRoleAdmin)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -38,13 +38,18 @@ func (m *userRepoMock) GetByID(ctx context.Context, id int64) (*store.User, erro
 func (m *userRepoMock) GetByEmail(ctx context.Context, email string) (*store.User, error) {
 	return m.getByEmailFn(ctx, email)
 }
-func (m *userRepoMock) ListActive(context.Context) ([]store.User, error) { return nil, nil }
-func (m *userRepoMock) MarkOnboardingComplete(context.Context, int64) error { return nil }
+func (m *userRepoMock) ListActive(context.Context) ([]store.User, error)        { return nil, nil }
+func (m *userRepoMock) MarkInternal(context.Context, int64) error               { return nil }
+func (m *userRepoMock) MarkOnboardingComplete(context.Context, int64) error     { return nil }
+func (m *userRepoMock) SetSyncHideCancelled(context.Context, int64, bool) error { return nil }
+func (m *userRepoMock) SetPreferences(context.Context, int64, string, string, *time.Weekday) error {
+	return nil
+}
 
 type appPasswordRepoMock struct {
 	createFn          func(context.Context, store.AppPassword) (*store.AppPassword, error)
 	findValidByUserFn func(context.Context, int64) ([]store.AppPassword, error)
-	touchLastUsedFn   func(context.Context, int64) error
+	touchLastUsedFn   func(context.Context, int64, string, string) error
 }
 
 func (m *appPasswordRepoMock) Create(ctx context.Context, token store.AppPassword) (*store.AppPassword, error) {
@@ -61,9 +66,9 @@ func (m *appPasswordRepoMock) GetByID(context.Context, int64) (*store.AppPasswor
 }
 func (m *appPasswordRepoMock) Revoke(context.Context, int64) error        { return nil }
 func (m *appPasswordRepoMock) DeleteRevoked(context.Context, int64) error { return nil }
-func (m *appPasswordRepoMock) TouchLastUsed(ctx context.Context, id int64) error {
+func (m *appPasswordRepoMock) TouchLastUsed(ctx context.Context, id int64, userAgent, ipAddress string) error {
 	if m.touchLastUsedFn != nil {
-		return m.touchLastUsedFn(ctx, id)
+		return m.touchLastUsedFn(ctx, id, userAgent, ipAddress)
 	}
 	return nil
 }
@@ -183,7 +188,7 @@ func TestCreateAndValidateAppPassword(t *testing.T) {
 						{ID: 77, TokenHash: stored.TokenHash},
 					}, nil
 				},
-				touchLastUsedFn: func(_ context.Context, id int64) error {
+				touchLastUsedFn: func(_ context.Context, id int64, _, _ string) error {
 					touched = id
 					return nil
 				},
@@ -191,16 +196,19 @@ func TestCreateAndValidateAppPassword(t *testing.T) {
 		},
 	}
 
-	plaintext, created, err := service.CreateAppPassword(context.Background(), user.ID, "laptop", nil)
+	plaintext, created, err := service.CreateAppPassword(context.Background(), user.ID, "laptop", "", nil)
 	if err != nil {
 		t.Fatalf("CreateAppPassword() error = %v", err)
 	}
 	if plaintext == "" || created == nil || created.ID != 77 {
 		t.Fatalf("CreateAppPassword() = %q %#v", plaintext, created)
 	}
-	if stored.Label != "laptop" || stored.UserID != user.ID {
+	if stored.Label != "laptop" || stored.UserID != user.ID || stored.APIRole != store.APIRoleEditor {
 		t.Fatalf("stored token = %#v", stored)
 	}
+	if _, _, err := service.CreateAppPassword(context.Background(), user.ID, "bot", "owner", nil); !errors.Is(err, ErrAPIRole) {
+		t.Fatalf("CreateAppPassword(unknown role) error = %v, want ErrAPIRole", err)
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(stored.TokenHash), []byte(plaintext)); err != nil {
 		t.Fatalf("stored hash does not match plaintext: %v", err)
 	}
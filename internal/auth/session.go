@@ -17,7 +17,8 @@ import (
 
 const (
 	sessionCookieName = "calcard_session"
-	sessionDuration   = 7 * 24 * time.Hour
+	// sessionDuration is the idle timeout used when none is configured.
+	sessionDuration = 7 * 24 * time.Hour
 )
 
 type SessionManager struct {
@@ -25,6 +26,11 @@ type SessionManager struct {
 	store          *store.Store
 	secure         bool
 	trustedProxies []*net.IPNet
+	idleTimeout    time.Duration
+	maxLifetime    time.Duration
+	bindIP         bool
+	bindUserAgent  bool
+	now            func() time.Time
 }
 
 func NewSessionManager(cfg *config.Config, st *store.Store) *SessionManager {
@@ -38,14 +44,67 @@ func NewSessionManager(cfg *config.Config, st *store.Store) *SessionManager {
 		store:          st,
 		secure:         secure,
 		trustedProxies: parseTrustedProxies(cfg.TrustedProxies),
+		idleTimeout:    cfg.Session.IdleTimeout,
+		maxLifetime:    cfg.Session.MaxLifetime,
+		bindIP:         cfg.Session.BindIP,
+		bindUserAgent:  cfg.Session.BindUserAgent,
 	}
 }
 
+// StartCleanup deletes expired sessions every configured cleanup interval
+// until ctx is cancelled.
+func (m *SessionManager) StartCleanup(ctx context.Context) {
+	if m.cfg.Session.CleanupInterval <= 0 {
+		return
+	}
+	store.StartSessionCleanup(ctx, m.store.Sessions, m.cfg.Session.CleanupInterval)
+}
+
+// expiry returns when a session created at createdAt expires if it is used
+// at now: one idle timeout later, capped at its absolute lifetime.
+func (m *SessionManager) expiry(createdAt, now time.Time) time.Time {
+	idle := m.idleTimeout
+	if idle <= 0 {
+		idle = sessionDuration
+	}
+	expiresAt := now.Add(idle)
+	if m.maxLifetime > 0 {
+		if deadline := createdAt.Add(m.maxLifetime); deadline.Before(expiresAt) {
+			return deadline
+		}
+	}
+	return expiresAt
+}
+
+func (m *SessionManager) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// boundTo reports whether the request matches the IP address and User-Agent
+// the session was issued to, for whichever bindings are enabled.
+func (m *SessionManager) boundTo(session *store.Session, r *http.Request) bool {
+	if m.bindIP && session.IPAddress != nil && *session.IPAddress != m.getClientIP(r) {
+		return false
+	}
+	if m.bindUserAgent && session.UserAgent != nil && *session.UserAgent != r.UserAgent() {
+		return false
+	}
+	return true
+}
+
+// Issue starts a new session for userID and sets its cookie. Any session the
+// request already carried is revoked, so signing in always rotates the ID.
 func (m *SessionManager) Issue(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64) error {
 	sessionID, err := generateSessionID()
 	if err != nil {
 		return err
 	}
+	if c, err := r.Cookie(sessionCookieName); err == nil && c.Value != "" {
+		_ = m.store.Sessions.Delete(ctx, c.Value)
+	}
 
 	var userAgent, ipAddress *string
 	if ua := r.UserAgent(); ua != "" {
@@ -55,7 +114,14 @@ func (m *SessionManager) Issue(ctx context.Context, w http.ResponseWriter, r *ht
 		ipAddress = util.StrPtr(ip)
 	}
 
-	expiresAt := time.Now().Add(sessionDuration)
+	now := m.clock()
+	expiresAt := m.expiry(now, now)
+	// The cookie lasts the session's whole lifetime; the server enforces the
+	// idle timeout as the session slides.
+	cookieExpires := expiresAt
+	if m.maxLifetime > 0 {
+		cookieExpires = now.Add(m.maxLifetime)
+	}
 
 	_, err = m.store.Sessions.Create(ctx, store.Session{
 		ID:        sessionID,
@@ -72,7 +138,7 @@ func (m *SessionManager) Issue(ctx context.Context, w http.ResponseWriter, r *ht
 		Name:     sessionCookieName,
 		Value:    sessionID,
 		Path:     "/",
-		Expires:  expiresAt,
+		Expires:  cookieExpires,
 		HttpOnly: true,
 		Secure:   m.secure,
 		SameSite: http.SameSiteLaxMode,
@@ -96,6 +162,9 @@ func (m *SessionManager) Clear(ctx context.Context, w http.ResponseWriter, r *ht
 	})
 }
 
+// GetSession returns the live session named by the request's cookie and
+// slides its expiry forward. A session past its absolute lifetime, or used
+// from another IP address or browser when binding is on, is revoked.
 func (m *SessionManager) GetSession(ctx context.Context, r *http.Request) (*store.Session, error) {
 	c, err := r.Cookie(sessionCookieName)
 	if err != nil {
@@ -113,9 +182,17 @@ func (m *SessionManager) GetSession(ctx context.Context, r *http.Request) (*stor
 		return nil, nil
 	}
 
+	now := m.clock()
+	if (m.maxLifetime > 0 && !now.Before(session.CreatedAt.Add(m.maxLifetime))) || !m.boundTo(session, r) {
+		_ = m.store.Sessions.Delete(ctx, session.ID)
+		return nil, nil
+	}
+
+	expiresAt := m.expiry(session.CreatedAt, now)
 	go func() {
-		_ = m.store.Sessions.TouchLastSeen(context.Background(), session.ID)
+		_ = m.store.Sessions.TouchLastSeen(context.Background(), session.ID, expiresAt)
 	}()
+	session.ExpiresAt = expiresAt
 
 	return session, nil
 }
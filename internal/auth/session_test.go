@@ -15,7 +15,7 @@ import (
 type sessionRepoMock struct {
 	createFn        func(context.Context, store.Session) (*store.Session, error)
 	getByIDFn       func(context.Context, string) (*store.Session, error)
-	touchLastSeenFn func(context.Context, string) error
+	touchLastSeenFn func(context.Context, string, time.Time) error
 	deleteFn        func(context.Context, string) error
 }
 
@@ -28,9 +28,9 @@ func (m *sessionRepoMock) GetByID(ctx context.Context, id string) (*store.Sessio
 func (m *sessionRepoMock) ListByUser(context.Context, int64) ([]store.Session, error) {
 	return nil, nil
 }
-func (m *sessionRepoMock) TouchLastSeen(ctx context.Context, id string) error {
+func (m *sessionRepoMock) TouchLastSeen(ctx context.Context, id string, expiresAt time.Time) error {
 	if m.touchLastSeenFn != nil {
-		return m.touchLastSeenFn(ctx, id)
+		return m.touchLastSeenFn(ctx, id, expiresAt)
 	}
 	return nil
 }
@@ -114,7 +114,7 @@ func TestSessionManagerCurrentUserIDReadsSessionAndTouchesLastSeen(t *testing.T)
 			getByIDFn: func(_ context.Context, id string) (*store.Session, error) {
 				return &store.Session{ID: id, UserID: 55}, nil
 			},
-			touchLastSeenFn: func(_ context.Context, id string) error {
+			touchLastSeenFn: func(_ context.Context, id string, _ time.Time) error {
 				touched <- id
 				return nil
 			},
@@ -139,6 +139,118 @@ func TestSessionManagerCurrentUserIDReadsSessionAndTouchesLastSeen(t *testing.T)
 	}
 }
 
+func TestSessionManagerSlidesExpiryWithinMaxLifetime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-29 * 24 * time.Hour)
+	touched := make(chan time.Time, 1)
+	deleted := ""
+	cfg := &config.Config{BaseURL: "https://calcard.example"}
+	cfg.Session.IdleTimeout = 7 * 24 * time.Hour
+	cfg.Session.MaxLifetime = 30 * 24 * time.Hour
+	manager := NewSessionManager(cfg, &store.Store{
+		Sessions: &sessionRepoMock{
+			getByIDFn: func(_ context.Context, id string) (*store.Session, error) {
+				return &store.Session{ID: id, UserID: 5, CreatedAt: created}, nil
+			},
+			touchLastSeenFn: func(_ context.Context, _ string, expiresAt time.Time) error {
+				touched <- expiresAt
+				return nil
+			},
+			deleteFn: func(_ context.Context, id string) error {
+				deleted = id
+				return nil
+			},
+		},
+	})
+	manager.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session-5"})
+	if _, _, ok := manager.CurrentUserID(context.Background(), req); !ok {
+		t.Fatal("expected the session to be valid")
+	}
+	select {
+	case got := <-touched:
+		if want := created.Add(30 * 24 * time.Hour); !got.Equal(want) {
+			t.Fatalf("expiry = %v, want it capped at %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TouchLastSeen() was not called")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if _, _, ok := manager.CurrentUserID(context.Background(), req); ok {
+		t.Fatal("expected the session to end at its max lifetime")
+	}
+	if deleted != "session-5" {
+		t.Fatalf("deleted session = %q, want it revoked", deleted)
+	}
+}
+
+func TestSessionManagerRevokesSessionsUsedElsewhere(t *testing.T) {
+	ip, ua := "198.51.100.8", "Firefox"
+	cfg := &config.Config{BaseURL: "https://calcard.example"}
+	cfg.Session.BindIP = true
+	cfg.Session.BindUserAgent = true
+	var deleted []string
+	manager := NewSessionManager(cfg, &store.Store{
+		Sessions: &sessionRepoMock{
+			getByIDFn: func(_ context.Context, id string) (*store.Session, error) {
+				return &store.Session{ID: id, UserID: 5, IPAddress: &ip, UserAgent: &ua, CreatedAt: time.Now()}, nil
+			},
+			deleteFn: func(_ context.Context, id string) error {
+				deleted = append(deleted, id)
+				return nil
+			},
+		},
+	})
+
+	request := func(remoteIP, userAgent string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteIP + ":1234"
+		req.Header.Set("User-Agent", userAgent)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session-5"})
+		return req
+	}
+	if _, _, ok := manager.CurrentUserID(context.Background(), request(ip, ua)); !ok {
+		t.Fatal("expected the session to be valid from its own IP and browser")
+	}
+	if _, _, ok := manager.CurrentUserID(context.Background(), request("203.0.113.9", ua)); ok {
+		t.Fatal("expected a session used from another IP to be rejected")
+	}
+	if _, _, ok := manager.CurrentUserID(context.Background(), request(ip, "curl/8.0")); ok {
+		t.Fatal("expected a session used from another browser to be rejected")
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("deleted = %v, want both sessions revoked", deleted)
+	}
+}
+
+func TestSessionManagerIssueRotatesExistingSession(t *testing.T) {
+	deleted := ""
+	manager := NewSessionManager(&config.Config{BaseURL: "https://calcard.example"}, &store.Store{
+		Sessions: &sessionRepoMock{
+			createFn: func(_ context.Context, s store.Session) (*store.Session, error) { return &s, nil },
+			deleteFn: func(_ context.Context, id string) error {
+				deleted = id
+				return nil
+			},
+		},
+	})
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "old-session"})
+	rec := httptest.NewRecorder()
+	if err := manager.Issue(context.Background(), rec, req, 5); err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if deleted != "old-session" {
+		t.Fatalf("deleted = %q, want the old session revoked", deleted)
+	}
+	if cookie := rec.Result().Cookies()[0]; cookie.Value == "old-session" {
+		t.Fatal("expected a new session ID")
+	}
+}
+
 func TestGetClientIP(t *testing.T) {
 	tests := []struct {
 		name    string
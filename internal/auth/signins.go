@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// signInRecordInterval is how often repeated sign-ins from one address are
+// recorded again, so a syncing device adds a row an hour, not a request.
+const signInRecordInterval = time.Hour
+
+// maxSignInEntries bounds the sign-ins remembered in memory.
+const maxSignInEntries = 10000
+
+// signInNotifier delivers sign-in alerts; *mail.Mailer satisfies it.
+type signInNotifier interface {
+	Send(msg mail.Message) error
+}
+
+type signInKey struct {
+	userID int64
+	ip     string
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+// signInMonitor remembers which addresses recently signed in to each
+// account and counts refused passwords for the soft lockout. The zero value
+// is ready to use.
+type signInMonitor struct {
+	mu          sync.Mutex
+	recorded    map[signInKey]time.Time
+	failures    map[int64]failureWindow
+	lockedUntil map[int64]time.Time
+}
+
+// recordedRecently reports whether a sign-in from key was recorded within
+// signInRecordInterval.
+func (m *signInMonitor) recordedRecently(key signInKey, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	at, ok := m.recorded[key]
+	return ok && now.Sub(at) < signInRecordInterval
+}
+
+func (m *signInMonitor) markRecorded(key signInKey, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.recorded == nil {
+		m.recorded = make(map[signInKey]time.Time)
+	}
+	if len(m.recorded) >= maxSignInEntries {
+		for k, at := range m.recorded {
+			if now.Sub(at) >= signInRecordInterval {
+				delete(m.recorded, k)
+			}
+		}
+		if len(m.recorded) >= maxSignInEntries {
+			m.recorded = make(map[signInKey]time.Time)
+		}
+	}
+	m.recorded[key] = now
+}
+
+// locked reports whether the account is locked against new addresses.
+func (m *signInMonitor) locked(userID int64, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.lockedUntil[userID]
+	if ok && !now.Before(until) {
+		delete(m.lockedUntil, userID)
+		return false
+	}
+	return ok
+}
+
+// fail counts a refused password and reports whether it locked the
+// account, which happens once threshold are refused within window.
+func (m *signInMonitor) fail(userID int64, now time.Time, threshold int, window time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures == nil {
+		m.failures = make(map[int64]failureWindow)
+		m.lockedUntil = make(map[int64]time.Time)
+	}
+	if len(m.failures) >= maxSignInEntries {
+		for id, f := range m.failures {
+			if now.Sub(f.start) >= window {
+				delete(m.failures, id)
+			}
+		}
+	}
+	f := m.failures[userID]
+	if now.Sub(f.start) >= window {
+		f = failureWindow{start: now}
+	}
+	f.count++
+	if f.count < threshold {
+		m.failures[userID] = f
+		return false
+	}
+	delete(m.failures, userID)
+	m.lockedUntil[userID] = now.Add(window)
+	return true
+}
+
+// admitSignIn records a successful DAV sign-in and reports whether it may
+// proceed. While the account is locked, only addresses it signed in from
+// before get through. The user is emailed about the first sign-in from an
+// address, except for the account's very first one.
+func (s *Service) admitSignIn(ctx context.Context, user *store.User, token *store.AppPassword, ip, userAgent string) bool {
+	if s.store.AuthEvents == nil {
+		return true
+	}
+	now := time.Now()
+	key := signInKey{userID: user.ID, ip: ip}
+	if s.signIns.recordedRecently(key, now) {
+		return true
+	}
+	fromIP, any, err := s.store.AuthEvents.PriorSignIns(ctx, user.ID, ip)
+	if err != nil {
+		log.Printf("failed to load sign-in history for user %d: %v", user.ID, err)
+		return true
+	}
+	event := store.AuthEvent{UserID: user.ID, IPAddress: ip, UserAgent: userAgent}
+	if token != nil {
+		event.AppPasswordID = &token.ID
+	}
+	if !fromIP && s.signIns.locked(user.ID, now) {
+		event.Outcome = store.AuthOutcomeLocked
+		s.recordAuthEvent(ctx, event)
+		return false
+	}
+	event.Outcome = store.AuthOutcomeSuccess
+	event.NewAddress = !fromIP
+	s.recordAuthEvent(ctx, event)
+	s.signIns.markRecorded(key, now)
+	if !fromIP && any && s.cfg.SignIn.NotifyNewAddress {
+		prefs := locale.ForUser(user)
+		s.notifySignIn(user, prefs.T("signin.new.subject"), prefs.T("signin.new.text",
+			user.PrimaryEmail, ip, userAgent, prefs.FormatDateTime(now), strings.TrimRight(s.cfg.BaseURL, "/")))
+	}
+	return true
+}
+
+// recordFailedSignIn records a refused DAV password for the account the
+// username names, if any, and locks the account once too many are refused.
+// Refusals while the account is locked are not recorded.
+func (s *Service) recordFailedSignIn(ctx context.Context, username, ip, userAgent string) {
+	if s.store.AuthEvents == nil {
+		return
+	}
+	user, err := s.store.Users.GetByEmail(ctx, username)
+	if err == nil && user == nil && s.passwords != nil {
+		if email := directoryEmail(username, s.cfg.PasswordAuth.EmailDomain); email != "" && email != username {
+			user, err = s.store.Users.GetByEmail(ctx, email)
+		}
+	}
+	if err != nil || user == nil {
+		return
+	}
+	now := time.Now()
+	if s.signIns.locked(user.ID, now) {
+		return
+	}
+	s.recordAuthEvent(ctx, store.AuthEvent{UserID: user.ID, Outcome: store.AuthOutcomeFailure, IPAddress: ip, UserAgent: userAgent})
+	threshold, window := s.cfg.SignIn.LockoutThreshold, s.cfg.SignIn.LockoutWindow
+	if threshold <= 0 || !s.signIns.fail(user.ID, now, threshold, window) {
+		return
+	}
+	log.Printf("locked %q against new addresses for %s after %d refused DAV passwords", user.PrimaryEmail, window, threshold)
+	prefs := locale.ForUser(user)
+	s.notifySignIn(user, prefs.T("signin.locked.subject"), prefs.Plural("signin.locked.text", threshold,
+		user.PrimaryEmail, ip, window, strings.TrimRight(s.cfg.BaseURL, "/")))
+}
+
+func (s *Service) recordAuthEvent(ctx context.Context, event store.AuthEvent) {
+	if err := s.store.AuthEvents.Record(ctx, event); err != nil {
+		log.Printf("failed to record %s sign-in for user %d: %v", event.Outcome, event.UserID, err)
+	}
+}
+
+// notifySignIn emails the user in the background when email is configured.
+func (s *Service) notifySignIn(user *store.User, subject, text string) {
+	if s.notifier == nil || user.PrimaryEmail == "" {
+		return
+	}
+	go func() {
+		if err := s.notifier.Send(mail.Message{To: []string{user.PrimaryEmail}, Subject: subject, Text: text}); err != nil {
+			log.Printf("failed to send sign-in alert to %q: %v", user.PrimaryEmail, err)
+		}
+	}()
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeAuthEventRepo struct {
+	mu     sync.Mutex
+	events []store.AuthEvent
+}
+
+func (f *fakeAuthEventRepo) Record(_ context.Context, event store.AuthEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeAuthEventRepo) ListByUser(context.Context, int64, int) ([]store.AuthEvent, error) {
+	return nil, nil
+}
+
+func (f *fakeAuthEventRepo) PriorSignIns(_ context.Context, userID int64, ip string) (bool, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var fromIP, any bool
+	for _, e := range f.events {
+		if e.UserID == userID && e.Outcome == store.AuthOutcomeSuccess {
+			any = true
+			fromIP = fromIP || e.IPAddress == ip
+		}
+	}
+	return fromIP, any, nil
+}
+
+func (f *fakeAuthEventRepo) DeleteOlderThan(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeAuthEventRepo) last() store.AuthEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events[len(f.events)-1]
+}
+
+type fakeNotifier chan mail.Message
+
+func (f fakeNotifier) Send(msg mail.Message) error {
+	f <- msg
+	return nil
+}
+
+func TestRequireDAVAuthAlertsOnNewAddressesAndLocksSoftly(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("app-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	alice := &store.User{ID: 7, PrimaryEmail: "alice@example.com"}
+	cfg := &config.Config{BaseURL: "https://calcard.example"}
+	cfg.SignIn.LockoutThreshold = 2
+	cfg.SignIn.LockoutWindow = time.Minute
+	cfg.SignIn.NotifyNewAddress = true
+	events := &fakeAuthEventRepo{events: []store.AuthEvent{{UserID: 7, Outcome: store.AuthOutcomeSuccess, IPAddress: "10.0.0.1"}}}
+	notifier := make(fakeNotifier, 4)
+	service := &Service{
+		cfg: cfg,
+		store: &store.Store{
+			Users: &userRepoMock{
+				getByEmailFn: func(_ context.Context, email string) (*store.User, error) {
+					if email == alice.PrimaryEmail {
+						return alice, nil
+					}
+					return nil, nil
+				},
+			},
+			AppPasswords: &appPasswordRepoMock{
+				findValidByUserFn: func(context.Context, int64) ([]store.AppPassword, error) {
+					return []store.AppPassword{{ID: 3, TokenHash: string(hash)}}, nil
+				},
+			},
+			AuthEvents: events,
+		},
+		notifier: notifier,
+	}
+	handler := service.RequireDAVAuth(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(remoteAddr, password string) int {
+		req := httptest.NewRequest("PROPFIND", "/dav/", nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth(alice.PrimaryEmail, password)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	receive := func(subject string) {
+		t.Helper()
+		select {
+		case msg := <-notifier:
+			if msg.Subject != subject || msg.To[0] != alice.PrimaryEmail {
+				t.Fatalf("sent %q to %v, want %q", msg.Subject, msg.To, subject)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %q email sent", subject)
+		}
+	}
+
+	if code := serve("10.0.0.2:4000", "app-secret"); code != http.StatusOK {
+		t.Fatalf("new address status = %d", code)
+	}
+	if e := events.last(); !e.NewAddress || e.AppPasswordID == nil || *e.AppPasswordID != 3 {
+		t.Fatalf("recorded %+v, want a new-address sign-in with app password 3", e)
+	}
+	receive("New sign-in to your CalCard account")
+	recorded := len(events.events)
+	if code := serve("10.0.0.2:4000", "app-secret"); code != http.StatusOK || len(events.events) != recorded {
+		t.Fatalf("repeat sign-in status = %d, events %d, want no new row", code, len(events.events)-recorded)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve("203.0.113.5:4000", "guess"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password status = %d", code)
+		}
+	}
+	if e := events.last(); e.Outcome != store.AuthOutcomeFailure || e.IPAddress != "203.0.113.5" {
+		t.Fatalf("recorded %+v, want a failure", e)
+	}
+	receive("CalCard sign-ins paused after failed passwords")
+
+	if code := serve("198.51.100.9:4000", "app-secret"); code != http.StatusTooManyRequests {
+		t.Fatalf("locked new address status = %d, want 429", code)
+	}
+	if e := events.last(); e.Outcome != store.AuthOutcomeLocked {
+		t.Fatalf("recorded %+v, want a locked sign-in", e)
+	}
+	if code := serve("10.0.0.1:4000", "app-secret"); code != http.StatusOK {
+		t.Fatalf("known address while locked status = %d", code)
+	}
+	if e := events.last(); e.Outcome != store.AuthOutcomeSuccess || e.IPAddress != "10.0.0.1" {
+		t.Fatalf("recorded %+v, want a sign-in from the known address", e)
+	}
+}
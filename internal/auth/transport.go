@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jw6ventures/calcard/internal/http/ipacl"
+)
+
+// insecureTransportMessage tells a client why its Basic credentials were
+// refused.
+const insecureTransportMessage = "Basic authentication requires HTTPS; connect with an https:// URL"
+
+// insecureTransportWarning makes sure the first refusal reaches the log, so
+// an admin whose proxy does not report HTTPS finds out why clients fail.
+var insecureTransportWarning sync.Once
+
+// SecureTransport reports whether r reached the server over HTTPS: either
+// directly over TLS, or through a proxy listed in APP_TRUSTED_PROXIES whose
+// X-Forwarded-Proto says the client connected with https. The proxy is the
+// connection's own peer, not the address RealIP takes from the forwarding
+// headers, and without trusted proxies the header is never believed.
+func (s *Service) SecureTransport(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	peerIP, _ := parseRemoteAddr(ipacl.Peer(r))
+	if !isTrustedProxy(peerIP, s.trustedProxies()) {
+		return false
+	}
+	// Proxies that append rather than replace leave the client's own value
+	// first; only the last one was set by the proxy we trust.
+	values := strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(values[len(values)-1]), "https")
+}
+
+// basicAuthAllowed reports whether r may carry Basic credentials.
+func (s *Service) basicAuthAllowed(r *http.Request) bool {
+	if s == nil || (s.cfg != nil && s.cfg.AllowInsecureBasicAuth) {
+		return true
+	}
+	return s.SecureTransport(r)
+}
+
+// RequireSecureTransport refuses Basic-authenticated routes over plain HTTP
+// before any password is checked. The refusal carries no WWW-Authenticate
+// challenge, so clients do not prompt for a password they would then send
+// in the clear. It is a no-op on a nil Service or when
+// APP_ALLOW_INSECURE_BASIC_AUTH is set.
+func (s *Service) RequireSecureTransport(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.basicAuthAllowed(r) {
+			insecureTransportWarning.Do(func() {
+				log.Printf("refused Basic authentication over plain HTTP from %s; serve CalCard over HTTPS, list the TLS proxy in APP_TRUSTED_PROXIES, or set APP_ALLOW_INSECURE_BASIC_AUTH=true", s.clientIP(r))
+			})
+			http.Error(w, insecureTransportMessage, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitInsecureCapabilities answers discovery OPTIONS requests made over
+// plain HTTP with only OPTIONS allowed and no DAV classes, so clients are
+// not invited to sign in where they would be refused. Over HTTPS, or with
+// APP_ALLOW_INSECURE_BASIC_AUTH set, next advertises the full set.
+func (s *Service) LimitInsecureCapabilities(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.basicAuthAllowed(r) {
+			w.Header().Set("Allow", http.MethodOptions)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Service) trustedProxies() []*net.IPNet {
+	if s.sessions != nil {
+		return s.sessions.trustedProxies
+	}
+	if s.cfg == nil {
+		return nil
+	}
+	return parseTrustedProxies(s.cfg.TrustedProxies)
+}
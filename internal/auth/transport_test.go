@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/http/ipacl"
+)
+
+func TestSecureTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		remoteAddr string
+		// realIP is the address RealIP rewrites RemoteAddr to.
+		realIP         string
+		proto          string
+		trustedProxies []string
+		want           bool
+	}{
+		{name: "plain http", target: "http://calcard.example/dav/", remoteAddr: "198.51.100.7:4000"},
+		{name: "tls", target: "https://calcard.example/dav/", remoteAddr: "198.51.100.7:4000", want: true},
+		{name: "no trusted proxies", target: "/dav/", remoteAddr: "10.0.0.2:4000", proto: "https"},
+		{name: "trusted proxy", target: "/dav/", remoteAddr: "10.0.0.2:4000", proto: "https", trustedProxies: []string{"10.0.0.0/8"}, want: true},
+		{name: "untrusted proxy", target: "/dav/", remoteAddr: "198.51.100.7:4000", proto: "https", trustedProxies: []string{"10.0.0.0/8"}},
+		{name: "forged client address", target: "/dav/", remoteAddr: "198.51.100.7:4000", realIP: "10.0.0.2", proto: "https", trustedProxies: []string{"10.0.0.0/8"}},
+		{name: "client behind trusted proxy", target: "/dav/", remoteAddr: "10.0.0.2:4000", realIP: "198.51.100.7", proto: "https", trustedProxies: []string{"10.0.0.0/8"}, want: true},
+		{name: "proxy reports http", target: "/dav/", remoteAddr: "10.0.0.2:4000", proto: "http", trustedProxies: []string{"10.0.0.0/8"}},
+		{name: "appended client value", target: "/dav/", remoteAddr: "10.0.0.2:4000", proto: "https, http", trustedProxies: []string{"10.0.0.0/8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &Service{cfg: &config.Config{TrustedProxies: tt.trustedProxies}}
+			req := httptest.NewRequest("PROPFIND", tt.target, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			var got bool
+			ipacl.RecordPeer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.realIP != "" {
+					r.RemoteAddr = tt.realIP
+				}
+				got = service.SecureTransport(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("SecureTransport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireSecureTransportRefusesPlainHTTP(t *testing.T) {
+	cfg := &config.Config{}
+	service := &Service{cfg: cfg}
+	reached := false
+	handler := service.RequireSecureTransport(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	options := service.LimitInsecureCapabilities(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest("PROPFIND", "http://calcard.example/dav/", nil)
+	req.SetBasicAuth("alice@example.com", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || reached || rec.Header().Get("WWW-Authenticate") != "" {
+		t.Fatalf("plain HTTP = %d, reached %v, challenge %q", rec.Code, reached, rec.Header().Get("WWW-Authenticate"))
+	}
+	rec = httptest.NewRecorder()
+	options.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "http://calcard.example/dav/", nil))
+	if rec.Header().Get("DAV") != "" || rec.Header().Get("Allow") != http.MethodOptions {
+		t.Fatalf("plain HTTP OPTIONS headers = %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("PROPFIND", "https://calcard.example/dav/", nil))
+	if !reached {
+		t.Fatal("expected HTTPS requests to pass")
+	}
+	rec = httptest.NewRecorder()
+	options.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "https://calcard.example/dav/", nil))
+	if rec.Header().Get("DAV") == "" {
+		t.Fatal("expected HTTPS OPTIONS to advertise DAV classes")
+	}
+
+	cfg.AllowInsecureBasicAuth = true
+	reached = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("PROPFIND", "http://calcard.example/dav/", nil))
+	if !reached {
+		t.Fatal("expected APP_ALLOW_INSECURE_BASIC_AUTH to admit plain HTTP")
+	}
+}
@@ -0,0 +1,584 @@
+// Package backup writes scheduled, gzip-compressed ICS and VCF snapshots of
+// every calendar and address book to a directory or S3 bucket, prunes old
+// snapshots, and restores collections from them.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const (
+	logClass = "Backup"
+	// snapshotIDLayout names snapshot directories; IDs sort chronologically.
+	snapshotIDLayout = "20060102T150405Z"
+	manifestName     = "manifest.json"
+
+	KindCalendar    = "calendar"
+	KindAddressBook = "addressbook"
+)
+
+// ErrNotFound is returned when a snapshot, object, or collection does not exist.
+var ErrNotFound = errors.New("backup: not found")
+
+// ErrBusy is returned when a snapshot is requested while one is running.
+var ErrBusy = errors.New("backup: a snapshot is already running")
+
+// Manifest describes a completed snapshot. It is written after every
+// collection, so a snapshot without one is incomplete and never restored.
+type Manifest struct {
+	ID          string       `json:"id"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	Collections []Collection `json:"collections"`
+}
+
+// Collection records one calendar or address book within a snapshot.
+type Collection struct {
+	Kind        string  `json:"kind"`
+	ID          int64   `json:"id"`
+	OwnerID     int64   `json:"ownerId"`
+	OwnerEmail  string  `json:"ownerEmail"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	Items       int     `json:"items"`
+	Key         string  `json:"key"`
+}
+
+// Status reports scheduler state for the admin API.
+type Status struct {
+	Enabled       bool       `json:"enabled"`
+	Target        string     `json:"target,omitempty"`
+	Interval      string     `json:"interval,omitempty"`
+	Retention     int        `json:"retention,omitempty"`
+	Running       bool       `json:"running"`
+	LastRunAt     *time.Time `json:"lastRunAt,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastSnapshot  string     `json:"lastSnapshot,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextRunAt     *time.Time `json:"nextRunAt,omitempty"`
+}
+
+// RestoreResult summarises a collection restore.
+type RestoreResult struct {
+	Kind         string `json:"kind"`
+	CollectionID int64  `json:"collectionId"`
+	// Recreated is set when the collection itself had been deleted.
+	Recreated bool `json:"recreated"`
+	Restored  int  `json:"restored"`
+	Unchanged int  `json:"unchanged"`
+	Failed    int  `json:"failed"`
+}
+
+// Service takes and restores snapshots. A nil *Service means backups are
+// disabled.
+type Service struct {
+	store     *store.Store
+	target    store.BlobStore
+	interval  time.Duration
+	retention int
+	log       *logging.Logger
+	now       func() time.Time
+
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns the backup service configured by cfg, or nil when neither a
+// backup directory nor an S3 bucket is configured.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) (*Service, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	target, err := store.NewBlobStore(cfg.Backup.BlobStorage)
+	if err != nil || target == nil {
+		return nil, err
+	}
+	return newService(st, target, cfg.Backup.Interval, cfg.Backup.Retention, logging.New(sink, logClass)), nil
+}
+
+func newService(st *store.Store, target store.BlobStore, interval time.Duration, retention int, log *logging.Logger) *Service {
+	return &Service{
+		store:     st,
+		target:    target,
+		interval:  interval,
+		retention: retention,
+		log:       log,
+		now:       time.Now,
+		status: Status{
+			Enabled:   true,
+			Target:    target.String(),
+			Interval:  interval.String(),
+			Retention: retention,
+		},
+	}
+}
+
+// Status returns a copy of the current scheduler state.
+func (s *Service) Status() Status {
+	if s == nil {
+		return Status{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Start runs snapshots every interval until ctx is cancelled. The first run is
+// scheduled one interval after the newest existing snapshot, so restarts do
+// not postpone backups indefinitely.
+func (s *Service) Start(ctx context.Context) {
+	delay := s.interval
+	if manifests, err := s.Snapshots(ctx); err != nil {
+		s.log.Warn("Start", "failed to list existing snapshots: %v", err)
+	} else if len(manifests) == 0 {
+		delay = 0
+	} else if due := manifests[0].CreatedAt.Add(s.interval); due.After(s.now()) {
+		delay = due.Sub(s.now())
+	} else {
+		delay = 0
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		next := s.now().Add(delay)
+		s.mu.Lock()
+		s.status.NextRunAt = &next
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if _, err := s.Run(ctx); err != nil && !errors.Is(err, ErrBusy) {
+			s.log.Error("Start", "scheduled snapshot failed: %v", err)
+		}
+		delay = s.interval
+		timer.Reset(delay)
+	}
+}
+
+// Trigger starts a snapshot in the background, returning ErrBusy when one is
+// already running.
+func (s *Service) Trigger() error {
+	if !s.runMu.TryLock() {
+		return ErrBusy
+	}
+	go func() {
+		defer s.runMu.Unlock()
+		if _, err := s.run(context.Background()); err != nil {
+			s.log.Error("Trigger", "manual snapshot failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Run takes a snapshot of every collection and prunes snapshots beyond the
+// retention count.
+func (s *Service) Run(ctx context.Context) (*Manifest, error) {
+	if !s.runMu.TryLock() {
+		return nil, ErrBusy
+	}
+	defer s.runMu.Unlock()
+	return s.run(ctx)
+}
+
+func (s *Service) run(ctx context.Context) (*Manifest, error) {
+	started := s.now().UTC()
+	s.mu.Lock()
+	s.status.Running = true
+	s.status.LastRunAt = &started
+	s.mu.Unlock()
+
+	manifest, err := s.snapshot(ctx, started)
+	if err == nil {
+		err = s.prune(ctx, manifest.ID)
+	}
+
+	s.mu.Lock()
+	s.status.Running = false
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		finished := s.now().UTC()
+		s.status.LastSuccessAt = &finished
+		s.status.LastSnapshot = manifest.ID
+		s.status.LastError = ""
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("run", "snapshot %s written with %d collections", manifest.ID, len(manifest.Collections))
+	return manifest, nil
+}
+
+func (s *Service) snapshot(ctx context.Context, started time.Time) (*Manifest, error) {
+	manifest := &Manifest{ID: started.Format(snapshotIDLayout), CreatedAt: started}
+	users, err := s.store.Users.ListActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	for _, user := range users {
+		cals, err := s.store.Calendars.ListByUser(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list calendars for user %d: %w", user.ID, err)
+		}
+		for _, cal := range cals {
+			events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+			if err != nil {
+				return nil, fmt.Errorf("list events for calendar %d: %w", cal.ID, err)
+			}
+			raws := make([]string, 0, len(events))
+			for _, ev := range events {
+				raws = append(raws, ev.RawICAL)
+			}
+			info := utils.CalendarExportInfo{Name: cal.Name}
+			if cal.Description != nil {
+				info.Description = *cal.Description
+			}
+			if cal.Color != nil {
+				info.Color = *cal.Color
+			}
+			entry := Collection{
+				Kind: KindCalendar, ID: cal.ID, OwnerID: user.ID, OwnerEmail: user.PrimaryEmail,
+				Name: cal.Name, Description: cal.Description, Timezone: cal.Timezone, Color: cal.Color,
+				Items: len(events), Key: fmt.Sprintf("%s/calendars/%d.ics.gz", manifest.ID, cal.ID),
+			}
+			if err := s.putGzip(ctx, entry.Key, utils.BuildCalendarExport(info, raws)); err != nil {
+				return nil, fmt.Errorf("write calendar %d: %w", cal.ID, err)
+			}
+			manifest.Collections = append(manifest.Collections, entry)
+		}
+
+		books, err := s.store.AddressBooks.ListByUser(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list address books for user %d: %w", user.ID, err)
+		}
+		for _, book := range books {
+			contacts, err := s.store.Contacts.ListForBook(ctx, book.ID)
+			if err != nil {
+				return nil, fmt.Errorf("list contacts for address book %d: %w", book.ID, err)
+			}
+			var vcf strings.Builder
+			for _, contact := range contacts {
+				vcf.WriteString(strings.TrimRight(contact.RawVCard, "\r\n"))
+				vcf.WriteString("\r\n")
+			}
+			entry := Collection{
+				Kind: KindAddressBook, ID: book.ID, OwnerID: user.ID, OwnerEmail: user.PrimaryEmail,
+				Name: book.Name, Description: book.Description,
+				Items: len(contacts), Key: fmt.Sprintf("%s/addressbooks/%d.vcf.gz", manifest.ID, book.ID),
+			}
+			if err := s.putGzip(ctx, entry.Key, vcf.String()); err != nil {
+				return nil, fmt.Errorf("write address book %d: %w", book.ID, err)
+			}
+			manifest.Collections = append(manifest.Collections, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.target.Put(ctx, manifest.ID+"/"+manifestName, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (s *Service) putGzip(ctx context.Context, key, content string) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return s.target.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+func (s *Service) getGzip(ctx context.Context, key string) (string, error) {
+	rc, err := s.open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	content, err := io.ReadAll(zr)
+	return string(content), err
+}
+
+// open reads an object from the target.
+func (s *Service) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := s.target.Get(ctx, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return rc, err
+}
+
+// prune keeps the newest retention completed snapshots. Older snapshots, and
+// incomplete ones left behind by failed runs before current, are removed.
+func (s *Service) prune(ctx context.Context, current string) error {
+	keys, err := s.target.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("list snapshots: %w", err)
+	}
+	objects := map[string][]string{}
+	complete := map[string]bool{}
+	for _, key := range keys {
+		id, rest, ok := strings.Cut(key, "/")
+		if !ok || !validSnapshotID(id) {
+			continue
+		}
+		objects[id] = append(objects[id], key)
+		if rest == manifestName {
+			complete[id] = true
+		}
+	}
+	var completed []string
+	for id := range complete {
+		completed = append(completed, id)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(completed)))
+	keep := map[string]bool{current: true}
+	for i, id := range completed {
+		if i < s.retention {
+			keep[id] = true
+		}
+	}
+	for id, idKeys := range objects {
+		if keep[id] || id > current {
+			continue
+		}
+		for _, key := range idKeys {
+			if err := s.target.Delete(ctx, key); err != nil {
+				return fmt.Errorf("delete %s: %w", key, err)
+			}
+		}
+		s.log.Debug("prune", "removed snapshot %s", id)
+	}
+	return nil
+}
+
+// Snapshots returns the manifests of completed snapshots, newest first.
+func (s *Service) Snapshots(ctx context.Context) ([]Manifest, error) {
+	keys, err := s.target.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var manifests []Manifest
+	for _, key := range keys {
+		id, rest, ok := strings.Cut(key, "/")
+		if !ok || rest != manifestName || !validSnapshotID(id) {
+			continue
+		}
+		manifest, err := s.Snapshot(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, *manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].ID > manifests[j].ID })
+	return manifests, nil
+}
+
+// Snapshot loads the manifest of a completed snapshot.
+func (s *Service) Snapshot(ctx context.Context, id string) (*Manifest, error) {
+	if !validSnapshotID(id) {
+		return nil, ErrNotFound
+	}
+	rc, err := s.open(ctx, path.Join(id, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("snapshot %s: invalid manifest: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// Restore copies a collection's resources from a snapshot back into the
+// store. Resources that still exist are left alone unless overwrite is set;
+// a deleted collection is recreated for its original owner.
+func (s *Service) Restore(ctx context.Context, snapshotID, kind string, collectionID int64, overwrite bool) (*RestoreResult, error) {
+	manifest, err := s.Snapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	var entry *Collection
+	for i := range manifest.Collections {
+		if c := manifest.Collections[i]; c.Kind == kind && c.ID == collectionID {
+			entry = &manifest.Collections[i]
+			break
+		}
+	}
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+	content, err := s.getGzip(ctx, entry.Key)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", entry.Key, err)
+	}
+
+	var result *RestoreResult
+	switch kind {
+	case KindCalendar:
+		result, err = s.restoreCalendar(ctx, entry, content, overwrite)
+	case KindAddressBook:
+		result, err = s.restoreAddressBook(ctx, entry, content, overwrite)
+	default:
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("Restore", "restored %s %d from snapshot %s into %d: %d restored, %d unchanged, %d failed",
+		kind, collectionID, snapshotID, result.CollectionID, result.Restored, result.Unchanged, result.Failed)
+	return result, nil
+}
+
+func (s *Service) restoreCalendar(ctx context.Context, entry *Collection, content string, overwrite bool) (*RestoreResult, error) {
+	result := &RestoreResult{Kind: KindCalendar, CollectionID: entry.ID}
+	cal, err := s.store.Calendars.GetByID(ctx, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cal == nil {
+		if err := s.requireOwner(ctx, entry); err != nil {
+			return nil, err
+		}
+		cal, err = s.store.Calendars.Create(ctx, store.Calendar{
+			UserID: entry.OwnerID, Name: entry.Name, Description: entry.Description, Timezone: entry.Timezone, Color: entry.Color,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("recreate calendar: %w", err)
+		}
+		result.CollectionID = cal.ID
+		result.Recreated = true
+	}
+
+	var events []string
+	if entry.Items > 0 {
+		if events, err = utils.ParseICSFile(content); err != nil {
+			return nil, fmt.Errorf("parse snapshot: %w", err)
+		}
+	}
+	for _, raw := range events {
+		uid := utils.ExtractUID(raw)
+		existing, err := s.store.Events.GetByUID(ctx, cal.ID, uid)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && (!overwrite || existing.RawICAL == raw) {
+			result.Unchanged++
+			continue
+		}
+		resourceName := utils.ResourceNameForUID(uid)
+		if existing != nil && existing.ResourceName != "" {
+			resourceName = existing.ResourceName
+		}
+		if _, err := s.store.Events.Upsert(ctx, store.Event{
+			CalendarID: cal.ID, UID: uid, ResourceName: resourceName, RawICAL: raw, ETag: utils.GenerateETag(raw),
+		}); err != nil {
+			s.log.Warn("restoreCalendar", "failed to restore event %s in calendar %d: %v", uid, cal.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Restored++
+	}
+	return result, nil
+}
+
+func (s *Service) restoreAddressBook(ctx context.Context, entry *Collection, content string, overwrite bool) (*RestoreResult, error) {
+	result := &RestoreResult{Kind: KindAddressBook, CollectionID: entry.ID}
+	book, err := s.store.AddressBooks.GetByID(ctx, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	if book == nil {
+		if err := s.requireOwner(ctx, entry); err != nil {
+			return nil, err
+		}
+		book, err = s.store.AddressBooks.Create(ctx, store.AddressBook{UserID: entry.OwnerID, Name: entry.Name, Description: entry.Description})
+		if err != nil {
+			return nil, fmt.Errorf("recreate address book: %w", err)
+		}
+		result.CollectionID = book.ID
+		result.Recreated = true
+	}
+
+	vcards, err := utils.ParseVCFFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse snapshot: %w", err)
+	}
+	for _, raw := range vcards {
+		uid := utils.ExtractVCardUID(raw)
+		if uid == "" {
+			result.Failed++
+			continue
+		}
+		existing, err := s.store.Contacts.GetByUID(ctx, book.ID, uid)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && (!overwrite || existing.RawVCard == raw) {
+			result.Unchanged++
+			continue
+		}
+		contact := store.Contact{AddressBookID: book.ID, UID: uid, RawVCard: raw, ETag: utils.GenerateETag(raw)}
+		if existing != nil {
+			contact.ResourceName = existing.ResourceName
+		}
+		if _, err := s.store.Contacts.Upsert(ctx, contact); err != nil {
+			s.log.Warn("restoreAddressBook", "failed to restore contact %s in address book %d: %v", uid, book.ID, err)
+			result.Failed++
+			continue
+		}
+		result.Restored++
+	}
+	return result, nil
+}
+
+func (s *Service) requireOwner(ctx context.Context, entry *Collection) error {
+	owner, err := s.store.Users.GetByID(ctx, entry.OwnerID)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return fmt.Errorf("%w: owner of %s %d no longer exists", ErrNotFound, entry.Kind, entry.ID)
+	}
+	return nil
+}
+
+func validSnapshotID(id string) bool {
+	_, err := time.Parse(snapshotIDLayout, id)
+	return err == nil
+}
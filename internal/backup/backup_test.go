@@ -0,0 +1,286 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+	users []store.User
+}
+
+func (f *fakeUsers) ListActive(ctx context.Context) ([]store.User, error) {
+	return f.users, nil
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	for i := range f.users {
+		if f.users[i].ID == id {
+			return &f.users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+	cals   map[int64]*store.Calendar
+	nextID int64
+}
+
+func (f *fakeCalendars) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
+	var out []store.Calendar
+	for _, cal := range f.cals {
+		if cal.UserID == userID {
+			out = append(out, *cal)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCalendars) GetByID(ctx context.Context, id int64) (*store.Calendar, error) {
+	return f.cals[id], nil
+}
+
+func (f *fakeCalendars) Create(ctx context.Context, cal store.Calendar) (*store.Calendar, error) {
+	f.nextID++
+	cal.ID = f.nextID
+	f.cals[cal.ID] = &cal
+	return &cal, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+	events map[int64]map[string]store.Event
+}
+
+func (f *fakeEvents) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
+	var out []store.Event
+	for _, ev := range f.events[calendarID] {
+		out = append(out, ev)
+	}
+	return out, nil
+}
+
+func (f *fakeEvents) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
+	if ev, ok := f.events[calendarID][uid]; ok {
+		return &ev, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeEvents) Upsert(ctx context.Context, ev store.Event) (*store.Event, error) {
+	if f.events[ev.CalendarID] == nil {
+		f.events[ev.CalendarID] = map[string]store.Event{}
+	}
+	f.events[ev.CalendarID][ev.UID] = ev
+	return &ev, nil
+}
+
+type fakeBooks struct {
+	store.AddressBookRepository
+	books map[int64]*store.AddressBook
+}
+
+func (f *fakeBooks) ListByUser(ctx context.Context, userID int64) ([]store.AddressBook, error) {
+	var out []store.AddressBook
+	for _, book := range f.books {
+		if book.UserID == userID {
+			out = append(out, *book)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBooks) GetByID(ctx context.Context, id int64) (*store.AddressBook, error) {
+	return f.books[id], nil
+}
+
+type fakeContacts struct {
+	store.ContactRepository
+	contacts map[int64]map[string]store.Contact
+}
+
+func (f *fakeContacts) ListForBook(ctx context.Context, bookID int64) ([]store.Contact, error) {
+	var out []store.Contact
+	for _, c := range f.contacts[bookID] {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (f *fakeContacts) GetByUID(ctx context.Context, bookID int64, uid string) (*store.Contact, error) {
+	if c, ok := f.contacts[bookID][uid]; ok {
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeContacts) Upsert(ctx context.Context, c store.Contact) (*store.Contact, error) {
+	f.contacts[c.AddressBookID][c.UID] = c
+	return &c, nil
+}
+
+func eventICAL(uid, summary string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:" + summary + "\r\nDTSTART:20260105T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+func newTestService(t *testing.T) (*Service, *store.Store) {
+	t.Helper()
+	target, err := store.NewDirBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBlobStore: %v", err)
+	}
+	st := &store.Store{
+		Users: &fakeUsers{users: []store.User{{ID: 1, PrimaryEmail: "owner@example.com"}}},
+		Calendars: &fakeCalendars{nextID: 10, cals: map[int64]*store.Calendar{
+			2: {ID: 2, UserID: 1, Name: "Work"},
+		}},
+		Events: &fakeEvents{events: map[int64]map[string]store.Event{
+			2: {
+				"a": {CalendarID: 2, UID: "a", ResourceName: "a.ics", RawICAL: eventICAL("a", "Standup")},
+				"b": {CalendarID: 2, UID: "b", ResourceName: "b.ics", RawICAL: eventICAL("b", "Review")},
+			},
+		}},
+		AddressBooks: &fakeBooks{books: map[int64]*store.AddressBook{3: {ID: 3, UserID: 1, Name: "Contacts"}}},
+		Contacts: &fakeContacts{contacts: map[int64]map[string]store.Contact{
+			3: {"c": {AddressBookID: 3, UID: "c", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:c\r\nFN:Carol\r\nEND:VCARD\r\n"}},
+		}},
+	}
+	return newService(st, target, time.Hour, 2, nil), st
+}
+
+func TestRunWritesSnapshotAndRestoresDeletedResources(t *testing.T) {
+	svc, st := newTestService(t)
+	ctx := context.Background()
+
+	manifest, err := svc.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(manifest.Collections) != 2 {
+		t.Fatalf("expected calendar and address book in manifest, got %+v", manifest.Collections)
+	}
+	if status := svc.Status(); status.LastSnapshot != manifest.ID || status.LastError != "" || status.Running {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	events := st.Events.(*fakeEvents)
+	delete(events.events[2], "a")
+	edited := events.events[2]["b"]
+	edited.RawICAL = eventICAL("b", "Edited")
+	events.events[2]["b"] = edited
+	delete(st.Contacts.(*fakeContacts).contacts[3], "c")
+
+	result, err := svc.Restore(ctx, manifest.ID, KindCalendar, 2, false)
+	if err != nil {
+		t.Fatalf("Restore calendar: %v", err)
+	}
+	if result.Restored != 1 || result.Unchanged != 1 || result.Recreated {
+		t.Fatalf("unexpected calendar restore result %+v", result)
+	}
+	if ev, ok := events.events[2]["a"]; !ok || ev.ResourceName != "a.ics" || !strings.Contains(ev.RawICAL, "SUMMARY:Standup") {
+		t.Fatalf("expected deleted event restored, got %+v", ev)
+	}
+	if !strings.Contains(events.events[2]["b"].RawICAL, "SUMMARY:Edited") {
+		t.Fatal("expected existing event kept without overwrite")
+	}
+
+	if _, err := svc.Restore(ctx, manifest.ID, KindCalendar, 2, true); err != nil {
+		t.Fatalf("Restore calendar with overwrite: %v", err)
+	}
+	if !strings.Contains(events.events[2]["b"].RawICAL, "SUMMARY:Review") {
+		t.Fatal("expected overwrite to restore the snapshot copy")
+	}
+
+	result, err = svc.Restore(ctx, manifest.ID, KindAddressBook, 3, false)
+	if err != nil {
+		t.Fatalf("Restore address book: %v", err)
+	}
+	if result.Restored != 1 {
+		t.Fatalf("unexpected address book restore result %+v", result)
+	}
+	if c := st.Contacts.(*fakeContacts).contacts[3]["c"]; !strings.Contains(c.RawVCard, "FN:Carol") {
+		t.Fatalf("expected deleted contact restored, got %+v", c)
+	}
+}
+
+func TestRestoreRecreatesDeletedCalendar(t *testing.T) {
+	svc, st := newTestService(t)
+	ctx := context.Background()
+	manifest, err := svc.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	cals := st.Calendars.(*fakeCalendars)
+	delete(cals.cals, 2)
+	delete(st.Events.(*fakeEvents).events, 2)
+
+	result, err := svc.Restore(ctx, manifest.ID, KindCalendar, 2, false)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !result.Recreated || result.CollectionID != 11 || result.Restored != 2 {
+		t.Fatalf("unexpected restore result %+v", result)
+	}
+	if cal := cals.cals[11]; cal == nil || cal.UserID != 1 || cal.Name != "Work" {
+		t.Fatalf("expected calendar recreated for its owner, got %+v", cal)
+	}
+}
+
+func TestRestoreRejectsUnknownSnapshotAndCollection(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	manifest, err := svc.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := svc.Restore(ctx, "../etc", KindCalendar, 2, false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for invalid snapshot id, got %v", err)
+	}
+	if _, err := svc.Restore(ctx, manifest.ID, KindCalendar, 99, false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown collection, got %v", err)
+	}
+}
+
+func TestRunPrunesSnapshotsBeyondRetention(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// A leftover from a run that failed before writing its manifest.
+	if err := svc.target.Put(ctx, "20260228T000000Z/calendars/2.ics.gz", strings.NewReader("partial"), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	var ids []string
+	for i := 0; i < 3; i++ {
+		manifest, err := svc.Run(ctx)
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		ids = append(ids, manifest.ID)
+		now = now.Add(time.Hour)
+	}
+
+	manifests, err := svc.Snapshots(ctx)
+	if err != nil {
+		t.Fatalf("Snapshots: %v", err)
+	}
+	if len(manifests) != 2 || manifests[0].ID != ids[2] || manifests[1].ID != ids[1] {
+		t.Fatalf("expected the two newest snapshots kept, got %+v", manifests)
+	}
+	keys, err := svc.target.List(ctx, "20260228T000000Z/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected incomplete snapshot removed, got %v", keys)
+	}
+}
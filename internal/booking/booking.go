@@ -0,0 +1,300 @@
+// Package booking offers a user's free time as public appointment slots and
+// books them into the user's calendar, confirming by iMIP email.
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/rsvp"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	// dateTimeLayout is the local date-time form events.StructuredInput takes.
+	dateTimeLayout = "2006-01-02T15:04"
+
+	MaxDurationMinutes = 8 * 60
+	MaxBufferMinutes   = 4 * 60
+	MaxWindowDays      = 180
+	// AllWeekdays is the Weekdays mask with every day set.
+	AllWeekdays = 1<<7 - 1
+)
+
+var (
+	// ErrInvalidPage reports page settings that cannot produce slots.
+	ErrInvalidPage = errors.New("booking: invalid page")
+	// ErrInvalidAttendee reports a missing name or unusable email address.
+	ErrInvalidAttendee = errors.New("booking: invalid name or email")
+	// ErrSlotUnavailable is returned when the requested start is not, or is
+	// no longer, an open slot.
+	ErrSlotUnavailable = errors.New("booking: slot unavailable")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}[a-z0-9]$`)
+
+// Slot is one bookable appointment.
+type Slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Confirmation describes a booked appointment.
+type Confirmation struct {
+	Event *store.Event
+	Slot  Slot
+	// Emailed reports whether the iMIP invitation was sent to the visitor
+	// and owner; it is false when email is not configured or failed.
+	Emailed bool
+}
+
+// sender delivers confirmation email; *mail.Mailer implements it.
+type sender interface {
+	Send(msg mail.Message) error
+}
+
+// rsvpLinker makes the link a visitor answers the invitation with;
+// *rsvp.Service implements it.
+type rsvpLinker interface {
+	URL(calendarID int64, uid, attendee string, end time.Time) string
+}
+
+// Service computes open slots and books them.
+type Service struct {
+	store  *store.Store
+	events *events.Service
+	mailer sender
+	rsvp   rsvpLinker
+	now    func() time.Time
+
+	// mu serialises bookings so two visitors cannot take the same slot.
+	mu sync.Mutex
+}
+
+// NewService returns a booking service. A nil mailer books without email;
+// a nil links leaves the RSVP link out of the confirmation.
+func NewService(st *store.Store, mailer *mail.Mailer, links *rsvp.Service) *Service {
+	s := &Service{store: st, events: events.NewService(st), now: time.Now}
+	if mailer != nil {
+		s.mailer = mailer
+	}
+	if links != nil {
+		s.rsvp = links
+	}
+	return s
+}
+
+// Normalize trims and validates a page before it is saved, filling the
+// defaults for settings left at zero.
+func Normalize(page *store.BookingPage) error {
+	page.Slug = strings.ToLower(strings.TrimSpace(page.Slug))
+	page.Title = strings.TrimSpace(page.Title)
+	page.Description = strings.TrimSpace(page.Description)
+	page.Timezone = strings.TrimSpace(page.Timezone)
+	if page.Timezone == "" {
+		page.Timezone = "UTC"
+	}
+	if page.WindowDays == 0 {
+		page.WindowDays = 30
+	}
+	if page.Weekdays == 0 {
+		page.Weekdays = 0b0111110
+	}
+
+	switch {
+	case !slugPattern.MatchString(page.Slug):
+		return fmt.Errorf("%w: link name must be 3-64 lowercase letters, digits or hyphens", ErrInvalidPage)
+	case page.Title == "":
+		return fmt.Errorf("%w: title is required", ErrInvalidPage)
+	case page.CalendarID <= 0:
+		return fmt.Errorf("%w: calendar is required", ErrInvalidPage)
+	case page.DurationMinutes < 5 || page.DurationMinutes > MaxDurationMinutes:
+		return fmt.Errorf("%w: duration must be between 5 and %d minutes", ErrInvalidPage, MaxDurationMinutes)
+	case page.BufferMinutes < 0 || page.BufferMinutes > MaxBufferMinutes:
+		return fmt.Errorf("%w: buffer must be between 0 and %d minutes", ErrInvalidPage, MaxBufferMinutes)
+	case page.NoticeMinutes < 0:
+		return fmt.Errorf("%w: minimum notice cannot be negative", ErrInvalidPage)
+	case page.WindowDays < 1 || page.WindowDays > MaxWindowDays:
+		return fmt.Errorf("%w: booking window must be between 1 and %d days", ErrInvalidPage, MaxWindowDays)
+	case page.DayStartMinute < 0 || page.DayEndMinute > 24*60 || page.DayStartMinute+page.DurationMinutes > page.DayEndMinute:
+		return fmt.Errorf("%w: daily hours must fit at least one appointment", ErrInvalidPage)
+	case page.Weekdays < 0 || page.Weekdays > AllWeekdays:
+		return fmt.Errorf("%w: invalid weekdays", ErrInvalidPage)
+	}
+	if _, err := time.LoadLocation(page.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPage, page.Timezone)
+	}
+	return nil
+}
+
+// Location returns the page's timezone, falling back to UTC.
+func Location(page *store.BookingPage) *time.Location {
+	if loc, err := time.LoadLocation(page.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Preferences returns the owner's regional preferences for formatting the
+// page's times, in the page's timezone rather than the owner's.
+func Preferences(page *store.BookingPage, owner *store.User) locale.Preferences {
+	prefs := locale.ForUser(owner)
+	prefs.Location = Location(page)
+	return prefs
+}
+
+// Slots returns the page's open appointments in order: every duration-long
+// step within the daily hours of an enabled weekday, from the minimum
+// notice until the window ends, that keeps the buffer clear of the owner's
+// busy time.
+func (s *Service) Slots(ctx context.Context, page *store.BookingPage) ([]Slot, error) {
+	owner, err := s.owner(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	return s.openSlots(ctx, page, owner)
+}
+
+func (s *Service) openSlots(ctx context.Context, page *store.BookingPage, owner *store.User) ([]Slot, error) {
+	loc := Location(page)
+	now := s.now().In(loc)
+	earliest := now.Add(time.Duration(page.NoticeMinutes) * time.Minute)
+	horizon := now.AddDate(0, 0, page.WindowDays)
+	duration := time.Duration(page.DurationMinutes) * time.Minute
+	buffer := time.Duration(page.BufferMinutes) * time.Minute
+	if duration <= 0 {
+		return nil, nil
+	}
+
+	busy, err := s.events.BusyPeriods(ctx, owner, earliest.Add(-buffer), horizon.Add(buffer))
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []Slot
+	for day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc); day.Before(horizon); day = day.AddDate(0, 0, 1) {
+		if page.Weekdays&(1<<day.Weekday()) == 0 {
+			continue
+		}
+		// time.Date normalises the minute overflow, keeping hours correct
+		// across DST changes.
+		dayEnd := time.Date(day.Year(), day.Month(), day.Day(), 0, page.DayEndMinute, 0, 0, loc)
+		for start := time.Date(day.Year(), day.Month(), day.Day(), 0, page.DayStartMinute, 0, 0, loc); !start.Add(duration).After(dayEnd); start = start.Add(duration) {
+			end := start.Add(duration)
+			if start.Before(earliest) || end.After(horizon) {
+				continue
+			}
+			if events.Overlaps(busy, start.Add(-buffer), end.Add(buffer)) {
+				continue
+			}
+			slots = append(slots, Slot{Start: start, End: end})
+		}
+	}
+	return slots, nil
+}
+
+// Book reserves the slot starting at start for the visitor, creating the
+// event in the page's calendar with the owner as organizer and the visitor
+// as attendee, then emails both an iMIP invitation when email is set up.
+// The event and email are written in the owner's language.
+func (s *Service) Book(ctx context.Context, page *store.BookingPage, start time.Time, name, email string) (*Confirmation, error) {
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if name == "" || len(name) > 200 || strings.ContainsAny(name, "<>\r\n") {
+		return nil, ErrInvalidAttendee
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return nil, ErrInvalidAttendee
+	}
+
+	owner, err := s.owner(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slots, err := s.openSlots(ctx, page, owner)
+	if err != nil {
+		return nil, err
+	}
+	var slot *Slot
+	for i := range slots {
+		if slots[i].Start.Equal(start) {
+			slot = &slots[i]
+			break
+		}
+	}
+	if slot == nil {
+		return nil, ErrSlotUnavailable
+	}
+
+	prefs := Preferences(page, owner)
+	description := prefs.T("booking.description", name, email, page.Title)
+	if page.Description != "" {
+		description = page.Description + "\n\n" + description
+	}
+	event, _, err := s.events.CreateEvent(ctx, owner, page.CalendarID, events.UpsertInput{
+		Structured: &events.StructuredInput{
+			Summary:     prefs.T("booking.summary", page.Title, name),
+			DTStart:     slot.Start.Format(dateTimeLayout),
+			DTEnd:       slot.End.Format(dateTimeLayout),
+			Timezone:    Location(page).String(),
+			Description: description,
+			Organizer:   owner.PrimaryEmail,
+			Attendees:   []string{fmt.Sprintf("%s <%s>", name, email)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation := &Confirmation{Event: event, Slot: *slot}
+	if s.mailer != nil {
+		text := prefs.T("booking.email.text", page.Title, name, prefs.FormatDateTime(slot.Start))
+		if s.rsvp != nil {
+			text += prefs.T("booking.email.rsvp", name, s.rsvp.URL(page.CalendarID, event.UID, email, slot.End))
+		}
+		err := s.mailer.Send(mail.Message{
+			To:       []string{email, owner.PrimaryEmail},
+			Subject:  prefs.T("booking.email.subject", page.Title, name),
+			Text:     text,
+			Calendar: withMethod(event.RawICAL, "REQUEST"),
+			Method:   "REQUEST",
+		})
+		confirmation.Emailed = err == nil
+	}
+	return confirmation, nil
+}
+
+func (s *Service) owner(ctx context.Context, page *store.BookingPage) (*store.User, error) {
+	owner, err := s.store.Users.GetByID(ctx, page.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, store.ErrNotFound
+	}
+	return owner, nil
+}
+
+// withMethod adds the iTIP METHOD property that iMIP requires but stored
+// calendar objects must not carry.
+func withMethod(ical, method string) string {
+	const begin = "BEGIN:VCALENDAR\r\n"
+	if !strings.HasPrefix(ical, begin) {
+		return ical
+	}
+	return begin + "METHOD:" + method + "\r\n" + ical[len(begin):]
+}
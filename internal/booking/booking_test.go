@@ -0,0 +1,249 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+	user store.User
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if id != f.user.ID {
+		return nil, nil
+	}
+	return &f.user, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+	cal store.Calendar
+}
+
+func (f *fakeCalendars) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
+	return []store.Calendar{f.cal}, nil
+}
+
+func (f *fakeCalendars) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
+	if calendarID != f.cal.ID || userID != f.cal.UserID {
+		return nil, nil
+	}
+	return &store.CalendarAccess{Calendar: f.cal, Editor: true}, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+	events []store.Event
+}
+
+func (f *fakeEvents) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
+	return f.events, nil
+}
+
+func (f *fakeEvents) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
+	for i := range f.events {
+		if f.events[i].UID == uid {
+			return &f.events[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeEvents) GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, error) {
+	return f.GetByUID(ctx, calendarID, resourceName)
+}
+
+// Upsert stores the event with the UTC times the real store extracts.
+func (f *fakeEvents) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
+	for _, line := range strings.Split(event.RawICAL, "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse("20060102T150405", strings.TrimSuffix(value, "Z"))
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "DTSTART"):
+			event.DTStart = &t
+		case strings.HasPrefix(name, "DTEND"):
+			event.DTEnd = &t
+		}
+	}
+	f.events = append(f.events, event)
+	return &event, nil
+}
+
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(msg mail.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+type fakeLinker struct{}
+
+func (fakeLinker) URL(calendarID int64, uid, attendee string, end time.Time) string {
+	return fmt.Sprintf("https://rsvp.test/%d/%s/%s", calendarID, uid, attendee)
+}
+
+func busyEvent(uid, start, end string) store.Event {
+	ev, _ := (&fakeEvents{}).Upsert(context.Background(), store.Event{CalendarID: 1, UID: uid,
+		RawICAL: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART:" + start +
+			"\r\nDTEND:" + end + "\r\nSUMMARY:Busy\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"})
+	return *ev
+}
+
+func newTestService(eventRepo *fakeEvents) *Service {
+	st := &store.Store{
+		Users:     &fakeUsers{user: store.User{ID: 1, PrimaryEmail: "owner@example.com"}},
+		Calendars: &fakeCalendars{cal: store.Calendar{ID: 1, UserID: 1, Name: "Work"}},
+		Events:    eventRepo,
+	}
+	svc := NewService(st, nil, nil)
+	// Sunday 2026-03-01 08:00 UTC.
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC) }
+	return svc
+}
+
+func testPage() *store.BookingPage {
+	return &store.BookingPage{
+		ID: 1, UserID: 1, CalendarID: 1, Slug: "intro", Title: "Intro call",
+		DurationMinutes: 30, BufferMinutes: 15, NoticeMinutes: 60, WindowDays: 2,
+		DayStartMinute: 9 * 60, DayEndMinute: 11 * 60, Weekdays: 1 << time.Monday, Timezone: "UTC",
+	}
+}
+
+func TestSlotsSkipBusyTimeAndBuffer(t *testing.T) {
+	svc := newTestService(&fakeEvents{events: []store.Event{
+		busyEvent("standup", "20260302T094500Z", "20260302T100000Z"),
+	}})
+
+	slots, err := svc.Slots(context.Background(), testPage())
+	if err != nil {
+		t.Fatalf("Slots() error = %v", err)
+	}
+	var got []string
+	for _, s := range slots {
+		got = append(got, s.Start.UTC().Format("15:04"))
+	}
+	// 09:30 and 10:00 fall within 15 minutes of the 09:45 stand-up; Sunday
+	// and Tuesday are not bookable weekdays.
+	if strings.Join(got, ",") != "09:00,10:30" {
+		t.Fatalf("unexpected slots %v", got)
+	}
+}
+
+func TestSlotsHonourTimezoneAndNotice(t *testing.T) {
+	svc := newTestService(&fakeEvents{})
+	page := testPage()
+	page.Timezone = "America/New_York"
+	page.Weekdays = 1 << time.Sunday
+	page.DayStartMinute = 3 * 60
+	page.DayEndMinute = 5 * 60
+	page.WindowDays = 1
+
+	slots, err := svc.Slots(context.Background(), page)
+	if err != nil {
+		t.Fatalf("Slots() error = %v", err)
+	}
+	// Now is 03:00 EST; an hour's notice leaves 04:00 and 04:30 local.
+	if len(slots) != 2 || slots[0].Start.UTC().Format("15:04") != "09:00" || slots[1].End.UTC().Format("15:04") != "10:00" {
+		t.Fatalf("unexpected slots %+v", slots)
+	}
+}
+
+func TestBookCreatesEventAndSendsInvitation(t *testing.T) {
+	eventRepo := &fakeEvents{}
+	svc := newTestService(eventRepo)
+	sender := &fakeSender{}
+	svc.mailer = sender
+	svc.rsvp = fakeLinker{}
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	got, err := svc.Book(context.Background(), testPage(), start, "Visitor Example", "visitor@example.com")
+	if err != nil {
+		t.Fatalf("Book() error = %v", err)
+	}
+	if !got.Emailed || len(sender.sent) != 1 || strings.Join(sender.sent[0].To, ",") != "visitor@example.com,owner@example.com" {
+		t.Fatalf("expected invitation to visitor and owner, got emailed=%v sent=%+v", got.Emailed, sender.sent)
+	}
+	ical := got.Event.RawICAL
+	for _, want := range []string{
+		"SUMMARY:Intro call with Visitor Example",
+		"ORGANIZER:mailto:owner@example.com",
+		"ATTENDEE;CN=Visitor Example:mailto:visitor@example.com",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Fatalf("expected %q in event, got %s", want, ical)
+		}
+	}
+	if strings.Contains(ical, "METHOD:") {
+		t.Fatalf("stored event must not carry METHOD, got %s", ical)
+	}
+	if msg := sender.sent[0]; msg.Method != "REQUEST" || !strings.Contains(msg.Calendar, "METHOD:REQUEST\r\n") {
+		t.Fatalf("expected iMIP REQUEST in mail, got %+v", msg)
+	}
+	if want := "https://rsvp.test/1/" + got.Event.UID + "/visitor@example.com"; !strings.Contains(sender.sent[0].Text, want) {
+		t.Fatalf("expected RSVP link %s in mail, got %q", want, sender.sent[0].Text)
+	}
+
+	// The booked slot and its buffer are no longer offered.
+	if _, err := svc.Book(context.Background(), testPage(), start, "Second Visitor", "second@example.com"); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("second booking error = %v, want ErrSlotUnavailable", err)
+	}
+	if _, err := svc.Book(context.Background(), testPage(), start.Add(30*time.Minute), "Second Visitor", "second@example.com"); !errors.Is(err, ErrSlotUnavailable) {
+		t.Fatalf("buffered booking error = %v, want ErrSlotUnavailable", err)
+	}
+}
+
+func TestBookRejectsBadAttendee(t *testing.T) {
+	svc := newTestService(&fakeEvents{})
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, tc := range []struct{ name, email string }{
+		{"", "visitor@example.com"},
+		{"Visitor", "not an email"},
+		{"Visitor", "Visitor <visitor@example.com>"},
+		{"Visitor\r\nBcc: x", "visitor@example.com"},
+	} {
+		if _, err := svc.Book(context.Background(), testPage(), start, tc.name, tc.email); !errors.Is(err, ErrInvalidAttendee) {
+			t.Fatalf("Book(%q, %q) error = %v, want ErrInvalidAttendee", tc.name, tc.email, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	page := &store.BookingPage{Slug: " Intro-Call ", Title: " Intro ", CalendarID: 1, DurationMinutes: 30, DayStartMinute: 540, DayEndMinute: 1020}
+	if err := Normalize(page); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if page.Slug != "intro-call" || page.Title != "Intro" || page.Timezone != "UTC" || page.WindowDays != 30 || page.Weekdays != 62 {
+		t.Fatalf("unexpected normalized page %+v", page)
+	}
+
+	for _, mutate := range []func(*store.BookingPage){
+		func(p *store.BookingPage) { p.Slug = "a" },
+		func(p *store.BookingPage) { p.Slug = "bad slug" },
+		func(p *store.BookingPage) { p.DurationMinutes = 0 },
+		func(p *store.BookingPage) { p.DayEndMinute = p.DayStartMinute + 10 },
+		func(p *store.BookingPage) { p.Timezone = "Mars/Olympus" },
+	} {
+		bad := *page
+		mutate(&bad)
+		if err := Normalize(&bad); !errors.Is(err, ErrInvalidPage) {
+			t.Fatalf("Normalize(%+v) error = %v, want ErrInvalidPage", bad, err)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+// Package bootstrap provisions users, calendars and calendar shares from a
+// declarative YAML file at startup, so a fresh deployment comes up with its
+// accounts in place. Applying the file again only creates or corrects what
+// differs from it; nothing missing from the file is removed.
+//
+// A calendar marked org is shared read-only with every account, including
+// ones created later, which suits holidays and company events. Its events
+// can be seeded from a holidays source.
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/holidays"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the bootstrap file.
+type File struct {
+	Users []User `yaml:"users"`
+}
+
+// User is an account to provision. Subject is the OAuth subject the user
+// signs in with; when empty the account is claimed by the first sign-in
+// with Email. Aliases are added as verified alias addresses.
+type User struct {
+	Email     string     `yaml:"email"`
+	Subject   string     `yaml:"subject"`
+	Aliases   []string   `yaml:"aliases"`
+	Calendars []Calendar `yaml:"calendars"`
+}
+
+// Calendar is a calendar the user owns, identified by name. Description,
+// Timezone and Color are enforced when set and left alone when empty, and
+// so is Org: true shares the calendar read-only with every account, false
+// withdraws that. Holidays is a source whose events are added to the
+// calendar; events already in it are left as they are.
+type Calendar struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Timezone    string  `yaml:"timezone"`
+	Color       string  `yaml:"color"`
+	Org         *bool   `yaml:"org"`
+	Holidays    string  `yaml:"holidays"`
+	Shares      []Share `yaml:"shares"`
+}
+
+// Share grants another user read access to a calendar, and write access
+// when Editor is set.
+type Share struct {
+	Email  string `yaml:"email"`
+	Editor bool   `yaml:"editor"`
+}
+
+// Result counts what applying the file changed.
+type Result struct {
+	UsersCreated     int
+	AliasesAdded     int
+	CalendarsCreated int
+	CalendarsUpdated int
+	SharesSet        int
+	OrgSharesSet     int
+	EventsImported   int
+}
+
+// Load reads and validates the bootstrap file at name.
+func Load(name string) (*File, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap: %w", err)
+	}
+	return Parse(raw)
+}
+
+// Parse decodes and validates a bootstrap file. Unknown keys are rejected so
+// a misspelt setting is not silently ignored.
+func Parse(raw []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("bootstrap: %w", err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("bootstrap: %w", err)
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	emails := make(map[string]bool, len(f.Users))
+	for i := range f.Users {
+		u := &f.Users[i]
+		u.Email = strings.TrimSpace(u.Email)
+		u.Subject = strings.TrimSpace(u.Subject)
+		if !strings.Contains(u.Email, "@") {
+			return fmt.Errorf("user %d: invalid email %q", i+1, u.Email)
+		}
+		if emails[strings.ToLower(u.Email)] {
+			return fmt.Errorf("user %s is listed twice", u.Email)
+		}
+		emails[strings.ToLower(u.Email)] = true
+	}
+	for i := range f.Users {
+		u := &f.Users[i]
+		for j := range u.Aliases {
+			alias := strings.TrimSpace(u.Aliases[j])
+			if !strings.Contains(alias, "@") {
+				return fmt.Errorf("user %s: invalid alias %q", u.Email, alias)
+			}
+			if emails[strings.ToLower(alias)] {
+				return fmt.Errorf("user %s: alias %s is already a user or alias", u.Email, alias)
+			}
+			emails[strings.ToLower(alias)] = true
+			u.Aliases[j] = alias
+		}
+	}
+	for i := range f.Users {
+		u := &f.Users[i]
+		names := make(map[string]bool, len(u.Calendars))
+		for j := range u.Calendars {
+			c := &u.Calendars[j]
+			c.Name = strings.TrimSpace(c.Name)
+			if c.Name == "" {
+				return fmt.Errorf("user %s: calendar %d has no name", u.Email, j+1)
+			}
+			if names[c.Name] {
+				return fmt.Errorf("user %s: calendar %q is listed twice", u.Email, c.Name)
+			}
+			names[c.Name] = true
+			if c.Timezone != "" {
+				if _, err := time.LoadLocation(c.Timezone); err != nil {
+					return fmt.Errorf("user %s: calendar %q: unknown timezone %q", u.Email, c.Name, c.Timezone)
+				}
+			}
+			color, err := store.NormalizeCalendarColorOpaque(c.Color)
+			if err != nil {
+				return fmt.Errorf("user %s: calendar %q: invalid color %q", u.Email, c.Name, c.Color)
+			}
+			if color != nil {
+				c.Color = *color
+			}
+			c.Holidays = strings.TrimSpace(c.Holidays)
+			if c.Holidays != "" {
+				if err := holidays.Supported(c.Holidays); err != nil {
+					return fmt.Errorf("user %s: calendar %q: %w", u.Email, c.Name, err)
+				}
+			}
+			for k := range c.Shares {
+				s := &c.Shares[k]
+				s.Email = strings.TrimSpace(s.Email)
+				if !strings.Contains(s.Email, "@") {
+					return fmt.Errorf("user %s: calendar %q: share %d has an invalid email %q", u.Email, c.Name, k+1, s.Email)
+				}
+				if strings.EqualFold(s.Email, u.Email) {
+					return fmt.Errorf("user %s: calendar %q is shared with its owner", u.Email, c.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Apply provisions the file's users and calendars in st, then their shares,
+// so a calendar may be shared with a user listed after its owner. Users are
+// matched by email and calendars by owner and name. Shares may name users
+// outside the file, but they must exist. A holidays source that cannot be
+// fetched or imported is logged and skipped, so an unreachable feed does
+// not keep the server from starting.
+func Apply(ctx context.Context, st *store.Store, f *File, sink logging.Sink) (Result, error) {
+	log := logging.New(sink, "bootstrap")
+	var result Result
+	users := make(map[string]*store.User, len(f.Users))
+	calendars := make(map[*Calendar]*store.Calendar)
+
+	for i := range f.Users {
+		u := &f.Users[i]
+		user, err := st.Users.GetByEmail(ctx, u.Email)
+		if err != nil {
+			return result, fmt.Errorf("bootstrap: load user %s: %w", u.Email, err)
+		}
+		if user == nil {
+			subject := u.Subject
+			if subject == "" {
+				subject = store.BootstrapSubject(u.Email)
+			}
+			if user, err = st.Users.UpsertOAuthUser(ctx, subject, u.Email); err != nil {
+				return result, fmt.Errorf("bootstrap: create user %s: %w", u.Email, err)
+			}
+			result.UsersCreated++
+			log.Info("Apply", "created user %s", u.Email)
+		}
+		users[strings.ToLower(u.Email)] = user
+		for _, alias := range u.Aliases {
+			if user.HasEmail(alias) {
+				continue
+			}
+			if _, err := st.EmailAliases.Create(ctx, user.ID, alias, "", true); err != nil {
+				return result, fmt.Errorf("bootstrap: alias %s of %s: %w", alias, u.Email, err)
+			}
+			result.AliasesAdded++
+			log.Info("Apply", "added alias %s to %s", alias, u.Email)
+		}
+
+		existing, err := st.Calendars.ListByUser(ctx, user.ID)
+		if err != nil {
+			return result, fmt.Errorf("bootstrap: list calendars of %s: %w", u.Email, err)
+		}
+		for j := range u.Calendars {
+			c := &u.Calendars[j]
+			cal, outcome, err := applyCalendar(ctx, st, user.ID, c, existing)
+			if err != nil {
+				return result, fmt.Errorf("bootstrap: calendar %q of %s: %w", c.Name, u.Email, err)
+			}
+			switch outcome {
+			case calendarCreated:
+				result.CalendarsCreated++
+				log.Info("Apply", "created calendar %q for %s", c.Name, u.Email)
+			case calendarUpdated:
+				result.CalendarsUpdated++
+				log.Info("Apply", "updated calendar %q of %s", c.Name, u.Email)
+			}
+			calendars[c] = cal
+		}
+	}
+
+	for i := range f.Users {
+		u := &f.Users[i]
+		for j := range u.Calendars {
+			c := &u.Calendars[j]
+			for _, share := range c.Shares {
+				target, ok := users[strings.ToLower(share.Email)]
+				if !ok {
+					user, err := st.Users.GetByEmail(ctx, share.Email)
+					if err != nil {
+						return result, fmt.Errorf("bootstrap: load user %s: %w", share.Email, err)
+					}
+					if user == nil {
+						return result, fmt.Errorf("bootstrap: calendar %q of %s is shared with %s, who has no account", c.Name, u.Email, share.Email)
+					}
+					target = user
+					users[strings.ToLower(share.Email)] = user
+				}
+				want := []string{"read", "read-free-busy"}
+				if share.Editor {
+					want = append(want, "write")
+				}
+				changed, err := applyGrant(ctx, st, calendars[c].ID, store.UserPrincipalHref(target.ID), want)
+				if err != nil {
+					return result, fmt.Errorf("bootstrap: share calendar %q of %s with %s: %w", c.Name, u.Email, share.Email, err)
+				}
+				if changed {
+					result.SharesSet++
+					log.Info("Apply", "shared calendar %q of %s with %s", c.Name, u.Email, share.Email)
+				}
+			}
+			if c.Org != nil {
+				var want []string
+				if *c.Org {
+					want = []string{"read", "read-free-busy"}
+				}
+				changed, err := applyGrant(ctx, st, calendars[c].ID, orgPrincipal, want)
+				if err != nil {
+					return result, fmt.Errorf("bootstrap: share calendar %q of %s with everyone: %w", c.Name, u.Email, err)
+				}
+				if changed {
+					result.OrgSharesSet++
+					log.Info("Apply", "set sharing of calendar %q of %s with everyone to %t", c.Name, u.Email, *c.Org)
+				}
+			}
+			if c.Holidays != "" {
+				imported, err := importHolidays(ctx, st, users[strings.ToLower(u.Email)], calendars[c].ID, c.Holidays)
+				result.EventsImported += imported
+				if err != nil {
+					log.Warn("Apply", "failed to import %s into calendar %q of %s: %v", c.Holidays, c.Name, u.Email, err)
+				} else if imported > 0 {
+					log.Info("Apply", "imported %d events from %s into calendar %q of %s", imported, c.Holidays, c.Name, u.Email)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// orgPrincipal is the principal org calendars are shared with: every
+// signed-in account, so accounts created or deleted later gain or lose
+// access without the ACL changing.
+const orgPrincipal = "DAV:authenticated"
+
+// importHolidays adds the events at source that the calendar does not
+// already have, and returns how many it added.
+func importHolidays(ctx context.Context, st *store.Store, owner *store.User, calendarID int64, source string) (int, error) {
+	ics, err := holidays.Fetch(ctx, source)
+	if err != nil {
+		return 0, err
+	}
+	objects, err := events.SplitImport(ics)
+	if err != nil {
+		return 0, err
+	}
+	svc := events.NewService(st)
+	imported := 0
+	for _, object := range objects {
+		if uid := utils.ExtractUID(object); uid != "" {
+			existing, err := st.Events.GetByUID(ctx, calendarID, uid)
+			if err != nil {
+				return imported, err
+			}
+			if existing != nil {
+				continue
+			}
+		}
+		if _, _, err := svc.ImportEvent(ctx, owner, calendarID, object); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+type calendarOutcome int
+
+const (
+	calendarUnchanged calendarOutcome = iota
+	calendarCreated
+	calendarUpdated
+)
+
+// applyCalendar creates the calendar or corrects the properties the file
+// sets.
+func applyCalendar(ctx context.Context, st *store.Store, userID int64, c *Calendar, existing []store.Calendar) (*store.Calendar, calendarOutcome, error) {
+	for i := range existing {
+		cal := &existing[i]
+		if cal.Name != c.Name {
+			continue
+		}
+		description, timezone, color := cal.Description, cal.Timezone, cal.Color
+		changed := false
+		set := func(field **string, value string) {
+			if value != "" && (*field == nil || **field != value) {
+				*field = &value
+				changed = true
+			}
+		}
+		set(&description, c.Description)
+		set(&timezone, c.Timezone)
+		set(&color, c.Color)
+		if !changed {
+			return cal, calendarUnchanged, nil
+		}
+		if err := st.Calendars.Update(ctx, userID, cal.ID, cal.Name, description, timezone, color); err != nil {
+			return nil, calendarUnchanged, err
+		}
+		return cal, calendarUpdated, nil
+	}
+	created, err := st.Calendars.Create(ctx, store.Calendar{
+		UserID:      userID,
+		Name:        c.Name,
+		Description: optional(c.Description),
+		Timezone:    optional(c.Timezone),
+		Color:       optional(c.Color),
+	})
+	if err != nil {
+		return nil, calendarUnchanged, err
+	}
+	return created, calendarCreated, nil
+}
+
+// applyGrant gives the principal exactly the sharing privileges in want on
+// the calendar, none when want is empty, and reports whether it changed the
+// ACL.
+func applyGrant(ctx context.Context, st *store.Store, calendarID int64, principalHref string, want []string) (bool, error) {
+	resourcePath := path.Join("/dav/calendars", fmt.Sprint(calendarID))
+	entries, err := st.ACLEntries.ListByResource(ctx, resourcePath)
+	if err != nil {
+		return false, err
+	}
+	held := map[string]bool{}
+	kept := make([]store.ACLEntry, 0, len(entries)+len(want))
+	for _, entry := range entries {
+		if entry.PrincipalHref == principalHref && entry.IsGrant && sharePrivilege(entry.Privilege) {
+			held[entry.Privilege] = true
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	if len(held) == len(want) && holdsAll(held, want) {
+		return false, nil
+	}
+	for _, privilege := range want {
+		kept = append(kept, store.ACLEntry{ResourcePath: resourcePath, PrincipalHref: principalHref, IsGrant: true, Privilege: privilege})
+	}
+	return true, st.ACLEntries.SetACL(ctx, resourcePath, kept)
+}
+
+func holdsAll(held map[string]bool, want []string) bool {
+	for _, privilege := range want {
+		if !held[privilege] {
+			return false
+		}
+	}
+	return true
+}
+
+// sharePrivilege reports whether privilege is one calendar sharing manages.
+func sharePrivilege(privilege string) bool {
+	switch privilege {
+	case "read", "read-free-busy", "write":
+		return true
+	default:
+		return false
+	}
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
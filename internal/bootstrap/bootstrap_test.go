@@ -0,0 +1,218 @@
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+	users []*store.User
+}
+
+func (f *fakeUsers) GetByEmail(_ context.Context, email string) (*store.User, error) {
+	for _, u := range f.users {
+		if u.PrimaryEmail == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeUsers) UpsertOAuthUser(_ context.Context, subject, email string) (*store.User, error) {
+	u := &store.User{ID: int64(len(f.users) + 1), OAuthSubject: subject, PrimaryEmail: email}
+	f.users = append(f.users, u)
+	return u, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+	calendars []store.Calendar
+	updates   int
+}
+
+func (f *fakeCalendars) ListByUser(_ context.Context, userID int64) ([]store.Calendar, error) {
+	var out []store.Calendar
+	for _, c := range f.calendars {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCalendars) Create(_ context.Context, cal store.Calendar) (*store.Calendar, error) {
+	cal.ID = int64(len(f.calendars) + 10)
+	f.calendars = append(f.calendars, cal)
+	return &cal, nil
+}
+
+func (f *fakeCalendars) Update(_ context.Context, userID, id int64, name string, description, timezone, color *string) error {
+	f.updates++
+	for i := range f.calendars {
+		if f.calendars[i].ID == id {
+			f.calendars[i].Description, f.calendars[i].Timezone, f.calendars[i].Color = description, timezone, color
+		}
+	}
+	return nil
+}
+
+type fakeAliases struct {
+	store.EmailAliasRepository
+	users *fakeUsers
+}
+
+func (f *fakeAliases) Create(_ context.Context, userID int64, email, tokenHash string, verified bool) (*store.EmailAlias, error) {
+	if !verified || tokenHash != "" {
+		return nil, store.ErrConflict
+	}
+	user := f.users.users[userID-1]
+	user.EmailAliases = append(user.EmailAliases, email)
+	return &store.EmailAlias{UserID: userID, Email: email}, nil
+}
+
+type fakeACL struct {
+	store.ACLRepository
+	entries map[string][]store.ACLEntry
+	sets    int
+}
+
+func (f *fakeACL) ListByResource(_ context.Context, resourcePath string) ([]store.ACLEntry, error) {
+	return f.entries[resourcePath], nil
+}
+
+func (f *fakeACL) SetACL(_ context.Context, resourcePath string, entries []store.ACLEntry) error {
+	f.sets++
+	f.entries[resourcePath] = entries
+	return nil
+}
+
+const family = `
+users:
+  - email: alice@example.com
+    aliases: [Alice.Work@example.com]
+    calendars:
+      - name: Family
+        timezone: America/Chicago
+        color: "#2f80ed"
+        shares:
+          - email: bob@example.com
+            editor: true
+  - email: bob@example.com
+    subject: "1234"
+`
+
+func TestApplyIsIdempotent(t *testing.T) {
+	file, err := Parse([]byte(family))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	users := &fakeUsers{}
+	calendars := &fakeCalendars{}
+	acl := &fakeACL{entries: map[string][]store.ACLEntry{}}
+	st := &store.Store{Users: users, EmailAliases: &fakeAliases{users: users}, Calendars: calendars, ACLEntries: acl}
+
+	result, err := Apply(context.Background(), st, file, nil)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if result != (Result{UsersCreated: 2, AliasesAdded: 1, CalendarsCreated: 1, SharesSet: 1}) {
+		t.Fatalf("first Apply() = %+v", result)
+	}
+	if len(users.users[0].EmailAliases) != 1 || !users.users[0].HasEmail("alice.work@EXAMPLE.com") {
+		t.Fatalf("aliases = %v", users.users[0].EmailAliases)
+	}
+	if users.users[0].OAuthSubject != "bootstrap:alice@example.com" || users.users[1].OAuthSubject != "1234" {
+		t.Fatalf("subjects = %q, %q", users.users[0].OAuthSubject, users.users[1].OAuthSubject)
+	}
+	cal := calendars.calendars[0]
+	if cal.UserID != 1 || *cal.Color != "#2F80EDFF" || *cal.Timezone != "America/Chicago" {
+		t.Fatalf("created calendar %+v", cal)
+	}
+	if entries := acl.entries["/dav/calendars/10"]; len(entries) != 3 || entries[0].PrincipalHref != "/dav/principals/2/" {
+		t.Fatalf("share entries = %+v", entries)
+	}
+
+	result, err = Apply(context.Background(), st, file, nil)
+	if err != nil || result != (Result{}) || acl.sets != 1 || calendars.updates != 0 {
+		t.Fatalf("second Apply() = %+v, %v with %d ACL writes and %d updates", result, err, acl.sets, calendars.updates)
+	}
+
+	file.Users[0].Calendars[0].Timezone = "Europe/Berlin"
+	file.Users[0].Calendars[0].Shares[0].Editor = false
+	result, err = Apply(context.Background(), st, file, nil)
+	if err != nil || result != (Result{CalendarsUpdated: 1, SharesSet: 1}) {
+		t.Fatalf("changed Apply() = %+v, %v", result, err)
+	}
+	if entries := acl.entries["/dav/calendars/10"]; len(entries) != 2 {
+		t.Fatalf("read-only share entries = %+v", entries)
+	}
+}
+
+func TestApplyOrgCalendar(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:new-year-2027\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;VALUE=DATE:20270101\r\nSUMMARY:New Year's Day\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:christmas-2027\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;VALUE=DATE:20271225\r\nSUMMARY:Christmas Day\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	source := filepath.Join(t.TempDir(), "holidays.ics")
+	if err := os.WriteFile(source, []byte(ics), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Parse([]byte("users:\n  - email: admin@example.com\n    calendars:\n      - name: Holidays\n        org: true\n        holidays: file://" + source + "\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	fixture := storetest.New()
+	acl := &fakeACL{entries: map[string][]store.ACLEntry{}}
+	st := fixture.Store
+	st.Users, st.ACLEntries = &fakeUsers{}, acl
+
+	result, err := Apply(context.Background(), st, file, nil)
+	if err != nil || result != (Result{UsersCreated: 1, CalendarsCreated: 1, OrgSharesSet: 1, EventsImported: 2}) {
+		t.Fatalf("first Apply() = %+v, %v", result, err)
+	}
+	entries := acl.entries["/dav/calendars/1"]
+	if len(entries) != 2 || entries[0].PrincipalHref != "DAV:authenticated" || entries[0].Privilege != "read" || entries[1].Privilege != "read-free-busy" {
+		t.Fatalf("org entries = %+v", entries)
+	}
+	if len(fixture.Events.Events) != 2 {
+		t.Fatalf("events = %d, want 2", len(fixture.Events.Events))
+	}
+
+	result, err = Apply(context.Background(), st, file, nil)
+	if err != nil || result != (Result{}) {
+		t.Fatalf("second Apply() = %+v, %v", result, err)
+	}
+
+	org := false
+	file.Users[0].Calendars[0].Org = &org
+	result, err = Apply(context.Background(), st, file, nil)
+	if err != nil || result != (Result{OrgSharesSet: 1}) || len(acl.entries["/dav/calendars/1"]) != 0 {
+		t.Fatalf("revoking Apply() = %+v, %v with entries %+v", result, err, acl.entries["/dav/calendars/1"])
+	}
+}
+
+func TestParseRejectsInvalidFiles(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown key":      "users:\n  - email: a@example.com\n    password: x\n",
+		"duplicate user":   "users:\n  - email: a@example.com\n  - email: A@example.com\n",
+		"bad timezone":     "users:\n  - email: a@example.com\n    calendars:\n      - name: Work\n        timezone: Mars/Olympus\n",
+		"bad color":        "users:\n  - email: a@example.com\n    calendars:\n      - name: Work\n        color: red\n",
+		"alias of a user":  "users:\n  - email: a@example.com\n    aliases: [B@example.com]\n  - email: b@example.com\n",
+		"share with owner": "users:\n  - email: a@example.com\n    calendars:\n      - name: Work\n        shares:\n          - email: a@example.com\n",
+		"unknown holidays": "users:\n  - email: a@example.com\n    calendars:\n      - name: Work\n        holidays: gopher://example.com/us\n",
+	} {
+		if _, err := Parse([]byte(body)); err == nil || !strings.HasPrefix(err.Error(), "bootstrap: ") {
+			t.Errorf("%s: Parse() error = %v", name, err)
+		}
+	}
+	if f, err := Parse(nil); err != nil || len(f.Users) != 0 {
+		t.Fatalf("empty file: %+v, %v", f, err)
+	}
+}
@@ -4,17 +4,123 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/quirks"
+)
+
+// Contact validation modes for Config.ContactValidation.
+const (
+	ContactValidationLenient = "lenient"
+	ContactValidationStrict  = "strict"
+)
+
+// Handling of iTIP METHOD properties in CalDAV PUTs, for Config.CalDAVMethod.
+const (
+	// CalDAVMethodReject refuses calendar data with a METHOD, as RFC 4791
+	// requires.
+	CalDAVMethodReject = "reject"
+	// CalDAVMethodStrip stores the objects of a scheduling message without
+	// its METHOD.
+	CalDAVMethodStrip = "strip"
+	// CalDAVMethodProcess applies a scheduling message to the stored event:
+	// CANCEL cancels it and REPLY records the attendee's answer.
+	CalDAVMethodProcess = "process"
+)
+
+// Feature flags, for Config.Features. Each gates a newer behaviour that an
+// admin can turn on for one account or group before the whole instance.
+const (
+	// FeaturePush offers WebDAV-Push on calendars and address books.
+	FeaturePush = "push"
+	// FeatureCalDAVRepair repairs calendar data CalDAV clients upload.
+	FeatureCalDAVRepair = "caldav-repair"
+	// FeatureFocusTime plans focus time for users who opt in.
+	FeatureFocusTime = "focus-time"
+)
+
+// FeatureFlags lists the feature flags in the order they are reported.
+var FeatureFlags = []string{FeaturePush, FeatureCalDAVRepair, FeatureFocusTime}
+
+// Log privacy levels for Config.LogPrivacy.
+const (
+	// LogPrivacyOff logs DAV bodies verbatim.
+	LogPrivacyOff = "off"
+	// LogPrivacyRedacted logs DAV bodies with personal values redacted.
+	LogPrivacyRedacted = "redacted"
+	// LogPrivacyStrict never logs DAV bodies and masks email addresses in
+	// every log line.
+	LogPrivacyStrict = "strict"
 )
 
+// BlobStorage selects where files are kept: a local directory or an
+// S3-compatible bucket. Neither set leaves the storage unconfigured.
+type BlobStorage struct {
+	Dir string
+	S3  S3Storage
+}
+
+// Configured reports whether a directory or bucket is set.
+func (b BlobStorage) Configured() bool {
+	return b.Dir != "" || b.S3.Bucket != ""
+}
+
+// S3Storage addresses an S3 or S3-compatible bucket, such as MinIO.
+type S3Storage struct {
+	// Endpoint defaults to the AWS endpoint for Region.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
 type Config struct {
-	ListenAddr   string
-	BaseURL      string
-	CommunityURL string
+	ListenAddr string
+	// ListenReusePort binds the listener with SO_REUSEPORT, so a new server
+	// can start on the same port before this one stops.
+	ListenReusePort bool
+	BaseURL         string
+	CommunityURL    string
+
+	// Shutdown controls how the server stops on SIGTERM or an admin drain
+	// request. It first drains for DrainDelay: /readyz fails and clients are
+	// asked to close their connections, so load balancers move traffic
+	// away. In-flight responses then get Timeout to finish.
+	Shutdown struct {
+		DrainDelay time.Duration
+		Timeout    time.Duration
+	}
 
 	DB struct {
 		DSN string
+		// ReplicaDSN is an optional read-only replica that serves the reads
+		// of DAV PROPFIND, REPORT and GET requests.
+		ReplicaDSN string
+		// Schema is the Postgres schema CalCard's tables live in, so that it
+		// can share a database with other applications. Empty means public.
+		// Load sets it as the search_path of DSN and ReplicaDSN, so every
+		// connection, including migrations, dump and restore, uses it.
+		Schema string
+	}
+
+	// Preflight runs the startup checks of the database, migrations,
+	// identity provider, SMTP and clocks, and refuses to start when one of
+	// them fails.
+	Preflight bool
+
+	// Redis is an optional server the replicas of one deployment share.
+	// With it, rate limits are enforced across replicas and revoked app
+	// passwords are dropped from every replica's credential cache; without
+	// it, each replica keeps its own.
+	Redis struct {
+		URL string
 	}
 
 	OAuth struct {
@@ -25,21 +131,302 @@ type Config struct {
 		RedirectPath string
 	}
 
+	// Session controls web UI sessions. Sessions slide forward by
+	// IdleTimeout on each request but never outlive MaxLifetime; the bind
+	// options revoke a session used from another IP address or browser.
 	Session struct {
-		Secret string
+		Secret          string
+		IdleTimeout     time.Duration
+		MaxLifetime     time.Duration
+		BindIP          bool
+		BindUserAgent   bool
+		CleanupInterval time.Duration
+	}
+
+	// DAV limits protect the server from clients that repeatedly resync
+	// whole collections or delete most of one at once.
+	DAV struct {
+		// ReportConcurrency caps in-flight query and sync REPORTs per
+		// account; 0 disables it. A REPORT over the cap waits up to
+		// ReportQueueWait for a slot before being refused.
+		ReportConcurrency int
+		ReportQueueWait   time.Duration
+		// SyncPageSize bounds sync-collection pages once an account is throttled.
+		SyncPageSize int
+		// FullResyncLimit full resyncs are served unpaged per FullResyncWindow.
+		FullResyncLimit  int
+		FullResyncWindow time.Duration
+		// MassDeletePercent holds a client's DELETEs for owner review once they
+		// exceed this share of a collection within MassDeleteWindow; 0 disables it.
+		MassDeletePercent int
+		MassDeleteWindow  time.Duration
+		// MassDeleteMinItems exempts collections smaller than this.
+		MassDeleteMinItems int
+		// AuthCacheTTL is how long verified Basic-auth credentials are reused
+		// without another bcrypt check.
+		AuthCacheTTL time.Duration
+		// NotFoundCacheTTL is how long a GET of a missing calendar object or
+		// contact is answered 404 without looking it up again; 0 disables it.
+		NotFoundCacheTTL time.Duration
+		// ClientQuirks adapts responses for clients with known interop bugs,
+		// matched by User-Agent; empty applies none.
+		ClientQuirks quirks.Rules
+		// StrictSyncTokens refuses stale sync tokens with a valid-sync-token
+		// error; otherwise they are answered with a full sync.
+		StrictSyncTokens bool
+		// LogBodies logs request and response bodies at Debug level, as far
+		// as LogPrivacy allows.
+		LogBodies bool
+		// InteropDir is where the DAV traffic of app passwords labelled
+		// "interop:<profile>" is recorded; empty records nothing.
+		InteropDir string
+		// PushEnabled advertises WebDAV-Push on collections and sends Web
+		// Push messages to the clients subscribed to them.
+		PushEnabled bool
+	}
+
+	// Blob is the file storage shared by attachments and contact photos.
+	Blob BlobStorage
+
+	// Scan configures the malware scanner that inline attachments and
+	// uploaded contact photos pass through before they are stored. Scanning
+	// is off unless ClamdAddress or Command is set.
+	Scan struct {
+		// ClamdAddress is a clamd socket: a path or "unix:" path for a Unix
+		// socket, otherwise a TCP host:port.
+		ClamdAddress string
+		// Command is run with the file on standard input; exit status 0
+		// means clean and 1 infected, with the signature on standard output.
+		Command string
+		Timeout time.Duration
+	}
+
+	// Backup configures scheduled snapshots of every collection. Backups are
+	// disabled unless Dir or S3.Bucket is set.
+	Backup struct {
+		BlobStorage
+		Interval time.Duration
+		// Retention is the number of completed snapshots kept.
+		Retention int
+	}
+
+	// Fsck configures the periodic consistency check of stored calendar and
+	// contact data. Scheduled checks are off unless Interval is set; admins
+	// can always start one through the admin API.
+	Fsck struct {
+		Interval time.Duration
+		// Repair applies the automatic fixes during scheduled checks.
+		Repair bool
+	}
+
+	// Telemetry keeps anonymous usage counts in the database for the admin
+	// usage page. It is off unless Enabled is set, and nothing is sent
+	// anywhere.
+	Telemetry struct {
+		Enabled  bool
+		Interval time.Duration
+	}
+
+	// LDAP serves address books and the user directory read-only over LDAPS
+	// for desk phones and mail clients that cannot use CardDAV. It is off
+	// unless Addr is set.
+	LDAP struct {
+		Addr     string
+		CertFile string
+		KeyFile  string
+		// BaseDN is the suffix of every entry the server returns.
+		BaseDN string
+	}
+
+	// ActiveSyncEnabled serves a read-only Exchange ActiveSync endpoint at
+	// /Microsoft-Server-ActiveSync for devices that only speak ActiveSync.
+	ActiveSyncEnabled bool
+
+	// CalDAVDisabled and CardDAVDisabled turn off the calendar or contact
+	// half of the server: its DAV collections, REST and web routes, and its
+	// discovery properties. The schema is unchanged so either half can be
+	// turned back on without losing data.
+	CalDAVDisabled  bool
+	CardDAVDisabled bool
+
+	// SMTP sends scheduling email such as booking confirmations. Email is
+	// disabled unless Host is set.
+	SMTP struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+		From     string
+	}
+
+	// ContactValidation is how contact writes with invalid TEL, EMAIL or URL
+	// values are handled: ContactValidationLenient stores them and reports
+	// them as contact quality issues, ContactValidationStrict rejects them.
+	ContactValidation string
+
+	// CalDAVMethod is how CalDAV PUTs of iTIP messages, such as invitation
+	// files dragged into a client, are handled: CalDAVMethodReject,
+	// CalDAVMethodStrip or CalDAVMethodProcess.
+	CalDAVMethod string
+
+	// RequireServerUIDs makes the REST API accept only UIDs the server
+	// issued, in the form uuid@host, when it creates events. CalDAV PUTs
+	// are exempt.
+	RequireServerUIDs bool
+
+	// CalDAVRepair makes CalDAV PUTs repair bare line endings and a missing
+	// PRODID instead of storing the data as sent.
+	CalDAVRepair bool
+
+	// Features is the instance-wide setting of each feature flag, which
+	// admins can override for a user or group. APP_DAV_PUSH_ENABLED and
+	// APP_CALDAV_REPAIR set the default of push and caldav-repair, and
+	// APP_FEATURES overrides any: "push" turns a flag on, "-push" off.
+	Features map[string]bool
+
+	// PasswordAuth lets DAV clients sign in with directory passwords as
+	// well as app passwords. Backend is PasswordAuthLDAP, PasswordAuthCommand
+	// or PasswordAuthHTTP; empty leaves app passwords as the only option.
+	PasswordAuth struct {
+		Backend string
+		// LDAPURL is the ldaps:// (or ldap://) server to bind against, as
+		// LDAPBindDN with {username} replaced by the sign-in name.
+		LDAPURL    string
+		LDAPBindDN string
+		// Command is run with the username and password on separate lines
+		// of its standard input, and accepts them by exiting 0.
+		Command string
+		// HTTPURL receives the credentials as a JSON POST and accepts them
+		// with a 2xx response.
+		HTTPURL string
+		Timeout time.Duration
+		// EmailDomain completes sign-in names without an @ into the email a
+		// user is known by.
+		EmailDomain string
+		// Provision creates the account of a directory user signing in for
+		// the first time.
+		Provision bool
+	}
+
+	// SignIn configures DAV sign-in monitoring.
+	SignIn struct {
+		// LockoutThreshold is how many refused DAV passwords within
+		// LockoutWindow lock an account against addresses it has never
+		// signed in from, for LockoutWindow. Zero disables the lockout.
+		LockoutThreshold int
+		LockoutWindow    time.Duration
+		// NotifyNewAddress emails users when their account signs in to DAV
+		// from an address it never signed in from before.
+		NotifyNewAddress bool
+	}
+
+	// Alarms configures pushing event alarms to users' notification
+	// providers.
+	Alarms struct {
+		// AllowPrivateProviders lets providers use plain http and loopback
+		// or private addresses, such as a Gotify server on the LAN.
+		AllowPrivateProviders bool
+	}
+
+	// Quota limits the calendar and contact data each user keeps. Users are
+	// warned at 80% and 95% of it.
+	Quota struct {
+		// UserBytes is each user's quota; 0 means no quota.
+		UserBytes int64
+	}
+
+	// AdminEmails lists the primary emails allowed to use the admin API.
+	AdminEmails []string
+
+	// BootstrapFile names a YAML file of users, calendars and shares that is
+	// applied at every startup, creating whatever is missing.
+	BootstrapFile string
+
+	// TravelSpeedKmh is the speed the agenda assumes for getting between
+	// events at different places when it warns of ones that cannot be
+	// reached in time.
+	TravelSpeedKmh int
+
+	// Network restricts route groups to client networks and countries.
+	Network struct {
+		Admin NetworkRules
+		API   NetworkRules
+		DAV   NetworkRules
+		UI    NetworkRules
+		// CountryHeader names the header a trusted proxy sets to the
+		// client's two-letter country code, such as CF-IPCountry.
+		CountryHeader string
 	}
 
 	PrometheusEnabled bool
-	TrustedProxies    []string
+	// ProbeToken is the bearer token GET /api/probe requires; empty
+	// disables the synthetic probe.
+	ProbeToken     string
+	TrustedProxies []string
+	// RateLimits are the requests per second, and the bursts above that,
+	// each client address may make: Auth to sign-in and public link routes,
+	// DAV to DAV and ActiveSync. They apply on reload.
+	RateLimits struct {
+		AuthRate  int
+		AuthBurst int
+		DAVRate   int
+		DAVBurst  int
+	}
+	// AllowInsecureBasicAuth accepts Basic credentials over plain HTTP.
+	// Otherwise only requests made over TLS, or that a trusted proxy marks
+	// with X-Forwarded-Proto: https, may sign in with a password.
+	AllowInsecureBasicAuth bool
+
+	// LogLevel is the minimum level logged: Trace, Debug, Info, Warn or Error.
+	LogLevel string
+	// LogPrivacy controls how much personal data may reach the logs: one of
+	// LogPrivacyOff, LogPrivacyRedacted or LogPrivacyStrict.
+	LogPrivacy string
+}
+
+// NetworkRules lists the clients a route group admits. Entries are IPs,
+// CIDRs or two-letter country codes. Deny wins over Allow, and an empty
+// Allow admits every client not denied.
+type NetworkRules struct {
+	Allow []string
+	Deny  []string
 }
 
+// Empty reports whether the rules admit every client.
+func (n NetworkRules) Empty() bool {
+	return len(n.Allow) == 0 && len(n.Deny) == 0
+}
+
+// Password authentication backends for Config.PasswordAuth.Backend.
+const (
+	PasswordAuthLDAP    = "ldap"
+	PasswordAuthCommand = "command"
+	PasswordAuthHTTP    = "http"
+)
+
+// Load reads the configuration from the environment. When APP_CONFIG_FILE
+// names a YAML or TOML file, its settings fill in variables the environment
+// leaves unset, so environment variables always take precedence.
 func Load() (*Config, error) {
 	cfg := &Config{}
 
+	origin, err := applyConfigFile(strings.TrimSpace(os.Getenv("APP_CONFIG_FILE")))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSettings(origin); err != nil {
+		return nil, err
+	}
+
 	cfg.ListenAddr = getenvDefault("APP_LISTEN_ADDR", ":8080")
+	cfg.ListenReusePort = getenvBool("APP_LISTEN_REUSEPORT", false)
+	cfg.Shutdown.DrainDelay = getenvDuration("APP_SHUTDOWN_DRAIN_DELAY", 0)
+	cfg.Shutdown.Timeout = getenvDuration("APP_SHUTDOWN_TIMEOUT", 30*time.Second)
 	cfg.BaseURL = getenvDefault("APP_BASE_URL", "http://localhost:8080")
 	cfg.CommunityURL = getenvDefault("APP_COMMUNITY_URL", "https://github.com/jw6ventures/calcard/issues")
 	cfg.DB.DSN = os.Getenv("APP_DB_DSN")
+	cfg.DB.ReplicaDSN = os.Getenv("APP_DB_REPLICA_DSN")
+	cfg.DB.Schema = strings.TrimSpace(os.Getenv("APP_DB_SCHEMA"))
 
 	if cfg.DB.DSN == "" {
 		host := os.Getenv("APP_DB_HOST")
@@ -68,18 +455,132 @@ func Load() (*Config, error) {
 		}
 	}
 
+	cfg.Preflight = getenvBool("APP_PREFLIGHT", true)
+	cfg.Redis.URL = os.Getenv("APP_REDIS_URL")
 	cfg.OAuth.ClientID = os.Getenv("APP_OAUTH_CLIENT_ID")
 	cfg.OAuth.ClientSecret = os.Getenv("APP_OAUTH_CLIENT_SECRET")
 	cfg.OAuth.IssuerURL = os.Getenv("APP_OAUTH_ISSUER_URL")
 	cfg.OAuth.DiscoveryURL = os.Getenv("APP_OAUTH_DISCOVERY_URL")
 	cfg.OAuth.RedirectPath = getenvDefault("APP_OAUTH_REDIRECT_PATH", "/auth/callback")
 	cfg.Session.Secret = os.Getenv("APP_SESSION_SECRET")
+	cfg.Session.IdleTimeout = getenvDuration("APP_SESSION_IDLE_TIMEOUT", 7*24*time.Hour)
+	cfg.Session.MaxLifetime = getenvDuration("APP_SESSION_MAX_LIFETIME", 30*24*time.Hour)
+	cfg.Session.BindIP = getenvBool("APP_SESSION_BIND_IP", false)
+	cfg.Session.BindUserAgent = getenvBool("APP_SESSION_BIND_USER_AGENT", false)
+	cfg.Session.CleanupInterval = getenvDuration("APP_SESSION_CLEANUP_INTERVAL", time.Hour)
 	cfg.PrometheusEnabled = getenvBool("APP_PROMETHEUS_ENDPOINT_ENABLED", false)
+	cfg.ProbeToken = os.Getenv("APP_PROBE_TOKEN")
 	cfg.TrustedProxies = getenvList("APP_TRUSTED_PROXIES")
+	cfg.RateLimits.AuthRate = getenvInt("APP_RATE_LIMIT_AUTH", 5)
+	cfg.RateLimits.AuthBurst = getenvInt("APP_RATE_LIMIT_AUTH_BURST", 10)
+	cfg.RateLimits.DAVRate = getenvInt("APP_RATE_LIMIT_DAV", 20)
+	cfg.RateLimits.DAVBurst = getenvInt("APP_RATE_LIMIT_DAV_BURST", 50)
+	cfg.AllowInsecureBasicAuth = getenvBool("APP_ALLOW_INSECURE_BASIC_AUTH", false)
+	cfg.DAV.ReportConcurrency = getenvInt("APP_DAV_REPORT_CONCURRENCY", 4)
+	cfg.DAV.ReportQueueWait = getenvDuration("APP_DAV_REPORT_QUEUE_WAIT", 10*time.Second)
+	cfg.DAV.SyncPageSize = getenvInt("APP_DAV_SYNC_PAGE_SIZE", 250)
+	cfg.DAV.FullResyncLimit = getenvInt("APP_DAV_FULL_RESYNC_LIMIT", 3)
+	cfg.DAV.FullResyncWindow = getenvDuration("APP_DAV_FULL_RESYNC_WINDOW", 15*time.Minute)
+	cfg.DAV.MassDeletePercent = getenvInt("APP_DAV_MASS_DELETE_PERCENT", 50)
+	cfg.DAV.MassDeleteWindow = getenvDuration("APP_DAV_MASS_DELETE_WINDOW", 10*time.Minute)
+	cfg.DAV.MassDeleteMinItems = getenvInt("APP_DAV_MASS_DELETE_MIN_ITEMS", 20)
+	cfg.DAV.AuthCacheTTL = getenvDuration("APP_DAV_AUTH_CACHE_TTL", time.Minute)
+	cfg.DAV.NotFoundCacheTTL = getenvDuration("APP_DAV_NOT_FOUND_CACHE_TTL", 30*time.Second)
+	clientQuirks, err := quirks.Parse(getenvList("APP_DAV_CLIENT_QUIRKS"))
+	if err != nil {
+		return nil, fmt.Errorf("APP_DAV_CLIENT_QUIRKS: %w", err)
+	}
+	cfg.DAV.ClientQuirks = clientQuirks
+	cfg.DAV.StrictSyncTokens = getenvBool("APP_DAV_STRICT_SYNC_TOKENS", true)
+	cfg.Blob.Dir = os.Getenv("APP_BLOB_DIR")
+	cfg.Blob.S3.Endpoint = os.Getenv("APP_BLOB_S3_ENDPOINT")
+	cfg.Blob.S3.Region = getenvDefault("APP_BLOB_S3_REGION", "us-east-1")
+	cfg.Blob.S3.Bucket = os.Getenv("APP_BLOB_S3_BUCKET")
+	cfg.Blob.S3.Prefix = os.Getenv("APP_BLOB_S3_PREFIX")
+	cfg.Blob.S3.AccessKeyID = os.Getenv("APP_BLOB_S3_ACCESS_KEY_ID")
+	cfg.Blob.S3.SecretAccessKey = os.Getenv("APP_BLOB_S3_SECRET_ACCESS_KEY")
+	cfg.Scan.ClamdAddress = os.Getenv("APP_SCAN_CLAMD_ADDRESS")
+	cfg.Scan.Command = os.Getenv("APP_SCAN_COMMAND")
+	cfg.Scan.Timeout = getenvDuration("APP_SCAN_TIMEOUT", 30*time.Second)
+
+	cfg.Backup.Dir = os.Getenv("APP_BACKUP_DIR")
+	cfg.Backup.S3.Endpoint = os.Getenv("APP_BACKUP_S3_ENDPOINT")
+	cfg.Backup.S3.Region = getenvDefault("APP_BACKUP_S3_REGION", "us-east-1")
+	cfg.Backup.S3.Bucket = os.Getenv("APP_BACKUP_S3_BUCKET")
+	cfg.Backup.S3.Prefix = os.Getenv("APP_BACKUP_S3_PREFIX")
+	cfg.Backup.S3.AccessKeyID = os.Getenv("APP_BACKUP_S3_ACCESS_KEY_ID")
+	cfg.Backup.S3.SecretAccessKey = os.Getenv("APP_BACKUP_S3_SECRET_ACCESS_KEY")
+	cfg.Backup.Interval = getenvDuration("APP_BACKUP_INTERVAL", 24*time.Hour)
+	cfg.Backup.Retention = getenvInt("APP_BACKUP_RETENTION", 7)
+	cfg.Fsck.Interval = getenvDuration("APP_FSCK_INTERVAL", 0)
+	cfg.Fsck.Repair = getenvBool("APP_FSCK_REPAIR", false)
+	cfg.Telemetry.Enabled = getenvBool("APP_TELEMETRY_ENABLED", false)
+	cfg.Telemetry.Interval = getenvDuration("APP_TELEMETRY_INTERVAL", 24*time.Hour)
+	cfg.LDAP.Addr = os.Getenv("APP_LDAP_ADDR")
+	cfg.LDAP.CertFile = os.Getenv("APP_LDAP_TLS_CERT")
+	cfg.LDAP.KeyFile = os.Getenv("APP_LDAP_TLS_KEY")
+	cfg.LDAP.BaseDN = strings.TrimSpace(getenvDefault("APP_LDAP_BASE_DN", "dc=calcard"))
+	cfg.ActiveSyncEnabled = getenvBool("APP_ACTIVESYNC_ENABLED", false)
+	cfg.CalDAVDisabled = !getenvBool("APP_CALDAV_ENABLED", true)
+	cfg.CardDAVDisabled = !getenvBool("APP_CARDDAV_ENABLED", true)
+	cfg.ContactValidation = strings.ToLower(strings.TrimSpace(getenvDefault("APP_CONTACT_VALIDATION", ContactValidationLenient)))
+	cfg.CalDAVMethod = strings.ToLower(strings.TrimSpace(getenvDefault("APP_CALDAV_METHOD", CalDAVMethodReject)))
+	cfg.CalDAVRepair = getenvBool("APP_CALDAV_REPAIR", false)
+	cfg.RequireServerUIDs = getenvBool("APP_API_REQUIRE_SERVER_UIDS", false)
+	cfg.AdminEmails = getenvList("APP_ADMIN_EMAILS")
+	cfg.BootstrapFile = strings.TrimSpace(os.Getenv("APP_BOOTSTRAP_FILE"))
+	cfg.TravelSpeedKmh = getenvInt("APP_TRAVEL_SPEED_KMH", 30)
+	cfg.PasswordAuth.Backend = strings.ToLower(strings.TrimSpace(os.Getenv("APP_PASSWORD_AUTH_BACKEND")))
+	cfg.PasswordAuth.LDAPURL = os.Getenv("APP_PASSWORD_AUTH_LDAP_URL")
+	cfg.PasswordAuth.LDAPBindDN = os.Getenv("APP_PASSWORD_AUTH_LDAP_BIND_DN")
+	cfg.PasswordAuth.Command = os.Getenv("APP_PASSWORD_AUTH_COMMAND")
+	cfg.PasswordAuth.HTTPURL = os.Getenv("APP_PASSWORD_AUTH_HTTP_URL")
+	cfg.PasswordAuth.Timeout = getenvDuration("APP_PASSWORD_AUTH_TIMEOUT", 5*time.Second)
+	cfg.PasswordAuth.EmailDomain = strings.TrimPrefix(strings.TrimSpace(os.Getenv("APP_PASSWORD_AUTH_EMAIL_DOMAIN")), "@")
+	cfg.PasswordAuth.Provision = getenvBool("APP_PASSWORD_AUTH_PROVISION", false)
+	cfg.SignIn.LockoutThreshold = getenvInt("APP_SIGNIN_LOCKOUT_THRESHOLD", 10)
+	cfg.SignIn.LockoutWindow = getenvDuration("APP_SIGNIN_LOCKOUT_WINDOW", 15*time.Minute)
+	cfg.SignIn.NotifyNewAddress = getenvBool("APP_SIGNIN_NOTIFY_NEW_ADDRESS", true)
+	cfg.Alarms.AllowPrivateProviders = getenvBool("APP_ALARM_PRIVATE_PROVIDERS", false)
+	cfg.Quota.UserBytes = int64(max(getenvInt("APP_USER_QUOTA_MB", 0), 0)) << 20
+	cfg.Network.Admin = NetworkRules{Allow: getenvList("APP_ACL_ADMIN_ALLOW"), Deny: getenvList("APP_ACL_ADMIN_DENY")}
+	cfg.Network.API = NetworkRules{Allow: getenvList("APP_ACL_API_ALLOW"), Deny: getenvList("APP_ACL_API_DENY")}
+	cfg.Network.DAV = NetworkRules{Allow: getenvList("APP_ACL_DAV_ALLOW"), Deny: getenvList("APP_ACL_DAV_DENY")}
+	cfg.Network.UI = NetworkRules{Allow: getenvList("APP_ACL_UI_ALLOW"), Deny: getenvList("APP_ACL_UI_DENY")}
+	cfg.Network.CountryHeader = strings.TrimSpace(os.Getenv("APP_ACL_COUNTRY_HEADER"))
+	cfg.SMTP.Host = os.Getenv("APP_SMTP_HOST")
+	cfg.SMTP.Port = getenvInt("APP_SMTP_PORT", 587)
+	cfg.SMTP.Username = os.Getenv("APP_SMTP_USERNAME")
+	cfg.SMTP.Password = os.Getenv("APP_SMTP_PASSWORD")
+	cfg.SMTP.From = os.Getenv("APP_SMTP_FROM")
+	cfg.LogLevel = getenvDefault("APP_LOG_LEVEL", getenvDefault("LOG_LEVEL", "Info"))
+	cfg.LogPrivacy = strings.ToLower(strings.TrimSpace(getenvDefault("APP_LOG_PRIVACY", LogPrivacyRedacted)))
+	cfg.DAV.LogBodies = getenvBool("APP_DAV_LOG_BODIES", false)
+	cfg.DAV.InteropDir = strings.TrimSpace(os.Getenv("APP_DAV_INTEROP_DIR"))
+	cfg.DAV.PushEnabled = getenvBool("APP_DAV_PUSH_ENABLED", false)
+	features, err := parseFeatures(getenvList("APP_FEATURES"), cfg.featureDefaults())
+	if err != nil {
+		return nil, err
+	}
+	cfg.Features = features
 
 	if cfg.DB.DSN == "" {
 		return nil, errors.New("APP_DB_DSN is required (or set APP_DB_HOST, APP_DB_NAME, APP_DB_USER, and APP_DB_PASSWORD)")
 	}
+	if cfg.DB.Schema != "" {
+		if !schemaName.MatchString(cfg.DB.Schema) || strings.HasPrefix(cfg.DB.Schema, "pg_") {
+			return nil, fmt.Errorf("APP_DB_SCHEMA %q must be a lowercase name of letters, digits and underscores, not starting with pg_", cfg.DB.Schema)
+		}
+		var err error
+		if cfg.DB.DSN, err = withSearchPath(cfg.DB.DSN, cfg.DB.Schema); err != nil {
+			return nil, fmt.Errorf("invalid APP_DB_DSN: %w", err)
+		}
+		if cfg.DB.ReplicaDSN != "" {
+			if cfg.DB.ReplicaDSN, err = withSearchPath(cfg.DB.ReplicaDSN, cfg.DB.Schema); err != nil {
+				return nil, fmt.Errorf("invalid APP_DB_REPLICA_DSN: %w", err)
+			}
+		}
+	}
 	if cfg.OAuth.ClientID == "" || cfg.OAuth.ClientSecret == "" {
 		return nil, fmt.Errorf("oauth configuration is required: client id and secret")
 	}
@@ -92,9 +593,79 @@ func Load() (*Config, error) {
 	if len(cfg.Session.Secret) < 32 {
 		return nil, fmt.Errorf("APP_SESSION_SECRET must be at least 32 characters long (got %d)", len(cfg.Session.Secret))
 	}
+	if cfg.ProbeToken != "" && len(cfg.ProbeToken) < 32 {
+		return nil, fmt.Errorf("APP_PROBE_TOKEN must be at least 32 characters long (got %d)", len(cfg.ProbeToken))
+	}
+	if cfg.Session.MaxLifetime < cfg.Session.IdleTimeout {
+		return nil, errors.New("APP_SESSION_MAX_LIFETIME must not be shorter than APP_SESSION_IDLE_TIMEOUT")
+	}
 	if err := validateTrustedProxies(cfg.TrustedProxies); err != nil {
 		return nil, err
 	}
+	if cfg.Redis.URL != "" {
+		if u, err := url.Parse(cfg.Redis.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+			return nil, errors.New("APP_REDIS_URL must be a redis:// or rediss:// URL with a host")
+		}
+	}
+	if err := validateBlobStorage(cfg.Blob, "BLOB"); err != nil {
+		return nil, err
+	}
+	if err := validateBackup(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Scan.ClamdAddress != "" && cfg.Scan.Command != "" {
+		return nil, errors.New("set only one of APP_SCAN_CLAMD_ADDRESS and APP_SCAN_COMMAND")
+	}
+	if cfg.Scan.Timeout <= 0 {
+		return nil, errors.New("APP_SCAN_TIMEOUT must be positive")
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"APP_RATE_LIMIT_AUTH", cfg.RateLimits.AuthRate},
+		{"APP_RATE_LIMIT_AUTH_BURST", cfg.RateLimits.AuthBurst},
+		{"APP_RATE_LIMIT_DAV", cfg.RateLimits.DAVRate},
+		{"APP_RATE_LIMIT_DAV_BURST", cfg.RateLimits.DAVBurst},
+	} {
+		if limit.value <= 0 {
+			return nil, fmt.Errorf("%s must be positive", limit.name)
+		}
+	}
+	if cfg.TravelSpeedKmh <= 0 {
+		return nil, errors.New("APP_TRAVEL_SPEED_KMH must be positive")
+	}
+	if cfg.ContactValidation != ContactValidationLenient && cfg.ContactValidation != ContactValidationStrict {
+		return nil, fmt.Errorf("APP_CONTACT_VALIDATION must be %q or %q", ContactValidationLenient, ContactValidationStrict)
+	}
+	switch cfg.CalDAVMethod {
+	case CalDAVMethodReject, CalDAVMethodStrip, CalDAVMethodProcess:
+	default:
+		return nil, fmt.Errorf("APP_CALDAV_METHOD must be %q, %q or %q", CalDAVMethodReject, CalDAVMethodStrip, CalDAVMethodProcess)
+	}
+	switch cfg.LogPrivacy {
+	case LogPrivacyOff, LogPrivacyRedacted, LogPrivacyStrict:
+	default:
+		return nil, fmt.Errorf("APP_LOG_PRIVACY must be %q, %q or %q", LogPrivacyOff, LogPrivacyRedacted, LogPrivacyStrict)
+	}
+	if cfg.CalDAVDisabled && cfg.CardDAVDisabled {
+		return nil, errors.New("APP_CALDAV_ENABLED and APP_CARDDAV_ENABLED cannot both be false")
+	}
+	if cfg.LDAP.Addr != "" && (cfg.LDAP.CertFile == "" || cfg.LDAP.KeyFile == "") {
+		return nil, errors.New("APP_LDAP_TLS_CERT and APP_LDAP_TLS_KEY are required with APP_LDAP_ADDR")
+	}
+	if cfg.LDAP.BaseDN == "" || !strings.Contains(cfg.LDAP.BaseDN, "=") {
+		return nil, fmt.Errorf("APP_LDAP_BASE_DN must be a DN such as dc=example,dc=com (got %q)", cfg.LDAP.BaseDN)
+	}
+	if err := validatePasswordAuth(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateNetwork(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		return nil, errors.New("APP_SMTP_FROM is required with APP_SMTP_HOST")
+	}
 
 	if len(cfg.TrustedProxies) == 0 {
 		fmt.Println("WARNING: No APP_TRUSTED_PROXIES configured. CalCard will trust all proxies - Not recommended for public environments.")
@@ -122,6 +693,24 @@ func getenvBool(key string, def bool) bool {
 	return def
 }
 
+func getenvInt(key string, def int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+func getenvDuration(key string, def time.Duration) time.Duration {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
 func getenvList(key string) []string {
 	if v := os.Getenv(key); v != "" {
 		var result []string
@@ -135,6 +724,157 @@ func getenvList(key string) []string {
 	return nil
 }
 
+func validateBackup(cfg *Config) error {
+	if err := validateBlobStorage(cfg.Backup.BlobStorage, "BACKUP"); err != nil {
+		return err
+	}
+	if cfg.Backup.Configured() && cfg.Backup.Retention < 1 {
+		return errors.New("APP_BACKUP_RETENTION must be at least 1")
+	}
+	return nil
+}
+
+// validateBlobStorage checks the storage read from the variables starting
+// with APP_ and the section name, such as BACKUP.
+func validateBlobStorage(b BlobStorage, section string) error {
+	if b.Dir != "" && b.S3.Bucket != "" {
+		return fmt.Errorf("set only one of APP_%[1]s_DIR and APP_%[1]s_S3_BUCKET", section)
+	}
+	if b.S3.Bucket != "" && (b.S3.AccessKeyID == "" || b.S3.SecretAccessKey == "") {
+		return fmt.Errorf("APP_%[1]s_S3_ACCESS_KEY_ID and APP_%[1]s_S3_SECRET_ACCESS_KEY are required with APP_%[1]s_S3_BUCKET", section)
+	}
+	return nil
+}
+
+func validatePasswordAuth(cfg *Config) error {
+	pa := cfg.PasswordAuth
+	switch pa.Backend {
+	case "":
+		return nil
+	case PasswordAuthLDAP:
+		if !strings.HasPrefix(pa.LDAPURL, "ldaps://") && !strings.HasPrefix(pa.LDAPURL, "ldap://") {
+			return errors.New("APP_PASSWORD_AUTH_LDAP_URL must be an ldaps:// or ldap:// URL with APP_PASSWORD_AUTH_BACKEND=ldap")
+		}
+		if !strings.Contains(pa.LDAPBindDN, "{username}") {
+			return errors.New("APP_PASSWORD_AUTH_LDAP_BIND_DN must contain {username}, e.g. uid={username},ou=people,dc=example,dc=com")
+		}
+	case PasswordAuthCommand:
+		if strings.TrimSpace(pa.Command) == "" {
+			return errors.New("APP_PASSWORD_AUTH_COMMAND is required with APP_PASSWORD_AUTH_BACKEND=command")
+		}
+	case PasswordAuthHTTP:
+		if !strings.HasPrefix(pa.HTTPURL, "https://") && !strings.HasPrefix(pa.HTTPURL, "http://") {
+			return errors.New("APP_PASSWORD_AUTH_HTTP_URL must be an http(s) URL with APP_PASSWORD_AUTH_BACKEND=http")
+		}
+	default:
+		return fmt.Errorf("APP_PASSWORD_AUTH_BACKEND must be %q, %q or %q", PasswordAuthLDAP, PasswordAuthCommand, PasswordAuthHTTP)
+	}
+	return nil
+}
+
+// validateNetwork checks the APP_ACL_* rules. Country codes need
+// APP_ACL_COUNTRY_HEADER and APP_TRUSTED_PROXIES, since only a trusted proxy
+// can tell where a client is.
+func validateNetwork(cfg *Config) error {
+	groups := []struct {
+		name  string
+		rules NetworkRules
+	}{
+		{"ADMIN", cfg.Network.Admin},
+		{"API", cfg.Network.API},
+		{"DAV", cfg.Network.DAV},
+		{"UI", cfg.Network.UI},
+	}
+	for _, g := range groups {
+		for _, list := range []struct {
+			suffix  string
+			entries []string
+		}{{"ALLOW", g.rules.Allow}, {"DENY", g.rules.Deny}} {
+			for _, entry := range list.entries {
+				if IsCountryCode(entry) {
+					if cfg.Network.CountryHeader == "" || len(cfg.TrustedProxies) == 0 {
+						return fmt.Errorf("APP_ACL_%s_%s lists country %q, which needs APP_ACL_COUNTRY_HEADER and APP_TRUSTED_PROXIES", g.name, list.suffix, entry)
+					}
+					continue
+				}
+				if _, _, err := net.ParseCIDR(entry); err == nil {
+					continue
+				}
+				if net.ParseIP(entry) == nil {
+					return fmt.Errorf("APP_ACL_%s_%s contains invalid IP, CIDR or country code %q", g.name, list.suffix, entry)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// IsCountryCode reports whether an APP_ACL_* entry is a two-letter country
+// code rather than an address.
+func IsCountryCode(entry string) bool {
+	if len(entry) != 2 {
+		return false
+	}
+	for _, c := range entry {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// FeatureEnabled reports the instance-wide setting of a feature flag.
+// Configs not built by Load, as in tests, get the flag's default.
+func (c *Config) FeatureEnabled(name string) bool {
+	if enabled, ok := c.Features[name]; ok {
+		return enabled
+	}
+	return c.featureDefaults()[name]
+}
+
+// featureDefaults returns the setting of each feature flag before
+// APP_FEATURES is applied.
+func (c *Config) featureDefaults() map[string]bool {
+	return map[string]bool{
+		FeaturePush:         c.DAV.PushEnabled,
+		FeatureCalDAVRepair: c.CalDAVRepair,
+		FeatureFocusTime:    true,
+	}
+}
+
+// parseFeatures applies APP_FEATURES to the flags' defaults: each entry
+// names a flag to turn on, or with a leading "-" to turn off.
+func parseFeatures(entries []string, defaults map[string]bool) (map[string]bool, error) {
+	for _, entry := range entries {
+		name, off := strings.CutPrefix(strings.ToLower(entry), "-")
+		if _, ok := defaults[name]; !ok {
+			return nil, fmt.Errorf("APP_FEATURES names unknown feature flag %q; known flags are %s", name, strings.Join(FeatureFlags, ", "))
+		}
+		defaults[name] = !off
+	}
+	return defaults, nil
+}
+
+// schemaName matches the schema names APP_DB_SCHEMA accepts: ones that
+// need no quoting in SQL or in a search_path.
+var schemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// withSearchPath sets the search_path run-time parameter of a connection
+// string, in URL or key=value form, replacing any it has.
+func withSearchPath(dsn, schema string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema, nil
+}
+
 func validateTrustedProxies(values []string) error {
 	for _, value := range values {
 		if _, _, err := net.ParseCIDR(value); err == nil {
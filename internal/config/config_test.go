@@ -1,9 +1,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadUsesExplicitDSNAndParsesFlags(t *testing.T) {
@@ -26,6 +30,9 @@ func TestLoadUsesExplicitDSNAndParsesFlags(t *testing.T) {
 	if !cfg.PrometheusEnabled {
 		t.Fatal("expected PrometheusEnabled")
 	}
+	if cfg.Session.IdleTimeout != 7*24*time.Hour || cfg.Session.MaxLifetime != 30*24*time.Hour || cfg.Session.BindIP || cfg.Session.CleanupInterval != time.Hour {
+		t.Fatalf("unexpected session defaults %+v", cfg.Session)
+	}
 	want := []string{"10.0.0.0/8", "127.0.0.1/32", "2001:db8::1/128"}
 	if !reflect.DeepEqual(cfg.TrustedProxies, want) {
 		t.Fatalf("TrustedProxies = %#v, want %#v", cfg.TrustedProxies, want)
@@ -74,6 +81,60 @@ func TestLoadBuildsDSNFromComponents(t *testing.T) {
 	}
 }
 
+func TestLoadSetsSchemaAsSearchPath(t *testing.T) {
+	t.Setenv("APP_DB_DSN", "postgres://user:pass@db:5432/shared?sslmode=disable&search_path=public")
+	t.Setenv("APP_DB_REPLICA_DSN", "host=replica dbname=shared")
+	t.Setenv("APP_DB_SCHEMA", "calcard")
+	t.Setenv("APP_OAUTH_CLIENT_ID", "client")
+	t.Setenv("APP_OAUTH_CLIENT_SECRET", "secret")
+	t.Setenv("APP_OAUTH_ISSUER_URL", "https://issuer.example")
+	t.Setenv("APP_SESSION_SECRET", strings.Repeat("s", 32))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := "postgres://user:pass@db:5432/shared?search_path=calcard&sslmode=disable"; cfg.DB.DSN != want {
+		t.Fatalf("DB.DSN = %q, want %q", cfg.DB.DSN, want)
+	}
+	if want := "host=replica dbname=shared search_path=calcard"; cfg.DB.ReplicaDSN != want {
+		t.Fatalf("DB.ReplicaDSN = %q, want %q", cfg.DB.ReplicaDSN, want)
+	}
+
+	for _, bad := range []string{"CalCard", "cal-card", "pg_calcard", "calcard;drop"} {
+		t.Setenv("APP_DB_SCHEMA", bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "APP_DB_SCHEMA") {
+			t.Fatalf("Load() with schema %q error = %v", bad, err)
+		}
+	}
+}
+
+func TestLoadAppliesFeaturesToFlagDefaults(t *testing.T) {
+	t.Setenv("APP_DB_DSN", "postgres://user:pass@db:5432/calcard?sslmode=disable")
+	t.Setenv("APP_OAUTH_CLIENT_ID", "client")
+	t.Setenv("APP_OAUTH_CLIENT_SECRET", "secret")
+	t.Setenv("APP_OAUTH_ISSUER_URL", "https://issuer.example")
+	t.Setenv("APP_SESSION_SECRET", strings.Repeat("s", 32))
+	t.Setenv("APP_DAV_PUSH_ENABLED", "true")
+	t.Setenv("APP_FEATURES", "caldav-repair, -push")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.FeatureEnabled(FeaturePush) || !cfg.FeatureEnabled(FeatureCalDAVRepair) || !cfg.FeatureEnabled(FeatureFocusTime) {
+		t.Fatalf("Features = %v", cfg.Features)
+	}
+	if !(&Config{CalDAVRepair: true}).FeatureEnabled(FeatureCalDAVRepair) {
+		t.Fatal("a Config not built by Load should fall back to APP_CALDAV_REPAIR")
+	}
+
+	t.Setenv("APP_FEATURES", "new-parser")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "new-parser") {
+		t.Fatalf("Load() with an unknown flag error = %v", err)
+	}
+}
+
 func TestLoadReturnsUsefulValidationErrors(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -120,6 +181,30 @@ func TestLoadReturnsUsefulValidationErrors(t *testing.T) {
 			},
 			wantErr: "must be at least 32 characters",
 		},
+		{
+			name: "probe token too short",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_PROBE_TOKEN":         "monitor",
+			},
+			wantErr: "APP_PROBE_TOKEN must be at least 32 characters",
+		},
+		{
+			name: "redis URL without redis scheme",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_REDIS_URL":           "cache.internal:6379",
+			},
+			wantErr: "APP_REDIS_URL must be a redis:// or rediss:// URL",
+		},
 		{
 			name: "invalid trusted proxy value",
 			env: map[string]string{
@@ -132,6 +217,131 @@ func TestLoadReturnsUsefulValidationErrors(t *testing.T) {
 			},
 			wantErr: "APP_TRUSTED_PROXIES contains invalid IP or CIDR",
 		},
+		{
+			name: "both backup targets",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_BACKUP_DIR":          "/backups",
+				"APP_BACKUP_S3_BUCKET":    "calcard",
+			},
+			wantErr: "set only one of APP_BACKUP_DIR and APP_BACKUP_S3_BUCKET",
+		},
+		{
+			name: "session lifetime shorter than idle timeout",
+			env: map[string]string{
+				"APP_DB_DSN":               "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":      "client",
+				"APP_OAUTH_CLIENT_SECRET":  "secret",
+				"APP_OAUTH_ISSUER_URL":     "https://issuer.example",
+				"APP_SESSION_SECRET":       strings.Repeat("s", 32),
+				"APP_SESSION_IDLE_TIMEOUT": "48h",
+				"APP_SESSION_MAX_LIFETIME": "24h",
+			},
+			wantErr: "APP_SESSION_MAX_LIFETIME must not be shorter than APP_SESSION_IDLE_TIMEOUT",
+		},
+		{
+			name: "s3 backup without credentials",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_BACKUP_S3_BUCKET":    "calcard",
+			},
+			wantErr: "APP_BACKUP_S3_ACCESS_KEY_ID and APP_BACKUP_S3_SECRET_ACCESS_KEY are required",
+		},
+		{
+			name: "smtp without sender",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_SMTP_HOST":           "smtp.example.com",
+			},
+			wantErr: "APP_SMTP_FROM is required with APP_SMTP_HOST",
+		},
+		{
+			name: "ldap password auth without username placeholder",
+			env: map[string]string{
+				"APP_DB_DSN":                     "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":            "client",
+				"APP_OAUTH_CLIENT_SECRET":        "secret",
+				"APP_OAUTH_ISSUER_URL":           "https://issuer.example",
+				"APP_SESSION_SECRET":             strings.Repeat("s", 32),
+				"APP_PASSWORD_AUTH_BACKEND":      "LDAP",
+				"APP_PASSWORD_AUTH_LDAP_URL":     "ldaps://directory.example.com",
+				"APP_PASSWORD_AUTH_LDAP_BIND_DN": "cn=admin,dc=example,dc=com",
+			},
+			wantErr: "APP_PASSWORD_AUTH_LDAP_BIND_DN must contain {username}",
+		},
+		{
+			name: "unknown password auth backend",
+			env: map[string]string{
+				"APP_DB_DSN":                "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":       "client",
+				"APP_OAUTH_CLIENT_SECRET":   "secret",
+				"APP_OAUTH_ISSUER_URL":      "https://issuer.example",
+				"APP_SESSION_SECRET":        strings.Repeat("s", 32),
+				"APP_PASSWORD_AUTH_BACKEND": "kerberos",
+			},
+			wantErr: "APP_PASSWORD_AUTH_BACKEND must be",
+		},
+		{
+			name: "invalid network acl entry",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_ACL_ADMIN_ALLOW":     "10.0.0.0/8, intranet",
+			},
+			wantErr: "APP_ACL_ADMIN_ALLOW contains invalid IP, CIDR or country code \"intranet\"",
+		},
+		{
+			name: "country acl without header",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_ACL_DAV_DENY":        "KP",
+			},
+			wantErr: "needs APP_ACL_COUNTRY_HEADER and APP_TRUSTED_PROXIES",
+		},
+		{
+			name: "both protocols disabled",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_CALDAV_ENABLED":      "false",
+				"APP_CARDDAV_ENABLED":     "false",
+			},
+			wantErr: "APP_CALDAV_ENABLED and APP_CARDDAV_ENABLED cannot both be false",
+		},
+		{
+			name: "unknown log privacy",
+			env: map[string]string{
+				"APP_DB_DSN":              "postgres://dsn",
+				"APP_OAUTH_CLIENT_ID":     "client",
+				"APP_OAUTH_CLIENT_SECRET": "secret",
+				"APP_OAUTH_ISSUER_URL":    "https://issuer.example",
+				"APP_SESSION_SECRET":      strings.Repeat("s", 32),
+				"APP_LOG_PRIVACY":         "partial",
+			},
+			wantErr: `APP_LOG_PRIVACY must be "off", "redacted" or "strict"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -142,6 +352,12 @@ func TestLoadReturnsUsefulValidationErrors(t *testing.T) {
 				"APP_OAUTH_CLIENT_ID", "APP_OAUTH_CLIENT_SECRET", "APP_OAUTH_ISSUER_URL",
 				"APP_OAUTH_DISCOVERY_URL", "APP_OAUTH_REDIRECT_PATH", "APP_SESSION_SECRET",
 				"APP_PROMETHEUS_ENDPOINT_ENABLED", "APP_TRUSTED_PROXIES",
+				"APP_BACKUP_DIR", "APP_BACKUP_S3_BUCKET", "APP_BACKUP_S3_ACCESS_KEY_ID", "APP_BACKUP_S3_SECRET_ACCESS_KEY",
+				"APP_SMTP_HOST", "APP_SMTP_FROM",
+				"APP_PASSWORD_AUTH_BACKEND", "APP_PASSWORD_AUTH_LDAP_URL", "APP_PASSWORD_AUTH_LDAP_BIND_DN",
+				"APP_ACL_ADMIN_ALLOW", "APP_ACL_DAV_DENY", "APP_ACL_COUNTRY_HEADER",
+				"APP_CALDAV_ENABLED", "APP_CARDDAV_ENABLED", "APP_LOG_PRIVACY",
+				"APP_REDIS_URL",
 			} {
 				t.Setenv(key, "")
 			}
@@ -179,3 +395,268 @@ func TestGetenvHelpers(t *testing.T) {
 		t.Fatalf("getenvList() = %#v, want nil", got)
 	}
 }
+
+func TestGetenvIntAndDuration(t *testing.T) {
+	t.Setenv("INT", " 12 ")
+	t.Setenv("INT_BAD", "-3")
+	t.Setenv("DURATION", "90s")
+	t.Setenv("DURATION_BAD", "soon")
+
+	if got := getenvInt("INT", 4); got != 12 {
+		t.Fatalf("getenvInt() = %d, want 12", got)
+	}
+	if got := getenvInt("INT_BAD", 4); got != 4 {
+		t.Fatalf("getenvInt() = %d, want default for negative value", got)
+	}
+	if got := getenvDuration("DURATION", time.Minute); got != 90*time.Second {
+		t.Fatalf("getenvDuration() = %v, want 90s", got)
+	}
+	if got := getenvDuration("DURATION_BAD", time.Minute); got != time.Minute {
+		t.Fatalf("getenvDuration() = %v, want default", got)
+	}
+}
+
+func TestLoadReadsConfigFileWithEnvOverride(t *testing.T) {
+	for _, format := range []struct {
+		name    string
+		content string
+	}{
+		{"calcard.yaml", `
+db:
+  dsn: postgres://file
+oauth:
+  client_id: client
+  client_secret: secret
+  issuer_url: https://issuer.example
+session:
+  secret: ` + strings.Repeat("s", 32) + `
+smtp:
+  host: smtp.example.com
+  port: 2525
+  from: calcard@example.com
+admin_emails: [a@example.com, b@example.com]
+dav:
+  auth_cache_ttl: 30s
+`},
+		{"calcard.toml", `
+admin_emails = ["a@example.com", "b@example.com"]
+
+[db]
+dsn = "postgres://file"
+
+[oauth]
+client_id = "client"
+client_secret = "secret"
+issuer_url = "https://issuer.example"
+
+[session]
+secret = "` + strings.Repeat("s", 32) + `"
+
+[smtp]
+host = "smtp.example.com"
+port = 2525
+from = "calcard@example.com"
+
+[dav]
+auth_cache_ttl = "30s"
+`},
+	} {
+		t.Run(format.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), format.name)
+			if err := os.WriteFile(path, []byte(format.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			for name := range settings {
+				t.Setenv(name, "")
+			}
+			t.Setenv("APP_CONFIG_FILE", path)
+			t.Setenv("APP_SMTP_PORT", "465")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.DB.DSN != "postgres://file" || cfg.SMTP.Host != "smtp.example.com" || cfg.DAV.AuthCacheTTL != 30*time.Second {
+				t.Fatalf("file settings not applied: %+v", cfg)
+			}
+			if cfg.SMTP.Port != 465 {
+				t.Fatalf("SMTP.Port = %d, want the environment to win", cfg.SMTP.Port)
+			}
+			if want := []string{"a@example.com", "b@example.com"}; !reflect.DeepEqual(cfg.AdminEmails, want) {
+				t.Fatalf("AdminEmails = %#v, want %#v", cfg.AdminEmails, want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadConfigFilesAndValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown key", file: "c.yaml", content: "smtp:\n  hots: mail\n", wantErr: `unknown setting "smtp.hots"`},
+		{name: "list for scalar", file: "c.yaml", content: "smtp:\n  host: [a, b]\n", wantErr: `setting "smtp.host": expected a single value`},
+		{name: "syntax error", file: "c.toml", content: "[smtp\n", wantErr: "c.toml"},
+		{name: "unsupported format", file: "c.json", content: "{}", wantErr: "unsupported extension"},
+		{name: "bad duration from file", file: "c.yaml", content: "backup:\n  interval: daily\n", wantErr: `APP_BACKUP_INTERVAL (from `},
+		{name: "bad int from env", env: map[string]string{"APP_SMTP_PORT": "smtp"}, wantErr: `APP_SMTP_PORT: "smtp" is not a whole number`},
+		{name: "bad bool from env", env: map[string]string{"APP_FSCK_REPAIR": "maybe"}, wantErr: `APP_FSCK_REPAIR: "maybe" is not a boolean`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name := range settings {
+				t.Setenv(name, "")
+			}
+			t.Setenv("APP_CONFIG_FILE", "")
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("APP_CONFIG_FILE", path)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettingsCoverEveryVariableLoadReads(t *testing.T) {
+	src, err := os.ReadFile("config.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range regexp.MustCompile(`"(APP_[A-Z0-9_]+)"`).FindAllStringSubmatch(string(src), -1) {
+		if _, ok := settings[name[1]]; !ok && name[1] != "APP_CONFIG_FILE" {
+			t.Errorf("%s is read by Load but missing from settings", name[1])
+		}
+	}
+}
+
+// reloadConfigFile clears the environment and points APP_CONFIG_FILE at a
+// file with the required settings, returning a func that rewrites it with
+// content added.
+func reloadConfigFile(t *testing.T) func(content string) {
+	t.Helper()
+	base := "db:\n  dsn: postgres://file\noauth:\n  client_id: client\n  client_secret: secret\n  issuer_url: https://issuer.example\nsession:\n  secret: " + strings.Repeat("s", 32) + "\n"
+	path := filepath.Join(t.TempDir(), "calcard.yaml")
+	for name := range settings {
+		t.Setenv(name, "")
+	}
+	t.Setenv("APP_CONFIG_FILE", path)
+	return func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(base+content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReloaderAppliesChangedSettings(t *testing.T) {
+	write := reloadConfigFile(t)
+	write("log_level: Debug\ndav:\n  report_concurrency: 2\nlisten_addr: \":8080\"\nbackup:\n  dir: /var/backups\n")
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	r := NewReloader()
+	var got []*Config
+	r.OnReload(func(cfg *Config) { got = append(got, cfg) })
+
+	write("log_level: Warn\ndav:\n  report_concurrency: 4\nlisten_addr: \":9090\"\n")
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"APP_DAV_REPORT_CONCURRENCY", "APP_LOG_LEVEL"}; !reflect.DeepEqual(result.Applied, want) {
+		t.Fatalf("Applied = %v, want %v", result.Applied, want)
+	}
+	if want := []string{"APP_BACKUP_DIR", "APP_LISTEN_ADDR"}; !reflect.DeepEqual(result.RestartRequired, want) {
+		t.Fatalf("RestartRequired = %v, want %v", result.RestartRequired, want)
+	}
+	if len(got) != 1 || got[0].LogLevel != "Warn" || got[0].DAV.ReportConcurrency != 4 || got[0].Backup.Dir != "" {
+		t.Fatalf("listener got %+v", got)
+	}
+
+	write("dav:\n  report_concurrency: many\n")
+	if _, err := r.Reload(); err == nil || !strings.Contains(err.Error(), "APP_DAV_REPORT_CONCURRENCY") {
+		t.Fatalf("Reload() error = %v, want the invalid setting rejected", err)
+	}
+	if len(got) != 1 || os.Getenv("APP_DAV_REPORT_CONCURRENCY") != "4" {
+		t.Fatal("an invalid configuration must not reach listeners or replace the running settings")
+	}
+}
+
+func TestReloaderAppliesRateLimits(t *testing.T) {
+	write := reloadConfigFile(t)
+	write("")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.RateLimits.AuthRate != 5 || cfg.RateLimits.AuthBurst != 10 || cfg.RateLimits.DAVRate != 20 || cfg.RateLimits.DAVBurst != 50 {
+		t.Fatalf("default RateLimits = %+v", cfg.RateLimits)
+	}
+
+	r := NewReloader()
+	var got []*Config
+	r.OnReload(func(cfg *Config) { got = append(got, cfg) })
+
+	write("rate_limit:\n  auth: 2\n  dav_burst: 80\n")
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"APP_RATE_LIMIT_AUTH", "APP_RATE_LIMIT_DAV_BURST"}; !reflect.DeepEqual(result.Applied, want) || len(result.RestartRequired) != 0 {
+		t.Fatalf("Applied = %v, RestartRequired = %v, want %v", result.Applied, result.RestartRequired, want)
+	}
+	if len(got) != 1 || got[0].RateLimits.AuthRate != 2 || got[0].RateLimits.DAVBurst != 80 || got[0].RateLimits.DAVRate != 20 {
+		t.Fatalf("listener got %+v", got)
+	}
+
+	write("rate_limit:\n  dav: 0\n")
+	if _, err := r.Reload(); err == nil || !strings.Contains(err.Error(), "APP_RATE_LIMIT_DAV") {
+		t.Fatalf("Reload() error = %v, want a zero rate rejected", err)
+	}
+}
+
+func TestReloaderAppliesFeatureFlags(t *testing.T) {
+	write := reloadConfigFile(t)
+	write("")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.FeatureEnabled(FeaturePush) || cfg.FeatureEnabled(FeatureCalDAVRepair) || !cfg.FeatureEnabled(FeatureFocusTime) {
+		t.Fatalf("default Features = %v", cfg.Features)
+	}
+
+	r := NewReloader()
+	var got []*Config
+	r.OnReload(func(cfg *Config) { got = append(got, cfg) })
+
+	write("dav:\n  push_enabled: true\ncaldav:\n  repair: true\nfeatures: -focus-time\n")
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if want := []string{"APP_CALDAV_REPAIR", "APP_DAV_PUSH_ENABLED", "APP_FEATURES"}; !reflect.DeepEqual(result.Applied, want) || len(result.RestartRequired) != 0 {
+		t.Fatalf("Applied = %v, RestartRequired = %v, want %v", result.Applied, result.RestartRequired, want)
+	}
+	if len(got) != 1 || !got[0].FeatureEnabled(FeaturePush) || !got[0].FeatureEnabled(FeatureCalDAVRepair) || got[0].FeatureEnabled(FeatureFocusTime) {
+		t.Fatalf("listener got %+v", got)
+	}
+
+	write("features: typo\n")
+	if _, err := r.Reload(); err == nil || !strings.Contains(err.Error(), "APP_FEATURES") {
+		t.Fatalf("Reload() error = %v, want an unknown flag rejected", err)
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// settingKind is how a setting's value is parsed.
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindInt
+	kindBool
+	kindDuration
+	kindList
+)
+
+// settings lists every environment variable Load reads with the kind of
+// value it holds. Config files may only set these.
+var settings = map[string]settingKind{
+	"APP_LISTEN_ADDR":                 kindString,
+	"APP_LISTEN_REUSEPORT":            kindBool,
+	"APP_SHUTDOWN_DRAIN_DELAY":        kindDuration,
+	"APP_SHUTDOWN_TIMEOUT":            kindDuration,
+	"APP_BASE_URL":                    kindString,
+	"APP_COMMUNITY_URL":               kindString,
+	"APP_DB_DSN":                      kindString,
+	"APP_DB_REPLICA_DSN":              kindString,
+	"APP_DB_SCHEMA":                   kindString,
+	"APP_FEATURES":                    kindString,
+	"APP_DB_HOST":                     kindString,
+	"APP_DB_NAME":                     kindString,
+	"APP_DB_USER":                     kindString,
+	"APP_DB_PASSWORD":                 kindString,
+	"APP_DB_PORT":                     kindString,
+	"APP_DB_SSLMODE":                  kindString,
+	"APP_REDIS_URL":                   kindString,
+	"APP_PREFLIGHT":                   kindBool,
+	"APP_OAUTH_CLIENT_ID":             kindString,
+	"APP_OAUTH_CLIENT_SECRET":         kindString,
+	"APP_OAUTH_ISSUER_URL":            kindString,
+	"APP_OAUTH_DISCOVERY_URL":         kindString,
+	"APP_OAUTH_REDIRECT_PATH":         kindString,
+	"APP_SESSION_SECRET":              kindString,
+	"APP_SESSION_IDLE_TIMEOUT":        kindDuration,
+	"APP_SESSION_MAX_LIFETIME":        kindDuration,
+	"APP_SESSION_BIND_IP":             kindBool,
+	"APP_SESSION_BIND_USER_AGENT":     kindBool,
+	"APP_SESSION_CLEANUP_INTERVAL":    kindDuration,
+	"APP_PROMETHEUS_ENDPOINT_ENABLED": kindBool,
+	"APP_PROBE_TOKEN":                 kindString,
+	"APP_TRUSTED_PROXIES":             kindList,
+	"APP_RATE_LIMIT_AUTH":             kindInt,
+	"APP_RATE_LIMIT_AUTH_BURST":       kindInt,
+	"APP_RATE_LIMIT_DAV":              kindInt,
+	"APP_RATE_LIMIT_DAV_BURST":        kindInt,
+	"APP_ALLOW_INSECURE_BASIC_AUTH":   kindBool,
+	"APP_DAV_REPORT_CONCURRENCY":      kindInt,
+	"APP_DAV_REPORT_QUEUE_WAIT":       kindDuration,
+	"APP_DAV_SYNC_PAGE_SIZE":          kindInt,
+	"APP_DAV_FULL_RESYNC_LIMIT":       kindInt,
+	"APP_DAV_FULL_RESYNC_WINDOW":      kindDuration,
+	"APP_DAV_MASS_DELETE_PERCENT":     kindInt,
+	"APP_DAV_MASS_DELETE_WINDOW":      kindDuration,
+	"APP_DAV_MASS_DELETE_MIN_ITEMS":   kindInt,
+	"APP_DAV_AUTH_CACHE_TTL":          kindDuration,
+	"APP_DAV_NOT_FOUND_CACHE_TTL":     kindDuration,
+	"APP_DAV_CLIENT_QUIRKS":           kindList,
+	"APP_DAV_STRICT_SYNC_TOKENS":      kindBool,
+	"APP_DAV_LOG_BODIES":              kindBool,
+	"APP_DAV_INTEROP_DIR":             kindString,
+	"APP_DAV_PUSH_ENABLED":            kindBool,
+	"APP_BLOB_DIR":                    kindString,
+	"APP_BLOB_S3_ENDPOINT":            kindString,
+	"APP_BLOB_S3_REGION":              kindString,
+	"APP_BLOB_S3_BUCKET":              kindString,
+	"APP_BLOB_S3_PREFIX":              kindString,
+	"APP_BLOB_S3_ACCESS_KEY_ID":       kindString,
+	"APP_BLOB_S3_SECRET_ACCESS_KEY":   kindString,
+	"APP_SCAN_CLAMD_ADDRESS":          kindString,
+	"APP_SCAN_COMMAND":                kindString,
+	"APP_SCAN_TIMEOUT":                kindDuration,
+	"APP_BACKUP_DIR":                  kindString,
+	"APP_BACKUP_S3_ENDPOINT":          kindString,
+	"APP_BACKUP_S3_REGION":            kindString,
+	"APP_BACKUP_S3_BUCKET":            kindString,
+	"APP_BACKUP_S3_PREFIX":            kindString,
+	"APP_BACKUP_S3_ACCESS_KEY_ID":     kindString,
+	"APP_BACKUP_S3_SECRET_ACCESS_KEY": kindString,
+	"APP_BACKUP_INTERVAL":             kindDuration,
+	"APP_BACKUP_RETENTION":            kindInt,
+	"APP_FSCK_INTERVAL":               kindDuration,
+	"APP_FSCK_REPAIR":                 kindBool,
+	"APP_TELEMETRY_ENABLED":           kindBool,
+	"APP_TELEMETRY_INTERVAL":          kindDuration,
+	"APP_CONTACT_VALIDATION":          kindString,
+	"APP_CALDAV_METHOD":               kindString,
+	"APP_CALDAV_REPAIR":               kindBool,
+	"APP_API_REQUIRE_SERVER_UIDS":     kindBool,
+	"APP_LDAP_ADDR":                   kindString,
+	"APP_LDAP_TLS_CERT":               kindString,
+	"APP_LDAP_TLS_KEY":                kindString,
+	"APP_LDAP_BASE_DN":                kindString,
+	"APP_ACTIVESYNC_ENABLED":          kindBool,
+	"APP_CALDAV_ENABLED":              kindBool,
+	"APP_CARDDAV_ENABLED":             kindBool,
+	"APP_ADMIN_EMAILS":                kindList,
+	"APP_BOOTSTRAP_FILE":              kindString,
+	"APP_TRAVEL_SPEED_KMH":            kindInt,
+	"APP_PASSWORD_AUTH_BACKEND":       kindString,
+	"APP_PASSWORD_AUTH_LDAP_URL":      kindString,
+	"APP_PASSWORD_AUTH_LDAP_BIND_DN":  kindString,
+	"APP_PASSWORD_AUTH_COMMAND":       kindString,
+	"APP_PASSWORD_AUTH_HTTP_URL":      kindString,
+	"APP_PASSWORD_AUTH_TIMEOUT":       kindDuration,
+	"APP_PASSWORD_AUTH_EMAIL_DOMAIN":  kindString,
+	"APP_PASSWORD_AUTH_PROVISION":     kindBool,
+	"APP_SIGNIN_LOCKOUT_THRESHOLD":    kindInt,
+	"APP_SIGNIN_LOCKOUT_WINDOW":       kindDuration,
+	"APP_SIGNIN_NOTIFY_NEW_ADDRESS":   kindBool,
+	"APP_ALARM_PRIVATE_PROVIDERS":     kindBool,
+	"APP_USER_QUOTA_MB":               kindInt,
+	"APP_ACL_ADMIN_ALLOW":             kindList,
+	"APP_ACL_ADMIN_DENY":              kindList,
+	"APP_ACL_API_ALLOW":               kindList,
+	"APP_ACL_API_DENY":                kindList,
+	"APP_ACL_DAV_ALLOW":               kindList,
+	"APP_ACL_DAV_DENY":                kindList,
+	"APP_ACL_UI_ALLOW":                kindList,
+	"APP_ACL_UI_DENY":                 kindList,
+	"APP_ACL_COUNTRY_HEADER":          kindString,
+	"APP_SMTP_HOST":                   kindString,
+	"APP_SMTP_PORT":                   kindInt,
+	"APP_SMTP_USERNAME":               kindString,
+	"APP_SMTP_PASSWORD":               kindString,
+	"APP_SMTP_FROM":                   kindString,
+	"APP_LOG_LEVEL":                   kindString,
+	"APP_LOG_PRIVACY":                 kindString,
+}
+
+// fileValues remembers the variables the config file set, so reading the
+// file again on reload replaces or removes them while variables from the real
+// environment still win.
+var (
+	fileValuesMu sync.Mutex
+	fileValues   = map[string]string{}
+)
+
+// applyConfigFile sets the environment variables for the settings in the
+// file at path that the environment leaves unset, returning the file each
+// applied variable came from. An empty path applies nothing.
+func applyConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileValuesMu.Lock()
+	defer fileValuesMu.Unlock()
+	previous := fileValues
+	fileValues = map[string]string{}
+	for name, old := range previous {
+		if _, ok := values[name]; !ok && os.Getenv(name) == old {
+			os.Unsetenv(name)
+		}
+	}
+	origin := map[string]string{}
+	for name, value := range values {
+		// A value the file did not set came from the real environment.
+		if current := os.Getenv(name); current != "" && current != previous[name] {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return nil, err
+		}
+		fileValues[name] = value
+		origin[name] = path
+	}
+	return origin, nil
+}
+
+// readConfigFile parses a YAML (.yaml, .yml) or TOML (.toml) config file
+// into environment variable names and values. Nested keys join with
+// underscores under the APP_ prefix, so smtp.host sets APP_SMTP_HOST, and
+// lists become comma-separated values.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q (use .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flattenSettings(tree, "", values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flattenSettings(tree map[string]any, prefix string, values map[string]string) error {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := tree[key].(map[string]any); ok {
+			if err := flattenSettings(nested, path, values); err != nil {
+				return err
+			}
+			continue
+		}
+		name := "APP_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+		kind, known := settings[name]
+		if !known {
+			return fmt.Errorf("unknown setting %q", path)
+		}
+		value, err := settingValue(tree[key], kind)
+		if err != nil {
+			return fmt.Errorf("setting %q: %w", path, err)
+		}
+		values[name] = value
+	}
+	return nil
+}
+
+// settingValue renders a parsed file value in the form the environment
+// variable takes.
+func settingValue(v any, kind settingKind) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		if kind != kindList {
+			return "", fmt.Errorf("expected a single value, not a list")
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := settingValue(item, kindString)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("expected a value, not a table")
+	case time.Duration:
+		return v.String(), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// validateSettings checks that every typed setting that is set parses, so a
+// typo fails at startup instead of silently falling back to the default.
+func validateSettings(origin map[string]string) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		raw := strings.TrimSpace(os.Getenv(name))
+		if raw == "" {
+			continue
+		}
+		var problem string
+		switch settings[name] {
+		case kindInt:
+			if n, err := strconv.Atoi(raw); err != nil || n < 0 {
+				problem = fmt.Sprintf("%q is not a whole number of zero or more", raw)
+			}
+		case kindBool:
+			switch strings.ToLower(raw) {
+			case "1", "true", "yes", "on", "0", "false", "no", "off":
+			default:
+				problem = fmt.Sprintf("%q is not a boolean (use true or false)", raw)
+			}
+		case kindDuration:
+			if d, err := time.ParseDuration(raw); err != nil || d < 0 {
+				problem = fmt.Sprintf("%q is not a duration (use a value like 90s, 15m or 24h)", raw)
+			}
+		}
+		if problem == "" {
+			continue
+		}
+		if source, ok := origin[name]; ok {
+			name += " (from " + source + ")"
+		}
+		problems = append(problems, name+": "+problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
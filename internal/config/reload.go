@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadable lists the settings a running server applies on reload. Every
+// other setting keeps its startup value until the server restarts.
+var reloadable = map[string]bool{
+	"APP_LOG_LEVEL":                 true,
+	"APP_BASE_URL":                  true,
+	"APP_COMMUNITY_URL":             true,
+	"APP_ADMIN_EMAILS":              true,
+	"APP_DAV_REPORT_CONCURRENCY":    true,
+	"APP_DAV_REPORT_QUEUE_WAIT":     true,
+	"APP_DAV_SYNC_PAGE_SIZE":        true,
+	"APP_DAV_FULL_RESYNC_LIMIT":     true,
+	"APP_DAV_FULL_RESYNC_WINDOW":    true,
+	"APP_DAV_MASS_DELETE_PERCENT":   true,
+	"APP_DAV_MASS_DELETE_WINDOW":    true,
+	"APP_DAV_MASS_DELETE_MIN_ITEMS": true,
+	"APP_DAV_AUTH_CACHE_TTL":        true,
+	"APP_DAV_NOT_FOUND_CACHE_TTL":   true,
+	"APP_DAV_CLIENT_QUIRKS":         true,
+	"APP_DAV_STRICT_SYNC_TOKENS":    true,
+	"APP_DAV_LOG_BODIES":            true,
+	"APP_LOG_PRIVACY":               true,
+	"APP_RATE_LIMIT_AUTH":           true,
+	"APP_RATE_LIMIT_AUTH_BURST":     true,
+	"APP_RATE_LIMIT_DAV":            true,
+	"APP_RATE_LIMIT_DAV_BURST":      true,
+	"APP_FEATURES":                  true,
+	"APP_DAV_PUSH_ENABLED":          true,
+	"APP_CALDAV_REPAIR":             true,
+}
+
+// ReloadResult describes one configuration reload.
+type ReloadResult struct {
+	ReloadedAt time.Time `json:"reloadedAt"`
+	// Applied lists the changed settings now in effect.
+	Applied []string `json:"applied"`
+	// RestartRequired lists changed settings that only take effect on restart.
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// Reloader re-reads the configuration while the server runs and hands it to
+// the components that can apply changes in place, so open DAV connections
+// are not dropped.
+type Reloader struct {
+	load func() (*Config, error)
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader returns a Reloader that reads the configuration with Load.
+func NewReloader() *Reloader {
+	return &Reloader{load: Load}
+}
+
+// OnReload registers fn to receive each successfully reloaded configuration.
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload reads the configuration again and applies it. An invalid
+// configuration is rejected and the running one is kept.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := settingValues()
+	fileValuesMu.Lock()
+	previousFile := fileValues
+	fileValuesMu.Unlock()
+	cfg, err := r.load()
+	if err != nil {
+		// Put back what the rejected file changed so the next reload starts
+		// from the configuration still running.
+		for name, value := range before {
+			os.Setenv(name, value)
+		}
+		fileValuesMu.Lock()
+		fileValues = previousFile
+		fileValuesMu.Unlock()
+		return nil, err
+	}
+	after := settingValues()
+
+	result := &ReloadResult{ReloadedAt: time.Now().UTC(), Applied: []string{}, RestartRequired: []string{}}
+	for name := range settings {
+		if before[name] == after[name] {
+			continue
+		}
+		if reloadable[name] {
+			result.Applied = append(result.Applied, name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+	sort.Strings(result.Applied)
+	sort.Strings(result.RestartRequired)
+
+	for _, fn := range r.listeners {
+		fn(cfg)
+	}
+	return result, nil
+}
+
+// WatchSignals reloads the configuration on every SIGHUP until ctx is
+// cancelled, passing each outcome to report.
+func (r *Reloader) WatchSignals(ctx context.Context, report func(*ReloadResult, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			report(r.Reload())
+		}
+	}
+}
+
+func settingValues() map[string]string {
+	values := make(map[string]string, len(settings))
+	for name := range settings {
+		values[name] = os.Getenv(name)
+	}
+	return values
+}
@@ -0,0 +1,156 @@
+package contacts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// MaxRecentChangeDays is how far back RecentChanges looks: as far as
+// superseded contact revisions are kept.
+const MaxRecentChangeDays = int(store.ContactHistoryRetention / (24 * time.Hour))
+
+// MaxRecentChanges caps the changes a single RecentChanges call returns.
+const MaxRecentChanges = 500
+
+// Kinds of ContactChange.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// ContactChange is a change to a contact, with the vCard properties it
+// touched and the email of the user who made it, when known.
+type ContactChange struct {
+	store.ContactChange
+	Kind           string
+	Fields         []string
+	ChangedByEmail string
+}
+
+// RecentChanges lists the changes made in the last days days to contacts in
+// every address book the user can read, newest first. Contacts a sharee is
+// denied are left out, as in ListContacts, and so are rewrites that changed
+// no property, such as a client re-saving a card.
+func (s *Service) RecentChanges(ctx context.Context, user *store.User, days int) ([]ContactChange, error) {
+	if days < 1 || days > MaxRecentChangeDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrBadRequest, MaxRecentChangeDays)
+	}
+	if s.store.ContactRevisions == nil {
+		return nil, fmt.Errorf("contact history not available")
+	}
+	books, err := s.ListAccessibleAddressBooks(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, 0, len(books))
+	shared := make(map[int64]bool, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+		shared[b.ID] = b.Shared
+	}
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	changes, err := s.store.ContactRevisions.ListChangedSince(ctx, ids, since, MaxRecentChanges)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := map[int64]string{}
+	result := make([]ContactChange, 0, len(changes))
+	for _, c := range changes {
+		if shared[c.AddressBookID] {
+			contact := store.Contact{AddressBookID: c.AddressBookID, UID: c.UID, ResourceName: c.ResourceName}
+			entriesByPath, err := s.prefetchACLEntries(ctx, user, c.AddressBookID, []store.Contact{contact})
+			if err != nil {
+				return nil, err
+			}
+			if !canReadContactFromEntries(user, c.AddressBookID, contactResourceName(contact), entriesByPath) {
+				continue
+			}
+		}
+		change := ContactChange{ContactChange: c}
+		switch {
+		case c.After == "":
+			change.Kind = ChangeDeleted
+		case c.Before == "":
+			change.Kind = ChangeCreated
+			change.Fields = ChangedFields("", c.After)
+		default:
+			change.Kind = ChangeUpdated
+			if change.Fields = ChangedFields(c.Before, c.After); len(change.Fields) == 0 {
+				continue
+			}
+		}
+		if c.ChangedBy != nil {
+			email, ok := emails[*c.ChangedBy]
+			if !ok {
+				changer, err := s.store.Users.GetByID(ctx, *c.ChangedBy)
+				if err != nil {
+					return nil, err
+				}
+				if changer != nil {
+					email = changer.PrimaryEmail
+				}
+				emails[*c.ChangedBy] = email
+			}
+			change.ChangedByEmail = email
+		}
+		result = append(result, change)
+	}
+	return result, nil
+}
+
+// ChangedFields returns the names of the vCard properties whose values
+// differ between two versions of a card, sorted. Properties that change on
+// every save, such as REV, are ignored.
+func ChangedFields(before, after string) []string {
+	old, updated := vCardProperties(before), vCardProperties(after)
+	var fields []string
+	for name, lines := range updated {
+		if !slices.Equal(lines, old[name]) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range old {
+		if _, ok := updated[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// vCardProperties returns each property's lines without their group, in a
+// stable order, keyed by upper-case property name.
+func vCardProperties(vcard string) map[string][]string {
+	props := map[string][]string{}
+	for _, line := range utils.UnfoldLines(vcard) {
+		idx := strings.IndexAny(line, ":;")
+		if idx < 0 {
+			continue
+		}
+		name := line[:idx]
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name, line = name[dot+1:], line[dot+1:]
+		}
+		name = strings.ToUpper(name)
+		switch name {
+		case "BEGIN", "END", "VERSION", "PRODID", "REV", "UID":
+			continue
+		}
+		props[name] = append(props[name], line)
+	}
+	for _, lines := range props {
+		slices.Sort(lines)
+	}
+	return props
+}
@@ -0,0 +1,63 @@
+package contacts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/util"
+)
+
+// MaxLookupResults caps the contacts a single lookup returns.
+const MaxLookupResults = 50
+
+// LookupContacts finds the contacts in every address book the user can read
+// that have the given email address or phone number. Exactly one of email and
+// phone must be set; both are normalized the way contacts are indexed, so
+// "+1 (415) 555-0100" finds a contact stored with "+14155550100".
+func (s *Service) LookupContacts(ctx context.Context, user *store.User, email, phone string) ([]store.Contact, error) {
+	if (email == "") == (phone == "") {
+		return nil, fmt.Errorf("%w: exactly one of email or phone is required", ErrBadRequest)
+	}
+	var emailKey, phoneKey string
+	if email != "" {
+		if emailKey = util.EmailLookupKey(email); emailKey == "" {
+			return nil, fmt.Errorf("%w: invalid email", ErrBadRequest)
+		}
+	} else if phoneKey = util.PhoneLookupKey(phone); phoneKey == "" {
+		return nil, fmt.Errorf("%w: invalid phone", ErrBadRequest)
+	}
+
+	books, err := s.ListAccessibleAddressBooks(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(books))
+	owned := make(map[int64]bool, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+		owned[b.ID] = !b.Shared
+	}
+	matches, err := s.store.Contacts.FindByLookupKey(ctx, ids, emailKey, phoneKey, MaxLookupResults)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sharees may be denied individual contacts, so matches in shared books
+	// are checked against their ACL entries like ListContacts does.
+	visible := make([]store.Contact, 0, len(matches))
+	for _, c := range matches {
+		if owned[c.AddressBookID] {
+			visible = append(visible, c)
+			continue
+		}
+		entriesByPath, err := s.prefetchACLEntries(ctx, user, c.AddressBookID, []store.Contact{c})
+		if err != nil {
+			return nil, err
+		}
+		if canReadContactFromEntries(user, c.AddressBookID, contactResourceName(c), entriesByPath) {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
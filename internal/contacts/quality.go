@@ -0,0 +1,251 @@
+package contacts
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// Issue severities. Errors are values that cannot be what the property
+// claims; strict validation rejects them. Warnings are usable values with a
+// suggested normalized form.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is one problem found in a structured contact field.
+type Issue struct {
+	Property string `json:"property"`
+	Value    string `json:"value"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Suggestion is the normalized value, when one can be derived.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ValidationError rejects a contact write under strict validation. It lists
+// every error-severity issue in the card.
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		parts = append(parts, fmt.Sprintf("%s %q: %s", issue.Property, issue.Value, issue.Message))
+	}
+	return "invalid contact data: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrBadRequest
+}
+
+// ContactIssues pairs a contact with the issues found in it.
+type ContactIssues struct {
+	Contact store.Contact
+	Issues  []Issue
+}
+
+// CheckVCard validates the TEL, EMAIL and URL properties of a vCard.
+func CheckVCard(raw string) []Issue {
+	var issues []Issue
+	for _, line := range utils.UnfoldLines(raw) {
+		name, value, ok := splitVCardLine(line)
+		if !ok || value == "" {
+			continue
+		}
+		var issue *Issue
+		switch name {
+		case "TEL":
+			issue = checkPhone(value)
+		case "EMAIL":
+			issue = checkEmail(value)
+		case "URL":
+			issue = checkURL(value)
+		}
+		if issue != nil {
+			issue.Property = name
+			issue.Value = value
+			issues = append(issues, *issue)
+		}
+	}
+	return issues
+}
+
+// ValidateStrict returns a *ValidationError when raw has error-severity
+// issues.
+func ValidateStrict(raw string) error {
+	var errs []Issue
+	for _, issue := range CheckVCard(raw) {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: errs}
+}
+
+// ContactQuality checks every contact in an address book the user can read
+// and returns those with issues.
+func (s *Service) ContactQuality(ctx context.Context, user *store.User, bookID int64) ([]ContactIssues, int, error) {
+	items, err := s.ListContacts(ctx, user, bookID, store.ContactFilter{})
+	if err != nil {
+		return nil, 0, err
+	}
+	var report []ContactIssues
+	for _, c := range items {
+		if issues := CheckVCard(c.RawVCard); len(issues) > 0 {
+			report = append(report, ContactIssues{Contact: c, Issues: issues})
+		}
+	}
+	return report, len(items), nil
+}
+
+// splitVCardLine returns the upper-cased property name, without any group,
+// and the unescaped value of an unfolded vCard line.
+func splitVCardLine(line string) (string, string, bool) {
+	colon := -1
+	inQuotes := false
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", "", false
+	}
+	name, _, _ := strings.Cut(line[:colon], ";")
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	// Google and Apple exports escape the colon in URLs too.
+	value := strings.NewReplacer(`\,`, ",", `\;`, ";", `\:`, ":", `\\`, `\`).Replace(strings.TrimSpace(line[colon+1:]))
+	return strings.ToUpper(strings.TrimSpace(name)), value, true
+}
+
+// checkPhone checks a TEL value the way libphonenumber parses numbers without
+// a default region: punctuation is ignored, international numbers are
+// normalized to E.164, and national numbers are only checked for length.
+func checkPhone(value string) *Issue {
+	number, ok := normalizePhone(value)
+	if !ok {
+		return &Issue{Severity: SeverityError, Message: number}
+	}
+	if strings.HasPrefix(number, "+") && number != value {
+		return &Issue{Severity: SeverityWarning, Message: "not in E.164 form", Suggestion: number}
+	}
+	return nil
+}
+
+// normalizePhone returns the normalized number, or the reason value is not
+// a phone number and false.
+func normalizePhone(value string) (string, bool) {
+	s := strings.TrimSpace(value)
+	if len(s) >= 4 && strings.EqualFold(s[:4], "tel:") {
+		s = s[4:]
+	}
+	ext := ""
+	lower := strings.ToLower(s)
+	for _, marker := range []string{";ext=", " ext.", " ext", " x"} {
+		if idx := strings.Index(lower, marker); idx >= 0 {
+			ext = strings.TrimSpace(s[idx+len(marker):])
+			s = s[:idx]
+			break
+		}
+	}
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international, s = true, s[1:]
+	case strings.HasPrefix(s, "00"):
+		international, s = true, s[2:]
+	}
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" -.()/ ", r):
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			return "contains letters", false
+		default:
+			return "contains characters that are not part of a phone number", false
+		}
+	}
+	for _, r := range ext {
+		if r < '0' || r > '9' {
+			return "extension must be digits", false
+		}
+	}
+	n := digits.String()
+	switch {
+	case len(n) < 3:
+		return "too short to be a phone number", false
+	case len(n) > 15:
+		return "longer than the 15 digits a phone number may have", false
+	case international && n[0] == '0':
+		return "country code cannot start with 0", false
+	case international && len(n) < 7:
+		return "too short for an international number", false
+	}
+	if international {
+		n = "+" + n
+	}
+	if ext != "" {
+		n += ";ext=" + ext
+	}
+	return n, true
+}
+
+func checkEmail(value string) *Issue {
+	candidate := value
+	if len(candidate) > 7 && strings.EqualFold(candidate[:7], "mailto:") {
+		candidate = candidate[7:]
+	}
+	addr, err := mail.ParseAddress(candidate)
+	if err != nil || addr.Name != "" || addr.Address != candidate {
+		return &Issue{Severity: SeverityError, Message: "not a valid email address"}
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	local, domain := addr.Address[:at], addr.Address[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return &Issue{Severity: SeverityError, Message: "domain is not fully qualified"}
+	}
+	if normalized := local + "@" + strings.ToLower(domain); normalized != value {
+		return &Issue{Severity: SeverityWarning, Message: "not in normalized form", Suggestion: normalized}
+	}
+	return nil
+}
+
+func checkURL(value string) *Issue {
+	u, err := url.Parse(value)
+	if err != nil || strings.ContainsAny(value, " \t") {
+		return &Issue{Severity: SeverityError, Message: "not a valid URL"}
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return &Issue{Severity: SeverityError, Message: "URL has no host"}
+		}
+		return nil
+	case "":
+		if host, _, _ := strings.Cut(value, "/"); strings.Contains(host, ".") {
+			return &Issue{Severity: SeverityWarning, Message: "URL has no scheme", Suggestion: "https://" + value}
+		}
+		return &Issue{Severity: SeverityError, Message: "not a valid URL"}
+	default:
+		return &Issue{Severity: SeverityError, Message: fmt.Sprintf("scheme %q is not allowed; use http or https", u.Scheme)}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/jw6ventures/calcard/internal/maintenance"
 	"github.com/jw6ventures/calcard/internal/store"
 	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
@@ -32,6 +33,9 @@ var (
 // Service exposes address book and contact operations for API callers.
 type Service struct {
 	store *store.Store
+	// strict rejects writes whose structured fields have errors instead of
+	// leaving them for the quality report.
+	strict bool
 }
 
 // NewService builds a contacts Service backed by the given store.
@@ -39,6 +43,12 @@ func NewService(st *store.Store) *Service {
 	return &Service{store: st}
 }
 
+// SetStrictValidation makes contact writes fail with a *ValidationError when
+// a TEL, EMAIL or URL value is invalid.
+func (s *Service) SetStrictValidation(strict bool) {
+	s.strict = strict
+}
+
 // StructuredInput is the JSON form of a contact, assembled into a vCard.
 type StructuredInput struct {
 	UID         string `json:"uid"`
@@ -170,6 +180,9 @@ func (s *Service) CreateContact(ctx context.Context, user *store.User, bookID in
 	if err != nil {
 		return nil, false, err
 	}
+	if err := s.checkStrict(body); err != nil {
+		return nil, false, err
+	}
 	existing, err := s.store.Contacts.GetByUID(ctx, bookID, uid)
 	if err != nil {
 		return nil, false, err
@@ -208,6 +221,9 @@ func (s *Service) UpdateContact(ctx context.Context, user *store.User, bookID in
 	if normalizedUID != uid {
 		return nil, false, fmt.Errorf("%w: uid mismatch", ErrBadRequest)
 	}
+	if err := s.checkStrict(body); err != nil {
+		return nil, false, err
+	}
 	resourceName := existing.ResourceName
 	if resourceName == "" {
 		resourceName = uid
@@ -234,6 +250,9 @@ func (s *Service) DeleteContact(ctx context.Context, user *store.User, bookID in
 	if existing == nil {
 		return ErrNotFound
 	}
+	if err := maintenance.Check(ctx, s.store, maintenance.AddressBook, bookID); err != nil {
+		return err
+	}
 	return s.store.Contacts.DeleteByUID(ctx, bookID, uid)
 }
 
@@ -345,6 +364,9 @@ func addressBookIDFromCollectionPath(resourcePath string) (int64, bool) {
 }
 
 func (s *Service) saveContact(ctx context.Context, bookID int64, uid, resourceName, body, ifMatch, ifNoneMatch string) (*store.Contact, bool, error) {
+	if err := maintenance.Check(ctx, s.store, maintenance.AddressBook, bookID); err != nil {
+		return nil, false, err
+	}
 	existingByResource, err := s.store.Contacts.GetByResourceName(ctx, bookID, resourceName)
 	if err != nil {
 		return nil, false, err
@@ -439,6 +461,15 @@ func buildStructuredContact(input *StructuredInput, expectedUID string) (string,
 	return body, uid, nil
 }
 
+// ValidateStored re-checks a stored vCard against the rules enforced when it
+// is written and returns its UID, which is empty when the card has none.
+func ValidateStored(raw string) (string, error) {
+	if err := validateVCard(raw); err != nil {
+		return "", err
+	}
+	return utils.ExtractVCardUID(raw), nil
+}
+
 func validateVCard(body string) error {
 	upper := strings.ToUpper(body)
 	if !strings.HasPrefix(upper, "BEGIN:VCARD") || !strings.Contains(upper, "END:VCARD") {
@@ -483,11 +514,20 @@ func checkConditionalHeaders(ifMatch, ifNoneMatch string, existing *store.Contac
 	return true
 }
 
+func (s *Service) checkStrict(body string) error {
+	if !s.strict {
+		return nil
+	}
+	return ValidateStrict(body)
+}
+
 // StatusCode maps a service error to the HTTP status the API should return.
 func StatusCode(err error) int {
 	switch {
 	case err == nil:
 		return http.StatusOK
+	case errors.As(err, new(*ValidationError)):
+		return http.StatusUnprocessableEntity
 	case errors.Is(err, ErrNotFound):
 		return http.StatusNotFound
 	case errors.Is(err, ErrForbidden):
@@ -498,6 +538,8 @@ func StatusCode(err error) int {
 		return http.StatusPreconditionFailed
 	case errors.Is(err, ErrBadRequest):
 		return http.StatusBadRequest
+	case errors.Is(err, maintenance.ErrActive):
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
 	}
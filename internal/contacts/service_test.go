@@ -3,12 +3,14 @@ package contacts
 import (
 	"context"
 	"errors"
+	"net/http"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/util"
 )
 
 // --- in-memory fakes -------------------------------------------------------
@@ -109,6 +111,25 @@ func (f *fakeContacts) CopyToAddressBook(context.Context, int64, int64, string,
 	return nil, nil
 }
 
+func (f *fakeContacts) FindByLookupKey(_ context.Context, bookIDs []int64, email, phone string, _ int) ([]store.Contact, error) {
+	var out []store.Contact
+	for _, id := range bookIDs {
+		for _, c := range f.items {
+			if c.AddressBookID != id {
+				continue
+			}
+			for _, line := range strings.Split(c.RawVCard, "\r\n") {
+				name, value, _ := strings.Cut(line, ":")
+				if (name == "EMAIL" && email != "" && util.EmailLookupKey(value) == email) || (name == "TEL" && phone != "" && util.PhoneLookupKey(value) == phone) {
+					out = append(out, c)
+					break
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
 type fakeACL struct{ entries []store.ACLEntry }
 
 func (f *fakeACL) SetACL(_ context.Context, resourcePath string, entries []store.ACLEntry) error {
@@ -177,7 +198,27 @@ func (f *fakeUsers) GetByID(_ context.Context, id int64) (*store.User, error) {
 }
 func (f *fakeUsers) GetByEmail(context.Context, string) (*store.User, error) { return nil, nil }
 func (f *fakeUsers) ListActive(context.Context) ([]store.User, error)        { return nil, nil }
+func (f *fakeUsers) MarkInternal(context.Context, int64) error               { return nil }
 func (f *fakeUsers) MarkOnboardingComplete(context.Context, int64) error     { return nil }
+func (f *fakeUsers) SetSyncHideCancelled(context.Context, int64, bool) error { return nil }
+func (f *fakeUsers) SetPreferences(context.Context, int64, string, string, *time.Weekday) error {
+	return nil
+}
+
+type fakeRevisions struct{ changes []store.ContactChange }
+
+func (f *fakeRevisions) ListChangedSince(_ context.Context, ids []int64, since time.Time, limit int) ([]store.ContactChange, error) {
+	var out []store.ContactChange
+	for _, c := range f.changes {
+		for _, id := range ids {
+			if c.AddressBookID == id && !c.ChangedAt.Before(since) && len(out) < limit {
+				out = append(out, c)
+			}
+		}
+	}
+	return out, nil
+}
+func (f *fakeRevisions) DeleteClosedBefore(context.Context, time.Time) (int64, error) { return 0, nil }
 
 // --- helpers ---------------------------------------------------------------
 
@@ -363,3 +404,162 @@ func TestListAccessibleIncludesSharedBook(t *testing.T) {
 		t.Fatalf("sharee accessible=%+v, want shared editor book 1", books)
 	}
 }
+
+func TestCheckVCardClassifiesStructuredFields(t *testing.T) {
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:q1\r\nFN:Quality\r\n" +
+		"TEL;TYPE=CELL:+1 (415) 555-0100\r\n" +
+		"TEL:+14155550101\r\n" +
+		"item1.TEL:call me\r\n" +
+		"EMAIL:Ann@Example.COM\r\n" +
+		"EMAIL:not-an-address\r\n" +
+		"URL:example.com/ann\r\n" +
+		"URL:javascript:alert(1)\r\n" +
+		"item2.URL:https\\://ann.example.com\r\n" +
+		"END:VCARD\r\n"
+	issues := CheckVCard(card)
+	want := []struct{ property, value, severity, suggestion string }{
+		{"TEL", "+1 (415) 555-0100", SeverityWarning, "+14155550100"},
+		{"TEL", "call me", SeverityError, ""},
+		{"EMAIL", "Ann@Example.COM", SeverityWarning, "Ann@example.com"},
+		{"EMAIL", "not-an-address", SeverityError, ""},
+		{"URL", "example.com/ann", SeverityWarning, "https://example.com/ann"},
+		{"URL", "javascript:alert(1)", SeverityError, ""},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %+v, want %d", issues, len(want))
+	}
+	for i, w := range want {
+		got := issues[i]
+		if got.Property != w.property || got.Value != w.value || got.Severity != w.severity || got.Suggestion != w.suggestion {
+			t.Errorf("issue %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestStrictValidationRejectsInvalidFields(t *testing.T) {
+	svc, _ := newTestService()
+	bad := UpsertInput{Structured: &StructuredInput{UID: "s1", DisplayName: "Strict", Email: "nobody", Phone: "12"}}
+
+	if _, _, err := svc.CreateContact(context.Background(), owner, 1, bad); err != nil {
+		t.Fatalf("lenient create err = %v", err)
+	}
+	report, checked, err := svc.ContactQuality(context.Background(), owner, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 2 || len(report) != 1 || report[0].Contact.UID != "s1" || len(report[0].Issues) != 2 {
+		t.Fatalf("report = %+v checked=%d, want s1 with two issues", report, checked)
+	}
+
+	svc.SetStrictValidation(true)
+	bad.Structured.UID = "s2"
+	_, _, err = svc.CreateContact(context.Background(), owner, 1, bad)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Issues) != 2 {
+		t.Fatalf("strict create err = %v, want ValidationError with two issues", err)
+	}
+	if StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Fatalf("StatusCode = %d, want 422", StatusCode(err))
+	}
+	if _, _, err := svc.CreateContact(context.Background(), owner, 1, uvCard("s3")); err != nil {
+		t.Fatalf("strict create of valid contact err = %v", err)
+	}
+}
+
+func TestLookupContactsSearchesAccessibleBooks(t *testing.T) {
+	svc, _ := newTestService()
+	fc := svc.store.Contacts.(*fakeContacts)
+	fc.items["1:tel"] = store.Contact{AddressBookID: 1, UID: "tel", ResourceName: "tel", RawVCard: "BEGIN:VCARD\r\nUID:tel\r\nFN:Bob\r\nTEL:+1 415 555 0100\r\nEMAIL:Bob@Example.com\r\nEND:VCARD\r\n"}
+
+	for _, tc := range []struct{ email, phone string }{
+		{phone: "+14155550100"},
+		{phone: "0014155550100"},
+		{email: "bob@example.COM"},
+	} {
+		got, err := svc.LookupContacts(context.Background(), owner, tc.email, tc.phone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].UID != "tel" {
+			t.Fatalf("lookup %+v = %+v, want tel", tc, got)
+		}
+	}
+
+	got, err := svc.LookupContacts(context.Background(), stranger, "", "+14155550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("stranger lookup = %+v, want none", got)
+	}
+	if err := svc.ShareAddressBook(context.Background(), owner, 1, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = svc.LookupContacts(context.Background(), sharee, "", "+14155550100"); err != nil || len(got) != 1 {
+		t.Fatalf("sharee lookup = %+v, %v; want one match", got, err)
+	}
+
+	if _, err := svc.LookupContacts(context.Background(), owner, "a@example.com", "+14155550100"); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("lookup with both err = %v, want ErrBadRequest", err)
+	}
+	if _, err := svc.LookupContacts(context.Background(), owner, "", "12"); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("lookup with short phone err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestRecentChangesClassifiesChanges(t *testing.T) {
+	svc, _ := newTestService()
+	ownerID := int64(1)
+	now := time.Now()
+	card := func(lines ...string) string {
+		return "BEGIN:VCARD\r\nVERSION:4.0\r\nUID:c1\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCARD\r\n"
+	}
+	svc.store.ContactRevisions = &fakeRevisions{changes: []store.ContactChange{
+		{AddressBookID: 1, UID: "c1", Before: card("FN:Alice", "EMAIL:a@example.com"), After: card("FN:Alice", "EMAIL:alice@example.com", "TEL:123"), ChangedBy: &ownerID, ChangedAt: now.Add(-time.Hour)},
+		{AddressBookID: 1, UID: "c1", Before: card("FN:Alice", "REV:1"), After: card("FN:Alice", "REV:2"), ChangedAt: now.Add(-2 * time.Hour)},
+		{AddressBookID: 1, UID: "c2", After: card("FN:Bob"), ChangedAt: now.Add(-3 * time.Hour)},
+		{AddressBookID: 1, UID: "c3", Before: card("FN:Carol"), ChangedBy: &ownerID, ChangedAt: now.Add(-4 * time.Hour)},
+		{AddressBookID: 1, UID: "old", After: card("FN:Old"), ChangedAt: now.Add(-10 * 24 * time.Hour)},
+	}}
+
+	got, err := svc.RecentChanges(context.Background(), owner, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("RecentChanges = %+v, want three changes", got)
+	}
+	if got[0].Kind != ChangeUpdated || strings.Join(got[0].Fields, ",") != "EMAIL,TEL" || got[0].ChangedByEmail != "owner@example.com" {
+		t.Fatalf("update = %+v", got[0])
+	}
+	if got[1].Kind != ChangeCreated || got[1].UID != "c2" || strings.Join(got[1].Fields, ",") != "FN" {
+		t.Fatalf("create = %+v", got[1])
+	}
+	if got[2].Kind != ChangeDeleted || got[2].UID != "c3" {
+		t.Fatalf("delete = %+v", got[2])
+	}
+
+	if got, err := svc.RecentChanges(context.Background(), stranger, 7); err != nil || len(got) != 0 {
+		t.Fatalf("stranger changes = %+v, %v; want none", got, err)
+	}
+	if err := svc.ShareAddressBook(context.Background(), owner, 1, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := svc.RecentChanges(context.Background(), sharee, 7); err != nil || len(got) != 3 {
+		t.Fatalf("sharee changes = %+v, %v; want three", got, err)
+	}
+
+	for _, days := range []int{0, MaxRecentChangeDays + 1} {
+		if _, err := svc.RecentChanges(context.Background(), owner, days); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("RecentChanges(%d days) err = %v, want ErrBadRequest", days, err)
+		}
+	}
+}
+
+func TestChangedFieldsIgnoresGroupsAndOrder(t *testing.T) {
+	before := "BEGIN:VCARD\r\nUID:a\r\nitem1.EMAIL:a@example.com\r\nEMAIL:b@example.com\r\nN:Doe;Jane;;;\r\nEND:VCARD\r\n"
+	after := "BEGIN:VCARD\r\nUID:a\r\nREV:20260101T000000Z\r\nEMAIL:b@example.com\r\nitem2.EMAIL:a@example.com\r\nNOTE:new\r\nEND:VCARD\r\n"
+	if got := ChangedFields(before, after); strings.Join(got, ",") != "N,NOTE" {
+		t.Fatalf("ChangedFields = %v, want [N NOTE]", got)
+	}
+}
@@ -105,20 +105,15 @@ func contactResourceName(c store.Contact) string {
 }
 
 func applicableACLPrincipals(user *store.User) map[string]struct{} {
-	principals := map[string]struct{}{"DAV:all": {}}
-	if user != nil {
-		principals[sharePrincipalHref(user.ID)] = struct{}{}
-		principals["DAV:authenticated"] = struct{}{}
+	principals := map[string]struct{}{}
+	for _, href := range store.ACLPrincipals(user) {
+		principals[href] = struct{}{}
 	}
 	return principals
 }
 
 func aclPrincipalHrefs(user *store.User) []string {
-	principals := []string{"DAV:all"}
-	if user != nil {
-		principals = append(principals, "DAV:authenticated", sharePrincipalHref(user.ID))
-	}
-	return principals
+	return store.ACLPrincipals(user)
 }
 
 func normalizeACLPrincipalHref(raw string) string {
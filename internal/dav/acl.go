@@ -22,7 +22,7 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Acl", "ACL %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -33,48 +33,48 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 	canonicalPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 	if err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) || errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous path")
 			return
 		}
-		http.Error(w, "failed to resolve path", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve path")
 		return
 	}
 
 	// Verify user has write-acl privilege
 	allowed, err := h.checkACLPrivilege(r.Context(), user, canonicalPath, "write-acl")
 	if err != nil {
-		http.Error(w, "failed to evaluate ACL", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to evaluate ACL")
 		return
 	}
 	if !allowed {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
 		return
 	}
 
 	body, err := readDAVBody(w, r, maxDAVBodyBytes)
 	if err != nil {
 		if errors.Is(err, errRequestTooLarge) {
-			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 		} else {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
 		}
 		return
 	}
 
 	var req aclRequest
 	if err := safeUnmarshalXML(body, &req); err != nil {
-		http.Error(w, "invalid ACL request", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid ACL request")
 		return
 	}
 
 	var entries []store.ACLEntry
 	for _, a := range req.ACE {
 		if err := validateACE(a); err != nil {
-			http.Error(w, "invalid ACL request", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid ACL request")
 			return
 		}
 		principalHref := normalizeACLPrincipalHref(a.Principal.Href)
@@ -89,7 +89,7 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 					principalHref = "DAV:authenticated"
 				}
 			} else {
-				http.Error(w, "invalid principal in ACE", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "invalid principal in ACE", condRecognizedPrincipal)
 				return
 			}
 		}
@@ -97,7 +97,7 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 		if a.Grant != nil {
 			for _, priv := range a.Grant.Privileges {
 				if err := validateACEPrivilege(priv); err != nil {
-					http.Error(w, "invalid privilege in ACE", http.StatusBadRequest)
+					writeDAVError(w, http.StatusBadRequest, "invalid privilege in ACE", condNotSupportedPrivilege)
 					return
 				}
 				for _, name := range extractACEPrivilegeNames(priv) {
@@ -113,7 +113,7 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 		if a.Deny != nil {
 			for _, priv := range a.Deny.Privileges {
 				if err := validateACEPrivilege(priv); err != nil {
-					http.Error(w, "invalid privilege in ACE", http.StatusBadRequest)
+					writeDAVError(w, http.StatusBadRequest, "invalid privilege in ACE", condNotSupportedPrivilege)
 					return
 				}
 				for _, name := range extractACEPrivilegeNames(priv) {
@@ -129,7 +129,7 @@ func (h *Handler) Acl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.store.ACLEntries.SetACL(r.Context(), canonicalPath, entries); err != nil {
-		http.Error(w, "failed to set ACL", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to set ACL")
 		return
 	}
 
@@ -359,10 +359,9 @@ func aclAggregateWriteDecision(entries []store.ACLEntry, applicablePrincipals ma
 }
 
 func applicableACLPrincipals(user *store.User) map[string]struct{} {
-	principals := map[string]struct{}{"DAV:all": {}}
-	if user != nil {
-		principals[fmt.Sprintf("/dav/principals/%d/", user.ID)] = struct{}{}
-		principals["DAV:authenticated"] = struct{}{}
+	principals := map[string]struct{}{}
+	for _, href := range store.ACLPrincipals(user) {
+		principals[href] = struct{}{}
 	}
 	return principals
 }
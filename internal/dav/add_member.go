@@ -0,0 +1,90 @@
+package dav
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// addMemberQuery is the query parameter of a collection's DAV:add-member URL
+// (RFC 5995). A POST to it creates the body as a new member of the
+// collection under a name the server picks.
+const addMemberQuery = "add-member"
+
+var propAddMember = xml.Name{Space: "DAV:", Local: "add-member"}
+
+// addMemberHref returns the DAV:add-member URL of a collection.
+func addMemberHref(collectionPath string) string {
+	return strings.TrimSuffix(collectionPath, "/") + "/?" + addMemberQuery
+}
+
+// isAddMemberRequest reports whether r is a POST to an add-member URL.
+func isAddMemberRequest(r *http.Request) bool {
+	_, ok := r.URL.Query()[addMemberQuery]
+	return ok
+}
+
+// addMemberExtension returns the file extension of members of the calendar
+// or address book collection at cleanPath.
+func addMemberExtension(cleanPath string) (string, bool) {
+	if singleCollectionSegment(cleanPath, "/dav/calendars/") != "" {
+		return ".ics", true
+	}
+	if singleCollectionSegment(cleanPath, "/dav/addressbooks/") != "" {
+		return ".vcf", true
+	}
+	return "", false
+}
+
+// addMember stores the POSTed resource under a new random name by running it
+// through Put as a create-only request, so it gets the same validation,
+// privilege checks and UID conflict detection. A successful response
+// carries the new member's URL in Location.
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request) {
+	cleanPath := path.Clean(r.URL.Path)
+	ext, ok := addMemberExtension(cleanPath)
+	if !ok {
+		writeDAVError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to name resource")
+		return
+	}
+	memberPath := cleanPath + "/" + hex.EncodeToString(id[:]) + ext
+
+	put := r.Clone(r.Context())
+	put.Method = http.MethodPut
+	put.URL.Path = memberPath
+	put.URL.RawPath = ""
+	put.URL.RawQuery = ""
+	put.RequestURI = memberPath
+	put.Header.Del("If-Match")
+	put.Header.Set("If-None-Match", "*")
+	h.logger().Trace("addMember", "adding %s to %s", memberPath, cleanPath)
+	h.Put(&addMemberWriter{ResponseWriter: w, location: memberPath}, put)
+}
+
+// addMemberWriter sets Location when the member is created.
+type addMemberWriter struct {
+	http.ResponseWriter
+	location    string
+	wroteHeader bool
+}
+
+func (w *addMemberWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status == http.StatusCreated {
+		w.Header().Set("Location", w.location)
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *addMemberWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
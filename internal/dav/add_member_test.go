@@ -0,0 +1,88 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestPostAddMemberCreatesEventUnderServerName(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+	ctx := auth.WithUser(t.Context(), &store.User{ID: 1})
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:added\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := httptest.NewRequest(http.MethodPost, "/dav/calendars/2/?add-member", strings.NewReader(ical)).WithContext(ctx)
+	req.Header.Set("Content-Type", "text/calendar")
+	rr := httptest.NewRecorder()
+	h.Post(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location := rr.Header().Get("Location")
+	if !regexp.MustCompile(`^/dav/calendars/2/[0-9a-f]{32}\.ics$`).MatchString(location) {
+		t.Fatalf("unexpected Location %q", location)
+	}
+	stored := eventRepo.Events[eventRepo.Key(2, "added")]
+	if stored == nil {
+		t.Fatal("event not stored")
+	}
+	if want := strings.TrimSuffix(strings.TrimPrefix(location, "/dav/calendars/2/"), ".ics"); stored.ResourceName != want {
+		t.Fatalf("expected resource name %q, got %q", want, stored.ResourceName)
+	}
+
+	// Adding the same UID again must not overwrite the first member.
+	req = httptest.NewRequest(http.MethodPost, "/dav/calendars/2/?add-member", strings.NewReader(ical)).WithContext(ctx)
+	req.Header.Set("Content-Type", "text/calendar")
+	rr = httptest.NewRecorder()
+	h.Post(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate UID, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Location") != "" {
+		t.Fatal("expected no Location on failure")
+	}
+}
+
+func TestPostAddMemberRejectsNonCollection(t *testing.T) {
+	h := &Handler{store: &store.Store{}}
+	req := httptest.NewRequest(http.MethodPost, "/dav/calendars/2/event.ics?add-member", strings.NewReader("x"))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Post(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestDecorateDAVPropAddsAddMemberToCollections(t *testing.T) {
+	h := &Handler{}
+	p := prop{ResourceType: resourceType{Collection: &struct{}{}, AddressBook: &struct{}{}}}
+	if err := h.decorateDAVProp(t.Context(), nil, "/dav/addressbooks/4/", &p); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := p.customXMLProperty(propAddMember)
+	if !ok || got.Value.(hrefProp).Href != "/dav/addressbooks/4/?add-member" {
+		t.Fatalf("unexpected add-member %+v", got)
+	}
+
+	p = prop{}
+	if err := h.decorateDAVProp(t.Context(), nil, "/dav/addressbooks/4/a.vcf", &p); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.customXMLProperty(propAddMember); ok {
+		t.Fatal("expected no add-member on a member resource")
+	}
+}
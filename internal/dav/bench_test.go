@@ -0,0 +1,167 @@
+package dav
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/icalgen"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+// benchSizes are the calendar sizes the DAV benchmarks run at. The largest
+// is skipped with -short.
+var benchSizes = []int{10_000, 100_000}
+
+var benchCorpora sync.Map // size -> *storetest.Events
+
+// benchHandler returns a handler serving calendar 1 of user 1 holding n
+// generated events. The corpus is built once per size and shared.
+func benchHandler(b *testing.B, n int) (*Handler, *store.User) {
+	b.Helper()
+	if n > 10_000 && testing.Short() {
+		b.Skip("large corpus skipped in short mode")
+	}
+	repo, ok := benchCorpora.Load(n)
+	if !ok {
+		events := map[string]*store.Event{}
+		for _, ev := range icalgen.Events(icalgen.Options{Seed: 1, Events: n, CalendarID: 1}) {
+			ev := ev
+			events[fmt.Sprintf("1:%s", ev.UID)] = &ev
+		}
+		repo, _ = benchCorpora.LoadOrStore(n, &storetest.Events{Events: events})
+	}
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Bench", CTag: 1, UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	h := &Handler{store: &store.Store{
+		Calendars:        calRepo,
+		Events:           repo.(*storetest.Events),
+		DeletedResources: &storetest.DeletedResources{},
+	}}
+	b.ResetTimer()
+	return h, &store.User{ID: 1}
+}
+
+func benchRequest(b *testing.B, h http.HandlerFunc, user *store.User, method, depth, body string) {
+	b.Helper()
+	req := httptest.NewRequest(method, "/dav/calendars/1/", strings.NewReader(body))
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	req = req.WithContext(auth.WithUser(req.Context(), user))
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	if rr.Code != http.StatusMultiStatus {
+		b.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func BenchmarkCalendarQuery(b *testing.B) {
+	// One month out of the generated year.
+	body := `<cal:calendar-query xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+		<d:prop><d:getetag/><cal:calendar-data/></d:prop>
+		<cal:filter>
+			<cal:comp-filter name="VCALENDAR">
+				<cal:comp-filter name="VEVENT">
+					<cal:time-range start="20240601T000000Z" end="20240701T000000Z"/>
+				</cal:comp-filter>
+			</cal:comp-filter>
+		</cal:filter>
+	</cal:calendar-query>`
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			h, user := benchHandler(b, n)
+			for i := 0; i < b.N; i++ {
+				benchRequest(b, h.Report, user, "REPORT", "1", body)
+			}
+		})
+	}
+}
+
+func BenchmarkSyncCollection(b *testing.B) {
+	const syncBody = `<d:sync-collection xmlns:d="DAV:">
+		<d:sync-token>%s</d:sync-token>
+		<d:sync-level>1</d:sync-level>
+		<d:prop><d:getetag/></d:prop>
+	</d:sync-collection>`
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("initial/events=%d", n), func(b *testing.B) {
+			h, user := benchHandler(b, n)
+			body := fmt.Sprintf(syncBody, "")
+			for i := 0; i < b.N; i++ {
+				benchRequest(b, h.Report, user, "REPORT", "", body)
+			}
+		})
+		b.Run(fmt.Sprintf("incremental/events=%d", n), func(b *testing.B) {
+			h, user := benchHandler(b, n)
+			// icalgen spaces modifications a second apart from 2024-01-01, so
+			// this token leaves the last 1% of events changed.
+			since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(n-n/100) * time.Second)
+			body := fmt.Sprintf(syncBody, buildSyncToken("cal", 1, since))
+			for i := 0; i < b.N; i++ {
+				benchRequest(b, h.Report, user, "REPORT", "", body)
+			}
+		})
+	}
+}
+
+func BenchmarkPropfind(b *testing.B) {
+	body := `<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:getcontenttype/><d:resourcetype/></d:prop></d:propfind>`
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			h, user := benchHandler(b, n)
+			for i := 0; i < b.N; i++ {
+				benchRequest(b, h.Propfind, user, "PROPFIND", "1", body)
+			}
+		})
+	}
+}
+
+// BenchmarkCalendarHomePropfind lists a calendar home holding one owned
+// calendar and many calendars shared by other users.
+func BenchmarkCalendarHomePropfind(b *testing.B) {
+	body := `<d:propfind xmlns:d="DAV:"><d:prop><d:displayname/><d:resourcetype/><d:current-user-privilege-set/></d:prop></d:propfind>`
+	for _, n := range []int{100, 500} {
+		b.Run(fmt.Sprintf("shared=%d", n), func(b *testing.B) {
+			accessible := []store.CalendarAccess{
+				{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Own", CTag: 1, UpdatedAt: store.Now()}, Editor: true},
+			}
+			for i := 0; i < n; i++ {
+				id := int64(i + 2)
+				accessible = append(accessible, store.CalendarAccess{
+					Calendar:           store.Calendar{ID: id, UserID: id, Name: fmt.Sprintf("Shared %d", i), CTag: 1, UpdatedAt: store.Now()},
+					OwnerEmail:         fmt.Sprintf("owner%d@example.com", i),
+					Shared:             true,
+					Privileges:         store.CalendarPrivileges{Read: true, ReadFreeBusy: true},
+					PrivilegesResolved: true,
+				})
+			}
+			h := &Handler{store: &store.Store{
+				Calendars:        &storetest.Calendars{Accessible: accessible},
+				Events:           &storetest.Events{Events: map[string]*store.Event{}},
+				DeletedResources: &storetest.DeletedResources{},
+			}}
+			user := &store.User{ID: 1}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest("PROPFIND", "/dav/calendars/", strings.NewReader(body))
+				req.Header.Set("Depth", "1")
+				req = req.WithContext(auth.WithUser(req.Context(), user))
+				rr := httptest.NewRecorder()
+				h.Propfind(rr, req)
+				if rr.Code != http.StatusMultiStatus {
+					b.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+				}
+			}
+		})
+	}
+}
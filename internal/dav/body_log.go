@@ -0,0 +1,89 @@
+package dav
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/logging"
+)
+
+// maxLoggedBody bounds how much of each request and response body is logged.
+const maxLoggedBody = 64 << 10
+
+// bodyLogPrivacy returns the privacy level bodies are logged at, or "" when
+// they are not logged at all.
+func bodyLogPrivacy(cfg *config.Config) string {
+	if cfg == nil || !cfg.DAV.LogBodies || cfg.LogPrivacy == config.LogPrivacyStrict {
+		return ""
+	}
+	if cfg.LogPrivacy == config.LogPrivacyOff {
+		return config.LogPrivacyOff
+	}
+	return config.LogPrivacyRedacted
+}
+
+func (h *Handler) bodyLogLevel() string {
+	h.confMu.RLock()
+	defer h.confMu.RUnlock()
+	return h.bodyLog
+}
+
+// LogBodies is middleware that logs DAV request and response bodies at
+// Debug level when APP_DAV_LOG_BODIES is set. Unless APP_LOG_PRIVACY is off,
+// the personal values of calendar and contact data are redacted first.
+func (h *Handler) LogBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		privacy := h.bodyLogLevel()
+		if privacy == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, maxLoggedBody))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		}
+		bw := &bodyLogWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+
+		redact := func(body []byte) string {
+			if privacy == config.LogPrivacyOff {
+				return string(body)
+			}
+			return logging.RedactPayload(string(body))
+		}
+		h.logger().Debug("LogBodies", "%s %s request body:\n%s", r.Method, r.URL.Path, redact(reqBody))
+		h.logger().Debug("LogBodies", "%s %s response %d body:\n%s", r.Method, r.URL.Path, bw.status, redact(bw.buf.Bytes()))
+	})
+}
+
+// bodyLogWriter passes a response through, keeping a copy of the first
+// maxLoggedBody bytes.
+type bodyLogWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bodyLogWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *bodyLogWriter) Write(p []byte) (int, error) {
+	if room := maxLoggedBody - w.buf.Len(); room > 0 {
+		w.buf.Write(p[:min(len(p), room)])
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
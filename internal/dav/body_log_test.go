@@ -0,0 +1,64 @@
+package dav
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	jw6_utils "github.com/jw6ventures/jw6-go-utils"
+)
+
+type bodyLogSink struct{ messages []string }
+
+func (s *bodyLogSink) Log(class, method string, level jw6_utils.LogLevel, message string) {
+	s.messages = append(s.messages, message)
+}
+
+func TestLogBodiesRedactsPayloadsByPrivacyLevel(t *testing.T) {
+	ical := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:u1\r\nSUMMARY:Therapy\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	tests := []struct {
+		privacy  string
+		logged   bool
+		verbatim bool
+	}{
+		{privacy: config.LogPrivacyRedacted, logged: true},
+		{privacy: config.LogPrivacyOff, logged: true, verbatim: true},
+		{privacy: config.LogPrivacyStrict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.privacy, func(t *testing.T) {
+			sink := &bodyLogSink{}
+			cfg := &config.Config{LogPrivacy: tt.privacy}
+			cfg.DAV.LogBodies = true
+			h := NewServer(Options{Config: cfg, Logger: sink})
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				seen = string(body)
+				_, _ = w.Write(body)
+			})
+			rr := httptest.NewRecorder()
+			h.LogBodies(next).ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/dav/calendars/1/u1.ics", strings.NewReader(ical)))
+
+			if seen != ical || rr.Body.String() != ical {
+				t.Fatal("the handler and client must see the bodies unchanged")
+			}
+			logged := strings.Join(sink.messages, "\n")
+			if !tt.logged {
+				if strings.Contains(logged, "UID:u1") {
+					t.Fatalf("expected no bodies logged, got %q", logged)
+				}
+				return
+			}
+			if strings.Count(logged, "UID:u1") != 2 {
+				t.Fatalf("expected request and response bodies logged, got %q", logged)
+			}
+			if strings.Contains(logged, "Therapy") != tt.verbatim {
+				t.Fatalf("privacy %s logged %q", tt.privacy, logged)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package dav
 
 import (
+	"encoding/xml"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -149,3 +151,55 @@ func TestBuildCalDAVErrorXMLMulti(t *testing.T) {
 		t.Error("expected second condition in XML")
 	}
 }
+
+func TestWriteDAVErrorIncludesConditionAndEscapedMessage(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeDAVError(w, http.StatusLocked, "locked by <other>", condLockTokenSubmitted)
+
+	if w.Code != http.StatusLocked {
+		t.Fatalf("expected 423, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Fatalf("expected XML content type, got %q", ct)
+	}
+	var parsed struct {
+		XMLName xml.Name
+		Lock    *struct{} `xml:"DAV: lock-token-submitted"`
+		Message string    `xml:"urn:calcard:error message"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid XML body: %v\n%s", err, w.Body.String())
+	}
+	if parsed.XMLName.Space != "DAV:" || parsed.XMLName.Local != "error" {
+		t.Fatalf("expected DAV:error root, got %v", parsed.XMLName)
+	}
+	if parsed.Lock == nil {
+		t.Fatalf("expected lock-token-submitted condition, got %s", w.Body.String())
+	}
+	if parsed.Message != "locked by <other>" {
+		t.Fatalf("unexpected message %q", parsed.Message)
+	}
+}
+
+func TestWriteDAVStatusErrorMarksForbiddenAsNeedPrivileges(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeDAVStatusError(w, http.StatusForbidden)
+	if !strings.Contains(w.Body.String(), "<D:need-privileges/>") {
+		t.Fatalf("expected need-privileges, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	writeDAVStatusError(w, http.StatusNotFound)
+	if strings.Contains(w.Body.String(), "need-privileges") {
+		t.Fatalf("unexpected need-privileges on 404: %s", w.Body.String())
+	}
+}
+
+func TestWriteCardDAVPreconditionUsesCardDAVNamespace(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeCardDAVPrecondition(w, http.StatusBadRequest, "valid-address-data")
+	body := w.Body.String()
+	if !strings.Contains(body, `xmlns:A="urn:ietf:params:xml:ns:carddav"`) || !strings.Contains(body, "<A:valid-address-data/>") {
+		t.Fatalf("expected CardDAV precondition, got %s", body)
+	}
+}
@@ -112,7 +112,7 @@ func (h *Handler) accessibleCalendars(ctx context.Context, user *store.User) ([]
 		return result, nil
 	}
 
-	for _, principal := range []string{"DAV:all", "DAV:authenticated", h.principalURL(user)} {
+	for _, principal := range aclPrincipalHrefs(user) {
 		entries, err := h.store.ACLEntries.ListByPrincipal(ctx, principal)
 		if err != nil {
 			return nil, err
@@ -483,6 +483,18 @@ func mergeCalendarAccessWithLegacy(access, legacy *store.CalendarAccess) {
 	access.Editor = access.Privileges.AllowsEventEditing()
 }
 
+// calendarCollectionHref is the path a user sees for a calendar: the slug it
+// was created under for the user's own calendars, otherwise its ID. ID paths
+// keep working as aliases for every calendar.
+func calendarCollectionHref(user *store.User, cal *store.Calendar) string {
+	if user != nil && cal.UserID == user.ID && cal.Slug != nil && isValidCalendarSlug(*cal.Slug) {
+		if _, err := strconv.ParseInt(*cal.Slug, 10, 64); err != nil {
+			return path.Join("/dav/calendars", *cal.Slug)
+		}
+	}
+	return path.Join("/dav/calendars", fmt.Sprint(cal.ID))
+}
+
 func calendarCollectionResourcePath(calendarID int64) string {
 	return path.Join("/dav/calendars", fmt.Sprint(calendarID))
 }
@@ -503,11 +515,7 @@ func calendarObjectACLPaths(calendarID int64, resourceName string) []string {
 }
 
 func aclPrincipalHrefs(user *store.User) []string {
-	principals := []string{"DAV:all"}
-	if user != nil {
-		principals = append(principals, "DAV:authenticated", fmt.Sprintf("/dav/principals/%d/", user.ID))
-	}
-	return principals
+	return store.ACLPrincipals(user)
 }
 
 func calendarPrivilegeDecisionFromEntries(user *store.User, cal *store.CalendarAccess, resourceName, privilege string, entriesByPath map[string][]store.ACLEntry) (bool, bool) {
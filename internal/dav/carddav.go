@@ -715,6 +715,15 @@ func propstatForAddressObjectPropfind(req *propfindPropQuery, src prop) []propst
 		okProp.GetContentType = src.GetContentType
 		okSet = true
 	}
+	if req.GetLastModified != nil {
+		if src.GetLastModified != "" {
+			okProp.GetLastModified = src.GetLastModified
+			okSet = true
+		} else {
+			notFoundProp.GetLastModified = "getlastmodified"
+			notFoundSet = true
+		}
+	}
 	if req.SupportedReportSet != nil {
 		okProp.SupportedReportSet = src.SupportedReportSet
 		okSet = true
@@ -882,6 +891,10 @@ func filterPrincipalPropfindResponse(resp response, req *propfindRequest) respon
 		notFound.AddressBookMaxResourceSize = "max-resource-size"
 		notFoundSet = true
 	}
+	if req.Prop.AddressBookMaxImageSize != nil {
+		notFound.AddressBookMaxImageSize = "max-image-size"
+		notFoundSet = true
+	}
 	if req.Prop.SupportedCollationSet != nil {
 		notFound.SupportedCollationSet = &supportedCollationSet{}
 		notFoundSet = true
@@ -938,6 +951,10 @@ func filterPrincipalPropfindResponse(resp response, req *propfindRequest) respon
 		notFound.Owner = &hrefProp{}
 		notFoundSet = true
 	}
+	if req.Prop.Invite != nil {
+		notFound.Invite = &inviteProp{}
+		notFoundSet = true
+	}
 
 	resp.Propstat = nil
 	if okSet {
@@ -981,6 +998,10 @@ func filterAddressBookCollectionPropfindResponse(resp response, req *propfindReq
 		okProp.AddressBookMaxResourceSize = src.AddressBookMaxResourceSize
 		okSet = true
 	}
+	if req.Prop.AddressBookMaxImageSize != nil {
+		okProp.AddressBookMaxImageSize = src.AddressBookMaxImageSize
+		okSet = true
+	}
 	if req.Prop.SupportedCollationSet != nil {
 		okProp.SupportedCollationSet = src.SupportedCollationSet
 		okSet = true
@@ -1106,6 +1127,10 @@ func filterAddressBookCollectionPropfindResponse(resp response, req *propfindReq
 		notFound.Owner = &hrefProp{}
 		notFoundSet = true
 	}
+	if req.Prop.Invite != nil {
+		notFound.Invite = &inviteProp{}
+		notFoundSet = true
+	}
 	resp.Propstat = nil
 	if okSet {
 		resp.Propstat = append(resp.Propstat, propstat{Prop: okProp, Status: httpStatusOK})
@@ -1129,6 +1154,7 @@ func stripAddressBookAllprop(responses []response) {
 			prop.AddressBookDesc = ""
 			prop.SupportedAddressData = nil
 			prop.AddressBookMaxResourceSize = ""
+			prop.AddressBookMaxImageSize = ""
 			prop.SupportedCollationSet = nil
 		}
 	}
@@ -1148,9 +1174,7 @@ func stripPrincipalAllprop(responses []response) {
 }
 
 func writeCardDAVPrecondition(w http.ResponseWriter, status int, condition string) {
-	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
-	w.WriteHeader(status)
-	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav"><C:%s/></D:error>`, condition)
+	writeDAVError(w, status, "", davCondition{"A", condition})
 }
 
 // writeCardDAVUIDConflict writes a no-uid-conflict error response including the
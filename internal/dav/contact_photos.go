@@ -0,0 +1,313 @@
+package dav
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// maxContactPhotoBytes caps photos uploaded with POST ?action=photo-add. It
+// is advertised on address books as CARDDAV:max-image-size.
+const maxContactPhotoBytes int64 = 1 << 20
+
+// contactPhotoPathPrefix is where uploaded photos are served from. Photos
+// live under the address book they were uploaded to and can be read by
+// anyone who can read that address book.
+const contactPhotoPathPrefix = "/dav/photos/"
+
+var contactPhotoTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+var contactPhotoName = regexp.MustCompile(`^[0-9a-f]{32}\.(jpg|png|gif|webp)$`)
+
+// Post handles the managed photo actions on a contact resource:
+// ?action=photo-add stores the request body as the contact's photo and
+// points PHOTO at it, and ?action=photo-remove drops the photo again. Both
+// answer with the new ETag of the contact. A POST to a collection's
+// add-member URL creates a new member instead; see addMember.
+func (h *Handler) Post(w http.ResponseWriter, r *http.Request) {
+	if h.handleRegisteredMethod(w, r) {
+		return
+	}
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
+		return
+	}
+	if isAddMemberRequest(r) {
+		h.addMember(w, r)
+		return
+	}
+	cleanPath := path.Clean(r.URL.Path)
+	// A POST to the collection itself registers a WebDAV-Push subscription.
+	if _, ok := addMemberExtension(cleanPath); ok && h.pushEnabled(r.Context(), user) {
+		h.pushRegister(w, r, user)
+		return
+	}
+	addressBookID, resourceName, matched, err := h.parseAddressBookResourcePath(r.Context(), user, cleanPath)
+	if err != nil {
+		if err == store.ErrNotFound {
+			writeDAVError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if errors.Is(err, errAmbiguousAddressBook) {
+			writeDAVError(w, http.StatusConflict, "ambiguous address book path")
+			return
+		}
+		writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
+		return
+	}
+	if !matched {
+		writeDAVError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	action := r.URL.Query().Get("action")
+	if action != "photo-add" && action != "photo-remove" {
+		writeDAVError(w, http.StatusBadRequest, "unsupported action")
+		return
+	}
+	if h.store.Blobs == nil {
+		writeDAVError(w, http.StatusNotImplemented, "photo storage is not configured")
+		return
+	}
+
+	book, err := h.getAddressBook(r.Context(), addressBookID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == store.ErrNotFound {
+			status = http.StatusNotFound
+		}
+		writeDAVError(w, status, "address book not found")
+		return
+	}
+	if err := h.requireAddressBookPrivilege(r.Context(), user, book, cleanPath, "write-content"); err != nil {
+		status := http.StatusForbidden
+		if err == store.ErrNotFound {
+			status = http.StatusNotFound
+		}
+		writeDAVStatusError(w, status)
+		return
+	}
+	if !h.requireLock(w, r, cleanPath, "resource is locked") {
+		return
+	}
+	contact, err := h.store.Contacts.GetByResourceName(r.Context(), addressBookID, resourceName)
+	if err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to load contact")
+		return
+	}
+	if contact == nil {
+		writeDAVError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !h.checkConditionalHeadersContact(r, contact) {
+		writeDAVError(w, http.StatusPreconditionFailed, "precondition failed")
+		return
+	}
+
+	card := utils.RemoveVCardPropertyLines(contact.RawVCard, "PHOTO")
+	var photoURL, key string
+	if action == "photo-add" {
+		if photoURL, key, ok = h.storeContactPhoto(w, r, addressBookID); !ok {
+			return
+		}
+		card = utils.AppendVCardLines(card, contactPhotoLine(card, photoURL, r.Header.Get("Content-Type")))
+		if err := h.validateVCard(card); err != nil {
+			_ = h.store.Blobs.Delete(r.Context(), key)
+			writeCardDAVPrecondition(w, http.StatusBadRequest, "valid-address-data")
+			return
+		}
+	}
+
+	etag := utils.GenerateETag(card)
+	updated := *contact
+	updated.RawVCard = card
+	updated.ETag = etag
+	if _, err := h.store.Contacts.Upsert(r.Context(), updated); err != nil {
+		h.logger().Error("Post", "failed to save photo of contact %q in address book %d: %v", contact.UID, addressBookID, err)
+		if key != "" {
+			_ = h.store.Blobs.Delete(r.Context(), key)
+		}
+		writeDAVError(w, http.StatusInternalServerError, "failed to save contact")
+		return
+	}
+	h.deleteReplacedContactPhotos(r, addressBookID, contact.RawVCard)
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+	if action == "photo-remove" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.logger().Info("Post", "stored photo of contact %q in address book %d", contact.UID, addressBookID)
+	w.Header().Set("Location", photoURL)
+	w.Header().Set("Content-Type", "text/vcard")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(card))
+}
+
+// storeContactPhoto saves the request body under a new key and returns the
+// URL it is served from. It writes the error response itself when it fails.
+func (h *Handler) storeContactPhoto(w http.ResponseWriter, r *http.Request, addressBookID int64) (string, string, bool) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+	ext, ok := contactPhotoTypes[mediaType]
+	if !ok {
+		writeDAVError(w, http.StatusUnsupportedMediaType, "photo must be JPEG, PNG, GIF or WebP")
+		return "", "", false
+	}
+	if r.ContentLength > maxContactPhotoBytes {
+		writeCardDAVPrecondition(w, http.StatusRequestEntityTooLarge, "max-image-size")
+		return "", "", false
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContactPhotoBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeCardDAVPrecondition(w, http.StatusRequestEntityTooLarge, "max-image-size")
+		} else {
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
+		}
+		return "", "", false
+	}
+	// The photo is served back with the declared type, so it has to be what
+	// it claims to be.
+	if http.DetectContentType(data) != mediaType {
+		writeDAVError(w, http.StatusUnsupportedMediaType, "photo does not match its content type")
+		return "", "", false
+	}
+	if h.store.Scanner != nil && !h.allowScanned(w, "Post", r.URL.Path, h.store.Scanner.Scan(r.Context(), bytes.NewReader(data))) {
+		return "", "", false
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to store photo")
+		return "", "", false
+	}
+	name := hex.EncodeToString(id[:]) + "." + ext
+	key := contactPhotoKey(addressBookID, name)
+	if err := h.store.Blobs.Put(r.Context(), key, bytes.NewReader(data), int64(len(data))); err != nil {
+		h.logger().Error("storeContactPhoto", "failed to store photo for address book %d: %v", addressBookID, err)
+		writeDAVError(w, http.StatusInternalServerError, "failed to store photo")
+		return "", "", false
+	}
+	photoURL := contactPhotoPathPrefix + strconv.FormatInt(addressBookID, 10) + "/" + name
+	if h.cfg != nil && h.cfg.BaseURL != "" {
+		photoURL = strings.TrimRight(h.cfg.BaseURL, "/") + photoURL
+	}
+	return photoURL, key, true
+}
+
+// deleteReplacedContactPhotos removes the stored photos the previous version
+// of a card pointed at. Failures only leave an unreferenced object behind.
+func (h *Handler) deleteReplacedContactPhotos(r *http.Request, addressBookID int64, previous string) {
+	for _, line := range utils.ExtractVCardPropertyLines(previous, "PHOTO") {
+		idx := strings.Index(line, contactPhotoPathPrefix)
+		if idx < 0 {
+			continue
+		}
+		bookID, name, ok := parseContactPhotoPath(line[idx:])
+		if !ok || bookID != addressBookID {
+			continue
+		}
+		if err := h.store.Blobs.Delete(r.Context(), contactPhotoKey(bookID, name)); err != nil {
+			h.logger().Warn("deleteReplacedContactPhotos", "failed to delete photo %s of address book %d: %v", name, bookID, err)
+		}
+	}
+}
+
+// getContactPhoto serves a photo stored with photo-add to users who can read
+// its address book.
+func (h *Handler) getContactPhoto(w http.ResponseWriter, r *http.Request, user *store.User, cleanPath string) {
+	bookID, name, ok := parseContactPhotoPath(cleanPath)
+	if !ok || h.store.Blobs == nil {
+		writeDAVError(w, http.StatusNotFound, "not found")
+		return
+	}
+	bookPath := "/dav/addressbooks/" + strconv.FormatInt(bookID, 10)
+	if _, err := h.loadAddressBookWithPrivilege(r.Context(), user, bookID, bookPath, "read"); err != nil {
+		if err == store.ErrNotFound {
+			writeDAVError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if errors.Is(err, errForbidden) {
+			writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
+			return
+		}
+		writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
+		return
+	}
+	rc, err := h.store.Blobs.Get(r.Context(), contactPhotoKey(bookID, name))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeDAVError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeDAVError(w, http.StatusInternalServerError, "failed to load photo")
+		return
+	}
+	defer rc.Close()
+	ext := path.Ext(name)[1:]
+	for mediaType, e := range contactPhotoTypes {
+		if e == ext {
+			w.Header().Set("Content-Type", mediaType)
+		}
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Names are never reused, so the content under one never changes.
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	_, _ = io.Copy(w, rc)
+}
+
+// parseContactPhotoPath splits "/dav/photos/<book>/<name>" and checks that
+// name is one photo-add could have generated.
+func parseContactPhotoPath(p string) (int64, string, bool) {
+	rest, ok := strings.CutPrefix(p, contactPhotoPathPrefix)
+	if !ok {
+		return 0, "", false
+	}
+	idPart, name, ok := strings.Cut(rest, "/")
+	if !ok || !contactPhotoName.MatchString(name) {
+		return 0, "", false
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, "", false
+	}
+	return id, name, true
+}
+
+func contactPhotoKey(addressBookID int64, name string) string {
+	return fmt.Sprintf("contact-photos/%d/%s", addressBookID, name)
+}
+
+// contactPhotoLine references photoURL in the form the card's vCard version
+// expects.
+func contactPhotoLine(card, photoURL, contentType string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, line := range utils.ExtractVCardPropertyLines(card, "VERSION") {
+		if strings.HasSuffix(strings.TrimSpace(line), "4.0") {
+			return "PHOTO;MEDIATYPE=" + mediaType + ":" + photoURL
+		}
+	}
+	return "PHOTO;VALUE=uri;TYPE=" + strings.ToUpper(strings.TrimPrefix(mediaType, "image/")) + ":" + photoURL
+}
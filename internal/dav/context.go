@@ -119,9 +119,9 @@ func writeResponseError(w http.ResponseWriter, err error) bool {
 		if body == "" {
 			body = http.StatusText(status)
 		}
-		http.Error(w, body, status)
+		writeDAVError(w, status, body)
 		return true
 	}
-	http.Error(w, err.Error(), http.StatusBadRequest)
+	writeDAVError(w, http.StatusBadRequest, err.Error())
 	return true
 }
@@ -10,10 +10,13 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/lib/pq"
 )
 
 func parseDestinationHeader(r *http.Request) (string, bool, error) {
@@ -47,14 +50,14 @@ func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Copy", "COPY %s -> %s", r.URL.Path, r.Header.Get("Destination"))
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
 	srcPath := path.Clean(r.URL.Path)
 	destPath, overwrite, err := parseDestinationHeader(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -68,7 +71,7 @@ func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
 
 	// Handle calendar event copy
 	if srcCalID, srcUID, srcMatched, err := h.parseCalendarResourcePath(r.Context(), user, srcPath); err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	} else if srcMatched && srcUID != "" {
 		h.copyCalendarEvent(w, r, user, srcCalID, srcUID, destPath, overwrite)
@@ -77,14 +80,104 @@ func (h *Handler) Copy(w http.ResponseWriter, r *http.Request) {
 
 	// Handle contact copy
 	if srcBookID, srcUID, srcMatched, err := h.parseAddressBookResourcePath(r.Context(), user, srcPath); err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	} else if srcMatched && srcUID != "" {
 		h.copyContact(w, r, user, srcBookID, srcUID, destPath, overwrite)
 		return
 	}
 
-	http.Error(w, "unsupported copy source", http.StatusForbidden)
+	// Handle calendar collection copy
+	if segment, ok := calendarCollectionSegment(srcPath); ok {
+		h.copyCalendarCollection(w, r, user, segment, destPath, overwrite)
+		return
+	}
+
+	writeDAVError(w, http.StatusForbidden, "unsupported copy source")
+}
+
+// calendarCollectionSegment returns the name or ID in a calendar collection
+// path such as /dav/calendars/work/.
+func calendarCollectionSegment(rawPath string) (string, bool) {
+	rest, ok := strings.CutPrefix(normalizeDAVHref(rawPath), "/dav/calendars/")
+	rest = strings.TrimSuffix(rest, "/")
+	if !ok || rest == "" || strings.Contains(rest, "/") {
+		return "", false
+	}
+	return rest, true
+}
+
+// copyCalendarCollection duplicates a readable calendar as a new calendar of
+// the user's at destPath, which must name a calendar that does not exist yet,
+// as MKCALENDAR would create it. Events are copied with new UIDs; with
+// Depth: 0 only the collection is.
+func (h *Handler) copyCalendarCollection(w http.ResponseWriter, r *http.Request, user *store.User, segment, destPath string, overwrite bool) {
+	srcID, ok, err := h.resolveCalendarID(r.Context(), user, segment)
+	if err != nil || !ok {
+		writeDAVError(w, http.StatusNotFound, "source not found")
+		return
+	}
+	if _, err := h.loadCalendarWithPrivilege(r.Context(), user, srcID, srcPath(r), "read"); err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, errForbidden) {
+			status = http.StatusForbidden
+		}
+		writeDAVError(w, status, "source not found")
+		return
+	}
+	destName, ok := calendarCollectionSegment(destPath)
+	if !ok {
+		writeDAVError(w, http.StatusForbidden, "invalid destination")
+		return
+	}
+	if _, err := strconv.ParseInt(destName, 10, 64); err == nil {
+		writeDAVError(w, http.StatusForbidden, "destination must name a new calendar")
+		return
+	}
+	slug := strings.ToLower(destName)
+	if !isValidCalendarSlug(slug) {
+		writeDAVError(w, http.StatusBadRequest, "invalid calendar name: must contain only lowercase letters, numbers, and hyphens")
+		return
+	}
+	cals, err := h.store.Calendars.ListAccessible(r.Context(), user.ID)
+	if err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to check calendars")
+		return
+	}
+	for _, cal := range cals {
+		if (cal.Slug != nil && *cal.Slug == slug) || strings.EqualFold(cal.Name, destName) {
+			// Replacing a calendar with a copy of another would drop its
+			// shares and history, so only new destinations are allowed.
+			if !overwrite {
+				writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
+			} else {
+				writeDAVError(w, http.StatusForbidden, "cannot overwrite an existing calendar")
+			}
+			return
+		}
+	}
+
+	created, _, err := events.NewService(h.store).DuplicateCalendar(r.Context(), user, srcID, events.DuplicateInput{
+		Name:       destName,
+		Slug:       &slug,
+		SkipEvents: r.Header.Get("Depth") == "0",
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			writeDAVError(w, http.StatusConflict, "calendar already exists", condResourceMustBeNull)
+		case errors.Is(err, events.ErrNotFound):
+			writeDAVError(w, http.StatusNotFound, "source not found")
+		case errors.Is(err, events.ErrForbidden):
+			writeDAVError(w, http.StatusForbidden, "source not readable")
+		default:
+			writeDAVError(w, http.StatusInternalServerError, "failed to copy calendar")
+		}
+		return
+	}
+	w.Header().Set("Location", calendarCollectionHref(user, created)+"/")
+	w.WriteHeader(http.StatusCreated)
 }
 
 func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user *store.User, srcCalID int64, srcUID, destPath string, overwrite bool) {
@@ -94,18 +187,18 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if errors.Is(err, errForbidden) {
 			status = http.StatusForbidden
 		}
-		http.Error(w, "source not found", status)
+		writeDAVError(w, status, "source not found")
 		return
 	}
 
 	src, err := h.store.Events.GetByResourceName(r.Context(), srcCalID, srcUID)
 	if err != nil || src == nil {
-		http.Error(w, "source event not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source event not found")
 		return
 	}
 	destCalID, destResourceName, destMatched, err := h.parseCalendarResourcePath(r.Context(), user, destPath)
 	if err != nil || !destMatched {
-		http.Error(w, "invalid destination", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "invalid destination")
 		return
 	}
 
@@ -115,13 +208,13 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 
 	existing, err := h.store.Events.GetByResourceName(r.Context(), destCalID, destResourceName)
 	if err != nil {
-		http.Error(w, "failed to load destination event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination event")
 		return
 	}
 	sameResource := srcCalID == destCalID && eventResourceName(*src) == destResourceName
 	if sameResource {
 		if !overwrite {
-			http.Error(w, "destination exists", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 			return
 		}
 		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, src.ETag))
@@ -130,7 +223,7 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 	}
 	existingByUID, err := h.store.Events.GetByUID(r.Context(), destCalID, src.UID)
 	if err != nil {
-		http.Error(w, "failed to load destination event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination event")
 		return
 	}
 	if existingByUID != nil {
@@ -145,7 +238,7 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		return
 	}
 	if existing != nil && !overwrite {
-		http.Error(w, "destination exists", http.StatusPreconditionFailed)
+		writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 		return
 	}
 	loadPrivilege := "bind"
@@ -158,7 +251,7 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if err := h.requireCalendarDestinationWritePrivileges(r.Context(), user, destCal, destPath, existing, src.UID); err != nil {
@@ -166,12 +259,12 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if existing != nil {
 		if err := h.deleteDAVResourceState(r.Context(), user, destPath); err != nil {
-			http.Error(w, "failed to clear destination state", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to clear destination state")
 			return
 		}
 	}
@@ -183,9 +276,10 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 			writeCalDAVError(w, http.StatusConflict, "no-uid-conflict")
 			return
 		}
-		http.Error(w, "failed to copy event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to copy event")
 		return
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldEvent, collectionID: destCalID, resourceName: destResourceName})
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, etag))
 	if existing != nil {
 		w.WriteHeader(http.StatusNoContent)
@@ -198,13 +292,13 @@ func (h *Handler) copyCalendarEvent(w http.ResponseWriter, r *http.Request, user
 func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *store.User, srcBookID int64, srcUID, destPath string, overwrite bool) {
 	destBookID, destResourceName, destMatched, err := h.parseAddressBookResourcePath(r.Context(), user, destPath)
 	if err != nil || !destMatched {
-		http.Error(w, "invalid destination", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "invalid destination")
 		return
 	}
 
 	srcBook, err := h.getAddressBook(r.Context(), srcBookID)
 	if err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	}
 	if err := h.requireAddressBookPrivilege(r.Context(), user, srcBook, path.Clean(r.URL.Path), "read"); err != nil {
@@ -212,28 +306,28 @@ func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *stor
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	src, err := h.store.Contacts.GetByResourceName(r.Context(), srcBookID, srcUID)
 	if err != nil {
-		http.Error(w, "failed to load source contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load source contact")
 		return
 	}
 	if src == nil {
-		http.Error(w, "source contact not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source contact not found")
 		return
 	}
 
 	destBook, err := h.getAddressBook(r.Context(), destBookID)
 	if err != nil {
-		http.Error(w, "destination not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "destination not found")
 		return
 	}
 
 	existingByName, err := h.store.Contacts.GetByResourceName(r.Context(), destBookID, destResourceName)
 	if err != nil {
-		http.Error(w, "failed to load destination contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination contact")
 		return
 	}
 	if err := h.requireAddressBookDestinationWritePrivileges(r.Context(), user, destBook, destPath, existingByName, src.UID); err != nil {
@@ -241,7 +335,7 @@ func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *stor
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if !h.requireLock(w, r, path.Dir(destPath), "destination is locked") {
@@ -254,7 +348,7 @@ func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *stor
 	sameResource := srcBookID == destBookID && contactResourceName(*src) == destResourceName
 	if sameResource {
 		if !overwrite {
-			http.Error(w, "destination exists", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 			return
 		}
 		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, src.ETag))
@@ -267,12 +361,12 @@ func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *stor
 		return
 	}
 	if existingByName != nil && !overwrite {
-		http.Error(w, "destination exists", http.StatusPreconditionFailed)
+		writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 		return
 	}
 	existingByUID, err := h.store.Contacts.GetByUID(r.Context(), destBookID, src.UID)
 	if err != nil {
-		http.Error(w, "failed to load destination contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination contact")
 		return
 	}
 	if existingByUID != nil {
@@ -287,21 +381,22 @@ func (h *Handler) copyContact(w http.ResponseWriter, r *http.Request, user *stor
 
 	if existingByName != nil {
 		if err := h.deleteDAVResourceState(r.Context(), user, destPath); err != nil {
-			http.Error(w, "failed to clear destination state", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to clear destination state")
 			return
 		}
 	} else {
 		if err := h.deleteDAVACLState(r.Context(), user, destPath); err != nil {
-			http.Error(w, "failed to reset destination ACL state", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to reset destination ACL state")
 			return
 		}
 	}
 
 	_, err = h.store.Contacts.CopyToAddressBook(r.Context(), srcBookID, destBookID, src.UID, destResourceName, etag)
 	if err != nil {
-		http.Error(w, "failed to copy contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to copy contact")
 		return
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldContact, collectionID: destBookID, resourceName: destResourceName})
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, etag))
 	if existingByName != nil {
 		w.WriteHeader(http.StatusNoContent)
@@ -318,14 +413,14 @@ func (h *Handler) Move(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Move", "MOVE %s -> %s", r.URL.Path, r.Header.Get("Destination"))
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
 	srcPath := path.Clean(r.URL.Path)
 	destPath, overwrite, err := parseDestinationHeader(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -339,7 +434,7 @@ func (h *Handler) Move(w http.ResponseWriter, r *http.Request) {
 
 	// Handle calendar event move
 	if srcCalID, srcUID, srcMatched, err := h.parseCalendarResourcePath(r.Context(), user, srcPath); err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	} else if srcMatched && srcUID != "" {
 		h.moveCalendarEvent(w, r, user, srcCalID, srcUID, destPath, overwrite)
@@ -348,14 +443,14 @@ func (h *Handler) Move(w http.ResponseWriter, r *http.Request) {
 
 	// Handle contact move
 	if srcBookID, srcUID, srcMatched, err := h.parseAddressBookResourcePath(r.Context(), user, srcPath); err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	} else if srcMatched && srcUID != "" {
 		h.moveContact(w, r, user, srcBookID, srcUID, destPath, overwrite)
 		return
 	}
 
-	http.Error(w, "unsupported move source", http.StatusForbidden)
+	writeDAVError(w, http.StatusForbidden, "unsupported move source")
 }
 
 func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user *store.User, srcCalID int64, srcUID, destPath string, overwrite bool) {
@@ -368,7 +463,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if errors.Is(err, errForbidden) {
 			status = http.StatusForbidden
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if err := h.requireCalendarPrivilege(r.Context(), user, &srcCal.Calendar, srcPath(r), "unbind"); err != nil {
@@ -376,7 +471,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if !h.requireLock(w, r, path.Dir(path.Clean(r.URL.Path)), "source is locked") {
@@ -384,13 +479,13 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 	}
 	src, err := h.store.Events.GetByResourceName(r.Context(), srcCalID, srcUID)
 	if err != nil || src == nil {
-		http.Error(w, "source event not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source event not found")
 		return
 	}
 
 	destCalID, destResourceName, destMatched, err := h.parseCalendarResourcePath(r.Context(), user, destPath)
 	if err != nil || !destMatched {
-		http.Error(w, "invalid destination", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "invalid destination")
 		return
 	}
 
@@ -400,13 +495,13 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 
 	existing, err := h.store.Events.GetByResourceName(r.Context(), destCalID, destResourceName)
 	if err != nil {
-		http.Error(w, "failed to load destination event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination event")
 		return
 	}
 	sameResource := srcCalID == destCalID && eventResourceName(*src) == destResourceName
 	if sameResource {
 		if !overwrite {
-			http.Error(w, "destination exists", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -414,7 +509,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 	}
 	existingByUID, err := h.store.Events.GetByUID(r.Context(), destCalID, src.UID)
 	if err != nil {
-		http.Error(w, "failed to load destination event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination event")
 		return
 	}
 	if existingByUID != nil {
@@ -425,7 +520,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		}
 	}
 	if existing != nil && !overwrite {
-		http.Error(w, "destination exists", http.StatusPreconditionFailed)
+		writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 		return
 	}
 	loadPrivilege := "bind"
@@ -438,7 +533,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if err := h.requireCalendarDestinationWritePrivileges(r.Context(), user, destCal, destPath, existing, src.UID); err != nil {
@@ -446,7 +541,7 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 
@@ -455,23 +550,24 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 			writeCalDAVError(w, http.StatusConflict, "no-uid-conflict")
 			return
 		}
-		http.Error(w, "failed to move event", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to move event")
 		return
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldEvent, collectionID: destCalID, resourceName: destResourceName})
 	if err := h.rebindMovedDAVResourceState(r.Context(), user, srcPath(r), destPath, existing != nil); err != nil {
 		if rollbackErr := h.rollbackCalendarMove(r.Context(), destCalID, destResourceName, *src, existing); rollbackErr != nil {
-			http.Error(w, "failed to roll back move after state rebind failure", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to roll back move after state rebind failure")
 			return
 		}
-		http.Error(w, "failed to rebind resource state", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to rebind resource state")
 		return
 	}
 	if err := h.clearOverwrittenEventTombstone(r.Context(), destCalID, existing, destResourceName); err != nil {
 		if rollbackErr := h.rollbackCalendarMove(r.Context(), destCalID, destResourceName, *src, existing); rollbackErr != nil {
-			http.Error(w, "failed to roll back move after tombstone cleanup failure", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to roll back move after tombstone cleanup failure")
 			return
 		}
-		http.Error(w, "failed to finalize move", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to finalize move")
 		return
 	}
 
@@ -486,13 +582,13 @@ func (h *Handler) moveCalendarEvent(w http.ResponseWriter, r *http.Request, user
 func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *store.User, srcBookID int64, srcUID, destPath string, overwrite bool) {
 	destBookID, destResourceName, destMatched, err := h.parseAddressBookResourcePath(r.Context(), user, destPath)
 	if err != nil || !destMatched {
-		http.Error(w, "invalid destination", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "invalid destination")
 		return
 	}
 
 	srcBook, err := h.getAddressBook(r.Context(), srcBookID)
 	if err != nil {
-		http.Error(w, "source not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source not found")
 		return
 	}
 	if err := h.requireAddressBookPrivilege(r.Context(), user, srcBook, path.Clean(r.URL.Path), "unbind"); err != nil {
@@ -500,7 +596,7 @@ func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *stor
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if !h.requireLock(w, r, path.Dir(path.Clean(r.URL.Path)), "source is locked") {
@@ -508,23 +604,23 @@ func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *stor
 	}
 	src, err := h.store.Contacts.GetByResourceName(r.Context(), srcBookID, srcUID)
 	if err != nil {
-		http.Error(w, "failed to load source contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load source contact")
 		return
 	}
 	if src == nil {
-		http.Error(w, "source contact not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "source contact not found")
 		return
 	}
 
 	destBook, err := h.getAddressBook(r.Context(), destBookID)
 	if err != nil {
-		http.Error(w, "destination not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "destination not found")
 		return
 	}
 
 	existingByName, err := h.store.Contacts.GetByResourceName(r.Context(), destBookID, destResourceName)
 	if err != nil {
-		http.Error(w, "failed to load destination contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination contact")
 		return
 	}
 	if err := h.requireAddressBookDestinationWritePrivileges(r.Context(), user, destBook, destPath, existingByName, src.UID); err != nil {
@@ -532,7 +628,7 @@ func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *stor
 		if err == store.ErrNotFound {
 			status = http.StatusNotFound
 		}
-		http.Error(w, http.StatusText(status), status)
+		writeDAVStatusError(w, status)
 		return
 	}
 	if !h.requireLock(w, r, path.Dir(destPath), "destination is locked") {
@@ -545,19 +641,19 @@ func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *stor
 	sameResource := srcBookID == destBookID && contactResourceName(*src) == destResourceName
 	if sameResource {
 		if !overwrite {
-			http.Error(w, "destination exists", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 	if existingByName != nil && !overwrite {
-		http.Error(w, "destination exists", http.StatusPreconditionFailed)
+		writeDAVError(w, http.StatusPreconditionFailed, "destination exists")
 		return
 	}
 	existingByUID, err := h.store.Contacts.GetByUID(r.Context(), destBookID, src.UID)
 	if err != nil {
-		http.Error(w, "failed to load destination contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load destination contact")
 		return
 	}
 	if existingByUID != nil {
@@ -570,23 +666,24 @@ func (h *Handler) moveContact(w http.ResponseWriter, r *http.Request, user *stor
 	}
 
 	if err := h.store.Contacts.MoveToAddressBook(r.Context(), srcBookID, destBookID, src.UID, destResourceName); err != nil {
-		http.Error(w, "failed to move contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to move contact")
 		return
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldContact, collectionID: destBookID, resourceName: destResourceName})
 	if err := h.rebindMovedDAVResourceState(r.Context(), user, srcPath(r), destPath, existingByName != nil); err != nil {
 		if rollbackErr := h.rollbackContactMove(r.Context(), destBookID, destResourceName, *src, existingByName); rollbackErr != nil {
-			http.Error(w, "failed to roll back move after state rebind failure", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to roll back move after state rebind failure")
 			return
 		}
-		http.Error(w, "failed to rebind resource state", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to rebind resource state")
 		return
 	}
 	if err := h.clearOverwrittenContactTombstone(r.Context(), destBookID, existingByName, destResourceName); err != nil {
 		if rollbackErr := h.rollbackContactMove(r.Context(), destBookID, destResourceName, *src, existingByName); rollbackErr != nil {
-			http.Error(w, "failed to roll back move after tombstone cleanup failure", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to roll back move after tombstone cleanup failure")
 			return
 		}
-		http.Error(w, "failed to finalize move", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to finalize move")
 		return
 	}
 
@@ -639,6 +736,7 @@ func (h *Handler) rollbackCalendarMove(ctx context.Context, currentCalendarID in
 	if err := h.store.Events.MoveToCalendar(ctx, currentCalendarID, src.CalendarID, src.UID, eventResourceName(src)); err != nil {
 		return err
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldEvent, collectionID: src.CalendarID, resourceName: eventResourceName(src)})
 	if replaced == nil {
 		return h.cleanupRollbackEventTombstones(ctx, currentCalendarID, currentResourceName, src, nil)
 	}
@@ -653,6 +751,7 @@ func (h *Handler) rollbackContactMove(ctx context.Context, currentAddressBookID
 	if err := h.store.Contacts.MoveToAddressBook(ctx, currentAddressBookID, src.AddressBookID, src.UID, contactResourceName(src)); err != nil {
 		return err
 	}
+	h.notFoundCache().forget(notFoundKey{resourceType: heldContact, collectionID: src.AddressBookID, resourceName: contactResourceName(src)})
 	if replaced == nil {
 		return h.cleanupRollbackContactTombstones(ctx, currentAddressBookID, currentResourceName, src, nil)
 	}
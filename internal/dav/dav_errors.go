@@ -0,0 +1,70 @@
+package dav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+)
+
+// davErrorMessageNS carries the human-readable message inside DAV:error bodies.
+// RFC 4918 §16 allows extension elements there; clients ignore what they don't know.
+const davErrorMessageNS = "urn:calcard:error"
+
+// davCondition names a precondition or postcondition element for a DAV:error body.
+type davCondition struct {
+	prefix string
+	name   string
+}
+
+var (
+	condNeedPrivileges               = davCondition{"D", "need-privileges"}
+	condValidSyncToken               = davCondition{"D", "valid-sync-token"}
+	condLockTokenSubmitted           = davCondition{"D", "lock-token-submitted"}
+	condLockTokenMatchesRequestURI   = davCondition{"D", "lock-token-matches-request-uri"}
+	condNoConflictingLock            = davCondition{"D", "no-conflicting-lock"}
+	condResourceMustBeNull           = davCondition{"D", "resource-must-be-null"}
+	condSupportedReport              = davCondition{"D", "supported-report"}
+	condRecognizedPrincipal          = davCondition{"D", "recognized-principal"}
+	condNotSupportedPrivilege        = davCondition{"D", "not-supported-privilege"}
+	condCalendarCollectionLocationOK = davCondition{"C", "calendar-collection-location-ok"}
+	condAddressBookLocationOK        = davCondition{"A", "addressbook-collection-location-ok"}
+	condAddressBookValidFilter       = davCondition{"A", "valid-filter"}
+)
+
+// writeDAVError writes an RFC 4918 DAV:error document with the given
+// condition elements and a calcard message element describing the failure.
+func writeDAVError(w http.ResponseWriter, status int, message string, conditions ...davCondition) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(buildDAVErrorXML(message, conditions)))
+}
+
+// writeDAVStatusError writes a DAV:error for a bare status, marking 403s as
+// privilege failures since that is the only way the callers produce them.
+func writeDAVStatusError(w http.ResponseWriter, status int) {
+	if status == http.StatusForbidden {
+		writeDAVError(w, status, http.StatusText(status), condNeedPrivileges)
+		return
+	}
+	writeDAVError(w, status, http.StatusText(status))
+}
+
+func buildDAVErrorXML(message string, conditions []davCondition) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<D:error xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:A="urn:ietf:params:xml:ns:carddav" xmlns:X="` + davErrorMessageNS + `">`)
+	for _, cond := range conditions {
+		if !isValidCalDAVCondition(cond.name) {
+			continue
+		}
+		b.WriteString("<" + cond.prefix + ":" + cond.name + "/>")
+	}
+	if message = strings.TrimSpace(message); message != "" {
+		b.WriteString("<X:message>")
+		_ = xml.EscapeText(&b, []byte(message))
+		b.WriteString("</X:message>")
+	}
+	b.WriteString("</D:error>")
+	return b.String()
+}
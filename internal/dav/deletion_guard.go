@@ -0,0 +1,153 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	heldEvent   = "event"
+	heldContact = "contact"
+)
+
+// deletionGuard detects a single client deleting a large share of a
+// collection in a short window, which is almost always a misbehaving client
+// or a broken local database rather than intent. Deletions past the
+// threshold are held for owner review instead of being tombstoned, so other
+// devices keep the data. A nil *deletionGuard holds nothing.
+type deletionGuard struct {
+	percent  int
+	window   time.Duration
+	minItems int
+	now      func() time.Time
+
+	mu     sync.Mutex
+	bursts map[deletionKey]*deletionBurst
+}
+
+// deletionKey identifies one client deleting from one collection.
+type deletionKey struct {
+	userID       int64
+	client       string
+	resourceType string
+	collectionID int64
+}
+
+// deletionBurst counts deletions against the collection size seen when the
+// burst started.
+type deletionBurst struct {
+	started  time.Time
+	baseline int
+	count    int
+}
+
+func newDeletionGuard(cfg *config.Config) *deletionGuard {
+	if cfg == nil || cfg.DAV.MassDeletePercent <= 0 || cfg.DAV.MassDeleteWindow <= 0 {
+		return nil
+	}
+	return &deletionGuard{
+		percent:  cfg.DAV.MassDeletePercent,
+		window:   cfg.DAV.MassDeleteWindow,
+		minItems: cfg.DAV.MassDeleteMinItems,
+		now:      time.Now,
+		bursts:   make(map[deletionKey]*deletionBurst),
+	}
+}
+
+// reconfigure applies new thresholds, keeping the bursts in progress.
+func (g *deletionGuard) reconfigure(cfg *config.Config) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.percent = cfg.DAV.MassDeletePercent
+	g.window = cfg.DAV.MassDeleteWindow
+	g.minItems = cfg.DAV.MassDeleteMinItems
+}
+
+// noteDeletion records one deletion and reports whether it pushes the burst
+// past the configured share of the collection. size is the number of
+// resources currently in the collection; it is only consulted when a new
+// burst starts.
+func (g *deletionGuard) noteDeletion(key deletionKey, size int) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for k, b := range g.bursts {
+		if now.Sub(b.started) > g.window {
+			delete(g.bursts, k)
+		}
+	}
+	b, ok := g.bursts[key]
+	if !ok {
+		b = &deletionBurst{started: now, baseline: size}
+		g.bursts[key] = b
+	}
+	b.count++
+	if b.baseline < g.minItems || b.baseline == 0 {
+		return false
+	}
+	return b.count*100 > b.baseline*g.percent
+}
+
+// holdDeletion decides whether a DELETE should be quarantined and records the
+// hold. It reports true when the caller should answer as if the resource was
+// deleted without removing it.
+func (h *Handler) holdDeletion(ctx context.Context, r *http.Request, user *store.User, resourceType string, collectionID, ownerID int64, uid, resourceName string) (bool, error) {
+	guard := h.deletionGuard()
+	if guard == nil || h.store == nil || h.store.HeldDeletions == nil {
+		return false, nil
+	}
+	held, err := h.store.HeldDeletions.IsHeld(ctx, resourceType, collectionID, uid)
+	if err != nil || held {
+		return held, err
+	}
+	size, err := h.collectionSize(ctx, resourceType, collectionID)
+	if err != nil {
+		return false, err
+	}
+	key := deletionKey{userID: user.ID, client: r.UserAgent(), resourceType: resourceType, collectionID: collectionID}
+	if !guard.noteDeletion(key, size) {
+		return false, nil
+	}
+	if err := h.store.HeldDeletions.Hold(ctx, store.HeldDeletion{
+		ResourceType: resourceType,
+		CollectionID: collectionID,
+		UID:          uid,
+		ResourceName: resourceName,
+		OwnerID:      ownerID,
+		DeletedBy:    user.ID,
+		Client:       r.UserAgent(),
+	}); err != nil {
+		return false, err
+	}
+	collection := "calendar"
+	if resourceType == heldContact {
+		collection = "addressbook"
+	}
+	metrics.IncDeletionHeld(r, collection)
+	h.logger().Warn("Delete", "holding deletion of %s %q in collection %d by user %d (%q) for owner review", resourceType, uid, collectionID, user.ID, r.UserAgent())
+	return true, nil
+}
+
+func (h *Handler) collectionSize(ctx context.Context, resourceType string, collectionID int64) (int, error) {
+	if resourceType == heldContact {
+		page, err := h.store.Contacts.ListForBookPaginated(ctx, collectionID, 1, 0)
+		if err != nil || page == nil {
+			return 0, err
+		}
+		return page.TotalCount, nil
+	}
+	page, err := h.store.Events.ListForCalendarPaginated(ctx, collectionID, 1, 0)
+	if err != nil || page == nil {
+		return 0, err
+	}
+	return page.TotalCount, nil
+}
@@ -0,0 +1,121 @@
+package dav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeHeldDeletionRepo struct {
+	held []store.HeldDeletion
+}
+
+func (f *fakeHeldDeletionRepo) Hold(ctx context.Context, held store.HeldDeletion) error {
+	f.held = append(f.held, held)
+	return nil
+}
+
+func (f *fakeHeldDeletionRepo) IsHeld(ctx context.Context, resourceType string, collectionID int64, uid string) (bool, error) {
+	for _, h := range f.held {
+		if h.ResourceType == resourceType && h.CollectionID == collectionID && h.UID == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeHeldDeletionRepo) ListByOwner(ctx context.Context, ownerID int64) ([]store.HeldDeletion, error) {
+	return f.held, nil
+}
+
+func (f *fakeHeldDeletionRepo) Remove(ctx context.Context, ownerID int64, ids []int64) error {
+	return nil
+}
+
+func TestNewDeletionGuardDisabled(t *testing.T) {
+	if newDeletionGuard(nil) != nil {
+		t.Fatal("expected nil guard without config")
+	}
+	cfg := &config.Config{}
+	cfg.DAV.MassDeleteWindow = time.Minute
+	if newDeletionGuard(cfg) != nil {
+		t.Fatal("expected nil guard with a zero percentage")
+	}
+}
+
+func TestDeletionGuardTripsPastShareOfBaseline(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := &deletionGuard{percent: 50, window: time.Minute, minItems: 4, now: func() time.Time { return now }, bursts: map[deletionKey]*deletionBurst{}}
+	key := deletionKey{userID: 1, client: "DAVx5", resourceType: heldEvent, collectionID: 2}
+
+	// The baseline is fixed when the burst starts, so later (smaller) sizes
+	// do not make the threshold easier to reach.
+	for i, want := range []bool{false, false, true} {
+		if got := g.noteDeletion(key, 4-i); got != want {
+			t.Fatalf("deletion %d held = %v, want %v", i+1, got, want)
+		}
+	}
+	if g.noteDeletion(deletionKey{userID: 1, client: "Thunderbird", resourceType: heldEvent, collectionID: 2}, 2) {
+		t.Fatal("expected another client to start its own burst")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if g.noteDeletion(key, 2) {
+		t.Fatal("expected the burst to reset after the window, and small collections to be exempt")
+	}
+}
+
+func TestDeleteHoldsMassDeletionForOwnerReview(t *testing.T) {
+	events := map[string]*store.Event{}
+	for i := 0; i < 4; i++ {
+		uid := fmt.Sprintf("e%d", i)
+		events["2:"+uid] = &store.Event{CalendarID: 2, UID: uid, ResourceName: uid, ETag: "x"}
+	}
+	eventRepo := &storetest.Events{Events: events}
+	heldRepo := &fakeHeldDeletionRepo{}
+	calRepo := &storetest.Calendars{Calendars: map[int64]*store.Calendar{2: {ID: 2, UserID: 1, Name: "Work"}}}
+	cfg := &config.Config{}
+	cfg.DAV.MassDeletePercent = 50
+	cfg.DAV.MassDeleteWindow = time.Minute
+	cfg.DAV.MassDeleteMinItems = 4
+	h := &Handler{
+		store:     &store.Store{Calendars: calRepo, Events: eventRepo, HeldDeletions: heldRepo},
+		deletions: newDeletionGuard(cfg),
+	}
+	user := &store.User{ID: 1}
+
+	del := func(uid string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/dav/calendars/2/"+uid+".ics", nil)
+		req.Header.Set("User-Agent", "DAVx5/4.3")
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		h.Delete(rr, req)
+		return rr.Code
+	}
+
+	for _, uid := range []string{"e0", "e1", "e2", "e2"} {
+		if code := del(uid); code != http.StatusNoContent {
+			t.Fatalf("DELETE %s = %d, want 204", uid, code)
+		}
+	}
+	if len(eventRepo.Deleted) != 2 {
+		t.Fatalf("expected the first two deletions applied, got %v", eventRepo.Deleted)
+	}
+	if _, ok := events["2:e2"]; !ok {
+		t.Fatal("expected the held event to stay in the calendar")
+	}
+	if len(heldRepo.held) != 1 {
+		t.Fatalf("expected one hold despite the repeated DELETE, got %+v", heldRepo.held)
+	}
+	if got := heldRepo.held[0]; got.UID != "e2" || got.OwnerID != 1 || got.DeletedBy != 1 || got.Client != "DAVx5/4.3" || got.ResourceType != heldEvent {
+		t.Fatalf("unexpected hold %+v", got)
+	}
+}
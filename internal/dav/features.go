@@ -0,0 +1,163 @@
+package dav
+
+import (
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+// featureScope says where a feature's DAV compliance class is advertised.
+type featureScope int
+
+const (
+	// scopeEverywhere classes apply to every DAV path, including collections
+	// registered by extensions.
+	scopeEverywhere featureScope = iota
+	// scopeServer classes apply to the DAV root and the built-in collections.
+	scopeServer
+	// scopeCollections classes apply to the built-in collections only.
+	scopeCollections
+)
+
+// Feature is one row of the server's compliance matrix.
+type Feature struct {
+	Name      string `json:"name"`
+	Spec      string `json:"spec"`
+	Supported bool   `json:"supported"`
+	// DAVClass is the compliance class advertised in the DAV response
+	// header, if the feature has one.
+	DAVClass string `json:"davClass,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+
+	scope featureScope
+}
+
+// Limits are the sizes and ranges the server enforces.
+type Limits struct {
+	MaxResourceSize         int64  `json:"maxResourceSize"`
+	MaxAttendeesPerInstance int    `json:"maxAttendeesPerInstance"`
+	MaxInstances            int    `json:"maxInstances"`
+	MinDateTime             string `json:"minDateTime"`
+	MaxDateTime             string `json:"maxDateTime"`
+	// SyncPageSize is the number of members per sync-collection page once
+	// an account is paged for resyncing too often.
+	SyncPageSize int `json:"syncPageSize"`
+}
+
+// Info describes what this deployment supports. The DAV and Allow headers
+// are generated from the same tables.
+type Info struct {
+	Features   []Feature `json:"features"`
+	Methods    []string  `json:"methods"`
+	Reports    []string  `json:"reports"`
+	Collations []string  `json:"collations"`
+	Limits     Limits    `json:"limits"`
+}
+
+// davFeatures lists the protocol features, with those that have DAV
+// compliance classes first in the order the DAV header lists them.
+var davFeatures = []Feature{
+	{Name: "webdav", Spec: "RFC 4918", Supported: true, DAVClass: "1"},
+	{Name: "locking", Spec: "RFC 4918", Supported: true, DAVClass: "2"},
+	{Name: "webdav-rfc4918", Spec: "RFC 4918", Supported: true, DAVClass: "3"},
+	{Name: "acl", Spec: "RFC 3744", Supported: true, DAVClass: "access-control"},
+	{Name: "caldav", Spec: "RFC 4791", Supported: true, DAVClass: "calendar-access", scope: scopeServer},
+	{Name: "carddav", Spec: "RFC 6352", Supported: true, DAVClass: "addressbook", scope: scopeServer},
+	{Name: "extended-mkcol", Spec: "RFC 5689", Supported: true, DAVClass: "extended-mkcol", scope: scopeCollections},
+	{Name: "calendar-no-timezone", Spec: "RFC 7809", Supported: true, DAVClass: "calendar-no-timezone", scope: scopeCollections, Notes: "CalDAV-Timezones: F leaves out VTIMEZONEs the time zone database defines"},
+	{Name: "current-user-principal", Spec: "RFC 5397", Supported: true},
+	{Name: "sync-collection", Spec: "RFC 6578", Supported: true},
+	{Name: "add-member", Spec: "RFC 5995", Supported: true, Notes: "POST to a calendar or address book's DAV:add-member URL"},
+	{Name: "expand-property", Spec: "RFC 3253", Supported: true},
+	{Name: "free-busy-query", Spec: "RFC 4791", Supported: true},
+	{Name: "calendar-properties", Spec: "RFC 7986", Supported: true, Notes: "COLOR, IMAGE and CONFERENCE on events"},
+	{Name: "valarm-extensions", Spec: "RFC 9074", Supported: true, Notes: "alarm UID, ACKNOWLEDGED, snooze and PROXIMITY alarms; dismiss and snooze over the JSON API"},
+	{Name: "caldav-expand", Spec: "RFC 4791", Supported: false, Notes: "calendar-data expand is not applied; recurring events are returned with their rules"},
+	{Name: "caldav-scheduling", Spec: "RFC 6638", Supported: false, Notes: "no scheduling inbox or outbox; see imip"},
+}
+
+// coreReports are the REPORTs the built-in collections answer.
+var coreReports = []string{
+	"calendar-query", "calendar-multiget", "free-busy-query",
+	"addressbook-query", "addressbook-multiget",
+	"sync-collection", "expand-property",
+}
+
+// supportedCollations are the text-match collations CardDAV and CalDAV
+// queries accept.
+var supportedCollations = []string{"i;ascii-casemap", "i;unicode-casemap"}
+
+// Info returns the compliance matrix for this deployment.
+func (h *Handler) Info() Info {
+	info := Info{
+		Features:   serverFeatures(h.cfg),
+		Methods:    append([]string(nil), davAllowMethodsWithCopyMove...),
+		Reports:    append([]string(nil), coreReports...),
+		Collations: append([]string(nil), supportedCollations...),
+		Limits: Limits{
+			MaxResourceSize:         maxDAVBodyBytes,
+			MaxAttendeesPerInstance: caldavMaxAttendees,
+			MaxInstances:            caldavMaxInstances,
+			MinDateTime:             caldavMinDateTime,
+			MaxDateTime:             caldavMaxDateTime,
+			SyncPageSize:            h.limits.syncPageSize(),
+		},
+	}
+	seen := make(map[string]bool, len(info.Methods))
+	for _, method := range info.Methods {
+		seen[method] = true
+	}
+	for _, method := range h.RegisteredMethods() {
+		if !seen[method] {
+			seen[method] = true
+			info.Methods = append(info.Methods, method)
+		}
+	}
+	return info
+}
+
+// serverFeatures adds the features that depend on configuration to
+// davFeatures.
+func serverFeatures(cfg *config.Config) []Feature {
+	features := append([]Feature(nil), davFeatures...)
+	imip := Feature{Name: "imip", Spec: "RFC 6047", Notes: "invitations, replies and booking confirmations by email"}
+	activeSync := Feature{Name: "activesync", Spec: "MS-ASHTTP", Notes: "read-only"}
+	if cfg != nil {
+		imip.Supported = strings.TrimSpace(cfg.SMTP.Host) != ""
+		activeSync.Supported = cfg.ActiveSyncEnabled
+		for i := range features {
+			switch {
+			case (features[i].Name == "caldav" || features[i].Name == "calendar-no-timezone") && cfg.CalDAVDisabled:
+				features[i].Supported, features[i].Notes = false, "APP_CALDAV_ENABLED is false"
+			case features[i].Name == "carddav" && cfg.CardDAVDisabled:
+				features[i].Supported, features[i].Notes = false, "APP_CARDDAV_ENABLED is false"
+			}
+		}
+	}
+	if !imip.Supported {
+		imip.Notes = "APP_SMTP_HOST is not set"
+	}
+	return append(features, imip, activeSync)
+}
+
+// davClasses returns the DAV header value for a path in the given scope.
+func davClasses(cfg *config.Config, scope featureScope) string {
+	var classes []string
+	for _, f := range serverFeatures(cfg) {
+		if f.Supported && f.DAVClass != "" && f.scope <= scope {
+			classes = append(classes, f.DAVClass)
+		}
+	}
+	return strings.Join(classes, ", ")
+}
+
+// calDAVEnabled reports whether calendar collections are served. A handler
+// without configuration serves both halves.
+func (h *Handler) calDAVEnabled() bool {
+	return h == nil || h.cfg == nil || !h.cfg.CalDAVDisabled
+}
+
+// cardDAVEnabled reports whether address book collections are served.
+func (h *Handler) cardDAVEnabled() bool {
+	return h == nil || h.cfg == nil || !h.cfg.CardDAVDisabled
+}
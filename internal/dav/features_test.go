@@ -0,0 +1,46 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+func TestInfoAgreesWithOptionsHeaders(t *testing.T) {
+	h := NewServer(Options{Config: &config.Config{}})
+	info := h.Info()
+
+	classes := map[string]bool{}
+	for _, f := range info.Features {
+		if f.DAVClass != "" {
+			classes[f.DAVClass] = f.Supported
+		}
+	}
+	rr := httptest.NewRecorder()
+	h.Options(rr, httptest.NewRequest(http.MethodOptions, "/dav/calendars/1/", nil))
+	for _, class := range strings.Split(rr.Header().Get("DAV"), ", ") {
+		if !classes[class] {
+			t.Errorf("DAV header class %q is not a supported feature", class)
+		}
+	}
+	for _, method := range strings.Split(rr.Header().Get("Allow"), ", ") {
+		found := false
+		for _, m := range info.Methods {
+			found = found || m == method
+		}
+		if !found {
+			t.Errorf("Allow method %s missing from Info().Methods", method)
+		}
+	}
+	if info.Limits.MaxResourceSize != maxDAVBodyBytes || info.Limits.SyncPageSize != defaultSyncPageSize {
+		t.Fatalf("limits = %+v", info.Limits)
+	}
+	for _, f := range info.Features {
+		if f.Name == "imip" && f.Supported {
+			t.Fatal("imip reported without SMTP configured")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package dav
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/icalgen"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// addClientSeeds seeds f with the payloads real clients sent, kept in
+// testdata/clients, matching pattern. Text payloads are added with both LF
+// and CRLF line endings since clients send either.
+func addClientSeeds(f *testing.F, pattern string) []string {
+	f.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "clients", pattern))
+	if err != nil || len(paths) == 0 {
+		f.Fatalf("no seeds match %s: %v", pattern, err)
+	}
+	var seeds []string
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seed := string(data)
+		seeds = append(seeds, seed, strings.ReplaceAll(seed, "\n", "\r\n"))
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	return seeds
+}
+
+func FuzzValidateICalendar(f *testing.F) {
+	addClientSeeds(f, "*.ics")
+	for seed := int64(1); seed <= 3; seed++ {
+		for _, obj := range icalgen.Objects(icalgen.Options{Seed: seed, Events: 5}) {
+			f.Add(obj.RawICAL)
+		}
+	}
+	h := &Handler{}
+	f.Fuzz(func(t *testing.T, data string) {
+		if err := h.validateICalendar(data); err != nil {
+			return
+		}
+		validateCalendarObjectResource(data)
+		uid, err := extractUIDFromICalendar(data)
+		if err == nil && (uid == "" || uid != strings.TrimSpace(uid)) {
+			t.Fatalf("extractUIDFromICalendar returned unusable UID %q", uid)
+		}
+	})
+}
+
+func FuzzExtractUIDFromVCard(f *testing.F) {
+	addClientSeeds(f, "*.vcf")
+	h := &Handler{}
+	f.Fuzz(func(t *testing.T, data string) {
+		validErr := h.validateVCard(data)
+		uid, err := extractUIDFromVCard(data)
+		if err != nil {
+			return
+		}
+		if uid == "" || uid != strings.TrimSpace(uid) {
+			t.Fatalf("extractUIDFromVCard returned unusable UID %q", uid)
+		}
+		if validErr == nil && strings.ContainsAny(uid, "\r\n") {
+			t.Fatalf("UID of a valid vCard spans lines: %q", uid)
+		}
+	})
+}
+
+func FuzzRecurringEventInTimeRange(f *testing.F) {
+	for _, seed := range addClientSeeds(f, "*.ics") {
+		if rule := extractRRule(seed); rule != "" {
+			f.Add(strings.TrimSpace(rule))
+		}
+	}
+	for _, rule := range []string{
+		"FREQ=DAILY;COUNT=2147483647",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=MONTHLY;INTERVAL=-1;UNTIL=20240101",
+		"FREQ=YEARLY;UNTIL=99991231T235959Z",
+		"FREQ=SECONDLY",
+		"",
+	} {
+		f.Add(rule)
+	}
+	h := &Handler{}
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	f.Fuzz(func(t *testing.T, rule string) {
+		event := store.Event{
+			RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:fuzz\r\nRRULE:" + rule + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			DTStart: &start,
+			DTEnd:   &end,
+		}
+		// The first occurrence is always the event itself.
+		if !h.recurringEventInTimeRange(event, start, end) {
+			t.Fatalf("RRULE %q: first occurrence not found in its own time range", rule)
+		}
+		h.recurringEventInTimeRange(event, start.AddDate(1, 0, 0), start.AddDate(1, 1, 0))
+	})
+}
+
+func FuzzReportRequestXML(f *testing.F) {
+	addClientSeeds(f, "*.xml")
+	h := &Handler{}
+	events := icalgen.Events(icalgen.Options{Seed: 1, Events: 20, CalendarID: 1})
+	f.Fuzz(func(t *testing.T, body string) {
+		var report reportRequest
+		if err := safeUnmarshalXML([]byte(body), &report); err != nil {
+			return
+		}
+		if report.SyncToken != "" {
+			parseSyncToken(report.SyncToken)
+		}
+		if report.Filter != nil {
+			h.applyCalendarFilter(events, report.Filter)
+		}
+		if report.CardFilter != nil {
+			validateCardFilter(report.CardFilter)
+		}
+		if report.AddressData != nil {
+			validateAddressDataRequest(report.AddressData)
+		}
+		if report.XMLName.Local == "expand-property" {
+			if req, err := parseExpandPropertyRequest([]byte(body)); err == nil && req != nil {
+				expandPropertySelections(req)
+			}
+		}
+	})
+}
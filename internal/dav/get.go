@@ -3,8 +3,10 @@ package dav
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/jw6ventures/calcard/internal/auth"
@@ -18,39 +20,44 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Get", "handling GET %s", r.URL.Path)
 	cleanPath := path.Clean(r.URL.Path)
 	if !strings.HasPrefix(cleanPath, "/dav") {
-		http.Error(w, "not found", http.StatusNotFound)
+		writeDAVError(w, http.StatusNotFound, "not found")
 		return
 	}
 	if strings.HasPrefix(cleanPath, "/dav/addressbooks/") {
 		trimmed := strings.Trim(strings.TrimPrefix(cleanPath, "/dav/addressbooks"), "/")
 		if trimmed != "" && len(strings.Split(trimmed, "/")) > 2 {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 	}
 
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
+		return
+	}
+
+	if strings.HasPrefix(cleanPath, contactPhotoPathPrefix) {
+		h.getContactPhoto(w, r, user, cleanPath)
 		return
 	}
 
 	if calendarID, uid, matched, err := h.parseCalendarResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous calendar path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous calendar path")
 			return
 		}
-		http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load calendar")
 		return
 	} else if matched {
 		if calendarID == birthdayCalendarID {
 			events, err := h.generateBirthdayEvents(r.Context(), user.ID)
 			if err != nil {
-				http.Error(w, "failed to load birthday events", http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, "failed to load birthday events")
 				return
 			}
 
@@ -62,7 +69,7 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 			if event == nil {
-				http.Error(w, "not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "not found")
 				return
 			}
 			w.Header().Set("Content-Type", "text/calendar")
@@ -74,36 +81,44 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		notFound := h.notFoundCache()
+		missKey := notFoundKey{resourceType: heldEvent, collectionID: calendarID, resourceName: uid}
+		if notFound.missed(user.ID, missKey) {
+			writeDAVError(w, http.StatusNotFound, "not found")
+			return
+		}
+
 		cal, err := h.loadCalendarWithPrivilege(r.Context(), user, calendarID, cleanPath, "read")
 		if err != nil {
 			if err == store.ErrNotFound {
-				http.Error(w, "not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "not found")
 				return
 			}
 			if errors.Is(err, errForbidden) {
-				http.Error(w, "not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "not found")
 				return
 			}
-			http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load calendar")
 			return
 		}
-		event, err := h.store.Events.GetByResourceName(r.Context(), calendarID, uid)
+		event, body, err := h.store.Events.OpenByResourceName(r.Context(), calendarID, uid)
 		if err != nil {
 			h.logger().Error("Get", "failed to load event %q from calendar %d: %v", uid, calendarID, err)
-			http.Error(w, "failed to load event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load event")
 			return
 		}
 		if event == nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			notFound.remember(user.ID, missKey)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		allowed, err := h.canReadCalendarObject(r.Context(), user, cal, uid)
 		if err != nil {
-			http.Error(w, "failed to evaluate calendar access", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to evaluate calendar access")
 			return
 		}
 		if !allowed {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		w.Header().Set("Content-Type", "text/calendar")
@@ -111,32 +126,47 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		if !event.LastModified.IsZero() {
 			w.Header().Set("Last-Modified", event.LastModified.UTC().Format(http.TimeFormat))
 		}
-		_, _ = w.Write([]byte(event.RawICAL))
+		// A large body is read from the database as it is written; the
+		// length lets a client tell a read cut short by a concurrent
+		// update from a complete one.
+		w.Header().Set("Content-Length", strconv.FormatInt(body.Size, 10))
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			h.logger().Warn("Get", "failed to stream event %q from calendar %d: %v", uid, calendarID, err)
+		}
 		return
 	}
 
 	if addressBookID, resourceName, matched, err := h.parseAddressBookResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) {
-			http.Error(w, "ambiguous address book path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous address book path")
 			return
 		}
-		http.Error(w, "failed to load address book", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
 		return
 	} else if matched {
+		missKey := notFoundKey{resourceType: heldContact, collectionID: addressBookID, resourceName: resourceName}
+		if h.notFoundCache().missed(user.ID, missKey) {
+			writeDAVError(w, http.StatusNotFound, "not found")
+			return
+		}
 		if _, err := h.loadAddressBookWithPrivilege(r.Context(), user, addressBookID, cleanPath, "read"); err != nil {
 			if err == store.ErrNotFound {
-				http.Error(w, "not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "not found")
 				return
 			}
 			if errors.Is(err, errForbidden) {
-				http.Error(w, "forbidden", http.StatusForbidden)
+				writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
 				return
 			}
-			http.Error(w, "failed to load address book", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
 			return
 		}
 		h.writeAddressBookContact(w, r, addressBookID, resourceName)
@@ -151,11 +181,14 @@ func (h *Handler) writeAddressBookContact(w http.ResponseWriter, r *http.Request
 	contact, err := h.store.Contacts.GetByResourceName(r.Context(), addressBookID, resourceName)
 	if err != nil {
 		h.logger().Error("writeAddressBookContact", "failed to load contact %q from address book %d: %v", resourceName, addressBookID, err)
-		http.Error(w, "failed to load contact", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load contact")
 		return
 	}
 	if contact == nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			h.notFoundCache().remember(user.ID, notFoundKey{resourceType: heldContact, collectionID: addressBookID, resourceName: resourceName})
+		}
+		writeDAVError(w, http.StatusNotFound, "not found")
 		return
 	}
 	if !acceptsVCardData(contact.RawVCard, r.Header.Get("Accept")) {
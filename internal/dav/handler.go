@@ -3,6 +3,7 @@ package dav
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -15,8 +16,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/scan"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
 	"github.com/lib/pq"
 )
 
@@ -38,7 +46,7 @@ func (h *Handler) Proppatch(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Proppatch", "PROPPATCH %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -51,16 +59,16 @@ func (h *Handler) Proppatch(w http.ResponseWriter, r *http.Request) {
 	body, err := readDAVBody(w, r, maxDAVBodyBytes)
 	if err != nil {
 		if errors.Is(err, errRequestTooLarge) {
-			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 		} else {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
 		}
 		return
 	}
 
 	var proppatchReq proppatchRequest
 	if err := safeUnmarshalXML(body, &proppatchReq); err != nil {
-		http.Error(w, "invalid PROPPATCH body", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid PROPPATCH body")
 		return
 	}
 
@@ -71,9 +79,9 @@ func (h *Handler) Proppatch(w http.ResponseWriter, r *http.Request) {
 		resp, err := h.proppatchCalendar(r.Context(), user, cleanPath, &proppatchReq)
 		if err != nil {
 			if errors.Is(err, errInvalidPath) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, err.Error())
 			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, err.Error())
 			}
 			return
 		}
@@ -82,15 +90,15 @@ func (h *Handler) Proppatch(w http.ResponseWriter, r *http.Request) {
 		resp, err := h.proppatchAddressBook(r.Context(), user, cleanPath, &proppatchReq)
 		if err != nil {
 			if errors.Is(err, errInvalidPath) {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, err.Error())
 			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, err.Error())
 			}
 			return
 		}
 		responses = append(responses, resp...)
 	} else {
-		http.Error(w, "unsupported path for PROPPATCH", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "unsupported path for PROPPATCH")
 		return
 	}
 
@@ -281,6 +289,10 @@ func (h *Handler) proppatchAddressBook(ctx context.Context, user *store.User, cl
 			protectedProp.AddressBookMaxResourceSize = fmt.Sprintf("%d", maxDAVBodyBytes)
 			hasProtected = true
 		}
+		if req.Set.Prop.AddressBookMaxImageSize != nil {
+			protectedProp.AddressBookMaxImageSize = fmt.Sprintf("%d", maxContactPhotoBytes)
+			hasProtected = true
+		}
 		if req.Set.Prop.SupportedCollationSet != nil {
 			protectedProp.SupportedCollationSet = supportedCollationSetProp()
 			hasProtected = true
@@ -352,7 +364,7 @@ func (h *Handler) Mkcol(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Mkcol", "MKCOL %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -362,25 +374,25 @@ func (h *Handler) Mkcol(w http.ResponseWriter, r *http.Request) {
 	}
 	pendingLockPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 	if err != nil {
-		http.Error(w, "failed to resolve collection path", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve collection path")
 		return
 	}
 	if !strings.HasPrefix(cleanPath, "/dav/addressbooks/") {
-		http.Error(w, "unsupported path", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "unsupported path")
 		return
 	}
 	parts := strings.Split(strings.TrimPrefix(cleanPath, "/dav/addressbooks"), "/")
 	if len(parts) > 2 || (len(parts) == 2 && parts[0] != "" && parts[1] != "") {
-		http.Error(w, "nested address book collections not allowed", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "nested address book collections not allowed", condAddressBookLocationOK)
 		return
 	}
 	name := strings.TrimSpace(parts[len(parts)-1])
 	if name == "" {
-		http.Error(w, "collection name required", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "collection name required")
 		return
 	}
 	if _, err := strconv.ParseInt(name, 10, 64); err == nil {
-		http.Error(w, "collection name must be non-numeric", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "collection name must be non-numeric")
 		return
 	}
 	description := (*string)(nil)
@@ -388,16 +400,16 @@ func (h *Handler) Mkcol(w http.ResponseWriter, r *http.Request) {
 		body, err := readDAVBody(w, r, maxDAVBodyBytes)
 		if err != nil {
 			if errors.Is(err, errRequestTooLarge) {
-				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 			} else {
-				http.Error(w, "failed to read body", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "failed to read body")
 			}
 			return
 		}
 		var mkReq mkcalendarRequest
 		if len(body) > 0 {
 			if err := safeUnmarshalXML(body, &mkReq); err != nil {
-				http.Error(w, "invalid MKCOL body", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "invalid MKCOL body")
 				return
 			}
 			if mkReq.Set != nil {
@@ -409,16 +421,16 @@ func (h *Handler) Mkcol(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if _, err := strconv.ParseInt(name, 10, 64); err == nil {
-		http.Error(w, "collection name must be non-numeric", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "collection name must be non-numeric")
 		return
 	}
 	created, err := h.store.AddressBooks.Create(r.Context(), store.AddressBook{UserID: user.ID, Name: name, Description: description})
 	if err != nil {
 		if errors.Is(err, store.ErrConflict) {
-			http.Error(w, "address book already exists", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "address book already exists", condResourceMustBeNull)
 			return
 		}
-		http.Error(w, "failed to create", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to create")
 		return
 	}
 	if created != nil {
@@ -427,7 +439,7 @@ func (h *Handler) Mkcol(w http.ResponseWriter, r *http.Request) {
 			if deleteErr := h.store.AddressBooks.Delete(r.Context(), user.ID, created.ID); deleteErr != nil && !errors.Is(deleteErr, store.ErrNotFound) {
 				log.Printf("failed to roll back address book %d after lock rebind failure: %v", created.ID, deleteErr)
 			}
-			http.Error(w, "failed to rebind collection locks", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to rebind collection locks")
 			return
 		}
 		w.Header().Set("Location", location)
@@ -442,7 +454,7 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Mkcalendar", "MKCALENDAR %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -452,27 +464,27 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 	}
 	pendingLockPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 	if err != nil {
-		http.Error(w, "failed to resolve collection path", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve collection path")
 		return
 	}
 	if !strings.HasPrefix(cleanPath, "/dav/calendars/") {
-		http.Error(w, "unsupported path", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "unsupported path")
 		return
 	}
 	parts := strings.Split(strings.TrimPrefix(cleanPath, "/dav/calendars"), "/")
 
 	if len(parts) > 2 || (len(parts) == 2 && parts[0] != "" && parts[1] != "") {
-		http.Error(w, "nested calendar collections not allowed", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "nested calendar collections not allowed", condCalendarCollectionLocationOK)
 		return
 	}
 
 	pathName := strings.TrimSpace(parts[len(parts)-1])
 	if pathName == "" {
-		http.Error(w, "calendar name required", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "calendar name required")
 		return
 	}
 	if _, err := strconv.ParseInt(pathName, 10, 64); err == nil {
-		http.Error(w, "calendar name must be non-numeric", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "calendar name must be non-numeric")
 		return
 	}
 
@@ -481,14 +493,14 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 		body, err := readDAVBody(w, r, maxDAVBodyBytes)
 		if err != nil {
 			if errors.Is(err, errRequestTooLarge) {
-				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 			} else {
-				http.Error(w, "failed to read body", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "failed to read body")
 			}
 			return
 		}
 		if err := safeUnmarshalXML(body, &mkReq); err != nil {
-			http.Error(w, "invalid MKCALENDAR body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid MKCALENDAR body")
 			return
 		}
 	}
@@ -509,7 +521,7 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 		if mkReq.Set.Prop.CalendarColor != nil {
 			color, err = store.NormalizeCalendarColor(*mkReq.Set.Prop.CalendarColor)
 			if err != nil {
-				http.Error(w, "invalid calendar color", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "invalid calendar color")
 				return
 			}
 		}
@@ -517,18 +529,18 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 
 	cals, err := h.store.Calendars.ListAccessible(r.Context(), user.ID)
 	if err != nil {
-		http.Error(w, "failed to check calendars", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to check calendars")
 		return
 	}
 	// Normalize slug for consistent case-insensitive comparison
 	normalizedPathName := strings.ToLower(pathName)
 	for _, cal := range cals {
 		if cal.Slug != nil && *cal.Slug == normalizedPathName {
-			http.Error(w, "calendar already exists", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "calendar already exists", condResourceMustBeNull)
 			return
 		}
 		if strings.EqualFold(cal.Name, pathName) {
-			http.Error(w, "calendar already exists", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "calendar already exists", condResourceMustBeNull)
 			return
 		}
 	}
@@ -536,7 +548,7 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 	slug := normalizedPathName
 	// Validate slug for path safety (prevent path traversal, injection)
 	if !isValidCalendarSlug(slug) {
-		http.Error(w, "invalid calendar name: must contain only lowercase letters, numbers, and hyphens", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid calendar name: must contain only lowercase letters, numbers, and hyphens")
 		return
 	}
 	created, err := h.store.Calendars.Create(r.Context(), store.Calendar{
@@ -550,18 +562,20 @@ func (h *Handler) Mkcalendar(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
-			http.Error(w, "calendar already exists", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "calendar already exists", condResourceMustBeNull)
 			return
 		}
-		http.Error(w, "failed to create", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to create")
 		return
 	}
-	location := path.Join("/dav/calendars", fmt.Sprint(created.ID)) + "/"
-	if err := h.rebindCollectionLocks(r.Context(), pendingLockPath, strings.TrimSuffix(location, "/")); err != nil {
+	// The calendar keeps the path the client created it under; its ID path is
+	// an alias.
+	location := calendarCollectionHref(user, created) + "/"
+	if err := h.rebindCollectionLocks(r.Context(), pendingLockPath, calendarCollectionResourcePath(created.ID)); err != nil {
 		if deleteErr := h.store.Calendars.Delete(r.Context(), user.ID, created.ID); deleteErr != nil && !errors.Is(deleteErr, store.ErrNotFound) {
 			log.Printf("failed to roll back calendar %d after lock rebind failure: %v", created.ID, deleteErr)
 		}
-		http.Error(w, "failed to rebind collection locks", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to rebind collection locks")
 		return
 	}
 	w.Header().Set("Location", location)
@@ -962,7 +976,8 @@ func parseCalendarTopLevelComponents(icalData string) []calendarTopLevelComponen
 			}
 			continue
 		}
-		if current == nil {
+		// Sub-components such as VALARM may carry their own UID (RFC 9074).
+		if current == nil || len(stack) != 2 {
 			continue
 		}
 		if strings.HasPrefix(upper, "UID") {
@@ -1172,7 +1187,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Put", "PUT %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -1181,24 +1196,38 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if cleanPath == "/dav/calendars" || cleanPath == "/dav/calendars/" {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "forbidden")
 		return
 	}
 	if strings.HasPrefix(cleanPath, "/dav/calendars/") {
 		if _, _, ok := parseCalendarResourceSegments(cleanPath); !ok {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "forbidden")
 			return
 		}
 	}
 	_, _, isCalendar := parseCalendarResourceSegments(cleanPath)
 	_, _, isAddressBook := parseAddressBookResourceSegments(cleanPath)
+	if isCalendar || isAddressBook {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		w = ww
+		defer func() {
+			collection := "calendar"
+			if isAddressBook {
+				collection = "addressbook"
+			}
+			switch ww.Status() {
+			case http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+				metrics.IncPutValidationFailure(r, collection, ww.Status())
+			}
+		}()
+	}
 	if r.ContentLength > maxDAVBodyBytes {
 		if isCalendar {
 			writeCalDAVError(w, http.StatusRequestEntityTooLarge, "max-resource-size")
 		} else if isAddressBook {
 			writeCardDAVPrecondition(w, http.StatusRequestEntityTooLarge, "max-resource-size")
 		} else {
-			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 		}
 		return
 	}
@@ -1212,35 +1241,62 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			} else if isAddressBook {
 				writeCardDAVPrecondition(w, http.StatusRequestEntityTooLarge, "max-resource-size")
 			} else {
-				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 			}
 		} else {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
 		}
 		return
 	}
-	etag := fmt.Sprintf("%x", sha256.Sum256(body))
+	etag := utils.GenerateETag(string(body))
 
 	if calendarID, resourceUID, matched, err := h.parseCalendarResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "calendar not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "calendar not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous calendar path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous calendar path")
 			return
 		}
-		http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load calendar")
 		return
 	} else if matched {
 		if calendarID == birthdayCalendarID {
-			http.Error(w, "birthday calendar is read-only", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "birthday calendar is read-only", condNeedPrivileges)
 			return
 		}
 
+		// Exchange/Outlook payloads are normalized before validation. When the
+		// stored body differs from what was sent, no ETag is returned so the
+		// client refetches (RFC 4791 §5.3.4).
+		bodyRewritten := false
+		var repairs []string
+		if sanitized := utils.SanitizeText(string(body)); sanitized != string(body) {
+			h.logger().Debug("Put", "stripped characters XML cannot carry from %s", cleanPath)
+			body = []byte(sanitized)
+			etag = utils.GenerateETag(string(body))
+			bodyRewritten = true
+			repairs = append(repairs, utils.RepairCharacters)
+		}
+		if normalized := utils.NormalizeExchangeICal(string(body)); normalized != string(body) {
+			body = []byte(normalized)
+			etag = utils.GenerateETag(string(body))
+			bodyRewritten = true
+			repairs = append(repairs, utils.RepairExchange)
+		}
+		if h.featureFlag(r.Context(), user, config.FeatureCalDAVRepair) {
+			if repaired, made := utils.RepairICal(string(body)); len(made) > 0 {
+				body = []byte(repaired)
+				etag = utils.GenerateETag(repaired)
+				bodyRewritten = true
+				repairs = append(repairs, made...)
+			}
+		}
+
 		existingByResource, err := h.store.Events.GetByResourceName(r.Context(), calendarID, resourceUID)
 		if err != nil {
-			http.Error(w, "failed to load event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load event")
 			return
 		}
 		requiredPrivilege := "bind"
@@ -1256,7 +1312,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			if errors.Is(err, errForbidden) {
 				status = http.StatusForbidden
 			}
-			http.Error(w, http.StatusText(status), status)
+			writeDAVStatusError(w, status)
 			return
 		}
 
@@ -1274,6 +1330,37 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			writeCalDAVError(w, http.StatusBadRequest, "valid-calendar-data")
 			return
 		}
+		if !h.allowScanned(w, "Put", cleanPath, scan.ICalAttachments(r.Context(), h.store.Scanner, string(body))) {
+			return
+		}
+
+		// iTIP messages, such as invitations dragged into a client, are
+		// stripped or applied when configured; otherwise the METHOD check
+		// below rejects them.
+		if method := events.Method(string(body)); method != "" && h.cfg != nil {
+			rewritten := string(body)
+			var err error
+			switch h.cfg.CalDAVMethod {
+			case config.CalDAVMethodStrip:
+				rewritten = events.StripMethod(rewritten)
+			case config.CalDAVMethodProcess:
+				rewritten, err = events.NewService(h.store).ProcessSchedulingMessage(r.Context(), calendarID, rewritten)
+			}
+			if errors.Is(err, events.ErrConflict) {
+				writeCalDAVError(w, http.StatusConflict, "valid-calendar-object-resource")
+				return
+			}
+			if err != nil {
+				h.logger().Error("Put", "failed to process %s message in calendar %d: %v", method, calendarID, err)
+				writeDAVError(w, http.StatusInternalServerError, "failed to process scheduling message")
+				return
+			}
+			if rewritten != string(body) {
+				body = []byte(rewritten)
+				etag = utils.GenerateETag(rewritten)
+				bodyRewritten = true
+			}
+		}
 
 		componentTypes := extractICalComponentTypes(string(body))
 		allowedComponents := map[string]struct{}{
@@ -1354,7 +1441,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 
 		existingByResource, err = h.store.Events.GetByResourceName(r.Context(), calendarID, resourceName)
 		if err != nil {
-			http.Error(w, "failed to load event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load event")
 			return
 		}
 		if existingByResource == nil && !h.requireLock(w, r, path.Dir(cleanPath), "resource is locked") {
@@ -1368,7 +1455,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 
 		existing, err := h.store.Events.GetByUID(r.Context(), calendarID, uid)
 		if err != nil {
-			http.Error(w, "failed to load event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load event")
 			return
 		}
 		if existing != nil && existing.ResourceName != "" && existing.ResourceName != resourceName {
@@ -1378,7 +1465,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if !h.checkConditionalHeaders(r, existing) {
-			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "precondition failed")
 			return
 		}
 
@@ -1397,12 +1484,43 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if provisioned, changed, err := events.NewService(h.store).ProvisionConference(r.Context(), calendarID, string(body), false); err != nil {
+			h.logger().Error("Put", "failed to provision conference for event %q in calendar %d: %v", uid, calendarID, err)
+			if errors.Is(err, events.ErrConferenceProvider) {
+				writeDAVError(w, http.StatusBadGateway, "conference provider failed")
+			} else {
+				writeDAVError(w, http.StatusInternalServerError, "failed to provision conference")
+			}
+			return
+		} else if changed {
+			body = []byte(provisioned)
+			etag = utils.GenerateETag(provisioned)
+			bodyRewritten = true
+		}
+		previous := ""
+		if existing != nil {
+			previous = existing.RawICAL
+		}
+		if scheduled, changed, err := events.NewService(h.store).ScheduleResources(r.Context(), calendarID, string(body), previous); err != nil {
+			h.logger().Error("Put", "failed to schedule resources for event %q in calendar %d: %v", uid, calendarID, err)
+			writeDAVError(w, http.StatusInternalServerError, "failed to schedule resources")
+			return
+		} else if changed {
+			body = []byte(scheduled)
+			etag = utils.GenerateETag(scheduled)
+			bodyRewritten = true
+		}
+
 		if _, err := h.store.Events.Upsert(r.Context(), store.Event{CalendarID: calendarID, UID: uid, ResourceName: resourceName, RawICAL: string(body), ETag: etag}); err != nil {
 			h.logger().Error("Put", "failed to save event %q in calendar %d: %v", uid, calendarID, err)
-			http.Error(w, "failed to save event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to save event")
 			return
 		}
-		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+		h.notFoundCache().forget(notFoundKey{resourceType: heldEvent, collectionID: calendarID, resourceName: resourceName})
+		if !bodyRewritten {
+			w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+		}
+		h.reportRepairs(w, r, user, cleanPath, repairs)
 		if existing == nil {
 			h.logger().Info("Put", "created event %q in calendar %d", uid, calendarID)
 			w.WriteHeader(http.StatusCreated)
@@ -1415,14 +1533,14 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 
 	if addressBookID, _, matched, err := h.parseAddressBookResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "address book not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "address book not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) {
-			http.Error(w, "ambiguous address book path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous address book path")
 			return
 		}
-		http.Error(w, "failed to load address book", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
 		return
 	} else if matched {
 		book, err := h.getAddressBook(r.Context(), addressBookID)
@@ -1431,7 +1549,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			if err == store.ErrNotFound {
 				status = http.StatusNotFound
 			}
-			http.Error(w, "address book not found", status)
+			writeDAVError(w, status, "address book not found")
 			return
 		}
 
@@ -1441,10 +1559,27 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Control characters and broken UTF-8 would make the contact
+		// unservable in REPORT responses, so they are stripped and, as for
+		// events, no ETag is returned.
+		bodyRewritten := false
+		if sanitized := utils.SanitizeText(string(body)); sanitized != string(body) {
+			h.logger().Debug("Put", "stripped characters XML cannot carry from %s", cleanPath)
+			body = []byte(sanitized)
+			etag = utils.GenerateETag(string(body))
+			bodyRewritten = true
+		}
+
 		if err := h.validateVCard(string(body)); err != nil {
 			writeCardDAVPrecondition(w, http.StatusBadRequest, "valid-address-data")
 			return
 		}
+		if h.cfg != nil && h.cfg.ContactValidation == config.ContactValidationStrict {
+			if err := contacts.ValidateStrict(string(body)); err != nil {
+				writeDAVError(w, http.StatusBadRequest, err.Error(), davCondition{"A", "valid-address-data"})
+				return
+			}
+		}
 
 		uid, err := extractUIDFromVCard(string(body))
 		if err != nil {
@@ -1458,7 +1593,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		// Check if an existing resource at this path has a different UID
 		existingByName, err := h.store.Contacts.GetByResourceName(r.Context(), addressBookID, resourceName)
 		if err != nil {
-			http.Error(w, "failed to load contact", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load contact")
 			return
 		}
 		if existingByName == nil && !h.requireLock(w, r, path.Dir(cleanPath), "resource is locked") {
@@ -1473,7 +1608,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 			if err == store.ErrNotFound {
 				status = http.StatusNotFound
 			}
-			http.Error(w, http.StatusText(status), status)
+			writeDAVStatusError(w, status)
 			return
 		}
 		if existingByName != nil && existingByName.UID != uid {
@@ -1485,7 +1620,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		// Check if another resource already uses this UID
 		existingByUID, err := h.store.Contacts.GetByUID(r.Context(), addressBookID, uid)
 		if err != nil {
-			http.Error(w, "failed to load contact", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load contact")
 			return
 		}
 		if existingByUID != nil && contactResourceName(*existingByUID) != resourceName {
@@ -1497,7 +1632,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		existing := existingByUID
 
 		if !h.checkConditionalHeadersContact(r, existing) {
-			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "precondition failed")
 			return
 		}
 
@@ -1518,7 +1653,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 
 		if existingByName == nil {
 			if err := h.deleteDAVACLState(r.Context(), user, cleanPath); err != nil {
-				http.Error(w, "failed to reset resource ACL state", http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, "failed to reset resource ACL state")
 				return
 			}
 		}
@@ -1529,10 +1664,13 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			h.logger().Error("Put", "failed to save contact %q in address book %d: %v", uid, addressBookID, err)
-			http.Error(w, "failed to save contact", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to save contact")
 			return
 		}
-		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+		h.notFoundCache().forget(notFoundKey{resourceType: heldContact, collectionID: addressBookID, resourceName: resourceName})
+		if !bodyRewritten {
+			w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+		}
 		if existing == nil {
 			h.logger().Info("Put", "created contact %q in address book %d", uid, addressBookID)
 			w.WriteHeader(http.StatusCreated)
@@ -1543,7 +1681,7 @@ func (h *Handler) Put(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Error(w, "unsupported path", http.StatusBadRequest)
+	writeDAVError(w, http.StatusBadRequest, "unsupported path")
 }
 
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -1553,32 +1691,36 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Delete", "DELETE %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
 	cleanPath := path.Clean(r.URL.Path)
+	if isPushSubscriptionPath(cleanPath) {
+		h.pushUnregister(w, r, user)
+		return
+	}
 	if !h.requireLock(w, r, cleanPath, "resource is locked") {
 		return
 	}
 	if calendarID, uid, matched, err := h.parseCalendarResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous calendar path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous calendar path")
 			return
 		}
-		http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load calendar")
 		return
 	} else if matched {
 		if calendarID == birthdayCalendarID {
-			http.Error(w, "birthday calendar is read-only", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "birthday calendar is read-only", condNeedPrivileges)
 			return
 		}
 
-		_, err = h.loadCalendarWithPrivilege(r.Context(), user, calendarID, cleanPath, "unbind")
+		cal, err := h.loadCalendarWithPrivilege(r.Context(), user, calendarID, cleanPath, "unbind")
 		if err != nil {
 			status := http.StatusInternalServerError
 			if err == store.ErrNotFound {
@@ -1587,7 +1729,7 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 			if errors.Is(err, errForbidden) {
 				status = http.StatusForbidden
 			}
-			http.Error(w, "not found", status)
+			writeDAVError(w, status, "not found")
 			return
 		}
 		if !h.requireLock(w, r, path.Dir(cleanPath), "resource is locked") {
@@ -1595,25 +1737,38 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		}
 		existing, err := h.store.Events.GetByResourceName(r.Context(), calendarID, uid)
 		if err != nil {
-			http.Error(w, "failed to load event", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load event")
 			return
 		}
 		if !h.checkConditionalHeaders(r, existing) {
-			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "precondition failed")
 			return
 		}
 		if existing == nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		canonicalPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 		if err != nil {
-			http.Error(w, "failed to resolve resource state", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to resolve resource state")
+			return
+		}
+		if held, err := h.holdDeletion(r.Context(), r, user, heldEvent, calendarID, cal.UserID, existing.UID, existing.ResourceName); err != nil {
+			h.logger().Error("Delete", "failed to check mass-deletion protection for calendar %d: %v", calendarID, err)
+			writeDAVError(w, http.StatusInternalServerError, "failed to delete")
+			return
+		} else if held {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := events.NewService(h.store).ReleaseResources(r.Context(), calendarID, existing.RawICAL); err != nil {
+			h.logger().Error("Delete", "failed to release resources of event %q in calendar %d: %v", existing.UID, calendarID, err)
+			writeDAVError(w, http.StatusInternalServerError, "failed to delete")
 			return
 		}
 		if err := h.store.DeleteEventAndState(r.Context(), calendarID, existing.UID, canonicalPath); err != nil {
 			h.logger().Error("Delete", "failed to delete event %q from calendar %d: %v", existing.UID, calendarID, err)
-			http.Error(w, "failed to delete", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to delete")
 			return
 		}
 		h.logger().Info("Delete", "deleted event %q from calendar %d", existing.UID, calendarID)
@@ -1622,14 +1777,14 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 	if addressBookID, resourceName, matched, err := h.parseAddressBookResourcePath(r.Context(), user, cleanPath); err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) {
-			http.Error(w, "ambiguous address book path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous address book path")
 			return
 		}
-		http.Error(w, "failed to load address book", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to load address book")
 		return
 	} else if matched {
 		book, err := h.getAddressBook(r.Context(), addressBookID)
@@ -1638,12 +1793,12 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 			if err == store.ErrNotFound {
 				status = http.StatusNotFound
 			}
-			http.Error(w, "not found", status)
+			writeDAVError(w, status, "not found")
 			return
 		}
 		existing, err := h.store.Contacts.GetByResourceName(r.Context(), addressBookID, resourceName)
 		if err != nil {
-			http.Error(w, "failed to load contact", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to load contact")
 			return
 		}
 		if err := h.requireAddressBookPrivilege(r.Context(), user, book, cleanPath, "unbind"); err != nil {
@@ -1651,38 +1806,46 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 			if err == store.ErrNotFound {
 				status = http.StatusNotFound
 			}
-			http.Error(w, http.StatusText(status), status)
+			writeDAVStatusError(w, status)
 			return
 		}
 		if !h.requireLock(w, r, path.Dir(cleanPath), "resource is locked") {
 			return
 		}
 		if !h.checkConditionalHeadersContact(r, existing) {
-			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "precondition failed")
 			return
 		}
 		if existing == nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		canonicalPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 		if err != nil {
-			http.Error(w, "failed to resolve resource state", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to resolve resource state")
+			return
+		}
+		if held, err := h.holdDeletion(r.Context(), r, user, heldContact, addressBookID, book.UserID, existing.UID, existing.ResourceName); err != nil {
+			h.logger().Error("Delete", "failed to check mass-deletion protection for address book %d: %v", addressBookID, err)
+			writeDAVError(w, http.StatusInternalServerError, "failed to delete")
+			return
+		} else if held {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 		if err := h.store.DeleteContactAndState(r.Context(), addressBookID, existing.UID, canonicalPath); err != nil {
 			h.logger().Error("Delete", "failed to delete contact %q from address book %d: %v", existing.UID, addressBookID, err)
-			http.Error(w, "failed to delete", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to delete")
 			return
 		}
 		h.logger().Info("Delete", "deleted contact %q from address book %d", existing.UID, addressBookID)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	http.Error(w, "unsupported path", http.StatusBadRequest)
+	writeDAVError(w, http.StatusBadRequest, "unsupported path")
 }
 
-func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, reqPath, depth string, user *store.User, propfindReq *propfindRequest) ([]response, error) {
+func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, reqPath, depth string, user *store.User, propfindReq *propfindRequest, window *propfindWindow) ([]response, error) {
 	cleanPath := path.Clean(reqPath)
 	if !strings.HasPrefix(cleanPath, "/dav") {
 		return nil, http.ErrNotSupported
@@ -1702,11 +1865,13 @@ func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, r
 		principalHref := h.principalURL(user)
 		res := []response{rootCollectionResponse(href, user, principalHref)}
 		if depth == "1" {
-			res = append(res,
-				collectionResponse(ensureCollectionHref("/dav/calendars"), "Calendars"),
-				collectionResponse(ensureCollectionHref("/dav/addressbooks"), "Address Books"),
-				principalResponse(ensureCollectionHref(principalHref), user),
-			)
+			if h.calDAVEnabled() {
+				res = append(res, collectionResponse(ensureCollectionHref("/dav/calendars"), "Calendars"))
+			}
+			if h.cardDAVEnabled() {
+				res = append(res, collectionResponse(ensureCollectionHref("/dav/addressbooks"), "Address Books"))
+			}
+			res = append(res, principalResponse(ensureCollectionHref(principalHref), user))
 		}
 		res, err := h.appendCollectionContributors(ctx, r, user, cleanPath, depth, res)
 		if err != nil {
@@ -1743,10 +1908,13 @@ func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, r
 		}
 		return responses, nil
 	case strings.HasPrefix(cleanPath, "/dav/calendars"):
-		responses, err := h.calendarResponses(ctx, cleanPath, depth, user, ensureCollectionHref)
+		responses, err := h.calendarResponses(ctx, cleanPath, depth, user, ensureCollectionHref, window)
 		if err != nil {
 			return nil, err
 		}
+		if err := h.addHomeSyncProperties(ctx, user, cleanPath, responses, propfindReq); err != nil {
+			return nil, err
+		}
 		responses, err = h.appendCollectionContributors(ctx, r, user, cleanPath, depth, responses)
 		if err != nil {
 			return nil, err
@@ -1754,6 +1922,15 @@ func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, r
 		if err := h.decoratePropfindResponses(ctx, r, user, responses); err != nil {
 			return nil, err
 		}
+		if err := h.addSyncStatsProperties(ctx, r, user, responses, propfindReq); err != nil {
+			return nil, err
+		}
+		if err := h.addPushProperties(ctx, user, responses, propfindReq); err != nil {
+			return nil, err
+		}
+		if err := h.addQuotaProperties(ctx, user, cleanPath, responses, propfindReq); err != nil {
+			return nil, err
+		}
 		if propfindReq != nil && propfindReq.AllProp != nil {
 			stripCalendarAllprop(responses)
 		}
@@ -1768,6 +1945,9 @@ func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, r
 		if err != nil {
 			return nil, err
 		}
+		if err := h.addHomeSyncProperties(ctx, user, cleanPath, responses, propfindReq); err != nil {
+			return nil, err
+		}
 		responses, err = h.appendCollectionContributors(ctx, r, user, cleanPath, depth, responses)
 		if err != nil {
 			return nil, err
@@ -1775,6 +1955,15 @@ func (h *Handler) buildPropfindResponses(ctx context.Context, r *http.Request, r
 		if err := h.decoratePropfindResponses(ctx, r, user, responses); err != nil {
 			return nil, err
 		}
+		if err := h.addSyncStatsProperties(ctx, r, user, responses, propfindReq); err != nil {
+			return nil, err
+		}
+		if err := h.addPushProperties(ctx, user, responses, propfindReq); err != nil {
+			return nil, err
+		}
+		if err := h.addQuotaProperties(ctx, user, cleanPath, responses, propfindReq); err != nil {
+			return nil, err
+		}
 		if propfindReq != nil && propfindReq.AllProp != nil {
 			stripAddressBookAllprop(responses)
 		}
@@ -1857,7 +2046,7 @@ func (h *Handler) appendCollectionContributors(ctx context.Context, r *http.Requ
 	return responses, nil
 }
 
-func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string, user *store.User, ensureCollectionHref func(string) string) ([]response, error) {
+func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string, user *store.User, ensureCollectionHref func(string) string, window *propfindWindow) ([]response, error) {
 	relPath := strings.Trim(strings.TrimPrefix(cleanPath, "/dav/calendars"), "/")
 	if relPath == "" {
 		base := ensureCollectionHref("/dav/calendars")
@@ -1879,10 +2068,10 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 
 			// Add regular calendars
 			for _, c := range cals {
-				href := ensureCollectionHref(path.Join("/dav/calendars", fmt.Sprint(c.ID)))
+				href := ensureCollectionHref(calendarCollectionHref(user, &c.Calendar))
 				ctag := fmt.Sprintf("%d", c.CTag)
 				syncToken := buildSyncToken("cal", c.ID, c.UpdatedAt)
-				res = append(res, calendarCollectionResponseWithPrivileges(href, c.Name, c.Description, c.Timezone, c.Color, principalHref, syncToken, ctag, c.EffectivePrivileges()))
+				res = append(res, withCalendarOwner(calendarCollectionResponseWithPrivileges(href, c.Name, c.Description, c.Timezone, c.Color, principalHref, syncToken, ctag, c.EffectivePrivileges()), user, c))
 			}
 		}
 		return res, nil
@@ -1895,6 +2084,23 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 	calID, err := strconv.ParseInt(segments[0], 10, 64)
 	if calID == birthdayCalendarID {
 		href := ensureCollectionHref(path.Join("/dav/calendars", fmt.Sprint(birthdayCalendarID)))
+		if len(segments) == 2 {
+			resourceName := strings.TrimSuffix(segments[1], path.Ext(segments[1]))
+			resourceHref := href + resourceName + ".ics"
+			events, err := h.generateBirthdayEvents(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, event := range events {
+				if eventResourceName(event) != resourceName {
+					continue
+				}
+				ps := calendarResourcePropstat(event.ETag, event.RawICAL, true)
+				ps.Prop.GetLastModified = httpDate(event.LastModified)
+				return []response{resourceResponse(resourceHref, ps)}, nil
+			}
+			return []response{{Href: resourceHref, Status: httpStatusNotFound}}, nil
+		}
 		birthdayName := "Birthdays"
 		birthdayDesc := "Contact birthdays from your address books"
 		// Use stable sync-token (epoch) for birthday calendar to ensure consistency
@@ -1907,6 +2113,9 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 			if err != nil {
 				return nil, err
 			}
+			if window != nil {
+				events = window.filter(h, events, time.Now())
+			}
 			base := ensureCollectionHref(href)
 			res = append(res, calendarResourceResponses(base, events)...)
 		}
@@ -1914,6 +2123,9 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 	}
 
 	var cal *store.CalendarAccess
+	// Answer under the path the client asked for: the ID alias or the
+	// calendar's own path.
+	var collectionPath string
 	if err != nil {
 		cal, err = h.loadCalendarByName(ctx, user, segments[0])
 		if err != nil {
@@ -1922,11 +2134,13 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 			}
 			return nil, http.ErrNotSupported
 		}
+		collectionPath = calendarCollectionHref(user, &cal.Calendar)
 	} else {
 		cal, err = h.loadDiscoverableCalendar(ctx, user, calID)
 		if err != nil {
 			return nil, err
 		}
+		collectionPath = calendarCollectionResourcePath(cal.ID)
 	}
 
 	if len(segments) == 2 {
@@ -1934,7 +2148,7 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 		if resourceName == "" {
 			return nil, http.ErrNotSupported
 		}
-		href := ensureCollectionHref(path.Join("/dav/calendars", fmt.Sprint(cal.ID)))
+		href := ensureCollectionHref(collectionPath)
 		resourceHref := strings.TrimSuffix(href, "/") + "/" + resourceName + ".ics"
 		allowed, err := h.canReadCalendarObject(ctx, user, cal, resourceName)
 		if err != nil {
@@ -1950,14 +2164,16 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 		if event == nil {
 			return []response{{Href: resourceHref, Status: httpStatusNotFound}}, nil
 		}
-		return []response{resourceResponse(resourceHref, calendarResourcePropstat(event.ETag, event.RawICAL, true))}, nil
+		ps := calendarResourcePropstat(event.ETag, event.RawICAL, true)
+		ps.Prop.GetLastModified = httpDate(event.LastModified)
+		return []response{resourceResponse(resourceHref, ps)}, nil
 	}
 
-	href := ensureCollectionHref(path.Join("/dav/calendars", fmt.Sprint(cal.ID)))
+	href := ensureCollectionHref(collectionPath)
 	ctag := fmt.Sprintf("%d", cal.CTag)
 	syncToken := buildSyncToken("cal", cal.ID, cal.UpdatedAt)
 	principalHref := h.principalURL(user)
-	res := []response{calendarCollectionResponseWithPrivileges(href, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, ctag, cal.EffectivePrivileges())}
+	res := []response{withCalendarOwner(calendarCollectionResponseWithPrivileges(href, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, ctag, cal.EffectivePrivileges()), user, *cal)}
 	if depth == "1" {
 		events, err := h.store.Events.ListForCalendar(ctx, cal.ID)
 		if err != nil {
@@ -1967,6 +2183,9 @@ func (h *Handler) calendarResponses(ctx context.Context, cleanPath, depth string
 		if err != nil {
 			return nil, err
 		}
+		if window != nil {
+			events = window.filter(h, events, time.Now())
+		}
 		base := ensureCollectionHref(href)
 		res = append(res, calendarResourceResponses(base, events)...)
 	}
@@ -2051,7 +2270,9 @@ func (h *Handler) addressBookResponses(ctx context.Context, cleanPath, depth str
 		if contact == nil {
 			return []response{{Href: href, Status: httpStatusNotFound}}, nil
 		}
-		return []response{resourceResponse(href, addressBookResourcePropstat(contact.ETag, contact.RawVCard, true))}, nil
+		ps := addressBookResourcePropstat(contact.ETag, contact.RawVCard, true)
+		ps.Prop.GetLastModified = httpDate(contact.LastModified)
+		return []response{resourceResponse(href, ps)}, nil
 	}
 	href := collectionHref
 	ctag := fmt.Sprintf("%d", book.CTag)
@@ -2083,6 +2304,15 @@ func (h *Handler) loadCalendarByName(ctx context.Context, user *store.User, name
 	if err != nil {
 		return nil, err
 	}
+	// Slugs are unique among a user's own calendars, so an owned slug match
+	// wins over shared calendars or display names that happen to agree.
+	normalizedName := strings.ToLower(name)
+	for _, c := range accessible {
+		if user != nil && c.UserID == user.ID && c.Slug != nil && *c.Slug == normalizedName {
+			copy := c
+			return &copy, nil
+		}
+	}
 	var match *store.CalendarAccess
 	for _, c := range accessible {
 		if (c.Slug != nil && *c.Slug == strings.ToLower(name)) || c.Name == name {
@@ -2424,6 +2654,9 @@ type syncTokenInfo struct {
 	Kind      string
 	ID        int64
 	Timestamp time.Time
+	// Cursor is set on intermediate tokens from a paged sync-collection: the
+	// resource name of the last member sent among those modified at Timestamp.
+	Cursor string
 }
 
 func buildSyncToken(kind string, id int64, ts time.Time) string {
@@ -2434,11 +2667,36 @@ func buildSyncToken(kind string, id int64, ts time.Time) string {
 	return fmt.Sprintf("%s:%s:%d:%d", syncTokenPrefix, kind, id, nanos)
 }
 
+// buildSyncPageToken builds the intermediate token returned with a truncated
+// sync-collection page; presenting it resumes after the given member.
+func buildSyncPageToken(kind string, id int64, ts time.Time, cursor string) string {
+	return buildSyncToken(kind, id, ts) + ":" + base64.RawURLEncoding.EncodeToString([]byte(cursor))
+}
+
+// parseSyncToken reads a token built by buildSyncToken or
+// buildSyncPageToken. Surrounding whitespace and percent-encoding are
+// ignored, since some clients, such as CardBook, echo tokens back padded or
+// URL-encoded.
 func parseSyncToken(token string) (syncTokenInfo, error) {
+	token = strings.TrimSpace(token)
+	if strings.Contains(token, "%") {
+		if decoded, err := url.PathUnescape(token); err == nil {
+			token = strings.TrimSpace(decoded)
+		}
+	}
 	if token == "" || !strings.HasPrefix(token, syncTokenPrefix+":") {
 		return syncTokenInfo{}, errInvalidSyncToken
 	}
 	parts := strings.Split(token[len(syncTokenPrefix)+1:], ":")
+	cursor := ""
+	if len(parts) == 4 {
+		decoded, err := base64.RawURLEncoding.DecodeString(parts[3])
+		if err != nil || len(decoded) == 0 {
+			return syncTokenInfo{}, errInvalidSyncToken
+		}
+		cursor = string(decoded)
+		parts = parts[:3]
+	}
 	if len(parts) != 3 {
 		return syncTokenInfo{}, errInvalidSyncToken
 	}
@@ -2450,7 +2708,7 @@ func parseSyncToken(token string) (syncTokenInfo, error) {
 	if err != nil {
 		return syncTokenInfo{}, errInvalidSyncToken
 	}
-	info := syncTokenInfo{Kind: parts[0], ID: id}
+	info := syncTokenInfo{Kind: parts[0], ID: id, Cursor: cursor}
 	if nanos > 0 {
 		info.Timestamp = time.Unix(0, nanos).UTC()
 	}
@@ -2774,7 +3032,8 @@ func (h *Handler) recurringEventInTimeRange(event store.Event, rangeStart, range
 
 	current := *event.DTStart
 	for i := 0; i < maxOccurrences; i++ {
-		if current.After(recurrenceEnd) {
+		// DTSTART is always the first occurrence, even past UNTIL (RFC 5545 §3.8.5.3).
+		if i > 0 && current.After(recurrenceEnd) {
 			break
 		}
 
@@ -2883,10 +3142,28 @@ func (h *Handler) calendarQuery(ctx context.Context, user *store.User, cal *stor
 	if err != nil {
 		return nil, err
 	}
+	events = withoutSyncHiddenEvents(user, events)
 
 	return calendarResourceResponsesFiltered(cleanPath, events, calData), nil
 }
 
+// withoutSyncHiddenEvents drops cancelled and declined events when the user
+// has opted out of syncing them. Incremental syncs then report those events
+// as removed, so clients drop copies they already hold.
+func withoutSyncHiddenEvents(user *store.User, events []store.Event) []store.Event {
+	if user == nil || !user.SyncHideCancelled {
+		return events
+	}
+	kept := make([]store.Event, 0, len(events))
+	for _, ev := range events {
+		if utils.IsCancelledOrDeclined(ev.RawICAL, user.Emails()...) {
+			continue
+		}
+		kept = append(kept, ev)
+	}
+	return kept
+}
+
 func (h *Handler) calendarMultiGet(ctx context.Context, user *store.User, cal *store.CalendarAccess, hrefs []string, resolvePath, responsePath string, calData *calendarDataEl) ([]response, error) {
 	if len(hrefs) == 0 {
 		return h.calendarQuery(ctx, user, cal, responsePath, nil, calData)
@@ -3135,45 +3412,108 @@ func addressBookResourceResponses(base string, contacts []store.Contact) []respo
 }
 
 func (h *Handler) calendarSyncCollection(ctx context.Context, user *store.User, cal *store.CalendarAccess, principalHref, cleanPath string, report reportRequest, calData *calendarDataEl) ([]response, string, error) {
+	if h.store.ReadsFromReplica(ctx) {
+		primary, err := h.store.Calendars.GetByID(store.WithPrimaryReads(ctx), cal.ID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load calendar")
+		}
+		if primary == nil || primary.CTag != cal.CTag || !primary.UpdatedAt.Equal(cal.UpdatedAt) {
+			// The replica is behind: answering from it could hand out a
+			// token that skips changes or misses tombstones.
+			h.logger().Debug("calendarSyncCollection", "replica behind primary for calendar %d, reading from primary", cal.ID)
+			ctx = store.WithPrimaryReads(ctx)
+			if primary != nil {
+				current := *cal
+				current.Calendar = *primary
+				cal = &current
+			}
+		}
+	}
 	syncToken, _ := h.calendarSyncTokenValue(ctx, cal)
 	collectionHref := strings.TrimSuffix(cleanPath, "/") + "/"
 
 	var since time.Time
-	if report.SyncToken != "" {
+	var cursor string
+	if strings.TrimSpace(report.SyncToken) != "" {
 		info, err := parseSyncToken(report.SyncToken)
 		if err != nil || info.Kind != "cal" || info.ID != cal.ID {
 			return nil, "", errInvalidSyncToken
 		}
+		if user != nil && user.SyncPrefsUpdatedAt != nil && info.Timestamp.Before(*user.SyncPrefsUpdatedAt) {
+			// Events hidden or revealed by the preference change are not in
+			// the modified-since window, so force a full resync.
+			if !h.lenientSyncTokens() {
+				return nil, "", errInvalidSyncToken
+			}
+			h.logger().Debug("calendarSyncCollection", "stale sync token for calendar %d, answering with a full sync", cal.ID)
+			info = syncTokenInfo{}
+		}
 		since = info.Timestamp
+		cursor = info.Cursor
 	}
+	// Throttled requests, and any request resuming a paged sync, are
+	// answered in bounded pages.
+	paged := cursor != "" || syncPagingRequested(ctx)
 
 	var events []store.Event
 	var err error
-	if since.IsZero() {
+	switch {
+	case since.IsZero():
 		events, err = h.store.Events.ListForCalendar(ctx, cal.ID)
-	} else {
+	case cursor != "":
+		// Members modified at the cursor timestamp may not all have been sent.
+		events, err = h.store.Events.ListModifiedSince(ctx, cal.ID, since.Add(-time.Nanosecond))
+	default:
 		events, err = h.store.Events.ListModifiedSince(ctx, cal.ID, since)
 	}
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to list events")
 	}
+	if paged {
+		sortEventsForSyncPaging(events)
+	}
+	if cursor != "" {
+		events = eventsAfterSyncCursor(events, since, cursor)
+	}
 	allEvents := events
 	events, err = h.filterReadableCalendarEvents(ctx, user, cal, events)
 	if err != nil {
 		return nil, "", err
 	}
+	events = withoutSyncHiddenEvents(user, events)
+	visible := make(map[string]struct{}, len(events))
+	for _, event := range events {
+		visible[eventResourceName(event)] = struct{}{}
+	}
+
+	var truncated bool
+	if pageSize := h.limits.syncPageSize(); paged && pageSize > 0 && len(events) > pageSize {
+		last := events[pageSize-1]
+		events = events[:pageSize]
+		syncToken = buildSyncPageToken("cal", cal.ID, last.LastModified, eventResourceName(last))
+		truncated = true
+		// Tombstones past the page boundary are sent with a later page.
+		var inPage []store.Event
+		for _, event := range allEvents {
+			if event.LastModified.Before(last.LastModified) || (event.LastModified.Equal(last.LastModified) && eventResourceName(event) <= eventResourceName(last)) {
+				inPage = append(inPage, event)
+			}
+		}
+		allEvents = inPage
+	}
 
 	responses := []response{
-		calendarCollectionResponseWithPrivileges(collectionHref, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, fmt.Sprintf("%d", cal.CTag), cal.EffectivePrivileges()),
+		withCalendarOwner(calendarCollectionResponseWithPrivileges(collectionHref, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, fmt.Sprintf("%d", cal.CTag), cal.EffectivePrivileges()), user, *cal),
 	}
 	responses = append(responses, calendarResourceResponsesFiltered(collectionHref, events, calData)...)
 
 	// Include deleted resources if this is an incremental sync
 	if !since.IsZero() {
-		deletedHrefs := make(map[string]struct{})
-		visible := make(map[string]struct{}, len(events))
+		// A member deleted and re-created since the token is reported above;
+		// its old tombstone must not follow and delete it again.
+		deletedHrefs := make(map[string]struct{}, len(events))
 		for _, event := range events {
-			visible[eventResourceName(event)] = struct{}{}
+			deletedHrefs[collectionHref+eventResourceName(event)+".ics"] = struct{}{}
 		}
 		for _, event := range allEvents {
 			if !event.LastModified.After(since) {
@@ -3204,16 +3544,38 @@ func (h *Handler) calendarSyncCollection(ctx context.Context, user *store.User,
 			deletedHrefs[href] = struct{}{}
 		}
 	}
+	if truncated {
+		// RFC 6578 §3.6: a 507 on the request-URI tells the client to come
+		// back with the returned token for the remaining members.
+		responses = append(responses, response{
+			Href:   collectionHref,
+			Status: "HTTP/1.1 507 Insufficient Storage",
+			Error:  &responseError{NumberOfMatchesWithinLimits: &struct{}{}},
+		})
+	}
 
 	return responses, syncToken, nil
 }
 
 func (h *Handler) addressBookSyncCollection(ctx context.Context, user *store.User, book *store.AddressBook, principalHref, cleanPath string, report reportRequest) ([]response, string, error) {
+	if h.store.ReadsFromReplica(ctx) {
+		primary, err := h.store.AddressBooks.GetByID(store.WithPrimaryReads(ctx), book.ID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load address book")
+		}
+		if primary == nil || primary.CTag != book.CTag || !primary.UpdatedAt.Equal(book.UpdatedAt) {
+			h.logger().Debug("addressBookSyncCollection", "replica behind primary for address book %d, reading from primary", book.ID)
+			ctx = store.WithPrimaryReads(ctx)
+			if primary != nil {
+				book = primary
+			}
+		}
+	}
 	syncToken, _ := h.addressBookSyncTokenValue(ctx, book)
 	collectionHref := strings.TrimSuffix(cleanPath, "/") + "/"
 
 	var since time.Time
-	if report.SyncToken != "" {
+	if strings.TrimSpace(report.SyncToken) != "" {
 		info, err := parseSyncToken(report.SyncToken)
 		if err != nil || info.Kind != "card" || info.ID != book.ID {
 			return nil, "", errInvalidSyncToken
@@ -3247,11 +3609,19 @@ func (h *Handler) addressBookSyncCollection(ctx context.Context, user *store.Use
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to list deleted contacts")
 		}
+		reported := make(map[string]struct{}, len(contacts))
+		for _, c := range contacts {
+			reported[contactResourceName(c)] = struct{}{}
+		}
 		for _, d := range deleted {
 			resourceName := d.ResourceName
 			if resourceName == "" {
 				resourceName = d.UID
 			}
+			if _, ok := reported[resourceName]; ok {
+				continue
+			}
+			reported[resourceName] = struct{}{}
 			allowed, err := h.canReadAddressBookContact(ctx, user, book, resourceName)
 			if err != nil {
 				return nil, "", err
@@ -3334,7 +3704,7 @@ func (h *Handler) generateBirthdayEvents(ctx context.Context, userID int64) ([]s
 		sb.WriteString("END:VCALENDAR\r\n")
 
 		rawICAL := sb.String()
-		etag := fmt.Sprintf("%x", sha256.Sum256([]byte(rawICAL)))
+		etag := utils.GenerateETag(rawICAL)
 
 		events = append(events, store.Event{
 			ID:           0, // Virtual event, no DB ID
@@ -3385,7 +3755,7 @@ func (h *Handler) birthdayCalendarReportResponses(ctx context.Context, user *sto
 		etag := fmt.Sprintf("%x", sha256.Sum256([]byte(freeBusyData)))
 		return []response{resourceResponse(href, etagProp(etag, freeBusyData, true))}, "", nil
 	case "sync-collection":
-		if report.SyncToken != "" {
+		if strings.TrimSpace(report.SyncToken) != "" {
 			info, err := parseSyncToken(report.SyncToken)
 			if err != nil || info.Kind != "cal" || info.ID != birthdayCalendarID {
 				return nil, "", errInvalidSyncToken
@@ -2,20 +2,27 @@ package dav
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/scan"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 )
 
 func newCalendarPutRequest(path string, body io.Reader) *http.Request {
@@ -34,8 +41,8 @@ func TestBirthdayCalendarGeneration(t *testing.T) {
 	name2 := "Jane Smith"
 	name3 := "Bob Johnson"
 
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"1:contact1": {
 				AddressBookID: 1,
 				UID:           "contact1",
@@ -140,8 +147,8 @@ func TestParseResourcePathAcceptsAbsoluteHref(t *testing.T) {
 }
 
 func TestCalendarMultiGetHandlesAbsoluteHref(t *testing.T) {
-	repo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:test-event": {
 				CalendarID: 2,
 				UID:        "test-event",
@@ -150,7 +157,7 @@ func TestCalendarMultiGetHandlesAbsoluteHref(t *testing.T) {
 			},
 		},
 	}
-	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
 
 	hrefs := []string{"https://cal.example.com/dav/calendars/2/test-event.ics"}
 	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1}}
@@ -167,8 +174,8 @@ func TestCalendarMultiGetHandlesAbsoluteHref(t *testing.T) {
 }
 
 func TestCalendarMultiGetHandlesRelativeHref(t *testing.T) {
-	repo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:test-event": {
 				CalendarID: 2,
 				UID:        "test-event",
@@ -177,7 +184,7 @@ func TestCalendarMultiGetHandlesRelativeHref(t *testing.T) {
 			},
 		},
 	}
-	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
 
 	hrefs := []string{"test-event.ics"}
 	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1}}
@@ -210,8 +217,8 @@ func TestParseResourcePathRejectsInvalid(t *testing.T) {
 
 func TestCalendarReportSyncCollectionReturnsToken(t *testing.T) {
 	now := store.Now()
-	repo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:test-event": {
 				CalendarID:   2,
 				UID:          "test-event",
@@ -221,7 +228,7 @@ func TestCalendarReportSyncCollectionReturnsToken(t *testing.T) {
 			},
 		},
 	}
-	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
 
 	report := reportRequest{XMLName: xml.Name{Local: "sync-collection"}}
 	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Test", CTag: 1, UpdatedAt: now}, Editor: true}
@@ -242,11 +249,11 @@ func TestCalendarReportSyncCollectionReturnsToken(t *testing.T) {
 
 func TestCalendarSyncCollectionIncludesDeletedResources(t *testing.T) {
 	now := store.Now()
-	repo := &fakeEventRepo{
-		events: map[string]*store.Event{},
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ID: 1, ResourceType: "event", CollectionID: 2, UID: "deleted-uid", ResourceName: "deleted-resource", DeletedAt: now},
 		},
 	}
@@ -282,8 +289,8 @@ func TestCalendarSyncCollectionIncludesDeletedResources(t *testing.T) {
 
 func TestCalendarSyncCollectionRejectsInvalidToken(t *testing.T) {
 	now := store.Now()
-	repo := &fakeEventRepo{events: map[string]*store.Event{}}
-	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	repo := &storetest.Events{Events: map[string]*store.Event{}}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
 	report := reportRequest{
 		XMLName:   xml.Name{Local: "sync-collection"},
 		SyncToken: buildSyncToken("card", 2, now), // wrong kind for calendar
@@ -311,8 +318,8 @@ func TestResolveDAVHrefHandlesRelativeAbsoluteAndURL(t *testing.T) {
 }
 
 func TestAddressBookMultiGetReportHandlesRelativeHrefAgainstResourceBase(t *testing.T) {
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -361,6 +368,24 @@ func TestParseSyncTokenRejectsGarbage(t *testing.T) {
 	}
 }
 
+func TestParseSyncTokenToleratesEncodingAndPadding(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 123, time.UTC)
+	token := buildSyncPageToken("card", 7, ts, "a b.vcf")
+	for _, sent := range []string{
+		"  " + token + "\n",
+		strings.ReplaceAll(token, ":", "%3A"),
+		"\t" + url.PathEscape(token) + " ",
+	} {
+		info, err := parseSyncToken(sent)
+		if err != nil || info.Kind != "card" || info.ID != 7 || !info.Timestamp.Equal(ts) || info.Cursor != "a b.vcf" {
+			t.Errorf("parseSyncToken(%q) = %+v, %v", sent, info, err)
+		}
+	}
+	if _, err := parseSyncToken("%zz" + token); !errors.Is(err, errInvalidSyncToken) {
+		t.Fatalf("expected errInvalidSyncToken for a bad escape, got %v", err)
+	}
+}
+
 func TestOptionsAdvertisesDAVCapabilities(t *testing.T) {
 	h := &Handler{}
 	rr := httptest.NewRecorder()
@@ -391,10 +416,10 @@ func TestOptionsAdvertisesCopyMoveOnlyForObjectResources(t *testing.T) {
 		wantCopyMove  bool
 		wantDAVHeader string
 	}{
-		{path: "/dav/addressbooks/5/", wantCopyMove: false, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol"},
-		{path: "/dav/addressbooks/5/alice.vcf", wantCopyMove: true, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol"},
-		{path: "/dav/calendars/2/", wantCopyMove: false, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol"},
-		{path: "/dav/calendars/2/event.ics", wantCopyMove: true, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol"},
+		{path: "/dav/addressbooks/5/", wantCopyMove: false, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol, calendar-no-timezone"},
+		{path: "/dav/addressbooks/5/alice.vcf", wantCopyMove: true, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol, calendar-no-timezone"},
+		{path: "/dav/calendars/2/", wantCopyMove: false, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol, calendar-no-timezone"},
+		{path: "/dav/calendars/2/event.ics", wantCopyMove: true, wantDAVHeader: "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol, calendar-no-timezone"},
 	}
 
 	for _, tc := range tests {
@@ -483,8 +508,8 @@ func TestCanLockCalendarPath(t *testing.T) {
 		{ResourcePath: "/dav/calendars/10", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "unbind"},
 		{ResourcePath: "/dav/calendars/11/event", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "write-content"},
 	}}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 5, UserID: 1, Name: "Home"}, Editor: true},
 			{Calendar: store.Calendar{ID: 6, UserID: 1, Name: "Readonly Shared"}, Shared: true, Editor: false},
 			{Calendar: store.Calendar{ID: 7, UserID: 1, Name: "Writable Shared"}, Shared: true, Editor: true},
@@ -492,7 +517,7 @@ func TestCanLockCalendarPath(t *testing.T) {
 			{Calendar: store.Calendar{ID: 9, UserID: 1, Name: "Write Content Shared"}, Shared: true, Privileges: store.CalendarPrivileges{Read: true, WriteContent: true}},
 			{Calendar: store.Calendar{ID: 10, UserID: 1, Name: "Unbind Shared"}, Shared: true, Privileges: store.CalendarPrivileges{Read: true, Unbind: true}},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5:  {ID: 5, UserID: 1, Name: "Home"},
 			6:  {ID: 6, UserID: 1, Name: "Readonly Shared"},
 			7:  {ID: 7, UserID: 1, Name: "Writable Shared"},
@@ -502,8 +527,8 @@ func TestCanLockCalendarPath(t *testing.T) {
 			11: {ID: 11, UserID: 1, Name: "Direct Object Shared"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"7:event":  {CalendarID: 7, UID: "event", ResourceName: "event"},
 			"11:event": {CalendarID: 11, UID: "event", ResourceName: "event"},
 		},
@@ -591,15 +616,37 @@ func TestPropfindRootIncludesPrincipalAndHomes(t *testing.T) {
 	}
 }
 
+func TestPropfindRootOmitsDisabledHalf(t *testing.T) {
+	h := &Handler{cfg: &config.Config{CalDAVDisabled: true}}
+	u := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
+
+	req := httptest.NewRequest("PROPFIND", "/dav", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	req.Header.Set("Depth", "1")
+	rr := httptest.NewRecorder()
+	h.Propfind(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "<d:href>/dav/calendars/</d:href>") || strings.Contains(body, "calendar-home-set") {
+		t.Fatalf("expected no calendar home with CalDAV disabled:\n%s", body)
+	}
+	if !strings.Contains(body, "<d:href>/dav/addressbooks/</d:href>") || !strings.Contains(body, "<card:addressbook-home-set>") {
+		t.Fatalf("expected the address book home to remain:\n%s", body)
+	}
+	if dav := h.davHeaderForPath("/dav"); strings.Contains(dav, "calendar-access") || !strings.Contains(dav, "addressbook") {
+		t.Fatalf("DAV header = %q", dav)
+	}
+}
+
 func TestPropfindCalendarCollectionIncludesReportsAndSync(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "etag", LastModified: now},
 		},
 	}
@@ -634,13 +681,13 @@ func TestPropfindCalendarCollectionIncludesReportsAndSync(t *testing.T) {
 
 func TestPropfindAddressBookCollectionIncludesReportsAndSync(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts", CTag: 9, UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", RawVCard: "VCARD", ETag: "e1", LastModified: now},
 		},
 	}
@@ -675,13 +722,13 @@ func TestPropfindAddressBookCollectionIncludesReportsAndSync(t *testing.T) {
 
 func TestPropfindCalendarDepth0DoesNotListEvents(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "etag", LastModified: now},
 		},
 	}
@@ -708,13 +755,13 @@ func TestPropfindCalendarDepth0DoesNotListEvents(t *testing.T) {
 }
 
 func TestPropfindCalendarResourceReturnsProps(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID:   1,
 				UID:          "event",
@@ -770,8 +817,8 @@ func TestPropfindPrincipalsDepth0OmitsUserPrincipal(t *testing.T) {
 }
 
 func TestPropfindCalendarsRootListsCollections(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "One"}},
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Two"}},
 		},
@@ -795,8 +842,8 @@ func TestPropfindCalendarsRootListsCollections(t *testing.T) {
 }
 
 func TestPropfindAddressBooksRootListsCollections(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			1: {ID: 1, UserID: 1, Name: "Personal"},
 			2: {ID: 2, UserID: 1, Name: "Shared"},
 		},
@@ -820,8 +867,8 @@ func TestPropfindAddressBooksRootListsCollections(t *testing.T) {
 }
 
 func TestCalendarReportFallsBackToQueryForUnknownType(t *testing.T) {
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "e"},
 		},
 	}
@@ -842,8 +889,8 @@ func TestCalendarReportFallsBackToQueryForUnknownType(t *testing.T) {
 }
 
 func TestAddressBookReportFallsBackToQueryForUnknownType(t *testing.T) {
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"4:alice": {AddressBookID: 4, UID: "alice", RawVCard: "VCARD", ETag: "e"},
 		},
 	}
@@ -865,13 +912,13 @@ func TestAddressBookReportFallsBackToQueryForUnknownType(t *testing.T) {
 }
 
 func TestGetServesCalendarEvent(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICALDATA", ETag: "etag1"},
 		},
 	}
@@ -893,19 +940,27 @@ func TestGetServesCalendarEvent(t *testing.T) {
 	if !strings.Contains(rr.Header().Get("ETag"), "etag1") {
 		t.Fatalf("missing ETag header, got %q", rr.Header().Get("ETag"))
 	}
-	if rr.Body.String() != "ICALDATA" {
-		t.Fatalf("unexpected body %q", rr.Body.String())
+	if rr.Body.String() != "ICALDATA" || rr.Header().Get("Content-Length") != "8" {
+		t.Fatalf("unexpected body %q (Content-Length %q)", rr.Body.String(), rr.Header().Get("Content-Length"))
+	}
+
+	req = httptest.NewRequest(http.MethodHead, "/dav/calendars/2/event.ics", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	rr = httptest.NewRecorder()
+	h.Head(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 || rr.Header().Get("Content-Length") != "8" {
+		t.Fatalf("HEAD = %d %q (Content-Length %q)", rr.Code, rr.Body.String(), rr.Header().Get("Content-Length"))
 	}
 }
 
 func TestGetServesContact(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: "VCARD", ETag: "etag2"},
 		},
 	}
@@ -933,13 +988,13 @@ func TestGetServesContact(t *testing.T) {
 }
 
 func TestGetRejectsWildcardAcceptRangeWithZeroQuality(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -968,13 +1023,13 @@ func TestGetRejectsWildcardAcceptRangeWithZeroQuality(t *testing.T) {
 }
 
 func TestGetAddressBookResourceReturnsInternalServerErrorWhenACLLookupFails(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -1011,13 +1066,13 @@ func TestGetRequiresUser(t *testing.T) {
 }
 
 func TestGetRequiresUserEvenForDAVAllAddressBookRead(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 		},
 	}
@@ -1041,13 +1096,13 @@ func TestGetRequiresUserEvenForDAVAllAddressBookRead(t *testing.T) {
 }
 
 func TestDeleteRemovesContactFromAddressBook(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice"},
 		},
 	}
@@ -1062,22 +1117,22 @@ func TestDeleteRemovesContactFromAddressBook(t *testing.T) {
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d", rr.Code)
 	}
-	if _, ok := contactRepo.contacts[contactRepo.key(5, "alice")]; ok {
+	if _, ok := contactRepo.Contacts[contactRepo.Key(5, "alice")]; ok {
 		t.Fatal("contact should be deleted")
 	}
 }
 
 func TestDeleteAddressBookContactPropagatesLookupErrors(t *testing.T) {
 	user := &store.User{ID: 1}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
 
 	t.Run("positive delete succeeds", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 			},
 		}
@@ -1097,12 +1152,12 @@ func TestDeleteAddressBookContactPropagatesLookupErrors(t *testing.T) {
 	})
 
 	t.Run("negative lookup errors return 500 and preserve state", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 			},
-			getByResourceNameErr:    errors.New("lookup failed"),
-			getByResourceNameErrKey: "5:alice",
+			GetByResourceNameErr:    errors.New("lookup failed"),
+			GetByResourceNameErrKey: "5:alice",
 		}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 		req := httptest.NewRequest(http.MethodDelete, "/dav/addressbooks/5/alice.vcf", nil)
@@ -1114,12 +1169,12 @@ func TestDeleteAddressBookContactPropagatesLookupErrors(t *testing.T) {
 		if rr.Code != http.StatusInternalServerError {
 			t.Fatalf("expected lookup failure to return 500, got %d: %s", rr.Code, rr.Body.String())
 		}
-		contactRepo.getByResourceNameErr = nil
+		contactRepo.GetByResourceNameErr = nil
 		if remaining, _ := contactRepo.GetByResourceName(req.Context(), 5, "alice"); remaining == nil {
 			t.Fatal("expected contact lookup failure to leave the contact untouched")
 		}
-		if len(contactRepo.deleted) != 0 {
-			t.Fatalf("expected delete to be skipped on lookup failure, got %#v", contactRepo.deleted)
+		if len(contactRepo.Deleted) != 0 {
+			t.Fatalf("expected delete to be skipped on lookup failure, got %#v", contactRepo.Deleted)
 		}
 	})
 }
@@ -1137,12 +1192,12 @@ func TestReportRequiresAuthentication(t *testing.T) {
 }
 
 func TestReportRejectsTooLargeBody(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	req := httptest.NewRequest("REPORT", "/dav/calendars/1/", nil)
 	req.ContentLength = maxDAVBodyBytes + 1
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
@@ -1156,13 +1211,13 @@ func TestReportRejectsTooLargeBody(t *testing.T) {
 }
 
 func TestReportCalendarQueryReturnsEvents(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "etag"},
 		},
 	}
@@ -1183,13 +1238,13 @@ func TestReportCalendarQueryReturnsEvents(t *testing.T) {
 }
 
 func TestReportAddressBookQueryReturnsContacts(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", RawVCard: "VCARD", ETag: "etag"},
 		},
 	}
@@ -1225,12 +1280,12 @@ func TestReportInvalidCalendarIDReturnsBadRequest(t *testing.T) {
 
 func TestReportAddressBookRejectsInvalidSyncToken(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts", CTag: 1, UpdatedAt: now},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, DeletedResources: &storetest.DeletedResources{}}}
 	req := httptest.NewRequest("REPORT", "/dav/addressbooks/3/", strings.NewReader(`<D:sync-collection xmlns:D="DAV:"><D:sync-token>urn:calcard-sync:cal:3:0</D:sync-token></D:sync-collection>`))
 	req.Header.Set("Content-Type", "application/xml")
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
@@ -1241,16 +1296,19 @@ func TestReportAddressBookRejectsInvalidSyncToken(t *testing.T) {
 	if rr.Code != http.StatusForbidden {
 		t.Fatalf("expected 403 for invalid sync token kind, got %d", rr.Code)
 	}
+	if !strings.Contains(rr.Body.String(), "<D:valid-sync-token/>") {
+		t.Fatalf("expected DAV:valid-sync-token precondition, got %s", rr.Body.String())
+	}
 }
 
 func TestPutCreatesCalendarEventWhenEditor(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: map[string]*store.Event{}}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	u := &store.User{ID: 1}
 
@@ -1267,21 +1325,197 @@ func TestPutCreatesCalendarEventWhenEditor(t *testing.T) {
 	if rr.Header().Get("ETag") == "" {
 		t.Fatal("expected ETag header")
 	}
-	if _, ok := eventRepo.events[eventRepo.key(2, "new")]; !ok {
+	if _, ok := eventRepo.Events[eventRepo.Key(2, "new")]; !ok {
+		t.Fatal("event not stored via Upsert")
+	}
+}
+
+func TestPutNormalizesExchangeQuirksAndOmitsETag(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:Microsoft Exchange Server 2010\r\nBEGIN:VEVENT\r\nUID:ex\r\n" +
+		"DTSTART:20260105T090000Z\r\nRRULE:FREQ=DAILY;UNTIL=20260110\r\nX-MICROSOFT-CDO-BUSYSTATUS:FREE\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/ex.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+
+	h.Put(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != "" {
+		t.Fatalf("expected no ETag for a rewritten body, got %q", etag)
+	}
+	stored := eventRepo.Events[eventRepo.Key(2, "ex")]
+	if stored == nil {
 		t.Fatal("event not stored via Upsert")
 	}
+	if !strings.Contains(stored.RawICAL, "UNTIL=20260110T235959Z") || !strings.Contains(stored.RawICAL, "TRANSP:TRANSPARENT") {
+		t.Fatalf("expected normalized calendar data, got:\n%s", stored.RawICAL)
+	}
+}
+
+type fakeClientRepairRepo struct {
+	store.ClientRepairRepository
+	recorded []store.ClientRepair
+}
+
+func (f *fakeClientRepairRepo) Record(ctx context.Context, repair store.ClientRepair) error {
+	f.recorded = append(f.recorded, repair)
+	return nil
+}
+
+func TestPutRepairsCalendarDataWhenConfigured(t *testing.T) {
+	ical := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:sloppy\nDTSTART:20260105T090000Z\nEND:VEVENT\nEND:VCALENDAR\n"
+	for _, repair := range []bool{false, true} {
+		calRepo := &storetest.Calendars{
+			Accessible: []store.CalendarAccess{
+				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+			},
+		}
+		eventRepo := storetest.NewEvents()
+		repairs := &fakeClientRepairRepo{}
+		h := &Handler{cfg: &config.Config{CalDAVRepair: repair}, store: &store.Store{Calendars: calRepo, Events: eventRepo, ClientRepairs: repairs}}
+
+		req := newCalendarPutRequest("/dav/calendars/2/sloppy.ics", strings.NewReader(ical))
+		req.Header.Set("User-Agent", "SloppyCal/1.0")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Put(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("repair=%v: expected 201, got %d: %s", repair, rr.Code, rr.Body.String())
+		}
+		stored := eventRepo.Events[eventRepo.Key(2, "sloppy")]
+		warnings := rr.Header().Values(repairWarningHeader)
+		if !repair {
+			if stored.RawICAL != ical || len(warnings) != 0 || len(repairs.recorded) != 0 || rr.Header().Get("ETag") == "" {
+				t.Fatalf("without repair: stored %q, warnings %v, recorded %v", stored.RawICAL, warnings, repairs.recorded)
+			}
+			continue
+		}
+		if !strings.Contains(stored.RawICAL, "\r\nPRODID:-//CalCard//Repaired//EN\r\n") || strings.Contains(strings.ReplaceAll(stored.RawICAL, "\r\n", ""), "\n") {
+			t.Fatalf("expected repaired calendar data, got %q", stored.RawICAL)
+		}
+		if rr.Header().Get("ETag") != "" {
+			t.Fatal("expected no ETag for a repaired body")
+		}
+		if len(warnings) != 2 || !strings.HasPrefix(warnings[0], "line-endings: ") || !strings.HasPrefix(warnings[1], "prodid: ") {
+			t.Fatalf("warnings = %v", warnings)
+		}
+		if len(repairs.recorded) != 2 || repairs.recorded[0].UserAgent != "SloppyCal/1.0" || repairs.recorded[1].LastResource != "/dav/calendars/2/sloppy.ics" {
+			t.Fatalf("recorded = %+v", repairs.recorded)
+		}
+	}
+}
+
+func TestPutKeepsAlarmAcknowledgments(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:acked\r\nDTSTART:20260105T090000Z\r\n" +
+		"X-MOZ-LASTACK:20260105T084500Z\r\nBEGIN:VALARM\r\nUID:alarm-1\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\n" +
+		"TRIGGER:-PT15M\r\nACKNOWLEDGED:20260105T084500Z\r\nEND:VALARM\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/acked.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+
+	h.Put(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	stored := eventRepo.Events[eventRepo.Key(2, "acked")]
+	if stored == nil || stored.RawICAL != ical || rr.Header().Get("ETag") == "" {
+		t.Fatalf("expected the calendar data to be stored verbatim, got:\n%v", stored)
+	}
+}
+
+func TestPutProvisionsConferenceAndOmitsETag(t *testing.T) {
+	failing := false
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"url":"https://meet.example.com/dav"}`)
+	}))
+	defer hook.Close()
+	defer func(client *http.Client) { events.ConferenceClient = client }(events.ConferenceClient)
+	events.ConferenceClient = hook.Client()
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	hooks := &fakeConferenceHookRepo{hook: &store.ConferenceHook{CalendarID: 2, URL: hook.URL}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, ConferenceHooks: hooks}}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:meet\r\nDTSTAMP:20260101T000000Z\r\n" +
+		"DTSTART:20260105T090000Z\r\nDTEND:20260105T100000Z\r\nSUMMARY:Sync\r\nX-CALCARD-REQUEST-CONFERENCE:TRUE\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/meet.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+
+	h.Put(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != "" {
+		t.Fatalf("expected no ETag for a provisioned event, got %q", etag)
+	}
+	stored := eventRepo.Events[eventRepo.Key(2, "meet")]
+	if stored == nil || !strings.Contains(stored.RawICAL, "CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:https://meet.example.com/dav") ||
+		strings.Contains(stored.RawICAL, "X-CALCARD-REQUEST-CONFERENCE") {
+		t.Fatalf("expected the provisioned link to be stored, got %+v", stored)
+	}
+
+	failing = true
+	req = newCalendarPutRequest("/dav/calendars/2/other.ics", strings.NewReader(strings.ReplaceAll(ical, "UID:meet", "UID:other")))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusBadGateway || eventRepo.Events[eventRepo.Key(2, "other")] != nil {
+		t.Fatalf("expected 502 without saving when the hook fails, got %d", rr.Code)
+	}
+}
+
+type fakeConferenceHookRepo struct {
+	store.ConferenceHookRepository
+	hook *store.ConferenceHook
+}
+
+func (f *fakeConferenceHookRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.ConferenceHook, error) {
+	if f.hook != nil && f.hook.CalendarID == calendarID {
+		return f.hook, nil
+	}
+	return nil, nil
 }
 
 func TestPutRejectsCalendarWriteWithoutEditor(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Work"}, Shared: true, Editor: false},
 		},
 	}
 	aclRepo := &fakeACLRepo{entries: []store.ACLEntry{
 		{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 	}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, ACLEntries: aclRepo}}
 	u := &store.User{ID: 1}
 
 	req := newCalendarPutRequest("/dav/calendars/2/new.ics", strings.NewReader("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:new\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
@@ -1297,12 +1531,12 @@ func TestPutRejectsCalendarWriteWithoutEditor(t *testing.T) {
 
 func TestPutCreatesContact(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+	contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 	u := &store.User{ID: 1}
 
@@ -1319,22 +1553,112 @@ func TestPutCreatesContact(t *testing.T) {
 	if rr.Header().Get("ETag") == "" {
 		t.Fatal("expected ETag header")
 	}
-	if _, ok := contactRepo.contacts[contactRepo.key(5, "alice")]; !ok {
+	if _, ok := contactRepo.Contacts[contactRepo.Key(5, "alice")]; !ok {
 		t.Fatal("contact not stored via Upsert")
 	}
 }
 
+func TestPutStripsControlCharactersAndOmitsETag(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: store.Now()}}}
+	contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, AddressBooks: bookRepo, Contacts: contactRepo}}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:kb\r\n" +
+		"DTSTART:20260105T090000Z\r\nSUMMARY:Café 🎂\x08\x1f \u202bעברית\u202c\xed\xb0\x80\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/kb.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if etag := rr.Header().Get("ETag"); etag != "" {
+		t.Fatalf("expected no ETag for a rewritten body, got %q", etag)
+	}
+	stored := eventRepo.Events[eventRepo.Key(2, "kb")]
+	if stored == nil || !strings.Contains(stored.RawICAL, "SUMMARY:Café 🎂 \u202bעברית\u202c\uFFFD\r\n") {
+		t.Fatalf("expected sanitized summary, got %+v", stored)
+	}
+
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:bob\r\nFN:Bob\x00 😀\r\nEND:VCARD\r\n"
+	req = httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/bob.vcf", strings.NewReader(vcard))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusCreated || rr.Header().Get("ETag") != "" {
+		t.Fatalf("expected 201 without ETag, got %d %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+	if contact := contactRepo.Contacts[contactRepo.Key(5, "bob")]; contact == nil || !strings.Contains(contact.RawVCard, "FN:Bob 😀\r\n") {
+		t.Fatalf("expected sanitized name, got %+v", contact)
+	}
+}
+
+type fakeScanner struct{ err error }
+
+func (f fakeScanner) Scan(_ context.Context, r io.Reader) error {
+	data, _ := io.ReadAll(r)
+	if strings.Contains(string(data), "EICAR") {
+		return &scan.InfectedError{Signature: "Eicar-Test-Signature"}
+	}
+	return f.err
+}
+
+func TestPutRejectsInfectedInlineAttachments(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	st := &store.Store{Calendars: calRepo, Events: eventRepo, Scanner: fakeScanner{}}
+	h := &Handler{store: st}
+
+	put := func(name, content string) *httptest.ResponseRecorder {
+		ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VEVENT\r\nUID:" + name + "\r\n" +
+			"DTSTART:20260105T090000Z\r\nSUMMARY:Trip\r\n" +
+			"ATTACH;FMTTYPE=text/plain;ENCODING=BASE64;VALUE=BINARY:" + base64.StdEncoding.EncodeToString([]byte(content)) + "\r\n" +
+			"END:VEVENT\r\nEND:VCALENDAR\r\n"
+		req := newCalendarPutRequest("/dav/calendars/2/"+name+".ics", strings.NewReader(ical))
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Put(rr, req)
+		return rr
+	}
+
+	if rr := put("clean", "boarding pass"); rr.Code != http.StatusCreated {
+		t.Fatalf("expected clean attachment stored, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := put("infected", "X5O!P%@AP EICAR test")
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "<X:malware-free/>") || !strings.Contains(rr.Body.String(), "Eicar-Test-Signature found") {
+		t.Fatalf("expected 403 malware-free, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if eventRepo.Events[eventRepo.Key(2, "infected")] != nil {
+		t.Fatal("infected event was stored")
+	}
+
+	st.Scanner = fakeScanner{err: errors.New("clamd is down")}
+	if rr := put("unscanned", "boarding pass"); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 while the scanner is down, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
 func TestDeleteCalendarEventHonorsEditor(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Work"}, Shared: true, Editor: false},
 		},
 	}
 	aclRepo := &fakeACLRepo{entries: []store.ACLEntry{
 		{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 	}}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:old": {CalendarID: 2, UID: "old"},
 		},
 	}
@@ -1352,7 +1676,7 @@ func TestDeleteCalendarEventHonorsEditor(t *testing.T) {
 	}
 
 	// Grant editor and delete
-	calRepo.accessible[0].Editor = true
+	calRepo.Accessible[0].Editor = true
 	aclRepo.entries = append(aclRepo.entries, store.ACLEntry{
 		ResourcePath:  "/dav/calendars/2",
 		PrincipalHref: "/dav/principals/1/",
@@ -1364,13 +1688,13 @@ func TestDeleteCalendarEventHonorsEditor(t *testing.T) {
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected 204, got %d", rr.Code)
 	}
-	if _, ok := eventRepo.events[eventRepo.key(2, "old")]; ok {
+	if _, ok := eventRepo.Events[eventRepo.Key(2, "old")]; ok {
 		t.Fatal("event should be deleted")
 	}
 }
 
 func TestMkcolCreatesAddressBook(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 	u := &store.User{ID: 1}
 	req := httptest.NewRequest("MKCOL", "/dav/addressbooks/NewBook", nil)
@@ -1382,13 +1706,13 @@ func TestMkcolCreatesAddressBook(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201, got %d", rr.Code)
 	}
-	if len(bookRepo.books) != 1 {
-		t.Fatalf("expected book to be created, got %d", len(bookRepo.books))
+	if len(bookRepo.Books) != 1 {
+		t.Fatalf("expected book to be created, got %d", len(bookRepo.Books))
 	}
 }
 
 func TestMkcalendarCreatesCalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	u := &store.User{ID: 1}
 	req := httptest.NewRequest("MKCALENDAR", "/dav/calendars/NewCal", nil)
@@ -1400,13 +1724,13 @@ func TestMkcalendarCreatesCalendar(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201, got %d", rr.Code)
 	}
-	if len(calRepo.calendars) != 1 {
-		t.Fatalf("expected calendar to be created, got %d", len(calRepo.calendars))
+	if len(calRepo.Calendars) != 1 {
+		t.Fatalf("expected calendar to be created, got %d", len(calRepo.Calendars))
 	}
 }
 
 func TestMkcalendarParsesChunkedRequestBody(t *testing.T) {
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	u := &store.User{ID: 1}
 	body := `<?xml version="1.0" encoding="utf-8" ?>
@@ -1428,15 +1752,15 @@ func TestMkcalendarParsesChunkedRequestBody(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201, got %d", rr.Code)
 	}
-	if len(calRepo.calendars) != 1 {
-		t.Fatalf("expected calendar to be created, got %d", len(calRepo.calendars))
+	if len(calRepo.Calendars) != 1 {
+		t.Fatalf("expected calendar to be created, got %d", len(calRepo.Calendars))
 	}
 }
 
 func TestMkcalendarRejectsSlugNameCollisions(t *testing.T) {
 	slug := "team"
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work", Slug: &slug}, Editor: true},
 		},
 	}
@@ -1461,7 +1785,7 @@ func TestMkcalendarRejectsSlugNameCollisions(t *testing.T) {
 }
 
 func TestMkcalendarRequiresParentCollectionLockToken(t *testing.T) {
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	lockRepo := &fakeLockRepo{
 		locks: map[string]*store.Lock{
 			"opaquelocktoken:root": {
@@ -1498,22 +1822,22 @@ func TestMkcalendarRequiresParentCollectionLockToken(t *testing.T) {
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("expected 201 with parent collection lock token, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(calRepo.calendars) != 1 {
-		t.Fatalf("expected calendar to be created once lock token was supplied, got %d", len(calRepo.calendars))
+	if len(calRepo.Calendars) != 1 {
+		t.Fatalf("expected calendar to be created once lock token was supplied, got %d", len(calRepo.Calendars))
 	}
 }
 
 func TestAddressBookMultiGetFiltersByBook(t *testing.T) {
-	repo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	repo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"2:keep": {AddressBookID: 2, UID: "keep", RawVCard: "VCARD", ETag: "etag-1"},
 			"3:skip": {AddressBookID: 3, UID: "skip", RawVCard: "VCARD", ETag: "etag-2"},
 		},
 	}
-	bookRepo := &fakeAddressBookRepo{books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{
 		2: {ID: 2, UserID: 1, Name: "Book"},
 	}}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: repo, DeletedResources: &storetest.DeletedResources{}}}
 	hrefs := []string{"/dav/addressbooks/2/keep.vcf", "/dav/addressbooks/3/skip.vcf"}
 	responses, err := h.addressBookMultiGet(context.Background(), &store.User{ID: 1}, 2, hrefs, "/dav/addressbooks/2/")
 	if err != nil {
@@ -1534,15 +1858,15 @@ func TestAddressBookMultiGetFiltersByBook(t *testing.T) {
 }
 
 func TestAddressBookMultiGetMissingReturns404(t *testing.T) {
-	repo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	repo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"2:present": {AddressBookID: 2, UID: "present", RawVCard: "VCARD", ETag: "etag-1"},
 		},
 	}
-	bookRepo := &fakeAddressBookRepo{books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{
 		2: {ID: 2, UserID: 1, Name: "Book"},
 	}}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: repo, DeletedResources: &storetest.DeletedResources{}}}
 	hrefs := []string{"/dav/addressbooks/2/present.vcf", "/dav/addressbooks/2/missing.vcf"}
 	responses, err := h.addressBookMultiGet(context.Background(), &store.User{ID: 1}, 2, hrefs, "/dav/addressbooks/2/")
 	if err != nil {
@@ -1558,13 +1882,13 @@ func TestAddressBookMultiGetMissingReturns404(t *testing.T) {
 
 func TestAddressBookSyncCollectionIncludesDeleted(t *testing.T) {
 	now := store.Now()
-	contacts := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contacts := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alive": {AddressBookID: 5, UID: "alive", RawVCard: "VCARD", ETag: "e", LastModified: now},
 		},
 	}
-	deleted := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deleted := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "contact", CollectionID: 5, UID: "gone", DeletedAt: now},
 		},
 	}
@@ -1602,13 +1926,13 @@ func TestAddressBookSyncCollectionIncludesDeleted(t *testing.T) {
 func TestCalendarSyncCollectionFiltersByModifiedSince(t *testing.T) {
 	then := time.Now().Add(-time.Hour)
 	now := time.Now()
-	repo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:old": {CalendarID: 2, UID: "old", RawICAL: "OLD", ETag: "1", LastModified: then},
 			"2:new": {CalendarID: 2, UID: "new", RawICAL: "NEW", ETag: "2", LastModified: now},
 		},
 	}
-	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
 	report := reportRequest{
 		XMLName:   xml.Name{Local: "sync-collection"},
 		SyncToken: buildSyncToken("cal", 2, then),
@@ -1627,6 +1951,95 @@ func TestCalendarSyncCollectionFiltersByModifiedSince(t *testing.T) {
 	}
 }
 
+func TestCalendarSyncCollectionHidesCancelledAndDeclinedWhenPreferred(t *testing.T) {
+	then := time.Now().Add(-time.Hour)
+	now := time.Now()
+	ical := func(uid, extra string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n" + extra + "DTSTART:20260101T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	}
+	repo := &storetest.Events{
+		Events: map[string]*store.Event{
+			"2:kept":      {CalendarID: 2, UID: "kept", RawICAL: ical("kept", ""), ETag: "1", LastModified: now},
+			"2:cancelled": {CalendarID: 2, UID: "cancelled", RawICAL: ical("cancelled", "STATUS:CANCELLED\r\n"), ETag: "2", LastModified: now},
+			"2:declined":  {CalendarID: 2, UID: "declined", RawICAL: ical("declined", "ATTENDEE;PARTSTAT=DECLINED:mailto:me@example.com\r\n"), ETag: "3", LastModified: now},
+		},
+	}
+	h := &Handler{store: &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}}}
+	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Test", CTag: 2, UpdatedAt: now}, Editor: true}
+	user := &store.User{ID: 1, PrimaryEmail: "me@example.com", SyncHideCancelled: true}
+
+	query := reportRequest{XMLName: xml.Name{Local: "calendar-query"}}
+	responses, _, err := h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", query)
+	if err != nil {
+		t.Fatalf("calendar-query returned error: %v", err)
+	}
+	if body := fmt.Sprint(responses); !strings.Contains(body, "kept.ics") || strings.Contains(body, "cancelled.ics") || strings.Contains(body, "declined.ics") {
+		t.Fatalf("expected only the kept event, got %v", body)
+	}
+
+	sync := reportRequest{XMLName: xml.Name{Local: "sync-collection"}, SyncToken: buildSyncToken("cal", 2, then)}
+	responses, _, err = h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", sync)
+	if err != nil {
+		t.Fatalf("sync-collection returned error: %v", err)
+	}
+	var removed []string
+	for _, resp := range responses {
+		if resp.Status == httpStatusNotFound {
+			removed = append(removed, resp.Href)
+		}
+	}
+	sort.Strings(removed)
+	if want := []string{"/dav/calendars/2/cancelled.ics", "/dav/calendars/2/declined.ics"}; !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected hidden events reported as removed, got %v", removed)
+	}
+
+	user.SyncHideCancelled = false
+	responses, _, err = h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", query)
+	if err != nil {
+		t.Fatalf("calendar-query returned error: %v", err)
+	}
+	if body := fmt.Sprint(responses); !strings.Contains(body, "cancelled.ics") || !strings.Contains(body, "declined.ics") {
+		t.Fatalf("expected all events without the preference, got %v", body)
+	}
+}
+
+func TestCalendarSyncCollectionRejectsTokenOlderThanSyncPreferenceChange(t *testing.T) {
+	then := time.Now().Add(-time.Hour)
+	changed := time.Now().Add(-time.Minute)
+	h := &Handler{store: &store.Store{Events: &storetest.Events{Events: map[string]*store.Event{}}, DeletedResources: &storetest.DeletedResources{}}}
+	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Test", CTag: 2, UpdatedAt: time.Now()}, Editor: true}
+	user := &store.User{ID: 1, SyncPrefsUpdatedAt: &changed}
+	report := reportRequest{XMLName: xml.Name{Local: "sync-collection"}, SyncToken: buildSyncToken("cal", 2, then)}
+	_, _, err := h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", report)
+	if !errors.Is(err, errInvalidSyncToken) {
+		t.Fatalf("expected errInvalidSyncToken, got %v", err)
+	}
+}
+
+func TestCalendarSyncCollectionAnswersStaleTokenWithFullSyncWhenLenient(t *testing.T) {
+	then := time.Now().Add(-time.Hour)
+	changed := time.Now().Add(-time.Minute)
+	events := &storetest.Events{Events: map[string]*store.Event{
+		"2:kept": {CalendarID: 2, UID: "kept", ResourceName: "kept", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "e1", LastModified: then.Add(-time.Hour)},
+	}}
+	h := &Handler{store: &store.Store{Events: events, DeletedResources: &storetest.DeletedResources{}}, lenientTokens: true}
+	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Test", CTag: 2, UpdatedAt: time.Now()}, Editor: true}
+	user := &store.User{ID: 1, SyncPrefsUpdatedAt: &changed}
+	report := reportRequest{XMLName: xml.Name{Local: "sync-collection"}, SyncToken: " " + url.PathEscape(buildSyncToken("cal", 2, then))}
+	responses, token, err := h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", report)
+	if err != nil {
+		t.Fatalf("expected a full sync, got %v", err)
+	}
+	if token == "" || !strings.Contains(fmt.Sprint(responses), "kept.ics") {
+		t.Fatalf("expected every member and a new token, got %q %v", token, responses)
+	}
+
+	report.SyncToken = buildSyncToken("cal", 3, then)
+	if _, _, err := h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", report); !errors.Is(err, errInvalidSyncToken) {
+		t.Fatalf("expected a token for another calendar to be refused, got %v", err)
+	}
+}
+
 func TestNewServerInitializesFields(t *testing.T) {
 	cfg := &config.Config{}
 	s := &store.Store{}
@@ -1637,13 +2050,13 @@ func TestNewServerInitializesFields(t *testing.T) {
 }
 
 func TestHeadDelegatesToGet(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICALDATA", ETag: "etag1"},
 		},
 	}
@@ -1673,11 +2086,11 @@ func TestProppatchRequiresAuth(t *testing.T) {
 }
 
 func TestProppatchCalendarUpdatesProperties(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Old Name"}, Editor: true},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: 1, Name: "Old Name"},
 		},
 	}
@@ -1710,7 +2123,7 @@ func TestProppatchCalendarUpdatesProperties(t *testing.T) {
 }
 
 func TestProppatchCalendarRejectsSlugPath(t *testing.T) {
-	h := &Handler{store: &store.Store{Calendars: &fakeCalendarRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: &storetest.Calendars{}}}
 	u := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPPATCH", "/dav/calendars/work", nil)
@@ -1726,8 +2139,8 @@ func TestProppatchCalendarRejectsSlugPath(t *testing.T) {
 
 func TestPropfindRejectsAmbiguousCalendarSlug(t *testing.T) {
 	slug := "work"
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 2, Name: "Work", Slug: &slug}, Editor: false},
 			{Calendar: store.Calendar{ID: 2, UserID: 3, Name: "Work", Slug: &slug}, Editor: false},
 		},
@@ -1751,12 +2164,12 @@ func TestPropfindRejectsAmbiguousCalendarSlug(t *testing.T) {
 }
 
 func TestGetNotFoundReturns404(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	req := httptest.NewRequest(http.MethodGet, "/dav/calendars/2/missing.ics", nil)
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -1833,12 +2246,12 @@ func TestReportUnsupportedPath(t *testing.T) {
 }
 
 func TestReportCalendarInvalidSyncToken(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, DeletedResources: &storetest.DeletedResources{}}}
 	req := httptest.NewRequest("REPORT", "/dav/calendars/1/", strings.NewReader(`<D:sync-collection xmlns:D="DAV:"><D:sync-token>urn:calcard-sync:card:1:0</D:sync-token></D:sync-collection>`))
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -1851,13 +2264,13 @@ func TestReportCalendarInvalidSyncToken(t *testing.T) {
 }
 
 func TestPutUpdatesExistingEventReturnsNoContent(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "old"},
 		},
 	}
@@ -1955,15 +2368,15 @@ func TestMkcalendarValidatesPathAndName(t *testing.T) {
 }
 
 func TestLoadCalendarNotFound(t *testing.T) {
-	h := &Handler{store: &store.Store{Calendars: &fakeCalendarRepo{accessible: []store.CalendarAccess{}}}}
+	h := &Handler{store: &store.Store{Calendars: &storetest.Calendars{Accessible: []store.CalendarAccess{}}}}
 	if _, err := h.loadCalendar(context.Background(), &store.User{ID: 1}, 10); !errors.Is(err, store.ErrNotFound) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
 
 func TestLoadAddressBookWrongUser(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			1: {ID: 1, UserID: 2, Name: "Other"},
 		},
 	}
@@ -1993,6 +2406,25 @@ func TestCdataStringEmptySkipsEncoding(t *testing.T) {
 	}
 }
 
+func TestCdataStringEncodesOnlyWellFormedXML(t *testing.T) {
+	summary := "SUMMARY:🎉 ‫מסיבה‬\x0b\x1b party\xed\xa0\xbd"
+	var buf strings.Builder
+	enc := xml.NewEncoder(&buf)
+	if err := cdataString(summary).MarshalXML(enc, xml.StartElement{Name: xml.Name{Local: "calendar-data"}}); err != nil {
+		t.Fatalf("MarshalXML returned error: %v", err)
+	}
+	enc.Flush()
+	var decoded struct {
+		Data string `xml:",chardata"`
+	}
+	if err := xml.Unmarshal([]byte(buf.String()), &decoded); err != nil {
+		t.Fatalf("output is not well-formed XML: %v\n%q", err, buf.String())
+	}
+	if want := "SUMMARY:🎉 ‫מסיבה‬ party\uFFFD"; decoded.Data != want {
+		t.Fatalf("decoded %q, want %q", decoded.Data, want)
+	}
+}
+
 func TestPropfindRequiresUser(t *testing.T) {
 	h := &Handler{}
 	req := httptest.NewRequest("PROPFIND", "/dav", nil)
@@ -2015,12 +2447,12 @@ func TestPropfindUnsupportedPathReturnsNotFound(t *testing.T) {
 }
 
 func TestGetAddressBookNotFoundForWrongUser(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 2, Name: "Other"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	req := httptest.NewRequest(http.MethodGet, "/dav/addressbooks/5/alice.vcf", nil)
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2041,8 +2473,8 @@ func TestPutRequiresUser(t *testing.T) {
 }
 
 func TestPutAddressBookNotFound(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	req := httptest.NewRequest(http.MethodPut, "/dav/addressbooks/9/alice.vcf", strings.NewReader("VCARD"))
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2053,7 +2485,7 @@ func TestPutAddressBookNotFound(t *testing.T) {
 }
 
 func TestDeleteCalendarNotFound(t *testing.T) {
-	h := &Handler{store: &store.Store{Calendars: &fakeCalendarRepo{accessible: []store.CalendarAccess{}}, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: &storetest.Calendars{Accessible: []store.CalendarAccess{}}, Events: &storetest.Events{}}}
 	req := httptest.NewRequest(http.MethodDelete, "/dav/calendars/1/e.ics", nil)
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2065,18 +2497,18 @@ func TestDeleteCalendarNotFound(t *testing.T) {
 
 func TestReportCalendarSyncCollectionViaHandler(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "e", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "event", CollectionID: 2, UID: "gone", DeletedAt: now},
 		},
 	}
@@ -2097,13 +2529,13 @@ func TestReportCalendarSyncCollectionViaHandler(t *testing.T) {
 
 func TestReportCalendarSyncCollectionReturnsTombstoneForACLHiddenEvent(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: 9, Name: "Shared", CTag: 4, UpdatedAt: now},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:hidden": {
 				CalendarID:   2,
 				UID:          "hidden",
@@ -2117,7 +2549,7 @@ func TestReportCalendarSyncCollectionReturnsTombstoneForACLHiddenEvent(t *testin
 	h := &Handler{store: &store.Store{
 		Calendars:        calRepo,
 		Events:           eventRepo,
-		DeletedResources: &fakeDeletedResourceRepo{},
+		DeletedResources: &storetest.DeletedResources{},
 		ACLEntries: &fakeACLRepo{entries: []store.ACLEntry{
 			{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 			{ResourcePath: "/dav/calendars/2/hidden", PrincipalHref: "/dav/principals/1/", IsGrant: false, Privilege: "read"},
@@ -2145,13 +2577,13 @@ func TestReportCalendarSyncCollectionReturnsTombstoneForACLHiddenEvent(t *testin
 
 func TestReportCalendarSyncCollectionDoesNotRepeatACLHiddenTombstoneAfterTokenAdvances(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: 9, Name: "Shared", CTag: 4, UpdatedAt: now},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:hidden": {
 				CalendarID:   2,
 				UID:          "hidden",
@@ -2165,7 +2597,7 @@ func TestReportCalendarSyncCollectionDoesNotRepeatACLHiddenTombstoneAfterTokenAd
 	h := &Handler{store: &store.Store{
 		Calendars:        calRepo,
 		Events:           eventRepo,
-		DeletedResources: &fakeDeletedResourceRepo{},
+		DeletedResources: &storetest.DeletedResources{},
 		ACLEntries: &fakeACLRepo{entries: []store.ACLEntry{
 			{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 			{ResourcePath: "/dav/calendars/2/hidden", PrincipalHref: "/dav/principals/1/", IsGrant: false, Privilege: "read"},
@@ -2205,7 +2637,7 @@ func TestCalendarQueryBatchesACLLookupsForEventFiltering(t *testing.T) {
 		},
 	}
 	h := &Handler{store: &store.Store{
-		Events: &fakeEventRepo{events: map[string]*store.Event{
+		Events: &storetest.Events{Events: map[string]*store.Event{
 			"2:visible": {CalendarID: 2, UID: "visible", ResourceName: "visible", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:visible\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-visible"},
 			"2:hidden":  {CalendarID: 2, UID: "hidden", ResourceName: "hidden", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:hidden\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-hidden"},
 			"2:other":   {CalendarID: 2, UID: "other", ResourceName: "other", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:other\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-other"},
@@ -2236,18 +2668,18 @@ func TestCalendarQueryBatchesACLLookupsForEventFiltering(t *testing.T) {
 
 func TestReportAddressBookSyncCollectionViaHandler(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts", CTag: 1, UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", RawVCard: "VCARD", ETag: "e", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "contact", CollectionID: 3, UID: "bob", DeletedAt: now},
 		},
 	}
@@ -2268,18 +2700,18 @@ func TestReportAddressBookSyncCollectionViaHandler(t *testing.T) {
 
 func TestReportAddressBookSyncCollectionUsesStoredResourceNames(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts", CTag: 1, UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:shared-uid": {AddressBookID: 3, UID: "shared-uid", ResourceName: "first", RawVCard: "VCARD", ETag: "e", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "contact", CollectionID: 3, UID: "gone-uid", ResourceName: "former", DeletedAt: now},
 		},
 	}
@@ -2303,18 +2735,18 @@ func TestReportAddressBookSyncCollectionUsesStoredResourceNames(t *testing.T) {
 
 func TestReportAddressBookSyncCollectionPreservesDeletedObjectACLVisibility(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts", CTag: 1, UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:secret": {AddressBookID: 3, UID: "secret", ResourceName: "secret", RawVCard: buildVCard("3.0", "UID:secret", "FN:Secret Person"), ETag: "secret-etag", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "contact", CollectionID: 3, UID: "public", ResourceName: "public", DeletedAt: now},
 		},
 	}
@@ -2334,7 +2766,7 @@ func TestReportAddressBookSyncCollectionPreservesDeletedObjectACLVisibility(t *t
 	if err := st.DeleteContactAndState(context.Background(), 3, "secret", "/dav/addressbooks/3/secret"); err != nil {
 		t.Fatalf("DeleteContactAndState() error = %v", err)
 	}
-	deletedRepo.deleted = append(deletedRepo.deleted, store.DeletedResource{
+	deletedRepo.Deleted = append(deletedRepo.Deleted, store.DeletedResource{
 		ResourceType: "contact",
 		CollectionID: 3,
 		UID:          "secret",
@@ -2381,8 +2813,8 @@ func TestMkcalendarRequiresUser(t *testing.T) {
 }
 
 func TestPutCalendarNotFound(t *testing.T) {
-	calRepo := &fakeCalendarRepo{accessible: []store.CalendarAccess{}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	req := newCalendarPutRequest("/dav/calendars/9/e.ics", strings.NewReader("ICAL"))
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2397,12 +2829,12 @@ type errReader struct{}
 func (errReader) Read(p []byte) (int, error) { return 0, errors.New("boom") }
 
 func TestPutReadErrorReturnsBadRequest(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	req := newCalendarPutRequest("/dav/calendars/1/e.ics", io.NopCloser(errReader{}))
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2413,13 +2845,13 @@ func TestPutReadErrorReturnsBadRequest(t *testing.T) {
 }
 
 func TestPutUpdatesExistingContactReturnsNoContent(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:alice\r\nFN:Alice\r\nEND:VCARD\r\n", ETag: "e"},
 		},
 	}
@@ -2435,7 +2867,7 @@ func TestPutUpdatesExistingContactReturnsNoContent(t *testing.T) {
 }
 
 func TestDeleteAddressBookNotFound(t *testing.T) {
-	h := &Handler{store: &store.Store{AddressBooks: &fakeAddressBookRepo{}, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: &storetest.AddressBooks{}, Contacts: &storetest.Contacts{}}}
 	req := httptest.NewRequest(http.MethodDelete, "/dav/addressbooks/9/alice.vcf", nil)
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
 	rr := httptest.NewRecorder()
@@ -2446,8 +2878,8 @@ func TestDeleteAddressBookNotFound(t *testing.T) {
 }
 
 func TestReportCalendarNotFound(t *testing.T) {
-	calRepo := &fakeCalendarRepo{accessible: []store.CalendarAccess{}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	body := `<cal:calendar-query xmlns:cal="urn:ietf:params:xml:ns:caldav"/>`
 	req := httptest.NewRequest("REPORT", "/dav/calendars/9/", strings.NewReader(body))
 	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
@@ -2459,8 +2891,8 @@ func TestReportCalendarNotFound(t *testing.T) {
 }
 
 func TestReportAddressBookNotFound(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{books: map[int64]*store.AddressBook{}}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	body := `<card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav"><card:filter/></card:addressbook-query>`
 	req := httptest.NewRequest("REPORT", "/dav/addressbooks/9/", strings.NewReader(body))
 	req.Header.Set("Depth", "1")
@@ -2473,13 +2905,13 @@ func TestReportAddressBookNotFound(t *testing.T) {
 }
 
 func TestReportAddressBookAliasResolutionDistinguishesMissingAndPresentAliases(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 		},
 	}
@@ -2518,12 +2950,12 @@ func TestReportAddressBookAliasResolutionDistinguishesMissingAndPresentAliases(t
 }
 
 func TestReportAddressBookQueryDepthZeroRequiresAccess(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			9: {ID: 9, UserID: 1, Name: "Private"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	body := `<card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav"><card:filter/></card:addressbook-query>`
 	req := httptest.NewRequest("REPORT", "/dav/addressbooks/9/", strings.NewReader(body))
 	req.Header.Set("Depth", "0")
@@ -2538,13 +2970,13 @@ func TestReportAddressBookQueryDepthZeroRequiresAccess(t *testing.T) {
 }
 
 func TestReportCalendarMultiGetPath(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "e"},
 		},
 	}
@@ -2563,13 +2995,13 @@ func TestReportCalendarMultiGetPath(t *testing.T) {
 }
 
 func TestReportAddressBookMultiGetPath(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", RawVCard: "VCARD", ETag: "e"},
 		},
 	}
@@ -2589,13 +3021,13 @@ func TestReportAddressBookMultiGetPath(t *testing.T) {
 }
 
 func TestReportAddressBookMultiGetResolvesAliasHrefWithinNumericRequest(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"3:alice": {AddressBookID: 3, UID: "alice", ResourceName: "alice", RawVCard: "VCARD", ETag: "e"},
 		},
 	}
@@ -2620,7 +3052,7 @@ func TestReportAddressBookMultiGetResolvesAliasHrefWithinNumericRequest(t *testi
 
 func TestCalendarMultiGetReturnsErrorWhenRepoFails(t *testing.T) {
 	brokenRepo := &errorEventRepo{}
-	h := &Handler{store: &store.Store{Events: brokenRepo, DeletedResources: &fakeDeletedResourceRepo{}}}
+	h := &Handler{store: &store.Store{Events: brokenRepo, DeletedResources: &storetest.DeletedResources{}}}
 	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 1, UserID: 1}}
 	_, err := h.calendarMultiGet(context.Background(), &store.User{ID: 1}, cal, []string{"/dav/calendars/1/e.ics"}, "/dav/calendars/1/", "/dav/calendars/1/", nil)
 	if err == nil {
@@ -2630,16 +3062,16 @@ func TestCalendarMultiGetReturnsErrorWhenRepoFails(t *testing.T) {
 
 func TestCalendarCopyAndMoveToSameDestinationAreNoOps(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			eventRepo := &fakeEventRepo{
-				events: map[string]*store.Event{
+			eventRepo := &storetest.Events{
+				Events: map[string]*store.Event{
 					"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-event"},
 				},
 			}
@@ -2669,16 +3101,16 @@ func TestCalendarCopyAndMoveToSameDestinationAreNoOps(t *testing.T) {
 
 func TestContactCopyAndMoveToSameDestinationAreNoOps(t *testing.T) {
 	user := &store.User{ID: 1}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 				},
 			}
@@ -2708,26 +3140,26 @@ func TestContactCopyAndMoveToSameDestinationAreNoOps(t *testing.T) {
 
 func TestCopyAndMoveOverwriteFailurePreservesExistingDestination(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 			{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Archive"}, Editor: true},
 		},
 	}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 			6: {ID: 6, UserID: 1, Name: "Archive"},
 		},
 	}
 
 	t.Run("calendar copy", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 				"3:event": {CalendarID: 3, UID: "event", ResourceName: "copied", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Old\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 			},
-			copyErr: errors.New("copy failed"),
+			CopyErr: errors.New("copy failed"),
 		}
 		h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 
@@ -2743,18 +3175,18 @@ func TestCopyAndMoveOverwriteFailurePreservesExistingDestination(t *testing.T) {
 		if got, _ := eventRepo.GetByResourceName(req.Context(), 3, "copied"); got == nil || got.ETag != "etag-dest" {
 			t.Fatalf("expected existing destination event to be preserved, got %#v", got)
 		}
-		if len(eventRepo.deleted) != 0 {
-			t.Fatalf("expected no eager destination delete, got %#v", eventRepo.deleted)
+		if len(eventRepo.Deleted) != 0 {
+			t.Fatalf("expected no eager destination delete, got %#v", eventRepo.Deleted)
 		}
 	})
 
 	t.Run("calendar move", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 				"3:event": {CalendarID: 3, UID: "event", ResourceName: "moved", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Old\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 			},
-			moveErr: errors.New("move failed"),
+			MoveErr: errors.New("move failed"),
 		}
 		h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 
@@ -2770,18 +3202,18 @@ func TestCopyAndMoveOverwriteFailurePreservesExistingDestination(t *testing.T) {
 		if got, _ := eventRepo.GetByResourceName(req.Context(), 3, "moved"); got == nil || got.ETag != "etag-dest" {
 			t.Fatalf("expected existing destination event to be preserved, got %#v", got)
 		}
-		if len(eventRepo.deleted) != 0 {
-			t.Fatalf("expected no eager destination delete, got %#v", eventRepo.deleted)
+		if len(eventRepo.Deleted) != 0 {
+			t.Fatalf("expected no eager destination delete, got %#v", eventRepo.Deleted)
 		}
 	})
 
 	t.Run("contact copy", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-source"},
 				"6:alice": {AddressBookID: 6, UID: "alice", ResourceName: "copied", RawVCard: buildVCard("3.0", "UID:alice", "FN:Old Alice"), ETag: "etag-dest"},
 			},
-			copyErr: errors.New("copy failed"),
+			CopyErr: errors.New("copy failed"),
 		}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
@@ -2797,18 +3229,18 @@ func TestCopyAndMoveOverwriteFailurePreservesExistingDestination(t *testing.T) {
 		if got, _ := contactRepo.GetByResourceName(req.Context(), 6, "copied"); got == nil || got.ETag != "etag-dest" {
 			t.Fatalf("expected existing destination contact to be preserved, got %#v", got)
 		}
-		if len(contactRepo.deleted) != 0 {
-			t.Fatalf("expected no eager destination delete, got %#v", contactRepo.deleted)
+		if len(contactRepo.Deleted) != 0 {
+			t.Fatalf("expected no eager destination delete, got %#v", contactRepo.Deleted)
 		}
 	})
 
 	t.Run("contact move", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-source"},
 				"6:alice": {AddressBookID: 6, UID: "alice", ResourceName: "moved", RawVCard: buildVCard("3.0", "UID:alice", "FN:Old Alice"), ETag: "etag-dest"},
 			},
-			moveErr: errors.New("move failed"),
+			MoveErr: errors.New("move failed"),
 		}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
@@ -2824,8 +3256,8 @@ func TestCopyAndMoveOverwriteFailurePreservesExistingDestination(t *testing.T) {
 		if got, _ := contactRepo.GetByResourceName(req.Context(), 6, "moved"); got == nil || got.ETag != "etag-dest" {
 			t.Fatalf("expected existing destination contact to be preserved, got %#v", got)
 		}
-		if len(contactRepo.deleted) != 0 {
-			t.Fatalf("expected no eager destination delete, got %#v", contactRepo.deleted)
+		if len(contactRepo.Deleted) != 0 {
+			t.Fatalf("expected no eager destination delete, got %#v", contactRepo.Deleted)
 		}
 	})
 }
@@ -2834,14 +3266,14 @@ func TestMoveRebindsACLStateAndRollsBackOnACLRebindFailure(t *testing.T) {
 	user := &store.User{ID: 1}
 
 	t.Run("calendar move rebinding succeeds", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessible: []store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			Accessible: []store.CalendarAccess{
 				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 				{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Archive"}, Editor: true},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			},
 		}
@@ -2876,24 +3308,24 @@ func TestMoveRebindsACLStateAndRollsBackOnACLRebindFailure(t *testing.T) {
 	})
 
 	t.Run("calendar move rolls back when ACL rebind fails", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessible: []store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			Accessible: []store.CalendarAccess{
 				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 				{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Archive"}, Editor: true},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Source\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			},
 		}
-		deletedRepo := &fakeDeletedResourceRepo{}
+		deletedRepo := &storetest.DeletedResources{}
 		aclRepo := &fakeACLRepo{
 			entries: []store.ACLEntry{
 				{ResourcePath: "/dav/calendars/2/event", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 			},
 			moveResourcePathHook: func(fromPath, toPath string) {
-				deletedRepo.deleted = []store.DeletedResource{
+				deletedRepo.Deleted = []store.DeletedResource{
 					{ResourceType: "event", CollectionID: 2, UID: "event", ResourceName: "event", DeletedAt: time.Now()},
 					{ResourceType: "event", CollectionID: 3, UID: "event", ResourceName: "moved", DeletedAt: time.Now()},
 				}
@@ -2932,14 +3364,14 @@ func TestMoveRebindsACLStateAndRollsBackOnACLRebindFailure(t *testing.T) {
 	})
 
 	t.Run("contact move rebinding succeeds", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 				6: {ID: 6, UserID: 1, Name: "Archive"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-source"},
 			},
 		}
@@ -2974,24 +3406,24 @@ func TestMoveRebindsACLStateAndRollsBackOnACLRebindFailure(t *testing.T) {
 	})
 
 	t.Run("contact move rolls back when ACL rebind fails", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 				6: {ID: 6, UserID: 1, Name: "Archive"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-source"},
 			},
 		}
-		deletedRepo := &fakeDeletedResourceRepo{}
+		deletedRepo := &storetest.DeletedResources{}
 		aclRepo := &fakeACLRepo{
 			entries: []store.ACLEntry{
 				{ResourcePath: "/dav/addressbooks/5/alice", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 			},
 			moveResourcePathHook: func(fromPath, toPath string) {
-				deletedRepo.deleted = []store.DeletedResource{
+				deletedRepo.Deleted = []store.DeletedResource{
 					{ResourceType: "contact", CollectionID: 5, UID: "alice", ResourceName: "alice", DeletedAt: time.Now()},
 					{ResourceType: "contact", CollectionID: 6, UID: "alice", ResourceName: "moved", DeletedAt: time.Now()},
 				}
@@ -3033,14 +3465,14 @@ func TestMoveRebindsACLStateAndRollsBackOnACLRebindFailure(t *testing.T) {
 func TestMoveCalendarEventRequiresSourceReadPrivilegeBeforeLookup(t *testing.T) {
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: delegate.ID, Name: "Destination"},
 			9: {ID: 9, UserID: owner.ID, Name: "Shared"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"9:secret": {
 				CalendarID:   9,
 				UID:          "secret",
@@ -3069,8 +3501,8 @@ func TestMoveCalendarEventRequiresSourceReadPrivilegeBeforeLookup(t *testing.T)
 	if rr.Code != http.StatusForbidden {
 		t.Fatalf("expected source read denial to return 403, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if eventRepo.resourceLookupCount != 0 {
-		t.Fatalf("expected MOVE to reject denied source before loading the event, got %d lookups", eventRepo.resourceLookupCount)
+	if eventRepo.ResourceLookupCount != 0 {
+		t.Fatalf("expected MOVE to reject denied source before loading the event, got %d lookups", eventRepo.ResourceLookupCount)
 	}
 }
 
@@ -3078,8 +3510,8 @@ func TestPropfindCalendarCollectionDoesNotOverAdvertisePartialWritePrivileges(t
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			delegate.ID: {
 				{
 					Calendar:   store.Calendar{ID: 5, UserID: owner.ID, Name: "Shared", UpdatedAt: now},
@@ -3089,7 +3521,7 @@ func TestPropfindCalendarCollectionDoesNotOverAdvertisePartialWritePrivileges(t
 				},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared", UpdatedAt: now},
 		},
 	}
@@ -3127,8 +3559,8 @@ func TestPropfindCalendarCollectionDoesNotOverAdvertisePartialWritePrivileges(t
 func TestCurrentUserPrivilegeSetForCalendarOmitsDeniedReadFreeBusy(t *testing.T) {
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared"},
 		},
 	}
@@ -3160,8 +3592,8 @@ func TestPropfindListsAndLoadsBindOnlyCalendarCollections(t *testing.T) {
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			delegate.ID: {
 				{
 					Calendar:   store.Calendar{ID: 8, UserID: owner.ID, Name: "Inbox", UpdatedAt: now},
@@ -3170,14 +3602,14 @@ func TestPropfindListsAndLoadsBindOnlyCalendarCollections(t *testing.T) {
 				},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			8: {ID: 8, UserID: owner.ID, Name: "Inbox", UpdatedAt: now},
 		},
 	}
 	aclRepo := &fakeACLRepo{entries: []store.ACLEntry{
 		{ResourcePath: "/dav/calendars/8", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "bind"},
 	}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, ACLEntries: aclRepo}}
 
 	rootReq := httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
 	rootReq.Header.Set("Depth", "1")
@@ -3218,8 +3650,8 @@ func TestPropfindListsAndLoadsPartialAccessCalendarCollections(t *testing.T) {
 	now := store.Now()
 	reviewSlug := "review"
 
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			delegate.ID: {
 				{
 					Calendar:   store.Calendar{ID: 9, UserID: owner.ID, Name: "Drafts", UpdatedAt: now},
@@ -3238,7 +3670,7 @@ func TestPropfindListsAndLoadsPartialAccessCalendarCollections(t *testing.T) {
 				},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			9:  {ID: 9, UserID: owner.ID, Name: "Drafts", UpdatedAt: now},
 			10: {ID: 10, UserID: owner.ID, Name: "Archive", UpdatedAt: now},
 			11: {ID: 11, UserID: owner.ID, Name: "Review", Slug: &reviewSlug, UpdatedAt: now},
@@ -3249,7 +3681,7 @@ func TestPropfindListsAndLoadsPartialAccessCalendarCollections(t *testing.T) {
 		{ResourcePath: "/dav/calendars/10", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "unbind"},
 		{ResourcePath: "/dav/calendars/11", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "write-content"},
 	}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, ACLEntries: aclRepo}}
 
 	rootReq := httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
 	rootReq.Header.Set("Depth", "1")
@@ -3306,18 +3738,18 @@ func TestPropfindDiscoveryIncludesObjectGrantedCalendars(t *testing.T) {
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 12, UserID: owner.ID, Name: "Object Shared", UpdatedAt: now}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			12: {ID: 12, UserID: owner.ID, Name: "Object Shared", UpdatedAt: now},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"12:special": {
 				CalendarID:   12,
 				UID:          "special",
@@ -3375,18 +3807,18 @@ func TestPropfindDiscoveryExcludesCalendarsWithOnlyObjectLevelDenyACE(t *testing
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 13, UserID: owner.ID, Name: "Hidden By Deny", UpdatedAt: now}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			13: {ID: 13, UserID: owner.ID, Name: "Hidden By Deny", UpdatedAt: now},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"13:secret": {
 				CalendarID:   13,
 				UID:          "secret",
@@ -3418,22 +3850,22 @@ func TestPropfindDiscoveryExcludesCalendarsWithOnlyObjectLevelDenyACE(t *testing
 
 func TestCopyGeneratesFreshETagsOnRepeatedOverwrite(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 			{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Archive"}, Editor: true},
 		},
 	}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 			6: {ID: 6, UserID: 1, Name: "Archive"},
 		},
 	}
 
 	t.Run("calendar", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 				"3:event": {CalendarID: 3, UID: "event", ResourceName: "copied", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Old\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 			},
@@ -3466,8 +3898,8 @@ func TestCopyGeneratesFreshETagsOnRepeatedOverwrite(t *testing.T) {
 	})
 
 	t.Run("contact", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-source"},
 				"6:alice": {AddressBookID: 6, UID: "alice", ResourceName: "copied", RawVCard: buildVCard("3.0", "UID:alice", "FN:Old Alice"), ETag: "etag-dest"},
 			},
@@ -3502,16 +3934,16 @@ func TestCopyGeneratesFreshETagsOnRepeatedOverwrite(t *testing.T) {
 
 func TestCalendarCopyAndMoveFailClosedOnDestinationLookupErrors(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 			{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Archive"}, Editor: true},
 		},
 	}
 
 	t.Run("positive copy succeeds when destination lookups succeed", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			},
 		}
@@ -3532,13 +3964,13 @@ func TestCalendarCopyAndMoveFailClosedOnDestinationLookupErrors(t *testing.T) {
 	})
 
 	t.Run("negative copy returns 500 on destination resource lookup error", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 				"3:event": {CalendarID: 3, UID: "event", ResourceName: "copied", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Old\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 			},
-			getByResourceNameErr: errors.New("resource lookup failed"),
-			getByResourceNameKey: "3:copied",
+			GetByResourceNameErr: errors.New("resource lookup failed"),
+			GetByResourceNameKey: "3:copied",
 		}
 		h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 
@@ -3551,7 +3983,7 @@ func TestCalendarCopyAndMoveFailClosedOnDestinationLookupErrors(t *testing.T) {
 		if rr.Code != http.StatusInternalServerError {
 			t.Fatalf("expected destination lookup failure to return 500, got %d: %s", rr.Code, rr.Body.String())
 		}
-		eventRepo.getByResourceNameErr = nil
+		eventRepo.GetByResourceNameErr = nil
 		if dest, _ := eventRepo.GetByResourceName(req.Context(), 3, "copied"); dest == nil || dest.ETag != "etag-dest" {
 			t.Fatalf("expected destination event to remain unchanged, got %#v", dest)
 		}
@@ -3561,13 +3993,13 @@ func TestCalendarCopyAndMoveFailClosedOnDestinationLookupErrors(t *testing.T) {
 	})
 
 	t.Run("negative move returns 500 on destination UID lookup error", func(t *testing.T) {
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 				"3:event": {CalendarID: 3, UID: "event", ResourceName: "moved", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nSUMMARY:Old\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 			},
-			getByUIDErr:    errors.New("uid lookup failed"),
-			getByUIDErrKey: "3:event",
+			GetByUIDErr:    errors.New("uid lookup failed"),
+			GetByUIDErrKey: "3:event",
 		}
 		h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 
@@ -3580,7 +4012,7 @@ func TestCalendarCopyAndMoveFailClosedOnDestinationLookupErrors(t *testing.T) {
 		if rr.Code != http.StatusInternalServerError {
 			t.Fatalf("expected destination UID lookup failure to return 500, got %d: %s", rr.Code, rr.Body.String())
 		}
-		eventRepo.getByUIDErr = nil
+		eventRepo.GetByUIDErr = nil
 		if dest, _ := eventRepo.GetByResourceName(req.Context(), 3, "moved"); dest == nil || dest.ETag != "etag-dest" {
 			t.Fatalf("expected destination event to remain unchanged, got %#v", dest)
 		}
@@ -3594,13 +4026,13 @@ func TestCopyWithinSameCollectionRejectsRebindingSameUID(t *testing.T) {
 	user := &store.User{ID: 1}
 
 	t.Run("calendar", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessible: []store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			Accessible: []store.CalendarAccess{
 				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "original", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			},
 		}
@@ -3630,13 +4062,13 @@ func TestCopyWithinSameCollectionRejectsRebindingSameUID(t *testing.T) {
 	})
 
 	t.Run("contact", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "original", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-source"},
 			},
 		}
@@ -3674,14 +4106,14 @@ func TestCalendarCopyAndMoveRejectDestinationUIDConflict(t *testing.T) {
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			calRepo := &fakeCalendarRepo{
-				accessible: []store.CalendarAccess{
+			calRepo := &storetest.Calendars{
+				Accessible: []store.CalendarAccess{
 					{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Source"}, Editor: true},
 					{Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Destination"}, Editor: true},
 				},
 			}
-			eventRepo := &fakeEventRepo{
-				events: map[string]*store.Event{
+			eventRepo := &storetest.Events{
+				Events: map[string]*store.Event{
 					"2:event": {CalendarID: 2, UID: "event", ResourceName: "original", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 					"3:event": {CalendarID: 3, UID: "event", ResourceName: "existing", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 				},
@@ -3724,13 +4156,13 @@ func TestCalendarCopyAndMoveRejectDestinationUIDConflict(t *testing.T) {
 
 func TestMoveCalendarEventOverwriteWithinSameCalendarReplacesDestination(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:source":      {CalendarID: 2, UID: "source", ResourceName: "original", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:source\r\nSUMMARY:Source\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			"2:destination": {CalendarID: 2, UID: "destination", ResourceName: "renamed", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:destination\r\nSUMMARY:Destination\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 		},
@@ -3766,18 +4198,18 @@ func TestMoveCalendarEventOverwriteWithinSameCalendarReplacesDestination(t *test
 
 func TestMoveCalendarEventOverwriteClearsDestinationTombstone(t *testing.T) {
 	user := &store.User{ID: 1}
-	deletedRepo := &fakeDeletedResourceRepo{}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	deletedRepo := &storetest.DeletedResources{}
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:source":      {CalendarID: 2, UID: "source", ResourceName: "original", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:source\r\nSUMMARY:Source\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-source"},
 			"2:destination": {CalendarID: 2, UID: "destination", ResourceName: "renamed", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:destination\r\nSUMMARY:Destination\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-dest"},
 		},
-		overwriteMoveDeletedRepo: deletedRepo,
+		OverwriteMoveDeleted: deletedRepo,
 	}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, DeletedResources: deletedRepo}}
 
@@ -3804,16 +4236,16 @@ func TestMoveCalendarEventOverwriteClearsDestinationTombstone(t *testing.T) {
 func TestCanonicalDAVPathUsesExtensionlessResourceIdentity(t *testing.T) {
 	slug := "work"
 	h := &Handler{store: &store.Store{
-		Calendars: &fakeCalendarRepo{
-			accessible: []store.CalendarAccess{
+		Calendars: &storetest.Calendars{
+			Accessible: []store.CalendarAccess{
 				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", Slug: &slug}, Editor: true},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: 1, Name: "Work", Slug: &slug},
 			},
 		},
-		AddressBooks: &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		AddressBooks: &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 			},
 		},
@@ -3860,13 +4292,13 @@ func TestNormalizeDAVResourceIdentityPreservesDottedCanonicalNames(t *testing.T)
 }
 
 func TestDeleteRejectsCanonicalResourceLockAcrossExtensions(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice"},
 		},
 	}
@@ -3890,13 +4322,13 @@ func TestDeleteRejectsCanonicalResourceLockAcrossExtensions(t *testing.T) {
 }
 
 func TestGetRejectsCanonicalResourceACLAcrossExtensions(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice"},
 		},
 	}
@@ -3921,8 +4353,8 @@ func TestGetRejectsCanonicalResourceACLAcrossExtensions(t *testing.T) {
 
 func TestLockAndACLRejectOversizedBodies(t *testing.T) {
 	user := &store.User{ID: 1}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
@@ -3959,8 +4391,8 @@ func TestLockAndACLRejectOversizedBodies(t *testing.T) {
 func TestCalendarCopyRequiresReadAccessToSource(t *testing.T) {
 	owner := &store.User{ID: 1}
 	attacker := &store.User{ID: 2}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 9, UserID: owner.ID, Name: "Private"}, Editor: true},
 			},
@@ -3968,13 +4400,13 @@ func TestCalendarCopyRequiresReadAccessToSource(t *testing.T) {
 				{Calendar: store.Calendar{ID: 2, UserID: attacker.ID, Name: "Mine"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: attacker.ID, Name: "Mine"},
 			9: {ID: 9, UserID: owner.ID, Name: "Private"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"9:secret": {
 				CalendarID:   9,
 				UID:          "secret",
@@ -4003,8 +4435,8 @@ func TestCalendarCopyRequiresReadAccessToSource(t *testing.T) {
 func TestCalendarMoveRejectsUnauthorizedSourceBeforeEventLookup(t *testing.T) {
 	owner := &store.User{ID: 1}
 	attacker := &store.User{ID: 2}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 9, UserID: owner.ID, Name: "Private"}, Editor: true},
 			},
@@ -4012,13 +4444,13 @@ func TestCalendarMoveRejectsUnauthorizedSourceBeforeEventLookup(t *testing.T) {
 				{Calendar: store.Calendar{ID: 2, UserID: attacker.ID, Name: "Mine"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: attacker.ID, Name: "Mine"},
 			9: {ID: 9, UserID: owner.ID, Name: "Private"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"9:secret": {
 				CalendarID:   9,
 				UID:          "secret",
@@ -4039,16 +4471,16 @@ func TestCalendarMoveRejectsUnauthorizedSourceBeforeEventLookup(t *testing.T) {
 	if rr.Code != http.StatusNotFound {
 		t.Fatalf("expected unauthorized source MOVE to return 404, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if eventRepo.resourceLookupCount != 0 {
-		t.Fatalf("expected MOVE to reject unauthorized source before loading the event, got %d lookups", eventRepo.resourceLookupCount)
+	if eventRepo.ResourceLookupCount != 0 {
+		t.Fatalf("expected MOVE to reject unauthorized source before loading the event, got %d lookups", eventRepo.ResourceLookupCount)
 	}
 }
 
 func TestContactCopyAndMoveRejectUnauthorizedSourceBeforeContactLookup(t *testing.T) {
 	owner := &store.User{ID: 1}
 	attacker := &store.User{ID: 2}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			2: {ID: 2, UserID: attacker.ID, Name: "Mine"},
 			9: {ID: 9, UserID: owner.ID, Name: "Private"},
 		},
@@ -4056,8 +4488,8 @@ func TestContactCopyAndMoveRejectUnauthorizedSourceBeforeContactLookup(t *testin
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"9:secret": {
 						AddressBookID: 9,
 						UID:           "secret",
@@ -4083,8 +4515,8 @@ func TestContactCopyAndMoveRejectUnauthorizedSourceBeforeContactLookup(t *testin
 			if rr.Code != http.StatusNotFound {
 				t.Fatalf("expected unauthorized source %s to return 404, got %d: %s", method, rr.Code, rr.Body.String())
 			}
-			if contactRepo.resourceLookupCount != 0 {
-				t.Fatalf("expected %s to reject unauthorized source before loading the contact, got %d lookups", method, contactRepo.resourceLookupCount)
+			if contactRepo.ResourceLookupCount != 0 {
+				t.Fatalf("expected %s to reject unauthorized source before loading the contact, got %d lookups", method, contactRepo.ResourceLookupCount)
 			}
 		})
 	}
@@ -4093,8 +4525,8 @@ func TestContactCopyAndMoveRejectUnauthorizedSourceBeforeContactLookup(t *testin
 func TestUnlockRejectsMismatchedRequestURI(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4127,8 +4559,8 @@ func TestUnlockRejectsMismatchedRequestURI(t *testing.T) {
 
 func TestCollectionLockRefreshAndUnlockRequireLockRoot(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4228,8 +4660,8 @@ func TestLockCoversPath(t *testing.T) {
 func TestACLNormalizesPrincipalHrefWithoutTrailingSlash(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	user2 := &store.User{ID: 2, PrimaryEmail: "user2@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared Contacts"},
 		},
 	}
@@ -4265,8 +4697,8 @@ func TestACLNormalizesPrincipalHrefWithoutTrailingSlash(t *testing.T) {
 
 func TestACLRejectsInvalidPrivileges(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared Contacts"},
 		},
 	}
@@ -4353,13 +4785,13 @@ func TestACLRejectsInvalidPrivileges(t *testing.T) {
 func TestLockAndACLCanonicalizeAddressBookAliases(t *testing.T) {
 	t.Run("lock alias blocks canonical path writes", func(t *testing.T) {
 		user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: user.ID, Name: "ProvisionedBook"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 			},
 		}
@@ -4405,13 +4837,13 @@ func TestLockAndACLCanonicalizeAddressBookAliases(t *testing.T) {
 	t.Run("acl alias grants apply to canonical path", func(t *testing.T) {
 		owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 		reader := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: owner.ID, Name: "Contacts"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 			},
 		}
@@ -4455,8 +4887,8 @@ func TestLockAndACLCanonicalizeAddressBookAliases(t *testing.T) {
 
 func TestLockResponseUsesServedResourceHref(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4485,13 +4917,13 @@ func TestLockResponseUsesServedResourceHref(t *testing.T) {
 
 func TestLockCreateRequiresRequestBody(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 		},
 	}
@@ -4514,13 +4946,13 @@ func TestLockCreateRequiresRequestBody(t *testing.T) {
 
 func TestLockRejectsInvalidRequestBodies(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: lockRepo}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: lockRepo}}
 
 	tests := []struct {
 		name string
@@ -4566,7 +4998,7 @@ func TestLockRejectsInvalidRequestBodies(t *testing.T) {
 
 func TestLockOnUnmappedCollectionReturnsCreated(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Locks: lockRepo}}
 
@@ -4592,14 +5024,14 @@ func TestLockOnUnmappedCollectionReturnsCreated(t *testing.T) {
 
 func TestLockDoesNotPersistWhenTargetResolutionFails(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		getByResourceNameErr:    errors.New("lookup failed"),
-		getByResourceNameErrKey: "5:alice",
+	contactRepo := &storetest.Contacts{
+		GetByResourceNameErr:    errors.New("lookup failed"),
+		GetByResourceNameErrKey: "5:alice",
 	}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, Locks: lockRepo}}
@@ -4626,7 +5058,7 @@ func TestLockDoesNotPersistWhenTargetResolutionFails(t *testing.T) {
 
 func TestMkcolRebindsPendingCollectionLocks(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Locks: lockRepo}}
 
@@ -4699,7 +5131,7 @@ func TestMkcolRebindsPendingCollectionLocks(t *testing.T) {
 }
 
 func TestMkcolAppliesExtendedBodyProperties(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -4723,7 +5155,7 @@ func TestMkcolAppliesExtendedBodyProperties(t *testing.T) {
 	if got := rr.Header().Get("Location"); got != "/dav/addressbooks/1/" {
 		t.Fatalf("expected Location for created address book, got %q", got)
 	}
-	book := bookRepo.books[1]
+	book := bookRepo.Books[1]
 	if book == nil {
 		t.Fatal("expected address book to be created")
 	}
@@ -4736,7 +5168,7 @@ func TestMkcolAppliesExtendedBodyProperties(t *testing.T) {
 }
 
 func TestMkcolRejectsOversizedRequestBody(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 	req := httptest.NewRequest("MKCOL", "/dav/addressbooks/tmp", strings.NewReader(strings.Repeat("x", int(maxDAVBodyBytes)+1)))
@@ -4748,14 +5180,14 @@ func TestMkcolRejectsOversizedRequestBody(t *testing.T) {
 	if rr.Code != http.StatusRequestEntityTooLarge {
 		t.Fatalf("expected oversized MKCOL body to return 413, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(bookRepo.books) != 0 {
-		t.Fatalf("expected oversized MKCOL to create no address book, got %#v", bookRepo.books)
+	if len(bookRepo.Books) != 0 {
+		t.Fatalf("expected oversized MKCOL to create no address book, got %#v", bookRepo.Books)
 	}
 }
 
 func TestMkcolReturnsInternalServerErrorWhenLockRebindFails(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	lockRepo := &fakeLockRepo{
 		locks:               map[string]*store.Lock{},
 		moveResourcePathErr: errors.New("rebind failed"),
@@ -4786,14 +5218,110 @@ func TestMkcolReturnsInternalServerErrorWhenLockRebindFails(t *testing.T) {
 	if got := createRR.Header().Get("Location"); got != "" {
 		t.Fatalf("expected MKCOL rebind failure to avoid Location header, got %q", got)
 	}
-	if len(bookRepo.books) != 0 {
-		t.Fatalf("expected MKCOL rebind failure to roll back created address book, got %#v", bookRepo.books)
+	if len(bookRepo.Books) != 0 {
+		t.Fatalf("expected MKCOL rebind failure to roll back created address book, got %#v", bookRepo.Books)
+	}
+}
+
+func TestCalendarSlugPathsAreStableWithIDAliases(t *testing.T) {
+	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
+	workSlug := "work"
+	now := store.Now()
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{
+		{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", Slug: &workSlug, UpdatedAt: now}, Editor: true, Privileges: store.FullCalendarPrivileges()},
+		// Another user's calendar shared with this one under the same slug.
+		{Calendar: store.Calendar{ID: 3, UserID: 9, Name: "Team", Slug: &workSlug, UpdatedAt: now}, Shared: true, Editor: true},
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
+
+	propfind := func(target, depth string) string {
+		req := httptest.NewRequest("PROPFIND", target, nil)
+		req.Header.Set("Depth", depth)
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		h.Propfind(rr, req)
+		if rr.Code != http.StatusMultiStatus {
+			t.Fatalf("PROPFIND %s: expected 207, got %d: %s", target, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	home := propfind("/dav/calendars/", "1")
+	if !strings.Contains(home, "<d:href>/dav/calendars/work/</d:href>") || !strings.Contains(home, "<d:href>/dav/calendars/3/</d:href>") {
+		t.Fatalf("expected own calendar under its slug and shared calendar under its ID, got %s", home)
+	}
+	if strings.Contains(home, "<d:href>/dav/calendars/2/</d:href>") {
+		t.Fatalf("expected the ID path only as an alias, got %s", home)
+	}
+
+	if got := propfind("/dav/calendars/work/", "0"); !strings.Contains(got, "<d:href>/dav/calendars/work/</d:href>") || !strings.Contains(got, ">Work<") {
+		t.Fatalf("expected the slug to resolve to the user's own calendar, got %s", got)
+	}
+	if got := propfind("/dav/calendars/2/", "0"); !strings.Contains(got, "<d:href>/dav/calendars/2/</d:href>") || !strings.Contains(got, ">Work<") {
+		t.Fatalf("expected the ID alias to keep working, got %s", got)
+	}
+
+	mkReq := httptest.NewRequest("MKCALENDAR", "/dav/calendars/Work/", nil)
+	mkReq = mkReq.WithContext(auth.WithUser(mkReq.Context(), user))
+	mkRR := httptest.NewRecorder()
+	h.Mkcalendar(mkRR, mkReq)
+	if mkRR.Code != http.StatusConflict {
+		t.Fatalf("expected a second calendar at the same slug rejected, got %d", mkRR.Code)
+	}
+}
+
+func TestCalendarCollectionsReportOwnerAndShareAccess(t *testing.T) {
+	user := &store.User{ID: 1, PrimaryEmail: "sharee@example.com"}
+	now := store.Now()
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{
+		{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Mine", UpdatedAt: now}, Editor: true, Privileges: store.FullCalendarPrivileges()},
+		{Calendar: store.Calendar{ID: 3, UserID: 9, Name: "Team", UpdatedAt: now}, OwnerEmail: "james@example.com", Shared: true, Editor: false},
+		{Calendar: store.Calendar{ID: 4, UserID: 9, Name: "Shared Work", UpdatedAt: now}, OwnerEmail: "james@example.com", Shared: true, Editor: true},
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
+
+	propfind := func(target string) string {
+		body := `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><d:owner/><cs:invite/></d:prop></d:propfind>`
+		req := httptest.NewRequest("PROPFIND", target, strings.NewReader(body))
+		req.Header.Set("Depth", "0")
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		h.Propfind(rr, req)
+		if rr.Code != http.StatusMultiStatus {
+			t.Fatalf("PROPFIND %s: expected 207, got %d: %s", target, rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	own := propfind("/dav/calendars/2/")
+	if !strings.Contains(own, "<d:owner><d:href>/dav/principals/1/</d:href></d:owner>") {
+		t.Fatalf("expected the user as owner of their own calendar, got %s", own)
+	}
+	if !strings.Contains(own, "<cs:invite></cs:invite>") || !strings.Contains(own, "404") {
+		t.Fatalf("expected no invite on an unshared calendar, got %s", own)
+	}
+
+	readOnly := propfind("/dav/calendars/3/")
+	for _, want := range []string{
+		"<d:owner><d:href>/dav/principals/9/</d:href></d:owner>",
+		"<cs:organizer><d:href>mailto:james@example.com</d:href><cs:common-name>james@example.com</cs:common-name></cs:organizer>",
+		"<d:href>mailto:sharee@example.com</d:href>",
+		"<cs:access><cs:read></cs:read></cs:access>",
+	} {
+		if !strings.Contains(readOnly, want) {
+			t.Fatalf("expected %q in read-only share, got %s", want, readOnly)
+		}
+	}
+
+	if got := propfind("/dav/calendars/4/"); !strings.Contains(got, "<cs:access><cs:read-write></cs:read-write></cs:access>") {
+		t.Fatalf("expected read-write access on an editable share, got %s", got)
 	}
 }
 
 func TestMkcalendarRebindsPendingCollectionLocks(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Locks: lockRepo}}
 
@@ -4825,8 +5353,8 @@ func TestMkcalendarRebindsPendingCollectionLocks(t *testing.T) {
 	if createRR.Code != http.StatusCreated {
 		t.Fatalf("expected MKCALENDAR to succeed, got %d: %s", createRR.Code, createRR.Body.String())
 	}
-	if got := createRR.Header().Get("Location"); got != "/dav/calendars/1/" {
-		t.Fatalf("expected MKCALENDAR location to point at the created collection, got %q", got)
+	if got := createRR.Header().Get("Location"); got != "/dav/calendars/work/" {
+		t.Fatalf("expected MKCALENDAR location to keep the created path, got %q", got)
 	}
 	createdLock, err := lockRepo.GetByToken(context.Background(), token)
 	if err != nil {
@@ -4866,7 +5394,7 @@ func TestMkcalendarRebindsPendingCollectionLocks(t *testing.T) {
 }
 
 func TestMkcalendarRejectsInvalidBodyAndDoesNotCreate(t *testing.T) {
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 
 	req := httptest.NewRequest("MKCALENDAR", "/dav/calendars/work", strings.NewReader(`<d:mkcalendar xmlns:d="DAV:"><d:set>`))
@@ -4878,13 +5406,13 @@ func TestMkcalendarRejectsInvalidBodyAndDoesNotCreate(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected MKCALENDAR to reject invalid body, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(calRepo.calendars) != 0 {
-		t.Fatalf("expected invalid MKCALENDAR body to create no calendar, got %#v", calRepo.calendars)
+	if len(calRepo.Calendars) != 0 {
+		t.Fatalf("expected invalid MKCALENDAR body to create no calendar, got %#v", calRepo.Calendars)
 	}
 }
 
 func TestMkcalendarRejectsOversizedRequestBody(t *testing.T) {
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 
 	req := httptest.NewRequest("MKCALENDAR", "/dav/calendars/work", strings.NewReader(strings.Repeat("x", int(maxDAVBodyBytes)+1)))
@@ -4896,14 +5424,14 @@ func TestMkcalendarRejectsOversizedRequestBody(t *testing.T) {
 	if rr.Code != http.StatusRequestEntityTooLarge {
 		t.Fatalf("expected oversized MKCALENDAR body to return 413, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(calRepo.calendars) != 0 {
-		t.Fatalf("expected oversized MKCALENDAR to create no calendar, got %#v", calRepo.calendars)
+	if len(calRepo.Calendars) != 0 {
+		t.Fatalf("expected oversized MKCALENDAR to create no calendar, got %#v", calRepo.Calendars)
 	}
 }
 
 func TestMkcalendarReturnsInternalServerErrorWhenLockRebindFails(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	lockRepo := &fakeLockRepo{
 		locks:               map[string]*store.Lock{},
 		moveResourcePathErr: errors.New("rebind failed"),
@@ -4934,19 +5462,19 @@ func TestMkcalendarReturnsInternalServerErrorWhenLockRebindFails(t *testing.T) {
 	if got := createRR.Header().Get("Location"); got != "" {
 		t.Fatalf("expected MKCALENDAR rebind failure to avoid Location header, got %q", got)
 	}
-	if len(calRepo.calendars) != 0 {
-		t.Fatalf("expected MKCALENDAR rebind failure to roll back created calendar, got %#v", calRepo.calendars)
+	if len(calRepo.Calendars) != 0 {
+		t.Fatalf("expected MKCALENDAR rebind failure to roll back created calendar, got %#v", calRepo.Calendars)
 	}
 }
 
 func TestPutAddressBookMapsUpsertConflictsToCardDAVConflict(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{upsertErr: store.ErrConflict}
+	contactRepo := &storetest.Contacts{UpsertErr: store.ErrConflict}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, Locks: &fakeLockRepo{}}}
 
 	req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice Example")))
@@ -4965,12 +5493,12 @@ func TestPutAddressBookMapsUpsertConflictsToCardDAVConflict(t *testing.T) {
 
 func TestPutAddressBookPreservesInternalErrorsFromUpsert(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{upsertErr: errors.New("db unavailable")}
+	contactRepo := &storetest.Contacts{UpsertErr: errors.New("db unavailable")}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, Locks: &fakeLockRepo{}}}
 
 	req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice Example")))
@@ -4989,14 +5517,14 @@ func TestPutAddressBookPreservesInternalErrorsFromUpsert(t *testing.T) {
 
 func TestCopyUsesTaggedIfTokensForLockedSourceAndDestination(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Source"},
 			6: {ID: 6, UserID: user.ID, Name: "Destination"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 		},
 	}
@@ -5042,8 +5570,8 @@ func TestCopyUsesTaggedIfTokensForLockedSourceAndDestination(t *testing.T) {
 
 func TestPropfindAddressBookCollectionIncludesLockAndACLProperties(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Shared Contacts"},
 		},
 	}
@@ -5098,8 +5626,8 @@ func TestPropfindAddressBookCollectionIncludesLockAndACLProperties(t *testing.T)
 
 func TestACLRejectsInvalidACEs(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared Contacts"},
 		},
 	}
@@ -5179,8 +5707,8 @@ func TestACLRejectsInvalidACEs(t *testing.T) {
 func TestPropfindAddressBookCurrentUserPrivilegeSetForDelegate(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Contacts"},
 		},
 	}
@@ -5217,13 +5745,13 @@ func TestPropfindAddressBookCurrentUserPrivilegeSetForDelegate(t *testing.T) {
 func TestPropfindCalendarCurrentUserPrivilegeSetForDelegate(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 5, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Work"},
 		},
 	}
@@ -5259,8 +5787,8 @@ func TestPropfindCalendarCurrentUserPrivilegeSetForDelegate(t *testing.T) {
 
 func TestCalendarCurrentUserPrivilegeSetForReadFreeBusyDelegate(t *testing.T) {
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: 1, Name: "Work"},
 		},
 	}
@@ -5285,8 +5813,8 @@ func TestCalendarCurrentUserPrivilegeSetForReadFreeBusyDelegate(t *testing.T) {
 
 func TestCalendarCurrentUserPrivilegeSetOmitsAggregateWriteWhenSubPrivilegeDenied(t *testing.T) {
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: 1, Name: "Work"},
 		},
 	}
@@ -5312,13 +5840,13 @@ func TestCalendarCurrentUserPrivilegeSetOmitsAggregateWriteWhenSubPrivilegeDenie
 func TestPropfindCalendarDiscoveryIncludesReadFreeBusyOnlyCalendars(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 5, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Work"},
 		},
 	}
@@ -5365,13 +5893,13 @@ func TestPropfindCalendarDiscoveryIncludesReadFreeBusyOnlyCalendars(t *testing.T
 func TestPropfindCalendarDiscoveryIncludesACLGrantedCalendars(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 5, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Work"},
 		},
 	}
@@ -5400,13 +5928,13 @@ func TestPropfindCalendarDiscoveryIncludesACLGrantedCalendars(t *testing.T) {
 
 func TestPropfindAddressBookObjectACLUsesCanonicalStoredPath(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -5475,16 +6003,16 @@ func TestPropfindGenericCollectionReportsUnsupportedRequestedPropertiesAs404(t *
 
 func TestPropfindCalendarPropRequestReturnsOnlyRequestedProperties(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 5, UserID: user.ID, Name: "Work"}, Editor: true},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: user.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"5:event": {
 				CalendarID:   5,
 				UID:          "event",
@@ -5544,13 +6072,13 @@ func TestPropfindCalendarPropRequestReturnsOnlyRequestedProperties(t *testing.T)
 
 func TestPropfindCalendarObjectHidesDeniedEvent(t *testing.T) {
 	user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: 1, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"5:event": {
 				CalendarID:   5,
 				UID:          "event",
@@ -5595,13 +6123,13 @@ func TestPropfindCalendarObjectHidesDeniedEvent(t *testing.T) {
 
 func TestPropfindAddressBookPropRequestReturnsOnlyRequestedProperties(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -5664,13 +6192,13 @@ func TestPropfindAddressBookDepthOneFiltersDeniedContacts(t *testing.T) {
 	user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
 
 	newHandler := func(denySecret bool) *Handler {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:public": {AddressBookID: 5, UID: "public", ResourceName: "public", RawVCard: buildVCard("3.0", "UID:public", "FN:Public"), ETag: "etag-public", LastModified: now},
 				"5:secret": {AddressBookID: 5, UID: "secret", ResourceName: "secret", RawVCard: buildVCard("3.0", "UID:secret", "FN:Secret"), ETag: "etag-secret", LastModified: now},
 			},
@@ -5734,13 +6262,13 @@ func TestAddressBookReportsFilterDeniedContacts(t *testing.T) {
 	user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
 
 	newHandler := func(denySecret bool) *Handler {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", UpdatedAt: now, CTag: 2},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:public": {AddressBookID: 5, UID: "public", ResourceName: "public", RawVCard: buildVCard("3.0", "UID:public", "FN:Public"), ETag: "etag-public", LastModified: now},
 				"5:secret": {AddressBookID: 5, UID: "secret", ResourceName: "secret", RawVCard: buildVCard("3.0", "UID:secret", "FN:Secret"), ETag: "etag-secret", LastModified: now},
 			},
@@ -5845,13 +6373,13 @@ func TestAddressBookReportsFilterDeniedContacts(t *testing.T) {
 
 func TestPropfindAddressDataRequestsRespectSelection(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {
 				AddressBookID: 5,
 				UID:           "alice",
@@ -5919,8 +6447,8 @@ func TestPropfindAddressDataRequestsRespectSelection(t *testing.T) {
 
 func TestPropfindCollectionPropRequestsReportUnsupportedProperties(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -6052,8 +6580,8 @@ func TestIsResourceOwnerParsesPrincipalIDPositionally(t *testing.T) {
 
 func TestPutAddressBookUIDConflictEscapesHref(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -6066,8 +6594,8 @@ func TestPutAddressBookUIDConflictEscapesHref(t *testing.T) {
 
 	run := func(t *testing.T, resourceName string) string {
 		t.Helper()
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				fmt.Sprintf("5:%s", "existing"): {
 					AddressBookID: 5,
 					UID:           "existing",
@@ -6108,7 +6636,7 @@ func TestPutAddressBookUIDConflictEscapesHref(t *testing.T) {
 }
 
 func TestMkcolRejectsNumericDisplayNameFromBody(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -6128,13 +6656,13 @@ func TestMkcolRejectsNumericDisplayNameFromBody(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected MKCOL to reject numeric displayname, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(bookRepo.books) != 0 {
-		t.Fatalf("expected no address book to be created, got %#v", bookRepo.books)
+	if len(bookRepo.Books) != 0 {
+		t.Fatalf("expected no address book to be created, got %#v", bookRepo.Books)
 	}
 }
 
 func TestMkcolRejectsInvalidExtendedBodyAndDoesNotCreate(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 	req := httptest.NewRequest("MKCOL", "/dav/addressbooks/tmp", strings.NewReader(`<d:mkcol xmlns:d="DAV:"><d:set>`))
@@ -6146,14 +6674,14 @@ func TestMkcolRejectsInvalidExtendedBodyAndDoesNotCreate(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected MKCOL to reject invalid extended body, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if len(bookRepo.books) != 0 {
-		t.Fatalf("expected no address book to be created, got %#v", bookRepo.books)
+	if len(bookRepo.Books) != 0 {
+		t.Fatalf("expected no address book to be created, got %#v", bookRepo.Books)
 	}
 }
 
 func TestMkcolRejectsDuplicateAddressBookName(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
@@ -6172,13 +6700,13 @@ func TestMkcolRejectsDuplicateAddressBookName(t *testing.T) {
 
 func TestAddressBookHandlersRejectExtraResourcePathSegments(t *testing.T) {
 	user := &store.User{ID: 1}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 		},
 	}
@@ -6216,14 +6744,14 @@ func TestAddressBookCopyAndMoveOverwriteReplaceDifferentUIDDestination(t *testin
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			bookRepo := &fakeAddressBookRepo{
-				books: map[int64]*store.AddressBook{
+			bookRepo := &storetest.AddressBooks{
+				Books: map[int64]*store.AddressBook{
 					5: {ID: 5, UserID: user.ID, Name: "Source", UpdatedAt: now},
 					6: {ID: 6, UserID: user.ID, Name: "Destination", UpdatedAt: now},
 				},
 			}
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 					"6:bob":   {AddressBookID: 6, UID: "bob", ResourceName: "renamed", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 				},
@@ -6266,19 +6794,19 @@ func TestAddressBookCopyAndMoveOverwriteReplaceDifferentUIDDestination(t *testin
 func TestMoveContactOverwriteClearsDestinationTombstone(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	now := store.Now()
-	deletedRepo := &fakeDeletedResourceRepo{}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	deletedRepo := &storetest.DeletedResources{}
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts", UpdatedAt: now},
 			6: {ID: 6, UserID: user.ID, Name: "Archive", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 			"6:bob":   {AddressBookID: 6, UID: "bob", ResourceName: "renamed", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 		},
-		overwriteMoveDeletedRepo: deletedRepo,
+		OverwriteMoveDeleted: deletedRepo,
 	}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, DeletedResources: deletedRepo}}
 
@@ -6307,14 +6835,14 @@ func TestAddressBookCopyAndMoveOverwriteDifferentUIDDestinationRequiresRebinding
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			bookRepo := &fakeAddressBookRepo{
-				books: map[int64]*store.AddressBook{
+			bookRepo := &storetest.AddressBooks{
+				Books: map[int64]*store.AddressBook{
 					5: {ID: 5, UserID: 1, Name: "Source", UpdatedAt: now},
 					6: {ID: 6, UserID: 1, Name: "Destination", UpdatedAt: now},
 				},
 			}
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 					"6:bob":   {AddressBookID: 6, UID: "bob", ResourceName: "renamed", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 				},
@@ -6370,14 +6898,14 @@ func TestCalendarCopyAndMoveOverwriteDifferentUIDDestinationRequiresRebindingPri
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(method, func(t *testing.T) {
-			calRepo := &fakeCalendarRepo{
-				calendars: map[int64]*store.Calendar{
+			calRepo := &storetest.Calendars{
+				Calendars: map[int64]*store.Calendar{
 					5: {ID: 5, UserID: 1, Name: "Source", UpdatedAt: now},
 					6: {ID: 6, UserID: 1, Name: "Destination", UpdatedAt: now},
 				},
 			}
-			eventRepo := &fakeEventRepo{
-				events: map[string]*store.Event{
+			eventRepo := &storetest.Events{
+				Events: map[string]*store.Event{
 					"5:alice": {
 						CalendarID:   5,
 						UID:          "alice",
@@ -6445,14 +6973,14 @@ func TestCalendarCopyAndMoveOverwriteDifferentUIDDestinationRequiresRebindingPri
 func TestMoveContactRebindsDirectLockToDestination(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Source", UpdatedAt: now},
 			6: {ID: 6, UserID: user.ID, Name: "Destination", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 		},
 	}
@@ -6516,14 +7044,14 @@ func TestMoveContactOverwritePreservesDestinationDAVState(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Source", UpdatedAt: now},
 			6: {ID: 6, UserID: user.ID, Name: "Destination", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 			"6:bob":   {AddressBookID: 6, UID: "bob", ResourceName: "renamed", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 		},
@@ -6603,13 +7131,13 @@ func TestMoveContactOverwritePreservesDestinationDAVState(t *testing.T) {
 
 func TestDeleteContactRemovesDirectLockState(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 		},
 	}
@@ -6653,14 +7181,14 @@ func TestMoveContactRebindsACLToDestination(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Source", UpdatedAt: now},
 			6: {ID: 6, UserID: owner.ID, Name: "Destination", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 		},
 	}
@@ -6712,13 +7240,13 @@ func TestMoveContactRebindsACLToDestination(t *testing.T) {
 func TestDeleteContactRemovesResourceACLState(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Contacts"},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 		},
 	}
@@ -6761,14 +7289,14 @@ func TestCopyContactOverwriteClearsDestinationDAVState(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: owner.ID, Name: "Source", UpdatedAt: now},
 			6: {ID: 6, UserID: owner.ID, Name: "Destination", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 			"6:bob":   {AddressBookID: 6, UID: "bob", ResourceName: "renamed", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 		},
@@ -6831,18 +7359,18 @@ func TestCopyCalendarOverwriteClearsDestinationDAVState(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 5, UserID: owner.ID, Name: "Source", UpdatedAt: now}, Editor: true},
 			{Calendar: store.Calendar{ID: 6, UserID: owner.ID, Name: "Destination", UpdatedAt: now}, Editor: true},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Source", UpdatedAt: now},
 			6: {ID: 6, UserID: owner.ID, Name: "Destination", UpdatedAt: now},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"5:alice": {CalendarID: 5, UID: "alice", ResourceName: "alice", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:alice\r\nSUMMARY:Alice\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-a", LastModified: now},
 			"6:bob":   {CalendarID: 6, UID: "bob", ResourceName: "renamed", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:bob\r\nSUMMARY:Bob\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-b", LastModified: now},
 		},
@@ -6901,22 +7429,79 @@ func TestCopyCalendarOverwriteClearsDestinationDAVState(t *testing.T) {
 	}
 }
 
-func TestPropfindACLUsesSpecialPrincipalElements(t *testing.T) {
-	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
-			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
+func TestCopyCalendarCollectionDuplicatesEventsWithNewUIDs(t *testing.T) {
+	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
+	now := store.Now()
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 1, UserID: owner.ID, Name: "Template", UpdatedAt: now}, Editor: true},
+		},
+		Calendars: map[int64]*store.Calendar{
+			1: {ID: 1, UserID: owner.ID, Name: "Template", UpdatedAt: now},
 		},
 	}
-	aclRepo := &fakeACLRepo{
-		entries: []store.ACLEntry{
-			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "DAV:authenticated", IsGrant: true, Privilege: "read"},
-			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "DAV:all", IsGrant: false, Privilege: "write"},
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
+			"1:alice": {CalendarID: 1, UID: "alice", ResourceName: "alice", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:alice\r\nDTSTART:20300101T090000Z\r\nSUMMARY:Alice\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "etag-a", LastModified: now},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+	copyCollection := func(dest, overwrite string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("COPY", "/dav/calendars/1/", nil)
+		req.Header.Set("Destination", "https://example.com"+dest)
+		if overwrite != "" {
+			req.Header.Set("Overwrite", overwrite)
+		}
+		req = req.WithContext(auth.WithUser(req.Context(), owner))
+		rr := httptest.NewRecorder()
+		h.Copy(rr, req)
+		return rr
+	}
 
-	body := `<?xml version="1.0" encoding="utf-8"?>
+	rr := copyCollection("/dav/calendars/planning/", "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected collection COPY to create a calendar, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Location"); got != "/dav/calendars/planning/" {
+		t.Fatalf("Location = %q, want /dav/calendars/planning/", got)
+	}
+	var copied *store.Event
+	for _, ev := range eventRepo.Events {
+		if ev.CalendarID != 1 {
+			copied = ev
+		}
+	}
+	if copied == nil || copied.UID == "alice" || !strings.Contains(copied.RawICAL, "UID:"+copied.UID+"\r\n") || !strings.Contains(copied.RawICAL, "SUMMARY:Alice") {
+		t.Fatalf("expected the event copied with a new UID, got %#v", copied)
+	}
+
+	if rr := copyCollection("/dav/calendars/planning/", "F"); rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected COPY onto an existing calendar with Overwrite: F to fail with 412, got %d", rr.Code)
+	}
+	if rr := copyCollection("/dav/calendars/planning/", "T"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected COPY onto an existing calendar to be refused, got %d", rr.Code)
+	}
+	if rr := copyCollection("/dav/calendars/7/", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected COPY to a numeric collection to be refused, got %d", rr.Code)
+	}
+}
+
+func TestPropfindACLUsesSpecialPrincipalElements(t *testing.T) {
+	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
+			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
+		},
+	}
+	aclRepo := &fakeACLRepo{
+		entries: []store.ACLEntry{
+			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "DAV:authenticated", IsGrant: true, Privilege: "read"},
+			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "DAV:all", IsGrant: false, Privilege: "write"},
+		},
+	}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, ACLEntries: aclRepo}}
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
 <d:propfind xmlns:d="DAV:">
   <d:prop>
     <d:acl/>
@@ -6965,17 +7550,17 @@ func TestBuildACLPropFromEntriesSeparatesGrantAndDenyACEs(t *testing.T) {
 
 func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Source"},
 			6: {ID: 6, UserID: user.ID, Name: "Destination"},
 		},
 	}
 
 	t.Run("put", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts:             map[string]*store.Contact{},
-			getByResourceNameErr: errors.New("resource lookup failed"),
+		contactRepo := &storetest.Contacts{
+			Contacts:             map[string]*store.Contact{},
+			GetByResourceNameErr: errors.New("resource lookup failed"),
 		}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 		req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice Example")))
@@ -6987,16 +7572,16 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 		if rr.Code != http.StatusInternalServerError {
 			t.Fatalf("expected PUT lookup failure to return 500, got %d: %s", rr.Code, rr.Body.String())
 		}
-		if len(contactRepo.contacts) != 0 {
-			t.Fatalf("expected PUT lookup failure to avoid writes, got %#v", contactRepo.contacts)
+		if len(contactRepo.Contacts) != 0 {
+			t.Fatalf("expected PUT lookup failure to avoid writes, got %#v", contactRepo.Contacts)
 		}
 	})
 
 	t.Run("put uid lookup", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts:       map[string]*store.Contact{},
-			getByUIDErr:    errors.New("uid lookup failed"),
-			getByUIDErrKey: "5:alice",
+		contactRepo := &storetest.Contacts{
+			Contacts:       map[string]*store.Contact{},
+			GetByUIDErr:    errors.New("uid lookup failed"),
+			GetByUIDErrKey: "5:alice",
 		}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 		req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice Example")))
@@ -7008,15 +7593,15 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 		if rr.Code != http.StatusInternalServerError {
 			t.Fatalf("expected PUT UID lookup failure to return 500, got %d: %s", rr.Code, rr.Body.String())
 		}
-		if len(contactRepo.contacts) != 0 {
-			t.Fatalf("expected PUT UID lookup failure to avoid writes, got %#v", contactRepo.contacts)
+		if len(contactRepo.Contacts) != 0 {
+			t.Fatalf("expected PUT UID lookup failure to avoid writes, got %#v", contactRepo.Contacts)
 		}
 	})
 
 	for _, method := range []string{"COPY", "MOVE"} {
 		t.Run(strings.ToLower(method)+" destination name lookup", func(t *testing.T) {
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {
 						AddressBookID: 5,
 						UID:           "alice",
@@ -7025,8 +7610,8 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 						ETag:          "etag-alice",
 					},
 				},
-				getByResourceNameErr:    errors.New("destination lookup failed"),
-				getByResourceNameErrKey: "6:copied",
+				GetByResourceNameErr:    errors.New("destination lookup failed"),
+				GetByResourceNameErrKey: "6:copied",
 			}
 			h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 			req := httptest.NewRequest(method, "/dav/addressbooks/5/alice.vcf", nil)
@@ -7043,17 +7628,17 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 			if rr.Code != http.StatusInternalServerError {
 				t.Fatalf("expected %s lookup failure to return 500, got %d: %s", method, rr.Code, rr.Body.String())
 			}
-			if _, ok := contactRepo.contacts["6:alice"]; ok {
-				t.Fatalf("expected %s lookup failure to avoid destination writes, got %#v", method, contactRepo.contacts)
+			if _, ok := contactRepo.Contacts["6:alice"]; ok {
+				t.Fatalf("expected %s lookup failure to avoid destination writes, got %#v", method, contactRepo.Contacts)
 			}
-			if _, ok := contactRepo.contacts["5:alice"]; !ok {
-				t.Fatalf("expected %s lookup failure to preserve source, got %#v", method, contactRepo.contacts)
+			if _, ok := contactRepo.Contacts["5:alice"]; !ok {
+				t.Fatalf("expected %s lookup failure to preserve source, got %#v", method, contactRepo.Contacts)
 			}
 		})
 
 		t.Run(strings.ToLower(method)+" source lookup", func(t *testing.T) {
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {
 						AddressBookID: 5,
 						UID:           "alice",
@@ -7062,8 +7647,8 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 						ETag:          "etag-alice",
 					},
 				},
-				getByResourceNameErr:    errors.New("source lookup failed"),
-				getByResourceNameErrKey: "5:alice",
+				GetByResourceNameErr:    errors.New("source lookup failed"),
+				GetByResourceNameErrKey: "5:alice",
 			}
 			h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 			req := httptest.NewRequest(method, "/dav/addressbooks/5/alice.vcf", nil)
@@ -7080,14 +7665,14 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 			if rr.Code != http.StatusInternalServerError {
 				t.Fatalf("expected %s source lookup failure to return 500, got %d: %s", method, rr.Code, rr.Body.String())
 			}
-			if _, ok := contactRepo.contacts["6:alice"]; ok {
-				t.Fatalf("expected %s source lookup failure to avoid destination writes, got %#v", method, contactRepo.contacts)
+			if _, ok := contactRepo.Contacts["6:alice"]; ok {
+				t.Fatalf("expected %s source lookup failure to avoid destination writes, got %#v", method, contactRepo.Contacts)
 			}
 		})
 
 		t.Run(strings.ToLower(method)+" destination uid lookup", func(t *testing.T) {
-			contactRepo := &fakeContactRepo{
-				contacts: map[string]*store.Contact{
+			contactRepo := &storetest.Contacts{
+				Contacts: map[string]*store.Contact{
 					"5:alice": {
 						AddressBookID: 5,
 						UID:           "alice",
@@ -7096,8 +7681,8 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 						ETag:          "etag-alice",
 					},
 				},
-				getByUIDErr:    errors.New("destination uid lookup failed"),
-				getByUIDErrKey: "6:alice",
+				GetByUIDErr:    errors.New("destination uid lookup failed"),
+				GetByUIDErrKey: "6:alice",
 			}
 			h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 			req := httptest.NewRequest(method, "/dav/addressbooks/5/alice.vcf", nil)
@@ -7114,11 +7699,11 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 			if rr.Code != http.StatusInternalServerError {
 				t.Fatalf("expected %s destination UID lookup failure to return 500, got %d: %s", method, rr.Code, rr.Body.String())
 			}
-			if _, ok := contactRepo.contacts["6:alice"]; ok {
-				t.Fatalf("expected %s destination UID lookup failure to avoid destination writes, got %#v", method, contactRepo.contacts)
+			if _, ok := contactRepo.Contacts["6:alice"]; ok {
+				t.Fatalf("expected %s destination UID lookup failure to avoid destination writes, got %#v", method, contactRepo.Contacts)
 			}
-			if _, ok := contactRepo.contacts["5:alice"]; !ok {
-				t.Fatalf("expected %s destination UID lookup failure to preserve source, got %#v", method, contactRepo.contacts)
+			if _, ok := contactRepo.Contacts["5:alice"]; !ok {
+				t.Fatalf("expected %s destination UID lookup failure to preserve source, got %#v", method, contactRepo.Contacts)
 			}
 		})
 	}
@@ -7126,14 +7711,14 @@ func TestContactWritesFailClosedOnLookupErrors(t *testing.T) {
 
 func TestCanLockAddressBookCollectionRequiresMoreThanBind(t *testing.T) {
 	user := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
 	h := &Handler{store: &store.Store{
 		AddressBooks: bookRepo,
-		Contacts:     &fakeContactRepo{},
+		Contacts:     &storetest.Contacts{},
 		ACLEntries: &fakeACLRepo{entries: []store.ACLEntry{
 			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "bind"},
 		}},
@@ -7199,7 +7784,7 @@ func TestLockScopesDirectChildCollectionCreationPathsPerUser(t *testing.T) {
 
 func TestMkcolIgnoresAnotherUsersPendingLockForSameCollectionName(t *testing.T) {
 	lockRepo := &fakeLockRepo{}
-	bookRepo := &fakeAddressBookRepo{}
+	bookRepo := &storetest.AddressBooks{}
 	h := &Handler{store: &store.Store{Locks: lockRepo, AddressBooks: bookRepo}}
 	lockBody := `<?xml version="1.0" encoding="utf-8"?>
 <D:lockinfo xmlns:D="DAV:">
@@ -7234,7 +7819,7 @@ func TestMkcolIgnoresAnotherUsersPendingLockForSameCollectionName(t *testing.T)
 
 func TestMkcalendarIgnoresAnotherUsersPendingLockForSameCollectionName(t *testing.T) {
 	lockRepo := &fakeLockRepo{}
-	calRepo := &fakeCalendarRepo{}
+	calRepo := &storetest.Calendars{}
 	h := &Handler{store: &store.Store{Locks: lockRepo, Calendars: calRepo}}
 	lockBody := `<?xml version="1.0" encoding="utf-8"?>
 <D:lockinfo xmlns:D="DAV:">
@@ -7268,13 +7853,13 @@ func TestMkcalendarIgnoresAnotherUsersPendingLockForSameCollectionName(t *testin
 }
 
 func TestPutReturnsInternalServerErrorWhenLockLookupFails(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
 	lockRepo := &fakeLockRepo{listByResourcesErr: errors.New("lock lookup failed")}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: lockRepo}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: lockRepo}}
 
 	req := httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice Example")))
 	req.Header.Set("Content-Type", "text/vcard")
@@ -7290,8 +7875,8 @@ func TestPutReturnsInternalServerErrorWhenLockLookupFails(t *testing.T) {
 
 func TestProppatchIgnoresParentCollectionLock(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 		},
 	}
@@ -7308,7 +7893,7 @@ func TestProppatchIgnoresParentCollectionLock(t *testing.T) {
 			},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: lockRepo}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: lockRepo}}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
 <D:propertyupdate xmlns:D="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
@@ -7328,15 +7913,15 @@ func TestProppatchIgnoresParentCollectionLock(t *testing.T) {
 	if rr.Code != http.StatusMultiStatus {
 		t.Fatalf("expected PROPPATCH to ignore parent collection lock and return 207, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if got := bookRepo.books[5].Name; got != "Renamed Book" {
+	if got := bookRepo.Books[5].Name; got != "Renamed Book" {
 		t.Fatalf("expected PROPPATCH to update the address book name, got %q", got)
 	}
 }
 
 func TestPropfindAddressBookHomeSetIncludesACLSharedBooks(t *testing.T) {
 	user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			2: {ID: 2, UserID: user.ID, Name: "Owned"},
 			5: {ID: 5, UserID: 1, Name: "Shared"},
 		},
@@ -7346,7 +7931,7 @@ func TestPropfindAddressBookHomeSetIncludesACLSharedBooks(t *testing.T) {
 			{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "read"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, ACLEntries: aclRepo}}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
 <d:propfind xmlns:d="DAV:">
@@ -7373,191 +7958,6 @@ func TestPropfindAddressBookHomeSetIncludesACLSharedBooks(t *testing.T) {
 	}
 }
 
-type fakeEventRepo struct {
-	events                   map[string]*store.Event
-	deleted                  []string
-	copyErr                  error
-	moveErr                  error
-	getByUIDErr              error
-	getByUIDErrKey           string
-	getByResourceNameErr     error
-	getByResourceNameKey     string
-	resourceLookupCount      int
-	overwriteMoveDeletedRepo *fakeDeletedResourceRepo
-}
-
-func (f *fakeEventRepo) key(calendarID int64, uid string) string {
-	return fmt.Sprintf("%d:%s", calendarID, uid)
-}
-
-func (f *fakeEventRepo) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
-	if f.events == nil {
-		f.events = map[string]*store.Event{}
-	}
-	if event.ResourceName == "" {
-		event.ResourceName = event.UID
-	}
-	copy := event
-	f.events[f.key(event.CalendarID, event.UID)] = &copy
-	return &copy, nil
-}
-
-func (f *fakeEventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid string) error {
-	f.deleted = append(f.deleted, f.key(calendarID, uid))
-	delete(f.events, f.key(calendarID, uid))
-	return nil
-}
-
-func (f *fakeEventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
-	if f.getByUIDErr != nil && (f.getByUIDErrKey == "" || f.getByUIDErrKey == f.key(calendarID, uid)) {
-		return nil, f.getByUIDErr
-	}
-	if ev, ok := f.events[f.key(calendarID, uid)]; ok {
-		copy := *ev
-		return &copy, nil
-	}
-	return nil, nil
-}
-
-func (f *fakeEventRepo) GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, error) {
-	f.resourceLookupCount++
-	if f.getByResourceNameErr != nil && (f.getByResourceNameKey == "" || f.getByResourceNameKey == f.key(calendarID, resourceName)) {
-		return nil, f.getByResourceNameErr
-	}
-	for _, ev := range f.events {
-		if ev.CalendarID != calendarID {
-			continue
-		}
-		name := ev.ResourceName
-		if name == "" {
-			name = ev.UID
-		}
-		if name == resourceName {
-			copy := *ev
-			return &copy, nil
-		}
-	}
-	return nil, nil
-}
-
-func (f *fakeEventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
-	var result []store.Event
-	for _, ev := range f.events {
-		if ev.CalendarID != calendarID {
-			continue
-		}
-		copy := *ev
-		result = append(result, copy)
-	}
-	return result, nil
-}
-
-func (f *fakeEventRepo) ListForCalendarFiltered(ctx context.Context, calendarID int64, _ store.EventFilter) ([]store.Event, error) {
-	return f.ListForCalendar(ctx, calendarID)
-}
-
-func (f *fakeEventRepo) ListForCalendarPaginated(ctx context.Context, calendarID int64, limit, offset int) (*store.PaginatedResult[store.Event], error) {
-	events, _ := f.ListForCalendar(ctx, calendarID)
-	return &store.PaginatedResult[store.Event]{
-		Items:      events,
-		TotalCount: len(events),
-		Limit:      limit,
-		Offset:     offset,
-	}, nil
-}
-
-func (f *fakeEventRepo) ListByUIDs(ctx context.Context, calendarID int64, uids []string) ([]store.Event, error) {
-	return nil, nil
-}
-
-func (f *fakeEventRepo) ListModifiedSince(ctx context.Context, calendarID int64, since time.Time) ([]store.Event, error) {
-	var result []store.Event
-	for _, ev := range f.events {
-		if ev.CalendarID != calendarID {
-			continue
-		}
-		if ev.LastModified.After(since) {
-			copy := *ev
-			result = append(result, copy)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeEventRepo) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]store.Event, error) {
-	return nil, nil
-}
-
-func (f *fakeEventRepo) MaxLastModified(ctx context.Context, calendarID int64) (time.Time, error) {
-	var max time.Time
-	for _, ev := range f.events {
-		if ev.CalendarID != calendarID {
-			continue
-		}
-		if ev.LastModified.After(max) {
-			max = ev.LastModified
-		}
-	}
-	return max, nil
-}
-
-func (f *fakeEventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName string) error {
-	if f.moveErr != nil {
-		return f.moveErr
-	}
-	oldKey := f.key(fromCalendarID, uid)
-	ev, ok := f.events[oldKey]
-	if !ok {
-		return nil
-	}
-	for key, existing := range f.events {
-		if existing.CalendarID != toCalendarID || existing.UID == uid {
-			continue
-		}
-		name := existing.ResourceName
-		if name == "" {
-			name = existing.UID
-		}
-		if destResourceName != "" && name == destResourceName {
-			if f.overwriteMoveDeletedRepo != nil {
-				f.overwriteMoveDeletedRepo.deleted = append(f.overwriteMoveDeletedRepo.deleted, store.DeletedResource{
-					ResourceType: "event",
-					CollectionID: toCalendarID,
-					UID:          existing.UID,
-					ResourceName: name,
-					DeletedAt:    time.Now(),
-				})
-			}
-			delete(f.events, key)
-		}
-	}
-	delete(f.events, oldKey)
-	ev.CalendarID = toCalendarID
-	if destResourceName != "" {
-		ev.ResourceName = destResourceName
-	}
-	f.events[f.key(toCalendarID, uid)] = ev
-	return nil
-}
-
-func (f *fakeEventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
-	if f.copyErr != nil {
-		return nil, f.copyErr
-	}
-	src, ok := f.events[f.key(fromCalendarID, uid)]
-	if !ok {
-		return nil, nil
-	}
-	copy := *src
-	copy.CalendarID = toCalendarID
-	if destResourceName != "" {
-		copy.ResourceName = destResourceName
-	}
-	copy.ETag = newETag
-	f.events[f.key(toCalendarID, copy.UID)] = &copy
-	return &copy, nil
-}
-
 type errorEventRepo struct{}
 
 func (e *errorEventRepo) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
@@ -7568,6 +7968,10 @@ func (e *errorEventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid
 	return errors.New("fail")
 }
 
+func (e *errorEventRepo) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	return 0, errors.New("fail")
+}
+
 func (e *errorEventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
 	return nil, errors.New("fail")
 }
@@ -7576,6 +7980,10 @@ func (e *errorEventRepo) GetByResourceName(ctx context.Context, calendarID int64
 	return nil, errors.New("fail")
 }
 
+func (e *errorEventRepo) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, *store.Body, error) {
+	return nil, nil, errors.New("fail")
+}
+
 func (e *errorEventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
 	return nil, errors.New("fail")
 }
@@ -7611,484 +8019,11 @@ func (e *errorEventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toC
 func (e *errorEventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
 	return nil, errors.New("fail")
 }
-
-type fakeContactRepo struct {
-	contacts                 map[string]*store.Contact
-	deleted                  []string
-	copyErr                  error
-	moveErr                  error
-	upsertErr                error
-	getByUIDErr              error
-	getByUIDErrKey           string
-	getByResourceNameErr     error
-	getByResourceNameErrKey  string
-	resourceLookupCount      int
-	overwriteMoveDeletedRepo *fakeDeletedResourceRepo
-}
-
-func (f *fakeContactRepo) key(bookID int64, uid string) string {
-	return fmt.Sprintf("%d:%s", bookID, uid)
-}
-
-func (f *fakeContactRepo) Upsert(ctx context.Context, contact store.Contact) (*store.Contact, error) {
-	if f.upsertErr != nil {
-		return nil, f.upsertErr
-	}
-	if f.contacts == nil {
-		f.contacts = map[string]*store.Contact{}
-	}
-	if contact.ResourceName == "" {
-		contact.ResourceName = contact.UID
-	}
-	copy := contact
-	f.contacts[f.key(contact.AddressBookID, contact.UID)] = &copy
-	return &copy, nil
-}
-
-func (f *fakeContactRepo) DeleteByUID(ctx context.Context, addressBookID int64, uid string) error {
-	f.deleted = append(f.deleted, f.key(addressBookID, uid))
-	delete(f.contacts, f.key(addressBookID, uid))
-	return nil
+func (e *errorEventRepo) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	return 0, errors.New("fail")
 }
-
-func (f *fakeContactRepo) GetByUID(ctx context.Context, addressBookID int64, uid string) (*store.Contact, error) {
-	if f.getByUIDErr != nil && (f.getByUIDErrKey == "" || f.getByUIDErrKey == f.key(addressBookID, uid)) {
-		return nil, f.getByUIDErr
-	}
-	if c, ok := f.contacts[f.key(addressBookID, uid)]; ok {
-		copy := *c
-		return &copy, nil
-	}
-	return nil, nil
-}
-
-func (f *fakeContactRepo) ListForBook(ctx context.Context, addressBookID int64) ([]store.Contact, error) {
-	var result []store.Contact
-	for _, c := range f.contacts {
-		if c.AddressBookID != addressBookID {
-			continue
-		}
-		copy := *c
-		result = append(result, copy)
-	}
-	return result, nil
-}
-
-func (f *fakeContactRepo) ListForBookFiltered(ctx context.Context, addressBookID int64, _ store.ContactFilter) ([]store.Contact, error) {
-	return f.ListForBook(ctx, addressBookID)
-}
-
-func (f *fakeContactRepo) ListForBookPaginated(ctx context.Context, addressBookID int64, limit, offset int) (*store.PaginatedResult[store.Contact], error) {
-	contacts, _ := f.ListForBook(ctx, addressBookID)
-	return &store.PaginatedResult[store.Contact]{
-		Items:      contacts,
-		TotalCount: len(contacts),
-		Limit:      limit,
-		Offset:     offset,
-	}, nil
-}
-
-func (f *fakeContactRepo) ListByUIDs(ctx context.Context, addressBookID int64, uids []string) ([]store.Contact, error) {
-	uidSet := make(map[string]struct{}, len(uids))
-	for _, uid := range uids {
-		uidSet[uid] = struct{}{}
-	}
-	var result []store.Contact
-	for _, c := range f.contacts {
-		if c.AddressBookID != addressBookID {
-			continue
-		}
-		if _, ok := uidSet[c.UID]; ok {
-			result = append(result, *c)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeContactRepo) ListModifiedSince(ctx context.Context, addressBookID int64, since time.Time) ([]store.Contact, error) {
-	var result []store.Contact
-	for _, c := range f.contacts {
-		if c.AddressBookID != addressBookID {
-			continue
-		}
-		if c.LastModified.After(since) {
-			copy := *c
-			result = append(result, copy)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeContactRepo) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]store.Contact, error) {
-	return nil, nil
-}
-
-func (f *fakeContactRepo) MaxLastModified(ctx context.Context, addressBookID int64) (time.Time, error) {
-	var max time.Time
-	for _, c := range f.contacts {
-		if c.AddressBookID != addressBookID {
-			continue
-		}
-		if c.LastModified.After(max) {
-			max = c.LastModified
-		}
-	}
-	return max, nil
-}
-
-func (f *fakeContactRepo) ListWithBirthdaysByUser(ctx context.Context, userID int64) ([]store.Contact, error) {
-	if f.contacts == nil {
-		return nil, nil
-	}
-	var result []store.Contact
-	for _, c := range f.contacts {
-		if c.Birthday != nil {
-			result = append(result, *c)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeContactRepo) GetByResourceName(ctx context.Context, addressBookID int64, resourceName string) (*store.Contact, error) {
-	f.resourceLookupCount++
-	if f.getByResourceNameErr != nil && (f.getByResourceNameErrKey == "" || f.getByResourceNameErrKey == f.key(addressBookID, resourceName)) {
-		return nil, f.getByResourceNameErr
-	}
-	for _, c := range f.contacts {
-		if c.AddressBookID != addressBookID {
-			continue
-		}
-		name := c.ResourceName
-		if name == "" {
-			name = c.UID
-		}
-		if name == resourceName {
-			copy := *c
-			return &copy, nil
-		}
-	}
-	return nil, nil
-}
-
-func (f *fakeContactRepo) CopyToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName, newETag string) (*store.Contact, error) {
-	if f.copyErr != nil {
-		return nil, f.copyErr
-	}
-	src, ok := f.contacts[f.key(fromAddressBookID, uid)]
-	if !ok {
-		return nil, nil
-	}
-	copy := *src
-	copy.AddressBookID = toAddressBookID
-	if destResourceName != "" {
-		copy.ResourceName = destResourceName
-	}
-	for key, existing := range f.contacts {
-		if existing.AddressBookID != toAddressBookID || existing.UID == copy.UID {
-			continue
-		}
-		name := existing.ResourceName
-		if name == "" {
-			name = existing.UID
-		}
-		if name == copy.ResourceName {
-			delete(f.contacts, key)
-		}
-	}
-	copy.ETag = newETag
-	f.contacts[f.key(toAddressBookID, copy.UID)] = &copy
-	return &copy, nil
-}
-
-func (f *fakeContactRepo) MoveToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName string) error {
-	if f.moveErr != nil {
-		return f.moveErr
-	}
-	oldKey := f.key(fromAddressBookID, uid)
-	contact, ok := f.contacts[oldKey]
-	if !ok {
-		return nil // Contact not found
-	}
-	// Remove from old location
-	delete(f.contacts, oldKey)
-	// Update address book ID
-	contact.AddressBookID = toAddressBookID
-	if destResourceName != "" {
-		contact.ResourceName = destResourceName
-	}
-	for key, existing := range f.contacts {
-		if existing.AddressBookID != toAddressBookID || existing.UID == contact.UID {
-			continue
-		}
-		name := existing.ResourceName
-		if name == "" {
-			name = existing.UID
-		}
-		if name == contact.ResourceName {
-			if f.overwriteMoveDeletedRepo != nil {
-				f.overwriteMoveDeletedRepo.deleted = append(f.overwriteMoveDeletedRepo.deleted, store.DeletedResource{
-					ResourceType: "contact",
-					CollectionID: toAddressBookID,
-					UID:          existing.UID,
-					ResourceName: name,
-					DeletedAt:    time.Now(),
-				})
-			}
-			delete(f.contacts, key)
-		}
-	}
-	// Add to new location
-	newKey := f.key(toAddressBookID, uid)
-	f.contacts[newKey] = contact
-	return nil
-}
-
-type fakeCalendarRepo struct {
-	accessible       []store.CalendarAccess
-	accessibleByUser map[int64][]store.CalendarAccess
-	calendars        map[int64]*store.Calendar
-}
-
-func (f *fakeCalendarRepo) GetByID(ctx context.Context, id int64) (*store.Calendar, error) {
-	if f.calendars == nil {
-		return nil, nil
-	}
-	return f.calendars[id], nil
-}
-
-func (f *fakeCalendarRepo) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
-	if f.calendars == nil {
-		return nil, nil
-	}
-	var result []store.Calendar
-	for _, cal := range f.calendars {
-		if cal.UserID == userID {
-			result = append(result, *cal)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeCalendarRepo) ListAccessible(ctx context.Context, userID int64) ([]store.CalendarAccess, error) {
-	if f.accessibleByUser != nil {
-		return f.accessibleByUser[userID], nil
-	}
-	return f.accessible, nil
-}
-
-func (f *fakeCalendarRepo) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
-	if f.accessibleByUser != nil {
-		for _, c := range f.accessibleByUser[userID] {
-			if c.ID == calendarID {
-				if c.UserID == 0 && !c.Shared {
-					c.UserID = userID
-				}
-				copy := c
-				return &copy, nil
-			}
-		}
-		return nil, nil
-	}
-	for _, c := range f.accessible {
-		if c.ID == calendarID {
-			if c.UserID == 0 && !c.Shared {
-				c.UserID = userID
-			}
-			copy := c
-			return &copy, nil
-		}
-	}
-	return nil, nil
-}
-
-func (f *fakeCalendarRepo) Create(ctx context.Context, cal store.Calendar) (*store.Calendar, error) {
-	if f.calendars == nil {
-		f.calendars = map[int64]*store.Calendar{}
-	}
-	cal.ID = int64(len(f.calendars) + 1)
-	copy := cal
-	f.calendars[copy.ID] = &copy
-	if cal.UserID != 0 {
-		f.accessible = append(f.accessible, store.CalendarAccess{Calendar: copy, Editor: true})
-	}
-	return &copy, nil
-}
-
-func (f *fakeCalendarRepo) Update(ctx context.Context, userID, id int64, name string, description, timezone, color *string) error {
-	cal, ok := f.calendars[id]
-	if !ok || cal.UserID != userID {
-		return store.ErrNotFound
-	}
-	cal.Name = name
-	cal.Description = description
-	cal.Timezone = timezone
-	cal.Color = color
-	return nil
-}
-
-func (f *fakeCalendarRepo) UpdateProperties(ctx context.Context, id int64, name string, description, timezone, color *string) error {
-	cal, ok := f.calendars[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	cal.Name = name
-	cal.Description = description
-	cal.Timezone = timezone
-	cal.Color = color
-	return nil
-}
-
-func (f *fakeCalendarRepo) Rename(ctx context.Context, userID, id int64, name string) error {
-	return nil
-}
-
-func (f *fakeCalendarRepo) Delete(ctx context.Context, userID, id int64) error {
-	cal, ok := f.calendars[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	if cal.UserID != userID {
-		return store.ErrNotFound
-	}
-	delete(f.calendars, id)
-	return nil
-}
-
-type fakeAddressBookRepo struct {
-	books map[int64]*store.AddressBook
-}
-
-func (f *fakeAddressBookRepo) hasDuplicateName(userID, excludeID int64, name string) bool {
-	for id, book := range f.books {
-		if id == excludeID || book.UserID != userID {
-			continue
-		}
-		if strings.EqualFold(book.Name, name) {
-			return true
-		}
-	}
-	return false
-}
-
-func (f *fakeAddressBookRepo) GetByID(ctx context.Context, id int64) (*store.AddressBook, error) {
-	if f.books == nil {
-		return nil, nil
-	}
-	return f.books[id], nil
-}
-
-func (f *fakeAddressBookRepo) ListByUser(ctx context.Context, userID int64) ([]store.AddressBook, error) {
-	var res []store.AddressBook
-	for _, b := range f.books {
-		if b.UserID == userID {
-			copy := *b
-			res = append(res, copy)
-		}
-	}
-	return res, nil
-}
-
-func (f *fakeAddressBookRepo) Create(ctx context.Context, book store.AddressBook) (*store.AddressBook, error) {
-	if f.books == nil {
-		f.books = map[int64]*store.AddressBook{}
-	}
-	if f.hasDuplicateName(book.UserID, 0, book.Name) {
-		return nil, store.ErrConflict
-	}
-	book.ID = int64(len(f.books) + 1)
-	copy := book
-	f.books[copy.ID] = &copy
-	return &copy, nil
-}
-
-func (f *fakeAddressBookRepo) Update(ctx context.Context, userID, id int64, name string, description *string) error {
-	book, ok := f.books[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	if book.UserID != userID {
-		return store.ErrNotFound
-	}
-	if f.hasDuplicateName(userID, id, name) {
-		return store.ErrConflict
-	}
-	book.Name = name
-	if description != nil {
-		book.Description = description
-	}
-	return nil
-}
-
-func (f *fakeAddressBookRepo) UpdateProperties(ctx context.Context, id int64, name string, description *string) error {
-	book, ok := f.books[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	if f.hasDuplicateName(book.UserID, id, name) {
-		return store.ErrConflict
-	}
-	book.Name = name
-	if description != nil {
-		book.Description = description
-	}
-	return nil
-}
-
-func (f *fakeAddressBookRepo) Rename(ctx context.Context, userID, id int64, name string) error {
-	book, ok := f.books[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	if book.UserID != userID {
-		return store.ErrNotFound
-	}
-	if f.hasDuplicateName(userID, id, name) {
-		return store.ErrConflict
-	}
-	book.Name = name
-	return nil
-}
-
-func (f *fakeAddressBookRepo) Delete(ctx context.Context, userID, id int64) error {
-	book, ok := f.books[id]
-	if !ok {
-		return store.ErrNotFound
-	}
-	if book.UserID != userID {
-		return store.ErrNotFound
-	}
-	delete(f.books, id)
-	return nil
-}
-
-type fakeDeletedResourceRepo struct {
-	deleted []store.DeletedResource
-}
-
-func (f *fakeDeletedResourceRepo) ListDeletedSince(ctx context.Context, resourceType string, collectionID int64, since time.Time) ([]store.DeletedResource, error) {
-	var result []store.DeletedResource
-	for _, d := range f.deleted {
-		if d.ResourceType == resourceType && d.CollectionID == collectionID && d.DeletedAt.After(since) {
-			result = append(result, d)
-		}
-	}
-	return result, nil
-}
-
-func (f *fakeDeletedResourceRepo) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
-	filtered := f.deleted[:0]
-	for _, d := range f.deleted {
-		if d.ResourceType == resourceType && d.CollectionID == collectionID && d.UID == uid && d.ResourceName == resourceName {
-			continue
-		}
-		filtered = append(filtered, d)
-	}
-	f.deleted = filtered
-	return nil
-}
-
-func (f *fakeDeletedResourceRepo) Cleanup(ctx context.Context, olderThan time.Duration) (int64, error) {
-	return 0, nil
+func (e *errorEventRepo) SplitToNewCalendar(ctx context.Context, cal store.Calendar, fromCalendarID int64, uids []string) (*store.Calendar, int, error) {
+	return nil, 0, errors.New("fail")
 }
 
 func TestCalendarDataUseCDATA(t *testing.T) {
@@ -8173,13 +8108,13 @@ func TestReportRequestParsesDifferentNamespacePrefixes(t *testing.T) {
 }
 
 func TestCalendarQueryWithCompFilter(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "event1", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1"},
 			"1:todo1":  {CalendarID: 1, UID: "todo1", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:todo1\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", ETag: "t1"},
 		},
@@ -8215,13 +8150,13 @@ func TestCalendarQueryWithTimeRangeFilter(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:in-range":  {CalendarID: 1, UID: "in-range", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:in-range\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1", DTStart: &start, DTEnd: &end},
 			"1:out-range": {CalendarID: 1, UID: "out-range", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:out-range\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e2", DTStart: ptrTime(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)), DTEnd: ptrTime(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC))},
 		},
@@ -8257,12 +8192,12 @@ func TestCalendarQueryWithTimeRangeFilter(t *testing.T) {
 
 func TestPropfindIncludesSupportedCalendarComponentSet(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/2/", nil)
@@ -8291,13 +8226,13 @@ func TestPropfindIncludesSupportedCalendarComponentSet(t *testing.T) {
 }
 
 func TestPutWithIfMatchSuccess(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "OLD", ETag: "old-etag"},
 		},
 	}
@@ -8319,18 +8254,18 @@ func TestPutWithIfMatchSuccess(t *testing.T) {
 func TestGetCalendarObjectUsesCollectionACLFallback(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {
 				CalendarID:   2,
 				UID:          "event",
@@ -8365,18 +8300,18 @@ func TestGetCalendarObjectUsesCollectionACLFallback(t *testing.T) {
 func TestGetCalendarObjectUsesCollectionACLFallbackDespiteUnrelatedObjectACL(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {
 				CalendarID:   2,
 				UID:          "event",
@@ -8412,18 +8347,18 @@ func TestGetCalendarObjectUsesCollectionACLFallbackDespiteUnrelatedObjectACL(t *
 func TestGetCalendarObjectAllowsObjectReadGrantWithoutCollectionAccess(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {
 				CalendarID:   2,
 				UID:          "event",
@@ -8455,8 +8390,8 @@ func TestGetCalendarObjectAllowsObjectReadGrantWithoutCollectionAccess(t *testin
 func TestGetCalendarObjectHonorsExplicitObjectReadDeny(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			delegate.ID: {
 				{
 					Calendar:   store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"},
@@ -8466,12 +8401,12 @@ func TestGetCalendarObjectHonorsExplicitObjectReadDeny(t *testing.T) {
 				},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {
 				CalendarID:   2,
 				UID:          "event",
@@ -8507,18 +8442,18 @@ func TestGetCalendarObjectHonorsExplicitObjectReadDeny(t *testing.T) {
 func TestReportCalendarQueryUsesCollectionACLFallback(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 1, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			1: {ID: 1, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "event1", ResourceName: "event1", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1"},
 		},
 	}
@@ -8548,18 +8483,18 @@ func TestReportCalendarQueryUsesCollectionACLFallback(t *testing.T) {
 func TestPutCalendarObjectUsesCollectionWriteFallbackDespiteUnrelatedObjectACL(t *testing.T) {
 	owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 	delegate := &store.User{ID: 2, PrimaryEmail: "delegate@example.com"}
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			owner.ID: {
 				{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: owner.ID, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {
 				CalendarID:   2,
 				UID:          "event",
@@ -8590,20 +8525,20 @@ func TestPutCalendarObjectUsesCollectionWriteFallbackDespiteUnrelatedObjectACL(t
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("expected PUT to succeed via collection ACL fallback, got %d: %s", rr.Code, rr.Body.String())
 	}
-	updated := eventRepo.events["2:event"]
+	updated := eventRepo.Events["2:event"]
 	if updated == nil || !strings.Contains(updated.RawICAL, "SUMMARY:Updated") {
 		t.Fatalf("expected event update to succeed, got %#v", updated)
 	}
 }
 
 func TestPutWithIfMatchFailure(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "OLD", ETag: "old-etag"},
 		},
 	}
@@ -8623,12 +8558,12 @@ func TestPutWithIfMatchFailure(t *testing.T) {
 }
 
 func TestPutWithIfNoneMatchStar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: map[string]*store.Event{}}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:new\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -8645,13 +8580,13 @@ func TestPutWithIfNoneMatchStar(t *testing.T) {
 }
 
 func TestPutWithIfNoneMatchStarFailsIfExists(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "OLD", ETag: "old"},
 		},
 	}
@@ -8676,17 +8611,17 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:event\r\nDTSTART:20240601T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
 
 	t.Run("read-only grant cannot create event", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{events: map[string]*store.Event{}}
+		eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
 		h := &Handler{store: &store.Store{
 			Calendars: calRepo,
 			Events:    eventRepo,
@@ -8707,17 +8642,17 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("bind grant can create event", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{events: map[string]*store.Event{}}
+		eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
 		h := &Handler{store: &store.Store{
 			Calendars: calRepo,
 			Events:    eventRepo,
@@ -8739,18 +8674,18 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("write-content grant can modify existing event", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "OLD", ETag: "old-etag"},
 			},
 		}
@@ -8776,18 +8711,18 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("object-level write-content grant can modify existing event without collection access", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "OLD", ETag: "old-etag"},
 			},
 		}
@@ -8812,8 +8747,8 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("object-level write-content deny blocks existing event update despite collection grant", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				delegate.ID: {
 					{
 						Calendar:   store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"},
@@ -8823,12 +8758,12 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 					},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "OLD", ETag: "old-etag"},
 			},
 		}
@@ -8855,18 +8790,18 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("unbind grant can delete existing event", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "ICAL", ETag: "current"},
 			},
 		}
@@ -8892,18 +8827,18 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("object-level unbind grant can delete existing event without collection access", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				owner.ID: {
 					{Calendar: store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"}, Editor: true},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "ICAL", ETag: "current"},
 			},
 		}
@@ -8928,8 +8863,8 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 	})
 
 	t.Run("object-level unbind deny blocks existing event delete despite collection grant", func(t *testing.T) {
-		calRepo := &fakeCalendarRepo{
-			accessibleByUser: map[int64][]store.CalendarAccess{
+		calRepo := &storetest.Calendars{
+			AccessibleByUser: map[int64][]store.CalendarAccess{
 				delegate.ID: {
 					{
 						Calendar:   store.Calendar{ID: 2, UserID: owner.ID, Name: "Work"},
@@ -8939,12 +8874,12 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 					},
 				},
 			},
-			calendars: map[int64]*store.Calendar{
+			Calendars: map[int64]*store.Calendar{
 				2: {ID: 2, UserID: owner.ID, Name: "Work"},
 			},
 		}
-		eventRepo := &fakeEventRepo{
-			events: map[string]*store.Event{
+		eventRepo := &storetest.Events{
+			Events: map[string]*store.Event{
 				"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: "ICAL", ETag: "current"},
 			},
 		}
@@ -8972,13 +8907,13 @@ func TestCalendarCollectionACLControlsWriteOperations(t *testing.T) {
 }
 
 func TestDeleteWithIfMatchSuccess(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "current"},
 		},
 	}
@@ -8997,13 +8932,13 @@ func TestDeleteWithIfMatchSuccess(t *testing.T) {
 }
 
 func TestDeleteWithIfMatchFailure(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICAL", ETag: "current"},
 		},
 	}
@@ -9022,12 +8957,12 @@ func TestDeleteWithIfMatchFailure(t *testing.T) {
 }
 
 func TestPutRejectsInvalidICalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 
 	tests := []struct {
 		name string
@@ -9058,12 +8993,12 @@ func TestPutRejectsInvalidICalendar(t *testing.T) {
 }
 
 func TestPutAcceptsValidICalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 
 	validData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:test\r\nDTSTART:20240601T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
 	req := newCalendarPutRequest("/dav/calendars/2/test.ics", strings.NewReader(validData))
@@ -9086,12 +9021,12 @@ func TestValidateICalendarDetectsVEVENT(t *testing.T) {
 }
 
 func TestPutRejectsInvalidVCard(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 
 	invalidData := "NOT A VCARD"
 	req := httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/alice.vcf", strings.NewReader(invalidData))
@@ -9109,12 +9044,12 @@ func TestPutRejectsInvalidVCard(t *testing.T) {
 }
 
 func TestPutAcceptsValidVCard(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 
 	validData := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:alice\r\nFN:Alice\r\nEND:VCARD\r\n"
 	req := httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/alice.vcf", strings.NewReader(validData))
@@ -9128,6 +9063,37 @@ func TestPutAcceptsValidVCard(t *testing.T) {
 	}
 }
 
+func TestPutStrictContactValidation(t *testing.T) {
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
+			5: {ID: 5, UserID: 1, Name: "Contacts"},
+		},
+	}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}, cfg: &config.Config{ContactValidation: config.ContactValidationStrict}}
+
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:alice\r\nFN:Alice\r\nEMAIL:alice\r\nURL:ftp://example.com\r\nEND:VCARD\r\n"
+	req := httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/alice.vcf", strings.NewReader(card))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 in strict mode, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "valid-address-data") || !strings.Contains(body, "EMAIL") || !strings.Contains(body, "URL") {
+		t.Fatalf("expected itemized valid-address-data error, got %s", body)
+	}
+
+	h.cfg.ContactValidation = config.ContactValidationLenient
+	req = httptest.NewRequest(http.MethodPut, "/dav/addressbooks/5/alice.vcf", strings.NewReader(card))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 in lenient mode, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
 func TestParseICalDateTime(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -9171,13 +9137,13 @@ func TestFreeBusyQueryReport(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "event1", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1", DTStart: &start, DTEnd: &end},
 		},
 	}
@@ -9213,12 +9179,12 @@ func TestFreeBusyQueryReport(t *testing.T) {
 }
 
 func TestPropfindParsesRequestBody(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8" ?>
@@ -9247,12 +9213,12 @@ func TestPropfindParsesRequestBody(t *testing.T) {
 }
 
 func TestPropfindParsesChunkedRequestBody(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8" ?>
@@ -9281,8 +9247,8 @@ func TestPropfindParsesChunkedRequestBody(t *testing.T) {
 }
 
 func TestProppatchAddressBookUpdatesProperties(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Old Name"},
 		},
 	}
@@ -9314,8 +9280,8 @@ func TestProppatchAddressBookUpdatesProperties(t *testing.T) {
 }
 
 func TestProppatchAddressBookRejectsDuplicateDisplayName(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 			6: {ID: 6, UserID: 1, Name: "Work"},
 		},
@@ -9347,8 +9313,8 @@ func TestProppatchAddressBookRejectsDuplicateDisplayName(t *testing.T) {
 }
 
 func TestProppatchAddressBookProtectedPropertyIsAtomic(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
@@ -9382,19 +9348,19 @@ func TestProppatchAddressBookProtectedPropertyIsAtomic(t *testing.T) {
 	if strings.Contains(rr.Body.String(), "200 OK") {
 		t.Fatalf("expected atomic failure with no successful propstat, got %s", rr.Body.String())
 	}
-	if got := bookRepo.books[5].Name; got != "Contacts" {
+	if got := bookRepo.Books[5].Name; got != "Contacts" {
 		t.Fatalf("expected PROPPATCH to leave address book unchanged, got %q", got)
 	}
 }
 
 func TestCalendarPropertiesIncludeLimits(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/2/", nil)
@@ -9428,12 +9394,12 @@ func TestCalendarPropertiesIncludeLimits(t *testing.T) {
 func TestCalendarPropfindIncludesRequestedCalendarColor(t *testing.T) {
 	now := store.Now()
 	color := "#336699FF"
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", Color: &color, CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	body := `<D:propfind xmlns:D="DAV:" xmlns:ical="http://apple.com/ns/ical/">
@@ -9462,12 +9428,12 @@ func TestCalendarPropfindIncludesRequestedCalendarColor(t *testing.T) {
 
 func TestCalendarPropfindReturnsNotFoundForUnsetCalendarColor(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	u := &store.User{ID: 1}
 
 	body := `<D:propfind xmlns:D="DAV:" xmlns:ical="http://apple.com/ns/ical/">
@@ -9498,11 +9464,11 @@ func TestCalendarPropfindReturnsNotFoundForUnsetCalendarColor(t *testing.T) {
 }
 
 func TestProppatchRejectsForbidden(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Not Mine"}, Editor: false},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: 2, Name: "Not Mine"},
 		},
 	}
@@ -9540,8 +9506,8 @@ func TestProppatchSharedCalendarWritePropertiesGrantPersistsChange(t *testing.T)
 	owner := &store.User{ID: 1}
 	delegate := &store.User{ID: 2}
 	description := "Before"
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: owner.ID, Name: "Shared", Description: &description},
 		},
 	}
@@ -9586,8 +9552,8 @@ func TestProppatchSharedCalendarWritePropertiesGrantPersistsChange(t *testing.T)
 
 func TestProppatchCalendarColorPersistsChange(t *testing.T) {
 	user := &store.User{ID: 1}
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: user.ID, Name: "Work"},
 		},
 	}
@@ -9626,8 +9592,8 @@ func TestProppatchCalendarColorPersistsChange(t *testing.T) {
 func TestProppatchCalendarColorRemoveClearsChange(t *testing.T) {
 	user := &store.User{ID: 1}
 	color := "#22CC8880"
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			5: {ID: 5, UserID: user.ID, Name: "Work", Color: &color},
 		},
 	}
@@ -9667,13 +9633,13 @@ func TestFreeBusyIncludesDateRange(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "event1", RawICAL: "ICAL", ETag: "e1", DTStart: &start, DTEnd: &end},
 		},
 	}
@@ -9708,13 +9674,13 @@ func TestFreeBusyQuerySkipsDeniedCalendarObjects(t *testing.T) {
 	hiddenStart := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
 	hiddenEnd := time.Date(2024, 6, 2, 13, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		calendars: map[int64]*store.Calendar{
+	calRepo := &storetest.Calendars{
+		Calendars: map[int64]*store.Calendar{
 			1: {ID: 1, UserID: 1, Name: "Shared"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:visible": {CalendarID: 1, UID: "visible", ResourceName: "visible", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:visible\r\nDTSTART:20240601T100000Z\r\nDTEND:20240601T110000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1", DTStart: &visibleStart, DTEnd: &visibleEnd},
 			"1:hidden":  {CalendarID: 1, UID: "hidden", ResourceName: "hidden", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:hidden\r\nDTSTART:20240602T120000Z\r\nDTEND:20240602T130000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e2", DTStart: &hiddenStart, DTEnd: &hiddenEnd},
 		},
@@ -9756,8 +9722,8 @@ func TestFreeBusyQueryRejectsReadOnlyCalendarWhenReadFreeBusyIsExplicitlyDenied(
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 11, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessibleByUser: map[int64][]store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		AccessibleByUser: map[int64][]store.CalendarAccess{
 			delegate.ID: {
 				{
 					Calendar:   store.Calendar{ID: 1, UserID: 1, Name: "Shared"},
@@ -9767,12 +9733,12 @@ func TestFreeBusyQueryRejectsReadOnlyCalendarWhenReadFreeBusyIsExplicitlyDenied(
 				},
 			},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			1: {ID: 1, UserID: 1, Name: "Shared"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID:   1,
 				UID:          "event",
@@ -9809,13 +9775,162 @@ func TestFreeBusyQueryRejectsReadOnlyCalendarWhenReadFreeBusyIsExplicitlyDenied(
 	}
 }
 
+func TestPropfindOnCalendarObjectResource(t *testing.T) {
+	modified := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: modified}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
+			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1", LastModified: modified},
+		},
+	}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+
+	body := `<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:getcontenttype/><d:getlastmodified/></d:prop></d:propfind>`
+	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/event.ics", strings.NewReader(body))
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+
+	h.Propfind(rr, req)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	resp := rr.Body.String()
+	for _, want := range []string{
+		"<d:href>/dav/calendars/1/event.ics</d:href>",
+		`<d:getetag>&#34;e1&#34;</d:getetag>`,
+		"<d:getcontenttype>text/calendar; charset=utf-8</d:getcontenttype>",
+		"<d:getlastmodified>Wed, 04 Mar 2026 05:06:07 GMT</d:getlastmodified>",
+	} {
+		if !strings.Contains(resp, want) {
+			t.Fatalf("expected %s in response, got %s", want, resp)
+		}
+	}
+	if strings.Contains(resp, "calendar-data") {
+		t.Fatalf("unexpected calendar-data for prop-limited PROPFIND: %s", resp)
+	}
+}
+
+func TestPropfindOnBirthdayCalendarResource(t *testing.T) {
+	birthday := time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)
+	name := "John Doe"
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
+			"1:contact1": {AddressBookID: 1, UID: "contact1", DisplayName: &name, Birthday: &birthday, LastModified: store.Now()},
+		},
+	}
+	h := &Handler{store: &store.Store{Contacts: contactRepo}}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus string
+	}{
+		{name: "existing", path: fmt.Sprintf("/dav/calendars/%d/birthday-contact1@calcard.ics", birthdayCalendarID), wantStatus: "getetag"},
+		{name: "missing", path: fmt.Sprintf("/dav/calendars/%d/nope.ics", birthdayCalendarID), wantStatus: httpStatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PROPFIND", tt.path, nil)
+			req.Header.Set("Depth", "0")
+			req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+			rr := httptest.NewRecorder()
+
+			h.Propfind(rr, req)
+
+			if rr.Code != http.StatusMultiStatus {
+				t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+			}
+			resp := rr.Body.String()
+			if !strings.Contains(resp, "<d:href>"+tt.path+"</d:href>") || !strings.Contains(resp, tt.wantStatus) {
+				t.Fatalf("expected resource response for %s, got %s", tt.path, resp)
+			}
+			if strings.Contains(resp, "<d:collection") {
+				t.Fatalf("expected object response, got collection: %s", resp)
+			}
+		})
+	}
+}
+
+func TestReportCalendarHomeFansOutAcrossCalendars(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Home"}, Editor: true},
+		},
+	}
+	ical := func(uid string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART:20260101T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	}
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
+			"1:work-event": {CalendarID: 1, UID: "work-event", RawICAL: ical("work-event"), ETag: "w"},
+			"2:home-event": {CalendarID: 2, UID: "home-event", RawICAL: ical("home-event"), ETag: "h"},
+		},
+	}
+	birthday := time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)
+	name := "John Doe"
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
+			"1:contact1": {AddressBookID: 1, UID: "contact1", DisplayName: &name, Birthday: &birthday},
+		},
+	}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, Contacts: contactRepo}}
+
+	run := func(body, depth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("REPORT", "/dav/calendars/", strings.NewReader(body))
+		if depth != "" {
+			req.Header.Set("Depth", depth)
+		}
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Report(rr, req)
+		return rr
+	}
+
+	query := `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop><C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT"/></C:comp-filter></C:filter></C:calendar-query>`
+	rr := run(query, "1")
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, want := range []string{"/dav/calendars/1/work-event.ics", "/dav/calendars/2/home-event.ics", fmt.Sprintf("/dav/calendars/%d/birthday-contact1@calcard.ics", birthdayCalendarID)} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("expected %s in home query response, got %s", want, rr.Body.String())
+		}
+	}
+
+	rr = run(query, "0")
+	if rr.Code != http.StatusMultiStatus || strings.Contains(rr.Body.String(), "<d:response>") {
+		t.Fatalf("expected empty multistatus for Depth: 0, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	multiget := `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><C:calendar-data/></D:prop>` +
+		`<D:href>/dav/calendars/2/home-event.ics</D:href><D:href>/dav/calendars/1/work-event.ics</D:href><D:href>/dav/calendars/9/other.ics</D:href></C:calendar-multiget>`
+	rr = run(multiget, "")
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "UID:home-event") || !strings.Contains(body, "UID:work-event") {
+		t.Fatalf("expected events from both calendars, got %s", body)
+	}
+	if !strings.Contains(body, "<d:href>/dav/calendars/9/other.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>") {
+		t.Fatalf("expected inaccessible href to be reported as 404, got %s", body)
+	}
+}
+
 func TestReportRejectsCalendarResourcePath(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 
 	testCases := []struct {
 		name         string
@@ -9863,12 +9978,12 @@ func TestReportRejectsCalendarResourcePath(t *testing.T) {
 }
 
 func TestReportRejectsAddressBookResourcePath(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			3: {ID: 3, UserID: 1, Name: "Contacts"},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 
 	testCases := []struct {
 		name       string
@@ -9908,3 +10023,80 @@ func TestReportRejectsAddressBookResourcePath(t *testing.T) {
 		})
 	}
 }
+
+func TestContactPhotoUploadServeAndRemove(t *testing.T) {
+	now := store.Now()
+	blobs, err := store.NewDirBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirBlobStore() error = %v", err)
+	}
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{
+		3: {ID: 3, UserID: 1, Name: "Contacts", UpdatedAt: now},
+	}}
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:alice\r\nFN:Alice\r\nPHOTO;ENCODING=b;TYPE=PNG:AAAA\r\nEND:VCARD\r\n"
+	contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{
+		"3:alice": {AddressBookID: 3, UID: "alice", ResourceName: "alice", RawVCard: card, ETag: "e1", LastModified: now},
+	}}
+	h := &Handler{
+		cfg:   &config.Config{BaseURL: "https://dav.example.com/"},
+		store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, Blobs: blobs},
+	}
+	owner := &store.User{ID: 1}
+	do := func(method, target, contentType, body string, user *store.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("PROPFIND", "/dav/addressbooks/3/", "", `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><d:prop><card:max-image-size/></d:prop></d:propfind>`, owner)
+	if rr.Code != http.StatusMultiStatus || !strings.Contains(rr.Body.String(), ">1048576</") {
+		t.Fatalf("expected max-image-size to be advertised, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 32)
+	if rr := do(http.MethodPost, "/dav/addressbooks/3/alice.vcf?action=photo-add", "image/jpeg", png, owner); rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected a mislabeled photo to be rejected, got %d", rr.Code)
+	}
+	big := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", int(maxContactPhotoBytes))
+	if rr := do(http.MethodPost, "/dav/addressbooks/3/alice.vcf?action=photo-add", "image/png", big, owner); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected an oversized photo to be rejected, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/dav/addressbooks/3/alice.vcf?action=photo-add", "image/png", png, owner)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location := rr.Header().Get("Location")
+	if !strings.HasPrefix(location, "https://dav.example.com/dav/photos/3/") || !strings.HasSuffix(location, ".png") {
+		t.Fatalf("unexpected Location %q", location)
+	}
+	stored := contactRepo.Contacts["3:alice"]
+	if strings.Contains(stored.RawVCard, "ENCODING=b") || !strings.Contains(strings.ReplaceAll(stored.RawVCard, "\r\n ", ""), "PHOTO;VALUE=uri;TYPE=PNG:"+location) {
+		t.Fatalf("expected PHOTO to reference the upload, got %q", stored.RawVCard)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"`+stored.ETag+`"` || stored.ETag == "e1" {
+		t.Fatalf("ETag = %q, stored %q", etag, stored.ETag)
+	}
+
+	photoPath := strings.TrimPrefix(location, "https://dav.example.com")
+	rr = do(http.MethodGet, photoPath, "", "", owner)
+	if rr.Code != http.StatusOK || rr.Body.String() != png || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("GET photo = %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr := do(http.MethodGet, photoPath, "", "", &store.User{ID: 2}); rr.Code != http.StatusNotFound && rr.Code != http.StatusForbidden {
+		t.Fatalf("expected another user to be refused the photo, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/dav/addressbooks/3/alice.vcf?action=photo-remove", "", "", owner)
+	if rr.Code != http.StatusNoContent || strings.Contains(contactRepo.Contacts["3:alice"].RawVCard, "PHOTO") {
+		t.Fatalf("expected the photo to be removed, got %d: %q", rr.Code, contactRepo.Contacts["3:alice"].RawVCard)
+	}
+	if rr := do(http.MethodGet, photoPath, "", "", owner); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the removed photo to be deleted, got %d", rr.Code)
+	}
+}
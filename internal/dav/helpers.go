@@ -2,7 +2,9 @@ package dav
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/jw6ventures/calcard/internal/store"
 )
@@ -91,6 +93,35 @@ func calendarCollectionResponseWithPrivileges(href, name string, description, ti
 	return resp
 }
 
+// withCalendarOwner sets DAV:owner to the calendar owner's principal and,
+// when the calendar is shared with user, a CS:invite naming the owner and
+// the user's access so clients can show "Shared by" and disable editing.
+func withCalendarOwner(resp response, user *store.User, cal store.CalendarAccess) response {
+	if len(resp.Propstat) == 0 {
+		return resp
+	}
+	p := &resp.Propstat[0].Prop
+	p.Owner = &hrefProp{Href: fmt.Sprintf("/dav/principals/%d/", cal.UserID)}
+	if !cal.Shared || user == nil {
+		return resp
+	}
+	access := inviteAccess{Read: &struct{}{}}
+	if cal.EffectivePrivileges().AllowsAnyWrite() {
+		access = inviteAccess{ReadWrite: &struct{}{}}
+	}
+	invite := &inviteProp{User: []inviteUser{{
+		Href:           "mailto:" + user.PrimaryEmail,
+		CommonName:     user.PrimaryEmail,
+		InviteAccepted: &struct{}{},
+		Access:         access,
+	}}}
+	if cal.OwnerEmail != "" {
+		invite.Organizer = &inviteOrganizer{Href: "mailto:" + cal.OwnerEmail, CommonName: cal.OwnerEmail}
+	}
+	p.Invite = invite
+	return resp
+}
+
 func addressBookCollectionResponse(href, name string, description *string, principalHref, syncToken, ctag string) response {
 	resp := response{
 		Href:     href,
@@ -108,6 +139,7 @@ func addressBookCollectionResponse(href, name string, description *string, princ
 	}
 	p.SupportedAddressData = supportedAddressDataProp()
 	p.AddressBookMaxResourceSize = fmt.Sprintf("%d", maxDAVBodyBytes)
+	p.AddressBookMaxImageSize = fmt.Sprintf("%d", maxContactPhotoBytes)
 	p.SupportedCollationSet = supportedCollationSetProp()
 	return resp
 }
@@ -247,7 +279,7 @@ func supportedAddressDataProp() *supportedAddressData {
 
 func supportedCollationSetProp() *supportedCollationSet {
 	return &supportedCollationSet{
-		SupportedCollation: []string{"i;ascii-casemap", "i;unicode-casemap"},
+		SupportedCollation: supportedCollations,
 	}
 }
 
@@ -304,3 +336,11 @@ func calendarTimezoneValue(tz *string) *string {
 }
 
 const defaultCalendarTimezone = "BEGIN:VTIMEZONE\nTZID:UTC\nBEGIN:STANDARD\nDTSTART:19700101T000000Z\nTZOFFSETFROM:+0000\nTZOFFSETTO:+0000\nTZNAME:UTC\nEND:STANDARD\nEND:VTIMEZONE"
+
+// httpDate formats t for DAV:getlastmodified (RFC 4918 §15.7); zero times are omitted.
+func httpDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(http.TimeFormat)
+}
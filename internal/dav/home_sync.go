@@ -0,0 +1,242 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// homeSyncCollection answers sync-collection on the calendar or address book
+// home (RFC 6578 §3.2). An initial sync lists every collection the user can
+// reach. An incremental sync lists the collections added, renamed, recolored
+// or re-shared since the token, and reports the ones deleted or unshared as
+// 404 members, so clients can follow the home without full re-discovery.
+func (h *Handler) homeSyncCollection(w http.ResponseWriter, r *http.Request, user *store.User, cleanPath string, report reportRequest) {
+	collectionType, kind, label := "calendar", "calhome", "calendar"
+	if cleanPath == "/dav/addressbooks" {
+		collectionType, kind, label = "addressbook", "cardhome", "addressbook"
+	}
+	responses, syncToken, err := h.homeSyncResponses(r.Context(), user, cleanPath, collectionType, kind, report.SyncToken)
+	if err != nil {
+		if errors.Is(err, errInvalidSyncToken) {
+			metrics.IncInvalidSyncToken(r, label)
+			writeDAVError(w, http.StatusForbidden, "invalid sync token", condValidSyncToken)
+			return
+		}
+		h.logger().Error("Report", "home sync-collection on %s failed: %v", cleanPath, err)
+		writeDAVError(w, http.StatusInternalServerError, "failed to list collections")
+		return
+	}
+
+	payload := multistatus{
+		XMLName:   xml.Name{Space: "DAV:", Local: "multistatus"},
+		XmlnsD:    "DAV:",
+		XmlnsC:    "urn:ietf:params:xml:ns:caldav",
+		XmlnsA:    "urn:ietf:params:xml:ns:carddav",
+		XmlnsCS:   "http://calendarserver.org/ns/",
+		XmlnsICAL: "http://apple.com/ns/ical/",
+		SyncToken: syncToken,
+		Response:  responses,
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_ = xml.NewEncoder(w).Encode(payload)
+}
+
+func (h *Handler) homeSyncResponses(ctx context.Context, user *store.User, cleanPath, collectionType, kind, token string) ([]response, string, error) {
+	var since time.Time
+	if strings.TrimSpace(token) != "" {
+		info, err := parseSyncToken(token)
+		if err != nil || info.Kind != kind || info.ID != user.ID || info.Cursor != "" {
+			return nil, "", errInvalidSyncToken
+		}
+		since = info.Timestamp
+	}
+
+	changes, err := h.homeChanges(ctx, user, collectionType, since)
+	if err != nil {
+		return nil, "", err
+	}
+	var all []response
+	if collectionType == "calendar" {
+		all, err = h.calendarResponses(ctx, cleanPath, "1", user, ensureHomeMemberHref, nil)
+	} else {
+		all, err = h.addressBookResponses(ctx, cleanPath, "1", user, ensureHomeMemberHref, nil)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if since.IsZero() {
+		return all, buildSyncToken(kind, user.ID, changes.latest), nil
+	}
+
+	// An incremental sync lists only the collections added or changed since
+	// the token, then the ones the user lost.
+	var responses []response
+	for _, resp := range all {
+		href := ensureHomeMemberHref(resp.Href)
+		if changedAt, ok := changes.changedAt[href]; ok && (changedAt.After(since) || changes.accessChanged[href]) {
+			responses = append(responses, resp)
+		}
+	}
+	for _, t := range changes.lost {
+		responses = append(responses, deletedResponse(ensureHomeMemberHref(tombstoneHref(user, t))))
+	}
+	return responses, buildSyncToken(kind, user.ID, changes.latest), nil
+}
+
+// homeChanges is the state of a calendar or address book home relative to a
+// sync token's timestamp.
+type homeChanges struct {
+	// changedAt is when each reachable collection, by href, last changed
+	// itself or was last granted to the user.
+	changedAt map[string]time.Time
+	// accessChanged marks reachable collections that lost a grant since
+	// the token, so the user's privileges on them may have changed.
+	accessChanged map[string]bool
+	// lost are the collections deleted or unshared since the token.
+	lost []store.CollectionTombstone
+	// latest is the timestamp of the home's sync token.
+	latest time.Time
+}
+
+func (h *Handler) homeChanges(ctx context.Context, user *store.User, collectionType string, since time.Time) (*homeChanges, error) {
+	changes := &homeChanges{changedAt: map[string]time.Time{}, accessChanged: map[string]bool{}, latest: since}
+	note := func(href string, t time.Time) {
+		changes.changedAt[ensureHomeMemberHref(href)] = t
+		changes.latest = latestTime(changes.latest, t)
+	}
+
+	// Grants bring a collection into the home without touching it, so they
+	// count as changes too.
+	granted := map[string]time.Time{}
+	if h.store != nil && h.store.ACLEntries != nil {
+		for _, principal := range aclPrincipalHrefs(user) {
+			entries, err := h.store.ACLEntries.ListByPrincipal(ctx, principal)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				collectionPath := calendarCollectionPath(entry.ResourcePath)
+				if collectionType == "addressbook" {
+					collectionPath = addressBookCollectionPath(entry.ResourcePath)
+				}
+				if entry.IsGrant && entry.CreatedAt.After(granted[collectionPath]) {
+					granted[collectionPath] = entry.CreatedAt
+				}
+			}
+		}
+	}
+	if collectionType == "calendar" {
+		cals, err := h.accessibleCalendars(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cals {
+			note(calendarCollectionHref(user, &c.Calendar), latestTime(c.UpdatedAt, granted[calendarCollectionResourcePath(c.ID)]))
+		}
+	} else {
+		books, err := h.accessibleAddressBooks(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range books {
+			bookPath := path.Join("/dav/addressbooks", fmt.Sprint(b.ID))
+			note(bookPath, latestTime(b.UpdatedAt, granted[bookPath]))
+		}
+	}
+
+	if h.store == nil || h.store.Tombstones == nil {
+		return changes, nil
+	}
+	if since.IsZero() {
+		latest, err := h.store.Tombstones.LatestDeletedAt(ctx, user.ID, collectionType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load deleted collections: %w", err)
+		}
+		changes.latest = latestTime(changes.latest, latest)
+		return changes, nil
+	}
+	tombstones, err := h.store.Tombstones.ListSince(ctx, user.ID, collectionType, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted collections: %w", err)
+	}
+	lost := map[string]struct{}{}
+	for _, t := range tombstones {
+		changes.latest = latestTime(changes.latest, t.DeletedAt)
+		href := ensureHomeMemberHref(tombstoneHref(user, t))
+		// A collection shared again, or still reachable through another
+		// grant, is reported as changed rather than removed.
+		if _, ok := changes.changedAt[href]; ok {
+			changes.accessChanged[href] = true
+			continue
+		}
+		if _, ok := lost[href]; ok {
+			continue
+		}
+		lost[href] = struct{}{}
+		changes.lost = append(changes.lost, t)
+	}
+	return changes, nil
+}
+
+// addHomeSyncProperties advertises sync-collection on a calendar or address
+// book home and, when asked for by name, fills its DAV:sync-token with the
+// token a sync-collection REPORT would return. The token is left out of
+// allprop (RFC 6578 §4).
+func (h *Handler) addHomeSyncProperties(ctx context.Context, user *store.User, cleanPath string, responses []response, req *propfindRequest) error {
+	if (cleanPath != "/dav/calendars" && cleanPath != "/dav/addressbooks") || len(responses) == 0 || len(responses[0].Propstat) == 0 {
+		return nil
+	}
+	collectionType, kind := "calendar", "calhome"
+	reports := &supportedReportSet{Reports: []supportedReport{
+		{Report: reportType{CalendarMultiGet: &struct{}{}}},
+		{Report: reportType{CalendarQuery: &struct{}{}}},
+		{Report: reportType{SyncCollection: &struct{}{}}},
+	}}
+	if cleanPath == "/dav/addressbooks" {
+		collectionType, kind = "addressbook", "cardhome"
+		reports = &supportedReportSet{Reports: []supportedReport{{Report: reportType{SyncCollection: &struct{}{}}}}}
+	}
+	p := &responses[0].Propstat[0].Prop
+	p.SupportedReportSet = reports
+	if req == nil || req.Prop == nil || req.Prop.SyncToken == nil {
+		return nil
+	}
+	changes, err := h.homeChanges(ctx, user, collectionType, time.Time{})
+	if err != nil {
+		return err
+	}
+	p.SyncToken = buildSyncToken(kind, user.ID, changes.latest)
+	return nil
+}
+
+func ensureHomeMemberHref(p string) string {
+	if !strings.HasSuffix(p, "/") {
+		return p + "/"
+	}
+	return p
+}
+
+func latestTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// tombstoneHref is the href the user knew a lost collection by.
+func tombstoneHref(user *store.User, t store.CollectionTombstone) string {
+	if t.CollectionType == "addressbook" {
+		return path.Join("/dav/addressbooks", fmt.Sprint(t.CollectionID))
+	}
+	return calendarCollectionHref(user, &store.Calendar{ID: t.CollectionID, UserID: t.OwnerUserID, Slug: t.Slug})
+}
@@ -0,0 +1,199 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeTombstoneRepo struct {
+	tombstones []store.CollectionTombstone
+}
+
+func (f *fakeTombstoneRepo) ListSince(ctx context.Context, userID int64, collectionType string, since time.Time) ([]store.CollectionTombstone, error) {
+	var out []store.CollectionTombstone
+	for _, t := range f.tombstones {
+		if t.UserID == userID && t.CollectionType == collectionType && t.DeletedAt.After(since) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeTombstoneRepo) LatestDeletedAt(ctx context.Context, userID int64, collectionType string) (time.Time, error) {
+	var latest time.Time
+	for _, t := range f.tombstones {
+		if t.UserID == userID && t.CollectionType == collectionType && t.DeletedAt.After(latest) {
+			latest = t.DeletedAt
+		}
+	}
+	return latest, nil
+}
+
+func (f *fakeTombstoneRepo) ListAfter(ctx context.Context, userID int64, after store.ChangeCursor, limit int) ([]store.CollectionTombstone, error) {
+	return nil, nil
+}
+
+type homeSyncResult struct {
+	SyncToken string `xml:"DAV: sync-token"`
+	Responses []struct {
+		Href   string `xml:"DAV: href"`
+		Status string `xml:"DAV: status"`
+	} `xml:"DAV: response"`
+}
+
+func homeSyncReport(t *testing.T, h *Handler, home, token string) (*httptest.ResponseRecorder, homeSyncResult) {
+	t.Helper()
+	body := `<?xml version="1.0"?><d:sync-collection xmlns:d="DAV:"><d:sync-token>` + token + `</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`
+	req := httptest.NewRequest("REPORT", home, strings.NewReader(body))
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Report(rr, req)
+	var ms homeSyncResult
+	if rr.Code == http.StatusMultiStatus {
+		if err := xml.Unmarshal(rr.Body.Bytes(), &ms); err != nil {
+			t.Fatalf("invalid multistatus: %v", err)
+		}
+	}
+	return rr, ms
+}
+
+func TestHomeSyncCollectionReportsChangedAndLostCollections(t *testing.T) {
+	now := store.Now()
+	workSlug, oldSlug := "work", "old"
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", Slug: &workSlug, UpdatedAt: now.Add(-2 * time.Hour)}, Editor: true},
+			{Calendar: store.Calendar{ID: 5, UserID: 9, Name: "Team", UpdatedAt: now.Add(-2 * time.Hour)}, Shared: true},
+			{Calendar: store.Calendar{ID: 6, UserID: 9, Name: "Rota", UpdatedAt: now.Add(-2 * time.Hour)}, Shared: true},
+			{Calendar: store.Calendar{ID: 12, UserID: 1, Name: "Renamed", UpdatedAt: now.Add(-10 * time.Minute)}, Editor: true},
+		},
+	}
+	aclRepo := &fakeACLRepo{entries: []store.ACLEntry{
+		// Shared with the user after the token was issued.
+		{ResourcePath: "/dav/calendars/6", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read", CreatedAt: now.Add(-30 * time.Minute)},
+	}}
+	tombstones := &fakeTombstoneRepo{tombstones: []store.CollectionTombstone{
+		{CollectionType: "calendar", CollectionID: 3, UserID: 1, OwnerUserID: 1, Slug: &oldSlug, DeletedAt: now.Add(-time.Hour)},
+		{CollectionType: "calendar", CollectionID: 7, UserID: 1, OwnerUserID: 9, DeletedAt: now.Add(-time.Hour)},
+		// Unshared, then shared again.
+		{CollectionType: "calendar", CollectionID: 5, UserID: 1, OwnerUserID: 9, DeletedAt: now.Add(-time.Hour)},
+		{CollectionType: "addressbook", CollectionID: 8, UserID: 1, OwnerUserID: 1, DeletedAt: now.Add(-time.Hour)},
+		{CollectionType: "calendar", CollectionID: 11, UserID: 2, OwnerUserID: 2, DeletedAt: now.Add(-time.Hour)},
+	}}
+	bookRepo := &storetest.AddressBooks{Books: map[int64]*store.AddressBook{
+		4: {ID: 4, UserID: 1, Name: "Contacts", UpdatedAt: now.Add(-2 * time.Hour)},
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, AddressBooks: bookRepo, Events: &storetest.Events{}, ACLEntries: aclRepo, Tombstones: tombstones}}
+
+	statuses := func(ms homeSyncResult) map[string]string {
+		got := map[string]string{}
+		for _, resp := range ms.Responses {
+			got[resp.Href] = resp.Status
+		}
+		return got
+	}
+
+	rr, ms := homeSyncReport(t, h, "/dav/calendars/", "")
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("initial sync status = %d: %s", rr.Code, rr.Body.String())
+	}
+	initial := statuses(ms)
+	for _, href := range []string{"/dav/calendars/work/", "/dav/calendars/5/", "/dav/calendars/6/", "/dav/calendars/12/"} {
+		if _, ok := initial[href]; !ok {
+			t.Fatalf("initial sync missing %s: %v", href, initial)
+		}
+	}
+	for href, status := range initial {
+		if status != "" {
+			t.Fatalf("initial sync reported %s as %q", href, status)
+		}
+	}
+	info, err := parseSyncToken(ms.SyncToken)
+	if err != nil || info.Kind != "calhome" || info.ID != 1 || !info.Timestamp.Equal(now.Add(-10*time.Minute)) {
+		t.Fatalf("unexpected home sync token %q", ms.SyncToken)
+	}
+
+	rr, ms = homeSyncReport(t, h, "/dav/calendars/", buildSyncToken("calhome", 1, now.Add(-90*time.Minute)))
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("incremental sync status = %d: %s", rr.Code, rr.Body.String())
+	}
+	got := statuses(ms)
+	want := map[string]string{
+		"/dav/calendars/old/": httpStatusNotFound,
+		"/dav/calendars/7/":   httpStatusNotFound,
+		"/dav/calendars/5/":   "",
+		"/dav/calendars/6/":   "",
+		"/dav/calendars/12/":  "",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("incremental sync = %v, want %v", got, want)
+	}
+	for href, status := range want {
+		if gotStatus, ok := got[href]; !ok || gotStatus != status {
+			t.Fatalf("incremental sync = %v, want %v", got, want)
+		}
+	}
+
+	// Nothing changed since the last token.
+	_, ms = homeSyncReport(t, h, "/dav/calendars/", buildSyncToken("calhome", 1, now))
+	if len(ms.Responses) != 0 || ms.SyncToken != buildSyncToken("calhome", 1, now) {
+		t.Fatalf("expected an empty sync, got %v %q", statuses(ms), ms.SyncToken)
+	}
+
+	_, ms = homeSyncReport(t, h, "/dav/addressbooks/", buildSyncToken("cardhome", 1, now.Add(-90*time.Minute)))
+	if got := statuses(ms); len(got) != 1 || got["/dav/addressbooks/8/"] != httpStatusNotFound {
+		t.Fatalf("unexpected address book home sync: %v", got)
+	}
+
+	for _, token := range []string{buildSyncToken("calhome", 2, now), buildSyncToken("cal", 1, now), "garbage"} {
+		if rr, _ := homeSyncReport(t, h, "/dav/calendars/", token); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "valid-sync-token") {
+			t.Fatalf("token %q: expected 403 valid-sync-token, got %d %s", token, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestPropfindHomeReportsSyncToken(t *testing.T) {
+	now := store.Now()
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: now.Add(-time.Hour)}, Editor: true},
+		},
+	}
+	tombstones := &fakeTombstoneRepo{tombstones: []store.CollectionTombstone{
+		{CollectionType: "calendar", CollectionID: 3, UserID: 1, OwnerUserID: 1, DeletedAt: now},
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, Tombstones: tombstones}}
+
+	body := `<?xml version="1.0"?><d:propfind xmlns:d="DAV:"><d:prop><d:sync-token/><d:supported-report-set/></d:prop></d:propfind>`
+	req := httptest.NewRequest("PROPFIND", "/dav/calendars/", strings.NewReader(body))
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Propfind(rr, req)
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", rr.Code)
+	}
+	got := rr.Body.String()
+	if !strings.Contains(got, buildSyncToken("calhome", 1, now)) || !strings.Contains(got, "sync-collection") {
+		t.Fatalf("expected the home sync token and sync-collection report, got %s", got)
+	}
+
+	// The token is not part of allprop.
+	req = httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Propfind(rr, req)
+	if strings.Contains(rr.Body.String(), "calhome") {
+		t.Fatalf("expected no home sync token for allprop, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,179 @@
+package dav
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/interop"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// interopLabelPrefix marks an app password whose DAV traffic is recorded
+// under the profile named after it.
+const interopLabelPrefix = "interop:"
+
+var interopProfileName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// interopRecorder writes the DAV traffic of interop app passwords to one
+// recording per profile. A recording starts over with the first request of
+// its profile after the server starts.
+type interopRecorder struct {
+	dir      string
+	baseURL  string
+	mu       sync.Mutex
+	profiles map[int64]string // app password ID -> profile, "" for none
+	sessions map[string]*interop.Session
+}
+
+func newInteropRecorder(cfg *config.Config) *interopRecorder {
+	if cfg == nil || cfg.DAV.InteropDir == "" {
+		return nil
+	}
+	return &interopRecorder{
+		dir:      cfg.DAV.InteropDir,
+		baseURL:  cfg.BaseURL,
+		profiles: map[int64]string{},
+		sessions: map[string]*interop.Session{},
+	}
+}
+
+// interopProfile returns the profile named by an app password label, or ""
+// when the label does not name a valid one.
+func interopProfile(label string) string {
+	profile, ok := strings.CutPrefix(strings.TrimSpace(label), interopLabelPrefix)
+	if !ok || !interopProfileName.MatchString(profile) {
+		return ""
+	}
+	return profile
+}
+
+// session returns the recording for the request, or nil when it is not
+// made with an interop app password.
+func (rec *interopRecorder) session(r *http.Request, passwords store.AppPasswordRepository) (*interop.Session, error) {
+	if rec == nil || passwords == nil {
+		return nil, nil
+	}
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return nil, nil
+	}
+	passwordID, ok := auth.AppPasswordIDFromContext(r.Context())
+	if !ok {
+		return nil, nil
+	}
+	profile, err := rec.profile(r.Context(), passwords, passwordID, user.ID)
+	if err != nil || profile == "" {
+		return nil, err
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if s, ok := rec.sessions[profile]; ok {
+		return s, nil
+	}
+	if err := os.MkdirAll(rec.dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(rec.dir, profile+".jsonl"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	s := interop.NewSession(f, user.ID, user.PrimaryEmail, rec.baseURL, scheme+"://"+r.Host)
+	rec.sessions[profile] = s
+	return s, nil
+}
+
+func (rec *interopRecorder) profile(ctx context.Context, passwords store.AppPasswordRepository, passwordID, userID int64) (string, error) {
+	rec.mu.Lock()
+	profile, ok := rec.profiles[passwordID]
+	rec.mu.Unlock()
+	if ok {
+		return profile, nil
+	}
+	pw, err := passwords.GetByID(ctx, passwordID)
+	if err != nil {
+		return "", err
+	}
+	if pw != nil && pw.UserID == userID {
+		profile = interopProfile(pw.Label)
+	}
+	rec.mu.Lock()
+	rec.profiles[passwordID] = profile
+	rec.mu.Unlock()
+	return profile, nil
+}
+
+// RecordInterop is middleware that records the DAV requests made with an
+// app password labelled "interop:<profile>", and the responses to them, when
+// APP_DAV_INTEROP_DIR is set. It must run after authentication.
+func (h *Handler) RecordInterop(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var passwords store.AppPasswordRepository
+		if h.store != nil {
+			passwords = h.store.AppPasswords
+		}
+		session, err := h.interop.session(r, passwords)
+		if err != nil {
+			h.logger().Error("RecordInterop", "failed to start interop recording: %v", err)
+		}
+		if session == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ex := interop.Exchange{
+			Method: r.Method,
+			Path:   r.URL.RequestURI(),
+			Header: interop.PickHeaders(r.Header, interop.RequestHeaders),
+		}
+		if r.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, interop.MaxBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			if len(body) > interop.MaxBody {
+				body, ex.Truncated = body[:interop.MaxBody], true
+			}
+			ex.Body = string(body)
+		}
+		iw := &interopWriter{bodyLogWriter: bodyLogWriter{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(iw, r)
+
+		ex.Status = iw.status
+		ex.ResponseHeader = interop.PickHeaders(w.Header(), interop.ResponseHeaders)
+		ex.ResponseBody = iw.buf.String()
+		ex.Truncated = ex.Truncated || iw.truncated
+		if err := session.Record(ex); err != nil {
+			h.logger().Error("RecordInterop", "failed to record %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+// interopWriter keeps up to interop.MaxBody bytes of a response.
+type interopWriter struct {
+	bodyLogWriter
+	truncated bool
+}
+
+func (w *interopWriter) Write(p []byte) (int, error) {
+	room := interop.MaxBody - w.buf.Len()
+	if len(p) > room {
+		w.truncated = true
+	}
+	w.buf.Write(p[:max(0, min(len(p), room))])
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,106 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/interop"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type interopPasswords struct {
+	store.AppPasswordRepository
+	passwords map[int64]store.AppPassword
+	lookups   int
+}
+
+func (p *interopPasswords) GetByID(_ context.Context, id int64) (*store.AppPassword, error) {
+	p.lookups++
+	pw, ok := p.passwords[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &pw, nil
+}
+
+func TestRecordInteropWritesProfileRecording(t *testing.T) {
+	for label, want := range map[string]string{
+		"interop:ios17-initial-setup": "ios17-initial-setup",
+		"interop:../escape":           "",
+		"interop:":                    "",
+		"Work laptop":                 "",
+	} {
+		if got := interopProfile(label); got != want {
+			t.Errorf("interopProfile(%q) = %q, want %q", label, got, want)
+		}
+	}
+
+	dir := t.TempDir()
+	cfg := &config.Config{BaseURL: "https://cal.example.com"}
+	cfg.DAV.InteropDir = dir
+	passwords := &interopPasswords{passwords: map[int64]store.AppPassword{
+		3: {ID: 3, UserID: 1, Label: "interop:ios17-initial-setup"},
+		4: {ID: 4, UserID: 1, Label: "Phone"},
+	}}
+	h := &Handler{
+		store: &store.Store{
+			AppPasswords: passwords,
+			Calendars: &storetest.Calendars{Accessible: []store.CalendarAccess{
+				{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+			}},
+			Events: &storetest.Events{Events: map[string]*store.Event{
+				"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICALDATA", ETag: "0f3c9a1b77"},
+			}},
+		},
+		interop: newInteropRecorder(cfg),
+	}
+	u := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
+	get := func(passwordID int64) {
+		req := httptest.NewRequest(http.MethodGet, "/dav/calendars/2/event.ics", nil)
+		req.Header.Set("User-Agent", "iOS/17.4 (21E219) dataaccessd/1.0")
+		req.Header.Set("Authorization", "Basic secret")
+		ctx := auth.WithAppPasswordID(auth.WithUser(req.Context(), u), passwordID)
+		rr := httptest.NewRecorder()
+		h.RecordInterop(http.HandlerFunc(h.Get)).ServeHTTP(rr, req.WithContext(ctx))
+		if rr.Code != http.StatusOK || rr.Body.String() != "ICALDATA" {
+			t.Fatalf("GET through recorder = %d %q", rr.Code, rr.Body.String())
+		}
+	}
+
+	get(3)
+	get(4)
+	get(3)
+	if passwords.lookups != 2 {
+		t.Fatalf("looked up app passwords %d times, want once each", passwords.lookups)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("recordings = %v, want only the interop profile", entries)
+	}
+	f, err := os.Open(filepath.Join(dir, "ios17-initial-setup.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	exchanges, err := interop.ReadSession(f)
+	if err != nil || len(exchanges) != 2 {
+		t.Fatalf("ReadSession() = %d exchanges, %v", len(exchanges), err)
+	}
+	ex := exchanges[0]
+	if ex.Path != "/dav/calendars/{calendar1}/event.ics" || ex.Status != http.StatusOK || ex.ResponseBody != "ICALDATA" {
+		t.Fatalf("exchange = %+v", ex)
+	}
+	if ex.Header["User-Agent"] != "iOS/17.4 (21E219) dataaccessd/1.0" || ex.Header["Authorization"] != "" {
+		t.Fatalf("request headers = %v", ex.Header)
+	}
+	if ex.ResponseHeader["ETag"] != `"{etag1}"` {
+		t.Fatalf("response headers = %v", ex.ResponseHeader)
+	}
+}
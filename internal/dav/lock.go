@@ -200,7 +200,7 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Lock", "LOCK %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
@@ -208,19 +208,19 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 	canonicalPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 	if err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) || errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous path")
 			return
 		}
-		http.Error(w, "failed to resolve path", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve path")
 		return
 	}
 	depth, err := parseLockDepth(r.Header.Get("Depth"))
 	if err != nil {
-		http.Error(w, "invalid Depth header", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid Depth header")
 		return
 	}
 	timeout := parseLockTimeout(r.Header.Get("Timeout"))
@@ -231,20 +231,20 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 	if ifToken := firstIfLockToken(ifHeader, cleanPath, canonicalPath); ifToken != "" {
 		existing, err := h.store.Locks.GetByToken(r.Context(), ifToken)
 		if err != nil || existing == nil {
-			http.Error(w, "lock token not found", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "lock token not found", condLockTokenMatchesRequestURI)
 			return
 		}
 		if existing.UserID != user.ID {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
 			return
 		}
 		if !sameLockRoot(existing.ResourcePath, canonicalPath) {
-			http.Error(w, "lock token does not match request URI", http.StatusPreconditionFailed)
+			writeDAVError(w, http.StatusPreconditionFailed, "lock token does not match request URI", condLockTokenMatchesRequestURI)
 			return
 		}
 		refreshed, err := h.store.Locks.Refresh(r.Context(), ifToken, timeout, expiresAt)
 		if err != nil {
-			http.Error(w, "failed to refresh lock", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to refresh lock")
 			return
 		}
 		writeLockResponse(w, refreshed, http.StatusOK)
@@ -253,11 +253,11 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 
 	allowed, err := h.canLockPath(r.Context(), user, cleanPath)
 	if err != nil {
-		http.Error(w, "failed to authorize lock", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to authorize lock")
 		return
 	}
 	if !allowed {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
 		return
 	}
 
@@ -265,24 +265,24 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 	body, err := readDAVBody(w, r, maxDAVBodyBytes)
 	if err != nil {
 		if errors.Is(err, errRequestTooLarge) {
-			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 		} else {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
 		}
 		return
 	}
 	if len(body) == 0 {
-		http.Error(w, "lock request body required", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "lock request body required")
 		return
 	}
 
 	var info lockInfo
 	if err := safeUnmarshalXML(body, &info); err != nil {
-		http.Error(w, "invalid lock request", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid lock request")
 		return
 	}
 	if info.LockType.Write == nil || (info.LockScope.Exclusive == nil && info.LockScope.Shared == nil) {
-		http.Error(w, "invalid lock request", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid lock request")
 		return
 	}
 
@@ -303,7 +303,7 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 
 	token, err := generateLockToken()
 	if err != nil {
-		http.Error(w, "failed to generate lock token", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to generate lock token")
 		return
 	}
 	newLock := store.Lock{
@@ -320,7 +320,7 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 
 	status := http.StatusOK
 	if exists, err := h.lockTargetExists(r.Context(), user, cleanPath); err != nil {
-		http.Error(w, "failed to resolve lock target", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve lock target")
 		return
 	} else if !exists {
 		status = http.StatusCreated
@@ -329,10 +329,10 @@ func (h *Handler) Lock(w http.ResponseWriter, r *http.Request) {
 	created, err := h.store.Locks.Create(r.Context(), newLock)
 	if err != nil {
 		if errors.Is(err, store.ErrLockConflict) {
-			http.Error(w, "resource is already locked", http.StatusLocked)
+			writeDAVError(w, http.StatusLocked, "resource is already locked", condNoConflictingLock)
 			return
 		}
-		http.Error(w, "failed to create lock", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to create lock")
 		return
 	}
 
@@ -594,13 +594,13 @@ func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
 	h.logger().Trace("Unlock", "UNLOCK %s", r.URL.Path)
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
 
 	tokenHeader := r.Header.Get("Lock-Token")
 	if tokenHeader == "" {
-		http.Error(w, "missing Lock-Token header", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "missing Lock-Token header")
 		return
 	}
 
@@ -609,34 +609,34 @@ func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
 	canonicalPath, err := h.canonicalDAVPath(r.Context(), user, cleanPath)
 	if err != nil {
 		if err == store.ErrNotFound {
-			http.Error(w, "not found", http.StatusNotFound)
+			writeDAVError(w, http.StatusNotFound, "not found")
 			return
 		}
 		if errors.Is(err, errAmbiguousAddressBook) || errors.Is(err, errAmbiguousCalendar) {
-			http.Error(w, "ambiguous path", http.StatusConflict)
+			writeDAVError(w, http.StatusConflict, "ambiguous path")
 			return
 		}
-		http.Error(w, "failed to resolve path", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to resolve path")
 		return
 	}
 
 	lock, err := h.store.Locks.GetByToken(r.Context(), token)
 	if err != nil || lock == nil {
-		http.Error(w, "lock not found", http.StatusConflict)
+		writeDAVError(w, http.StatusConflict, "lock not found", condLockTokenMatchesRequestURI)
 		return
 	}
 	if !sameLockRoot(lock.ResourcePath, canonicalPath) {
-		http.Error(w, "lock token does not match request URI", http.StatusPreconditionFailed)
+		writeDAVError(w, http.StatusPreconditionFailed, "lock token does not match request URI", condLockTokenMatchesRequestURI)
 		return
 	}
 
 	if lock.UserID != user.ID {
-		http.Error(w, "forbidden", http.StatusForbidden)
+		writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
 		return
 	}
 
 	if err := h.store.Locks.Delete(r.Context(), token); err != nil {
-		http.Error(w, "failed to unlock", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to unlock")
 		return
 	}
 
@@ -718,26 +718,32 @@ func (h *Handler) checkLocks(r *http.Request, resourcePaths ...string) (bool, er
 }
 
 func (h *Handler) requireLock(w http.ResponseWriter, r *http.Request, resourcePath, lockedMessage string) bool {
+	if !h.requireNoMaintenance(w, r, resourcePath) {
+		return false
+	}
 	allowed, err := h.checkLock(r, resourcePath)
 	if err != nil {
-		http.Error(w, "failed to verify lock state", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to verify lock state")
 		return false
 	}
 	if !allowed {
-		http.Error(w, lockedMessage, http.StatusLocked)
+		writeDAVError(w, http.StatusLocked, lockedMessage, condLockTokenSubmitted)
 		return false
 	}
 	return true
 }
 
 func (h *Handler) requireLocks(w http.ResponseWriter, r *http.Request, lockedMessage string, resourcePaths ...string) bool {
+	if !h.requireNoMaintenance(w, r, resourcePaths...) {
+		return false
+	}
 	allowed, err := h.checkLocks(r, resourcePaths...)
 	if err != nil {
-		http.Error(w, "failed to verify lock state", http.StatusInternalServerError)
+		writeDAVError(w, http.StatusInternalServerError, "failed to verify lock state")
 		return false
 	}
 	if !allowed {
-		http.Error(w, lockedMessage, http.StatusLocked)
+		writeDAVError(w, http.StatusLocked, lockedMessage, condLockTokenSubmitted)
 		return false
 	}
 	return true
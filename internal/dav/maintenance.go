@@ -0,0 +1,69 @@
+package dav
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/maintenance"
+)
+
+// requireNoMaintenance answers 503 with Retry-After, and returns false, when
+// any of resourcePaths lies in a calendar or address book an admin has put
+// into maintenance. It runs with the lock check that precedes every write,
+// so a COPY out of a collection in maintenance is refused as well, since
+// what it would copy may be half merged or restored.
+func (h *Handler) requireNoMaintenance(w http.ResponseWriter, r *http.Request, resourcePaths ...string) bool {
+	if h == nil || h.store == nil || h.store.Maintenance == nil {
+		return true
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	for _, resourcePath := range resourcePaths {
+		canonical := normalizeDAVHref(resourcePath)
+		if user != nil {
+			if resolved, err := h.canonicalDAVPath(r.Context(), user, canonical); err == nil && resolved != "" {
+				canonical = resolved
+			}
+		}
+		collectionType, collectionID, ok := maintenanceCollection(canonical)
+		if !ok {
+			continue
+		}
+		err := maintenance.Check(r.Context(), h.store, collectionType, collectionID)
+		var active *maintenance.Error
+		switch {
+		case errors.As(err, &active):
+			w.Header().Set("Retry-After", strconv.Itoa(active.RetryAfter(time.Now())))
+			writeDAVError(w, http.StatusServiceUnavailable, active.Error())
+			return false
+		case err != nil:
+			writeDAVError(w, http.StatusInternalServerError, "failed to verify maintenance state")
+			return false
+		}
+	}
+	return true
+}
+
+// maintenanceCollection returns the collection a canonical DAV path lies
+// in, if it lies in one.
+func maintenanceCollection(canonicalPath string) (string, int64, bool) {
+	for prefix, collectionType := range map[string]string{
+		"/dav/calendars/":    maintenance.Calendar,
+		"/dav/addressbooks/": maintenance.AddressBook,
+	} {
+		rest, ok := strings.CutPrefix(canonicalPath, prefix)
+		if !ok {
+			continue
+		}
+		segment, _, _ := strings.Cut(rest, "/")
+		id, err := strconv.ParseInt(segment, 10, 64)
+		if err != nil || id <= 0 {
+			return "", 0, false
+		}
+		return collectionType, id, true
+	}
+	return "", 0, false
+}
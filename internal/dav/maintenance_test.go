@@ -0,0 +1,79 @@
+package dav
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestMaintenanceRefusesWritesButServesReads(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{
+		"2:event": {CalendarID: 2, UID: "event", RawICAL: "ICALDATA", ETag: "etag1"},
+	}}
+	running := &storetest.Maintenance{Running: []store.CollectionMaintenance{
+		{CollectionType: "calendar", CollectionID: 2, Reason: "restoring from backup", ExpiresAt: time.Now().Add(90 * time.Second)},
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, Maintenance: running}}
+	u := &store.User{ID: 1}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:new\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/new.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	rr := httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "restoring from backup") {
+		t.Fatalf("PUT during maintenance = %d %s", rr.Code, rr.Body.String())
+	}
+	if retry := rr.Header().Get("Retry-After"); retry != "90" && retry != "89" {
+		t.Fatalf("Retry-After = %q, want the time left", retry)
+	}
+	if _, ok := eventRepo.Events[eventRepo.Key(2, "new")]; ok {
+		t.Fatal("PUT during maintenance stored the event")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/dav/calendars/2/event.ics", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	rr = httptest.NewRecorder()
+	h.Delete(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("DELETE during maintenance = %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/dav/calendars/2/event.ics", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	rr = httptest.NewRecorder()
+	h.Get(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "ICALDATA" {
+		t.Fatalf("GET during maintenance = %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMaintenanceCollection(t *testing.T) {
+	for path, want := range map[string]string{
+		"/dav/calendars/2/event.ics":      "calendar 2",
+		"/dav/calendars/2":                "calendar 2",
+		"/dav/addressbooks/5/contact.vcf": "addressbook 5",
+		"/dav/calendars/pending-1-work":   "",
+		"/dav/principals/1/":              "",
+	} {
+		got := ""
+		if collectionType, id, ok := maintenanceCollection(path); ok {
+			got = fmt.Sprintf("%s %d", collectionType, id)
+		}
+		if got != want {
+			t.Errorf("maintenanceCollection(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package dav
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jw6ventures/calcard/internal/scan"
+)
+
+// condMalwareFree is the calcard precondition an upload the malware
+// scanner rejects fails.
+var condMalwareFree = davCondition{"X", "malware-free"}
+
+// allowScanned reports whether an upload passed the malware scanner, given
+// the error scanning it returned, and otherwise writes the response: 403
+// for infected content and 503 when the scanner could not check it, since
+// nothing unscanned is stored.
+func (h *Handler) allowScanned(w http.ResponseWriter, method, target string, err error) bool {
+	var infected *scan.InfectedError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &infected):
+		h.logger().Warn(method, "rejected %s: %s found", target, infected.Signature)
+		writeDAVError(w, http.StatusForbidden, "attachment rejected: "+infected.Signature+" found", condMalwareFree)
+	case errors.Is(err, scan.ErrMalformedAttachment):
+		writeCalDAVError(w, http.StatusBadRequest, "valid-calendar-data")
+	default:
+		h.logger().Error(method, "failed to scan %s: %v", target, err)
+		w.Header().Set("Retry-After", "60")
+		writeDAVError(w, http.StatusServiceUnavailable, "attachment scanner unavailable")
+	}
+	return false
+}
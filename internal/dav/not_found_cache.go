@@ -0,0 +1,131 @@
+package dav
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/metrics"
+)
+
+// maxNotFoundEntries bounds the not-found cache; once it is full and nothing
+// has expired, new misses are not remembered.
+const maxNotFoundEntries = 10000
+
+// notFoundCache remembers GETs of calendar objects and contacts that found
+// nothing, so clients that keep asking for a deleted resource, often for
+// weeks, are answered 404 without loading the collection and the resource
+// again. Misses are remembered per user, so a cached answer never tells a
+// user more than their own last request did, and are forgotten when a DAV
+// PUT, COPY or MOVE writes the resource. Writes made elsewhere, through the
+// REST API or on another replica, show once the entry expires. A nil
+// *notFoundCache remembers nothing.
+type notFoundCache struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[notFoundKey]map[int64]time.Time // by user ID
+}
+
+// notFoundKey names a resource in a collection.
+type notFoundKey struct {
+	resourceType string // heldEvent or heldContact
+	collectionID int64
+	resourceName string
+}
+
+func newNotFoundCache(cfg *config.Config) *notFoundCache {
+	if cfg == nil || cfg.DAV.NotFoundCacheTTL <= 0 {
+		return nil
+	}
+	return &notFoundCache{now: time.Now, ttl: cfg.DAV.NotFoundCacheTTL, entries: make(map[notFoundKey]map[int64]time.Time)}
+}
+
+// reconfigure applies a new lifetime to misses remembered from now on.
+func (c *notFoundCache) reconfigure(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = cfg.DAV.NotFoundCacheTTL
+}
+
+// missed reports whether the user's last GET of the resource found nothing
+// and that answer has not expired.
+func (c *notFoundCache) missed(userID int64, key notFoundKey) bool {
+	if c == nil {
+		return false
+	}
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires, ok := c.entries[key][userID]
+	if ok && !now.Before(expires) {
+		c.drop(key, userID)
+		ok = false
+	}
+	if ok {
+		metrics.IncDAVNotFoundCache("hit")
+	} else {
+		metrics.IncDAVNotFoundCache("miss")
+	}
+	return ok
+}
+
+// remember records that the user's GET of the resource found nothing.
+func (c *notFoundCache) remember(userID int64, key notFoundKey) {
+	if c == nil {
+		return
+	}
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+	if c.size >= maxNotFoundEntries {
+		for k, users := range c.entries {
+			for id, expires := range users {
+				if !now.Before(expires) {
+					c.drop(k, id)
+				}
+			}
+		}
+		if c.size >= maxNotFoundEntries {
+			return
+		}
+	}
+	users := c.entries[key]
+	if users == nil {
+		users = make(map[int64]time.Time)
+		c.entries[key] = users
+	}
+	if _, ok := users[userID]; !ok {
+		c.size++
+	}
+	users[userID] = now.Add(c.ttl)
+}
+
+// forget drops every user's remembered miss of a resource that now exists.
+func (c *notFoundCache) forget(key notFoundKey) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size -= len(c.entries[key])
+	delete(c.entries, key)
+}
+
+// drop removes one entry; c.mu must be held.
+func (c *notFoundCache) drop(key notFoundKey, userID int64) {
+	users := c.entries[key]
+	if _, ok := users[userID]; !ok {
+		return
+	}
+	delete(users, userID)
+	c.size--
+	if len(users) == 0 {
+		delete(c.entries, key)
+	}
+}
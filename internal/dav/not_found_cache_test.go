@@ -0,0 +1,96 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestNotFoundCacheRemembersMissesPerUser(t *testing.T) {
+	if newNotFoundCache(&config.Config{}) != nil {
+		t.Fatal("expected nil cache with a zero TTL")
+	}
+	var disabled *notFoundCache
+	disabled.remember(1, notFoundKey{})
+	if disabled.missed(1, notFoundKey{}) {
+		t.Fatal("nil cache reported a miss")
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &config.Config{}
+	cfg.DAV.NotFoundCacheTTL = time.Minute
+	c := newNotFoundCache(cfg)
+	c.now = func() time.Time { return now }
+	key := notFoundKey{resourceType: heldEvent, collectionID: 2, resourceName: "gone"}
+
+	c.remember(1, key)
+	if !c.missed(1, key) {
+		t.Fatal("expected the miss to be remembered")
+	}
+	if c.missed(2, key) {
+		t.Fatal("expected another user's lookup to go to the store")
+	}
+	if c.missed(1, notFoundKey{resourceType: heldContact, collectionID: 2, resourceName: "gone"}) {
+		t.Fatal("expected a contact of the same name to go to the store")
+	}
+
+	now = now.Add(time.Minute)
+	if c.missed(1, key) || c.size != 0 {
+		t.Fatalf("expected the miss to expire, size = %d", c.size)
+	}
+
+	c.remember(1, key)
+	c.remember(2, key)
+	c.forget(key)
+	if c.missed(1, key) || c.missed(2, key) || c.size != 0 {
+		t.Fatalf("expected forget to drop every user's miss, size = %d", c.size)
+	}
+}
+
+func TestGetAnswersRepeatedMissFromCache(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{}}
+	cfg := &config.Config{}
+	cfg.DAV.NotFoundCacheTTL = time.Minute
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}, notFound: newNotFoundCache(cfg)}
+	u := &store.User{ID: 1}
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/dav/calendars/2/new.ics", nil)
+		req = req.WithContext(auth.WithUser(req.Context(), u))
+		rr := httptest.NewRecorder()
+		h.Get(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := get(); rr.Code != http.StatusNotFound {
+			t.Fatalf("GET %d = %d, want 404", i+1, rr.Code)
+		}
+	}
+	if eventRepo.ResourceLookupCount != 1 {
+		t.Fatalf("looked up the event %d times, want 1", eventRepo.ResourceLookupCount)
+	}
+
+	ical := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:new\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	req := newCalendarPutRequest("/dav/calendars/2/new.ics", strings.NewReader(ical))
+	req = req.WithContext(auth.WithUser(req.Context(), u))
+	rr := httptest.NewRecorder()
+	h.Put(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("PUT = %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := get(); rr.Code != http.StatusOK {
+		t.Fatalf("GET after PUT = %d, want 200", rr.Code)
+	}
+}
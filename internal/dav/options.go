@@ -5,19 +5,25 @@ import (
 	"path"
 	"sort"
 	"strings"
+
+	"github.com/jw6ventures/calcard/internal/config"
 )
 
 var davAllowMethods = []string{"OPTIONS", "HEAD", "GET", "PROPFIND", "PROPPATCH", "MKCOL", "MKCALENDAR", "PUT", "DELETE", "REPORT", "LOCK", "UNLOCK", "ACL"}
 var davAllowMethodsWithCopyMove = []string{"OPTIONS", "HEAD", "GET", "PROPFIND", "PROPPATCH", "MKCOL", "MKCALENDAR", "PUT", "DELETE", "REPORT", "COPY", "MOVE", "LOCK", "UNLOCK", "ACL"}
 
 func (h *Handler) davHeaderForPath(cleanPath string) string {
+	var cfg *config.Config
+	if h != nil {
+		cfg = h.cfg
+	}
 	if cleanPath == "/dav" || cleanPath == "/dav/" {
-		return "1, 2, 3, access-control, calendar-access, addressbook"
+		return davClasses(cfg, scopeServer)
 	}
 	if h != nil && h.davRegistry().isExtensionPath(cleanPath) {
-		return "1, 2, 3, access-control"
+		return davClasses(cfg, scopeEverywhere)
 	}
-	return "1, 2, 3, access-control, calendar-access, addressbook, extended-mkcol"
+	return davClasses(cfg, scopeCollections)
 }
 
 func (h *Handler) Options(w http.ResponseWriter, r *http.Request) {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/quirks"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -22,7 +23,13 @@ func (h *Handler) Propfind(w http.ResponseWriter, r *http.Request) {
 
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
+		return
+	}
+
+	window, err := parsePropfindWindow(r)
+	if err != nil {
+		writeDAVError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -31,9 +38,9 @@ func (h *Handler) Propfind(w http.ResponseWriter, r *http.Request) {
 		body, err := readDAVBody(w, r, maxDAVBodyBytes)
 		if err != nil {
 			if errors.Is(err, errRequestTooLarge) {
-				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 			} else {
-				http.Error(w, "failed to read body", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "failed to read body")
 			}
 			return
 		}
@@ -43,8 +50,11 @@ func (h *Handler) Propfind(w http.ResponseWriter, r *http.Request) {
 	} else {
 		propfindReq.AllProp = &struct{}{}
 	}
+	if propfindReq.AllProp != nil && clientQuirks(r.Context()).Has(quirks.MinimalProps) {
+		propfindReq = propfindRequest{Prop: minimalAllProp()}
+	}
 
-	responses, err := h.buildPropfindResponses(r.Context(), r, r.URL.Path, depth, user, &propfindReq)
+	responses, err := h.buildPropfindResponses(r.Context(), r, r.URL.Path, depth, user, &propfindReq, window)
 	if err != nil {
 		status := http.StatusBadRequest
 		if errors.Is(err, errAmbiguousCalendar) || errors.Is(err, errAmbiguousAddressBook) {
@@ -57,11 +67,14 @@ func (h *Handler) Propfind(w http.ResponseWriter, r *http.Request) {
 			status = http.StatusNotFound
 		}
 		h.logger().Error("Propfind", "failed to build responses for %s (status %d): %v", r.URL.Path, status, err)
-		http.Error(w, err.Error(), status)
+		writeDAVError(w, status, err.Error())
 		return
 	}
 	h.logger().Debug("Propfind", "%s returned %d responses", r.URL.Path, len(responses))
 
+	if window != nil {
+		w.Header().Set(PropfindWindowHeader, window.String())
+	}
 	payload := multistatus{
 		XMLName:   xml.Name{Space: "DAV:", Local: "multistatus"},
 		XmlnsD:    "DAV:",
@@ -45,6 +45,12 @@ func (h *Handler) decorateDAVProp(ctx context.Context, user *store.User, resourc
 		return nil
 	}
 
+	if !h.calDAVEnabled() {
+		p.CalendarHomeSet = nil
+	}
+	if !h.cardDAVEnabled() {
+		p.AddressbookHomeSet = nil
+	}
 	p.SupportedLock = defaultSupportedLock()
 	p.SupportedPrivilegeSet = defaultSupportedPrivilegeSet()
 	p.PrincipalCollectionSet = &hrefListProp{Href: []string{"/dav/principals/"}}
@@ -63,6 +69,10 @@ func (h *Handler) decorateDAVProp(ctx context.Context, user *store.User, resourc
 		p.ACL = buildACLPropFromEntries(entries)
 	}
 
+	if p.ResourceType.Calendar != nil || p.ResourceType.AddressBook != nil {
+		p.setCustomXMLProperty(XMLProperty{Name: propAddMember, Value: hrefProp{Href: addMemberHref(resourcePath)}})
+	}
+
 	if user != nil && p.CurrentUserPrincipal == nil {
 		principalHref := h.principalURL(user)
 		p.CurrentUserPrincipal = &expandableHrefProp{Href: principalHref}
@@ -227,7 +237,7 @@ func (h *Handler) accessibleAddressBooks(ctx context.Context, user *store.User)
 		seen[book.ID] = struct{}{}
 	}
 
-	principals := []string{"DAV:all", "DAV:authenticated", h.principalURL(user)}
+	principals := aclPrincipalHrefs(user)
 	for _, principal := range principals {
 		entries, err := h.store.ACLEntries.ListByPrincipal(ctx, principal)
 		if err != nil {
@@ -367,13 +377,23 @@ func filterGenericCollectionPropfindResponse(resp response, req *propfindRequest
 		notFoundProp.AddressBookMaxResourceSize = "max-resource-size"
 		notFoundSet = true
 	}
+	if req.Prop.AddressBookMaxImageSize != nil {
+		notFoundProp.AddressBookMaxImageSize = "max-image-size"
+		notFoundSet = true
+	}
 	if req.Prop.SupportedCollationSet != nil {
 		notFoundProp.SupportedCollationSet = &supportedCollationSet{}
 		notFoundSet = true
 	}
 	if req.Prop.SyncToken != nil {
-		notFoundProp.SyncToken = "sync-token"
-		notFoundSet = true
+		// Only the calendar and address book homes have a sync token.
+		if src.SyncToken != "" {
+			okProp.SyncToken = src.SyncToken
+			okSet = true
+		} else {
+			notFoundProp.SyncToken = "sync-token"
+			notFoundSet = true
+		}
 	}
 	if req.Prop.CTag != nil {
 		notFoundProp.CTag = "getctag"
@@ -435,6 +455,10 @@ func filterGenericCollectionPropfindResponse(resp response, req *propfindRequest
 		notFoundProp.Owner = &hrefProp{}
 		notFoundSet = true
 	}
+	if req.Prop.Invite != nil {
+		notFoundProp.Invite = &inviteProp{}
+		notFoundSet = true
+	}
 	resp.Propstat = nil
 	if okSet {
 		resp.Propstat = append(resp.Propstat, propstat{Prop: okProp, Status: httpStatusOK})
@@ -614,6 +638,10 @@ func filterCalendarCollectionPropfindResponse(resp response, req *propfindReques
 		notFoundProp.AddressBookMaxResourceSize = "max-resource-size"
 		notFoundSet = true
 	}
+	if req.Prop.AddressBookMaxImageSize != nil {
+		notFoundProp.AddressBookMaxImageSize = "max-image-size"
+		notFoundSet = true
+	}
 	if req.Prop.SupportedCollationSet != nil {
 		notFoundProp.SupportedCollationSet = &supportedCollationSet{}
 		notFoundSet = true
@@ -635,8 +663,22 @@ func filterCalendarCollectionPropfindResponse(resp response, req *propfindReques
 		notFoundSet = true
 	}
 	if req.Prop.Owner != nil {
-		notFoundProp.Owner = &hrefProp{}
-		notFoundSet = true
+		if src.Owner != nil {
+			okProp.Owner = src.Owner
+			okSet = true
+		} else {
+			notFoundProp.Owner = &hrefProp{}
+			notFoundSet = true
+		}
+	}
+	if req.Prop.Invite != nil {
+		if src.Invite != nil {
+			okProp.Invite = src.Invite
+			okSet = true
+		} else {
+			notFoundProp.Invite = &inviteProp{}
+			notFoundSet = true
+		}
 	}
 	resp.Propstat = nil
 	if okSet {
@@ -667,6 +709,15 @@ func propstatForCalendarObjectPropfind(req *propfindPropQuery, src prop) []props
 		okProp.GetContentType = src.GetContentType
 		okSet = true
 	}
+	if req.GetLastModified != nil {
+		if src.GetLastModified != "" {
+			okProp.GetLastModified = src.GetLastModified
+			okSet = true
+		} else {
+			notFoundProp.GetLastModified = "getlastmodified"
+			notFoundSet = true
+		}
+	}
 	if req.CalendarData != nil {
 		okProp.CalendarData = src.CalendarData
 		okSet = true
@@ -0,0 +1,81 @@
+package dav
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// PropfindWindowHeader opts a Depth:1 PROPFIND on a calendar into listing
+// only the events near today. Embedded clients that cannot issue a
+// calendar-query REPORT use it to avoid downloading a whole calendar. The
+// window query parameter is accepted where a client cannot set headers.
+const PropfindWindowHeader = "X-Calcard-Window"
+
+// maxPropfindWindowDays bounds each side of a PROPFIND window.
+const maxPropfindWindowDays = 366
+
+// propfindWindow is how many days before and after now a windowed PROPFIND
+// lists events for.
+type propfindWindow struct {
+	PastDays   int
+	FutureDays int
+}
+
+// parsePropfindWindow reads the requested window: "N" for N days either side
+// of now, or "P,F" for P days back and F days ahead. It returns nil when no
+// window was asked for.
+func parsePropfindWindow(r *http.Request) (*propfindWindow, error) {
+	raw := strings.TrimSpace(r.Header.Get(PropfindWindowHeader))
+	if raw == "" {
+		raw = strings.TrimSpace(r.URL.Query().Get("window"))
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	past, future, split := strings.Cut(raw, ",")
+	if !split {
+		future = past
+	}
+	pastDays, err := parseWindowDays(past)
+	if err != nil {
+		return nil, err
+	}
+	futureDays, err := parseWindowDays(future)
+	if err != nil {
+		return nil, err
+	}
+	return &propfindWindow{PastDays: pastDays, FutureDays: futureDays}, nil
+}
+
+func parseWindowDays(raw string) (int, error) {
+	days, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || days < 0 || days > maxPropfindWindowDays {
+		return 0, errors.New("invalid window: expected days, or past,future days, up to 366 each")
+	}
+	return days, nil
+}
+
+func (w *propfindWindow) String() string {
+	return fmt.Sprintf("%d,%d", w.PastDays, w.FutureDays)
+}
+
+// filter keeps the events with an instance inside the window.
+func (w *propfindWindow) filter(h *Handler, events []store.Event, now time.Time) []store.Event {
+	tr := &timeRange{
+		Start: now.AddDate(0, 0, -w.PastDays).UTC().Format("20060102T150405Z"),
+		End:   now.AddDate(0, 0, w.FutureDays).UTC().Format("20060102T150405Z"),
+	}
+	kept := events[:0]
+	for _, ev := range events {
+		if h.eventInTimeRange(ev, tr) {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
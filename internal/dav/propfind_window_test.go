@@ -0,0 +1,87 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func windowTestEvent(uid string, start time.Time, rrule string) *store.Event {
+	end := start.Add(time.Hour)
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n"
+	if rrule != "" {
+		raw += "RRULE:" + rrule + "\r\n"
+	}
+	raw += "END:VEVENT\r\nEND:VCALENDAR\r\n"
+	return &store.Event{CalendarID: 2, UID: uid, ResourceName: uid, RawICAL: raw, ETag: uid, DTStart: &start, DTEnd: &end, LastModified: start}
+}
+
+func TestPropfindWindowListsOnlyNearbyEvents(t *testing.T) {
+	now := time.Now().UTC()
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: now}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{Events: map[string]*store.Event{
+		"2:yesterday": windowTestEvent("yesterday", now.AddDate(0, 0, -1), ""),
+		"2:nextweek":  windowTestEvent("nextweek", now.AddDate(0, 0, 6), ""),
+		"2:lastyear":  windowTestEvent("lastyear", now.AddDate(-1, 0, 0), ""),
+		"2:nextyear":  windowTestEvent("nextyear", now.AddDate(1, 0, 0), ""),
+		"2:standup":   windowTestEvent("standup", now.AddDate(-1, 0, 0), "FREQ=DAILY"),
+	}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
+
+	propfind := func(target, window string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PROPFIND", target, nil)
+		req.Header.Set("Depth", "1")
+		if window != "" {
+			req.Header.Set(PropfindWindowHeader, window)
+		}
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Propfind(rr, req)
+		return rr
+	}
+
+	rr := propfind("/dav/calendars/2/", "2,7")
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get(PropfindWindowHeader); got != "2,7" {
+		t.Fatalf("%s response header = %q, want 2,7", PropfindWindowHeader, got)
+	}
+	body := rr.Body.String()
+	for _, uid := range []string{"yesterday", "nextweek", "standup"} {
+		if !strings.Contains(body, "/"+uid+".ics") {
+			t.Fatalf("expected %s listed, got %s", uid, body)
+		}
+	}
+	for _, uid := range []string{"lastyear", "nextyear"} {
+		if strings.Contains(body, "/"+uid+".ics") {
+			t.Fatalf("expected %s outside the window, got %s", uid, body)
+		}
+	}
+
+	rr = propfind("/dav/calendars/2/?window=3", "")
+	if body := rr.Body.String(); strings.Count(body, "<d:response>") != 3 {
+		t.Fatalf("expected collection, yesterday and standup for a 3-day window, got %s", body)
+	}
+
+	rr = propfind("/dav/calendars/2/", "")
+	if body := rr.Body.String(); strings.Count(body, "<d:response>") != 6 || rr.Header().Get(PropfindWindowHeader) != "" {
+		t.Fatalf("expected every event without a window, got %s", body)
+	}
+
+	for _, window := range []string{"soon", "-1", "400", "1,2,3"} {
+		if rr := propfind("/dav/calendars/2/", window); rr.Code != http.StatusBadRequest {
+			t.Fatalf("window %q status = %d, want 400", window, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package dav
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+// PublicEvent serves GET /event-links/{token}.ics: the single event an
+// expiring link points to, for pasting event details into chat or email.
+// Every download is counted on the link.
+func (h *Handler) PublicEvent(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(path.Base(r.URL.Path), ".ics")
+	if h.store == nil || h.store.EventLinks == nil || !ok || token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	ev, err := events.NewService(h.store).OpenEventLink(r.Context(), token)
+	if errors.Is(err, events.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load event", http.StatusInternalServerError)
+		return
+	}
+	// Not cached, so each download reaches the access count.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="event.ics"`)
+	_, _ = w.Write([]byte(ev.RawICAL))
+}
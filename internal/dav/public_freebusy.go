@@ -0,0 +1,52 @@
+package dav
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+// PublicFreeBusy serves GET /freebusy/{token}.ifb: a VFREEBUSY covering the
+// link owner's calendars from now until the link's window ends. It carries
+// only busy periods, never summaries, attendees or the owner's address.
+func (h *Handler) PublicFreeBusy(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(path.Base(r.URL.Path), ".ifb")
+	if h.store == nil || h.store.FreeBusyLinks == nil || token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	link, err := h.store.FreeBusyLinks.GetByToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "failed to load free/busy", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		http.NotFound(w, r)
+		return
+	}
+	owner, err := h.store.Users.GetByID(r.Context(), link.UserID)
+	if err != nil {
+		http.Error(w, "failed to load free/busy", http.StatusInternalServerError)
+		return
+	}
+	if owner == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	start := time.Now().UTC().Truncate(time.Hour)
+	end := start.AddDate(0, 0, link.WindowDays)
+	periods, err := events.NewService(h.store).BusyPeriods(r.Context(), owner, start, end)
+	if err != nil {
+		http.Error(w, "failed to load free/busy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "max-age=300")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	_, _ = w.Write([]byte(events.FreeBusyCalendar(periods, start, end)))
+}
@@ -0,0 +1,109 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeFreeBusyLinkRepo struct {
+	store.FreeBusyLinkRepository
+	links []store.FreeBusyLink
+}
+
+func (f *fakeFreeBusyLinkRepo) GetByToken(ctx context.Context, token string) (*store.FreeBusyLink, error) {
+	for _, l := range f.links {
+		if l.Token == token {
+			link := l
+			return &link, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeFreeBusyUserRepo struct {
+	store.UserRepository
+	users map[int64]*store.User
+}
+
+func (f *fakeFreeBusyUserRepo) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return f.users[id], nil
+}
+
+func freeBusyTestEvent(calendarID int64, uid string, start time.Time, duration time.Duration, props ...string) *store.Event {
+	end := start.Add(duration)
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:Secret " + uid + "\r\n"
+	for _, p := range props {
+		raw += p + "\r\n"
+	}
+	raw += "END:VEVENT\r\nEND:VCALENDAR\r\n"
+	return &store.Event{CalendarID: calendarID, UID: uid, RawICAL: raw, DTStart: &start, DTEnd: &end}
+}
+
+func TestPublicFreeBusyReturnsMergedBusyTimeOnly(t *testing.T) {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 2)
+	events := map[string]*store.Event{
+		"1:a": freeBusyTestEvent(1, "a", day.Add(9*time.Hour), time.Hour),
+		// Overlaps "a" on another of the owner's calendars.
+		"2:b":           freeBusyTestEvent(2, "b", day.Add(9*time.Hour+30*time.Minute), time.Hour),
+		"1:transparent": freeBusyTestEvent(1, "transparent", day.Add(13*time.Hour), time.Hour, "TRANSP:TRANSPARENT"),
+		"1:cancelled":   freeBusyTestEvent(1, "cancelled", day.Add(14*time.Hour), time.Hour, "STATUS:CANCELLED"),
+		"1:weekly":      freeBusyTestEvent(1, "weekly", day.AddDate(0, 0, -14).Add(16*time.Hour), time.Hour, "RRULE:FREQ=WEEKLY"),
+		"1:past":        freeBusyTestEvent(1, "past", day.AddDate(0, 0, -30), time.Hour),
+		"3:other-user":  freeBusyTestEvent(3, "other-user", day.Add(18*time.Hour), time.Hour),
+	}
+	h := &Handler{store: &store.Store{
+		FreeBusyLinks: &fakeFreeBusyLinkRepo{links: []store.FreeBusyLink{{UserID: 1, Token: "tok", WindowDays: 7}}},
+		Users:         &fakeFreeBusyUserRepo{users: map[int64]*store.User{1: {ID: 1, PrimaryEmail: "me@example.com"}}},
+		Calendars: &storetest.Calendars{Calendars: map[int64]*store.Calendar{
+			1: {ID: 1, UserID: 1},
+			2: {ID: 2, UserID: 1},
+			3: {ID: 3, UserID: 9},
+		}},
+		Events: &storetest.Events{Events: events},
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/freebusy/tok.ifb", nil)
+	rr := httptest.NewRecorder()
+	h.PublicFreeBusy(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	const stamp = "20060102T150405Z"
+	busy := func(start time.Time, d time.Duration) string {
+		return "FREEBUSY;FBTYPE=BUSY:" + start.Format(stamp) + "/" + start.Add(d).Format(stamp)
+	}
+	for _, want := range []string{
+		"BEGIN:VFREEBUSY",
+		busy(day.Add(9*time.Hour), 90*time.Minute),
+		busy(day.Add(16*time.Hour), time.Hour),
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in response, got %s", want, body)
+		}
+	}
+	// The merged morning block and this week's weekly instance; next week's
+	// falls outside the 7-day window.
+	if got := strings.Count(body, "FREEBUSY;"); got != 2 {
+		t.Fatalf("expected 2 busy periods, got %d in %s", got, body)
+	}
+	for _, leak := range []string{"Secret", "SUMMARY", "ORGANIZER", "me@example.com", busy(day.Add(13*time.Hour), time.Hour), busy(day.Add(14*time.Hour), time.Hour), busy(day.Add(18*time.Hour), time.Hour)} {
+		if strings.Contains(body, leak) {
+			t.Fatalf("expected %q left out of public free/busy, got %s", leak, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.PublicFreeBusy(rr, httptest.NewRequest(http.MethodGet, "/freebusy/unknown.ifb", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown token status = %d, want 404", rr.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package dav
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+)
+
+type publicTasksResponse struct {
+	Tasks []publicTask `json:"tasks"`
+}
+
+type publicTask struct {
+	UID          string   `json:"uid"`
+	CalendarID   int64    `json:"calendarId"`
+	CalendarName string   `json:"calendarName"`
+	Summary      string   `json:"summary"`
+	Description  string   `json:"description,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	Priority     int      `json:"priority,omitempty"`
+	Due          string   `json:"due,omitempty"`
+	AllDay       bool     `json:"allDay,omitempty"`
+	Categories   []string `json:"categories"`
+}
+
+// PublicTasks serves GET /tasks/{token}.ics and /tasks/{token}.json: the
+// link owner's open tasks from their own calendars, as iCalendar VTODOs or
+// JSON. The due_after, due_before and category query parameters narrow the
+// list; due bounds are dates (YYYY-MM-DD, midnight UTC) or RFC 3339 times.
+func (h *Handler) PublicTasks(w http.ResponseWriter, r *http.Request) {
+	file := path.Base(r.URL.Path)
+	ext := path.Ext(file)
+	token := strings.TrimSuffix(file, ext)
+	if h.store == nil || h.store.TaskFeedLinks == nil || token == "" || (ext != ".ics" && ext != ".json") {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	var filter events.TaskFilter
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"due_after", &filter.DueAfter}, {"due_before", &filter.DueBefore}} {
+		raw := strings.TrimSpace(query.Get(bound.name))
+		if raw == "" {
+			continue
+		}
+		t, err := parseTaskFeedTime(raw)
+		if err != nil {
+			http.Error(w, "invalid "+bound.name, http.StatusBadRequest)
+			return
+		}
+		*bound.dst = &t
+	}
+	filter.Category = strings.TrimSpace(query.Get("category"))
+
+	link, err := h.store.TaskFeedLinks.GetByToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		http.NotFound(w, r)
+		return
+	}
+	owner, err := h.store.Users.GetByID(r.Context(), link.UserID)
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	if owner == nil {
+		http.NotFound(w, r)
+		return
+	}
+	tasks, err := events.NewService(h.store).OpenTasks(r.Context(), owner, filter)
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "max-age=300")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if ext == ".ics" {
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		_, _ = w.Write([]byte(events.TasksCalendar(tasks)))
+		return
+	}
+	resp := publicTasksResponse{Tasks: make([]publicTask, 0, len(tasks))}
+	for _, t := range tasks {
+		item := publicTask{
+			UID:          t.UID,
+			CalendarID:   t.CalendarID,
+			CalendarName: t.CalendarName,
+			Summary:      t.Summary,
+			Description:  t.Description,
+			Status:       t.Status,
+			Priority:     t.Priority,
+			AllDay:       t.DueAllDay,
+			Categories:   t.Categories,
+		}
+		if item.Categories == nil {
+			item.Categories = []string{}
+		}
+		if t.Due != nil {
+			if t.DueAllDay {
+				item.Due = t.Due.Format("2006-01-02")
+			} else {
+				item.Due = t.Due.UTC().Format(time.RFC3339)
+			}
+		}
+		resp.Tasks = append(resp.Tasks, item)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func parseTaskFeedTime(raw string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
@@ -0,0 +1,136 @@
+package dav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeTaskFeedLinkRepo struct {
+	store.TaskFeedLinkRepository
+	links []store.TaskFeedLink
+}
+
+func (f *fakeTaskFeedLinkRepo) GetByToken(ctx context.Context, token string) (*store.TaskFeedLink, error) {
+	for _, l := range f.links {
+		if l.Token == token {
+			link := l
+			return &link, nil
+		}
+	}
+	return nil, nil
+}
+
+func taskTestObject(calendarID int64, uid string, props ...string) *store.Event {
+	raw := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:" + uid + "\r\n"
+	for _, p := range props {
+		raw += p + "\r\n"
+	}
+	raw += "END:VTODO\r\nEND:VCALENDAR\r\n"
+	return &store.Event{CalendarID: calendarID, UID: uid, RawICAL: raw}
+}
+
+func newPublicTasksTestHandler() *Handler {
+	objects := map[string]*store.Event{
+		"1:late":    taskTestObject(1, "late", "SUMMARY:Renew passport", "DUE;VALUE=DATE:20300301", "CATEGORIES:Home,Errands"),
+		"2:soon":    taskTestObject(2, "soon", "SUMMARY:Water plants", "DUE:20300110T090000Z", "PRIORITY:1", "CATEGORIES:Home"),
+		"1:undated": taskTestObject(1, "undated", "SUMMARY:Read book"),
+		"1:done":    taskTestObject(1, "done", "SUMMARY:Finished", "STATUS:COMPLETED", "DUE:20300105T090000Z"),
+		"1:full":    taskTestObject(1, "full", "SUMMARY:All but marked", "PERCENT-COMPLETE:100"),
+		"1:event":   taskTestEvent(1, "event"),
+		"3:other":   taskTestObject(3, "other", "SUMMARY:Someone else's", "DUE:20300110T090000Z"),
+	}
+	return &Handler{store: &store.Store{
+		TaskFeedLinks: &fakeTaskFeedLinkRepo{links: []store.TaskFeedLink{{UserID: 1, Token: "tok"}}},
+		Users:         &fakeFreeBusyUserRepo{users: map[int64]*store.User{1: {ID: 1, PrimaryEmail: "me@example.com"}}},
+		Calendars: &storetest.Calendars{Calendars: map[int64]*store.Calendar{
+			1: {ID: 1, UserID: 1, Name: "Personal"},
+			2: {ID: 2, UserID: 1, Name: "Garden"},
+			3: {ID: 3, UserID: 9, Name: "Theirs"},
+		}},
+		Events: &storetest.Events{Events: objects},
+	}}
+}
+
+func taskTestEvent(calendarID int64, uid string) *store.Event {
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:Meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	return &store.Event{CalendarID: calendarID, UID: uid, RawICAL: raw}
+}
+
+func TestPublicTasksJSONListsOpenTasksByDueDate(t *testing.T) {
+	h := newPublicTasksTestHandler()
+
+	rr := httptest.NewRecorder()
+	h.PublicTasks(rr, httptest.NewRequest(http.MethodGet, "/tasks/tok.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp publicTasksResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var uids []string
+	for _, task := range resp.Tasks {
+		uids = append(uids, task.UID)
+	}
+	if got := strings.Join(uids, ","); got != "soon,late,undated" {
+		t.Fatalf("tasks = %s, want soon,late,undated", got)
+	}
+	soon, late := resp.Tasks[0], resp.Tasks[1]
+	if soon.Due != "2030-01-10T09:00:00Z" || soon.Priority != 1 || soon.CalendarName != "Garden" {
+		t.Fatalf("unexpected task %+v", soon)
+	}
+	if late.Due != "2030-03-01" || !late.AllDay || strings.Join(late.Categories, ",") != "Home,Errands" {
+		t.Fatalf("unexpected task %+v", late)
+	}
+
+	rr = httptest.NewRecorder()
+	h.PublicTasks(rr, httptest.NewRequest(http.MethodGet, "/tasks/tok.json?due_before=2030-02-01&category=home", nil))
+	resp = publicTasksResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Tasks) != 1 || resp.Tasks[0].UID != "soon" {
+		t.Fatalf("filtered tasks = %+v, want only soon", resp.Tasks)
+	}
+
+	rr = httptest.NewRecorder()
+	h.PublicTasks(rr, httptest.NewRequest(http.MethodGet, "/tasks/tok.json?due_after=tomorrow", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid due_after status = %d, want 400", rr.Code)
+	}
+}
+
+func TestPublicTasksICalendarFeed(t *testing.T) {
+	h := newPublicTasksTestHandler()
+
+	rr := httptest.NewRecorder()
+	h.PublicTasks(rr, httptest.NewRequest(http.MethodGet, "/tasks/tok.ics?category=Errands", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+	if strings.Count(body, "BEGIN:VTODO") != 1 || !strings.Contains(body, "UID:late") {
+		t.Fatalf("expected only the errand in the feed, got %s", body)
+	}
+	if strings.Contains(body, "VEVENT") {
+		t.Fatalf("expected events left out of the task feed, got %s", body)
+	}
+
+	for _, target := range []string{"/tasks/unknown.ics", "/tasks/tok.txt"} {
+		rr = httptest.NewRecorder()
+		h.PublicTasks(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s status = %d, want 404", target, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package dav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/push"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// pushSubscriptionsPath is where WebDAV-Push subscriptions live once
+// registered; a DELETE there removes one.
+const pushSubscriptionsPath = "/dav/push-subscriptions"
+
+// pushMaxLifetime caps how long a subscription lasts without the client
+// registering it again.
+const pushMaxLifetime = 7 * 24 * time.Hour
+
+var (
+	propPushTransports        = xml.Name{Space: push.Namespace, Local: "transports"}
+	propPushTopic             = xml.Name{Space: push.Namespace, Local: "topic"}
+	propPushSupportedTriggers = xml.Name{Space: push.Namespace, Local: "supported-triggers"}
+)
+
+// pushTransportsProp advertises Web Push with the server's VAPID key, the
+// only transport offered.
+type pushTransportsProp struct {
+	WebPush struct {
+		VAPIDPublicKey struct {
+			Type string `xml:"type,attr"`
+			Key  string `xml:",chardata"`
+		} `xml:"https://bitfire.at/webdav-push vapid-public-key"`
+	} `xml:"https://bitfire.at/webdav-push web-push"`
+}
+
+// pushTriggersProp tells clients that messages announce changes to the
+// collection's members, not to its properties.
+type pushTriggersProp struct {
+	ContentUpdate struct {
+		Depth string `xml:"DAV: depth"`
+	} `xml:"https://bitfire.at/webdav-push content-update"`
+}
+
+// pushRegisterRequest is the body of a WebDAV-Push registration POST.
+type pushRegisterRequest struct {
+	XMLName      xml.Name `xml:"https://bitfire.at/webdav-push push-register"`
+	Subscription struct {
+		WebPush *struct {
+			PushResource    string `xml:"https://bitfire.at/webdav-push push-resource"`
+			ContentEncoding string `xml:"https://bitfire.at/webdav-push content-encoding"`
+			PublicKey       struct {
+				Type string `xml:"type,attr"`
+				Key  string `xml:",chardata"`
+			} `xml:"https://bitfire.at/webdav-push subscription-public-key"`
+			AuthSecret string `xml:"https://bitfire.at/webdav-push auth-secret"`
+		} `xml:"https://bitfire.at/webdav-push web-push-subscription"`
+	} `xml:"https://bitfire.at/webdav-push subscription"`
+	Trigger struct {
+		ContentUpdate *struct{} `xml:"https://bitfire.at/webdav-push content-update"`
+	} `xml:"https://bitfire.at/webdav-push trigger"`
+	Expires string `xml:"https://bitfire.at/webdav-push expires"`
+}
+
+// pushEnabled reports whether WebDAV-Push is offered to user, which the
+// push feature flag decides.
+func (h *Handler) pushEnabled(ctx context.Context, user *store.User) bool {
+	if h.store == nil || h.store.PushSubscriptions == nil {
+		return false
+	}
+	return h.featureFlag(ctx, user, config.FeaturePush)
+}
+
+// vapidKeys returns the server's VAPID keys, loading them on first use.
+func (h *Handler) vapidKeys(ctx context.Context) (*push.Keys, error) {
+	h.pushMu.Lock()
+	defer h.pushMu.Unlock()
+	if h.pushKeys == nil {
+		keys, err := push.LoadKeys(ctx, h.store.PushSubscriptions)
+		if err != nil {
+			return nil, err
+		}
+		h.pushKeys = keys
+	}
+	return h.pushKeys, nil
+}
+
+// requestedPushProperties reports whether a PROPFIND names any WebDAV-Push
+// property. Like the sync statistics they are left out of allprop.
+func requestedPushProperties(req *propfindRequest) bool {
+	if req == nil || req.Prop == nil {
+		return false
+	}
+	for _, name := range req.Prop.CustomXML {
+		switch name {
+		case propPushTransports, propPushTopic, propPushSupportedTriggers:
+			return true
+		}
+	}
+	return false
+}
+
+// addPushProperties fills the WebDAV-Push properties on calendar and
+// address book collection responses when push is enabled.
+func (h *Handler) addPushProperties(ctx context.Context, user *store.User, responses []response, req *propfindRequest) error {
+	if user == nil || !requestedPushProperties(req) || !h.pushEnabled(ctx, user) {
+		return nil
+	}
+	keys, err := h.vapidKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range responses {
+		collectionType, collectionID, ok := h.responseCollection(ctx, user, responses[i])
+		if !ok {
+			continue
+		}
+		p := &responses[i].Propstat[0].Prop
+		var transports pushTransportsProp
+		transports.WebPush.VAPIDPublicKey.Type = "p256ecdsa"
+		transports.WebPush.VAPIDPublicKey.Key = keys.PublicKey()
+		var triggers pushTriggersProp
+		triggers.ContentUpdate.Depth = "1"
+		p.setCustomXMLProperty(XMLProperty{Name: propPushTransports, Value: transports})
+		p.setCustomXMLProperty(XMLProperty{Name: propPushTopic, Value: keys.Topic(collectionType, collectionID)})
+		p.setCustomXMLProperty(XMLProperty{Name: propPushSupportedTriggers, Value: triggers})
+	}
+	return nil
+}
+
+// pushRegister subscribes a Web Push endpoint to changes to the calendar or
+// address book at the request path. The subscription's URL is returned in
+// Location with 201, or with 204 when the client renewed an existing one.
+func (h *Handler) pushRegister(w http.ResponseWriter, r *http.Request, user *store.User) {
+	ctx := r.Context()
+	cleanPath := path.Clean(r.URL.Path)
+	collectionType, collectionID, err := h.pushCollection(ctx, user, cleanPath)
+	if err != nil {
+		switch {
+		case errors.Is(err, errForbidden):
+			writeDAVError(w, http.StatusForbidden, "forbidden", condNeedPrivileges)
+		case errors.Is(err, store.ErrNotFound):
+			writeDAVError(w, http.StatusNotFound, "not found")
+		default:
+			writeDAVError(w, http.StatusInternalServerError, "failed to load collection")
+		}
+		return
+	}
+
+	body, err := readDAVBody(w, r, maxDAVBodyBytes)
+	if err != nil {
+		if errors.Is(err, errRequestTooLarge) {
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
+			return
+		}
+		writeDAVError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	var req pushRegisterRequest
+	if err := safeUnmarshalXML(body, &req); err != nil || req.Subscription.WebPush == nil {
+		writeDAVError(w, http.StatusBadRequest, "invalid push registration")
+		return
+	}
+	if req.Trigger.ContentUpdate == nil {
+		writeDAVError(w, http.StatusForbidden, "content-update is the only supported trigger")
+		return
+	}
+	sub := req.Subscription.WebPush
+	resource := strings.TrimSpace(sub.PushResource)
+	publicKey := strings.TrimSpace(sub.PublicKey.Key)
+	authSecret := strings.TrimSpace(sub.AuthSecret)
+	if encoding := strings.TrimSpace(sub.ContentEncoding); encoding != "" && encoding != "aes128gcm" {
+		writeDAVError(w, http.StatusForbidden, "aes128gcm is the only supported content encoding")
+		return
+	}
+	if err := push.ValidateResource(resource); err != nil {
+		writeDAVError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if keyType := strings.TrimSpace(sub.PublicKey.Type); keyType != "" && keyType != "p256dh" {
+		writeDAVError(w, http.StatusBadRequest, "invalid push registration")
+		return
+	}
+	if err := push.ValidateKeys(publicKey, authSecret); err != nil {
+		writeDAVError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(pushMaxLifetime)
+	if requested, err := http.ParseTime(strings.TrimSpace(req.Expires)); err == nil && requested.After(now) && requested.Before(expires) {
+		expires = requested
+	}
+	cursor, err := h.store.Changes.Latest(ctx)
+	if err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to register subscription")
+		return
+	}
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to register subscription")
+		return
+	}
+	saved, created, err := h.store.PushSubscriptions.Register(ctx, store.PushSubscription{
+		UserID:         user.ID,
+		CollectionType: collectionType,
+		CollectionID:   collectionID,
+		Token:          hex.EncodeToString(token[:]),
+		PushResource:   resource,
+		PublicKey:      publicKey,
+		AuthSecret:     authSecret,
+		Cursor:         cursor,
+		ExpiresAt:      expires.UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		h.logger().Error("pushRegister", "failed to register push subscription on %s: %v", cleanPath, err)
+		writeDAVError(w, http.StatusInternalServerError, "failed to register subscription")
+		return
+	}
+	h.logger().Debug("pushRegister", "registered push subscription %d on %s", saved.ID, cleanPath)
+	w.Header().Set("Location", pushSubscriptionsPath+"/"+saved.Token)
+	w.Header().Set("Expires", saved.ExpiresAt.UTC().Format(http.TimeFormat))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pushCollection resolves the calendar or address book collection at
+// cleanPath that user may read.
+func (h *Handler) pushCollection(ctx context.Context, user *store.User, cleanPath string) (string, int64, error) {
+	if segment := singleCollectionSegment(cleanPath, "/dav/calendars/"); segment != "" {
+		id, ok, err := h.resolveCalendarID(ctx, user, segment)
+		if err != nil {
+			return "", 0, err
+		}
+		if !ok || id == birthdayCalendarID {
+			return "", 0, store.ErrNotFound
+		}
+		if _, err := h.loadCalendarWithPrivilege(ctx, user, id, cleanPath, "read"); err != nil {
+			return "", 0, err
+		}
+		return push.Calendar, id, nil
+	}
+	if segment := singleCollectionSegment(cleanPath, "/dav/addressbooks/"); segment != "" {
+		id, ok, err := h.resolveAddressBookID(ctx, user, segment)
+		if err != nil {
+			return "", 0, err
+		}
+		if !ok {
+			return "", 0, store.ErrNotFound
+		}
+		if _, err := h.loadAddressBookWithPrivilege(ctx, user, id, cleanPath, "read"); err != nil {
+			return "", 0, err
+		}
+		return push.AddressBook, id, nil
+	}
+	return "", 0, store.ErrNotFound
+}
+
+// isPushSubscriptionPath reports whether cleanPath names a registered
+// subscription.
+func isPushSubscriptionPath(cleanPath string) bool {
+	return singleCollectionSegment(cleanPath, pushSubscriptionsPath+"/") != ""
+}
+
+// pushUnregister removes the user's subscription at the request path.
+func (h *Handler) pushUnregister(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if !h.pushEnabled(r.Context(), user) {
+		writeDAVError(w, http.StatusNotFound, "not found")
+		return
+	}
+	token := path.Base(path.Clean(r.URL.Path))
+	sub, err := h.store.PushSubscriptions.GetByToken(r.Context(), token)
+	if err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to load subscription")
+		return
+	}
+	if sub == nil || sub.UserID != user.ID {
+		writeDAVError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err := h.store.PushSubscriptions.Delete(r.Context(), sub.ID); err != nil {
+		writeDAVError(w, http.StatusInternalServerError, "failed to remove subscription")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,193 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakePushSubscriptionRepo struct {
+	store.PushSubscriptionRepository
+	subs   []store.PushSubscription
+	nextID int64
+}
+
+func (f *fakePushSubscriptionRepo) Register(ctx context.Context, sub store.PushSubscription) (*store.PushSubscription, bool, error) {
+	for i := range f.subs {
+		s := &f.subs[i]
+		if s.CollectionType == sub.CollectionType && s.CollectionID == sub.CollectionID && s.PushResource == sub.PushResource {
+			s.UserID, s.PublicKey, s.AuthSecret, s.ExpiresAt = sub.UserID, sub.PublicKey, sub.AuthSecret, sub.ExpiresAt
+			saved := *s
+			return &saved, false, nil
+		}
+	}
+	f.nextID++
+	sub.ID = f.nextID
+	f.subs = append(f.subs, sub)
+	return &sub, true, nil
+}
+
+func (f *fakePushSubscriptionRepo) GetByToken(ctx context.Context, token string) (*store.PushSubscription, error) {
+	for _, s := range f.subs {
+		if s.Token == token {
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakePushSubscriptionRepo) Delete(ctx context.Context, id int64) error {
+	for i, s := range f.subs {
+		if s.ID == id {
+			f.subs = append(f.subs[:i], f.subs[i+1:]...)
+		}
+	}
+	return nil
+}
+
+func (f *fakePushSubscriptionRepo) VAPIDKey(ctx context.Context, candidate string) (string, error) {
+	return candidate, nil
+}
+
+type fakeLatestChanges struct {
+	store.ChangeRepository
+}
+
+func (fakeLatestChanges) Latest(ctx context.Context) (store.ChangeCursor, error) {
+	return store.ChangeCursor{TxID: 77, Seq: 3}, nil
+}
+
+func newPushTestHandler(enabled bool) (*Handler, *fakePushSubscriptionRepo) {
+	cfg := &config.Config{}
+	cfg.DAV.PushEnabled = enabled
+	subs := &fakePushSubscriptionRepo{}
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	return &Handler{cfg: cfg, store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, PushSubscriptions: subs, Changes: fakeLatestChanges{}}}, subs
+}
+
+func TestPropfindAdvertisesWebDAVPush(t *testing.T) {
+	body := `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:p="https://bitfire.at/webdav-push"><d:prop>` +
+		`<p:transports/><p:topic/><p:supported-triggers/></d:prop></d:propfind>`
+	propfind := func(h *Handler) string {
+		req := httptest.NewRequest("PROPFIND", "/dav/calendars/2/", strings.NewReader(body))
+		req.Header.Set("Depth", "0")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Propfind(rr, req)
+		if rr.Code != http.StatusMultiStatus {
+			t.Fatalf("expected 207, got %d", rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	h, _ := newPushTestHandler(true)
+	got := propfind(h)
+	keys, err := h.vapidKeys(context.Background())
+	if err != nil {
+		t.Fatalf("vapidKeys() error = %v", err)
+	}
+	for _, want := range []string{
+		`type="p256ecdsa">` + keys.PublicKey() + `</vapid-public-key>`,
+		`>` + keys.Topic("calendar", 2) + `</topic>`,
+		`<depth xmlns="DAV:">1</depth>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in response, got %s", want, got)
+		}
+	}
+
+	h, _ = newPushTestHandler(false)
+	if got := propfind(h); strings.Contains(got, "vapid-public-key") {
+		t.Fatalf("expected no push transport when disabled, got %s", got)
+	}
+}
+
+func TestPushRegistrationLifecycle(t *testing.T) {
+	h, subs := newPushTestHandler(true)
+	register := func(resource, extra string) *httptest.ResponseRecorder {
+		body := `<?xml version="1.0" encoding="utf-8"?>
+<P:push-register xmlns:D="DAV:" xmlns:P="https://bitfire.at/webdav-push">
+  <P:subscription><P:web-push-subscription>
+    <P:push-resource>` + resource + `</P:push-resource>
+    <P:subscription-public-key type="p256dh">BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4</P:subscription-public-key>
+    <P:auth-secret>BTBZMqHH6r4Tts7J_aSIgg</P:auth-secret>
+  </P:web-push-subscription></P:subscription>
+  <P:trigger><P:content-update><D:depth>1</D:depth></P:content-update></P:trigger>` + extra + `
+</P:push-register>`
+		req := httptest.NewRequest(http.MethodPost, "/dav/calendars/2/", strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Post(rr, req)
+		return rr
+	}
+
+	expires := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	rr := register("https://push.example.net/wpush/abc", "<P:expires>"+expires.Format(http.TimeFormat)+"</P:expires>")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	location := rr.Header().Get("Location")
+	if len(subs.subs) != 1 || location != pushSubscriptionsPath+"/"+subs.subs[0].Token || rr.Header().Get("Expires") != expires.Format(http.TimeFormat) {
+		t.Fatalf("Location = %q, Expires = %q, subs = %+v", location, rr.Header().Get("Expires"), subs.subs)
+	}
+	if sub := subs.subs[0]; sub.CollectionType != "calendar" || sub.CollectionID != 2 || sub.Cursor != (store.ChangeCursor{TxID: 77, Seq: 3}) {
+		t.Fatalf("subscription = %+v", sub)
+	}
+
+	// Registering again renews it, and expiry is capped.
+	rr = register("https://push.example.net/wpush/abc", "<P:expires>"+time.Now().Add(365*24*time.Hour).UTC().Format(http.TimeFormat)+"</P:expires>")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Location") != location || len(subs.subs) != 1 {
+		t.Fatalf("renew status = %d Location = %q subs = %d", rr.Code, rr.Header().Get("Location"), len(subs.subs))
+	}
+	if subs.subs[0].ExpiresAt.After(time.Now().Add(pushMaxLifetime)) {
+		t.Fatalf("expiry %v beyond the cap", subs.subs[0].ExpiresAt)
+	}
+
+	for _, resource := range []string{"http://push.example.net/x", "https://127.0.0.1/x"} {
+		if rr := register(resource, ""); rr.Code != http.StatusForbidden {
+			t.Fatalf("register(%q) status = %d, want 403", resource, rr.Code)
+		}
+	}
+
+	del := func(user int64) int {
+		req := httptest.NewRequest(http.MethodDelete, location, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: user}))
+		rr := httptest.NewRecorder()
+		h.Delete(rr, req)
+		return rr.Code
+	}
+	if code := del(9); code != http.StatusNotFound {
+		t.Fatalf("unregister by another user status = %d, want 404", code)
+	}
+	if code := del(1); code != http.StatusNoContent || len(subs.subs) != 0 {
+		t.Fatalf("unregister status = %d, subs = %d", code, len(subs.subs))
+	}
+}
+
+func TestReconfigureTogglesWebDAVPush(t *testing.T) {
+	base, _ := newPushTestHandler(false)
+	h := NewServer(Options{Config: base.cfg, Store: base.store})
+	ctx := context.Background()
+	user := &store.User{ID: 1}
+	if h.pushEnabled(ctx, user) {
+		t.Fatal("push is off in the startup configuration")
+	}
+	next := &config.Config{}
+	next.DAV.PushEnabled = true
+	h.Reconfigure(next)
+	if !h.pushEnabled(ctx, user) {
+		t.Fatal("a reload turning push on should apply to the next request")
+	}
+}
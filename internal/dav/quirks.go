@@ -0,0 +1,140 @@
+package dav
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/quirks"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+type quirksContextKey struct{}
+
+// clientQuirks returns the quirks ClientQuirks matched for the request.
+func clientQuirks(ctx context.Context) quirks.Set {
+	set, _ := ctx.Value(quirksContextKey{}).(quirks.Set)
+	return set
+}
+
+// ClientQuirks is middleware that matches the request's User-Agent against
+// the configured quirk rules and applies the CalDAV-Timezones header.
+// Matched clients have their responses buffered and rewritten: vCards
+// downgraded to 3.0, VTIMEZONEs stripped or added and CDATA sections
+// escaped. Everyone else is served directly.
+func (h *Handler) ClientQuirks(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set := timezonePreference(r, h.quirkRules().For(r.UserAgent()))
+		if set == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.logger().Trace("ClientQuirks", "applying %s for %q", set, r.UserAgent())
+		r = r.WithContext(context.WithValue(r.Context(), quirksContextKey{}, set))
+		if !set.Has(quirks.VCard3) && !set.Has(quirks.NoCDATA) && !set.Has(quirks.NoTimezones) && !set.Has(quirks.Timezones) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		qw := &quirkWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(qw, r)
+		body := rewriteForQuirks(set, w.Header().Get("Content-Type"), qw.buf.Bytes())
+		w.Header().Del("Content-Length")
+		w.WriteHeader(qw.status)
+		_, _ = w.Write(body)
+	})
+}
+
+func (h *Handler) quirkRules() quirks.Rules {
+	h.confMu.RLock()
+	defer h.confMu.RUnlock()
+	return h.quirks
+}
+
+// quirkWriter holds a response back so it can be rewritten once complete.
+type quirkWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *quirkWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *quirkWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+var (
+	addressDataElement  = regexp.MustCompile(`(?s)(<card:address-data>)((?:<!\[CDATA\[.*?\]\]>)+)(</card:address-data>)`)
+	calendarDataElement = regexp.MustCompile(`(?s)(<cal:calendar-data>)((?:<!\[CDATA\[.*?\]\]>)+)(</cal:calendar-data>)`)
+	cdataSection        = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+	xmlTextEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// rewriteForQuirks applies the response quirks in set to a body of the
+// given content type.
+func rewriteForQuirks(set quirks.Set, contentType string, body []byte) []byte {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "text/vcard" && set.Has(quirks.VCard3):
+		return []byte(utils.ConvertVCardTo3(string(body)))
+	case mediaType == "text/calendar":
+		return []byte(adjustTimezones(string(body), set))
+	case !strings.HasSuffix(mediaType, "xml"):
+		return body
+	}
+	if set.Has(quirks.VCard3) {
+		body = addressDataElement.ReplaceAllFunc(body, func(m []byte) []byte {
+			parts := addressDataElement.FindSubmatch(m)
+			card := utils.ConvertVCardTo3(string(cdataText(parts[2])))
+			return append(append(append([]byte{}, parts[1]...), cdata(card)...), parts[3]...)
+		})
+	}
+	if set.Has(quirks.NoTimezones) || set.Has(quirks.Timezones) {
+		body = calendarDataElement.ReplaceAllFunc(body, func(m []byte) []byte {
+			parts := calendarDataElement.FindSubmatch(m)
+			data := adjustTimezones(string(cdataText(parts[2])), set)
+			return append(append(append([]byte{}, parts[1]...), cdata(data)...), parts[3]...)
+		})
+	}
+	if set.Has(quirks.NoCDATA) {
+		body = cdataSection.ReplaceAllFunc(body, func(m []byte) []byte {
+			return []byte(xmlTextEscaper.Replace(string(cdataSection.FindSubmatch(m)[1])))
+		})
+	}
+	return body
+}
+
+// cdataText joins the content of consecutive CDATA sections, which the XML
+// encoder produces when the text itself contains "]]>".
+func cdataText(sections []byte) []byte {
+	var out []byte
+	for _, m := range cdataSection.FindAllSubmatch(sections, -1) {
+		out = append(out, m[1]...)
+	}
+	return out
+}
+
+func cdata(text string) string {
+	return "<![CDATA[" + strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+// minimalAllProp is what an allprop PROPFIND returns to clients with the
+// MinimalProps quirk: enough to discover and sync collections.
+func minimalAllProp() *propfindPropQuery {
+	return &propfindPropQuery{
+		DisplayName:             &struct{}{},
+		ResourceType:            &struct{}{},
+		GetETag:                 &struct{}{},
+		GetContentType:          &struct{}{},
+		GetLastModified:         &struct{}{},
+		SyncToken:               &struct{}{},
+		CTag:                    &struct{}{},
+		CalendarColor:           &struct{}{},
+		CurrentUserPrincipal:    &struct{}{},
+		CurrentUserPrivilegeSet: &struct{}{},
+	}
+}
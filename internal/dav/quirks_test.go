@@ -0,0 +1,107 @@
+package dav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/quirks"
+)
+
+func TestClientQuirksRewritesMatchedClientsOnly(t *testing.T) {
+	rules, err := quirks.Parse([]string{"OldClient=vcard3+no-cdata+minimal-props"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.DAV.ClientQuirks = rules
+	h := NewServer(Options{Config: cfg})
+
+	card := "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:A & B\r\nEND:VCARD\r\n"
+	var seen quirks.Set
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = clientQuirks(r.Context())
+		writeMultiStatus(w, multistatus{Response: []response{
+			resourceResponse("/dav/addressbooks/1/a.vcf", etagProp("e", card, false)),
+		}})
+	})
+	serve := func(userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("REPORT", "/dav/addressbooks/1/", nil)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		h.ClientQuirks(next).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("Mozilla/5.0 NewClient/1")
+	if seen != 0 || !strings.Contains(rec.Body.String(), "<![CDATA[BEGIN:VCARD\r\nVERSION:4.0") {
+		t.Fatalf("unmatched client got quirks %v:\n%s", seen, rec.Body.String())
+	}
+
+	rec = serve("OldClient/3.1")
+	body := rec.Body.String()
+	if rec.Code != http.StatusMultiStatus || !seen.Has(quirks.MinimalProps) {
+		t.Fatalf("status = %d, quirks = %v", rec.Code, seen)
+	}
+	if strings.Contains(body, "CDATA") || !strings.Contains(body, "VERSION:3.0") || !strings.Contains(body, "FN:A &amp; B") {
+		t.Fatalf("rewritten body:\n%s", body)
+	}
+}
+
+func TestClientQuirksStripsOrAddsTimezones(t *testing.T) {
+	rules, err := quirks.Parse([]string{"NeedsZones=timezones"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.DAV.ClientQuirks = rules
+	h := NewServer(Options{Config: cfg})
+
+	berlin := "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nBEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n"
+	custom := "BEGIN:VTIMEZONE\r\nTZID:Office Time\r\nBEGIN:STANDARD\r\nDTSTART:19700101T000000\r\nTZOFFSETFROM:+0300\r\nTZOFFSETTO:+0300\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n"
+	event := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + berlin + custom +
+		"BEGIN:VEVENT\r\nUID:a\r\nDTSTART;TZID=Europe/Berlin:20300105T090000\r\nDTEND;TZID=\"America/New_York\":20300105T100000\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	serve := func(method, userAgent, preference string) string {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if method == http.MethodGet {
+				w.Header().Set("Content-Type", "text/calendar")
+				_, _ = w.Write([]byte(event))
+				return
+			}
+			writeMultiStatus(w, multistatus{Response: []response{
+				resourceResponse("/dav/calendars/1/a.ics", etagProp("e", event, true)),
+			}})
+		})
+		req := httptest.NewRequest(method, "/dav/calendars/1/a.ics", nil)
+		req.Header.Set("User-Agent", userAgent)
+		if preference != "" {
+			req.Header.Set("CalDAV-Timezones", preference)
+		}
+		rec := httptest.NewRecorder()
+		h.ClientQuirks(next).ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if body := serve(http.MethodGet, "Plain/1", ""); body != event {
+		t.Fatalf("plain client got a rewritten body:\n%s", body)
+	}
+	for _, method := range []string{http.MethodGet, "REPORT"} {
+		body := serve(method, "Plain/1", "F")
+		if strings.Contains(body, "TZID:Europe/Berlin") || !strings.Contains(body, "TZID:Office Time") || !strings.Contains(body, "DTSTART;TZID=Europe/Berlin:") {
+			t.Fatalf("%s with CalDAV-Timezones: F kept database zones or lost the custom one:\n%s", method, body)
+		}
+
+		body = serve(method, "NeedsZones/2", "")
+		if !strings.Contains(body, "TZID:America/New_York") || strings.Count(body, "TZID:Europe/Berlin") != 1 {
+			t.Fatalf("%s for a client needing zones did not add exactly the missing one:\n%s", method, body)
+		}
+		if strings.Index(body, "TZID:America/New_York") > strings.Index(body, "BEGIN:VEVENT") {
+			t.Fatalf("%s added the VTIMEZONE after the event using it:\n%s", method, body)
+		}
+	}
+	if body := serve(http.MethodGet, "NeedsZones/2", "F"); strings.Contains(body, "America/New_York\r\n") || strings.Contains(body, "TZID:Europe/Berlin") {
+		t.Fatalf("CalDAV-Timezones: F did not override the timezones quirk:\n%s", body)
+	}
+}
@@ -0,0 +1,83 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"strconv"
+
+	"github.com/jw6ventures/calcard/internal/quota"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+var (
+	// RFC 4331 quota properties.
+	propQuotaUsedBytes      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+	propQuotaAvailableBytes = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	// propQuotaLevel is "ok", "warning" from 80% of the quota, or "critical"
+	// from 95%, so clients can warn before writes start failing.
+	propQuotaLevel = xml.Name{Space: calcardNS, Local: "quota-level"}
+)
+
+// requestedQuotaProperties reports whether a PROPFIND names a quota
+// property. Like the sync statistics they are left out of allprop, as RFC
+// 4331 asks.
+func requestedQuotaProperties(req *propfindRequest) bool {
+	if req == nil || req.Prop == nil {
+		return false
+	}
+	for _, name := range req.Prop.CustomXML {
+		switch name {
+		case propQuotaUsedBytes, propQuotaAvailableBytes, propQuotaLevel:
+			return true
+		}
+	}
+	return false
+}
+
+// addQuotaProperties reports the user's storage quota on their calendar or
+// address book home and on the collections they own. Collections shared
+// with them count against their owner's quota, so carry none. Nothing is
+// reported when no quota is configured.
+func (h *Handler) addQuotaProperties(ctx context.Context, user *store.User, cleanPath string, responses []response, req *propfindRequest) error {
+	if user == nil || !requestedQuotaProperties(req) {
+		return nil
+	}
+	status, err := quota.Check(ctx, h.cfg, h.store, user.ID)
+	if err != nil || status == nil {
+		return err
+	}
+	for i := range responses {
+		if len(responses[i].Propstat) == 0 {
+			continue
+		}
+		if !(i == 0 && (cleanPath == "/dav/calendars" || cleanPath == "/dav/addressbooks")) {
+			owned, err := h.ownsResponseCollection(ctx, user, responses[i])
+			if err != nil {
+				return err
+			}
+			if !owned {
+				continue
+			}
+		}
+		p := &responses[i].Propstat[0].Prop
+		p.setCustomXMLProperty(XMLProperty{Name: propQuotaUsedBytes, Value: strconv.FormatInt(status.UsedBytes, 10)})
+		p.setCustomXMLProperty(XMLProperty{Name: propQuotaAvailableBytes, Value: strconv.FormatInt(status.AvailableBytes(), 10)})
+		p.setCustomXMLProperty(XMLProperty{Name: propQuotaLevel, Value: status.Level})
+	}
+	return nil
+}
+
+// ownsResponseCollection reports whether a response is a calendar or
+// address book the user owns.
+func (h *Handler) ownsResponseCollection(ctx context.Context, user *store.User, res response) (bool, error) {
+	collectionType, collectionID, ok := h.responseCollection(ctx, user, res)
+	if !ok {
+		return false, nil
+	}
+	if collectionType == "addressbook" {
+		book, err := h.store.AddressBooks.GetByID(ctx, collectionID)
+		return book != nil && book.UserID == user.ID, err
+	}
+	cal, err := h.store.Calendars.GetByID(ctx, collectionID)
+	return cal != nil && cal.UserID == user.ID, err
+}
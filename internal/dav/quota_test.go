@@ -0,0 +1,72 @@
+package dav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestPropfindReportsQuotaOnHomeAndOwnedCollections(t *testing.T) {
+	now := store.Now()
+	calRepo := storetest.NewCalendars(
+		store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: now}, Editor: true},
+		store.CalendarAccess{Calendar: store.Calendar{ID: 3, UserID: 9, Name: "Team", CTag: 1, UpdatedAt: now}, Shared: true},
+	)
+	cfg := &config.Config{}
+	cfg.Quota.UserBytes = 1000
+	h := &Handler{cfg: cfg, store: &store.Store{
+		Calendars: calRepo,
+		Events:    &storetest.Events{},
+		Quota:     &storetest.Quota{Used: map[int64]int64{1: 850}},
+	}}
+
+	body := `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:x="urn:calcard:dav"><d:prop>` +
+		`<d:quota-used-bytes/><d:quota-available-bytes/><x:quota-level/></d:prop></d:propfind>`
+	req := httptest.NewRequest("PROPFIND", "/dav/calendars/", strings.NewReader(body))
+	req.Header.Set("Depth", "1")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Propfind(rr, req)
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var ms struct {
+		Responses []struct {
+			Href  string `xml:"DAV: href"`
+			Inner string `xml:",innerxml"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &ms); err != nil {
+		t.Fatal(err)
+	}
+	reported := map[string]bool{}
+	for _, res := range ms.Responses {
+		ok := strings.Contains(res.Inner, ">850</") && strings.Contains(res.Inner, ">150</") && strings.Contains(res.Inner, ">warning</")
+		reported[strings.TrimSuffix(res.Href, "/")] = ok
+	}
+	if !reported["/dav/calendars"] || !reported["/dav/calendars/2"] {
+		t.Fatalf("expected quota on the home and the owned calendar, got %v in %s", reported, rr.Body.String())
+	}
+	if reported["/dav/calendars/3"] {
+		t.Fatalf("expected no quota on a calendar shared by another user, got %s", rr.Body.String())
+	}
+
+	// Without a quota nothing is reported.
+	h.cfg = &config.Config{}
+	req = httptest.NewRequest("PROPFIND", "/dav/calendars/2/", strings.NewReader(body))
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Propfind(rr, req)
+	if strings.Contains(rr.Body.String(), ">850<") {
+		t.Fatalf("expected no quota without APP_USER_QUOTA_MB, got %s", rr.Body.String())
+	}
+}
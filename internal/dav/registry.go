@@ -382,14 +382,13 @@ func isDefaultDAVPath(requestPath string) bool {
 }
 
 func isCoreReportName(reportName string) bool {
-	switch strings.TrimSpace(reportName) {
-	case "calendar-query", "calendar-multiget", "free-busy-query",
-		"addressbook-query", "addressbook-multiget",
-		"sync-collection", "expand-property":
-		return true
-	default:
-		return false
+	reportName = strings.TrimSpace(reportName)
+	for _, name := range coreReports {
+		if name == reportName {
+			return true
+		}
 	}
+	return false
 }
 
 func isCoreDAVMethod(method string) bool {
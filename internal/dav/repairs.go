@@ -0,0 +1,38 @@
+package dav
+
+import (
+	"net/http"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// repairWarningHeader carries each repair made to a PUT body, as
+// "<repair>: <description>", so the people behind a client can see what it
+// got wrong. It is not a standard header; clients are free to ignore it.
+const repairWarningHeader = "X-CalCard-Warning"
+
+// reportRepairs lists the repairs made to a stored body in the response
+// headers and counts them in the user's client data quality report.
+// Failures to record are logged and never fail the PUT.
+func (h *Handler) reportRepairs(w http.ResponseWriter, r *http.Request, user *store.User, resourcePath string, repairs []string) {
+	for _, repair := range repairs {
+		w.Header().Add(repairWarningHeader, repair+": "+utils.RepairDescriptions[repair])
+	}
+	if len(repairs) == 0 || h.store == nil || h.store.ClientRepairs == nil || user == nil {
+		return
+	}
+	deviceKey, appPasswordID := syncDeviceKey(r)
+	for _, repair := range repairs {
+		if err := h.store.ClientRepairs.Record(r.Context(), store.ClientRepair{
+			UserID:        user.ID,
+			DeviceKey:     deviceKey,
+			AppPasswordID: appPasswordID,
+			UserAgent:     r.UserAgent(),
+			Repair:        repair,
+			LastResource:  resourcePath,
+		}); err != nil {
+			h.logger().Warn("Put", "failed to record %s repair of %s: %v", repair, resourcePath, err)
+		}
+	}
+}
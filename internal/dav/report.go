@@ -2,6 +2,7 @@ package dav
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -21,10 +23,9 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 	}
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "missing user", http.StatusUnauthorized)
+		writeDAVError(w, http.StatusUnauthorized, "missing user")
 		return
 	}
-
 	cleanPath := path.Clean(r.URL.Path)
 	ensureCollectionHref := func(p string) string {
 		if !strings.HasSuffix(p, "/") {
@@ -35,24 +36,34 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 	body, err := readDAVBody(w, r, maxDAVBodyBytes)
 	if err != nil {
 		if errors.Is(err, errRequestTooLarge) {
-			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			writeDAVError(w, http.StatusRequestEntityTooLarge, "request too large")
 		} else {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "failed to read body")
 		}
 		return
 	}
 	var report reportRequest
 	if err := safeUnmarshalXML(body, &report); err != nil {
 		h.logger().Error("Report", "invalid REPORT body for %s: %v", cleanPath, err)
-		http.Error(w, "invalid REPORT body", http.StatusBadRequest)
+		writeDAVError(w, http.StatusBadRequest, "invalid REPORT body")
 		return
 	}
 	h.logger().Trace("Report", "REPORT %s type=%s", cleanPath, report.XMLName.Local)
+	if limitedReports[report.XMLName.Local] {
+		release, ok := h.limits.acquireReport(r.Context(), user.ID)
+		if !ok {
+			metrics.IncReportConcurrencyRejected(r)
+			w.Header().Set("Retry-After", "5")
+			writeDAVError(w, http.StatusServiceUnavailable, "too many concurrent reports for this account")
+			return
+		}
+		defer release()
+	}
 	var expandReq *expandPropertyRequest
 	if report.XMLName.Local == "expand-property" {
 		expandReq, err = parseExpandPropertyRequest(body)
 		if err != nil {
-			http.Error(w, "invalid REPORT body", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid REPORT body")
 			return
 		}
 	}
@@ -70,20 +81,38 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Multiget names its targets by href, so a multiget addressed at a calendar
+	// object (some clients re-read a resource this way after PUT) is answered
+	// from the object's collection.
+	if report.XMLName.Local == "calendar-multiget" {
+		if _, _, ok := parseCalendarResourceSegments(cleanPath); ok {
+			cleanPath = path.Dir(cleanPath)
+		}
+	}
+
+	if cleanPath == "/dav/calendars" && (report.XMLName.Local == "calendar-query" || report.XMLName.Local == "calendar-multiget") {
+		h.calendarHomeReport(w, r, user, report)
+		return
+	}
+	if (cleanPath == "/dav/calendars" || cleanPath == "/dav/addressbooks") && report.XMLName.Local == "sync-collection" {
+		h.homeSyncCollection(w, r, user, cleanPath, report)
+		return
+	}
+
 	if report.XMLName.Local == "calendar-query" || report.XMLName.Local == "calendar-multiget" {
 		if _, _, ok := parseCalendarResourceSegments(cleanPath); ok {
-			http.Error(w, "calendar reports not allowed on calendar object resources", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "calendar reports not allowed on calendar object resources", condSupportedReport)
 			return
 		}
 		if !strings.HasPrefix(cleanPath, "/dav/calendars/") {
-			http.Error(w, "calendar reports must target a calendar collection", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "calendar reports must target a calendar collection", condSupportedReport)
 			return
 		}
 	}
 
 	if report.XMLName.Local == "free-busy-query" {
 		if _, _, ok := parseCalendarResourceSegments(cleanPath); ok {
-			http.Error(w, "free-busy-query not allowed on calendar object resources", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "free-busy-query not allowed on calendar object resources", condSupportedReport)
 			return
 		}
 	}
@@ -91,32 +120,32 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 	if strings.HasPrefix(cleanPath, "/dav/calendars/") {
 		// Reject REPORT requests on resource paths (only allow on collection)
 		if _, _, isResource := parseCalendarResourceSegments(cleanPath); isResource {
-			http.Error(w, "REPORT not allowed on calendar object resources", http.StatusForbidden)
+			writeDAVError(w, http.StatusForbidden, "REPORT not allowed on calendar object resources", condSupportedReport)
 			return
 		}
 
 		rel := strings.Trim(strings.TrimPrefix(cleanPath, "/dav/calendars"), "/")
 		parts := strings.Split(rel, "/")
 		if len(parts) < 1 || strings.TrimSpace(parts[0]) == "" {
-			http.Error(w, "invalid calendar path", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid calendar path")
 			return
 		}
 		calID, ok, err := h.resolveCalendarID(r.Context(), user, strings.TrimSpace(parts[0]))
 		if err != nil {
 			if errors.Is(err, errAmbiguousCalendar) {
-				http.Error(w, "ambiguous calendar path", http.StatusConflict)
+				writeDAVError(w, http.StatusConflict, "ambiguous calendar path")
 				return
 			}
 			if err == store.ErrNotFound {
-				http.Error(w, "calendar not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "calendar not found")
 				return
 			}
 			h.logger().Error("Report", "failed to resolve calendar for %s: %v", cleanPath, err)
-			http.Error(w, "failed to resolve calendar", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to resolve calendar")
 			return
 		}
 		if !ok {
-			http.Error(w, "invalid calendar id", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid calendar id")
 			return
 		}
 
@@ -150,7 +179,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			if report.XMLName.Local == "free-busy-query" {
 				events, err := h.generateBirthdayEvents(r.Context(), user.ID)
 				if err != nil {
-					http.Error(w, "failed to generate birthday events", http.StatusInternalServerError)
+					writeDAVError(w, http.StatusInternalServerError, "failed to generate birthday events")
 					return
 				}
 				if report.Filter != nil {
@@ -165,7 +194,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 
 			responses, syncToken, err := h.birthdayCalendarReportResponses(r.Context(), user, h.principalURL(user), cleanPath, report)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, err.Error())
 				return
 			}
 			payload := multistatus{
@@ -198,7 +227,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			if errors.Is(err, errForbidden) {
 				status = http.StatusForbidden
 			}
-			http.Error(w, "calendar not found", status)
+			writeDAVError(w, status, "calendar not found")
 			return
 		}
 		canonicalPath := path.Join("/dav/calendars", fmt.Sprint(cal.ID))
@@ -208,7 +237,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			ctag := fmt.Sprintf("%d", cal.CTag)
 			syncToken := buildSyncToken("cal", cal.ID, cal.UpdatedAt)
 			responses := []response{
-				calendarCollectionResponseWithPrivileges(href, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, ctag, cal.EffectivePrivileges()),
+				withCalendarOwner(calendarCollectionResponseWithPrivileges(href, cal.Name, cal.Description, cal.Timezone, cal.Color, principalHref, syncToken, ctag, cal.EffectivePrivileges()), user, *cal),
 				principalResponse(ensureCollectionHref(principalHref), user),
 			}
 			payload := multistatus{
@@ -228,7 +257,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 		if report.XMLName.Local == "free-busy-query" {
 			responses, err := h.freeBusyQuery(r.Context(), user, cal, canonicalPath, report.Filter)
 			if err != nil {
-				http.Error(w, "failed to list events", http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, "failed to list events")
 				return
 			}
 			freeBusyData := ""
@@ -240,15 +269,24 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			_, _ = w.Write([]byte(freeBusyData))
 			return
 		}
+		if report.XMLName.Local == "sync-collection" && strings.TrimSpace(report.SyncToken) == "" && h.limits.noteFullResync(user.ID) {
+			metrics.IncFullResyncThrottled(r, "calendar")
+			r = r.WithContext(withSyncPaging(r.Context()))
+		}
 		responses, syncToken, err := h.calendarReportResponses(r.Context(), user, cal, h.principalURL(user), cleanPath, canonicalPath, report)
 		if err != nil {
 			if errors.Is(err, errInvalidSyncToken) {
-				http.Error(w, "invalid sync token", http.StatusForbidden)
+				metrics.IncInvalidSyncToken(r, "calendar")
+				writeDAVError(w, http.StatusForbidden, "invalid sync token", condValidSyncToken)
 			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, err.Error())
 			}
 			return
 		}
+		if report.XMLName.Local == "sync-collection" {
+			metrics.ObserveSyncCollection(r, "calendar", report.SyncToken == "", syncMemberCount(responses))
+		}
+		h.recordCollectionSync(r, user, "calendar", cal.ID)
 
 		payload := multistatus{
 			XMLName:   xml.Name{Space: "DAV:", Local: "multistatus"},
@@ -269,16 +307,16 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 	if strings.HasPrefix(cleanPath, "/dav/addressbooks/") {
 		_, hasDepth := r.Header["Depth"]
 		if report.XMLName.Local == "addressbook-query" && report.CardFilter == nil {
-			http.Error(w, "filter required", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "filter required", condAddressBookValidFilter)
 			return
 		}
 		if report.XMLName.Local == "addressbook-multiget" {
 			if !hasDepth || strings.TrimSpace(r.Header.Get("Depth")) != "0" {
-				http.Error(w, "Depth: 0 required", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "Depth: 0 required")
 				return
 			}
 			if len(report.Hrefs) == 0 {
-				http.Error(w, "href required", http.StatusBadRequest)
+				writeDAVError(w, http.StatusBadRequest, "href required")
 				return
 			}
 		}
@@ -306,28 +344,28 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 		trimmed := strings.Trim(strings.TrimPrefix(cleanPath, "/dav/addressbooks"), "/")
 		parts := strings.Split(trimmed, "/")
 		if len(parts) == 0 || strings.TrimSpace(parts[0]) == "" {
-			http.Error(w, "invalid address book path", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid address book path")
 			return
 		}
 		bookID, ok, err := h.resolveAddressBookID(r.Context(), user, strings.TrimSpace(parts[0]))
 		if err != nil {
 			if errors.Is(err, errAmbiguousAddressBook) {
-				http.Error(w, "ambiguous address book path", http.StatusConflict)
+				writeDAVError(w, http.StatusConflict, "ambiguous address book path")
 				return
 			}
 			if errors.Is(err, store.ErrNotFound) {
-				http.Error(w, "address book not found", http.StatusNotFound)
+				writeDAVError(w, http.StatusNotFound, "address book not found")
 				return
 			}
-			http.Error(w, "failed to resolve address book", http.StatusInternalServerError)
+			writeDAVError(w, http.StatusInternalServerError, "failed to resolve address book")
 			return
 		}
 		if !ok {
-			http.Error(w, "invalid address book id", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid address book id")
 			return
 		}
 		if len(parts) > 2 {
-			http.Error(w, "invalid address book path", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "invalid address book path")
 			return
 		}
 		isResource := len(parts) == 2 && parts[1] != ""
@@ -335,16 +373,16 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			switch report.XMLName.Local {
 			case "addressbook-query", "addressbook-multiget", "expand-property":
 				if !hasDepth {
-					http.Error(w, "REPORT not allowed on address book object resources", http.StatusForbidden)
+					writeDAVError(w, http.StatusForbidden, "REPORT not allowed on address book object resources", condSupportedReport)
 					return
 				}
 			default:
-				http.Error(w, "REPORT not allowed on address book object resources", http.StatusForbidden)
+				writeDAVError(w, http.StatusForbidden, "REPORT not allowed on address book object resources", condSupportedReport)
 				return
 			}
 		}
 		if report.XMLName.Local == "addressbook-query" && !hasDepth {
-			http.Error(w, "Depth header required", http.StatusBadRequest)
+			writeDAVError(w, http.StatusBadRequest, "Depth header required")
 			return
 		}
 
@@ -357,7 +395,7 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 			if err == store.ErrNotFound {
 				status = http.StatusNotFound
 			}
-			http.Error(w, "address book not found", status)
+			writeDAVError(w, status, "address book not found")
 			return
 		}
 		// Depth:0 on a collection for addressbook-query means only the collection
@@ -380,12 +418,17 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 		responses, syncToken, err := h.addressBookReportResponses(r.Context(), user, book, h.principalURL(user), cleanPath, report, expandReq)
 		if err != nil {
 			if errors.Is(err, errInvalidSyncToken) {
-				http.Error(w, "invalid sync token", http.StatusForbidden)
+				metrics.IncInvalidSyncToken(r, "addressbook")
+				writeDAVError(w, http.StatusForbidden, "invalid sync token", condValidSyncToken)
 			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeDAVError(w, http.StatusInternalServerError, err.Error())
 			}
 			return
 		}
+		if report.XMLName.Local == "sync-collection" {
+			metrics.ObserveSyncCollection(r, "addressbook", report.SyncToken == "", syncMemberCount(responses))
+		}
+		h.recordCollectionSync(r, user, "addressbook", book.ID)
 
 		payload := multistatus{
 			XMLName:   xml.Name{Space: "DAV:", Local: "multistatus"},
@@ -431,5 +474,149 @@ func (h *Handler) Report(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Error(w, "unsupported REPORT path", http.StatusBadRequest)
+	writeDAVError(w, http.StatusBadRequest, "unsupported REPORT path")
+}
+
+// syncMemberCount excludes the collection's own entry from a sync-collection response.
+func syncMemberCount(responses []response) int {
+	if len(responses) == 0 {
+		return 0
+	}
+	return len(responses) - 1
+}
+
+// calendarHomeReport answers calendar-query and calendar-multiget addressed at
+// the calendar home by fanning out across every readable calendar
+// (RFC 4791 §7.8 and §7.9 allow targeting ordinary collections).
+func (h *Handler) calendarHomeReport(w http.ResponseWriter, r *http.Request, user *store.User, report reportRequest) {
+	var (
+		responses []response
+		err       error
+	)
+	if report.XMLName.Local == "calendar-multiget" {
+		if len(report.Hrefs) == 0 {
+			writeDAVError(w, http.StatusBadRequest, "href required")
+			return
+		}
+		responses, err = h.calendarHomeMultiGet(r.Context(), user, report)
+	} else {
+		depth := strings.ToLower(strings.TrimSpace(r.Header.Get("Depth")))
+		// Depth defaults to 0 for REPORT; the home itself holds no calendar data.
+		if depth == "1" || depth == "infinity" {
+			responses, err = h.calendarHomeQuery(r.Context(), user, report)
+		}
+	}
+	if err != nil {
+		h.logger().Error("Report", "calendar home %s failed: %v", report.XMLName.Local, err)
+		writeDAVError(w, http.StatusInternalServerError, "failed to list events")
+		return
+	}
+
+	payload := multistatus{
+		XMLName:   xml.Name{Space: "DAV:", Local: "multistatus"},
+		XmlnsD:    "DAV:",
+		XmlnsC:    "urn:ietf:params:xml:ns:caldav",
+		XmlnsA:    "urn:ietf:params:xml:ns:carddav",
+		XmlnsCS:   "http://calendarserver.org/ns/",
+		XmlnsICAL: "http://apple.com/ns/ical/",
+		Response:  responses,
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_ = xml.NewEncoder(w).Encode(payload)
+}
+
+func (h *Handler) calendarHomeQuery(ctx context.Context, user *store.User, report reportRequest) ([]response, error) {
+	calData := reportCalendarData(report)
+	birthdayPath := path.Join("/dav/calendars", fmt.Sprint(birthdayCalendarID))
+	responses, _, err := h.birthdayCalendarReportResponses(ctx, user, h.principalURL(user), birthdayPath, report)
+	if err != nil {
+		return nil, err
+	}
+	cals, err := h.accessibleCalendars(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cals {
+		calPath := path.Join("/dav/calendars", fmt.Sprint(c.ID))
+		cal, err := h.loadCalendarWithPrivilege(ctx, user, c.ID, calPath, "read")
+		if err != nil {
+			if errors.Is(err, errForbidden) || errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		res, err := h.calendarQuery(ctx, user, cal, calPath, report.Filter, calData)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res...)
+	}
+	return responses, nil
+}
+
+func (h *Handler) calendarHomeMultiGet(ctx context.Context, user *store.User, report reportRequest) ([]response, error) {
+	calData := reportCalendarData(report)
+	var segments []string
+	hrefsBySegment := map[string][]string{}
+	var responses []response
+	for _, href := range report.Hrefs {
+		cleanHref := resolveDAVHref("/dav/calendars/", href)
+		segment, _, ok := parseCalendarResourceSegments(cleanHref)
+		if !ok {
+			if cleanHref != "" {
+				responses = append(responses, response{Href: cleanHref, Status: httpStatusNotFound})
+			}
+			continue
+		}
+		if _, seen := hrefsBySegment[segment]; !seen {
+			segments = append(segments, segment)
+		}
+		hrefsBySegment[segment] = append(hrefsBySegment[segment], cleanHref)
+	}
+
+	for _, segment := range segments {
+		hrefs := hrefsBySegment[segment]
+		calID, ok, err := h.resolveCalendarID(ctx, user, segment)
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, errAmbiguousCalendar) {
+			return nil, err
+		}
+		if err != nil || !ok {
+			responses = append(responses, notFoundResponses(hrefs)...)
+			continue
+		}
+		calPath := path.Join("/dav/calendars", fmt.Sprint(calID))
+		if calID == birthdayCalendarID {
+			sub := report
+			sub.Hrefs = hrefs
+			res, _, err := h.birthdayCalendarReportResponses(ctx, user, h.principalURL(user), calPath, sub)
+			if err != nil {
+				return nil, err
+			}
+			responses = append(responses, res...)
+			continue
+		}
+		cal, err := h.loadCalendarWithPrivilege(ctx, user, calID, calPath, "read")
+		if err != nil {
+			if errors.Is(err, errForbidden) || errors.Is(err, store.ErrNotFound) {
+				responses = append(responses, notFoundResponses(hrefs)...)
+				continue
+			}
+			return nil, err
+		}
+		res, err := h.calendarMultiGet(ctx, user, cal, hrefs, "/dav/calendars/", calPath, calData)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, res...)
+	}
+	return responses, nil
+}
+
+func notFoundResponses(hrefs []string) []response {
+	responses := make([]response, 0, len(hrefs))
+	for _, href := range hrefs {
+		responses = append(responses, response{Href: href, Status: httpStatusNotFound})
+	}
+	return responses
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 	"github.com/jw6ventures/calcard/internal/util"
 )
 
@@ -44,12 +45,12 @@ func TestRFC4791_OptionsAdvertisesCalendarAccess(t *testing.T) {
 // Section 5.2: Calendar Collection Properties
 func TestRFC4791_CalendarCollectionMustHaveResourceType(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "test@example.com"}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/", nil)
@@ -72,12 +73,12 @@ func TestRFC4791_CalendarCollectionMustHaveResourceType(t *testing.T) {
 // Section 5.2.3: supported-calendar-component-set Property
 func TestRFC4791_SupportedCalendarComponentSetRequired(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/", nil)
@@ -101,13 +102,13 @@ func TestRFC4791_SupportedCalendarComponentSetRequired(t *testing.T) {
 
 // Section 5.2.4: supported-calendar-data Property
 func TestRFC4791_CalendarDataContentTypeTextCalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "e"},
 		},
 	}
@@ -129,7 +130,7 @@ func TestRFC4791_CalendarDataContentTypeTextCalendar(t *testing.T) {
 
 // Section 5.3.1: MKCALENDAR Method
 func TestRFC4791_MkcalendarCreatesCalendarCollection(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -146,7 +147,7 @@ func TestRFC4791_MkcalendarCreatesCalendarCollection(t *testing.T) {
 }
 
 func TestRFC4791_MKCALENDAR_ResourcetypeIsCollectionAndCalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -183,7 +184,7 @@ func TestRFC4791_MKCALENDAR_ResourcetypeIsCollectionAndCalendar(t *testing.T) {
 }
 
 func TestRFC4791_MKCALENDAR_BodySetsPropertiesOrReturns207WithPropstat(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -249,7 +250,7 @@ END:VTIMEZONE</cal:calendar-timezone>
 func TestRFC4791_MkcalendarOnExistingResourceFails(t *testing.T) {
 	// Note: This test verifies expected behavior even though our implementation
 	// doesn't fully track duplicates. A stricter implementation would return 405.
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -277,12 +278,12 @@ func TestRFC4791_MkcalendarOnExistingResourceFails(t *testing.T) {
 
 // Section 5.3.2.1: PUT Preconditions - If-None-Match
 func TestRFC4791_PutWithIfNoneMatchStarCreatesNew(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -302,13 +303,13 @@ func TestRFC4791_PutWithIfNoneMatchStarCreatesNew(t *testing.T) {
 
 // Section 5.3.2.1: PUT Preconditions - If-Match
 func TestRFC4791_PutWithIfMatchRequiresMatchingETag(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:existing": {CalendarID: 1, UID: "existing", RawICAL: "OLD", ETag: "correct-etag"},
 		},
 	}
@@ -331,13 +332,13 @@ func TestRFC4791_PutWithIfMatchRequiresMatchingETag(t *testing.T) {
 
 // Section 5.3.2.1: PUT Preconditions - If-Match success path
 func TestRFC4791_PutWithIfMatchSucceedsAndUpdatesETag(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:existing": {CalendarID: 1, UID: "existing", RawICAL: "OLD", ETag: "old-etag"},
 		},
 	}
@@ -366,12 +367,12 @@ func TestRFC4791_PutWithIfMatchSucceedsAndUpdatesETag(t *testing.T) {
 
 // Section 5.3.4: ETag behavior on semantically identical updates (content-based policy)
 func TestRFC4791_PutIdenticalBodyKeepsETag(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -411,12 +412,12 @@ func TestRFC4791_PutIdenticalBodyKeepsETag(t *testing.T) {
 
 // Section 5.3.2.1: PUT Preconditions - If-Match on missing resource
 func TestRFC4791_PutWithIfMatchOnMissingResourceFails(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -435,12 +436,12 @@ func TestRFC4791_PutWithIfMatchOnMissingResourceFails(t *testing.T) {
 
 // Section 5.3.4: Calendar Object Resource Entity Tag
 func TestRFC4791_PutReturnsETagHeader(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -514,13 +515,13 @@ func TestRFC4791_PrincipalCalendarHomeSetNotInAllprop(t *testing.T) {
 // This is the primary way CalDAV clients discover available calendars
 func TestRFC4791_CalendarHomeListsCalendars(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work", UpdatedAt: now, CTag: 10}, Editor: true},
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Personal", UpdatedAt: now, CTag: 20}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	// RFC 4791 Section 6.2.1: PROPFIND on calendar-home-set with Depth: 1 lists calendars
@@ -565,10 +566,10 @@ func TestRFC4791_CalendarHomeListsCalendars(t *testing.T) {
 
 // Test that empty calendar-home-set returns no calendars (not an error)
 func TestRFC4791_EmptyCalendarHomeReturnsNoCalendars(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{}, // No calendars
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{}, // No calendars
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
@@ -600,8 +601,8 @@ func TestRFC4791_EmptyCalendarHomeReturnsNoCalendars(t *testing.T) {
 // RFC 4791 Section 5.2: Calendar Collection Properties Required for Client Compatibility
 func TestRFC4791_CalendarCollectionHasRequiredProperties(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{
 				ID:          1,
 				UserID:      1,
@@ -612,7 +613,7 @@ func TestRFC4791_CalendarCollectionHasRequiredProperties(t *testing.T) {
 			}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// Request common properties that clients need
@@ -659,13 +660,13 @@ func TestRFC4791_CalendarCollectionHasRequiredProperties(t *testing.T) {
 // Test Depth: 0 on calendar home (should only return the home collection, not the calendars)
 func TestRFC4791_CalendarHomeDepthZero(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work", UpdatedAt: now}, Editor: true},
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Personal", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
@@ -693,13 +694,13 @@ func TestRFC4791_CalendarHomeDepthZero(t *testing.T) {
 // This tests the full discovery sequence per RFC 4791 that CalDAV clients use
 func TestRFC4791_CalendarDiscoverySequence(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work", UpdatedAt: now, CTag: 10}, Editor: true},
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Home", UpdatedAt: now, CTag: 20}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	// Step 1: PROPFIND on root to get current-user-principal (RFC 5397)
@@ -828,8 +829,8 @@ func TestRFC4791_CalendarDiscoverySequence(t *testing.T) {
 // RFC 4791: Verify shared calendars (read-only) are discoverable
 func TestRFC4791_SharedCalendarsAreDiscoverable(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "My Calendar", UpdatedAt: now}, Editor: true},
 			{Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Shared With Me", UpdatedAt: now}, Editor: false}, // Read-only
 		},
@@ -837,7 +838,7 @@ func TestRFC4791_SharedCalendarsAreDiscoverable(t *testing.T) {
 	aclRepo := &fakeACLRepo{entries: []store.ACLEntry{
 		{ResourcePath: "/dav/calendars/2", PrincipalHref: "/dav/principals/1/", IsGrant: true, Privilege: "read"},
 	}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}, ACLEntries: aclRepo}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, ACLEntries: aclRepo}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/", nil)
@@ -867,12 +868,12 @@ func TestRFC4791_SharedCalendarsAreDiscoverable(t *testing.T) {
 // Section 7.1: DAV:expand-property REPORT
 func TestRFC4791_ExpandPropertyReportWorks(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -907,13 +908,13 @@ func TestRFC4791_ExpandPropertyReportWorks(t *testing.T) {
 
 // Section 7.8: calendar-query REPORT
 func TestRFC4791_CalendarQueryReportBasic(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e"},
 		},
 	}
@@ -1003,13 +1004,13 @@ func TestRFC4791_TimeRangeFilteringAccuracy(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			calRepo := &fakeCalendarRepo{
-				accessible: []store.CalendarAccess{
+			calRepo := &storetest.Calendars{
+				Accessible: []store.CalendarAccess{
 					{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 				},
 			}
-			eventRepo := &fakeEventRepo{
-				events: map[string]*store.Event{
+			eventRepo := &storetest.Events{
+				Events: map[string]*store.Event{
 					"1:test": {
 						CalendarID: 1,
 						UID:        "test",
@@ -1052,13 +1053,13 @@ func TestRFC4791_TimeRangeFilteringAccuracy(t *testing.T) {
 
 // Section 7.9: calendar-multiget REPORT
 func TestRFC4791_CalendarMultigetReport(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "event1", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e1"},
 			"1:event2": {CalendarID: 1, UID: "event2", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event2\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e2"},
 		},
@@ -1105,13 +1106,13 @@ func TestRFC4791_CalendarMultigetReport(t *testing.T) {
 }
 
 func TestRFC4791_CalendarQuery_OnNonCalendarCollection_Fails(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e"},
 		},
 	}
@@ -1148,13 +1149,13 @@ func TestRFC4791_CalendarQuery_OnNonCalendarCollection_Fails(t *testing.T) {
 }
 
 func TestRFC4791_CalendarMultiget_OnNonCalendarCollection_Fails(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e"},
 		},
 	}
@@ -1173,8 +1174,9 @@ func TestRFC4791_CalendarMultiget_OnNonCalendarCollection_Fails(t *testing.T) {
 	req = req.WithContext(auth.WithUser(req.Context(), user))
 	rr := httptest.NewRecorder()
 	h.Report(rr, req)
-	if rr.Code == http.StatusMultiStatus || rr.Code == http.StatusOK {
-		t.Errorf("calendar-multiget on calendar object must not return success-like response, got %d", rr.Code)
+	// Section 7.9: the Request-URI may also be a calendar object resource.
+	if rr.Code != http.StatusMultiStatus || !strings.Contains(rr.Body.String(), "UID:event") {
+		t.Errorf("calendar-multiget on calendar object should be answered from its collection, got %d: %s", rr.Code, rr.Body.String())
 	}
 
 	req = httptest.NewRequest("REPORT", "/dav/", strings.NewReader(body))
@@ -1191,13 +1193,13 @@ func TestRFC4791_FreeBusyQueryReport(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -1257,12 +1259,12 @@ func TestRFC4791_FreeBusyQueryReport(t *testing.T) {
 
 // Section 7.10: free-busy-query with no matching events returns empty VFREEBUSY
 func TestRFC4791_FreeBusyQueryNoMatches(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -1296,12 +1298,12 @@ func TestRFC4791_FreeBusyQueryNoMatches(t *testing.T) {
 
 // Section 7.10: free-busy-query on calendar object resource must be forbidden
 func TestRFC4791_FreeBusyQueryOnCalendarObjectForbidden(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -1328,8 +1330,8 @@ func TestRFC4791_FreeBusyQueryOnCalendarObjectForbidden(t *testing.T) {
 
 // Section 7.10: free-busy-query without privileges must return 404
 func TestRFC4791_FreeBusyQueryUnauthorizedReturnsNotFound(t *testing.T) {
-	calRepo := &fakeCalendarRepo{accessible: []store.CalendarAccess{}}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8" ?>
@@ -1379,13 +1381,13 @@ func TestRFC4791_XMLNamespacesCorrect(t *testing.T) {
 }
 
 func TestRFC4791_ReportCalendarData_ReturnsValidICalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -1427,13 +1429,13 @@ func TestRFC4791_ReportCalendarData_ReturnsValidICalendar(t *testing.T) {
 func TestRFC4791_CalendarQuery_CalendarDataComponentFiltering_Works(t *testing.T) {
 	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -1487,13 +1489,13 @@ func TestRFC4791_CalendarQuery_CalendarDataComponentFiltering_Works(t *testing.T
 
 // Test ETag format compliance (RFC 4791 Section 5.3.4)
 func TestRFC4791_ETagFormatCompliance(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "abc123"},
 		},
 	}
@@ -1526,13 +1528,13 @@ func TestRFC4791_ETagFormatCompliance(t *testing.T) {
 // Test PROPFIND Depth header handling (RFC 4918, referenced by RFC 4791)
 func TestRFC4791_PropfindDepthHeaderHandling(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "e", LastModified: now},
 		},
 	}
@@ -1579,17 +1581,17 @@ func TestRFC4791_PropfindDepthHeaderHandling(t *testing.T) {
 // Test sync-collection REPORT (WebDAV Sync, commonly used by CalDAV clients)
 func TestRFC4791_SyncCollectionReport(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", CTag: 5, UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "e", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{}
+	deletedRepo := &storetest.DeletedResources{}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo, DeletedResources: deletedRepo}}
 	user := &store.User{ID: 1}
 
@@ -1635,12 +1637,12 @@ func TestRFC4791_SyncCollectionReport(t *testing.T) {
 
 // Test invalid calendar data rejection
 func TestRFC4791_RejectMalformedICalendar(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	tests := []struct {
@@ -1684,12 +1686,12 @@ func TestRFC4791_RejectMalformedICalendar(t *testing.T) {
 // Test supported-report-set property
 func TestRFC4791_SupportedReportSetProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/", nil)
@@ -1729,13 +1731,13 @@ func TestRFC4791_SupportedReportSetProperty(t *testing.T) {
 
 func TestRFC4791_SupportedReportSetOnCalendarObjectResource(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:event\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ETag: "e"},
 		},
 	}
@@ -1768,13 +1770,13 @@ func TestRFC4791_SupportedReportSetOnCalendarObjectResource(t *testing.T) {
 func TestRFC4791_SupportedReportSet_AdvertisedReportsActuallyWork(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: store.Now()}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -1873,7 +1875,7 @@ func TestRFC4791_SupportedReportSet_AdvertisedReportsActuallyWork(t *testing.T)
 
 // Test that calendar collections cannot contain other calendar collections
 func TestRFC4791_NoNestedCalendarCollections(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -1893,12 +1895,12 @@ func TestRFC4791_NoNestedCalendarCollections(t *testing.T) {
 
 // Test Content-Type validation for PUT
 func TestRFC4791_PutContentTypeValidation(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	validIcal := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:test\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -1944,12 +1946,12 @@ func TestRFC4791_CurrentUserPrincipalProperty(t *testing.T) {
 
 // Test VTODO support in calendar collections
 func TestRFC4791_VTODOComponentSupport(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -1968,12 +1970,12 @@ func TestRFC4791_VTODOComponentSupport(t *testing.T) {
 
 // Test VJOURNAL support in calendar collections
 func TestRFC4791_VJOURNALComponentSupport(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -1992,12 +1994,12 @@ func TestRFC4791_VJOURNALComponentSupport(t *testing.T) {
 
 // Section 4.1: UID Property - Must be consistent with filename
 func TestRFC4791_UIDMustMatchResourceName(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -2021,12 +2023,12 @@ func TestRFC4791_UIDMustMatchResourceName(t *testing.T) {
 // Section 5.2.1: calendar-description Property
 func TestRFC4791_CalendarDescriptionProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "My Calendar", Description: util.StrPtr("Personal events"), UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2052,12 +2054,12 @@ func TestRFC4791_CalendarDescriptionProperty(t *testing.T) {
 
 func TestRFC4791_CalendarCollection_Propfind_CalendarTimezone_PresentAndValid(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2089,12 +2091,12 @@ func TestRFC4791_CalendarCollection_Propfind_CalendarTimezone_PresentAndValid(t
 
 func TestRFC4791_CalendarCollection_CalendarTimezone_NotInAllprop(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/", nil)
@@ -2111,12 +2113,12 @@ func TestRFC4791_CalendarCollection_CalendarTimezone_NotInAllprop(t *testing.T)
 
 func TestRFC4791_CalendarCollection_Propfind_SupportedCalendarData_Advertised(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2148,12 +2150,12 @@ func TestRFC4791_CalendarCollection_Propfind_SupportedCalendarData_Advertised(t
 
 func TestRFC4791_CalendarCollection_SupportedCalendarData_NotInAllprop(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	req := httptest.NewRequest("PROPFIND", "/dav/calendars/1/", nil)
@@ -2170,12 +2172,12 @@ func TestRFC4791_CalendarCollection_SupportedCalendarData_NotInAllprop(t *testin
 
 func TestRFC4791_CalendarCollection_Propfind_MaxResourceSize_AdvertisedOrExplicit404(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2207,12 +2209,12 @@ func TestRFC4791_CalendarCollection_Propfind_MaxResourceSize_AdvertisedOrExplici
 
 func TestRFC4791_CalendarCollection_Propfind_MinMaxDateTime_AdvertisedOrExplicit404(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2257,12 +2259,12 @@ func TestRFC4791_CalendarCollection_Propfind_MinMaxDateTime_AdvertisedOrExplicit
 // Section 5.2.2: calendar-timezone Property
 func TestRFC4791_CalendarTimezoneProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2293,12 +2295,12 @@ func TestRFC4791_CalendarTimezoneProperty(t *testing.T) {
 // Section 5.2.5: max-resource-size Property
 func TestRFC4791_MaxResourceSizeProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2325,12 +2327,12 @@ func TestRFC4791_MaxResourceSizeProperty(t *testing.T) {
 // Section 5.2.6 & 5.2.7: min-date-time and max-date-time Properties
 func TestRFC4791_DateTimeRangeLimitsProperties(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2360,12 +2362,12 @@ func TestRFC4791_DateTimeRangeLimitsProperties(t *testing.T) {
 
 func TestRFC4791_Precondition_SupportedCalendarData_RejectUnsupportedMediaType(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -2402,12 +2404,12 @@ func TestRFC4791_Precondition_SupportedCalendarData_RejectUnsupportedMediaType(t
 
 func TestRFC4791_Precondition_MaxResourceSize_RejectTooLargeObject(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -2458,12 +2460,12 @@ func TestRFC4791_Precondition_MaxResourceSize_RejectTooLargeObject(t *testing.T)
 
 func TestRFC4791_Precondition_MinDateTime_RejectEarlierDates(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -2507,12 +2509,12 @@ func TestRFC4791_Precondition_MinDateTime_RejectEarlierDates(t *testing.T) {
 
 func TestRFC4791_Precondition_MinDateTime_RejectEarlierDatesWithTZID(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	loc, err := time.LoadLocation("America/New_York")
@@ -2561,12 +2563,12 @@ func TestRFC4791_Precondition_MinDateTime_RejectEarlierDatesWithTZID(t *testing.
 
 func TestRFC4791_Precondition_MaxDateTime_RejectLaterDates(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -2610,12 +2612,12 @@ func TestRFC4791_Precondition_MaxDateTime_RejectLaterDates(t *testing.T) {
 
 func TestRFC4791_Precondition_MaxDateTime_RejectLaterDatesWithOffset(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -2660,12 +2662,12 @@ func TestRFC4791_Precondition_MaxDateTime_RejectLaterDatesWithOffset(t *testing.
 
 // Section 5.2.6: min-date-time Precondition
 func TestRFC4791_PutBeforeMinDateTimeRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:too-old\r\nDTSTART:18000101T000000Z\r\nDTEND:18000101T010000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -2683,12 +2685,12 @@ func TestRFC4791_PutBeforeMinDateTimeRejected(t *testing.T) {
 
 // Section 5.2.7: max-date-time Precondition
 func TestRFC4791_PutAfterMaxDateTimeRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:too-far\r\nDTSTART:22000101T000000Z\r\nDTEND:22000101T010000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -2706,13 +2708,13 @@ func TestRFC4791_PutAfterMaxDateTimeRejected(t *testing.T) {
 
 // Section 5.3.3: DELETE Method
 func TestRFC4791_DeleteCalendarObject(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:to-delete": {CalendarID: 1, UID: "to-delete", RawICAL: "ICAL", ETag: "etag1"},
 		},
 	}
@@ -2731,7 +2733,7 @@ func TestRFC4791_DeleteCalendarObject(t *testing.T) {
 	}
 
 	// Verify deletion
-	_, exists := eventRepo.events["1:to-delete"]
+	_, exists := eventRepo.Events["1:to-delete"]
 	if exists {
 		t.Error("RFC 4791 Section 5.3.3: Event should be deleted")
 	}
@@ -2748,13 +2750,13 @@ func TestRFC4791_DeleteCalendarObject(t *testing.T) {
 
 // Section 5.3.3: DELETE with If-Match precondition
 func TestRFC4791_DeleteWithIfMatchPrecondition(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "correct-etag"},
 		},
 	}
@@ -2775,7 +2777,7 @@ func TestRFC4791_DeleteWithIfMatchPrecondition(t *testing.T) {
 	}
 
 	// Event should still exist
-	_, exists := eventRepo.events["1:event"]
+	_, exists := eventRepo.Events["1:event"]
 	if !exists {
 		t.Error("RFC 4791 Section 5.3.3: Event should not be deleted when If-Match fails")
 	}
@@ -2783,13 +2785,13 @@ func TestRFC4791_DeleteWithIfMatchPrecondition(t *testing.T) {
 
 // Section 5.3.3: DELETE with correct If-Match
 func TestRFC4791_DeleteWithIfMatchSucceeds(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: "ICAL", ETag: "correct-etag"},
 		},
 	}
@@ -2806,19 +2808,19 @@ func TestRFC4791_DeleteWithIfMatchSucceeds(t *testing.T) {
 	if rr.Code != http.StatusNoContent {
 		t.Errorf("RFC 4791 Section 5.3.3: DELETE with matching If-Match must return 204, got %d", rr.Code)
 	}
-	if _, exists := eventRepo.events["1:event"]; exists {
+	if _, exists := eventRepo.Events["1:event"]; exists {
 		t.Error("RFC 4791 Section 5.3.3: Event should be deleted when If-Match succeeds")
 	}
 }
 
 // Section 5.3.3: DELETE on missing resource
 func TestRFC4791_DeleteMissingResource(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -2835,13 +2837,13 @@ func TestRFC4791_DeleteMissingResource(t *testing.T) {
 
 // Section 7.8.5: Text Match Filtering in calendar-query
 func TestRFC4791_TextMatchFilterInQuery(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:meeting": {
 				CalendarID: 1,
 				UID:        "meeting",
@@ -2894,13 +2896,13 @@ func TestRFC4791_TextMatchFilterInQuery(t *testing.T) {
 
 // Section 9.7.2: Prop Filter - is-not-defined
 func TestRFC4791_PropFilterIsNotDefined(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:with-location": {
 				CalendarID: 1,
 				UID:        "with-location",
@@ -2952,13 +2954,13 @@ func TestRFC4791_PropFilterIsNotDefined(t *testing.T) {
 
 // Section 7.8.9: Partial Retrieval of Calendar Data
 func TestRFC4791_PartialCalendarDataRetrieval(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -3037,13 +3039,13 @@ func TestRFC4791_PartialCalendarDataRetrieval(t *testing.T) {
 
 // Section 5.3.2: PUT - Duplicate UID Restriction
 func TestRFC4791_PreventDuplicateUIDInDifferentResources(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {CalendarID: 1, UID: "duplicate-uid", RawICAL: "ICAL1", ETag: "e1"},
 		},
 	}
@@ -3076,14 +3078,14 @@ func TestRFC4791_PreventDuplicateUIDInDifferentResources(t *testing.T) {
 // Section 9.6.5: limit-recurrence-set
 func TestRFC4791_LimitRecurrenceSetInCalendarData(t *testing.T) {
 	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
 	// Recurring event
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:recurring": {
 				CalendarID: 1,
 				UID:        "recurring",
@@ -3127,13 +3129,13 @@ func TestRFC4791_LimitRecurrenceSetInCalendarData(t *testing.T) {
 // Section 9.6.6: expand
 func TestRFC4791_ExpandRecurringEventsInCalendarData(t *testing.T) {
 	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:recurring": {
 				CalendarID: 1,
 				UID:        "recurring",
@@ -3177,13 +3179,13 @@ func TestRFC4791_ExpandRecurringEventsInCalendarData(t *testing.T) {
 // Section 7.8.1: Time Range Filtering with Recurring Events
 func TestRFC4791_TimeRangeFilteringWithRecurringEvents(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:recurring": {
 				CalendarID: 1,
 				UID:        "recurring",
@@ -3223,13 +3225,13 @@ func TestRFC4791_TimeRangeFilteringWithRecurringEvents(t *testing.T) {
 // Section 5.3.4: Last-Modified Header
 func TestRFC4791_GetReturnsLastModifiedHeader(t *testing.T) {
 	lastMod := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID:   1,
 				UID:          "event",
@@ -3257,13 +3259,13 @@ func TestRFC4791_GetReturnsLastModifiedHeader(t *testing.T) {
 
 // Section 5.3.2.1: PUT - If-None-Match with existing resource
 func TestRFC4791_PutWithIfNoneMatchOnExistingResource(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:existing": {CalendarID: 1, UID: "existing", RawICAL: "OLD", ETag: "etag1"},
 		},
 	}
@@ -3286,12 +3288,12 @@ func TestRFC4791_PutWithIfNoneMatchOnExistingResource(t *testing.T) {
 
 // Section 5.1: CALDAV:read-free-busy Privilege
 func TestRFC4791_ReadFreeBusyPrivilege(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8" ?>
@@ -3321,12 +3323,12 @@ func TestRFC4791_ReadFreeBusyPrivilege(t *testing.T) {
 // Section 6.3: current-user-privilege-set must include read-free-busy
 func TestRFC4791_CurrentUserPrivilegeSetIncludesReadFreeBusy(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: false},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	propBody := `<?xml version="1.0" encoding="utf-8"?>
@@ -3365,8 +3367,8 @@ func TestRFC4791_CurrentUserPrivilegeSetIncludesReadFreeBusy(t *testing.T) {
 func TestRFC4791_ReadFreeBusyPrivilegeEnforcedForReports(t *testing.T) {
 	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			// Shared calendar with limited privileges (read-free-busy but not full read).
 			{
 				Calendar:   store.Calendar{ID: 1, UserID: 9, Name: "Test", UpdatedAt: store.Now()},
@@ -3376,8 +3378,8 @@ func TestRFC4791_ReadFreeBusyPrivilegeEnforcedForReports(t *testing.T) {
 			},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -3442,12 +3444,12 @@ func TestRFC4791_ReadFreeBusyPrivilegeEnforcedForReports(t *testing.T) {
 // Section 5.2.8: schedule-calendar-transp Property
 func TestRFC4791_ScheduleCalendarTranspProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3480,13 +3482,13 @@ func TestRFC4791_CalendarQueryWithMultipleFilters(t *testing.T) {
 	end := time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC)
 	nomatchStart := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
 
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:match": {
 				CalendarID: 1,
 				UID:        "match",
@@ -3544,12 +3546,12 @@ func TestRFC4791_CalendarQueryWithMultipleFilters(t *testing.T) {
 // Section 9.11: CALDAV:timezone XML Element
 func TestRFC4791_TimezoneXMLElement(t *testing.T) {
 	// RFC 4791 Section 9.11: timezone element used in free-busy queries
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3581,13 +3583,13 @@ END:VTIMEZONE
 
 // Section 9.6.4: CALDAV:filter in calendar-data
 func TestRFC4791_FilterWithinCalendarData(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -3632,7 +3634,7 @@ func TestRFC4791_FilterWithinCalendarData(t *testing.T) {
 
 // Section 5.3.1: MKCALENDAR with Request Body
 func TestRFC4791_MkcalendarWithProperties(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -3664,12 +3666,12 @@ func TestRFC4791_MkcalendarWithProperties(t *testing.T) {
 // Interoperability: Calendar object resources often use .ics extension
 func TestInteroperability_CalendarObjectResourceNaming(t *testing.T) {
 	// Many clients use .ics filenames, but this is not a strict RFC 4791 requirement
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -3690,13 +3692,13 @@ func TestInteroperability_CalendarObjectResourceNaming(t *testing.T) {
 
 // Section 9.7.3: Test is-defined filter
 func TestRFC4791_PropFilterIsDefined(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:with-desc": {
 				CalendarID: 1,
 				UID:        "with-desc",
@@ -3747,12 +3749,12 @@ func TestRFC4791_PropFilterIsDefined(t *testing.T) {
 // Test getctag Property (RFC 6578, commonly used with CalDAV)
 func TestRFC4791_GetCTagProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", CTag: 42, UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3778,13 +3780,13 @@ func TestRFC4791_GetCTagProperty(t *testing.T) {
 
 // Section 7.8.6: Negate Condition in Filters
 func TestRFC4791_NegateConditionInFilter(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {
 				CalendarID: 1,
 				UID:        "event1",
@@ -3835,12 +3837,12 @@ func TestRFC4791_NegateConditionInFilter(t *testing.T) {
 // Section 5.2.9: max-attendees-per-instance Property
 func TestRFC4791_MaxAttendeesPerInstanceProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3867,12 +3869,12 @@ func TestRFC4791_MaxAttendeesPerInstanceProperty(t *testing.T) {
 // Section 5.2.8: max-instances Property
 func TestRFC4791_MaxInstancesProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3898,12 +3900,12 @@ func TestRFC4791_MaxInstancesProperty(t *testing.T) {
 
 // Section 5.2.9: max-attendees-per-instance Precondition
 func TestRFC4791_PutExceedsMaxAttendeesPerInstance(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	var sb strings.Builder
@@ -3927,12 +3929,12 @@ func TestRFC4791_PutExceedsMaxAttendeesPerInstance(t *testing.T) {
 
 // Section 5.2.8: max-instances Precondition
 func TestRFC4791_PutExceedsMaxInstances(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:too-many\r\nDTSTART:20240101T000000Z\r\nRRULE:FREQ=DAILY;COUNT=2001\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -3949,12 +3951,12 @@ func TestRFC4791_PutExceedsMaxInstances(t *testing.T) {
 }
 
 func TestRFC4791_PutExceedsMaxInstancesLowercaseParams(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:too-many-lower\r\nDTSTART:20240101T000000Z\r\nRRULE:freq=daily;count=2001\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -3973,12 +3975,12 @@ func TestRFC4791_PutExceedsMaxInstancesLowercaseParams(t *testing.T) {
 // Section 5.2.10 & 5.3.2.1: PROPPATCH Preconditions
 func TestRFC4791_ProppatchOnReadOnlyProperties(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// RFC 4791 Section 5.2.10: Attempt to modify read-only property
@@ -4007,12 +4009,12 @@ func TestRFC4791_ProppatchOnReadOnlyProperties(t *testing.T) {
 
 // Section 5.3.2.1: CALDAV:supported-calendar-data Precondition
 func TestRFC4791_PutWithUnsupportedMediaType(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// Try to PUT with unsupported content type (e.g., JSON instead of iCalendar)
@@ -4033,12 +4035,12 @@ func TestRFC4791_PutWithUnsupportedMediaType(t *testing.T) {
 
 // Section 5.3.2.1: Content-Type is required for calendar object resources
 func TestRFC4791_PutWithoutContentTypeRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:no-ctype\r\nSUMMARY:No Content-Type\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4058,12 +4060,12 @@ func TestRFC4791_PutWithoutContentTypeRejected(t *testing.T) {
 
 // Section 5.3.2.1: text/plain is not supported calendar data
 func TestRFC4791_PutWithTextPlainRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:plain-text\r\nSUMMARY:Plain Text\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4082,12 +4084,12 @@ func TestRFC4791_PutWithTextPlainRejected(t *testing.T) {
 
 // Section 5.3.2.1: CALDAV:supported-calendar-component Precondition
 func TestRFC4791_PutWithUnsupportedComponent(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// Try to PUT a mix of supported and unsupported components.
@@ -4107,12 +4109,12 @@ func TestRFC4791_PutWithUnsupportedComponent(t *testing.T) {
 
 // Section 5.3.2.1: CALDAV:max-resource-size Precondition
 func TestRFC4791_PutExceedsMaxResourceSize(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// Create a very large iCalendar object
@@ -4134,12 +4136,12 @@ func TestRFC4791_PutExceedsMaxResourceSize(t *testing.T) {
 
 // Section 4.1.2/5.3.2.1: METHOD property must be rejected in calendar object resources
 func TestRFC4791_ValidCalendarObject_RejectMethodProperty(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:method-reject\r\nSUMMARY:Method\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4155,13 +4157,52 @@ func TestRFC4791_ValidCalendarObject_RejectMethodProperty(t *testing.T) {
 	assertCalDAVErrorBody(t, rr.Body.String(), "valid-calendar-object-resource")
 }
 
+func TestPutStripsOrProcessesMethodWhenConfigured(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
+		},
+	}
+	eventRepo := &storetest.Events{}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}, cfg: &config.Config{CalDAVMethod: config.CalDAVMethodStrip}}
+	put := func(name, ical string) *httptest.ResponseRecorder {
+		req := newCalendarPutRequest("/dav/calendars/1/"+name, strings.NewReader(ical))
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Put(rr, req)
+		return rr
+	}
+
+	rr := put("invite.ics", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:invite\r\nSUMMARY:Invite\r\nDTSTART:20300101T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	if rr.Code != http.StatusCreated || rr.Header().Get("ETag") != "" {
+		t.Fatalf("strip PUT status = %d, ETag %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	stored := eventRepo.Events[eventRepo.Key(1, "invite")]
+	if stored == nil || strings.Contains(stored.RawICAL, "METHOD") || !strings.Contains(stored.RawICAL, "SUMMARY:Invite") {
+		t.Fatalf("stored invite = %+v", stored)
+	}
+
+	h.cfg.CalDAVMethod = config.CalDAVMethodProcess
+	rr = put("invite.ics", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:CANCEL\r\nBEGIN:VEVENT\r\nUID:invite\r\nDTSTART:20300101T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("CANCEL PUT status = %d, body %s", rr.Code, rr.Body.String())
+	}
+	stored = eventRepo.Events[eventRepo.Key(1, "invite")]
+	if !strings.Contains(stored.RawICAL, "STATUS:CANCELLED") || !strings.Contains(stored.RawICAL, "SUMMARY:Invite") {
+		t.Fatalf("cancelled invite = %q", stored.RawICAL)
+	}
+	if rr := put("other.ics", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:COUNTER\r\nBEGIN:VEVENT\r\nUID:other\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"); rr.Code != http.StatusConflict {
+		t.Fatalf("COUNTER PUT status = %d, want 409", rr.Code)
+	}
+}
+
 func TestRFC4791_ValidCalendarObject_RejectMultipleTopLevelComponents(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:one\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nUID:two\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4178,12 +4219,12 @@ func TestRFC4791_ValidCalendarObject_RejectMultipleTopLevelComponents(t *testing
 }
 
 func TestRFC4791_ValidCalendarObject_RejectMissingUID(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nSUMMARY:Missing UID\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4200,12 +4241,12 @@ func TestRFC4791_ValidCalendarObject_RejectMissingUID(t *testing.T) {
 }
 
 func TestRFC4791_UIDUniqueness_SameUIDMustBeSameResource(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -4232,12 +4273,12 @@ func TestRFC4791_UIDUniqueness_SameUIDMustBeSameResource(t *testing.T) {
 }
 
 func TestRFC4791_UpdateDoesNotAllowChangingUID(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -4265,12 +4306,12 @@ func TestRFC4791_UpdateDoesNotAllowChangingUID(t *testing.T) {
 
 // Section 5.3.2.1: Multiple different UIDs in a single resource must be rejected
 func TestRFC4791_PutWithDifferentUIDsRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// RFC 4791 Section 4.1: Calendar object resource MUST NOT mix different UIDs in one resource
@@ -4289,12 +4330,12 @@ func TestRFC4791_PutWithDifferentUIDsRejected(t *testing.T) {
 
 // Section 4.1: Recurrence set with same UID in a single resource is allowed
 func TestRFC4791_PutWithRecurrenceSetSameUIDAccepted(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:recurring\r\nDTSTART:20240101T100000Z\r\nRRULE:FREQ=DAILY;COUNT=2\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nUID:recurring\r\nRECURRENCE-ID:20240102T100000Z\r\nSUMMARY:Override\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4311,12 +4352,12 @@ func TestRFC4791_PutWithRecurrenceSetSameUIDAccepted(t *testing.T) {
 
 // Section 5.3.2.1: Calendar object resources MUST NOT be created directly in calendar-home
 func TestRFC4791_PutCalendarObjectAtCalendarHomeRejected(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:home-root\r\nSUMMARY:Home Root\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
@@ -4334,7 +4375,7 @@ func TestRFC4791_PutCalendarObjectAtCalendarHomeRejected(t *testing.T) {
 
 // Section 7.2: REPORT on Non-Calendar Collections
 func TestRFC4791_ReportOnOrdinaryCollection(t *testing.T) {
-	h := &Handler{store: &store.Store{Calendars: &fakeCalendarRepo{}, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: &storetest.Calendars{}, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	// RFC 4791 Section 7.2: calendar-query on non-calendar collection should work on descendants
@@ -4365,12 +4406,12 @@ func TestRFC4791_ReportOnOrdinaryCollection(t *testing.T) {
 // Section 7.5.1: CALDAV:supported-collation-set Property
 func TestRFC4791_SupportedCollationSetProperty(t *testing.T) {
 	now := store.Now()
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test", UpdatedAt: now}, Editor: true},
 		},
 	}
-	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &fakeEventRepo{}}}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}}}
 	user := &store.User{ID: 1}
 
 	body := `<?xml version="1.0" encoding="utf-8"?>
@@ -4398,13 +4439,13 @@ func TestRFC4791_SupportedCollationSetProperty(t *testing.T) {
 
 // Section 7.5: Text Match with Different Collations
 func TestRFC4791_TextMatchWithCollation(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -4447,14 +4488,14 @@ func TestRFC4791_TextMatchWithCollation(t *testing.T) {
 
 // HTTP compliance: Content-Length header
 func TestHTTP_GetReturnsContentLength(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
 	icalData := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {CalendarID: 1, UID: "event", RawICAL: icalData, ETag: "e"},
 		},
 	}
@@ -4482,12 +4523,12 @@ func TestHTTP_GetReturnsContentLength(t *testing.T) {
 
 // Section 5.3.2: PUT Must Handle Component Type Correctly
 func TestRFC4791_PutPreservesComponentType(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{events: make(map[string]*store.Event)}
+	eventRepo := &storetest.Events{Events: make(map[string]*store.Event)}
 	h := &Handler{store: &store.Store{Calendars: calRepo, Events: eventRepo}}
 	user := &store.User{ID: 1}
 
@@ -4521,7 +4562,7 @@ func TestRFC4791_PutPreservesComponentType(t *testing.T) {
 
 // Section 5.3.1: MKCALENDAR on Invalid Path
 func TestRFC4791_MkcalendarInvalidPath(t *testing.T) {
-	calRepo := &fakeCalendarRepo{calendars: make(map[int64]*store.Calendar)}
+	calRepo := &storetest.Calendars{Calendars: make(map[int64]*store.Calendar)}
 	h := &Handler{store: &store.Store{Calendars: calRepo}}
 	user := &store.User{ID: 1}
 
@@ -4540,13 +4581,13 @@ func TestRFC4791_MkcalendarInvalidPath(t *testing.T) {
 
 // Section 9.7.1: Component Filter Test Attribute
 func TestRFC4791_CompFilterWithTestAttribute(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event": {
 				CalendarID: 1,
 				UID:        "event",
@@ -4587,13 +4628,13 @@ func TestRFC4791_CompFilterWithTestAttribute(t *testing.T) {
 
 // Section 9.7.4: Param Filter
 func TestRFC4791_ParamFilterInQuery(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:event1": {
 				CalendarID: 1,
 				UID:        "event1",
@@ -15,6 +15,7 @@ import (
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 )
 
 func newAddressBookPutRequest(path string, body io.Reader) *http.Request {
@@ -369,8 +370,8 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_WebDAVClass3LocksBlockWritesOnDescendants", func(t *testing.T) {
 		user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 			},
 		}
@@ -385,7 +386,7 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 				},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: lockRepo}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: lockRepo}}
 		vcard := buildVCard("3.0", "UID:locked", "FN:Locked Contact")
 
 		req := newAddressBookPutRequest("/dav/addressbooks/5/locked.vcf", strings.NewReader(vcard))
@@ -410,8 +411,8 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_ACLDenyOverridesBroadGrant", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
@@ -436,13 +437,13 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_ACLReadGrantAllowsGETOnAddressObject", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 			},
 		}
@@ -466,8 +467,8 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_ACLReadGrantAllowsPROPFINDOnSharedAddressBook", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
@@ -476,7 +477,7 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 				{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "read"},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, ACLEntries: aclRepo}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, ACLEntries: aclRepo}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <d:propfind xmlns:d="DAV:">
@@ -498,13 +499,13 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_ACLDenyBlocksGETOnAddressObject", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice"},
 			},
 		}
@@ -529,12 +530,12 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_ACLWriteGrantAllowsPUTIntoSharedAddressBook", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "writer@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		aclRepo := &fakeACLRepo{
 			entries: []store.ACLEntry{
 				{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "bind"},
@@ -557,8 +558,8 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 	t.Run("Section3_ACLWritePropertiesGrantAllowsPROPPATCHOnSharedAddressBook", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "editor@example.com"}
 		description := "Shared Contacts"
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", Description: &description},
 			},
 		}
@@ -568,7 +569,7 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 				{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "write-properties"},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, ACLEntries: aclRepo}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, ACLEntries: aclRepo}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <D:propertyupdate xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
@@ -602,8 +603,8 @@ func TestRFC6352_RequirementsOverview(t *testing.T) {
 
 	t.Run("Section3_AddressBookCollectionOwnersCanManageACLs", func(t *testing.T) {
 		user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts"},
 			},
 		}
@@ -721,8 +722,8 @@ func TestRFC6352_DeploymentAndSecurityRequirements(t *testing.T) {
 	t.Run("Section13_NewlyProvisionedAddressBooksDefaultToPrivate", func(t *testing.T) {
 		owner := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
 		reader := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
-		bookRepo := &fakeAddressBookRepo{}
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		bookRepo := &storetest.AddressBooks{}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		aclRepo := &fakeACLRepo{}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, ACLEntries: aclRepo}}
 
@@ -775,13 +776,13 @@ func TestRFC6352_DeploymentAndSecurityRequirements(t *testing.T) {
 func TestRFC6352_AddressBookSecuritySemantics(t *testing.T) {
 	reader := &store.User{ID: 2, PrimaryEmail: "reader@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-a", LastModified: now},
 		},
 	}
@@ -876,13 +877,13 @@ func TestRFC6352_AddressBookDiscoverySequence(t *testing.T) {
 	now := store.Now()
 	desc1 := "Work contacts"
 	desc2 := "Family contacts"
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			1: {ID: 1, UserID: 1, Name: "Work", Description: &desc1, UpdatedAt: now, CTag: 10},
 			2: {ID: 2, UserID: 1, Name: "Family", Description: &desc2, UpdatedAt: now, CTag: 20},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	t.Run("Step1_DiscoverCurrentUserPrincipal", func(t *testing.T) {
@@ -990,12 +991,12 @@ func TestRFC6352_AddressBookDiscoverySequence(t *testing.T) {
 func TestRFC6352_AddressBookCollectionProperties(t *testing.T) {
 	now := store.Now()
 	description := "Primary contacts"
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", Description: &description, UpdatedAt: now, CTag: 9},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 	user := &store.User{ID: 1, PrimaryEmail: "user@example.com"}
 
 	t.Run("Section5_2_ResourceType", func(t *testing.T) {
@@ -1320,8 +1321,8 @@ func TestRFC6352_PrincipalProperties(t *testing.T) {
 }
 
 func TestRFC6352_ProtectedCardDAVProperties(t *testing.T) {
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts"},
 		},
 	}
@@ -1398,15 +1399,15 @@ func TestRFC6352_ProtectedCardDAVProperties(t *testing.T) {
 
 func TestRFC6352_AddressObjectResources(t *testing.T) {
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 		},
 	}
 	user := &store.User{ID: 1}
 
 	t.Run("Section6_3_2_PutCreatesNewResourceOnUnmappedURI", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("3.0",
@@ -1426,7 +1427,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_IfNoneMatchStarCreatesNewResource", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("3.0", "UID:new-uid", "FN:New Contact")
@@ -1443,8 +1444,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_IfNoneMatchStarFailsOnExistingResource", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "old"},
 			},
 		}
@@ -1464,8 +1465,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_IfMatchRequiresMatchingETag", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "correct-etag"},
 			},
 		}
@@ -1485,8 +1486,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_Section6_3_2_3_IfMatchSuccessReturnsStrongETag", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "old-etag"},
 			},
 		}
@@ -1514,8 +1515,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 
 	t.Run("Section5_1_Section6_3_2_3_GetReturnsTextVCardAndStrongETag", func(t *testing.T) {
 		vcard := buildVCard("3.0", "UID:alice", "FN:Alice Example", "EMAIL:alice@example.com")
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: vcard, ETag: "etag-1"},
 			},
 		}
@@ -1547,8 +1548,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 
 	t.Run("Section5_1_1_1_GetRejectsUnsupportedAddressDataConversion", func(t *testing.T) {
 		vcard := buildVCard("3.0", "UID:alice", "FN:Alice Example")
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: vcard, ETag: "etag-1"},
 			},
 		}
@@ -1566,8 +1567,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 
 	t.Run("Section5_1_1_1_GetRejectsUnsupportedVCardVersionConversion", func(t *testing.T) {
 		vcard := buildVCard("4.0", "UID:alice-v4", "FN:Alice Example")
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice-v4": {AddressBookID: 5, UID: "alice-v4", RawVCard: vcard, ETag: "etag-v4"},
 			},
 		}
@@ -1587,7 +1588,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsUnsupportedMediaType", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("3.0", "UID:alice", "FN:Alice Example")
@@ -1605,7 +1606,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsInvalidVCardData", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader("NOT A VCARD"))
@@ -1621,7 +1622,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section5_1_RejectsVCardWithoutUID", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		card := buildVCard("3.0", "FN:Alice Example")
@@ -1638,7 +1639,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsVCardWithoutVersion", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		card := "BEGIN:VCARD\r\nUID:alice\r\nFN:Alice Example\r\nEND:VCARD\r\n"
@@ -1655,7 +1656,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsUnsupportedVCardVersion", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		card := buildVCard("2.1", "UID:alice", "FN:Alice Example")
@@ -1672,7 +1673,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsVCardWithoutFN", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		card := buildVCard("3.0", "UID:alice")
@@ -1713,7 +1714,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.name, func(t *testing.T) {
-				contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+				contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 				h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 				req := newAddressBookPutRequest(tc.path, strings.NewReader(tc.card))
@@ -1730,7 +1731,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section5_1_AddressObjectContainsSingleVCardComponentOnly", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		doubleVCard := buildVCard("3.0", "UID:first", "FN:First") + buildVCard("3.0", "UID:second", "FN:Second")
@@ -1744,7 +1745,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section3_ServerShouldSupportVCard4", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("4.0", "UID:alice-v4", "FN:Alice Example")
@@ -1760,7 +1761,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_2_AcceptsNonStandardXProperties", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("3.0",
@@ -1789,7 +1790,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_2_AcceptsNonStandardParameters", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		vcard := buildVCard("3.0",
@@ -1818,7 +1819,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section5_1_Section6_3_2_1_DuplicateUIDAcrossResourcesRejected", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		first := buildVCard("3.0", "UID:shared-uid", "FN:First Contact")
@@ -1842,7 +1843,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_ChangingUIDOfExistingResourceRejected", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		initial := buildVCard("3.0", "UID:alice", "FN:Alice Example")
@@ -1869,7 +1870,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section5_1_Section6_3_2_1_ResourceHrefsPreserveDestinationNames", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		first := buildVCard("3.0", "UID:shared-uid", "FN:First Contact")
@@ -1947,14 +1948,14 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_COPYPreservesUIDAndUsesDestinationResourceName", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Source"},
 				6: {ID: 6, UserID: 1, Name: "Destination"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:shared-uid": {AddressBookID: 5, UID: "shared-uid", ResourceName: "first", RawVCard: buildVCard("3.0", "UID:shared-uid", "FN:First Contact"), ETag: "etag-1"},
 			},
 		}
@@ -1977,14 +1978,14 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_COPYRejectsDestinationUIDConflicts", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Source"},
 				6: {ID: 6, UserID: 1, Name: "Destination"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:shared-uid": {AddressBookID: 5, UID: "shared-uid", ResourceName: "first", RawVCard: buildVCard("3.0", "UID:shared-uid", "FN:First Contact"), ETag: "etag-1"},
 				"6:shared-uid": {AddressBookID: 6, UID: "shared-uid", ResourceName: "existing", RawVCard: buildVCard("3.0", "UID:shared-uid", "FN:Existing Contact"), ETag: "etag-2"},
 			},
@@ -2002,14 +2003,14 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_MOVEPreservesUIDAndUsesDestinationResourceName", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Source"},
 				6: {ID: 6, UserID: 1, Name: "Destination"},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:shared-uid": {AddressBookID: 5, UID: "shared-uid", ResourceName: "first", RawVCard: buildVCard("3.0", "UID:shared-uid", "FN:First Contact"), ETag: "etag-1"},
 			},
 		}
@@ -2036,7 +2037,7 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_RejectsResourcesExceedingMaxResourceSize", func(t *testing.T) {
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo}}
 
 		oversized := buildVCard("3.0", "UID:alice", "FN:"+strings.Repeat("A", int(maxDAVBodyBytes)))
@@ -2053,15 +2054,15 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_COPYRejectsResourcesExceedingMaxResourceSize", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Source"},
 				6: {ID: 6, UserID: 1, Name: "Destination"},
 			},
 		}
 		oversized := buildVCard("3.0", "UID:shared-uid", "FN:"+strings.Repeat("A", int(maxDAVBodyBytes)))
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:shared-uid": {AddressBookID: 5, UID: "shared-uid", ResourceName: "first", RawVCard: oversized, ETag: "etag-1"},
 			},
 		}
@@ -2081,15 +2082,15 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 	})
 
 	t.Run("Section6_3_2_1_MOVERejectsResourcesExceedingMaxResourceSize", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Source"},
 				6: {ID: 6, UserID: 1, Name: "Destination"},
 			},
 		}
 		oversized := buildVCard("3.0", "UID:shared-uid", "FN:"+strings.Repeat("A", int(maxDAVBodyBytes)))
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:shared-uid": {AddressBookID: 5, UID: "shared-uid", ResourceName: "first", RawVCard: oversized, ETag: "etag-1"},
 			},
 		}
@@ -2110,8 +2111,8 @@ func TestRFC6352_AddressObjectResources(t *testing.T) {
 
 	t.Run("Section6_3_2_3_ObjectResourcePropfindExposesGetETagContentTypeAndReports", func(t *testing.T) {
 		vcard := buildVCard("3.0", "UID:alice", "FN:Alice Example", "EMAIL:alice@example.com")
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: vcard, ETag: "etag-1"},
 			},
 		}
@@ -2155,7 +2156,7 @@ func TestRFC6352_AddressBookCreation(t *testing.T) {
 	user := &store.User{ID: 1}
 
 	t.Run("Section6_3_1_MKCOLCreatesAddressBookCollection", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{}
+		bookRepo := &storetest.AddressBooks{}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 		req := httptest.NewRequest("MKCOL", "/dav/addressbooks/NewBook", nil)
@@ -2170,8 +2171,8 @@ func TestRFC6352_AddressBookCreation(t *testing.T) {
 		if rr.Header().Get("Location") != "/dav/addressbooks/1/" {
 			t.Errorf("MKCOL should return the canonical collection location, got %q", rr.Header().Get("Location"))
 		}
-		if len(bookRepo.books) != 1 {
-			t.Fatalf("expected one address book to be created, got %d", len(bookRepo.books))
+		if len(bookRepo.Books) != 1 {
+			t.Fatalf("expected one address book to be created, got %d", len(bookRepo.Books))
 		}
 
 		req = httptest.NewRequest("PROPFIND", "/dav/addressbooks/NewBook/", nil)
@@ -2190,7 +2191,7 @@ func TestRFC6352_AddressBookCreation(t *testing.T) {
 	})
 
 	t.Run("Section6_3_1_ExtendedMKCOLInitializesProperties", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{}
+		bookRepo := &storetest.AddressBooks{}
 		h := &Handler{store: &store.Store{AddressBooks: bookRepo}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -2249,8 +2250,8 @@ func TestRFC6352_AddressBookCreation(t *testing.T) {
 	})
 
 	t.Run("Section5_2_NestedAddressBooksRejected", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 			},
 		}
@@ -2271,13 +2272,13 @@ func TestRFC6352_AddressBookCreation(t *testing.T) {
 func TestRFC6352_ReportMethodSupport(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Adams"), ETag: "etag-a", LastModified: now},
 		},
 	}
@@ -2345,8 +2346,8 @@ func TestRFC6352_ReportMethodSupport(t *testing.T) {
 func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -2376,7 +2377,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	}
 
 	t.Run("Section8_1_8_6_QuerySupportedOnAddressBookCollections", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2409,7 +2410,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_TopLevelAddressDataSelectionIsHonored", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2445,7 +2446,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_QueryRequiresDepthHeader", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav">
@@ -2481,7 +2482,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 				LastModified: now,
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: prodidContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: prodidContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2512,7 +2513,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_QueryReportsSupportedOnAddressObjectResources", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2540,7 +2541,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_EmptyMultistatusWhenNoMatches", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: map[string]*store.Contact{}}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: map[string]*store.Contact{}}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav">
@@ -2565,7 +2566,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_MissingRequestedWebDAVPropertyReturns404Propstat", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2592,7 +2593,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_3_FilteringByTextMatchModes", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		tests := []struct {
 			name      string
@@ -2639,7 +2640,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_3_DefaultCollationAliasUsesUnicodeCasemap", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2669,7 +2670,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section10_5_4_MissingCollationDefaultsToUnicodeCasemap", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2699,7 +2700,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section10_5_3_IsNotDefinedFilter", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2730,7 +2731,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section10_5_FilterTestAttributeAnyOfAndAllOf", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		defaultBody := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2820,7 +2821,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 			"5:home": {AddressBookID: 5, UID: "home", RawVCard: homeVCard, ETag: "etag-home", LastModified: now},
 			"5:work": {AddressBookID: 5, UID: "work", RawVCard: workVCard, ETag: "etag-work", LastModified: now},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2859,7 +2860,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 			"5:typed":   {AddressBookID: 5, UID: "typed", RawVCard: typedVCard, ETag: "etag-typed", LastModified: now},
 			"5:untyped": {AddressBookID: 5, UID: "untyped", RawVCard: untypedVCard, ETag: "etag-untyped", LastModified: now},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2892,7 +2893,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section10_5_4_NegateCondition", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2920,7 +2921,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_3_RejectsUnsupportedCollation", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2945,7 +2946,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_3_RejectsWildcardCollationIdentifiers", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2970,7 +2971,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_RejectsUnsupportedRequestedAddressDataType", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -2993,7 +2994,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_4_PartialRetrievalAllprop", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3023,7 +3024,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_4_SelectedPropertiesPartialRetrieval", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3068,7 +3069,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 		contacts := map[string]*store.Contact{
 			"5:grouped": {AddressBookID: 5, UID: "grouped", RawVCard: groupedVCard, ETag: "etag-grouped", LastModified: now},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 		ungroupedBody := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3127,7 +3128,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section10_4_2_NovalueSelection", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3160,7 +3161,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_5_NonStandardPropertySelection", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3189,7 +3190,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_5_UnsupportedInvalidPropertyFilterFails", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		// Use a property name that is neither a standard vCard property nor an X- extension
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3215,7 +3216,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_5_UnsupportedParamFilterFailsWithSupportedFilter", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3248,7 +3249,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 			"5:x-prop-user": {AddressBookID: 5, UID: "x-prop-user", ResourceName: "x-prop-user", RawVCard: xPropVCard, ETag: "etag-xp", LastModified: now},
 			"5:no-x-prop":   {AddressBookID: 5, UID: "no-x-prop", ResourceName: "no-x-prop", RawVCard: noXPropVCard, ETag: "etag-nxp", LastModified: now},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3290,7 +3291,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 			"5:grouped-b": {AddressBookID: 5, UID: "grouped-b", RawVCard: groupedB, ETag: "etag-grouped-b", LastModified: now},
 			"5:plain":     {AddressBookID: 5, UID: "plain", RawVCard: plain, ETag: "etag-plain", LastModified: now},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 		ungroupedBody := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3347,7 +3348,7 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 	})
 
 	t.Run("Section8_6_1_And_8_6_2_ClientLimitHandling", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-query xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3391,8 +3392,8 @@ func TestRFC6352_AddressbookQueryReport(t *testing.T) {
 func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -3405,7 +3406,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	}
 
 	t.Run("Section8_7_RequestMustIncludeAtLeastOneHref", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:"/>`
@@ -3423,7 +3424,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_MultigetSupportedOnAddressBookCollections", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3448,7 +3449,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_MultigetSupportedOnAddressObjectResources", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3468,7 +3469,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_MultigetRequiresDepthZero", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3488,7 +3489,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_MultigetRequiresDepthHeader", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3507,7 +3508,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_MissingHrefReturns404ForThatResponse", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3528,7 +3529,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_OutOfScopeHrefReturnsPerResourceError", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3552,7 +3553,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_SelectedPropertiesPartialRetrieval", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3583,7 +3584,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	t.Run("Section8_7_PerResourceVersionConversionFailureUsesSupportedAddressDataConversion", func(t *testing.T) {
 		h := &Handler{store: &store.Store{
 			AddressBooks: bookRepo,
-			Contacts: &fakeContactRepo{contacts: map[string]*store.Contact{
+			Contacts: &storetest.Contacts{Contacts: map[string]*store.Contact{
 				"5:alice-v4": {AddressBookID: 5, UID: "alice-v4", ResourceName: "alice-v4", RawVCard: buildVCard("4.0", "UID:alice-v4", "FN:Alice Example"), ETag: "etag-v4"},
 			}},
 		}}
@@ -3619,7 +3620,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	t.Run("Section8_7_TopLevelVersionConversionFailureUsesSupportedAddressDataConversion", func(t *testing.T) {
 		h := &Handler{store: &store.Store{
 			AddressBooks: bookRepo,
-			Contacts: &fakeContactRepo{contacts: map[string]*store.Contact{
+			Contacts: &storetest.Contacts{Contacts: map[string]*store.Contact{
 				"5:alice-v4": {AddressBookID: 5, UID: "alice-v4", ResourceName: "alice-v4", RawVCard: buildVCard("4.0", "UID:alice-v4", "FN:Alice Example"), ETag: "etag-v4"},
 			}},
 		}}
@@ -3650,7 +3651,7 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 	})
 
 	t.Run("Section8_7_RejectsUnsupportedRequestedAddressDataType", func(t *testing.T) {
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: baseContacts}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: baseContacts}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <card:addressbook-multiget xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:D="DAV:">
@@ -3674,8 +3675,8 @@ func TestRFC6352_AddressbookMultigetReport(t *testing.T) {
 func TestRFC6352_UnicodeCollation(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -3686,7 +3687,7 @@ func TestRFC6352_UnicodeCollation(t *testing.T) {
 		"5:jose":    {AddressBookID: 5, UID: "jose", ResourceName: "jose", RawVCard: joseVCard, ETag: "etag-jose", LastModified: now},
 		"5:strasse": {AddressBookID: 5, UID: "strasse", ResourceName: "strasse", RawVCard: strasseVCard, ETag: "etag-strasse", LastModified: now},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 	t.Run("UnicodeCasemapMatchesAccentedCharacters", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3772,8 +3773,8 @@ func TestRFC6352_UnicodeCollation(t *testing.T) {
 func TestRFC6352_Depth0AddressbookQuery(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -3781,7 +3782,7 @@ func TestRFC6352_Depth0AddressbookQuery(t *testing.T) {
 	contacts := map[string]*store.Contact{
 		"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: aliceVCard, ETag: "etag-a", LastModified: now},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 	t.Run("Section8_6_Depth0OnCollectionReturnsNoResources", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3813,8 +3814,8 @@ func TestRFC6352_Depth0AddressbookQuery(t *testing.T) {
 func TestRFC6352_UIDConflictIncludesHref(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -3825,9 +3826,9 @@ func TestRFC6352_UIDConflictIncludesHref(t *testing.T) {
 	h := &Handler{
 		store: &store.Store{
 			AddressBooks:     bookRepo,
-			Contacts:         &fakeContactRepo{contacts: contacts},
+			Contacts:         &storetest.Contacts{Contacts: contacts},
 			Locks:            &fakeLockRepo{},
-			DeletedResources: &fakeDeletedResourceRepo{},
+			DeletedResources: &storetest.DeletedResources{},
 		},
 	}
 
@@ -3859,12 +3860,12 @@ func TestRFC6352_UIDConflictIncludesHref(t *testing.T) {
 func TestRFC6352_ExpandPropertyAtDAVRoot(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "root@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}}}
 
 	t.Run("Section8_1_ExpandPropertySupportedOnDAVRoot", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -3924,8 +3925,8 @@ func TestRFC6352_LockConflictBetweenUsers(t *testing.T) {
 	user1 := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	user2 := &store.User{ID: 2, PrimaryEmail: "bob@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user1.ID, Name: "Contacts"},
 		},
 	}
@@ -4028,8 +4029,8 @@ func TestRFC6352_LockConflictBetweenUsers(t *testing.T) {
 				ExpiresAt:    time.Now().Add(time.Hour),
 			},
 		}}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: user1.ID, Name: "Contacts"},
 			},
 		}
@@ -4051,8 +4052,8 @@ func TestRFC6352_LockConflictBetweenUsers(t *testing.T) {
 func TestRFC4918_LockResponseUsesDAVXML(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4091,8 +4092,8 @@ func TestRFC6352_UnlockByWrongUser(t *testing.T) {
 	user1 := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	user2 := &store.User{ID: 2, PrimaryEmail: "bob@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user1.ID, Name: "Contacts"},
 		},
 	}
@@ -4141,8 +4142,8 @@ func TestRFC6352_UnlockByWrongUser(t *testing.T) {
 func TestRFC6352_LockRefresh(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4239,13 +4240,13 @@ func TestRFC6352_LockRefresh(t *testing.T) {
 func TestRFC6352_Depth0CollectionLockBlocksMemberMutation(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice", LastModified: now},
 		},
 	}
@@ -4289,8 +4290,8 @@ func TestRFC6352_Depth0CollectionLockBlocksMemberMutation(t *testing.T) {
 
 func TestRFC6352_InvalidLockDepthRejected(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -4315,12 +4316,12 @@ func TestRFC6352_InvalidLockDepthRejected(t *testing.T) {
 func TestRFC6352_CollectionLockTokensAuthorizeMemberWrites(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+	contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 	lockRepo := &fakeLockRepo{
 		locks: map[string]*store.Lock{
 			"opaquelocktoken:collection": {
@@ -4352,13 +4353,13 @@ func TestRFC6352_CollectionLockTokensAuthorizeMemberWrites(t *testing.T) {
 func TestRFC4918_NegatedIfStateDoesNotAuthorizeLockedWrite(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice Example"), ETag: "etag-alice", LastModified: now},
 		},
 	}
@@ -4393,8 +4394,8 @@ func TestRFC4918_NegatedIfStateDoesNotAuthorizeLockedWrite(t *testing.T) {
 func TestRFC6352_LockAuthorizationAndErrorHandling(t *testing.T) {
 	t.Run("UnauthorizedUserCannotLockAnotherUsersAddressObject", func(t *testing.T) {
 		user := &store.User{ID: 2, PrimaryEmail: "intruder@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Private Contacts"},
 			},
 		}
@@ -4417,8 +4418,8 @@ func TestRFC6352_LockAuthorizationAndErrorHandling(t *testing.T) {
 
 	t.Run("LockLookupErrorsBlockWrites", func(t *testing.T) {
 		user := &store.User{ID: 1, PrimaryEmail: "owner@example.com"}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts"},
 			},
 		}
@@ -4426,7 +4427,7 @@ func TestRFC6352_LockAuthorizationAndErrorHandling(t *testing.T) {
 			locks:              map[string]*store.Lock{},
 			listByResourcesErr: errors.New("lock store unavailable"),
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: lockRepo}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: lockRepo}}
 
 		req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(buildVCard("3.0", "UID:alice", "FN:Alice")))
 		req = req.WithContext(auth.WithUser(req.Context(), user))
@@ -4446,17 +4447,17 @@ func TestRFC6352_LockAuthorizationAndErrorHandling(t *testing.T) {
 func TestRFC6352_SyncCollectionOnAddressBook(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "test@example.com"}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 3},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice", LastModified: now},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{}
+	deletedRepo := &storetest.DeletedResources{}
 	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, DeletedResources: deletedRepo}}
 
 	t.Run("Section8_2_InitialSyncReturnsAllContacts", func(t *testing.T) {
@@ -4550,19 +4551,19 @@ func TestRFC6352_IncrementalSyncCollection(t *testing.T) {
 	baseTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
 	laterTime := baseTime.Add(time.Hour)
 
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: laterTime, CTag: 3},
 		},
 	}
-	contactRepo := &fakeContactRepo{
-		contacts: map[string]*store.Contact{
+	contactRepo := &storetest.Contacts{
+		Contacts: map[string]*store.Contact{
 			"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice", LastModified: baseTime},
 			"5:bob":   {AddressBookID: 5, UID: "bob", ResourceName: "bob", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-bob", LastModified: laterTime},
 		},
 	}
-	deletedRepo := &fakeDeletedResourceRepo{
-		deleted: []store.DeletedResource{
+	deletedRepo := &storetest.DeletedResources{
+		Deleted: []store.DeletedResource{
 			{ResourceType: "contact", CollectionID: 5, UID: "carol", ResourceName: "carol", DeletedAt: laterTime},
 		},
 	}
@@ -4627,13 +4628,13 @@ func TestRFC6352_DeleteAddressBookCollection(t *testing.T) {
 	now := store.Now()
 
 	t.Run("DeleteContactResource204", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice"},
 			},
 		}
@@ -4656,12 +4657,12 @@ func TestRFC6352_DeleteAddressBookCollection(t *testing.T) {
 	})
 
 	t.Run("DeleteNonExistentReturns404", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: &fakeLockRepo{}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: &fakeLockRepo{}}}
 
 		req := httptest.NewRequest(http.MethodDelete, "/dav/addressbooks/5/nonexistent.vcf", nil)
 		req = req.WithContext(auth.WithUser(req.Context(), user))
@@ -4674,13 +4675,13 @@ func TestRFC6352_DeleteAddressBookCollection(t *testing.T) {
 	})
 
 	t.Run("DeleteLockedResourceReturns423", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-alice"},
 			},
 		}
@@ -4709,8 +4710,8 @@ func TestRFC6352_DeleteAddressBookCollection(t *testing.T) {
 func TestRFC6352_AddressbookQueryEmptyFilter(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
@@ -4718,7 +4719,7 @@ func TestRFC6352_AddressbookQueryEmptyFilter(t *testing.T) {
 		"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 		"5:bob":   {AddressBookID: 5, UID: "bob", ResourceName: "bob", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 	t.Run("EmptyFilterMatchesAllResources", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -4777,12 +4778,12 @@ func TestRFC6352_ProppatchOnAddressBook(t *testing.T) {
 	now := store.Now()
 
 	t.Run("ProppatchDisplayNameUpdatesAddressBook", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: &fakeLockRepo{}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: &fakeLockRepo{}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <D:propertyupdate xmlns:D="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
@@ -4806,19 +4807,19 @@ func TestRFC6352_ProppatchOnAddressBook(t *testing.T) {
 		}
 
 		// Verify the update took effect
-		book := bookRepo.books[5]
+		book := bookRepo.Books[5]
 		if book.Name != "Renamed Book" {
 			t.Errorf("PROPPATCH should update the display name, got %q", book.Name)
 		}
 	})
 
 	t.Run("ProppatchProtectedPropertyReturnsForbidden", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: &fakeLockRepo{}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: &fakeLockRepo{}}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <D:propertyupdate xmlns:D="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
@@ -4845,8 +4846,8 @@ func TestRFC6352_ProppatchOnAddressBook(t *testing.T) {
 
 	t.Run("ProppatchByNonOwnerReturnsForbidden", func(t *testing.T) {
 		nonOwner := &store.User{ID: 2}
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 			},
 		}
@@ -4855,7 +4856,7 @@ func TestRFC6352_ProppatchOnAddressBook(t *testing.T) {
 				{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "read"},
 			},
 		}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{}, Locks: &fakeLockRepo{}, ACLEntries: aclRepo}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{}, Locks: &fakeLockRepo{}, ACLEntries: aclRepo}}
 
 		body := `<?xml version="1.0" encoding="utf-8"?>
 <D:propertyupdate xmlns:D="DAV:">
@@ -4887,15 +4888,15 @@ func TestRFC6352_ProppatchOnAddressBook(t *testing.T) {
 func TestRFC6352_DepthInfinityAddressbookQuery(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
 	contacts := map[string]*store.Contact{
 		"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a", LastModified: now},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 	t.Run("DepthInfinityReturnsContactResources", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
@@ -4933,14 +4934,14 @@ func TestRFC6352_CopyMoveIntegration(t *testing.T) {
 	aliceVCard := buildVCard("3.0", "UID:alice", "FN:Alice")
 
 	t.Run("CopyContactToAnotherAddressBook", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Personal", UpdatedAt: now},
 				6: {ID: 6, UserID: 1, Name: "Work", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: aliceVCard, ETag: "etag-a", LastModified: now},
 			},
 		}
@@ -4969,14 +4970,14 @@ func TestRFC6352_CopyMoveIntegration(t *testing.T) {
 	})
 
 	t.Run("MoveContactToAnotherAddressBook", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Personal", UpdatedAt: now},
 				6: {ID: 6, UserID: 1, Name: "Work", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:bob": {AddressBookID: 5, UID: "bob", ResourceName: "bob", RawVCard: buildVCard("3.0", "UID:bob", "FN:Bob"), ETag: "etag-b", LastModified: now},
 			},
 		}
@@ -5005,14 +5006,14 @@ func TestRFC6352_CopyMoveIntegration(t *testing.T) {
 	})
 
 	t.Run("CopyOverwriteFalseFailsIfDestExists", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Personal", UpdatedAt: now},
 				6: {ID: 6, UserID: 1, Name: "Work", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: aliceVCard, ETag: "etag-a", LastModified: now},
 				"6:alice": {AddressBookID: 6, UID: "alice", ResourceName: "alice", RawVCard: aliceVCard, ETag: "etag-a2", LastModified: now},
 			},
@@ -5033,14 +5034,14 @@ func TestRFC6352_CopyMoveIntegration(t *testing.T) {
 	})
 
 	t.Run("MoveLockedSourceReturns423", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Personal", UpdatedAt: now},
 				6: {ID: 6, UserID: 1, Name: "Work", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:carol": {AddressBookID: 5, UID: "carol", ResourceName: "carol", RawVCard: buildVCard("3.0", "UID:carol", "FN:Carol"), ETag: "etag-c", LastModified: now},
 			},
 		}
@@ -5072,12 +5073,12 @@ func TestRFC6352_ACLDenyBlocksPutDelete(t *testing.T) {
 	now := store.Now()
 
 	t.Run("DenyBindBlocksPUT", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+		contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 		aclRepo := &fakeACLRepo{
 			entries: []store.ACLEntry{
 				{ResourcePath: "/dav/addressbooks/5", PrincipalHref: "/dav/principals/2/", IsGrant: false, Privilege: "bind"},
@@ -5098,13 +5099,13 @@ func TestRFC6352_ACLDenyBlocksPutDelete(t *testing.T) {
 	})
 
 	t.Run("DenyUnbindBlocksDELETE", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 			},
 		}
@@ -5127,13 +5128,13 @@ func TestRFC6352_ACLDenyBlocksPutDelete(t *testing.T) {
 	})
 
 	t.Run("GrantWriteContentAllowsPUTUpdate", func(t *testing.T) {
-		bookRepo := &fakeAddressBookRepo{
-			books: map[int64]*store.AddressBook{
+		bookRepo := &storetest.AddressBooks{
+			Books: map[int64]*store.AddressBook{
 				5: {ID: 5, UserID: 1, Name: "Shared Contacts", UpdatedAt: now},
 			},
 		}
-		contactRepo := &fakeContactRepo{
-			contacts: map[string]*store.Contact{
+		contactRepo := &storetest.Contacts{
+			Contacts: map[string]*store.Contact{
 				"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: buildVCard("3.0", "UID:alice", "FN:Alice"), ETag: "etag-a"},
 			},
 		}
@@ -5143,7 +5144,7 @@ func TestRFC6352_ACLDenyBlocksPutDelete(t *testing.T) {
 			},
 		}
 		lockRepo := &fakeLockRepo{}
-		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, ACLEntries: aclRepo, Locks: lockRepo, DeletedResources: &fakeDeletedResourceRepo{}}}
+		h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: contactRepo, ACLEntries: aclRepo, Locks: lockRepo, DeletedResources: &storetest.DeletedResources{}}}
 
 		vcard := buildVCard("3.0", "UID:alice", "FN:Alice Updated")
 		req := newAddressBookPutRequest("/dav/addressbooks/5/alice.vcf", strings.NewReader(vcard))
@@ -5166,12 +5167,12 @@ func TestRFC6352_SharedLocksAllowWrites(t *testing.T) {
 	user2 := &store.User{ID: 2, PrimaryEmail: "bob@example.com"}
 	now := store.Now()
 
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now},
 		},
 	}
-	contactRepo := &fakeContactRepo{contacts: map[string]*store.Contact{}}
+	contactRepo := &storetest.Contacts{Contacts: map[string]*store.Contact{}}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
 	aclRepo := &fakeACLRepo{
 		entries: []store.ACLEntry{
@@ -5255,8 +5256,8 @@ func TestRFC6352_SharedLocksAllowWrites(t *testing.T) {
 func TestRFC6352_LockTimeoutCappingIntegration(t *testing.T) {
 	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
 	lockRepo := &fakeLockRepo{locks: map[string]*store.Lock{}}
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: user.ID, Name: "Contacts"},
 		},
 	}
@@ -5310,15 +5311,15 @@ func TestRFC6352_AddressDataResponseFormat(t *testing.T) {
 	user := &store.User{ID: 1}
 	now := store.Now()
 	aliceVCard := buildVCard("3.0", "UID:alice", "FN:Alice", "EMAIL:alice@example.com")
-	bookRepo := &fakeAddressBookRepo{
-		books: map[int64]*store.AddressBook{
+	bookRepo := &storetest.AddressBooks{
+		Books: map[int64]*store.AddressBook{
 			5: {ID: 5, UserID: 1, Name: "Contacts", UpdatedAt: now, CTag: 1},
 		},
 	}
 	contacts := map[string]*store.Contact{
 		"5:alice": {AddressBookID: 5, UID: "alice", ResourceName: "alice", RawVCard: aliceVCard, ETag: "etag-a", LastModified: now},
 	}
-	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &fakeContactRepo{contacts: contacts}}}
+	h := &Handler{store: &store.Store{AddressBooks: bookRepo, Contacts: &storetest.Contacts{Contacts: contacts}}}
 
 	t.Run("MultigetIncludesAddressDataInResponse", func(t *testing.T) {
 		body := `<?xml version="1.0" encoding="utf-8"?>
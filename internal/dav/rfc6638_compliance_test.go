@@ -7,17 +7,18 @@ import (
 
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 )
 
 // Extension: Schedule-Tag Header (RFC 6638 - CalDAV Scheduling)
 func TestRFC6638_ScheduleTagHeader(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Test"}, Editor: true},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"1:meeting": {
 				CalendarID: 1,
 				UID:        "meeting",
@@ -1,12 +1,17 @@
 package dav
 
 import (
+	"context"
 	"net/http"
 	"path"
+	"sync"
 
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/flags"
 	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/push"
+	"github.com/jw6ventures/calcard/internal/quirks"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -28,6 +33,22 @@ type Server struct {
 	store    *store.Store
 	registry *Registry
 	log      *logging.Logger
+	limits   *syncLimiter
+	interop  *interopRecorder
+	// confMu guards deletions, notFound, quirks, bodyLog, lenientTokens and
+	// features, which Reconfigure may replace.
+	confMu        sync.RWMutex
+	deletions     *deletionGuard
+	notFound      *notFoundCache
+	quirks        quirks.Rules
+	bodyLog       string
+	lenientTokens bool
+	// features is the last reloaded configuration, which feature flags are
+	// resolved against instead of cfg.
+	features *config.Config
+	// pushMu guards pushKeys, the VAPID keys loaded on first use.
+	pushMu   sync.Mutex
+	pushKeys *push.Keys
 }
 
 // Handler is kept as a package compatibility alias while the DAV entrypoints
@@ -44,7 +65,82 @@ func NewServer(opts Options) *Server {
 			ext.RegisterDAV(registry)
 		}
 	}
-	return &Server{cfg: opts.Config, store: opts.Store, registry: registry, log: logging.New(opts.Logger, logClass)}
+	var rules quirks.Rules
+	lenientTokens := false
+	if opts.Config != nil {
+		rules = opts.Config.DAV.ClientQuirks
+		lenientTokens = !opts.Config.DAV.StrictSyncTokens
+	}
+	return &Server{
+		cfg:           opts.Config,
+		store:         opts.Store,
+		registry:      registry,
+		log:           logging.New(opts.Logger, logClass),
+		limits:        newSyncLimiter(opts.Config),
+		interop:       newInteropRecorder(opts.Config),
+		deletions:     newDeletionGuard(opts.Config),
+		notFound:      newNotFoundCache(opts.Config),
+		quirks:        rules,
+		bodyLog:       bodyLogPrivacy(opts.Config),
+		lenientTokens: lenientTokens,
+	}
+}
+
+// Reconfigure applies reloaded DAV limits without interrupting requests in
+// progress.
+func (h *Handler) Reconfigure(cfg *config.Config) {
+	h.limits.reconfigure(cfg)
+	next := newDeletionGuard(cfg)
+	nextNotFound := newNotFoundCache(cfg)
+	h.confMu.Lock()
+	defer h.confMu.Unlock()
+	if cfg != nil {
+		h.quirks = cfg.DAV.ClientQuirks
+		h.lenientTokens = !cfg.DAV.StrictSyncTokens
+		h.features = cfg
+	}
+	h.bodyLog = bodyLogPrivacy(cfg)
+	if nextNotFound != nil && h.notFound != nil {
+		h.notFound.reconfigure(cfg)
+	} else {
+		h.notFound = nextNotFound
+	}
+	if next != nil && h.deletions != nil {
+		h.deletions.reconfigure(cfg)
+		return
+	}
+	h.deletions = next
+}
+
+// featureFlag reports whether the feature flag name is on for user.
+func (h *Handler) featureFlag(ctx context.Context, user *store.User, name string) bool {
+	h.confMu.RLock()
+	cfg := h.features
+	h.confMu.RUnlock()
+	if cfg == nil {
+		cfg = h.cfg
+	}
+	return flags.Enabled(ctx, cfg, h.store, user, name)
+}
+
+// lenientSyncTokens reports whether a well-formed but stale sync token is
+// answered with a full sync instead of a valid-sync-token error.
+func (h *Handler) lenientSyncTokens() bool {
+	h.confMu.RLock()
+	defer h.confMu.RUnlock()
+	return h.lenientTokens
+}
+
+func (h *Handler) deletionGuard() *deletionGuard {
+	h.confMu.RLock()
+	defer h.confMu.RUnlock()
+	return h.deletions
+}
+
+func (h *Handler) notFoundCache() *notFoundCache {
+	h.confMu.RLock()
+	defer h.confMu.RUnlock()
+	return h.notFound
 }
 
 // logger returns a usable logger, lazily creating a no-op one so handlers never
@@ -93,7 +189,7 @@ func (h *Handler) handleRegisteredMethod(w http.ResponseWriter, r *http.Request)
 	if route.options.Auth != MethodAuthNone {
 		if _, ok := auth.UserFromContext(r.Context()); !ok {
 			h.logger().Error("handleRegisteredMethod", "unauthenticated request for %s %s", r.Method, r.URL.Path)
-			http.Error(w, "missing user", http.StatusUnauthorized)
+			writeDAVError(w, http.StatusUnauthorized, "missing user")
 			return true
 		}
 	}
@@ -105,6 +201,11 @@ func (h *Handler) handleRegisteredMethod(w http.ResponseWriter, r *http.Request)
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger().Debug("ServeHTTP", "%s %s", r.Method, r.URL.Path)
 	switch r.Method {
+	case http.MethodHead, http.MethodGet, "PROPFIND", "REPORT":
+		// Read-only methods may be served by the read replica.
+		r = r.WithContext(store.WithReplicaReads(r.Context()))
+	}
+	switch r.Method {
 	case http.MethodOptions:
 		h.Options(w, r)
 	case http.MethodHead:
@@ -121,6 +222,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.Mkcalendar(w, r)
 	case http.MethodPut:
 		h.Put(w, r)
+	case http.MethodPost:
+		h.Post(w, r)
 	case http.MethodDelete:
 		h.Delete(w, r)
 	case "REPORT":
@@ -139,7 +242,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if h.handleRegisteredMethod(w, r) {
 			return
 		}
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeDAVError(w, http.StatusMethodNotAllowed, "method not allowed")
 	}
 }
 
@@ -12,6 +12,7 @@ import (
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 )
 
 type extensionFunc func(*Registry)
@@ -230,7 +231,7 @@ func TestCollectionContributorOnlyAppliesToExactParent(t *testing.T) {
 	s := NewServer(Options{
 		Config: &config.Config{},
 		Store: &store.Store{
-			Calendars: &fakeCalendarRepo{},
+			Calendars: &storetest.Calendars{},
 		},
 		Extensions: []Extension{extensionFunc(func(r *Registry) {
 			r.RegisterCollectionContributor("/dav", func(ctx RequestContext) ([]Collection, error) {
@@ -415,16 +416,16 @@ func TestExtensionOptionsAuthPolicyIsExplicit(t *testing.T) {
 }
 
 func TestExtensionPutValidatorRejectsCalendarObject(t *testing.T) {
-	calRepo := &fakeCalendarRepo{
-		accessible: []store.CalendarAccess{
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
 			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true},
 		},
-		calendars: map[int64]*store.Calendar{
+		Calendars: map[int64]*store.Calendar{
 			2: {ID: 2, UserID: 1, Name: "Work"},
 		},
 	}
-	eventRepo := &fakeEventRepo{
-		events: map[string]*store.Event{
+	eventRepo := &storetest.Events{
+		Events: map[string]*store.Event{
 			"2:event": {CalendarID: 2, UID: "event", ResourceName: "event", RawICAL: validCalendarObject("event"), ETag: "old"},
 		},
 	}
@@ -453,7 +454,7 @@ func TestExtensionPutValidatorRejectsCalendarObject(t *testing.T) {
 	if !strings.Contains(rec.Body.String(), "blocked by extension") {
 		t.Fatalf("PUT body = %q", rec.Body.String())
 	}
-	if eventRepo.events["2:event"].ETag != "old" {
+	if eventRepo.Events["2:event"].ETag != "old" {
 		t.Fatalf("validator rejection should prevent persistence")
 	}
 }
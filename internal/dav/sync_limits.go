@@ -0,0 +1,200 @@
+package dav
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	defaultSyncPageSize     = 250
+	defaultFullResyncWindow = 15 * time.Minute
+	// defaultReportQueueWait is how long a REPORT waits for a free
+	// per-account slot before the client is told to retry.
+	defaultReportQueueWait = 10 * time.Second
+)
+
+// limitedReports are the REPORTs that take a per-account slot: those that
+// read whole collections and so cost the database the most. Principal and
+// ACL reports stay unlimited so a busy account can still discover its
+// collections.
+var limitedReports = map[string]bool{
+	"calendar-query":       true,
+	"calendar-multiget":    true,
+	"free-busy-query":      true,
+	"addressbook-query":    true,
+	"addressbook-multiget": true,
+	"sync-collection":      true,
+}
+
+// syncLimiter applies per-account limits to REPORT processing: a cap on
+// concurrent REPORTs, and paging of sync-collection responses once an account
+// starts resyncing whole collections more often than the configured budget.
+// A nil *syncLimiter imposes no concurrency or storm limits.
+type syncLimiter struct {
+	concurrency  int
+	queueWait    time.Duration
+	pageSize     int
+	resyncLimit  int
+	resyncWindow time.Duration
+	now          func() time.Time
+
+	mu        sync.Mutex
+	slots     map[int64]chan struct{}
+	fullSyncs map[int64][]time.Time
+}
+
+func newSyncLimiter(cfg *config.Config) *syncLimiter {
+	l := &syncLimiter{
+		now:       time.Now,
+		slots:     make(map[int64]chan struct{}),
+		fullSyncs: make(map[int64][]time.Time),
+	}
+	l.reconfigure(cfg)
+	return l
+}
+
+// reconfigure applies the limits in cfg. Changing the concurrency cap starts
+// new per-account slot pools; REPORTs already running release into the old
+// ones.
+func (l *syncLimiter) reconfigure(cfg *config.Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	concurrency := 0
+	l.queueWait = defaultReportQueueWait
+	l.pageSize = defaultSyncPageSize
+	l.resyncWindow = defaultFullResyncWindow
+	l.resyncLimit = 0
+	if cfg != nil {
+		concurrency = cfg.DAV.ReportConcurrency
+		if cfg.DAV.ReportQueueWait > 0 {
+			l.queueWait = cfg.DAV.ReportQueueWait
+		}
+		l.resyncLimit = cfg.DAV.FullResyncLimit
+		if cfg.DAV.SyncPageSize > 0 {
+			l.pageSize = cfg.DAV.SyncPageSize
+		}
+		if cfg.DAV.FullResyncWindow > 0 {
+			l.resyncWindow = cfg.DAV.FullResyncWindow
+		}
+	}
+	if concurrency != l.concurrency {
+		l.concurrency = concurrency
+		l.slots = make(map[int64]chan struct{})
+	}
+}
+
+// acquireReport reserves one of the account's REPORT slots, waiting up to the
+// configured queue wait. The returned release func must be called when ok is
+// true.
+func (l *syncLimiter) acquireReport(ctx context.Context, userID int64) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	l.mu.Lock()
+	if l.concurrency <= 0 {
+		l.mu.Unlock()
+		return func() {}, true
+	}
+	slot, ok := l.slots[userID]
+	if !ok {
+		slot = make(chan struct{}, l.concurrency)
+		l.slots[userID] = slot
+	}
+	wait := l.queueWait
+	l.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, true
+	default:
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// noteFullResync records a token-less sync-collection for the account and
+// reports whether it exceeds the account's full-resync budget.
+func (l *syncLimiter) noteFullResync(userID int64) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.resyncLimit <= 0 {
+		return false
+	}
+	now := l.now()
+	cutoff := now.Add(-l.resyncWindow)
+	recent := l.fullSyncs[userID][:0]
+	for _, ts := range l.fullSyncs[userID] {
+		if ts.After(cutoff) {
+			recent = append(recent, ts)
+		}
+	}
+	recent = append(recent, now)
+	l.fullSyncs[userID] = recent
+	return len(recent) > l.resyncLimit
+}
+
+// syncPageSize is the number of members returned per paged sync-collection.
+func (l *syncLimiter) syncPageSize() int {
+	if l == nil {
+		return defaultSyncPageSize
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pageSize
+}
+
+type syncPagingKey struct{}
+
+// withSyncPaging marks a sync-collection request as throttled, so its
+// response is split into pages linked by intermediate sync tokens.
+func withSyncPaging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, syncPagingKey{}, true)
+}
+
+func syncPagingRequested(ctx context.Context) bool {
+	paged, _ := ctx.Value(syncPagingKey{}).(bool)
+	return paged
+}
+
+// sortEventsForSyncPaging orders events by (last modified, resource name),
+// the order that page cursors are expressed in.
+func sortEventsForSyncPaging(events []store.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].LastModified.Equal(events[j].LastModified) {
+			return events[i].LastModified.Before(events[j].LastModified)
+		}
+		return eventResourceName(events[i]) < eventResourceName(events[j])
+	})
+}
+
+// eventsAfterSyncCursor keeps events that sort after the (ts, cursor) position
+// of a previous page.
+func eventsAfterSyncCursor(events []store.Event, ts time.Time, cursor string) []store.Event {
+	kept := events[:0]
+	for _, ev := range events {
+		if ev.LastModified.Before(ts) {
+			continue
+		}
+		if ev.LastModified.Equal(ts) && eventResourceName(ev) <= cursor {
+			continue
+		}
+		kept = append(kept, ev)
+	}
+	return kept
+}
@@ -0,0 +1,190 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestParseSyncTokenWithCursor(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	info, err := parseSyncToken(buildSyncPageToken("cal", 7, ts, "weird:name.ics"))
+	if err != nil {
+		t.Fatalf("parseSyncToken returned error: %v", err)
+	}
+	if info.Kind != "cal" || info.ID != 7 || !info.Timestamp.Equal(ts) || info.Cursor != "weird:name.ics" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if _, err := parseSyncToken(buildSyncToken("cal", 7, ts) + ":!!"); err == nil {
+		t.Fatal("expected malformed cursor to be rejected")
+	}
+}
+
+func TestSyncLimiterFullResyncBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := &syncLimiter{resyncLimit: 2, resyncWindow: time.Minute, now: func() time.Time { return now }, fullSyncs: map[int64][]time.Time{}}
+	if l.noteFullResync(1) || l.noteFullResync(1) {
+		t.Fatal("expected resyncs within budget to pass")
+	}
+	if !l.noteFullResync(1) {
+		t.Fatal("expected third resync in window to be throttled")
+	}
+	if l.noteFullResync(2) {
+		t.Fatal("expected budgets to be per account")
+	}
+	now = now.Add(2 * time.Minute)
+	if l.noteFullResync(1) {
+		t.Fatal("expected budget to recover after the window")
+	}
+}
+
+func TestSyncLimiterCapsConcurrentReports(t *testing.T) {
+	l := &syncLimiter{concurrency: 1, slots: map[int64]chan struct{}{}}
+	release, ok := l.acquireReport(context.Background(), 1)
+	if !ok {
+		t.Fatal("expected first report to get a slot")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := l.acquireReport(ctx, 1); ok {
+		t.Fatal("expected second concurrent report to be refused")
+	}
+	if _, ok := l.acquireReport(ctx, 2); !ok {
+		t.Fatal("expected another account to be unaffected")
+	}
+	release()
+	if _, ok := l.acquireReport(context.Background(), 1); !ok {
+		t.Fatal("expected slot to be reusable after release")
+	}
+}
+
+func TestSyncLimiterReconfigure(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DAV.ReportConcurrency = 1
+	l := newSyncLimiter(cfg)
+	release, ok := l.acquireReport(context.Background(), 1)
+	if !ok {
+		t.Fatal("expected first report to get a slot")
+	}
+
+	cfg = &config.Config{}
+	cfg.DAV.ReportConcurrency = 2
+	cfg.DAV.SyncPageSize = 50
+	cfg.DAV.FullResyncLimit = 1
+	l.reconfigure(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 2; i++ {
+		if _, ok := l.acquireReport(ctx, 1); !ok {
+			t.Fatalf("report %d refused, want the raised cap applied", i+1)
+		}
+	}
+	release()
+	if l.syncPageSize() != 50 || l.noteFullResync(1) || !l.noteFullResync(1) {
+		t.Fatal("expected the new page size and resync budget to apply")
+	}
+
+	l.reconfigure(&config.Config{})
+	if _, ok := l.acquireReport(ctx, 1); !ok || l.syncPageSize() != defaultSyncPageSize || l.noteFullResync(1) {
+		t.Fatal("expected the limits to be lifted")
+	}
+}
+
+func TestReportConcurrencyOnlyLimitsCollectionReports(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.DAV.ReportConcurrency = 1
+	cfg.DAV.ReportQueueWait = 10 * time.Millisecond
+	h := &Handler{
+		store: &store.Store{
+			Calendars: &storetest.Calendars{Accessible: []store.CalendarAccess{{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work"}, Editor: true}}},
+			Events:    &storetest.Events{Events: map[string]*store.Event{}},
+		},
+		limits: newSyncLimiter(cfg),
+	}
+	release, ok := h.limits.acquireReport(context.Background(), 1)
+	if !ok {
+		t.Fatal("expected to take the account's only slot")
+	}
+	defer release()
+
+	report := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("REPORT", target, strings.NewReader(body))
+		req.Header.Set("Depth", "1")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+		rr := httptest.NewRecorder()
+		h.Report(rr, req)
+		return rr
+	}
+
+	rr := report("/dav/calendars/2/", `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop><C:filter><C:comp-filter name="VCALENDAR"/></C:filter></C:calendar-query>`)
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("calendar-query over the cap: status %d Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	rr = report("/dav/", `<D:expand-property xmlns:D="DAV:"><D:property name="current-user-principal"/></D:expand-property>`)
+	if rr.Code == http.StatusServiceUnavailable {
+		t.Fatal("expected expand-property to bypass the REPORT cap")
+	}
+}
+
+func TestCalendarSyncCollectionPagesThrottledResync(t *testing.T) {
+	ts := time.Now().Add(-time.Minute).UTC()
+	repo := &storetest.Events{Events: map[string]*store.Event{
+		"2:a": {CalendarID: 2, UID: "a", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "1", LastModified: ts},
+		"2:b": {CalendarID: 2, UID: "b", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "2", LastModified: ts},
+		"2:c": {CalendarID: 2, UID: "c", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "3", LastModified: ts.Add(time.Second)},
+	}}
+	h := &Handler{
+		store:  &store.Store{Events: repo, DeletedResources: &storetest.DeletedResources{}},
+		limits: &syncLimiter{pageSize: 2},
+	}
+	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Test", CTag: 2, UpdatedAt: time.Now()}, Editor: true}
+	user := &store.User{ID: 1}
+	members := func(responses []response) ([]string, bool) {
+		var hrefs []string
+		truncated := false
+		for _, resp := range responses[1:] {
+			if resp.Error != nil && resp.Error.NumberOfMatchesWithinLimits != nil {
+				truncated = true
+				continue
+			}
+			hrefs = append(hrefs, resp.Href)
+		}
+		return hrefs, truncated
+	}
+
+	report := reportRequest{XMLName: xml.Name{Local: "sync-collection"}}
+	responses, token, err := h.calendarReportResponses(withSyncPaging(context.Background()), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", report)
+	if err != nil {
+		t.Fatalf("sync-collection returned error: %v", err)
+	}
+	hrefs, truncated := members(responses)
+	if !truncated || len(hrefs) != 2 || hrefs[0] != "/dav/calendars/2/a.ics" || hrefs[1] != "/dav/calendars/2/b.ics" {
+		t.Fatalf("expected first page of a and b with 507, got %v truncated=%v", hrefs, truncated)
+	}
+	if info, err := parseSyncToken(token); err != nil || info.Cursor != "b" {
+		t.Fatalf("expected page token with cursor, got %q (%v)", token, err)
+	}
+
+	report.SyncToken = token
+	responses, token, err = h.calendarReportResponses(context.Background(), user, cal, "/dav/principals/1/", "/dav/calendars/2/", "/dav/calendars/2/", report)
+	if err != nil {
+		t.Fatalf("resumed sync-collection returned error: %v", err)
+	}
+	hrefs, truncated = members(responses)
+	if truncated || len(hrefs) != 1 || hrefs[0] != "/dav/calendars/2/c.ics" {
+		t.Fatalf("expected remaining member c, got %v truncated=%v", hrefs, truncated)
+	}
+	if info, err := parseSyncToken(token); err != nil || info.Cursor != "" {
+		t.Fatalf("expected final token without cursor, got %q (%v)", token, err)
+	}
+}
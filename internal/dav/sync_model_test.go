@@ -0,0 +1,295 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+	"github.com/jw6ventures/jw6-go-utils/database"
+)
+
+// syncModelDSNEnv names a disposable PostgreSQL database for
+// TestSyncCollectionStateMachine. The schema is created if missing.
+const syncModelDSNEnv = "CALCARD_TEST_DATABASE_URL"
+
+const (
+	syncModelSeeds     = 8
+	syncModelSteps     = 60
+	syncModelResources = 6
+)
+
+type syncModelMultistatus struct {
+	SyncToken string `xml:"DAV: sync-token"`
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Status   string `xml:"DAV: status"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			ETag   string `xml:"DAV: prop>getetag"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// syncModelClient is an RFC 6578 client: it holds the ETag of every member it
+// has been told about and the token to sync from next.
+type syncModelClient struct {
+	token   string
+	members map[string]string
+}
+
+type syncModel struct {
+	t     *testing.T
+	h     *Handler
+	st    *store.Store
+	user  *store.User
+	calID int64
+	rng   *rand.Rand
+	rev   int
+}
+
+// TestSyncCollectionStateMachine drives random sequences of PUT, DELETE and
+// sync-collection against the PostgreSQL store. After every sync the model
+// client must hold exactly the server's members and ETags, every write must
+// change the collection ctag, and every content change the member's ETag.
+func TestSyncCollectionStateMachine(t *testing.T) {
+	dsn := os.Getenv(syncModelDSNEnv)
+	if dsn == "" {
+		t.Skipf("set %s to run against PostgreSQL", syncModelDSNEnv)
+	}
+	manager := database.NewManager(database.Config{
+		Driver:           "postgres",
+		ConnString:       dsn,
+		SchemaPath:       "../../db.sql",
+		SchemaCheckTable: "users",
+	})
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer manager.Close()
+	st := store.New(manager.DB)
+	h := NewServer(Options{Config: &config.Config{}, Store: st})
+
+	ctx := context.Background()
+	subject := fmt.Sprintf("sync-model-%d", time.Now().UnixNano())
+	user, err := st.Users.UpsertOAuthUser(ctx, subject, subject+"@example.com")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for seed := uint64(1); seed <= syncModelSeeds; seed++ {
+		t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+			cal, err := st.Calendars.Create(ctx, store.Calendar{UserID: user.ID, Name: fmt.Sprintf("%s seed %d", subject, seed)})
+			if err != nil {
+				t.Fatalf("create calendar: %v", err)
+			}
+			defer st.Calendars.Delete(ctx, user.ID, cal.ID)
+			m := &syncModel{t: t, h: h, st: st, user: user, calID: cal.ID, rng: rand.New(rand.NewPCG(seed, 0))}
+			m.run()
+		})
+	}
+}
+
+func (m *syncModel) run() {
+	client := &syncModelClient{members: map[string]string{}}
+	m.sync(client)
+	m.checkConverged(client)
+
+	for step := 0; step < syncModelSteps; step++ {
+		name := fmt.Sprintf("member-%d", m.rng.IntN(syncModelResources))
+		switch op := m.rng.IntN(10); {
+		case op < 5:
+			m.put(name)
+		case op < 8:
+			m.delete(name)
+		default:
+			m.sync(client)
+			m.checkConverged(client)
+		}
+	}
+	m.sync(client)
+	m.checkConverged(client)
+
+	// A sync from a fresh token must report nothing further.
+	token := client.token
+	if changed := m.sync(client); changed != 0 {
+		m.t.Fatalf("sync from %s with no writes reported %d changes", token, changed)
+	}
+}
+
+func (m *syncModel) memberHref(name string) string {
+	return fmt.Sprintf("/dav/calendars/%d/%s.ics", m.calID, name)
+}
+
+func (m *syncModel) do(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	req = req.WithContext(auth.WithUser(req.Context(), m.user))
+	rr := httptest.NewRecorder()
+	switch method {
+	case http.MethodPut:
+		m.h.Put(rr, req)
+	case http.MethodDelete:
+		m.h.Delete(rr, req)
+	default:
+		m.h.Report(rr, req)
+	}
+	return rr
+}
+
+func (m *syncModel) ctag() int64 {
+	cal, err := m.st.Calendars.GetByID(context.Background(), m.calID)
+	if err != nil || cal == nil {
+		m.t.Fatalf("load calendar: %v", err)
+	}
+	return cal.CTag
+}
+
+func (m *syncModel) serverMembers() map[string]string {
+	events, err := m.st.Events.ListForCalendar(context.Background(), m.calID)
+	if err != nil {
+		m.t.Fatalf("list events: %v", err)
+	}
+	members := map[string]string{}
+	for _, ev := range events {
+		members[strings.TrimSuffix(eventResourceName(ev), ".ics")] = ev.ETag
+	}
+	return members
+}
+
+func (m *syncModel) put(name string) {
+	before := m.serverMembers()[name]
+	ctag := m.ctag()
+	m.rev++
+	body := fmt.Sprintf("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//calcard//sync model//EN\r\nBEGIN:VEVENT\r\nUID:%s\r\nDTSTAMP:20240101T000000Z\r\nDTSTART:20240101T%02d0000Z\r\nSUMMARY:rev %d\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", name, m.rev%24, m.rev)
+	rr := m.do(http.MethodPut, m.memberHref(name), body, map[string]string{"Content-Type": "text/calendar"})
+	if rr.Code != http.StatusCreated && rr.Code != http.StatusNoContent {
+		m.t.Fatalf("PUT %s: %d %s", name, rr.Code, rr.Body.String())
+	}
+	after := m.serverMembers()[name]
+	if after == "" || after == before {
+		m.t.Fatalf("PUT %s: ETag %q did not change from %q", name, after, before)
+	}
+	if got := strings.Trim(rr.Header().Get("ETag"), `"`); got != "" && got != after {
+		m.t.Fatalf("PUT %s: response ETag %q, stored %q", name, got, after)
+	}
+	if m.ctag() == ctag {
+		m.t.Fatalf("PUT %s: ctag %d unchanged", name, ctag)
+	}
+}
+
+func (m *syncModel) delete(name string) {
+	_, exists := m.serverMembers()[name]
+	ctag := m.ctag()
+	rr := m.do(http.MethodDelete, m.memberHref(name), "", nil)
+	if !exists {
+		if rr.Code != http.StatusNotFound {
+			m.t.Fatalf("DELETE missing %s: %d", name, rr.Code)
+		}
+		return
+	}
+	if rr.Code != http.StatusNoContent && rr.Code != http.StatusOK {
+		m.t.Fatalf("DELETE %s: %d %s", name, rr.Code, rr.Body.String())
+	}
+	if _, still := m.serverMembers()[name]; still {
+		m.t.Fatalf("DELETE %s: member still stored", name)
+	}
+	if m.ctag() == ctag {
+		m.t.Fatalf("DELETE %s: ctag %d unchanged", name, ctag)
+	}
+}
+
+// sync runs sync-collection from the client's token, following truncated
+// pages, applies the reported changes and returns how many it saw.
+func (m *syncModel) sync(client *syncModelClient) int {
+	changed := 0
+	for page := 0; page < 100; page++ {
+		body := fmt.Sprintf(`<D:sync-collection xmlns:D="DAV:"><D:sync-token>%s</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`, client.token)
+		rr := m.do("REPORT", fmt.Sprintf("/dav/calendars/%d/", m.calID), body, nil)
+		if rr.Code != http.StatusMultiStatus {
+			m.t.Fatalf("sync from %q: %d %s", client.token, rr.Code, rr.Body.String())
+		}
+		var ms syncModelMultistatus
+		if err := xml.Unmarshal(rr.Body.Bytes(), &ms); err != nil {
+			m.t.Fatalf("sync from %q: %v", client.token, err)
+		}
+		if ms.SyncToken == "" {
+			m.t.Fatalf("sync from %q returned no token", client.token)
+		}
+		truncated := false
+		for _, resp := range ms.Responses {
+			if !strings.HasSuffix(resp.Href, ".ics") {
+				truncated = truncated || strings.Contains(resp.Status, "507")
+				continue
+			}
+			name := strings.TrimSuffix(path.Base(resp.Href), ".ics")
+			changed++
+			if strings.Contains(resp.Status, "404") {
+				delete(client.members, name)
+				continue
+			}
+			etag := ""
+			for _, ps := range resp.Propstat {
+				if strings.Contains(ps.Status, "200") {
+					etag = strings.Trim(ps.ETag, `"`)
+				}
+			}
+			if etag == "" {
+				m.t.Fatalf("sync from %q: %s reported without an ETag", client.token, resp.Href)
+			}
+			client.members[name] = etag
+		}
+		client.token = ms.SyncToken
+		if !truncated {
+			return changed
+		}
+	}
+	m.t.Fatal("sync did not finish within 100 pages")
+	return changed
+}
+
+func (m *syncModel) checkConverged(client *syncModelClient) {
+	server := m.serverMembers()
+	for name, etag := range server {
+		if got, ok := client.members[name]; !ok || got != etag {
+			m.t.Fatalf("client has %s=%q, server %q (token %s)", name, got, etag, client.token)
+		}
+	}
+	for name := range client.members {
+		if _, ok := server[name]; !ok {
+			m.t.Fatalf("client still has deleted %s (token %s)", name, client.token)
+		}
+	}
+}
+
+func TestSyncCollectionOmitsTombstoneOfRecreatedMember(t *testing.T) {
+	now := time.Now().UTC()
+	calRepo := &storetest.Calendars{Accessible: []store.CalendarAccess{{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", UpdatedAt: now}, Editor: true}}}
+	events := &storetest.Events{Events: map[string]*store.Event{
+		"2:a": {CalendarID: 2, UID: "a", ResourceName: "a", RawICAL: "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", ETag: "new", LastModified: now},
+	}}
+	deleted := &storetest.DeletedResources{Deleted: []store.DeletedResource{
+		{ResourceType: "event", CollectionID: 2, UID: "a", ResourceName: "a", DeletedAt: now.Add(-time.Second)},
+	}}
+	st := &store.Store{Calendars: calRepo, Events: events, DeletedResources: deleted}
+	m := &syncModel{t: t, h: &Handler{store: st}, st: st, user: &store.User{ID: 1}, calID: 2}
+
+	client := &syncModelClient{token: buildSyncToken("cal", 2, now.Add(-time.Minute)), members: map[string]string{}}
+	m.sync(client)
+	if client.members["a"] != "new" {
+		t.Fatalf("expected the re-created member to survive the sync, got %v", client.members)
+	}
+}
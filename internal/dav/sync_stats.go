@@ -0,0 +1,193 @@
+package dav
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// calcardNS is the namespace of CalCard-specific live properties.
+const calcardNS = "urn:calcard:dav"
+
+var (
+	propResourceCount = xml.Name{Space: calcardNS, Local: "resource-count"}
+	propDataSize      = xml.Name{Space: calcardNS, Local: "data-size"}
+	propLastSyncedAt  = xml.Name{Space: calcardNS, Local: "last-synced-at"}
+	propSyncDevices   = xml.Name{Space: calcardNS, Local: "sync-devices"}
+	propChecksum      = xml.Name{Space: calcardNS, Local: "checksum"}
+)
+
+// syncDevicesProp lists the requesting user's devices that have synced a
+// collection, most recent first.
+type syncDevicesProp struct {
+	Devices []syncDeviceProp `xml:"urn:calcard:dav device"`
+}
+
+type syncDeviceProp struct {
+	Name         string    `xml:"urn:calcard:dav name"`
+	UserAgent    string    `xml:"urn:calcard:dav user-agent,omitempty"`
+	LastSyncedAt string    `xml:"urn:calcard:dav last-synced-at"`
+	Current      *struct{} `xml:"urn:calcard:dav current,omitempty"`
+}
+
+// syncDeviceKey identifies the device making the request: its app password
+// when it authenticated with one, otherwise its User-Agent.
+func syncDeviceKey(r *http.Request) (string, *int64) {
+	if id, ok := auth.AppPasswordIDFromContext(r.Context()); ok {
+		return "app-password:" + strconv.FormatInt(id, 10), &id
+	}
+	return "ua:" + r.UserAgent(), nil
+}
+
+// recordCollectionSync notes that the requesting device read the collection.
+// Failures are logged and never fail the REPORT.
+func (h *Handler) recordCollectionSync(r *http.Request, user *store.User, collectionType string, collectionID int64) {
+	if h.store == nil || h.store.CollectionSyncs == nil || user == nil {
+		return
+	}
+	deviceKey, appPasswordID := syncDeviceKey(r)
+	if err := h.store.CollectionSyncs.Record(r.Context(), store.CollectionSync{
+		CollectionType: collectionType,
+		CollectionID:   collectionID,
+		UserID:         user.ID,
+		DeviceKey:      deviceKey,
+		AppPasswordID:  appPasswordID,
+		UserAgent:      r.UserAgent(),
+	}); err != nil {
+		h.logger().Warn("Report", "failed to record sync of %s %d: %v", collectionType, collectionID, err)
+	}
+}
+
+// requestedSyncStats returns the CalCard sync statistics named in a PROPFIND.
+// They are only computed on request, never for allprop, since each one costs
+// a query per collection.
+func requestedSyncStats(req *propfindRequest) map[xml.Name]bool {
+	if req == nil || req.Prop == nil {
+		return nil
+	}
+	var wanted map[xml.Name]bool
+	for _, name := range req.Prop.CustomXML {
+		switch name {
+		case propResourceCount, propDataSize, propLastSyncedAt, propSyncDevices, propChecksum:
+			if wanted == nil {
+				wanted = make(map[xml.Name]bool)
+			}
+			wanted[name] = true
+		}
+	}
+	return wanted
+}
+
+// addSyncStatsProperties fills the requested CalCard statistics on calendar
+// and address book collection responses.
+func (h *Handler) addSyncStatsProperties(ctx context.Context, r *http.Request, user *store.User, responses []response, req *propfindRequest) error {
+	wanted := requestedSyncStats(req)
+	if len(wanted) == 0 || h.store == nil || h.store.CollectionSyncs == nil || user == nil {
+		return nil
+	}
+	deviceKey, _ := syncDeviceKey(r)
+	for i := range responses {
+		collectionType, collectionID, ok := h.responseCollection(ctx, user, responses[i])
+		if !ok {
+			continue
+		}
+		p := &responses[i].Propstat[0].Prop
+
+		if wanted[propResourceCount] || wanted[propDataSize] {
+			usage, err := h.store.CollectionSyncs.Usage(ctx, collectionType, collectionID)
+			if err != nil {
+				return err
+			}
+			if wanted[propResourceCount] {
+				p.setCustomXMLProperty(XMLProperty{Name: propResourceCount, Value: strconv.FormatInt(usage.ResourceCount, 10)})
+			}
+			if wanted[propDataSize] {
+				p.setCustomXMLProperty(XMLProperty{Name: propDataSize, Value: strconv.FormatInt(usage.DataSize, 10)})
+			}
+		}
+
+		if wanted[propChecksum] {
+			sum, err := h.store.CollectionSyncs.Checksum(ctx, collectionType, collectionID)
+			if err != nil {
+				return err
+			}
+			p.setCustomXMLProperty(XMLProperty{Name: propChecksum, Value: sum})
+		}
+
+		if wanted[propLastSyncedAt] || wanted[propSyncDevices] {
+			syncs, err := h.store.CollectionSyncs.ListForCollection(ctx, user.ID, collectionType, collectionID)
+			if err != nil {
+				return err
+			}
+			devices := syncDevicesProp{Devices: []syncDeviceProp{}}
+			for _, s := range syncs {
+				device := syncDeviceProp{
+					Name:         syncDeviceName(s),
+					UserAgent:    s.UserAgent,
+					LastSyncedAt: s.LastSyncedAt.UTC().Format(time.RFC3339),
+				}
+				if s.DeviceKey == deviceKey {
+					device.Current = &struct{}{}
+					if wanted[propLastSyncedAt] {
+						p.setCustomXMLProperty(XMLProperty{Name: propLastSyncedAt, Value: device.LastSyncedAt})
+					}
+				}
+				devices.Devices = append(devices.Devices, device)
+			}
+			if wanted[propSyncDevices] {
+				p.setCustomXMLProperty(XMLProperty{Name: propSyncDevices, Value: devices})
+			}
+		}
+	}
+	return nil
+}
+
+// responseCollection identifies the calendar or address book collection a
+// PROPFIND response describes.
+func (h *Handler) responseCollection(ctx context.Context, user *store.User, res response) (string, int64, bool) {
+	if len(res.Propstat) == 0 {
+		return "", 0, false
+	}
+	p := res.Propstat[0].Prop
+	collectionType := ""
+	resolve := h.resolveCalendarID
+	switch {
+	case p.ResourceType.Calendar != nil:
+		collectionType = "calendar"
+	case p.ResourceType.AddressBook != nil:
+		collectionType = "addressbook"
+		resolve = h.resolveAddressBookID
+	default:
+		return "", 0, false
+	}
+	// Calendars may be listed under their slug rather than their ID.
+	segment, err := url.PathUnescape(path.Base(strings.TrimSuffix(res.Href, "/")))
+	if err != nil {
+		return "", 0, false
+	}
+	collectionID, ok, err := resolve(ctx, user, segment)
+	if err != nil || !ok || collectionID <= 0 {
+		return "", 0, false
+	}
+	return collectionType, collectionID, true
+}
+
+// syncDeviceName labels a device by its app password, or by its User-Agent
+// when it did not use one.
+func syncDeviceName(s store.CollectionSync) string {
+	if s.AppPasswordLabel != "" {
+		return s.AppPasswordLabel
+	}
+	if s.UserAgent != "" {
+		return s.UserAgent
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,135 @@
+package dav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeCollectionSyncRepo struct {
+	recorded []store.CollectionSync
+	syncs    []store.CollectionSync
+	usage    store.CollectionUsage
+	checksum string
+}
+
+func (f *fakeCollectionSyncRepo) Record(ctx context.Context, sync store.CollectionSync) error {
+	f.recorded = append(f.recorded, sync)
+	return nil
+}
+
+func (f *fakeCollectionSyncRepo) ListForCollection(ctx context.Context, userID int64, collectionType string, collectionID int64) ([]store.CollectionSync, error) {
+	var out []store.CollectionSync
+	for _, s := range f.syncs {
+		if s.UserID == userID && s.CollectionType == collectionType && s.CollectionID == collectionID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCollectionSyncRepo) ListByUser(ctx context.Context, userID int64, since time.Time) ([]store.CollectionSync, error) {
+	return f.syncs, nil
+}
+
+func (f *fakeCollectionSyncRepo) Usage(ctx context.Context, collectionType string, collectionID int64) (store.CollectionUsage, error) {
+	return f.usage, nil
+}
+
+func (f *fakeCollectionSyncRepo) Checksum(ctx context.Context, collectionType string, collectionID int64) (string, error) {
+	return f.checksum, nil
+}
+
+func TestPropfindCalendarReturnsRequestedSyncStats(t *testing.T) {
+	now := store.Now()
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: now}, Editor: true},
+		},
+	}
+	syncedAt := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	tabletID := int64(7)
+	syncRepo := &fakeCollectionSyncRepo{
+		usage:    store.CollectionUsage{ResourceCount: 42, DataSize: 12345},
+		checksum: "9f86d081",
+		syncs: []store.CollectionSync{
+			{CollectionType: "calendar", CollectionID: 2, UserID: 1, DeviceKey: "app-password:7", AppPasswordID: &tabletID, AppPasswordLabel: "Tablet", UserAgent: "DAVx5/4.3", LastSyncedAt: syncedAt},
+			{CollectionType: "calendar", CollectionID: 2, UserID: 1, DeviceKey: "ua:Thunderbird", UserAgent: "Thunderbird", LastSyncedAt: syncedAt.Add(-48 * time.Hour)},
+			{CollectionType: "calendar", CollectionID: 2, UserID: 9, DeviceKey: "ua:other", UserAgent: "Other user", LastSyncedAt: syncedAt},
+		},
+	}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, CollectionSyncs: syncRepo}}
+
+	body := `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:x="urn:calcard:dav"><d:prop>` +
+		`<x:resource-count/><x:data-size/><x:last-synced-at/><x:sync-devices/><x:checksum/><d:displayname/></d:prop></d:propfind>`
+	req := httptest.NewRequest("PROPFIND", "/dav/calendars/2/", strings.NewReader(body))
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithAppPasswordID(auth.WithUser(req.Context(), &store.User{ID: 1}), 7))
+	rr := httptest.NewRecorder()
+	h.Propfind(rr, req)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", rr.Code)
+	}
+	got := rr.Body.String()
+	for _, want := range []string{
+		">42</resource-count>",
+		">12345</data-size>",
+		">9f86d081</checksum>",
+		">2026-03-01T09:30:00Z</last-synced-at>",
+		">Tablet</name>",
+		">Thunderbird</name>",
+		"<current",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in response, got %s", want, got)
+		}
+	}
+	if strings.Contains(got, "Other user") {
+		t.Fatalf("expected other users' devices hidden, got %s", got)
+	}
+
+	// Stats are never computed for allprop.
+	req = httptest.NewRequest("PROPFIND", "/dav/calendars/2/", nil)
+	req.Header.Set("Depth", "0")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr = httptest.NewRecorder()
+	h.Propfind(rr, req)
+	if strings.Contains(rr.Body.String(), "resource-count") {
+		t.Fatalf("expected no sync stats for allprop, got %s", rr.Body.String())
+	}
+}
+
+func TestCalendarReportRecordsDeviceSync(t *testing.T) {
+	calRepo := &storetest.Calendars{
+		Accessible: []store.CalendarAccess{
+			{Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Work", CTag: 1, UpdatedAt: store.Now()}, Editor: true},
+		},
+	}
+	syncRepo := &fakeCollectionSyncRepo{}
+	h := &Handler{store: &store.Store{Calendars: calRepo, Events: &storetest.Events{}, DeletedResources: &storetest.DeletedResources{}, CollectionSyncs: syncRepo}}
+
+	body := `<?xml version="1.0"?><d:sync-collection xmlns:d="DAV:"><d:sync-token/><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`
+	req := httptest.NewRequest("REPORT", "/dav/calendars/2/", strings.NewReader(body))
+	req.Header.Set("User-Agent", "Thunderbird/128")
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1}))
+	rr := httptest.NewRecorder()
+	h.Report(rr, req)
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(syncRepo.recorded) != 1 {
+		t.Fatalf("expected one recorded sync, got %+v", syncRepo.recorded)
+	}
+	if got := syncRepo.recorded[0]; got.CollectionType != "calendar" || got.CollectionID != 2 || got.DeviceKey != "ua:Thunderbird/128" || got.AppPasswordID != nil {
+		t.Fatalf("unexpected recorded sync %+v", got)
+	}
+}
@@ -0,0 +1,126 @@
+package dav
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/quirks"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// timezonePreference applies the RFC 7809 CalDAV-Timezones request header to
+// a client's quirks: "F" asks for time zones by reference, "T" for every
+// VTIMEZONE to be included.
+func timezonePreference(r *http.Request, set quirks.Set) quirks.Set {
+	switch strings.ToUpper(strings.TrimSpace(r.Header.Get("CalDAV-Timezones"))) {
+	case "F":
+		return set&^quirks.Timezones | quirks.NoTimezones
+	case "T":
+		return set&^quirks.NoTimezones | quirks.Timezones
+	}
+	return set
+}
+
+var tzidParameter = regexp.MustCompile(`(?i);TZID=(?:"([^"]*)"|([^;:]*))[^:]*:(\d{4})?`)
+
+// adjustTimezones strips or adds VTIMEZONE components of an iCalendar
+// object as set asks. Only zones the time zone database knows are stripped,
+// since a client cannot look up a custom one, and only those can be added.
+func adjustTimezones(raw string, set quirks.Set) string {
+	strip, add := set.Has(quirks.NoTimezones), set.Has(quirks.Timezones)
+	if !strip && !add {
+		return raw
+	}
+	lines := strings.SplitAfter(raw, "\n")
+	var out []string
+	defined := map[string]bool{}
+	var block []string
+	for _, line := range lines {
+		name := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case name == "BEGIN:VTIMEZONE":
+			block = []string{line}
+			continue
+		case block == nil:
+			out = append(out, line)
+			continue
+		}
+		block = append(block, line)
+		if name != "END:VTIMEZONE" {
+			continue
+		}
+		tzid := timezoneID(strings.Join(block, ""))
+		if !strip || !knownTimezone(tzid) {
+			defined[tzid] = true
+			out = append(out, block...)
+		}
+		block = nil
+	}
+	out = append(out, block...)
+	if strip || !add {
+		return strings.Join(out, "")
+	}
+
+	years := map[string][]int{}
+	for _, line := range utils.UnfoldLines(raw) {
+		for _, m := range tzidParameter.FindAllStringSubmatch(line, -1) {
+			tzid := m[1] + m[2]
+			if defined[tzid] || !knownTimezone(tzid) {
+				continue
+			}
+			if year, err := strconv.Atoi(m[3]); err == nil {
+				years[tzid] = append(years[tzid], year)
+			} else if _, ok := years[tzid]; !ok {
+				years[tzid] = nil
+			}
+		}
+	}
+	if len(years) == 0 {
+		return strings.Join(out, "")
+	}
+	tzids := make([]string, 0, len(years))
+	for tzid := range years {
+		tzids = append(tzids, tzid)
+	}
+	sort.Strings(tzids)
+	var missing strings.Builder
+	for _, tzid := range tzids {
+		missing.WriteString(utils.GenerateVTimezone(tzid, years[tzid]...))
+	}
+	generated := missing.String()
+	if !strings.Contains(raw, "\r\n") {
+		generated = strings.ReplaceAll(generated, "\r\n", "\n")
+	}
+	// VTIMEZONEs go before the components that use them.
+	for i, line := range out {
+		name := strings.ToUpper(strings.TrimSpace(line))
+		if strings.HasPrefix(name, "BEGIN:") && name != "BEGIN:VCALENDAR" {
+			out = append(out[:i], append([]string{generated}, out[i:]...)...)
+			break
+		}
+	}
+	return strings.Join(out, "")
+}
+
+func timezoneID(component string) string {
+	for _, line := range utils.UnfoldLines(component) {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "TZID") {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// knownTimezone reports whether tzid names a zone of the time zone database
+// that needs a definition; UTC never does.
+func knownTimezone(tzid string) bool {
+	if tzid == "" || strings.EqualFold(tzid, "UTC") {
+		return false
+	}
+	_, err := time.LoadLocation(tzid)
+	return err == nil
+}
@@ -0,0 +1,201 @@
+package dav
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// clientVCards returns the vCards real clients exported, kept in
+// testdata/clients, keyed by file name.
+func clientVCards(t *testing.T) map[string]string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "clients", "*.vcf"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no client vCards: %v", err)
+	}
+	cards := make(map[string]string, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cards[filepath.Base(p)] = string(data)
+	}
+	return cards
+}
+
+func TestClientVCardsRoundTripByteForByte(t *testing.T) {
+	for name, card := range clientVCards(t) {
+		for ending, body := range map[string]string{"LF": card, "CRLF": strings.ReplaceAll(card, "\n", "\r\n")} {
+			t.Run(name+"/"+ending, func(t *testing.T) {
+				h := &Handler{store: &store.Store{
+					AddressBooks: &storetest.AddressBooks{Books: map[int64]*store.AddressBook{5: {ID: 5, UserID: 1, Name: "Contacts"}}},
+					Contacts:     &storetest.Contacts{},
+				}}
+				user := &store.User{ID: 1}
+				href := "/dav/addressbooks/5/" + name
+
+				req := httptest.NewRequest(http.MethodPut, href, strings.NewReader(body))
+				req.Header.Set("Content-Type", "text/vcard; charset=utf-8")
+				req = req.WithContext(auth.WithUser(req.Context(), user))
+				rr := httptest.NewRecorder()
+				h.Put(rr, req)
+				if rr.Code != http.StatusCreated {
+					t.Fatalf("PUT = %d: %s", rr.Code, rr.Body.String())
+				}
+				putETag := rr.Header().Get("ETag")
+
+				req = httptest.NewRequest(http.MethodGet, href, nil)
+				req = req.WithContext(auth.WithUser(req.Context(), user))
+				rr = httptest.NewRecorder()
+				h.Get(rr, req)
+				if rr.Code != http.StatusOK {
+					t.Fatalf("GET = %d: %s", rr.Code, rr.Body.String())
+				}
+				if got := rr.Body.String(); got != body {
+					t.Fatalf("GET returned a different card:\n%q\nwant\n%q", got, body)
+				}
+				if putETag == "" || rr.Header().Get("ETag") != putETag {
+					t.Fatalf("GET ETag = %q, PUT ETag = %q", rr.Header().Get("ETag"), putETag)
+				}
+			})
+		}
+	}
+}
+
+func TestClientVCardsParse(t *testing.T) {
+	tests := map[string]struct {
+		uid     string
+		version string
+		fn      string
+		// grouped are the properties exported in an itemN group, matched by
+		// their base name.
+		grouped []string
+		// photo is the property carrying an inline base64 picture, if any.
+		photo string
+	}{
+		"ios-contact.vcf": {
+			uid:     "9B1E7C3D-2A4F-4B6E-8D0C-1F2E3D4C5B6A",
+			version: "3.0",
+			fn:      "Siobhán O'Brien",
+			grouped: []string{"EMAIL", "X-ABLABEL", "ADR", "X-ABADR"},
+			photo:   "PHOTO",
+		},
+		"android-contact.vcf": {
+			uid:     "3f9a2c71-84d6-4b0e-a5c3-1e7d8f60b2a9",
+			version: "3.0",
+			fn:      "Haruto Nakamura",
+			photo:   "PHOTO",
+		},
+		"outlook-contact.vcf": {
+			uid:     "0c5b8d2e-7f41-4a93-b6e0-9d12c3a4f587",
+			version: "3.0",
+			fn:      "Dr. Jürgen Müller",
+			photo:   "X-MS-CARDPICTURE",
+		},
+		"google-contact.vcf": {
+			uid:     "c2d8e4f1-5a6b-4c7d-8e9f-0a1b2c3d4e5f",
+			version: "3.0",
+			fn:      "Amara Okafor",
+			grouped: []string{"TEL", "URL", "X-ABDATE", "X-ABLABEL"},
+			photo:   "PHOTO",
+		},
+		"davx5-contact.vcf": {
+			uid:     "urn:uuid:6f8e1a2b-3c4d-4e5f-9a0b-1c2d3e4f5a6b",
+			version: "4.0",
+			fn:      "陳偉",
+		},
+		"davx5-group.vcf": {
+			uid:     "a7c4d1e2-0b9f-4e3a-8c6d-5f2e1d0c9b8a",
+			version: "3.0",
+			fn:      "Book club",
+		},
+	}
+	cards := clientVCards(t)
+	for name := range cards {
+		if _, ok := tests[name]; !ok {
+			t.Errorf("%s has no expectations in TestClientVCardsParse", name)
+		}
+	}
+	h := &Handler{}
+	for name, tt := range tests {
+		card, ok := cards[name]
+		if !ok {
+			t.Errorf("testdata/clients/%s is missing", name)
+			continue
+		}
+		for ending, body := range map[string]string{"LF": card, "CRLF": strings.ReplaceAll(card, "\n", "\r\n")} {
+			t.Run(name+"/"+ending, func(t *testing.T) {
+				if err := h.validateVCard(body); err != nil {
+					t.Fatalf("validateVCard() error = %v", err)
+				}
+				if uid, err := extractUIDFromVCard(body); err != nil || uid != tt.uid {
+					t.Fatalf("extractUIDFromVCard() = %q, %v; want %q", uid, err, tt.uid)
+				}
+				if uid := utils.ExtractVCardUID(body); uid != tt.uid {
+					t.Fatalf("ExtractVCardUID() = %q, want %q", uid, tt.uid)
+				}
+				if version, err := extractVCardVersion(body); err != nil || version != tt.version {
+					t.Fatalf("extractVCardVersion() = %q, %v; want %q", version, err, tt.version)
+				}
+
+				props := parseVCardProperties(body)
+				var fn string
+				grouped := map[string]bool{}
+				for _, p := range props {
+					if p.Name == "FN" {
+						fn = p.Value
+					}
+					if base := vcardPropertyBaseName(p.Name); base != p.Name {
+						grouped[base] = true
+					}
+					if strings.ContainsAny(p.Raw, "\r\n") {
+						t.Fatalf("property %s was not unfolded: %q", p.Name, p.Raw)
+					}
+				}
+				if fn != tt.fn {
+					t.Fatalf("FN = %q, want %q", fn, tt.fn)
+				}
+				for _, name := range tt.grouped {
+					if !grouped[name] {
+						t.Fatalf("grouped property %s not parsed, got %v", name, grouped)
+					}
+				}
+
+				if tt.photo != "" {
+					lines := utils.ExtractVCardPropertyLines(body, tt.photo)
+					if len(lines) != 1 {
+						t.Fatalf("%s lines = %d, want 1", tt.photo, len(lines))
+					}
+					data := lines[0][strings.IndexByte(lines[0], ':')+1:]
+					if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+						t.Fatalf("%s does not unfold to valid base64: %v", tt.photo, err)
+					}
+				}
+
+				for _, issue := range contacts.CheckVCard(body) {
+					if issue.Severity == contacts.SeverityError {
+						t.Fatalf("CheckVCard() reported %s %q: %s", issue.Property, issue.Value, issue.Message)
+					}
+				}
+				if changed := contacts.ChangedFields(card, body); len(changed) != 0 {
+					t.Fatalf("ChangedFields() between line endings = %v", changed)
+				}
+				if v3 := utils.ConvertVCardTo3(body); utils.ExtractVCardUID(v3) != tt.uid {
+					t.Fatalf("ConvertVCardTo3() lost the UID: %q", v3)
+				}
+			})
+		}
+	}
+}
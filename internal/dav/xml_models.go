@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
 
 // XML response models and helpers for DAV PROPFIND/REPORT responses.
@@ -42,6 +44,7 @@ type prop struct {
 	ResourceType                  resourceType                   `xml:"d:resourcetype"`
 	GetETag                       string                         `xml:"d:getetag,omitempty"`
 	GetContentType                string                         `xml:"d:getcontenttype,omitempty"`
+	GetLastModified               string                         `xml:"d:getlastmodified,omitempty"`
 	CalendarData                  cdataString                    `xml:"cal:calendar-data,omitempty"`
 	AddressData                   cdataString                    `xml:"card:address-data,omitempty"`
 	CalendarDescription           string                         `xml:"cal:calendar-description,omitempty"`
@@ -50,6 +53,7 @@ type prop struct {
 	AddressBookDesc               string                         `xml:"card:addressbook-description,omitempty"`
 	SupportedAddressData          *supportedAddressData          `xml:"card:supported-address-data,omitempty"`
 	AddressBookMaxResourceSize    string                         `xml:"card:max-resource-size,omitempty"`
+	AddressBookMaxImageSize       string                         `xml:"card:max-image-size,omitempty"`
 	SupportedCollationSet         *supportedCollationSet         `xml:"card:supported-collation-set,omitempty"`
 	SyncToken                     string                         `xml:"d:sync-token,omitempty"`
 	CTag                          string                         `xml:"cs:getctag,omitempty"`
@@ -73,6 +77,7 @@ type prop struct {
 	LockDiscovery                 *lockDiscoveryProp             `xml:"d:lockdiscovery,omitempty"`
 	SupportedLock                 *supportedLockProp             `xml:"d:supportedlock,omitempty"`
 	Owner                         *hrefProp                      `xml:"d:owner,omitempty"`
+	Invite                        *inviteProp                    `xml:"cs:invite,omitempty"`
 	ACL                           *aclProp                       `xml:"d:acl,omitempty"`
 	SupportedPrivilegeSet         *supportedPrivilegeSetProp     `xml:"d:supported-privilege-set,omitempty"`
 	PrincipalCollectionSet        *hrefListProp                  `xml:"d:principal-collection-set,omitempty"`
@@ -112,13 +117,17 @@ func (p XMLProperty) MarshalXML(enc *xml.Encoder, start xml.StartElement) error
 // cdataString wraps string content in CDATA for raw XML output.
 type cdataString string
 
+// MarshalXML writes c as a CDATA section. encoding/xml does not check CDATA
+// content, so characters XML cannot carry, which data stored before PUT
+// stripped them may still hold, are removed here rather than breaking the
+// whole multistatus.
 func (c cdataString) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	if c == "" {
 		return nil
 	}
 	return e.EncodeElement(struct {
 		S string `xml:",cdata"`
-	}{S: string(c)}, start)
+	}{S: utils.SanitizeText(string(c))}, start)
 }
 
 type resourceType struct {
@@ -189,6 +198,7 @@ type propfindPropQuery struct {
 	ResourceType                  *struct{}         `xml:"DAV: resourcetype"`
 	GetETag                       *struct{}         `xml:"DAV: getetag"`
 	GetContentType                *struct{}         `xml:"DAV: getcontenttype"`
+	GetLastModified               *struct{}         `xml:"DAV: getlastmodified"`
 	CalendarData                  *struct{}         `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
 	AddressData                   *addressDataQuery `xml:"urn:ietf:params:xml:ns:carddav address-data"`
 	CalendarDescription           *struct{}         `xml:"urn:ietf:params:xml:ns:caldav calendar-description"`
@@ -197,6 +207,7 @@ type propfindPropQuery struct {
 	AddressBookDesc               *struct{}         `xml:"urn:ietf:params:xml:ns:carddav addressbook-description"`
 	SupportedAddressData          *struct{}         `xml:"urn:ietf:params:xml:ns:carddav supported-address-data"`
 	AddressBookMaxResourceSize    *struct{}         `xml:"urn:ietf:params:xml:ns:carddav max-resource-size"`
+	AddressBookMaxImageSize       *struct{}         `xml:"urn:ietf:params:xml:ns:carddav max-image-size"`
 	SupportedCollationSet         *struct{}         `xml:"urn:ietf:params:xml:ns:carddav supported-collation-set"`
 	SyncToken                     *struct{}         `xml:"DAV: sync-token"`
 	CTag                          *struct{}         `xml:"http://calendarserver.org/ns/ getctag"`
@@ -220,6 +231,7 @@ type propfindPropQuery struct {
 	LockDiscovery                 *struct{}         `xml:"DAV: lockdiscovery"`
 	SupportedLock                 *struct{}         `xml:"DAV: supportedlock"`
 	Owner                         *struct{}         `xml:"DAV: owner"`
+	Invite                        *struct{}         `xml:"http://calendarserver.org/ns/ invite"`
 	ACLProp                       *struct{}         `xml:"DAV: acl"`
 	SupportedPrivilegeSet         *struct{}         `xml:"DAV: supported-privilege-set"`
 	PrincipalCollectionSet        *struct{}         `xml:"DAV: principal-collection-set"`
@@ -311,6 +323,7 @@ type proppatchProp struct {
 	AddressBookDesc            *string                `xml:"urn:ietf:params:xml:ns:carddav addressbook-description"`
 	SupportedAddressData       *supportedAddressData  `xml:"urn:ietf:params:xml:ns:carddav supported-address-data"`
 	AddressBookMaxResourceSize *string                `xml:"urn:ietf:params:xml:ns:carddav max-resource-size"`
+	AddressBookMaxImageSize    *string                `xml:"urn:ietf:params:xml:ns:carddav max-image-size"`
 	SupportedCollationSet      *supportedCollationSet `xml:"urn:ietf:params:xml:ns:carddav supported-collation-set"`
 }
 
@@ -318,6 +331,31 @@ type hrefProp struct {
 	Href string `xml:"d:href"`
 }
 
+// inviteProp is the CalendarServer sharing invite property. On a calendar
+// shared with the current user it names the owner as organizer and the
+// user's own access level.
+type inviteProp struct {
+	Organizer *inviteOrganizer `xml:"cs:organizer,omitempty"`
+	User      []inviteUser     `xml:"cs:user"`
+}
+
+type inviteOrganizer struct {
+	Href       string `xml:"d:href"`
+	CommonName string `xml:"cs:common-name,omitempty"`
+}
+
+type inviteUser struct {
+	Href           string       `xml:"d:href"`
+	CommonName     string       `xml:"cs:common-name,omitempty"`
+	InviteAccepted *struct{}    `xml:"cs:invite-accepted,omitempty"`
+	Access         inviteAccess `xml:"cs:access"`
+}
+
+type inviteAccess struct {
+	Read      *struct{} `xml:"cs:read,omitempty"`
+	ReadWrite *struct{} `xml:"cs:read-write,omitempty"`
+}
+
 type expandableHrefProp struct {
 	Href     string
 	Response []response
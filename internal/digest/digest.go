@@ -0,0 +1,251 @@
+// Package digest emails users a daily or weekly agenda of their upcoming
+// events and due tasks, at the time of day they choose in their timezone.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ErrInvalidSettings reports a digest schedule that cannot be saved.
+var ErrInvalidSettings = errors.New("invalid digest settings")
+
+// checkInterval is how often schedules are checked for due digests.
+const checkInterval = time.Minute
+
+// catchUpWindow bounds how late a digest is still sent, so a server that
+// was down overnight does not deliver yesterday's agenda.
+const catchUpWindow = 6 * time.Hour
+
+// sender delivers digest email; *mail.Mailer implements it.
+type sender interface {
+	Send(msg mail.Message) error
+}
+
+// Service sends the digests that are due.
+type Service struct {
+	store  *store.Store
+	events *events.Service
+	mailer sender
+	log    *logging.Logger
+	now    func() time.Time
+}
+
+// New returns the digest service for cfg. It sends nothing when email is
+// not configured.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	s := &Service{store: st, events: events.NewService(st), log: logging.New(sink, "digest"), now: time.Now}
+	if mailer := mail.New(cfg); mailer != nil {
+		s.mailer = mailer
+	}
+	return s
+}
+
+// Normalize validates a schedule before it is saved.
+func Normalize(settings *store.DigestSettings) error {
+	settings.Frequency = strings.ToLower(strings.TrimSpace(settings.Frequency))
+	switch {
+	case settings.Frequency != store.DigestDaily && settings.Frequency != store.DigestWeekly:
+		return fmt.Errorf("%w: frequency must be daily or weekly", ErrInvalidSettings)
+	case settings.SendMinute < 0 || settings.SendMinute >= 24*60:
+		return fmt.Errorf("%w: send time must be between 00:00 and 23:59", ErrInvalidSettings)
+	case settings.Weekday < time.Sunday || settings.Weekday > time.Saturday:
+		return fmt.Errorf("%w: weekday must be 0 (Sunday) to 6", ErrInvalidSettings)
+	}
+	return nil
+}
+
+// Start checks for due digests every minute until ctx is cancelled. It
+// returns at once when email is not configured.
+func (s *Service) Start(ctx context.Context) {
+	if s.mailer == nil {
+		return
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.SendDue(ctx); err != nil {
+			s.log.Error("Start", "failed to check digest schedules: %v", err)
+		}
+	}
+}
+
+// SendDue sends every digest whose send time has passed since it was last
+// sent or its schedule last changed. A digest that fails to send is retried
+// on the next check.
+func (s *Service) SendDue(ctx context.Context) error {
+	if s.mailer == nil {
+		return nil
+	}
+	all, err := s.store.Digests.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, settings := range all {
+		user, err := s.store.Users.GetByID(ctx, settings.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.PrimaryEmail == "" || user.Internal {
+			continue
+		}
+		prefs := locale.ForUser(user)
+		scheduled, ok := Due(settings, prefs, now)
+		if !ok {
+			continue
+		}
+		if err := s.send(ctx, user, settings, prefs, scheduled); err != nil {
+			s.log.Error("SendDue", "failed to send digest to user %d: %v", user.ID, err)
+			continue
+		}
+		if err := s.store.Digests.MarkSent(ctx, user.ID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Due returns the send time of the digest due at now, reporting false when
+// none is: the latest scheduled time has already been served, predates the
+// schedule, or is too long ago to be worth sending.
+func Due(settings store.DigestSettings, prefs locale.Preferences, now time.Time) (time.Time, bool) {
+	scheduled, ok := lastScheduled(settings, prefs, now)
+	if !ok || now.Sub(scheduled) > catchUpWindow {
+		return time.Time{}, false
+	}
+	since := settings.UpdatedAt
+	if settings.LastSentAt != nil && settings.LastSentAt.After(since) {
+		since = *settings.LastSentAt
+	}
+	if !scheduled.After(since) {
+		return time.Time{}, false
+	}
+	return scheduled, true
+}
+
+// lastScheduled returns the latest send time at or before now, in the
+// user's timezone. Weekly digests go out on their weekday only, and daily
+// ones limited to working days skip the weekend of the user's region.
+func lastScheduled(settings store.DigestSettings, prefs locale.Preferences, now time.Time) (time.Time, bool) {
+	local := now.In(prefs.Location)
+	working := prefs.WorkingDays()
+	for back := 0; back <= 7; back++ {
+		at := time.Date(local.Year(), local.Month(), local.Day()-back, settings.SendMinute/60, settings.SendMinute%60, 0, 0, prefs.Location)
+		if at.After(now) {
+			continue
+		}
+		switch {
+		case settings.Frequency == store.DigestWeekly && at.Weekday() != settings.Weekday:
+			continue
+		case settings.Frequency == store.DigestDaily && settings.WorkingDaysOnly && working&(1<<at.Weekday()) == 0:
+			continue
+		}
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+// send emails the agenda covering the day, or week, from scheduled. Nothing
+// is sent when there is nothing on it.
+func (s *Service) send(ctx context.Context, user *store.User, settings store.DigestSettings, prefs locale.Preferences, scheduled time.Time) error {
+	end := scheduled.AddDate(0, 0, 1)
+	if settings.Frequency == store.DigestWeekly {
+		end = scheduled.AddDate(0, 0, 7)
+	}
+	items, err := s.events.Agenda(ctx, user, scheduled, end)
+	if err != nil {
+		return err
+	}
+	tasks, err := s.events.OpenTasks(ctx, user, events.TaskFilter{DueBefore: &end})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 && len(tasks) == 0 {
+		return nil
+	}
+	msg := Compose(settings, prefs, scheduled, items, tasks)
+	msg.To = []string{user.PrimaryEmail}
+	return s.mailer.Send(msg)
+}
+
+// Compose writes the digest email, without recipients, for the agenda
+// starting at start, in the user's language.
+func Compose(settings store.DigestSettings, prefs locale.Preferences, start time.Time, items []events.AgendaItem, tasks []events.Task) mail.Message {
+	subject := prefs.T("digest.daily", prefs.FormatMonthDay(start))
+	if settings.Frequency == store.DigestWeekly {
+		subject = prefs.T("digest.weekly", prefs.FormatMonthDay(start))
+	}
+	return mail.Message{Subject: subject, Text: Text(prefs, start, items, tasks)}
+}
+
+// Text writes the agenda starting at start as plain text in the user's
+// language: the occurrences under a heading per day, then the tasks. Tasks
+// due before start are listed as overdue.
+func Text(prefs locale.Preferences, start time.Time, items []events.AgendaItem, tasks []events.Task) string {
+	var b strings.Builder
+	day := ""
+	for _, item := range items {
+		at := item.Start
+		if item.AllDay {
+			// All-day dates float: keep the stored day in any timezone.
+			at = time.Date(at.Year(), at.Month(), at.Day(), 12, 0, 0, 0, prefs.Location)
+		}
+		if heading := prefs.FormatMonthDay(at); heading != day {
+			writeDayHeading(&b, heading)
+			day = heading
+		}
+		when := prefs.T("digest.allDay")
+		if !item.AllDay {
+			when = prefs.FormatTime(item.Start) + "-" + prefs.FormatTime(item.End)
+		}
+		line := fmt.Sprintf("  %s  %s (%s)", when, orUntitled(prefs, item.Summary), item.CalendarName)
+		if item.Location != "" {
+			line += ", " + item.Location
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(tasks) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(prefs.T("digest.tasks") + "\n")
+		for _, task := range tasks {
+			due := prefs.FormatMonthDay(*task.Due)
+			if task.Due.Before(start) {
+				due = prefs.T("digest.overdue", due)
+			}
+			fmt.Fprintf(&b, "  %s  %s (%s)\n", due, orUntitled(prefs, task.Summary), task.CalendarName)
+		}
+	}
+	return b.String()
+}
+
+func writeDayHeading(b *strings.Builder, heading string) {
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(heading + "\n")
+}
+
+func orUntitled(prefs locale.Preferences, summary string) string {
+	if strings.TrimSpace(summary) == "" {
+		return prefs.T("untitled")
+	}
+	return summary
+}
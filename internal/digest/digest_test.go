@@ -0,0 +1,178 @@
+package digest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeDigests struct {
+	store.DigestRepository
+	all  []store.DigestSettings
+	sent map[int64]time.Time
+}
+
+func (f *fakeDigests) ListAll(ctx context.Context) ([]store.DigestSettings, error) {
+	return f.all, nil
+}
+
+func (f *fakeDigests) MarkSent(ctx context.Context, userID int64, at time.Time) error {
+	f.sent[userID] = at
+	return nil
+}
+
+type fakeUsers struct {
+	store.UserRepository
+	user store.User
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if id != f.user.ID {
+		return nil, nil
+	}
+	return &f.user, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+}
+
+func (f *fakeCalendars) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
+	return []store.Calendar{{ID: 5, UserID: userID, Name: "Work"}}, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+	events []store.Event
+}
+
+func (f *fakeEvents) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
+	return f.events, nil
+}
+
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(msg mail.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestDueFollowsUserTimezoneAndWorkingDays(t *testing.T) {
+	prefs := locale.ForUser(&store.User{Timezone: "America/New_York"})
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	daily := store.DigestSettings{Frequency: store.DigestDaily, SendMinute: 8 * 60, UpdatedAt: created}
+
+	// 12:30 UTC on Tuesday 3 March is 07:30 in New York: not yet.
+	if _, ok := Due(daily, prefs, time.Date(2026, 3, 3, 12, 30, 0, 0, time.UTC)); ok {
+		t.Fatal("digest due before its local send time")
+	}
+	now := time.Date(2026, 3, 3, 13, 5, 0, 0, time.UTC)
+	at, ok := Due(daily, prefs, now)
+	if want := time.Date(2026, 3, 3, 13, 0, 0, 0, time.UTC); !ok || !at.Equal(want) {
+		t.Fatalf("Due = %v %v, want %v", at, ok, want)
+	}
+	daily.LastSentAt = &now
+	if _, ok := Due(daily, prefs, now.Add(time.Minute)); ok {
+		t.Fatal("digest due again after it was sent")
+	}
+	if _, ok := Due(store.DigestSettings{Frequency: store.DigestDaily, SendMinute: 8 * 60, UpdatedAt: created}, prefs, now.Add(7*time.Hour)); ok {
+		t.Fatal("digest still sent long after its send time")
+	}
+
+	saturday := time.Date(2026, 3, 7, 13, 5, 0, 0, time.UTC)
+	workdays := store.DigestSettings{Frequency: store.DigestDaily, SendMinute: 8 * 60, WorkingDaysOnly: true, UpdatedAt: created}
+	if _, ok := Due(workdays, prefs, saturday); ok {
+		t.Fatal("working-days digest due on Saturday")
+	}
+	weekly := store.DigestSettings{Frequency: store.DigestWeekly, SendMinute: 8 * 60, Weekday: time.Saturday, UpdatedAt: created}
+	if _, ok := Due(weekly, prefs, saturday); !ok {
+		t.Fatal("weekly digest not due on its weekday")
+	}
+	if _, ok := Due(weekly, prefs, now); ok {
+		t.Fatal("weekly digest due on another weekday")
+	}
+}
+
+func TestSendDueMailsAgendaAndTasks(t *testing.T) {
+	digests := &fakeDigests{sent: map[int64]time.Time{}, all: []store.DigestSettings{{
+		UserID: 1, Frequency: store.DigestDaily, SendMinute: 8 * 60,
+		UpdatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}}}
+	standup := "Standup"
+	stored := &fakeEvents{events: []store.Event{
+		{UID: "standup", Summary: &standup, RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Standup\r\nDTSTART:20260302T093000Z\r\nDTEND:20260302T094500Z\r\nRRULE:FREQ=DAILY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{UID: "report", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:report\r\nSUMMARY:Send report\r\nDUE:20260303T170000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"},
+		{UID: "later", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:later\r\nSUMMARY:Later\r\nDUE:20260320T170000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"},
+	}}
+	sender := &fakeSender{}
+	now := time.Date(2026, 3, 3, 8, 1, 0, 0, time.UTC)
+	s := &Service{
+		store: &store.Store{
+			Digests:   digests,
+			Users:     &fakeUsers{user: store.User{ID: 1, PrimaryEmail: "ada@example.com", Locale: "en-GB"}},
+			Calendars: &fakeCalendars{},
+			Events:    stored,
+		},
+		mailer: sender,
+		now:    func() time.Time { return now },
+	}
+	s.events = events.NewService(s.store)
+
+	if err := s.SendDue(context.Background()); err != nil {
+		t.Fatalf("SendDue: %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d digests, want 1", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if msg.To[0] != "ada@example.com" || msg.Subject != "Your agenda for Tuesday, 3 March" {
+		t.Fatalf("message = %+v", msg)
+	}
+	for _, want := range []string{"Tuesday, 3 March", "09:30-09:45  Standup (Work)", "Tasks due", "Send report (Work)"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("digest missing %q:\n%s", want, msg.Text)
+		}
+	}
+	if strings.Contains(msg.Text, "Later") || strings.Count(msg.Text, "Standup") != 1 {
+		t.Errorf("digest covers more than the day:\n%s", msg.Text)
+	}
+	if !digests.sent[1].Equal(now) {
+		t.Fatalf("sent at %v, want %v", digests.sent[1], now)
+	}
+
+	digests.all[0].LastSentAt = &now
+	if err := s.SendDue(context.Background()); err != nil || len(sender.sent) != 1 {
+		t.Fatalf("second check sent again: %v, %d", err, len(sender.sent))
+	}
+
+	digests.all[0].LastSentAt = nil
+	s.store.Users.(*fakeUsers).user.Internal = true
+	if err := s.SendDue(context.Background()); err != nil || len(sender.sent) != 1 {
+		t.Fatalf("an internal account was sent a digest: %v, %d", err, len(sender.sent))
+	}
+}
+
+func TestComposeWritesTheUsersLanguage(t *testing.T) {
+	prefs := locale.ForUser(&store.User{Locale: "de-DE", Timezone: "Europe/Berlin"})
+	start := time.Date(2026, 3, 3, 0, 0, 0, 0, prefs.Location)
+	due := start.Add(-24 * time.Hour)
+	msg := Compose(store.DigestSettings{Frequency: store.DigestWeekly}, prefs, start,
+		[]events.AgendaItem{{Start: start, AllDay: true, CalendarName: "Privat"}},
+		[]events.Task{{Summary: "Steuer", CalendarName: "Privat", Due: &due}})
+	if msg.Subject != "Ihre Termine für die Woche ab Dienstag, 3. März" {
+		t.Fatalf("Subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"Ganztägig  (ohne Titel) (Privat)", "Fällige Aufgaben", "Überfällig seit Montag, 2. März"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("digest missing %q:\n%s", want, msg.Text)
+		}
+	}
+}
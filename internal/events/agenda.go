@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// AgendaItem is one occurrence of an event on the owner's agenda.
+type AgendaItem struct {
+	CalendarID   int64
+	CalendarName string
+	UID          string
+	Summary      string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	// Geo is where the event takes place, from its GEO property, as saved
+	// places write it.
+	Geo *Geo
+}
+
+// Agenda returns the occurrences of events on the owner's own calendars that
+// overlap [start, end), ordered by start. Recurring events are expanded, and
+// cancelled occurrences and events the owner declined are left out.
+func (s *Service) Agenda(ctx context.Context, owner *store.User, start, end time.Time) ([]AgendaItem, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	var items []AgendaItem
+	for _, cal := range cals {
+		events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if utils.IsCancelledOrDeclined(ev.RawICAL, owner.Emails()...) {
+				continue
+			}
+			summary, location := "", ""
+			if ev.Summary != nil {
+				summary = *ev.Summary
+			}
+			if ev.Location != nil {
+				location = *ev.Location
+			}
+			geo := eventGeo(ev.RawICAL)
+			for _, inst := range expandInstances(ev, start, end) {
+				if inst.Cancelled {
+					continue
+				}
+				item := AgendaItem{
+					CalendarID:   cal.ID,
+					CalendarName: cal.Name,
+					UID:          ev.UID,
+					Summary:      summary,
+					Location:     location,
+					Start:        inst.Start,
+					End:          inst.End,
+					AllDay:       inst.AllDay,
+					Geo:          geo,
+				}
+				if inst.Summary != "" {
+					item.Summary = inst.Summary
+				}
+				items = append(items, item)
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Start.Equal(items[j].Start) {
+			return items[i].Start.Before(items[j].Start)
+		}
+		return items[i].Summary < items[j].Summary
+	})
+	return items, nil
+}
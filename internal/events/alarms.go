@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// MaxSnooze bounds how far ahead an alarm can be snoozed.
+const MaxSnooze = 7 * 24 * time.Hour
+
+// AcknowledgeAlarm dismisses one alarm of an event the user can write, on
+// every device that syncs the event. alarmID is an ID from utils.ParseAlarms.
+func (s *Service) AcknowledgeAlarm(ctx context.Context, user *store.User, calendarID int64, uid, alarmID string) (*store.Event, error) {
+	now := time.Now().UTC()
+	return s.editAlarm(ctx, user, calendarID, uid, func(raw string) (string, bool) {
+		return utils.AcknowledgeAlarm(raw, alarmID, now)
+	})
+}
+
+// SnoozeAlarm dismisses one alarm of an event the user can write and adds
+// an RFC 9074 snooze alarm that fires again at until.
+func (s *Service) SnoozeAlarm(ctx context.Context, user *store.User, calendarID int64, uid, alarmID string, until time.Time) (*store.Event, error) {
+	now := time.Now().UTC()
+	if !until.After(now) || until.Sub(now) > MaxSnooze {
+		return nil, fmt.Errorf("%w: alarms can be snoozed for up to %d days", ErrBadRequest, int(MaxSnooze.Hours()/24))
+	}
+	return s.editAlarm(ctx, user, calendarID, uid, func(raw string) (string, bool) {
+		return utils.SnoozeAlarm(raw, alarmID, until, now)
+	})
+}
+
+func (s *Service) editAlarm(ctx context.Context, user *store.User, calendarID int64, uid string, edit func(string) (string, bool)) (*store.Event, error) {
+	existing, err := s.GetEvent(ctx, user, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := s.loadCalendarForResource(ctx, user, calendarID, eventResourceName(*existing), "write-content")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireCalendarPrivilege(ctx, user, cal, eventResourceName(*existing), "write-content"); err != nil {
+		return nil, err
+	}
+	body, ok := edit(existing.RawICAL)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated, _, err := s.saveEvent(ctx, calendarID, existing.UID, eventResourceName(*existing), body, existing.ETag, "")
+	return updated, err
+}
+
+// DueAlarm is an alarm of one event occurrence.
+type DueAlarm struct {
+	CalendarID   int64
+	CalendarName string
+	UID          string
+	// AlarmID is the alarm's ID from utils.ParseAlarms.
+	AlarmID  string
+	Summary  string
+	Location string
+	Start    time.Time
+	AllDay   bool
+	FireAt   time.Time
+}
+
+// DueAlarms returns the alarms of events on the owner's own calendars that
+// fire in [from, to), ordered by when they fire. A recurring event's alarms
+// fire for each occurrence. Cancelled and declined occurrences are left out,
+// as are alarms dismissed by the time they fire, ACTION:NONE alarms and
+// proximity alarms, which fire on location rather than time.
+func (s *Service) DueAlarms(ctx context.Context, owner *store.User, from, to time.Time) ([]DueAlarm, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	var due []DueAlarm
+	for _, cal := range cals {
+		events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			alarms := firingAlarms(ev.RawICAL)
+			if len(alarms) == 0 || utils.IsCancelledOrDeclined(ev.RawICAL, owner.Emails()...) {
+				continue
+			}
+			summary, location := "", ""
+			if ev.Summary != nil {
+				summary = *ev.Summary
+			}
+			if ev.Location != nil {
+				location = *ev.Location
+			}
+			lastAck := utils.LastAcknowledged(ev.RawICAL)
+			add := func(a utils.Alarm, inst Instance, fire time.Time) {
+				if fire.Before(from) || !fire.Before(to) || dismissedBy(a.Acknowledged, fire) || dismissedBy(lastAck, fire) {
+					return
+				}
+				item := DueAlarm{
+					CalendarID:   cal.ID,
+					CalendarName: cal.Name,
+					UID:          ev.UID,
+					AlarmID:      a.ID,
+					Summary:      summary,
+					Location:     location,
+					Start:        inst.Start,
+					AllDay:       inst.AllDay,
+					FireAt:       fire,
+				}
+				if inst.Summary != "" {
+					item.Summary = inst.Summary
+				}
+				due = append(due, item)
+			}
+
+			// Occurrences are looked for as far around the window as the
+			// alarms reach.
+			var earliest, latest time.Duration
+			var relative []utils.Alarm
+			for _, a := range alarms {
+				d, ok := utils.ParseICalDuration(a.Trigger)
+				if !ok {
+					if fire, ok := a.FireTime(time.Time{}, time.Time{}); ok {
+						start := fire
+						if ev.DTStart != nil {
+							start = ev.DTStart.UTC()
+						}
+						add(a, Instance{Start: start, AllDay: ev.AllDay}, fire)
+					}
+					continue
+				}
+				earliest, latest = min(earliest, d), max(latest, d)
+				relative = append(relative, a)
+			}
+			if len(relative) == 0 {
+				continue
+			}
+			for _, inst := range expandInstances(ev, from.Add(-latest), to.Add(-earliest)) {
+				if inst.Cancelled {
+					continue
+				}
+				for _, a := range relative {
+					fire, _ := a.FireTime(inst.Start, inst.End)
+					add(a, inst, fire)
+				}
+			}
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].FireAt.Before(due[j].FireAt)
+	})
+	return due, nil
+}
+
+// dismissedBy reports whether an alarm firing at fire was dismissed at ack.
+func dismissedBy(ack, fire time.Time) bool {
+	return !ack.IsZero() && !ack.Before(fire)
+}
+
+// firingAlarms returns the alarms of the first VEVENT that fire at a time.
+func firingAlarms(ical string) []utils.Alarm {
+	var alarms []utils.Alarm
+	for _, a := range utils.ParseAlarms(ical) {
+		if a.Proximity == "" && a.Action != "NONE" {
+			alarms = append(alarms, a)
+		}
+	}
+	return alarms
+}
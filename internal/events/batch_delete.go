@@ -0,0 +1,293 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/maintenance"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// MaxBatchDelete caps how many events one batch removes. A query matching
+// more deletes the earliest and reports that more remain, so large clean-ups
+// proceed in steps the caller paces.
+const MaxBatchDelete = 500
+
+// BatchDeleteInput selects the events to delete from one calendar: either
+// UIDs, or a query of Before and Category that ANDs the fields set.
+type BatchDeleteInput struct {
+	UIDs []string
+	// Before matches events that end before it. Recurring events match only
+	// when their rule ends (UNTIL) before it.
+	Before   *time.Time
+	Category string // case-insensitive match on any category
+	// Client names the caller in held deletions, as DAV clients are named by
+	// their User-Agent.
+	Client string
+}
+
+// MassDeletePolicy is the mass-deletion protection a batch is held to. A
+// batch that, with the collection's other deletions in the last Window,
+// removes more than Percent of a collection of at least MinItems is held
+// for owner review instead of being applied. Percent 0 disables it.
+type MassDeletePolicy struct {
+	Percent  int
+	Window   time.Duration
+	MinItems int
+}
+
+// BatchDeleteResult reports what happened to each selected event.
+type BatchDeleteResult struct {
+	Deleted []string
+	// Held lists events kept until the calendar owner confirms the deletion.
+	Held    []string
+	Skipped []BatchDeleteSkip
+	// More is set when the query matched more than MaxBatchDelete events.
+	More bool
+}
+
+// BatchDeleteSkip is a selected event that was not deleted.
+type BatchDeleteSkip struct {
+	UID    string
+	Reason string // "not found" or "forbidden"
+}
+
+// DeleteEvents deletes many events from one calendar at once. Each event is
+// tombstoned as if deleted alone, but the calendar's ctag moves once.
+func (s *Service) DeleteEvents(ctx context.Context, user *store.User, calendarID int64, input BatchDeleteInput, policy MassDeletePolicy) (*BatchDeleteResult, error) {
+	byQuery := input.Before != nil || strings.TrimSpace(input.Category) != ""
+	switch {
+	case len(input.UIDs) == 0 && !byQuery:
+		return nil, errors.Join(ErrBadRequest, errors.New("select events by uids or by before/category"))
+	case len(input.UIDs) > 0 && byQuery:
+		return nil, errors.Join(ErrBadRequest, errors.New("uids cannot be combined with before/category"))
+	case len(input.UIDs) > MaxBatchDelete:
+		return nil, errors.Join(ErrBadRequest, errors.New("too many uids in one batch"))
+	}
+	cal, err := s.GetCalendar(ctx, user, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchDeleteResult{}
+	var candidates []store.Event
+	if byQuery {
+		all, err := s.store.Events.ListForCalendar(ctx, calendarID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range all {
+			if input.matches(ev) {
+				candidates = append(candidates, ev)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return eventSortTime(candidates[i]).Before(eventSortTime(candidates[j]))
+		})
+		if len(candidates) > MaxBatchDelete {
+			candidates, result.More = candidates[:MaxBatchDelete], true
+		}
+	} else {
+		found, err := s.store.Events.ListByUIDs(ctx, calendarID, dedupe(input.UIDs))
+		if err != nil {
+			return nil, err
+		}
+		present := make(map[string]bool, len(found))
+		for _, ev := range found {
+			present[ev.UID] = true
+		}
+		for _, uid := range dedupe(input.UIDs) {
+			if !present[uid] {
+				result.Skipped = append(result.Skipped, BatchDeleteSkip{UID: uid, Reason: "not found"})
+			}
+		}
+		candidates = found
+	}
+
+	var allowed []store.Event
+	for _, ev := range candidates {
+		if err := s.requireCalendarPrivilege(ctx, user, cal, eventResourceName(ev), "unbind"); err != nil {
+			if errors.Is(err, ErrForbidden) {
+				result.Skipped = append(result.Skipped, BatchDeleteSkip{UID: ev.UID, Reason: "forbidden"})
+				continue
+			}
+			return nil, err
+		}
+		allowed = append(allowed, ev)
+	}
+	if len(allowed) == 0 {
+		return result, nil
+	}
+
+	if err := maintenance.Check(ctx, s.store, maintenance.Calendar, calendarID); err != nil {
+		return nil, err
+	}
+	hold, err := s.exceedsMassDelete(ctx, calendarID, len(allowed), policy)
+	if err != nil {
+		return nil, err
+	}
+	if hold && s.store.HeldDeletions != nil {
+		for _, ev := range allowed {
+			held, err := s.store.HeldDeletions.IsHeld(ctx, "event", calendarID, ev.UID)
+			if err != nil {
+				return nil, err
+			}
+			if !held {
+				if err := s.store.HeldDeletions.Hold(ctx, store.HeldDeletion{
+					ResourceType: "event",
+					CollectionID: calendarID,
+					UID:          ev.UID,
+					ResourceName: ev.ResourceName,
+					OwnerID:      cal.UserID,
+					DeletedBy:    user.ID,
+					Client:       input.Client,
+				}); err != nil {
+					return nil, err
+				}
+			}
+			result.Held = append(result.Held, ev.UID)
+		}
+		return result, nil
+	}
+
+	uids := make([]string, 0, len(allowed))
+	for _, ev := range allowed {
+		if err := s.ReleaseResources(ctx, calendarID, ev.RawICAL); err != nil {
+			return nil, err
+		}
+		uids = append(uids, ev.UID)
+	}
+	if _, err := s.store.Events.DeleteByUIDs(ctx, calendarID, uids); err != nil {
+		return nil, err
+	}
+	result.Deleted = uids
+	return result, nil
+}
+
+// exceedsMassDelete reports whether deleting count more events, on top of
+// the calendar's deletions within the policy window, passes its threshold.
+func (s *Service) exceedsMassDelete(ctx context.Context, calendarID int64, count int, policy MassDeletePolicy) (bool, error) {
+	if policy.Percent <= 0 {
+		return false, nil
+	}
+	page, err := s.store.Events.ListForCalendarPaginated(ctx, calendarID, 1, 0)
+	if err != nil {
+		return false, err
+	}
+	size := 0
+	if page != nil {
+		size = page.TotalCount
+	}
+	recent := 0
+	if policy.Window > 0 && s.store.DeletedResources != nil {
+		tombstones, err := s.store.DeletedResources.ListDeletedSince(ctx, "event", calendarID, time.Now().Add(-policy.Window))
+		if err != nil {
+			return false, err
+		}
+		recent = len(tombstones)
+	}
+	// The baseline is the collection as it was before the recent deletions.
+	baseline := size + recent
+	if baseline == 0 || baseline < policy.MinItems {
+		return false, nil
+	}
+	return (recent+count)*100 > baseline*policy.Percent, nil
+}
+
+func (in BatchDeleteInput) matches(ev store.Event) bool {
+	if in.Before != nil {
+		end, ok := eventEnd(ev)
+		if !ok || !end.Before(*in.Before) {
+			return false
+		}
+	}
+	category := strings.TrimSpace(in.Category)
+	if category == "" {
+		return true
+	}
+	for _, c := range eventCategories(ev.RawICAL) {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventEnd is when the event's last instance ends, or false when it has no
+// start or recurs without an UNTIL.
+func eventEnd(ev store.Event) (time.Time, bool) {
+	if ev.DTStart == nil {
+		return time.Time{}, false
+	}
+	end := *ev.DTStart
+	if ev.DTEnd != nil {
+		end = *ev.DTEnd
+	}
+	if rrule := extractICalRRULE(ev.RawICAL); rrule != nil {
+		until, err := parseICalDateTime(rrule["UNTIL"])
+		if err != nil {
+			return time.Time{}, false
+		}
+		end = until.Add(end.Sub(*ev.DTStart))
+	}
+	return end, true
+}
+
+// eventCategories returns the CATEGORIES of the master VEVENT.
+func eventCategories(raw string) []string {
+	for _, comp := range utils.ICalComponents(raw, "VEVENT") {
+		depth := 0
+		master := true
+		var found []string
+		for _, line := range utils.UnfoldLines(comp) {
+			name, _, value, ok := parseInstanceProperty(strings.TrimSpace(line))
+			if !ok {
+				continue
+			}
+			switch name {
+			case "BEGIN":
+				depth++
+				continue
+			case "END":
+				depth--
+				continue
+			}
+			if depth != 1 {
+				continue
+			}
+			switch name {
+			case "RECURRENCE-ID":
+				master = false
+			case "CATEGORIES":
+				found = append(found, splitTaskList(value)...)
+			}
+		}
+		if master {
+			return found
+		}
+	}
+	return nil
+}
+
+func eventSortTime(ev store.Event) time.Time {
+	if ev.DTStart != nil {
+		return *ev.DTStart
+	}
+	return ev.LastModified
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,275 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/netguard"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// ConferenceRequestProperty asks, when set to TRUE on a VEVENT, for the
+// calendar's conferencing webhook to provision a meeting link. It is replaced
+// by a CONFERENCE property before the event is stored.
+const ConferenceRequestProperty = "X-CALCARD-REQUEST-CONFERENCE"
+
+// ConferenceSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the hook's secret, as "sha256=<hex>".
+const ConferenceSignatureHeader = "X-Calcard-Signature"
+
+const (
+	conferenceHookTimeout  = 10 * time.Second
+	maxConferenceHookReply = 64 << 10
+)
+
+// ErrConferenceProvider reports a conferencing webhook that failed or
+// answered without a usable link.
+var ErrConferenceProvider = errors.New("conference provider failed")
+
+// ConferenceClient is the client conferencing webhooks are called with. It
+// refuses to connect to loopback, private and other non-public addresses
+// and does not follow redirects, so a hook cannot make the server reach its
+// own network; tests that serve a hook locally replace it.
+var ConferenceClient = netguard.NewClient(false)
+
+// conferenceHookRequest is the JSON body POSTed to a conferencing webhook.
+type conferenceHookRequest struct {
+	CalendarID int64  `json:"calendarId"`
+	UID        string `json:"uid"`
+	Summary    string `json:"summary"`
+	Start      string `json:"start,omitempty"`
+	End        string `json:"end,omitempty"`
+	AllDay     bool   `json:"allDay"`
+	Organizer  string `json:"organizer,omitempty"`
+}
+
+// conferenceHookResponse is what a conferencing webhook answers with.
+type conferenceHookResponse struct {
+	URL string `json:"url"`
+}
+
+// ConferenceHook returns the conferencing webhook of a calendar the user
+// owns, or nil when none is set.
+func (s *Service) ConferenceHook(ctx context.Context, user *store.User, calendarID int64) (*store.ConferenceHook, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	if s.store.ConferenceHooks == nil {
+		return nil, nil
+	}
+	return s.store.ConferenceHooks.GetByCalendar(ctx, calendarID)
+}
+
+// SetConferenceHook sets the conferencing webhook of a calendar the user
+// owns. The URL must be absolute http or https and must not name a
+// non-public address.
+func (s *Service) SetConferenceHook(ctx context.Context, user *store.User, calendarID int64, hookURL, secret string) (*store.ConferenceHook, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	hookURL = strings.TrimSpace(hookURL)
+	if !isHTTPURL(hookURL) {
+		return nil, fmt.Errorf("%w: hook url must be an absolute http or https url", ErrBadRequest)
+	}
+	if u, _ := url.Parse(hookURL); !netguard.PublicHost(u.Hostname()) {
+		return nil, fmt.Errorf("%w: hook url must name a public host", ErrBadRequest)
+	}
+	if s.store.ConferenceHooks == nil {
+		return nil, fmt.Errorf("conference hooks not available")
+	}
+	return s.store.ConferenceHooks.Upsert(ctx, store.ConferenceHook{CalendarID: calendarID, URL: hookURL, Secret: secret})
+}
+
+// DeleteConferenceHook removes the conferencing webhook of a calendar the
+// user owns.
+func (s *Service) DeleteConferenceHook(ctx context.Context, user *store.User, calendarID int64) error {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return err
+	}
+	if s.store.ConferenceHooks == nil {
+		return nil
+	}
+	return s.store.ConferenceHooks.Delete(ctx, calendarID)
+}
+
+func (s *Service) requireOwnedCalendar(ctx context.Context, user *store.User, calendarID int64) error {
+	cal, err := s.GetCalendar(ctx, user, calendarID)
+	if err != nil {
+		return err
+	}
+	if cal.UserID != user.ID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ProvisionConference writes a meeting link from the calendar's conferencing
+// webhook into body when it asks for one, through ConferenceRequestProperty or
+// requested, and has no CONFERENCE yet. It reports whether body changed; it
+// is returned unchanged when the calendar has no webhook.
+func (s *Service) ProvisionConference(ctx context.Context, calendarID int64, body string, requested bool) (string, bool, error) {
+	header, components, footer := utils.SplitComponents(body)
+	if len(components) == 0 {
+		return body, false, nil
+	}
+	hasConference := false
+	for _, lines := range components {
+		for _, line := range componentProperties(lines) {
+			name, _, value, ok := parseInstanceProperty(line)
+			if !ok {
+				continue
+			}
+			switch name {
+			case ConferenceRequestProperty:
+				requested = requested || strings.EqualFold(strings.TrimSpace(value), "TRUE")
+			case "CONFERENCE":
+				hasConference = true
+			}
+		}
+	}
+	if !requested || hasConference || s == nil || s.store == nil || s.store.ConferenceHooks == nil {
+		return body, false, nil
+	}
+	hook, err := s.store.ConferenceHooks.GetByCalendar(ctx, calendarID)
+	if err != nil {
+		return "", false, err
+	}
+	if hook == nil {
+		return body, false, nil
+	}
+
+	link, err := callConferenceHook(ctx, *hook, conferenceRequestFor(calendarID, components[0]))
+	if err != nil {
+		return "", false, err
+	}
+	conference := "CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:" + link
+	for i, lines := range components {
+		rewritten := make([]string, 0, len(lines)+1)
+		inserted := false
+		for _, line := range lines {
+			name, _, _, _ := parseInstanceProperty(line)
+			if name == ConferenceRequestProperty {
+				continue
+			}
+			// Properties come before nested components such as VALARM.
+			if !inserted && strings.HasPrefix(strings.ToUpper(line), "BEGIN:") {
+				rewritten = append(rewritten, conference)
+				inserted = true
+			}
+			rewritten = append(rewritten, line)
+		}
+		if !inserted {
+			rewritten = append(rewritten, conference)
+		}
+		components[i] = rewritten
+	}
+	return utils.BuildFromComponents(header, components, footer), true, nil
+}
+
+// componentProperties returns the lines of a VEVENT outside its nested
+// components.
+func componentProperties(lines []string) []string {
+	var props []string
+	depth := 0
+	for _, line := range lines {
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(upper, "BEGIN:"):
+			depth++
+		case strings.HasPrefix(upper, "END:"):
+			depth--
+		case depth == 0:
+			props = append(props, line)
+		}
+	}
+	return props
+}
+
+func conferenceRequestFor(calendarID int64, lines []string) conferenceHookRequest {
+	req := conferenceHookRequest{CalendarID: calendarID}
+	for _, line := range componentProperties(lines) {
+		name, params, value, ok := parseInstanceProperty(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "UID":
+			req.UID = strings.TrimSpace(value)
+		case "SUMMARY":
+			req.Summary = value
+		case "ORGANIZER":
+			req.Organizer = strings.TrimPrefix(strings.TrimPrefix(value, "mailto:"), "MAILTO:")
+		case "DTSTART", "DTEND":
+			t, allDay, err := parseInstanceTime(value, params, nil)
+			if err != nil {
+				continue
+			}
+			formatted := t.UTC().Format(time.RFC3339)
+			if allDay {
+				formatted = t.Format("2006-01-02")
+			}
+			if name == "DTSTART" {
+				req.Start, req.AllDay = formatted, allDay
+			} else {
+				req.End = formatted
+			}
+		}
+	}
+	return req
+}
+
+// callConferenceHook POSTs req to the hook and returns the meeting link it
+// answers with.
+func callConferenceHook(ctx context.Context, hook store.ConferenceHook, req conferenceHookRequest) (string, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, conferenceHookTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrConferenceProvider, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(payload)
+		httpReq.Header.Set(ConferenceSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := ConferenceClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrConferenceProvider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%w: hook responded with %s", ErrConferenceProvider, resp.Status)
+	}
+	var reply conferenceHookResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxConferenceHookReply)).Decode(&reply); err != nil {
+		return "", fmt.Errorf("%w: invalid hook response: %v", ErrConferenceProvider, err)
+	}
+	link := strings.TrimSpace(reply.URL)
+	if !isHTTPURL(link) || strings.ContainsAny(link, "\r\n\t ") {
+		return "", fmt.Errorf("%w: hook returned no usable url", ErrConferenceProvider)
+	}
+	return link, nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
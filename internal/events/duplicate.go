@@ -0,0 +1,206 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// DateShift moves dates by whole years, months and days, as time.AddDate
+// does, so a one-year shift keeps events on the same day of the month.
+type DateShift struct {
+	Years  int
+	Months int
+	Days   int
+}
+
+// IsZero reports whether the shift leaves dates unchanged.
+func (d DateShift) IsZero() bool {
+	return d == DateShift{}
+}
+
+var dateShiftPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?$`)
+
+// ParseDateShift parses an ISO 8601 duration of years, months, weeks and
+// days, such as "P1Y" or "-P2W".
+func ParseDateShift(s string) (DateShift, error) {
+	m := dateShiftPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil || (m[2] == "" && m[3] == "" && m[4] == "" && m[5] == "") {
+		return DateShift{}, fmt.Errorf("%w: shift must be a duration such as P1Y, P6M or -P7D", ErrBadRequest)
+	}
+	n := func(v string) int {
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	shift := DateShift{Years: n(m[2]), Months: n(m[3]), Days: 7*n(m[4]) + n(m[5])}
+	if m[1] == "-" {
+		shift = DateShift{Years: -shift.Years, Months: -shift.Months, Days: -shift.Days}
+	}
+	return shift, nil
+}
+
+// DuplicateInput describes a copy of a calendar.
+type DuplicateInput struct {
+	Name string
+	// Slug is the new calendar's DAV path name, if it has one.
+	Slug  *string
+	Shift DateShift
+	// SkipEvents copies only the calendar's properties.
+	SkipEvents bool
+}
+
+// DuplicateCalendar copies a calendar the user can read, and the events in
+// it they can read, into a new calendar of theirs. Every copied event gets a
+// new UID, so clients showing both calendars never confuse the copies with
+// the originals, and is moved by input.Shift. It returns the new calendar
+// and how many events were copied; on failure nothing is left behind.
+func (s *Service) DuplicateCalendar(ctx context.Context, user *store.User, sourceID int64, input DuplicateInput) (*store.Calendar, int, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, 0, fmt.Errorf("%w: name is required", ErrBadRequest)
+	}
+	source, err := s.GetCalendar(ctx, user, sourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var evs []store.Event
+	if !input.SkipEvents {
+		if evs, err = s.ListEvents(ctx, user, sourceID, store.EventFilter{}); err != nil {
+			return nil, 0, err
+		}
+	}
+	cal, err := s.store.Calendars.Create(ctx, store.Calendar{
+		UserID:      user.ID,
+		Name:        name,
+		Slug:        input.Slug,
+		Description: source.Description,
+		Timezone:    source.Timezone,
+		Color:       source.Color,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, ev := range evs {
+		uid := utils.GenerateUID()
+		body := shiftEventBody(ev.RawICAL, ev.UID, uid, input.Shift)
+		if _, _, err := s.saveEvent(ctx, cal.ID, uid, uid, body, "", ""); err != nil {
+			if delErr := s.store.Calendars.Delete(ctx, user.ID, cal.ID); delErr != nil {
+				return nil, 0, fmt.Errorf("copy event %d of %d: %w (and removing the partial copy failed: %v)", i+1, len(evs), err, delErr)
+			}
+			return nil, 0, err
+		}
+	}
+	return cal, len(evs), nil
+}
+
+// shiftedProperties hold the dates of an event that move with it. DTSTAMP,
+// CREATED and LAST-MODIFIED record when the data was written and are kept.
+var shiftedProperties = map[string]bool{
+	"DTSTART":       true,
+	"DTEND":         true,
+	"DUE":           true,
+	"RECURRENCE-ID": true,
+	"EXDATE":        true,
+	"RDATE":         true,
+	"TRIGGER":       true,
+}
+
+// shiftEventBody gives a calendar object a new UID and moves its dates by
+// shift. Time zone definitions and relative alarm triggers are left alone.
+func shiftEventBody(raw, oldUID, newUID string, shift DateShift) string {
+	lines := utils.UnfoldLines(raw)
+	out := make([]string, 0, len(lines))
+	inTimezone := false
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		name, value := splitContentLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTIMEZONE"):
+			inTimezone = true
+		case name == "END" && strings.EqualFold(value, "VTIMEZONE"):
+			inTimezone = false
+		case name == "UID" && value == oldUID:
+			line = "UID:" + newUID
+		case inTimezone || shift.IsZero():
+		case shiftedProperties[name]:
+			if shifted, ok := shiftDateValues(value, shift); ok {
+				line = line[:len(line)-len(value)] + shifted
+			}
+		case name == "RRULE":
+			line = line[:len(line)-len(value)] + shiftRRuleUntil(value, shift)
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\r\n") + "\r\n"
+}
+
+// splitContentLine splits an unfolded content line into its upper-cased
+// name and its value.
+func splitContentLine(line string) (string, string) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", ""
+	}
+	name, _, _ := strings.Cut(head, ";")
+	return strings.ToUpper(name), value
+}
+
+var icalDateLayouts = []string{"20060102T150405Z", "20060102T150405", "20060102"}
+
+// shiftDateValues shifts a comma-separated list of DATE, DATE-TIME or
+// PERIOD values, keeping each one's form. It reports false when a value is
+// not a date, as for a relative TRIGGER.
+func shiftDateValues(value string, shift DateShift) (string, bool) {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		start, end, isPeriod := strings.Cut(item, "/")
+		shifted, ok := shiftDate(start, shift)
+		if !ok {
+			return value, false
+		}
+		if isPeriod {
+			if endShifted, ok := shiftDate(end, shift); ok {
+				end = endShifted
+			}
+			shifted += "/" + end
+		}
+		items[i] = shifted
+	}
+	return strings.Join(items, ","), true
+}
+
+func shiftDate(value string, shift DateShift) (string, bool) {
+	for _, layout := range icalDateLayouts {
+		if len(value) != len(layout) {
+			continue
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		return t.AddDate(shift.Years, shift.Months, shift.Days).Format(layout), true
+	}
+	return value, false
+}
+
+// shiftRRuleUntil moves the UNTIL of a recurrence rule.
+func shiftRRuleUntil(rule string, shift DateShift) string {
+	parts := strings.Split(rule, ";")
+	for i, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		if ok && strings.EqualFold(key, "UNTIL") {
+			if shifted, ok := shiftDate(value, shift); ok {
+				parts[i] = key + "=" + shifted
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
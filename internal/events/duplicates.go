@@ -0,0 +1,234 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/maintenance"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Reasons a DuplicateGroup's events are considered copies of each other.
+const (
+	DuplicateSameUID          = "same uid"
+	DuplicateSameSummaryStart = "same summary and start"
+)
+
+// EventRef names one event of one calendar.
+type EventRef struct {
+	CalendarID int64
+	UID        string
+}
+
+// DuplicateEvent is one copy in a DuplicateGroup.
+type DuplicateEvent struct {
+	EventRef
+	CalendarName string
+	Summary      string
+	Start        *time.Time
+	AllDay       bool
+	LastModified time.Time
+}
+
+// DuplicateGroup is a set of events that are probably copies of one event,
+// typically left behind by a migration that imported a calendar twice.
+type DuplicateGroup struct {
+	Reason string
+	// Events lists the copies, the one to keep first: the most recently
+	// modified, then the one in the oldest calendar.
+	Events []DuplicateEvent
+}
+
+// DuplicateCleanupResult reports what RemoveDuplicates did with each event
+// it was asked to remove.
+type DuplicateCleanupResult struct {
+	Deleted []EventRef
+	Skipped []DuplicateCleanupSkip
+}
+
+// DuplicateCleanupSkip is an event RemoveDuplicates left in place.
+type DuplicateCleanupSkip struct {
+	EventRef
+	Reason string // "not a duplicate" or "last copy"
+}
+
+// FindDuplicates returns the probable duplicate events across the owner's
+// own calendars: events sharing a UID in different calendars, and events
+// sharing a summary and start time. An event belongs to at most one group;
+// UID matches take precedence.
+func (s *Service) FindDuplicates(ctx context.Context, owner *store.User) ([]DuplicateGroup, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cals, func(i, j int) bool { return cals[i].ID < cals[j].ID })
+
+	var all []DuplicateEvent
+	for _, cal := range cals {
+		events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			dup := DuplicateEvent{
+				EventRef:     EventRef{CalendarID: cal.ID, UID: ev.UID},
+				CalendarName: cal.Name,
+				Start:        ev.DTStart,
+				AllDay:       ev.AllDay,
+				LastModified: ev.LastModified,
+			}
+			if ev.Summary != nil {
+				dup.Summary = *ev.Summary
+			}
+			all = append(all, dup)
+		}
+	}
+
+	byUID := map[string][]DuplicateEvent{}
+	for _, ev := range all {
+		byUID[ev.UID] = append(byUID[ev.UID], ev)
+	}
+	var groups []DuplicateGroup
+	claimed := map[EventRef]bool{}
+	for _, ev := range all {
+		copies := byUID[ev.UID]
+		if len(copies) < 2 || claimed[ev.EventRef] {
+			continue
+		}
+		for _, c := range copies {
+			claimed[c.EventRef] = true
+		}
+		groups = append(groups, newDuplicateGroup(DuplicateSameUID, copies))
+	}
+
+	bySummary := map[string][]DuplicateEvent{}
+	var keys []string
+	for _, ev := range all {
+		key, ok := duplicateSummaryKey(ev)
+		if !ok || claimed[ev.EventRef] {
+			continue
+		}
+		if _, seen := bySummary[key]; !seen {
+			keys = append(keys, key)
+		}
+		bySummary[key] = append(bySummary[key], ev)
+	}
+	for _, key := range keys {
+		if copies := bySummary[key]; len(copies) > 1 {
+			groups = append(groups, newDuplicateGroup(DuplicateSameSummaryStart, copies))
+		}
+	}
+	return groups, nil
+}
+
+// RemoveDuplicates deletes the given events of the owner's calendars, each
+// tombstoned as if deleted alone. Only events FindDuplicates currently
+// reports are removed, and never every copy of a group. With no events
+// given it removes every copy but the first of each group.
+func (s *Service) RemoveDuplicates(ctx context.Context, owner *store.User, remove []EventRef) (*DuplicateCleanupResult, error) {
+	if len(remove) > MaxBatchDelete {
+		return nil, errors.Join(ErrBadRequest, errors.New("too many events in one cleanup"))
+	}
+	groups, err := s.FindDuplicates(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	groupOf := map[EventRef]int{}
+	for i, g := range groups {
+		for _, ev := range g.Events {
+			groupOf[ev.EventRef] = i
+		}
+	}
+	if len(remove) == 0 {
+		for _, g := range groups {
+			for _, ev := range g.Events[1:] {
+				remove = append(remove, ev.EventRef)
+			}
+		}
+	}
+
+	result := &DuplicateCleanupResult{}
+	left := make([]int, len(groups))
+	for i, g := range groups {
+		left[i] = len(g.Events)
+	}
+	byCalendar := map[int64][]string{}
+	var calendarOrder []int64
+	seen := map[EventRef]bool{}
+	for _, ref := range remove {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		i, ok := groupOf[ref]
+		switch {
+		case !ok:
+			result.Skipped = append(result.Skipped, DuplicateCleanupSkip{EventRef: ref, Reason: "not a duplicate"})
+			continue
+		case left[i] == 1:
+			result.Skipped = append(result.Skipped, DuplicateCleanupSkip{EventRef: ref, Reason: "last copy"})
+			continue
+		}
+		left[i]--
+		if _, ok := byCalendar[ref.CalendarID]; !ok {
+			calendarOrder = append(calendarOrder, ref.CalendarID)
+		}
+		byCalendar[ref.CalendarID] = append(byCalendar[ref.CalendarID], ref.UID)
+	}
+
+	for _, calendarID := range calendarOrder {
+		if err := maintenance.Check(ctx, s.store, maintenance.Calendar, calendarID); err != nil {
+			return nil, err
+		}
+	}
+	for _, calendarID := range calendarOrder {
+		uids := byCalendar[calendarID]
+		found, err := s.store.Events.ListByUIDs(ctx, calendarID, uids)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range found {
+			if err := s.ReleaseResources(ctx, calendarID, ev.RawICAL); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.store.Events.DeleteByUIDs(ctx, calendarID, uids); err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			result.Deleted = append(result.Deleted, EventRef{CalendarID: calendarID, UID: uid})
+		}
+	}
+	return result, nil
+}
+
+func newDuplicateGroup(reason string, copies []DuplicateEvent) DuplicateGroup {
+	events := append([]DuplicateEvent(nil), copies...)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].LastModified.Equal(events[j].LastModified) {
+			return events[i].LastModified.After(events[j].LastModified)
+		}
+		if events[i].CalendarID != events[j].CalendarID {
+			return events[i].CalendarID < events[j].CalendarID
+		}
+		return events[i].UID < events[j].UID
+	})
+	return DuplicateGroup{Reason: reason, Events: events}
+}
+
+// duplicateSummaryKey is the summary and start two copies of an event
+// share, ignoring case and spacing in the summary.
+func duplicateSummaryKey(ev DuplicateEvent) (string, bool) {
+	summary := strings.ToLower(strings.Join(strings.Fields(ev.Summary), " "))
+	if summary == "" || ev.Start == nil {
+		return "", false
+	}
+	start := ev.Start.UTC().Format(time.RFC3339)
+	if ev.AllDay {
+		start = ev.Start.Format("2006-01-02")
+	}
+	return summary + "\n" + start, true
+}
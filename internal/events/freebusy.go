@@ -0,0 +1,185 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// maxBusyIterations bounds how many instances of one recurring event are
+// stepped through, from its first instance, when computing busy time. It
+// covers a daily event started decades ago.
+const maxBusyIterations = 50000
+
+// BusyPeriod is a span of time blocked by at least one event.
+type BusyPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// BusyPeriods returns the merged spans within [start, end) during which the
+// owner's own calendars are busy. Transparent, cancelled and declined events
+// leave their time free.
+func (s *Service) BusyPeriods(ctx context.Context, owner *store.User, start, end time.Time) ([]BusyPeriod, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	var periods []BusyPeriod
+	for _, cal := range cals {
+		events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if utils.IsTransparent(ev.RawICAL) || utils.IsCancelledOrDeclined(ev.RawICAL, owner.Emails()...) {
+				continue
+			}
+			periods = append(periods, eventBusyPeriods(ev, start, end)...)
+		}
+	}
+	return mergeBusyPeriods(periods), nil
+}
+
+// Overlaps reports whether any period intersects [start, end).
+func Overlaps(periods []BusyPeriod, start, end time.Time) bool {
+	for _, p := range periods {
+		if p.Start.Before(end) && p.End.After(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// FreeBusyCalendar renders periods as a published VFREEBUSY covering
+// [start, end). It carries no organizer, summaries or attendees.
+func FreeBusyCalendar(periods []BusyPeriod, start, end time.Time) string {
+	const stamp = "20060102T150405Z"
+	var sb strings.Builder
+	sb.WriteString("BEGIN:VCALENDAR\r\n")
+	sb.WriteString("VERSION:2.0\r\n")
+	sb.WriteString("PRODID:-//CalCard//CalDAV Server//EN\r\n")
+	sb.WriteString("METHOD:PUBLISH\r\n")
+	sb.WriteString("BEGIN:VFREEBUSY\r\n")
+	sb.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", time.Now().UTC().Format(stamp)))
+	sb.WriteString(fmt.Sprintf("DTSTART:%s\r\n", start.UTC().Format(stamp)))
+	sb.WriteString(fmt.Sprintf("DTEND:%s\r\n", end.UTC().Format(stamp)))
+	for _, p := range periods {
+		sb.WriteString(fmt.Sprintf("FREEBUSY;FBTYPE=BUSY:%s/%s\r\n", p.Start.UTC().Format(stamp), p.End.UTC().Format(stamp)))
+	}
+	sb.WriteString("END:VFREEBUSY\r\n")
+	sb.WriteString("END:VCALENDAR\r\n")
+	return sb.String()
+}
+
+// eventBusyPeriods returns the instances of ev that overlap [start, end),
+// clipped to it. Recurring events are expanded by FREQ, INTERVAL, COUNT and
+// UNTIL.
+func eventBusyPeriods(ev store.Event, start, end time.Time) []BusyPeriod {
+	if ev.DTStart == nil || ev.DTEnd == nil || !ev.DTEnd.After(*ev.DTStart) {
+		return nil
+	}
+	duration := ev.DTEnd.Sub(*ev.DTStart)
+	clip := func(s time.Time) (BusyPeriod, bool) {
+		e := s.Add(duration)
+		if !s.Before(end) || !e.After(start) {
+			return BusyPeriod{}, false
+		}
+		if s.Before(start) {
+			s = start
+		}
+		if e.After(end) {
+			e = end
+		}
+		return BusyPeriod{Start: s.UTC(), End: e.UTC()}, true
+	}
+
+	rrule := extractICalRRULE(ev.RawICAL)
+	if rrule == nil {
+		if p, ok := clip(*ev.DTStart); ok {
+			return []BusyPeriod{p}
+		}
+		return nil
+	}
+
+	interval := 1
+	if v, err := strconv.Atoi(rrule["INTERVAL"]); err == nil && v > 0 {
+		interval = v
+	}
+	count := maxBusyIterations
+	if v, err := strconv.Atoi(rrule["COUNT"]); err == nil && v > 0 && v < count {
+		count = v
+	}
+	until := end
+	if raw := rrule["UNTIL"]; raw != "" {
+		if t, err := parseICalDateTime(raw); err == nil && t.Before(until) {
+			until = t
+		}
+	}
+
+	var periods []BusyPeriod
+	current := *ev.DTStart
+	// DTSTART is always the first instance, even past UNTIL (RFC 5545 §3.8.5.3).
+	for i := 0; i < count && (i == 0 || !current.After(until)) && current.Before(end); i++ {
+		if p, ok := clip(current); ok {
+			periods = append(periods, p)
+		}
+		switch strings.ToUpper(rrule["FREQ"]) {
+		case "DAILY":
+			current = current.AddDate(0, 0, interval)
+		case "WEEKLY":
+			current = current.AddDate(0, 0, 7*interval)
+		case "MONTHLY":
+			current = current.AddDate(0, interval, 0)
+		case "YEARLY":
+			current = current.AddDate(interval, 0, 0)
+		default:
+			// Unsupported frequencies only count the first instance.
+			return periods
+		}
+	}
+	return periods
+}
+
+// extractICalRRULE returns the parts of the first RRULE, keyed by upper-case
+// name, or nil when there is none.
+func extractICalRRULE(icalData string) map[string]string {
+	for _, line := range strings.Split(icalData, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if !strings.HasPrefix(strings.ToUpper(line), "RRULE:") {
+			continue
+		}
+		parts := make(map[string]string)
+		for _, part := range strings.Split(line[len("RRULE:"):], ";") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) == 2 {
+				parts[strings.ToUpper(kv[0])] = kv[1]
+			}
+		}
+		return parts
+	}
+	return nil
+}
+
+// mergeBusyPeriods sorts periods and joins those that overlap or touch, so
+// callers cannot tell how many events fill a block of time.
+func mergeBusyPeriods(periods []BusyPeriod) []BusyPeriod {
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	var merged []BusyPeriod
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.Start.After(merged[n-1].End) {
+			if p.End.After(merged[n-1].End) {
+				merged[n-1].End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
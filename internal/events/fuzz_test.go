@@ -0,0 +1,68 @@
+package events
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/icalgen"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func FuzzValidateStored(f *testing.F) {
+	for seed := int64(1); seed <= 3; seed++ {
+		for _, obj := range icalgen.Objects(icalgen.Options{Seed: seed, Events: 5}) {
+			f.Add(obj.RawICAL)
+			f.Add(strings.ReplaceAll(obj.RawICAL, "\r\n", "\n"))
+		}
+	}
+	f.Add("BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	f.Add("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nUID:b\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	f.Fuzz(func(t *testing.T, data string) {
+		uid, err := ValidateStored(data)
+		if err != nil {
+			return
+		}
+		if uid == "" || uid != strings.TrimSpace(uid) {
+			t.Fatalf("ValidateStored accepted unusable UID %q", uid)
+		}
+		if validateICalendar(data) != nil {
+			t.Fatal("ValidateStored accepted data validateICalendar rejects")
+		}
+	})
+}
+
+func FuzzEventBusyPeriods(f *testing.F) {
+	for _, obj := range icalgen.Objects(icalgen.Options{Seed: 1, Events: 200}) {
+		for _, line := range strings.Split(obj.RawICAL, "\r\n") {
+			if strings.HasPrefix(line, "RRULE:") {
+				f.Add(strings.TrimPrefix(line, "RRULE:"))
+			}
+		}
+	}
+	f.Add("FREQ=DAILY;COUNT=2147483647")
+	f.Add("FREQ=MONTHLY;INTERVAL=-1;UNTIL=20240101")
+	f.Add("FREQ=WEEKLY;UNTIL=notadate")
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	rangeStart, rangeEnd := start.AddDate(0, 0, -7), start.AddDate(1, 0, 0)
+	f.Fuzz(func(t *testing.T, rule string) {
+		ev := store.Event{
+			RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:fuzz\r\nRRULE:" + rule + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			DTStart: &start,
+			DTEnd:   &end,
+		}
+		periods := eventBusyPeriods(ev, rangeStart, rangeEnd)
+		if len(periods) == 0 || !periods[0].Start.Equal(start) {
+			t.Fatalf("RRULE %q: first instance at DTSTART missing, got %v", rule, periods)
+		}
+		if len(periods) > maxBusyIterations {
+			t.Fatalf("RRULE %q: %d periods exceed the iteration cap", rule, len(periods))
+		}
+		for _, p := range periods {
+			if !p.End.After(p.Start) || p.Start.Before(rangeStart) || p.End.After(rangeEnd) {
+				t.Fatalf("RRULE %q: period %v outside the requested range", rule, p)
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Heatmap holds, for each weekday (Sunday first) and hour of the day, the
+// share of that hour that was busy, from 0 to 1.
+type Heatmap [7][24]float64
+
+// BusyHeatmap returns how busy users were, on average, in each hour of the
+// week in loc between start and end. Busy time is computed as for free/busy.
+func (s *Service) BusyHeatmap(ctx context.Context, users []*store.User, start, end time.Time, loc *time.Location) (Heatmap, error) {
+	busy := make([][]BusyPeriod, 0, len(users))
+	for _, user := range users {
+		periods, err := s.BusyPeriods(ctx, user, start, end)
+		if err != nil {
+			return Heatmap{}, err
+		}
+		busy = append(busy, periods)
+	}
+	return BusyDensity(busy, start, end, loc), nil
+}
+
+// BusyDensity buckets the merged busy periods of each person into the hours
+// of the week in loc and returns the busy share of each hour within
+// [start, end), averaged over people. Hours the window never covers stay 0.
+func BusyDensity(busy [][]BusyPeriod, start, end time.Time, loc *time.Location) Heatmap {
+	var busySeconds, totalSeconds Heatmap
+	if len(busy) == 0 || !end.After(start) {
+		return Heatmap{}
+	}
+	next := make([]int, len(busy))
+	local := start.In(loc)
+	slot := start.Add(-time.Duration(local.Minute())*time.Minute - time.Duration(local.Second())*time.Second - time.Duration(local.Nanosecond()))
+	for ; slot.Before(end); slot = slot.Add(time.Hour) {
+		from, to := slot, slot.Add(time.Hour)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		at := slot.In(loc)
+		day, hour := at.Weekday(), at.Hour()
+		totalSeconds[day][hour] += to.Sub(from).Seconds() * float64(len(busy))
+		for i, periods := range busy {
+			for next[i] < len(periods) && !periods[next[i]].End.After(from) {
+				next[i]++
+			}
+			for _, p := range periods[next[i]:] {
+				if !p.Start.Before(to) {
+					break
+				}
+				s, e := p.Start, p.End
+				if s.Before(from) {
+					s = from
+				}
+				if e.After(to) {
+					e = to
+				}
+				busySeconds[day][hour] += e.Sub(s).Seconds()
+			}
+		}
+	}
+	var density Heatmap
+	for day := range density {
+		for hour := range density[day] {
+			if totalSeconds[day][hour] > 0 {
+				density[day][hour] = busySeconds[day][hour] / totalSeconds[day][hour]
+			}
+		}
+	}
+	return density
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// HistorySince returns the earliest time a calendar can be viewed as of at
+// now: when history recording began, or the oldest superseded revision
+// still kept, whichever is later.
+func (s *Service) HistorySince(ctx context.Context, now time.Time) (time.Time, error) {
+	if s.store.EventRevisions == nil {
+		return now, nil
+	}
+	since, err := s.store.EventRevisions.HistorySince(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if horizon := now.Add(-store.EventHistoryRetention); horizon.After(since) {
+		since = horizon
+	}
+	return since, nil
+}
+
+// CalendarAsOf returns the versions of the events in a calendar the user
+// can read as they stood at at, by UID. Per-resource access is checked as
+// it is now, not as it was. Times before HistorySince, and in the future,
+// are rejected.
+func (s *Service) CalendarAsOf(ctx context.Context, user *store.User, calendarID int64, at time.Time) ([]store.EventRevision, error) {
+	cal, err := s.GetCalendar(ctx, user, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	if s.store.EventRevisions == nil {
+		return nil, fmt.Errorf("event history not available")
+	}
+	now := time.Now()
+	if at.After(now) {
+		return nil, fmt.Errorf("%w: time is in the future", ErrBadRequest)
+	}
+	since, err := s.HistorySince(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if at.Before(since) {
+		return nil, fmt.Errorf("%w: history is only kept from %s", ErrBadRequest, since.UTC().Format(time.RFC3339))
+	}
+	revisions, err := s.store.EventRevisions.ListAsOf(ctx, calendarID, at)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(revisions))
+	for i, rev := range revisions {
+		names[i] = rev.ResourceName
+	}
+	readable, err := s.ReadableResources(ctx, user, cal, names)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]store.EventRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		if readable[rev.ResourceName] {
+			visible = append(visible, rev)
+		}
+	}
+	return visible, nil
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// SplitImport splits an ICS file into one calendar object per event, each
+// carrying the file's time zones, ready for ImportEvent.
+func SplitImport(ics string) ([]string, error) {
+	objects, err := utils.ParseICSFile(ics)
+	if err != nil {
+		return nil, errors.Join(ErrBadRequest, err)
+	}
+	return objects, nil
+}
+
+// ImportEvent writes one calendar object of an imported file, replacing the
+// calendar's event with the same UID, and returns the UID and whether the
+// event was created. Objects without a UID are given one. The object is
+// validated like any other API write.
+func (s *Service) ImportEvent(ctx context.Context, user *store.User, calendarID int64, object string) (string, bool, error) {
+	object = utils.NormalizeExchangeICal(object)
+	uid := utils.ExtractUID(object)
+	if uid == "" {
+		uid = utils.GenerateUID()
+		object = utils.EnsureUID(object, uid)
+	}
+	existing, err := s.store.Events.GetByUID(ctx, calendarID, uid)
+	if err != nil {
+		return uid, false, err
+	}
+	input := UpsertInput{RawICS: object, ContentType: "text/calendar"}
+	if existing == nil {
+		_, _, err = s.CreateEvent(ctx, user, calendarID, input)
+		return uid, err == nil, err
+	}
+	_, _, err = s.UpdateEvent(ctx, user, calendarID, uid, input)
+	return uid, false, err
+}
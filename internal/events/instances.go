@@ -0,0 +1,358 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// Instance is one occurrence of a recurring event.
+type Instance struct {
+	// RecurrenceID is the start the recurrence rule gives the occurrence,
+	// which identifies it even after it is moved.
+	RecurrenceID time.Time
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	// Overridden is set when a component with this RECURRENCE-ID replaces
+	// the occurrence.
+	Overridden bool
+	// Cancelled is set for occurrences removed by EXDATE or overridden with
+	// STATUS:CANCELLED.
+	Cancelled bool
+	// Rescheduled is set when an override moved the start or end.
+	Rescheduled bool
+	// Summary is the override's summary when it differs from the series.
+	Summary string
+}
+
+// instanceComponent is the part of a VEVENT needed to expand instances.
+type instanceComponent struct {
+	start        time.Time
+	end          *time.Time
+	allDay       bool
+	recurrenceID *time.Time
+	rrule        map[string]string
+	exdates      []time.Time
+	cancelled    bool
+	summary      string
+}
+
+// EventInstances returns the occurrences of the event with uid whose original
+// or current time overlaps [start, end), ordered by recurrence ID. A
+// non-recurring event has a single instance.
+func (s *Service) EventInstances(ctx context.Context, user *store.User, calendarID int64, uid string, start, end time.Time) ([]Instance, error) {
+	ev, err := s.GetEvent(ctx, user, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	return expandInstances(*ev, start, end), nil
+}
+
+// expandInstances expands the master component of ev by FREQ, INTERVAL,
+// COUNT, UNTIL and, for weekly rules, BYDAY, then applies EXDATEs and
+// RECURRENCE-ID overrides. Steps are taken in the DTSTART time zone so
+// instances keep their wall-clock time across DST changes.
+func expandInstances(ev store.Event, start, end time.Time) []Instance {
+	var master *instanceComponent
+	var overrides []instanceComponent
+	for _, raw := range utils.ICalComponents(ev.RawICAL, "VEVENT") {
+		comp, ok := parseInstanceComponent(raw)
+		if !ok {
+			continue
+		}
+		if comp.recurrenceID != nil {
+			overrides = append(overrides, comp)
+		} else if master == nil {
+			master = &comp
+		}
+	}
+	if master == nil {
+		return nil
+	}
+
+	duration := time.Duration(0)
+	if master.end != nil && master.end.After(master.start) {
+		duration = master.end.Sub(master.start)
+	} else if ev.DTStart != nil && ev.DTEnd != nil && ev.DTEnd.After(*ev.DTStart) {
+		duration = ev.DTEnd.Sub(*ev.DTStart)
+	} else if master.allDay {
+		duration = 24 * time.Hour
+	}
+	overlaps := func(s time.Time, d time.Duration) bool {
+		if d == 0 {
+			return !s.Before(start) && s.Before(end)
+		}
+		return s.Before(end) && s.Add(d).After(start)
+	}
+
+	var instances []Instance
+	byID := map[int64]int{}
+	for _, occurrence := range ruleOccurrences(*master, start.Add(-duration), end) {
+		if !overlaps(occurrence, duration) {
+			continue
+		}
+		byID[occurrence.Unix()] = len(instances)
+		instances = append(instances, Instance{
+			RecurrenceID: occurrence.UTC(),
+			Start:        occurrence.UTC(),
+			End:          occurrence.Add(duration).UTC(),
+			AllDay:       master.allDay,
+		})
+	}
+	for _, exdate := range master.exdates {
+		if i, ok := byID[exdate.Unix()]; ok {
+			instances[i].Cancelled = true
+		}
+	}
+
+	for _, o := range overrides {
+		oStart := o.start
+		oDuration := duration
+		if o.end != nil && o.end.After(o.start) {
+			oDuration = o.end.Sub(o.start)
+		}
+		inst := Instance{
+			RecurrenceID: o.recurrenceID.UTC(),
+			Start:        oStart.UTC(),
+			End:          oStart.Add(oDuration).UTC(),
+			AllDay:       o.allDay,
+			Overridden:   true,
+			Cancelled:    o.cancelled,
+			Rescheduled:  !oStart.Equal(*o.recurrenceID) || oDuration != duration,
+		}
+		if o.summary != master.summary {
+			inst.Summary = o.summary
+		}
+		if i, ok := byID[o.recurrenceID.Unix()]; ok {
+			inst.Cancelled = inst.Cancelled || instances[i].Cancelled
+			instances[i] = inst
+			continue
+		}
+		// Moved in from outside the range, or an occurrence the supported
+		// rule parts do not produce.
+		if overlaps(oStart, oDuration) || overlaps(*o.recurrenceID, duration) {
+			instances = append(instances, inst)
+		}
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].RecurrenceID.Before(instances[j].RecurrenceID)
+	})
+	return instances
+}
+
+// ruleOccurrences returns the starts the master's rule gives in [from, end),
+// capped at caldavMaxInstances. Occurrences before from still count towards
+// COUNT. Without a rule it returns DTSTART alone.
+func ruleOccurrences(master instanceComponent, from, end time.Time) []time.Time {
+	first := master.start
+	if master.rrule == nil {
+		return []time.Time{first}
+	}
+	rule := master.rrule
+	interval := 1
+	if v, err := strconv.Atoi(rule["INTERVAL"]); err == nil && v > 0 {
+		interval = v
+	}
+	count := maxBusyIterations
+	if v, err := strconv.Atoi(rule["COUNT"]); err == nil && v > 0 && v < count {
+		count = v
+	}
+	until := end
+	if raw := rule["UNTIL"]; raw != "" {
+		if t, _, err := parseInstanceTime(raw, nil, first.Location()); err == nil && t.Before(until) {
+			until = t
+		}
+	}
+	freq := strings.ToUpper(rule["FREQ"])
+
+	var occurrences []time.Time
+	seen := 0
+	// emit records the next occurrence and reports whether to go on.
+	emit := func(t time.Time) bool {
+		// DTSTART is always the first instance, even past UNTIL (RFC 5545 §3.8.5.3).
+		if seen >= count || (seen > 0 && t.After(until)) || !t.Before(end) || len(occurrences) >= caldavMaxInstances {
+			return false
+		}
+		seen++
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	}
+	if !emit(first) {
+		return occurrences
+	}
+
+	var weekdays []time.Weekday
+	if freq == "WEEKLY" {
+		for _, day := range strings.Split(rule["BYDAY"], ",") {
+			if wd, ok := icalWeekdays[strings.ToUpper(strings.TrimSpace(day))]; ok {
+				weekdays = append(weekdays, wd)
+			}
+		}
+	}
+	if len(weekdays) > 0 {
+		wkst := time.Monday
+		if wd, ok := icalWeekdays[strings.ToUpper(rule["WKST"])]; ok {
+			wkst = wd
+		}
+		sinceWkst := func(wd time.Weekday) int { return (int(wd) - int(wkst) + 7) % 7 }
+		sort.Slice(weekdays, func(a, b int) bool { return sinceWkst(weekdays[a]) < sinceWkst(weekdays[b]) })
+		// Walk whole weeks from the one DTSTART falls in.
+		weekStart := first.AddDate(0, 0, -sinceWkst(first.Weekday()))
+		for i := 0; i < maxBusyIterations; i++ {
+			week := weekStart.AddDate(0, 0, 7*interval*i)
+			for _, wd := range weekdays {
+				day := week.AddDate(0, 0, sinceWkst(wd))
+				if !day.After(first) {
+					continue
+				}
+				if !emit(day) {
+					return occurrences
+				}
+			}
+		}
+		return occurrences
+	}
+
+	for i := 1; i < maxBusyIterations; i++ {
+		var next time.Time
+		switch freq {
+		case "DAILY":
+			next = first.AddDate(0, 0, i*interval)
+		case "WEEKLY":
+			next = first.AddDate(0, 0, 7*i*interval)
+		case "MONTHLY":
+			next = first.AddDate(0, i*interval, 0)
+			if next.Day() != first.Day() {
+				// The month is too short for the day; RFC 5545 skips it.
+				continue
+			}
+		case "YEARLY":
+			next = first.AddDate(i*interval, 0, 0)
+			if next.Day() != first.Day() {
+				continue
+			}
+		default:
+			// Unsupported frequencies only count the first instance.
+			return occurrences
+		}
+		if !emit(next) {
+			break
+		}
+	}
+	return occurrences
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseInstanceComponent reads the properties of a VEVENT that bear on its
+// instances, ignoring nested components such as VALARM.
+func parseInstanceComponent(raw string) (instanceComponent, bool) {
+	var comp instanceComponent
+	hasStart := false
+	depth := 0
+	for _, line := range utils.UnfoldLines(raw) {
+		line = strings.TrimSpace(line)
+		name, params, value, ok := parseInstanceProperty(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			depth++
+			continue
+		case "END":
+			depth--
+			continue
+		}
+		if depth != 1 {
+			continue
+		}
+		switch name {
+		case "DTSTART":
+			if t, allDay, err := parseInstanceTime(value, params, nil); err == nil {
+				comp.start, comp.allDay, hasStart = t, allDay, true
+			}
+		case "DTEND":
+			if t, _, err := parseInstanceTime(value, params, nil); err == nil {
+				comp.end = &t
+			}
+		case "RECURRENCE-ID":
+			if t, _, err := parseInstanceTime(value, params, nil); err == nil {
+				comp.recurrenceID = &t
+			}
+		case "RRULE":
+			if comp.rrule == nil {
+				comp.rrule = extractICalRRULE("RRULE:" + value)
+			}
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseInstanceTime(v, params, nil); err == nil {
+					comp.exdates = append(comp.exdates, t)
+				}
+			}
+		case "STATUS":
+			comp.cancelled = strings.EqualFold(strings.TrimSpace(value), "CANCELLED")
+		case "SUMMARY":
+			comp.summary = value
+		}
+	}
+	return comp, hasStart
+}
+
+func parseInstanceProperty(line string) (string, map[string]string, string, bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// parseInstanceTime parses a DATE or DATE-TIME value. Floating times are read
+// in the TZID parameter's zone, else in fallback, else UTC. All-day dates are
+// midnight in that zone.
+func parseInstanceTime(value string, params map[string]string, fallback *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	loc := time.UTC
+	if fallback != nil {
+		loc = fallback
+	}
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if len(value) == 8 || strings.EqualFold(params["VALUE"], "DATE") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
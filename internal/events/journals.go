@@ -0,0 +1,223 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// journalDate is the layout of Journal.Date and JournalFilter bounds.
+const journalDate = "2006-01-02"
+
+// Journal is a VJOURNAL entry, such as a daily note, from a calendar.
+type Journal struct {
+	CalendarID  int64
+	UID         string
+	Summary     string
+	Description string
+	Status      string
+	Categories  []string
+	// Date is the day of DTSTART as YYYY-MM-DD, or "" when the entry is
+	// undated. A DATE value names the same day everywhere (RFC 5545
+	// §3.3.4); a local time is taken as written and a UTC time is read in
+	// the filter's location.
+	Date         string
+	ETag         string
+	LastModified time.Time
+}
+
+// JournalFilter narrows ListJournals. From and To are inclusive YYYY-MM-DD
+// days; either may be empty. Undated entries are left out when either is
+// set.
+type JournalFilter struct {
+	From, To string
+	// Location dates UTC start times; nil means UTC.
+	Location *time.Location
+}
+
+// JournalInput is a new dated journal entry.
+type JournalInput struct {
+	Date        string   `json:"date"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Categories  []string `json:"categories"`
+}
+
+// ListJournals returns the journal entries of a calendar the user can read,
+// newest day first.
+func (s *Service) ListJournals(ctx context.Context, user *store.User, calendarID int64, filter JournalFilter) ([]Journal, error) {
+	for _, bound := range []string{filter.From, filter.To} {
+		if _, err := time.Parse(journalDate, bound); bound != "" && err != nil {
+			return nil, fmt.Errorf("%w: dates must be YYYY-MM-DD", ErrBadRequest)
+		}
+	}
+	events, err := s.ListEvents(ctx, user, calendarID, store.EventFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var journals []Journal
+	for _, ev := range events {
+		journal, ok := parseJournal(ev.RawICAL, filter.Location)
+		if !ok || !filter.matches(journal) {
+			continue
+		}
+		journal.CalendarID = calendarID
+		if journal.UID == "" {
+			journal.UID = ev.UID
+		}
+		journal.ETag, journal.LastModified = ev.ETag, ev.LastModified
+		journals = append(journals, journal)
+	}
+	sort.SliceStable(journals, func(i, j int) bool {
+		if journals[i].Date != journals[j].Date {
+			return journals[i].Date > journals[j].Date
+		}
+		return journals[i].LastModified.After(journals[j].LastModified)
+	})
+	return journals, nil
+}
+
+// CreateJournal adds a journal entry for a day to a calendar the user can
+// write. The day is stored as a DATE, so it reads the same in every time
+// zone.
+func (s *Service) CreateJournal(ctx context.Context, user *store.User, calendarID int64, input JournalInput) (*Journal, error) {
+	day, err := time.Parse(journalDate, strings.TrimSpace(input.Date))
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrBadRequest)
+	}
+	summary, description := strings.TrimSpace(input.Summary), strings.TrimSpace(input.Description)
+	if summary == "" && description == "" {
+		return nil, fmt.Errorf("%w: a summary or description is required", ErrBadRequest)
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//CalCard//CalDAV Server//EN\r\nBEGIN:VJOURNAL\r\n")
+	fmt.Fprintf(&b, "UID:%s\r\n", utils.GenerateUID())
+	fmt.Fprintf(&b, "DTSTAMP:%s\r\n", time.Now().UTC().Format("20060102T150405Z"))
+	fmt.Fprintf(&b, "DTSTART;VALUE=DATE:%s\r\n", day.Format("20060102"))
+	if summary != "" {
+		fmt.Fprintf(&b, "SUMMARY:%s\r\n", utils.EscapeICalValue(summary))
+	}
+	if description != "" {
+		fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", utils.EscapeICalValue(description))
+	}
+	var categories []string
+	for _, c := range input.Categories {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, utils.EscapeICalValue(c))
+		}
+	}
+	if len(categories) > 0 {
+		fmt.Fprintf(&b, "CATEGORIES:%s\r\n", strings.Join(categories, ","))
+	}
+	b.WriteString("STATUS:FINAL\r\nEND:VJOURNAL\r\nEND:VCALENDAR\r\n")
+	ev, _, err := s.CreateEvent(ctx, user, calendarID, UpsertInput{RawICS: b.String(), ContentType: "text/calendar"})
+	if err != nil {
+		return nil, err
+	}
+	journal, _ := parseJournal(ev.RawICAL, nil)
+	journal.CalendarID = calendarID
+	journal.ETag, journal.LastModified = ev.ETag, ev.LastModified
+	return &journal, nil
+}
+
+func (f JournalFilter) matches(j Journal) bool {
+	if f.From == "" && f.To == "" {
+		return true
+	}
+	if j.Date == "" {
+		return false
+	}
+	// YYYY-MM-DD strings order as their days do.
+	return (f.From == "" || j.Date >= f.From) && (f.To == "" || j.Date <= f.To)
+}
+
+// parseJournal reads the master VJOURNAL of a stored object, reporting false
+// when there is none.
+func parseJournal(raw string, loc *time.Location) (Journal, bool) {
+	for _, comp := range utils.ICalComponents(raw, "VJOURNAL") {
+		if journal, master := parseJournalComponent(comp, loc); master {
+			return journal, true
+		}
+	}
+	return Journal{}, false
+}
+
+// parseJournalComponent reads the top-level properties of one VJOURNAL,
+// reporting whether it is the master (no RECURRENCE-ID).
+func parseJournalComponent(raw string, loc *time.Location) (Journal, bool) {
+	var journal Journal
+	master := true
+	depth := 0
+	for _, line := range utils.UnfoldLines(raw) {
+		name, _, value, ok := parseInstanceProperty(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			depth++
+			continue
+		case "END":
+			depth--
+			continue
+		}
+		if depth != 1 {
+			continue
+		}
+		switch name {
+		case "UID":
+			journal.UID = strings.TrimSpace(value)
+		case "SUMMARY":
+			journal.Summary = unescapeTaskText(value)
+		case "DESCRIPTION":
+			// RFC 5545 allows several DESCRIPTIONs on a VJOURNAL.
+			if journal.Description != "" {
+				journal.Description += "\n\n"
+			}
+			journal.Description += unescapeTaskText(value)
+		case "STATUS":
+			journal.Status = strings.ToUpper(strings.TrimSpace(value))
+		case "CATEGORIES":
+			for _, c := range splitTaskList(value) {
+				if c != "" {
+					journal.Categories = append(journal.Categories, c)
+				}
+			}
+		case "DTSTART":
+			journal.Date = journalDay(value, loc)
+		case "RECURRENCE-ID":
+			master = false
+		}
+	}
+	return journal, master
+}
+
+// journalDay returns the day a DTSTART value falls on. DATE values and local
+// times keep the day they were written with; only UTC times are converted,
+// into loc.
+func journalDay(value string, loc *time.Location) string {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return ""
+		}
+		if loc != nil {
+			t = t.In(loc)
+		}
+		return t.Format(journalDate)
+	}
+	if len(value) < 8 {
+		return ""
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return ""
+	}
+	return t.Format(journalDate)
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	// DefaultEventLinkLifetime is how long an event link lasts when no
+	// lifetime is asked for.
+	DefaultEventLinkLifetime = 7 * 24 * time.Hour
+	// MaxEventLinkLifetime bounds how long an event link can last.
+	MaxEventLinkLifetime = 90 * 24 * time.Hour
+)
+
+// CreateEventLink issues an expiring read-only link to an event the user
+// can read. A zero lifetime uses DefaultEventLinkLifetime.
+func (s *Service) CreateEventLink(ctx context.Context, user *store.User, calendarID int64, uid string, lifetime time.Duration) (*store.EventLink, error) {
+	if lifetime == 0 {
+		lifetime = DefaultEventLinkLifetime
+	}
+	if lifetime < time.Minute || lifetime > MaxEventLinkLifetime {
+		return nil, fmt.Errorf("%w: links must last between a minute and %d days", ErrBadRequest, int(MaxEventLinkLifetime.Hours()/24))
+	}
+	ev, err := s.GetEvent(ctx, user, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return s.store.EventLinks.Create(ctx, store.EventLink{
+		UserID:     user.ID,
+		CalendarID: calendarID,
+		UID:        ev.UID,
+		Token:      base64.RawURLEncoding.EncodeToString(buf),
+		ExpiresAt:  time.Now().Add(lifetime).UTC(),
+	})
+}
+
+// ListEventLinks returns the user's unexpired links to an event, newest
+// first.
+func (s *Service) ListEventLinks(ctx context.Context, user *store.User, calendarID int64, uid string) ([]store.EventLink, error) {
+	ev, err := s.GetEvent(ctx, user, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.EventLinks.ListForEvent(ctx, user.ID, calendarID, ev.UID)
+}
+
+// RevokeEventLink deletes one of the user's event links.
+func (s *Service) RevokeEventLink(ctx context.Context, user *store.User, id int64) error {
+	err := s.store.EventLinks.Delete(ctx, user.ID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// OpenEventLink returns the event a link points to and counts the access.
+// The event is read as the link's creator, so a link stops working once
+// they can no longer read it.
+func (s *Service) OpenEventLink(ctx context.Context, token string) (*store.Event, error) {
+	link, err := s.store.EventLinks.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrNotFound
+	}
+	creator, err := s.store.Users.GetByID(ctx, link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if creator == nil {
+		return nil, ErrNotFound
+	}
+	ev, err := s.GetEvent(ctx, creator, link.CalendarID, link.UID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.EventLinks.RecordAccess(ctx, link.ID); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const (
+	maxLocationNameLength    = 200
+	maxLocationAddressLength = 1000
+	// maxLocationSuggestions caps SuggestLocations.
+	maxLocationSuggestions = 20
+)
+
+// LocationInput is a saved place as the user enters it.
+type LocationInput struct {
+	Name      string
+	Address   string
+	Latitude  *float64
+	Longitude *float64
+}
+
+// LocationSuggestion is a place offered while the user types a location:
+// a saved place, with its ID, or a location of one of their past events.
+type LocationSuggestion struct {
+	Name       string
+	LocationID int64
+}
+
+// ListLocations returns the user's saved places.
+func (s *Service) ListLocations(ctx context.Context, user *store.User) ([]store.Location, error) {
+	return s.store.Locations.ListByUser(ctx, user.ID)
+}
+
+// CreateLocation saves a place for the user.
+func (s *Service) CreateLocation(ctx context.Context, user *store.User, input LocationInput) (*store.Location, error) {
+	loc, err := validateLocation(input)
+	if err != nil {
+		return nil, err
+	}
+	loc.UserID = user.ID
+	created, err := s.store.Locations.Create(ctx, loc)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, fmt.Errorf("%w: a location with that name already exists", ErrConflict)
+	}
+	return created, err
+}
+
+// UpdateLocation changes one of the user's saved places. Events it was
+// already written into are not changed.
+func (s *Service) UpdateLocation(ctx context.Context, user *store.User, id int64, input LocationInput) (*store.Location, error) {
+	loc, err := validateLocation(input)
+	if err != nil {
+		return nil, err
+	}
+	loc.ID, loc.UserID = id, user.ID
+	updated, err := s.store.Locations.Update(ctx, loc)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, ErrNotFound
+	case errors.Is(err, store.ErrConflict):
+		return nil, fmt.Errorf("%w: a location with that name already exists", ErrConflict)
+	}
+	return updated, err
+}
+
+// DeleteLocation removes one of the user's saved places.
+func (s *Service) DeleteLocation(ctx context.Context, user *store.User, id int64) error {
+	if _, err := s.ownedLocation(ctx, user, id); err != nil {
+		return err
+	}
+	return s.store.Locations.Delete(ctx, user.ID, id)
+}
+
+// SuggestLocations autocompletes a location: the user's saved places whose
+// name or address contains query, then the locations of their past events
+// that do, most recently used first.
+func (s *Service) SuggestLocations(ctx context.Context, user *store.User, query string) ([]LocationSuggestion, error) {
+	query = strings.TrimSpace(query)
+	saved, err := s.store.Locations.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	var suggestions []LocationSuggestion
+	seen := map[string]bool{}
+	needle := strings.ToLower(query)
+	for _, loc := range saved {
+		if strings.Contains(strings.ToLower(loc.Name), needle) || strings.Contains(strings.ToLower(loc.Address), needle) {
+			suggestions = append(suggestions, LocationSuggestion{Name: loc.Name, LocationID: loc.ID})
+			seen[strings.ToLower(loc.Name)] = true
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return strings.HasPrefix(strings.ToLower(suggestions[i].Name), needle) && !strings.HasPrefix(strings.ToLower(suggestions[j].Name), needle)
+	})
+	if len(suggestions) >= maxLocationSuggestions {
+		return suggestions[:maxLocationSuggestions], nil
+	}
+	past, err := s.store.Locations.History(ctx, user.ID, query, maxLocationSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range past {
+		if len(suggestions) == maxLocationSuggestions {
+			break
+		}
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			suggestions = append(suggestions, LocationSuggestion{Name: name})
+		}
+	}
+	return suggestions, nil
+}
+
+func (s *Service) ownedLocation(ctx context.Context, user *store.User, id int64) (*store.Location, error) {
+	loc, err := s.store.Locations.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil || loc.UserID != user.ID {
+		return nil, ErrNotFound
+	}
+	return loc, nil
+}
+
+func validateLocation(input LocationInput) (store.Location, error) {
+	loc := store.Location{
+		Name:      strings.TrimSpace(input.Name),
+		Address:   strings.TrimSpace(input.Address),
+		Latitude:  input.Latitude,
+		Longitude: input.Longitude,
+	}
+	switch {
+	case loc.Name == "" || len(loc.Name) > maxLocationNameLength:
+		return loc, fmt.Errorf("%w: name is required and must be at most %d characters", ErrBadRequest, maxLocationNameLength)
+	case len(loc.Address) > maxLocationAddressLength:
+		return loc, fmt.Errorf("%w: address must be at most %d characters", ErrBadRequest, maxLocationAddressLength)
+	case (loc.Latitude == nil) != (loc.Longitude == nil):
+		return loc, fmt.Errorf("%w: latitude and longitude must be given together", ErrBadRequest)
+	case loc.Latitude != nil && (*loc.Latitude < -90 || *loc.Latitude > 90 || *loc.Longitude < -180 || *loc.Longitude > 180):
+		return loc, fmt.Errorf("%w: coordinates out of range", ErrBadRequest)
+	}
+	return loc, nil
+}
+
+// locationProperties are the properties applyLocation replaces.
+var locationProperties = map[string]bool{
+	"LOCATION":                    true,
+	"GEO":                         true,
+	"X-APPLE-STRUCTURED-LOCATION": true,
+}
+
+// applyLocation writes a saved place into every VEVENT of body as LOCATION,
+// and, when it has coordinates, GEO and the X-APPLE-STRUCTURED-LOCATION that
+// Apple clients show a map for.
+func applyLocation(body string, loc store.Location) string {
+	text := loc.Name
+	if loc.Address != "" {
+		text += "\n" + loc.Address
+	}
+	props := []string{"LOCATION:" + utils.EscapeICalValue(text)}
+	if loc.Latitude != nil {
+		lat := strconv.FormatFloat(*loc.Latitude, 'f', -1, 64)
+		lon := strconv.FormatFloat(*loc.Longitude, 'f', -1, 64)
+		props = append(props,
+			"GEO:"+lat+";"+lon,
+			"X-APPLE-STRUCTURED-LOCATION;VALUE=URI;X-ADDRESS="+quoteParam(loc.Address)+";X-APPLE-RADIUS=70;X-TITLE="+quoteParam(loc.Name)+":geo:"+lat+","+lon,
+		)
+	}
+
+	var out []string
+	depth, eventDepth := 0, 0
+	for _, line := range utils.UnfoldLines(body) {
+		if line == "" {
+			continue
+		}
+		name, value := splitContentLine(line)
+		switch {
+		case name == "BEGIN":
+			depth++
+			if strings.EqualFold(value, "VEVENT") {
+				eventDepth = depth
+			}
+		case name == "END":
+			if depth == eventDepth {
+				out = append(out, props...)
+				eventDepth = 0
+			}
+			depth--
+		case depth == eventDepth && locationProperties[name]:
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\r\n") + "\r\n"
+}
+
+// quoteParam quotes a parameter value. Double quotes cannot be escaped in
+// parameters, so they become single quotes, and line breaks become spaces.
+func quoteParam(value string) string {
+	value = strings.NewReplacer(`"`, "'", "\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+	return `"` + value + `"`
+}
+
+// savedLocation writes the place input.Structured.LocationID names into
+// body, if it names one.
+func (s *Service) savedLocation(ctx context.Context, user *store.User, input UpsertInput, body string) (string, error) {
+	if input.Structured == nil || input.Structured.LocationID == 0 {
+		return body, nil
+	}
+	loc, err := s.ownedLocation(ctx, user, input.Structured.LocationID)
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: unknown locationId", ErrBadRequest)
+	}
+	if err != nil {
+		return "", err
+	}
+	return applyLocation(body, *loc), nil
+}
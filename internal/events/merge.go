@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// maxMergeConflictsReported caps the conflicting UIDs a failed merge names.
+const maxMergeConflictsReported = 10
+
+// SplitInput selects the events split off into a new calendar named Name.
+// Set fields are ANDed; at least one of Category, From and To is required.
+type SplitInput struct {
+	Name     string
+	Category string // case-insensitive match on any category
+	// From matches events starting at or after it.
+	From *time.Time
+	// To matches events that end before it. Recurring events match only
+	// when their rule ends (UNTIL) before it.
+	To *time.Time
+}
+
+// MergeCalendars moves every event of the user's calendar sourceID into
+// their calendar targetID, keeping UIDs. Clients syncing the source see the
+// events deleted; the source itself is kept, empty. The merge moves nothing
+// and fails with ErrConflict when the target already holds one of the UIDs
+// or resource names.
+func (s *Service) MergeCalendars(ctx context.Context, user *store.User, sourceID, targetID int64) (int, error) {
+	if sourceID == targetID {
+		return 0, fmt.Errorf("%w: cannot merge a calendar into itself", ErrBadRequest)
+	}
+	for _, id := range []int64{sourceID, targetID} {
+		if err := s.requireOwnedCalendar(ctx, user, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.checkMergeConflicts(ctx, sourceID, targetID); err != nil {
+		return 0, err
+	}
+	moved, err := s.store.Events.MoveAllToCalendar(ctx, sourceID, targetID, nil)
+	if errors.Is(err, store.ErrConflict) {
+		return 0, fmt.Errorf("%w: the target calendar changed during the merge, try again", ErrConflict)
+	}
+	return moved, err
+}
+
+// SplitCalendar moves the events of the user's calendar sourceID that match
+// input into a new calendar, which takes the source's timezone and color.
+func (s *Service) SplitCalendar(ctx context.Context, user *store.User, sourceID int64, input SplitInput) (*store.Calendar, int, error) {
+	name := strings.TrimSpace(input.Name)
+	switch {
+	case name == "":
+		return nil, 0, fmt.Errorf("%w: name is required", ErrBadRequest)
+	case strings.TrimSpace(input.Category) == "" && input.From == nil && input.To == nil:
+		return nil, 0, fmt.Errorf("%w: select events by category, from or to", ErrBadRequest)
+	case input.From != nil && input.To != nil && !input.From.Before(*input.To):
+		return nil, 0, fmt.Errorf("%w: from must be before to", ErrBadRequest)
+	}
+	if err := s.requireOwnedCalendar(ctx, user, sourceID); err != nil {
+		return nil, 0, err
+	}
+	source, err := s.store.Calendars.GetByID(ctx, sourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if source == nil {
+		return nil, 0, ErrNotFound
+	}
+
+	all, err := s.store.Events.ListForCalendar(ctx, sourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	selection := BatchDeleteInput{Before: input.To, Category: input.Category}
+	uids := []string{}
+	for _, ev := range all {
+		if input.From != nil && (ev.DTStart == nil || ev.DTStart.Before(*input.From)) {
+			continue
+		}
+		if selection.matches(ev) {
+			uids = append(uids, ev.UID)
+		}
+	}
+	return s.store.Events.SplitToNewCalendar(ctx, store.Calendar{
+		UserID:   user.ID,
+		Name:     name,
+		Timezone: source.Timezone,
+		Color:    source.Color,
+	}, sourceID, uids)
+}
+
+// checkMergeConflicts reports the source events whose UID or resource name
+// the target already uses.
+func (s *Service) checkMergeConflicts(ctx context.Context, sourceID, targetID int64) error {
+	source, err := s.store.Events.ListForCalendar(ctx, sourceID)
+	if err != nil {
+		return err
+	}
+	target, err := s.store.Events.ListForCalendar(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]bool, 2*len(target))
+	for _, ev := range target {
+		taken["uid:"+ev.UID] = true
+		taken["name:"+eventResourceName(ev)] = true
+	}
+	var conflicts []string
+	for _, ev := range source {
+		if taken["uid:"+ev.UID] || taken["name:"+eventResourceName(ev)] {
+			conflicts = append(conflicts, ev.UID)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	more := ""
+	if len(conflicts) > maxMergeConflictsReported {
+		more = fmt.Sprintf(" and %d more", len(conflicts)-maxMergeConflictsReported)
+		conflicts = conflicts[:maxMergeConflictsReported]
+	}
+	return fmt.Errorf("%w: the target calendar already has events %s%s", ErrConflict, strings.Join(conflicts, ", "), more)
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// Method returns the upper-cased iTIP METHOD of a calendar, or "" when it
+// is not a scheduling message.
+func Method(raw string) string {
+	for _, line := range utils.UnfoldLines(raw) {
+		upper := strings.ToUpper(strings.TrimSpace(line))
+		if strings.HasPrefix(upper, "BEGIN:") && upper != "BEGIN:VCALENDAR" {
+			break
+		}
+		if name, _, value := splitProperty(strings.TrimSpace(line)); name == "METHOD" {
+			return strings.ToUpper(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// StripMethod removes the METHOD property from a calendar, leaving the
+// objects of a scheduling message to be stored as they are.
+func StripMethod(raw string) string {
+	var sb strings.Builder
+	inHeader := true
+	for _, line := range utils.UnfoldLines(raw) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		upper := strings.ToUpper(trimmed)
+		if strings.HasPrefix(upper, "BEGIN:") && upper != "BEGIN:VCALENDAR" {
+			inHeader = false
+		}
+		if name, _, _ := splitProperty(trimmed); inHeader && name == "METHOD" {
+			continue
+		}
+		sb.WriteString(trimmed + "\r\n")
+	}
+	return sb.String()
+}
+
+// ProcessSchedulingMessage returns what to store when an iTIP message, such
+// as an invitation .ics dragged into a client, is written to calendarID.
+// REQUEST, PUBLISH and ADD store its objects without the METHOD. CANCEL
+// marks the stored event, or just the instances the message names, as
+// cancelled. REPLY sets the replying attendees' PARTSTAT on the stored
+// event. Other methods, and replies to events not in the calendar, are
+// ErrConflict.
+func (s *Service) ProcessSchedulingMessage(ctx context.Context, calendarID int64, raw string) (string, error) {
+	method := Method(raw)
+	stripped := StripMethod(raw)
+	switch method {
+	case "", "REQUEST", "PUBLISH", "ADD":
+		return stripped, nil
+	case "CANCEL", "REPLY":
+	default:
+		return "", fmt.Errorf("%w: unsupported METHOD %s", ErrConflict, method)
+	}
+	_, components, _ := utils.SplitComponents(stripped)
+	if len(components) == 0 {
+		return "", fmt.Errorf("%w: %s carries no event", ErrConflict, method)
+	}
+	uid := ""
+	for _, line := range componentProperties(components[0]) {
+		if name, _, value := splitProperty(line); name == "UID" {
+			uid = strings.TrimSpace(value)
+		}
+	}
+	existing, err := s.store.Events.GetByUID(ctx, calendarID, uid)
+	if err != nil {
+		return "", err
+	}
+	if method == "CANCEL" {
+		if existing == nil {
+			return cancelComponents(stripped, nil), nil
+		}
+		return cancelInstances(existing.RawICAL, components), nil
+	}
+	if existing == nil {
+		return "", fmt.Errorf("%w: REPLY to an event not in this calendar", ErrConflict)
+	}
+	partstats := map[string]string{}
+	for _, lines := range components {
+		for _, line := range componentProperties(lines) {
+			name, params, value := splitProperty(line)
+			addr, ok := normalizeAddress(value)
+			if partstat := strings.ToUpper(paramValue(params, "PARTSTAT")); ok && name == "ATTENDEE" && partstat != "" && partstats[addr] == "" {
+				partstats[addr] = partstat
+			}
+		}
+	}
+	return setResourcePartstats(existing.RawICAL, partstats, ""), nil
+}
+
+// cancelInstances applies a CANCEL carrying cancelled to the stored event
+// raw. A component without RECURRENCE-ID cancels the whole event; otherwise
+// the named instances are cancelled, adding overrides for those that have
+// none.
+func cancelInstances(raw string, cancelled [][]string) string {
+	ids := map[string]bool{}
+	for _, lines := range cancelled {
+		id := strings.TrimSpace(utils.RecurrenceIDValue(componentProperties(lines)))
+		if id == "" {
+			return cancelComponents(raw, nil)
+		}
+		ids[id] = true
+	}
+	header, components, footer := utils.SplitComponents(raw)
+	for _, lines := range components {
+		delete(ids, strings.TrimSpace(utils.RecurrenceIDValue(componentProperties(lines))))
+	}
+	for _, lines := range cancelled {
+		if ids[strings.TrimSpace(utils.RecurrenceIDValue(componentProperties(lines)))] {
+			components = append(components, lines)
+		}
+	}
+	return cancelComponents(utils.BuildFromComponents(header, components, footer), cancelled)
+}
+
+// cancelComponents sets STATUS:CANCELLED on the components of raw, or only
+// on those whose RECURRENCE-ID is among only's when only is not nil.
+func cancelComponents(raw string, only [][]string) string {
+	ids := map[string]bool{}
+	for _, lines := range only {
+		ids[strings.TrimSpace(utils.RecurrenceIDValue(componentProperties(lines)))] = true
+	}
+	header, components, footer := utils.SplitComponents(raw)
+	for i, lines := range components {
+		if only != nil && !ids[strings.TrimSpace(utils.RecurrenceIDValue(componentProperties(lines)))] {
+			continue
+		}
+		rewritten := make([]string, 0, len(lines)+1)
+		depth := 0
+		set := false
+		for _, line := range lines {
+			upper := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(upper, "BEGIN:"):
+				if depth == 0 && !set {
+					rewritten = append(rewritten, "STATUS:CANCELLED")
+					set = true
+				}
+				depth++
+			case strings.HasPrefix(upper, "END:"):
+				depth--
+			case depth == 0:
+				if name, _, _ := splitProperty(line); name == "STATUS" {
+					if !set {
+						rewritten = append(rewritten, "STATUS:CANCELLED")
+						set = true
+					}
+					continue
+				}
+			}
+			rewritten = append(rewritten, line)
+		}
+		if !set {
+			rewritten = append(rewritten, "STATUS:CANCELLED")
+		}
+		components[i] = rewritten
+	}
+	return utils.BuildFromComponents(header, components, footer)
+}
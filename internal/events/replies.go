@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// AttendeeResponses are the PARTSTAT values an attendee can reply with.
+var AttendeeResponses = []string{partstatAccepted, "TENTATIVE", partstatDeclined}
+
+// SetAttendeeResponse records attendee's answer to the event calendarID/uid
+// by setting their PARTSTAT in every instance, as an iTIP REPLY from them
+// would. It takes no user: the caller must already have authenticated the
+// attendee, as RSVP links do with a signed token.
+func (s *Service) SetAttendeeResponse(ctx context.Context, calendarID int64, uid, attendee, partstat string) (*store.Event, error) {
+	partstat = strings.ToUpper(strings.TrimSpace(partstat))
+	valid := false
+	for _, p := range AttendeeResponses {
+		valid = valid || p == partstat
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: response must be ACCEPTED, TENTATIVE or DECLINED", ErrBadRequest)
+	}
+	addr, ok := normalizeAddress(attendee)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid attendee", ErrBadRequest)
+	}
+	event, err := s.store.Events.GetByUID(ctx, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrNotFound
+	}
+	switch AttendeePartstat(event.RawICAL, addr) {
+	case "":
+		return nil, ErrNotFound
+	case partstat:
+		return event, nil
+	}
+	body := setResourcePartstats(event.RawICAL, map[string]string{addr: partstat}, "")
+	updated, _, err := s.saveEvent(ctx, calendarID, event.UID, event.ResourceName, body, event.ETag, "")
+	return updated, err
+}
+
+// AttendeePartstat returns the upper-cased PARTSTAT of attendee in the
+// event's first component, NEEDS-ACTION when they have none, or "" when
+// they are not invited.
+func AttendeePartstat(raw, attendee string) string {
+	addr, ok := normalizeAddress(attendee)
+	if !ok {
+		return ""
+	}
+	_, components, _ := utils.SplitComponents(raw)
+	if len(components) == 0 {
+		return ""
+	}
+	for _, line := range componentProperties(components[0]) {
+		name, params, value := splitProperty(line)
+		if candidate, ok := normalizeAddress(value); !ok || name != "ATTENDEE" || candidate != addr {
+			continue
+		}
+		if partstat := paramValue(params, "PARTSTAT"); partstat != "" {
+			return strings.ToUpper(partstat)
+		}
+		return "NEEDS-ACTION"
+	}
+	return ""
+}
+
+// OrganizerAddress returns the lower-cased email address of the event's
+// ORGANIZER, or "" when it has none.
+func OrganizerAddress(raw string) string {
+	return parseScheduling(raw).organizer
+}
+
+// ReplyCalendar builds the iTIP REPLY for attendee's answer to the event:
+// METHOD:REPLY with every ATTENDEE but them left out, ready to send to the
+// organizer by iMIP.
+func ReplyCalendar(raw, attendee string) string {
+	addr, _ := normalizeAddress(attendee)
+	header, components, footer := utils.SplitComponents(raw)
+	for i, lines := range components {
+		kept := make([]string, 0, len(lines))
+		depth := 0
+		for _, line := range lines {
+			upper := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(upper, "BEGIN:"):
+				depth++
+			case strings.HasPrefix(upper, "END:"):
+				depth--
+			case depth == 0:
+				name, _, value := splitProperty(line)
+				if candidate, ok := normalizeAddress(value); name == "ATTENDEE" && (!ok || candidate != addr) {
+					continue
+				}
+			}
+			kept = append(kept, line)
+		}
+		components[i] = kept
+	}
+	if len(header) > 0 && strings.EqualFold(strings.TrimSpace(header[0]), "BEGIN:VCALENDAR") {
+		header = append([]string{header[0], "METHOD:REPLY"}, header[1:]...)
+	}
+	return utils.BuildFromComponents(header, components, footer)
+}
+
+// paramValue returns the unquoted value of the named parameter in params,
+// which are the ";"-led parameters of a content line.
+func paramValue(params, name string) string {
+	quoted := false
+	start := 0
+	for i := 0; i <= len(params); i++ {
+		if i < len(params) {
+			switch params[i] {
+			case '"':
+				quoted = !quoted
+				continue
+			case ';':
+				if quoted || i == 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if key, val, ok := strings.Cut(strings.TrimPrefix(params[start:i], ";"), "="); ok && strings.EqualFold(key, name) {
+			return strings.Trim(val, `"`)
+		}
+		start = i
+	}
+	return ""
+}
@@ -0,0 +1,493 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// BookedFromProperty records, on a resource calendar's copy of an event, the
+// calendar of the organizer's copy.
+const BookedFromProperty = "X-CALCARD-BOOKED-FROM"
+
+const (
+	// resourceWindow is how far ahead recurring events are checked for
+	// conflicts.
+	resourceWindow        = 366 * 24 * time.Hour
+	maxPriorityOrganizers = 100
+	partstatAccepted      = "ACCEPTED"
+	partstatDeclined      = "DECLINED"
+	resourceKindRoom      = "ROOM"
+	resourceKindResource  = "RESOURCE"
+	attendeeMailtoPrefix  = "mailto:"
+)
+
+// SchedulingResource returns the resource settings of a calendar the user
+// owns, or nil when it is not a resource.
+func (s *Service) SchedulingResource(ctx context.Context, user *store.User, calendarID int64) (*store.SchedulingResource, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	if s.store.Resources == nil {
+		return nil, nil
+	}
+	return s.store.Resources.GetByCalendar(ctx, calendarID)
+}
+
+// SetSchedulingResource makes a calendar the user owns a bookable resource.
+// Kind defaults to ROOM and Policy to first-come.
+func (s *Service) SetSchedulingResource(ctx context.Context, user *store.User, calendarID int64, res store.SchedulingResource) (*store.SchedulingResource, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	email, ok := normalizeAddress(res.Email)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid resource email", ErrBadRequest)
+	}
+	res.CalendarID, res.Email = calendarID, email
+	switch res.Kind = strings.ToUpper(strings.TrimSpace(res.Kind)); res.Kind {
+	case "":
+		res.Kind = resourceKindRoom
+	case resourceKindRoom, resourceKindResource:
+	default:
+		return nil, fmt.Errorf("%w: kind must be ROOM or RESOURCE", ErrBadRequest)
+	}
+	switch res.Policy = strings.ToLower(strings.TrimSpace(res.Policy)); res.Policy {
+	case "":
+		res.Policy = store.ResourcePolicyFirstCome
+	case store.ResourcePolicyFirstCome, store.ResourcePolicyPriority:
+	default:
+		return nil, fmt.Errorf("%w: policy must be first-come or priority", ErrBadRequest)
+	}
+	if len(res.PriorityOrganizers) > maxPriorityOrganizers {
+		return nil, fmt.Errorf("%w: at most %d priority organizers", ErrBadRequest, maxPriorityOrganizers)
+	}
+	organizers := make([]string, 0, len(res.PriorityOrganizers))
+	for _, raw := range res.PriorityOrganizers {
+		organizer, ok := normalizeAddress(raw)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid priority organizer %q", ErrBadRequest, raw)
+		}
+		organizers = append(organizers, organizer)
+	}
+	res.PriorityOrganizers = organizers
+	if s.store.Resources == nil {
+		return nil, fmt.Errorf("scheduling resources not available")
+	}
+	saved, err := s.store.Resources.Upsert(ctx, res)
+	if err == store.ErrConflict {
+		return nil, fmt.Errorf("%w: another calendar already uses %s", ErrConflict, email)
+	}
+	return saved, err
+}
+
+// DeleteSchedulingResource stops a calendar the user owns from being a
+// bookable resource. Its bookings stay in the calendar.
+func (s *Service) DeleteSchedulingResource(ctx context.Context, user *store.User, calendarID int64) error {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return err
+	}
+	if s.store.Resources == nil {
+		return nil
+	}
+	return s.store.Resources.Delete(ctx, calendarID)
+}
+
+// ScheduleResources books the rooms and resources that body, the organizer's
+// copy of an event, invites, and sets each one's PARTSTAT to ACCEPTED or, when
+// it is taken at any of the event's times, DECLINED. previous is the copy
+// body replaces, if any; resources it invited that body no longer does are
+// released. Copies written by attendees are left alone. It reports whether
+// body changed.
+func (s *Service) ScheduleResources(ctx context.Context, calendarID int64, body, previous string) (string, bool, error) {
+	if s == nil || s.store == nil || s.store.Resources == nil {
+		return body, false, nil
+	}
+	event := parseScheduling(body)
+	before := parseScheduling(previous)
+	addresses := append(append([]string{}, event.attendees...), before.attendees...)
+	if len(addresses) == 0 {
+		return body, false, nil
+	}
+	resources, err := s.store.Resources.ListByEmails(ctx, addresses)
+	if err != nil {
+		return "", false, err
+	}
+	if len(resources) == 0 {
+		return body, false, nil
+	}
+	if organizer, err := s.writtenByOrganizer(ctx, calendarID, event); err != nil || !organizer {
+		return body, false, err
+	}
+
+	// Occurrences that are over cannot conflict.
+	from, ok := firstStart(body)
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	to := from.Add(resourceWindow)
+	requested := ok && !event.cancelled
+	var incoming []busyInterval
+	if requested {
+		incoming = busyIntervals(body, from, to)
+	}
+
+	decisions := map[string]string{}
+	for _, res := range resources {
+		if res.CalendarID == calendarID {
+			// The resource's own calendar holds its bookings.
+			continue
+		}
+		if !requested || !containsAddress(event.attendees, res.Email) {
+			if err := s.releaseResource(ctx, res, calendarID, event.uid, before.uid); err != nil {
+				return "", false, err
+			}
+			continue
+		}
+		partstat, err := s.reserveResource(ctx, res, calendarID, body, event, incoming, from, to)
+		if err != nil {
+			return "", false, err
+		}
+		decisions[res.Email] = partstat
+	}
+	if len(decisions) == 0 {
+		return body, false, nil
+	}
+	rewritten := setResourcePartstats(body, decisions, "")
+	return rewritten, rewritten != body, nil
+}
+
+// ReleaseResources frees the resource bookings of raw, an event being
+// deleted from calendarID.
+func (s *Service) ReleaseResources(ctx context.Context, calendarID int64, raw string) error {
+	_, _, err := s.ScheduleResources(ctx, calendarID, "", raw)
+	return err
+}
+
+// writtenByOrganizer reports whether calendarID belongs to the event's
+// organizer. Events without an ORGANIZER are the calendar owner's.
+func (s *Service) writtenByOrganizer(ctx context.Context, calendarID int64, event schedulingEvent) (bool, error) {
+	if event.organizer == "" {
+		return true, nil
+	}
+	if s.store.Calendars == nil || s.store.Users == nil {
+		return false, nil
+	}
+	cal, err := s.store.Calendars.GetByID(ctx, calendarID)
+	if err != nil || cal == nil {
+		return false, err
+	}
+	owner, err := s.store.Users.GetByID(ctx, cal.UserID)
+	if err != nil || owner == nil {
+		return false, err
+	}
+	return owner.HasEmail(event.organizer), nil
+}
+
+// reserveResource books res for the event under the resource's lock and
+// returns the attendee's new PARTSTAT.
+func (s *Service) reserveResource(ctx context.Context, res store.SchedulingResource, calendarID int64, body string, event schedulingEvent, incoming []busyInterval, from, to time.Time) (string, error) {
+	rank := organizerRank(res, event.organizer)
+	partstat := partstatDeclined
+	var displaced []store.Event
+	err := s.store.Resources.Reserve(ctx, res.CalendarID, func(bookings []store.Event) (store.ResourceReservation, error) {
+		var reservation store.ResourceReservation
+		displaced = nil
+		partstat = partstatAccepted
+		ownCopy := false
+		for _, booking := range bookings {
+			if booking.UID == event.uid {
+				ownCopy = true
+				continue
+			}
+			if !overlapsAny(busyIntervals(booking.RawICAL, from, to), incoming) {
+				continue
+			}
+			other := parseScheduling(booking.RawICAL)
+			if res.Policy != store.ResourcePolicyPriority || organizerRank(res, other.organizer) <= rank {
+				partstat = partstatDeclined
+				break
+			}
+			displaced = append(displaced, booking)
+		}
+		if partstat == partstatDeclined {
+			displaced = nil
+			if ownCopy {
+				reservation.Release = []string{event.uid}
+			}
+			return reservation, nil
+		}
+		for _, booking := range displaced {
+			reservation.Release = append(reservation.Release, booking.UID)
+		}
+		booked := setResourcePartstats(body, map[string]string{res.Email: partstatAccepted}, BookedFromProperty+":"+strconv.FormatInt(calendarID, 10))
+		reservation.Book = &store.Event{
+			UID:          event.uid,
+			ResourceName: utils.ResourceNameForUID(event.uid),
+			RawICAL:      booked,
+			ETag:         utils.GenerateETag(booked),
+		}
+		return reservation, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, booking := range displaced {
+		// The resource calendar is already right; a displaced organizer's
+		// copy that cannot be updated here is corrected on its next write.
+		s.declineDisplaced(ctx, res, booking)
+	}
+	return partstat, nil
+}
+
+// releaseResource removes the resource's booking of uid when it was booked
+// from calendarID.
+func (s *Service) releaseResource(ctx context.Context, res store.SchedulingResource, calendarID int64, uids ...string) error {
+	return s.store.Resources.Reserve(ctx, res.CalendarID, func(bookings []store.Event) (store.ResourceReservation, error) {
+		var reservation store.ResourceReservation
+		for _, booking := range bookings {
+			for _, uid := range uids {
+				if uid != "" && booking.UID == uid && bookedFrom(booking.RawICAL) == calendarID {
+					reservation.Release = append(reservation.Release, uid)
+				}
+			}
+		}
+		return reservation, nil
+	})
+}
+
+// declineDisplaced marks the resource DECLINED in the organizer's copy of a
+// booking another organizer took over.
+func (s *Service) declineDisplaced(ctx context.Context, res store.SchedulingResource, booking store.Event) {
+	calendarID := bookedFrom(booking.RawICAL)
+	if calendarID == 0 || s.store.Events == nil {
+		return
+	}
+	ev, err := s.store.Events.GetByUID(ctx, calendarID, booking.UID)
+	if err != nil || ev == nil {
+		return
+	}
+	raw := setResourcePartstats(ev.RawICAL, map[string]string{res.Email: partstatDeclined}, "")
+	if raw == ev.RawICAL {
+		return
+	}
+	ev.RawICAL = raw
+	ev.ETag = utils.GenerateETag(raw)
+	_, _ = s.store.Events.Upsert(ctx, *ev)
+}
+
+func organizerRank(res store.SchedulingResource, organizer string) int {
+	for i, candidate := range res.PriorityOrganizers {
+		if strings.EqualFold(candidate, organizer) {
+			return i
+		}
+	}
+	return len(res.PriorityOrganizers)
+}
+
+// schedulingEvent is the part of an event that bears on resource booking.
+type schedulingEvent struct {
+	uid       string
+	organizer string
+	attendees []string
+	cancelled bool
+}
+
+func parseScheduling(raw string) schedulingEvent {
+	var event schedulingEvent
+	if strings.TrimSpace(raw) == "" {
+		return event
+	}
+	_, components, _ := utils.SplitComponents(raw)
+	for i, lines := range components {
+		for _, line := range componentProperties(lines) {
+			name, _, value := splitProperty(line)
+			switch name {
+			case "UID":
+				event.uid = strings.TrimSpace(value)
+			case "ORGANIZER":
+				event.organizer, _ = normalizeAddress(value)
+			case "ATTENDEE":
+				if addr, ok := normalizeAddress(value); ok && !containsAddress(event.attendees, addr) {
+					event.attendees = append(event.attendees, addr)
+				}
+			case "STATUS":
+				// Only the series' status cancels the whole event.
+				if i == 0 && utils.RecurrenceIDValue(lines) == "" {
+					event.cancelled = strings.EqualFold(strings.TrimSpace(value), "CANCELLED")
+				}
+			}
+		}
+	}
+	return event
+}
+
+// setResourcePartstats sets the PARTSTAT of the attendees in partstats, by
+// address, in every component of raw, and adds extra to each component when
+// it is not empty.
+func setResourcePartstats(raw string, partstats map[string]string, extra string) string {
+	header, components, footer := utils.SplitComponents(raw)
+	for i, lines := range components {
+		rewritten := make([]string, 0, len(lines)+1)
+		depth := 0
+		inserted := extra == ""
+		for _, line := range lines {
+			upper := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(upper, "BEGIN:"):
+				if !inserted {
+					rewritten = append(rewritten, extra)
+					inserted = true
+				}
+				depth++
+			case strings.HasPrefix(upper, "END:"):
+				depth--
+			case depth == 0:
+				name, params, value := splitProperty(line)
+				if name == BookedFromProperty && extra != "" {
+					continue
+				}
+				if addr, ok := normalizeAddress(value); ok && name == "ATTENDEE" && partstats[addr] != "" {
+					line = name + setParam(params, "PARTSTAT", partstats[addr]) + ":" + value
+				}
+			}
+			rewritten = append(rewritten, line)
+		}
+		if !inserted {
+			rewritten = append(rewritten, extra)
+		}
+		components[i] = rewritten
+	}
+	return utils.BuildFromComponents(header, components, footer)
+}
+
+// splitProperty splits a content line into its upper-cased name, its
+// parameters including the leading semicolon, and its value, honouring
+// quoted parameter values.
+func splitProperty(line string) (string, string, string) {
+	quoted := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if quoted {
+				continue
+			}
+			head := line[:i]
+			name, params := head, ""
+			if j := strings.Index(head, ";"); j >= 0 {
+				name, params = head[:j], head[j:]
+			}
+			return strings.ToUpper(name), params, line[i+1:]
+		}
+	}
+	return "", "", ""
+}
+
+// setParam replaces or adds a parameter in params, which are the ";"-led
+// parameters of a content line.
+func setParam(params, name, value string) string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range params {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted && i > 0:
+			parts = append(parts, params[start+1:i])
+			start = i
+		}
+	}
+	if params != "" {
+		parts = append(parts, params[start+1:])
+	}
+	out := ""
+	replaced := false
+	for _, part := range parts {
+		if key, _, _ := strings.Cut(part, "="); strings.EqualFold(key, name) {
+			part = name + "=" + value
+			replaced = true
+		}
+		out += ";" + part
+	}
+	if !replaced {
+		out += ";" + name + "=" + value
+	}
+	return out
+}
+
+func normalizeAddress(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > len(attendeeMailtoPrefix) && strings.EqualFold(raw[:len(attendeeMailtoPrefix)], attendeeMailtoPrefix) {
+		raw = raw[len(attendeeMailtoPrefix):]
+	}
+	addr, err := netmail.ParseAddress(raw)
+	if err != nil || addr.Address != raw {
+		return "", false
+	}
+	return strings.ToLower(addr.Address), true
+}
+
+func containsAddress(addresses []string, addr string) bool {
+	for _, candidate := range addresses {
+		if strings.EqualFold(candidate, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func bookedFrom(raw string) int64 {
+	for _, line := range utils.UnfoldLines(raw) {
+		if name, _, value := splitProperty(line); name == BookedFromProperty {
+			id, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return id
+		}
+	}
+	return 0
+}
+
+type busyInterval struct {
+	start, end time.Time
+}
+
+// firstStart returns the DTSTART of the series in raw.
+func firstStart(raw string) (time.Time, bool) {
+	for _, component := range utils.ICalComponents(raw, "VEVENT") {
+		if comp, ok := parseInstanceComponent(component); ok && comp.recurrenceID == nil {
+			return comp.start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// busyIntervals returns the times raw's instances hold in [from, to),
+// leaving out cancelled ones.
+func busyIntervals(raw string, from, to time.Time) []busyInterval {
+	var intervals []busyInterval
+	for _, inst := range expandInstances(store.Event{RawICAL: raw}, from, to) {
+		if !inst.Cancelled && inst.End.After(inst.Start) {
+			intervals = append(intervals, busyInterval{inst.Start, inst.End})
+		}
+	}
+	return intervals
+}
+
+func overlapsAny(a, b []busyInterval) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.start.Before(y.end) && y.start.Before(x.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
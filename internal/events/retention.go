@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// MaxRetentionMonths bounds a retention policy at a century.
+const MaxRetentionMonths = 1200
+
+// RetentionPolicy returns the retention policy of a calendar the user owns,
+// or nil when none is set.
+func (s *Service) RetentionPolicy(ctx context.Context, user *store.User, calendarID int64) (*store.RetentionPolicy, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	if s.store.Retention == nil {
+		return nil, nil
+	}
+	return s.store.Retention.GetByCalendar(ctx, calendarID)
+}
+
+// SetRetentionPolicy makes a calendar the user owns delete its events once
+// they have been over for months months.
+func (s *Service) SetRetentionPolicy(ctx context.Context, user *store.User, calendarID int64, months int) (*store.RetentionPolicy, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	if months < 1 || months > MaxRetentionMonths {
+		return nil, fmt.Errorf("%w: months must be between 1 and %d", ErrBadRequest, MaxRetentionMonths)
+	}
+	if s.store.Retention == nil {
+		return nil, fmt.Errorf("retention policies not available")
+	}
+	return s.store.Retention.Upsert(ctx, store.RetentionPolicy{CalendarID: calendarID, Months: months})
+}
+
+// DeleteRetentionPolicy stops a calendar the user owns from deleting old
+// events.
+func (s *Service) DeleteRetentionPolicy(ctx context.Context, user *store.User, calendarID int64) error {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return err
+	}
+	if s.store.Retention == nil {
+		return nil
+	}
+	return s.store.Retention.Delete(ctx, calendarID)
+}
+
+// RetentionDeletions returns the newest events the retention policy of a
+// calendar the user owns has deleted.
+func (s *Service) RetentionDeletions(ctx context.Context, user *store.User, calendarID int64, limit int) ([]store.RetentionDeletion, error) {
+	if err := s.requireOwnedCalendar(ctx, user, calendarID); err != nil {
+		return nil, err
+	}
+	if s.store.Retention == nil {
+		return nil, nil
+	}
+	return s.store.Retention.ListDeletions(ctx, calendarID, limit)
+}
+
+// RetentionCutoff returns the time events must have ended by to be deleted
+// under a policy of months months at now.
+func RetentionCutoff(months int, now time.Time) time.Time {
+	return now.UTC().AddDate(0, -months, 0)
+}
+
+// Expired reports whether every occurrence of ev, moved ones included,
+// ended before cutoff. Objects without a VEVENT, such as tasks, never
+// expire, and neither do recurring events without COUNT or UNTIL.
+func Expired(ev store.Event, cutoff time.Time) bool {
+	var master *instanceComponent
+	for _, raw := range utils.ICalComponents(ev.RawICAL, "VEVENT") {
+		if comp, ok := parseInstanceComponent(raw); ok && comp.recurrenceID == nil {
+			master = &comp
+			break
+		}
+	}
+	if master == nil {
+		return false
+	}
+	if rule := master.rrule; rule != nil {
+		count, _ := strconv.Atoi(rule["COUNT"])
+		until, _, err := parseInstanceTime(rule["UNTIL"], nil, master.start.Location())
+		switch {
+		case rule["UNTIL"] != "" && err == nil:
+			if !until.Before(cutoff) {
+				return false
+			}
+		case count <= 0 || count >= maxBusyIterations:
+			// Too many occurrences to be sure the last is found.
+			return false
+		}
+	}
+	return len(expandInstances(ev, cutoff, cutoff.AddDate(MaxRetentionMonths/12, 0, 0))) == 0
+}
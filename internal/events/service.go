@@ -2,7 +2,6 @@ package events
 
 import (
 	"context"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jw6ventures/calcard/internal/maintenance"
+	"github.com/jw6ventures/calcard/internal/scan"
 	"github.com/jw6ventures/calcard/internal/store"
 	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
@@ -23,10 +24,16 @@ var (
 	ErrConflict             = errors.New("conflict")
 	ErrPreconditionFailed   = errors.New("precondition failed")
 	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrScanUnavailable reports that inline attachments could not be
+	// checked because the malware scanner failed.
+	ErrScanUnavailable = errors.New("attachment scanner unavailable")
 )
 
 type Service struct {
 	store *store.Store
+	// uidHost, when set, is the host new events' UIDs must be issued for;
+	// see RequireServerUIDs.
+	uidHost string
 }
 
 func NewService(st *store.Store) *Service {
@@ -44,24 +51,39 @@ type StructuredRecurrence struct {
 }
 
 type StructuredInput struct {
-	UID          string                `json:"uid"`
-	Summary      string                `json:"summary"`
-	DTStart      string                `json:"dtstart"`
-	DTEnd        string                `json:"dtend"`
-	AllDay       bool                  `json:"allDay"`
-	Location     string                `json:"location"`
-	Description  string                `json:"description"`
-	Timezone     string                `json:"timezone"`
-	URL          string                `json:"url"`
-	Status       string                `json:"status"`
-	Categories   []string              `json:"categories"`
-	Class        string                `json:"class"`
-	Transparency string                `json:"transparency"`
-	Organizer    string                `json:"organizer"`
-	Attendees    []string              `json:"attendees"`
-	Attachments  []string              `json:"attachments"`
-	Reminders    []int                 `json:"reminders"`
-	Recurrence   *StructuredRecurrence `json:"recurrence"`
+	UID      string `json:"uid"`
+	Summary  string `json:"summary"`
+	DTStart  string `json:"dtstart"`
+	DTEnd    string `json:"dtend"`
+	AllDay   bool   `json:"allDay"`
+	Location string `json:"location"`
+	// LocationID is one of the user's saved places, written into the event
+	// in place of Location with its address and coordinates.
+	LocationID  int64  `json:"locationId"`
+	Description string `json:"description"`
+	// DescriptionFormat is "text" (the default), "markdown" or "html".
+	// Markdown descriptions are rendered to HTML alongside the source; for
+	// HTML, DescriptionHTML is sanitized and Description, when empty, is
+	// derived from it.
+	DescriptionFormat string `json:"descriptionFormat"`
+	DescriptionHTML   string `json:"descriptionHtml"`
+	Timezone          string `json:"timezone"`
+	URL               string `json:"url"`
+	Conference        string `json:"conference"`
+	// RequestConference asks the calendar's conferencing webhook for a
+	// meeting link when Conference is empty.
+	RequestConference bool                  `json:"requestConference"`
+	Color             string                `json:"color"`
+	Image             string                `json:"image"`
+	Status            string                `json:"status"`
+	Categories        []string              `json:"categories"`
+	Class             string                `json:"class"`
+	Transparency      string                `json:"transparency"`
+	Organizer         string                `json:"organizer"`
+	Attendees         []string              `json:"attendees"`
+	Attachments       []string              `json:"attachments"`
+	Reminders         []int                 `json:"reminders"`
+	Recurrence        *StructuredRecurrence `json:"recurrence"`
 }
 
 type UpsertInput struct {
@@ -76,6 +98,11 @@ func (s *Service) ListCalendars(ctx context.Context, user *store.User) ([]store.
 	return s.store.Calendars.ListAccessible(ctx, user.ID)
 }
 
+// ListCalendarsPage returns one page of ListCalendars with event counts.
+func (s *Service) ListCalendarsPage(ctx context.Context, user *store.User, limit, offset int) (*store.PaginatedResult[store.CalendarAccess], error) {
+	return s.store.Calendars.ListAccessiblePage(ctx, user.ID, limit, offset)
+}
+
 func (s *Service) GetCalendar(ctx context.Context, user *store.User, calendarID int64) (*store.CalendarAccess, error) {
 	cal, err := s.store.Calendars.GetAccessible(ctx, calendarID, user.ID)
 	if err != nil {
@@ -179,6 +206,28 @@ func (s *Service) ListEvents(ctx context.Context, user *store.User, calendarID i
 	return visible, nil
 }
 
+// ReadableResources returns which of the event resource names in cal user may
+// read, applying per-resource ACLs the same way ListEvents does.
+func (s *Service) ReadableResources(ctx context.Context, user *store.User, cal *store.CalendarAccess, resourceNames []string) (map[string]bool, error) {
+	events := make([]store.Event, len(resourceNames))
+	for i, name := range resourceNames {
+		events[i] = store.Event{CalendarID: cal.ID, ResourceName: name}
+	}
+	entries, err := s.prefetchCalendarACLEntries(ctx, user, cal.ID, events)
+	if err != nil {
+		return nil, err
+	}
+	readable := make(map[string]bool, len(resourceNames))
+	for _, name := range resourceNames {
+		allowed, err := s.canReadCalendarResourceWithEntries(user, cal, name, entries)
+		if err != nil {
+			return nil, err
+		}
+		readable[name] = allowed
+	}
+	return readable, nil
+}
+
 func (s *Service) GetEvent(ctx context.Context, user *store.User, calendarID int64, uid string) (*store.Event, error) {
 	ev, err := s.store.Events.GetByUID(ctx, calendarID, uid)
 	if err != nil {
@@ -211,10 +260,24 @@ func (s *Service) CreateEvent(ctx context.Context, user *store.User, calendarID
 		return nil, false, err
 	}
 
+	if s.uidHost != "" && input.Structured != nil && strings.TrimSpace(input.Structured.UID) == "" {
+		structured := *input.Structured
+		structured.UID = NewUID(s.uidHost)
+		input.Structured = &structured
+	}
 	body, uid, err := s.normalizeEventPayload(input, "")
 	if err != nil {
 		return nil, false, err
 	}
+	if err := s.checkServerUID(uid); err != nil {
+		return nil, false, err
+	}
+	if err := s.scanAttachments(ctx, body); err != nil {
+		return nil, false, err
+	}
+	if body, err = s.savedLocation(ctx, user, input, body); err != nil {
+		return nil, false, err
+	}
 	existing, err := s.store.Events.GetByUID(ctx, calendarID, uid)
 	if err != nil {
 		return nil, false, err
@@ -228,6 +291,12 @@ func (s *Service) CreateEvent(ctx context.Context, user *store.User, calendarID
 	if err := s.requireCalendarPrivilege(ctx, user, cal, uid, "bind"); err != nil {
 		return nil, false, err
 	}
+	if body, _, err = s.ProvisionConference(ctx, calendarID, body, conferenceRequested(input)); err != nil {
+		return nil, false, err
+	}
+	if body, _, err = s.ScheduleResources(ctx, calendarID, body, ""); err != nil {
+		return nil, false, err
+	}
 
 	event, created, err := s.saveEvent(ctx, calendarID, uid, uid, body, input.IfMatch, input.IfNoneMatch)
 	return event, created, err
@@ -252,6 +321,17 @@ func (s *Service) UpdateEvent(ctx context.Context, user *store.User, calendarID
 	if normalizedUID != uid {
 		return nil, false, fmt.Errorf("%w: uid mismatch", ErrBadRequest)
 	}
+	if err := s.scanAttachments(ctx, body); err != nil {
+		return nil, false, err
+	}
+	if input.Structured != nil {
+		// Structured input rebuilds the event; keep dismissals made on
+		// other devices.
+		body = utils.CarryAlarmState(existing.RawICAL, body)
+	}
+	if body, err = s.savedLocation(ctx, user, input, body); err != nil {
+		return nil, false, err
+	}
 
 	resourceName := existing.ResourceName
 	if resourceName == "" {
@@ -264,6 +344,12 @@ func (s *Service) UpdateEvent(ctx context.Context, user *store.User, calendarID
 	if err := s.requireCalendarPrivilege(ctx, user, cal, resourceName, "write-content"); err != nil {
 		return nil, false, err
 	}
+	if body, _, err = s.ProvisionConference(ctx, calendarID, body, conferenceRequested(input)); err != nil {
+		return nil, false, err
+	}
+	if body, _, err = s.ScheduleResources(ctx, calendarID, body, existing.RawICAL); err != nil {
+		return nil, false, err
+	}
 	event, created, err := s.saveEvent(ctx, calendarID, uid, resourceName, body, input.IfMatch, input.IfNoneMatch)
 	return event, created, err
 }
@@ -286,6 +372,12 @@ func (s *Service) DeleteEvent(ctx context.Context, user *store.User, calendarID
 	if err := s.requireCalendarPrivilege(ctx, user, cal, eventResourceName(*existing), "unbind"); err != nil {
 		return err
 	}
+	if err := maintenance.Check(ctx, s.store, maintenance.Calendar, calendarID); err != nil {
+		return err
+	}
+	if err := s.ReleaseResources(ctx, calendarID, existing.RawICAL); err != nil {
+		return err
+	}
 	return s.store.Events.DeleteByUID(ctx, calendarID, uid)
 }
 
@@ -477,20 +569,15 @@ func eventResourceName(event store.Event) string {
 }
 
 func applicableACLPrincipals(user *store.User) map[string]struct{} {
-	principals := map[string]struct{}{"DAV:all": {}}
-	if user != nil {
-		principals[fmt.Sprintf("/dav/principals/%d/", user.ID)] = struct{}{}
-		principals["DAV:authenticated"] = struct{}{}
+	principals := map[string]struct{}{}
+	for _, href := range store.ACLPrincipals(user) {
+		principals[href] = struct{}{}
 	}
 	return principals
 }
 
 func aclPrincipalHrefs(user *store.User) []string {
-	principals := []string{"DAV:all"}
-	if user != nil {
-		principals = append(principals, "DAV:authenticated", fmt.Sprintf("/dav/principals/%d/", user.ID))
-	}
-	return principals
+	return store.ACLPrincipals(user)
 }
 
 func calendarPrivilegeDecisionFromEntries(user *store.User, cal *store.CalendarAccess, resourceName, privilege string, entriesByPath map[string][]store.ACLEntry) (bool, bool) {
@@ -612,7 +699,7 @@ func (s *Service) normalizeEventPayload(input UpsertInput, expectedUID string) (
 		if err := validateCalendarContentType(input.ContentType); err != nil {
 			return "", "", err
 		}
-		body := strings.TrimSpace(input.RawICS)
+		body := utils.NormalizeExchangeICal(strings.TrimSpace(input.RawICS))
 		if err := validateStrictICalendar(body); err != nil {
 			return "", "", err
 		}
@@ -639,7 +726,30 @@ func (s *Service) normalizeEventPayload(input UpsertInput, expectedUID string) (
 	return body, uid, nil
 }
 
+// scanAttachments passes the inline attachments of body through the
+// configured malware scanner, if any.
+func (s *Service) scanAttachments(ctx context.Context, body string) error {
+	err := scan.ICalAttachments(ctx, s.store.Scanner, body)
+	var infected *scan.InfectedError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &infected):
+		return fmt.Errorf("%w: attachment rejected, %s found", ErrBadRequest, infected.Signature)
+	case errors.Is(err, scan.ErrMalformedAttachment):
+		return fmt.Errorf("%w: inline attachment is not valid base64", ErrBadRequest)
+	}
+	return fmt.Errorf("%w: %w", ErrScanUnavailable, err)
+}
+
+func conferenceRequested(input UpsertInput) bool {
+	return input.Structured != nil && input.Structured.RequestConference && strings.TrimSpace(input.Structured.Conference) == ""
+}
+
 func (s *Service) saveEvent(ctx context.Context, calendarID int64, uid, resourceName, body, ifMatch, ifNoneMatch string) (*store.Event, bool, error) {
+	if err := maintenance.Check(ctx, s.store, maintenance.Calendar, calendarID); err != nil {
+		return nil, false, err
+	}
 	existingByResource, err := s.store.Events.GetByResourceName(ctx, calendarID, resourceName)
 	if err != nil {
 		return nil, false, err
@@ -659,7 +769,7 @@ func (s *Service) saveEvent(ctx context.Context, calendarID int64, uid, resource
 		return nil, false, ErrPreconditionFailed
 	}
 
-	etag := fmt.Sprintf("%x", sha256.Sum256([]byte(body)))
+	etag := utils.GenerateETag(body)
 	created := existing == nil
 	ev, err := s.store.Events.Upsert(ctx, store.Event{
 		CalendarID:   calendarID,
@@ -699,6 +809,17 @@ func buildStructuredEvent(input *StructuredInput, expectedUID string) (string, s
 		uid = utils.GenerateUID()
 	}
 
+	descFormat := strings.ToLower(strings.TrimSpace(input.DescriptionFormat))
+	switch descFormat {
+	case "", utils.DescriptionText, utils.DescriptionMarkdown:
+	case utils.DescriptionHTML:
+		if strings.TrimSpace(input.DescriptionHTML) == "" {
+			return "", "", fmt.Errorf("%w: descriptionHtml is required for html descriptions", ErrBadRequest)
+		}
+	default:
+		return "", "", fmt.Errorf("%w: descriptionFormat must be text, markdown or html", ErrBadRequest)
+	}
+
 	var recurrence *utils.RecurrenceOptions
 	if input.Recurrence != nil {
 		recurrence = &utils.RecurrenceOptions{
@@ -715,6 +836,9 @@ func buildStructuredEvent(input *StructuredInput, expectedUID string) (string, s
 	opts := &utils.EventOptions{
 		Timezone:     strings.TrimSpace(input.Timezone),
 		URL:          strings.TrimSpace(input.URL),
+		Conference:   strings.TrimSpace(input.Conference),
+		Color:        strings.TrimSpace(input.Color),
+		Image:        strings.TrimSpace(input.Image),
 		Status:       strings.TrimSpace(input.Status),
 		Categories:   input.Categories,
 		Class:        strings.TrimSpace(input.Class),
@@ -723,6 +847,9 @@ func buildStructuredEvent(input *StructuredInput, expectedUID string) (string, s
 		Attendees:    input.Attendees,
 		Attachments:  input.Attachments,
 		Reminders:    input.Reminders,
+
+		DescriptionFormat: descFormat,
+		DescriptionHTML:   input.DescriptionHTML,
 	}
 
 	body := utils.BuildEvent(
@@ -803,6 +930,19 @@ func checkConditionalHeaders(ifMatch, ifNoneMatch string, existing *store.Event)
 	return true
 }
 
+// ValidateStored re-checks a stored calendar object against the rules
+// enforced when it is written and returns the UID it carries.
+func ValidateStored(raw string) (string, error) {
+	if err := validateStrictICalendar(raw); err != nil {
+		return "", err
+	}
+	uid, err := extractUIDFromICalendar(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	return uid, nil
+}
+
 func validateStrictICalendar(data string) error {
 	if err := validateICalendar(data); err != nil {
 		return fmt.Errorf("%w: invalid calendar data", ErrBadRequest)
@@ -936,7 +1076,7 @@ func countICalAttendees(icalData string) int {
 }
 
 func extractUIDFromICalendar(icalData string) (string, error) {
-	lines := utils.UnfoldLines(icalData)
+	lines := componentPropertyLines(icalData)
 	seenUIDs := make(map[string]struct{})
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
@@ -963,7 +1103,7 @@ func extractUIDFromICalendar(icalData string) (string, error) {
 
 func validateCalendarObjectResource(icalData string) []string {
 	var conditions []string
-	lines := utils.UnfoldLines(icalData)
+	lines := componentPropertyLines(icalData)
 	inEvent := false
 	currentUID := ""
 	seenUID := false
@@ -998,7 +1138,7 @@ func validateCalendarObjectResource(icalData string) []string {
 }
 
 func hasMultipleDifferentUIDs(icalData string) bool {
-	lines := utils.UnfoldLines(icalData)
+	lines := componentPropertyLines(icalData)
 	seenUIDs := make(map[string]struct{})
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
@@ -1013,6 +1153,33 @@ func hasMultipleDifferentUIDs(icalData string) bool {
 	return len(seenUIDs) > 1
 }
 
+// componentPropertyLines returns the unfolded lines of icalData without the
+// contents of sub-components such as VALARM, whose UID (RFC 9074) is not
+// the calendar object's.
+func componentPropertyLines(icalData string) []string {
+	var lines []string
+	depth := 0
+	for _, line := range utils.UnfoldLines(icalData) {
+		upper := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(upper, "BEGIN:"):
+			depth++
+			if depth > 2 {
+				continue
+			}
+		case strings.HasPrefix(upper, "END:"):
+			depth--
+			if depth >= 2 {
+				continue
+			}
+		case depth > 2:
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 func containsICalMethodProperty(icalData string) bool {
 	lines := utils.UnfoldLines(icalData)
 	for _, line := range lines {
@@ -1104,6 +1271,10 @@ func StatusCode(err error) int {
 		return http.StatusUnsupportedMediaType
 	case errors.Is(err, ErrBadRequest):
 		return http.StatusBadRequest
+	case errors.Is(err, ErrConferenceProvider):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrScanUnavailable), errors.Is(err, maintenance.ErrActive):
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
 	}
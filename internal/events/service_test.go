@@ -2,14 +2,22 @@ package events
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
 )
 
 func TestServiceCRUDAndValidation(t *testing.T) {
@@ -451,6 +459,9 @@ func TestHelpersAndValidators(t *testing.T) {
 		if err != nil || uid == "" || !strings.Contains(body, "RRULE:FREQ=DAILY;COUNT=2") {
 			t.Fatalf("unexpected result err=%v uid=%q body=%s", err, uid, body)
 		}
+		if _, _, err := buildStructuredEvent(&StructuredInput{Summary: "x", DTStart: "2026-03-20T10:00", DTEnd: "2026-03-20T11:00", DescriptionFormat: "rtf"}, ""); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("expected ErrBadRequest for unknown description format, got %v", err)
+		}
 	})
 
 	t.Run("content type validation", func(t *testing.T) {
@@ -537,6 +548,10 @@ func TestHelpersAndValidators(t *testing.T) {
 		if !hasMultipleDifferentUIDs("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nUID:b\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n") {
 			t.Fatal("expected multiple uids")
 		}
+		alarmUID := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nBEGIN:VALARM\r\nUID:alarm\r\nACKNOWLEDGED:20260320T094500Z\r\nEND:VALARM\r\nUID:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+		if uid, err := extractUIDFromICalendar(alarmUID); err != nil || uid != "a" || hasMultipleDifferentUIDs(alarmUID) || len(validateCalendarObjectResource(alarmUID)) != 0 {
+			t.Fatalf("expected the VALARM UID to be ignored, got uid=%q err=%v", uid, err)
+		}
 		if !containsICalMethodProperty(methodICS("x")) {
 			t.Fatal("expected method property")
 		}
@@ -607,6 +622,739 @@ func methodICS(uid string) string {
 	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:Planning\r\nDTSTART:20260320T100000Z\r\nDTEND:20260320T110000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
 }
 
+func TestEventInstancesFlagsExceptions(t *testing.T) {
+	raw := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"SUMMARY:Standup",
+		"DTSTART;TZID=America/New_York:20260302T090000",
+		"DTEND;TZID=America/New_York:20260302T091500",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6",
+		"EXDATE;TZID=America/New_York:20260304T090000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"SUMMARY:Standup (moved)",
+		"RECURRENCE-ID;TZID=America/New_York:20260309T090000",
+		"DTSTART;TZID=America/New_York:20260309T100000",
+		"DTEND;TZID=America/New_York:20260309T101500",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"SUMMARY:Standup",
+		"RECURRENCE-ID;TZID=America/New_York:20260311T090000",
+		"DTSTART;TZID=America/New_York:20260311T090000",
+		"DTEND;TZID=America/New_York:20260311T091500",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+		}},
+		Events:     &fakeEventRepo{events: map[string]store.Event{"1:standup": {CalendarID: 1, UID: "standup", RawICAL: raw}}},
+		ACLEntries: &fakeACLRepo{},
+	})
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	instances, err := svc.EventInstances(context.Background(), &store.User{ID: 1}, 1, "standup", start, start.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("EventInstances() error = %v", err)
+	}
+	if len(instances) != 6 {
+		t.Fatalf("expected 6 instances, got %+v", instances)
+	}
+	type flags struct{ overridden, cancelled, rescheduled bool }
+	want := []struct {
+		start string
+		flags flags
+	}{
+		{"2026-03-02T14:00:00Z", flags{}},
+		{"2026-03-04T14:00:00Z", flags{cancelled: true}},
+		// The 8th is the first Monday on daylight saving time.
+		{"2026-03-09T14:00:00Z", flags{overridden: true, rescheduled: true}},
+		{"2026-03-11T13:00:00Z", flags{overridden: true, cancelled: true}},
+		{"2026-03-16T13:00:00Z", flags{}},
+		{"2026-03-18T13:00:00Z", flags{}},
+	}
+	for i, w := range want {
+		inst := instances[i]
+		if got := inst.Start.Format(time.RFC3339); got != w.start {
+			t.Fatalf("instance %d starts %s, want %s", i, got, w.start)
+		}
+		if got := (flags{inst.Overridden, inst.Cancelled, inst.Rescheduled}); got != w.flags {
+			t.Fatalf("instance %d flags = %+v, want %+v", i, got, w.flags)
+		}
+	}
+	if instances[2].RecurrenceID.Format(time.RFC3339) != "2026-03-09T13:00:00Z" || instances[2].Summary != "Standup (moved)" || instances[3].Summary != "" {
+		t.Fatalf("unexpected overrides %+v %+v", instances[2], instances[3])
+	}
+
+	// A range after COUNT runs out has no instances; one holding only the
+	// moved occurrence's new time still reports it.
+	if got := expandInstances(store.Event{RawICAL: raw}, start.AddDate(0, 1, 0), start.AddDate(0, 2, 0)); len(got) != 0 {
+		t.Fatalf("expected no instances after COUNT, got %+v", got)
+	}
+	moved := time.Date(2026, 3, 9, 14, 0, 0, 0, time.UTC)
+	if got := expandInstances(store.Event{RawICAL: raw}, moved, moved.Add(time.Minute)); len(got) != 1 || !got[0].Rescheduled {
+		t.Fatalf("expected the moved instance, got %+v", got)
+	}
+
+	if _, err := svc.EventInstances(context.Background(), &store.User{ID: 1}, 1, "missing", start, start.AddDate(0, 1, 0)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("EventInstances() for a missing event error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEventInstancesDailyFromLongAgo(t *testing.T) {
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:pills\r\nDTSTART:20100101T080000Z\r\nDTEND:20100101T081000Z\r\nRRULE:FREQ=DAILY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	got := expandInstances(store.Event{RawICAL: raw}, start, start.AddDate(0, 0, 7))
+	if len(got) != 7 || got[0].Start != start.Add(8*time.Hour) || got[0].End != start.Add(8*time.Hour+10*time.Minute) {
+		t.Fatalf("expandInstances() = %+v", got)
+	}
+}
+
+func TestDueAlarmsFireForEachOccurrence(t *testing.T) {
+	f := storetest.New()
+	f.AddCalendar(storetest.OwnedCalendar(1, 1, "Home"))
+	f.AddCalendar(storetest.SharedCalendar(2, 2, "Team", "boss@example.com", true))
+	event := func(calendarID int64, uid, summary, body string) {
+		f.AddEvent(store.Event{CalendarID: calendarID, UID: uid, ResourceName: uid, Summary: &summary,
+			RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n" + body + "END:VEVENT\r\nEND:VCALENDAR\r\n"})
+	}
+	event(1, "pills", "Pills", "DTSTART:20260501T080000Z\r\nDTEND:20260501T081000Z\r\nRRULE:FREQ=DAILY\r\n"+
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n"+
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER;RELATED=END:PT0S\r\nACKNOWLEDGED:20260502T081000Z\r\nEND:VALARM\r\n")
+	event(1, "office", "Office", "DTSTART:20260502T090000Z\r\n"+
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT5M\r\nPROXIMITY:ARRIVE\r\nEND:VALARM\r\n"+
+		"BEGIN:VALARM\r\nUID:call\r\nACTION:AUDIO\r\nTRIGGER;VALUE=DATE-TIME:20260502T070000Z\r\nEND:VALARM\r\n")
+	event(2, "shared", "Shared", "DTSTART:20260502T080000Z\r\nBEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n")
+
+	svc := NewService(f.Store)
+	from := time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC)
+	due, err := svc.DueAlarms(context.Background(), &store.User{ID: 1}, from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("DueAlarms() error = %v", err)
+	}
+	var got []string
+	for _, a := range due {
+		got = append(got, a.UID+"/"+a.AlarmID+"@"+a.FireAt.Format("15:04")+" "+a.Summary)
+	}
+	// The end alarm of the day's pills was dismissed when it fired.
+	want := []string{"office/call@07:00 Office", "pills/0@07:45 Pills"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("DueAlarms() = %v, want %v", got, want)
+	}
+	due, err = svc.DueAlarms(context.Background(), &store.User{ID: 1}, from.Add(24*time.Hour), from.Add(48*time.Hour))
+	if err != nil || len(due) != 2 || due[1].FireAt != from.Add(32*time.Hour+10*time.Minute) {
+		t.Fatalf("DueAlarms() the next day = %+v, %v", due, err)
+	}
+}
+
+func TestBusyDensityBucketsByLocalHourOfWeek(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	start := time.Date(2026, 5, 4, 0, 0, 0, 0, loc)
+	busy := [][]BusyPeriod{
+		{
+			{Start: time.Date(2026, 5, 4, 13, 0, 0, 0, time.UTC), End: time.Date(2026, 5, 4, 13, 30, 0, 0, time.UTC)},
+			{Start: time.Date(2026, 5, 4, 14, 0, 0, 0, time.UTC), End: time.Date(2026, 5, 4, 15, 0, 0, 0, time.UTC)},
+		},
+		nil,
+	}
+	got := BusyDensity(busy, start, start.AddDate(0, 0, 7), loc)
+	if got[time.Monday][9] != 0.25 || got[time.Monday][10] != 0.5 || got[time.Monday][11] != 0 || got[time.Tuesday][9] != 0 {
+		t.Fatalf("BusyDensity() Monday = %v", got[time.Monday])
+	}
+
+	// Two weeks halve the share of an hour only busy in one of them.
+	got = BusyDensity(busy[:1], start, start.AddDate(0, 0, 14), loc)
+	if got[time.Monday][10] != 0.5 {
+		t.Fatalf("BusyDensity() over two weeks = %v", got[time.Monday][10])
+	}
+	if got := BusyDensity(nil, start, start.AddDate(0, 0, 7), loc); got != (Heatmap{}) {
+		t.Fatalf("BusyDensity(nil) = %v", got)
+	}
+}
+
+func TestExpiredWaitsForTheLastOccurrence(t *testing.T) {
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	wrap := func(body string) store.Event {
+		return store.Event{RawICAL: "BEGIN:VCALENDAR\r\n" + body + "END:VCALENDAR\r\n"}
+	}
+	for _, tc := range []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"ended", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20250601T080000Z\r\nDTEND:20250601T090000Z\r\nEND:VEVENT\r\n", true},
+		{"ends after cutoff", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20251231T230000Z\r\nDTEND:20260101T010000Z\r\nEND:VEVENT\r\n", false},
+		{"all day", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART;VALUE=DATE:20251230\r\nEND:VEVENT\r\n", true},
+		{"open-ended series", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20200101T080000Z\r\nDTEND:20200101T090000Z\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\n", false},
+		{"series until before cutoff", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20200101T080000Z\r\nDTEND:20200101T090000Z\r\nRRULE:FREQ=WEEKLY;UNTIL=20201231T000000Z\r\nEND:VEVENT\r\n", true},
+		{"series until after cutoff", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20200101T080000Z\r\nDTEND:20200101T090000Z\r\nRRULE:FREQ=WEEKLY;UNTIL=20260301T000000Z\r\nEND:VEVENT\r\n", false},
+		{"series count ends after cutoff", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20251201T080000Z\r\nDTEND:20251201T090000Z\r\nRRULE:FREQ=WEEKLY;COUNT=10\r\nEND:VEVENT\r\n", false},
+		{"occurrence moved past cutoff", "BEGIN:VEVENT\r\nUID:a\r\nDTSTART:20250101T080000Z\r\nDTEND:20250101T090000Z\r\nRRULE:FREQ=DAILY;COUNT=3\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:a\r\nRECURRENCE-ID:20250103T080000Z\r\nDTSTART:20260203T080000Z\r\nDTEND:20260203T090000Z\r\nEND:VEVENT\r\n", false},
+		{"task", "BEGIN:VTODO\r\nUID:a\r\nDUE:20200101T080000Z\r\nEND:VTODO\r\n", false},
+	} {
+		if got := Expired(wrap(tc.body), cutoff); got != tc.want {
+			t.Errorf("Expired(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetentionPolicyIsForOwnersOnly(t *testing.T) {
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Shifts"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Team"}, Shared: true, Editor: true},
+		}},
+		Retention: &fakeRetentionRepo{},
+	})
+	user := &store.User{ID: 1}
+	ctx := context.Background()
+
+	if _, err := svc.SetRetentionPolicy(ctx, user, 2, 6); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("SetRetentionPolicy() on a shared calendar error = %v, want ErrForbidden", err)
+	}
+	for _, months := range []int{0, MaxRetentionMonths + 1} {
+		if _, err := svc.SetRetentionPolicy(ctx, user, 1, months); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("SetRetentionPolicy(%d) error = %v, want ErrBadRequest", months, err)
+		}
+	}
+	policy, err := svc.SetRetentionPolicy(ctx, user, 1, 6)
+	if err != nil || policy.Months != 6 {
+		t.Fatalf("SetRetentionPolicy() = %+v, %v", policy, err)
+	}
+	if got, err := svc.RetentionPolicy(ctx, user, 1); err != nil || got == nil || got.Months != 6 {
+		t.Fatalf("RetentionPolicy() = %+v, %v", got, err)
+	}
+}
+
+type fakeRetentionRepo struct {
+	store.RetentionRepository
+	policies map[int64]store.RetentionPolicy
+}
+
+func (f *fakeRetentionRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.RetentionPolicy, error) {
+	if policy, ok := f.policies[calendarID]; ok {
+		return &policy, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeRetentionRepo) Upsert(ctx context.Context, policy store.RetentionPolicy) (*store.RetentionPolicy, error) {
+	if f.policies == nil {
+		f.policies = map[int64]store.RetentionPolicy{}
+	}
+	f.policies[policy.CalendarID] = policy
+	return &policy, nil
+}
+
+func TestCreateEventProvisionsConference(t *testing.T) {
+	var got conferenceHookRequest
+	var signature string
+	status := http.StatusOK
+	hookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if r.Header.Get(ConferenceSignatureHeader) != signature {
+			signature = ""
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"url":"https://meet.example.com/abc-defg"}`))
+	}))
+	defer hookServer.Close()
+
+	hooks := &fakeConferenceHookRepo{hooks: map[int64]store.ConferenceHook{}}
+	repo := &fakeEventRepo{events: map[string]store.Event{}}
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Work"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Team"}, Shared: true, Editor: true},
+		}},
+		Events:          repo,
+		ConferenceHooks: hooks,
+	})
+	user := &store.User{ID: 1}
+	ctx := context.Background()
+
+	if _, err := svc.SetConferenceHook(ctx, user, 2, hookServer.URL, ""); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("SetConferenceHook() on a shared calendar error = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.SetConferenceHook(ctx, user, 1, "ftp://meet.example.com", ""); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("SetConferenceHook() with a non-http url error = %v, want ErrBadRequest", err)
+	}
+	for _, target := range []string{hookServer.URL, "http://169.254.169.254/latest", "https://100.64.0.1/hook", "https://[fd00::1]/hook"} {
+		if _, err := svc.SetConferenceHook(ctx, user, 1, target, ""); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("SetConferenceHook(%q) error = %v, want ErrBadRequest", target, err)
+		}
+	}
+
+	// Without a hook the request is kept but nothing is provisioned.
+	ev, _, err := svc.CreateEvent(ctx, user, 1, UpsertInput{
+		Structured: &StructuredInput{UID: "no-hook", Summary: "Sync", DTStart: "2026-03-20T10:00", DTEnd: "2026-03-20T11:00", RequestConference: true},
+	})
+	if err != nil || strings.Contains(ev.RawICAL, "CONFERENCE") {
+		t.Fatalf("CreateEvent() without a hook = %v, %q", err, ev.RawICAL)
+	}
+
+	if _, err := svc.SetConferenceHook(ctx, user, 1, "https://meet.example.com/hook", "s3cret"); err != nil {
+		t.Fatalf("SetConferenceHook() error = %v", err)
+	}
+	// A hook saved before targets were checked is refused when it is
+	// dialled.
+	hooks.hooks[1] = store.ConferenceHook{CalendarID: 1, URL: hookServer.URL, Secret: "s3cret"}
+	_, _, err = svc.CreateEvent(ctx, user, 1, UpsertInput{
+		Structured: &StructuredInput{UID: "private", Summary: "Sync", DTStart: "2026-03-20T10:00", DTEnd: "2026-03-20T11:00", RequestConference: true},
+	})
+	if !errors.Is(err, ErrConferenceProvider) {
+		t.Fatalf("CreateEvent() with a loopback hook error = %v, want ErrConferenceProvider", err)
+	}
+	defer func(client *http.Client) { ConferenceClient = client }(ConferenceClient)
+	ConferenceClient = hookServer.Client()
+
+	ev, _, err = svc.CreateEvent(ctx, user, 1, UpsertInput{
+		Structured: &StructuredInput{UID: "structured", Summary: "Sync", DTStart: "2026-03-20T10:00", DTEnd: "2026-03-20T11:00", RequestConference: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if !strings.Contains(ev.RawICAL, "CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:https://meet.example.com/abc-defg\r\n") {
+		t.Fatalf("expected the provisioned link, got %q", ev.RawICAL)
+	}
+	if got.CalendarID != 1 || got.UID != "structured" || got.Summary != "Sync" || got.Start == "" || signature == "" {
+		t.Fatalf("unexpected hook request %+v (signed=%v)", got, signature != "")
+	}
+
+	// A raw payload asks with the property, which is removed; the link goes
+	// before the alarm in every component.
+	raw := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:raw",
+		"SUMMARY:Review",
+		"DTSTART:20260320T100000Z",
+		"DTEND:20260320T110000Z",
+		"RRULE:FREQ=DAILY;COUNT=2",
+		ConferenceRequestProperty + ":TRUE",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT5M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:raw",
+		"SUMMARY:Review",
+		"RECURRENCE-ID:20260321T100000Z",
+		"DTSTART:20260321T120000Z",
+		"DTEND:20260321T130000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	ev, _, err = svc.CreateEvent(ctx, user, 1, UpsertInput{RawICS: raw, ContentType: "text/calendar"})
+	if err != nil {
+		t.Fatalf("CreateEvent() raw error = %v", err)
+	}
+	if strings.Contains(ev.RawICAL, ConferenceRequestProperty) || strings.Count(ev.RawICAL, "CONFERENCE;") != 2 ||
+		!strings.Contains(ev.RawICAL, "abc-defg\r\nBEGIN:VALARM") {
+		t.Fatalf("unexpected provisioned payload %q", ev.RawICAL)
+	}
+
+	status = http.StatusInternalServerError
+	_, _, err = svc.CreateEvent(ctx, user, 1, UpsertInput{
+		Structured: &StructuredInput{UID: "failing", Summary: "Sync", DTStart: "2026-03-20T10:00", DTEnd: "2026-03-20T11:00", RequestConference: true},
+	})
+	if !errors.Is(err, ErrConferenceProvider) || StatusCode(err) != http.StatusBadGateway {
+		t.Fatalf("CreateEvent() with a failing hook error = %v, want ErrConferenceProvider", err)
+	}
+	if _, ok := repo.events["1:failing"]; ok {
+		t.Fatal("event saved although provisioning failed")
+	}
+
+	if err := svc.DeleteConferenceHook(ctx, user, 1); err != nil || len(hooks.hooks) != 0 {
+		t.Fatalf("DeleteConferenceHook() error = %v, hooks = %v", err, hooks.hooks)
+	}
+}
+
+func TestScheduleResourcesBooksAndDeclinesRooms(t *testing.T) {
+	repo := &fakeEventRepo{events: map[string]store.Event{}}
+	resources := &fakeResourceRepo{events: repo, resources: map[int64]store.SchedulingResource{}}
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1:  {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Alice"}, Editor: true},
+			2:  {Calendar: store.Calendar{ID: 2, UserID: 2, Name: "Bob"}, Editor: true},
+			10: {Calendar: store.Calendar{ID: 10, UserID: 3, Name: "Room A"}, Editor: true},
+		}},
+		Users: &fakeUserRepo{users: map[int64]store.User{
+			1: {ID: 1, PrimaryEmail: "alice@example.com"},
+			2: {ID: 2, PrimaryEmail: "bob@example.com"},
+			3: {ID: 3, PrimaryEmail: "facilities@example.com"},
+		}},
+		Events:    repo,
+		Resources: resources,
+	})
+	ctx := context.Background()
+	alice, bob, facilities := &store.User{ID: 1}, &store.User{ID: 2}, &store.User{ID: 3}
+
+	if _, err := svc.SetSchedulingResource(ctx, alice, 10, store.SchedulingResource{Email: "room-a@example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SetSchedulingResource() by a non-owner error = %v, want ErrNotFound", err)
+	}
+	if _, err := svc.SetSchedulingResource(ctx, facilities, 10, store.SchedulingResource{Email: "room-a@example.com", Policy: "lottery"}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("SetSchedulingResource() with an unknown policy error = %v, want ErrBadRequest", err)
+	}
+	res, err := svc.SetSchedulingResource(ctx, facilities, 10, store.SchedulingResource{Email: "Room-A@example.com"})
+	if err != nil || res.Email != "room-a@example.com" || res.Kind != "ROOM" || res.Policy != store.ResourcePolicyFirstCome {
+		t.Fatalf("SetSchedulingResource() = %+v, %v", res, err)
+	}
+
+	meeting := func(uid, organizer, start, end string, extra ...string) string {
+		lines := []string{
+			"BEGIN:VCALENDAR", "VERSION:2.0", "BEGIN:VEVENT",
+			"UID:" + uid, "SUMMARY:" + uid,
+			"DTSTART:" + start, "DTEND:" + end,
+			"ORGANIZER:mailto:" + organizer,
+			"ATTENDEE;CUTYPE=ROOM;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:room-a@example.com",
+		}
+		lines = append(lines, extra...)
+		return strings.Join(append(lines, "END:VEVENT", "END:VCALENDAR", ""), "\r\n")
+	}
+	create := func(user *store.User, calendarID int64, raw string) *store.Event {
+		t.Helper()
+		ev, _, err := svc.CreateEvent(ctx, user, calendarID, UpsertInput{RawICS: raw, ContentType: "text/calendar"})
+		if err != nil {
+			t.Fatalf("CreateEvent() error = %v", err)
+		}
+		return ev
+	}
+	partstat := func(ev *store.Event) string {
+		for _, line := range strings.Split(ev.RawICAL, "\r\n") {
+			if strings.HasPrefix(line, "ATTENDEE") {
+				_, params, _ := splitProperty(line)
+				for _, p := range strings.Split(params, ";") {
+					if v, ok := strings.CutPrefix(p, "PARTSTAT="); ok {
+						return v
+					}
+				}
+			}
+		}
+		return ""
+	}
+
+	a1 := create(alice, 1, meeting("a1", "alice@example.com", "20300501T100000Z", "20300501T110000Z"))
+	if partstat(a1) != "ACCEPTED" || !strings.Contains(a1.RawICAL, "RSVP=TRUE") {
+		t.Fatalf("expected the free room to accept, got %q", a1.RawICAL)
+	}
+	booking, ok := repo.events[key(10, "a1")]
+	if !ok || bookedFrom(booking.RawICAL) != 1 {
+		t.Fatalf("expected a booking from calendar 1 in the room calendar, got %+v", booking)
+	}
+
+	if b1 := create(bob, 2, meeting("b1", "bob@example.com", "20300501T103000Z", "20300501T113000Z")); partstat(b1) != "DECLINED" {
+		t.Fatalf("expected the overlapping request to be declined, got %q", b1.RawICAL)
+	}
+	if _, ok := repo.events[key(10, "b1")]; ok {
+		t.Fatal("declined request booked the room")
+	}
+	if b2 := create(bob, 2, meeting("b2", "bob@example.com", "20300501T110000Z", "20300501T120000Z")); partstat(b2) != "ACCEPTED" {
+		t.Fatalf("expected the adjacent request to be accepted, got %q", b2.RawICAL)
+	}
+
+	// A weekly series is declined when any occurrence is taken.
+	if series := create(alice, 1, meeting("series", "alice@example.com", "20300424T110000Z", "20300424T113000Z", "RRULE:FREQ=WEEKLY;COUNT=3")); partstat(series) != "DECLINED" {
+		t.Fatalf("expected the series to be declined, got %q", series.RawICAL)
+	}
+
+	// An attendee's copy does not book anything.
+	if copied := create(bob, 2, meeting("a9", "alice@example.com", "20300601T100000Z", "20300601T110000Z")); partstat(copied) != "NEEDS-ACTION" {
+		t.Fatalf("expected an attendee copy to be left alone, got %q", copied.RawICAL)
+	}
+
+	// Moving the meeting keeps its own booking from conflicting; dropping
+	// the room releases it.
+	raw := meeting("a1", "alice@example.com", "20300501T093000Z", "20300501T103000Z")
+	if moved, _, err := svc.UpdateEvent(ctx, alice, 1, "a1", UpsertInput{RawICS: raw, ContentType: "text/calendar"}); err != nil || partstat(moved) != "ACCEPTED" {
+		t.Fatalf("UpdateEvent() = %v, %v", moved, err)
+	}
+	raw = strings.Replace(raw, "ATTENDEE;CUTYPE=ROOM;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:room-a@example.com", "ATTENDEE:mailto:carol@example.com", 1)
+	if _, _, err := svc.UpdateEvent(ctx, alice, 1, "a1", UpsertInput{RawICS: raw, ContentType: "text/calendar"}); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+	if _, ok := repo.events[key(10, "a1")]; ok {
+		t.Fatal("expected the room to be released")
+	}
+
+	// Under the priority policy bob takes the room from alice, whose copy
+	// is updated.
+	if _, err := svc.SetSchedulingResource(ctx, facilities, 10, store.SchedulingResource{Email: "room-a@example.com", Policy: "priority", PriorityOrganizers: []string{"bob@example.com"}}); err != nil {
+		t.Fatalf("SetSchedulingResource() error = %v", err)
+	}
+	if a2 := create(alice, 1, meeting("a2", "alice@example.com", "20300502T140000Z", "20300502T150000Z")); partstat(a2) != "ACCEPTED" {
+		t.Fatalf("expected a2 to be accepted, got %q", a2.RawICAL)
+	}
+	if b3 := create(bob, 2, meeting("b3", "bob@example.com", "20300502T143000Z", "20300502T153000Z")); partstat(b3) != "ACCEPTED" {
+		t.Fatalf("expected the priority organizer to be accepted, got %q", b3.RawICAL)
+	}
+	if _, ok := repo.events[key(10, "a2")]; ok {
+		t.Fatal("expected the displaced booking to be released")
+	}
+	if a2 := repo.events[key(1, "a2")]; partstat(&a2) != "DECLINED" {
+		t.Fatalf("expected the displaced organizer's copy to be declined, got %q", a2.RawICAL)
+	}
+	if a3 := create(alice, 1, meeting("a3", "alice@example.com", "20300502T150000Z", "20300502T160000Z")); partstat(a3) != "DECLINED" {
+		t.Fatalf("expected a lower priority organizer to be declined, got %q", a3.RawICAL)
+	}
+
+	if err := svc.DeleteEvent(ctx, bob, 2, "b3", "", ""); err != nil {
+		t.Fatalf("DeleteEvent() error = %v", err)
+	}
+	if _, ok := repo.events[key(10, "b3")]; ok {
+		t.Fatal("expected deleting the event to release the room")
+	}
+}
+
+func TestMergeAndSplitCalendars(t *testing.T) {
+	at := func(days int) *time.Time {
+		t := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC).AddDate(0, 0, days)
+		return &t
+	}
+	event := func(calendarID int64, uid string, start *time.Time, props ...string) store.Event {
+		raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n"
+		for _, p := range props {
+			raw += p + "\r\n"
+		}
+		end := start.Add(time.Hour)
+		return store.Event{CalendarID: calendarID, UID: uid, ResourceName: uid, RawICAL: raw + "END:VEVENT\r\nEND:VCALENDAR\r\n", DTStart: start, DTEnd: &end}
+	}
+	repo := &fakeEventRepo{events: map[string]store.Event{
+		key(1, "a"):      event(1, "a", at(0)),
+		key(1, "b"):      event(1, "b", at(1)),
+		key(2, "b"):      event(2, "b", at(2)),
+		key(3, "gym"):    event(3, "gym", at(3), "CATEGORIES:Fitness"),
+		key(3, "yoga"):   event(3, "yoga", at(40), "CATEGORIES:Fitness"),
+		key(3, "dinner"): event(3, "dinner", at(4), "CATEGORIES:Social"),
+	}}
+	timezone := "Europe/Paris"
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Old"}, Editor: true},
+			2: {Calendar: store.Calendar{ID: 2, UserID: 1, Name: "Main"}, Editor: true},
+			3: {Calendar: store.Calendar{ID: 3, UserID: 1, Name: "Life", Timezone: &timezone}, Editor: true},
+			4: {Calendar: store.Calendar{ID: 4, UserID: 2, Name: "Theirs"}, Editor: true},
+		}},
+		Events: repo,
+	})
+	ctx := context.Background()
+	user := &store.User{ID: 1}
+
+	if _, err := svc.MergeCalendars(ctx, user, 1, 2); !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "b") {
+		t.Fatalf("MergeCalendars() with a shared UID error = %v, want ErrConflict naming b", err)
+	}
+	if _, err := svc.MergeCalendars(ctx, user, 1, 4); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("MergeCalendars() into another user's calendar error = %v, want ErrNotFound", err)
+	}
+	delete(repo.events, key(2, "b"))
+	moved, err := svc.MergeCalendars(ctx, user, 1, 2)
+	if err != nil || moved != 2 {
+		t.Fatalf("MergeCalendars() = %d, %v, want 2", moved, err)
+	}
+	if _, ok := repo.events[key(2, "a")]; !ok {
+		t.Fatal("expected event a merged into calendar 2")
+	}
+
+	if _, _, err := svc.SplitCalendar(ctx, user, 3, SplitInput{Name: "Fitness"}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("SplitCalendar() without a selection error = %v, want ErrBadRequest", err)
+	}
+	cal, moved, err := svc.SplitCalendar(ctx, user, 3, SplitInput{Name: "Fitness", Category: "fitness", To: at(30)})
+	if err != nil || moved != 1 {
+		t.Fatalf("SplitCalendar() = %d, %v, want 1", moved, err)
+	}
+	if cal.Name != "Fitness" || cal.UserID != 1 || cal.Timezone == nil || *cal.Timezone != timezone {
+		t.Fatalf("unexpected new calendar %+v", cal)
+	}
+	if _, ok := repo.events[key(cal.ID, "gym")]; !ok {
+		t.Fatal("expected gym split into the new calendar")
+	}
+	for _, uid := range []string{"yoga", "dinner"} {
+		if _, ok := repo.events[key(3, uid)]; !ok {
+			t.Fatalf("expected %s left in calendar 3", uid)
+		}
+	}
+}
+
+func TestDuplicateCalendarShiftsCopiesWithNewUIDs(t *testing.T) {
+	raw := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Paris\r\nBEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\nUID:plan\r\nDTSTAMP:20300101T000000Z\r\n" +
+		"DTSTART;TZID=Europe/Paris:20300131T090000\r\nDTEND;TZID=Europe/Paris:20300131T100000\r\n" +
+		"RRULE:FREQ=WEEKLY;UNTIL=20300601T000000Z\r\nEXDATE;VALUE=DATE:20300207,20300214\r\n" +
+		"BEGIN:VALARM\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	start := time.Date(2030, 1, 31, 8, 0, 0, 0, time.UTC)
+	repo := &fakeEventRepo{events: map[string]store.Event{
+		key(1, "plan"): {CalendarID: 1, UID: "plan", ResourceName: "plan", RawICAL: raw, DTStart: &start},
+	}}
+	color := "#112233"
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "2030", Color: &color}, Editor: true},
+		}},
+		Events: repo,
+	})
+	ctx := context.Background()
+	user := &store.User{ID: 1}
+
+	if _, _, err := svc.DuplicateCalendar(ctx, user, 1, DuplicateInput{Name: " "}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("DuplicateCalendar() without a name error = %v, want ErrBadRequest", err)
+	}
+	if _, _, err := svc.DuplicateCalendar(ctx, user, 9, DuplicateInput{Name: "Copy"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DuplicateCalendar() of a missing calendar error = %v, want ErrNotFound", err)
+	}
+	shift, err := ParseDateShift("P1Y")
+	if err != nil {
+		t.Fatalf("ParseDateShift() error = %v", err)
+	}
+	cal, copied, err := svc.DuplicateCalendar(ctx, user, 1, DuplicateInput{Name: "2031", Shift: shift})
+	if err != nil || copied != 1 {
+		t.Fatalf("DuplicateCalendar() = %d, %v, want 1", copied, err)
+	}
+	if cal.Name != "2031" || cal.Color == nil || *cal.Color != color {
+		t.Fatalf("unexpected new calendar %+v", cal)
+	}
+	var dup *store.Event
+	for _, ev := range repo.events {
+		if ev.CalendarID == cal.ID {
+			dup = &ev
+		}
+	}
+	if dup == nil || dup.UID == "plan" {
+		t.Fatalf("expected a copy with a new UID, got %+v", dup)
+	}
+	for _, want := range []string{
+		"UID:" + dup.UID,
+		"DTSTAMP:20300101T000000Z",
+		"DTSTART;TZID=Europe/Paris:20310131T090000",
+		"DTEND;TZID=Europe/Paris:20310131T100000",
+		"RRULE:FREQ=WEEKLY;UNTIL=20310601T000000Z",
+		"EXDATE;VALUE=DATE:20310207,20310214",
+		"TRIGGER:-PT15M",
+		"DTSTART:19701025T030000",
+	} {
+		if !strings.Contains(dup.RawICAL, want+"\r\n") {
+			t.Errorf("copy missing %q:\n%s", want, dup.RawICAL)
+		}
+	}
+	if _, ok := repo.events[key(1, "plan")]; !ok {
+		t.Fatal("expected the original event kept")
+	}
+}
+
+func TestParseDateShift(t *testing.T) {
+	for in, want := range map[string]DateShift{
+		"P1Y":     {Years: 1},
+		"p6m":     {Months: 6},
+		"P1Y2W3D": {Years: 1, Days: 17},
+		"-P7D":    {Days: -7},
+	} {
+		if got, err := ParseDateShift(in); err != nil || got != want {
+			t.Errorf("ParseDateShift(%q) = %+v, %v, want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "P", "PT1H", "1Y"} {
+		if _, err := ParseDateShift(in); !errors.Is(err, ErrBadRequest) {
+			t.Errorf("ParseDateShift(%q) error = %v, want ErrBadRequest", in, err)
+		}
+	}
+}
+
+func TestParseJournalDates(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	journal := func(props ...string) string {
+		return "BEGIN:VCALENDAR\r\nBEGIN:VJOURNAL\r\nUID:j1\r\n" + strings.Join(props, "\r\n") + "\r\nEND:VJOURNAL\r\nEND:VCALENDAR\r\n"
+	}
+	for _, tc := range []struct {
+		dtstart, want string
+	}{
+		// A DATE names the same day in every zone.
+		{"DTSTART;VALUE=DATE:20260320", "2026-03-20"},
+		{"DTSTART;TZID=Europe/Berlin:20260320T003000", "2026-03-20"},
+		{"DTSTART:20260320T003000", "2026-03-20"},
+		{"DTSTART:20260320T003000Z", "2026-03-19"},
+	} {
+		got, ok := parseJournal(journal(tc.dtstart, "DESCRIPTION:one", "DESCRIPTION:two\\, three"), newYork)
+		if !ok || got.Date != tc.want || got.Description != "one\n\ntwo, three" {
+			t.Fatalf("parseJournal(%s) = %+v, want date %s", tc.dtstart, got, tc.want)
+		}
+	}
+	if _, ok := parseJournal(journal("RECURRENCE-ID:20260320T003000Z"), nil); ok {
+		t.Fatal("expected an override without a master to be skipped")
+	}
+
+	filter := JournalFilter{From: "2026-03-20", To: "2026-03-21"}
+	for date, want := range map[string]bool{"2026-03-19": false, "2026-03-20": true, "2026-03-21": true, "2026-03-22": false, "": false} {
+		if got := filter.matches(Journal{Date: date}); got != want {
+			t.Fatalf("matches(%q) = %v, want %v", date, got, want)
+		}
+	}
+	if !(JournalFilter{}).matches(Journal{}) {
+		t.Fatal("expected an empty filter to keep undated entries")
+	}
+}
+
+func TestProcessSchedulingMessage(t *testing.T) {
+	stored := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:sync\r\nSUMMARY:Weekly sync\r\nDTSTART:20300107T100000Z\r\nRRULE:FREQ=WEEKLY\r\nSTATUS:CONFIRMED\r\nORGANIZER:mailto:me@example.com\r\nATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:ann@example.com\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	svc := NewService(&store.Store{Events: &fakeEventRepo{events: map[string]store.Event{key(1, "sync"): {CalendarID: 1, UID: "sync", RawICAL: stored}}}})
+	message := func(method string, props ...string) string {
+		return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:" + method + "\r\nBEGIN:VEVENT\r\n" + strings.Join(props, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	}
+
+	if Method(methodICS("x")) != "REQUEST" || Method(validICS("x")) != "" {
+		t.Fatal("Method did not read the calendar METHOD")
+	}
+	got, err := svc.ProcessSchedulingMessage(context.Background(), 1, methodICS("new"))
+	if err != nil || containsICalMethodProperty(got) || !strings.Contains(got, "UID:new") {
+		t.Fatalf("REQUEST = %q, %v", got, err)
+	}
+
+	got, err = svc.ProcessSchedulingMessage(context.Background(), 1, message("REPLY", "UID:sync", "ATTENDEE;PARTSTAT=ACCEPTED:mailto:ann@example.com"))
+	if err != nil || AttendeePartstat(got, "ann@example.com") != "ACCEPTED" || !strings.Contains(got, "SUMMARY:Weekly sync") {
+		t.Fatalf("REPLY = %q, %v", got, err)
+	}
+	if _, err := svc.ProcessSchedulingMessage(context.Background(), 1, message("REPLY", "UID:unknown", "ATTENDEE;PARTSTAT=ACCEPTED:mailto:ann@example.com")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("REPLY to unknown event error = %v, want ErrConflict", err)
+	}
+
+	got, err = svc.ProcessSchedulingMessage(context.Background(), 1, message("CANCEL", "UID:sync", "RECURRENCE-ID:20300114T100000Z", "DTSTART:20300114T100000Z", "STATUS:CANCELLED"))
+	if err != nil || strings.Count(got, "BEGIN:VEVENT") != 2 || strings.Count(got, "STATUS:CANCELLED") != 1 || !strings.Contains(got, "STATUS:CONFIRMED") {
+		t.Fatalf("CANCEL of one instance = %q, %v", got, err)
+	}
+	got, err = svc.ProcessSchedulingMessage(context.Background(), 1, message("CANCEL", "UID:sync"))
+	if err != nil || strings.Contains(got, "STATUS:CONFIRMED") || !strings.Contains(got, "STATUS:CANCELLED") || !strings.Contains(got, "RRULE:FREQ=WEEKLY") {
+		t.Fatalf("CANCEL of the event = %q, %v", got, err)
+	}
+
+	if _, err := svc.ProcessSchedulingMessage(context.Background(), 1, message("COUNTER", "UID:sync")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("COUNTER error = %v, want ErrConflict", err)
+	}
+}
+
 type fakeCalendarRepo struct {
 	calendars map[int64]*store.CalendarAccess
 }
@@ -630,6 +1378,17 @@ func (f *fakeCalendarRepo) ListAccessible(ctx context.Context, userID int64) ([]
 	}
 	return out, nil
 }
+func (f *fakeCalendarRepo) ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*store.PaginatedResult[store.CalendarAccess], error) {
+	all, err := f.ListAccessible(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	page := &store.PaginatedResult[store.CalendarAccess]{TotalCount: len(all), Limit: limit, Offset: offset}
+	if offset < len(all) {
+		page.Items = all[offset:min(offset+limit, len(all))]
+	}
+	return page, nil
+}
 func (f *fakeCalendarRepo) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
 	if cal, ok := f.calendars[calendarID]; ok && (cal.UserID == userID || cal.Shared) {
 		copy := *cal
@@ -638,7 +1397,15 @@ func (f *fakeCalendarRepo) GetAccessible(ctx context.Context, calendarID, userID
 	return nil, nil
 }
 func (f *fakeCalendarRepo) Create(ctx context.Context, cal store.Calendar) (*store.Calendar, error) {
-	return nil, nil
+	if f.calendars == nil {
+		return nil, nil
+	}
+	for id := range f.calendars {
+		cal.ID = max(cal.ID, id+1)
+	}
+	f.calendars[cal.ID] = &store.CalendarAccess{Calendar: cal, Editor: true}
+	created := cal
+	return &created, nil
 }
 func (f *fakeCalendarRepo) Update(ctx context.Context, userID, id int64, name string, description, timezone, color *string) error {
 	return nil
@@ -668,6 +1435,16 @@ func (f *fakeEventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid s
 	delete(f.events, key(calendarID, uid))
 	return nil
 }
+func (f *fakeEventRepo) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	deleted := 0
+	for _, uid := range uids {
+		if err := f.DeleteByUID(ctx, calendarID, uid); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
 func (f *fakeEventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
 	ev, ok := f.events[key(calendarID, uid)]
 	if !ok {
@@ -685,6 +1462,16 @@ func (f *fakeEventRepo) GetByResourceName(ctx context.Context, calendarID int64,
 	}
 	return nil, nil
 }
+
+func (f *fakeEventRepo) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, *store.Body, error) {
+	ev, err := f.GetByResourceName(ctx, calendarID, resourceName)
+	if err != nil || ev == nil {
+		return nil, nil, err
+	}
+	body := store.NewBody(ev.RawICAL)
+	ev.RawICAL = ""
+	return ev, body, nil
+}
 func (f *fakeEventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
 	var out []store.Event
 	for _, ev := range f.events {
@@ -718,6 +1505,24 @@ func (f *fakeEventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toCa
 func (f *fakeEventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
 	return nil, nil
 }
+func (f *fakeEventRepo) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	moved := 0
+	for k, ev := range f.events {
+		if ev.CalendarID != fromCalendarID || (uids != nil && !slices.Contains(uids, ev.UID)) {
+			continue
+		}
+		delete(f.events, k)
+		ev.CalendarID = toCalendarID
+		f.events[key(toCalendarID, ev.UID)] = ev
+		moved++
+	}
+	return moved, nil
+}
+func (f *fakeEventRepo) SplitToNewCalendar(ctx context.Context, cal store.Calendar, fromCalendarID int64, uids []string) (*store.Calendar, int, error) {
+	cal.ID = 100
+	moved, err := f.MoveAllToCalendar(ctx, fromCalendarID, cal.ID, uids)
+	return &cal, moved, err
+}
 
 type fakeACLRepo struct {
 	entries              []store.ACLEntry
@@ -770,3 +1575,194 @@ func (f *fakeACLRepo) Delete(ctx context.Context, resourcePath string) error {
 func key(calendarID int64, uid string) string {
 	return strconv.FormatInt(calendarID, 10) + ":" + uid
 }
+
+type fakeConferenceHookRepo struct {
+	hooks map[int64]store.ConferenceHook
+}
+
+func (f *fakeConferenceHookRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.ConferenceHook, error) {
+	if hook, ok := f.hooks[calendarID]; ok {
+		return &hook, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeConferenceHookRepo) Upsert(ctx context.Context, hook store.ConferenceHook) (*store.ConferenceHook, error) {
+	f.hooks[hook.CalendarID] = hook
+	return &hook, nil
+}
+
+func (f *fakeConferenceHookRepo) Delete(ctx context.Context, calendarID int64) error {
+	delete(f.hooks, calendarID)
+	return nil
+}
+
+type fakeUserRepo struct {
+	store.UserRepository
+	users map[int64]store.User
+}
+
+func (f *fakeUserRepo) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if user, ok := f.users[id]; ok {
+		return &user, nil
+	}
+	return nil, nil
+}
+
+// fakeResourceRepo keeps resource bookings in the events of a fakeEventRepo.
+type fakeResourceRepo struct {
+	events    *fakeEventRepo
+	resources map[int64]store.SchedulingResource
+}
+
+func (f *fakeResourceRepo) GetByCalendar(ctx context.Context, calendarID int64) (*store.SchedulingResource, error) {
+	if res, ok := f.resources[calendarID]; ok {
+		return &res, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeResourceRepo) ListByEmails(ctx context.Context, emails []string) ([]store.SchedulingResource, error) {
+	var out []store.SchedulingResource
+	for _, res := range f.resources {
+		for _, email := range emails {
+			if strings.EqualFold(res.Email, email) {
+				out = append(out, res)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeResourceRepo) Upsert(ctx context.Context, res store.SchedulingResource) (*store.SchedulingResource, error) {
+	f.resources[res.CalendarID] = res
+	return &res, nil
+}
+
+func (f *fakeResourceRepo) Delete(ctx context.Context, calendarID int64) error {
+	delete(f.resources, calendarID)
+	return nil
+}
+
+func (f *fakeResourceRepo) Reserve(ctx context.Context, calendarID int64, decide func([]store.Event) (store.ResourceReservation, error)) error {
+	bookings, _ := f.events.ListForCalendar(ctx, calendarID)
+	reservation, err := decide(bookings)
+	if err != nil {
+		return err
+	}
+	for _, uid := range reservation.Release {
+		delete(f.events.events, key(calendarID, uid))
+	}
+	if reservation.Book != nil {
+		book := *reservation.Book
+		book.CalendarID = calendarID
+		_, _ = f.events.Upsert(ctx, book)
+	}
+	return nil
+}
+
+type fakeEventRevisionRepo struct {
+	store.EventRevisionRepository
+	since     time.Time
+	revisions []store.EventRevision
+}
+
+func (f *fakeEventRevisionRepo) HistorySince(ctx context.Context) (time.Time, error) {
+	return f.since, nil
+}
+
+func (f *fakeEventRevisionRepo) ListAsOf(ctx context.Context, calendarID int64, at time.Time) ([]store.EventRevision, error) {
+	var result []store.EventRevision
+	for _, rev := range f.revisions {
+		if rev.CalendarID == calendarID && !rev.ValidFrom.After(at) && (rev.ValidTo == nil || rev.ValidTo.After(at)) {
+			result = append(result, rev)
+		}
+	}
+	return result, nil
+}
+
+func TestCalendarAsOfReturnsReadableRevisionsWithinHistory(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-30 * 24 * time.Hour)
+	moved := now.Add(-24 * time.Hour)
+	before, after := "Planning", "Planning (moved)"
+	svc := NewService(&store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.CalendarAccess{
+			1: {Calendar: store.Calendar{ID: 1, UserID: 1, Name: "Shared"}, Shared: true},
+		}},
+		ACLEntries: &fakeACLRepo{entries: []store.ACLEntry{
+			{ResourcePath: "/dav/calendars/1", PrincipalHref: "/dav/principals/2/", IsGrant: true, Privilege: "read"},
+			{ResourcePath: "/dav/calendars/1/hidden", PrincipalHref: "/dav/principals/2/", IsGrant: false, Privilege: "read"},
+		}},
+		EventRevisions: &fakeEventRevisionRepo{since: since, revisions: []store.EventRevision{
+			{CalendarID: 1, UID: "planning", ResourceName: "planning", ETag: "v1", Summary: &before, ValidFrom: since, ValidTo: &moved},
+			{CalendarID: 1, UID: "planning", ResourceName: "planning", ETag: "v2", Summary: &after, ValidFrom: moved},
+			{CalendarID: 1, UID: "hidden", ResourceName: "hidden", ETag: "h1", ValidFrom: since},
+		}},
+	})
+	user := &store.User{ID: 2}
+	ctx := context.Background()
+
+	got, err := svc.CalendarAsOf(ctx, user, 1, now.Add(-48*time.Hour))
+	if err != nil || len(got) != 1 || got[0].ETag != "v1" {
+		t.Fatalf("CalendarAsOf() before the move = %+v, %v, want only planning v1", got, err)
+	}
+	if got, err := svc.CalendarAsOf(ctx, user, 1, now.Add(-time.Hour)); err != nil || len(got) != 1 || got[0].ETag != "v2" {
+		t.Fatalf("CalendarAsOf() after the move = %+v, %v, want planning v2", got, err)
+	}
+	for _, at := range []time.Time{since.Add(-time.Hour), now.Add(time.Hour)} {
+		if _, err := svc.CalendarAsOf(ctx, user, 1, at); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("CalendarAsOf(%s) error = %v, want ErrBadRequest", at, err)
+		}
+	}
+	if _, err := svc.CalendarAsOf(ctx, user, 9, now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("CalendarAsOf() on an unknown calendar error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHistorySinceIsBoundedByHistoryRetention(t *testing.T) {
+	now := time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+	svc := NewService(&store.Store{EventRevisions: &fakeEventRevisionRepo{since: now.AddDate(-3, 0, 0)}})
+	if got, err := svc.HistorySince(context.Background(), now); err != nil || !got.Equal(now.Add(-store.EventHistoryRetention)) {
+		t.Fatalf("HistorySince() = %s, %v, want the retention horizon", got, err)
+	}
+}
+
+func TestTravelConflictsFlagsUnreachableEvents(t *testing.T) {
+	raw := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\nGEO:52.5219;13.4132\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if g := eventGeo(raw); g == nil || g.Latitude != 52.5219 || g.Longitude != 13.4132 {
+		t.Fatalf("eventGeo() = %+v", g)
+	}
+	for _, bad := range []string{"GEO:91;13", "GEO:52.5", "GEO:north;east"} {
+		if g := eventGeo(strings.Replace(raw, "GEO:52.5219;13.4132", bad, 1)); g != nil {
+			t.Errorf("eventGeo(%q) = %+v, want nil", bad, g)
+		}
+	}
+
+	berlin := &Geo{Latitude: 52.5219, Longitude: 13.4132}
+	nextDoor := &Geo{Latitude: 52.5225, Longitude: 13.4140}
+	potsdam := &Geo{Latitude: 52.3906, Longitude: 13.0645}
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	items := []AgendaItem{
+		{UID: "holiday", Start: day, End: day.AddDate(0, 0, 1), AllDay: true, Geo: potsdam},
+		{UID: "standup", Start: at(9, 0), End: at(9, 30), Geo: berlin},
+		{UID: "review", Start: at(9, 30), End: at(10, 0), Geo: nextDoor},
+		{UID: "call", Start: at(10, 0), End: at(10, 30)},
+		{UID: "visit", Start: at(10, 30), End: at(11, 30), Geo: potsdam},
+		{UID: "lunch", Start: at(13, 0), End: at(14, 0), Geo: berlin},
+	}
+	got := TravelConflicts(items, 30)
+	if len(got) != 1 || got[0].From != 2 || got[0].To != 4 {
+		t.Fatalf("TravelConflicts() = %+v, want review -> visit only", got)
+	}
+	if c := got[0]; c.Gap != 30*time.Minute || c.DistanceKm < 26 || c.DistanceKm > 28 || c.Travel < 50*time.Minute {
+		t.Fatalf("conflict = %+v", c)
+	}
+	if got := TravelConflicts(items, 120); len(got) != 0 {
+		t.Fatalf("TravelConflicts() at 120 km/h = %+v, want none", got)
+	}
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// ShiftInput selects events of one calendar and how far to move them.
+type ShiftInput struct {
+	// UIDs selects the events to move; empty selects every event the user
+	// can read.
+	UIDs []string
+	// Shift moves every date of the selected events. StartDate is the
+	// alternative: the earliest selected event moves to start on that day
+	// and the others keep their distance from it.
+	Shift     DateShift
+	StartDate *time.Time
+	// CopyTo, when set, leaves the selected events alone and creates
+	// shifted copies with new UIDs in that calendar, which may be the same
+	// one.
+	CopyTo int64
+}
+
+// ShiftedEvent is an event moved by ShiftEvents. NewUID is set for copies.
+type ShiftedEvent struct {
+	UID    string
+	NewUID string
+}
+
+// ShiftEvents moves a set of events by a date offset, for rolling a season
+// or semester forward. Start and end times, recurrence IDs, exceptions,
+// recurrence rule UNTILs and absolute alarm times move together; relative
+// alarms keep their offsets. Every selected event is checked before any is
+// written, so a forbidden or missing one changes nothing.
+func (s *Service) ShiftEvents(ctx context.Context, user *store.User, calendarID int64, input ShiftInput) ([]ShiftedEvent, error) {
+	switch {
+	case input.StartDate != nil && !input.Shift.IsZero():
+		return nil, errors.Join(ErrBadRequest, errors.New("shift cannot be combined with startDate"))
+	case input.StartDate == nil && input.Shift.IsZero() && input.CopyTo == 0:
+		return nil, errors.Join(ErrBadRequest, errors.New("shift or startDate is required"))
+	}
+	cal, err := s.GetCalendar(ctx, user, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	var selected []store.Event
+	if len(input.UIDs) == 0 {
+		if selected, err = s.ListEvents(ctx, user, calendarID, store.EventFilter{}); err != nil {
+			return nil, err
+		}
+	} else {
+		uids := dedupe(input.UIDs)
+		if selected, err = s.store.Events.ListByUIDs(ctx, calendarID, uids); err != nil {
+			return nil, err
+		}
+		if len(selected) != len(uids) {
+			return nil, fmt.Errorf("%w: %d of the selected events do not exist", ErrNotFound, len(uids)-len(selected))
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	shift := input.Shift
+	if input.StartDate != nil {
+		shift = shiftToStartDate(selected, *input.StartDate)
+	}
+
+	target := cal
+	privilege := "write-content"
+	if input.CopyTo != 0 {
+		if target, err = s.GetCalendar(ctx, user, input.CopyTo); err != nil {
+			return nil, err
+		}
+		privilege = "read"
+	}
+	for _, ev := range selected {
+		if err := s.requireCalendarPrivilege(ctx, user, cal, eventResourceName(ev), privilege); err != nil {
+			return nil, err
+		}
+	}
+	if input.CopyTo != 0 {
+		if err := s.requireCalendarPrivilege(ctx, user, target, "", "bind"); err != nil {
+			return nil, err
+		}
+	}
+
+	shifted := make([]ShiftedEvent, 0, len(selected))
+	for _, ev := range selected {
+		uid, resourceName, previous := ev.UID, eventResourceName(ev), ev.RawICAL
+		moved := ShiftedEvent{UID: ev.UID}
+		if input.CopyTo != 0 {
+			uid = utils.GenerateUID()
+			resourceName, previous, moved.NewUID = uid, "", uid
+		}
+		body := shiftEventBody(ev.RawICAL, ev.UID, uid, shift)
+		if body, _, err = s.ScheduleResources(ctx, target.ID, body, previous); err != nil {
+			return shifted, err
+		}
+		if _, _, err := s.saveEvent(ctx, target.ID, uid, resourceName, body, "", ""); err != nil {
+			return shifted, err
+		}
+		shifted = append(shifted, moved)
+	}
+	return shifted, nil
+}
+
+// shiftToStartDate returns the whole-day shift that moves the earliest of
+// evs to start on the day of start.
+func shiftToStartDate(evs []store.Event, start time.Time) DateShift {
+	var earliest time.Time
+	for _, ev := range evs {
+		if ev.DTStart != nil && (earliest.IsZero() || ev.DTStart.Before(earliest)) {
+			earliest = *ev.DTStart
+		}
+	}
+	if earliest.IsZero() {
+		return DateShift{}
+	}
+	from := time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	return DateShift{Days: int(to.Sub(from).Hours() / 24)}
+}
@@ -0,0 +1,238 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// Task is an open VTODO from one of the owner's calendars.
+type Task struct {
+	CalendarID   int64
+	CalendarName string
+	UID          string
+	Summary      string
+	Description  string
+	Status       string
+	// Priority is 1 (highest) to 9 (lowest), or 0 when unset.
+	Priority   int
+	Due        *time.Time
+	DueAllDay  bool
+	Categories []string
+
+	// components are the task's VTODOs and the VTIMEZONEs they use, as
+	// stored, for iCalendar output.
+	components []string
+	timezones  []string
+}
+
+// TaskFilter narrows OpenTasks. Zero-value fields are ignored; tasks without
+// a due date are left out when either due bound is set.
+type TaskFilter struct {
+	DueAfter  *time.Time // include tasks due at or after DueAfter
+	DueBefore *time.Time // include tasks due before DueBefore
+	Category  string     // case-insensitive match on any category
+}
+
+// OpenTasks returns the VTODOs on the owner's own calendars that are neither
+// completed nor cancelled, ordered by due date with undated tasks last.
+func (s *Service) OpenTasks(ctx context.Context, owner *store.User, filter TaskFilter) ([]Task, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for _, cal := range cals {
+		events, err := s.store.Events.ListForCalendar(ctx, cal.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			task, ok := parseTask(ev.RawICAL)
+			if !ok || !filter.matches(task) {
+				continue
+			}
+			task.CalendarID, task.CalendarName = cal.ID, cal.Name
+			if task.UID == "" {
+				task.UID = ev.UID
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		switch {
+		case a.Due != nil && b.Due != nil && !a.Due.Equal(*b.Due):
+			return a.Due.Before(*b.Due)
+		case (a.Due == nil) != (b.Due == nil):
+			return a.Due != nil
+		case a.Priority != b.Priority:
+			// Unset priority sorts after every set one.
+			return a.Priority != 0 && (b.Priority == 0 || a.Priority < b.Priority)
+		}
+		return strings.ToLower(a.Summary) < strings.ToLower(b.Summary)
+	})
+	return tasks, nil
+}
+
+func (f TaskFilter) matches(t Task) bool {
+	if f.DueAfter != nil || f.DueBefore != nil {
+		if t.Due == nil {
+			return false
+		}
+		if f.DueAfter != nil && t.Due.Before(*f.DueAfter) {
+			return false
+		}
+		if f.DueBefore != nil && !t.Due.Before(*f.DueBefore) {
+			return false
+		}
+	}
+	if f.Category == "" {
+		return true
+	}
+	for _, c := range t.Categories {
+		if strings.EqualFold(c, f.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTask reads the master VTODO of a stored object, reporting false when
+// there is none or it is no longer open.
+func parseTask(raw string) (Task, bool) {
+	components := utils.ICalComponents(raw, "VTODO")
+	var task Task
+	found := false
+	for _, comp := range components {
+		t, master, open := parseTaskComponent(comp)
+		if !master {
+			continue
+		}
+		if !open {
+			return Task{}, false
+		}
+		task, found = t, true
+		break
+	}
+	if !found {
+		return Task{}, false
+	}
+	task.components = components
+	task.timezones = utils.ICalComponents(raw, "VTIMEZONE")
+	return task, true
+}
+
+// parseTaskComponent reads the top-level properties of one VTODO, reporting
+// whether it is the master (no RECURRENCE-ID) and whether it is open.
+func parseTaskComponent(raw string) (Task, bool, bool) {
+	var task Task
+	master, open := true, true
+	depth := 0
+	for _, line := range utils.UnfoldLines(raw) {
+		name, params, value, ok := parseInstanceProperty(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		switch name {
+		case "BEGIN":
+			depth++
+			continue
+		case "END":
+			depth--
+			continue
+		}
+		if depth != 1 {
+			continue
+		}
+		switch name {
+		case "UID":
+			task.UID = strings.TrimSpace(value)
+		case "SUMMARY":
+			task.Summary = unescapeTaskText(value)
+		case "DESCRIPTION":
+			task.Description = unescapeTaskText(value)
+		case "STATUS":
+			task.Status = strings.ToUpper(strings.TrimSpace(value))
+			if task.Status == "COMPLETED" || task.Status == "CANCELLED" {
+				open = false
+			}
+		case "COMPLETED":
+			open = false
+		case "PERCENT-COMPLETE":
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 100 {
+				open = false
+			}
+		case "PRIORITY":
+			if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n >= 0 && n <= 9 {
+				task.Priority = n
+			}
+		case "DUE":
+			if t, allDay, err := parseInstanceTime(value, params, nil); err == nil {
+				task.Due, task.DueAllDay = &t, allDay
+			}
+		case "CATEGORIES":
+			for _, c := range splitTaskList(value) {
+				if c != "" {
+					task.Categories = append(task.Categories, c)
+				}
+			}
+		case "RECURRENCE-ID":
+			master = false
+		}
+	}
+	return task, master, open
+}
+
+func unescapeTaskText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// splitTaskList splits a list value on unescaped commas and unescapes each
+// item.
+func splitTaskList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, unescapeTaskText(strings.TrimSpace(value[start:i])))
+			start = i + 1
+		}
+	}
+	return append(items, unescapeTaskText(strings.TrimSpace(value[start:])))
+}
+
+// TasksCalendar renders tasks as a published VCALENDAR holding their VTODOs
+// as stored, with each time zone they use included once.
+func TasksCalendar(tasks []Task) string {
+	var sb strings.Builder
+	sb.WriteString("BEGIN:VCALENDAR\r\n")
+	sb.WriteString("VERSION:2.0\r\n")
+	sb.WriteString("PRODID:-//CalCard//CalDAV Server//EN\r\n")
+	sb.WriteString("METHOD:PUBLISH\r\n")
+	sb.WriteString("X-WR-CALNAME:Tasks\r\n")
+	seen := make(map[string]bool)
+	for _, t := range tasks {
+		for _, tz := range t.timezones {
+			if !seen[tz] {
+				seen[tz] = true
+				sb.WriteString(tz)
+			}
+		}
+	}
+	for _, t := range tasks {
+		for _, comp := range t.components {
+			sb.WriteString(comp)
+		}
+	}
+	sb.WriteString("END:VCALENDAR\r\n")
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const (
+	// earthRadiusKm is the mean radius of the earth.
+	earthRadiusKm = 6371.0
+	// samePlaceKm is how close two events' coordinates must be to count as
+	// the same place, so that rooms in one building need no travel.
+	samePlaceKm = 0.2
+)
+
+// Geo is a point on the earth, from an event's GEO property.
+type Geo struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// DistanceKm returns the great-circle distance to other, in kilometres.
+func (g Geo) DistanceKm(other Geo) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := rad(other.Latitude - g.Latitude)
+	dLon := rad(other.Longitude - g.Longitude)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(g.Latitude))*math.Cos(rad(other.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// eventGeo returns the GEO property of the first VEVENT in raw, or nil
+// when it has none or it is malformed.
+func eventGeo(raw string) *Geo {
+	inEvent := false
+	for _, line := range utils.UnfoldLines(raw) {
+		name, value := splitContentLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent = true
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			return nil
+		case inEvent && name == "GEO":
+			lat, lon, ok := strings.Cut(value, ";")
+			if !ok {
+				return nil
+			}
+			g := Geo{}
+			var err error
+			if g.Latitude, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil || g.Latitude < -90 || g.Latitude > 90 {
+				return nil
+			}
+			if g.Longitude, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil || g.Longitude < -180 || g.Longitude > 180 {
+				return nil
+			}
+			return &g
+		}
+	}
+	return nil
+}
+
+// TravelConflict flags an agenda item that cannot be reached in time from
+// the one before it: at the given speed, the straight line between their
+// places takes longer than the time between them.
+type TravelConflict struct {
+	// From and To index the two items in the agenda.
+	From, To   int
+	DistanceKm float64
+	Travel     time.Duration
+	// Gap is the time from the end of From to the start of To; it is zero
+	// when they overlap.
+	Gap time.Duration
+}
+
+// TravelConflicts checks each timed item with coordinates against the one
+// before it that has coordinates, in the order of items, assuming travel
+// in a straight line at speedKmh. All-day items and items at the same
+// place are never in conflict.
+func TravelConflicts(items []AgendaItem, speedKmh float64) []TravelConflict {
+	if speedKmh <= 0 {
+		return nil
+	}
+	var conflicts []TravelConflict
+	prev := -1
+	for i, item := range items {
+		if item.AllDay || item.Geo == nil {
+			continue
+		}
+		if prev >= 0 {
+			from := items[prev]
+			distance := from.Geo.DistanceKm(*item.Geo)
+			gap := max(item.Start.Sub(from.End), 0)
+			travel := time.Duration(distance / speedKmh * float64(time.Hour))
+			if distance >= samePlaceKm && travel > gap {
+				conflicts = append(conflicts, TravelConflict{From: prev, To: i, DistanceKm: distance, Travel: travel, Gap: gap})
+			}
+		}
+		prev = i
+	}
+	return conflicts
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// defaultUIDHost names the server in issued UIDs when no base URL is set.
+const defaultUIDHost = "calcard"
+
+// serverUIDPattern matches the random UUID part of a UID NewUID issued.
+var serverUIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}@(.+)$`)
+
+// UIDHost returns the host issued UIDs are qualified with: that of
+// baseURL, or defaultUIDHost when it has none.
+func UIDHost(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return defaultUIDHost
+}
+
+// NewUID returns a UID in the form uuid@host, with a random (version 4)
+// UUID, as RFC 7986 §5.3 recommends.
+func NewUID(host string) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x@%s", b[0:4], b[4:6], b[6:8], b[8:10], b[10:], host)
+}
+
+// IsServerUID reports whether uid has the form NewUID issues for host.
+func IsServerUID(uid, host string) bool {
+	m := serverUIDPattern.FindStringSubmatch(uid)
+	return m != nil && strings.EqualFold(m[1], host)
+}
+
+// RequireServerUIDs makes CreateEvent accept only UIDs NewUID issued for
+// host, and issue one itself for structured events without a UID. Clients
+// that reuse UIDs across calendars then cannot create colliding events
+// through this Service; CalDAV, which must store the UIDs clients choose,
+// uses its own.
+func (s *Service) RequireServerUIDs(host string) {
+	s.uidHost = host
+}
+
+// checkServerUID enforces RequireServerUIDs on a new event's UID.
+func (s *Service) checkServerUID(uid string) error {
+	if s.uidHost == "" || IsServerUID(uid, s.uidHost) {
+		return nil
+	}
+	return fmt.Errorf("%w: uid must be issued by the server, in the form uuid@%s; get one from POST /api/uids", ErrBadRequest, s.uidHost)
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestNewUIDIsARandomUUIDAtHost(t *testing.T) {
+	host := UIDHost("https://Cal.Example.com:8443/base")
+	if host != "cal.example.com" {
+		t.Fatalf("UIDHost() = %q", host)
+	}
+	if got := UIDHost(""); got != defaultUIDHost {
+		t.Fatalf("UIDHost(\"\") = %q, want %q", got, defaultUIDHost)
+	}
+	a, b := NewUID(host), NewUID(host)
+	if a == b || !IsServerUID(a, host) || !IsServerUID(a, "CAL.example.com") {
+		t.Fatalf("NewUID() = %q, %q", a, b)
+	}
+	for _, uid := range []string{"standup@cal.example.com", strings.TrimSuffix(a, host) + "other.example.com", "1712-abcd@calcard"} {
+		if IsServerUID(uid, host) {
+			t.Fatalf("IsServerUID(%q) = true", uid)
+		}
+	}
+}
+
+func TestRequireServerUIDsAppliesToCreateEvent(t *testing.T) {
+	user := &store.User{ID: 1}
+	repo := &fakeEventRepo{events: map[string]store.Event{}}
+	svc := newServiceWithRepos(true, repo)
+	svc.RequireServerUIDs("cal.example.com")
+	ctx := context.Background()
+
+	if _, _, err := svc.CreateEvent(ctx, user, 1, UpsertInput{RawICS: validICS("reused-uid"), ContentType: "text/calendar"}); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("CreateEvent() with a client UID error = %v, want ErrBadRequest", err)
+	}
+	issued := NewUID("cal.example.com")
+	if ev, created, err := svc.CreateEvent(ctx, user, 1, UpsertInput{RawICS: validICS(issued), ContentType: "text/calendar"}); err != nil || !created || ev.UID != issued {
+		t.Fatalf("CreateEvent() with an issued UID = %+v, %v, %v", ev, created, err)
+	}
+	input := &StructuredInput{Summary: "Planning", DTStart: "2026-03-20T10:00:00Z", DTEnd: "2026-03-20T11:00:00Z"}
+	ev, _, err := svc.CreateEvent(ctx, user, 1, UpsertInput{Structured: input})
+	if err != nil || !IsServerUID(ev.UID, "cal.example.com") || input.UID != "" {
+		t.Fatalf("CreateEvent() without a UID = %+v, %v; input UID %q", ev, err, input.UID)
+	}
+	// Updates keep the UID the event has.
+	if _, _, err := svc.UpdateEvent(ctx, user, 1, issued, UpsertInput{RawICS: validICS(issued), ContentType: "text/calendar"}); err != nil {
+		t.Fatalf("UpdateEvent() error = %v", err)
+	}
+}
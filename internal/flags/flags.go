@@ -0,0 +1,101 @@
+// Package flags resolves feature flags. Each flag gates a newer behaviour:
+// it has an instance-wide setting in the configuration, which admins can
+// override for a user or a group, so that the behaviour can be tried on
+// one test account before the whole instance.
+package flags
+
+import (
+	"context"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Flag describes a feature flag.
+type Flag struct {
+	Name        string
+	Description string
+}
+
+// All lists the feature flags in the order they are reported.
+var All = []Flag{
+	{Name: config.FeaturePush, Description: "Offer WebDAV-Push on calendars and address books, and push changes to subscribed clients"},
+	{Name: config.FeatureCalDAVRepair, Description: "Repair bare line feeds and a missing PRODID in calendar data CalDAV clients upload"},
+	{Name: config.FeatureFocusTime, Description: "Block out focus time for users who opt in"},
+}
+
+// Known reports whether name is a feature flag.
+func Known(name string) bool {
+	for _, f := range All {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether a flag is on for user. An override for the user
+// wins over those for their groups, and among groups one turning the flag
+// on wins. Without overrides the instance-wide setting applies, as it does
+// when the overrides cannot be loaded.
+func Enabled(ctx context.Context, cfg *config.Config, st *store.Store, user *store.User, name string) bool {
+	overrides, err := overridesFor(ctx, st, user)
+	if err != nil {
+		return instance(cfg, name)
+	}
+	return resolve(cfg, overrides, name)
+}
+
+// ForUser returns the setting of every flag for user.
+func ForUser(ctx context.Context, cfg *config.Config, st *store.Store, user *store.User) (map[string]bool, error) {
+	overrides, err := overridesFor(ctx, st, user)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(All))
+	for _, f := range All {
+		set[f.Name] = resolve(cfg, overrides, f.Name)
+	}
+	return set, nil
+}
+
+// Possible reports whether a flag is on for anyone: instance-wide, or
+// through an override. Background jobs check it before doing per-user work.
+func Possible(ctx context.Context, cfg *config.Config, st *store.Store, name string) (bool, error) {
+	if instance(cfg, name) || st == nil || st.FeatureFlags == nil {
+		return instance(cfg, name), nil
+	}
+	return st.FeatureFlags.AnyEnabled(ctx, name)
+}
+
+func instance(cfg *config.Config, name string) bool {
+	return cfg != nil && cfg.FeatureEnabled(name)
+}
+
+func overridesFor(ctx context.Context, st *store.Store, user *store.User) ([]store.FeatureFlagOverride, error) {
+	if st == nil || st.FeatureFlags == nil || user == nil {
+		return nil, nil
+	}
+	return st.FeatureFlags.ListForUser(ctx, user.ID, user.GroupIDs)
+}
+
+// resolve applies the overrides of one user and their groups to the
+// instance-wide setting of a flag.
+func resolve(cfg *config.Config, overrides []store.FeatureFlagOverride, name string) bool {
+	var groupOn, groupOff bool
+	for _, o := range overrides {
+		switch {
+		case o.Flag != name:
+		case o.UserID != nil:
+			return o.Enabled
+		case o.Enabled:
+			groupOn = true
+		default:
+			groupOff = true
+		}
+	}
+	if groupOn || groupOff {
+		return groupOn
+	}
+	return instance(cfg, name)
+}
@@ -0,0 +1,81 @@
+package flags
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestEnabledPrefersUserThenGroupsThenInstance(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{}
+	repo := &storetest.FeatureFlags{}
+	st := &store.Store{FeatureFlags: repo}
+	set := func(userID, groupID int64, enabled bool) {
+		t.Helper()
+		o := store.FeatureFlagOverride{Flag: config.FeaturePush, Enabled: enabled}
+		if userID != 0 {
+			o.UserID = &userID
+		} else {
+			o.GroupID = &groupID
+		}
+		if _, err := repo.Set(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tester := &store.User{ID: 1, GroupIDs: []int64{10, 11}}
+	other := &store.User{ID: 2, GroupIDs: []int64{11}}
+
+	if Enabled(ctx, cfg, st, tester, config.FeaturePush) {
+		t.Fatal("push is off instance-wide but on for the tester")
+	}
+	if possible, err := Possible(ctx, cfg, st, config.FeaturePush); err != nil || possible {
+		t.Fatalf("Possible() = %v, %v before any override", possible, err)
+	}
+
+	set(0, 10, true)
+	set(0, 11, false)
+	if !Enabled(ctx, cfg, st, tester, config.FeaturePush) {
+		t.Fatal("a group turning push on should win over one turning it off")
+	}
+	if Enabled(ctx, cfg, st, other, config.FeaturePush) {
+		t.Fatal("push is on for a user outside group 10")
+	}
+	if possible, err := Possible(ctx, cfg, st, config.FeaturePush); err != nil || !possible {
+		t.Fatalf("Possible() = %v, %v with a group override", possible, err)
+	}
+
+	set(1, 0, false)
+	if Enabled(ctx, cfg, st, tester, config.FeaturePush) {
+		t.Fatal("the user's own override should win over their groups'")
+	}
+
+	cfg.Features = map[string]bool{config.FeaturePush: true}
+	got, err := ForUser(ctx, cfg, st, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{config.FeaturePush: false, config.FeatureCalDAVRepair: false, config.FeatureFocusTime: true}
+	for name, on := range want {
+		if got[name] != on {
+			t.Fatalf("ForUser() = %v, want %v", got, want)
+		}
+	}
+	if !Enabled(ctx, cfg, st, &store.User{ID: 3}, config.FeaturePush) {
+		t.Fatal("a user without overrides should get the instance-wide setting")
+	}
+}
+
+func TestAllCoversConfiguredFlags(t *testing.T) {
+	if len(All) != len(config.FeatureFlags) {
+		t.Fatalf("All has %d flags, config knows %d", len(All), len(config.FeatureFlags))
+	}
+	for _, name := range config.FeatureFlags {
+		if !Known(name) {
+			t.Fatalf("flag %q has no description", name)
+		}
+	}
+}
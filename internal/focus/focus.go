@@ -0,0 +1,253 @@
+// Package focus blocks out focus time: for users who opt in, the free
+// stretches of their coming working week within their protected hours are
+// booked as tentative events on their default calendar, which they can
+// remove again in one go.
+package focus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/flags"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ErrInvalidSettings reports focus time settings that cannot be saved.
+var ErrInvalidSettings = errors.New("invalid focus settings")
+
+const (
+	// checkInterval is how often users' coming days are planned.
+	checkInterval = time.Hour
+	// horizonDays is how far ahead, from today, focus time is planned.
+	horizonDays = 7
+	// MinBlockMinutes and MaxBlockMinutes bound the shortest stretch of
+	// free time a user can ask to have blocked.
+	MinBlockMinutes = 15
+	MaxBlockMinutes = 8 * 60
+	dateTimeLayout  = "2006-01-02T15:04"
+)
+
+// Service plans focus time.
+type Service struct {
+	store  *store.Store
+	events *events.Service
+	log    *logging.Logger
+	now    func() time.Time
+
+	// cfgMu guards cfg, which Reconfigure may replace.
+	cfgMu sync.RWMutex
+	cfg   *config.Config
+}
+
+// New returns the focus time service for cfg.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	return &Service{cfg: cfg, store: st, events: events.NewService(st), log: logging.New(sink, "focus"), now: time.Now}
+}
+
+// Reconfigure applies a reloaded configuration, such as the focus-time
+// feature flag being turned on or off instance-wide.
+func (s *Service) Reconfigure(cfg *config.Config) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	s.cfg = cfg
+}
+
+func (s *Service) config() *config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg
+}
+
+// Normalize validates settings before they are saved.
+func Normalize(settings *store.FocusSettings) error {
+	switch {
+	case settings.MinMinutes < MinBlockMinutes || settings.MinMinutes > MaxBlockMinutes:
+		return fmt.Errorf("%w: minimum block must be between %d and %d minutes", ErrInvalidSettings, MinBlockMinutes, MaxBlockMinutes)
+	case settings.DayStartMinute < 0 || settings.DayEndMinute > 24*60 || settings.DayStartMinute >= settings.DayEndMinute:
+		return fmt.Errorf("%w: protected hours must start before they end", ErrInvalidSettings)
+	case settings.DayEndMinute-settings.DayStartMinute < settings.MinMinutes:
+		return fmt.Errorf("%w: protected hours are shorter than the minimum block", ErrInvalidSettings)
+	}
+	return nil
+}
+
+// Start plans focus time every hour until ctx is cancelled.
+func (s *Service) Start(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.PlanDue(ctx); err != nil {
+			s.log.Error("Start", "failed to plan focus time: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PlanDue plans the days that have come within the horizon of every user
+// who opted in. A user whose planning fails is retried on the next check.
+func (s *Service) PlanDue(ctx context.Context) error {
+	all, err := s.store.Focus.ListSettings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, settings := range all {
+		if _, err := s.Plan(ctx, settings); err != nil {
+			s.log.Error("PlanDue", "failed to plan focus time for user %d: %v", settings.UserID, err)
+		}
+	}
+	return nil
+}
+
+// Plan blocks the user's free time on the days from where planning last
+// stopped, or today, to the horizon, and returns how many blocks it made.
+// Each day is planned once, so blocks the user deletes or moves are not
+// put back, and replicas racing to plan the same days make them once.
+// Nothing is planned for users the focus-time feature flag is off for.
+func (s *Service) Plan(ctx context.Context, settings store.FocusSettings) (int, error) {
+	user, err := s.store.Users.GetByID(ctx, settings.UserID)
+	if err != nil || user == nil {
+		return 0, err
+	}
+	if !flags.Enabled(ctx, s.config(), s.store, user, config.FeatureFocusTime) {
+		return 0, nil
+	}
+	prefs := locale.ForUser(user)
+	now := s.now()
+	local := now.In(prefs.Location)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, prefs.Location)
+	until := from.AddDate(0, 0, horizonDays)
+	if settings.PlannedUntil != nil && settings.PlannedUntil.After(from) {
+		from = settings.PlannedUntil.In(prefs.Location)
+	}
+	if !from.Before(until) {
+		return 0, nil
+	}
+
+	calendarID, err := s.defaultCalendar(ctx, user)
+	if err != nil || calendarID == 0 {
+		return 0, err
+	}
+	busy, err := s.events.BusyPeriods(ctx, user, from, until)
+	if err != nil {
+		return 0, err
+	}
+	claimed, err := s.store.Focus.ClaimPlanning(ctx, user.ID, settings.PlannedUntil, until)
+	if err != nil || !claimed {
+		return 0, err
+	}
+
+	made := 0
+	for _, gap := range Gaps(busy, settings, prefs, from, until, now) {
+		event, _, err := s.events.CreateEvent(ctx, user, calendarID, events.UpsertInput{
+			Structured: &events.StructuredInput{
+				Summary:      prefs.T("focus.summary"),
+				Description:  prefs.T("focus.description"),
+				DTStart:      gap.Start.Format(dateTimeLayout),
+				DTEnd:        gap.End.Format(dateTimeLayout),
+				Timezone:     prefs.Location.String(),
+				Status:       "TENTATIVE",
+				Transparency: "OPAQUE",
+			},
+		})
+		if err != nil {
+			return made, err
+		}
+		if err := s.store.Focus.AddBlock(ctx, store.FocusBlock{UserID: user.ID, CalendarID: calendarID, UID: event.UID, Start: gap.Start, End: gap.End}); err != nil {
+			return made, err
+		}
+		made++
+	}
+	return made, nil
+}
+
+// Gaps returns the stretches of at least the settings' minimum length,
+// within the protected hours of the working days from from to until and
+// after now, that no busy period touches.
+func Gaps(busy []events.BusyPeriod, settings store.FocusSettings, prefs locale.Preferences, from, until, now time.Time) []events.BusyPeriod {
+	minimum := time.Duration(settings.MinMinutes) * time.Minute
+	working := prefs.WorkingDays()
+	var gaps []events.BusyPeriod
+	for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+		if working&(1<<day.Weekday()) == 0 {
+			continue
+		}
+		// time.Date normalises the minute overflow, keeping hours correct
+		// across DST changes.
+		cursor := time.Date(day.Year(), day.Month(), day.Day(), 0, settings.DayStartMinute, 0, 0, prefs.Location)
+		dayEnd := time.Date(day.Year(), day.Month(), day.Day(), 0, settings.DayEndMinute, 0, 0, prefs.Location)
+		if cursor.Before(now) {
+			// Start on the next whole quarter hour.
+			cursor = now.Truncate(15 * time.Minute)
+			if cursor.Before(now) {
+				cursor = cursor.Add(15 * time.Minute)
+			}
+		}
+		for _, p := range busy {
+			if !p.End.After(cursor) {
+				continue
+			}
+			if !p.Start.Before(dayEnd) {
+				break
+			}
+			if p.Start.Sub(cursor) >= minimum {
+				gaps = append(gaps, events.BusyPeriod{Start: cursor, End: p.Start})
+			}
+			cursor = p.End
+		}
+		if dayEnd.Sub(cursor) >= minimum {
+			gaps = append(gaps, events.BusyPeriod{Start: cursor, End: dayEnd})
+		}
+	}
+	return gaps
+}
+
+// Remove deletes the user's focus time blocks that have not yet ended and
+// returns how many it removed. Blocks whose event the user already deleted,
+// or whose calendar is no longer theirs, are forgotten.
+func (s *Service) Remove(ctx context.Context, user *store.User) (int, error) {
+	blocks, err := s.store.Focus.ListBlocks(ctx, user.ID, s.now())
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, b := range blocks {
+		err := s.events.DeleteEvent(ctx, user, b.CalendarID, b.UID, "", "")
+		switch {
+		case err == nil:
+			removed++
+		case !errors.Is(err, events.ErrNotFound) && !errors.Is(err, events.ErrForbidden):
+			return removed, err
+		}
+		if err := s.store.Focus.DeleteBlock(ctx, user.ID, b.ID); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// defaultCalendar returns the user's first calendar, the one created with
+// their account, or 0 when they own none.
+func (s *Service) defaultCalendar(ctx context.Context, user *store.User) (int64, error) {
+	cals, err := s.store.Calendars.ListByUser(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	for _, cal := range cals {
+		if id == 0 || cal.ID < id {
+			id = cal.ID
+		}
+	}
+	return id, nil
+}
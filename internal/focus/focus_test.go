@@ -0,0 +1,142 @@
+package focus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+	user store.User
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if id != f.user.ID {
+		return nil, nil
+	}
+	return &f.user, nil
+}
+
+var workday = store.FocusSettings{UserID: 1, MinMinutes: 60, DayStartMinute: 9 * 60, DayEndMinute: 17 * 60}
+
+func TestNormalizeRejectsImpossibleSettings(t *testing.T) {
+	for name, settings := range map[string]store.FocusSettings{
+		"short block":    {MinMinutes: 10, DayStartMinute: 540, DayEndMinute: 1020},
+		"long block":     {MinMinutes: 600, DayStartMinute: 0, DayEndMinute: 1440},
+		"reversed hours": {MinMinutes: 60, DayStartMinute: 1020, DayEndMinute: 540},
+		"past midnight":  {MinMinutes: 60, DayStartMinute: 540, DayEndMinute: 1500},
+		"too few hours":  {MinMinutes: 120, DayStartMinute: 540, DayEndMinute: 600},
+	} {
+		if err := Normalize(&settings); err == nil {
+			t.Errorf("%s: Normalize() accepted %+v", name, settings)
+		}
+	}
+	settings := workday
+	if err := Normalize(&settings); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+}
+
+func TestGapsLeaveBusyTimeWeekendsAndThePast(t *testing.T) {
+	prefs := locale.ForUser(&store.User{Timezone: "Europe/Berlin"})
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, prefs.Location)
+	}
+	// Monday 2 March 2026, 10:10 in Berlin.
+	now := at(2, 10, 10)
+	busy := []events.BusyPeriod{
+		{Start: at(2, 12, 0), End: at(2, 13, 0)},
+		{Start: at(3, 8, 0), End: at(3, 16, 30)},
+	}
+	got := Gaps(busy, workday, prefs, at(2, 0, 0), at(9, 0, 0), now)
+	want := []events.BusyPeriod{
+		{Start: at(2, 10, 15), End: at(2, 12, 0)},
+		{Start: at(2, 13, 0), End: at(2, 17, 0)},
+		{Start: at(4, 9, 0), End: at(4, 17, 0)},
+		{Start: at(5, 9, 0), End: at(5, 17, 0)},
+		{Start: at(6, 9, 0), End: at(6, 17, 0)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Gaps() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			t.Fatalf("gap %d = %v-%v, want %v-%v", i, got[i].Start, got[i].End, want[i].Start, want[i].End)
+		}
+	}
+}
+
+func TestPlanBlocksEachDayOnceAndRemoveDeletesTheBlocks(t *testing.T) {
+	f := storetest.New()
+	f.AddCalendar(storetest.OwnedCalendar(9, 1, "Side project"))
+	f.AddCalendar(storetest.OwnedCalendar(5, 1, "Personal"))
+	// Busy all of Tuesday's protected hours.
+	offsite := storetest.Event(9, "offsite", "Offsite", time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC))
+	offsite.RawICAL = strings.Replace(offsite.RawICAL, "DTEND:20260303T100000Z", "DTEND:20260303T170000Z", 1)
+	offsite.DTEnd = ptr(time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC))
+	f.AddEvent(offsite)
+	focusRepo := &storetest.Focus{}
+	f.Store.Focus = focusRepo
+	ann := store.User{ID: 1, PrimaryEmail: "ann@example.com"}
+	f.Store.Users = &fakeUsers{user: ann}
+	if _, err := focusRepo.UpsertSettings(context.Background(), workday); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC) // Monday evening
+	s := New(&config.Config{}, f.Store, nil)
+	s.now = func() time.Time { return now }
+	if err := s.PlanDue(context.Background()); err != nil {
+		t.Fatalf("PlanDue() error = %v", err)
+	}
+	// Wednesday to Friday; Monday is over and Tuesday is busy.
+	if len(focusRepo.Blocks) != 3 {
+		t.Fatalf("blocks = %+v, want 3", focusRepo.Blocks)
+	}
+	for _, b := range focusRepo.Blocks {
+		if b.CalendarID != 5 {
+			t.Fatalf("block on calendar %d, want the default calendar 5", b.CalendarID)
+		}
+		ev := f.Events.Events[f.Events.Key(5, b.UID)]
+		if ev == nil || !strings.Contains(ev.RawICAL, "STATUS:TENTATIVE") || !strings.Contains(ev.RawICAL, "SUMMARY:Focus time") {
+			t.Fatalf("focus event = %+v", ev)
+		}
+	}
+
+	// Deleting a block does not bring it back, and nothing is planned twice.
+	if err := s.events.DeleteEvent(context.Background(), &ann, 5, focusRepo.Blocks[0].UID, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Plan(context.Background(), *focusRepo.Settings[1]); err != nil || n != 0 {
+		t.Fatalf("replanning made %d blocks, %v", n, err)
+	}
+
+	// A day later the following Monday comes within the week.
+	now = now.AddDate(0, 0, 1)
+	if n, err := s.Plan(context.Background(), *focusRepo.Settings[1]); err != nil || n != 1 {
+		t.Fatalf("next day made %d blocks, %v; want 1", n, err)
+	}
+
+	removed, err := s.Remove(context.Background(), &ann)
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if removed != 3 || len(focusRepo.Blocks) != 0 {
+		t.Fatalf("Remove() = %d, blocks left %+v", removed, focusRepo.Blocks)
+	}
+	for key := range f.Events.Events {
+		if strings.HasPrefix(key, "5:") {
+			t.Fatalf("focus event %s left behind", key)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
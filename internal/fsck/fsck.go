@@ -0,0 +1,490 @@
+// Package fsck checks stored calendar and contact data for consistency:
+// payloads the parser now rejects, ETags that no longer match their payload,
+// UIDs that disagree with the payload, VTIMEZONEs the time zone database has
+// since changed, tombstones left behind by deleted collections or
+// recreated resources, and events and contacts whose collection no longer
+// exists. It can repair what is safe to fix.
+package fsck
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const logClass = "Fsck"
+
+// canonicalBatch is how many resources one canonical-form backfill migrates.
+const canonicalBatch = 500
+
+// maxAlertedEvents bounds how many events an admin alert lists.
+const maxAlertedEvents = 50
+
+// Issue kinds reported by a check.
+const (
+	IssueInvalidData       = "invalid_data"
+	IssueETagMismatch      = "etag_mismatch"
+	IssueUIDMismatch       = "uid_mismatch"
+	IssueOrphanedTombstone = "orphaned_tombstone"
+	IssueStaleTimezone     = "stale_timezone"
+	IssueOrphanedResource  = "orphaned_resource"
+)
+
+// allIssueKinds lists every issue kind, for metrics.
+var allIssueKinds = []string{IssueInvalidData, IssueETagMismatch, IssueUIDMismatch, IssueOrphanedTombstone, IssueStaleTimezone, IssueOrphanedResource}
+
+const (
+	ResourceEvent   = "event"
+	ResourceContact = "contact"
+)
+
+// ErrBusy is returned when a check is requested while one is running.
+var ErrBusy = errors.New("fsck: a check is already running")
+
+// Issue is one inconsistency found by a check.
+type Issue struct {
+	Kind         string `json:"kind"`
+	ResourceType string `json:"resourceType"`
+	CollectionID int64  `json:"collectionId"`
+	UID          string `json:"uid"`
+	ResourceName string `json:"resourceName,omitempty"`
+	Detail       string `json:"detail,omitempty"`
+	// Repaired is set when the check fixed the issue. Invalid data and UID
+	// mismatches need a person and are never repaired automatically.
+	Repaired bool `json:"repaired"`
+}
+
+// Report summarises one check.
+type Report struct {
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	Repair       bool      `json:"repair"`
+	Calendars    int       `json:"calendars"`
+	Events       int       `json:"events"`
+	AddressBooks int       `json:"addressBooks"`
+	Contacts     int       `json:"contacts"`
+	Issues       []Issue   `json:"issues"`
+	Repaired     int       `json:"repaired"`
+	// LegacyETags counts resources whose ETag is still the hash of their raw
+	// bytes rather than of their canonical form; Canonicalized counts those
+	// a repairing check migrated.
+	LegacyETags   int `json:"legacyETags"`
+	Canonicalized int `json:"canonicalized"`
+
+	// timezones caches staleTimezones verdicts for the run.
+	timezones map[string]int
+}
+
+// Status reports scheduler state and the last report for the admin API.
+type Status struct {
+	Interval   string     `json:"interval,omitempty"`
+	AutoRepair bool       `json:"autoRepair"`
+	Running    bool       `json:"running"`
+	LastRunAt  *time.Time `json:"lastRunAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	NextRunAt  *time.Time `json:"nextRunAt,omitempty"`
+	LastReport *Report    `json:"lastReport,omitempty"`
+}
+
+// Service runs consistency checks on demand and, when an interval is
+// configured, on a schedule.
+type Service struct {
+	store    *store.Store
+	interval time.Duration
+	repair   bool
+	log      *logging.Logger
+	now      func() time.Time
+	mailer   *mail.Mailer
+	admins   []string
+
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	status Status
+	// alerted is the number of stale time zones admins were last told about.
+	alerted int
+}
+
+// New returns the check service configured by cfg.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	s := &Service{
+		store:    st,
+		interval: cfg.Fsck.Interval,
+		repair:   cfg.Fsck.Repair,
+		log:      logging.New(sink, logClass),
+		now:      time.Now,
+		mailer:   mail.New(cfg),
+		admins:   cfg.AdminEmails,
+	}
+	s.status.AutoRepair = s.repair
+	if s.interval > 0 {
+		s.status.Interval = s.interval.String()
+	}
+	return s
+}
+
+// Status returns a copy of the current state.
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Start runs a check every interval until ctx is cancelled. It returns at
+// once when no interval is configured.
+func (s *Service) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		next := s.now().Add(s.interval)
+		s.mu.Lock()
+		s.status.NextRunAt = &next
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.Run(ctx, s.repair); err != nil && !errors.Is(err, ErrBusy) {
+			s.log.Error("Start", "scheduled check failed: %v", err)
+		}
+	}
+}
+
+// Trigger starts a check in the background, returning ErrBusy when one is
+// already running.
+func (s *Service) Trigger(repair bool) error {
+	if !s.runMu.TryLock() {
+		return ErrBusy
+	}
+	go func() {
+		defer s.runMu.Unlock()
+		if _, err := s.run(context.Background(), repair); err != nil {
+			s.log.Error("Trigger", "manual check failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Run checks every collection of every active user and all tombstones,
+// repairing ETag mismatches and orphaned tombstones when repair is set.
+func (s *Service) Run(ctx context.Context, repair bool) (*Report, error) {
+	if !s.runMu.TryLock() {
+		return nil, ErrBusy
+	}
+	defer s.runMu.Unlock()
+	return s.run(ctx, repair)
+}
+
+func (s *Service) run(ctx context.Context, repair bool) (*Report, error) {
+	report := &Report{StartedAt: s.now().UTC(), Repair: repair, Issues: []Issue{}, timezones: map[string]int{}}
+	s.mu.Lock()
+	s.status.Running = true
+	s.status.LastRunAt = &report.StartedAt
+	s.mu.Unlock()
+
+	err := s.check(ctx, report)
+	report.FinishedAt = s.now().UTC()
+
+	s.mu.Lock()
+	s.status.Running = false
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastError = ""
+		s.status.LastReport = report
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("run", "checked %d events and %d contacts: %d issues, %d repaired",
+		report.Events, report.Contacts, len(report.Issues), report.Repaired)
+	report.observe()
+	s.alertStaleTimezones(report)
+	return report, nil
+}
+
+func (s *Service) check(ctx context.Context, report *Report) error {
+	if report.Repair && s.store.CanonicalForms != nil {
+		if err := s.backfillCanonical(ctx, report); err != nil {
+			return err
+		}
+	}
+	users, err := s.store.Users.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+	for _, user := range users {
+		cals, err := s.store.Calendars.ListByUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("list calendars for user %d: %w", user.ID, err)
+		}
+		for _, cal := range cals {
+			report.Calendars++
+			if err := s.checkCalendar(ctx, cal.ID, report); err != nil {
+				return err
+			}
+		}
+		books, err := s.store.AddressBooks.ListByUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("list address books for user %d: %w", user.ID, err)
+		}
+		for _, book := range books {
+			report.AddressBooks++
+			if err := s.checkAddressBook(ctx, book.ID, report); err != nil {
+				return err
+			}
+		}
+	}
+	if s.store.Orphans != nil {
+		if err := s.checkOrphans(ctx, report); err != nil {
+			return err
+		}
+	}
+	return s.checkTombstones(ctx, report)
+}
+
+func (s *Service) checkCalendar(ctx context.Context, calendarID int64, report *Report) error {
+	evs, err := s.store.Events.ListForCalendar(ctx, calendarID)
+	if err != nil {
+		return fmt.Errorf("list events for calendar %d: %w", calendarID, err)
+	}
+	for _, ev := range evs {
+		report.Events++
+		issue := Issue{ResourceType: ResourceEvent, CollectionID: calendarID, UID: ev.UID, ResourceName: ev.ResourceName}
+
+		if uid, err := events.ValidateStored(ev.RawICAL); err != nil {
+			report.add(issue.with(IssueInvalidData, err.Error()))
+		} else if uid != ev.UID {
+			report.add(issue.with(IssueUIDMismatch, fmt.Sprintf("payload UID is %q", uid)))
+		}
+
+		if want := utils.GenerateETag(ev.RawICAL); ev.ETag == legacyETag(ev.RawICAL) && ev.ETag != want {
+			report.LegacyETags++
+		} else if ev.ETag != want {
+			found := issue.with(IssueETagMismatch, fmt.Sprintf("stored %q, payload hashes to %q", ev.ETag, want))
+			if report.Repair {
+				ev.ETag = want
+				if _, err := s.store.Events.Upsert(ctx, ev); err != nil {
+					return fmt.Errorf("repair etag of event %q in calendar %d: %w", ev.UID, calendarID, err)
+				}
+				found.Repaired = true
+			}
+			report.add(found)
+		}
+
+		if stale := staleTimezones(ev.RawICAL, s.now(), report.timezones); len(stale) > 0 {
+			found := issue.with(IssueStaleTimezone, staleTimezoneDetail(stale))
+			if report.Repair {
+				ev.RawICAL = rewriteTimezones(ev.RawICAL, stale)
+				ev.ETag = utils.GenerateETag(ev.RawICAL)
+				if _, err := s.store.Events.Upsert(ctx, ev); err != nil {
+					return fmt.Errorf("rewrite time zones of event %q in calendar %d: %w", ev.UID, calendarID, err)
+				}
+				found.Repaired = true
+			}
+			report.add(found)
+		}
+	}
+	return nil
+}
+
+func (s *Service) checkAddressBook(ctx context.Context, bookID int64, report *Report) error {
+	cards, err := s.store.Contacts.ListForBook(ctx, bookID)
+	if err != nil {
+		return fmt.Errorf("list contacts for address book %d: %w", bookID, err)
+	}
+	for _, card := range cards {
+		report.Contacts++
+		issue := Issue{ResourceType: ResourceContact, CollectionID: bookID, UID: card.UID, ResourceName: card.ResourceName}
+
+		// A card without a UID is stored under the one its path implies.
+		if uid, err := contacts.ValidateStored(card.RawVCard); err != nil {
+			report.add(issue.with(IssueInvalidData, err.Error()))
+		} else if uid != "" && uid != card.UID {
+			report.add(issue.with(IssueUIDMismatch, fmt.Sprintf("payload UID is %q", uid)))
+		}
+
+		if want := utils.GenerateETag(card.RawVCard); card.ETag == legacyETag(card.RawVCard) && card.ETag != want {
+			report.LegacyETags++
+		} else if card.ETag != want {
+			found := issue.with(IssueETagMismatch, fmt.Sprintf("stored %q, payload hashes to %q", card.ETag, want))
+			if report.Repair {
+				card.ETag = want
+				if _, err := s.store.Contacts.Upsert(ctx, card); err != nil {
+					return fmt.Errorf("repair etag of contact %q in address book %d: %w", card.UID, bookID, err)
+				}
+				found.Repaired = true
+			}
+			report.add(found)
+		}
+	}
+	return nil
+}
+
+// backfillCanonical stores the canonical form of resources written before
+// ETags were computed from it, switching them to canonical ETags.
+func (s *Service) backfillCanonical(ctx context.Context, report *Report) error {
+	for _, backfill := range []struct {
+		kind string
+		fn   func(context.Context, int) (int, error)
+	}{
+		{"events", s.store.CanonicalForms.BackfillEvents},
+		{"contacts", s.store.CanonicalForms.BackfillContacts},
+	} {
+		for {
+			n, err := backfill.fn(ctx, canonicalBatch)
+			report.Canonicalized += n
+			if err != nil {
+				return fmt.Errorf("backfill canonical %s: %w", backfill.kind, err)
+			}
+			if n == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// checkOrphans finds events and contacts whose collection was deleted
+// without them. Nobody can reach them any more, so repairing deletes them.
+// Deleting one leaves a tombstone for the missing collection, which
+// checkTombstones then removes.
+func (s *Service) checkOrphans(ctx context.Context, report *Report) error {
+	orphaned, err := s.store.Orphans.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list orphaned resources: %w", err)
+	}
+	for _, o := range orphaned {
+		found := Issue{
+			Kind:         IssueOrphanedResource,
+			ResourceType: o.ResourceType,
+			CollectionID: o.CollectionID,
+			UID:          o.UID,
+			ResourceName: o.ResourceName,
+			Detail:       "collection no longer exists",
+		}
+		if report.Repair {
+			if err := s.store.Orphans.Delete(ctx, o.ResourceType, o.CollectionID, o.UID); err != nil {
+				return fmt.Errorf("remove orphaned %s %q: %w", o.ResourceType, o.UID, err)
+			}
+			found.Repaired = true
+		}
+		report.add(found)
+	}
+	return nil
+}
+
+func (s *Service) checkTombstones(ctx context.Context, report *Report) error {
+	orphaned, err := s.store.DeletedResources.ListOrphaned(ctx)
+	if err != nil {
+		return fmt.Errorf("list orphaned tombstones: %w", err)
+	}
+	for _, d := range orphaned {
+		found := Issue{
+			Kind:         IssueOrphanedTombstone,
+			ResourceType: d.ResourceType,
+			CollectionID: d.CollectionID,
+			UID:          d.UID,
+			ResourceName: d.ResourceName,
+			Detail:       "collection deleted or resource recreated",
+		}
+		if report.Repair {
+			if err := s.store.DeletedResources.DeleteByIdentity(ctx, d.ResourceType, d.CollectionID, d.UID, d.ResourceName); err != nil {
+				return fmt.Errorf("remove tombstone %d: %w", d.ID, err)
+			}
+			found.Repaired = true
+		}
+		report.add(found)
+	}
+	return nil
+}
+
+// alertStaleTimezones tells admins about events whose VTIMEZONEs the time
+// zone database has changed and the check left alone, once per change in
+// their number.
+func (s *Service) alertStaleTimezones(report *Report) {
+	var stale []Issue
+	for _, issue := range report.Issues {
+		if issue.Kind == IssueStaleTimezone && !issue.Repaired {
+			stale = append(stale, issue)
+		}
+	}
+	s.mu.Lock()
+	changed := len(stale) != s.alerted
+	s.alerted = len(stale)
+	s.mu.Unlock()
+	if !changed || len(stale) == 0 {
+		return
+	}
+	s.log.Warn("alertStaleTimezones", "%d events have time zone definitions that differ from the current time zone database; run a repairing check to rewrite them", len(stale))
+	if s.mailer == nil || len(s.admins) == 0 {
+		return
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d events store time zone definitions that differ from the current time zone database, so clients may show them at the wrong time.\n", len(stale))
+	body.WriteString("Run a repairing check (POST /api/admin/fsck with {\"repair\":true}) to rewrite them.\n\n")
+	for i, issue := range stale {
+		if i == maxAlertedEvents {
+			fmt.Fprintf(&body, "... and %d more\n", len(stale)-i)
+			break
+		}
+		fmt.Fprintf(&body, "calendar %d, event %s: %s\n", issue.CollectionID, issue.UID, issue.Detail)
+	}
+	if err := s.mailer.Send(mail.Message{
+		To:      s.admins,
+		Subject: fmt.Sprintf("CalCard: %d events have outdated time zones", len(stale)),
+		Text:    body.String(),
+	}); err != nil {
+		s.log.Error("alertStaleTimezones", "failed to email admins: %v", err)
+	}
+}
+
+// legacyETag is the ETag computed before canonicalization: the hash of the
+// payload bytes as stored.
+func legacyETag(raw string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(raw)))
+}
+
+func (r *Report) add(issue Issue) {
+	r.Issues = append(r.Issues, issue)
+	if issue.Repaired {
+		r.Repaired++
+	}
+}
+
+// observe publishes the report's issue counts as metrics.
+func (r *Report) observe() {
+	found := map[string]int{}
+	repaired := map[string]int{}
+	for _, issue := range r.Issues {
+		found[issue.Kind]++
+		if issue.Repaired {
+			repaired[issue.Kind]++
+		}
+	}
+	metrics.SetFsckIssues(allIssueKinds, found, repaired)
+}
+
+func (i Issue) with(kind, detail string) Issue {
+	i.Kind = kind
+	i.Detail = detail
+	return i
+}
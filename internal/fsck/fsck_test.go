@@ -0,0 +1,358 @@
+package fsck
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+}
+
+func (f *fakeUsers) ListActive(ctx context.Context) ([]store.User, error) {
+	return []store.User{{ID: 1}}, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+}
+
+func (f *fakeCalendars) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
+	return []store.Calendar{{ID: 10, UserID: userID}}, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+	events []store.Event
+}
+
+func (f *fakeEvents) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
+	return append([]store.Event(nil), f.events...), nil
+}
+
+func (f *fakeEvents) Upsert(ctx context.Context, ev store.Event) (*store.Event, error) {
+	for i := range f.events {
+		if f.events[i].UID == ev.UID {
+			f.events[i] = ev
+		}
+	}
+	return &ev, nil
+}
+
+type fakeAddressBooks struct {
+	store.AddressBookRepository
+}
+
+func (f *fakeAddressBooks) ListByUser(ctx context.Context, userID int64) ([]store.AddressBook, error) {
+	return []store.AddressBook{{ID: 20, UserID: userID}}, nil
+}
+
+type fakeContacts struct {
+	store.ContactRepository
+	contacts []store.Contact
+}
+
+func (f *fakeContacts) ListForBook(ctx context.Context, bookID int64) ([]store.Contact, error) {
+	return f.contacts, nil
+}
+
+type fakeTombstones struct {
+	store.DeletedResourceRepository
+	orphaned []store.DeletedResource
+}
+
+func (f *fakeTombstones) ListOrphaned(ctx context.Context) ([]store.DeletedResource, error) {
+	return append([]store.DeletedResource(nil), f.orphaned...), nil
+}
+
+func (f *fakeTombstones) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
+	kept := f.orphaned[:0]
+	for _, d := range f.orphaned {
+		if d.ResourceType != resourceType || d.CollectionID != collectionID || d.UID != uid || d.ResourceName != resourceName {
+			kept = append(kept, d)
+		}
+	}
+	f.orphaned = kept
+	return nil
+}
+
+func eventICAL(uid string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART:20260105T090000Z\r\nSUMMARY:Test\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+func storedEvent(uid, raw string) store.Event {
+	return store.Event{CalendarID: 10, UID: uid, ResourceName: uid, RawICAL: raw, ETag: utils.GenerateETag(raw)}
+}
+
+func newTestService() (*Service, *fakeEvents, *fakeTombstones) {
+	stale := storedEvent("stale", eventICAL("stale"))
+	stale.ETag = "0123"
+	eventRepo := &fakeEvents{events: []store.Event{
+		storedEvent("good", eventICAL("good")),
+		stale,
+		storedEvent("renamed", eventICAL("other-uid")),
+		storedEvent("broken", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n"),
+	}}
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:card\r\nFN:Card\r\nEND:VCARD\r\n"
+	tombstones := &fakeTombstones{orphaned: []store.DeletedResource{
+		{ID: 1, ResourceType: ResourceEvent, CollectionID: 99, UID: "gone", ResourceName: "gone"},
+	}}
+	st := &store.Store{
+		Users:            &fakeUsers{},
+		Calendars:        &fakeCalendars{},
+		Events:           eventRepo,
+		AddressBooks:     &fakeAddressBooks{},
+		Contacts:         &fakeContacts{contacts: []store.Contact{{AddressBookID: 20, UID: "card", ResourceName: "card", RawVCard: card, ETag: utils.GenerateETag(card)}}},
+		DeletedResources: tombstones,
+	}
+	return New(&config.Config{}, st, nil), eventRepo, tombstones
+}
+
+func issueKinds(report *Report) map[string]string {
+	kinds := map[string]string{}
+	for _, issue := range report.Issues {
+		kinds[issue.UID] = issue.Kind
+	}
+	return kinds
+}
+
+func TestRunReportsWithoutRepairing(t *testing.T) {
+	svc, eventRepo, tombstones := newTestService()
+
+	report, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Events != 4 || report.Contacts != 1 || len(report.Issues) != 4 || report.Repaired != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	want := map[string]string{
+		"stale":   IssueETagMismatch,
+		"renamed": IssueUIDMismatch,
+		"broken":  IssueInvalidData,
+		"gone":    IssueOrphanedTombstone,
+	}
+	got := issueKinds(report)
+	for uid, kind := range want {
+		if got[uid] != kind {
+			t.Fatalf("issue for %q = %q, want %q (report %+v)", uid, got[uid], kind, report.Issues)
+		}
+	}
+	if eventRepo.events[1].ETag != "0123" || len(tombstones.orphaned) != 1 {
+		t.Fatal("a report-only check must not change stored data")
+	}
+	if status := svc.Status(); status.Running || status.LastReport != report {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestRunRepairsETagsAndTombstones(t *testing.T) {
+	svc, eventRepo, tombstones := newTestService()
+
+	report, err := svc.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Repaired != 2 {
+		t.Fatalf("repaired = %d, want 2 (issues %+v)", report.Repaired, report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if repairable := issue.Kind == IssueETagMismatch || issue.Kind == IssueOrphanedTombstone; issue.Repaired != repairable {
+			t.Fatalf("issue %+v repaired = %v, want %v", issue, issue.Repaired, repairable)
+		}
+	}
+	if eventRepo.events[1].ETag != utils.GenerateETag(eventRepo.events[1].RawICAL) || len(tombstones.orphaned) != 0 {
+		t.Fatal("expected the ETag recomputed and the tombstone removed")
+	}
+
+	again, err := svc.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	if len(again.Issues) != 2 || again.Repaired != 0 {
+		t.Fatalf("expected only the manual issues to remain, got %+v", again.Issues)
+	}
+}
+
+type fakeOrphans struct {
+	orphaned []store.OrphanedResource
+}
+
+func (f *fakeOrphans) List(ctx context.Context) ([]store.OrphanedResource, error) {
+	return append([]store.OrphanedResource(nil), f.orphaned...), nil
+}
+
+func (f *fakeOrphans) Delete(ctx context.Context, resourceType string, collectionID int64, uid string) error {
+	kept := f.orphaned[:0]
+	for _, o := range f.orphaned {
+		if o.ResourceType != resourceType || o.CollectionID != collectionID || o.UID != uid {
+			kept = append(kept, o)
+		}
+	}
+	f.orphaned = kept
+	return nil
+}
+
+func TestRunRemovesOrphanedResources(t *testing.T) {
+	svc, _, _ := newTestService()
+	orphans := &fakeOrphans{orphaned: []store.OrphanedResource{
+		{ResourceType: ResourceEvent, CollectionID: 98, UID: "lost-event", ResourceName: "lost-event"},
+		{ResourceType: ResourceContact, CollectionID: 97, UID: "lost-card", ResourceName: "lost-card"},
+	}}
+	svc.store.Orphans = orphans
+
+	report, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := issueKinds(report); got["lost-event"] != IssueOrphanedResource || got["lost-card"] != IssueOrphanedResource {
+		t.Fatalf("expected both orphans reported, got %+v", report.Issues)
+	}
+	if len(orphans.orphaned) != 2 {
+		t.Fatal("a report-only check must not delete orphans")
+	}
+
+	if _, err := svc.Run(context.Background(), true); err != nil {
+		t.Fatalf("repairing Run() error = %v", err)
+	}
+	if len(orphans.orphaned) != 0 {
+		t.Fatalf("expected orphans deleted, %d left", len(orphans.orphaned))
+	}
+}
+
+type fakeCanonicalForms struct {
+	events  *fakeEvents
+	batches int
+}
+
+func (f *fakeCanonicalForms) BackfillEvents(ctx context.Context, limit int) (int, error) {
+	f.batches++
+	updated := 0
+	for i := range f.events.events {
+		if want := utils.GenerateETag(f.events.events[i].RawICAL); f.events.events[i].ETag != want && updated < limit {
+			f.events.events[i].ETag = want
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (f *fakeCanonicalForms) BackfillContacts(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func TestRunMigratesLegacyETags(t *testing.T) {
+	raw := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:legacy\nDTSTART:20260105T090000Z\nEND:VEVENT\nEND:VCALENDAR\n"
+	legacy := storedEvent("legacy", raw)
+	legacy.ETag = fmt.Sprintf("%x", sha256.Sum256([]byte(raw)))
+	svc, eventRepo, _ := newTestService()
+	eventRepo.events = []store.Event{storedEvent("good", eventICAL("good")), legacy}
+
+	report, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.LegacyETags != 1 || len(issueKinds(report)) != 1 {
+		t.Fatalf("expected one legacy ETag and only the tombstone issue, got %+v", report)
+	}
+
+	forms := &fakeCanonicalForms{events: eventRepo}
+	svc.store.CanonicalForms = forms
+	report, err = svc.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Canonicalized != 1 || report.LegacyETags != 0 || forms.batches != 2 {
+		t.Fatalf("expected the legacy ETag migrated in one batch, got %+v after %d batches", report, forms.batches)
+	}
+	if eventRepo.events[1].ETag != utils.GenerateETag(raw) {
+		t.Fatalf("etag = %q, want the canonical ETag", eventRepo.events[1].ETag)
+	}
+}
+
+func TestRunRejectsConcurrentChecks(t *testing.T) {
+	svc, _, _ := newTestService()
+	svc.runMu.Lock()
+	defer svc.runMu.Unlock()
+	if _, err := svc.Run(context.Background(), false); !errors.Is(err, ErrBusy) {
+		t.Fatalf("Run() error = %v, want ErrBusy", err)
+	}
+	if err := svc.Trigger(false); !errors.Is(err, ErrBusy) {
+		t.Fatalf("Trigger() error = %v, want ErrBusy", err)
+	}
+}
+
+func timezoneEventICAL(uid, vtimezone string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\n" + vtimezone +
+		"BEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;TZID=America/New_York:20260601T090000\r\n" +
+		"DTEND;TZID=America/New_York:20260601T100000\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:Test\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+// usVTimezone returns a client-style VTIMEZONE for America/New_York with
+// daylight time between the given rule parts.
+func usVTimezone(daylightStart, standardStart string) string {
+	return "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n" +
+		"BEGIN:DAYLIGHT\r\nDTSTART:19700308T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\nRRULE:FREQ=YEARLY;" + daylightStart + "\r\nTZNAME:EDT\r\nEND:DAYLIGHT\r\n" +
+		"BEGIN:STANDARD\r\nDTSTART:19701101T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\nRRULE:FREQ=YEARLY;" + standardStart + "\r\nTZNAME:EST\r\nEND:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n"
+}
+
+func TestRunRewritesStaleTimezones(t *testing.T) {
+	current := timezoneEventICAL("current", usVTimezone("BYMONTH=3;BYDAY=2SU", "BYMONTH=11;BYDAY=1SU"))
+	generated := timezoneEventICAL("generated", utils.GenerateVTimezone("America/New_York", 2026, 2027, 2028, 2029, 2030, 2031))
+	// The rules before 2007: daylight time from the first Sunday in April
+	// to the last Sunday in October.
+	outdated := timezoneEventICAL("outdated", usVTimezone("BYMONTH=4;BYDAY=1SU", "BYMONTH=10;BYDAY=-1SU"))
+
+	svc, eventRepo, _ := newTestService()
+	svc.now = func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+	eventRepo.events = []store.Event{storedEvent("current", current), storedEvent("generated", generated), storedEvent("outdated", outdated)}
+
+	report, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	kinds := issueKinds(report)
+	if kinds["outdated"] != IssueStaleTimezone || kinds["current"] != "" || kinds["generated"] != "" {
+		t.Fatalf("expected only the outdated VTIMEZONE reported, got %+v", report.Issues)
+	}
+	if svc.alerted != 1 {
+		t.Fatalf("alerted = %d, want 1", svc.alerted)
+	}
+
+	report, err = svc.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if issueKinds(report)["outdated"] != IssueStaleTimezone || report.Repaired != 2 {
+		t.Fatalf("expected the time zone and the tombstone repaired, got %+v", report.Issues)
+	}
+	rewritten := eventRepo.events[2]
+	if strings.Contains(rewritten.RawICAL, "BYDAY=1SU") || !strings.Contains(rewritten.RawICAL, "DTSTART:20260308T020000") {
+		t.Fatalf("expected a VTIMEZONE generated from the database, got:\n%s", rewritten.RawICAL)
+	}
+	if rewritten.ETag != utils.GenerateETag(rewritten.RawICAL) || !strings.Contains(rewritten.RawICAL, "RRULE:FREQ=WEEKLY") {
+		t.Fatalf("unexpected rewritten event %+v", rewritten)
+	}
+	if _, err := events.ValidateStored(rewritten.RawICAL); err != nil {
+		t.Fatalf("rewritten event is invalid: %v", err)
+	}
+
+	again, err := svc.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if issueKinds(again)["outdated"] != "" || svc.alerted != 0 {
+		t.Fatalf("expected the rewritten VTIMEZONE to match, got %+v", again.Issues)
+	}
+}
@@ -0,0 +1,430 @@
+package fsck
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+const (
+	// timezoneLookaheadYears is how far past the current year an open-ended
+	// recurring event is checked and its rewritten VTIMEZONE reaches.
+	timezoneLookaheadYears = 5
+	// timezoneSampleStep is how often a year is sampled when comparing a
+	// VTIMEZONE with the time zone database.
+	timezoneSampleStep = 6 * time.Hour
+)
+
+// observance is one STANDARD or DAYLIGHT block of a VTIMEZONE. Local times
+// are wall clock times held in UTC.
+type observance struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	rrule      map[string]string
+	rdates     []time.Time
+}
+
+// vtimezone is a parsed VTIMEZONE component.
+type vtimezone struct {
+	tzid        string
+	observances []observance
+}
+
+// staleTimezone is a VTIMEZONE of an event that disagrees with the time zone
+// database in a year the event uses.
+type staleTimezone struct {
+	tzid  string
+	year  int
+	years []int
+}
+
+// staleTimezones returns the VTIMEZONEs in raw whose offsets differ from the
+// current time zone database during the years the event uses. TZIDs the
+// database does not know are skipped. cache holds verdicts across events,
+// which mostly share a few VTIMEZONEs.
+func staleTimezones(raw string, now time.Time, cache map[string]int) []staleTimezone {
+	var stale []staleTimezone
+	for _, component := range utils.ICalComponents(raw, "VTIMEZONE") {
+		vtz, ok := parseVTimezone(component)
+		if !ok {
+			continue
+		}
+		years := timezoneYears(raw, vtz.tzid, now)
+		if len(years) == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%x:%d:%d", sha256.Sum256([]byte(component)), years[0], years[len(years)-1])
+		year, seen := cache[key]
+		if !seen {
+			year = 0
+			loc, err := time.LoadLocation(vtz.tzid)
+			first, last := vtz.coverage()
+			for _, y := range years {
+				if err != nil {
+					break
+				}
+				if y < first || y > last {
+					// Only the years the VTIMEZONE describes can go stale.
+					continue
+				}
+				if !vtz.matches(loc, y) {
+					year = y
+					break
+				}
+			}
+			cache[key] = year
+		}
+		if year != 0 {
+			stale = append(stale, staleTimezone{tzid: vtz.tzid, year: year, years: years})
+		}
+	}
+	return stale
+}
+
+// rewriteTimezones replaces the stale VTIMEZONEs in raw with ones generated
+// from the time zone database for the years the event uses.
+func rewriteTimezones(raw string, stale []staleTimezone) string {
+	raw = normalizeLineEndings(raw)
+	for _, s := range stale {
+		for _, component := range utils.ICalComponents(raw, "VTIMEZONE") {
+			vtz, ok := parseVTimezone(component)
+			if !ok || vtz.tzid != s.tzid {
+				continue
+			}
+			if fresh := utils.GenerateVTimezone(s.tzid, s.years...); fresh != "" {
+				raw = strings.Replace(raw, component, fresh, 1)
+			}
+		}
+	}
+	return raw
+}
+
+func staleTimezoneDetail(stale []staleTimezone) string {
+	parts := make([]string, 0, len(stale))
+	for _, s := range stale {
+		parts = append(parts, fmt.Sprintf("VTIMEZONE %s differs from the time zone database in %d", s.tzid, s.year))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// normalizeLineEndings unfolds raw and ends each line with CRLF, the form
+// utils.ICalComponents returns components in.
+func normalizeLineEndings(raw string) string {
+	lines := utils.UnfoldLines(raw)
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// timezoneYears returns the years in which raw has times in tzid: the years
+// of every DTSTART, DTEND and RECURRENCE-ID with that TZID and, for a
+// recurring component, every year up to its UNTIL or, without one, up to
+// timezoneLookaheadYears from now.
+func timezoneYears(raw, tzid string, now time.Time) []int {
+	first, last := 0, 0
+	add := func(y int) {
+		if first == 0 || y < first {
+			first = y
+		}
+		if y > last {
+			last = y
+		}
+	}
+	for _, component := range utils.ICalComponents(raw, "VEVENT") {
+		start := 0
+		var rrule map[string]string
+		for _, line := range utils.UnfoldLines(component) {
+			name, params, value, ok := splitProperty(line)
+			if !ok {
+				continue
+			}
+			switch name {
+			case "DTSTART", "DTEND", "RECURRENCE-ID":
+				if params["TZID"] != tzid {
+					continue
+				}
+				if t, ok := parseLocal(value); ok {
+					add(t.Year())
+					if name == "DTSTART" {
+						start = t.Year()
+					}
+				}
+			case "RRULE":
+				rrule = ruleParts(value)
+			}
+		}
+		if start == 0 || rrule == nil {
+			continue
+		}
+		end := now.Year() + timezoneLookaheadYears
+		if until, ok := parseLocal(rrule["UNTIL"]); ok && until.Year() < end {
+			end = until.Year()
+		} else if _, ok := rrule["COUNT"]; ok && end > now.Year() && start < now.Year() {
+			// A counted series may well have ended; check until now.
+			end = now.Year()
+		}
+		if end >= start {
+			add(end)
+		}
+	}
+	if first == 0 {
+		return nil
+	}
+	years := make([]int, 0, last-first+1)
+	for y := first; y <= last; y++ {
+		years = append(years, y)
+	}
+	return years
+}
+
+func parseVTimezone(component string) (vtimezone, bool) {
+	var vtz vtimezone
+	var current *observance
+	for _, line := range utils.UnfoldLines(component) {
+		name, _, value, ok := splitProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && (strings.EqualFold(value, "STANDARD") || strings.EqualFold(value, "DAYLIGHT")):
+			current = &observance{}
+		case name == "END" && (strings.EqualFold(value, "STANDARD") || strings.EqualFold(value, "DAYLIGHT")):
+			if current != nil && !current.start.IsZero() {
+				vtz.observances = append(vtz.observances, *current)
+			}
+			current = nil
+		case name == "TZID" && current == nil:
+			vtz.tzid = strings.TrimSpace(value)
+		case current == nil:
+		case name == "DTSTART":
+			current.start, _ = parseLocal(value)
+		case name == "TZOFFSETFROM":
+			current.offsetFrom, _ = parseUTCOffset(value)
+		case name == "TZOFFSETTO":
+			current.offsetTo, _ = parseUTCOffset(value)
+		case name == "RRULE":
+			current.rrule = ruleParts(value)
+		case name == "RDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, ok := parseLocal(v); ok {
+					current.rdates = append(current.rdates, t)
+				}
+			}
+		}
+	}
+	return vtz, vtz.tzid != "" && len(vtz.observances) > 0
+}
+
+// coverage returns the first and last year the VTIMEZONE describes. A
+// yearly rule without UNTIL or COUNT describes every later year.
+func (v vtimezone) coverage() (int, int) {
+	first, last := 0, 0
+	for _, o := range v.observances {
+		if first == 0 || o.start.Year() < first {
+			first = o.start.Year()
+		}
+		end := o.start.Year()
+		for _, r := range o.rdates {
+			end = max(end, r.Year())
+		}
+		if o.rrule != nil {
+			if until, ok := parseLocal(o.rrule["UNTIL"]); ok {
+				end = max(end, until.Year())
+			} else if n, err := strconv.Atoi(o.rrule["COUNT"]); err == nil {
+				end = max(end, o.start.Year()+n-1)
+			} else {
+				end = 1<<31 - 1
+			}
+		}
+		last = max(last, end)
+	}
+	return first, last
+}
+
+// onset is when an observance takes effect.
+type onset struct {
+	at   time.Time
+	from int
+	to   int
+}
+
+// onsets returns every observance change up to the end of year, in UTC
+// order.
+func (v vtimezone) onsets(year int) []onset {
+	var out []onset
+	for _, o := range v.observances {
+		add := func(local time.Time) {
+			if local.Year() <= year {
+				out = append(out, onset{at: local.Add(-time.Duration(o.offsetFrom) * time.Second), from: o.offsetFrom, to: o.offsetTo})
+			}
+		}
+		add(o.start)
+		for _, r := range o.rdates {
+			add(r)
+		}
+		if o.rrule == nil || !strings.EqualFold(o.rrule["FREQ"], "YEARLY") {
+			continue
+		}
+		until, hasUntil := parseLocal(o.rrule["UNTIL"])
+		count, _ := strconv.Atoi(o.rrule["COUNT"])
+		for y := o.start.Year() + 1; y <= year; y++ {
+			if count > 0 && y-o.start.Year() >= count {
+				break
+			}
+			local, ok := yearlyOnset(o, y)
+			if !ok {
+				continue
+			}
+			// UNTIL is in UTC (RFC 5545 §3.3.10); compare the onset in UTC.
+			if hasUntil && local.Add(-time.Duration(o.offsetFrom)*time.Second).After(until) {
+				break
+			}
+			add(local)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].at.Before(out[j].at) })
+	return out
+}
+
+// yearlyOnset applies a yearly BYMONTH rule with a BYDAY such as 2SU or -1SU,
+// or a BYMONTHDAY, to year.
+func yearlyOnset(o observance, year int) (time.Time, bool) {
+	month := int(o.start.Month())
+	if v, err := strconv.Atoi(o.rrule["BYMONTH"]); err == nil {
+		month = v
+	}
+	clock := time.Duration(o.start.Hour())*time.Hour + time.Duration(o.start.Minute())*time.Minute + time.Duration(o.start.Second())*time.Second
+	if byDay := strings.ToUpper(o.rrule["BYDAY"]); byDay != "" {
+		wd, ok := weekdays[byDay[max(len(byDay)-2, 0):]]
+		if !ok {
+			return time.Time{}, false
+		}
+		n := 1
+		if len(byDay) > 2 {
+			v, err := strconv.Atoi(byDay[:len(byDay)-2])
+			if err != nil || v == 0 {
+				return time.Time{}, false
+			}
+			n = v
+		}
+		if n > 0 {
+			day := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			day = day.AddDate(0, 0, (int(wd)-int(day.Weekday())+7)%7+7*(n-1))
+			if int(day.Month()) != month {
+				return time.Time{}, false
+			}
+			return day.Add(clock), true
+		}
+		day := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC)
+		day = day.AddDate(0, 0, -((int(day.Weekday())-int(wd)+7)%7 + 7*(-n-1)))
+		if int(day.Month()) != month {
+			return time.Time{}, false
+		}
+		return day.Add(clock), true
+	}
+	dayOfMonth := o.start.Day()
+	if v, err := strconv.Atoi(o.rrule["BYMONTHDAY"]); err == nil && v > 0 {
+		dayOfMonth = v
+	}
+	return time.Date(year, time.Month(month), dayOfMonth, 0, 0, 0, 0, time.UTC).Add(clock), true
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// matches reports whether the VTIMEZONE gives the same UTC offset as loc
+// throughout year.
+func (v vtimezone) matches(loc *time.Location, year int) bool {
+	onsets := v.onsets(year)
+	if len(onsets) == 0 {
+		return true
+	}
+	// Before the first onset, the offset it changes from applies.
+	offset := onsets[0].from
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	next := 0
+	for t := start; t.Before(end); t = t.Add(timezoneSampleStep) {
+		for next < len(onsets) && !onsets[next].at.After(t) {
+			offset = onsets[next].to
+			next++
+		}
+		if _, want := t.In(loc).Zone(); want != offset {
+			return false
+		}
+	}
+	return true
+}
+
+func splitProperty(line string) (string, map[string]string, string, bool) {
+	head, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+	if !ok {
+		return "", nil, "", false
+	}
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value), true
+}
+
+func ruleParts(value string) map[string]string {
+	parts := map[string]string{}
+	for _, part := range strings.Split(value, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			parts[strings.ToUpper(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return parts
+}
+
+// parseLocal parses a DATE or DATE-TIME value as a wall clock time in UTC.
+func parseLocal(value string) (time.Time, bool) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "Z")
+	for _, layout := range []string{"20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseUTCOffset parses a UTC-OFFSET value such as -0500 or +053000 into
+// seconds.
+func parseUTCOffset(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) != 5 && len(value) != 7 {
+		return 0, false
+	}
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(value[1:3])
+	m, err2 := strconv.Atoi(value[3:5])
+	s := 0
+	var err3 error
+	if len(value) == 7 {
+		s, err3 = strconv.Atoi(value[5:7])
+	}
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	return sign * (h*3600 + m*60 + s), true
+}
@@ -0,0 +1,118 @@
+// Package holidays fetches the calendar data that seeds shared calendars,
+// such as a public holiday ICS feed. Sources are URLs; each scheme is
+// served by a Provider, and http, https and file are built in.
+package holidays
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxFeedBytes caps how much calendar data one source may return.
+const maxFeedBytes = 5 << 20
+
+// Provider fetches the iCalendar data at a source URL.
+type Provider interface {
+	Fetch(ctx context.Context, source *url.URL) (string, error)
+}
+
+// ErrUnsupported reports a source whose scheme has no provider.
+var ErrUnsupported = errors.New("holidays: unsupported source")
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"http":  feed{client: &http.Client{Timeout: 30 * time.Second}},
+		"https": feed{client: &http.Client{Timeout: 30 * time.Second}},
+		"file":  file{},
+	}
+)
+
+// Register makes p serve sources with scheme, replacing any provider
+// already registered for it.
+func Register(scheme string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = p
+}
+
+// Supported reports an error unless source is a URL some provider serves.
+func Supported(source string) error {
+	_, _, err := provider(source)
+	return err
+}
+
+// Fetch returns the iCalendar data at source.
+func Fetch(ctx context.Context, source string) (string, error) {
+	u, p, err := provider(source)
+	if err != nil {
+		return "", err
+	}
+	return p.Fetch(ctx, u)
+}
+
+func provider(source string) (*url.URL, Provider, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	mu.RLock()
+	p, ok := providers[u.Scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupported, source)
+	}
+	return u, p, nil
+}
+
+// feed downloads an ICS feed over HTTP.
+type feed struct {
+	client *http.Client
+}
+
+func (f feed) Fetch(ctx context.Context, source *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("holidays: %s answered %s", source.Redacted(), resp.Status)
+	}
+	return readLimited(resp.Body)
+}
+
+// file reads an ICS file from the server's disk.
+type file struct{}
+
+func (file) Fetch(_ context.Context, source *url.URL) (string, error) {
+	f, err := os.Open(source.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readLimited(f)
+}
+
+func readLimited(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFeedBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFeedBytes {
+		return "", fmt.Errorf("holidays: source is larger than %d bytes", maxFeedBytes)
+	}
+	return string(data), nil
+}
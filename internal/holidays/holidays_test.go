@@ -0,0 +1,65 @@
+package holidays
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const newYear = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:new-year-2027\r\nDTSTART;VALUE=DATE:20270101\r\nSUMMARY:New Year's Day\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestFetchBuiltInProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/holidays.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(newYear))
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "holidays.ics")
+	if err := os.WriteFile(name, []byte(newYear), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{srv.URL + "/holidays.ics", "file://" + name} {
+		got, err := Fetch(context.Background(), source)
+		if err != nil || got != newYear {
+			t.Fatalf("Fetch(%q) = %q, %v", source, got, err)
+		}
+	}
+	if _, err := Fetch(context.Background(), srv.URL+"/missing.ics"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Fetch(missing) error = %v", err)
+	}
+}
+
+type staticProvider string
+
+func (p staticProvider) Fetch(context.Context, *url.URL) (string, error) {
+	return string(p), nil
+}
+
+func TestRegisterProvider(t *testing.T) {
+	if err := Supported("example:us"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Supported() before Register = %v", err)
+	}
+	Register("example", staticProvider(newYear))
+	defer func() {
+		mu.Lock()
+		delete(providers, "example")
+		mu.Unlock()
+	}()
+	if err := Supported("example:us"); err != nil {
+		t.Fatalf("Supported() = %v", err)
+	}
+	if got, err := Fetch(context.Background(), "example:us"); err != nil || got != newYear {
+		t.Fatalf("Fetch() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package apirole limits what a REST API credential may do, independently of
+// the DAV shares of the collections it reaches. Each app password has a role
+// (viewer, editor or admin), and a policy table names the role each API route
+// needs, so an automation token can read events but never manage shares.
+package apirole
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Policy maps "METHOD /route/pattern" to the role the route needs, using the
+// patterns the routes were registered with. Prefix entries ending in "/*"
+// cover every method and route below them, the longest matching prefix
+// winning. Routes not listed need a viewer to read and an editor to change
+// anything.
+type Policy map[string]string
+
+// Need returns the role a request for pattern with method needs.
+func (p Policy) Need(method, pattern string) string {
+	if role, ok := p[method+" "+pattern]; ok {
+		return role
+	}
+	longest, need := "", ""
+	for key, role := range p {
+		prefix, ok := strings.CutSuffix(key, "/*")
+		if ok && len(prefix) >= len(longest) && strings.HasPrefix(pattern, prefix+"/") {
+			longest, need = prefix, role
+		}
+	}
+	if need != "" {
+		return need
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return store.APIRoleViewer
+	}
+	return store.APIRoleEditor
+}
+
+// Middleware refuses requests whose credential's role is below what the
+// policy names for the route with 403 Forbidden. It must run after
+// authentication, which records the role.
+func Middleware(policy Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := auth.APIRoleFromContext(r.Context())
+			if role == store.APIRoleAdmin {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Requests that match no route only reach a 404, but are refused
+			// rather than guessed at.
+			need := store.APIRoleAdmin
+			if pattern := routePattern(r); pattern != "" {
+				need = policy.Need(r.Method, pattern)
+			}
+			if !auth.APIRoleAllows(role, need) {
+				http.Error(w, "this app password's "+role+" API role does not allow this request; it needs "+need, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routePattern returns the pattern of the route the request will reach, or
+// "" when none matches. Routing has not finished when middleware runs, so the
+// pattern is looked up from the top-level router, with the path chi routes
+// on.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	return rctx.Routes.Find(chi.NewRouteContext(), r.Method, path)
+}
@@ -3,9 +3,11 @@ package csrf
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/jw6ventures/calcard/internal/config"
 )
@@ -15,10 +17,14 @@ type contextKey struct{}
 const csrfCookieName = "calcard_csrf"
 
 // Middleware issues a CSRF token cookie and validates it on mutating requests.
+// Mutating requests a browser marks as coming from another site are refused
+// before the token is checked.
 func Middleware(cfg *config.Config) func(http.Handler) http.Handler {
 	secure := true
-	if base, err := url.Parse(cfg.BaseURL); err == nil && base.Scheme != "https" {
-		secure = false
+	baseHost := ""
+	if base, err := url.Parse(cfg.BaseURL); err == nil {
+		secure = base.Scheme == "https"
+		baseHost = strings.ToLower(base.Host)
 	}
 
 	return func(next http.Handler) http.Handler {
@@ -45,11 +51,15 @@ func Middleware(cfg *config.Config) func(http.Handler) http.Handler {
 			}
 
 			if isStateChanging(r.Method) {
+				if crossSite(r, baseHost) {
+					http.Error(w, "cross-site request refused", http.StatusForbidden)
+					return
+				}
 				provided := r.Header.Get("X-CSRF-Token")
 				if provided == "" {
 					provided = r.FormValue("_csrf")
 				}
-				if provided == "" || provided != token {
+				if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
 					http.Error(w, "invalid csrf token", http.StatusForbidden)
 					return
 				}
@@ -69,6 +79,29 @@ func TokenFromContext(ctx context.Context) string {
 	return ""
 }
 
+// crossSite reports whether the browser says the request came from another
+// site: by Sec-Fetch-Site, or by an Origin (or, failing that, Referer) naming
+// a host other than the request's or the configured base URL's. Requests
+// without these headers are left to the token check.
+func crossSite(r *http.Request, baseHost string) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+	source := r.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = r.Referer()
+	}
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return true
+	}
+	host := strings.ToLower(u.Host)
+	return host != strings.ToLower(r.Host) && host != baseHost
+}
+
 func generateToken() (string, error) {
 	buf := make([]byte, 32)
 	if _, err := rand.Read(buf); err != nil {
@@ -106,3 +106,37 @@ func TestIsStateChanging(t *testing.T) {
 		t.Fatal("GET should not be state changing")
 	}
 }
+
+func TestMiddlewareRefusesCrossSiteRequestsEvenWithToken(t *testing.T) {
+	cfg := &config.Config{BaseURL: "https://calendar.example.com"}
+	handler := Middleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "no origin", want: http.StatusAccepted},
+		{name: "same host", headers: map[string]string{"Origin": "http://example.com"}, want: http.StatusAccepted},
+		{name: "base url host", headers: map[string]string{"Origin": "https://calendar.example.com"}, want: http.StatusAccepted},
+		{name: "other origin", headers: map[string]string{"Origin": "https://evil.example"}, want: http.StatusForbidden},
+		{name: "other referer", headers: map[string]string{"Referer": "https://evil.example/page"}, want: http.StatusForbidden},
+		{name: "fetch metadata", headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/calendars", nil)
+			req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+			req.Header.Set("X-CSRF-Token", "token")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Package drain tracks whether the server is draining: it has stopped
+// reporting ready and is letting in-flight responses, such as long
+// sync-collection reports, finish before it shuts down.
+package drain
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Drainer records when draining started. A nil Drainer never drains.
+type Drainer struct {
+	once  sync.Once
+	mu    sync.Mutex
+	since time.Time
+	done  chan struct{}
+}
+
+// New returns a Drainer that is not draining.
+func New() *Drainer {
+	return &Drainer{done: make(chan struct{})}
+}
+
+// Drain starts draining and reports whether this call started it.
+func (d *Drainer) Drain() bool {
+	started := false
+	d.once.Do(func() {
+		d.mu.Lock()
+		d.since = time.Now().UTC()
+		d.mu.Unlock()
+		close(d.done)
+		started = true
+	})
+	return started
+}
+
+// Done is closed when draining starts.
+func (d *Drainer) Done() <-chan struct{} {
+	return d.done
+}
+
+// Since returns when draining started, or false when it has not.
+func (d *Drainer) Since() (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.since, !d.since.IsZero()
+}
+
+// Draining reports whether draining has started.
+func (d *Drainer) Draining() bool {
+	_, ok := d.Since()
+	return ok
+}
+
+// Middleware asks clients to close their connection after each response
+// once draining has started, so their next request opens one to a server
+// that is not going away.
+func (d *Drainer) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d.Draining() {
+				w.Header().Set("Connection", "close")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
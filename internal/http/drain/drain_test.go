@@ -0,0 +1,42 @@
+package drain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDrainerClosesConnectionsOnceDraining(t *testing.T) {
+	d := New()
+	handler := d.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header().Get("Connection")
+	}
+
+	if got := serve(); got != "" || d.Draining() {
+		t.Fatalf("before draining: Connection %q, draining %v", got, d.Draining())
+	}
+	if !d.Drain() || d.Drain() {
+		t.Fatal("Drain() should report starting only on the first call")
+	}
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("Done() not closed after Drain()")
+	}
+	if got := serve(); got != "close" {
+		t.Fatalf("while draining: Connection %q, want close", got)
+	}
+
+	var none *Drainer
+	if none.Draining() {
+		t.Fatal("nil Drainer reports draining")
+	}
+	rec := httptest.NewRecorder()
+	none.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Connection") != "" {
+		t.Fatal("nil Drainer closed the connection")
+	}
+}
@@ -0,0 +1,173 @@
+// Package idempotency lets clients retry REST writes safely. A POST or PUT
+// sent with an Idempotency-Key header is applied once; sending it again with
+// the same key replays the first response, so a request retried after a
+// dropped connection does not create a second event.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Header is the request header carrying the key.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader marks a response replayed from an earlier request.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// TTL is how long a key is remembered.
+const TTL = 24 * time.Hour
+
+// claimTimeout is how long a request may run before its key is treated as
+// abandoned, as when a replica stopped mid-request, and may be claimed by a
+// retry.
+const claimTimeout = 10 * time.Minute
+
+// maxKeyLength bounds a key; clients normally send a UUID.
+const maxKeyLength = 255
+
+// Middleware applies POST and PUT requests carrying an Idempotency-Key at
+// most once per key within scope, which returns "" for requests that are
+// not to be tracked. Bodies over maxBodyBytes are refused, since the body
+// is read up front to tell a retry from a different request reusing the
+// key. A retry while the first request is running gets 409 Conflict, and a
+// key reused for a different request 422 Unprocessable Entity. Server
+// errors are not kept, so the client can retry them.
+func Middleware(repo store.IdempotencyKeyRepository, scope func(*http.Request) string, maxBodyBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if key == "" || repo == nil || (r.Method != http.MethodPost && r.Method != http.MethodPut) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			owner := scope(r)
+			if owner == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validKey(key) {
+				http.Error(w, "invalid Idempotency-Key", http.StatusBadRequest)
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+			if err != nil {
+				http.Error(w, "failed to read body", http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > maxBodyBytes {
+				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			fingerprint := fingerprint(r, body)
+			saved, claimed, err := repo.Claim(r.Context(), store.IdempotencyKey{
+				Scope:       owner,
+				Key:         key,
+				Fingerprint: fingerprint,
+				ExpiresAt:   now.Add(TTL),
+			}, now.Add(-claimTimeout))
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !claimed {
+				replay(w, saved, fingerprint)
+				return
+			}
+
+			// The response is kept even when the client has gone, since
+			// that is when it will retry.
+			ctx := context.WithoutCancel(r.Context())
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// A handler that panicked or failed leaves the key free for
+				// the retry.
+				if !completed {
+					_ = repo.Release(ctx, saved.ID)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+			if rec.status >= 500 {
+				return
+			}
+			if err := repo.Complete(ctx, saved.ID, rec.status, rec.headers, rec.body.Bytes()); err == nil {
+				completed = true
+			}
+		})
+	}
+}
+
+// validKey reports whether key is short printable ASCII.
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies the request a key was used with.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay answers a request whose key is already held.
+func replay(w http.ResponseWriter, saved *store.IdempotencyKey, fingerprint string) {
+	switch {
+	case saved.Fingerprint != fingerprint:
+		http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+	case saved.Status == 0:
+		http.Error(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+	default:
+		for name, values := range saved.Headers {
+			w.Header()[name] = values
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(saved.Status)
+		w.Write(saved.Body)
+	}
+}
+
+// recorder passes a response through while keeping a copy to replay.
+type recorder struct {
+	http.ResponseWriter
+	status  int
+	headers map[string][]string
+	body    bytes.Buffer
+	wrote   bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.wrote = true
+		r.status = status
+		r.headers = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
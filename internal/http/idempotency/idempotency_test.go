@@ -0,0 +1,133 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeKeys struct {
+	keys   map[string]*store.IdempotencyKey
+	nextID int64
+}
+
+func (f *fakeKeys) Claim(_ context.Context, key store.IdempotencyKey, staleBefore time.Time) (*store.IdempotencyKey, bool, error) {
+	id := key.Scope + "\x00" + key.Key
+	if held, ok := f.keys[id]; ok && !(held.Status == 0 && held.CreatedAt.Before(staleBefore)) {
+		saved := *held
+		return &saved, false, nil
+	}
+	f.nextID++
+	key.ID, key.CreatedAt = f.nextID, time.Now()
+	f.keys[id] = &key
+	saved := key
+	return &saved, true, nil
+}
+
+func (f *fakeKeys) Complete(_ context.Context, id int64, status int, headers map[string][]string, body []byte) error {
+	for _, k := range f.keys {
+		if k.ID == id {
+			k.Status, k.Headers, k.Body = status, headers, body
+		}
+	}
+	return nil
+}
+
+func (f *fakeKeys) Release(_ context.Context, id int64) error {
+	for name, k := range f.keys {
+		if k.ID == id {
+			delete(f.keys, name)
+		}
+	}
+	return nil
+}
+
+func (f *fakeKeys) DeleteExpired(context.Context) (int64, error) { return 0, nil }
+
+func TestMiddlewareReplaysRetries(t *testing.T) {
+	keys := &fakeKeys{keys: map[string]*store.IdempotencyKey{}}
+	created, failing := 0, true
+	handler := Middleware(keys, func(r *http.Request) string { return r.Header.Get("X-User") }, 1<<10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && failing {
+			failing = false
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		created++
+		w.Header().Set("Location", fmt.Sprintf("/events/%d", created))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, created)
+	}))
+	send := func(path, user, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		if key != "" {
+			req.Header.Set(Header, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send("/events", "1", "k1", `{"summary":"Lunch"}`)
+	retry := send("/events", "1", "k1", `{"summary":"Lunch"}`)
+	if created != 1 || retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("Location") != "/events/1" || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Fatalf("retry = %d %q %v after %d creates", retry.Code, retry.Body.String(), retry.Header(), created)
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first response marked replayed")
+	}
+
+	if rr := send("/events", "1", "k1", `{"summary":"Dinner"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key status = %d, want 422", rr.Code)
+	}
+	if rr := send("/events", "2", "k1", `{"summary":"Lunch"}`); rr.Code != http.StatusCreated || created != 2 {
+		t.Fatalf("another user's key status = %d after %d creates", rr.Code, created)
+	}
+	send("/events", "1", "", `{}`)
+	send("/events", "1", "", `{}`)
+	if created != 4 {
+		t.Fatalf("requests without a key created %d events, want 2 more", created-2)
+	}
+
+	if rr := send("/flaky", "1", "k2", `{}`); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("flaky status = %d", rr.Code)
+	}
+	if rr := send("/flaky", "1", "k2", `{}`); rr.Code != http.StatusCreated || rr.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("retry after a server error = %d %v, want it run again", rr.Code, rr.Header())
+	}
+
+	if rr := send("/events", "1", strings.Repeat("x", 300), `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("long key status = %d, want 400", rr.Code)
+	}
+	if rr := send("/events", "1", "k3", strings.Repeat("x", 2<<10)); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large body status = %d, want 413", rr.Code)
+	}
+}
+
+func TestMiddlewareRefusesConcurrentRetry(t *testing.T) {
+	keys := &fakeKeys{keys: map[string]*store.IdempotencyKey{}}
+	var handler http.Handler
+	var inner *httptest.ResponseRecorder
+	handler = Middleware(keys, func(*http.Request) string { return "user:1" }, 1<<10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The retry arrives while the first request is still running.
+		req := httptest.NewRequest(http.MethodPut, "/events/a", strings.NewReader("{}"))
+		req.Header.Set(Header, "k1")
+		inner = httptest.NewRecorder()
+		handler.ServeHTTP(inner, req)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodPut, "/events/a", strings.NewReader("{}"))
+	req.Header.Set(Header, "k1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if inner.Code != http.StatusConflict {
+		t.Fatalf("concurrent retry status = %d, want 409", inner.Code)
+	}
+}
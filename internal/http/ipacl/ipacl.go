@@ -0,0 +1,170 @@
+// Package ipacl restricts route groups to the client networks and countries
+// configured with the APP_ACL_* settings.
+package ipacl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/logging"
+)
+
+type peerKey struct{}
+
+// RecordPeer remembers the address the connection came from. It must run
+// before middleware.RealIP, which replaces RemoteAddr with whatever the
+// forwarding headers claim, so that those headers are only believed when a
+// trusted proxy sent them.
+func RecordPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerKey{}, r.RemoteAddr)))
+	})
+}
+
+// Peer returns the address the connection came from, as RecordPeer
+// recorded it, or RemoteAddr on a request RecordPeer did not see.
+func Peer(r *http.Request) string {
+	if peer, _ := r.Context().Value(peerKey{}).(string); peer != "" {
+		return peer
+	}
+	return r.RemoteAddr
+}
+
+// Policy admits or refuses the clients of one route group.
+type Policy struct {
+	group          string
+	allow, deny    []*net.IPNet
+	allowCountries map[string]bool
+	denyCountries  map[string]bool
+	countryHeader  string
+	trustedProxies []*net.IPNet
+	logger         *logging.Logger
+}
+
+// New builds the policy for group, which names it in responses and logs,
+// from rules validated by config.Load. Forwarding and country headers are
+// only read from requests sent by one of trustedProxies.
+func New(group string, rules config.NetworkRules, countryHeader string, trustedProxies []string, sink logging.Sink) *Policy {
+	p := &Policy{
+		group:          group,
+		allowCountries: map[string]bool{},
+		denyCountries:  map[string]bool{},
+		countryHeader:  countryHeader,
+		trustedProxies: parseNetworks(trustedProxies),
+		logger:         logging.New(sink, "ipacl"),
+	}
+	for _, entry := range rules.Allow {
+		if config.IsCountryCode(entry) {
+			p.allowCountries[strings.ToUpper(entry)] = true
+		} else {
+			p.allow = append(p.allow, parseNetworks([]string{entry})...)
+		}
+	}
+	for _, entry := range rules.Deny {
+		if config.IsCountryCode(entry) {
+			p.denyCountries[strings.ToUpper(entry)] = true
+		} else {
+			p.deny = append(p.deny, parseNetworks([]string{entry})...)
+		}
+	}
+	return p
+}
+
+// Middleware refuses clients the policy does not admit with 403 Forbidden
+// and logs each refusal. A policy without rules passes every request.
+func (p *Policy) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(p.allow) == 0 && len(p.deny) == 0 && len(p.allowCountries) == 0 && len(p.denyCountries) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, country := p.client(r)
+			if !p.admits(ip, country) {
+				p.logger.Warn("Middleware", "blocked %s %s from %s (country %q) by the %s network rules", r.Method, r.URL.Path, ip, country, p.group)
+				http.Error(w, fmt.Sprintf("access to the %s is not allowed from your network (%s)", p.group, ip), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (p *Policy) admits(ip net.IP, country string) bool {
+	if containsIP(p.deny, ip) || p.denyCountries[country] {
+		return false
+	}
+	if len(p.allow) == 0 && len(p.allowCountries) == 0 {
+		return true
+	}
+	return containsIP(p.allow, ip) || p.allowCountries[country]
+}
+
+// client returns the requesting client's address and upper-case country
+// code. When the connection comes from a trusted proxy, the client is the
+// rightmost X-Forwarded-For entry that is not itself a trusted proxy, and
+// the country is taken from the country header; otherwise the client is the
+// peer and its country is unknown.
+func (p *Policy) client(r *http.Request) (net.IP, string) {
+	peer := parseIP(Peer(r))
+	if !containsIP(p.trustedProxies, peer) {
+		return peer, ""
+	}
+	country := ""
+	if p.countryHeader != "" {
+		country = strings.ToUpper(strings.TrimSpace(r.Header.Get(p.countryHeader)))
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !containsIP(p.trustedProxies, ip) {
+			return ip, country
+		}
+	}
+	return peer, country
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses IPs and CIDRs, treating an IP as a single-address
+// network. Invalid entries are skipped.
+func parseNetworks(values []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, value := range values {
+		if _, n, err := net.ParseCIDR(value); err == nil {
+			networks = append(networks, n)
+			continue
+		}
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return networks
+}
+
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,81 @@
+package ipacl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+func TestPolicyMiddleware(t *testing.T) {
+	rules := config.NetworkRules{Allow: []string{"10.0.0.0/8", "CH"}, Deny: []string{"10.6.6.6"}}
+	policy := New("admin API", rules, "CF-IPCountry", []string{"192.0.2.1"}, nil)
+	handler := RecordPeer(middleware.RealIP(policy.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       int
+	}{
+		{name: "allowed network", remoteAddr: "10.1.2.3:5000", want: http.StatusOK},
+		{name: "denied address", remoteAddr: "10.6.6.6:5000", want: http.StatusForbidden},
+		{name: "outside network", remoteAddr: "203.0.113.9:5000", want: http.StatusForbidden},
+		{
+			name:       "spoofed forwarding header",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string]string{"X-Forwarded-For": "10.1.2.3", "X-Real-IP": "10.1.2.3", "CF-IPCountry": "CH"},
+			want:       http.StatusForbidden,
+		},
+		{
+			name:       "client behind trusted proxy",
+			remoteAddr: "192.0.2.1:443",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9, 10.1.2.3"},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "allowed country behind trusted proxy",
+			remoteAddr: "192.0.2.1:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7", "CF-IPCountry": "ch"},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "other country behind trusted proxy",
+			remoteAddr: "192.0.2.1:443",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7", "CF-IPCountry": "FR"},
+			want:       http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/fsck", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusForbidden && !strings.Contains(rec.Body.String(), "admin API is not allowed from your network") {
+				t.Fatalf("unexpected body %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPolicyWithoutRulesPassesThrough(t *testing.T) {
+	handler := New("DAV service", config.NetworkRules{}, "", nil, nil).Middleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dav/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,54 @@
+// Package listener opens the HTTP listening socket in ways that let a new
+// server binary take over from a running one without refusing connections:
+// by inheriting the socket from a supervisor, or by sharing the port.
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// firstInheritedFD is the first descriptor passed by socket activation.
+const firstInheritedFD = 3
+
+// Listen returns the listener the server accepts connections on. A socket
+// passed by systemd-style socket activation (LISTEN_FDS and LISTEN_PID) is
+// used when there is one. Otherwise a socket is bound to addr, with
+// SO_REUSEPORT when reusePort is set so another server can bind the same
+// port while this one drains.
+func Listen(ctx context.Context, addr string, reusePort bool) (net.Listener, error) {
+	ln, err := inherited(os.Getenv, os.Getpid(), firstInheritedFD)
+	if err != nil || ln != nil {
+		return ln, err
+	}
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = setReusePort
+	}
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// inherited returns the first socket passed by socket activation, or nil
+// when none was passed to this process.
+func inherited(getenv func(string) string, pid, firstFD int) (net.Listener, error) {
+	fds := getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil
+	}
+	if listenPID, err := strconv.Atoi(getenv("LISTEN_PID")); err != nil || listenPID != pid {
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(fds); err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+	f := os.NewFile(uintptr(firstFD), "LISTEN_FD_"+strconv.Itoa(firstFD))
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited socket: %w", err)
+	}
+	return ln, nil
+}
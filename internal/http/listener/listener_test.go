@@ -0,0 +1,67 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package listener
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestInheritedUsesSocketPassedToThisProcess(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+	defer f.Close()
+	// inherited takes ownership of the descriptor, as of one passed by a
+	// supervisor, so hand it a copy.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("Dup() error = %v", err)
+	}
+	env := func(pid int) func(string) string {
+		return func(key string) string {
+			return map[string]string{"LISTEN_FDS": "1", "LISTEN_PID": strconv.Itoa(pid)}[key]
+		}
+	}
+
+	if got, err := inherited(env(41), 42, fd); got != nil || err != nil {
+		t.Fatalf("inherited() for another process = %v, %v", got, err)
+	}
+	got, err := inherited(env(42), 42, fd)
+	if err != nil {
+		t.Fatalf("inherited() error = %v", err)
+	}
+	defer got.Close()
+	if got.Addr().String() != ln.Addr().String() {
+		t.Fatalf("inherited address %s, want %s", got.Addr(), ln.Addr())
+	}
+	if got, err := inherited(func(string) string { return "" }, 42, fd); got != nil || err != nil {
+		t.Fatalf("inherited() without LISTEN_FDS = %v, %v", got, err)
+	}
+}
+
+func TestListenReusePortSharesThePort(t *testing.T) {
+	first, err := Listen(context.Background(), "127.0.0.1:0", true)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer first.Close()
+	second, err := Listen(context.Background(), first.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("second Listen() on %s error = %v", first.Addr(), err)
+	}
+	second.Close()
+	if ln, err := Listen(context.Background(), first.Addr().String(), false); err == nil {
+		ln.Close()
+		t.Fatal("Listen() without reuse bound a port in use")
+	}
+}
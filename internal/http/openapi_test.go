@@ -0,0 +1,90 @@
+package httpserver
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// pathParam matches a path parameter; names differ between the router and
+// the spec, so only their positions are compared.
+var pathParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// TestOpenAPIMatchesRoutes keeps docs/openapi.yaml in step with the REST
+// API: every /api route must be documented and every documented operation
+// routed.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	routes, ok := NewRouter(&config.Config{BaseURL: "http://localhost:8080"}, store.New(db), nil).(chi.Routes)
+	if !ok {
+		t.Fatal("NewRouter() does not expose its routes")
+	}
+	routed := make(map[string]bool)
+	err = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") {
+			routed[operationKey(method, route)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chi.Walk() error = %v", err)
+	}
+
+	raw, err := os.ReadFile("../../docs/openapi.yaml")
+	if err != nil {
+		t.Fatalf("read spec: %v", err)
+	}
+	var spec struct {
+		Paths map[string]map[string]yaml.Node `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(raw, &spec); err != nil {
+		t.Fatalf("parse spec: %v", err)
+	}
+	documented := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "patch", "delete":
+				documented[operationKey(method, path)] = true
+			}
+		}
+	}
+
+	var missing, stale []string
+	for op := range routed {
+		if !documented[op] {
+			missing = append(missing, op)
+		}
+	}
+	for op := range documented {
+		if !routed[op] {
+			stale = append(stale, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 {
+		t.Errorf("routes missing from docs/openapi.yaml:\n%s", strings.Join(missing, "\n"))
+	}
+	if len(stale) > 0 {
+		t.Errorf("documented operations with no route:\n%s", strings.Join(stale, "\n"))
+	}
+}
+
+func operationKey(method, path string) string {
+	return strings.ToUpper(method) + " " + pathParam.ReplaceAllString(strings.TrimSuffix(path, "/"), "{}")
+}
@@ -0,0 +1,27 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// protocolRoutes registers routes that only serve one half of the server,
+// calendars or address books. When the configuration turns that half off
+// they are not registered at all, so the web interface, REST API and DAV
+// tree look as if it was never there.
+func protocolRoutes(r chi.Router, enabled bool, routes func(r chi.Router)) {
+	if enabled {
+		r.Group(routes)
+	}
+}
+
+// hideDAVTree answers 404 for a collection tree of the DAV router, such as
+// /calendars, when its protocol is turned off. The rest of the tree is
+// served by catch-all routes, so it has to be hidden by name.
+func hideDAVTree(r chi.Router, enabled bool, prefix string) {
+	if !enabled {
+		r.Handle(prefix, http.NotFoundHandler())
+		r.Handle(prefix+"/*", http.NotFoundHandler())
+	}
+}
@@ -1,10 +1,13 @@
 package ratelimit
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -19,8 +22,30 @@ type IPRateLimiter struct {
 	cleanup        time.Duration
 	maxEntries     int
 	trustedProxies []*net.IPNet
+
+	// shared counts requests for every replica when set; see Share.
+	shared     Counter
+	sharedName string
+	// sharedDownUntil holds the Unix nanoseconds until which shared is
+	// skipped after it failed.
+	sharedDownUntil atomic.Int64
+}
+
+// Counter counts requests in fixed windows shared by every replica;
+// *redis.Client implements it.
+type Counter interface {
+	IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error)
 }
 
+const (
+	// sharedTimeout bounds each shared count, so a slow Redis delays
+	// requests only briefly.
+	sharedTimeout = 200 * time.Millisecond
+	// sharedRetry is how long the limiter keeps to its own count after
+	// the shared counter fails.
+	sharedRetry = 10 * time.Second
+)
+
 type limiterEntry struct {
 	limiter    *rate.Limiter
 	lastAccess time.Time
@@ -93,6 +118,53 @@ func (l *IPRateLimiter) getLimiter(ip string) *rate.Limiter {
 	return entry.limiter
 }
 
+// Share makes replicas enforce one limit per client between them by
+// counting requests in counter under name. Shared counts use fixed windows
+// of burst/rate, each allowing burst requests, which averages to the same
+// rate. While counter fails, each replica falls back to its own limit.
+func (l *IPRateLimiter) Share(counter Counter, name string) *IPRateLimiter {
+	if counter != nil && l.rate > 0 && l.rate != rate.Inf {
+		l.shared = counter
+		l.sharedName = name
+	}
+	return l
+}
+
+// SetLimit changes the rate and burst, for clients already seen as well as
+// new ones, so a reloaded configuration applies at once.
+func (l *IPRateLimiter) SetLimit(r rate.Limit, b int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = r, b
+	for _, entry := range l.limiters {
+		entry.limiter.SetLimit(r)
+		entry.limiter.SetBurst(b)
+	}
+}
+
+// Allow reports whether a request from ip may proceed now, for callers
+// outside HTTP such as the LDAP listener.
+func (l *IPRateLimiter) Allow(ip string) bool {
+	if l.shared != nil && time.Now().UnixNano() >= l.sharedDownUntil.Load() {
+		l.mu.RLock()
+		r, burst := l.rate, l.burst
+		l.mu.RUnlock()
+		window := time.Duration(float64(burst) / float64(r) * float64(time.Second))
+		if window < time.Millisecond {
+			window = time.Millisecond
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+		defer cancel()
+		key := "calcard:ratelimit:" + l.sharedName + ":" + ip + ":" + strconv.FormatInt(time.Now().UnixNano()/int64(window), 10)
+		n, err := l.shared.IncrWindow(ctx, key, window)
+		if err == nil {
+			return n <= int64(burst)
+		}
+		l.sharedDownUntil.Store(time.Now().Add(sharedRetry).UnixNano())
+	}
+	return l.getLimiter(ip).Allow()
+}
+
 func (l *IPRateLimiter) evictOldest() {
 	var oldestIP string
 	var oldestTime time.Time
@@ -129,10 +201,7 @@ func (l *IPRateLimiter) cleanupStale() {
 func (l *IPRateLimiter) Middleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := l.getClientIP(r)
-			limiter := l.getLimiter(ip)
-
-			if !limiter.Allow() {
+			if !l.Allow(l.getClientIP(r)) {
 				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
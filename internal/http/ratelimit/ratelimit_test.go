@@ -1,6 +1,8 @@
 package ratelimit
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -90,3 +92,52 @@ func TestParseIP(t *testing.T) {
 		t.Fatalf("parseIP() = %q", got)
 	}
 }
+
+type fakeCounter struct {
+	counts map[string]int64
+	err    error
+}
+
+func (f *fakeCounter) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.counts[key]++
+	return f.counts[key], nil
+}
+
+func TestSharedLimiterCountsAcrossReplicasAndFallsBack(t *testing.T) {
+	counter := &fakeCounter{counts: map[string]int64{}}
+	a := NewIPRateLimiter(rate.Limit(1), 2, time.Hour, nil).Share(counter, "dav")
+	b := NewIPRateLimiter(rate.Limit(1), 2, time.Hour, nil).Share(counter, "dav")
+
+	if !a.Allow("198.51.100.1") || !b.Allow("198.51.100.1") {
+		t.Fatal("first two requests across replicas were refused")
+	}
+	if b.Allow("198.51.100.1") {
+		t.Fatal("third request within the window was allowed")
+	}
+	if !a.Allow("198.51.100.2") {
+		t.Fatal("another client was refused")
+	}
+
+	counter.err = errors.New("connection refused")
+	c := NewIPRateLimiter(rate.Limit(1), 1, time.Hour, nil).Share(counter, "dav")
+	if !c.Allow("198.51.100.3") || c.Allow("198.51.100.3") {
+		t.Fatal("limiter did not fall back to its own limit")
+	}
+}
+
+func TestSetLimitAppliesToSeenAndNewClients(t *testing.T) {
+	limiter := NewIPRateLimiter(rate.Limit(0.001), 5, time.Hour, nil)
+	if !limiter.Allow("198.51.100.1") {
+		t.Fatal("first request refused")
+	}
+
+	limiter.SetLimit(rate.Limit(0.001), 1)
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		if !limiter.Allow(ip) || limiter.Allow(ip) {
+			t.Fatalf("%s was not held to the new burst of 1", ip)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package httpserver
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,12 +12,22 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"golang.org/x/time/rate"
 
+	"github.com/jw6ventures/calcard/internal/activesync"
 	"github.com/jw6ventures/calcard/internal/api"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/backup"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/fsck"
+	"github.com/jw6ventures/calcard/internal/http/apirole"
 	"github.com/jw6ventures/calcard/internal/http/csrf"
+	"github.com/jw6ventures/calcard/internal/http/drain"
+	"github.com/jw6ventures/calcard/internal/http/headers"
+	"github.com/jw6ventures/calcard/internal/http/idempotency"
+	"github.com/jw6ventures/calcard/internal/http/ipacl"
 	"github.com/jw6ventures/calcard/internal/http/ratelimit"
+	"github.com/jw6ventures/calcard/internal/jobs"
 	"github.com/jw6ventures/calcard/internal/logging"
 	"github.com/jw6ventures/calcard/internal/metrics"
 	"github.com/jw6ventures/calcard/internal/store"
@@ -44,6 +55,29 @@ var registeredDAVMethods = struct {
 	"ACL":              {},
 }}
 
+// apiRolePolicy names the REST API routes an app password needs more than the
+// default role for: reads need a viewer and changes an editor, while routes
+// that share data with other people or manage accounts need an admin.
+var apiRolePolicy = apirole.Policy{
+	"POST /api/addressbooks/{id}/shares":              store.APIRoleAdmin,
+	"DELETE /api/addressbooks/{id}/shares/{userId}":   store.APIRoleAdmin,
+	"POST /api/calendars/{id}/events/{uid}/links":     store.APIRoleAdmin,
+	"PUT /api/calendars/{id}/conference-hook":         store.APIRoleAdmin,
+	"DELETE /api/calendars/{id}/conference-hook":      store.APIRoleAdmin,
+	"DELETE /api/event-links/{linkId}":                store.APIRoleAdmin,
+	"POST /api/groups":                                store.APIRoleAdmin,
+	"DELETE /api/groups/{id}":                         store.APIRoleAdmin,
+	"PUT /api/groups/{id}/members/{userId}":           store.APIRoleAdmin,
+	"DELETE /api/groups/{id}/members/{userId}":        store.APIRoleAdmin,
+	"GET /api/devices":                                store.APIRoleAdmin,
+	"POST /api/devices/{id}/revoke":                   store.APIRoleAdmin,
+	"POST /api/devices/{id}/rotate":                   store.APIRoleAdmin,
+	"GET /api/auth-events":                            store.APIRoleAdmin,
+	"POST /api/preferences/alarm-providers":           store.APIRoleAdmin,
+	"POST /api/preferences/alarm-providers/{id}/test": store.APIRoleAdmin,
+	"/api/admin/*":                                    store.APIRoleAdmin,
+}
+
 func init() {
 	for _, method := range []string{
 		"PROPFIND",
@@ -68,6 +102,42 @@ type RouterOptions struct {
 	// Logger is the leveled log sink handed to the DAV server. A nil sink
 	// disables DAV logging.
 	Logger logging.Sink
+	// Backups serves the admin backup endpoints; nil reports backups as disabled.
+	Backups *backup.Service
+	// Fsck serves the admin consistency check endpoints.
+	Fsck *fsck.Service
+	// Reloader serves the admin configuration reload endpoint, and the
+	// router's handlers apply the configurations it reloads.
+	Reloader *config.Reloader
+	// Jobs runs imports and backups in the background; nil disables the job
+	// endpoints and runs backups untracked.
+	Jobs *jobs.Runner
+	// Drainer fails /readyz and closes connections once the server drains,
+	// and serves the admin drain endpoint.
+	Drainer *drain.Drainer
+	// Migrations serves the admin migration endpoints; nil leaves them
+	// unavailable.
+	Migrations api.Migrator
+	// RateLimits shares the auth and DAV rate limits between replicas; nil
+	// limits each replica on its own.
+	RateLimits ratelimit.Counter
+}
+
+// authRateLimit and davRateLimit return the per-client rate and burst of
+// cfg, or the defaults when they are unset, as in configurations built
+// without config.Load.
+func authRateLimit(cfg *config.Config) (rate.Limit, int) {
+	if cfg.RateLimits.AuthRate <= 0 || cfg.RateLimits.AuthBurst <= 0 {
+		return rate.Limit(5), 10
+	}
+	return rate.Limit(cfg.RateLimits.AuthRate), cfg.RateLimits.AuthBurst
+}
+
+func davRateLimit(cfg *config.Config) (rate.Limit, int) {
+	if cfg.RateLimits.DAVRate <= 0 || cfg.RateLimits.DAVBurst <= 0 {
+		return rate.Limit(20), 50
+	}
+	return rate.Limit(cfg.RateLimits.DAVRate), cfg.RateLimits.DAVBurst
 }
 
 // NewRouter wires all HTTP routes for UI and DAV endpoints.
@@ -79,16 +149,31 @@ func NewRouter(cfg *config.Config, store *store.Store, authService *auth.Service
 func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *auth.Service, opts RouterOptions) http.Handler {
 	r := chi.NewRouter()
 
-	// Auth endpoints: 5 requests per second, burst of 10
-	authRateLimiter := ratelimit.NewIPRateLimiter(rate.Limit(5), 10, 5*time.Minute, cfg.TrustedProxies)
-	// DAV endpoints: 20 requests per second, burst of 50 (more permissive for sync clients)
-	davRateLimiter := ratelimit.NewIPRateLimiter(rate.Limit(20), 50, 5*time.Minute, cfg.TrustedProxies)
+	// Auth endpoints: 5 requests per second, burst of 10 by default
+	authLimit, authBurst := authRateLimit(cfg)
+	authRateLimiter := ratelimit.NewIPRateLimiter(authLimit, authBurst, 5*time.Minute, cfg.TrustedProxies).Share(opts.RateLimits, "auth")
+	// DAV endpoints: 20 requests per second, burst of 50 by default (more permissive for sync clients)
+	davLimit, davBurst := davRateLimit(cfg)
+	davRateLimiter := ratelimit.NewIPRateLimiter(davLimit, davBurst, 5*time.Minute, cfg.TrustedProxies).Share(opts.RateLimits, "dav")
+
+	// Network rules per route group. RecordPeer keeps the connection's own
+	// address before RealIP rewrites it from client-supplied headers.
+	acl := func(group string, rules config.NetworkRules) func(http.Handler) http.Handler {
+		return ipacl.New(group, rules, cfg.Network.CountryHeader, cfg.TrustedProxies, opts.Logger).Middleware()
+	}
+	adminACL := acl("admin API", cfg.Network.Admin)
+	apiACL := acl("REST API", cfg.Network.API)
+	davACL := acl("DAV service", cfg.Network.DAV)
+	uiACL := acl("web interface", cfg.Network.UI)
 
+	r.Use(ipacl.RecordPeer)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(overrideMethod)
 	r.Use(metrics.Middleware())
+	r.Use(databaseUnavailable)
+	r.Use(opts.Drainer.Middleware())
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -96,6 +181,10 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 	})
 
 	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if opts.Drainer.Draining() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 
@@ -118,11 +207,25 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 	wellKnownHandler := func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/dav/", http.StatusMovedPermanently)
 	}
-	r.Get("/.well-known/caldav", wellKnownHandler)
-	r.MethodFunc("PROPFIND", "/.well-known/caldav", wellKnownHandler)
+	// Routes that only serve calendars or only address books go in these
+	// groups, which are left out when the configuration turns the protocol off.
+	calendarRoutes := func(r chi.Router, routes func(r chi.Router)) {
+		protocolRoutes(r, !cfg.CalDAVDisabled, routes)
+	}
+	contactRoutes := func(r chi.Router, routes func(r chi.Router)) {
+		protocolRoutes(r, !cfg.CardDAVDisabled, routes)
+	}
 
-	r.Get("/.well-known/carddav", wellKnownHandler)
-	r.MethodFunc("PROPFIND", "/.well-known/carddav", wellKnownHandler)
+	calendarRoutes(r, func(r chi.Router) {
+		r.Get("/.well-known/caldav", wellKnownHandler)
+		r.MethodFunc("PROPFIND", "/.well-known/caldav", wellKnownHandler)
+		// Redirect Apple-specific legacy path to /dav/
+		r.MethodFunc("PROPFIND", "/calendar/*", wellKnownHandler)
+	})
+	contactRoutes(r, func(r chi.Router) {
+		r.Get("/.well-known/carddav", wellKnownHandler)
+		r.MethodFunc("PROPFIND", "/.well-known/carddav", wellKnownHandler)
+	})
 
 	// Redirect root PROPFIND to /dav/ for discovery
 	r.MethodFunc("PROPFIND", "/", wellKnownHandler)
@@ -133,73 +236,109 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 	}
 	r.MethodFunc("PROPFIND", "/principals/*", principalsRedirectHandler)
 
-	// Redirect Apple-specific legacy path to /dav/
-	r.MethodFunc("PROPFIND", "/calendar/*", wellKnownHandler)
-
 	uiHandler := ui.NewHandler(cfg, store, authService)
 	apiHandler := api.NewHandler(cfg, store)
+	apiHandler.SetBackups(opts.Backups)
+	apiHandler.SetFsck(opts.Fsck)
+	apiHandler.SetAuthService(authService)
+	apiHandler.SetJobs(opts.Jobs)
+	apiHandler.SetDrainer(opts.Drainer)
+	apiHandler.SetMigrator(opts.Migrations)
+	// Browser pages get security headers; DAV and the REST API, whose
+	// clients authenticate with Basic auth, do not.
+	securityHeaders := headers.Middleware()
 	r.Route("/auth", func(r chi.Router) {
+		r.Use(uiACL)
 		r.Use(authRateLimiter.Middleware())
+		r.Use(securityHeaders)
 		r.Get("/login", authService.BeginOAuth)
 		r.Get("/callback", authService.HandleOAuthCallback)
 	})
 
-	r.With(authService.RequireSession, csrf.Middleware(cfg)).Post("/auth/logout", uiHandler.Logout)
+	r.With(uiACL, securityHeaders, authService.RequireSession, csrf.Middleware(cfg)).Post("/auth/logout", uiHandler.Logout)
 
 	r.Group(func(r chi.Router) {
+		r.Use(uiACL)
+		r.Use(securityHeaders)
 		r.Use(authService.RequireSession)
 		r.Use(csrf.Middleware(cfg))
 		r.Get("/", uiHandler.Dashboard)
-		r.Get("/calendars", uiHandler.Calendars)
-		r.Get("/calendars/all", uiHandler.ViewAllCalendars)
-		r.Get("/calendars/all/events.json", uiHandler.GetAllCalendarEventsJSON)
-		r.Get("/calendars/{id}", uiHandler.ViewCalendar)
-		r.Get("/calendars/{id}/export", uiHandler.ExportCalendar)
-		r.Get("/calendars/{id}/events.json", uiHandler.GetCalendarEventsJSON)
-		r.Get("/addressbooks", uiHandler.AddressBooks)
-		r.Get("/addressbooks/{id}", uiHandler.ViewAddressBook)
 		r.Get("/app-passwords", uiHandler.AppPasswords)
+		r.Get("/email-aliases/verify", uiHandler.VerifyEmailAlias)
 		r.Get("/sessions", uiHandler.Sessions)
-		r.Get("/birthdays", uiHandler.ViewBirthdays)
 		r.Get("/help", uiHandler.Help)
+		r.With(adminACL).Get("/admin/usage", uiHandler.Usage)
+
+		calendarRoutes(r, func(r chi.Router) {
+			r.Get("/calendars", uiHandler.Calendars)
+			r.Get("/calendars/all", uiHandler.ViewAllCalendars)
+			r.Get("/calendars/all/events.json", uiHandler.GetAllCalendarEventsJSON)
+			r.Get("/calendars/{id}", uiHandler.ViewCalendar)
+			r.Get("/calendars/{id}/export", uiHandler.ExportCalendar)
+			r.Get("/calendars/{id}/events.json", uiHandler.GetCalendarEventsJSON)
+			r.Get("/booking-pages", uiHandler.BookingPages)
+			r.Get("/journal", uiHandler.Journal)
+
+			r.Post("/calendars", uiHandler.CreateCalendar)
+			r.Put("/calendars/{id}", uiHandler.RenameCalendar)
+			r.Delete("/calendars/{id}", uiHandler.DeleteCalendar)
+			r.Post("/calendars/{id}/shares", uiHandler.ShareCalendar)
+			r.Delete("/calendars/{id}/shares/{userId}", uiHandler.UnshareCalendar)
+			r.Post("/calendars/{id}/shares/{userId}/delete", uiHandler.UnshareCalendar) // HTML form fallback
+			r.Delete("/calendars/{id}/group-shares/{groupId}", uiHandler.UnshareCalendarGroup)
+			r.Post("/calendars/{id}/group-shares/{groupId}/delete", uiHandler.UnshareCalendarGroup) // HTML form fallback
+
+			// Calendar import
+			r.Post("/calendars/{id}/import", uiHandler.ImportCalendar)
+
+			// Event CRUD
+			r.Post("/calendars/{id}/events", uiHandler.CreateEvent)
+			r.Put("/calendars/{id}/events/{uid}", uiHandler.UpdateEvent)
+			r.Delete("/calendars/{id}/events/{uid}", uiHandler.DeleteEvent)
+			r.Post("/calendars/{id}/events/{uid}/delete", uiHandler.DeleteEvent) // HTML form fallback
+
+			r.Post("/freebusy-link", uiHandler.UpdateFreeBusyLink)
+			r.Post("/freebusy-link/delete", uiHandler.DeleteFreeBusyLink)
+			r.Post("/task-feed-link", uiHandler.UpdateTaskFeedLink)
+			r.Post("/task-feed-link/delete", uiHandler.DeleteTaskFeedLink)
+			r.Post("/booking-pages", uiHandler.CreateBookingPage)
+			r.Post("/booking-pages/{id}/delete", uiHandler.DeleteBookingPage)
+			r.Post("/journal", uiHandler.CreateJournal)
+		})
 
-		r.Post("/calendars", uiHandler.CreateCalendar)
-		r.Put("/calendars/{id}", uiHandler.RenameCalendar)
-		r.Delete("/calendars/{id}", uiHandler.DeleteCalendar)
-		r.Post("/calendars/{id}/shares", uiHandler.ShareCalendar)
-		r.Delete("/calendars/{id}/shares/{userId}", uiHandler.UnshareCalendar)
-		r.Post("/calendars/{id}/shares/{userId}/delete", uiHandler.UnshareCalendar) // HTML form fallback
-
-		// Calendar import
-		r.Post("/calendars/{id}/import", uiHandler.ImportCalendar)
-
-		// Event CRUD
-		r.Post("/calendars/{id}/events", uiHandler.CreateEvent)
-		r.Put("/calendars/{id}/events/{uid}", uiHandler.UpdateEvent)
-		r.Delete("/calendars/{id}/events/{uid}", uiHandler.DeleteEvent)
-		r.Post("/calendars/{id}/events/{uid}/delete", uiHandler.DeleteEvent) // HTML form fallback
-
-		r.Post("/addressbooks", uiHandler.CreateAddressBook)
-		r.Put("/addressbooks/{id}", uiHandler.RenameAddressBook)
-		r.Delete("/addressbooks/{id}", uiHandler.DeleteAddressBook)
-		r.Post("/addressbooks/{id}/shares", uiHandler.ShareAddressBook)
-		r.Delete("/addressbooks/{id}/shares/{userId}", uiHandler.UnshareAddressBook)
-		r.Post("/addressbooks/{id}/shares/{userId}/delete", uiHandler.UnshareAddressBook) // HTML form fallback
-
-		// Address book import
-		r.Post("/addressbooks/{id}/import", uiHandler.ImportAddressBook)
-
-		// Contact CRUD
-		r.Post("/addressbooks/{id}/contacts", uiHandler.CreateContact)
-		r.Put("/addressbooks/{id}/contacts/{uid}", uiHandler.UpdateContact)
-		r.Delete("/addressbooks/{id}/contacts/{uid}", uiHandler.DeleteContact)
-		r.Post("/addressbooks/{id}/contacts/{uid}/delete", uiHandler.DeleteContact) // HTML form fallback
-		r.Post("/addressbooks/{id}/contacts/{uid}/move", uiHandler.MoveContact)     // Move contact to another address book
+		contactRoutes(r, func(r chi.Router) {
+			r.Get("/addressbooks", uiHandler.AddressBooks)
+			r.Get("/addressbooks/{id}", uiHandler.ViewAddressBook)
+			r.Get("/birthdays", uiHandler.ViewBirthdays)
+
+			r.Post("/addressbooks", uiHandler.CreateAddressBook)
+			r.Put("/addressbooks/{id}", uiHandler.RenameAddressBook)
+			r.Delete("/addressbooks/{id}", uiHandler.DeleteAddressBook)
+			r.Post("/addressbooks/{id}/shares", uiHandler.ShareAddressBook)
+			r.Delete("/addressbooks/{id}/shares/{userId}", uiHandler.UnshareAddressBook)
+			r.Post("/addressbooks/{id}/shares/{userId}/delete", uiHandler.UnshareAddressBook) // HTML form fallback
+
+			// Address book import
+			r.Post("/addressbooks/{id}/import", uiHandler.ImportAddressBook)
+
+			// Contact CRUD
+			r.Post("/addressbooks/{id}/contacts", uiHandler.CreateContact)
+			r.Put("/addressbooks/{id}/contacts/{uid}", uiHandler.UpdateContact)
+			r.Delete("/addressbooks/{id}/contacts/{uid}", uiHandler.DeleteContact)
+			r.Post("/addressbooks/{id}/contacts/{uid}/delete", uiHandler.DeleteContact) // HTML form fallback
+			r.Post("/addressbooks/{id}/contacts/{uid}/move", uiHandler.MoveContact)     // Move contact to another address book
+		})
 
 		r.Post("/app-passwords", uiHandler.CreateAppPassword)
 		r.Delete("/app-passwords/{id}", uiHandler.RevokeAppPassword)
 		r.Post("/app-passwords/{id}/revoke", uiHandler.RevokeAppPassword)
 		r.Post("/app-passwords/{id}/delete", uiHandler.DeleteAppPassword)
+		r.Post("/email-aliases", uiHandler.AddEmailAlias)
+		r.Post("/email-aliases/{id}/delete", uiHandler.DeleteEmailAlias)
+		r.Post("/sync-preferences", uiHandler.UpdateSyncPreferences)
+		r.Post("/preferences", uiHandler.UpdatePreferences)
+		r.Post("/held-deletions/confirm", uiHandler.ConfirmHeldDeletions)
+		r.Post("/held-deletions/release", uiHandler.ReleaseHeldDeletions)
 
 		r.Post("/sessions/{id}/revoke", uiHandler.RevokeSession)
 		r.Post("/sessions/revoke-all", uiHandler.RevokeAllSessions)
@@ -207,31 +346,173 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 		r.Post("/onboarding/complete", uiHandler.CompleteOnboarding)
 	})
 
+	// Writes retried with the same Idempotency-Key are applied once: per
+	// user on the REST API, and for anyone on the public booking form.
+	apiIdempotency := idempotency.Middleware(store.IdempotencyKeys, func(r *http.Request) string {
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			return "user:" + strconv.FormatInt(user.ID, 10)
+		}
+		return ""
+	}, events.MaxBodyBytes)
+	bookingIdempotency := idempotency.Middleware(store.IdempotencyKeys, func(*http.Request) string { return "booking" }, 1<<20)
+
+	// Uptime monitors call the synthetic probe with its own token rather
+	// than an account's credentials.
+	r.With(apiACL, authRateLimiter.Middleware()).Get("/api/probe", apiHandler.RunProbe)
+
 	r.Route("/api", func(r chi.Router) {
+		r.Use(apiACL)
 		r.Use(davRateLimiter.Middleware())
+		r.Use(authService.RequireSecureTransport)
 		r.Use(authService.RequireDAVAuth)
-		r.Get("/calendars", apiHandler.ListCalendars)
-		r.Get("/calendars/{id}", apiHandler.GetCalendar)
-		r.Get("/calendars/{id}/events", apiHandler.ListEvents)
-		r.Get("/calendars/{id}/events/{uid}", apiHandler.GetEvent)
-		r.Post("/calendars/{id}/events", apiHandler.CreateEvent)
-		r.Put("/calendars/{id}/events/{uid}", apiHandler.UpdateEvent)
-		r.Delete("/calendars/{id}/events/{uid}", apiHandler.DeleteEvent)
-
-		r.Get("/addressbooks", apiHandler.ListAddressBooks)
-		r.Get("/addressbooks/{id}", apiHandler.GetAddressBook)
-		r.Get("/addressbooks/{id}/shares", apiHandler.ListAddressBookShares)
-		r.Post("/addressbooks/{id}/shares", apiHandler.ShareAddressBook)
-		r.Delete("/addressbooks/{id}/shares/{userId}", apiHandler.UnshareAddressBook)
-		r.Get("/addressbooks/{id}/contacts", apiHandler.ListContacts)
-		r.Get("/addressbooks/{id}/contacts/{uid}", apiHandler.GetContact)
-		r.Post("/addressbooks/{id}/contacts", apiHandler.CreateContact)
-		r.Put("/addressbooks/{id}/contacts/{uid}", apiHandler.UpdateContact)
-		r.Delete("/addressbooks/{id}/contacts/{uid}", apiHandler.DeleteContact)
+		r.Use(apirole.Middleware(apiRolePolicy))
+		r.Use(apiIdempotency)
+		calendarRoutes(r, func(r chi.Router) {
+			r.Get("/calendars", apiHandler.ListCalendars)
+			r.Get("/calendars/{id}", apiHandler.GetCalendar)
+			r.Post("/calendars/{id}/merge", apiHandler.MergeCalendar)
+			r.Post("/calendars/{id}/split", apiHandler.SplitCalendar)
+			r.Post("/calendars/{id}/duplicate", apiHandler.DuplicateCalendar)
+			r.Post("/calendars/{id}/import", apiHandler.ImportCalendar)
+			r.Get("/calendars/{id}/conference-hook", apiHandler.GetConferenceHook)
+			r.Put("/calendars/{id}/conference-hook", apiHandler.SetConferenceHook)
+			r.Delete("/calendars/{id}/conference-hook", apiHandler.DeleteConferenceHook)
+			r.Get("/calendars/{id}/retention", apiHandler.GetRetentionPolicy)
+			r.Put("/calendars/{id}/retention", apiHandler.SetRetentionPolicy)
+			r.Delete("/calendars/{id}/retention", apiHandler.DeleteRetentionPolicy)
+			r.Get("/calendars/{id}/retention/deletions", apiHandler.ListRetentionDeletions)
+			r.Get("/calendars/{id}/as-of", apiHandler.GetCalendarAsOf)
+			r.Get("/calendars/{id}/resource", apiHandler.GetSchedulingResource)
+			r.Put("/calendars/{id}/resource", apiHandler.SetSchedulingResource)
+			r.Delete("/calendars/{id}/resource", apiHandler.DeleteSchedulingResource)
+			r.Get("/calendars/{id}/events", apiHandler.ListEvents)
+			r.Get("/calendars/{id}/events/{uid}", apiHandler.GetEvent)
+			r.Get("/calendars/{id}/events/{uid}/instances", apiHandler.ListEventInstances)
+			r.Post("/calendars/{id}/events", apiHandler.CreateEvent)
+			r.Put("/calendars/{id}/events/{uid}", apiHandler.UpdateEvent)
+			r.Delete("/calendars/{id}/events/{uid}", apiHandler.DeleteEvent)
+			r.Post("/calendars/{id}/events/batch-delete", apiHandler.BatchDeleteEvents)
+			r.Post("/calendars/{id}/events/shift", apiHandler.ShiftEvents)
+			r.Get("/calendars/{id}/events/{uid}/links", apiHandler.ListEventLinks)
+			r.Post("/calendars/{id}/events/{uid}/links", apiHandler.CreateEventLink)
+			r.Post("/calendars/{id}/events/{uid}/alarms/{alarmId}/acknowledge", apiHandler.AcknowledgeAlarm)
+			r.Post("/calendars/{id}/events/{uid}/alarms/{alarmId}/snooze", apiHandler.SnoozeAlarm)
+			r.Get("/calendars/{id}/journals", apiHandler.ListJournals)
+			r.Post("/calendars/{id}/journals", apiHandler.CreateJournal)
+			r.Delete("/event-links/{linkId}", apiHandler.RevokeEventLink)
+			r.Post("/uids", apiHandler.IssueUIDs)
+			r.Get("/agenda", apiHandler.Agenda)
+			r.Get("/availability", apiHandler.Availability)
+			r.Get("/agenda.txt", apiHandler.AgendaText)
+			r.Get("/duplicates", apiHandler.ListDuplicates)
+			r.Post("/duplicates/cleanup", apiHandler.CleanupDuplicates)
+			r.Get("/preferences/digest", apiHandler.GetDigest)
+			r.Put("/preferences/digest", apiHandler.UpdateDigest)
+			r.Delete("/preferences/digest", apiHandler.DeleteDigest)
+			r.Get("/preferences/focus", apiHandler.GetFocus)
+			r.Put("/preferences/focus", apiHandler.UpdateFocus)
+			r.Delete("/preferences/focus", apiHandler.DeleteFocus)
+			r.Delete("/preferences/focus/blocks", apiHandler.RemoveFocusBlocks)
+			r.Get("/preferences/alarm-providers", apiHandler.ListAlarmProviders)
+			r.Post("/preferences/alarm-providers", apiHandler.CreateAlarmProvider)
+			r.Delete("/preferences/alarm-providers/{id}", apiHandler.DeleteAlarmProvider)
+			r.Post("/preferences/alarm-providers/{id}/test", apiHandler.TestAlarmProvider)
+			r.Get("/preferences/alarm-deliveries", apiHandler.ListAlarmDeliveries)
+			r.Get("/locations", apiHandler.ListLocations)
+			r.Post("/locations", apiHandler.CreateLocation)
+			r.Get("/locations/suggest", apiHandler.SuggestLocations)
+			r.Put("/locations/{id}", apiHandler.UpdateLocation)
+			r.Delete("/locations/{id}", apiHandler.DeleteLocation)
+		})
+
+		contactRoutes(r, func(r chi.Router) {
+			r.Get("/addressbooks", apiHandler.ListAddressBooks)
+			r.Get("/addressbooks/{id}", apiHandler.GetAddressBook)
+			r.Get("/addressbooks/{id}/shares", apiHandler.ListAddressBookShares)
+			r.Post("/addressbooks/{id}/shares", apiHandler.ShareAddressBook)
+			r.Delete("/addressbooks/{id}/shares/{userId}", apiHandler.UnshareAddressBook)
+			r.Get("/addressbooks/{id}/quality", apiHandler.ContactQuality)
+			r.Get("/contacts/lookup", apiHandler.LookupContacts)
+			r.Get("/contacts/recent", apiHandler.RecentContactChanges)
+			r.Get("/addressbooks/{id}/contacts", apiHandler.ListContacts)
+			r.Get("/addressbooks/{id}/contacts/{uid}", apiHandler.GetContact)
+			r.Post("/addressbooks/{id}/contacts", apiHandler.CreateContact)
+			r.Put("/addressbooks/{id}/contacts/{uid}", apiHandler.UpdateContact)
+			r.Delete("/addressbooks/{id}/contacts/{uid}", apiHandler.DeleteContact)
+		})
+
+		r.Get("/sync-activity", apiHandler.ListSyncActivity)
+		r.Get("/client-quality", apiHandler.ClientQuality)
+		r.Get("/changes", apiHandler.ListChanges)
+		r.Get("/auth-events", apiHandler.ListAuthEvents)
+		r.Get("/jobs", apiHandler.ListJobs)
+		r.Get("/jobs/{id}", apiHandler.GetJob)
+		r.Post("/jobs/{id}/resume", apiHandler.ResumeJob)
+		r.Post("/jobs/{id}/abort", apiHandler.AbortJob)
+		r.Get("/server-info", apiHandler.GetServerInfo)
+		r.Get("/quota", apiHandler.GetQuota)
+		r.Get("/preferences", apiHandler.GetPreferences)
+		r.Put("/preferences", apiHandler.UpdatePreferences)
+		r.Get("/preferences/notifications", apiHandler.ListNotifications)
+		r.Put("/preferences/notifications/{type}/{id}", apiHandler.FollowCollection)
+		r.Delete("/preferences/notifications/{type}/{id}", apiHandler.UnfollowCollection)
+		r.Get("/groups", apiHandler.ListGroups)
+		r.Post("/groups", apiHandler.CreateGroup)
+		r.Delete("/groups/{id}", apiHandler.DeleteGroup)
+		r.Put("/groups/{id}/members/{userId}", apiHandler.AddGroupMember)
+		r.Delete("/groups/{id}/members/{userId}", apiHandler.RemoveGroupMember)
+		r.Get("/devices", apiHandler.ListDevices)
+		r.Post("/devices/{id}/revoke", apiHandler.RevokeDevice)
+		r.Post("/devices/{id}/rotate", apiHandler.RotateDevice)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(adminACL)
+			r.Get("/backups", apiHandler.GetBackupStatus)
+			r.Post("/backups", apiHandler.RunBackup)
+			r.Get("/backups/{snapshot}", apiHandler.GetBackupSnapshot)
+			r.Post("/backups/{snapshot}/restore", apiHandler.RestoreBackup)
+			r.Get("/fsck", apiHandler.GetFsckStatus)
+			r.Post("/fsck", apiHandler.RunFsck)
+			r.Post("/config/reload", apiHandler.ReloadConfig)
+			r.Get("/migrations", apiHandler.GetMigrations)
+			r.Post("/migrations", apiHandler.RunMigrations)
+			r.Post("/users", apiHandler.CreateUser)
+			r.Get("/drain", apiHandler.GetDrainStatus)
+			r.Post("/drain", apiHandler.StartDrain)
+			r.Get("/usage", apiHandler.GetUsageStats)
+			r.Get("/impersonations", apiHandler.ListImpersonations)
+			r.Post("/impersonations", apiHandler.StartImpersonation)
+			r.Get("/impersonations/{id}", apiHandler.GetImpersonation)
+			r.Delete("/impersonations/{id}", apiHandler.EndImpersonation)
+			r.Get("/maintenance", apiHandler.ListMaintenance)
+			r.Put("/maintenance/{type}/{id}", apiHandler.StartMaintenance)
+			r.Delete("/maintenance/{type}/{id}", apiHandler.EndMaintenance)
+			r.Get("/feature-flags", apiHandler.ListFeatureFlags)
+			r.Put("/feature-flags/{name}/{scope}/{id}", apiHandler.SetFeatureFlagOverride)
+			r.Delete("/feature-flags/{name}/{scope}/{id}", apiHandler.DeleteFeatureFlagOverride)
+			calendarRoutes(r, func(r chi.Router) {
+				r.Post("/calendars/{id}/transfer", apiHandler.TransferCalendar)
+				r.Get("/calendars/{id}/transfers", apiHandler.ListCalendarTransfers)
+			})
+		})
 	})
 
 	davHandler := dav.NewServer(dav.Options{Config: cfg, Store: store, Extensions: opts.DAVExtensions, Logger: opts.Logger})
 	registerDAVMethods(davHandler.RegisteredMethods())
+	apiHandler.SetDAVServer(davHandler)
+	if opts.Reloader != nil {
+		apiHandler.SetReloader(opts.Reloader)
+		opts.Reloader.OnReload(uiHandler.Reconfigure)
+		opts.Reloader.OnReload(apiHandler.Reconfigure)
+		opts.Reloader.OnReload(davHandler.Reconfigure)
+		opts.Reloader.OnReload(func(next *config.Config) {
+			authRateLimiter.SetLimit(authRateLimit(next))
+			davRateLimiter.SetLimit(davRateLimit(next))
+		})
+		if authService != nil {
+			opts.Reloader.OnReload(authService.Reconfigure)
+		}
+	}
 	davAuth := opts.DAVAuthMiddleware
 	if davAuth == nil && authService != nil {
 		davAuth = authService.RequireDAVAuth
@@ -243,16 +524,57 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 			})
 		}
 	}
+	// Basic credentials are refused over plain HTTP, and discovery there
+	// advertises nothing that would need them.
+	davOptions := func(next http.Handler) http.Handler { return next }
+	if authService != nil {
+		basicAuth := davAuth
+		davAuth = func(next http.Handler) http.Handler {
+			return authService.RequireSecureTransport(basicAuth(next))
+		}
+		davOptions = authService.LimitInsecureCapabilities
+	}
+
+	calendarRoutes(r, func(r chi.Router) {
+		// Public free/busy links need no login; the token in the URL is the
+		// only credential, so they share the stricter auth rate limit.
+		r.With(authRateLimiter.Middleware()).Get("/freebusy/{token}.ifb", davHandler.PublicFreeBusy)
+		r.With(authRateLimiter.Middleware()).Get("/tasks/{file}", davHandler.PublicTasks)
+		r.With(authRateLimiter.Middleware()).Get("/event-links/{file}", davHandler.PublicEvent)
+
+		// Public booking pages are likewise open to anyone with the link.
+		r.With(authRateLimiter.Middleware(), securityHeaders).Get("/book/{slug}", uiHandler.PublicBookingPage)
+		r.With(authRateLimiter.Middleware(), securityHeaders, bookingIdempotency).Post("/book/{slug}", uiHandler.SubmitBooking)
+
+		// RSVP links are signed per attendee, so invitees answer without an
+		// account.
+		r.With(authRateLimiter.Middleware(), securityHeaders).Get("/rsvp/{token}", uiHandler.RSVPPage)
+		r.With(authRateLimiter.Middleware(), securityHeaders).Post("/rsvp/{token}", uiHandler.SubmitRSVP)
+	})
+
+	if cfg.ActiveSyncEnabled {
+		easHandler := activesync.NewHandler(store, opts.Logger)
+		easHandler.SetCollections(!cfg.CalDAVDisabled, !cfg.CardDAVDisabled)
+		r.With(davACL, davRateLimiter.Middleware(), davOptions).Options(activesync.Path, easHandler.ServeHTTP)
+		r.With(davACL, davRateLimiter.Middleware(), davAuth).Post(activesync.Path, easHandler.ServeHTTP)
+	}
 
 	r.Route("/dav", func(r chi.Router) {
+		r.Use(davACL)
 		r.Use(davRateLimiter.Middleware())
+		r.Use(davHandler.LogBodies)
+		r.Use(davHandler.ClientQuirks)
+
+		hideDAVTree(r, !cfg.CalDAVDisabled, "/calendars")
+		hideDAVTree(r, !cfg.CardDAVDisabled, "/addressbooks")
 
 		// OPTIONS and root PROPFIND must be accessible without authentication for CalDAV client discovery
-		r.MethodFunc("OPTIONS", "/*", davHandler.Options)
+		r.With(davOptions).MethodFunc("OPTIONS", "/*", davHandler.Options)
 
 		// All other methods require authentication
 		r.Group(func(r chi.Router) {
 			r.Use(davAuth)
+			r.Use(davHandler.RecordInterop)
 			r.MethodFunc("HEAD", "/*", davHandler.Head)
 			r.MethodFunc("GET", "/*", davHandler.Get)
 			r.MethodFunc("PROPFIND", "/*", davHandler.Propfind)
@@ -260,6 +582,7 @@ func NewRouterWithOptions(cfg *config.Config, store *store.Store, authService *a
 			r.MethodFunc("MKCOL", "/*", davHandler.Mkcol)
 			r.MethodFunc("MKCALENDAR", "/*", davHandler.Mkcalendar)
 			r.MethodFunc("PUT", "/*", davHandler.Put)
+			r.MethodFunc("POST", "/*", davHandler.Post)
 			r.MethodFunc("DELETE", "/*", davHandler.Delete)
 			r.MethodFunc("REPORT", "/*", davHandler.Report)
 			r.MethodFunc("COPY", "/*", davHandler.Copy)
@@ -317,7 +640,7 @@ func extensionMethodHandler(davHandler *dav.Server, davAuth func(http.Handler) h
 func isBuiltInDAVMethod(method string) bool {
 	method = strings.ToUpper(strings.TrimSpace(method))
 	switch method {
-	case http.MethodHead, http.MethodGet, http.MethodOptions, http.MethodPut, http.MethodDelete,
+	case http.MethodHead, http.MethodGet, http.MethodOptions, http.MethodPut, http.MethodPost, http.MethodDelete,
 		"PROPFIND", "PROPPATCH", "MKCOL", "MKCALENDAR", "REPORT", "COPY", "MOVE", "LOCK", "UNLOCK", "ACL":
 		return true
 	default:
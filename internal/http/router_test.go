@@ -2,17 +2,22 @@ package httpserver
 
 import (
 	"bytes"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/http/drain"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -116,6 +121,9 @@ func TestNewRouterPublicEndpoints(t *testing.T) {
 	if rec.Code != http.StatusNoContent {
 		t.Fatalf("OPTIONS /dav = %d", rec.Code)
 	}
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "" {
+		t.Fatalf("expected no browser security headers on DAV, got CSP %q", csp)
+	}
 
 	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
 	rec = httptest.NewRecorder()
@@ -129,6 +137,32 @@ func TestNewRouterPublicEndpoints(t *testing.T) {
 	}
 }
 
+func TestReadyzFailsWhileDraining(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	drainer := drain.New()
+	r := NewRouterWithOptions(&config.Config{BaseURL: "http://localhost:8080"}, store.New(db), nil, RouterOptions{Drainer: drainer})
+	drainer.Drain()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Connection") != "close" {
+		t.Fatalf("/readyz while draining = %d, Connection %q", rec.Code, rec.Header().Get("Connection"))
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/healthz while draining = %d", rec.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestNewRouterMetricsCanBeDisabled(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -146,6 +180,149 @@ func TestNewRouterMetricsCanBeDisabled(t *testing.T) {
 	}
 }
 
+func TestNewRouterHidesDisabledProtocol(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	r := NewRouter(&config.Config{BaseURL: "http://localhost:8080", CardDAVDisabled: true}, store.New(db), nil)
+	for _, target := range []string{"/.well-known/carddav", "/dav/addressbooks", "/dav/addressbooks/", "/dav/addressbooks/1/a.vcf", "/addressbooks/1"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("OPTIONS %s = %d, want 404", target, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/caldav", nil))
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("/.well-known/caldav = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/dav/", nil))
+	if dav := rec.Header().Get("DAV"); !strings.Contains(dav, "calendar-access") || strings.Contains(dav, "addressbook") {
+		t.Fatalf("DAV header = %q", dav)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/dav/addressbooks-archive/", nil))
+	if rec.Code == http.StatusNotFound {
+		t.Fatal("prefix match must stop at a path segment")
+	}
+
+	r = NewRouter(&config.Config{BaseURL: "http://localhost:8080", CalDAVDisabled: true}, store.New(db), nil)
+	for _, target := range []string{"/.well-known/caldav", "/dav/calendars/", "/dav/calendars/1/e.ics", "/calendars/1"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, target, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("OPTIONS %s = %d with CalDAV disabled, want 404", target, rec.Code)
+		}
+	}
+}
+
+// sharedRoutes serve both calendars and address books, so they stay up
+// with either protocol turned off. Entries ending in "/*" cover the route and
+// every route below it.
+var sharedRoutes = []string{
+	"/",
+	"/healthz",
+	"/readyz",
+	"/help",
+	"/auth/*",
+	"/app-passwords/*",
+	"/sessions/*",
+	"/email-aliases/*",
+	"/preferences",
+	"/sync-preferences",
+	"/onboarding/*",
+	"/held-deletions/*",
+	"/admin/usage",
+	"/principals/*",
+	"/dav/*",
+	"/api/admin/backups/*",
+	"/api/admin/config/reload",
+	"/api/admin/drain",
+	"/api/admin/feature-flags/*",
+	"/api/admin/fsck",
+	"/api/admin/impersonations/*",
+	"/api/admin/maintenance/*",
+	"/api/admin/migrations",
+	"/api/admin/usage",
+	"/api/admin/users",
+	"/api/auth-events",
+	"/api/changes",
+	"/api/client-quality",
+	"/api/devices/*",
+	"/api/groups/*",
+	"/api/jobs/*",
+	"/api/preferences",
+	"/api/preferences/notifications/*",
+	"/api/probe",
+	"/api/quota",
+	"/api/server-info",
+	"/api/sync-activity",
+}
+
+func TestNewRouterTagsEveryRouteWithItsProtocol(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	shared := func(route string) bool {
+		for _, entry := range sharedRoutes {
+			if prefix, ok := strings.CutSuffix(entry, "/*"); ok {
+				if rest, ok := strings.CutPrefix(route, prefix); ok && (rest == "" || rest[0] == '/') {
+					return true
+				}
+			} else if route == entry {
+				return true
+			}
+		}
+		return false
+	}
+	walk := func(r http.Handler) map[[2]string]bool {
+		routes := map[[2]string]bool{}
+		err := chi.Walk(r.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			routes[[2]string{method, route}] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("chi.Walk() error = %v", err)
+		}
+		return routes
+	}
+	placeholder := regexp.MustCompile(`\{[^}]+\}`)
+
+	st := store.New(db)
+	all := walk(NewRouter(&config.Config{BaseURL: "http://localhost:8080"}, st, nil))
+	noCalDAV := NewRouter(&config.Config{BaseURL: "http://localhost:8080", CalDAVDisabled: true}, st, nil)
+	noCardDAV := NewRouter(&config.Config{BaseURL: "http://localhost:8080", CardDAVDisabled: true}, st, nil)
+	withoutCalDAV, withoutCardDAV := walk(noCalDAV), walk(noCardDAV)
+	for route := range all {
+		method, path := route[0], route[1]
+		if withoutCalDAV[route] && withoutCardDAV[route] && !shared(path) {
+			t.Errorf("%s %s stays up with either protocol off; register it in calendarRoutes or contactRoutes, or add it to sharedRoutes if it serves both", method, path)
+		}
+		for _, off := range []struct {
+			name   string
+			routes map[[2]string]bool
+			router http.Handler
+		}{{"CalDAV", withoutCalDAV, noCalDAV}, {"CardDAV", withoutCardDAV, noCardDAV}} {
+			if off.routes[route] {
+				continue
+			}
+			target := strings.ReplaceAll(placeholder.ReplaceAllString(path, "1"), "*", "x")
+			if off.router.(chi.Routes).Match(chi.NewRouteContext(), method, target) {
+				t.Errorf("%s %s still matches a route with %s disabled", method, path, off.name)
+			}
+		}
+	}
+}
+
 func TestNewRouterWithOptionsWiresDAVExtensions(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -285,3 +462,101 @@ type davExtensionFunc func(*dav.Registry)
 func (f davExtensionFunc) RegisterDAV(r *dav.Registry) {
 	f(r)
 }
+
+func TestDatabaseUnavailableRewritesErrorResponses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	st := store.New(db)
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("SELECT .* FROM calendars").WillReturnError(refused)
+	}
+
+	h := databaseUnavailable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if cal, err := st.Calendars.GetByID(r.Context(), 1); err != nil || cal == nil {
+			http.NotFound(w, r)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PROPFIND", "/dav/calendars/1/1/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After = %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expected the read to be retried: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want a genuine 404 kept", rec.Code)
+	}
+}
+
+func TestRouterAppliesNetworkRulesPerGroup(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	cfg.Network.DAV.Allow = []string{"10.0.0.0/8"}
+	r := NewRouter(cfg, store.New(db), nil)
+
+	for _, tt := range []struct {
+		method, path, remoteAddr string
+		want                     int
+	}{
+		{http.MethodOptions, "/dav/", "203.0.113.9:1234", http.StatusForbidden},
+		{http.MethodOptions, "/dav/", "10.1.2.3:1234", http.StatusNoContent},
+		{http.MethodGet, "/healthz", "203.0.113.9:1234", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", "10.9.9.9")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Fatalf("%s %s from %s: status = %d, want %d", tt.method, tt.path, tt.remoteAddr, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAPIRolePolicyNamesRoutes(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	routes := NewRouter(&config.Config{BaseURL: "http://localhost:8080"}, store.New(db), nil).(chi.Routes)
+	var routed []string
+	err = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed = append(routed, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("chi.Walk() error = %v", err)
+	}
+	for key := range apiRolePolicy {
+		found := false
+		for _, route := range routed {
+			if prefix, ok := strings.CutSuffix(key, "/*"); ok {
+				_, path, _ := strings.Cut(route, " ")
+				found = found || strings.HasPrefix(path, prefix+"/")
+			} else {
+				found = found || route == key
+			}
+		}
+		if !found {
+			t.Errorf("API role policy entry %q names no route", key)
+		}
+	}
+}
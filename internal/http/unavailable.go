@@ -0,0 +1,67 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// unavailableRetryAfter is the Retry-After, in seconds, sent while the
+// database is unreachable.
+const unavailableRetryAfter = "5"
+
+// databaseUnavailable turns any error response written after the database
+// failed to answer into 503 with Retry-After. Handlers report lookups that
+// failed as missing, forbidden or unauthenticated in many places, and sync
+// clients react to those by deleting local data; a 503 makes them retry.
+func databaseUnavailable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(store.WithAvailabilityTracking(r.Context()))
+		next.ServeHTTP(&unavailableWriter{ResponseWriter: w, ctx: r.Context()}, r)
+	})
+}
+
+type unavailableWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *unavailableWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code < http.StatusBadRequest || code == http.StatusServiceUnavailable || !store.Unavailable(w.ctx) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.replaced = true
+	h := w.ResponseWriter.Header()
+	h.Del("Content-Length")
+	h.Del("ETag")
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Retry-After", unavailableRetryAfter)
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = io.WriteString(w.ResponseWriter, "database temporarily unavailable\n")
+}
+
+func (w *unavailableWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		// The handler's error body is dropped in favour of the 503's.
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *unavailableWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,477 @@
+// Package icalgen generates large, realistic iCalendar corpora for
+// benchmarks and fuzz seeds. Events mix recurrence rules with exceptions and
+// overrides, time zones, all-day spans, alarms, attendees and non-ASCII
+// summaries, and the output is the same for the same seed.
+package icalgen
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	_ "time/tzdata" // zone rules must not depend on the host
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/util"
+)
+
+// Options controls a generated corpus.
+type Options struct {
+	// Seed selects the corpus; equal seeds give identical output.
+	Seed int64
+	// Events is the number of calendar objects to generate.
+	Events int
+	// CalendarID is set on every event Events returns.
+	CalendarID int64
+	// Start is the earliest event start; zero means 2024-01-01 UTC.
+	Start time.Time
+	// Days is the span event starts are spread over; zero means 365.
+	Days int
+}
+
+// Object is one generated calendar object resource: a VCALENDAR holding a
+// single UID, possibly with overridden occurrences.
+type Object struct {
+	UID          string
+	RawICAL      string
+	Summary      string
+	Description  string
+	Location     string
+	DTStart      time.Time
+	DTEnd        time.Time
+	AllDay       bool
+	LastModified time.Time
+}
+
+// Objects generates the calendar objects selected by opts. LastModified
+// increases with the index, one second apart from Start, so callers can pick
+// a sync point that leaves a known number of objects changed after it.
+func Objects(opts Options) []Object {
+	g := newGenerator(opts)
+	objects := make([]Object, opts.Events)
+	for i := range objects {
+		objects[i] = g.object(i).Object
+	}
+	return objects
+}
+
+// Events generates the calendar objects selected by opts as stored events,
+// the shape the DAV handlers read from the event repository.
+func Events(opts Options) []store.Event {
+	objects := Objects(opts)
+	events := make([]store.Event, len(objects))
+	for i, obj := range objects {
+		start, end := obj.DTStart, obj.DTEnd
+		events[i] = store.Event{
+			ID:           int64(i + 1),
+			CalendarID:   opts.CalendarID,
+			UID:          obj.UID,
+			ResourceName: obj.UID,
+			RawICAL:      obj.RawICAL,
+			ETag:         util.CanonicalETag(obj.RawICAL),
+			Summary:      util.StrPtr(obj.Summary),
+			Description:  optional(obj.Description),
+			Location:     optional(obj.Location),
+			DTStart:      &start,
+			DTEnd:        &end,
+			AllDay:       obj.AllDay,
+			LastModified: obj.LastModified,
+		}
+	}
+	return events
+}
+
+// Calendar generates the objects selected by opts as one VCALENDAR, the
+// shape of a calendar export or an import file.
+func Calendar(opts Options) string {
+	g := newGenerator(opts)
+	var b strings.Builder
+	var components strings.Builder
+	b.WriteString(calendarHeader)
+	seen := map[string]bool{}
+	for i := 0; i < opts.Events; i++ {
+		obj := g.object(i)
+		if obj.zone.lines != nil && !seen[obj.zone.id] {
+			seen[obj.zone.id] = true
+			b.WriteString(obj.zone.definition())
+		}
+		components.WriteString(obj.components)
+	}
+	b.WriteString(components.String())
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return util.StrPtr(s)
+}
+
+const calendarHeader = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//calcard//icalgen//EN\r\n"
+
+// generated is an object with the parts Calendar merges across objects.
+type generated struct {
+	Object
+	// zone is the time zone the object's times use; all-day objects use none.
+	zone zone
+	// components holds the object's VEVENTs, folded.
+	components string
+}
+
+type generator struct {
+	opts Options
+	rng  *rand.Rand
+}
+
+func newGenerator(opts Options) *generator {
+	if opts.Start.IsZero() {
+		opts.Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if opts.Days <= 0 {
+		opts.Days = 365
+	}
+	return &generator{opts: opts, rng: rand.New(rand.NewSource(opts.Seed))}
+}
+
+// chance reports true with probability percent/100.
+func (g *generator) chance(percent int) bool {
+	return g.rng.Intn(100) < percent
+}
+
+func pick[T any](g *generator, items []T) T {
+	return items[g.rng.Intn(len(items))]
+}
+
+func (g *generator) object(i int) generated {
+	obj := Object{
+		UID:          fmt.Sprintf("icalgen-%d-%07d@calcard.test", g.opts.Seed, i),
+		LastModified: g.opts.Start.Add(time.Duration(i+1) * time.Second).UTC(),
+	}
+
+	zone := zones[0]
+	if g.chance(60) {
+		zone = pick(g, zones[1:])
+	}
+	loc, err := time.LoadLocation(zone.id)
+	if err != nil {
+		loc = time.UTC
+	}
+	day := g.opts.Start.AddDate(0, 0, g.rng.Intn(g.opts.Days))
+	obj.AllDay = g.chance(12)
+	var start, end time.Time
+	if obj.AllDay {
+		start = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 1+g.rng.Intn(3))
+	} else {
+		start = time.Date(day.Year(), day.Month(), day.Day(), 7+g.rng.Intn(13), 15*g.rng.Intn(4), 0, 0, loc)
+		end = start.Add(time.Duration(1+g.rng.Intn(16)) * 15 * time.Minute)
+	}
+	obj.DTStart, obj.DTEnd = start.UTC(), end.UTC()
+
+	obj.Summary = pick(g, summaries) + pick(g, summarySuffixes)
+	if g.chance(50) {
+		obj.Description = g.description()
+	}
+	if g.chance(40) {
+		obj.Location = pick(g, locations)
+	}
+
+	lines := []string{"BEGIN:VEVENT", "UID:" + obj.UID}
+	lines = append(lines, g.stamps(obj.LastModified)...)
+	lines = append(lines, dateProp("DTSTART", start, obj.AllDay, zone.id), dateProp("DTEND", end, obj.AllDay, zone.id))
+	lines = append(lines, textProp("SUMMARY", obj.Summary))
+	if obj.Description != "" {
+		lines = append(lines, textProp("DESCRIPTION", obj.Description))
+	}
+	if obj.Location != "" {
+		lines = append(lines, textProp("LOCATION", obj.Location))
+	}
+	lines = append(lines, "STATUS:"+pick(g, []string{"CONFIRMED", "CONFIRMED", "TENTATIVE"}))
+	if obj.AllDay || g.chance(10) {
+		lines = append(lines, "TRANSP:TRANSPARENT")
+	}
+	if g.chance(20) {
+		lines = append(lines, "CATEGORIES:"+strings.Join(pickCategories(g), ","))
+	}
+
+	var override []string
+	if g.chance(30) {
+		rule, next := g.recurrence(start)
+		lines = append(lines, "RRULE:"+rule)
+		if !next.IsZero() {
+			// Some series cancel their third occurrence or move the second.
+			if g.chance(30) {
+				lines = append(lines, dateProp("EXDATE", step(rule, next), obj.AllDay, zone.id))
+			}
+			if g.chance(25) {
+				override = g.override(obj, next, end.Sub(start), zone.id)
+			}
+		}
+	}
+	if g.chance(30) {
+		lines = append(lines, g.attendees()...)
+	}
+	if g.chance(40) {
+		lines = append(lines, g.alarms(obj.Summary)...)
+	}
+	lines = append(lines, "END:VEVENT")
+	lines = append(lines, override...)
+
+	out := generated{Object: obj}
+	if !obj.AllDay {
+		out.zone = zone
+	}
+	var comps strings.Builder
+	for _, line := range lines {
+		comps.WriteString(fold(line))
+	}
+	out.components = comps.String()
+	out.RawICAL = calendarHeader + out.zone.definition() + out.components + "END:VCALENDAR\r\n"
+	return out
+}
+
+func (g *generator) stamps(modified time.Time) []string {
+	created := modified.Add(-time.Duration(g.rng.Intn(90*24)) * time.Hour)
+	return []string{
+		"DTSTAMP:" + modified.Format(utcLayout),
+		"CREATED:" + created.Format(utcLayout),
+		"LAST-MODIFIED:" + modified.Format(utcLayout),
+		fmt.Sprintf("SEQUENCE:%d", g.rng.Intn(4)),
+	}
+}
+
+// recurrence returns an RRULE for an event starting at start and, when the
+// rule's second occurrence is easy to know, that occurrence.
+func (g *generator) recurrence(start time.Time) (string, time.Time) {
+	until := start.AddDate(0, 6, 0).UTC().Format(utcLayout)
+	switch g.rng.Intn(6) {
+	case 0:
+		n := 1 + g.rng.Intn(3)
+		return fmt.Sprintf("FREQ=DAILY;INTERVAL=%d;COUNT=%d", n, 5+g.rng.Intn(20)), start.AddDate(0, 0, n)
+	case 1:
+		return fmt.Sprintf("FREQ=WEEKLY;COUNT=%d", 4+g.rng.Intn(50)), start.AddDate(0, 0, 7)
+	case 2:
+		return "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=" + until, time.Time{}
+	case 3:
+		if start.Day() > 28 {
+			return "FREQ=MONTHLY;BYDAY=1MO;COUNT=12", time.Time{}
+		}
+		return fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d;UNTIL=%s", start.Day(), until), start.AddDate(0, 1, 0)
+	case 4:
+		return "FREQ=YEARLY", start.AddDate(1, 0, 0)
+	default:
+		return "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", time.Time{}
+	}
+}
+
+// step returns the occurrence after t for the simple rules recurrence
+// reports a second occurrence for.
+func step(rule string, t time.Time) time.Time {
+	switch {
+	case strings.HasPrefix(rule, "FREQ=DAILY"):
+		var n int
+		fmt.Sscanf(rule, "FREQ=DAILY;INTERVAL=%d", &n)
+		return t.AddDate(0, 0, n)
+	case strings.HasPrefix(rule, "FREQ=WEEKLY"):
+		return t.AddDate(0, 0, 7)
+	case strings.HasPrefix(rule, "FREQ=MONTHLY"):
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+// override moves the occurrence at next an hour later with a new summary.
+func (g *generator) override(obj Object, next time.Time, length time.Duration, tzid string) []string {
+	moved := next
+	if !obj.AllDay {
+		moved = next.Add(time.Hour)
+	}
+	lines := []string{"BEGIN:VEVENT", "UID:" + obj.UID}
+	lines = append(lines, g.stamps(obj.LastModified)...)
+	lines = append(lines,
+		dateProp("RECURRENCE-ID", next, obj.AllDay, tzid),
+		dateProp("DTSTART", moved, obj.AllDay, tzid),
+		dateProp("DTEND", moved.Add(length), obj.AllDay, tzid),
+		textProp("SUMMARY", obj.Summary+" (moved)"),
+		"END:VEVENT",
+	)
+	return lines
+}
+
+func (g *generator) attendees() []string {
+	organizer := pick(g, people)
+	lines := []string{fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", paramValue(organizer.name), organizer.email)}
+	for n := 1 + g.rng.Intn(8); n > 0; n-- {
+		p := pick(g, people)
+		lines = append(lines, fmt.Sprintf("ATTENDEE;CN=%s;ROLE=%s;PARTSTAT=%s;RSVP=TRUE:mailto:%s",
+			paramValue(p.name), pick(g, []string{"REQ-PARTICIPANT", "REQ-PARTICIPANT", "OPT-PARTICIPANT"}),
+			pick(g, []string{"NEEDS-ACTION", "ACCEPTED", "ACCEPTED", "DECLINED", "TENTATIVE"}), p.email))
+	}
+	return lines
+}
+
+func (g *generator) alarms(summary string) []string {
+	var lines []string
+	for n := 1 + g.rng.Intn(2); n > 0; n-- {
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			textProp("DESCRIPTION", summary),
+			"TRIGGER:"+pick(g, []string{"-PT5M", "-PT15M", "-PT30M", "-PT1H", "-P1D", "PT0S"}),
+			"END:VALARM",
+		)
+	}
+	return lines
+}
+
+func (g *generator) description() string {
+	n := 1 + g.rng.Intn(4)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = pick(g, sentences)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func pickCategories(g *generator) []string {
+	cats := []string{"Work", "Personal", "Travel", "Health", "Family"}
+	g.rng.Shuffle(len(cats), func(i, j int) { cats[i], cats[j] = cats[j], cats[i] })
+	return cats[:1+g.rng.Intn(3)]
+}
+
+const (
+	utcLayout   = "20060102T150405Z"
+	localLayout = "20060102T150405"
+	dateLayout  = "20060102"
+)
+
+// dateProp renders a DATE, UTC DATE-TIME or zoned DATE-TIME property.
+func dateProp(name string, t time.Time, allDay bool, tzid string) string {
+	switch {
+	case allDay:
+		return name + ";VALUE=DATE:" + t.Format(dateLayout)
+	case tzid == "UTC":
+		return name + ":" + t.UTC().Format(utcLayout)
+	default:
+		return name + ";TZID=" + tzid + ":" + t.Format(localLayout)
+	}
+}
+
+func textProp(name, value string) string {
+	r := strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\n", "\\n")
+	return name + ":" + r.Replace(value)
+}
+
+// paramValue quotes a parameter value that contains characters a bare value
+// may not.
+func paramValue(v string) string {
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// fold splits line into 75-octet lines per RFC 5545 §3.1 without breaking a
+// UTF-8 sequence, and terminates it with CRLF.
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := len(string(r))
+		if width+n > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+type person struct {
+	name  string
+	email string
+}
+
+type zone struct {
+	id    string
+	lines []string
+}
+
+// definition returns the zone's VTIMEZONE, or nothing for UTC.
+func (z zone) definition() string {
+	if z.lines == nil {
+		return ""
+	}
+	return strings.Join(z.lines, "\r\n") + "\r\n"
+}
+
+// zones lists the time zones events use. UTC needs no VTIMEZONE; the rest
+// carry the definitions clients usually send.
+var zones = []zone{
+	{id: "UTC"},
+	{id: "America/New_York", lines: []string{
+		"BEGIN:VTIMEZONE", "TZID:America/New_York",
+		"BEGIN:DAYLIGHT", "TZOFFSETFROM:-0500", "TZOFFSETTO:-0400", "TZNAME:EDT", "DTSTART:19700308T020000", "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU", "END:DAYLIGHT",
+		"BEGIN:STANDARD", "TZOFFSETFROM:-0400", "TZOFFSETTO:-0500", "TZNAME:EST", "DTSTART:19701101T020000", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU", "END:STANDARD",
+		"END:VTIMEZONE",
+	}},
+	{id: "Europe/Berlin", lines: []string{
+		"BEGIN:VTIMEZONE", "TZID:Europe/Berlin",
+		"BEGIN:DAYLIGHT", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0200", "TZNAME:CEST", "DTSTART:19700329T020000", "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", "END:DAYLIGHT",
+		"BEGIN:STANDARD", "TZOFFSETFROM:+0200", "TZOFFSETTO:+0100", "TZNAME:CET", "DTSTART:19701025T030000", "RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU", "END:STANDARD",
+		"END:VTIMEZONE",
+	}},
+	{id: "Asia/Kolkata", lines: []string{
+		"BEGIN:VTIMEZONE", "TZID:Asia/Kolkata",
+		"BEGIN:STANDARD", "TZOFFSETFROM:+0530", "TZOFFSETTO:+0530", "TZNAME:IST", "DTSTART:19700101T000000", "END:STANDARD",
+		"END:VTIMEZONE",
+	}},
+	{id: "Australia/Sydney", lines: []string{
+		"BEGIN:VTIMEZONE", "TZID:Australia/Sydney",
+		"BEGIN:STANDARD", "TZOFFSETFROM:+1100", "TZOFFSETTO:+1000", "TZNAME:AEST", "DTSTART:19700405T030000", "RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU", "END:STANDARD",
+		"BEGIN:DAYLIGHT", "TZOFFSETFROM:+1000", "TZOFFSETTO:+1100", "TZNAME:AEDT", "DTSTART:19701004T020000", "RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=1SU", "END:DAYLIGHT",
+		"END:VTIMEZONE",
+	}},
+}
+
+var summaries = []string{
+	"Standup", "Sprint review 🚀", "1:1 with Priya", "Quarterly planning", "Dentist 🦷",
+	"Team lunch 🍕", "Yoga 🧘‍♀️", "Flight ✈️ LHR→JFK", "Café with Zoë", "Réunion d'équipe",
+	"Geburtstag 🎂", "週次ミーティング", "Обед с командой", "Family call 👨‍👩‍👧‍👦", "Release 🎉 v2.0",
+	"Orchestra rehearsal 🎻", "Parent–teacher conference", "Gym 💪", "Book club 📚", "Ramadan iftar 🌙",
+}
+
+var summarySuffixes = []string{"", "", "", " (remote)", " — room 4.12", ", Berlin; HQ", " #42", " 🔁"}
+
+var locations = []string{
+	"Room 4.12", "Zoom: https://example.com/j/123456789", "Café Müller, Hauptstraße 5, 10115 Berlin",
+	"東京オフィス 会議室A", "Home 🏠", "Terminal 5, Gate B32",
+}
+
+var sentences = []string{
+	"Agenda: review last week's action items; agree on the next milestone, owners and dates.",
+	"Bring the signed forms 📝 and your ID.",
+	"Dial-in: +1 555 0100, PIN 4242#",
+	"Notes are in the shared folder → Planning/2024/Q3.",
+	"Über die nächsten Schritte sprechen wir vor Ort.",
+	"会議の資料は前日までに共有してください。",
+	"Parking is limited, please use the S-Bahn 🚆 if you can.",
+	"Don't forget: backslashes \\ and commas, semicolons; all need escaping.",
+}
+
+var people = []person{
+	{"Priya Raman", "priya@example.com"},
+	{"Zoë Müller", "zoe@example.org"},
+	{"José Álvarez", "jose@example.net"},
+	{"Chen Wei", "wei.chen@example.com"},
+	{"Ngozi Okafor", "ngozi@example.org"},
+	{"O'Brien, Siobhán", "siobhan@example.ie"},
+	{"Ahmed Hassan", "ahmed@example.com"},
+	{"Kim Lee", "kim.lee@example.kr"},
+	{"Calendar Bot 🤖", "bot@example.com"},
+}
@@ -0,0 +1,88 @@
+package icalgen
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+func TestObjectsAreDeterministic(t *testing.T) {
+	a := Objects(Options{Seed: 7, Events: 200})
+	b := Objects(Options{Seed: 7, Events: 200})
+	c := Objects(Options{Seed: 8, Events: 200})
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("object %d differs between runs with the same seed", i)
+		}
+	}
+	same := 0
+	for i := range a {
+		if a[i].RawICAL == strings.ReplaceAll(c[i].RawICAL, "icalgen-8-", "icalgen-7-") {
+			same++
+		}
+	}
+	if same == len(a) {
+		t.Fatal("different seeds produced the same corpus")
+	}
+}
+
+func TestObjectsAreValidAndVaried(t *testing.T) {
+	objects := Objects(Options{Seed: 1, Events: 1000})
+	features := map[string]int{}
+	for i, obj := range objects {
+		payloads, err := utils.ParseICSFile(obj.RawICAL)
+		if err != nil {
+			t.Fatalf("object %d does not parse: %v\n%s", i, err, obj.RawICAL)
+		}
+		if len(payloads) != 1 {
+			t.Fatalf("object %d parsed into %d resources, want 1", i, len(payloads))
+		}
+		if got := utils.ExtractUID(obj.RawICAL); got != obj.UID {
+			t.Fatalf("object %d has UID %q, want %q", i, got, obj.UID)
+		}
+		if i > 0 && !obj.LastModified.After(objects[i-1].LastModified) {
+			t.Fatalf("object %d LastModified does not increase", i)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(obj.RawICAL, "\r\n"), "\r\n") {
+			if len(line) > 75 {
+				t.Fatalf("object %d has an unfolded line of %d octets: %q", i, len(line), line)
+			}
+			if !utf8.ValidString(line) {
+				t.Fatalf("object %d folds inside a UTF-8 sequence: %q", i, line)
+			}
+		}
+		for _, feature := range []string{"RRULE:", "EXDATE", "RECURRENCE-ID", "TZID=", "VALUE=DATE", "BEGIN:VALARM", "ATTENDEE;"} {
+			if strings.Contains(obj.RawICAL, feature) {
+				features[feature]++
+			}
+		}
+		for _, r := range obj.Summary {
+			if r > 0xFFFF {
+				features["emoji"]++
+				break
+			}
+		}
+	}
+	for _, feature := range []string{"RRULE:", "EXDATE", "RECURRENCE-ID", "TZID=", "VALUE=DATE", "BEGIN:VALARM", "ATTENDEE;", "emoji"} {
+		if features[feature] == 0 {
+			t.Errorf("no object uses %s", feature)
+		}
+	}
+}
+
+func TestCalendarHoldsEveryObject(t *testing.T) {
+	opts := Options{Seed: 3, Events: 300}
+	cal := Calendar(opts)
+	payloads, err := utils.ParseICSFile(cal)
+	if err != nil {
+		t.Fatalf("calendar does not parse: %v", err)
+	}
+	if len(payloads) != opts.Events {
+		t.Fatalf("calendar holds %d resources, want %d", len(payloads), opts.Events)
+	}
+	if n := strings.Count(cal, "TZID:Europe/Berlin\r\n"); n != 1 {
+		t.Fatalf("Europe/Berlin is defined %d times, want once", n)
+	}
+}
@@ -0,0 +1,221 @@
+// Package interop records the DAV requests a real client makes during a
+// named test profile, such as "ios17-initial-setup", and replays them
+// against another build to spot where its answers to that client drifted.
+//
+// A recording is a file of JSON lines, one Exchange per request. Values
+// that differ between accounts and runs are stored as placeholders: the
+// server's address as {base}, the account's email as {email} and principal
+// ID as {user}, collection IDs as {calendar1} or {addressbook1}, and ETags,
+// sync tokens, CTags and lock tokens as {etag1}, {synctoken1} and so on.
+// Dates the server sets, such as DTSTAMP and getlastmodified, are stored as
+// {*} and not compared.
+package interop
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxBody bounds how much of each request and response body is recorded.
+const MaxBody = 4 << 20
+
+// RequestHeaders are the request headers recorded and replayed. The
+// User-Agent is kept so the server applies the same client quirks.
+var RequestHeaders = []string{
+	"Accept", "Brief", "Content-Type", "Depth", "Destination", "If", "If-Match",
+	"If-None-Match", "Lock-Token", "Overwrite", "Prefer", "Timeout", "User-Agent",
+}
+
+// ResponseHeaders are the response headers recorded and compared.
+var ResponseHeaders = []string{"Content-Type", "DAV", "ETag", "Location", "Lock-Token", "Preference-Applied"}
+
+// Exchange is one request of a recording and the response it got.
+type Exchange struct {
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Header         map[string]string `json:"header,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	Status         int               `json:"status"`
+	ResponseHeader map[string]string `json:"responseHeader,omitempty"`
+	ResponseBody   string            `json:"responseBody,omitempty"`
+	// Truncated marks an exchange with a body over MaxBody, which cannot be
+	// replayed faithfully.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// PickHeaders returns the values in h of the named headers that are set.
+func PickHeaders(h http.Header, names []string) map[string]string {
+	picked := map[string]string{}
+	for _, name := range names {
+		if values := h.Values(name); len(values) > 0 {
+			picked[name] = strings.Join(values, ", ")
+		}
+	}
+	if len(picked) == 0 {
+		return nil
+	}
+	return picked
+}
+
+// ReadSession reads a recording.
+func ReadSession(r io.Reader) ([]Exchange, error) {
+	var exchanges []Exchange
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var ex Exchange
+		if err := dec.Decode(&ex); err == io.EOF {
+			return exchanges, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("exchange %d: %w", len(exchanges)+1, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+}
+
+var (
+	// Values learned from responses and replaced wherever they appear.
+	etagProp      = regexp.MustCompile(`<(?:[\w-]+:)?getetag>([^<]*)</`)
+	syncTokenProp = regexp.MustCompile(`<(?:[\w-]+:)?sync-token>([^<]*)</`)
+	ctagProp      = regexp.MustCompile(`<(?:[\w-]+:)?getctag>([^<]*)</`)
+	lockToken     = regexp.MustCompile(`opaquelocktoken:[0-9a-fA-F-]+`)
+
+	// Values the server sets afresh on every run.
+	volatileProp = regexp.MustCompile(`(<(?:[\w-]+:)?(?:getlastmodified|creationdate|quota-used-bytes|quota-available-bytes)>)[^<]*(</)`)
+	volatileLine = regexp.MustCompile(`(?m)^((?:DTSTAMP|LAST-MODIFIED|REV)(?:;[^:\r\n]*)?:)[^\r\n]*`)
+
+	// IDs, replaced only where they appear in a DAV path.
+	pathID = regexp.MustCompile(`(/dav/(calendars|addressbooks|principals)/)([0-9]+)\b`)
+)
+
+// minTokenLength keeps short values, which could appear by chance in
+// unrelated text, from being replaced.
+const minTokenLength = 6
+
+// Session writes one recording, replacing the values it learns with
+// placeholders. It is safe for concurrent use.
+type Session struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	userID int64
+	tokens map[string]string // value -> placeholder
+	ids    map[string]string // kind + ID -> placeholder
+	counts map[string]int
+}
+
+// NewSession starts a recording written to w for the account with userID
+// and email, served at each of bases.
+func NewSession(w io.Writer, userID int64, email string, bases ...string) *Session {
+	s := &Session{
+		enc:    json.NewEncoder(w),
+		userID: userID,
+		tokens: map[string]string{},
+		ids:    map[string]string{},
+		counts: map[string]int{},
+	}
+	s.enc.SetEscapeHTML(false)
+	if email != "" {
+		s.tokens[email] = "{email}"
+	}
+	for _, base := range bases {
+		if base = strings.TrimSuffix(base, "/"); base != "" {
+			s.tokens[base] = "{base}"
+		}
+	}
+	return s
+}
+
+// Record canonicalizes ex and appends it to the recording.
+func (s *Session) Record(ex Exchange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range etagProp.FindAllStringSubmatch(ex.ResponseBody, -1) {
+		s.learn("etag", unquoteETag(m[1]))
+	}
+	if etag, ok := ex.ResponseHeader["ETag"]; ok {
+		s.learn("etag", unquoteETag(etag))
+	}
+	for _, m := range syncTokenProp.FindAllStringSubmatch(ex.ResponseBody, -1) {
+		s.learn("synctoken", m[1])
+	}
+	for _, m := range ctagProp.FindAllStringSubmatch(ex.ResponseBody, -1) {
+		s.learn("ctag", m[1])
+	}
+	for _, text := range []string{ex.ResponseBody, ex.ResponseHeader["Lock-Token"]} {
+		for _, token := range lockToken.FindAllString(text, -1) {
+			s.learn("locktoken", token)
+		}
+	}
+
+	ex.ResponseBody = volatileProp.ReplaceAllString(ex.ResponseBody, "${1}{*}${2}")
+	ex.ResponseBody = volatileLine.ReplaceAllString(ex.ResponseBody, "${1}{*}")
+	ex.Path = s.canonical(ex.Path)
+	ex.Body = s.canonical(ex.Body)
+	ex.ResponseBody = s.canonical(ex.ResponseBody)
+	for _, header := range []map[string]string{ex.Header, ex.ResponseHeader} {
+		for name, value := range header {
+			header[name] = s.canonical(value)
+		}
+	}
+	return s.enc.Encode(ex)
+}
+
+func (s *Session) learn(kind, value string) {
+	if len(value) < minTokenLength {
+		return
+	}
+	if _, ok := s.tokens[value]; ok {
+		return
+	}
+	s.counts[kind]++
+	s.tokens[value] = "{" + kind + strconv.Itoa(s.counts[kind]) + "}"
+}
+
+// canonical replaces the learned values and the IDs in DAV paths in text.
+func (s *Session) canonical(text string) string {
+	if text == "" {
+		return text
+	}
+	values := make([]string, 0, len(s.tokens))
+	for value := range s.tokens {
+		if strings.Contains(text, value) {
+			values = append(values, value)
+		}
+	}
+	// Longer values first, so a value containing another is replaced whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, s.tokens[value])
+	}
+	return pathID.ReplaceAllStringFunc(text, func(match string) string {
+		m := pathID.FindStringSubmatch(match)
+		kind := strings.TrimSuffix(m[2], "s")
+		if kind == "principal" && m[3] == strconv.FormatInt(s.userID, 10) {
+			return m[1] + "{user}"
+		}
+		key := kind + ":" + m[3]
+		name, ok := s.ids[key]
+		if !ok {
+			s.counts[kind]++
+			name = "{" + kind + strconv.Itoa(s.counts[kind]) + "}"
+			s.ids[key] = name
+		}
+		return m[1] + name
+	})
+}
+
+func unquoteETag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	for _, quote := range []string{`"`, "&#34;", "&quot;"} {
+		etag = strings.TrimSuffix(strings.TrimPrefix(etag, quote), quote)
+	}
+	return etag
+}
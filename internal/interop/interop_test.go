@@ -0,0 +1,117 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplacesRunSpecificValues(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSession(&buf, 7, "owner@example.com", "https://cal.example.com")
+	err := s.Record(Exchange{
+		Method: "PROPFIND",
+		Path:   "/dav/calendars/42/",
+		Header: map[string]string{"Depth": "1"},
+		Status: http.StatusMultiStatus,
+		ResponseBody: `<d:multistatus><d:response><d:href>/dav/calendars/42/a.ics</d:href>` +
+			`<d:getetag>"0f3c9a1b"</d:getetag><d:getlastmodified>Fri, 16 Oct 2026 10:00:00 GMT</d:getlastmodified>` +
+			`<d:owner><d:href>https://cal.example.com/dav/principals/7/</d:href></d:owner>` +
+			`<d:sharee><d:href>/dav/principals/9/</d:href></d:sharee></d:response>` +
+			`<d:sync-token>urn:calcard-sync:calendar:42:1760608800000000000</d:sync-token></d:multistatus>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Record(Exchange{
+		Method:         "PUT",
+		Path:           "/dav/calendars/42/a.ics",
+		Header:         map[string]string{"If-Match": `"0f3c9a1b"`},
+		Body:           "BEGIN:VCALENDAR\r\nORGANIZER:mailto:owner@example.com\r\nEND:VCALENDAR\r\n",
+		Status:         http.StatusNoContent,
+		ResponseHeader: map[string]string{"ETag": `"77aa01ff"`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchanges, err := ReadSession(&buf)
+	if err != nil || len(exchanges) != 2 {
+		t.Fatalf("ReadSession() = %d exchanges, %v", len(exchanges), err)
+	}
+	propfind, put := exchanges[0], exchanges[1]
+	if propfind.Path != "/dav/calendars/{calendar1}/" {
+		t.Fatalf("path = %q", propfind.Path)
+	}
+	for _, want := range []string{
+		"<d:href>/dav/calendars/{calendar1}/a.ics</d:href>",
+		`<d:getetag>"{etag1}"</d:getetag>`,
+		"<d:getlastmodified>{*}</d:getlastmodified>",
+		"<d:href>{base}/dav/principals/{user}/</d:href>",
+		"<d:href>/dav/principals/{principal1}/</d:href>",
+		"<d:sync-token>{synctoken1}</d:sync-token>",
+	} {
+		if !strings.Contains(propfind.ResponseBody, want) {
+			t.Errorf("response body lacks %s:\n%s", want, propfind.ResponseBody)
+		}
+	}
+	if put.Header["If-Match"] != `"{etag1}"` || put.ResponseHeader["ETag"] != `"{etag2}"` {
+		t.Fatalf("PUT headers = %v, %v", put.Header, put.ResponseHeader)
+	}
+	if !strings.Contains(put.Body, "mailto:{email}") {
+		t.Fatalf("PUT body = %q", put.Body)
+	}
+}
+
+func TestReplayBindsPlaceholdersFromResponses(t *testing.T) {
+	recording := []Exchange{
+		{
+			Method: "PROPFIND", Path: "/dav/calendars/", Status: http.StatusMultiStatus,
+			ResponseBody: `<d:href>/dav/calendars/{calendar1}/</d:href><d:getctag>{ctag1}</d:getctag><d:getlastmodified>{*}</d:getlastmodified>`,
+		},
+		{
+			Method: "PUT", Path: "/dav/calendars/{calendar1}/a.ics", Body: "ORGANIZER:mailto:{email}",
+			Status: http.StatusCreated, ResponseHeader: map[string]string{"ETag": `"{etag1}"`},
+		},
+		{
+			Method: "GET", Path: "/dav/calendars/{calendar1}/a.ics", Header: map[string]string{"If-None-Match": `"{etag1}"`},
+			Status: http.StatusNotModified,
+		},
+	}
+	var sent []string
+	send := func(_ context.Context, method, path string, header map[string]string, body string) (*Response, error) {
+		sent = append(sent, method+" "+path+" "+header["If-None-Match"]+body)
+		switch method {
+		case "PROPFIND":
+			return &Response{Status: http.StatusMultiStatus, Header: http.Header{}, Body: `<d:href>/dav/calendars/3/</d:href><d:getctag>c-991</d:getctag><d:getlastmodified>Sat, 17 Oct 2026 08:00:00 GMT</d:getlastmodified>`}, nil
+		case "PUT":
+			return &Response{Status: http.StatusCreated, Header: http.Header{"Etag": {`"e-123"`}}}, nil
+		default:
+			return &Response{Status: http.StatusNotModified, Header: http.Header{}}, nil
+		}
+	}
+
+	bindings := map[string]string{"base": "https://new.example.com", "email": "tester@example.com"}
+	if err := Replay(context.Background(), recording, bindings, send); err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	want := []string{
+		"PROPFIND /dav/calendars/ ",
+		"PUT /dav/calendars/3/a.ics ORGANIZER:mailto:tester@example.com",
+		`GET /dav/calendars/3/a.ics "e-123"`,
+	}
+	if strings.Join(sent, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("sent:\n%s", strings.Join(sent, "\n"))
+	}
+
+	recording[0].ResponseBody = `<d:href>/dav/calendars/{calendar1}/</d:href>` + "\n" + `<d:displayname>Work</d:displayname>`
+	sent = nil
+	err := Replay(context.Background(), recording, map[string]string{}, func(context.Context, string, string, map[string]string, string) (*Response, error) {
+		return &Response{Status: http.StatusMultiStatus, Header: http.Header{}, Body: "<d:href>/dav/calendars/3/</d:href>\n<d:displayname>Home</d:displayname>"}, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), `request 1 (PROPFIND /dav/calendars/): body differs: line 2 is "<d:displayname>Home</d:displayname>"`) {
+		t.Fatalf("Replay() = %v", err)
+	}
+}
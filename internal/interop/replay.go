@@ -0,0 +1,159 @@
+package interop
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Response is what the server under test answered to a replayed request.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// Sender sends one replayed request to the server under test. path is
+// relative to the server's root.
+type Sender func(ctx context.Context, method, path string, header map[string]string, body string) (*Response, error)
+
+// Replay sends the requests of a recording in order and returns an error
+// describing the first response that differs from the one recorded.
+// bindings holds the known placeholder values, at least "base" and "email";
+// Replay adds the values it learns from responses to it.
+func Replay(ctx context.Context, exchanges []Exchange, bindings map[string]string, send Sender) error {
+	for i, ex := range exchanges {
+		if ex.Truncated {
+			return fmt.Errorf("request %d (%s): the recording was truncated and cannot be replayed", i+1, ex.Method)
+		}
+		path, err := expand(ex.Path, bindings)
+		if err == nil {
+			ex.Body, err = expand(ex.Body, bindings)
+		}
+		header := map[string]string{}
+		for name, value := range ex.Header {
+			if err != nil {
+				break
+			}
+			header[name], err = expand(value, bindings)
+		}
+		if err != nil {
+			return fmt.Errorf("request %d (%s %s): %w", i+1, ex.Method, ex.Path, err)
+		}
+		resp, err := send(ctx, ex.Method, path, header, ex.Body)
+		if err != nil {
+			return fmt.Errorf("request %d (%s %s): %w", i+1, ex.Method, ex.Path, err)
+		}
+		if err := compare(ex, resp, bindings); err != nil {
+			return fmt.Errorf("request %d (%s %s): %w", i+1, ex.Method, ex.Path, err)
+		}
+	}
+	return nil
+}
+
+func compare(ex Exchange, resp *Response, bindings map[string]string) error {
+	if resp.Status != ex.Status {
+		return fmt.Errorf("status %d, recorded %d", resp.Status, ex.Status)
+	}
+	for _, name := range ResponseHeaders {
+		recorded, ok := ex.ResponseHeader[name]
+		live := strings.Join(resp.Header.Values(name), ", ")
+		if !ok {
+			continue
+		}
+		if !match(recorded, live, bindings) {
+			return fmt.Errorf("%s header %q, recorded %q", name, live, recorded)
+		}
+	}
+	if !match(ex.ResponseBody, resp.Body, bindings) {
+		return fmt.Errorf("body differs: %s", firstDifference(ex.ResponseBody, resp.Body, bindings))
+	}
+	return nil
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z]+[0-9]*|\*)\}`)
+
+// expand replaces the placeholders in a recorded request with their values.
+func expand(text string, bindings map[string]string) (string, error) {
+	var missing string
+	text = placeholder.ReplaceAllStringFunc(text, func(p string) string {
+		name := p[1 : len(p)-1]
+		value, ok := bindings[name]
+		if !ok && missing == "" {
+			missing = p
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("no earlier response gave a value for %s", missing)
+	}
+	return text, nil
+}
+
+// match reports whether live fits the recorded text, binding the
+// placeholders seen for the first time to the values in live.
+func match(recorded, live string, bindings map[string]string) bool {
+	var pattern strings.Builder
+	pattern.WriteString(`(?s)^`)
+	var groups []string
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(recorded, -1) {
+		pattern.WriteString(regexp.QuoteMeta(recorded[last:loc[0]]))
+		last = loc[1]
+		name := recorded[loc[2]:loc[3]]
+		if name == "*" {
+			pattern.WriteString(`[^\r\n<"]*?`)
+			continue
+		}
+		if value, ok := bindings[name]; ok {
+			pattern.WriteString(regexp.QuoteMeta(value))
+			continue
+		}
+		pattern.WriteString(`([^\s"'<>&/]+)`)
+		groups = append(groups, name)
+	}
+	pattern.WriteString(regexp.QuoteMeta(recorded[last:]))
+	pattern.WriteString(`$`)
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return false
+	}
+	m := re.FindStringSubmatch(live)
+	if m == nil {
+		return false
+	}
+	learned := map[string]string{}
+	for i, name := range groups {
+		if value, ok := learned[name]; ok && value != m[i+1] {
+			return false
+		}
+		learned[name] = m[i+1]
+	}
+	for name, value := range learned {
+		bindings[name] = value
+	}
+	return true
+}
+
+// firstDifference describes the first line of live that does not fit the
+// recording.
+func firstDifference(recorded, live string, bindings map[string]string) string {
+	scratch := make(map[string]string, len(bindings))
+	for name, value := range bindings {
+		scratch[name] = value
+	}
+	recordedLines := strings.Split(recorded, "\n")
+	liveLines := strings.Split(live, "\n")
+	for i, line := range recordedLines {
+		if i >= len(liveLines) {
+			return fmt.Sprintf("line %d missing, recorded %q", i+1, line)
+		}
+		if !match(line, liveLines[i], scratch) {
+			return fmt.Sprintf("line %d is %q, recorded %q", i+1, liveLines[i], line)
+		}
+	}
+	return fmt.Sprintf("%d lines, recorded %d", len(liveLines), len(recordedLines))
+}
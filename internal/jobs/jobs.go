@@ -0,0 +1,233 @@
+// Package jobs runs long operations such as imports and backups in the
+// background and keeps their progress in the database, so clients can poll
+// it and a restart does not lose it.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Kinds of job.
+const (
+	KindCalendarImport = "calendar-import"
+	KindBackup         = "backup"
+)
+
+// MaxItemErrors bounds the item errors kept per job; later ones are only
+// counted in Processed.
+const MaxItemErrors = 1000
+
+// flushInterval is how often progress is saved while a job runs.
+const flushInterval = time.Second
+
+// Func is the work of a job. It reports progress through p and returns a
+// result that is saved as JSON when it succeeds.
+type Func func(ctx context.Context, p *Progress) (any, error)
+
+// ErrNotResumable is returned when resuming a job that was not interrupted
+// or is already running again.
+var ErrNotResumable = errors.New("job is not interrupted")
+
+// Runner starts jobs and saves their progress.
+type Runner struct {
+	repo store.JobRepository
+	log  *logging.Logger
+	now  func() time.Time
+	wg   sync.WaitGroup
+
+	mu sync.Mutex
+	// cancels stops the jobs running in this process, by ID.
+	cancels map[string]context.CancelFunc
+}
+
+// NewRunner returns a Runner that keeps jobs in repo.
+func NewRunner(repo store.JobRepository, sink logging.Sink) *Runner {
+	return &Runner{repo: repo, log: logging.New(sink, "jobs"), now: time.Now, cancels: map[string]context.CancelFunc{}}
+}
+
+// Recover marks the jobs a previous run of the server left running as
+// interrupted. Call it once at startup, before starting jobs.
+func (r *Runner) Recover(ctx context.Context) error {
+	n, err := r.repo.MarkInterrupted(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.log.Warn("Recover", "marked %d jobs interrupted by the restart", n)
+	}
+	return nil
+}
+
+// Start records a running job of kind for userID and runs fn in the
+// background. The job outlives the request that started it.
+func (r *Runner) Start(ctx context.Context, userID int64, kind string, fn Func) (*store.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job, err := r.repo.Create(ctx, store.Job{ID: id, UserID: userID, Kind: kind, Status: store.JobRunning})
+	if err != nil {
+		return nil, err
+	}
+	r.run(*job, fn)
+	return job, nil
+}
+
+// Resume runs fn again for an interrupted job, keeping its ID and the
+// progress and item errors it had saved. fn must skip the work the job had
+// already done.
+func (r *Runner) Resume(ctx context.Context, job store.Job, fn Func) (*store.Job, error) {
+	r.mu.Lock()
+	_, running := r.cancels[job.ID]
+	r.mu.Unlock()
+	if job.Status != store.JobInterrupted || running {
+		return nil, ErrNotResumable
+	}
+	job.Status = store.JobRunning
+	job.Error = ""
+	job.FinishedAt = nil
+	if err := r.repo.Update(ctx, job); err != nil {
+		return nil, err
+	}
+	r.run(job, fn)
+	return &job, nil
+}
+
+// Abort stops a job running in this process. The job finishes as aborted
+// once fn returns. It reports false when no such job is running.
+func (r *Runner) Abort(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cancel, ok := r.cancels[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (r *Runner) run(job store.Job, fn Func) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancels[job.ID] = cancel
+	r.mu.Unlock()
+	p := &Progress{runner: r, job: job, flushed: r.now()}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		result, err := fn(ctx, p)
+		if err != nil && ctx.Err() != nil {
+			err = context.Canceled
+		}
+		r.mu.Lock()
+		delete(r.cancels, job.ID)
+		r.mu.Unlock()
+		cancel()
+		p.finish(result, err)
+	}()
+}
+
+// Wait blocks until every started job has finished.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Progress is a running job's view of its own record.
+type Progress struct {
+	runner  *Runner
+	mu      sync.Mutex
+	job     store.Job
+	flushed time.Time
+}
+
+// ID returns the ID of the job.
+func (p *Progress) ID() string {
+	return p.job.ID
+}
+
+// SetTotal sets how many items the job will process.
+func (p *Progress) SetTotal(n int) {
+	p.mu.Lock()
+	p.job.Total = n
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+// Advance counts one processed item.
+func (p *Progress) Advance() {
+	p.mu.Lock()
+	p.job.Processed++
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+// ItemFailed counts one processed item that failed without failing the job.
+func (p *Progress) ItemFailed(item string, err error) {
+	p.mu.Lock()
+	p.job.Processed++
+	if len(p.job.Errors) < MaxItemErrors {
+		p.job.Errors = append(p.job.Errors, store.JobItemError{Item: item, Error: err.Error()})
+	}
+	p.mu.Unlock()
+	p.flush(false)
+}
+
+func (p *Progress) finish(result any, err error) {
+	p.mu.Lock()
+	finished := p.runner.now().UTC()
+	p.job.FinishedAt = &finished
+	switch {
+	case errors.Is(err, context.Canceled):
+		p.job.Status = store.JobAborted
+		p.job.Error = "aborted"
+	case err != nil:
+		p.job.Status = store.JobFailed
+		p.job.Error = err.Error()
+	default:
+		p.job.Status = store.JobSucceeded
+		if result != nil {
+			raw, marshalErr := json.Marshal(result)
+			if marshalErr != nil {
+				p.job.Status = store.JobFailed
+				p.job.Error = marshalErr.Error()
+			} else {
+				p.job.Result = raw
+			}
+		}
+	}
+	p.mu.Unlock()
+	p.flush(true)
+}
+
+// flush saves the job, at most once per flushInterval unless forced.
+func (p *Progress) flush(force bool) {
+	p.mu.Lock()
+	now := p.runner.now()
+	if !force && now.Sub(p.flushed) < flushInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.flushed = now
+	job := p.job
+	job.Errors = append([]store.JobItemError(nil), p.job.Errors...)
+	p.mu.Unlock()
+	if err := p.runner.repo.Update(context.Background(), job); err != nil {
+		p.runner.log.Error("flush", "failed to save progress of job %s: %v", job.ID, err)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
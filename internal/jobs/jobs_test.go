@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeJobRepo struct {
+	store.JobRepository
+	mu      sync.Mutex
+	jobs    map[string]store.Job
+	updates int
+}
+
+func (f *fakeJobRepo) Create(_ context.Context, job store.Job) (*store.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.jobs == nil {
+		f.jobs = map[string]store.Job{}
+	}
+	f.jobs[job.ID] = job
+	return &job, nil
+}
+
+func (f *fakeJobRepo) Update(_ context.Context, job store.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs[job.ID] = job
+	f.updates++
+	return nil
+}
+
+func TestRunnerSavesProgressAndOutcome(t *testing.T) {
+	repo := &fakeJobRepo{}
+	runner := NewRunner(repo, nil)
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	runner.now = func() time.Time { return clock }
+
+	job, err := runner.Start(context.Background(), 4, KindCalendarImport, func(ctx context.Context, p *Progress) (any, error) {
+		p.SetTotal(3)
+		p.Advance()
+		p.ItemFailed("b", errors.New("invalid"))
+		p.Advance()
+		return map[string]int{"created": 2}, nil
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if len(job.ID) != 32 || job.Status != store.JobRunning || job.UserID != 4 {
+		t.Fatalf("Start() = %+v", job)
+	}
+	failed, err := runner.Start(context.Background(), 4, KindBackup, func(context.Context, *Progress) (any, error) {
+		return nil, errors.New("storage unreachable")
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	runner.Wait()
+
+	got := repo.jobs[job.ID]
+	if got.Status != store.JobSucceeded || got.Total != 3 || got.Processed != 3 || got.FinishedAt == nil {
+		t.Fatalf("finished job = %+v", got)
+	}
+	if len(got.Errors) != 1 || got.Errors[0] != (store.JobItemError{Item: "b", Error: "invalid"}) {
+		t.Fatalf("item errors = %+v", got.Errors)
+	}
+	var result map[string]int
+	if err := json.Unmarshal(got.Result, &result); err != nil || result["created"] != 2 {
+		t.Fatalf("result = %s, %v", got.Result, err)
+	}
+	// With the clock stopped, only the final state of each job is saved.
+	if repo.updates != 2 {
+		t.Fatalf("saved %d times, want 2", repo.updates)
+	}
+	if got := repo.jobs[failed.ID]; got.Status != store.JobFailed || got.Error != "storage unreachable" {
+		t.Fatalf("failed job = %+v", got)
+	}
+}
+
+func TestRunnerAbortsAndResumes(t *testing.T) {
+	repo := &fakeJobRepo{}
+	runner := NewRunner(repo, nil)
+
+	started := make(chan struct{})
+	job, err := runner.Start(context.Background(), 4, KindCalendarImport, func(ctx context.Context, p *Progress) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, errors.New("import stopped")
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	<-started
+	if !runner.Abort(job.ID) {
+		t.Fatal("Abort() of a running job = false")
+	}
+	runner.Wait()
+	if got := repo.jobs[job.ID]; got.Status != store.JobAborted || got.FinishedAt == nil {
+		t.Fatalf("aborted job = %+v", got)
+	}
+	if runner.Abort(job.ID) {
+		t.Fatal("Abort() of a finished job = true")
+	}
+
+	if _, err := runner.Resume(context.Background(), repo.jobs[job.ID], nil); !errors.Is(err, ErrNotResumable) {
+		t.Fatalf("Resume() of an aborted job error = %v", err)
+	}
+	interrupted := store.Job{ID: "i", UserID: 4, Kind: KindCalendarImport, Status: store.JobInterrupted, Total: 2, Processed: 1}
+	resumed, err := runner.Resume(context.Background(), interrupted, func(ctx context.Context, p *Progress) (any, error) {
+		if p.ID() != "i" {
+			t.Errorf("resumed job ID = %q", p.ID())
+		}
+		p.Advance()
+		return nil, nil
+	})
+	if err != nil || resumed.Status != store.JobRunning || resumed.FinishedAt != nil {
+		t.Fatalf("Resume() = %+v, %v", resumed, err)
+	}
+	runner.Wait()
+	if got := repo.jobs["i"]; got.Status != store.JobSucceeded || got.Processed != 2 {
+		t.Fatalf("resumed job = %+v", got)
+	}
+}
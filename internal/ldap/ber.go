@@ -0,0 +1,193 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER identifier classes and the universal tags LDAP uses (X.690).
+const (
+	classUniversal   byte = 0x00
+	classApplication byte = 0x40
+	classContext     byte = 0x80
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10
+	tagSet         = 0x11
+)
+
+// maxMessageBytes bounds a single LDAP message read from a client.
+const maxMessageBytes = 1 << 20
+
+var errMalformed = errors.New("ldap: malformed BER")
+
+// packet is one BER element. Primitive elements carry value; constructed
+// ones carry children.
+type packet struct {
+	class       byte
+	constructed bool
+	tag         int
+	value       []byte
+	children    []*packet
+}
+
+// readMessage reads the bytes of one top-level element from r.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	ident, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	header := []byte{ident, first}
+	length := int(first)
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, errMalformed
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			header = append(header, b)
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageBytes {
+		return nil, fmt.Errorf("ldap: message of %d bytes exceeds limit", length)
+	}
+	buf := make([]byte, len(header)+length)
+	copy(buf, header)
+	if _, err := io.ReadFull(r, buf[len(header):]); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// decodePacket parses the element at the start of data and returns it with
+// the number of bytes it used.
+func decodePacket(data []byte) (*packet, int, error) {
+	if len(data) < 2 {
+		return nil, 0, errMalformed
+	}
+	p := &packet{class: data[0] & 0xc0, constructed: data[0]&0x20 != 0, tag: int(data[0] & 0x1f)}
+	if p.tag == 0x1f {
+		// LDAP never uses high tag numbers.
+		return nil, 0, errMalformed
+	}
+	pos := 2
+	length := int(data[1])
+	if data[1]&0x80 != 0 {
+		n := int(data[1] & 0x7f)
+		if n == 0 || n > 4 || len(data) < 2+n {
+			return nil, 0, errMalformed
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		pos += n
+	}
+	if length < 0 || len(data)-pos < length {
+		return nil, 0, errMalformed
+	}
+	body := data[pos : pos+length]
+	if !p.constructed {
+		p.value = body
+		return p, pos + length, nil
+	}
+	for len(body) > 0 {
+		child, n, err := decodePacket(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		p.children = append(p.children, child)
+		body = body[n:]
+	}
+	return p, pos + length, nil
+}
+
+func (p *packet) is(class byte, tag int) bool {
+	return p != nil && p.class == class && p.tag == tag
+}
+
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+// int decodes an INTEGER or ENUMERATED value.
+func (p *packet) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errMalformed
+	}
+	v := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (p *packet) encode() []byte {
+	body := p.value
+	if p.constructed {
+		body = nil
+		for _, c := range p.children {
+			body = append(body, c.encode()...)
+		}
+	}
+	ident := p.class | byte(p.tag)
+	if p.constructed {
+		ident |= 0x20
+	}
+	out := []byte{ident}
+	switch n := len(body); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, body...)
+}
+
+func constructed(class byte, tag int, children ...*packet) *packet {
+	return &packet{class: class, constructed: true, tag: tag, children: children}
+}
+
+func sequence(children ...*packet) *packet {
+	return constructed(classUniversal, tagSequence, children...)
+}
+
+func set(children ...*packet) *packet {
+	return constructed(classUniversal, tagSet, children...)
+}
+
+func octetString(s string) *packet {
+	return &packet{class: classUniversal, tag: tagOctetString, value: []byte(s)}
+}
+
+func integer(tag int, v int64) *packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		if (v >= -128 && v < 128) || len(b) == 8 {
+			break
+		}
+		v >>= 8
+	}
+	return &packet{class: classUniversal, tag: tag, value: b}
+}
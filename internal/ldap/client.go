@@ -0,0 +1,112 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is returned by Bind when the directory rejects the
+// DN and password.
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+// BindDN returns template with {username} replaced by username, escaped as a
+// DN attribute value.
+func BindDN(template, username string) string {
+	return strings.ReplaceAll(template, "{username}", escapeDNValue(username))
+}
+
+// Bind checks a password by making a simple bind as dn to the directory at
+// rawURL, an ldaps:// or ldap:// URL, and then disconnects. An empty password
+// is refused rather than sent, since directories treat it as an anonymous
+// bind that succeeds.
+func Bind(ctx context.Context, rawURL, dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+	host := u.Host
+	var dialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+	switch u.Scheme {
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		dialer = &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		dialer = &net.Dialer{}
+	default:
+		return fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(idleTimeout))
+	}
+	return bindConn(conn, dn, password)
+}
+
+// bindConn sends a simple bind request over conn, reads the response and
+// unbinds.
+func bindConn(conn net.Conn, dn, password string) error {
+	const bindID, unbindID = 1, 2
+	req := constructed(classApplication, opBindRequest,
+		integer(tagInteger, 3),
+		octetString(dn),
+		&packet{class: classContext, tag: 0, value: []byte(password)},
+	)
+	if _, err := conn.Write(sequence(integer(tagInteger, bindID), req).encode()); err != nil {
+		return err
+	}
+	raw, err := readMessage(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	msg, _, err := decodePacket(raw)
+	if err != nil {
+		return err
+	}
+	if len(msg.children) < 2 {
+		return errMalformed
+	}
+	if id, err := msg.children[0].int(); err != nil || id != bindID {
+		return errMalformed
+	}
+	resp := msg.children[1]
+	if !resp.is(classApplication, opBindResponse) || len(resp.children) < 3 {
+		return errMalformed
+	}
+	code, err := resp.children[0].int()
+	if err != nil {
+		return err
+	}
+	unbind := &packet{class: classApplication, tag: opUnbindRequest}
+	_, _ = conn.Write(sequence(integer(tagInteger, unbindID), unbind).encode())
+	switch code {
+	case resultSuccess:
+		return nil
+	case resultInvalidCredentials:
+		return ErrInvalidCredentials
+	default:
+		return fmt.Errorf("ldap: bind failed with result %d: %s", code, resp.children[2].str())
+	}
+}
@@ -0,0 +1,304 @@
+package ldap
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// The tree below the base DN:
+//
+//	ou=addressbooks,<base>                     the bound user's address books
+//	ou=<book id>,ou=addressbooks,<base>        one address book
+//	uid=<contact uid>,ou=<book id>,ou=addressbooks,<base>
+//	ou=directory,<base>                        the organization's users
+//	uid=<email>,ou=directory,<base>
+const (
+	addressBooksRDN = "ou=addressbooks"
+	directoryRDN    = "ou=directory"
+)
+
+type attribute struct {
+	name   string
+	values []string
+}
+
+// entry is one object in the tree. Attribute names keep their usual casing
+// for responses; lookups are case-insensitive.
+type entry struct {
+	dn    string
+	attrs []attribute
+}
+
+func (e *entry) add(name string, values ...string) {
+	var kept []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+	for i := range e.attrs {
+		if strings.EqualFold(e.attrs[i].name, name) {
+			e.attrs[i].values = append(e.attrs[i].values, kept...)
+			return
+		}
+	}
+	e.attrs = append(e.attrs, attribute{name: name, values: kept})
+}
+
+// values returns the values of the attribute with the lower-cased name key.
+func (e *entry) values(key string) []string {
+	for _, a := range e.attrs {
+		if strings.ToLower(a.name) == key {
+			return a.values
+		}
+	}
+	return nil
+}
+
+func organizationalUnit(dn, ou, description string) *entry {
+	e := &entry{dn: dn}
+	e.add("objectClass", "top", "organizationalUnit")
+	e.add("ou", ou)
+	e.add("description", description)
+	return e
+}
+
+func baseEntry(baseDN string) *entry {
+	e := &entry{dn: baseDN}
+	e.add("objectClass", "top")
+	if name, value, ok := strings.Cut(firstRDN(baseDN), "="); ok {
+		e.add(name, value)
+	}
+	return e
+}
+
+func addressBookDN(baseDN string, bookID int64) string {
+	return "ou=" + strconv.FormatInt(bookID, 10) + "," + addressBooksRDN + "," + baseDN
+}
+
+// userEntry is a directory entry for a calcard user. Users have no name
+// beyond their email address.
+func userEntry(baseDN string, u store.User) *entry {
+	e := &entry{dn: "uid=" + escapeDNValue(u.PrimaryEmail) + "," + directoryRDN + "," + baseDN}
+	e.add("objectClass", "top", "person", "organizationalPerson", "inetOrgPerson")
+	e.add("uid", u.PrimaryEmail)
+	e.add("cn", u.PrimaryEmail)
+	e.add("displayName", u.PrimaryEmail)
+	e.add("sn", strings.SplitN(u.PrimaryEmail, "@", 2)[0])
+	e.add("mail", u.PrimaryEmail)
+	return e
+}
+
+// contactEntry maps a vCard onto inetOrgPerson attributes.
+func contactEntry(baseDN string, c store.Contact) *entry {
+	e := &entry{dn: "uid=" + escapeDNValue(c.UID) + "," + addressBookDN(baseDN, c.AddressBookID)}
+	e.add("objectClass", "top", "person", "organizationalPerson", "inetOrgPerson")
+	e.add("uid", c.UID)
+	fn := unescapeValue(vcardValue(c.RawVCard, "FN"))
+	var given, family string
+	if n := vcardValue(c.RawVCard, "N"); n != "" {
+		parts := splitStructured(n)
+		family = parts[0]
+		if len(parts) > 1 {
+			given = parts[1]
+		}
+	}
+	if fn == "" {
+		fn = strings.TrimSpace(given + " " + family)
+	}
+	e.add("cn", fn)
+	e.add("displayName", fn)
+	e.add("givenName", given)
+	// inetOrgPerson requires sn.
+	if family == "" {
+		family = fn
+	}
+	e.add("sn", family)
+	for _, line := range utils.ExtractVCardPropertyLines(c.RawVCard, "EMAIL") {
+		_, value := splitLine(line)
+		e.add("mail", value)
+	}
+	for _, line := range utils.ExtractVCardPropertyLines(c.RawVCard, "TEL") {
+		params, value := splitLine(line)
+		value = strings.TrimPrefix(value, "tel:")
+		switch {
+		case hasType(params, "cell"):
+			e.add("mobile", value)
+		case hasType(params, "fax"):
+			e.add("facsimileTelephoneNumber", value)
+		case hasType(params, "home"):
+			e.add("homePhone", value)
+		default:
+			e.add("telephoneNumber", value)
+		}
+	}
+	if org := vcardValue(c.RawVCard, "ORG"); org != "" {
+		parts := splitStructured(org)
+		e.add("o", parts[0])
+		if len(parts) > 1 {
+			e.add("ou", parts[1])
+		}
+	}
+	e.add("title", unescapeValue(vcardValue(c.RawVCard, "TITLE")))
+	e.add("labeledURI", unescapeValue(vcardValue(c.RawVCard, "URL")))
+	e.add("description", unescapeValue(vcardValue(c.RawVCard, "NOTE")))
+	return e
+}
+
+func vcardValue(raw, name string) string {
+	lines := utils.ExtractVCardPropertyLines(raw, name)
+	if len(lines) == 0 {
+		return ""
+	}
+	_, value := splitLine(lines[0])
+	return value
+}
+
+// unescapeValue undoes vCard TEXT escaping.
+func unescapeValue(v string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(v)
+}
+
+// splitLine returns the upper-cased parameters and the value of an unfolded
+// content line.
+func splitLine(line string) (string, string) {
+	inQuotes := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ':' && !inQuotes:
+			head := line[:i]
+			params := ""
+			if idx := strings.Index(head, ";"); idx >= 0 {
+				params = strings.ToUpper(head[idx:])
+			}
+			return params, strings.TrimSpace(line[i+1:])
+		}
+	}
+	return "", ""
+}
+
+func hasType(params, typ string) bool {
+	typ = strings.ToUpper(typ)
+	for _, p := range strings.Split(params, ";") {
+		name, values, ok := strings.Cut(p, "=")
+		if !ok {
+			// vCard 2.1 style bare types such as ";CELL".
+			if p == typ {
+				return true
+			}
+			continue
+		}
+		if name != "TYPE" {
+			continue
+		}
+		for _, v := range strings.Split(strings.Trim(values, `"`), ",") {
+			if v == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitStructured splits a structured value on unescaped semicolons and
+// unescapes each component.
+func splitStructured(v string) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v):
+			i++
+			if v[i] == 'n' || v[i] == 'N' {
+				cur.WriteByte('\n')
+			} else {
+				cur.WriteByte(v[i])
+			}
+		case v[i] == ';':
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(v[i])
+		}
+	}
+	return append(parts, strings.TrimSpace(cur.String()))
+}
+
+// escapeDNValue escapes an attribute value for use in a DN (RFC 4514 §2.4).
+func escapeDNValue(v string) string {
+	var b strings.Builder
+	for i, r := range v {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			(i == 0 && (r == ' ' || r == '#')),
+			(i == len(v)-1 && r == ' '):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeDN lower-cases a DN and drops the spaces around separators so
+// equivalent spellings compare equal. Escaped characters are kept.
+func normalizeDN(dn string) string {
+	var rdns []string
+	for _, rdn := range splitDN(dn) {
+		name, value, _ := strings.Cut(rdn, "=")
+		rdns = append(rdns, strings.ToLower(strings.TrimSpace(name))+"="+strings.ToLower(strings.TrimSpace(value)))
+	}
+	return strings.Join(rdns, ",")
+}
+
+// splitDN splits a DN into its RDNs on unescaped commas.
+func splitDN(dn string) []string {
+	dn = strings.TrimSpace(dn)
+	if dn == "" {
+		return nil
+	}
+	var rdns []string
+	start := 0
+	for i := 0; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case ',':
+			rdns = append(rdns, dn[start:i])
+			start = i + 1
+		}
+	}
+	return append(rdns, dn[start:])
+}
+
+func firstRDN(dn string) string {
+	if rdns := splitDN(dn); len(rdns) > 0 {
+		return strings.TrimSpace(rdns[0])
+	}
+	return ""
+}
+
+// rdnValue returns the unescaped value of the first RDN of dn.
+func rdnValue(dn string) string {
+	_, v, ok := strings.Cut(firstRDN(dn), "=")
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) {
+			i++
+		}
+		b.WriteByte(v[i])
+	}
+	return strings.TrimSpace(b.String())
+}
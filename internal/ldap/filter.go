@@ -0,0 +1,187 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter choices of a SearchRequest (RFC 4511 §4.5.1.7).
+const (
+	filterAnd            = 0
+	filterOr             = 1
+	filterNot            = 2
+	filterEqualityMatch  = 3
+	filterSubstrings     = 4
+	filterGreaterOrEqual = 5
+	filterLessOrEqual    = 6
+	filterPresent        = 7
+	filterApproxMatch    = 8
+	filterExtensible     = 9
+)
+
+// filter reports whether an entry matches a decoded search filter.
+type filter func(e *entry) bool
+
+// attributeAliases maps alternative attribute names clients send to the
+// names entries are built with.
+var attributeAliases = map[string]string{
+	"commonname":             "cn",
+	"surname":                "sn",
+	"gn":                     "givenname",
+	"rfc822mailbox":          "mail",
+	"mobiletelephonenumber":  "mobile",
+	"organizationname":       "o",
+	"organizationalunitname": "ou",
+	"hometelephonenumber":    "homephone",
+}
+
+// phoneAttributes compare with telephoneNumberMatch semantics (RFC 4517
+// §4.2.29): spaces and punctuation are ignored.
+var phoneAttributes = map[string]bool{
+	"telephonenumber":          true,
+	"mobile":                   true,
+	"homephone":                true,
+	"facsimiletelephonenumber": true,
+}
+
+// attributeKey returns the lower-cased attribute name a description refers
+// to, without options such as ";binary".
+func attributeKey(desc string) string {
+	name, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(desc)), ";")
+	if alias, ok := attributeAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// matchValue normalizes an assertion or attribute value for comparison.
+func matchValue(attr, v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if phoneAttributes[attr] {
+		v = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(" -.()/", r) {
+				return -1
+			}
+			return r
+		}, v)
+	}
+	return v
+}
+
+func parseFilter(p *packet) (filter, error) {
+	if p == nil || p.class != classContext {
+		return nil, errMalformed
+	}
+	switch p.tag {
+	case filterAnd, filterOr:
+		if !p.constructed {
+			return nil, errMalformed
+		}
+		subs := make([]filter, 0, len(p.children))
+		for _, c := range p.children {
+			f, err := parseFilter(c)
+			if err != nil {
+				return nil, err
+			}
+			subs = append(subs, f)
+		}
+		if p.tag == filterAnd {
+			return func(e *entry) bool {
+				for _, f := range subs {
+					if !f(e) {
+						return false
+					}
+				}
+				return true
+			}, nil
+		}
+		return func(e *entry) bool {
+			for _, f := range subs {
+				if f(e) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case filterNot:
+		if !p.constructed || len(p.children) != 1 {
+			return nil, errMalformed
+		}
+		f, err := parseFilter(p.children[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(e *entry) bool { return !f(e) }, nil
+	case filterPresent:
+		attr := attributeKey(p.str())
+		return func(e *entry) bool { return len(e.values(attr)) > 0 }, nil
+	case filterEqualityMatch, filterApproxMatch, filterGreaterOrEqual, filterLessOrEqual:
+		if !p.constructed || len(p.children) != 2 {
+			return nil, errMalformed
+		}
+		attr := attributeKey(p.children[0].str())
+		want := matchValue(attr, p.children[1].str())
+		tag := p.tag
+		return func(e *entry) bool {
+			for _, v := range e.values(attr) {
+				got := matchValue(attr, v)
+				switch {
+				case tag == filterGreaterOrEqual && got >= want,
+					tag == filterLessOrEqual && got <= want,
+					(tag == filterEqualityMatch || tag == filterApproxMatch) && got == want:
+					return true
+				}
+			}
+			return false
+		}, nil
+	case filterSubstrings:
+		if !p.constructed || len(p.children) != 2 {
+			return nil, errMalformed
+		}
+		attr := attributeKey(p.children[0].str())
+		var initial, final string
+		var middle []string
+		for _, c := range p.children[1].children {
+			v := matchValue(attr, c.str())
+			switch c.tag {
+			case 0:
+				initial = v
+			case 1:
+				middle = append(middle, v)
+			case 2:
+				final = v
+			default:
+				return nil, errMalformed
+			}
+		}
+		return func(e *entry) bool {
+			for _, v := range e.values(attr) {
+				if substringsMatch(matchValue(attr, v), initial, middle, final) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case filterExtensible:
+		// Matching rules are not supported; such a filter is Undefined and
+		// matches nothing.
+		return func(*entry) bool { return false }, nil
+	default:
+		return nil, fmt.Errorf("ldap: unknown filter choice %d", p.tag)
+	}
+}
+
+func substringsMatch(v, initial string, middle []string, final string) bool {
+	if !strings.HasPrefix(v, initial) {
+		return false
+	}
+	v = v[len(initial):]
+	for _, part := range middle {
+		idx := strings.Index(v, part)
+		if idx < 0 {
+			return false
+		}
+		v = v[idx+len(part):]
+	}
+	return strings.HasSuffix(v, final)
+}
@@ -0,0 +1,436 @@
+// Package ldap is a read-only LDAPv3 front end for address books. Many desk
+// phones and mail clients can only search LDAP directories, so the server
+// answers binds with the same app passwords CardDAV uses and searches over
+// the bound user's address books and the organization's user directory. It
+// only listens over TLS (LDAPS); every write operation is refused.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/http/ratelimit"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/time/rate"
+)
+
+const logClass = "LDAP"
+
+// maxSearchResults caps the entries one search returns, whatever size limit
+// the client asks for.
+const maxSearchResults = 500
+
+// idleTimeout closes connections that send nothing for this long.
+const idleTimeout = 5 * time.Minute
+
+// Protocol operations (RFC 4511 §4.2–4.12), as APPLICATION tags.
+const (
+	opBindRequest      = 0
+	opBindResponse     = 1
+	opUnbindRequest    = 2
+	opSearchRequest    = 3
+	opSearchResultEnt  = 4
+	opSearchResultDone = 5
+	opModifyRequest    = 6
+	opAddRequest       = 8
+	opDelRequest       = 10
+	opModifyDNRequest  = 12
+	opCompareRequest   = 14
+	opAbandonRequest   = 16
+	opExtendedRequest  = 23
+	opExtendedResponse = 24
+)
+
+// Result codes (RFC 4511 Appendix A).
+const (
+	resultSuccess                 = 0
+	resultProtocolError           = 2
+	resultSizeLimitExceeded       = 4
+	resultAuthMethodNotSupported  = 7
+	resultNoSuchObject            = 32
+	resultInvalidCredentials      = 49
+	resultInsufficientAccessRight = 50
+	resultBusy                    = 51
+	resultUnwillingToPerform      = 53
+	resultOther                   = 80
+)
+
+// Search scopes.
+const (
+	scopeBaseObject   = 0
+	scopeSingleLevel  = 1
+	scopeWholeSubtree = 2
+)
+
+// Authenticator checks an app password; *auth.Service satisfies it.
+type Authenticator interface {
+	ValidateAppPassword(ctx context.Context, username, password string) (*store.User, error)
+}
+
+// Server accepts LDAPS connections.
+type Server struct {
+	addr      string
+	baseDN    string
+	tlsConfig *tls.Config
+	store     *store.Store
+	contacts  *contacts.Service
+	auth      Authenticator
+	binds     *ratelimit.IPRateLimiter
+	log       *logging.Logger
+	listener  net.Listener
+}
+
+// New returns the LDAP server configured by cfg, or nil when APP_LDAP_ADDR
+// is unset or CardDAV, whose contacts it serves, is turned off.
+func New(cfg *config.Config, st *store.Store, authn Authenticator, sink logging.Sink) (*Server, error) {
+	if cfg.LDAP.Addr == "" || cfg.CardDAVDisabled {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.LDAP.CertFile, cfg.LDAP.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load LDAP certificate: %w", err)
+	}
+	s := newServer(cfg.LDAP.BaseDN, st, authn, sink)
+	s.addr = cfg.LDAP.Addr
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	return s, nil
+}
+
+func newServer(baseDN string, st *store.Store, authn Authenticator, sink logging.Sink) *Server {
+	return &Server{
+		baseDN:   baseDN,
+		store:    st,
+		contacts: contacts.NewService(st),
+		auth:     authn,
+		// Same budget as the HTTP sign-in endpoints: binds are password checks.
+		binds: ratelimit.NewIPRateLimiter(rate.Limit(5), 10, 5*time.Minute, nil),
+		log:   logging.New(sink, logClass),
+	}
+}
+
+// Listen opens the TLS listener, so a port conflict fails startup.
+func (s *Server) Listen() error {
+	ln, err := tls.Listen("tcp", s.addr, s.tlsConfig)
+	if err != nil {
+		return fmt.Errorf("listen for LDAP on %s: %w", s.addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Serve accepts connections until ctx is done.
+func (s *Server) Serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = s.listener.Close()
+	}()
+	s.log.Info("Serve", "LDAPS listening on %s", s.addr)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.log.Warn("Serve", "accept failed: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// session is the state of one connection.
+type session struct {
+	ip   string
+	user *store.User
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	sess := &session{ip: remoteIP(conn.RemoteAddr())}
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		raw, err := readMessage(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.log.Debug("serveConn", "closing connection from %s: %v", sess.ip, err)
+			}
+			return
+		}
+		msg, _, err := decodePacket(raw)
+		if err != nil || !msg.is(classUniversal, tagSequence) || len(msg.children) < 2 {
+			return
+		}
+		id, err := msg.children[0].int()
+		if err != nil {
+			return
+		}
+		op := msg.children[1]
+		if op.class != classApplication {
+			return
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		switch op.tag {
+		case opBindRequest:
+			s.bind(ctx, w, sess, id, op)
+		case opUnbindRequest:
+			return
+		case opSearchRequest:
+			s.search(ctx, w, sess, id, op)
+		case opAbandonRequest:
+			// Searches are answered before the next request is read, so
+			// there is never anything to abandon.
+			continue
+		case opModifyRequest, opAddRequest, opDelRequest, opModifyDNRequest, opCompareRequest:
+			writeResult(w, id, op.tag+1, resultUnwillingToPerform, "", "the directory is read-only")
+		case opExtendedRequest:
+			writeResult(w, id, opExtendedResponse, resultUnwillingToPerform, "", "extended operations are not supported")
+		default:
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) bind(ctx context.Context, w io.Writer, sess *session, id int64, op *packet) {
+	if !op.constructed || len(op.children) < 3 {
+		writeResult(w, id, opBindResponse, resultProtocolError, "", "malformed bind request")
+		return
+	}
+	sess.user = nil
+	if version, err := op.children[0].int(); err != nil || version != 3 {
+		writeResult(w, id, opBindResponse, resultProtocolError, "", "only LDAPv3 is supported")
+		return
+	}
+	name := op.children[1].str()
+	auth := op.children[2]
+	if !auth.is(classContext, 0) || auth.constructed {
+		writeResult(w, id, opBindResponse, resultAuthMethodNotSupported, "", "only simple binds are supported")
+		return
+	}
+	password := auth.str()
+	switch {
+	case name == "" && password == "":
+		// Anonymous bind: only the root DSE is readable.
+		writeResult(w, id, opBindResponse, resultSuccess, "", "")
+		return
+	case password == "":
+		writeResult(w, id, opBindResponse, resultUnwillingToPerform, "", "unauthenticated binds are not allowed")
+		return
+	}
+	if !s.binds.Allow(sess.ip) {
+		writeResult(w, id, opBindResponse, resultBusy, "", "too many bind attempts")
+		return
+	}
+	username := name
+	if strings.Contains(name, "=") {
+		username = rdnValue(name)
+	}
+	user, err := s.auth.ValidateAppPassword(ctx, username, password)
+	if err != nil || user == nil {
+		s.log.Info("bind", "failed bind as %q from %s", username, sess.ip)
+		writeResult(w, id, opBindResponse, resultInvalidCredentials, "", "invalid credentials")
+		return
+	}
+	sess.user = user
+	writeResult(w, id, opBindResponse, resultSuccess, "", "")
+}
+
+func (s *Server) search(ctx context.Context, w io.Writer, sess *session, id int64, op *packet) {
+	if !op.constructed || len(op.children) < 8 {
+		writeResult(w, id, opSearchResultDone, resultProtocolError, "", "malformed search request")
+		return
+	}
+	base := op.children[0].str()
+	scope, err1 := op.children[1].int()
+	sizeLimit, err2 := op.children[3].int()
+	match, err3 := parseFilter(op.children[6])
+	if err := errors.Join(err1, err2, err3); err != nil {
+		writeResult(w, id, opSearchResultDone, resultProtocolError, "", "malformed search request")
+		return
+	}
+	typesOnly := len(op.children[5].value) == 1 && op.children[5].value[0] != 0
+	var requested []string
+	for _, a := range op.children[7].children {
+		requested = append(requested, a.str())
+	}
+
+	if normalizeDN(base) == "" && scope == scopeBaseObject {
+		if root := s.rootDSE(); match(root) {
+			writeEntry(w, id, root, requested, typesOnly)
+		}
+		writeResult(w, id, opSearchResultDone, resultSuccess, "", "")
+		return
+	}
+	if sess.user == nil {
+		writeResult(w, id, opSearchResultDone, resultInsufficientAccessRight, "", "bind required")
+		return
+	}
+
+	entries, err := s.entries(ctx, sess.user, base)
+	if err != nil {
+		s.log.Error("search", "failed to load entries for user %d: %v", sess.user.ID, err)
+		writeResult(w, id, opSearchResultDone, resultOther, "", "failed to load entries")
+		return
+	}
+	nbase := normalizeDN(base)
+	found := false
+	for _, e := range entries {
+		if normalizeDN(e.dn) == nbase {
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeResult(w, id, opSearchResultDone, resultNoSuchObject, s.baseDN, "no such object")
+		return
+	}
+
+	limit := maxSearchResults
+	if sizeLimit > 0 && int(sizeLimit) < limit {
+		limit = int(sizeLimit)
+	}
+	sent := 0
+	for _, e := range entries {
+		if !inScope(normalizeDN(e.dn), nbase, scope) || !match(e) {
+			continue
+		}
+		if sent == limit {
+			writeResult(w, id, opSearchResultDone, resultSizeLimitExceeded, "", "")
+			return
+		}
+		writeEntry(w, id, e, requested, typesOnly)
+		sent++
+	}
+	writeResult(w, id, opSearchResultDone, resultSuccess, "", "")
+}
+
+func (s *Server) rootDSE() *entry {
+	e := &entry{}
+	e.add("objectClass", "top")
+	e.add("namingContexts", s.baseDN)
+	e.add("supportedLDAPVersion", "3")
+	e.add("vendorName", "CalCard")
+	return e
+}
+
+// entries returns the base entry, the containers, and the objects of the
+// subtrees base can reach, as seen by user.
+func (s *Server) entries(ctx context.Context, user *store.User, base string) ([]*entry, error) {
+	nbase := normalizeDN(base)
+	root := normalizeDN(s.baseDN)
+	reaches := func(dn string) bool {
+		n := normalizeDN(dn)
+		return nbase == root || nbase == n || strings.HasSuffix(nbase, ","+n) || strings.HasSuffix(n, ","+nbase)
+	}
+
+	booksDN := addressBooksRDN + "," + s.baseDN
+	dirDN := directoryRDN + "," + s.baseDN
+	result := []*entry{
+		baseEntry(s.baseDN),
+		organizationalUnit(booksDN, "addressbooks", "Your address books"),
+		organizationalUnit(dirDN, "directory", "People in this organization"),
+	}
+	if reaches(booksDN) {
+		books, err := s.contacts.ListAccessibleAddressBooks(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range books {
+			bookDN := addressBookDN(s.baseDN, b.ID)
+			result = append(result, organizationalUnit(bookDN, strconv.FormatInt(b.ID, 10), b.Name))
+			if !reaches(bookDN) {
+				continue
+			}
+			items, err := s.contacts.ListContacts(ctx, user, b.ID, store.ContactFilter{})
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range items {
+				result = append(result, contactEntry(s.baseDN, c))
+			}
+		}
+	}
+	if reaches(dirDN) {
+		users, err := s.store.Users.ListActive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			result = append(result, userEntry(s.baseDN, u))
+		}
+	}
+	return result, nil
+}
+
+// inScope reports whether the normalized dn is within scope of base.
+func inScope(dn, base string, scope int64) bool {
+	switch scope {
+	case scopeBaseObject:
+		return dn == base
+	case scopeSingleLevel:
+		_, parent, _ := strings.Cut(dn, ",")
+		return dn != base && parent == base
+	default:
+		return dn == base || strings.HasSuffix(dn, ","+base)
+	}
+}
+
+func writeResult(w io.Writer, id int64, tag, code int, matchedDN, message string) {
+	op := constructed(classApplication, tag, integer(tagEnumerated, int64(code)), octetString(matchedDN), octetString(message))
+	_, _ = w.Write(sequence(integer(tagInteger, id), op).encode())
+}
+
+// writeEntry sends e with the requested attributes: all of them when none
+// or "*" is requested, none for "1.1".
+func writeEntry(w io.Writer, id int64, e *entry, requested []string, typesOnly bool) {
+	all := len(requested) == 0
+	want := map[string]bool{}
+	for _, r := range requested {
+		if r == "*" {
+			all = true
+		}
+		want[attributeKey(r)] = true
+	}
+	attrs := sequence()
+	for _, a := range e.attrs {
+		if !all && !want[strings.ToLower(a.name)] {
+			continue
+		}
+		vals := set()
+		if !typesOnly {
+			for _, v := range a.values {
+				vals.children = append(vals.children, octetString(v))
+			}
+		}
+		attrs.children = append(attrs.children, sequence(octetString(a.name), vals))
+	}
+	op := constructed(classApplication, opSearchResultEnt, octetString(e.dn), attrs)
+	_, _ = w.Write(sequence(integer(tagInteger, id), op).encode())
+}
+
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
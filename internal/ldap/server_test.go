@@ -0,0 +1,317 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	jw6_utils "github.com/jw6ventures/jw6-go-utils"
+)
+
+type fakeUsers struct {
+	store.UserRepository
+	users []store.User
+}
+
+func (f *fakeUsers) ListActive(context.Context) ([]store.User, error) { return f.users, nil }
+
+type fakeBooks struct {
+	store.AddressBookRepository
+	books []store.AddressBook
+}
+
+func (f *fakeBooks) GetByID(_ context.Context, id int64) (*store.AddressBook, error) {
+	for i := range f.books {
+		if f.books[i].ID == id {
+			return &f.books[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeBooks) ListByUser(_ context.Context, userID int64) ([]store.AddressBook, error) {
+	var out []store.AddressBook
+	for _, b := range f.books {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+type fakeContacts struct {
+	store.ContactRepository
+	items []store.Contact
+}
+
+func (f *fakeContacts) ListForBookFiltered(_ context.Context, bookID int64, _ store.ContactFilter) ([]store.Contact, error) {
+	var out []store.Contact
+	for _, c := range f.items {
+		if c.AddressBookID == bookID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+type fakeAuth struct{ user *store.User }
+
+func (f fakeAuth) ValidateAppPassword(_ context.Context, username, password string) (*store.User, error) {
+	if username == f.user.PrimaryEmail && password == "secret" {
+		return f.user, nil
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+type nopSink struct{}
+
+func (nopSink) Log(string, string, jw6_utils.LogLevel, string) {}
+
+// client drives serveConn over an in-memory pipe.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	id   int64
+}
+
+func newTestClient(t *testing.T) *client {
+	t.Helper()
+	user := &store.User{ID: 1, PrimaryEmail: "alice@example.com"}
+	st := &store.Store{
+		Users:        &fakeUsers{users: []store.User{*user, {ID: 2, PrimaryEmail: "bob@example.com"}}},
+		AddressBooks: &fakeBooks{books: []store.AddressBook{{ID: 7, UserID: 1, Name: "Work"}, {ID: 8, UserID: 2, Name: "Bob's"}}},
+		Contacts: &fakeContacts{items: []store.Contact{
+			{AddressBookID: 7, UID: "c1", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:c1\r\nFN:Carol Jones\r\nN:Jones;Carol;;;\r\nEMAIL:carol@example.com\r\nTEL;TYPE=CELL:+1 (555) 010-0000\r\nEND:VCARD\r\n"},
+			{AddressBookID: 7, UID: "c2", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:c2\r\nFN:Dan Smith\r\nEMAIL:dan@example.com\r\nEND:VCARD\r\n"},
+			{AddressBookID: 8, UID: "c3", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:c3\r\nFN:Carol Private\r\nEND:VCARD\r\n"},
+		}},
+	}
+	srv := newServer("dc=example,dc=com", st, fakeAuth{user: user}, nopSink{})
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.serveConn(ctx, serverConn)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		clientConn.Close()
+		cancel()
+		<-done
+	})
+	return &client{t: t, conn: clientConn, r: bufio.NewReader(clientConn)}
+}
+
+func (c *client) send(op *packet) {
+	c.t.Helper()
+	c.id++
+	if _, err := c.conn.Write(sequence(integer(tagInteger, c.id), op).encode()); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// recv returns the protocol operation of the next response.
+func (c *client) recv() *packet {
+	c.t.Helper()
+	raw, err := readMessage(c.r)
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	msg, _, err := decodePacket(raw)
+	if err != nil {
+		c.t.Fatalf("decode: %v", err)
+	}
+	if id, _ := msg.children[0].int(); id != c.id {
+		c.t.Fatalf("message id = %d, want %d", id, c.id)
+	}
+	return msg.children[1]
+}
+
+func resultCode(t *testing.T, op *packet) int64 {
+	t.Helper()
+	code, err := op.children[0].int()
+	if err != nil {
+		t.Fatalf("result code: %v", err)
+	}
+	return code
+}
+
+func (c *client) bind(name, password string) int64 {
+	c.t.Helper()
+	c.send(constructed(classApplication, opBindRequest, integer(tagInteger, 3), octetString(name),
+		&packet{class: classContext, tag: 0, value: []byte(password)}))
+	resp := c.recv()
+	if !resp.is(classApplication, opBindResponse) {
+		c.t.Fatalf("expected BindResponse, got tag %d", resp.tag)
+	}
+	return resultCode(c.t, resp)
+}
+
+// search returns the DNs found, their values keyed by "dn/attribute", and
+// the result code.
+func (c *client) search(base string, scope int64, f *packet, attrs ...string) ([]string, map[string][]string, int64) {
+	c.t.Helper()
+	list := sequence()
+	for _, a := range attrs {
+		list.children = append(list.children, octetString(a))
+	}
+	c.send(constructed(classApplication, opSearchRequest,
+		octetString(base), integer(tagEnumerated, scope), integer(tagEnumerated, 0),
+		integer(tagInteger, 0), integer(tagInteger, 0), &packet{class: classUniversal, tag: tagBoolean, value: []byte{0}},
+		f, list))
+	var dns []string
+	values := map[string][]string{}
+	for {
+		resp := c.recv()
+		switch {
+		case resp.is(classApplication, opSearchResultEnt):
+			dn := resp.children[0].str()
+			dns = append(dns, dn)
+			for _, a := range resp.children[1].children {
+				for _, v := range a.children[1].children {
+					values[dn+"/"+a.children[0].str()] = append(values[dn+"/"+a.children[0].str()], v.str())
+				}
+			}
+		case resp.is(classApplication, opSearchResultDone):
+			return dns, values, resultCode(c.t, resp)
+		default:
+			c.t.Fatalf("unexpected response tag %d", resp.tag)
+		}
+	}
+}
+
+func present(attr string) *packet {
+	return &packet{class: classContext, tag: filterPresent, value: []byte(attr)}
+}
+
+func equality(attr, value string) *packet {
+	return constructed(classContext, filterEqualityMatch, octetString(attr), octetString(value))
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'x'
+	}
+	in := sequence(integer(tagInteger, -129), integer(tagInteger, 70000), octetString(string(long)), set())
+	out, n, err := decodePacket(in.encode())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if n != len(in.encode()) || len(out.children) != 4 {
+		t.Fatalf("decoded %d bytes into %d children", n, len(out.children))
+	}
+	if v, _ := out.children[0].int(); v != -129 {
+		t.Errorf("first integer = %d", v)
+	}
+	if v, _ := out.children[1].int(); v != 70000 {
+		t.Errorf("second integer = %d", v)
+	}
+	if out.children[2].str() != string(long) {
+		t.Errorf("long string did not round-trip")
+	}
+}
+
+func TestSearchRequiresBind(t *testing.T) {
+	c := newTestClient(t)
+
+	dns, _, code := c.search("", scopeBaseObject, present("objectClass"))
+	if code != resultSuccess || len(dns) != 1 {
+		t.Fatalf("root DSE: code %d, entries %v", code, dns)
+	}
+	if _, _, code := c.search("dc=example,dc=com", scopeWholeSubtree, present("objectClass")); code != resultInsufficientAccessRight {
+		t.Fatalf("unbound search code = %d, want %d", code, resultInsufficientAccessRight)
+	}
+	if code := c.bind("uid=alice@example.com,ou=directory,dc=example,dc=com", "wrong"); code != resultInvalidCredentials {
+		t.Fatalf("bad bind code = %d", code)
+	}
+	if code := c.bind("alice@example.com", ""); code != resultUnwillingToPerform {
+		t.Fatalf("unauthenticated bind code = %d", code)
+	}
+}
+
+func TestSearchAddressBooksAndDirectory(t *testing.T) {
+	c := newTestClient(t)
+	if code := c.bind("uid=alice@example.com,ou=directory,dc=example,dc=com", "secret"); code != resultSuccess {
+		t.Fatalf("bind code = %d", code)
+	}
+
+	f := constructed(classContext, filterAnd,
+		equality("objectClass", "inetOrgPerson"),
+		constructed(classContext, filterSubstrings, octetString("cn"),
+			sequence(&packet{class: classContext, tag: 0, value: []byte("carol")})))
+	dns, values, code := c.search("DC=Example, DC=Com", scopeWholeSubtree, f, "cn", "mobile", "mail")
+	if code != resultSuccess {
+		t.Fatalf("search code = %d", code)
+	}
+	want := "uid=c1,ou=7,ou=addressbooks,dc=example,dc=com"
+	if len(dns) != 1 || dns[0] != want {
+		t.Fatalf("entries = %v, want only %s (other users' books must stay hidden)", dns, want)
+	}
+	if got := values[want+"/mobile"]; len(got) != 1 || got[0] != "+1 (555) 010-0000" {
+		t.Errorf("mobile = %v", got)
+	}
+	if _, ok := values[want+"/sn"]; ok {
+		t.Errorf("unrequested attribute sn returned")
+	}
+
+	dns, _, _ = c.search("ou=addressbooks,dc=example,dc=com", scopeWholeSubtree, equality("telephoneNumber", "+15550100000"))
+	if len(dns) != 0 {
+		t.Errorf("mobile numbers matched telephoneNumber: %v", dns)
+	}
+	dns, _, _ = c.search("ou=addressbooks,dc=example,dc=com", scopeWholeSubtree, equality("mobile", "+15550100000"))
+	if len(dns) != 1 {
+		t.Errorf("phone match ignoring punctuation found %v", dns)
+	}
+
+	dns, _, code = c.search("ou=directory,dc=example,dc=com", scopeSingleLevel, equality("mail", "BOB@example.com"))
+	if code != resultSuccess || len(dns) != 1 || dns[0] != "uid=bob@example.com,ou=directory,dc=example,dc=com" {
+		t.Errorf("directory search: code %d, entries %v", code, dns)
+	}
+
+	if _, _, code := c.search("ou=8,ou=addressbooks,dc=example,dc=com", scopeWholeSubtree, present("objectClass")); code != resultNoSuchObject {
+		t.Errorf("search of another user's book code = %d, want %d", code, resultNoSuchObject)
+	}
+}
+
+func TestWritesAreRefused(t *testing.T) {
+	c := newTestClient(t)
+	if code := c.bind("alice@example.com", "secret"); code != resultSuccess {
+		t.Fatalf("bind code = %d", code)
+	}
+	c.send(&packet{class: classApplication, tag: opDelRequest, value: []byte("uid=c1,ou=7,ou=addressbooks,dc=example,dc=com")})
+	resp := c.recv()
+	if !resp.is(classApplication, opDelRequest+1) || resultCode(t, resp) != resultUnwillingToPerform {
+		t.Fatalf("delete response tag %d code %d", resp.tag, resultCode(t, resp))
+	}
+}
+
+func TestNewIsOffWithoutCardDAV(t *testing.T) {
+	cfg := &config.Config{CardDAVDisabled: true}
+	cfg.LDAP.Addr = ":6360"
+	if s, err := New(cfg, &store.Store{}, nil, nil); s != nil || err != nil {
+		t.Fatalf("New() with CardDAV off = %v, %v; want no server", s, err)
+	}
+}
+
+func TestBindClient(t *testing.T) {
+	if got := BindDN("uid={username},ou=people,dc=example,dc=com", "smith, j"); got != `uid=smith\, j,ou=people,dc=example,dc=com` {
+		t.Fatalf("BindDN() = %q", got)
+	}
+	c := newTestClient(t)
+	if err := bindConn(c.conn, "uid=alice@example.com,dc=example,dc=com", "secret"); err != nil {
+		t.Fatalf("bindConn() error = %v", err)
+	}
+	c = newTestClient(t)
+	if err := bindConn(c.conn, "uid=alice@example.com,dc=example,dc=com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("bindConn() with a bad password error = %v, want ErrInvalidCredentials", err)
+	}
+	if err := Bind(context.Background(), "ldaps://directory.invalid", "uid=alice", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Bind() with an empty password error = %v, want ErrInvalidCredentials", err)
+	}
+}
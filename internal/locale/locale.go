@@ -0,0 +1,202 @@
+// Package locale resolves a user's regional preferences, their locale,
+// timezone and first day of the week, and formats server-generated dates
+// and text the way they expect. The locale decides date order, the clock and
+// calendar conventions, and the language of emails and public pages when it
+// is one of Languages. The signed-in UI is English.
+package locale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used for users who have not chosen a locale.
+const DefaultLocale = "en-US"
+
+// ErrInvalid reports a preference that cannot be stored.
+var ErrInvalid = errors.New("locale: invalid preference")
+
+// Preferences are a user's resolved regional preferences.
+type Preferences struct {
+	Tag language.Tag
+	// Language is the one of Languages text is written in.
+	Language  language.Tag
+	Location  *time.Location
+	WeekStart time.Weekday
+}
+
+// ForUser resolves the user's preferences, filling the defaults for those
+// unset or no longer valid. A nil user gets the defaults.
+func ForUser(u *store.User) Preferences {
+	p := Preferences{Tag: language.MustParse(DefaultLocale), Language: language.English, Location: time.UTC}
+	if u == nil {
+		p.WeekStart = FirstDay(p.Tag)
+		return p
+	}
+	if tag, err := language.Parse(u.Locale); err == nil && u.Locale != "" {
+		p.Tag = tag
+		p.Language = matchLanguage(tag)
+	}
+	if u.Timezone != "" {
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			p.Location = loc
+		}
+	}
+	p.WeekStart = FirstDay(p.Tag)
+	if u.WeekStart != nil && *u.WeekStart >= time.Sunday && *u.WeekStart <= time.Saturday {
+		p.WeekStart = *u.WeekStart
+	}
+	return p
+}
+
+// Normalize validates preferences before they are stored, returning the
+// canonical locale tag. Empty values clear a preference.
+func Normalize(locale, timezone string, weekStart *time.Weekday) (string, string, error) {
+	locale, timezone = strings.TrimSpace(locale), strings.TrimSpace(timezone)
+	if locale != "" {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: unknown locale %q", ErrInvalid, locale)
+		}
+		locale = tag.String()
+	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return "", "", fmt.Errorf("%w: unknown timezone %q", ErrInvalid, timezone)
+		}
+	}
+	if weekStart != nil && (*weekStart < time.Sunday || *weekStart > time.Saturday) {
+		return "", "", fmt.Errorf("%w: week start must be a day from 0 (Sunday) to 6", ErrInvalid)
+	}
+	return locale, timezone, nil
+}
+
+// Regions whose calendars start the week on Sunday or Saturday; the rest
+// start on Monday. From the CLDR week data.
+var (
+	sundayFirst   = regions("AG AS BD BR BS BT BW BZ CA CN CO DM DO ET GT GU HK HN ID IL IN JM JP KE KH KR LA MH MM MO MT MX MZ NI NP PA PE PH PK PR PT PY SA SG SV TH TT TW UM US VE VI WS YE ZA ZW")
+	saturdayFirst = regions("AE AF BH DJ DZ EG IQ IR JO KW LY OM QA SD SY")
+)
+
+// Weekends that differ from Saturday and Sunday, from the CLDR week data.
+var weekends = map[string][]time.Weekday{
+	"AF": {time.Thursday, time.Friday},
+	"IN": {time.Sunday},
+	"IR": {time.Friday},
+	"UG": {time.Sunday},
+}
+
+func init() {
+	for r := range regions("BH DZ EG IL IQ JO KW LY OM QA SA SD SY YE") {
+		weekends[r] = []time.Weekday{time.Friday, time.Saturday}
+	}
+}
+
+// Regions that write the month before the day, and those that write the
+// year first; the rest write the day first.
+var (
+	monthFirst = regions("US PH FM MH PW UM AS GU PR VI")
+	yearFirst  = regions("CN HU JP KR LT MN TW")
+)
+
+// Regions that use a 12-hour clock.
+var twelveHour = regions("AS AU BD CA EG GU IN MY NZ PH PK PR SA US VI")
+
+// FirstDay returns the first day of the week where tag is spoken.
+func FirstDay(tag language.Tag) time.Weekday {
+	switch r := region(tag); {
+	case sundayFirst[r]:
+		return time.Sunday
+	case saturdayFirst[r]:
+		return time.Saturday
+	default:
+		return time.Monday
+	}
+}
+
+// Weekdays returns the seven days in the user's order, starting with
+// WeekStart.
+func (p Preferences) Weekdays() []time.Weekday {
+	days := make([]time.Weekday, 7)
+	for i := range days {
+		days[i] = (p.WeekStart + time.Weekday(i)) % 7
+	}
+	return days
+}
+
+// WorkingDays returns the days outside the weekend of the user's region, as
+// a mask with bit 1<<weekday set for each, the form booking pages use.
+func (p Preferences) WorkingDays() int {
+	weekend, ok := weekends[region(p.Tag)]
+	if !ok {
+		weekend = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	mask := 1<<7 - 1
+	for _, d := range weekend {
+		mask &^= 1 << d
+	}
+	return mask
+}
+
+// FormatDate formats t as a long date with its weekday, such as "Tuesday,
+// 3 March 2026", in the user's timezone and language.
+func (p Preferences) FormatDate(t time.Time) string {
+	return p.formatDay(t.In(p.Location), true)
+}
+
+// FormatMonthDay formats t as a weekday, day and month without the year,
+// such as "Tuesday, 3 March", in the user's timezone and language.
+func (p Preferences) FormatMonthDay(t time.Time) string {
+	return p.formatDay(t.In(p.Location), false)
+}
+
+// FormatTime formats the time of day of t in the user's timezone and clock.
+func (p Preferences) FormatTime(t time.Time) string {
+	t = t.In(p.Location)
+	if twelveHour[region(p.Tag)] {
+		return t.Format("3:04 PM")
+	}
+	return t.Format("15:04")
+}
+
+// FormatDateTime formats t as a long date and time with the timezone
+// abbreviation, such as "Tuesday, 3 March 2026 at 14:30 GMT".
+func (p Preferences) FormatDateTime(t time.Time) string {
+	t = t.In(p.Location)
+	return p.T("date.at", p.FormatDate(t), p.FormatTime(t), t.Format("MST"))
+}
+
+func (p Preferences) formatDay(t time.Time, withYear bool) string {
+	order := "dmy"
+	switch r := region(p.Tag); {
+	case monthFirst[r]:
+		order = "mdy"
+	case yearFirst[r]:
+		order = "ymd"
+	}
+	if !withYear {
+		// Year-first regions then write the month first.
+		order = strings.Replace(order, "y", "", 1)
+	}
+	return p.T("date."+order, p.T(fmt.Sprintf("weekday.%d", t.Weekday())), t.Day(), p.T(fmt.Sprintf("month.%d", t.Month())), t.Year())
+}
+
+// region returns the tag's region, inferring one for a bare language such
+// as "de".
+func region(tag language.Tag) string {
+	r, _ := tag.Region()
+	return r.String()
+}
+
+func regions(list string) map[string]bool {
+	m := make(map[string]bool)
+	for _, r := range strings.Fields(list) {
+		m[r] = true
+	}
+	return m
+}
@@ -0,0 +1,119 @@
+package locale
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+func TestForUserFormatsDatesTheUsersWay(t *testing.T) {
+	at := time.Date(2026, 3, 3, 13, 30, 0, 0, time.UTC)
+	monday := time.Monday
+	for _, tc := range []struct {
+		user      *store.User
+		dateTime  string
+		weekStart time.Weekday
+		working   int
+	}{
+		{nil, "Tuesday, March 3, 2026 at 1:30 PM UTC", time.Sunday, 0b0111110},
+		{&store.User{Locale: "en-GB", Timezone: "Europe/London"}, "Tuesday, 3 March 2026 at 13:30 GMT", time.Monday, 0b0111110},
+		{&store.User{Locale: "de", Timezone: "Europe/Berlin"}, "Dienstag, 3. März 2026 um 14:30 CET", time.Monday, 0b0111110},
+		{&store.User{Locale: "fr-FR", Timezone: "Europe/Paris"}, "mardi 3 mars 2026 à 14:30 CET", time.Monday, 0b0111110},
+		{&store.User{Locale: "ja-JP", Timezone: "Asia/Tokyo", WeekStart: &monday}, "Tuesday, 2026 March 3 at 22:30 JST", time.Monday, 0b0111110},
+		{&store.User{Locale: "ar-SA", Timezone: "Nowhere/Invalid"}, "Tuesday, 3 March 2026 at 1:30 PM UTC", time.Sunday, 0b0011111},
+	} {
+		p := ForUser(tc.user)
+		if got := p.FormatDateTime(at); got != tc.dateTime {
+			t.Errorf("%+v: FormatDateTime() = %q, want %q", tc.user, got, tc.dateTime)
+		}
+		if p.WeekStart != tc.weekStart || p.Weekdays()[0] != tc.weekStart {
+			t.Errorf("%+v: week starts %v, want %v", tc.user, p.WeekStart, tc.weekStart)
+		}
+		if got := p.WorkingDays(); got != tc.working {
+			t.Errorf("%+v: WorkingDays() = %07b, want %07b", tc.user, got, tc.working)
+		}
+	}
+}
+
+func TestNormalizeRejectsUnknownPreferences(t *testing.T) {
+	if lang, tz, err := Normalize(" en-gb ", "Europe/London", nil); err != nil || lang != "en-GB" || tz != "Europe/London" {
+		t.Fatalf("Normalize() = %q, %q, %v", lang, tz, err)
+	}
+	bad := time.Weekday(9)
+	for _, tc := range []struct {
+		locale, timezone string
+		weekStart        *time.Weekday
+	}{
+		{"en_GB!", "", nil},
+		{"", "Mars/Olympus", nil},
+		{"", "", &bad},
+	} {
+		if _, _, err := Normalize(tc.locale, tc.timezone, tc.weekStart); err == nil {
+			t.Errorf("Normalize(%q, %q, %v) succeeded", tc.locale, tc.timezone, tc.weekStart)
+		}
+	}
+}
+
+func TestCatalogsTranslateEveryMessageWithTheSameArguments(t *testing.T) {
+	verbs := regexp.MustCompile(`%\[(\d)\]|%[sd]`)
+	arguments := func(msg string) []string {
+		var args []string
+		for _, m := range verbs.FindAllStringSubmatch(msg, -1) {
+			args = append(args, m[1])
+		}
+		slices.Sort(args)
+		return slices.Compact(args)
+	}
+	for tag, catalog := range catalogs {
+		for key, msg := range english {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing %q", tag, key)
+				continue
+			}
+			if want, got := arguments(msg), arguments(translated); !slices.Equal(want, got) && !strings.HasPrefix(key, "date.") {
+				t.Errorf("%s: %q takes %v, want %v", tag, key, got, want)
+			}
+		}
+		for key := range catalog {
+			if _, ok := english[key]; !ok {
+				t.Errorf("%s: %q is not in English", tag, key)
+			}
+		}
+	}
+}
+
+func TestPluralAndAcceptLanguage(t *testing.T) {
+	en := ForUser(nil)
+	fr := ForUser(&store.User{Locale: "fr"})
+	if got := en.Plural("notify.more", 1); got != "and 1 more" {
+		t.Errorf("English Plural() = %q", got)
+	}
+	// French uses the singular for zero too.
+	if got := fr.Plural("notify.more", 0); got != "et 0 autre" {
+		t.Errorf("French Plural(0) = %q", got)
+	}
+	if got := fr.Plural("notify.more", 2); got != "et 2 autres" {
+		t.Errorf("French Plural(2) = %q", got)
+	}
+	if got := en.T("no.such.key"); got != "no.such.key" {
+		t.Errorf("T() of an unknown key = %q", got)
+	}
+	if got := ForUser(&store.User{Locale: "ja-JP"}).T("digest.allDay"); got != "All day" {
+		t.Errorf("untranslated language T() = %q", got)
+	}
+
+	if got := en.WithAcceptLanguage("de-CH,de;q=0.9,en;q=0.8").T("booking.page.book"); got != "Buchen" {
+		t.Errorf("WithAcceptLanguage(de-CH) = %q", got)
+	}
+	if got := fr.WithAcceptLanguage("ja,zh;q=0.5").T("booking.page.book"); got != "Réserver" {
+		t.Errorf("WithAcceptLanguage of an untranslated language = %q, want the owner's", got)
+	}
+	if got := fr.WithAcceptLanguage("").T("booking.page.book"); got != "Réserver" {
+		t.Errorf("WithAcceptLanguage(\"\") = %q", got)
+	}
+}
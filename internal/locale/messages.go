@@ -0,0 +1,101 @@
+package locale
+
+import (
+	"fmt"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// Languages are those server-generated text is translated into, English
+// first. Users whose locale is in another language get English.
+var Languages = []language.Tag{language.English, language.German, language.French}
+
+var matcher = language.NewMatcher(Languages)
+
+// catalogs holds each language's messages by key. Messages are fmt formats;
+// those taking several arguments refer to them by index, so translations can
+// reorder them. A plural message has one key per CLDR form, such as
+// "notify.more.one" and "notify.more.other", and takes the count first.
+var catalogs = map[language.Tag]map[string]string{
+	language.English: english,
+	language.German:  german,
+	language.French:  french,
+}
+
+var pluralForms = map[plural.Form]string{
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+	plural.Other: "other",
+}
+
+// matchLanguage returns the language of Languages closest to tag, or English.
+func matchLanguage(tag language.Tag) language.Tag {
+	_, i, confidence := matcher.Match(tag)
+	if confidence == language.No {
+		return language.English
+	}
+	return Languages[i]
+}
+
+// WithAcceptLanguage returns p translated into the language an
+// Accept-Language header prefers, when it is one of Languages, so public
+// pages speak the visitor's language rather than the owner's. Dates keep
+// the owner's order and timezone.
+func (p Preferences) WithAcceptLanguage(header string) Preferences {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return p
+	}
+	if _, i, confidence := matcher.Match(tags...); confidence != language.No {
+		p.Language = Languages[i]
+	}
+	return p
+}
+
+// T returns the message for key in the user's language, formatted with
+// args. Messages missing from a translation fall back to English, and
+// unknown keys to the key itself.
+func (p Preferences) T(key string, args ...any) string {
+	msg, ok := p.message(key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Plural returns the form of the message for key that suits n in the
+// user's language, formatted with n followed by args.
+func (p Preferences) Plural(key string, n int, args ...any) string {
+	form := plural.Cardinal.MatchPlural(p.language(), n, 0, 0, 0, 0)
+	msg, ok := p.message(key + "." + pluralForms[form])
+	if !ok {
+		if msg, ok = p.message(key + ".other"); !ok {
+			return key
+		}
+	}
+	return fmt.Sprintf(msg, append([]any{n}, args...)...)
+}
+
+func (p Preferences) message(key string) (string, bool) {
+	if msg, ok := catalogs[p.language()][key]; ok {
+		return msg, true
+	}
+	msg, ok := english[key]
+	return msg, ok
+}
+
+// language returns the catalog language, English for preferences built
+// without one.
+func (p Preferences) language() language.Tag {
+	if _, ok := catalogs[p.Language]; ok {
+		return p.Language
+	}
+	return language.English
+}
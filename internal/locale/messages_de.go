@@ -0,0 +1,108 @@
+package locale
+
+var german = map[string]string{
+	"weekday.0": "Sonntag",
+	"weekday.1": "Montag",
+	"weekday.2": "Dienstag",
+	"weekday.3": "Mittwoch",
+	"weekday.4": "Donnerstag",
+	"weekday.5": "Freitag",
+	"weekday.6": "Samstag",
+	"month.1":   "Januar",
+	"month.2":   "Februar",
+	"month.3":   "März",
+	"month.4":   "April",
+	"month.5":   "Mai",
+	"month.6":   "Juni",
+	"month.7":   "Juli",
+	"month.8":   "August",
+	"month.9":   "September",
+	"month.10":  "Oktober",
+	"month.11":  "November",
+	"month.12":  "Dezember",
+	"date.dmy":  "%[1]s, %[2]d. %[3]s %[4]d",
+	"date.mdy":  "%[1]s, %[3]s %[2]d, %[4]d",
+	"date.ymd":  "%[1]s, %[4]d %[3]s %[2]d",
+	"date.dm":   "%[1]s, %[2]d. %[3]s",
+	"date.md":   "%[1]s, %[3]s %[2]d",
+	"date.at":   "%[1]s um %[2]s %[3]s",
+	"untitled":  "(ohne Titel)",
+
+	"digest.daily":   "Ihre Termine für %s",
+	"digest.weekly":  "Ihre Termine für die Woche ab %s",
+	"digest.allDay":  "Ganztägig",
+	"digest.tasks":   "Fällige Aufgaben",
+	"digest.overdue": "Überfällig seit %s",
+	"digest.empty":   "Keine Termine",
+
+	"alarms.test": "Testbenachrichtigung: So kommen Erinnerungen an.",
+
+	"quota.subject": "Ihr CalCard-Speicher ist zu %[1]d%% voll",
+	"quota.text":    "Ihre Kalender und Kontakte belegen %[1]s Ihres Speichers von %[2]s (%[3]d%%).\n\nSobald er voll ist, können Ihre Geräte keine neuen Termine und Kontakte mehr speichern. Löschen Sie Termine oder Kontakte, die Sie nicht mehr brauchen, oder bitten Sie Ihren Administrator um mehr Speicher.\n",
+
+	"focus.summary":     "Fokuszeit",
+	"focus.description": "Von CalCard für konzentriertes Arbeiten geblockt. Löschen oder verschieben Sie den Termin, wenn Sie die Zeit brauchen.",
+
+	"notify.subject":           "Änderungen in %s",
+	"notify.subjectMany.one":   "Änderungen in %[1]d Kalender oder Adressbuch",
+	"notify.subjectMany.other": "Änderungen in %[1]d Kalendern und Adressbüchern",
+	"notify.calendar":          "Kalender",
+	"notify.addressBook":       "Adressbuch",
+	"notify.someone":           "Jemand",
+	"notify.added":             "%[1]s hat %[2]s hinzugefügt",
+	"notify.changed":           "%[1]s hat %[2]s geändert",
+	"notify.deleted":           "%[1]s hat %[2]s gelöscht",
+	"notify.more.one":          "und %[1]d weitere",
+	"notify.more.other":        "und %[1]d weitere",
+	"notify.footer":            "Sie erhalten diese E-Mails, weil Sie diesen Sammlungen folgen. Sie können sie in den Benachrichtigungseinstellungen abschalten.",
+
+	"signin.new.subject":       "Neue Anmeldung bei Ihrem CalCard-Konto",
+	"signin.new.text":          "Ihr Konto %[1]s hat sich von einer bisher nicht verwendeten Adresse bei CalDAV/CardDAV angemeldet.\n\nAdresse: %[2]s\nClient: %[3]s\nZeit: %[4]s\n\nWenn Sie das waren, ist nichts zu tun. Andernfalls widerrufen Sie das App-Passwort des Geräts unter %[5]s/app-passwords.\n",
+	"signin.locked.subject":    "CalCard-Anmeldungen nach falschen Passwörtern pausiert",
+	"signin.locked.text.one":   "Für Ihr Konto %[2]s wurde %[1]d falsches Passwort versucht, von %[3]s.\n\nFür die nächsten %[4]s nimmt es nur Anmeldungen von bereits verwendeten Adressen an, Ihre Geräte funktionieren also weiter. Wenn Sie das nicht waren, versucht womöglich jemand, Ihre App-Passwörter zu erraten; prüfen Sie Ihre Geräte unter %[5]s/app-passwords.\n",
+	"signin.locked.text.other": "Für Ihr Konto %[2]s wurden %[1]d falsche Passwörter versucht, zuletzt von %[3]s.\n\nFür die nächsten %[4]s nimmt es nur Anmeldungen von bereits verwendeten Adressen an, Ihre Geräte funktionieren also weiter. Wenn Sie das nicht waren, versucht womöglich jemand, Ihre App-Passwörter zu erraten; prüfen Sie Ihre Geräte unter %[5]s/app-passwords.\n",
+
+	"alias.subject":    "Bestätigen Sie Ihren CalCard-E-Mail-Alias",
+	"alias.text.one":   "%[2]s möchte %[3]s als Alias des eigenen CalCard-Kontos hinzufügen.\n\nÖffnen Sie diesen Link, während Sie als %[2]s angemeldet sind, um ihn zu bestätigen. Er ist %[1]d Stunde gültig:\n\n%[4]s\n\nWenn Sie das nicht angefordert haben, ignorieren Sie diese E-Mail.\n",
+	"alias.text.other": "%[2]s möchte %[3]s als Alias des eigenen CalCard-Kontos hinzufügen.\n\nÖffnen Sie diesen Link, während Sie als %[2]s angemeldet sind, um ihn zu bestätigen. Er ist %[1]d Stunden gültig:\n\n%[4]s\n\nWenn Sie das nicht angefordert haben, ignorieren Sie diese E-Mail.\n",
+
+	"booking.summary":            "%[1]s mit %[2]s",
+	"booking.description":        "Gebucht von %[1]s <%[2]s> über %[3]s.",
+	"booking.email.subject":      "Bestätigt: %[1]s mit %[2]s",
+	"booking.email.text":         "%[1]s mit %[2]s ist gebucht für %[3]s.\n",
+	"booking.email.rsvp":         "\n%[1]s kann im Browser zusagen oder absagen unter %[2]s\n",
+	"booking.page.booked":        "✓ Ihr Termin ist gebucht: %s.",
+	"booking.page.emailed":       "Eine Einladung wurde an %s gesendet.",
+	"booking.page.notEmailed":    "Es konnte keine Bestätigung per E-Mail gesendet werden, bitte notieren Sie sich die Zeit.",
+	"booking.page.details.one":   "%[1]d Minute · Zeiten in %[2]s",
+	"booking.page.details.other": "%[1]d Minuten · Zeiten in %[2]s",
+	"booking.page.name":          "Ihr Name",
+	"booking.page.email":         "Ihre E-Mail-Adresse",
+	"booking.page.book":          "Buchen",
+	"booking.page.noTimes":       "Derzeit sind keine Zeiten frei. Bitte schauen Sie später wieder vorbei.",
+	"booking.page.chooseTime":    "Wählen Sie eine Zeit.",
+	"booking.page.invalid":       "Geben Sie Ihren Namen und eine gültige E-Mail-Adresse ein.",
+	"booking.page.taken":         "Diese Zeit wurde leider gerade vergeben. Bitte wählen Sie eine andere.",
+
+	"rsvp.yourEvent":         "Ihren Termin",
+	"rsvp.subject.ACCEPTED":  "Zugesagt: %s",
+	"rsvp.subject.TENTATIVE": "Vorläufig zugesagt: %s",
+	"rsvp.subject.DECLINED":  "Abgesagt: %s",
+	"rsvp.text.ACCEPTED":     "%[1]s hat %[2]s zugesagt.\n",
+	"rsvp.text.TENTATIVE":    "%[1]s hat %[2]s vorläufig zugesagt.\n",
+	"rsvp.text.DECLINED":     "%[1]s hat %[2]s abgesagt.\n",
+	"rsvp.page.invitation":   "Einladung",
+	"rsvp.page.organizer":    "Organisiert von %s",
+	"rsvp.page.answered":     "✓ Ihre Antwort wurde gespeichert: %s.",
+	"rsvp.page.notified":     "Der Organisator hat Ihre Antwort per E-Mail erhalten.",
+	"rsvp.page.question":     "Nehmen Sie teil, %s?",
+	"rsvp.answer.ACCEPTED":   "zugesagt",
+	"rsvp.answer.TENTATIVE":  "vorläufig",
+	"rsvp.answer.DECLINED":   "abgesagt",
+	"rsvp.current.ACCEPTED":  "Sie haben zugesagt.",
+	"rsvp.current.TENTATIVE": "Sie haben vorläufig zugesagt.",
+	"rsvp.current.DECLINED":  "Sie haben abgesagt.",
+	"rsvp.page.accept":       "Zusagen",
+	"rsvp.page.maybe":        "Vielleicht",
+	"rsvp.page.decline":      "Absagen",
+}
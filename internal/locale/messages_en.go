@@ -0,0 +1,116 @@
+package locale
+
+var english = map[string]string{
+	"weekday.0": "Sunday",
+	"weekday.1": "Monday",
+	"weekday.2": "Tuesday",
+	"weekday.3": "Wednesday",
+	"weekday.4": "Thursday",
+	"weekday.5": "Friday",
+	"weekday.6": "Saturday",
+	"month.1":   "January",
+	"month.2":   "February",
+	"month.3":   "March",
+	"month.4":   "April",
+	"month.5":   "May",
+	"month.6":   "June",
+	"month.7":   "July",
+	"month.8":   "August",
+	"month.9":   "September",
+	"month.10":  "October",
+	"month.11":  "November",
+	"month.12":  "December",
+	// Dates take the weekday, day, month and year.
+	"date.dmy": "%[1]s, %[2]d %[3]s %[4]d",
+	"date.mdy": "%[1]s, %[3]s %[2]d, %[4]d",
+	"date.ymd": "%[1]s, %[4]d %[3]s %[2]d",
+	"date.dm":  "%[1]s, %[2]d %[3]s",
+	"date.md":  "%[1]s, %[3]s %[2]d",
+	// date.at takes the date, time and timezone abbreviation.
+	"date.at":  "%[1]s at %[2]s %[3]s",
+	"untitled": "(untitled)",
+
+	"digest.daily":   "Your agenda for %s",
+	"digest.weekly":  "Your agenda for the week of %s",
+	"digest.allDay":  "All day",
+	"digest.tasks":   "Tasks due",
+	"digest.overdue": "Overdue since %s",
+	"digest.empty":   "Nothing scheduled",
+
+	"alarms.test": "Test notification: alarms will arrive like this.",
+
+	"quota.subject": "Your CalCard storage is %[1]d%% full",
+	"quota.text":    "Your calendars and contacts take up %[1]s of your %[2]s of storage (%[3]d%%).\n\nOnce it is full, your devices can no longer save new events and contacts. Delete events or contacts you no longer need, or ask your administrator for more space.\n",
+
+	"focus.summary":     "Focus time",
+	"focus.description": "Blocked for focused work by CalCard. Delete or move it if you need the time.",
+
+	"notify.subject":           "Changes in %s",
+	"notify.subjectMany.one":   "Changes in %[1]d calendar or address book",
+	"notify.subjectMany.other": "Changes in %[1]d calendars and address books",
+	"notify.calendar":          "calendar",
+	"notify.addressBook":       "address book",
+	"notify.someone":           "Someone",
+	"notify.added":             "%[1]s added %[2]s",
+	"notify.changed":           "%[1]s changed %[2]s",
+	"notify.deleted":           "%[1]s deleted %[2]s",
+	"notify.more.one":          "and %[1]d more",
+	"notify.more.other":        "and %[1]d more",
+	"notify.footer":            "You get these emails because you follow these collections. Turn them off in the notification preferences.",
+
+	"signin.new.subject": "New sign-in to your CalCard account",
+	// signin.new.text takes the account, address, client, time and base URL.
+	"signin.new.text":       "Your account %[1]s signed in to CalDAV/CardDAV from an address it has not used before.\n\nAddress: %[2]s\nClient: %[3]s\nTime: %[4]s\n\nIf this was you, there is nothing to do. If not, revoke the app password the device uses at %[5]s/app-passwords.\n",
+	"signin.locked.subject": "CalCard sign-ins paused after failed passwords",
+	// signin.locked.text takes the count, account, address, lockout window
+	// and base URL.
+	"signin.locked.text.one":   "%[1]d wrong password was tried for your account %[2]s, from %[3]s.\n\nFor the next %[4]s it only accepts sign-ins from addresses it has used before, so your existing devices keep working. If you did not cause this, someone may be guessing your app passwords; review your devices at %[5]s/app-passwords.\n",
+	"signin.locked.text.other": "%[1]d wrong passwords were tried for your account %[2]s, most recently from %[3]s.\n\nFor the next %[4]s it only accepts sign-ins from addresses it has used before, so your existing devices keep working. If you did not cause this, someone may be guessing your app passwords; review your devices at %[5]s/app-passwords.\n",
+
+	"alias.subject": "Confirm your CalCard email alias",
+	// alias.text takes the hours the link works, the account, the alias and
+	// the link.
+	"alias.text.one":   "%[2]s asked to add %[3]s as an alias of their CalCard account.\n\nOpen this link while signed in as %[2]s to confirm it. It works for %[1]d hour:\n\n%[4]s\n\nIf you did not ask for this, ignore this email.\n",
+	"alias.text.other": "%[2]s asked to add %[3]s as an alias of their CalCard account.\n\nOpen this link while signed in as %[2]s to confirm it. It works for %[1]d hours:\n\n%[4]s\n\nIf you did not ask for this, ignore this email.\n",
+
+	"booking.summary":         "%[1]s with %[2]s",
+	"booking.description":     "Booked by %[1]s <%[2]s> via %[3]s.",
+	"booking.email.subject":   "Confirmed: %[1]s with %[2]s",
+	"booking.email.text":      "%[1]s with %[2]s is booked for %[3]s.\n",
+	"booking.email.rsvp":      "\n%[1]s can accept or decline in a browser at %[2]s\n",
+	"booking.page.booked":     "✓ You're booked for %s.",
+	"booking.page.emailed":    "An invitation has been sent to %s.",
+	"booking.page.notEmailed": "No confirmation email could be sent, so please note the time.",
+	// booking.page.details takes the duration in minutes and the timezone.
+	"booking.page.details.one":   "%[1]d minute · times shown in %[2]s",
+	"booking.page.details.other": "%[1]d minutes · times shown in %[2]s",
+	"booking.page.name":          "Your name",
+	"booking.page.email":         "Your email",
+	"booking.page.book":          "Book",
+	"booking.page.noTimes":       "There are no open times right now. Please check back later.",
+	"booking.page.chooseTime":    "Choose a time.",
+	"booking.page.invalid":       "Enter your name and a valid email address.",
+	"booking.page.taken":         "Sorry, that time was just taken. Please choose another.",
+
+	"rsvp.yourEvent":         "your event",
+	"rsvp.subject.ACCEPTED":  "Accepted: %s",
+	"rsvp.subject.TENTATIVE": "Tentatively accepted: %s",
+	"rsvp.subject.DECLINED":  "Declined: %s",
+	"rsvp.text.ACCEPTED":     "%[1]s has accepted %[2]s.\n",
+	"rsvp.text.TENTATIVE":    "%[1]s has tentatively accepted %[2]s.\n",
+	"rsvp.text.DECLINED":     "%[1]s has declined %[2]s.\n",
+	"rsvp.page.invitation":   "Invitation",
+	"rsvp.page.organizer":    "Organized by %s",
+	"rsvp.page.answered":     "✓ Your answer has been recorded: %s.",
+	"rsvp.page.notified":     "The organizer has been emailed your reply.",
+	"rsvp.page.question":     "Will you attend, %s?",
+	"rsvp.answer.ACCEPTED":   "accepted",
+	"rsvp.answer.TENTATIVE":  "tentative",
+	"rsvp.answer.DECLINED":   "declined",
+	"rsvp.current.ACCEPTED":  "You have accepted.",
+	"rsvp.current.TENTATIVE": "You answered tentatively.",
+	"rsvp.current.DECLINED":  "You have declined.",
+	"rsvp.page.accept":       "Accept",
+	"rsvp.page.maybe":        "Maybe",
+	"rsvp.page.decline":      "Decline",
+}
@@ -0,0 +1,108 @@
+package locale
+
+var french = map[string]string{
+	"weekday.0": "dimanche",
+	"weekday.1": "lundi",
+	"weekday.2": "mardi",
+	"weekday.3": "mercredi",
+	"weekday.4": "jeudi",
+	"weekday.5": "vendredi",
+	"weekday.6": "samedi",
+	"month.1":   "janvier",
+	"month.2":   "février",
+	"month.3":   "mars",
+	"month.4":   "avril",
+	"month.5":   "mai",
+	"month.6":   "juin",
+	"month.7":   "juillet",
+	"month.8":   "août",
+	"month.9":   "septembre",
+	"month.10":  "octobre",
+	"month.11":  "novembre",
+	"month.12":  "décembre",
+	"date.dmy":  "%[1]s %[2]d %[3]s %[4]d",
+	"date.mdy":  "%[1]s %[3]s %[2]d, %[4]d",
+	"date.ymd":  "%[1]s %[4]d %[3]s %[2]d",
+	"date.dm":   "%[1]s %[2]d %[3]s",
+	"date.md":   "%[1]s %[3]s %[2]d",
+	"date.at":   "%[1]s à %[2]s %[3]s",
+	"untitled":  "(sans titre)",
+
+	"digest.daily":   "Votre agenda du %s",
+	"digest.weekly":  "Votre agenda de la semaine du %s",
+	"digest.allDay":  "Toute la journée",
+	"digest.tasks":   "Tâches à faire",
+	"digest.overdue": "En retard depuis %s",
+	"digest.empty":   "Rien de prévu",
+
+	"alarms.test": "Notification de test : les alarmes arriveront ainsi.",
+
+	"quota.subject": "Votre espace CalCard est plein à %[1]d %%",
+	"quota.text":    "Vos calendriers et contacts occupent %[1]s de votre espace de %[2]s (%[3]d %%).\n\nUne fois plein, vos appareils ne pourront plus enregistrer de nouveaux événements ni contacts. Supprimez les événements ou contacts dont vous n'avez plus besoin, ou demandez plus d'espace à votre administrateur.\n",
+
+	"focus.summary":     "Temps de concentration",
+	"focus.description": "Réservé par CalCard pour un travail concentré. Supprimez ou déplacez-le si vous avez besoin de ce temps.",
+
+	"notify.subject":           "Modifications dans %s",
+	"notify.subjectMany.one":   "Modifications dans %[1]d calendrier ou carnet d'adresses",
+	"notify.subjectMany.other": "Modifications dans %[1]d calendriers et carnets d'adresses",
+	"notify.calendar":          "calendrier",
+	"notify.addressBook":       "carnet d'adresses",
+	"notify.someone":           "Quelqu'un",
+	"notify.added":             "%[1]s a ajouté %[2]s",
+	"notify.changed":           "%[1]s a modifié %[2]s",
+	"notify.deleted":           "%[1]s a supprimé %[2]s",
+	"notify.more.one":          "et %[1]d autre",
+	"notify.more.other":        "et %[1]d autres",
+	"notify.footer":            "Vous recevez ces e-mails parce que vous suivez ces collections. Désactivez-les dans les préférences de notification.",
+
+	"signin.new.subject":       "Nouvelle connexion à votre compte CalCard",
+	"signin.new.text":          "Votre compte %[1]s s'est connecté à CalDAV/CardDAV depuis une adresse qu'il n'avait jamais utilisée.\n\nAdresse : %[2]s\nClient : %[3]s\nHeure : %[4]s\n\nSi c'était vous, il n'y a rien à faire. Sinon, révoquez le mot de passe d'application de l'appareil sur %[5]s/app-passwords.\n",
+	"signin.locked.subject":    "Connexions CalCard suspendues après des mots de passe erronés",
+	"signin.locked.text.one":   "%[1]d mot de passe erroné a été essayé pour votre compte %[2]s, depuis %[3]s.\n\nPendant les %[4]s à venir, il n'accepte que les connexions depuis des adresses déjà utilisées, vos appareils continuent donc de fonctionner. Si ce n'était pas vous, quelqu'un essaie peut-être de deviner vos mots de passe d'application ; vérifiez vos appareils sur %[5]s/app-passwords.\n",
+	"signin.locked.text.other": "%[1]d mots de passe erronés ont été essayés pour votre compte %[2]s, la dernière fois depuis %[3]s.\n\nPendant les %[4]s à venir, il n'accepte que les connexions depuis des adresses déjà utilisées, vos appareils continuent donc de fonctionner. Si ce n'était pas vous, quelqu'un essaie peut-être de deviner vos mots de passe d'application ; vérifiez vos appareils sur %[5]s/app-passwords.\n",
+
+	"alias.subject":    "Confirmez votre alias e-mail CalCard",
+	"alias.text.one":   "%[2]s a demandé à ajouter %[3]s comme alias de son compte CalCard.\n\nOuvrez ce lien en étant connecté en tant que %[2]s pour le confirmer. Il est valable %[1]d heure :\n\n%[4]s\n\nSi vous n'avez rien demandé, ignorez cet e-mail.\n",
+	"alias.text.other": "%[2]s a demandé à ajouter %[3]s comme alias de son compte CalCard.\n\nOuvrez ce lien en étant connecté en tant que %[2]s pour le confirmer. Il est valable %[1]d heures :\n\n%[4]s\n\nSi vous n'avez rien demandé, ignorez cet e-mail.\n",
+
+	"booking.summary":            "%[1]s avec %[2]s",
+	"booking.description":        "Réservé par %[1]s <%[2]s> via %[3]s.",
+	"booking.email.subject":      "Confirmé : %[1]s avec %[2]s",
+	"booking.email.text":         "%[1]s avec %[2]s est réservé pour le %[3]s.\n",
+	"booking.email.rsvp":         "\n%[1]s peut accepter ou refuser dans un navigateur sur %[2]s\n",
+	"booking.page.booked":        "✓ Votre rendez-vous est réservé pour le %s.",
+	"booking.page.emailed":       "Une invitation a été envoyée à %s.",
+	"booking.page.notEmailed":    "Aucun e-mail de confirmation n'a pu être envoyé, veuillez noter l'heure.",
+	"booking.page.details.one":   "%[1]d minute · heures affichées en %[2]s",
+	"booking.page.details.other": "%[1]d minutes · heures affichées en %[2]s",
+	"booking.page.name":          "Votre nom",
+	"booking.page.email":         "Votre e-mail",
+	"booking.page.book":          "Réserver",
+	"booking.page.noTimes":       "Aucun créneau n'est disponible pour le moment. Revenez plus tard.",
+	"booking.page.chooseTime":    "Choisissez un créneau.",
+	"booking.page.invalid":       "Saisissez votre nom et une adresse e-mail valide.",
+	"booking.page.taken":         "Désolé, ce créneau vient d'être pris. Veuillez en choisir un autre.",
+
+	"rsvp.yourEvent":         "votre événement",
+	"rsvp.subject.ACCEPTED":  "Accepté : %s",
+	"rsvp.subject.TENTATIVE": "Accepté provisoirement : %s",
+	"rsvp.subject.DECLINED":  "Refusé : %s",
+	"rsvp.text.ACCEPTED":     "%[1]s a accepté %[2]s.\n",
+	"rsvp.text.TENTATIVE":    "%[1]s a accepté provisoirement %[2]s.\n",
+	"rsvp.text.DECLINED":     "%[1]s a refusé %[2]s.\n",
+	"rsvp.page.invitation":   "Invitation",
+	"rsvp.page.organizer":    "Organisé par %s",
+	"rsvp.page.answered":     "✓ Votre réponse a été enregistrée : %s.",
+	"rsvp.page.notified":     "L'organisateur a reçu votre réponse par e-mail.",
+	"rsvp.page.question":     "Participerez-vous, %s ?",
+	"rsvp.answer.ACCEPTED":   "accepté",
+	"rsvp.answer.TENTATIVE":  "provisoire",
+	"rsvp.answer.DECLINED":   "refusé",
+	"rsvp.current.ACCEPTED":  "Vous avez accepté.",
+	"rsvp.current.TENTATIVE": "Vous avez répondu provisoirement.",
+	"rsvp.current.DECLINED":  "Vous avez refusé.",
+	"rsvp.page.accept":       "Accepter",
+	"rsvp.page.maybe":        "Peut-être",
+	"rsvp.page.decline":      "Refuser",
+}
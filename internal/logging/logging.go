@@ -5,6 +5,7 @@ package logging
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	jw6_utils "github.com/jw6ventures/jw6-go-utils"
 )
@@ -73,3 +74,42 @@ func (l *Logger) Warn(method, format string, args ...any) {
 func (l *Logger) Error(method, format string, args ...any) {
 	l.log(method, jw6_utils.Error, format, args...)
 }
+
+// LevelSink forwards messages at or above a minimum level to another sink.
+// The level can be changed while the server runs, for example on a
+// configuration reload.
+type LevelSink struct {
+	sink       Sink
+	level      atomic.Int32
+	maskEmails atomic.Bool
+}
+
+// NewLevelSink returns a LevelSink that forwards to sink messages at level
+// or above.
+func NewLevelSink(sink Sink, level jw6_utils.LogLevel) *LevelSink {
+	s := &LevelSink{sink: sink}
+	s.SetLevel(level)
+	return s
+}
+
+// SetLevel changes the minimum level forwarded.
+func (s *LevelSink) SetLevel(level jw6_utils.LogLevel) {
+	s.level.Store(int32(level))
+}
+
+// SetMaskEmails turns masking of email addresses in forwarded messages on
+// or off.
+func (s *LevelSink) SetMaskEmails(mask bool) {
+	s.maskEmails.Store(mask)
+}
+
+// Log implements Sink.
+func (s *LevelSink) Log(class string, method string, level jw6_utils.LogLevel, message string) {
+	if int32(level) < s.level.Load() {
+		return
+	}
+	if s.maskEmails.Load() {
+		message = MaskEmails(message)
+	}
+	s.sink.Log(class, method, level, message)
+}
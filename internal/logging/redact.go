@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces personal values in redacted payloads.
+const redactedValue = "[redacted]"
+
+// calendarPersonalProperties are the iCalendar properties whose values
+// describe people or what they are doing. Everything else in a calendar,
+// such as dates, recurrence rules and UIDs, is kept so logs still show the
+// structure a client sent.
+var calendarPersonalProperties = map[string]bool{
+	"SUMMARY":     true,
+	"DESCRIPTION": true,
+	"LOCATION":    true,
+	"COMMENT":     true,
+	"ATTENDEE":    true,
+	"ORGANIZER":   true,
+	"CONTACT":     true,
+	"ATTACH":      true,
+	"CONFERENCE":  true,
+	"URL":         true,
+	"GEO":         true,
+	"RESOURCES":   true,
+	"IMAGE":       true,
+}
+
+// vcardStructuralProperties are the only vCard properties kept verbatim:
+// every other field of a contact is personal.
+var vcardStructuralProperties = map[string]bool{
+	"BEGIN":   true,
+	"END":     true,
+	"VERSION": true,
+	"UID":     true,
+	"REV":     true,
+	"PRODID":  true,
+	"KIND":    true,
+}
+
+// safeParameters are the parameters kept on a redacted property because
+// they describe its form, not its content.
+var safeParameters = map[string]bool{
+	"VALUE":    true,
+	"TYPE":     true,
+	"PARTSTAT": true,
+	"ROLE":     true,
+	"RSVP":     true,
+	"CUTYPE":   true,
+	"TZID":     true,
+	"ENCODING": true,
+	"FMTTYPE":  true,
+	"PREF":     true,
+}
+
+// contentLine matches a property line, allowing XML markup and CDATA
+// openers before it as in calendar-data and address-data elements.
+var contentLine = regexp.MustCompile(`^((?:\s*(?:<!\[CDATA\[|<[^>]*>))*\s*)([A-Za-z][A-Za-z0-9.-]*)((?:;[^:]*)?):(.*)$`)
+
+var emailAddress = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// RedactPayload returns body with the personal values of any iCalendar or
+// vCard data in it replaced, keeping property names, form-describing
+// parameters and line structure. It accepts bare payloads as well as XML
+// multistatus bodies that embed them.
+func RedactPayload(body string) string {
+	lines := strings.SplitAfter(body, "\n")
+	inCard := false
+	redactingFold := false
+	for i, line := range lines {
+		text, eol := splitEOL(line)
+		if redactingFold && (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) {
+			lines[i] = " " + redactedValue + eol
+			continue
+		}
+		redactingFold = false
+		m := contentLine.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		prefix, name, params, value := m[1], strings.ToUpper(m[2]), m[3], m[4]
+		if name == "BEGIN" || name == "END" {
+			if strings.EqualFold(strings.TrimSpace(stripMarkup(value)), "VCARD") {
+				inCard = name == "BEGIN"
+			}
+			continue
+		}
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = name[dot+1:]
+		}
+		personal := calendarPersonalProperties[name] || strings.HasPrefix(name, "X-")
+		if inCard {
+			personal = !vcardStructuralProperties[name]
+		}
+		if !personal {
+			continue
+		}
+		lines[i] = prefix + m[2] + redactParameters(params) + ":" + redactedValue + trailingMarkup(value) + eol
+		redactingFold = true
+	}
+	return strings.Join(lines, "")
+}
+
+// MaskEmails replaces every email address in s.
+func MaskEmails(s string) string {
+	return emailAddress.ReplaceAllString(s, redactedValue)
+}
+
+func redactParameters(params string) string {
+	if params == "" {
+		return ""
+	}
+	var kept strings.Builder
+	for _, param := range strings.Split(strings.TrimPrefix(params, ";"), ";") {
+		name, _, _ := strings.Cut(param, "=")
+		if safeParameters[strings.ToUpper(name)] {
+			kept.WriteString(";" + param)
+		} else {
+			kept.WriteString(";" + name + "=" + redactedValue)
+		}
+	}
+	return kept.String()
+}
+
+// trailingMarkup returns the XML closing tags at the end of a value, so a
+// redacted last line keeps its element structure.
+func trailingMarkup(value string) string {
+	if i := strings.IndexAny(value, "<]"); i >= 0 && (strings.HasPrefix(value[i:], "</") || strings.HasPrefix(value[i:], "]]>")) {
+		return value[i:]
+	}
+	return ""
+}
+
+func stripMarkup(value string) string {
+	return value[:len(value)-len(trailingMarkup(value))]
+}
+
+func splitEOL(line string) (string, string) {
+	text := strings.TrimRight(line, "\r\n")
+	return text, line[len(text):]
+}
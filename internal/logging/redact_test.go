@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+
+	jw6_utils "github.com/jw6ventures/jw6-go-utils"
+)
+
+func TestRedactPayloadKeepsCalendarStructure(t *testing.T) {
+	in := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:abc\r\nDTSTART:20260105T090000Z\r\nSUMMARY:Dentist\r\nDESCRIPTION:Bring the forms\r\n  and insurance card\r\n" +
+		"ATTENDEE;CN=\"Ann Smith\";PARTSTAT=ACCEPTED:mailto:ann@example.com\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	got := RedactPayload(in)
+	for _, secret := range []string{"Dentist", "forms", "insurance", "Ann Smith", "ann@example.com"} {
+		if strings.Contains(got, secret) {
+			t.Fatalf("%q left in redacted payload:\n%s", secret, got)
+		}
+	}
+	for _, kept := range []string{"UID:abc\r\n", "DTSTART:20260105T090000Z\r\n", "SUMMARY:[redacted]\r\n", "ATTENDEE;CN=[redacted];PARTSTAT=ACCEPTED:[redacted]\r\n", "RRULE:FREQ=WEEKLY\r\n"} {
+		if !strings.Contains(got, kept) {
+			t.Fatalf("expected %q in redacted payload:\n%s", kept, got)
+		}
+	}
+	if strings.Count(got, "\r\n") != strings.Count(in, "\r\n") {
+		t.Fatalf("line structure changed:\n%s", got)
+	}
+}
+
+func TestRedactPayloadRedactsEmbeddedVCards(t *testing.T) {
+	in := "<d:multistatus xmlns:d=\"DAV:\" xmlns:card=\"urn:ietf:params:xml:ns:carddav\">\n" +
+		"<card:address-data><![CDATA[BEGIN:VCARD\r\nVERSION:4.0\r\nUID:card-1\r\nFN:Ann Smith\r\nitem1.TEL;TYPE=cell:+15551234\r\nEND:VCARD\r\n]]></card:address-data>\n</d:multistatus>\n"
+	got := RedactPayload(in)
+	if strings.Contains(got, "Ann Smith") || strings.Contains(got, "5551234") {
+		t.Fatalf("contact values left in payload:\n%s", got)
+	}
+	for _, kept := range []string{"<![CDATA[BEGIN:VCARD", "UID:card-1", "FN:[redacted]", "item1.TEL;TYPE=cell:[redacted]", "]]></card:address-data>"} {
+		if !strings.Contains(got, kept) {
+			t.Fatalf("expected %q in redacted payload:\n%s", kept, got)
+		}
+	}
+}
+
+type recordingSink struct{ messages []string }
+
+func (s *recordingSink) Log(class, method string, level jw6_utils.LogLevel, message string) {
+	s.messages = append(s.messages, message)
+}
+
+func TestLevelSinkMasksEmails(t *testing.T) {
+	rec := &recordingSink{}
+	sink := NewLevelSink(rec, jw6_utils.Info)
+	sink.Log("c", "m", jw6_utils.Info, "login for ann@example.com")
+	sink.SetMaskEmails(true)
+	sink.Log("c", "m", jw6_utils.Info, "login for ann@example.com")
+	if rec.messages[0] != "login for ann@example.com" || rec.messages[1] != "login for [redacted]" {
+		t.Fatalf("unexpected messages %q", rec.messages)
+	}
+}
@@ -0,0 +1,186 @@
+// Package mail sends scheduling email over SMTP, including iCalendar
+// invitations in the iMIP format (RFC 6047).
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+// Message is one outgoing email. When Calendar is set it is attached as a
+// text/calendar part with the given iTIP Method (e.g. REQUEST), which mail
+// clients show as an invitation.
+type Message struct {
+	To       []string
+	Subject  string
+	Text     string
+	Calendar string
+	Method   string
+}
+
+// Mailer delivers messages through the configured SMTP server. A nil
+// *Mailer means email is disabled.
+type Mailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	now  func() time.Time
+}
+
+// New returns a Mailer for cfg.SMTP, or nil when no SMTP host is configured.
+func New(cfg *config.Config) *Mailer {
+	if cfg == nil || cfg.SMTP.Host == "" {
+		return nil
+	}
+	m := &Mailer{
+		addr: net.JoinHostPort(cfg.SMTP.Host, strconv.Itoa(cfg.SMTP.Port)),
+		host: cfg.SMTP.Host,
+		from: cfg.SMTP.From,
+		send: smtp.SendMail,
+		now:  time.Now,
+	}
+	if cfg.SMTP.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
+	}
+	return m
+}
+
+// Send delivers msg. The SMTP connection is upgraded with STARTTLS when the
+// server offers it.
+func (m *Mailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("mail: no recipients")
+	}
+	body, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+	if err := m.send(m.addr, m.auth, m.from, msg.To, body); err != nil {
+		return fmt.Errorf("mail: send to %s: %w", strings.Join(msg.To, ", "), err)
+	}
+	return nil
+}
+
+func (m *Mailer) build(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		// Strip CR/LF so values cannot inject headers.
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", m.from)
+	writeHeader("To", strings.Join(msg.To, ", "))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", m.now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", "<"+messageID()+"@"+m.host+">")
+	writeHeader("MIME-Version", "1.0")
+
+	if msg.Calendar == "" {
+		writeHeader("Content-Type", "text/plain; charset=utf-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(text, msg.Text); err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(msg.Method))
+	if method == "" {
+		method = "REQUEST"
+	}
+	cal, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/calendar; charset=utf-8; method=" + method},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(cal, msg.Calendar); err != nil {
+		return nil, err
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Check connects to the SMTP server and signs in the way Send would,
+// without sending anything, so a wrong host, port or password shows up
+// before the first email is due.
+func (m *Mailer) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	return c.Quit()
+}
@@ -0,0 +1,152 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+func TestNewDisabledWithoutHost(t *testing.T) {
+	if New(&config.Config{}) != nil {
+		t.Fatal("expected nil mailer without an SMTP host")
+	}
+}
+
+func TestSendBuildsIMIPInvitation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SMTP.Host = "smtp.example.com"
+	cfg.SMTP.Port = 587
+	cfg.SMTP.From = "calcard@example.com"
+	m := New(cfg)
+	m.now = func() time.Time { return time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC) }
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var raw []byte
+	m.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, raw = addr, from, to, msg
+		return nil
+	}
+
+	ical := "BEGIN:VCALENDAR\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:abc\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	err := m.Send(Message{
+		To:       []string{"visitor@example.com"},
+		Subject:  "Booked: Intro call\r\nBcc: evil@example.com",
+		Text:     "See you then.",
+		Calendar: ical,
+		Method:   "request",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "calcard@example.com" || len(gotTo) != 1 || gotTo[0] != "visitor@example.com" {
+		t.Fatalf("unexpected envelope %q %q %v", gotAddr, gotFrom, gotTo)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Fatal("expected the subject unable to inject headers")
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", parsed.Header.Get("Content-Type"), err)
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var types []string
+	var calendar string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(part)
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/calendar") {
+			calendar = string(body)
+		}
+	}
+	if len(types) != 2 || types[1] != "text/calendar; charset=utf-8; method=REQUEST" {
+		t.Fatalf("unexpected parts %v", types)
+	}
+	if calendar != ical {
+		t.Fatalf("calendar part = %q, want %q", calendar, ical)
+	}
+}
+
+func TestCheckSignsInWithoutSending(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var commands []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 fake ESMTP\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			commands = append(commands, strings.Fields(line)[0])
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250-fake\r\n250 AUTH PLAIN\r\n"))
+			case strings.HasPrefix(line, "AUTH"):
+				conn.Write([]byte("535 5.7.8 authentication failed\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("502 unexpected\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	cfg := &config.Config{}
+	cfg.SMTP.Host = host
+	cfg.SMTP.Port, _ = strconv.Atoi(port)
+	cfg.SMTP.From = "calcard@example.com"
+	cfg.SMTP.Username = "calcard"
+	cfg.SMTP.Password = "wrong"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = New(cfg).Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("Check() error = %v, want the refused sign-in", err)
+	}
+	ln.Close()
+	<-done
+	for _, cmd := range commands {
+		if cmd == "MAIL" || cmd == "RCPT" || cmd == "DATA" {
+			t.Fatalf("Check() sent %s; commands = %v", cmd, commands)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package maintenance refuses client writes to calendars and address books
+// an admin has put into maintenance while running a bulk operation on them,
+// such as a merge or a restore. Clients can still read such a collection;
+// their writes get 503 Service Unavailable with a Retry-After, so they do
+// not interleave with the operation.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Types of the collections put into maintenance.
+const (
+	Calendar    = "calendar"
+	AddressBook = "addressbook"
+)
+
+// maxRetryAfter caps the Retry-After sent to clients, so they check back
+// within minutes even when the maintenance may run for hours.
+const maxRetryAfter = 5 * time.Minute
+
+// ErrActive reports a write refused because its collection is in
+// maintenance.
+var ErrActive = errors.New("collection is in maintenance")
+
+// Error is ErrActive with the maintenance that refused the write.
+type Error struct {
+	Maintenance store.CollectionMaintenance
+}
+
+func (e *Error) Error() string {
+	name := "calendar"
+	if e.Maintenance.CollectionType == AddressBook {
+		name = "address book"
+	}
+	msg := fmt.Sprintf("%s is in maintenance until %s", name, e.Maintenance.ExpiresAt.UTC().Format(time.RFC3339))
+	if e.Maintenance.Reason != "" {
+		msg += ": " + e.Maintenance.Reason
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrActive
+}
+
+// RetryAfter returns how many seconds a client should wait at now before
+// trying the write again.
+func (e *Error) RetryAfter(now time.Time) int {
+	wait := min(e.Maintenance.ExpiresAt.Sub(now), maxRetryAfter)
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
+
+// Check returns an *Error when the collection is in maintenance. Nothing is
+// ever in maintenance in a store without a maintenance repository.
+func Check(ctx context.Context, st *store.Store, collectionType string, collectionID int64) error {
+	if st == nil || st.Maintenance == nil {
+		return nil
+	}
+	m, err := st.Maintenance.Get(ctx, collectionType, collectionID)
+	if err != nil || m == nil {
+		return err
+	}
+	return &Error{Maintenance: *m}
+}
+
+// WriteError answers 503 with Retry-After when err is an *Error, and
+// reports whether it did.
+func WriteError(w http.ResponseWriter, err error) bool {
+	var active *Error
+	if !errors.As(err, &active) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(active.RetryAfter(time.Now())))
+	http.Error(w, active.Error(), http.StatusServiceUnavailable)
+	return true
+}
@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var davAuthCache = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "calcard_dav_auth_cache_total",
+	Help: "Total number of DAV Basic-auth credential checks, by whether the verified-credentials cache answered them.",
+}, []string{"result"})
+
+// IncDAVAuthCache counts a credential check answered from the cache ("hit")
+// or verified against the stored hashes ("miss").
+func IncDAVAuthCache(result string) {
+	davAuthCache.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dbRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "calcard_db_retries_total",
+		Help: "Total number of database calls retried after a connection error.",
+	})
+	dbCircuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "calcard_db_circuit_open",
+		Help: "Whether the database circuit breaker is open (1) and failing calls fast, or closed (0).",
+	})
+	dbCircuitTrips = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "calcard_db_circuit_trips_total",
+		Help: "Total number of times the database circuit breaker opened.",
+	})
+	dbCircuitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "calcard_db_circuit_rejections_total",
+		Help: "Total number of database calls refused while the circuit breaker was open.",
+	})
+)
+
+// IncDBRetry counts a database call retried after a connection error.
+func IncDBRetry() {
+	dbRetries.Inc()
+}
+
+// SetDBCircuitOpen records whether the database circuit breaker is open,
+// counting each transition to open as a trip.
+func SetDBCircuitOpen(open bool) {
+	if open {
+		dbCircuitTrips.Inc()
+		dbCircuitState.Set(1)
+		return
+	}
+	dbCircuitState.Set(0)
+}
+
+// IncDBCircuitRejection counts a database call refused by the open breaker.
+func IncDBCircuitRejection() {
+	dbCircuitRejections.Inc()
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var fsckIssues = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "calcard_fsck_issues",
+	Help: "Number of issues the last consistency check found, by kind and whether it repaired them.",
+}, []string{"kind", "repaired"})
+
+// SetFsckIssues records the issue counts of a finished consistency check.
+// Kinds missing from found or repaired are reported as zero.
+func SetFsckIssues(kinds []string, found, repaired map[string]int) {
+	for _, kind := range kinds {
+		fsckIssues.WithLabelValues(kind, "false").Set(float64(found[kind] - repaired[kind]))
+		fsckIssues.WithLabelValues(kind, "true").Set(float64(repaired[kind]))
+	}
+}
@@ -72,6 +72,9 @@ func Middleware() func(http.Handler) http.Handler {
 			if status >= http.StatusInternalServerError {
 				httpErrorsTotal.WithLabelValues(method, route, statusCode).Inc()
 			}
+			if status == http.StatusPreconditionFailed {
+				davPreconditionFailed.WithLabelValues(method, ClientFamily(r.UserAgent())).Inc()
+			}
 		})
 	}
 }
@@ -76,3 +76,67 @@ func TestRoutePatternFallsBackToPath(t *testing.T) {
 		t.Fatalf("routePattern() = %q", got)
 	}
 }
+
+func TestClientFamily(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", "unknown"},
+		{"iOS/17.4 (21E219) dataaccessd/1.0", "ios"},
+		{"macOS/14.4 (23E214) dataaccessd/1.0", "macos"},
+		{"Mac OS X/10.15.7 (19H2) CalendarAgent/954", "macos"},
+		{"DAVx5/4.3.13-ose (2024/02/13; dav4jvm; okhttp/4.12.0) Android/14", "davx5"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:115.0) Gecko/20100101 Thunderbird/115.9.0", "thunderbird"},
+		{"Evolution/3.50.4", "evolution"},
+		{"CalDavSynchronizer/4.4", "outlook"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)", "browser"},
+		{"calcardctl", "calcardctl"},
+		{"SomethingElse/1.0", "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want+"/"+tt.userAgent, func(t *testing.T) {
+			if got := ClientFamily(tt.userAgent); got != tt.want {
+				t.Fatalf("ClientFamily(%q) = %q, want %q", tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCountsPreconditionFailuresByClient(t *testing.T) {
+	davPreconditionFailed.Reset()
+
+	handler := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	req := httptest.NewRequest(http.MethodPut, "/dav/calendars/1/a.ics", nil)
+	req.Header.Set("User-Agent", "iOS/17.4 (21E219) dataaccessd/1.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := testutil.ToFloat64(davPreconditionFailed.WithLabelValues(http.MethodPut, "ios")); got != 1 {
+		t.Fatalf("precondition failed count = %v", got)
+	}
+}
+
+func TestSyncMetricsUseClientFamily(t *testing.T) {
+	davSyncResults.Reset()
+	davInvalidSyncTokens.Reset()
+	davPutValidationFailures.Reset()
+
+	req := httptest.NewRequest("REPORT", "/dav/calendars/1/", nil)
+	req.Header.Set("User-Agent", "DAVx5/4.3 okhttp/4.12.0")
+	ObserveSyncCollection(req, "calendar", false, 3)
+	IncInvalidSyncToken(req, "calendar")
+	IncPutValidationFailure(req, "addressbook", http.StatusBadRequest)
+
+	if got := testutil.CollectAndCount(davSyncResults); got != 1 {
+		t.Fatalf("sync result series = %d", got)
+	}
+	if got := testutil.ToFloat64(davInvalidSyncTokens.WithLabelValues("calendar", "davx5")); got != 1 {
+		t.Fatalf("invalid sync token count = %v", got)
+	}
+	if got := testutil.ToFloat64(davPutValidationFailures.WithLabelValues("addressbook", "400", "davx5")); got != 1 {
+		t.Fatalf("put validation failure count = %v", got)
+	}
+}
@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var davNotFoundCache = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "calcard_dav_not_found_cache_total",
+	Help: "Total number of DAV GETs of calendar objects and contacts, by whether the not-found cache answered them.",
+}, []string{"result"})
+
+// IncDAVNotFoundCache counts a resource GET answered 404 from the not-found
+// cache ("hit") or looked up in the database ("miss").
+func IncDAVNotFoundCache(result string) {
+	davNotFoundCache.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	probeRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_probe_runs_total",
+		Help: "Total number of synthetic probe runs, by whether every step succeeded.",
+	}, []string{"result"})
+	probeStepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "calcard_probe_step_duration_seconds",
+		Help:    "Histogram of synthetic probe step latencies, by step and whether it succeeded.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"step", "result"})
+)
+
+// ObserveProbeStep records how long a step of the synthetic probe took.
+func ObserveProbeStep(step string, ok bool, d time.Duration) {
+	probeStepDuration.WithLabelValues(step, probeResult(ok)).Observe(d.Seconds())
+}
+
+// IncProbeRuns counts a finished synthetic probe run.
+func IncProbeRuns(ok bool) {
+	probeRuns.WithLabelValues(probeResult(ok)).Inc()
+}
+
+func probeResult(ok bool) string {
+	if ok {
+		return "success"
+	}
+	return "failure"
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	davSyncResults = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "calcard_dav_sync_collection_results",
+		Help:    "Number of changed or removed members returned by sync-collection REPORTs.",
+		Buckets: []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000},
+	}, []string{"collection", "sync", "client"})
+
+	davInvalidSyncTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_dav_invalid_sync_token_total",
+		Help: "Total number of sync-collection REPORTs rejected for an invalid sync token, forcing a full resync.",
+	}, []string{"collection", "client"})
+
+	davPreconditionFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_http_precondition_failed_total",
+		Help: "Total number of requests answered with 412 Precondition Failed.",
+	}, []string{"method", "client"})
+
+	davFullResyncThrottled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_dav_full_resync_throttled_total",
+		Help: "Total number of token-less sync-collection REPORTs served in pages because the account exceeded its full-resync budget.",
+	}, []string{"collection", "client"})
+
+	davReportsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_dav_report_concurrency_rejected_total",
+		Help: "Total number of REPORTs rejected because the account had too many in flight.",
+	}, []string{"client"})
+
+	davDeletionsHeld = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_dav_deletions_held_total",
+		Help: "Total number of DAV DELETEs held for owner review by mass-deletion protection.",
+	}, []string{"collection", "client"})
+
+	davPutValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "calcard_dav_put_validation_failures_total",
+		Help: "Total number of DAV PUT requests rejected as invalid.",
+	}, []string{"collection", "status", "client"})
+)
+
+// clientFamilies maps lower-cased User-Agent substrings to a bounded label set.
+// Order matters: more specific clients come before the platforms they run on.
+var clientFamilies = []struct {
+	needle string
+	family string
+}{
+	{"davx5", "davx5"},
+	{"davdroid", "davx5"},
+	{"thunderbird", "thunderbird"},
+	{"evolution", "evolution"},
+	{"gnome", "gnome"},
+	{"caldavsynchronizer", "outlook"},
+	{"outlook", "outlook"},
+	{"microsoft", "outlook"},
+	{"calcardctl", "calcardctl"},
+	{"mozilla", "browser"},
+	{"ios/", "ios"},
+	{"iphone", "ios"},
+	{"ipad", "ios"},
+	{"macos", "macos"},
+	{"mac os x", "macos"},
+	{"mac_os_x", "macos"},
+	{"calendaragent", "macos"},
+	{"addressbookcore", "macos"},
+	{"calendarstore", "macos"},
+	{"dataaccessd", "ios"},
+	{"android", "android"},
+	{"okhttp", "android"},
+	{"windows", "windows"},
+	{"go-http-client", "go"},
+	{"curl", "curl"},
+}
+
+// ClientFamily classifies a User-Agent into a small, fixed set of client
+// families suitable for use as a metric label.
+func ClientFamily(userAgent string) string {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return "unknown"
+	}
+	for _, c := range clientFamilies {
+		if strings.Contains(ua, c.needle) {
+			return c.family
+		}
+	}
+	return "other"
+}
+
+// ObserveSyncCollection records the size of a sync-collection result. members
+// excludes the collection's own response; initial marks a request without a token.
+func ObserveSyncCollection(r *http.Request, collection string, initial bool, members int) {
+	sync := "incremental"
+	if initial {
+		sync = "initial"
+	}
+	davSyncResults.WithLabelValues(collection, sync, ClientFamily(r.UserAgent())).Observe(float64(members))
+}
+
+// IncInvalidSyncToken counts a sync-collection REPORT rejected for a stale or
+// malformed token.
+func IncInvalidSyncToken(r *http.Request, collection string) {
+	davInvalidSyncTokens.WithLabelValues(collection, ClientFamily(r.UserAgent())).Inc()
+}
+
+// IncFullResyncThrottled counts a full resync that was switched to paged
+// responses because the account resynced too often.
+func IncFullResyncThrottled(r *http.Request, collection string) {
+	davFullResyncThrottled.WithLabelValues(collection, ClientFamily(r.UserAgent())).Inc()
+}
+
+// IncReportConcurrencyRejected counts a REPORT turned away because the account
+// already had the maximum number of REPORTs in flight.
+func IncReportConcurrencyRejected(r *http.Request) {
+	davReportsRejected.WithLabelValues(ClientFamily(r.UserAgent())).Inc()
+}
+
+// IncDeletionHeld counts a DAV DELETE quarantined because the client was
+// deleting most of the collection.
+func IncDeletionHeld(r *http.Request, collection string) {
+	davDeletionsHeld.WithLabelValues(collection, ClientFamily(r.UserAgent())).Inc()
+}
+
+// IncPutValidationFailure counts a DAV PUT rejected with the given status.
+func IncPutValidationFailure(r *http.Request, collection string, status int) {
+	davPutValidationFailures.WithLabelValues(collection, strconv.Itoa(status), ClientFamily(r.UserAgent())).Inc()
+}
@@ -0,0 +1,107 @@
+// Package netguard builds the HTTP client the server posts to user-supplied
+// URLs with — push resources, notification providers and conference hooks —
+// so that a user cannot make it reach its own network.
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// nonPublic lists the ranges that are not routable on the internet or that
+// reach back into the server's own network: RFC 6890 special-purpose
+// ranges, translation prefixes that embed an IPv4 address, and the
+// deprecated IPv6 site-local block.
+var nonPublic = func() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range []string{
+		"0.0.0.0/8",       // this network
+		"10.0.0.0/8",      // private
+		"100.64.0.0/10",   // carrier-grade NAT
+		"127.0.0.0/8",     // loopback
+		"169.254.0.0/16",  // link-local, including cloud metadata
+		"172.16.0.0/12",   // private
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"192.88.99.0/24",  // 6to4 relay anycast
+		"192.168.0.0/16",  // private
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"224.0.0.0/4",     // multicast
+		"240.0.0.0/4",     // reserved and broadcast
+		"::/96",           // unspecified, loopback and IPv4-compatible
+		"64:ff9b::/96",    // NAT64
+		"64:ff9b:1::/48",  // local-use NAT64
+		"100::/64",        // discard
+		"2001::/23",       // IETF protocol assignments, including Teredo
+		"2001:db8::/32",   // documentation
+		"2002::/16",       // 6to4
+		"3fff::/20",       // documentation
+		"fc00::/7",        // unique local
+		"fe80::/10",       // link-local
+		"fec0::/10",       // site-local
+		"ff00::/8",        // multicast
+	} {
+		prefixes = append(prefixes, netip.MustParsePrefix(s))
+	}
+	return prefixes
+}()
+
+// Public reports whether addr is a unicast address routable on the
+// internet. IPv4-mapped IPv6 addresses are judged by their IPv4 address.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicHost reports whether a URL host may be posted to. Host names pass,
+// as they are checked when they are dialled; IP literals must be Public.
+func PublicHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return Public(addr)
+	}
+	return host != ""
+}
+
+// NewClient returns a client that does not follow redirects or use a
+// proxy and, unless allowPrivate is set, refuses to connect to any address
+// that is not Public, whatever a host name resolves to.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !Public(addr) {
+				return fmt.Errorf("netguard: refusing to connect to %s", address)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package netguard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.215.14":        true,
+		"2606:4700:4700::1111": true,
+		"::ffff:93.184.215.14": true,
+		"0.1.2.3":              false,
+		"10.1.2.3":             false,
+		"100.64.0.1":           false,
+		"100.127.255.254":      false,
+		"127.0.0.1":            false,
+		"169.254.169.254":      false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"198.18.0.1":           false,
+		"198.19.255.255":       false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::":                   false,
+		"::1":                  false,
+		"::ffff:192.168.1.1":   false,
+		"64:ff9b::a00:1":       false,
+		"64:ff9b:1::1":         false,
+		"2001:db8::1":          false,
+		"2002:a00:1::1":        false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"fec0::1":              false,
+		"ff02::1":              false,
+	} {
+		if got := Public(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Public(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPublicHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"push.example.net": true,
+		"93.184.215.14":    true,
+		"100.64.0.1":       false,
+		"::1":              false,
+		"":                 false,
+	} {
+		if got := PublicHost(host); got != want {
+			t.Errorf("PublicHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if resp, err := NewClient(false).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("guarded client connected to a loopback address")
+	}
+	resp, err := NewClient(true).Get(server.URL + "/redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want the redirect itself", resp.StatusCode)
+	}
+}
@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/netguard"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// alarmInterval is how often alarms are checked. An alarm is queued one
+// interval ahead, so it goes out within an interval of firing.
+const alarmInterval = time.Minute
+
+// alarmCatchUp bounds how late an alarm is still sent, so a server that was
+// down does not deliver reminders of meetings long over.
+const alarmCatchUp = 15 * time.Minute
+
+// alarmLease is how long a claimed delivery is left to its sender before
+// another replica may try it.
+const alarmLease = 2 * time.Minute
+
+// alarmBatch bounds the deliveries sent per check.
+const alarmBatch = 200
+
+// alarmRetention is how long deliveries are kept for users to look back on.
+const alarmRetention = 30 * 24 * time.Hour
+
+// alarmRetries are the waits before each retry of a failed delivery. It is
+// given up after the last.
+var alarmRetries = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+// Alarms pushes event alarms to the notification providers users set up,
+// such as Gotify, ntfy or Pushover, for self-hosters without their own
+// mobile push service. Every delivery is recorded, and failed ones retried.
+type Alarms struct {
+	store  *store.Store
+	events *events.Service
+	client *http.Client
+	log    *logging.Logger
+	now    func() time.Time
+	pruned time.Time
+	// allowPrivate lets providers use plain http and private addresses.
+	allowPrivate bool
+}
+
+// NewAlarms returns the alarm dispatcher for cfg.
+func NewAlarms(cfg *config.Config, st *store.Store, sink logging.Sink) *Alarms {
+	allowPrivate := cfg != nil && cfg.Alarms.AllowPrivateProviders
+	return &Alarms{
+		store:        st,
+		events:       events.NewService(st),
+		client:       netguard.NewClient(allowPrivate),
+		log:          logging.New(sink, "notify"),
+		now:          time.Now,
+		allowPrivate: allowPrivate,
+	}
+}
+
+// Normalize validates a provider before it is saved. Providers must use
+// public https URLs unless APP_ALARM_PRIVATE_PROVIDERS is set.
+func (a *Alarms) Normalize(p *store.NotificationProvider) error {
+	return normalizeProvider(p, a.allowPrivate)
+}
+
+// Start sends due alarms every alarm interval until ctx is cancelled.
+func (a *Alarms) Start(ctx context.Context) {
+	ticker := time.NewTicker(alarmInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := a.SendDue(ctx); err != nil {
+			a.log.Error("Start", "failed to send alarms: %v", err)
+		}
+	}
+}
+
+// SendDue queues the alarms firing before the next check for each of their
+// owner's providers, then sends the deliveries that are due, including
+// retries. Each alarm goes to each provider once, however many replicas
+// check.
+func (a *Alarms) SendDue(ctx context.Context) error {
+	now := a.now()
+	providers, err := a.store.Providers.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]store.NotificationProvider, len(providers))
+	for start := 0; start < len(providers); {
+		end := start
+		for end < len(providers) && providers[end].UserID == providers[start].UserID {
+			byID[providers[end].ID] = providers[end]
+			end++
+		}
+		if err := a.queue(ctx, providers[start].UserID, providers[start:end], now); err != nil {
+			a.log.Error("SendDue", "failed to queue alarms of user %d: %v", providers[start].UserID, err)
+		}
+		start = end
+	}
+
+	due, err := a.store.AlarmDeliveries.ClaimDue(ctx, now, now.Add(alarmLease), alarmBatch)
+	if err != nil {
+		return err
+	}
+	for _, d := range due {
+		p, ok := byID[d.ProviderID]
+		if !ok {
+			// Added since the providers were listed; the lease runs out
+			// before the next check.
+			continue
+		}
+		a.deliver(ctx, p, d)
+	}
+
+	if now.Sub(a.pruned) >= time.Hour {
+		if _, err := a.store.AlarmDeliveries.DeleteBefore(ctx, now.Add(-alarmRetention)); err != nil {
+			return err
+		}
+		a.pruned = now
+	}
+	return nil
+}
+
+// queue records a delivery to each provider for every alarm of the user's
+// firing from the catch-up window up to the next check.
+func (a *Alarms) queue(ctx context.Context, userID int64, providers []store.NotificationProvider, now time.Time) error {
+	user, err := a.store.Users.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return err
+	}
+	alarms, err := a.events.DueAlarms(ctx, user, now.Add(-alarmCatchUp), now.Add(alarmInterval))
+	if err != nil {
+		return err
+	}
+	prefs := locale.ForUser(user)
+	for _, alarm := range alarms {
+		title, message := alarmText(prefs, alarm)
+		for _, p := range providers {
+			_, err := a.store.AlarmDeliveries.Enqueue(ctx, store.AlarmDelivery{
+				ProviderID:    p.ID,
+				UserID:        userID,
+				CalendarID:    alarm.CalendarID,
+				UID:           alarm.UID,
+				AlarmID:       alarm.AlarmID,
+				FireAt:        alarm.FireAt,
+				Title:         title,
+				Message:       message,
+				NextAttemptAt: alarm.FireAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deliver sends a claimed delivery and records how it went. A failure is
+// retried after the next wait in alarmRetries, unless retrying cannot help.
+func (a *Alarms) deliver(ctx context.Context, p store.NotificationProvider, d store.AlarmDelivery) {
+	err := sendAlarm(ctx, a.client, p, d)
+	if err == nil {
+		err = a.store.AlarmDeliveries.MarkSent(ctx, d.ID, a.now())
+	} else {
+		var retryAt *time.Time
+		var permanent permanentError
+		if !errors.As(err, &permanent) && d.Attempts <= len(alarmRetries) {
+			at := a.now().Add(alarmRetries[d.Attempts-1])
+			retryAt = &at
+		}
+		a.log.Warn("deliver", "failed to send alarm %d to provider %d: %v", d.ID, p.ID, err)
+		err = a.store.AlarmDeliveries.MarkFailed(ctx, d.ID, err.Error(), retryAt)
+	}
+	if err != nil {
+		a.log.Error("deliver", "failed to record alarm %d: %v", d.ID, err)
+	}
+}
+
+// SendTest sends a test message to a provider, so a user can check its
+// settings before an alarm depends on them.
+func (a *Alarms) SendTest(ctx context.Context, user *store.User, p store.NotificationProvider) error {
+	prefs := locale.ForUser(user)
+	now := a.now()
+	return sendAlarm(ctx, a.client, p, store.AlarmDelivery{
+		UserID:  user.ID,
+		AlarmID: "test",
+		FireAt:  now,
+		Title:   "calcard",
+		Message: prefs.T("alarms.test"),
+	})
+}
+
+// alarmText returns the title and message of an alarm: the event's
+// summary, then when it starts, its calendar and location.
+func alarmText(prefs locale.Preferences, alarm events.DueAlarm) (string, string) {
+	title := alarm.Summary
+	if strings.TrimSpace(title) == "" {
+		title = prefs.T("untitled")
+	}
+	var when string
+	if alarm.AllDay {
+		// All-day dates float: keep the stored day in any timezone.
+		at := alarm.Start
+		when = prefs.FormatMonthDay(time.Date(at.Year(), at.Month(), at.Day(), 12, 0, 0, 0, prefs.Location))
+	} else {
+		when = prefs.FormatMonthDay(alarm.Start) + ", " + prefs.FormatTime(alarm.Start)
+	}
+	message := when + " (" + alarm.CalendarName + ")"
+	if alarm.Location != "" {
+		message += ", " + alarm.Location
+	}
+	return title, message
+}
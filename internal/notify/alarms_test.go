@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestSendDuePushesAlarmsToEachProviderAndRetries(t *testing.T) {
+	var gotify []string
+	gotifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Title, Message string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotify = append(gotify, r.URL.Path+" "+r.Header.Get("X-Gotify-Key")+" "+body.Title+": "+body.Message)
+	}))
+	defer gotifyServer.Close()
+	webhookStatus := http.StatusBadGateway
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(webhookStatus)
+	}))
+	defer webhookServer.Close()
+	ntfyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Title") != "Standup" || !strings.Contains(string(body), "(Work)") {
+			t.Errorf("ntfy got %q: %s", r.Header.Get("Title"), body)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ntfyServer.Close()
+
+	f := storetest.New()
+	f.AddCalendar(storetest.OwnedCalendar(1, 1, "Work"))
+	start := time.Date(2026, 5, 2, 9, 0, 0, 0, time.UTC)
+	ev := storetest.Event(1, "standup", "Standup", start)
+	ev.RawICAL = strings.Replace(ev.RawICAL, "END:VEVENT", "BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT", 1)
+	f.AddEvent(ev)
+	providers := &storetest.Providers{List: []store.NotificationProvider{
+		{ID: 1, UserID: 1, Kind: store.ProviderGotify, URL: gotifyServer.URL, Token: "app-token"},
+		{ID: 2, UserID: 1, Kind: store.ProviderWebhook, URL: webhookServer.URL},
+		{ID: 3, UserID: 1, Kind: store.ProviderNtfy, URL: ntfyServer.URL + "/alarms"},
+	}}
+	deliveries := &storetest.AlarmDeliveries{}
+	f.Store.Users = &fakeUsers{}
+	f.Store.Providers = providers
+	f.Store.AlarmDeliveries = deliveries
+
+	cfg := &config.Config{}
+	cfg.Alarms.AllowPrivateProviders = true
+	a := NewAlarms(cfg, f.Store, nil)
+	now := start.Add(-15*time.Minute - 30*time.Second)
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Queued ahead of firing, and sent once it fires.
+	if err := a.SendDue(ctx); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if len(deliveries.List) != 3 || len(gotify) != 0 {
+		t.Fatalf("before firing: deliveries %+v, gotify %v", deliveries.List, gotify)
+	}
+	now = now.Add(time.Minute)
+	for range 2 {
+		if err := a.SendDue(ctx); err != nil {
+			t.Fatalf("SendDue() error = %v", err)
+		}
+	}
+	if len(deliveries.List) != 3 || len(gotify) != 1 || !strings.HasPrefix(gotify[0], "/message app-token Standup: ") {
+		t.Fatalf("after firing: deliveries %+v, gotify %v", deliveries.List, gotify)
+	}
+	status := func(id int64) string {
+		d := deliveries.List[id-1]
+		return d.Status + " " + d.LastError
+	}
+	if got := status(1); got != "sent " {
+		t.Errorf("gotify delivery = %q", got)
+	}
+	if got := status(2); got != "pending webhook answered 502 Bad Gateway" {
+		t.Errorf("webhook delivery = %q", got)
+	}
+	if got := status(3); got != "failed ntfy answered 401 Unauthorized" {
+		t.Errorf("ntfy delivery = %q, want given up", got)
+	}
+
+	webhookStatus = http.StatusNoContent
+	now = now.Add(time.Minute)
+	if err := a.SendDue(ctx); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if d := deliveries.List[1]; d.Status != store.DeliverySent || d.Attempts != 2 {
+		t.Fatalf("retried webhook delivery = %+v", d)
+	}
+}
+
+func TestNormalizeProvider(t *testing.T) {
+	a := NewAlarms(&config.Config{}, nil, nil)
+	for _, p := range []store.NotificationProvider{
+		{Kind: "gotify", URL: "https://push.example.com"},
+		{Kind: "ntfy", URL: "http://ntfy.example.com/alarms"},
+		{Kind: "webhook", URL: "https://192.168.1.5/hook"},
+		{Kind: "pushover", Token: "app"},
+		{Kind: "apns", URL: "https://push.example.com"},
+	} {
+		if err := a.Normalize(&p); err == nil {
+			t.Errorf("Normalize(%+v) accepted", p)
+		}
+	}
+	p := store.NotificationProvider{Kind: " Pushover ", Token: "app", Recipient: "user", URL: "https://ignored.example.com"}
+	if err := a.Normalize(&p); err != nil || p.Kind != "pushover" || p.Name != "pushover" || p.URL != "" {
+		t.Fatalf("Normalize() = %+v, %v", p, err)
+	}
+}
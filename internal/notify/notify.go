@@ -0,0 +1,348 @@
+// Package notify emails users about changes other users make in the
+// calendars and address books they follow. Changes are gathered from the
+// change feed and sent in batches, so a burst of edits becomes one email.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Collection types a user can follow.
+const (
+	Calendar    = "calendar"
+	AddressBook = "addressbook"
+)
+
+var (
+	// ErrInvalidType reports a collection type other than calendar or
+	// addressbook.
+	ErrInvalidType = errors.New("collection type must be calendar or addressbook")
+	// ErrNotFound reports a collection the user cannot read.
+	ErrNotFound = errors.New("collection not found")
+)
+
+// batchInterval is how often followed collections are checked. Everything
+// that changed in between goes out in one email per user.
+const batchInterval = 10 * time.Minute
+
+// pageSize is how many changes are read from the feed at a time.
+const pageSize = 500
+
+// maxListed bounds the changes listed per collection; the rest are counted.
+const maxListed = 20
+
+// sender delivers notification email; *mail.Mailer implements it.
+type sender interface {
+	Send(msg mail.Message) error
+}
+
+// Service sends change notifications.
+type Service struct {
+	store    *store.Store
+	events   *events.Service
+	contacts *contacts.Service
+	mailer   sender
+	log      *logging.Logger
+	now      func() time.Time
+}
+
+// New returns the notification service for cfg. It sends nothing when
+// email is not configured.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	s := &Service{store: st, events: events.NewService(st), contacts: contacts.NewService(st), log: logging.New(sink, "notify"), now: time.Now}
+	if mailer := mail.New(cfg); mailer != nil {
+		s.mailer = mailer
+	}
+	return s
+}
+
+// Enabled reports whether notifications can be sent at all.
+func (s *Service) Enabled() bool {
+	return s.mailer != nil
+}
+
+// Follow subscribes user to email about later changes other users make in
+// the collection. Following a collection twice keeps the first
+// subscription.
+func (s *Service) Follow(ctx context.Context, user *store.User, collectionType string, collectionID int64) (*store.CollectionNotification, error) {
+	if collectionType != Calendar && collectionType != AddressBook {
+		return nil, ErrInvalidType
+	}
+	names, err := s.readable(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := names[collectionKey{collectionType, collectionID}]; !ok {
+		return nil, ErrNotFound
+	}
+	cursor, err := s.store.Changes.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Notifications.Subscribe(ctx, store.CollectionNotification{
+		UserID:         user.ID,
+		CollectionType: collectionType,
+		CollectionID:   collectionID,
+		Cursor:         cursor,
+	})
+}
+
+// Unfollow stops notifications about the collection.
+func (s *Service) Unfollow(ctx context.Context, user *store.User, collectionType string, collectionID int64) error {
+	if collectionType != Calendar && collectionType != AddressBook {
+		return ErrInvalidType
+	}
+	return s.store.Notifications.Unsubscribe(ctx, user.ID, collectionType, collectionID)
+}
+
+// Start sends notifications every batch interval until ctx is cancelled.
+// It returns at once when email is not configured.
+func (s *Service) Start(ctx context.Context) {
+	if s.mailer == nil {
+		return
+	}
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.SendDue(ctx); err != nil {
+			s.log.Error("Start", "failed to send change notifications: %v", err)
+		}
+	}
+}
+
+// SendDue emails each subscriber the changes other users made in the
+// collections they follow since they were last told. A user who made the
+// changes, or a collection they can no longer read, gets no email; a
+// failed email is retried on the next check.
+func (s *Service) SendDue(ctx context.Context) error {
+	if s.mailer == nil {
+		return nil
+	}
+	all, err := s.store.Notifications.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(all); {
+		end := start
+		for end < len(all) && all[end].UserID == all[start].UserID {
+			end++
+		}
+		if err := s.notifyUser(ctx, all[start].UserID, all[start:end]); err != nil {
+			s.log.Error("SendDue", "failed to notify user %d: %v", all[start].UserID, err)
+		}
+		start = end
+	}
+	return nil
+}
+
+type collectionKey struct {
+	Type string
+	ID   int64
+}
+
+// Section is the changes to one collection in a notification.
+type Section struct {
+	Name    string
+	Type    string
+	Changes []Line
+	// More counts the changes left out of Changes.
+	More int
+}
+
+// Line describes one change.
+type Line struct {
+	// Actor is empty when the user who made the change is gone.
+	Actor   string
+	Action  string // "added", "changed" or "deleted"
+	Subject string
+}
+
+func (s *Service) notifyUser(ctx context.Context, userID int64, subs []store.CollectionNotification) error {
+	user, err := s.store.Users.GetByID(ctx, userID)
+	if err != nil || user == nil || user.PrimaryEmail == "" {
+		return err
+	}
+	names, err := s.readable(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	type advance struct {
+		sub    store.CollectionNotification
+		cursor store.ChangeCursor
+		told   bool
+	}
+	var sections []Section
+	var advances []advance
+	actors := map[int64]string{}
+	for _, sub := range subs {
+		key := collectionKey{sub.CollectionType, sub.CollectionID}
+		name, ok := names[key]
+		if !ok {
+			continue
+		}
+		changes, cursor, err := s.othersChanges(ctx, user.ID, key, sub.Cursor)
+		if err != nil {
+			return err
+		}
+		if cursor == sub.Cursor {
+			continue
+		}
+		advances = append(advances, advance{sub: sub, cursor: cursor, told: len(changes) > 0})
+		if len(changes) == 0 {
+			continue
+		}
+		section := Section{Name: name, Type: sub.CollectionType}
+		for i, change := range changes {
+			if i == maxListed {
+				section.More = len(changes) - maxListed
+				break
+			}
+			section.Changes = append(section.Changes, s.describe(ctx, change, actors))
+		}
+		sections = append(sections, section)
+	}
+
+	if len(sections) > 0 {
+		msg := Compose(locale.ForUser(user), sections)
+		msg.To = []string{user.PrimaryEmail}
+		if err := s.mailer.Send(msg); err != nil {
+			return err
+		}
+	}
+	now := s.now()
+	for _, a := range advances {
+		var notifiedAt *time.Time
+		if a.told {
+			notifiedAt = &now
+		}
+		if err := s.store.Notifications.Advance(ctx, userID, a.sub.CollectionType, a.sub.CollectionID, a.cursor, notifiedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// othersChanges reads the collection's changes after cursor, keeping those
+// made by a user other than userID, and returns the cursor after them all.
+func (s *Service) othersChanges(ctx context.Context, userID int64, key collectionKey, cursor store.ChangeCursor) ([]store.Change, store.ChangeCursor, error) {
+	var calendarIDs, bookIDs []int64
+	if key.Type == Calendar {
+		calendarIDs = []int64{key.ID}
+	} else {
+		bookIDs = []int64{key.ID}
+	}
+	var kept []store.Change
+	for {
+		page, err := s.store.Changes.ListSince(ctx, calendarIDs, bookIDs, cursor, pageSize)
+		if err != nil {
+			return nil, cursor, err
+		}
+		for _, change := range page {
+			cursor = change.Cursor
+			if change.ChangedBy != 0 && change.ChangedBy != userID {
+				kept = append(kept, change)
+			}
+		}
+		if len(page) < pageSize {
+			return kept, cursor, nil
+		}
+	}
+}
+
+// readable returns the names of the collections user can read.
+func (s *Service) readable(ctx context.Context, user *store.User) (map[collectionKey]string, error) {
+	calendars, err := s.events.ListCalendars(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	books, err := s.contacts.ListAccessibleAddressBooks(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[collectionKey]string, len(calendars)+len(books))
+	for _, cal := range calendars {
+		names[collectionKey{Calendar, cal.ID}] = cal.Name
+	}
+	for _, book := range books {
+		names[collectionKey{AddressBook, book.ID}] = book.Name
+	}
+	return names, nil
+}
+
+// describe names who made change and what it touched. actors caches the
+// addresses of the users seen so far, empty for users who are gone.
+func (s *Service) describe(ctx context.Context, change store.Change, actors map[int64]string) Line {
+	actor, ok := actors[change.ChangedBy]
+	if !ok {
+		if u, err := s.store.Users.GetByID(ctx, change.ChangedBy); err == nil && u != nil {
+			actor = u.PrimaryEmail
+		}
+		actors[change.ChangedBy] = actor
+	}
+	line := Line{Actor: actor, Action: "changed", Subject: change.ResourceName}
+	switch {
+	case change.Deleted:
+		line.Action = "deleted"
+		return line
+	case change.Created:
+		line.Action = "added"
+	}
+	if change.ResourceType == "event" {
+		if ev, err := s.store.Events.GetByUID(ctx, change.CollectionID, change.UID); err == nil && ev != nil && ev.Summary != nil && strings.TrimSpace(*ev.Summary) != "" {
+			line.Subject = *ev.Summary
+		}
+	} else if c, err := s.store.Contacts.GetByUID(ctx, change.CollectionID, change.UID); err == nil && c != nil && c.DisplayName != nil && strings.TrimSpace(*c.DisplayName) != "" {
+		line.Subject = *c.DisplayName
+	}
+	return line
+}
+
+// Compose writes the notification email, without recipients, in the
+// user's language.
+func Compose(prefs locale.Preferences, sections []Section) mail.Message {
+	subject := prefs.T("notify.subject", sections[0].Name)
+	if len(sections) > 1 {
+		subject = prefs.Plural("notify.subjectMany", len(sections))
+	}
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		kind := prefs.T("notify.calendar")
+		if section.Type == AddressBook {
+			kind = prefs.T("notify.addressBook")
+		}
+		fmt.Fprintf(&b, "%s (%s)\n", section.Name, kind)
+		for _, line := range section.Changes {
+			actor := line.Actor
+			if actor == "" {
+				actor = prefs.T("notify.someone")
+			}
+			b.WriteString("  " + prefs.T("notify."+line.Action, actor, line.Subject) + "\n")
+		}
+		if section.More > 0 {
+			b.WriteString("  " + prefs.Plural("notify.more", section.More) + "\n")
+		}
+	}
+	b.WriteString("\n" + prefs.T("notify.footer") + "\n")
+	return mail.Message{Subject: subject, Text: b.String()}
+}
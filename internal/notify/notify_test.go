@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeNotifications struct {
+	store.CollectionNotificationRepository
+	all []store.CollectionNotification
+}
+
+func (f *fakeNotifications) ListAll(ctx context.Context) ([]store.CollectionNotification, error) {
+	return f.all, nil
+}
+
+func (f *fakeNotifications) Subscribe(ctx context.Context, n store.CollectionNotification) (*store.CollectionNotification, error) {
+	f.all = append(f.all, n)
+	return &n, nil
+}
+
+func (f *fakeNotifications) Advance(ctx context.Context, userID int64, collectionType string, collectionID int64, cursor store.ChangeCursor, notifiedAt *time.Time) error {
+	for i := range f.all {
+		if n := &f.all[i]; n.UserID == userID && n.CollectionType == collectionType && n.CollectionID == collectionID {
+			n.Cursor = cursor
+			if notifiedAt != nil {
+				n.LastNotifiedAt = notifiedAt
+			}
+		}
+	}
+	return nil
+}
+
+type fakeChanges struct {
+	store.ChangeRepository
+	changes []store.Change
+}
+
+func (f *fakeChanges) ListSince(ctx context.Context, calendarIDs, addressBookIDs []int64, after store.ChangeCursor, limit int) ([]store.Change, error) {
+	var page []store.Change
+	for _, c := range f.changes {
+		if (c.Cursor.TxID > after.TxID || (c.Cursor.TxID == after.TxID && c.Cursor.Seq > after.Seq)) && len(page) < limit {
+			page = append(page, c)
+		}
+	}
+	return page, nil
+}
+
+func (f *fakeChanges) Latest(ctx context.Context) (store.ChangeCursor, error) {
+	return store.ChangeCursor{TxID: 99}, nil
+}
+
+type fakeUsers struct {
+	store.UserRepository
+}
+
+func (f *fakeUsers) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	switch id {
+	case 1:
+		return &store.User{ID: 1, PrimaryEmail: "ann@example.com"}, nil
+	case 2:
+		return &store.User{ID: 2, PrimaryEmail: "bob@example.com"}, nil
+	}
+	return nil, nil
+}
+
+type fakeCalendars struct {
+	store.CalendarRepository
+}
+
+func (f *fakeCalendars) ListAccessible(ctx context.Context, userID int64) ([]store.CalendarAccess, error) {
+	return []store.CalendarAccess{{Calendar: store.Calendar{ID: 5, UserID: 2, Name: "Family"}, Shared: true, Editor: true}}, nil
+}
+
+type fakeAddressBooks struct {
+	store.AddressBookRepository
+}
+
+func (f *fakeAddressBooks) ListByUser(ctx context.Context, userID int64) ([]store.AddressBook, error) {
+	return nil, nil
+}
+
+type fakeEvents struct {
+	store.EventRepository
+}
+
+func (f *fakeEvents) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
+	summary := "Dinner at Grandma's"
+	return &store.Event{CalendarID: calendarID, UID: uid, Summary: &summary}, nil
+}
+
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(msg mail.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func newTestService(changes []store.Change) (*Service, *fakeNotifications, *fakeSender) {
+	notifications := &fakeNotifications{}
+	st := &store.Store{
+		Notifications: notifications,
+		Changes:       &fakeChanges{changes: changes},
+		Users:         &fakeUsers{},
+		Calendars:     &fakeCalendars{},
+		AddressBooks:  &fakeAddressBooks{},
+		Events:        &fakeEvents{},
+	}
+	sender := &fakeSender{}
+	s := &Service{store: st, events: events.NewService(st), contacts: contacts.NewService(st), mailer: sender, log: logging.New(nil, "notify"), now: time.Now}
+	return s, notifications, sender
+}
+
+func TestSendDueBatchesOtherUsersChanges(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	s, notifications, sender := newTestService([]store.Change{
+		{Cursor: store.ChangeCursor{TxID: 100, Seq: 1}, ResourceType: "event", CollectionID: 5, UID: "mine", ResourceName: "mine.ics", ChangedBy: 1, ChangedAt: at},
+		{Cursor: store.ChangeCursor{TxID: 101, Seq: 2}, ResourceType: "event", CollectionID: 5, UID: "dinner", ResourceName: "dinner.ics", Created: true, ChangedBy: 2, ChangedAt: at},
+		{Cursor: store.ChangeCursor{TxID: 102, Seq: 3}, ResourceType: "event", CollectionID: 5, UID: "old", ResourceName: "old.ics", Deleted: true, ChangedBy: 2, ChangedAt: at},
+		{Cursor: store.ChangeCursor{TxID: 103, Seq: 4}, ResourceType: "event", CollectionID: 5, UID: "touched", ResourceName: "touched.ics", ChangedAt: at},
+	})
+	ctx := context.Background()
+	ann := &store.User{ID: 1, PrimaryEmail: "ann@example.com"}
+	if _, err := s.Follow(ctx, ann, Calendar, 5); err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	notifications.all[0].Cursor = store.ChangeCursor{}
+
+	if err := s.SendDue(ctx); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if msg.To[0] != "ann@example.com" || msg.Subject != "Changes in Family" {
+		t.Fatalf("email = %+v", msg)
+	}
+	for _, want := range []string{"bob@example.com added Dinner at Grandma's", "bob@example.com deleted old.ics"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("email text missing %q:\n%s", want, msg.Text)
+		}
+	}
+	if strings.Contains(msg.Text, "mine.ics") || strings.Contains(msg.Text, "touched") {
+		t.Errorf("email lists the user's own or unattributed changes:\n%s", msg.Text)
+	}
+	if sub := notifications.all[0]; sub.Cursor != (store.ChangeCursor{TxID: 103, Seq: 4}) || sub.LastNotifiedAt == nil {
+		t.Fatalf("subscription after send = %+v", sub)
+	}
+
+	// Nothing new: no second email.
+	if err := s.SendDue(ctx); err != nil || len(sender.sent) != 1 {
+		t.Fatalf("second SendDue() sent %d emails, err %v", len(sender.sent), err)
+	}
+}
+
+func TestFollowRequiresReadableCollection(t *testing.T) {
+	s, _, _ := newTestService(nil)
+	ann := &store.User{ID: 1}
+	if _, err := s.Follow(context.Background(), ann, Calendar, 6); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Follow() of an unknown calendar error = %v", err)
+	}
+	if _, err := s.Follow(context.Background(), ann, "tasks", 5); !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("Follow() of an unknown type error = %v", err)
+	}
+	sub, err := s.Follow(context.Background(), ann, Calendar, 5)
+	if err != nil || sub.Cursor != (store.ChangeCursor{TxID: 99}) {
+		t.Fatalf("Follow() = %+v, %v; want to start at the latest change", sub, err)
+	}
+}
+
+func TestComposeWritesTheUsersLanguage(t *testing.T) {
+	msg := Compose(locale.ForUser(&store.User{Locale: "fr"}), []Section{
+		{Name: "Famille", Type: Calendar, Changes: []Line{{Action: "deleted", Subject: "Dîner"}}, More: 1},
+		{Name: "Amis", Type: AddressBook, Changes: []Line{{Actor: "bob@example.com", Action: "added", Subject: "Zoé"}}},
+	})
+	if msg.Subject != "Modifications dans 2 calendriers et carnets d'adresses" {
+		t.Fatalf("Subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"Famille (calendrier)", "Quelqu'un a supprimé Dîner", "et 1 autre\n", "Amis (carnet d'adresses)", "bob@example.com a ajouté Zoé"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("email text missing %q:\n%s", want, msg.Text)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/netguard"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// ErrInvalidProvider reports a notification provider that cannot be saved.
+var ErrInvalidProvider = errors.New("invalid notification provider")
+
+// pushoverURL is the Pushover message API.
+var pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// maxProviderName bounds the name a user gives a provider.
+const maxProviderName = 100
+
+// normalizeProvider trims a provider's settings and checks the ones its
+// kind needs.
+func normalizeProvider(p *store.NotificationProvider, allowPrivate bool) error {
+	p.Kind = strings.ToLower(strings.TrimSpace(p.Kind))
+	p.Name = strings.TrimSpace(p.Name)
+	p.URL = strings.TrimSpace(p.URL)
+	p.Token = strings.TrimSpace(p.Token)
+	p.Recipient = strings.TrimSpace(p.Recipient)
+	if p.Name == "" {
+		p.Name = p.Kind
+	}
+	if len(p.Name) > maxProviderName {
+		return fmt.Errorf("%w: name is longer than %d characters", ErrInvalidProvider, maxProviderName)
+	}
+	switch p.Kind {
+	case store.ProviderGotify:
+		if p.Token == "" {
+			return fmt.Errorf("%w: gotify needs an application token", ErrInvalidProvider)
+		}
+	case store.ProviderNtfy, store.ProviderWebhook:
+	case store.ProviderPushover:
+		if p.Token == "" || p.Recipient == "" {
+			return fmt.Errorf("%w: pushover needs an application token and a user key", ErrInvalidProvider)
+		}
+		p.URL = ""
+		return nil
+	default:
+		return fmt.Errorf("%w: kind must be gotify, ntfy, pushover or webhook", ErrInvalidProvider)
+	}
+	p.Recipient = ""
+	return validateProviderURL(p.URL, allowPrivate)
+}
+
+func validateProviderURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || u.User != nil || len(raw) > 2048 {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidProvider)
+	}
+	if allowPrivate {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidProvider)
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: url must be an https URL", ErrInvalidProvider)
+	}
+	if !netguard.PublicHost(u.Hostname()) {
+		return fmt.Errorf("%w: url must name a public host", ErrInvalidProvider)
+	}
+	return nil
+}
+
+// permanentError is a failure retrying will not fix, such as a refused
+// token.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// webhookPayload is the JSON body posted to webhook providers.
+type webhookPayload struct {
+	Title      string `json:"title"`
+	Message    string `json:"message"`
+	CalendarID int64  `json:"calendarId"`
+	UID        string `json:"uid"`
+	AlarmID    string `json:"alarmId"`
+	FireAt     string `json:"fireAt"`
+}
+
+// sendAlarm delivers an alarm to a provider.
+func sendAlarm(ctx context.Context, client *http.Client, p store.NotificationProvider, d store.AlarmDelivery) error {
+	var req *http.Request
+	var err error
+	switch p.Kind {
+	case store.ProviderGotify:
+		body, _ := json.Marshal(map[string]any{"title": d.Title, "message": d.Message, "priority": 8})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.URL, "/")+"/message", bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Gotify-Key", p.Token)
+		}
+	case store.ProviderNtfy:
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.URL, strings.NewReader(d.Message))
+		if err == nil {
+			req.Header.Set("Title", d.Title)
+			req.Header.Set("Tags", "alarm_clock")
+			req.Header.Set("Priority", "high")
+			if p.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+p.Token)
+			}
+		}
+	case store.ProviderPushover:
+		form := url.Values{"token": {p.Token}, "user": {p.Recipient}, "title": {d.Title}, "message": {d.Message}, "timestamp": {fmt.Sprint(d.FireAt.Unix())}}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	case store.ProviderWebhook:
+		body, _ := json.Marshal(webhookPayload{
+			Title:      d.Title,
+			Message:    d.Message,
+			CalendarID: d.CalendarID,
+			UID:        d.UID,
+			AlarmID:    d.AlarmID,
+			FireAt:     d.FireAt.UTC().Format(time.RFC3339),
+		})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			if p.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+p.Token)
+			}
+		}
+	default:
+		return permanentError{fmt.Errorf("unknown provider kind %q", p.Kind)}
+	}
+	if err != nil {
+		return permanentError{err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return nil
+	case code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests:
+		return permanentError{fmt.Errorf("%s answered %s", p.Kind, resp.Status)}
+	default:
+		return fmt.Errorf("%s answered %s", p.Kind, resp.Status)
+	}
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/quota"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// quotaInterval is how often storage use is checked against the quota.
+const quotaInterval = time.Hour
+
+// Quota warns users by email when their data crosses 80% and then 95% of
+// their storage quota, so that they can make room before their devices stop
+// syncing. Each level is reported once; a user who drops below it is warned
+// again the next time they cross it.
+type Quota struct {
+	store  *store.Store
+	mailer sender
+	log    *logging.Logger
+	bytes  int64
+}
+
+// NewQuota returns the quota warner for cfg. It warns nobody when no quota
+// is configured, and only logs warnings when email is not.
+func NewQuota(cfg *config.Config, st *store.Store, sink logging.Sink) *Quota {
+	q := &Quota{store: st, log: logging.New(sink, "notify")}
+	if cfg != nil {
+		q.bytes = cfg.Quota.UserBytes
+	}
+	if mailer := mail.New(cfg); mailer != nil {
+		q.mailer = mailer
+	}
+	return q
+}
+
+// Start checks storage use every quota interval until ctx is cancelled.
+func (q *Quota) Start(ctx context.Context) {
+	if q.bytes <= 0 {
+		return
+	}
+	ticker := time.NewTicker(quotaInterval)
+	defer ticker.Stop()
+	for {
+		if err := q.Check(ctx); err != nil {
+			q.log.Error("Start", "failed to check storage quotas: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check warns the users whose quota level rose since they were last warned,
+// and records the level of those whose fell.
+func (q *Quota) Check(ctx context.Context) error {
+	usage, err := q.store.Quota.ListUsage(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		level := quota.LevelFor(u.UsedBytes, q.bytes)
+		recorded := level
+		if level == quota.LevelOK {
+			recorded = ""
+		}
+		if recorded == u.Warned {
+			continue
+		}
+		set, err := q.store.Quota.SetWarned(ctx, u.UserID, u.Warned, recorded)
+		if err != nil {
+			return err
+		}
+		if !set || quota.Rank(level) <= quota.Rank(u.Warned) {
+			continue
+		}
+		status := quota.Status{UsedBytes: u.UsedBytes, QuotaBytes: q.bytes, Level: level}
+		q.log.Warn("Check", "user %d has used %d%% of their storage quota", u.UserID, status.Percent())
+		if err := q.warn(ctx, u.UserID, status); err != nil {
+			q.log.Error("Check", "failed to email quota warning to user %d: %v", u.UserID, err)
+		}
+	}
+	return nil
+}
+
+// warn emails a user their quota status.
+func (q *Quota) warn(ctx context.Context, userID int64, status quota.Status) error {
+	if q.mailer == nil {
+		return nil
+	}
+	user, err := q.store.Users.GetByID(ctx, userID)
+	if err != nil || user == nil || user.PrimaryEmail == "" {
+		return err
+	}
+	prefs := locale.ForUser(user)
+	return q.mailer.Send(mail.Message{
+		To:      []string{user.PrimaryEmail},
+		Subject: prefs.T("quota.subject", status.Percent()),
+		Text:    prefs.T("quota.text", formatMB(status.UsedBytes), formatMB(status.QuotaBytes), status.Percent()),
+	})
+}
+
+// formatMB renders a size in whole megabytes, as the quota is configured.
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%d MB", (bytes+1<<19)>>20)
+}
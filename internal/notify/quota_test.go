@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestQuotaCheckWarnsOncePerLevelCrossed(t *testing.T) {
+	usage := &storetest.Quota{Used: map[int64]int64{1: 700 << 20, 2: 100 << 20}}
+	sender := &fakeSender{}
+	q := &Quota{
+		store:  &store.Store{Quota: usage, Users: &fakeUsers{}},
+		mailer: sender,
+		log:    logging.New(nil, "notify"),
+		bytes:  1000 << 20,
+	}
+	ctx := context.Background()
+	check := func() {
+		t.Helper()
+		if err := q.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check()
+	if len(sender.sent) != 0 {
+		t.Fatalf("sent %d warnings below 80%%", len(sender.sent))
+	}
+
+	usage.Used[1] = 850 << 20
+	check()
+	check()
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "ann@example.com" || sender.sent[0].Subject != "Your CalCard storage is 85% full" {
+		t.Fatalf("warnings at 85%% = %+v", sender.sent)
+	}
+	if !strings.Contains(sender.sent[0].Text, "850 MB of your 1000 MB") {
+		t.Fatalf("warning text = %q", sender.sent[0].Text)
+	}
+
+	usage.Used[1] = 960 << 20
+	check()
+	if len(sender.sent) != 2 || usage.Warned[1] != "critical" {
+		t.Fatalf("after 95%%: sent %d, recorded %q", len(sender.sent), usage.Warned[1])
+	}
+
+	// Making room and filling up again warns again.
+	usage.Used[1] = 500 << 20
+	check()
+	if _, ok := usage.Warned[1]; ok {
+		t.Fatalf("warning still recorded below 80%%: %q", usage.Warned[1])
+	}
+	usage.Used[1] = 820 << 20
+	check()
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent %d warnings, want a third after filling up again", len(sender.sent))
+	}
+}
@@ -0,0 +1,416 @@
+// Package preflight checks, before the server starts, that the services its
+// configuration points at can be used: the database and its permissions,
+// pending migrations, the identity provider, SMTP and the clocks. Each
+// problem is reported with what to change, instead of surfacing later as a
+// failed request.
+package preflight
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/lib/pq"
+)
+
+const (
+	// checkTimeout bounds each network check.
+	checkTimeout = 5 * time.Second
+	// maxClockSkew is the clock difference worth a warning. ID tokens and
+	// sync tokens tolerate less than a few minutes.
+	maxClockSkew = 30 * time.Second
+)
+
+// Finding is one problem found by Run.
+type Finding struct {
+	Check string
+	// Problem says what is wrong and Fix what to change.
+	Problem string
+	Fix     string
+	// Warning marks a problem the server can start with.
+	Warning bool
+}
+
+func (f Finding) String() string {
+	if f.Fix == "" {
+		return f.Check + ": " + f.Problem
+	}
+	return f.Check + ": " + f.Problem + ". " + f.Fix
+}
+
+// Fatal returns the findings that should stop the server starting.
+func Fatal(findings []Finding) []Finding {
+	var fatal []Finding
+	for _, f := range findings {
+		if !f.Warning {
+			fatal = append(fatal, f)
+		}
+	}
+	return fatal
+}
+
+// Options are the parts of startup Run cannot read from the configuration.
+type Options struct {
+	// MigrationsPath and AppVersion locate the migrations the server is
+	// about to apply. Pending migrations are run in a transaction that is
+	// rolled back. An AppVersion that is not a release version skips this.
+	MigrationsPath string
+	AppVersion     string
+}
+
+// Run performs every check that applies to cfg and returns the problems
+// found, fatal ones first.
+func Run(ctx context.Context, cfg *config.Config, opts Options) []Finding {
+	var findings []Finding
+	add := func(f ...Finding) { findings = append(findings, f...) }
+
+	db, err := sql.Open("postgres", cfg.DB.DSN)
+	if err == nil {
+		defer db.Close()
+		err = ping(ctx, db)
+	}
+	if err != nil {
+		add(databaseFinding(err))
+	} else {
+		add(checkDatabase(ctx, db, cfg.DB.Schema, opts)...)
+	}
+	add(checkIdentityProvider(ctx, cfg)...)
+	add(checkBaseURL(ctx, cfg)...)
+	if mailer := mail.New(cfg); mailer != nil {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := mailer.Check(checkCtx)
+		cancel()
+		if err != nil {
+			add(Finding{
+				Check:   "smtp",
+				Problem: fmt.Sprintf("cannot sign in to %s:%d: %v", cfg.SMTP.Host, cfg.SMTP.Port, err),
+				Fix:     "Check APP_SMTP_HOST, APP_SMTP_PORT, APP_SMTP_USERNAME and APP_SMTP_PASSWORD; until then invitations and notifications are not delivered",
+				Warning: true,
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return !findings[i].Warning && findings[j].Warning })
+	return findings
+}
+
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// databaseFinding explains why the database could not be reached.
+func databaseFinding(err error) Finding {
+	f := Finding{Check: "database", Problem: fmt.Sprintf("cannot connect: %v", err)}
+	var pqErr *pq.Error
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &pqErr) && pqErr.Code == "28P01":
+		f.Fix = "The password was refused; check APP_DB_USER and APP_DB_PASSWORD, or the credentials in APP_DB_DSN"
+	case errors.As(err, &pqErr) && pqErr.Code == "28000":
+		f.Fix = "PostgreSQL does not allow this user from this host; add a matching line to pg_hba.conf or connect as another user"
+	case errors.As(err, &pqErr) && pqErr.Code == "3D000":
+		f.Fix = "The database does not exist; create it with createdb or fix APP_DB_NAME"
+	case errors.Is(err, pq.ErrSSLNotSupported):
+		f.Fix = "The server does not offer TLS; set APP_DB_SSLMODE=disable or enable ssl in postgresql.conf"
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		f.Fix = "No answer within " + checkTimeout.String() + "; check APP_DB_HOST and APP_DB_PORT and that a firewall does not drop the connection"
+	case errors.As(err, &opErr):
+		f.Fix = "Check that PostgreSQL is running and listening at APP_DB_HOST and APP_DB_PORT"
+	default:
+		f.Fix = "Check APP_DB_DSN or the APP_DB_* settings"
+	}
+	return f
+}
+
+func checkDatabase(ctx context.Context, db *sql.DB, schema string, opts Options) []Finding {
+	var findings []Finding
+	var dbNow time.Time
+	if err := db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&dbNow); err == nil {
+		if skew := time.Since(dbNow); skew > maxClockSkew || skew < -maxClockSkew {
+			findings = append(findings, Finding{
+				Check:   "clock",
+				Problem: fmt.Sprintf("this host's clock is %s off the database's", skew.Round(time.Second)),
+				Fix:     "Run NTP on both hosts; sign-in tokens, sessions and sync timestamps compare the two",
+				Warning: true,
+			})
+		}
+	}
+
+	if schema == "" {
+		schema = "public"
+	}
+	var namespaceExists, schemaExists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1), to_regclass($1 || '.users') IS NOT NULL`, schema).Scan(&namespaceExists, &schemaExists); err != nil {
+		return append(findings, Finding{Check: "database", Problem: fmt.Sprintf("cannot read the catalog: %v", err), Fix: "Check that the user may connect to the database"})
+	}
+	if !namespaceExists {
+		// The server creates APP_DB_SCHEMA at startup when it may.
+		var canCreate bool
+		if err := db.QueryRowContext(ctx, `SELECT has_database_privilege(current_database(), 'CREATE')`).Scan(&canCreate); err == nil && !canCreate {
+			findings = append(findings, Finding{
+				Check:   "database",
+				Problem: fmt.Sprintf("schema %s does not exist and this user may not create it", schema),
+				Fix:     fmt.Sprintf("Run CREATE SCHEMA %s AUTHORIZATION the CalCard user, or set APP_DB_SCHEMA to an existing schema", schema),
+			})
+		}
+		return findings
+	}
+	if !schemaExists {
+		var canCreate bool
+		if err := db.QueryRowContext(ctx, `SELECT has_schema_privilege($1, 'CREATE')`, schema).Scan(&canCreate); err == nil && !canCreate {
+			findings = append(findings, Finding{
+				Check:   "database",
+				Problem: fmt.Sprintf("the database is empty and this user may not create tables in schema %s", schema),
+				Fix:     fmt.Sprintf("Run GRANT CREATE ON SCHEMA %s TO the CalCard user, or make it the database owner", schema),
+			})
+		}
+		return findings
+	}
+
+	var missing []string
+	rows, err := db.QueryContext(ctx, `
+SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1 AND c.relkind = 'r'
+  AND NOT (has_table_privilege(c.oid, 'SELECT') AND has_table_privilege(c.oid, 'INSERT')
+       AND has_table_privilege(c.oid, 'UPDATE') AND has_table_privilege(c.oid, 'DELETE'))
+ORDER BY c.relname`, schema)
+	if err == nil {
+		for rows.Next() {
+			var name string
+			if rows.Scan(&name) == nil {
+				missing = append(missing, name)
+			}
+		}
+		rows.Close()
+	}
+	if len(missing) > 0 {
+		findings = append(findings, Finding{
+			Check:   "database",
+			Problem: "this user cannot read and write tables " + strings.Join(missing, ", "),
+			Fix:     fmt.Sprintf("Run GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %s TO the CalCard user", schema),
+		})
+	}
+
+	return append(findings, checkMigrations(ctx, db, schema, opts)...)
+}
+
+// migrationFile matches the migration names the migration runner accepts.
+var migrationFile = regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)(?:-[0-9A-Za-z.-]+)?\.sql$`)
+
+// explicitTransaction matches migrations that manage their own transaction
+// and so cannot be tried inside one.
+var explicitTransaction = regexp.MustCompile(`(?im)^\s*(BEGIN|COMMIT|ROLLBACK|START\s+TRANSACTION)\b`)
+
+// checkMigrations tries the migrations startup is about to apply inside a
+// transaction it rolls back, so one that would fail stops the server before
+// any of them is applied.
+func checkMigrations(ctx context.Context, db *sql.DB, schema string, opts Options) []Finding {
+	target, ok := parseVersion(opts.AppVersion)
+	if !ok || opts.MigrationsPath == "" {
+		return nil
+	}
+	var current string
+	if err := db.QueryRowContext(ctx, `SELECT value FROM application WHERE key = 'version'`).Scan(&current); err != nil {
+		return nil
+	}
+	from, ok := parseVersion(current)
+	if !ok {
+		return []Finding{{Check: "migrations", Problem: fmt.Sprintf("the database records version %q, which is not a version", current), Fix: "Restore the application table's version row from a backup"}}
+	}
+	if compareVersions(from, target) > 0 {
+		return []Finding{{
+			Check:   "migrations",
+			Problem: fmt.Sprintf("the database was upgraded to %s but this server is %s", current, opts.AppVersion),
+			Fix:     "Run the newer release; older releases may not understand the newer schema",
+			Warning: true,
+		}}
+	}
+
+	entries, err := os.ReadDir(opts.MigrationsPath)
+	if err != nil {
+		return []Finding{{Check: "migrations", Problem: fmt.Sprintf("cannot read %s: %v", opts.MigrationsPath, err), Fix: "Run the server from its install directory, which holds the migrations directory"}}
+	}
+	type pending struct {
+		name    string
+		version [3]int
+	}
+	var todo []pending
+	for _, entry := range entries {
+		v, ok := parseVersion(strings.TrimSuffix(entry.Name(), ".sql"))
+		if !migrationFile.MatchString(entry.Name()) || !ok {
+			continue
+		}
+		if compareVersions(v, from) > 0 && compareVersions(v, target) <= 0 {
+			todo = append(todo, pending{entry.Name(), v})
+		}
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+	sort.Slice(todo, func(i, j int) bool { return compareVersions(todo[i].version, todo[j].version) < 0 })
+
+	var notOwned []string
+	if rows, err := db.QueryContext(ctx, `
+SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1 AND c.relkind = 'r' AND NOT pg_has_role(c.relowner, 'USAGE')
+ORDER BY c.relname`, schema); err == nil {
+		for rows.Next() {
+			var name string
+			if rows.Scan(&name) == nil {
+				notOwned = append(notOwned, name)
+			}
+		}
+		rows.Close()
+	}
+	if len(notOwned) > 0 {
+		return []Finding{{
+			Check:   "migrations",
+			Problem: fmt.Sprintf("%d migrations are pending but this user does not own tables %s", len(todo), strings.Join(notOwned, ", ")),
+			Fix:     "Start this release once as the user that owns the tables, or run ALTER TABLE ... OWNER TO the CalCard user",
+		}}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return []Finding{{Check: "migrations", Problem: fmt.Sprintf("cannot start a trial transaction: %v", err)}}
+	}
+	defer tx.Rollback()
+	for _, m := range todo {
+		body, err := os.ReadFile(filepath.Join(opts.MigrationsPath, m.name))
+		if err != nil {
+			return []Finding{{Check: "migrations", Problem: fmt.Sprintf("cannot read %s: %v", m.name, err), Fix: "Check the migrations directory's permissions"}}
+		}
+		if explicitTransaction.Match(body) {
+			// Later migrations may depend on this one; stop trying here.
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			return []Finding{{
+				Check:   "migrations",
+				Problem: fmt.Sprintf("migration %s fails against this database: %v", m.name, err),
+				Fix:     "Nothing was changed. Fix the data the error names, or restore a backup, before starting this release",
+			}}
+		}
+	}
+	return nil
+}
+
+// parseVersion reads a version such as v1.1.33 or 1.2.0-rc1, ignoring any
+// prerelease suffix.
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkIdentityProvider fetches the OpenID configuration startup needs,
+// and compares the provider's clock with this one.
+func checkIdentityProvider(ctx context.Context, cfg *config.Config) []Finding {
+	source := cfg.OAuth.DiscoveryURL
+	setting := "APP_OAUTH_DISCOVERY_URL"
+	if source == "" {
+		source, setting = cfg.OAuth.IssuerURL, "APP_OAUTH_ISSUER_URL"
+	}
+	const wellKnown = "/.well-known/openid-configuration"
+	target := strings.TrimRight(source, "/")
+	if !strings.HasSuffix(target, wellKnown) {
+		target += wellKnown
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return []Finding{{Check: "identity provider", Problem: fmt.Sprintf("%s is not a URL: %v", setting, err), Fix: "Set it to the provider's issuer URL, such as https://accounts.example.com"}}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return []Finding{{
+			Check:   "identity provider",
+			Problem: fmt.Sprintf("cannot fetch %s: %v", target, err),
+			Fix:     fmt.Sprintf("Check %s and that this host can reach it, including any outbound proxy (HTTPS_PROXY)", setting),
+		}}
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []Finding{{
+			Check:   "identity provider",
+			Problem: fmt.Sprintf("%s answered %s", target, resp.Status),
+			Fix:     fmt.Sprintf("Check %s; it should be the issuer URL, under which the provider serves %s", setting, wellKnown),
+		}}
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		if skew := time.Since(date); skew > maxClockSkew+time.Second || skew < -maxClockSkew-time.Second {
+			return []Finding{{
+				Check:   "clock",
+				Problem: fmt.Sprintf("this host's clock is %s off the identity provider's", skew.Round(time.Second)),
+				Fix:     "Run NTP on this host; ID tokens are rejected when the clocks disagree by more than a minute or so",
+				Warning: true,
+			}}
+		}
+	}
+	return nil
+}
+
+// checkBaseURL makes sure the public URL's host resolves. Only this host's
+// view is checked: split DNS may still send users elsewhere.
+func checkBaseURL(ctx context.Context, cfg *config.Config) []Finding {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return []Finding{{Check: "base URL", Problem: fmt.Sprintf("APP_BASE_URL %q is not an http or https URL", cfg.BaseURL), Fix: "Set it to the address users open, such as https://calendar.example.com"}}
+	}
+	if net.ParseIP(u.Hostname()) != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		return []Finding{{
+			Check:   "base URL",
+			Problem: fmt.Sprintf("%s does not resolve: %v", u.Hostname(), err),
+			Fix:     "Check APP_BASE_URL; sign-in redirects and links in email use it",
+			Warning: true,
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/lib/pq"
+)
+
+func TestDatabaseFindingNamesTheSettingToFix(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&pq.Error{Code: "28P01"}, "APP_DB_PASSWORD"},
+		{&pq.Error{Code: "3D000"}, "createdb"},
+		{pq.ErrSSLNotSupported, "APP_DB_SSLMODE=disable"},
+		{context.DeadlineExceeded, "firewall"},
+		{errors.New("something else"), "APP_DB_DSN"},
+	}
+	for _, tt := range tests {
+		if f := databaseFinding(tt.err); f.Warning || !strings.Contains(f.Fix, tt.want) {
+			t.Errorf("databaseFinding(%v) = %+v, want a fatal finding mentioning %q", tt.err, f, tt.want)
+		}
+	}
+}
+
+func TestCheckIdentityProvider(t *testing.T) {
+	skewed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Date", time.Now().Add(-5*time.Minute).UTC().Format(http.TimeFormat))
+		w.Write([]byte(`{}`))
+	}))
+	defer skewed.Close()
+
+	cfg := &config.Config{}
+	cfg.OAuth.IssuerURL = skewed.URL
+	findings := checkIdentityProvider(context.Background(), cfg)
+	if len(findings) != 1 || findings[0].Check != "clock" || !findings[0].Warning {
+		t.Fatalf("checkIdentityProvider() with a skewed clock = %+v", findings)
+	}
+
+	cfg.OAuth.DiscoveryURL = skewed.URL + "/realms/calcard"
+	findings = checkIdentityProvider(context.Background(), cfg)
+	if len(findings) != 1 || findings[0].Warning || !strings.Contains(findings[0].Problem, "404") || !strings.Contains(findings[0].Fix, "APP_OAUTH_DISCOVERY_URL") {
+		t.Fatalf("checkIdentityProvider() with a wrong URL = %+v", findings)
+	}
+}
+
+func TestCheckBaseURLRejectsRelativeURL(t *testing.T) {
+	cfg := &config.Config{BaseURL: "calendar.example.com"}
+	if findings := checkBaseURL(context.Background(), cfg); len(findings) != 1 || findings[0].Warning {
+		t.Fatalf("checkBaseURL() = %+v", findings)
+	}
+	cfg.BaseURL = "http://127.0.0.1:8080"
+	if findings := checkBaseURL(context.Background(), cfg); len(findings) != 0 {
+		t.Fatalf("checkBaseURL() of an IP address = %+v", findings)
+	}
+}
+
+func TestCheckMigrationsTriesPendingMigrationsAndRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"v1.1.1.sql": "ALTER TABLE users ADD COLUMN applied TEXT;",
+		"v1.1.2.sql": "ALTER TABLE users ADD COLUMN nickname TEXT;",
+		"v1.1.3.sql": "ALTER TABLE users ADD COLUMN broken;",
+		"v1.2.0.sql": "ALTER TABLE users ADD COLUMN future TEXT;",
+		"notes.txt":  "not a migration",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM application WHERE key = 'version'`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("v1.1.1"))
+	mock.ExpectQuery(`NOT pg_has_role`).WillReturnRows(sqlmock.NewRows([]string{"relname"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN nickname TEXT;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN broken;")).WillReturnError(errors.New(`syntax error at or near ";"`))
+	mock.ExpectRollback()
+
+	findings := checkMigrations(context.Background(), db, "public", Options{MigrationsPath: dir, AppVersion: "v1.1.3"})
+	if len(findings) != 1 || findings[0].Warning || !strings.Contains(findings[0].Problem, "v1.1.3.sql") {
+		t.Fatalf("checkMigrations() = %+v", findings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckMigrationsWarnsAboutDowngradeAndSkipsDevBuilds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM application WHERE key = 'version'`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("v1.2.0"))
+
+	findings := checkMigrations(context.Background(), db, "public", Options{MigrationsPath: t.TempDir(), AppVersion: "v1.1.9"})
+	if len(findings) != 1 || !findings[0].Warning {
+		t.Fatalf("checkMigrations() after a downgrade = %+v", findings)
+	}
+	if findings := checkMigrations(context.Background(), db, "public", Options{MigrationsPath: t.TempDir(), AppVersion: "(devel)"}); findings != nil {
+		t.Fatalf("checkMigrations() for a dev build = %+v", findings)
+	}
+}
+
+func TestCheckDatabaseLooksInTheConfiguredSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT NOW()`)).
+		WillReturnRows(sqlmock.NewRows([]string{"now"}).AddRow(time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM pg_namespace WHERE nspname = $1`)).WithArgs("calcard").
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "users"}).AddRow(false, false))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT has_database_privilege(current_database(), 'CREATE')`)).
+		WillReturnRows(sqlmock.NewRows([]string{"create"}).AddRow(false))
+
+	findings := checkDatabase(context.Background(), db, "calcard", Options{})
+	if len(findings) != 1 || findings[0].Warning || !strings.Contains(findings[0].Fix, "CREATE SCHEMA calcard") {
+		t.Fatalf("checkDatabase() = %+v", findings)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
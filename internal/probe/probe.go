@@ -0,0 +1,304 @@
+// Package probe runs the synthetic monitoring probe. Each run PUTs an
+// event, finds it again with a calendar-query REPORT and DELETEs it, through
+// the same DAV handlers clients use, as an internal account on a calendar
+// no one else can see. Events a failed run leaves behind are deleted at the
+// start of the next. Uptime monitors then check the path devices sync
+// through, not only whether the server answers.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	// Email and Subject identify the probe account. The .invalid domain
+	// cannot receive mail, and no identity provider issues the subject, so
+	// no one can sign in as it.
+	Email   = "probe@calcard.invalid"
+	Subject = "calcard:probe"
+	// CalendarName is the probe account's calendar.
+	CalendarName = "Synthetic probe"
+	// timeout bounds a whole run.
+	timeout = 10 * time.Second
+)
+
+// Steps of a run, in order.
+const (
+	StepPut    = "put"
+	StepReport = "report"
+	StepDelete = "delete"
+)
+
+// DAV is the part of the DAV server a run goes through.
+type DAV interface {
+	Put(http.ResponseWriter, *http.Request)
+	Report(http.ResponseWriter, *http.Request)
+	Delete(http.ResponseWriter, *http.Request)
+}
+
+// Step is the outcome of one request of a run.
+type Step struct {
+	Name     string
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+// Result is the outcome of a run. OK is set when every step succeeded.
+type Result struct {
+	OK        bool
+	StartedAt time.Time
+	Duration  time.Duration
+	Steps     []Step
+	// Err is set when the run could not start, such as when the probe
+	// account cannot be loaded.
+	Err error
+}
+
+// Prober runs the probe.
+type Prober struct {
+	store *store.Store
+	dav   DAV
+	now   func() time.Time
+
+	mu sync.Mutex
+	// user and calendarID are the probe account and calendar, once found.
+	user       *store.User
+	calendarID int64
+}
+
+// New returns a prober going through dav.
+func New(st *store.Store, dav DAV) *Prober {
+	return &Prober{store: st, dav: dav, now: time.Now}
+}
+
+// Run makes one probe run and records it in the metrics.
+func (p *Prober) Run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res := Result{StartedAt: p.now()}
+	defer func() {
+		res.Duration = p.now().Sub(res.StartedAt)
+		metrics.IncProbeRuns(res.OK)
+	}()
+
+	user, calendarID, err := p.account(ctx)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if err := p.cleanUp(ctx, calendarID); err != nil {
+		res.Err = err
+		return res
+	}
+	uid, err := newUID()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	collection := fmt.Sprintf("/dav/calendars/%d/", calendarID)
+	href := collection + uid + ".ics"
+	start := res.StartedAt.UTC().Truncate(time.Minute)
+
+	put := p.step(ctx, user, StepPut, p.dav.Put, http.MethodPut, href, eventICS(uid, start), http.StatusCreated)
+	res.Steps = append(res.Steps, put)
+	if put.Err != nil {
+		p.forget()
+		return res
+	}
+	report := p.step(ctx, user, StepReport, p.dav.Report, "REPORT", collection, calendarQuery(start), http.StatusMultiStatus, href)
+	del := p.step(ctx, user, StepDelete, p.dav.Delete, http.MethodDelete, href, "", http.StatusNoContent)
+	res.Steps = append(res.Steps, report, del)
+	res.OK = report.Err == nil && del.Err == nil
+	return res
+}
+
+// step makes one request and checks that it is answered with want, and
+// that the body contains each of contains.
+func (p *Prober) step(ctx context.Context, user *store.User, name string, handle http.HandlerFunc, method, target, body string, want int, contains ...string) Step {
+	s := Step{Name: name}
+	req, err := http.NewRequestWithContext(auth.WithUser(ctx, user), method, target, strings.NewReader(body))
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	switch method {
+	case http.MethodPut:
+		req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+		req.Header.Set("If-None-Match", "*")
+	case "REPORT":
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		req.Header.Set("Depth", "1")
+	}
+	rec := &recorder{header: http.Header{}}
+	began := p.now()
+	handle(rec, req)
+	s.Duration = p.now().Sub(began)
+	s.Status = rec.status
+	switch {
+	case rec.status != want:
+		s.Err = fmt.Errorf("%s %s answered %d, want %d", method, target, rec.status, want)
+	case ctx.Err() != nil:
+		s.Err = ctx.Err()
+	}
+	for _, needle := range contains {
+		if s.Err == nil && !bytes.Contains(rec.body.Bytes(), []byte(needle)) {
+			s.Err = fmt.Errorf("%s %s did not return %s", method, target, needle)
+		}
+	}
+	metrics.ObserveProbeStep(name, s.Err == nil, s.Duration)
+	return s
+}
+
+// account returns the probe account and its calendar, creating them on
+// first use.
+func (p *Prober) account(ctx context.Context) (*store.User, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.user != nil {
+		return p.user, p.calendarID, nil
+	}
+	user, err := p.store.Users.GetByEmail(ctx, Email)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load probe account: %w", err)
+	}
+	if user == nil {
+		if user, err = p.store.Users.UpsertOAuthUser(ctx, Subject, Email); err != nil {
+			return nil, 0, fmt.Errorf("create probe account: %w", err)
+		}
+	}
+	if user.OAuthSubject != Subject {
+		return nil, 0, errors.New("probe account email belongs to another account")
+	}
+	if !user.Internal {
+		if err := p.store.Users.MarkInternal(ctx, user.ID); err != nil {
+			return nil, 0, fmt.Errorf("mark probe account internal: %w", err)
+		}
+		user.Internal = true
+	}
+	calendars, err := p.store.Calendars.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("load probe calendar: %w", err)
+	}
+	var calendarID int64
+	for _, cal := range calendars {
+		if cal.UserID == user.ID && cal.Name == CalendarName && (calendarID == 0 || cal.ID < calendarID) {
+			calendarID = cal.ID
+		}
+	}
+	if calendarID == 0 {
+		description := "Used by the synthetic monitoring probe; its events are deleted as soon as they are created."
+		cal, err := p.store.Calendars.Create(ctx, store.Calendar{UserID: user.ID, Name: CalendarName, Description: &description})
+		if err != nil {
+			return nil, 0, fmt.Errorf("create probe calendar: %w", err)
+		}
+		calendarID = cal.ID
+	}
+	p.user, p.calendarID = user, calendarID
+	return user, calendarID, nil
+}
+
+// cleanUp deletes events an earlier run left behind on the probe calendar,
+// such as when its DELETE step failed.
+func (p *Prober) cleanUp(ctx context.Context, calendarID int64) error {
+	events, err := p.store.Events.ListForCalendar(ctx, calendarID)
+	if err != nil {
+		return fmt.Errorf("load earlier probe events: %w", err)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	uids := make([]string, 0, len(events))
+	for _, e := range events {
+		uids = append(uids, e.UID)
+	}
+	if _, err := p.store.Events.DeleteByUIDs(ctx, calendarID, uids); err != nil {
+		return fmt.Errorf("delete earlier probe events: %w", err)
+	}
+	return nil
+}
+
+// forget drops the probe account and calendar found earlier, so that the
+// next run looks them up again in case they were removed.
+func (p *Prober) forget() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user, p.calendarID = nil, 0
+}
+
+func newUID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "calcard-probe-" + hex.EncodeToString(b), nil
+}
+
+// eventICS is the event a run creates: one minute long, from start.
+func eventICS(uid string, start time.Time) string {
+	const layout = "20060102T150405Z"
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//CalCard//Synthetic probe//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:" + start.Format(layout),
+		"DTSTART:" + start.Format(layout),
+		"DTEND:" + start.Add(time.Minute).Format(layout),
+		"SUMMARY:Synthetic probe",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+// calendarQuery finds the events overlapping the minute from start.
+func calendarQuery(start time.Time) string {
+	const layout = "20060102T150405Z"
+	return `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="` + start.Format(layout) + `" end="` + start.Add(time.Minute).Format(layout) + `"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+}
+
+// recorder keeps the status and body a DAV handler writes.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
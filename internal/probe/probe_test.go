@@ -0,0 +1,99 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/dav"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestRunCreatesFindsAndDeletesAnEvent(t *testing.T) {
+	f := storetest.New()
+	users := storetest.NewUsers(store.User{ID: 1, PrimaryEmail: "ann@example.com"})
+	f.Store.Users = users
+	p := New(f.Store, dav.NewServer(dav.Options{Store: f.Store}))
+
+	for run := 0; run < 2; run++ {
+		res := p.Run(context.Background())
+		if res.Err != nil || !res.OK || len(res.Steps) != 3 {
+			t.Fatalf("run %d = %+v", run, res)
+		}
+		for i, want := range []string{StepPut, StepReport, StepDelete} {
+			if res.Steps[i].Name != want || res.Steps[i].Err != nil {
+				t.Fatalf("run %d step %d = %+v, want %s", run, i, res.Steps[i], want)
+			}
+		}
+	}
+	if probe := users.Users[2]; len(users.Users) != 2 || probe.OAuthSubject != Subject || !probe.Internal {
+		t.Fatalf("users = %+v, want one internal probe account", users.Users)
+	}
+	if active, _ := users.ListActive(context.Background()); len(active) != 0 {
+		t.Fatalf("ListActive() = %+v, want the probe account left out", active)
+	}
+	calendars, _ := f.Calendars.ListByUser(context.Background(), 2)
+	if len(calendars) != 1 || calendars[0].Name != CalendarName {
+		t.Fatalf("probe calendars = %+v", calendars)
+	}
+	if len(f.Events.Events) != 0 {
+		t.Fatalf("probe left events behind: %v", f.Events.Events)
+	}
+}
+
+func TestRunFailsWhenTheEventIsNotFound(t *testing.T) {
+	f := storetest.New()
+	f.Store.Users = storetest.NewUsers()
+	server := dav.NewServer(dav.Options{Store: f.Store})
+	p := New(f.Store, forgetfulReport{server})
+
+	res := p.Run(context.Background())
+	if res.OK || len(res.Steps) != 3 || res.Steps[1].Err == nil || res.Steps[2].Err != nil {
+		t.Fatalf("Run() = %+v", res)
+	}
+	if len(f.Events.Events) != 0 {
+		t.Fatalf("probe left events behind after a failed REPORT: %v", f.Events.Events)
+	}
+}
+
+func TestRunRefusesAnAccountItDidNotCreate(t *testing.T) {
+	f := storetest.New()
+	f.Store.Users = storetest.NewUsers(store.User{ID: 1, OAuthSubject: "someone", PrimaryEmail: Email})
+	p := New(f.Store, dav.NewServer(dav.Options{Store: f.Store}))
+	if res := p.Run(context.Background()); res.OK || res.Err == nil || len(res.Steps) != 0 {
+		t.Fatalf("Run() = %+v", res)
+	}
+}
+
+func TestRunDeletesEventsAnEarlierRunLeftBehind(t *testing.T) {
+	f := storetest.New()
+	f.Store.Users = storetest.NewUsers()
+	p := New(f.Store, failingDelete{dav.NewServer(dav.Options{Store: f.Store})})
+	if res := p.Run(context.Background()); res.OK || len(f.Events.Events) != 1 {
+		t.Fatalf("Run() = %+v with %d events, want a failed DELETE leaving one behind", res, len(f.Events.Events))
+	}
+
+	p.dav = dav.NewServer(dav.Options{Store: f.Store})
+	if res := p.Run(context.Background()); !res.OK {
+		t.Fatalf("Run() = %+v", res)
+	}
+	if len(f.Events.Events) != 0 {
+		t.Fatalf("probe left events behind: %v", f.Events.Events)
+	}
+}
+
+// forgetfulReport answers every REPORT with an empty multistatus.
+type forgetfulReport struct{ *dav.Server }
+
+func (forgetfulReport) Report(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = w.Write([]byte(`<d:multistatus xmlns:d="DAV:"/>`))
+}
+
+// failingDelete answers every DELETE with a server error.
+type failingDelete struct{ *dav.Server }
+
+func (failingDelete) Delete(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+}
@@ -0,0 +1,108 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// recordSize is the aes128gcm record size. Push messages are far smaller,
+// so each is a single record.
+const recordSize = 4096
+
+// ErrInvalidSubscription reports a client key or auth secret that messages
+// cannot be encrypted for.
+var ErrInvalidSubscription = errors.New("invalid push subscription keys")
+
+// ValidateKeys checks a client's base64url p256dh public key and auth
+// secret.
+func ValidateKeys(publicKey, authSecret string) error {
+	public, err := b64.DecodeString(publicKey)
+	if err != nil {
+		return ErrInvalidSubscription
+	}
+	if _, err := ecdh.P256().NewPublicKey(public); err != nil {
+		return ErrInvalidSubscription
+	}
+	if auth, err := b64.DecodeString(authSecret); err != nil || len(auth) != 16 {
+		return ErrInvalidSubscription
+	}
+	return nil
+}
+
+// encrypt encrypts plaintext for the client's p256dh public key and auth
+// secret as RFC 8291 describes.
+func encrypt(plaintext []byte, publicKey, authSecret string) ([]byte, error) {
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWith(plaintext, publicKey, authSecret, ephemeral, salt)
+}
+
+// encryptWith is encrypt with the server's ephemeral key and the salt
+// chosen by the caller.
+func encryptWith(plaintext []byte, publicKey, authSecret string, ephemeral *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaRaw, err := b64.DecodeString(publicKey)
+	if err != nil {
+		return nil, ErrInvalidSubscription
+	}
+	ua, err := ecdh.P256().NewPublicKey(uaRaw)
+	if err != nil {
+		return nil, ErrInvalidSubscription
+	}
+	auth, err := b64.DecodeString(authSecret)
+	if err != nil || len(auth) != 16 {
+		return nil, ErrInvalidSubscription
+	}
+	shared, err := ephemeral.ECDH(ua)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := ephemeral.PublicKey().Bytes()
+
+	keyInfo := "WebPush: info\x00" + string(uaRaw) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, shared, auth, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is salt, record size and the server's public key as key ID
+	// (RFC 8188); 0x02 marks the last and only record.
+	out := make([]byte, 0, len(salt)+5+len(asPublic)+len(plaintext)+1+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(out, nonce, record, nil), nil
+}
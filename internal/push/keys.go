@@ -0,0 +1,112 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// b64 is the unpadded base64url encoding Web Push uses for keys.
+var b64 = base64.RawURLEncoding
+
+// vapidLifetime is how long a VAPID token is valid; RFC 8292 allows at
+// most 24 hours.
+const vapidLifetime = 12 * time.Hour
+
+// Keys is the server's VAPID key pair (RFC 8292). Push services only
+// accept messages for a subscription signed with the key the client
+// subscribed with, so every replica shares the one stored in the database.
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  string
+	raw     []byte
+}
+
+// LoadKeys returns the stored VAPID keys, generating and storing them the
+// first time.
+func LoadKeys(ctx context.Context, repo store.PushSubscriptionRepository) (*Keys, error) {
+	candidate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := candidate.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := repo.VAPIDKey(ctx, b64.EncodeToString(raw))
+	if err != nil {
+		return nil, err
+	}
+	return parseKeys(stored)
+}
+
+func parseKeys(encoded string) (*Keys, error) {
+	raw, err := b64.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode VAPID key: %w", err)
+	}
+	private, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse VAPID key: %w", err)
+	}
+	public, err := private.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{private: private, public: b64.EncodeToString(public), raw: raw}, nil
+}
+
+// PublicKey returns the uncompressed public key, base64url-encoded, that
+// clients subscribe with.
+func (k *Keys) PublicKey() string {
+	return k.public
+}
+
+// Topic names a collection in push messages. It is derived from the
+// private key so that push services cannot tell which collection, or whose,
+// a message is about.
+func (k *Keys) Topic(collectionType string, collectionID int64) string {
+	mac := hmac.New(sha256.New, k.raw)
+	mac.Write([]byte(collectionType + "/" + strconv.FormatInt(collectionID, 10)))
+	// 16 bytes encode to 22 characters, within the 32 RFC 8030 allows in
+	// a Topic header.
+	return b64.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// authorization returns the VAPID Authorization header for a message to
+// endpoint. subject, when set, tells the push service whom to contact.
+func (k *Keys) authorization(endpoint *url.URL, subject string, now time.Time) (string, error) {
+	claims := map[string]any{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": now.Add(vapidLifetime).Unix(),
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS carries the signature as the two 32-byte integers, not ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + unsigned + "." + b64.EncodeToString(sig) + ", k=" + k.public, nil
+}
@@ -0,0 +1,306 @@
+// Package push sends WebDAV-Push messages. When a calendar or address book
+// changes, every client subscribed to it gets a Web Push message (RFC 8030)
+// telling it to sync, instead of having to poll. Messages are encrypted for
+// the client (RFC 8291) and signed with the server's VAPID key (RFC 8292).
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/flags"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/netguard"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Namespace is the XML namespace of the WebDAV-Push draft.
+const Namespace = "https://bitfire.at/webdav-push"
+
+// Collection types a client can subscribe to.
+const (
+	Calendar    = "calendar"
+	AddressBook = "addressbook"
+)
+
+// checkInterval is how often the change feed is checked. A check that
+// finds nothing new costs one query.
+const checkInterval = 5 * time.Second
+
+// pageSize is how many changes are read from the feed at a time.
+const pageSize = 500
+
+// messageTTL is how long a push service holds a message for a device that
+// is offline. Messages for one collection share a Topic, so a later one
+// replaces any still waiting.
+const messageTTL = 24 * time.Hour
+
+// Service sends push messages for collection changes.
+type Service struct {
+	store    *store.Store
+	events   *events.Service
+	contacts *contacts.Service
+	client   *http.Client
+	subject  string
+	log      *logging.Logger
+	now      func() time.Time
+
+	// cfgMu guards cfg, which Reconfigure may replace.
+	cfgMu sync.RWMutex
+	cfg   *config.Config
+
+	mu   sync.Mutex
+	keys *Keys
+	// seen is the change feed position after the last complete check.
+	seen store.ChangeCursor
+}
+
+// New returns the push service for cfg. It sends nothing to users the push
+// feature flag is off for.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	return &Service{
+		store:    st,
+		events:   events.NewService(st),
+		contacts: contacts.NewService(st),
+		client:   netguard.NewClient(false),
+		subject:  cfg.BaseURL,
+		cfg:      cfg,
+		log:      logging.New(sink, "push"),
+		now:      time.Now,
+	}
+}
+
+// Reconfigure applies a reloaded configuration, such as the push feature
+// flag being turned on or off instance-wide.
+func (s *Service) Reconfigure(cfg *config.Config) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	s.cfg = cfg
+}
+
+func (s *Service) config() *config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg
+}
+
+// Start checks for changes to push every check interval until ctx is
+// cancelled. Checks are skipped while the push feature flag is off for
+// everyone.
+func (s *Service) Start(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if on, err := flags.Possible(ctx, s.config(), s.store, config.FeaturePush); err != nil || !on {
+			if err != nil {
+				s.log.Error("Start", "failed to check the push feature flag: %v", err)
+			}
+			continue
+		}
+		if err := s.SendDue(ctx); err != nil {
+			s.log.Error("Start", "failed to send push messages: %v", err)
+		}
+	}
+}
+
+type collectionKey struct {
+	Type string
+	ID   int64
+}
+
+// SendDue pushes a message to every subscription whose collection changed
+// since it was last told. Subscriptions to collections their user can no
+// longer read, or that the push service reports gone, are removed; those of
+// users the push feature flag is off for are left until it is back on.
+func (s *Service) SendDue(ctx context.Context) error {
+	latest, err := s.store.Changes.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if latest == s.seen {
+		return nil
+	}
+	if s.keys == nil {
+		keys, err := LoadKeys(ctx, s.store.PushSubscriptions)
+		if err != nil {
+			return err
+		}
+		s.keys = keys
+	}
+	subs, err := s.store.PushSubscriptions.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	readable := map[int64]map[collectionKey]bool{}
+	muted := map[int64]bool{}
+	for _, sub := range subs {
+		key := collectionKey{sub.CollectionType, sub.CollectionID}
+		canRead, ok := readable[sub.UserID]
+		if !ok {
+			if canRead, muted[sub.UserID], err = s.readable(ctx, sub.UserID); err != nil {
+				return err
+			}
+			readable[sub.UserID] = canRead
+		}
+		if muted[sub.UserID] {
+			continue
+		}
+		if !canRead[key] {
+			if err := s.store.PushSubscriptions.Delete(ctx, sub.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		cursor, changed, err := s.changedSince(ctx, key, sub.Cursor)
+		if err != nil {
+			return err
+		}
+		if cursor == sub.Cursor {
+			continue
+		}
+		var pushedAt *time.Time
+		if changed {
+			now := s.now()
+			pushedAt = &now
+		}
+		// Moving the cursor first claims the message, so replicas checking
+		// at the same time do not both send it.
+		claimed, err := s.store.PushSubscriptions.Advance(ctx, sub.ID, sub.Cursor, cursor, pushedAt)
+		if err != nil {
+			return err
+		}
+		if claimed && changed {
+			s.send(ctx, sub)
+		}
+	}
+	s.seen = latest
+	return nil
+}
+
+// changedSince reads the collection's changes after cursor and returns the
+// cursor after them all, and whether there were any.
+func (s *Service) changedSince(ctx context.Context, key collectionKey, cursor store.ChangeCursor) (store.ChangeCursor, bool, error) {
+	var calendarIDs, bookIDs []int64
+	if key.Type == Calendar {
+		calendarIDs = []int64{key.ID}
+	} else {
+		bookIDs = []int64{key.ID}
+	}
+	changed := false
+	for {
+		page, err := s.store.Changes.ListSince(ctx, calendarIDs, bookIDs, cursor, pageSize)
+		if err != nil {
+			return cursor, changed, err
+		}
+		if len(page) > 0 {
+			cursor = page[len(page)-1].Cursor
+			changed = true
+		}
+		if len(page) < pageSize {
+			return cursor, changed, nil
+		}
+	}
+}
+
+// readable returns the collections the user can read, and whether the push
+// feature flag is off for them.
+func (s *Service) readable(ctx context.Context, userID int64) (map[collectionKey]bool, bool, error) {
+	user, err := s.store.Users.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, false, err
+	}
+	if !flags.Enabled(ctx, s.config(), s.store, user, config.FeaturePush) {
+		return nil, true, nil
+	}
+	calendars, err := s.events.ListCalendars(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+	books, err := s.contacts.ListAccessibleAddressBooks(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+	keys := make(map[collectionKey]bool, len(calendars)+len(books))
+	for _, cal := range calendars {
+		keys[collectionKey{Calendar, cal.ID}] = true
+	}
+	for _, book := range books {
+		keys[collectionKey{AddressBook, book.ID}] = true
+	}
+	return keys, false, nil
+}
+
+// message is a WebDAV-Push push message announcing a content update.
+type message struct {
+	XMLName       xml.Name `xml:"https://bitfire.at/webdav-push push-message"`
+	Topic         string   `xml:"https://bitfire.at/webdav-push topic"`
+	ContentUpdate struct{} `xml:"https://bitfire.at/webdav-push content-update"`
+}
+
+// send pushes a content update for the subscription's collection. Failures
+// are logged: the client still syncs on its own schedule.
+func (s *Service) send(ctx context.Context, sub store.PushSubscription) {
+	if err := s.deliver(ctx, sub); err != nil {
+		s.log.Warn("send", "failed to push to subscription %d: %v", sub.ID, err)
+	}
+}
+
+func (s *Service) deliver(ctx context.Context, sub store.PushSubscription) error {
+	endpoint, err := url.Parse(sub.PushResource)
+	if err != nil {
+		return err
+	}
+	topic := s.keys.Topic(sub.CollectionType, sub.CollectionID)
+	payload, err := xml.Marshal(message{Topic: topic})
+	if err != nil {
+		return err
+	}
+	body, err := encrypt(append([]byte(xml.Header), payload...), sub.PublicKey, sub.AuthSecret)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.keys.authorization(endpoint, s.subject, s.now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.PushResource, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(messageTTL/time.Second)))
+	req.Header.Set("Topic", topic)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The client unsubscribed from the push service.
+		return s.store.PushSubscriptions.Delete(ctx, sub.ID)
+	default:
+		return fmt.Errorf("push service answered %s", resp.Status)
+	}
+}
@@ -0,0 +1,315 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+// decrypt reverses encrypt for the client holding uaPrivate (RFC 8291).
+func decrypt(t *testing.T, body []byte, uaPrivate *ecdh.PrivateKey, authSecret []byte) []byte {
+	t.Helper()
+	salt, rs, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if rs != recordSize || idLen != 65 {
+		t.Fatalf("header rs=%d idlen=%d", rs, idLen)
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	if err != nil {
+		t.Fatalf("key id: %v", err)
+	}
+	shared, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPrivate.PublicKey().Bytes()) + string(asPublic.Bytes())
+	ikm, _ := hkdf.Key(sha256.New, shared, authSecret, keyInfo, 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	record, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if record[len(record)-1] != 0x02 {
+		t.Fatalf("record delimiter = %#x", record[len(record)-1])
+	}
+	return record[:len(record)-1]
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := b64.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+// TestEncryptMatchesRFC8291 uses the example from RFC 8291 Appendix A.
+func TestEncryptMatchesRFC8291(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	auth := "BTBZMqHH6r4Tts7J_aSIgg"
+	plaintext := []byte("When I grow up, I want to be a watermelon")
+
+	got, err := encryptWith(plaintext, uaPublic, auth, asPrivate, mustDecode(t, "DGv6ra1nlYgDCS1FRnbzlw"))
+	if err != nil {
+		t.Fatalf("encryptWith() error = %v", err)
+	}
+	if plain := decrypt(t, got, uaPrivate, mustDecode(t, auth)); !bytes.Equal(plain, plaintext) {
+		t.Fatalf("decrypted %q", plain)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if b64.EncodeToString(got) != want {
+		t.Fatalf("encryptWith() = %s, want %s", b64.EncodeToString(got), want)
+	}
+
+	if _, err := encrypt(plaintext, "bm90IGEga2V5", auth); err != ErrInvalidSubscription {
+		t.Fatalf("encrypt(bad key) error = %v", err)
+	}
+	if err := ValidateKeys(uaPublic, auth); err != nil {
+		t.Fatalf("ValidateKeys() error = %v", err)
+	}
+	if err := ValidateKeys(uaPublic, "c2hvcnQ"); err != ErrInvalidSubscription {
+		t.Fatalf("ValidateKeys(short auth) error = %v", err)
+	}
+}
+
+func TestAuthorizationIsSignedVAPIDToken(t *testing.T) {
+	keys, err := parseKeys("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	if err != nil {
+		t.Fatalf("parseKeys() error = %v", err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	header, err := keys.authorization(&url.URL{Scheme: "https", Host: "push.example.net", Path: "/x"}, "https://cal.example.com", now)
+	if err != nil {
+		t.Fatalf("authorization() error = %v", err)
+	}
+	token, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok || key != keys.PublicKey() {
+		t.Fatalf("authorization() = %q", header)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token = %q", token)
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(mustDecode(t, parts[1]), &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Aud != "https://push.example.net" || claims.Sub != "https://cal.example.com" || claims.Exp != now.Add(vapidLifetime).Unix() {
+		t.Fatalf("claims = %+v", claims)
+	}
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), mustDecode(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := mustDecode(t, parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatal("VAPID signature does not verify")
+	}
+
+	if keys.Topic("calendar", 1) == keys.Topic("addressbook", 1) || len(keys.Topic("calendar", 1)) != 22 {
+		t.Fatalf("Topic() = %q", keys.Topic("calendar", 1))
+	}
+}
+
+func TestValidateResource(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://push.example.net/wpush/v2/abc": true,
+		"https://[2606:4700::1111]/x":           true,
+		"https://100.64.0.1/x":                  false,
+		"http://push.example.net/x":             false,
+		"https://user@push.example.net/x":       false,
+		"https://127.0.0.1/x":                   false,
+		"https://10.1.2.3/x":                    false,
+		"https://169.254.169.254/latest":        false,
+		"https://[::1]/x":                       false,
+		"https://[::ffff:192.168.1.1]/x":        false,
+		"not a url":                             false,
+	} {
+		if err := ValidateResource(raw); (err == nil) != ok {
+			t.Errorf("ValidateResource(%q) error = %v, want ok %v", raw, err, ok)
+		}
+	}
+}
+
+type fakeSubscriptions struct {
+	store.PushSubscriptionRepository
+	subs    []store.PushSubscription
+	deleted []int64
+}
+
+func (f *fakeSubscriptions) ListActive(ctx context.Context) ([]store.PushSubscription, error) {
+	return append([]store.PushSubscription(nil), f.subs...), nil
+}
+
+func (f *fakeSubscriptions) Advance(ctx context.Context, id int64, from, to store.ChangeCursor, pushedAt *time.Time) (bool, error) {
+	for i := range f.subs {
+		if s := &f.subs[i]; s.ID == id && s.Cursor == from {
+			s.Cursor = to
+			if pushedAt != nil {
+				s.LastPushedAt = pushedAt
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeSubscriptions) Delete(ctx context.Context, id int64) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeSubscriptions) VAPIDKey(ctx context.Context, candidate string) (string, error) {
+	return candidate, nil
+}
+
+type fakeChanges struct {
+	store.ChangeRepository
+	changes []store.Change
+	latest  store.ChangeCursor
+}
+
+func (f *fakeChanges) ListSince(ctx context.Context, calendarIDs, addressBookIDs []int64, after store.ChangeCursor, limit int) ([]store.Change, error) {
+	var page []store.Change
+	for _, c := range f.changes {
+		if len(calendarIDs) == 0 || c.CollectionID != calendarIDs[0] {
+			continue
+		}
+		if c.Cursor.TxID > after.TxID || (c.Cursor.TxID == after.TxID && c.Cursor.Seq > after.Seq) {
+			page = append(page, c)
+		}
+	}
+	return page, nil
+}
+
+func (f *fakeChanges) Latest(ctx context.Context) (store.ChangeCursor, error) {
+	return f.latest, nil
+}
+
+func TestSendDuePushesChangedCollections(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := "BTBZMqHH6r4Tts7J_aSIgg"
+
+	var received []*http.Request
+	var bodies [][]byte
+	status := http.StatusCreated
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sub := func(id, calendarID int64) store.PushSubscription {
+		return store.PushSubscription{ID: id, UserID: 1, CollectionType: Calendar, CollectionID: calendarID, PushResource: server.URL + "/push",
+			PublicKey: b64.EncodeToString(uaPrivate.PublicKey().Bytes()), AuthSecret: auth, Cursor: store.ChangeCursor{TxID: 10}}
+	}
+	subs := &fakeSubscriptions{subs: []store.PushSubscription{sub(1, 5), sub(2, 6)}}
+	changes := &fakeChanges{
+		changes: []store.Change{{CollectionID: 5, Cursor: store.ChangeCursor{TxID: 11, Seq: 1}}},
+		latest:  store.ChangeCursor{TxID: 12},
+	}
+	st := &store.Store{PushSubscriptions: subs, Changes: changes, Users: storetest.NewUsers(store.User{ID: 1}),
+		Calendars: storetest.NewCalendars(store.CalendarAccess{Calendar: store.Calendar{ID: 5, UserID: 1, Name: "Work"}}), AddressBooks: storetest.NewAddressBooks()}
+	cfg := &config.Config{}
+	cfg.DAV.PushEnabled = true
+	s := &Service{store: st, events: events.NewService(st), contacts: contacts.NewService(st), client: server.Client(), cfg: cfg,
+		log: logging.New(nil, "push"), now: time.Now}
+
+	if err := s.SendDue(context.Background()); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(received))
+	}
+	req := received[0]
+	if req.Header.Get("Content-Encoding") != "aes128gcm" || req.Header.Get("Topic") != s.keys.Topic(Calendar, 5) ||
+		!strings.HasPrefix(req.Header.Get("Authorization"), "vapid t=") || req.Header.Get("TTL") != "86400" {
+		t.Fatalf("headers = %v", req.Header)
+	}
+	plain := string(decrypt(t, bodies[0], uaPrivate, mustDecode(t, auth)))
+	if !strings.Contains(plain, "<topic xmlns=\"https://bitfire.at/webdav-push\">"+s.keys.Topic(Calendar, 5)+"</topic>") || !strings.Contains(plain, "content-update") {
+		t.Fatalf("message = %s", plain)
+	}
+	if subs.subs[0].Cursor != (store.ChangeCursor{TxID: 11, Seq: 1}) || subs.subs[0].LastPushedAt == nil {
+		t.Fatalf("subscription = %+v", subs.subs[0])
+	}
+	// Calendar 6 is not readable by the user any more.
+	if len(subs.deleted) != 1 || subs.deleted[0] != 2 {
+		t.Fatalf("deleted = %v, want [2]", subs.deleted)
+	}
+
+	// Nothing new in the feed: no queries beyond Latest, no messages.
+	subs.subs = subs.subs[:1]
+	subs.deleted = nil
+	if err := s.SendDue(context.Background()); err != nil || len(received) != 1 {
+		t.Fatalf("SendDue() = %v, sent %d", err, len(received))
+	}
+
+	// With the push feature flag turned off, changes are not pushed and the
+	// subscription is kept.
+	cfg.Features = map[string]bool{config.FeaturePush: false}
+	changes.changes = append(changes.changes, store.Change{CollectionID: 5, Cursor: store.ChangeCursor{TxID: 12, Seq: 1}})
+	changes.latest = store.ChangeCursor{TxID: 13}
+	if err := s.SendDue(context.Background()); err != nil || len(received) != 1 || len(subs.deleted) != 0 {
+		t.Fatalf("SendDue() = %v, sent %d, deleted %v", err, len(received), subs.deleted)
+	}
+	if subs.subs[0].Cursor != (store.ChangeCursor{TxID: 11, Seq: 1}) {
+		t.Fatalf("muted subscription advanced to %+v", subs.subs[0].Cursor)
+	}
+	cfg.Features = nil
+
+	// A push service that has forgotten the subscription gets it removed.
+	status = http.StatusGone
+	changes.changes = append(changes.changes, store.Change{CollectionID: 5, Cursor: store.ChangeCursor{TxID: 13, Seq: 1}})
+	changes.latest = store.ChangeCursor{TxID: 14}
+	if err := s.SendDue(context.Background()); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if len(received) != 2 || len(subs.deleted) != 1 || subs.deleted[0] != 1 {
+		t.Fatalf("sent %d, deleted %v", len(received), subs.deleted)
+	}
+}
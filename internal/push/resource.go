@@ -0,0 +1,25 @@
+package push
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/jw6ventures/calcard/internal/netguard"
+)
+
+// ErrInvalidResource reports a push resource the server will not send to.
+var ErrInvalidResource = errors.New("push resource must be a public https URL")
+
+// ValidateResource checks a client's push resource: an https URL that
+// does not name a loopback, private or otherwise internal address. Host
+// names are checked again when they are dialled.
+func ValidateResource(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil || len(raw) > 2048 {
+		return ErrInvalidResource
+	}
+	if !netguard.PublicHost(u.Hostname()) {
+		return ErrInvalidResource
+	}
+	return nil
+}
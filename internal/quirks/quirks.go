@@ -0,0 +1,153 @@
+// Package quirks matches DAV clients with known interoperability bugs by
+// their User-Agent, so the DAV server can adapt its responses for them
+// without changing what conforming clients get. Nothing is matched unless
+// profiles or rules are configured.
+package quirks
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Set is a set of workarounds applied to one client.
+type Set uint8
+
+const (
+	// VCard3 serves vCard 4.0 contacts as vCard 3.0.
+	VCard3 Set = 1 << iota
+	// NoCDATA sends calendar and address data as escaped text rather than
+	// CDATA sections.
+	NoCDATA
+	// MinimalProps answers allprop PROPFIND requests with a short list of
+	// common properties.
+	MinimalProps
+	// NoTimezones leaves out of calendar data the VTIMEZONEs a client can
+	// look up in the time zone database itself, as RFC 7809 clients ask for
+	// with "CalDAV-Timezones: F".
+	NoTimezones
+	// Timezones adds a VTIMEZONE from the time zone database for every TZID
+	// that calendar data uses without defining.
+	Timezones
+)
+
+var quirkNames = map[string]Set{
+	"vcard3":        VCard3,
+	"no-cdata":      NoCDATA,
+	"minimal-props": MinimalProps,
+	"no-timezones":  NoTimezones,
+	"timezones":     Timezones,
+}
+
+// Has reports whether s includes every quirk in q.
+func (s Set) Has(q Set) bool {
+	return q != 0 && s&q == q
+}
+
+// String lists the quirks in s by name, such as "no-cdata+vcard3".
+func (s Set) String() string {
+	var names []string
+	for name, q := range quirkNames {
+		if s.Has(q) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
+
+type rule struct {
+	match  func(userAgent string) bool
+	quirks Set
+}
+
+var thunderbirdVersion = regexp.MustCompile(`thunderbird/(\d+)`)
+
+// profiles are the built-in rules, enabled by name.
+var profiles = map[string]rule{
+	// Apple Contacts on iOS and macOS.
+	"apple": {match: containsAny("dataaccessd", "addressbookcore", "addressbook/"), quirks: VCard3},
+	// Thunderbird before 78, whose Lightning and CardBook add-ons time out on
+	// large allprop responses.
+	"thunderbird-legacy": {
+		match: func(ua string) bool {
+			m := thunderbirdVersion.FindStringSubmatch(ua)
+			if m == nil {
+				return false
+			}
+			major, err := strconv.Atoi(m[1])
+			return err == nil && major < 78
+		},
+		quirks: MinimalProps,
+	},
+	// The Outlook CalDav Synchronizer, whose XML reader drops CDATA content.
+	"caldavsynchronizer": {match: containsAny("caldavsynchronizer"), quirks: NoCDATA},
+}
+
+// Rules are the configured client quirks.
+type Rules []rule
+
+// Parse reads quirk rules from APP_DAV_CLIENT_QUIRKS entries. Each entry is
+// either a built-in profile name, such as "apple", or a case-insensitive
+// User-Agent substring and the quirks to apply, joined by "+", as in
+// "MyClient/2=no-cdata+vcard3".
+func Parse(entries []string) (Rules, error) {
+	var rules Rules
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match, names, custom := strings.Cut(entry, "=")
+		if !custom {
+			p, ok := profiles[strings.ToLower(entry)]
+			if !ok {
+				return nil, fmt.Errorf("unknown client quirks profile %q", entry)
+			}
+			rules = append(rules, p)
+			continue
+		}
+		match = strings.ToLower(strings.TrimSpace(match))
+		if match == "" {
+			return nil, fmt.Errorf("client quirks rule %q has no User-Agent to match", entry)
+		}
+		var set Set
+		for _, name := range strings.Split(names, "+") {
+			q, ok := quirkNames[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return nil, fmt.Errorf("unknown client quirk %q in %q", name, entry)
+			}
+			set |= q
+		}
+		rules = append(rules, rule{match: containsAny(match), quirks: set})
+	}
+	return rules, nil
+}
+
+// For returns the quirks of every rule matching userAgent.
+func (r Rules) For(userAgent string) Set {
+	if len(r) == 0 {
+		return 0
+	}
+	ua := strings.ToLower(userAgent)
+	var set Set
+	for _, rule := range r {
+		if rule.match(ua) {
+			set |= rule.quirks
+		}
+	}
+	return set
+}
+
+func containsAny(needles ...string) func(string) bool {
+	return func(ua string) bool {
+		for _, n := range needles {
+			if strings.Contains(ua, n) {
+				return true
+			}
+		}
+		return false
+	}
+}
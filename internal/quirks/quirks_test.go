@@ -0,0 +1,36 @@
+package quirks
+
+import "testing"
+
+func TestParseAndMatch(t *testing.T) {
+	rules, err := Parse([]string{"apple", "thunderbird-legacy", " MyClient/2 = no-cdata+vcard3 "})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for ua, want := range map[string]Set{
+		"iOS/17.2 (21C62) dataaccessd/1.0":                      VCard3,
+		"Mozilla/5.0 (X11; Linux x86_64) Thunderbird/68.12.0":   MinimalProps,
+		"Mozilla/5.0 (X11; Linux x86_64) Thunderbird/115.3.1":   0,
+		"mozilla/5.0 myclient/2.1":                              NoCDATA | VCard3,
+		"DAVx5/4.3.10-ose (2023/12/04; dav4jvm; okhttp/4.12.0)": 0,
+		"": 0,
+	} {
+		if got := rules.For(ua); got != want {
+			t.Errorf("For(%q) = %v, want %v", ua, got, want)
+		}
+	}
+	if got := (NoCDATA | VCard3).String(); got != "no-cdata+vcard3" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestParseRejectsUnknownNames(t *testing.T) {
+	for _, entry := range []string{"android", "Client=fast", "=vcard3"} {
+		if _, err := Parse([]string{entry}); err == nil {
+			t.Errorf("Parse(%q) succeeded", entry)
+		}
+	}
+	if rules, err := Parse(nil); err != nil || rules.For("iOS dataaccessd") != 0 {
+		t.Fatalf("empty rules = %v, %v", rules, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package quota reports how much of their storage quota users have used,
+// so that they and their devices are warned well before it runs out.
+package quota
+
+import (
+	"context"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Quota levels, from least to most full.
+const (
+	LevelOK       = "ok"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// Thresholds, in percent of the quota, at which users are warned.
+const (
+	WarningPercent  = 80
+	CriticalPercent = 95
+)
+
+// Status is a user's storage use against their quota.
+type Status struct {
+	UsedBytes  int64
+	QuotaBytes int64
+	Level      string
+}
+
+// AvailableBytes returns how much of the quota is left.
+func (s Status) AvailableBytes() int64 {
+	return max(s.QuotaBytes-s.UsedBytes, 0)
+}
+
+// Percent returns the share of the quota used, rounded down.
+func (s Status) Percent() int {
+	if s.QuotaBytes <= 0 {
+		return 0
+	}
+	return int(s.UsedBytes * 100 / s.QuotaBytes)
+}
+
+// LevelFor returns the level of used bytes against a quota. Without a
+// quota every user is LevelOK.
+func LevelFor(used, quota int64) string {
+	switch {
+	case quota <= 0 || used*100 < quota*WarningPercent:
+		return LevelOK
+	case used*100 < quota*CriticalPercent:
+		return LevelWarning
+	default:
+		return LevelCritical
+	}
+}
+
+// Rank orders levels, so that a warning is only sent when the level rises.
+// The empty level, as recorded before any warning, ranks as LevelOK.
+func Rank(level string) int {
+	switch level {
+	case LevelWarning:
+		return 1
+	case LevelCritical:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Check returns the user's status, or nil when no quota is configured.
+func Check(ctx context.Context, cfg *config.Config, st *store.Store, userID int64) (*Status, error) {
+	if cfg == nil || cfg.Quota.UserBytes <= 0 || st == nil || st.Quota == nil {
+		return nil, nil
+	}
+	used, err := st.Quota.Usage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{UsedBytes: used, QuotaBytes: cfg.Quota.UserBytes, Level: LevelFor(used, cfg.Quota.UserBytes)}, nil
+}
@@ -0,0 +1,46 @@
+package quota
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/store/storetest"
+)
+
+func TestLevelForThresholds(t *testing.T) {
+	tests := []struct {
+		used, quota int64
+		want        string
+	}{
+		{used: 1 << 40, quota: 0, want: LevelOK},
+		{used: 79, quota: 100, want: LevelOK},
+		{used: 80, quota: 100, want: LevelWarning},
+		{used: 94, quota: 100, want: LevelWarning},
+		{used: 95, quota: 100, want: LevelCritical},
+		{used: 120, quota: 100, want: LevelCritical},
+	}
+	for _, tt := range tests {
+		if got := LevelFor(tt.used, tt.quota); got != tt.want {
+			t.Errorf("LevelFor(%d, %d) = %q, want %q", tt.used, tt.quota, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReportsUsageAgainstQuota(t *testing.T) {
+	st := &store.Store{Quota: &storetest.Quota{Used: map[int64]int64{1: 900}}}
+	if status, err := Check(context.Background(), &config.Config{}, st, 1); err != nil || status != nil {
+		t.Fatalf("Check() without a quota = %+v, %v, want nil", status, err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Quota.UserBytes = 1000
+	status, err := Check(context.Background(), cfg, st, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Level != LevelWarning || status.AvailableBytes() != 100 || status.Percent() != 90 {
+		t.Fatalf("Check() = %+v, available %d, %d%%", status, status.AvailableBytes(), status.Percent())
+	}
+}
@@ -0,0 +1,420 @@
+// Package redis is a small client for the parts of Redis that let several
+// CalCard replicas coordinate: counters that expire and publish/subscribe.
+// It speaks RESP2 over a handful of pooled connections and needs nothing
+// beyond the standard library.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// poolSize bounds the idle connections kept for commands.
+	poolSize = 8
+	// dialTimeout bounds connecting and authenticating.
+	dialTimeout = 2 * time.Second
+	// commandTimeout bounds a command whose context has no deadline.
+	commandTimeout = time.Second
+	// pingInterval is how often a subscription checks its connection.
+	pingInterval = 30 * time.Second
+)
+
+// Error is an error reply from the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// ErrClosed is returned by commands on a closed Client.
+var ErrClosed = errors.New("redis: client closed")
+
+// Client runs commands against one Redis server. It is safe for
+// concurrent use.
+type Client struct {
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	db       int
+
+	idle   chan *conn
+	closed chan struct{}
+}
+
+// New returns a client for a redis:// or rediss:// URL of the form
+// redis://[[user]:password@]host[:port][/db]. It does not connect until the
+// first command.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: invalid URL: %w", err)
+	}
+	c := &Client{idle: make(chan *conn, poolSize), closed: make(chan struct{})}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("redis: URL scheme must be redis or rediss, not %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis: URL has no host")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	c.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Close closes the pooled connections and ends subscriptions.
+func (c *Client) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+	}
+	close(c.closed)
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Do runs one command and returns its reply: a string, an int64, nil, or a
+// []any of those.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// IncrWindow adds one to the counter at key and returns the new count. A
+// counter that did not exist starts at zero and expires after window.
+func (c *Client) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, error) {
+	ms := strconv.FormatInt(window.Milliseconds(), 10)
+	replies, err := c.pipeline(ctx, []string{"SET", key, "0", "PX", ms, "NX"}, []string{"INCR", key})
+	if err != nil {
+		return 0, err
+	}
+	n, ok := replies[1].(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: INCR replied %v", replies[1])
+	}
+	return n, nil
+}
+
+// Publish sends message to the subscribers of channel.
+func (c *Client) Publish(ctx context.Context, channel, message string) error {
+	_, err := c.Do(ctx, "PUBLISH", channel, message)
+	return err
+}
+
+// Subscribe calls fn with each message published to channel until ctx is
+// cancelled or the client is closed, reconnecting after connection errors.
+// Messages published while it reconnects are lost.
+func (c *Client) Subscribe(ctx context.Context, channel string, fn func(message string)) error {
+	backoff := time.Second
+	for {
+		err := c.subscribeOnce(ctx, channel, fn, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrClosed) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closed:
+			return ErrClosed
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (c *Client) subscribeOnce(ctx context.Context, channel string, fn func(string), subscribed func()) error {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// Closing the connection is the only way to interrupt the read.
+		select {
+		case <-ctx.Done():
+		case <-c.closed:
+		case <-done:
+		}
+		cn.Close()
+	}()
+
+	cn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := cn.write([]string{"SUBSCRIBE", channel}); err != nil {
+		return err
+	}
+	cn.SetWriteDeadline(time.Time{})
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if cn.write([]string{"PING"}) != nil {
+					return
+				}
+			}
+		}
+	}()
+	for {
+		reply, err := cn.read()
+		if err != nil {
+			select {
+			case <-c.closed:
+				return ErrClosed
+			default:
+			}
+			return err
+		}
+		// Anything, including the reply to a PING, proves the link is up.
+		cn.SetReadDeadline(time.Now().Add(3 * pingInterval))
+		parts, _ := reply.([]any)
+		if len(parts) == 0 {
+			continue
+		}
+		switch kind, _ := parts[0].(string); kind {
+		case "subscribe":
+			subscribed()
+		case "message":
+			if len(parts) == 3 {
+				if message, ok := parts[2].(string); ok {
+					fn(message)
+				}
+			}
+		}
+	}
+}
+
+// pipeline sends cmds in one write and reads their replies. An error reply
+// to any of them is returned as an Error.
+func (c *Client) pipeline(ctx context.Context, cmds ...[]string) ([]any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(commandTimeout)
+	}
+	cn.SetDeadline(deadline)
+	replies, err := cn.roundTrip(cmds)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, err
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrClosed
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+		return c.dial(ctx)
+	}
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case <-c.closed:
+		cn.Close()
+		return
+	default:
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// dial connects, authenticates and selects the database.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrClosed
+	default:
+	}
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.tls != nil {
+		tc := tls.Client(nc, c.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	deadline, _ := ctx.Deadline()
+	cn.SetDeadline(deadline)
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := cn.roundTrip(setup); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// conn is one connection to the server.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (cn *conn) roundTrip(cmds [][]string) ([]any, error) {
+	for _, cmd := range cmds {
+		cn.encode(cmd)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := cn.read()
+		var replyErr Error
+		if err != nil && !errors.As(err, &replyErr) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+func (cn *conn) write(cmd []string) error {
+	cn.encode(cmd)
+	return cn.w.Flush()
+}
+
+// encode buffers cmd as an array of bulk strings. Write errors surface on
+// the next flush.
+func (cn *conn) encode(cmd []string) {
+	fmt.Fprintf(cn.w, "*%d\r\n", len(cmd))
+	for _, arg := range cmd {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// read parses one reply. An error reply is returned as an Error alongside
+// a nil value, leaving the connection usable.
+func (cn *conn) read() (any, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			// An error inside an array does not end the reply.
+			item, err := cn.read()
+			var replyErr Error
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = replyErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
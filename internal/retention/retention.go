@@ -0,0 +1,118 @@
+// Package retention enforces per-calendar retention policies, deleting the
+// events of a calendar once they have been over for the number of months
+// its owner chose.
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// checkInterval is how often policies are checked for a due run.
+const checkInterval = time.Hour
+
+// runInterval is how often each policy is enforced. A policy that changed
+// since its last run is enforced at the next check.
+const runInterval = 24 * time.Hour
+
+// batchSize bounds the events read and deleted at a time.
+const batchSize = 500
+
+// Service enforces the policies that are due.
+type Service struct {
+	store *store.Store
+	log   *logging.Logger
+	now   func() time.Time
+}
+
+// New returns the retention service for st.
+func New(st *store.Store, sink logging.Sink) *Service {
+	return &Service{store: st, log: logging.New(sink, "retention"), now: time.Now}
+}
+
+// Start enforces due policies every hour until ctx is cancelled.
+func (s *Service) Start(ctx context.Context) {
+	if s.store.Retention == nil {
+		return
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.EnforceDue(ctx); err != nil {
+			s.log.Error("Start", "failed to check retention policies: %v", err)
+		}
+	}
+}
+
+// EnforceDue enforces every policy not run in the last day or changed since
+// it last ran. A calendar that fails is retried on the next check.
+func (s *Service) EnforceDue(ctx context.Context) error {
+	policies, err := s.store.Retention.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, policy := range policies {
+		if !Due(policy, now) {
+			continue
+		}
+		if _, err := s.Enforce(ctx, policy); err != nil {
+			s.log.Error("EnforceDue", "failed to enforce retention of calendar %d: %v", policy.CalendarID, err)
+		}
+	}
+	return nil
+}
+
+// Due reports whether policy should be enforced at now.
+func Due(policy store.RetentionPolicy, now time.Time) bool {
+	last := policy.LastRunAt
+	return last == nil || policy.UpdatedAt.After(*last) || now.Sub(*last) >= runInterval
+}
+
+// Enforce deletes the events of the policy's calendar that ended before its
+// cutoff and returns how many it deleted. Each deletion leaves a tombstone,
+// so syncing clients drop the event, and is logged in the calendar's
+// retention log.
+func (s *Service) Enforce(ctx context.Context, policy store.RetentionPolicy) (int, error) {
+	cutoff := events.RetentionCutoff(policy.Months, s.now())
+	var expired []store.Event
+	var afterID int64
+	for {
+		batch, err := s.store.Retention.ListEndedBefore(ctx, policy.CalendarID, cutoff, afterID, batchSize)
+		if err != nil {
+			return 0, err
+		}
+		for _, ev := range batch {
+			if events.Expired(ev, cutoff) {
+				expired = append(expired, ev)
+			}
+		}
+		if len(batch) < batchSize {
+			break
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+
+	deleted := 0
+	for start := 0; start == 0 || start < len(expired); start += batchSize {
+		end := min(start+batchSize, len(expired))
+		n, err := s.store.Retention.Expire(ctx, policy.CalendarID, expired[start:end], cutoff)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	if deleted > 0 {
+		s.log.Info("Enforce", "deleted %d events of calendar %d that ended before %s", deleted, policy.CalendarID, cutoff.Format(time.RFC3339))
+	}
+	return deleted, nil
+}
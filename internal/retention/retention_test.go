@@ -0,0 +1,99 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeRetentionRepo struct {
+	store.RetentionRepository
+	policies []store.RetentionPolicy
+	events   []store.Event
+	expired  []string
+	cutoff   time.Time
+	runs     int
+}
+
+func (f *fakeRetentionRepo) ListAll(ctx context.Context) ([]store.RetentionPolicy, error) {
+	return f.policies, nil
+}
+
+func (f *fakeRetentionRepo) ListEndedBefore(ctx context.Context, calendarID int64, cutoff time.Time, afterID int64, limit int) ([]store.Event, error) {
+	var result []store.Event
+	for _, ev := range f.events {
+		if ev.CalendarID == calendarID && ev.ID > afterID && len(result) < limit {
+			result = append(result, ev)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeRetentionRepo) Expire(ctx context.Context, calendarID int64, events []store.Event, cutoff time.Time) (int, error) {
+	f.runs++
+	f.cutoff = cutoff
+	for _, ev := range events {
+		f.expired = append(f.expired, ev.UID)
+	}
+	return len(events), nil
+}
+
+func event(id int64, uid, body string) store.Event {
+	return store.Event{ID: id, CalendarID: 1, UID: uid, RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:" + uid + "\r\n" + body + "END:VEVENT\r\nEND:VCALENDAR\r\n"}
+}
+
+func TestEnforceDeletesOnlyEventsWhollyBeforeTheCutoff(t *testing.T) {
+	now := time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+	repo := &fakeRetentionRepo{
+		events: []store.Event{
+			event(1, "old-shift", "DTSTART:20251001T080000Z\r\nDTEND:20251001T160000Z\r\n"),
+			event(2, "recent-shift", "DTSTART:20260301T080000Z\r\nDTEND:20260301T160000Z\r\n"),
+			event(3, "standup", "DTSTART:20240101T090000Z\r\nDTEND:20240101T091500Z\r\nRRULE:FREQ=DAILY\r\n"),
+		},
+	}
+	s := New(&store.Store{Retention: repo}, nil)
+	s.now = func() time.Time { return now }
+
+	deleted, err := s.Enforce(context.Background(), store.RetentionPolicy{CalendarID: 1, Months: 6})
+	if err != nil || deleted != 1 {
+		t.Fatalf("Enforce() = %d, %v, want 1", deleted, err)
+	}
+	if len(repo.expired) != 1 || repo.expired[0] != "old-shift" {
+		t.Fatalf("expired %v, want only old-shift", repo.expired)
+	}
+	if want := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC); !repo.cutoff.Equal(want) {
+		t.Fatalf("cutoff = %s, want %s", repo.cutoff, want)
+	}
+}
+
+func TestEnforceMarksARunWithNothingToDelete(t *testing.T) {
+	repo := &fakeRetentionRepo{}
+	s := New(&store.Store{Retention: repo}, nil)
+	if deleted, err := s.Enforce(context.Background(), store.RetentionPolicy{CalendarID: 1, Months: 1}); err != nil || deleted != 0 || repo.runs != 1 {
+		t.Fatalf("Enforce() = %d, %v with %d runs, want 0 with one run", deleted, err, repo.runs)
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	for _, tc := range []struct {
+		name   string
+		policy store.RetentionPolicy
+		want   bool
+	}{
+		{"never run", store.RetentionPolicy{UpdatedAt: now}, true},
+		{"ran an hour ago", store.RetentionPolicy{UpdatedAt: now.Add(-48 * time.Hour), LastRunAt: at(time.Hour)}, false},
+		{"ran a day ago", store.RetentionPolicy{UpdatedAt: now.Add(-48 * time.Hour), LastRunAt: at(24 * time.Hour)}, true},
+		{"changed since", store.RetentionPolicy{UpdatedAt: now.Add(-time.Minute), LastRunAt: at(time.Hour)}, true},
+	} {
+		if got := Due(tc.policy, now); got != tc.want {
+			t.Errorf("Due(%s) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package richtext
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletPattern      = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern     = regexp.MustCompile(`^\s*(\d{1,9})[.)]\s+(.*)$`)
+	linkPattern        = regexp.MustCompile(`^\[([^\]]*)\]\(\s*<?((?:[^()\s<>]|\([^()\s]*\))*)>?(?:\s+"[^"]*")?\s*\)`)
+	autolinkPattern    = regexp.MustCompile(`^<((?:https?|mailto):[^>\s]+)>`)
+	bareURLPattern     = regexp.MustCompile(`^https?://[^\s<]*[^\s<.,:;!?'")\]]`)
+	emphasisDelimiters = []struct{ marker, tag string }{
+		{"**", "strong"}, {"__", "strong"}, {"~~", "del"}, {"*", "em"}, {"_", "em"},
+	}
+)
+
+// Markdown renders src, a common subset of Markdown, to HTML: paragraphs
+// with hard line breaks, headings, bullet and numbered lists, block quotes,
+// fenced code, rules, emphasis, code spans and links. Raw HTML in src is
+// shown as text, and the result is sanitized.
+func Markdown(src string) string {
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\r", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return Sanitize(b.String())
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				j++
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(lines[i+1:j], "\n")) + "</code></pre>")
+			i = j + 1
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">")
+			i++
+		case isRule(line):
+			b.WriteString("<hr>")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+				i++
+			}
+			b.WriteString("<blockquote>")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>")
+		case bulletPattern.MatchString(line):
+			i = renderList(b, lines, i, bulletPattern, "ul")
+		case orderedPattern.MatchString(line):
+			i = renderList(b, lines, i, orderedPattern, "ol")
+		default:
+			var para []string
+			for i < len(lines) && startsParagraphLine(lines[i], len(para) == 0) {
+				para = append(para, renderInline(strings.TrimSpace(lines[i])))
+				i++
+			}
+			b.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+		}
+	}
+}
+
+// startsParagraphLine reports whether line continues the paragraph being
+// collected, which ends at a blank line or the start of another block.
+func startsParagraphLine(line string, first bool) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+	if first {
+		return true
+	}
+	return !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") &&
+		!strings.HasPrefix(trimmed, ">") && !headingPattern.MatchString(trimmed) &&
+		!bulletPattern.MatchString(line) && !orderedPattern.MatchString(line) && !isRule(line)
+}
+
+// renderList renders the list items starting at lines[i] and returns the
+// index of the first line after the list. Indented lines continue an item.
+func renderList(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag)
+	if tag == "ol" {
+		if m := orderedPattern.FindStringSubmatch(lines[i]); m[1] != "1" {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				b.WriteString(` start="` + strconv.Itoa(n) + `"`)
+			}
+		}
+	}
+	b.WriteString(">")
+	for i < len(lines) {
+		m := item.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		text := []string{renderInline(strings.TrimSpace(m[len(m)-1]))}
+		i++
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" && (strings.HasPrefix(lines[i], " ") || strings.HasPrefix(lines[i], "\t")) &&
+			!bulletPattern.MatchString(lines[i]) && !orderedPattern.MatchString(lines[i]) {
+			text = append(text, renderInline(strings.TrimSpace(lines[i])))
+			i++
+		}
+		b.WriteString("<li>" + strings.Join(text, "<br>") + "</li>")
+	}
+	b.WriteString("</" + tag + ">")
+	return i
+}
+
+// renderInline renders code spans, links and emphasis within a line,
+// escaping everything else.
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_{}[]()#+-.!~<>", rune(rest[1])):
+			b.WriteString(html.EscapeString(rest[1:2]))
+			i += 2
+			continue
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				b.WriteString("<code>" + html.EscapeString(rest[1:1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case rest[0] == '[':
+			if m := linkPattern.FindStringSubmatch(rest); m != nil {
+				if href, ok := SafeURL(m[2]); ok {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `">` + renderInline(m[1]) + "</a>")
+				} else {
+					b.WriteString(renderInline(m[1]))
+				}
+				i += len(m[0])
+				continue
+			}
+		case rest[0] == '<':
+			if m := autolinkPattern.FindStringSubmatch(rest); m != nil {
+				b.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(strings.TrimPrefix(m[1], "mailto:")) + "</a>")
+				i += len(m[0])
+				continue
+			}
+		case rest[0] == 'h' && (i == 0 || !isNameByte(s[i-1])):
+			if m := bareURLPattern.FindString(rest); m != "" {
+				b.WriteString(`<a href="` + html.EscapeString(m) + `">` + html.EscapeString(m) + "</a>")
+				i += len(m)
+				continue
+			}
+		}
+		if tag, inner, n := emphasis(rest); n > 0 {
+			b.WriteString("<" + tag + ">" + renderInline(inner) + "</" + tag + ">")
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+// emphasis matches an emphasis span at the start of s, returning its tag,
+// the text inside and its length. A delimiter must hug the text, so
+// "2 * 3 * 4" is left alone, and "_" does not open inside a word.
+func emphasis(s string) (string, string, int) {
+	for _, d := range emphasisDelimiters {
+		if !strings.HasPrefix(s, d.marker) {
+			continue
+		}
+		body := s[len(d.marker):]
+		if body == "" || body[0] == ' ' || strings.HasPrefix(body, d.marker[:1]) && len(d.marker) == 1 {
+			continue
+		}
+		for from := 0; ; {
+			end := strings.Index(body[from:], d.marker)
+			if end < 0 {
+				break
+			}
+			end += from
+			if end > 0 && body[end-1] != ' ' && body[end-1] != '\\' {
+				after := end + len(d.marker)
+				if d.marker[0] != '_' || after == len(body) || !isNameByte(body[after]) {
+					return d.tag, body[:end], len(d.marker) + after
+				}
+			}
+			from = end + len(d.marker)
+		}
+	}
+	return "", "", 0
+}
+
+// isRule reports whether line is a thematic break: three or more of the
+// same "-", "*" or "_", optionally spaced.
+func isRule(line string) bool {
+	s := strings.ReplaceAll(strings.ReplaceAll(line, " ", ""), "\t", "")
+	if len(s) < 3 || !strings.ContainsRune("-*_", rune(s[0])) {
+		return false
+	}
+	return strings.Count(s, s[:1]) == len(s)
+}
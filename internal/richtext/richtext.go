@@ -0,0 +1,243 @@
+// Package richtext handles formatted event descriptions: it renders a
+// Markdown subset to HTML, sanitizes HTML from calendar clients down to a
+// safe set of tags, and derives the plain text iCalendar DESCRIPTION
+// carries for clients that only show text.
+package richtext
+
+import (
+	"html"
+	"strings"
+)
+
+// allowedTags are the tags Sanitize keeps, mapped to whether they are void
+// elements with no closing tag.
+var allowedTags = map[string]bool{
+	"a": false, "b": false, "strong": false, "i": false, "em": false, "u": false, "s": false,
+	"strike": false, "del": false, "ins": false, "sub": false, "sup": false, "small": false,
+	"mark": false, "code": false, "pre": false, "p": false, "div": false, "span": false,
+	"blockquote": false, "ul": false, "ol": false, "li": false, "dl": false, "dt": false, "dd": false,
+	"h1": false, "h2": false, "h3": false, "h4": false, "h5": false, "h6": false,
+	"table": false, "caption": false, "thead": false, "tbody": false, "tfoot": false,
+	"tr": false, "td": false, "th": false,
+	"br": true, "hr": true,
+}
+
+// droppedTags are removed together with everything inside them.
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "noscript": true,
+	"template": true, "textarea": true, "title": true, "head": true, "svg": true, "math": true,
+	"select": true, "frameset": true, "noembed": true, "xmp": true,
+}
+
+// blockTags start a new line in plain text, and paragraphTags a new
+// paragraph.
+var (
+	blockTags = map[string]bool{
+		"div": true, "li": true, "dt": true, "dd": true, "caption": true, "tr": true, "hr": true,
+	}
+	paragraphTags = map[string]bool{
+		"p": true, "blockquote": true, "ul": true, "ol": true, "dl": true, "table": true, "pre": true,
+		"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	}
+)
+
+// Sanitize returns html reduced to the allowed tags, with links limited to
+// http, https, mailto and tel and opened without a referrer. Everything else
+// is re-escaped text, so the result is safe to insert into a page. Unclosed
+// tags are closed at the end.
+func Sanitize(src string) string {
+	var b strings.Builder
+	var open []string
+	tokenize(src, func(t token) {
+		switch t.kind {
+		case textToken:
+			b.WriteString(html.EscapeString(t.text))
+		case startToken:
+			void, ok := allowedTags[t.name]
+			if !ok {
+				return
+			}
+			b.WriteString("<" + t.name)
+			writeAttrs(&b, t)
+			b.WriteString(">")
+			if !void && !t.selfClosing {
+				open = append(open, t.name)
+			}
+		case endToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != t.name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	})
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+func writeAttrs(b *strings.Builder, t token) {
+	switch t.name {
+	case "a":
+		if href, ok := SafeURL(t.attrs["href"]); ok {
+			b.WriteString(` href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer"`)
+		}
+		if title := t.attrs["title"]; title != "" {
+			b.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+	case "td", "th":
+		for _, name := range []string{"colspan", "rowspan"} {
+			if v := t.attrs[name]; isDigits(v) {
+				b.WriteString(" " + name + `="` + v + `"`)
+			}
+		}
+	case "ol":
+		if v := t.attrs["start"]; isDigits(v) {
+			b.WriteString(` start="` + v + `"`)
+		}
+	}
+}
+
+// SafeURL returns the trimmed URL when it uses a scheme a description link
+// may have.
+func SafeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	scheme, _, ok := strings.Cut(raw, ":")
+	if !ok {
+		return "", false
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto", "tel":
+	default:
+		return "", false
+	}
+	if strings.ContainsAny(raw, "\x00\t\r\n") {
+		return "", false
+	}
+	return raw, true
+}
+
+// PlainText returns the text of html with block elements on their own
+// lines, list items marked with "- " and link targets after their text.
+func PlainText(src string) string {
+	var b strings.Builder
+	var href string
+	var linkText strings.Builder
+	pre := 0
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+	}
+	paragraph := func() {
+		newline()
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+			b.WriteString("\n")
+		}
+	}
+	tokenize(src, func(t token) {
+		switch t.kind {
+		case textToken:
+			text := t.text
+			if pre == 0 {
+				text = collapseSpace(text)
+				if strings.HasSuffix(b.String(), "\n") || b.Len() == 0 {
+					text = strings.TrimLeft(text, " ")
+				}
+			}
+			b.WriteString(text)
+			if href != "" {
+				linkText.WriteString(text)
+			}
+		case startToken:
+			switch {
+			case t.name == "br":
+				b.WriteString("\n")
+			case t.name == "li":
+				newline()
+				b.WriteString("- ")
+			case t.name == "a":
+				href, _ = SafeURL(t.attrs["href"])
+				linkText.Reset()
+			case paragraphTags[t.name]:
+				paragraph()
+			case blockTags[t.name]:
+				newline()
+			}
+			if t.name == "pre" {
+				pre++
+			}
+		case endToken:
+			switch {
+			case t.name == "a":
+				target := strings.TrimPrefix(href, "mailto:")
+				if href != "" && strings.TrimSpace(linkText.String()) != target {
+					b.WriteString(" (" + target + ")")
+				}
+				href = ""
+			case paragraphTags[t.name]:
+				paragraph()
+			case blockTags[t.name]:
+				newline()
+			}
+			if t.name == "pre" && pre > 0 {
+				pre--
+			}
+		}
+	})
+	lines := strings.Split(b.String(), "\n")
+	var out []string
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// collapseSpace folds runs of HTML whitespace into single spaces.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	if s == "" || len(s) > 4 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package richtext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeRemovesScriptAndHandlers(t *testing.T) {
+	for input, want := range map[string]string{
+		`<p onclick="x()">Hi <b>there</b></p>`:              `<p>Hi <b>there</b></p>`,
+		`<img src=x onerror=alert(1)>text`:                  `text`,
+		`<script>alert(1)</script>after`:                    `after`,
+		`<SCRIPT >alert(1)</script >after`:                  `after`,
+		`<a href="javascript:alert(1)">x</a>`:               `<a>x</a>`,
+		`<a href=" JaVaScRiPt:alert(1)">x</a>`:              `<a>x</a>`,
+		`<a href="https://example.com/?a=1&amp;b=2">x</a>`:  `<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener noreferrer">x</a>`,
+		`<style>p{}</style><p>ok`:                           `<p>ok</p>`,
+		`</div>stray<i>open`:                                `stray<i>open</i>`,
+		`a < b & c`:                                         `a &lt; b &amp; c`,
+		`<!-- hidden --><svg><script>x</script></svg>shown`: `shown`,
+		`<td colspan="2" style="x">c</td>`:                  `<td colspan="2">c</td>`,
+	} {
+		if got := Sanitize(input); got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	for input, want := range map[string]string{
+		"Hello **world** and _you_":      `<p>Hello <strong>world</strong> and <em>you</em></p>`,
+		"line one\nline two":             `<p>line one<br>line two</p>`,
+		"# Agenda\n\n- one\n- two":       `<h1>Agenda</h1><ul><li>one</li><li>two</li></ul>`,
+		"3. c\n4. d":                     `<ol start="3"><li>c</li><li>d</li></ol>`,
+		"> quoted":                       `<blockquote><p>quoted</p></blockquote>`,
+		"```\n<b>x</b>\n```":             `<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>`,
+		"[docs](https://example.com)":    `<p><a href="https://example.com" rel="nofollow noopener noreferrer">docs</a></p>`,
+		"[bad](javascript:alert(1))":     `<p>bad</p>`,
+		"see https://example.com/a.":     `<p>see <a href="https://example.com/a" rel="nofollow noopener noreferrer">https://example.com/a</a>.</p>`,
+		"<script>alert(1)</script>":      `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		"2 * 3 * 4 and snake_case_name":  `<p>2 * 3 * 4 and snake_case_name</p>`,
+		"`a*b*c` ~~gone~~ \\*literal\\*": `<p><code>a*b*c</code> <del>gone</del> *literal*</p>`,
+	} {
+		if got := Markdown(input); got != want {
+			t.Errorf("Markdown(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText(`<h1>Agenda</h1><p>Join   <a href="https://example.com/call">the call</a>.</p><ul><li>one</li><li>two<br>more</li></ul><pre>a
+  b</pre><p>&lt;done&gt;</p>`)
+	want := strings.Join([]string{
+		"Agenda",
+		"",
+		"Join the call (https://example.com/call).",
+		"",
+		"- one",
+		"- two",
+		"more",
+		"",
+		"a",
+		"  b",
+		"",
+		"<done>",
+	}, "\n")
+	if got != want {
+		t.Fatalf("PlainText() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package richtext
+
+import (
+	"html"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startToken
+	endToken
+)
+
+// token is a run of text, with entities decoded, or a start or end tag
+// with a lower-case name and attributes.
+type token struct {
+	kind        tokenKind
+	text        string
+	name        string
+	attrs       map[string]string
+	selfClosing bool
+}
+
+// tokenize splits src into tokens for emit. It is lenient the way browsers
+// are: a "<" that does not begin a tag is text, comments and declarations
+// are skipped, and the content of droppedTags is skipped along with them.
+func tokenize(src string, emit func(token)) {
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			emit(token{kind: textToken, text: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(src); {
+		if src[i] != '<' {
+			j := strings.IndexByte(src[i:], '<')
+			if j < 0 {
+				j = len(src) - i
+			}
+			text.WriteString(src[i : i+j])
+			i += j
+			continue
+		}
+		rest := src[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			flush()
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			flush()
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return
+			}
+			i += end + 1
+			continue
+		}
+		t, n, ok := parseTag(rest)
+		if !ok {
+			text.WriteByte('<')
+			i++
+			continue
+		}
+		flush()
+		i += n
+		if t.kind == startToken && droppedTags[t.name] && !t.selfClosing {
+			i += skipElement(src[i:], t.name)
+			continue
+		}
+		emit(t)
+	}
+	flush()
+}
+
+// skipElement returns the length of src up to and including the end tag
+// closing name, or all of src when it is never closed.
+func skipElement(src, name string) int {
+	lower := strings.ToLower(src)
+	for from := 0; ; {
+		j := strings.Index(lower[from:], "</"+name)
+		if j < 0 {
+			return len(src)
+		}
+		j += from
+		after := j + 2 + len(name)
+		if after == len(src) || !isNameByte(src[after]) {
+			if end := strings.IndexByte(src[after:], '>'); end >= 0 {
+				return after + end + 1
+			}
+			return len(src)
+		}
+		from = after
+	}
+}
+
+// parseTag parses the tag at the start of src, returning it and its length.
+func parseTag(src string) (token, int, bool) {
+	i := 1
+	t := token{kind: startToken}
+	if i < len(src) && src[i] == '/' {
+		t.kind = endToken
+		i++
+	}
+	start := i
+	if i >= len(src) || !isLetter(src[i]) {
+		return t, 0, false
+	}
+	for i < len(src) && isNameByte(src[i]) {
+		i++
+	}
+	t.name = strings.ToLower(src[start:i])
+	for {
+		for i < len(src) && isTagSpace(src[i]) {
+			i++
+		}
+		if i >= len(src) {
+			return t, 0, false
+		}
+		switch src[i] {
+		case '>':
+			return t, i + 1, true
+		case '/':
+			t.selfClosing = true
+			i++
+			continue
+		}
+		nameStart := i
+		for i < len(src) && !isTagSpace(src[i]) && src[i] != '=' && src[i] != '>' && src[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(src[nameStart:i])
+		for i < len(src) && isTagSpace(src[i]) {
+			i++
+		}
+		value := ""
+		if i < len(src) && src[i] == '=' {
+			i++
+			for i < len(src) && isTagSpace(src[i]) {
+				i++
+			}
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+				quote := src[i]
+				end := strings.IndexByte(src[i+1:], quote)
+				if end < 0 {
+					return t, 0, false
+				}
+				value = src[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(src) && !isTagSpace(src[i]) && src[i] != '>' {
+					i++
+				}
+				value = src[valueStart:i]
+			}
+		}
+		if name == "" {
+			i++
+			continue
+		}
+		if t.attrs == nil {
+			t.attrs = make(map[string]string)
+		}
+		if _, seen := t.attrs[name]; !seen {
+			t.attrs[name] = html.UnescapeString(value)
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameByte(c byte) bool {
+	return isLetter(c) || c >= '0' && c <= '9' || c == '-' || c == ':' || c == '_'
+}
+
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,198 @@
+// Package rsvp lets invitees without an account answer an invitation in a
+// browser. Each attendee gets a signed link naming the event and their
+// address; following it records their PARTSTAT and sends the organizer an
+// iMIP REPLY.
+package rsvp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Path is where RSVP links point, followed by the token.
+const Path = "/rsvp/"
+
+// linkGrace is how long after its event ends an RSVP link keeps working, so
+// that late answers still reach the organizer.
+const linkGrace = 7 * 24 * time.Hour
+
+// ErrInvalidLink is returned for a token that is malformed, carries a bad
+// signature, or names an event the attendee is no longer invited to.
+var ErrInvalidLink = errors.New("rsvp: invalid or expired link")
+
+// Invitation is the event an RSVP link answers, as its attendee sees it.
+type Invitation struct {
+	CalendarID int64
+	UID        string
+	Attendee   string
+	Event      *store.Event
+	// Partstat is the attendee's current answer, e.g. NEEDS-ACTION.
+	Partstat string
+	// Notified reports whether the organizer was emailed the reply.
+	Notified bool
+}
+
+// sender delivers reply email; *mail.Mailer implements it.
+type sender interface {
+	Send(msg mail.Message) error
+}
+
+// Service signs RSVP links and records the answers given through them.
+type Service struct {
+	store   *store.Store
+	events  *events.Service
+	mailer  sender
+	baseURL string
+	key     []byte
+	now     func() time.Time
+}
+
+// NewService returns an RSVP service signing with the session secret. A
+// nil mailer records answers without telling the organizer.
+func NewService(cfg *config.Config, st *store.Store, mailer *mail.Mailer) *Service {
+	s := &Service{store: st, events: events.NewService(st), now: time.Now}
+	if cfg != nil {
+		s.baseURL = strings.TrimRight(cfg.BaseURL, "/")
+		s.key = []byte(cfg.Session.Secret)
+	}
+	if mailer != nil {
+		s.mailer = mailer
+	}
+	return s
+}
+
+// URL returns the attendee's RSVP link for the event calendarID/uid, which
+// ends at end. The link expires linkGrace after that.
+func (s *Service) URL(calendarID int64, uid, attendee string, end time.Time) string {
+	expires := end.Add(linkGrace).Unix()
+	payload := strconv.FormatInt(expires, 10) + "\n" + strconv.FormatInt(calendarID, 10) + "\n" + uid + "\n" + strings.ToLower(strings.TrimSpace(attendee))
+	enc := base64.RawURLEncoding
+	return s.baseURL + Path + enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(s.sign(payload))
+}
+
+// Lookup resolves a link's token to the invitation it answers.
+func (s *Service) Lookup(ctx context.Context, token string) (*Invitation, error) {
+	inv, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.store.Events.GetByUID(ctx, inv.CalendarID, inv.UID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, ErrInvalidLink
+	}
+	inv.Partstat = events.AttendeePartstat(event.RawICAL, inv.Attendee)
+	if inv.Partstat == "" {
+		return nil, ErrInvalidLink
+	}
+	inv.Event = event
+	return inv, nil
+}
+
+// Respond records partstat (ACCEPTED, TENTATIVE or DECLINED) as the link's
+// attendee's answer and emails the organizer an iMIP REPLY when it changed.
+func (s *Service) Respond(ctx context.Context, token, partstat string) (*Invitation, error) {
+	inv, err := s.Lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	partstat = strings.ToUpper(strings.TrimSpace(partstat))
+	previous := inv.Partstat
+	event, err := s.events.SetAttendeeResponse(ctx, inv.CalendarID, inv.UID, inv.Attendee, partstat)
+	if errors.Is(err, events.ErrNotFound) {
+		return nil, ErrInvalidLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	inv.Event, inv.Partstat = event, partstat
+	if previous != partstat {
+		inv.Notified = s.notifyOrganizer(ctx, inv)
+	}
+	return inv, nil
+}
+
+// notifyOrganizer emails the organizer the attendee's reply, in their
+// language when they have an account here.
+func (s *Service) notifyOrganizer(ctx context.Context, inv *Invitation) bool {
+	organizer := events.OrganizerAddress(inv.Event.RawICAL)
+	if s.mailer == nil || organizer == "" {
+		return false
+	}
+	var user *store.User
+	if s.store.Users != nil {
+		user, _ = s.store.Users.GetByEmail(ctx, organizer)
+	}
+	prefs := locale.ForUser(user)
+	summary := prefs.T("rsvp.yourEvent")
+	if inv.Event.Summary != nil && *inv.Event.Summary != "" {
+		summary = *inv.Event.Summary
+	}
+	err := s.mailer.Send(mail.Message{
+		To:       []string{organizer},
+		Subject:  prefs.T("rsvp.subject."+replyPartstat(inv.Partstat), summary),
+		Text:     prefs.T("rsvp.text."+replyPartstat(inv.Partstat), inv.Attendee, summary),
+		Calendar: events.ReplyCalendar(inv.Event.RawICAL, inv.Attendee),
+		Method:   "REPLY",
+	})
+	return err == nil
+}
+
+func (s *Service) parse(token string) (*Invitation, error) {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok || len(s.key) == 0 {
+		return nil, ErrInvalidLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, s.sign(string(payload))) {
+		return nil, ErrInvalidLink
+	}
+	parts := strings.SplitN(string(payload), "\n", 4)
+	if len(parts) != 4 {
+		return nil, ErrInvalidLink
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || !s.now().Before(time.Unix(expires, 0)) {
+		return nil, ErrInvalidLink
+	}
+	calendarID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || parts[2] == "" || parts[3] == "" {
+		return nil, ErrInvalidLink
+	}
+	return &Invitation{CalendarID: calendarID, UID: parts[2], Attendee: parts[3]}, nil
+}
+
+func (s *Service) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("rsvp\n" + payload))
+	return mac.Sum(nil)
+}
+
+// replyPartstat returns the answer partstat stands for: ACCEPTED, TENTATIVE
+// or DECLINED.
+func replyPartstat(partstat string) string {
+	switch partstat {
+	case "ACCEPTED", "TENTATIVE":
+		return partstat
+	default:
+		return "DECLINED"
+	}
+}
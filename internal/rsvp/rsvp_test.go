@@ -0,0 +1,143 @@
+package rsvp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeEvents struct {
+	store.EventRepository
+	event *store.Event
+}
+
+func (f *fakeEvents) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
+	if f.event == nil || f.event.CalendarID != calendarID || f.event.UID != uid {
+		return nil, nil
+	}
+	ev := *f.event
+	return &ev, nil
+}
+
+func (f *fakeEvents) GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, error) {
+	return f.GetByUID(ctx, calendarID, resourceName)
+}
+
+func (f *fakeEvents) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
+	summary := "Planning"
+	event.Summary = &summary
+	f.event = &event
+	return &event, nil
+}
+
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (f *fakeSender) Send(msg mail.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// eventEnd is when the test event ends.
+var eventEnd = time.Date(2030, 1, 5, 10, 0, 0, 0, time.UTC)
+
+func newTestService() (*Service, *fakeEvents, *fakeSender) {
+	events := &fakeEvents{event: &store.Event{
+		CalendarID: 1, UID: "plan", ResourceName: "plan", ETag: "v1",
+		RawICAL: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:plan\r\nSUMMARY:Planning\r\n" +
+			"DTSTART:20300105T090000Z\r\nORGANIZER:mailto:owner@example.com\r\n" +
+			"ATTENDEE;CN=\"Guest; Jr\";PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:guest@example.com\r\n" +
+			"ATTENDEE;PARTSTAT=ACCEPTED:mailto:other@example.com\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}}
+	cfg := &config.Config{BaseURL: "https://cal.example.com/"}
+	cfg.Session.Secret = strings.Repeat("s", 32)
+	svc := NewService(cfg, &store.Store{Events: events}, nil)
+	svc.now = func() time.Time { return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC) }
+	sender := &fakeSender{}
+	svc.mailer = sender
+	return svc, events, sender
+}
+
+func TestRespondRecordsAnswerAndRepliesToOrganizer(t *testing.T) {
+	svc, events, sender := newTestService()
+	link := svc.URL(1, "plan", "Guest@Example.com", eventEnd)
+	if !strings.HasPrefix(link, "https://cal.example.com/rsvp/") {
+		t.Fatalf("unexpected link %q", link)
+	}
+	token := strings.TrimPrefix(link, "https://cal.example.com/rsvp/")
+
+	inv, err := svc.Lookup(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if inv.Attendee != "guest@example.com" || inv.Partstat != "NEEDS-ACTION" {
+		t.Fatalf("unexpected invitation %+v", inv)
+	}
+
+	inv, err = svc.Respond(context.Background(), token, "declined")
+	if err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if !inv.Notified || inv.Partstat != "DECLINED" {
+		t.Fatalf("unexpected invitation %+v", inv)
+	}
+	if !strings.Contains(events.event.RawICAL, "ATTENDEE;CN=\"Guest; Jr\";PARTSTAT=DECLINED;RSVP=TRUE:mailto:guest@example.com") {
+		t.Fatalf("expected guest declined, got %s", events.event.RawICAL)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected one reply, got %d", len(sender.sent))
+	}
+	reply := sender.sent[0]
+	if reply.To[0] != "owner@example.com" || reply.Method != "REPLY" || !strings.Contains(reply.Calendar, "METHOD:REPLY\r\n") {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+	if strings.Contains(reply.Calendar, "other@example.com") {
+		t.Fatalf("expected other attendees left out of the reply, got %s", reply.Calendar)
+	}
+
+	// Answering the same way again does not email the organizer twice.
+	if _, err := svc.Respond(context.Background(), token, "DECLINED"); err != nil || len(sender.sent) != 1 {
+		t.Fatalf("repeat answer: err %v, %d emails", err, len(sender.sent))
+	}
+}
+
+func TestLookupRejectsForgedLinks(t *testing.T) {
+	svc, _, _ := newTestService()
+	token := strings.TrimPrefix(svc.URL(1, "plan", "guest@example.com", eventEnd), "https://cal.example.com/rsvp/")
+	payload, sig, _ := strings.Cut(token, ".")
+
+	other := strings.TrimPrefix(svc.URL(1, "plan", "stranger@example.com", eventEnd), "https://cal.example.com/rsvp/")
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	for name, candidate := range map[string]string{
+		"swapped payload": otherPayload + "." + sig,
+		"no signature":    payload,
+		"garbage":         "not-a-token",
+		"not invited":     other,
+	} {
+		if _, err := svc.Lookup(context.Background(), candidate); !errors.Is(err, ErrInvalidLink) {
+			t.Fatalf("%s: error = %v, want ErrInvalidLink", name, err)
+		}
+	}
+}
+
+func TestLookupRejectsExpiredLinks(t *testing.T) {
+	svc, _, _ := newTestService()
+	token := strings.TrimPrefix(svc.URL(1, "plan", "guest@example.com", eventEnd), "https://cal.example.com/rsvp/")
+
+	svc.now = func() time.Time { return eventEnd.Add(linkGrace - time.Minute) }
+	if _, err := svc.Lookup(context.Background(), token); err != nil {
+		t.Fatalf("Lookup() within the grace period error = %v", err)
+	}
+	svc.now = func() time.Time { return eventEnd.Add(linkGrace) }
+	if _, err := svc.Lookup(context.Background(), token); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("Lookup() of an expired link error = %v, want ErrInvalidLink", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package scan passes uploaded files through a malware scanner before they
+// are stored: a clamd daemon, spoken to over its INSTREAM protocol, or any
+// command that reads the file on standard input.
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+// ErrMalformedAttachment reports an inline attachment whose base64 content
+// cannot be decoded, and so cannot be scanned.
+var ErrMalformedAttachment = errors.New("scan: malformed inline attachment")
+
+// InfectedError reports a file the scanner found malware in.
+type InfectedError struct {
+	// Signature names what was found, such as "Eicar-Test-Signature".
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return "scan: infected with " + e.Signature
+}
+
+// Scanner checks a file for malware. Scan returns nil for a clean file, an
+// *InfectedError for an infected one, and any other error when the file
+// could not be scanned.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+// New returns the scanner cfg selects, or nil when scanning is off.
+func New(cfg *config.Config) Scanner {
+	switch {
+	case cfg == nil:
+		return nil
+	case cfg.Scan.ClamdAddress != "":
+		return &clamd{address: cfg.Scan.ClamdAddress, timeout: cfg.Scan.Timeout}
+	case cfg.Scan.Command != "":
+		return &command{args: strings.Fields(cfg.Scan.Command), timeout: cfg.Scan.Timeout}
+	}
+	return nil
+}
+
+// ICalAttachments scans every inline binary ATTACH property of ical, those
+// with ENCODING=BASE64, stopping at the first that is infected or cannot be
+// scanned. A nil scanner accepts everything.
+func ICalAttachments(ctx context.Context, s Scanner, ical string) error {
+	if s == nil {
+		return nil
+	}
+	for _, line := range unfoldLines(ical) {
+		name, params, value, ok := splitContentLine(line)
+		if !ok || name != "ATTACH" || !strings.Contains(strings.ToUpper(params), "ENCODING=BASE64") {
+			continue
+		}
+		data, err := decodeBase64(value)
+		if err != nil {
+			return ErrMalformedAttachment
+		}
+		if err := s.Scan(ctx, bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unfoldLines splits ical into content lines, joining folded ones. Base64
+// values ignore the whitespace a fold leaves behind.
+func unfoldLines(ical string) []string {
+	ical = strings.ReplaceAll(ical, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(ical, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitContentLine splits a content line into its upper-cased name, its
+// parameters and its value, honouring quoted parameter values.
+func splitContentLine(line string) (string, string, string, bool) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ':' && !quoted:
+			name, params, _ := strings.Cut(line[:i], ";")
+			return strings.ToUpper(strings.TrimSpace(name)), params, line[i+1:], true
+		}
+	}
+	return "", "", "", false
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, value)
+	if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// clamd scans with a clamd daemon over its INSTREAM command.
+type clamd struct {
+	address string
+	timeout time.Duration
+}
+
+// clamdChunk is the size of the chunks streamed to clamd.
+const clamdChunk = 64 << 10
+
+func (c *clamd) Scan(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	network, address := "tcp", c.address
+	if rest, ok := strings.CutPrefix(address, "unix:"); ok {
+		network, address = "unix", rest
+	} else if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return fmt.Errorf("scan: connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("scan: send to clamd: %w", err)
+	}
+	buf := make([]byte, 4+clamdChunk)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd hangs up on streams over its StreamMaxLength; its
+				// reply says so.
+				break
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			_, _ = conn.Write([]byte{0, 0, 0, 0})
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("scan: read file: %w", readErr)
+		}
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 4096))
+	if err != nil && len(reply) == 0 {
+		return fmt.Errorf("scan: read clamd reply: %w", err)
+	}
+	return parseClamdReply(string(reply))
+}
+
+// parseClamdReply interprets a reply such as "stream: OK" or "stream:
+// Eicar-Test-Signature FOUND".
+func parseClamdReply(reply string) error {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00\n"))
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &InfectedError{Signature: strings.TrimSuffix(result, " FOUND")}
+	case result == "":
+		return errors.New("scan: clamd closed the connection without a reply")
+	}
+	return fmt.Errorf("scan: clamd: %s", result)
+}
+
+// command scans by running an external program, such as
+// "clamdscan --no-summary -", with the file on standard input.
+type command struct {
+	args    []string
+	timeout time.Duration
+}
+
+func (c *command) Scan(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stdin = r
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && ctx.Err() == nil:
+		signature, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+		// clamscan prints "stdin: Eicar-Test-Signature FOUND".
+		if _, rest, ok := strings.Cut(signature, ": "); ok {
+			signature = rest
+		}
+		signature = strings.TrimSuffix(strings.TrimSpace(signature), " FOUND")
+		if signature == "" {
+			signature = "malware"
+		}
+		return &InfectedError{Signature: signature}
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("scan: %s: %w: %s", c.args[0], err, msg)
+	}
+	return fmt.Errorf("scan: %s: %w", c.args[0], err)
+}
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands on a Unix socket, finding the EICAR
+// test string.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if binary.Read(r, binary.BigEndian, &size) != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					io.CopyN(&data, r, int64(size))
+				}
+				if strings.Contains(data.String(), "EICAR-STANDARD") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return socket
+}
+
+func TestClamdScanner(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Scan.ClamdAddress = "unix:" + fakeClamd(t)
+	cfg.Scan.Timeout = 5 * time.Second
+	s := New(cfg)
+
+	if err := s.Scan(context.Background(), strings.NewReader(strings.Repeat("clean ", 30000))); err != nil {
+		t.Fatalf("Scan() of a clean file = %v", err)
+	}
+	var infected *InfectedError
+	if err := s.Scan(context.Background(), strings.NewReader(eicar)); !errors.As(err, &infected) || infected.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("Scan() of EICAR = %v", err)
+	}
+
+	cfg.Scan.ClamdAddress = filepath.Join(t.TempDir(), "missing.sock")
+	if err := New(cfg).Scan(context.Background(), strings.NewReader("x")); err == nil || errors.As(err, &infected) {
+		t.Fatalf("Scan() without clamd = %v, want a scan failure", err)
+	}
+}
+
+func TestCommandScanner(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "scan.sh")
+	body := "#!/bin/sh\nif grep -q EICAR; then echo 'stdin: Eicar-Test-Signature FOUND'; exit 1; fi\nexit 0\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Scan.Command = script + " --no-summary"
+	cfg.Scan.Timeout = 5 * time.Second
+	s := New(cfg)
+
+	if err := s.Scan(context.Background(), strings.NewReader("clean")); err != nil {
+		t.Fatalf("Scan() of a clean file = %v", err)
+	}
+	var infected *InfectedError
+	if err := s.Scan(context.Background(), strings.NewReader(eicar)); !errors.As(err, &infected) || infected.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("Scan() of EICAR = %v", err)
+	}
+	cfg.Scan.Command = "/bin/false-and-missing"
+	if err := New(cfg).Scan(context.Background(), strings.NewReader("x")); err == nil || errors.As(err, &infected) {
+		t.Fatalf("Scan() with a missing command = %v, want a scan failure", err)
+	}
+	if New(&config.Config{}) != nil {
+		t.Fatal("New() without a scanner configured is not nil")
+	}
+}
+
+type recordingScanner struct{ scanned []string }
+
+func (r *recordingScanner) Scan(_ context.Context, f io.Reader) error {
+	data, _ := io.ReadAll(f)
+	r.scanned = append(r.scanned, string(data))
+	if strings.Contains(string(data), "EICAR") {
+		return &InfectedError{Signature: "Eicar-Test-Signature"}
+	}
+	return nil
+}
+
+func TestICalAttachmentsScansInlineBinaries(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("hello attachment"))
+	ical := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:a\r\n" +
+		"ATTACH:https://example.com/agenda.pdf\r\n" +
+		"ATTACH;FMTTYPE=text/plain;ENCODING=BASE64;VALUE=BINARY:" + encoded[:10] + "\r\n " + encoded[10:] + "\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	s := &recordingScanner{}
+	if err := ICalAttachments(context.Background(), s, ical); err != nil || len(s.scanned) != 1 || s.scanned[0] != "hello attachment" {
+		t.Fatalf("ICalAttachments() = %v, scanned %q", err, s.scanned)
+	}
+
+	infected := strings.Replace(ical, encoded[:10]+"\r\n "+encoded[10:], base64.StdEncoding.EncodeToString([]byte(eicar)), 1)
+	var infectedErr *InfectedError
+	if err := ICalAttachments(context.Background(), s, infected); !errors.As(err, &infectedErr) {
+		t.Fatalf("ICalAttachments() of EICAR = %v", err)
+	}
+	malformed := strings.Replace(ical, encoded[:10], "!!not base64", 1)
+	if err := ICalAttachments(context.Background(), s, malformed); !errors.Is(err, ErrMalformedAttachment) {
+		t.Fatalf("ICalAttachments() of bad base64 = %v", err)
+	}
+	if err := ICalAttachments(context.Background(), nil, infected); err != nil {
+		t.Fatalf("ICalAttachments() without a scanner = %v", err)
+	}
+}
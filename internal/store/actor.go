@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context whose writes to events and contacts are
+// recorded in the change feed as made by the user userID.
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// actorFrom returns the user ctx acts for, or zero.
+func actorFrom(ctx context.Context) int64 {
+	id, _ := ctx.Value(actorKey{}).(int64)
+	return id
+}
+
+// actorPool tells the database who each transaction acts for, through the
+// transaction-local calcard.actor setting that the change triggers read.
+type actorPool struct {
+	dbPool
+}
+
+func (p actorPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := p.dbPool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := setActor(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+func setActor(ctx context.Context, tx *sql.Tx) error {
+	actor := actorFrom(ctx)
+	if actor == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `SELECT set_config('calcard.actor', $1, true)`, strconv.FormatInt(actor, 10))
+	return err
+}
+
+// rowWriter is the pool or a transaction.
+type rowWriter interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// attributed runs a single write through pool, wrapped in a transaction
+// when ctx acts for a user so that actorPool can record the change as
+// theirs.
+func attributed(ctx context.Context, pool dbPool, write func(db rowWriter) error) error {
+	if actorFrom(ctx) == 0 {
+		return write(pool)
+	}
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := write(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -3,12 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 )
 
 func TestAppPasswordRepoCRUDAndQueries(t *testing.T) {
@@ -24,46 +26,48 @@ func TestAppPasswordRepoCRUDAndQueries(t *testing.T) {
 	lastUsed := now.Add(time.Hour)
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-INSERT INTO app_passwords (user_id, label, token_hash, expires_at)
-VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at
+INSERT INTO app_passwords (user_id, label, token_hash, expires_at, api_role)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role
 `)).
-		WithArgs(int64(7), "Laptop", "hash", &expires).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "label", "token_hash", "created_at", "expires_at", "revoked_at", "last_used_at"}).
-			AddRow(int64(1), int64(7), "Laptop", "hash", now, expires, nil, nil))
+		WithArgs(int64(7), "Laptop", "hash", &expires, APIRoleViewer).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "label", "token_hash", "created_at", "expires_at", "revoked_at", "last_used_at", "last_used_user_agent", "last_used_ip", "api_role"}).
+			AddRow(int64(1), int64(7), "Laptop", "hash", now, expires, nil, nil, nil, nil, APIRoleViewer))
 
 	created, err := repo.Create(context.Background(), AppPassword{
 		UserID:    7,
 		Label:     "Laptop",
 		TokenHash: "hash",
 		ExpiresAt: &expires,
+		APIRole:   APIRoleViewer,
 	})
 	if err != nil {
 		t.Fatalf("Create() error = %v", err)
 	}
-	if created.ID != 1 || created.ExpiresAt == nil || !created.ExpiresAt.Equal(expires) {
+	if created.ID != 1 || created.ExpiresAt == nil || !created.ExpiresAt.Equal(expires) || created.APIRole != APIRoleViewer {
 		t.Fatalf("Create() = %#v", created)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at
+SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role
 FROM app_passwords
 WHERE user_id=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
 ORDER BY created_at DESC
 `)).
 		WithArgs(int64(7)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "label", "token_hash", "created_at", "expires_at", "revoked_at", "last_used_at"}).
-			AddRow(int64(1), int64(7), "Laptop", "hash", now, expires, nil, lastUsed))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "label", "token_hash", "created_at", "expires_at", "revoked_at", "last_used_at", "last_used_user_agent", "last_used_ip", "api_role"}).
+			AddRow(int64(1), int64(7), "Laptop", "hash", now, expires, nil, lastUsed, "DAVx5/4.3", "203.0.113.7", APIRoleAdmin))
 
 	found, err := repo.FindValidByUser(context.Background(), 7)
 	if err != nil {
 		t.Fatalf("FindValidByUser() error = %v", err)
 	}
-	if len(found) != 1 || found[0].LastUsedAt == nil || !found[0].LastUsedAt.Equal(lastUsed) {
+	if len(found) != 1 || found[0].LastUsedAt == nil || !found[0].LastUsedAt.Equal(lastUsed) ||
+		found[0].LastUsedUserAgent == nil || *found[0].LastUsedUserAgent != "DAVx5/4.3" || found[0].LastUsedIP == nil || *found[0].LastUsedIP != "203.0.113.7" {
 		t.Fatalf("FindValidByUser() = %#v", found)
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at FROM app_passwords WHERE id=$1`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role FROM app_passwords WHERE id=$1`)).
 		WithArgs(int64(9)).
 		WillReturnError(sql.ErrNoRows)
 
@@ -89,10 +93,10 @@ ORDER BY created_at DESC
 		t.Fatalf("DeleteRevoked() error = %v", err)
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE app_passwords SET last_used_at = NOW() WHERE id=$1`)).
-		WithArgs(int64(1)).
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE app_passwords SET last_used_at = NOW(), last_used_user_agent = COALESCE(NULLIF($2, ''), last_used_user_agent), last_used_ip = COALESCE(NULLIF($3, ''), last_used_ip) WHERE id=$1`)).
+		WithArgs(int64(1), "DAVx5/4.3", "203.0.113.7").
 		WillReturnResult(sqlmock.NewResult(0, 1))
-	if err := repo.TouchLastUsed(context.Background(), 1); err != nil {
+	if err := repo.TouchLastUsed(context.Background(), 1, "DAVx5/4.3", "203.0.113.7"); err != nil {
 		t.Fatalf("TouchLastUsed() error = %v", err)
 	}
 
@@ -142,6 +146,308 @@ func TestDeletedResourceRepoListAndCleanup(t *testing.T) {
 	}
 }
 
+func TestHeldDeletionRepoHoldListAndRemove(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &heldDeletionRepo{pool: db}
+	heldAt := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO held_deletions (resource_type, collection_id, uid, resource_name, owner_id, deleted_by, client)`)).
+		WithArgs("event", int64(4), "uid-1", "uid-1.ics", int64(1), int64(2), "DAVx5/4.3").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := repo.Hold(context.Background(), HeldDeletion{ResourceType: "event", CollectionID: 4, UID: "uid-1", ResourceName: "uid-1.ics", OwnerID: 1, DeletedBy: 2, Client: "DAVx5/4.3"}); err != nil {
+		t.Fatalf("Hold() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM held_deletions WHERE resource_type=$1 AND collection_id=$2 AND uid=$3)`)).
+		WithArgs("event", int64(4), "uid-1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	held, err := repo.IsHeld(context.Background(), "event", 4, "uid-1")
+	if err != nil || !held {
+		t.Fatalf("IsHeld() = %v, %v", held, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM held_deletions h`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "resource_type", "collection_id", "uid", "resource_name", "owner_id", "deleted_by", "client", "held_at"}).
+			AddRow(int64(9), "event", int64(4), "uid-1", "uid-1.ics", int64(1), int64(2), "DAVx5/4.3", heldAt))
+	items, err := repo.ListByOwner(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ListByOwner() error = %v", err)
+	}
+	if len(items) != 1 || items[0].ID != 9 || items[0].Client != "DAVx5/4.3" {
+		t.Fatalf("ListByOwner() = %#v", items)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM held_deletions WHERE owner_id=$1 AND id = ANY($2)`)).
+		WithArgs(int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Remove(context.Background(), 1, []int64{9}); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := repo.Remove(context.Background(), 1, nil); err != nil {
+		t.Fatalf("Remove(nil) error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestCollectionSyncRepoRecordListAndUsage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &collectionSyncRepo{pool: db}
+	appPasswordID := int64(7)
+	syncedAt := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO collection_syncs (collection_type, collection_id, user_id, device_key, app_password_id, user_agent)`)).
+		WithArgs("calendar", int64(4), int64(1), "app-password:7", &appPasswordID, "DAVx5/4.3").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := repo.Record(context.Background(), CollectionSync{CollectionType: "calendar", CollectionID: 4, UserID: 1, DeviceKey: "app-password:7", AppPasswordID: &appPasswordID, UserAgent: "DAVx5/4.3"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	columns := []string{"collection_type", "collection_id", "user_id", "device_key", "app_password_id", "label", "user_agent", "last_synced_at", "sync_count"}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE s.user_id=$1 AND s.collection_type=$2 AND s.collection_id=$3 ORDER BY s.last_synced_at DESC`)).
+		WithArgs(int64(1), "calendar", int64(4)).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("calendar", int64(4), int64(1), "app-password:7", int64(7), "Tablet", "DAVx5/4.3", syncedAt, int64(3)).
+			AddRow("calendar", int64(4), int64(1), "ua:curl/8", nil, "", "curl/8", syncedAt, int64(1)))
+	syncs, err := repo.ListForCollection(context.Background(), 1, "calendar", 4)
+	if err != nil {
+		t.Fatalf("ListForCollection() error = %v", err)
+	}
+	if len(syncs) != 2 || syncs[0].AppPasswordID == nil || *syncs[0].AppPasswordID != 7 || syncs[0].AppPasswordLabel != "Tablet" || syncs[1].AppPasswordID != nil {
+		t.Fatalf("ListForCollection() = %#v", syncs)
+	}
+
+	since := syncedAt.Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE s.user_id=$1 AND s.last_synced_at >= $2`)).
+		WithArgs(int64(1), since).
+		WillReturnRows(sqlmock.NewRows(columns))
+	if syncs, err := repo.ListByUser(context.Background(), 1, since); err != nil || len(syncs) != 0 {
+		t.Fatalf("ListByUser() = %#v, %v", syncs, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*), COALESCE(SUM(octet_length(COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)))), 0) FROM contacts WHERE address_book_id=$1`)).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "size"}).AddRow(int64(12), int64(4096)))
+	usage, err := repo.Usage(context.Background(), "addressbook", 5)
+	if err != nil || usage.ResourceCount != 12 || usage.DataSize != 4096 {
+		t.Fatalf("Usage() = %#v, %v", usage, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestClientRepairRepoRecordAndList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &clientRepairRepo{pool: db}
+	seen := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`ON CONFLICT (user_id, device_key, repair) DO UPDATE`)).
+		WithArgs(int64(1), "ua:SloppyCal/1.0", nil, "SloppyCal/1.0", "prodid", "/dav/calendars/2/a.ics").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := repo.Record(context.Background(), ClientRepair{UserID: 1, DeviceKey: "ua:SloppyCal/1.0", UserAgent: "SloppyCal/1.0", Repair: "prodid", LastResource: "/dav/calendars/2/a.ics"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE c.user_id = $1 ORDER BY c.last_seen_at DESC, c.repair`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "device_key", "app_password_id", "label", "user_agent", "repair", "repair_count", "last_resource", "first_seen_at", "last_seen_at"}).
+			AddRow(int64(1), "app-password:7", int64(7), "Tablet", "DAVx5/4.3", "line-endings", int64(3), "/dav/calendars/2/b.ics", seen, seen))
+	repairs, err := repo.ListByUser(context.Background(), 1)
+	if err != nil || len(repairs) != 1 || repairs[0].AppPasswordID == nil || repairs[0].AppPasswordLabel != "Tablet" || repairs[0].Count != 3 {
+		t.Fatalf("ListByUser() = %#v, %v", repairs, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestCollectionSyncRepoChecksumIgnoresRowOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &collectionSyncRepo{pool: db}
+	query := regexp.QuoteMeta(`SELECT uid, etag FROM events WHERE calendar_id=$1`)
+	mock.ExpectQuery(query).WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"uid", "etag"}).AddRow("b", "2").AddRow("a", "1"))
+	mock.ExpectQuery(query).WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"uid", "etag"}).AddRow("a", "1").AddRow("b", "2"))
+	first, err := repo.Checksum(context.Background(), "calendar", 4)
+	if err != nil {
+		t.Fatalf("Checksum() error = %v", err)
+	}
+	second, err := repo.Checksum(context.Background(), "calendar", 5)
+	if err != nil || first != second || len(first) != 64 {
+		t.Fatalf("Checksum() = %q and %q, %v; want the same SHA-256 for the same pairs", first, second, err)
+	}
+	if ContentChecksum([][2]string{{"a", "1"}, {"b", "3"}}) == first {
+		t.Fatal("checksum unchanged after an ETag changed")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestFreeBusyLinkRepoUpsertLookupAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &freeBusyLinkRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"user_id", "token", "window_days", "created_at", "updated_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO freebusy_links (user_id, token, window_days)`)).
+		WithArgs(int64(1), "tok", 30).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), "tok", 30, now, now))
+	link, err := repo.Upsert(context.Background(), FreeBusyLink{UserID: 1, Token: "tok", WindowDays: 30})
+	if err != nil || link == nil || link.Token != "tok" || link.WindowDays != 30 {
+		t.Fatalf("Upsert() = %#v, %v", link, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, token, window_days, created_at, updated_at FROM freebusy_links WHERE token=$1`)).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	if link, err := repo.GetByToken(context.Background(), "missing"); err != nil || link != nil {
+		t.Fatalf("GetByToken() = %#v, %v, want nil", link, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, token, window_days, created_at, updated_at FROM freebusy_links WHERE user_id=$1`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), "tok", 30, now, now))
+	if link, err := repo.GetByUser(context.Background(), 1); err != nil || link == nil || link.UserID != 1 {
+		t.Fatalf("GetByUser() = %#v, %v", link, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM freebusy_links WHERE user_id=$1`)).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestTaskFeedLinkRepoUpsertLookupAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &taskFeedLinkRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"user_id", "token", "created_at", "updated_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO task_feed_links (user_id, token)`)).
+		WithArgs(int64(1), "tok").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), "tok", now, now))
+	link, err := repo.Upsert(context.Background(), TaskFeedLink{UserID: 1, Token: "tok"})
+	if err != nil || link == nil || link.Token != "tok" {
+		t.Fatalf("Upsert() = %#v, %v", link, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, token, created_at, updated_at FROM task_feed_links WHERE token=$1`)).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	if link, err := repo.GetByToken(context.Background(), "missing"); err != nil || link != nil {
+		t.Fatalf("GetByToken() = %#v, %v, want nil", link, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_id, token, created_at, updated_at FROM task_feed_links WHERE user_id=$1`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), "tok", now, now))
+	if link, err := repo.GetByUser(context.Background(), 1); err != nil || link == nil || link.UserID != 1 {
+		t.Fatalf("GetByUser() = %#v, %v", link, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM task_feed_links WHERE user_id=$1`)).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestBookingPageRepoCreateConflictAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &bookingPageRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"id", "user_id", "calendar_id", "slug", "title", "description", "duration_minutes", "buffer_minutes",
+		"notice_minutes", "window_days", "day_start_minute", "day_end_minute", "weekdays", "timezone", "created_at"}
+	page := BookingPage{UserID: 1, CalendarID: 2, Slug: "intro", Title: "Intro call", DurationMinutes: 30, NoticeMinutes: 60,
+		WindowDays: 30, DayStartMinute: 540, DayEndMinute: 1020, Weekdays: 62, Timezone: "UTC"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO booking_pages`)).
+		WithArgs(int64(1), int64(2), "intro", "Intro call", "", 30, 0, 60, 30, 540, 1020, 62, "UTC").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(5), int64(1), int64(2), "intro", "Intro call", "", 30, 0, 60, 30, 540, 1020, 62, "UTC", now))
+	created, err := repo.Create(context.Background(), page)
+	if err != nil || created == nil || created.ID != 5 || created.Slug != "intro" {
+		t.Fatalf("Create() = %#v, %v", created, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO booking_pages`)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "booking_pages_slug_key"})
+	if _, err := repo.Create(context.Background(), page); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Create() duplicate error = %v, want ErrConflict", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM booking_pages WHERE slug=$1`)).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	if got, err := repo.GetBySlug(context.Background(), "missing"); err != nil || got != nil {
+		t.Fatalf("GetBySlug() = %#v, %v, want nil", got, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM booking_pages WHERE id=$1 AND user_id=$2`)).
+		WithArgs(int64(5), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.Delete(context.Background(), 9, 5); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() other user error = %v, want ErrNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestSessionRepoCRUDQueriesAndNilOnMissing(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -204,10 +510,10 @@ RETURNING id, user_id, user_agent, ip_address, created_at, expires_at, last_seen
 		t.Fatalf("ListByUser() = %#v", list)
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET last_seen_at = NOW() WHERE id=$1`)).
-		WithArgs("session-1").
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE sessions SET last_seen_at = NOW(), expires_at = $2 WHERE id=$1`)).
+		WithArgs("session-1", expires).
 		WillReturnResult(sqlmock.NewResult(0, 1))
-	if err := repo.TouchLastSeen(context.Background(), "session-1"); err != nil {
+	if err := repo.TouchLastSeen(context.Background(), "session-1", expires); err != nil {
 		t.Fatalf("TouchLastSeen() error = %v", err)
 	}
 
@@ -240,6 +546,263 @@ RETURNING id, user_id, user_agent, ip_address, created_at, expires_at, last_seen
 	}
 }
 
+func TestDeletedResourceRepoListOrphaned(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &deletedResourceRepo{pool: db}
+	now := time.Now().UTC()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM deleted_resources d`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "resource_type", "collection_id", "uid", "resource_name", "deleted_at"}).
+			AddRow(int64(3), "event", int64(7), "uid-1", "uid-1", now).
+			AddRow(int64(4), "contact", int64(8), "uid-2", "card", now))
+	got, err := repo.ListOrphaned(context.Background())
+	if err != nil || len(got) != 2 || got[0].CollectionID != 7 || got[1].ResourceName != "card" {
+		t.Fatalf("ListOrphaned() = %#v, %v", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrphanRepoListAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &orphanRepo{pool: db}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = e.calendar_id)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"resource_type", "collection_id", "uid", "resource_name"}).
+			AddRow("contact", int64(8), "uid-2", "card").
+			AddRow("event", int64(7), "uid-1", "uid-1"))
+	got, err := repo.List(context.Background())
+	if err != nil || len(got) != 2 || got[0].ResourceType != "contact" || got[1].CollectionID != 7 {
+		t.Fatalf("List() = %#v, %v", got, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM events e WHERE e.calendar_id = $1 AND e.uid = $2`)).
+		WithArgs(int64(7), "uid-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Delete(context.Background(), "event", 7, "uid-1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := repo.Delete(context.Background(), "task", 7, "uid-1"); err == nil {
+		t.Fatal("expected an unknown resource type to be rejected")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLocationRepoCreateUpdateAndHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &locationRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"id", "user_id", "name", "address", "latitude", "longitude", "created_at", "updated_at"}
+	lat, lon := 52.52, 13.405
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO locations (user_id, name, address, latitude, longitude)`)).
+		WithArgs(int64(1), "Office", "Unter den Linden 1", &lat, &lon).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(3), int64(1), "Office", "Unter den Linden 1", lat, lon, now, now))
+	created, err := repo.Create(context.Background(), Location{UserID: 1, Name: "Office", Address: "Unter den Linden 1", Latitude: &lat, Longitude: &lon})
+	if err != nil || created.ID != 3 || created.Latitude == nil || *created.Longitude != lon {
+		t.Fatalf("Create() = %#v, %v", created, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE locations SET name = $3`)).
+		WithArgs(int64(3), int64(2), "Office", "", nil, nil).
+		WillReturnError(sql.ErrNoRows)
+	if _, err := repo.Update(context.Background(), Location{ID: 3, UserID: 2, Name: "Office"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Update() of another user's place error = %v, want ErrNotFound", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE c.user_id = $1 AND e.location <> '' AND e.location ILIKE $2`)).
+		WithArgs(int64(1), `%50\%%`, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"location"}).AddRow("Room 50% off"))
+	got, err := repo.History(context.Background(), 1, "50%", 5)
+	if err != nil || len(got) != 1 || got[0] != "Room 50% off" {
+		t.Fatalf("History() = %#v, %v", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDigestRepoUpsertListAndMarkSent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &digestRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"user_id", "frequency", "send_minute", "weekday", "working_days_only", "last_sent_at", "created_at", "updated_at"}
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO digest_settings (user_id, frequency, send_minute, weekday, working_days_only)`)).
+		WithArgs(int64(1), DigestWeekly, 480, 5, false).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), DigestWeekly, 480, 5, false, nil, now, now))
+	saved, err := repo.Upsert(context.Background(), DigestSettings{UserID: 1, Frequency: DigestWeekly, SendMinute: 480, Weekday: time.Friday})
+	if err != nil || saved.Weekday != time.Friday || saved.LastSentAt != nil {
+		t.Fatalf("Upsert() = %#v, %v", saved, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM digest_settings WHERE user_id = $1`)).
+		WithArgs(int64(2)).
+		WillReturnError(sql.ErrNoRows)
+	if got, err := repo.GetByUser(context.Background(), 2); got != nil || err != nil {
+		t.Fatalf("GetByUser() without a schedule = %#v, %v", got, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM digest_settings ORDER BY user_id`)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), DigestDaily, 420, 1, true, now, now, now))
+	all, err := repo.ListAll(context.Background())
+	if err != nil || len(all) != 1 || !all[0].WorkingDaysOnly || all[0].LastSentAt == nil {
+		t.Fatalf("ListAll() = %#v, %v", all, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE digest_settings SET last_sent_at = $2 WHERE user_id = $1`)).
+		WithArgs(int64(1), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.MarkSent(context.Background(), 1, now); err != nil {
+		t.Fatalf("MarkSent() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEventLinkRepoGetByTokenAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &eventLinkRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"id", "user_id", "calendar_id", "uid", "token", "expires_at", "access_count", "last_accessed_at", "created_at"}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM event_links WHERE token = $1 AND expires_at > NOW()`)).
+		WithArgs("tok").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(3), int64(1), int64(2), "dinner", "tok", now.Add(time.Hour), int64(4), now, now))
+	link, err := repo.GetByToken(context.Background(), "tok")
+	if err != nil || link == nil || link.AccessCount != 4 || link.LastAccessedAt == nil {
+		t.Fatalf("GetByToken() = %#v, %v", link, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM event_links WHERE token = $1`)).
+		WithArgs("gone").
+		WillReturnError(sql.ErrNoRows)
+	if link, err := repo.GetByToken(context.Background(), "gone"); link != nil || err != nil {
+		t.Fatalf("GetByToken() for an expired link = %#v, %v", link, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM event_links WHERE id = $1 AND user_id = $2`)).
+		WithArgs(int64(3), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.Delete(context.Background(), 9, 3); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() of another user's link error = %v, want ErrNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCollectionTombstoneRepoLists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &collectionTombstoneRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"change_txid", "change_seq", "collection_type", "collection_id", "user_id", "owner_user_id", "slug", "deleted_at"}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE user_id = $1 AND collection_type = $2 AND deleted_at > $3`)).
+		WithArgs(int64(1), "calendar", now.Add(-time.Hour)).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(int64(10), int64(2), "calendar", int64(3), int64(1), int64(1), "work", now).
+			AddRow(int64(11), int64(4), "calendar", int64(7), int64(1), int64(9), nil, now))
+	tombstones, err := repo.ListSince(context.Background(), 1, "calendar", now.Add(-time.Hour))
+	if err != nil || len(tombstones) != 2 {
+		t.Fatalf("ListSince() = %#v, %v", tombstones, err)
+	}
+	if tombstones[0].Slug == nil || *tombstones[0].Slug != "work" || tombstones[1].Slug != nil || tombstones[1].OwnerUserID != 9 {
+		t.Fatalf("unexpected tombstones %#v", tombstones)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`(change_txid, change_seq) > ($2::text::xid8, $3)`)).
+		WithArgs(int64(1), int64(10), int64(2), 50).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(11), int64(4), "addressbook", int64(8), int64(1), int64(1), nil, now))
+	tombstones, err = repo.ListAfter(context.Background(), 1, ChangeCursor{TxID: 10, Seq: 2}, 50)
+	if err != nil || len(tombstones) != 1 || tombstones[0].Cursor != (ChangeCursor{TxID: 11, Seq: 4}) || tombstones[0].CollectionType != "addressbook" {
+		t.Fatalf("ListAfter() = %#v, %v", tombstones, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCollectionMaintenanceRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &collectionMaintenanceRepo{pool: db}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	columns := []string{"collection_type", "collection_id", "reason", "started_by", "started_at", "expires_at"}
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (collection_type, collection_id) DO UPDATE`)).
+		WithArgs("calendar", int64(3), "merging", "admin@example.com", now.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("calendar", int64(3), "merging", "admin@example.com", now, now.Add(time.Hour)))
+	m, err := repo.Start(ctx, CollectionMaintenance{CollectionType: "calendar", CollectionID: 3, Reason: "merging", StartedBy: "admin@example.com", ExpiresAt: now.Add(time.Hour)})
+	if err != nil || m.Reason != "merging" || !m.StartedAt.Equal(now) {
+		t.Fatalf("Start() = %#v, %v", m, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE collection_type = $1 AND collection_id = $2 AND expires_at > NOW()`)).
+		WithArgs("addressbook", int64(4)).
+		WillReturnRows(sqlmock.NewRows(columns))
+	if m, err := repo.Get(ctx, "addressbook", 4); err != nil || m != nil {
+		t.Fatalf("Get() without maintenance = %#v, %v", m, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE expires_at > NOW()`)).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("calendar", int64(3), "merging", "admin@example.com", now, now.Add(time.Hour)).
+			AddRow("addressbook", int64(5), "restore", "admin@example.com", now, now.Add(time.Hour)))
+	list, err := repo.ListActive(ctx)
+	if err != nil || len(list) != 2 || list[1].CollectionType != "addressbook" || list[1].CollectionID != 5 {
+		t.Fatalf("ListActive() = %#v, %v", list, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM collection_maintenance WHERE collection_type = $1 AND collection_id = $2`)).
+		WithArgs("calendar", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"running"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM collection_maintenance`)).
+		WithArgs("calendar", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"running"}))
+	if ended, err := repo.End(ctx, "calendar", 3); err != nil || !ended {
+		t.Fatalf("End() = %v, %v", ended, err)
+	}
+	if ended, err := repo.End(ctx, "calendar", 3); err != nil || ended {
+		t.Fatalf("End() twice = %v, %v", ended, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestScanHelpersHandleNullableFields(t *testing.T) {
 	now := time.Now().UTC()
 
@@ -276,3 +839,183 @@ func TestScanHelpersHandleNullableFields(t *testing.T) {
 		t.Fatal("expected scanSession error")
 	}
 }
+
+func TestAuthEventRepoQueries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &authEventRepo{pool: db}
+	ctx := context.Background()
+	appPasswordID := int64(3)
+	now := time.Now().UTC()
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+INSERT INTO auth_events (user_id, app_password_id, outcome, new_address, ip_address, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6)
+`)).
+		WithArgs(int64(4), &appPasswordID, AuthOutcomeSuccess, true, "198.51.100.4", "DAVx5").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := repo.Record(ctx, AuthEvent{UserID: 4, AppPasswordID: &appPasswordID, Outcome: AuthOutcomeSuccess, NewAddress: true, IPAddress: "198.51.100.4", UserAgent: "DAVx5"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, app_password_id, outcome, new_address, ip_address, user_agent, created_at FROM auth_events WHERE user_id=$1 ORDER BY created_at DESC, id DESC LIMIT $2`)).
+		WithArgs(int64(4), 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "app_password_id", "outcome", "new_address", "ip_address", "user_agent", "created_at"}).
+			AddRow(int64(2), int64(4), nil, AuthOutcomeFailure, false, "203.0.113.5", "", now).
+			AddRow(int64(1), int64(4), appPasswordID, AuthOutcomeSuccess, true, "198.51.100.4", "DAVx5", now.Add(-time.Hour)))
+	events, err := repo.ListByUser(ctx, 4, 50)
+	if err != nil {
+		t.Fatalf("ListByUser() error = %v", err)
+	}
+	if len(events) != 2 || events[0].AppPasswordID != nil || events[1].AppPasswordID == nil || *events[1].AppPasswordID != 3 {
+		t.Fatalf("ListByUser() = %#v", events)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(bool_or(ip_address=$2), FALSE), COUNT(*) > 0 FROM auth_events WHERE user_id=$1 AND outcome='success'`)).
+		WithArgs(int64(4), "203.0.113.5").
+		WillReturnRows(sqlmock.NewRows([]string{"from_ip", "any"}).AddRow(false, true))
+	fromIP, any, err := repo.PriorSignIns(ctx, 4, "203.0.113.5")
+	if err != nil || fromIP || !any {
+		t.Fatalf("PriorSignIns() = %v, %v, %v", fromIP, any, err)
+	}
+
+	cutoff := now.Add(-AuthEventRetention)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM auth_events WHERE created_at < $1`)).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+	if deleted, err := repo.DeleteOlderThan(ctx, cutoff); err != nil || deleted != 7 {
+		t.Fatalf("DeleteOlderThan() = %d, %v", deleted, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestImpersonationRepoQueries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &impersonationRepo{pool: db}
+	ctx := context.Background()
+	adminID := int64(1)
+	now := time.Now().UTC()
+	columns := []string{"id", "admin_user_id", "admin_email", "user_id", "scope", "reason", "token_hash", "created_at", "expires_at", "ended_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO impersonations (admin_user_id, admin_email, user_id, scope, reason, token_hash, expires_at)`)).
+		WithArgs(&adminID, "admin@example.com", int64(4), ImpersonationRead, "ticket 12", "hash", now.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(7), adminID, "admin@example.com", int64(4), ImpersonationRead, "ticket 12", "hash", now, now.Add(time.Hour), nil))
+	imp, err := repo.Create(ctx, Impersonation{AdminUserID: &adminID, AdminEmail: "admin@example.com", UserID: 4, Scope: ImpersonationRead, Reason: "ticket 12", TokenHash: "hash", ExpiresAt: now.Add(time.Hour)})
+	if err != nil || imp.ID != 7 || imp.AdminUserID == nil || *imp.AdminUserID != 1 || imp.EndedAt != nil || !imp.Active(now) {
+		t.Fatalf("Create() = %+v, %v", imp, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + impersonationColumns + ` FROM impersonations WHERE token_hash = $1`)).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(columns))
+	if imp, err := repo.GetByTokenHash(ctx, "missing"); imp != nil || err != nil {
+		t.Fatalf("GetByTokenHash() of an unknown token = %+v, %v", imp, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE impersonations SET ended_at = NOW() WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()`)).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if ended, err := repo.End(ctx, 7); err != nil || !ended {
+		t.Fatalf("End() = %v, %v", ended, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + impersonationColumns + ` FROM impersonations ORDER BY created_at DESC, id DESC LIMIT $1`)).
+		WithArgs(20).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(7), nil, "gone@example.com", int64(4), ImpersonationWrite, "ticket 12", "hash", now, now.Add(time.Hour), now))
+	list, err := repo.List(ctx, 20)
+	if err != nil || len(list) != 1 || list[0].AdminUserID != nil || list[0].EndedAt == nil || list[0].Active(now) {
+		t.Fatalf("List() = %+v, %v", list, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+INSERT INTO impersonation_requests (impersonation_id, method, path, status, ip_address, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6)
+`)).
+		WithArgs(int64(7), "REPORT", "/dav/calendars/2/", 207, "198.51.100.4", "curl").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := repo.RecordRequest(ctx, ImpersonationRequest{ImpersonationID: 7, Method: "REPORT", Path: "/dav/calendars/2/", Status: 207, IPAddress: "198.51.100.4", UserAgent: "curl"}); err != nil {
+		t.Fatalf("RecordRequest() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, impersonation_id, method, path, status, ip_address, user_agent, created_at FROM impersonation_requests WHERE impersonation_id = $1 ORDER BY created_at, id LIMIT $2`)).
+		WithArgs(int64(7), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "impersonation_id", "method", "path", "status", "ip_address", "user_agent", "created_at"}).
+			AddRow(int64(1), int64(7), "REPORT", "/dav/calendars/2/", 207, "198.51.100.4", "curl", now))
+	requests, err := repo.ListRequests(ctx, 7, 100)
+	if err != nil || len(requests) != 1 || requests[0].Status != 207 {
+		t.Fatalf("ListRequests() = %+v, %v", requests, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestJobRepoQueries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &jobRepo{pool: db}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	columns := []string{"id", "user_id", "kind", "status", "total", "processed", "item_errors", "result", "error", "created_at", "updated_at", "finished_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO jobs (id, user_id, kind, status, total)`)).
+		WithArgs("abc", int64(4), "calendar-import", JobRunning, 0).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("abc", int64(4), "calendar-import", JobRunning, 0, 0, []byte(`[]`), nil, "", now, now, nil))
+	job, err := repo.Create(ctx, Job{ID: "abc", UserID: 4, Kind: "calendar-import", Status: JobRunning})
+	if err != nil || job.FinishedAt != nil || len(job.Errors) != 0 {
+		t.Fatalf("Create() = %+v, %v", job, err)
+	}
+
+	job.Status = JobSucceeded
+	job.Total, job.Processed = 2, 2
+	job.Errors = []JobItemError{{Item: "uid-1", Error: "bad request"}}
+	job.Result = json.RawMessage(`{"created":1}`)
+	job.FinishedAt = &now
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE jobs SET status=$2, total=$3, processed=$4, item_errors=$5, result=$6, error=$7, finished_at=$8, updated_at=NOW() WHERE id=$1`)).
+		WithArgs("abc", JobSucceeded, 2, 2, []byte(`[{"item":"uid-1","error":"bad request"}]`), []byte(`{"created":1}`), "", &now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Update(ctx, *job); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM jobs WHERE id=$1`)).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("abc", int64(4), "calendar-import", JobSucceeded, 2, 2, []byte(`[{"item":"uid-1","error":"bad request"}]`), []byte(`{"created":1}`), "", now, now, now))
+	got, err := repo.GetByID(ctx, "abc")
+	if err != nil || got.Status != JobSucceeded || len(got.Errors) != 1 || got.Errors[0].Item != "uid-1" || string(got.Result) != `{"created":1}` || got.FinishedAt == nil {
+		t.Fatalf("GetByID() = %+v, %v", got, err)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM jobs WHERE id=$1`)).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	if got, err := repo.GetByID(ctx, "missing"); got != nil || err != nil {
+		t.Fatalf("GetByID(missing) = %+v, %v", got, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE jobs SET status='interrupted', finished_at=NOW(), updated_at=NOW() WHERE status='running'`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if n, err := repo.MarkInterrupted(ctx); err != nil || n != 2 {
+		t.Fatalf("MarkInterrupted() = %d, %v", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
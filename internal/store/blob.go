@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+// BlobStore keeps files such as backups, attachments and contact photos
+// under slash-separated keys. Contents are streamed in both directions so
+// large files are never held in memory.
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing object.
+	// size is the number of bytes r yields, or -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the object under key; the caller closes it. It returns
+	// ErrNotFound when the key does not exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns every key starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// String describes the store for status output, without credentials.
+	String() string
+}
+
+// NewBlobStore builds the store cfg selects, or returns nil when neither a
+// directory nor a bucket is configured.
+func NewBlobStore(cfg config.BlobStorage) (BlobStore, error) {
+	switch {
+	case cfg.Dir != "":
+		return NewDirBlobStore(cfg.Dir)
+	case cfg.S3.Bucket != "":
+		return NewS3BlobStore(cfg.S3)
+	}
+	return nil, nil
+}
+
+// dirBlobStore keeps objects in a local directory, typically a mounted
+// volume.
+type dirBlobStore struct {
+	root string
+}
+
+// NewDirBlobStore returns a BlobStore rooted at the directory root, creating
+// it if needed.
+func NewDirBlobStore(root string) (BlobStore, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, err
+	}
+	return &dirBlobStore{root: abs}, nil
+}
+
+func (b *dirBlobStore) String() string {
+	return "dir:" + b.root
+}
+
+func (b *dirBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("blob store: invalid key " + key)
+	}
+	return filepath.Join(b.root, clean), nil
+}
+
+func (b *dirBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated
+	// object under the final name.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (b *dirBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (b *dirBlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (b *dirBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// Drop directories left empty; Remove fails harmlessly on non-empty ones.
+	for dir := filepath.Dir(p); dir != b.root && strings.HasPrefix(dir, b.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
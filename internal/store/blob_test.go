@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jw6ventures/calcard/internal/config"
+)
+
+func TestNewBlobStoreUnconfigured(t *testing.T) {
+	blobs, err := NewBlobStore(config.BlobStorage{})
+	if err != nil || blobs != nil {
+		t.Fatalf("NewBlobStore() = %v, %v; want nil for no storage", blobs, err)
+	}
+}
+
+func TestDirBlobStoreRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	blobs, err := NewBlobStore(config.BlobStorage{Dir: root})
+	if err != nil {
+		t.Fatalf("NewBlobStore: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := blobs.Put(ctx, "attachments/7/a.pdf", strings.NewReader("pdf"), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := blobs.Get(ctx, "attachments/7/a.pdf")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "pdf" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	if keys, err := blobs.List(ctx, "attachments/"); err != nil || len(keys) != 1 || keys[0] != "attachments/7/a.pdf" {
+		t.Fatalf("List = %v, %v", keys, err)
+	}
+	if err := blobs.Put(ctx, "../escape", strings.NewReader("x"), 1); err == nil {
+		t.Fatal("expected a key outside the root to be rejected")
+	}
+
+	if err := blobs.Delete(ctx, "attachments/7/a.pdf"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := blobs.Get(ctx, "attachments/7/a.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "attachments")); !os.IsNotExist(err) {
+		t.Fatal("expected empty directories to be removed")
+	}
+}
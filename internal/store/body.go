@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// inlineBodyBytes is the largest body read along with its row; larger ones
+// are read from content_bodies in chunks.
+const inlineBodyBytes = 256 << 10
+
+// bodyChunkChars is how many characters one chunk of a large body holds.
+// substring counts characters, not bytes, in a text column.
+const bodyChunkChars = 256 << 10
+
+// ErrBodyChanged reports a large body that was replaced or deleted while it
+// was being read.
+var ErrBodyChanged = errors.New("resource body changed while it was read")
+
+// Body is a resource body being read from the database. Small bodies are
+// already in memory; large ones are fetched a chunk at a time as they are
+// read, so serving a resource with embedded attachments does not hold it
+// whole.
+type Body struct {
+	io.Reader
+	// Size is the body's length in bytes.
+	Size int64
+}
+
+// NewBody returns a Body reading s.
+func NewBody(s string) *Body {
+	return &Body{Reader: strings.NewReader(s), Size: int64(len(s))}
+}
+
+// chunkedBody reads the content_bodies row with hash in chunks, each with a
+// query of its own so no connection is held between reads. Bodies are
+// stored by the hash of their content, so the chunks always belong to the
+// same body; one released mid-read fails with ErrBodyChanged.
+type chunkedBody struct {
+	ctx  context.Context
+	pool dbPool
+	hash string
+	next int64 // 1-based character offset of the next chunk
+	buf  string
+	done bool
+}
+
+func (b *chunkedBody) Read(p []byte) (int, error) {
+	for b.buf == "" {
+		if b.done {
+			return 0, io.EOF
+		}
+		if err := b.fetch(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+func (b *chunkedBody) fetch() error {
+	const q = `SELECT substring(body FROM $2 FOR $3) FROM content_bodies WHERE hash = $1`
+	defer observeDB(b.ctx, "content_bodies.read_chunk")()
+	var chunk string
+	if err := b.pool.QueryRowContext(b.ctx, q, b.hash, b.next, bodyChunkChars).Scan(&chunk); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBodyChanged
+		}
+		return err
+	}
+	chars := utf8.RuneCountInString(chunk)
+	b.buf = chunk
+	b.next += int64(chars)
+	b.done = chars < bodyChunkChars
+	return nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// StartLockCleanup periodically removes expired locks from the database.
+func StartLockCleanup(ctx context.Context, repo LockRepository, interval time.Duration) {
+	startCleanup(ctx, "lock_cleanup", "lock", repo.DeleteExpired, interval)
+}
+
+// StartSessionCleanup periodically removes expired sessions from the database.
+func StartSessionCleanup(ctx context.Context, repo SessionRepository, interval time.Duration) {
+	startCleanup(ctx, "session_cleanup", "session", repo.DeleteExpired, interval)
+}
+
+// StartEventLinkCleanup periodically removes expired event links.
+func StartEventLinkCleanup(ctx context.Context, repo EventLinkRepository, interval time.Duration) {
+	startCleanup(ctx, "event_link_cleanup", "event link", repo.DeleteExpired, interval)
+}
+
+// StartPushSubscriptionCleanup periodically removes expired WebDAV-Push
+// subscriptions.
+func StartPushSubscriptionCleanup(ctx context.Context, repo PushSubscriptionRepository, interval time.Duration) {
+	startCleanup(ctx, "push_subscription_cleanup", "push subscription", repo.DeleteExpired, interval)
+}
+
+// StartIdempotencyKeyCleanup periodically removes expired idempotency keys.
+func StartIdempotencyKeyCleanup(ctx context.Context, repo IdempotencyKeyRepository, interval time.Duration) {
+	startCleanup(ctx, "idempotency_key_cleanup", "idempotency key", repo.DeleteExpired, interval)
+}
+
+// AuthEventRetention is how long the DAV sign-in history is kept.
+const AuthEventRetention = 90 * 24 * time.Hour
+
+// StartAuthEventCleanup periodically removes sign-in history older than
+// AuthEventRetention.
+func StartAuthEventCleanup(ctx context.Context, repo AuthEventRepository, interval time.Duration) {
+	startCleanup(ctx, "auth_event_cleanup", "auth event", func(ctx context.Context) (int64, error) {
+		return repo.DeleteOlderThan(ctx, time.Now().Add(-AuthEventRetention))
+	}, interval)
+}
+
+// ClientRepairRetention is how long a repair counts towards the client data
+// quality report after a device last needed it.
+const ClientRepairRetention = 90 * 24 * time.Hour
+
+// StartClientRepairCleanup periodically removes repairs no device has
+// needed for ClientRepairRetention.
+func StartClientRepairCleanup(ctx context.Context, repo ClientRepairRepository, interval time.Duration) {
+	startCleanup(ctx, "client_repair_cleanup", "client repair", func(ctx context.Context) (int64, error) {
+		return repo.DeleteOlderThan(ctx, time.Now().Add(-ClientRepairRetention))
+	}, interval)
+}
+
+// JobRetention is how long finished jobs are kept.
+const JobRetention = 30 * 24 * time.Hour
+
+// StartJobCleanup periodically removes jobs that finished more than
+// JobRetention ago.
+func StartJobCleanup(ctx context.Context, repo JobRepository, interval time.Duration) {
+	startCleanup(ctx, "job_cleanup", "job", func(ctx context.Context) (int64, error) {
+		return repo.DeleteFinishedBefore(ctx, time.Now().Add(-JobRetention))
+	}, interval)
+}
+
+// RetentionLogRetention is how long the log of events deleted by retention
+// policies is kept.
+const RetentionLogRetention = 365 * 24 * time.Hour
+
+// StartRetentionLogCleanup periodically removes retention deletions logged
+// more than RetentionLogRetention ago.
+func StartRetentionLogCleanup(ctx context.Context, repo RetentionRepository, interval time.Duration) {
+	startCleanup(ctx, "retention_log_cleanup", "retention deletion", func(ctx context.Context) (int64, error) {
+		return repo.DeleteDeletionsBefore(ctx, time.Now().Add(-RetentionLogRetention))
+	}, interval)
+}
+
+// EventHistoryRetention is how long superseded event revisions are kept,
+// and so how far back a calendar can be viewed.
+const EventHistoryRetention = 400 * 24 * time.Hour
+
+// StartEventHistoryCleanup periodically removes event revisions superseded
+// more than EventHistoryRetention ago.
+func StartEventHistoryCleanup(ctx context.Context, repo EventRevisionRepository, interval time.Duration) {
+	startCleanup(ctx, "event_history_cleanup", "event revision", func(ctx context.Context) (int64, error) {
+		return repo.DeleteClosedBefore(ctx, time.Now().Add(-EventHistoryRetention))
+	}, interval)
+}
+
+// ContactHistoryRetention is how long superseded contact revisions are
+// kept, and so how far back the recently changed contacts go.
+const ContactHistoryRetention = 90 * 24 * time.Hour
+
+// StartContactHistoryCleanup periodically removes contact revisions
+// superseded more than ContactHistoryRetention ago.
+func StartContactHistoryCleanup(ctx context.Context, repo ContactRevisionRepository, interval time.Duration) {
+	startCleanup(ctx, "contact_history_cleanup", "contact revision", func(ctx context.Context) (int64, error) {
+		return repo.DeleteClosedBefore(ctx, time.Now().Add(-ContactHistoryRetention))
+	}, interval)
+}
+
+func startCleanup(ctx context.Context, op, what string, deleteExpired func(context.Context) (int64, error), interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := deleteExpired(ctx)
+			if err != nil {
+				if isConnError(err) {
+					queryLogger.Error(op, "expired-%s cleanup failed, database appears unreachable: %v", what, err)
+				} else {
+					queryLogger.Warn(op, "expired-%s cleanup failed: %v", what, err)
+				}
+				continue
+			}
+			if deleted > 0 {
+				queryLogger.Debug(op, "cleaned up %d expired %ss", deleted, what)
+			}
+		}
+	}
+}
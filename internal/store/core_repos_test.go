@@ -3,16 +3,75 @@ package store
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"io"
+	"math"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jw6ventures/calcard/internal/util"
 	"github.com/lib/pq"
 )
 
+func TestUserRepoUpsertOAuthUserClaimsBootstrapAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &userRepo{pool: db}
+	now := time.Now().UTC()
+	mock.ExpectQuery(regexp.QuoteMeta(`WITH claimed AS (`)).
+		WithArgs("oidc-1234", "Alice@Example.com", "bootstrap:alice@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "oauth_subject", "primary_email", "created_at", "last_login_at", "onboarding_completed_at", "sync_hide_cancelled", "sync_prefs_updated_at", "locale", "timezone", "week_start", "internal", "group_ids", "email_aliases"}).
+			AddRow(int64(3), "oidc-1234", "Alice@Example.com", now, now, nil, false, nil, "en-GB", "Europe/London", int64(1), false, "{4,7}", "{}"))
+	user, err := repo.UpsertOAuthUser(context.Background(), "oidc-1234", "Alice@Example.com")
+	if err != nil || user.ID != 3 || user.OAuthSubject != "oidc-1234" || user.Locale != "en-GB" || user.WeekStart == nil || *user.WeekStart != time.Monday || len(user.GroupIDs) != 2 {
+		t.Fatalf("UpsertOAuthUser() = %+v, %v", user, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestACLPrincipalsIncludeGroups(t *testing.T) {
+	got := ACLPrincipals(&User{ID: 4, GroupIDs: []int64{9}})
+	want := []string{"DAV:all", "DAV:authenticated", "/dav/principals/4/", "/dav/principals/groups/9/"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ACLPrincipals() = %v, want %v", got, want)
+	}
+	if got := ACLPrincipals(nil); len(got) != 1 || got[0] != "DAV:all" {
+		t.Fatalf("ACLPrincipals(nil) = %v", got)
+	}
+	if got := ACLPrincipals(&User{ID: 5, Internal: true}); strings.Join(got, ",") != "DAV:all,/dav/principals/5/" {
+		t.Fatalf("ACLPrincipals(internal) = %v, want no DAV:authenticated", got)
+	}
+}
+
+func TestUserGroupRepoCreateReportsDuplicateName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &userGroupRepo{pool: db}
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO user_groups`)).
+		WithArgs(int64(1), "Family").
+		WillReturnError(&pq.Error{Code: "23505"})
+	if _, err := repo.Create(context.Background(), 1, "Family"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Create() error = %v, want ErrConflict", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestCalendarRepoCreateAndOwnerScopedMutations(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -168,6 +227,38 @@ func TestCalendarAccessibleReposUseACLs(t *testing.T) {
 	}
 }
 
+func TestCalendarListAccessiblePageStartsFromGrants(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &calendarRepo{pool: db}
+	now := time.Now().UTC()
+
+	mock.ExpectQuery(`(?s)WITH candidates AS \(.*acl_entries a.*a.is_grant = TRUE.*event_count.*COUNT\(\*\) OVER \(\).*JOIN candidates k ON k.id = c.id.*ORDER BY shared, name.*LIMIT \$2 OFFSET \$3`).
+		WithArgs(int64(4), 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "slug", "description", "timezone", "color", "ctag", "created_at", "updated_at", "owner_email", "shared", "can_read", "can_read_free_busy", "can_write", "can_write_content", "can_write_properties", "can_bind", "can_unbind", "event_count", "total_count"}).
+			AddRow(int64(2), int64(9), "Shared", nil, nil, nil, nil, int64(3), now, now, "other@example.com", true, true, true, false, false, false, false, false, 12, 3))
+
+	page, err := repo.ListAccessiblePage(context.Background(), 4, 1, 1)
+	if err != nil {
+		t.Fatalf("ListAccessiblePage() error = %v", err)
+	}
+	if page.TotalCount != 3 || page.Limit != 1 || page.Offset != 1 || len(page.Items) != 1 {
+		t.Fatalf("ListAccessiblePage() = %#v", page)
+	}
+	got := page.Items[0]
+	if got.ID != 2 || !got.Shared || got.Editor || got.EventCount == nil || *got.EventCount != 12 {
+		t.Fatalf("ListAccessiblePage() item = %#v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestCalendarAccessibleReposIncludeReadFreeBusyOnlyCalendars(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -341,11 +432,13 @@ func TestEventRepoUpsertParsesFieldsAndPagination(t *testing.T) {
 
 	rawICAL := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:test-uid\r\nSUMMARY:Planning Day\r\nDTSTART;VALUE=DATE:20260412\r\nDTEND;VALUE=DATE:20260413\r\nEND:VEVENT\r\nEND:VCALENDAR"
 	mock.ExpectQuery(regexp.QuoteMeta(`
-INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, canonical_ical, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
 ON CONFLICT (calendar_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_ical = EXCLUDED.raw_ical,
+        raw_ical_hash = EXCLUDED.raw_ical_hash,
+        canonical_ical = EXCLUDED.canonical_ical,
         etag = EXCLUDED.etag,
         summary = EXCLUDED.summary,
         description = EXCLUDED.description,
@@ -354,9 +447,9 @@ ON CONFLICT (calendar_id, uid) DO UPDATE SET
         dtend = EXCLUDED.dtend,
         all_day = EXCLUDED.all_day,
         last_modified = NOW()
-RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified
+RETURNING id, calendar_id, uid, resource_name, $4, etag, summary, description, location, dtstart, dtend, all_day, last_modified
 `)).
-		WithArgs(int64(7), "test-uid", "test-uid", rawICAL, "etag-1", "Planning Day", nil, nil, dtstart, dtend, true).
+		WithArgs(int64(7), "test-uid", "test-uid", rawICAL, "etag-1", "Planning Day", nil, nil, dtstart, dtend, true, util.Canonicalize(rawICAL)).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(1), int64(7), "test-uid", "test-uid", rawICAL, "etag-1", "Planning Day", nil, nil, dtstart, dtend, true, now))
 
@@ -376,7 +469,7 @@ RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, descript
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM events WHERE calendar_id=$1`)).
 		WithArgs(int64(7)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC LIMIT $2 OFFSET $3`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC LIMIT $2 OFFSET $3`)).
 		WithArgs(int64(7), 1, 1).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(2), int64(7), "other", "other.ics", rawICAL, "etag-2", nil, nil, nil, nil, nil, false, now))
@@ -413,6 +506,61 @@ RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, descript
 	}
 }
 
+func TestEventRepoOpenByResourceNameStreamsLargeBodies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	repo := &eventRepo{pool: db}
+	now := time.Now()
+	cols := []string{"id", "calendar_id", "uid", "resource_name", "body", "raw_ical_hash", "size", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}
+	open := regexp.QuoteMeta(`FROM events e LEFT JOIN content_bodies b ON b.hash = e.raw_ical_hash`)
+	chunk := regexp.QuoteMeta(`SELECT substring(body FROM $2 FOR $3) FROM content_bodies WHERE hash = $1`)
+
+	mock.ExpectQuery(open).WithArgs(int64(1), "small.ics", inlineBodyBytes).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(int64(5), int64(1), "small", "small.ics", "BEGIN:VCALENDAR", "h1", int64(15), "e1", "Lunch", nil, nil, nil, nil, false, now))
+	ev, body, err := repo.OpenByResourceName(context.Background(), 1, "small.ics")
+	if err != nil || ev == nil || ev.Summary == nil || *ev.Summary != "Lunch" || ev.RawICAL != "" {
+		t.Fatalf("OpenByResourceName(small) = %+v, %v", ev, err)
+	}
+	if data, _ := io.ReadAll(body); string(data) != "BEGIN:VCALENDAR" || body.Size != 15 {
+		t.Fatalf("small body = %q (%d bytes)", data, body.Size)
+	}
+
+	large := strings.Repeat("é", 10)
+	mock.ExpectQuery(open).WithArgs(int64(1), "large.ics", inlineBodyBytes).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(int64(6), int64(1), "large", "large.ics", nil, "h2", int64(len(large)), "e2", nil, nil, nil, nil, nil, false, now))
+	mock.ExpectQuery(chunk).WithArgs("h2", int64(1), bodyChunkChars).
+		WillReturnRows(sqlmock.NewRows([]string{"substring"}).AddRow(large))
+	ev, body, err = repo.OpenByResourceName(context.Background(), 1, "large.ics")
+	if err != nil || ev == nil {
+		t.Fatalf("OpenByResourceName(large) = %+v, %v", ev, err)
+	}
+	if data, err := io.ReadAll(body); err != nil || string(data) != large || body.Size != int64(len(large)) {
+		t.Fatalf("large body = %q, %v", data, err)
+	}
+
+	mock.ExpectQuery(open).WithArgs(int64(1), "gone.ics", inlineBodyBytes).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(int64(7), int64(1), "gone", "gone.ics", nil, "h3", int64(1<<20), "e3", nil, nil, nil, nil, nil, false, now))
+	mock.ExpectQuery(chunk).WithArgs("h3", int64(1), bodyChunkChars).WillReturnError(sql.ErrNoRows)
+	_, body, err = repo.OpenByResourceName(context.Background(), 1, "gone.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(body); !errors.Is(err, ErrBodyChanged) {
+		t.Fatalf("read of a replaced body error = %v, want ErrBodyChanged", err)
+	}
+
+	mock.ExpectQuery(open).WithArgs(int64(1), "missing.ics", inlineBodyBytes).WillReturnError(sql.ErrNoRows)
+	if ev, body, err := repo.OpenByResourceName(context.Background(), 1, "missing.ics"); ev != nil || body != nil || err != nil {
+		t.Fatalf("OpenByResourceName(missing) = %+v, %v, %v", ev, body, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestEventRepoMoveToCalendarRenameWithinSameCalendarCreatesTombstone(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -446,6 +594,95 @@ func TestEventRepoMoveToCalendarRenameWithinSameCalendarCreatesTombstone(t *test
 	}
 }
 
+func TestEventRepoDeleteByUIDsBumpsCTagOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &eventRepo{pool: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL calcard.batch_ctag = 'on'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM events WHERE calendar_id=$1 AND uid = ANY($2)`)).
+		WithArgs(int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = $1`)).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := repo.DeleteByUIDs(context.Background(), 5, []string{"a", "b", "c"})
+	if err != nil || deleted != 3 {
+		t.Fatalf("DeleteByUIDs() = %d, %v, want 3", deleted, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL calcard.batch_ctag = 'on'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM events WHERE calendar_id=$1 AND uid = ANY($2)`)).
+		WithArgs(int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if deleted, err := repo.DeleteByUIDs(context.Background(), 5, []string{"gone"}); err != nil || deleted != 0 {
+		t.Fatalf("DeleteByUIDs() = %d, %v, want 0", deleted, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestEventRepoMoveAllToCalendar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &eventRepo{pool: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM events s JOIN events d`)).
+		WithArgs(int64(1), int64(2), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectRollback()
+	if _, err := repo.MoveAllToCalendar(context.Background(), 1, 2, nil); !errors.Is(err, ErrConflict) {
+		t.Fatalf("MoveAllToCalendar() error = %v, want ErrConflict", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM events s JOIN events d`)).
+		WithArgs(int64(1), int64(2), nil).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL calcard.batch_ctag = 'on'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`UPDATE events SET calendar_id=$2, last_modified=NOW() WHERE calendar_id=$1`)).
+		WithArgs(int64(1), int64(2), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"uid", "resource_name"}).AddRow("a", "a.ics").AddRow("b", "b"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO deleted_resources`)).
+		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM acl_entries WHERE resource_path = ANY($1)`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = ANY($1)`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	moved, err := repo.MoveAllToCalendar(context.Background(), 1, 2, nil)
+	if err != nil || moved != 2 {
+		t.Fatalf("MoveAllToCalendar() = %d, %v, want 2", moved, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
 func TestEventRepoMoveToCalendarOverwriteWithinSameCalendarDeletesDestination(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -518,7 +755,7 @@ func TestEventRepoCopyToCalendarRejectsDestinationUIDRebindAcrossCalendars(t *te
 	now := time.Now().UTC()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`)).
 		WithArgs(int64(5), "event-1").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(1), int64(5), "event-1", "source-name", "BEGIN:VCALENDAR", "etag-src", nil, nil, nil, nil, nil, false, now))
@@ -547,7 +784,7 @@ func TestEventRepoAndAddressBookRepoReturnNilOrErrNotFound(t *testing.T) {
 	eventRepo := &eventRepo{pool: db}
 	addressBookRepo := &addressBookRepo{pool: db}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`)).
 		WithArgs(int64(2), "missing").
 		WillReturnError(sql.ErrNoRows)
 	ev, err := eventRepo.GetByUID(context.Background(), 2, "missing")
@@ -588,7 +825,7 @@ func TestEventAndAddressBookListQueries(t *testing.T) {
 	bookRepo := &addressBookRepo{pool: db}
 	now := time.Now().UTC()
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid = ANY($2)`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid = ANY($2)`)).
 		WithArgs(int64(7), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(1), int64(7), "uid-1", "uid-1.ics", "BEGIN:VCALENDAR", "etag-1", "Meeting", nil, nil, now, now.Add(time.Hour), false, now))
@@ -600,7 +837,7 @@ func TestEventAndAddressBookListQueries(t *testing.T) {
 		t.Fatalf("ListByUIDs() = %#v", byUIDs)
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND resource_name=$2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND resource_name=$2`)).
 		WithArgs(int64(7), "missing.ics").
 		WillReturnError(sql.ErrNoRows)
 	resource, err := eventRepo.GetByResourceName(context.Background(), 7, "missing.ics")
@@ -612,7 +849,7 @@ func TestEventAndAddressBookListQueries(t *testing.T) {
 	}
 
 	since := now.Add(-time.Hour)
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, calendar_id, uid, resource_name, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)), etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`)).
 		WithArgs(int64(7), since).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(2), int64(7), "uid-2", "uid-2.ics", "BEGIN:VCALENDAR", "etag-2", "Recent", nil, nil, nil, nil, true, now))
@@ -624,7 +861,7 @@ func TestEventAndAddressBookListQueries(t *testing.T) {
 		t.Fatalf("ListModifiedSince() = %#v", modified)
 	}
 
-	mock.ExpectQuery(`(?s)SELECT e.id, e.calendar_id, e.uid, e.resource_name, e.raw_ical, e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified.*FROM events e.*acl_entries.*ORDER BY e.last_modified DESC.*LIMIT \$2`).
+	mock.ExpectQuery(`(?s)SELECT e.id, e.calendar_id, e.uid, e.resource_name, COALESCE\(raw_ical, \(SELECT body FROM content_bodies WHERE hash = raw_ical_hash\)\), e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified.*FROM events e.*acl_entries.*ORDER BY e.last_modified DESC.*LIMIT \$2`).
 		WithArgs(int64(4), 2).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(3), int64(8), "uid-3", "uid-3.ics", "BEGIN:VCALENDAR", "etag-3", nil, nil, nil, nil, nil, false, now))
@@ -636,7 +873,7 @@ func TestEventAndAddressBookListQueries(t *testing.T) {
 		t.Fatalf("ListRecentByUser() = %#v", recent)
 	}
 
-	mock.ExpectQuery(`(?s)SELECT e.id, e.calendar_id, e.uid, e.resource_name, e.raw_ical, e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified.*resource_path IN.*e.resource_name.*LIMIT \$2`).
+	mock.ExpectQuery(`(?s)SELECT e.id, e.calendar_id, e.uid, e.resource_name, COALESCE\(raw_ical, \(SELECT body FROM content_bodies WHERE hash = raw_ical_hash\)\), e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified.*resource_path IN.*e.resource_name.*LIMIT \$2`).
 		WithArgs(int64(4), 2).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}).
 			AddRow(int64(6), int64(8), "uid-object", "uid-object", "BEGIN:VCALENDAR", "etag-6", "Direct Grant", nil, nil, nil, nil, false, now))
@@ -685,19 +922,23 @@ func TestContactRepoUpsertAndMoveToAddressBook(t *testing.T) {
 	rawVCard := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Jane Doe\r\nEMAIL:jane@example.com\r\nBDAY:1990-05-15\r\nEND:VCARD"
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, canonical_vcard, email_keys, phone_keys, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 ON CONFLICT (address_book_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_vcard = EXCLUDED.raw_vcard,
+        raw_vcard_hash = EXCLUDED.raw_vcard_hash,
+        canonical_vcard = EXCLUDED.canonical_vcard,
         etag = EXCLUDED.etag,
         display_name = EXCLUDED.display_name,
         primary_email = EXCLUDED.primary_email,
         birthday = EXCLUDED.birthday,
+        email_keys = EXCLUDED.email_keys,
+        phone_keys = EXCLUDED.phone_keys,
         last_modified = NOW()
-RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified
+RETURNING id, address_book_id, uid, resource_name, $4, etag, display_name, primary_email, birthday, last_modified
 `)).
-		WithArgs(int64(5), "contact-1", "contact-1", rawVCard, "etag-1", "Jane Doe", "jane@example.com", birthday).
+		WithArgs(int64(5), "contact-1", "contact-1", rawVCard, "etag-1", "Jane Doe", "jane@example.com", birthday, util.Canonicalize(rawVCard), pq.Array([]string{"jane@example.com"}), pq.Array([]string{})).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(1), int64(5), "contact-1", "contact-1", rawVCard, "etag-1", "Jane Doe", "jane@example.com", birthday, now))
 
@@ -761,6 +1002,41 @@ RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name
 	}
 }
 
+func TestContactRepoFindByLookupKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &contactRepo{pool: db}
+	now := time.Now().UTC()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM contacts
+WHERE address_book_id = ANY($1) AND (email_keys @> ARRAY[$2]::text[] OR phone_keys @> ARRAY[$3]::text[])`)).
+		WithArgs(pq.Array([]int64{5, 9}), "", "14155550100", 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
+			AddRow(int64(1), int64(9), "contact-1", "contact-1", "BEGIN:VCARD\r\nEND:VCARD", "etag-1", "Jane Doe", nil, nil, now))
+
+	found, err := repo.FindByLookupKey(context.Background(), []int64{5, 9}, "", "14155550100", 50)
+	if err != nil {
+		t.Fatalf("FindByLookupKey() error = %v", err)
+	}
+	if len(found) != 1 || found[0].AddressBookID != 9 {
+		t.Fatalf("FindByLookupKey() = %#v", found)
+	}
+	if found, err := repo.FindByLookupKey(context.Background(), nil, "", "14155550100", 50); err != nil || len(found) != 0 {
+		t.Fatalf("FindByLookupKey() without books = %#v, %v", found, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+
+	emails, phones := parseVCardLookupKeys("BEGIN:VCARD\r\nEMAIL:Jane@Example.com\r\nitem1.EMAIL;TYPE=work:jane@example.com\r\nTEL:+1 415 555 0100\r\nTEL:12\r\nEND:VCARD")
+	if len(emails) != 1 || emails[0] != "jane@example.com" || len(phones) != 1 || phones[0] != "14155550100" {
+		t.Fatalf("parseVCardLookupKeys() = %v, %v", emails, phones)
+	}
+}
+
 func TestContactRepoMoveToAddressBookRenameWithinSameBookCreatesTombstone(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -838,19 +1114,23 @@ func TestContactRepoUpsertMapsResourceNameConflictsToErrConflict(t *testing.T) {
 	rawVCard := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:contact-1\r\nFN:Jane Doe\r\nEND:VCARD\r\n"
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, canonical_vcard, email_keys, phone_keys, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 ON CONFLICT (address_book_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_vcard = EXCLUDED.raw_vcard,
+        raw_vcard_hash = EXCLUDED.raw_vcard_hash,
+        canonical_vcard = EXCLUDED.canonical_vcard,
         etag = EXCLUDED.etag,
         display_name = EXCLUDED.display_name,
         primary_email = EXCLUDED.primary_email,
         birthday = EXCLUDED.birthday,
+        email_keys = EXCLUDED.email_keys,
+        phone_keys = EXCLUDED.phone_keys,
         last_modified = NOW()
-RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified
+RETURNING id, address_book_id, uid, resource_name, $4, etag, display_name, primary_email, birthday, last_modified
 `)).
-		WithArgs(int64(5), "contact-1", "renamed", rawVCard, "etag-1", "Jane Doe", nil, nil).
+		WithArgs(int64(5), "contact-1", "renamed", rawVCard, "etag-1", "Jane Doe", nil, nil, util.Canonicalize(rawVCard), pq.Array([]string{}), pq.Array([]string{})).
 		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_contacts_resource_name"})
 
 	_, err = repo.Upsert(context.Background(), Contact{
@@ -881,7 +1161,7 @@ func TestContactRepoCopyToAddressBookRenameExistingUIDCreatesDestinationTombston
 	rawVCard := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:contact-1\r\nFN:Jane Doe\r\nEND:VCARD\r\n"
 
 	mock.ExpectBegin()
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
 		WithArgs(int64(5), "contact-1").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(1), int64(5), "contact-1", "source-name", rawVCard, "etag-src", "Jane Doe", nil, nil, now))
@@ -895,19 +1175,23 @@ func TestContactRepoCopyToAddressBookRenameExistingUIDCreatesDestinationTombston
 		WithArgs(int64(9), "contact-1", "old-dest-name").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectQuery(regexp.QuoteMeta(`
-INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, canonical_vcard, email_keys, phone_keys, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 ON CONFLICT (address_book_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_vcard = EXCLUDED.raw_vcard,
+        raw_vcard_hash = EXCLUDED.raw_vcard_hash,
+        canonical_vcard = EXCLUDED.canonical_vcard,
         etag = EXCLUDED.etag,
         display_name = EXCLUDED.display_name,
         primary_email = EXCLUDED.primary_email,
         birthday = EXCLUDED.birthday,
+        email_keys = EXCLUDED.email_keys,
+        phone_keys = EXCLUDED.phone_keys,
         last_modified = NOW()
-RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified
+RETURNING id, address_book_id, uid, resource_name, $4, etag, display_name, primary_email, birthday, last_modified
 `)).
-		WithArgs(int64(9), "contact-1", "new-dest-name", rawVCard, "etag-new", "Jane Doe", nil, nil).
+		WithArgs(int64(9), "contact-1", "new-dest-name", rawVCard, "etag-new", "Jane Doe", nil, nil, util.Canonicalize(rawVCard), pq.Array([]string{}), pq.Array([]string{})).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(2), int64(9), "contact-1", "new-dest-name", rawVCard, "etag-new", "Jane Doe", nil, nil, now))
 	mock.ExpectCommit()
@@ -1138,7 +1422,7 @@ func TestContactRepoListQueriesAndMoveRollbackOnFailure(t *testing.T) {
 	birthday := time.Date(1985, 7, 20, 0, 0, 0, 0, time.UTC)
 	since := now.Add(-2 * time.Hour)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid = ANY($2)`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid = ANY($2)`)).
 		WithArgs(int64(5), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(1), int64(5), "uid-1", "uid-1", "BEGIN:VCARD", "etag-1", "Jane Doe", "jane@example.com", birthday, now))
@@ -1150,7 +1434,7 @@ func TestContactRepoListQueriesAndMoveRollbackOnFailure(t *testing.T) {
 		t.Fatalf("ListByUIDs() = %#v", contacts)
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY last_modified DESC`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY last_modified DESC`)).
 		WithArgs(int64(5)).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(2), int64(5), "uid-2", "uid-2", "BEGIN:VCARD", "etag-2", nil, nil, nil, now))
@@ -1165,7 +1449,7 @@ func TestContactRepoListQueriesAndMoveRollbackOnFailure(t *testing.T) {
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM contacts WHERE address_book_id=$1`)).
 		WithArgs(int64(5)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY LOWER(COALESCE(display_name, '')) ASC, id ASC LIMIT $2 OFFSET $3`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY LOWER(COALESCE(display_name, '')) ASC, id ASC LIMIT $2 OFFSET $3`)).
 		WithArgs(int64(5), 10, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(3), int64(5), "uid-3", "uid-3", "BEGIN:VCARD", "etag-3", "Alex", nil, nil, now))
@@ -1177,7 +1461,7 @@ func TestContactRepoListQueriesAndMoveRollbackOnFailure(t *testing.T) {
 		t.Fatalf("ListForBookPaginated() = %#v", page)
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`)).
 		WithArgs(int64(5), since).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "address_book_id", "uid", "resource_name", "raw_vcard", "etag", "display_name", "primary_email", "birthday", "last_modified"}).
 			AddRow(int64(4), int64(5), "uid-4", "uid-4", "BEGIN:VCARD", "etag-4", "Chris", "chris@example.com", nil, now))
@@ -1190,7 +1474,7 @@ func TestContactRepoListQueriesAndMoveRollbackOnFailure(t *testing.T) {
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-SELECT c.id, c.address_book_id, c.uid, c.resource_name, c.raw_vcard, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
+SELECT c.id, c.address_book_id, c.uid, c.resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
 FROM contacts c
 JOIN address_books ab ON ab.id = c.address_book_id
 WHERE ab.user_id = $1
@@ -1220,7 +1504,7 @@ LIMIT $2
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-SELECT c.id, c.address_book_id, c.uid, c.resource_name, c.raw_vcard, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
+SELECT c.id, c.address_book_id, c.uid, c.resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
 FROM contacts c
 JOIN address_books ab ON ab.id = c.address_book_id
 WHERE ab.user_id = $1 AND c.birthday IS NOT NULL
@@ -1258,7 +1542,7 @@ ORDER BY c.display_name
 		t.Fatalf("MoveToAddressBook() error = %v", err)
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, address_book_id, uid, resource_name, COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash)), etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
 		WithArgs(int64(5), "missing").
 		WillReturnError(sql.ErrNoRows)
 	got, err := repo.GetByUID(context.Background(), 5, "missing")
@@ -1547,3 +1831,800 @@ func TestLockRepoCreateRejectsInvalidDepth(t *testing.T) {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestCanonicalFormRepoBackfillEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &canonicalFormRepo{pool: db}
+	raw := "BEGIN:VCALENDAR\nVERSION:2.0\nEND:VCALENDAR\n"
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash)) FROM events WHERE canonical_ical IS NULL ORDER BY id LIMIT $1`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "raw_ical"}).AddRow(int64(1), raw).AddRow(int64(2), raw))
+	update := regexp.QuoteMeta(`UPDATE events SET canonical_ical=$2, etag=$3`)
+	mock.ExpectExec(update).
+		WithArgs(int64(1), util.Canonicalize(raw), util.CanonicalETag(raw), raw).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The second event changed after it was read; its writer stored the canonical form.
+	mock.ExpectExec(update).
+		WithArgs(int64(2), util.Canonicalize(raw), util.CanonicalETag(raw), raw).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := repo.BackfillEvents(context.Background(), 2)
+	if err != nil || n != 1 {
+		t.Fatalf("BackfillEvents() = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestChangeRepoListSinceAndLatest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &changeRepo{pool: db}
+	changedAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	columns := []string{"change_txid", "change_seq", "resource_type", "collection_id", "uid", "resource_name", "etag", "created", "deleted", "changed_at", "changed_by"}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE (change_txid, change_seq) > ($3::text::xid8, $4) AND change_txid < pg_snapshot_xmin(pg_current_snapshot())`)).
+		WithArgs(pq.Array([]int64{1, 2}), pq.Array([]int64{5}), int64(9), int64(7), 2).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(int64(10), int64(8), "event", int64(1), "a", "a.ics", "etag-a", true, false, changedAt, int64(3)).
+			AddRow(int64(11), int64(9), "contact", int64(5), "b", "b.vcf", "", false, true, changedAt, int64(0)))
+	changes, err := repo.ListSince(context.Background(), []int64{1, 2}, []int64{5}, ChangeCursor{TxID: 9, Seq: 7}, 2)
+	if err != nil {
+		t.Fatalf("ListSince() error = %v", err)
+	}
+	if len(changes) != 2 || changes[0].Cursor != (ChangeCursor{TxID: 10, Seq: 8}) || !changes[0].Created || changes[0].ChangedBy != 3 || changes[1].ResourceType != "contact" || !changes[1].Deleted {
+		t.Fatalf("ListSince() = %#v", changes)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint - 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"txid"}).AddRow(int64(41)))
+	latest, err := repo.Latest(context.Background())
+	if err != nil || latest.TxID != 41 || latest.Seq != math.MaxInt64 {
+		t.Fatalf("Latest() = %+v, %v", latest, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestWritesWithActorRunInAttributedTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &contactRepo{pool: actorPool{db}}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('calcard.actor', $1, true)`)).
+		WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
+		WithArgs(int64(4), "c1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := repo.DeleteByUID(WithActor(context.Background(), 7), 4, "c1"); err != nil {
+		t.Fatalf("DeleteByUID() error = %v", err)
+	}
+
+	// Without an actor the write runs on its own, as before.
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM contacts WHERE address_book_id=$1 AND uid=$2`)).
+		WithArgs(int64(4), "c2").WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.DeleteByUID(context.Background(), 4, "c2"); err != nil {
+		t.Fatalf("DeleteByUID() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestJobJournalRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &jobJournalRepo{pool: db}
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO job_journal (job_id, seq, collection_id, payload)`)).
+		WithArgs("job1", pq.Array([]int64{1, 2}), pq.Array([]int64{5, 5}), pq.Array([]string{"a", "b"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := repo.Append(context.Background(), "job1", []JournalEntry{{Seq: 1, CollectionID: 5, Payload: "a"}, {Seq: 2, CollectionID: 5, Payload: "b"}}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE job_journal SET applied_at=NOW() WHERE job_id=$1 AND seq=$2`)).
+		WithArgs("job1", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.MarkApplied(context.Background(), "job1", 1); err != nil {
+		t.Fatalf("MarkApplied() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM job_journal WHERE job_id=$1 AND applied_at IS NULL ORDER BY seq`)).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "seq", "collection_id", "payload", "applied_at"}).AddRow("job1", 2, int64(5), "b", nil))
+	pending, err := repo.ListPending(context.Background(), "job1")
+	if err != nil || len(pending) != 1 || pending[0].Seq != 2 || pending[0].Payload != "b" || pending[0].AppliedAt != nil {
+		t.Fatalf("ListPending() = %+v, %v", pending, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM job_journal WHERE job_id=$1`)).
+		WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := repo.Discard(context.Background(), "job1"); err != nil {
+		t.Fatalf("Discard() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCollectionNotificationRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &collectionNotificationRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"user_id", "collection_type", "collection_id", "cursor_txid", "cursor_seq", "last_notified_at", "created_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO collection_notifications (user_id, collection_type, collection_id, cursor_txid, cursor_seq)`)).
+		WithArgs(int64(2), "calendar", int64(9), int64(40), int64(5)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(2), "calendar", int64(9), int64(40), int64(5), nil, now))
+	n, err := repo.Subscribe(context.Background(), CollectionNotification{UserID: 2, CollectionType: "calendar", CollectionID: 9, Cursor: ChangeCursor{TxID: 40, Seq: 5}})
+	if err != nil || n.Cursor != (ChangeCursor{TxID: 40, Seq: 5}) || n.LastNotifiedAt != nil {
+		t.Fatalf("Subscribe() = %+v, %v", n, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`SET cursor_txid = $4, cursor_seq = $5, last_notified_at = COALESCE($6, last_notified_at)`)).
+		WithArgs(int64(2), "calendar", int64(9), int64(41), int64(1), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Advance(context.Background(), 2, "calendar", 9, ChangeCursor{TxID: 41, Seq: 1}, &now); err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM collection_notifications WHERE user_id = $1`)).
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(2), "calendar", int64(9), int64(41), int64(1), now, now))
+	list, err := repo.ListByUser(context.Background(), 2)
+	if err != nil || len(list) != 1 || list[0].LastNotifiedAt == nil {
+		t.Fatalf("ListByUser() = %+v, %v", list, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM collection_notifications WHERE user_id = $1 AND collection_type = $2 AND collection_id = $3`)).
+		WithArgs(int64(2), "calendar", int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Unsubscribe(context.Background(), 2, "calendar", 9); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPushSubscriptionRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &pushSubscriptionRepo{pool: db}
+	now := time.Now().UTC()
+	expires := now.Add(72 * time.Hour)
+	columns := []string{"id", "user_id", "collection_type", "collection_id", "token", "push_resource", "public_key", "auth_secret",
+		"cursor_txid", "cursor_seq", "expires_at", "last_pushed_at", "created_at"}
+	row := func(txid int64, pushed any) []driver.Value {
+		return []driver.Value{int64(5), int64(2), "calendar", int64(9), "tok", "https://push.example.net/x", "pub", "auth", txid, int64(1), expires, pushed, now}
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (collection_type, collection_id, push_resource) DO UPDATE`)).
+		WithArgs(int64(2), "calendar", int64(9), "tok", "https://push.example.net/x", "pub", "auth", int64(40), int64(1), expires).
+		WillReturnRows(sqlmock.NewRows(append(columns, "created")).AddRow(append(row(40, nil), false)...))
+	sub, created, err := repo.Register(context.Background(), PushSubscription{UserID: 2, CollectionType: "calendar", CollectionID: 9, Token: "tok",
+		PushResource: "https://push.example.net/x", PublicKey: "pub", AuthSecret: "auth", Cursor: ChangeCursor{TxID: 40, Seq: 1}, ExpiresAt: expires})
+	if err != nil || created || sub.ID != 5 || sub.LastPushedAt != nil {
+		t.Fatalf("Register() = %+v, %v, %v", sub, created, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`WHERE id = $1 AND cursor_txid = $2 AND cursor_seq = $3`)).
+		WithArgs(int64(5), int64(40), int64(1), int64(41), int64(2), now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if ok, err := repo.Advance(context.Background(), 5, ChangeCursor{TxID: 40, Seq: 1}, ChangeCursor{TxID: 41, Seq: 2}, &now); err != nil || ok {
+		t.Fatalf("Advance() = %v, %v, want false for a cursor another replica moved", ok, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM push_subscriptions WHERE expires_at > NOW()`)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(row(41, now)...))
+	list, err := repo.ListActive(context.Background())
+	if err != nil || len(list) != 1 || list[0].LastPushedAt == nil || list[0].Cursor.TxID != 41 {
+		t.Fatalf("ListActive() = %+v, %v", list, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM push_subscriptions WHERE token = $1`)).
+		WithArgs("gone").
+		WillReturnError(sql.ErrNoRows)
+	if sub, err := repo.GetByToken(context.Background(), "gone"); err != nil || sub != nil {
+		t.Fatalf("GetByToken() = %+v, %v, want nil", sub, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO application (key, value) VALUES ('vapid_private_key', $1)`)).
+		WithArgs("candidate").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("stored"))
+	if key, err := repo.VAPIDKey(context.Background(), "candidate"); err != nil || key != "stored" {
+		t.Fatalf("VAPIDKey() = %q, %v, want the stored key", key, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM push_subscriptions WHERE expires_at <= NOW()`)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	if n, err := repo.DeleteExpired(context.Background()); err != nil || n != 3 {
+		t.Fatalf("DeleteExpired() = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConferenceHookRepoUpsertLookupAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &conferenceHookRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"calendar_id", "url", "secret", "created_at", "updated_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO conference_hooks (calendar_id, url, secret)`)).
+		WithArgs(int64(3), "https://meet.example.com/hook", "s3cret").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(3), "https://meet.example.com/hook", "s3cret", now, now))
+	hook, err := repo.Upsert(context.Background(), ConferenceHook{CalendarID: 3, URL: "https://meet.example.com/hook", Secret: "s3cret"})
+	if err != nil || hook == nil || hook.URL != "https://meet.example.com/hook" || hook.Secret != "s3cret" {
+		t.Fatalf("Upsert() = %#v, %v", hook, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT calendar_id, url, secret, created_at, updated_at FROM conference_hooks WHERE calendar_id=$1`)).
+		WithArgs(int64(4)).
+		WillReturnError(sql.ErrNoRows)
+	if hook, err := repo.GetByCalendar(context.Background(), 4); err != nil || hook != nil {
+		t.Fatalf("GetByCalendar() = %#v, %v, want nil", hook, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM conference_hooks WHERE calendar_id=$1`)).
+		WithArgs(int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Delete(context.Background(), 3); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRetentionRepoExpireLogsDeletionsAndMarksTheRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &retentionRepo{pool: db}
+	cutoff := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	events := []Event{{UID: "a", ETag: "e1"}, {UID: "b", ETag: "e2"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL calcard.batch_ctag = 'on'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO retention_deletions (calendar_id, uid, summary, dtstart, dtend, cutoff)`)).
+		WithArgs(int64(5), sqlmock.AnyArg(), sqlmock.AnyArg(), cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = $1`)).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendar_retention SET last_run_at = NOW() WHERE calendar_id = $1`)).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if deleted, err := repo.Expire(context.Background(), 5, events, cutoff); err != nil || deleted != 2 {
+		t.Fatalf("Expire() = %d, %v, want 2", deleted, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendar_retention SET last_run_at = NOW() WHERE calendar_id = $1`)).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if deleted, err := repo.Expire(context.Background(), 5, nil, cutoff); err != nil || deleted != 0 {
+		t.Fatalf("Expire() with nothing to delete = %d, %v, want 0", deleted, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSchedulingResourceRepoUpsertAndReserve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &schedulingResourceRepo{pool: db}
+	now := time.Now().UTC()
+	columns := []string{"calendar_id", "email", "kind", "policy", "priority_organizers", "created_at", "updated_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO scheduling_resources (calendar_id, email, kind, policy, priority_organizers)`)).
+		WithArgs(int64(10), "room-a@example.com", "ROOM", ResourcePolicyPriority, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(10), "room-a@example.com", "ROOM", ResourcePolicyPriority, "{ceo@example.com}", now, now))
+	res, err := repo.Upsert(context.Background(), SchedulingResource{CalendarID: 10, Email: "Room-A@example.com", Kind: "ROOM", Policy: ResourcePolicyPriority, PriorityOrganizers: []string{"ceo@example.com"}})
+	if err != nil || res == nil || len(res.PriorityOrganizers) != 1 || res.PriorityOrganizers[0] != "ceo@example.com" {
+		t.Fatalf("Upsert() = %#v, %v", res, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO scheduling_resources`)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "scheduling_resources_email_key"})
+	if _, err := repo.Upsert(context.Background(), SchedulingResource{CalendarID: 11, Email: "room-a@example.com"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Upsert() with a taken email error = %v, want ErrConflict", err)
+	}
+
+	eventColumns := []string{"id", "calendar_id", "uid", "resource_name", "raw_ical", "etag", "summary", "description", "location", "dtstart", "dtend", "all_day", "last_modified"}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock(hashtext($1))`)).
+		WithArgs("resource:10").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM events WHERE calendar_id=$1 ORDER BY id`)).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows(eventColumns).AddRow(int64(1), int64(10), "old", "old", "BEGIN:VCALENDAR", "etag", nil, nil, nil, nil, nil, false, now))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM events WHERE calendar_id=$1 AND uid=$2`)).
+		WithArgs(int64(10), "old").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO events`)).
+		WillReturnRows(sqlmock.NewRows(eventColumns).AddRow(int64(2), int64(10), "new", "new", "BEGIN:VCALENDAR", "etag2", nil, nil, nil, nil, nil, false, now))
+	mock.ExpectCommit()
+
+	var seen []string
+	err = repo.Reserve(context.Background(), 10, func(bookings []Event) (ResourceReservation, error) {
+		for _, b := range bookings {
+			seen = append(seen, b.UID)
+		}
+		return ResourceReservation{Book: &Event{UID: "new", RawICAL: "BEGIN:VCALENDAR", ETag: "etag2"}, Release: []string{"old"}}, nil
+	})
+	if err != nil || len(seen) != 1 || seen[0] != "old" {
+		t.Fatalf("Reserve() saw %v, error = %v", seen, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestUsageStatsRepoInsertAndList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &usageStatsRepo{pool: db}
+	now := time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "collected_at", "users", "active_users", "calendars", "address_books", "events", "contacts", "client_families"}
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO usage_stats`)).
+		WithArgs(now, int64(5), int64(3), int64(4), int64(2), int64(40), int64(9), []byte(`{"ios":2}`)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), now, int64(5), int64(3), int64(4), int64(2), int64(40), int64(9), []byte(`{"ios":2}`)))
+	saved, err := repo.Insert(context.Background(), UsageSnapshot{
+		CollectedAt: now, Users: 5, ActiveUsers: 3, Calendars: 4, AddressBooks: 2, Events: 40, Contacts: 9,
+		ClientFamilies: map[string]int64{"ios": 2},
+	})
+	if err != nil || saved.ID != 1 || saved.ClientFamilies["ios"] != 2 {
+		t.Fatalf("Insert() = %+v, %v", saved, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM usage_stats ORDER BY collected_at DESC, id DESC LIMIT $1`)).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), now, int64(5), int64(3), int64(4), int64(2), int64(40), int64(9), []byte(`{}`)))
+	list, err := repo.List(context.Background(), 10)
+	if err != nil || len(list) != 1 || list[0].Events != 40 || list[0].ClientFamilies == nil {
+		t.Fatalf("List() = %+v, %v", list, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestUserRepoGetByEmailMatchesAliasesIgnoringCase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &userRepo{pool: db}
+	now := time.Now().UTC()
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE lower(primary_email) = lower($1)`)).
+		WithArgs("Ann.Work@Example.COM", "ann.work@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "oauth_subject", "primary_email", "created_at", "last_login_at", "onboarding_completed_at", "sync_hide_cancelled", "sync_prefs_updated_at", "locale", "timezone", "week_start", "internal", "group_ids", "email_aliases"}).
+			AddRow(int64(5), "oidc-5", "ann@example.com", now, now, nil, false, nil, "", "", nil, false, "{}", "{ann.work@example.com}"))
+	user, err := repo.GetByEmail(context.Background(), " Ann.Work@Example.COM ")
+	if err != nil || user == nil || user.ID != 5 || !user.HasEmail("mailto:ANN.WORK@example.com") || user.HasEmail("other@example.com") {
+		t.Fatalf("GetByEmail() = %+v, %v", user, err)
+	}
+
+	alias := &emailAliasRepo{pool: db}
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO user_email_aliases`)).
+		WithArgs(int64(5), "Ann.Home@Example.com", "ann.home@example.com", sqlmock.AnyArg(), false).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_user_email_aliases_verified"})
+	if _, err := alias.Create(context.Background(), 5, "Ann.Home@Example.com", "hash", false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Create() of a taken address error = %v, want ErrConflict", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestEventRevisionRepoListAsOf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &eventRevisionRepo{pool: db}
+	at := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	from := at.Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE calendar_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)`)).
+		WithArgs(int64(3), at).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "uid", "resource_name", "body", "etag", "summary", "location", "dtstart", "dtend", "all_day", "changed_by", "valid_from", "valid_to"}).
+			AddRow(int64(7), int64(3), "standup", "standup.ics", "BEGIN:VCALENDAR", "e1", "Standup", nil, from, nil, false, int64(2), from, nil))
+
+	revisions, err := repo.ListAsOf(context.Background(), 3, at)
+	if err != nil {
+		t.Fatalf("ListAsOf() error = %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("ListAsOf() = %+v, want one revision", revisions)
+	}
+	rev := revisions[0]
+	if rev.ResourceName != "standup.ics" || rev.RawICAL != "BEGIN:VCALENDAR" || *rev.Summary != "Standup" || rev.Location != nil ||
+		rev.ChangedBy == nil || *rev.ChangedBy != 2 || rev.ValidTo != nil || !rev.ValidFrom.Equal(from) {
+		t.Fatalf("ListAsOf() revision = %+v", rev)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestContactRevisionRepoListChangedSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &contactRevisionRepo{pool: db}
+	since := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE r.address_book_id = ANY($1) AND r.valid_from >= $2`)).
+		WithArgs(pq.Array([]int64{4, 5}), since, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"address_book_id", "uid", "resource_name", "display_name", "before", "after", "changed_by", "changed_at"}).
+			AddRow(int64(4), "ada", "ada.vcf", "Ada", "BEGIN:VCARD\r\nFN:Ada\r\nEND:VCARD", nil, int64(2), since.Add(2*time.Hour)).
+			AddRow(int64(5), "bob", "bob.vcf", nil, nil, "BEGIN:VCARD\r\nFN:Bob\r\nEND:VCARD", nil, since.Add(time.Hour)))
+
+	changes, err := repo.ListChangedSince(context.Background(), []int64{4, 5}, since, 100)
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("ListChangedSince() = %+v, %v", changes, err)
+	}
+	if c := changes[0]; c.After != "" || c.Before == "" || *c.DisplayName != "Ada" || c.ChangedBy == nil || *c.ChangedBy != 2 {
+		t.Fatalf("deletion = %+v", c)
+	}
+	if c := changes[1]; c.Before != "" || c.After == "" || c.DisplayName != nil || c.ChangedBy != nil {
+		t.Fatalf("creation = %+v", c)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM contact_revisions WHERE valid_to < $1`)).
+		WithArgs(since).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	if n, err := repo.DeleteClosedBefore(context.Background(), since); err != nil || n != 3 {
+		t.Fatalf("DeleteClosedBefore() = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestIdempotencyKeyRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &idempotencyKeyRepo{pool: db}
+	now := time.Now().UTC()
+	expires := now.Add(24 * time.Hour)
+	stale := now.Add(-10 * time.Minute)
+	columns := []string{"id", "scope", "key", "fingerprint", "status", "headers", "body", "expires_at", "created_at"}
+	key := IdempotencyKey{Scope: "user:2", Key: "k1", Fingerprint: "fp", ExpiresAt: expires}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (scope, key) DO UPDATE`)).
+		WithArgs("user:2", "k1", "fp", expires, stale).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(7), "user:2", "k1", "fp", nil, nil, nil, expires, now))
+	saved, claimed, err := repo.Claim(context.Background(), key, stale)
+	if err != nil || !claimed || saved.ID != 7 || saved.Status != 0 {
+		t.Fatalf("Claim() = %+v, %v, %v", saved, claimed, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE idempotency_keys SET status = $2, headers = $3, body = $4 WHERE id = $1`)).
+		WithArgs(int64(7), 201, `{"Location":["/api/calendars/1/events/a"]}`, []byte("{}")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Complete(context.Background(), 7, 201, map[string][]string{"Location": {"/api/calendars/1/events/a"}}, []byte("{}")); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (scope, key) DO UPDATE`)).
+		WithArgs("user:2", "k1", "fp", expires, stale).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM idempotency_keys WHERE scope = $1 AND key = $2`)).
+		WithArgs("user:2", "k1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(7), "user:2", "k1", "fp", int64(201), `{"Location":["/x"]}`, []byte("{}"), expires, now))
+	saved, claimed, err = repo.Claim(context.Background(), key, stale)
+	if err != nil || claimed || saved.Status != 201 || saved.Headers["Location"][0] != "/x" || string(saved.Body) != "{}" {
+		t.Fatalf("Claim() of a held key = %+v, %v, %v", saved, claimed, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE id = $1`)).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.Release(context.Background(), 7); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if n, err := repo.DeleteExpired(context.Background()); err != nil || n != 2 {
+		t.Fatalf("DeleteExpired() = %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCalendarTransferRepoTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &calendarTransferRepo{pool: db}
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.user_id, c.slug FROM calendars c WHERE c.id = $1 FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "slug"}).AddRow(int64(1), "team"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET user_id = $2 WHERE id = $1`)).
+		WithArgs(int64(7), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM acl_entries WHERE principal_href = ANY($1)`)).
+		WithArgs(pq.Array([]string{"/dav/principals/1/", "/dav/principals/2/"}), "/dav/calendars/7", "/dav/calendars/7/%").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO collection_tombstones`)).
+		WithArgs(int64(7), int64(1), sql.NullString{String: "team", Valid: true}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO calendar_transfers`)).
+		WithArgs(int64(7), int64(1), int64(2), "admin@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "calendar_id", "from_user_id", "from_email", "to_user_id", "to_email", "transferred_by", "transferred_at"}).
+			AddRow(int64(1), int64(7), int64(1), "leaver@example.com", int64(2), "successor@example.com", "admin@example.com", now))
+	mock.ExpectCommit()
+	transfer, err := repo.Transfer(ctx, 7, 2, "admin@example.com")
+	if err != nil || transfer.FromEmail != "leaver@example.com" || transfer.ToEmail != "successor@example.com" {
+		t.Fatalf("Transfer() = %#v, %v", transfer, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM calendars c WHERE c.id = $1 FOR UPDATE`)).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "slug"}).AddRow(int64(1), "team"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET user_id = $2 WHERE id = $1`)).
+		WithArgs(int64(7), int64(3)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "calendars_slug_ci_unique"})
+	mock.ExpectRollback()
+	if _, err := repo.Transfer(ctx, 7, 3, "admin@example.com"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Transfer() onto a taken slug error = %v, want ErrConflict", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM calendars c WHERE c.id = $1 FOR UPDATE`)).
+		WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "slug"}))
+	mock.ExpectRollback()
+	if _, err := repo.Transfer(ctx, 8, 2, "admin@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Transfer() of a missing calendar error = %v, want ErrNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAlarmDeliveryRepoClaimsAndRetries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &alarmDeliveryRepo{pool: db}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	lease := now.Add(5 * time.Minute)
+
+	mock.ExpectExec(regexp.QuoteMeta(`ON CONFLICT (provider_id, calendar_id, uid, alarm_id, fire_at) DO NOTHING`)).
+		WithArgs(int64(3), int64(1), int64(7), "standup", "0", now, "Standup", "Today 09:00", now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	queued, err := repo.Enqueue(ctx, AlarmDelivery{ProviderID: 3, UserID: 1, CalendarID: 7, UID: "standup", AlarmID: "0",
+		FireAt: now, Title: "Standup", Message: "Today 09:00", NextAttemptAt: now})
+	if err != nil || queued {
+		t.Fatalf("Enqueue() of a known alarm = %v, %v, want false", queued, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WithArgs(now, lease, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "provider_id", "user_id", "calendar_id", "uid", "alarm_id", "fire_at", "title", "message",
+			"status", "attempts", "last_error", "next_attempt_at", "sent_at", "created_at"}).
+			AddRow(int64(9), int64(3), int64(1), int64(7), "standup", "0", now, "Standup", "Today 09:00", "pending", 1, "", lease, nil, now))
+	claimed, err := repo.ClaimDue(ctx, now, lease, 50)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 || claimed[0].SentAt != nil {
+		t.Fatalf("ClaimDue() = %#v, %v", claimed, err)
+	}
+
+	retry := now.Add(time.Minute)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE alarm_deliveries`)).
+		WithArgs(int64(9), "gotify answered 502 Bad Gateway", &retry).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.MarkFailed(ctx, 9, "gotify answered 502 Bad Gateway", &retry); err != nil {
+		t.Fatalf("MarkFailed() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestQuotaRepoSetWarnedOnlyFromTheLevelSeen(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &quotaRepo{pool: db}
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`LEFT JOIN quota_warnings w ON w.user_id = u.id`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "used", "level"}).
+			AddRow(int64(1), int64(900), "warning").
+			AddRow(int64(2), int64(0), ""))
+	usage, err := repo.ListUsage(ctx)
+	if err != nil || len(usage) != 2 || usage[0] != (StorageUsage{UserID: 1, UsedBytes: 900, Warned: "warning"}) {
+		t.Fatalf("ListUsage() = %#v, %v", usage, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO quota_warnings (user_id, level) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`)).
+		WithArgs(int64(2), "warning").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if set, err := repo.SetWarned(ctx, 2, "", "warning"); err != nil || set {
+		t.Fatalf("SetWarned() after another replica = %v, %v, want false", set, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE quota_warnings SET level = $3, updated_at = NOW() WHERE user_id = $1 AND level = $2`)).
+		WithArgs(int64(1), "warning", "critical").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if set, err := repo.SetWarned(ctx, 1, "warning", "critical"); err != nil || !set {
+		t.Fatalf("SetWarned() = %v, %v, want true", set, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM quota_warnings WHERE user_id = $1 AND level = $2`)).
+		WithArgs(int64(1), "critical").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if set, err := repo.SetWarned(ctx, 1, "critical", ""); err != nil || !set {
+		t.Fatalf("SetWarned() clearing = %v, %v, want true", set, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestFocusRepoClaimPlanningOnlyFromThePlanSeen(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &focusRepo{pool: db}
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM focus_settings WHERE user_id = $1`)).
+		WithArgs(int64(2)).
+		WillReturnError(sql.ErrNoRows)
+	if settings, err := repo.GetSettings(ctx, 2); err != nil || settings != nil {
+		t.Fatalf("GetSettings() without opting in = %+v, %v", settings, err)
+	}
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	until := now.AddDate(0, 0, 7)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM focus_settings WHERE user_id = $1`)).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "min_minutes", "day_start_minute", "day_end_minute", "planned_until", "created_at", "updated_at"}).
+			AddRow(int64(1), 60, 540, 1020, nil, now, now))
+	settings, err := repo.GetSettings(ctx, 1)
+	if err != nil || settings == nil || settings.MinMinutes != 60 || settings.PlannedUntil != nil {
+		t.Fatalf("GetSettings() = %+v, %v", settings, err)
+	}
+
+	claim := regexp.QuoteMeta(`UPDATE focus_settings SET planned_until = $3 WHERE user_id = $1 AND planned_until IS NOT DISTINCT FROM $2`)
+	mock.ExpectExec(claim).
+		WithArgs(int64(1), nil, until).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if claimed, err := repo.ClaimPlanning(ctx, 1, settings.PlannedUntil, until); err != nil || !claimed {
+		t.Fatalf("ClaimPlanning() = %v, %v, want true", claimed, err)
+	}
+	mock.ExpectExec(claim).
+		WithArgs(int64(1), nil, until).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if claimed, err := repo.ClaimPlanning(ctx, 1, settings.PlannedUntil, until); err != nil || claimed {
+		t.Fatalf("ClaimPlanning() after another replica = %v, %v, want false", claimed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestFeatureFlagRepoSetTargetsUserOrGroup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	repo := &featureFlagRepo{pool: db}
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	columns := []string{"id", "flag", "user_id", "group_id", "enabled", "created_at", "updated_at"}
+	groupID := int64(4)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (flag, group_id) WHERE group_id IS NOT NULL DO UPDATE`)).
+		WithArgs("push", nil, &groupID, true).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(1), "push", nil, groupID, true, now, now))
+	saved, err := repo.Set(ctx, FeatureFlagOverride{Flag: "push", GroupID: &groupID, Enabled: true})
+	if err != nil || saved.UserID != nil || saved.GroupID == nil || *saved.GroupID != 4 {
+		t.Fatalf("Set() for a group = %+v, %v", saved, err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM feature_flag_overrides WHERE flag = $1 AND user_id = $2`)).
+		WithArgs("push", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if deleted, err := repo.Delete(ctx, "push", 7, 0); err != nil || deleted {
+		t.Fatalf("Delete() without an override = %v, %v", deleted, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE user_id = $1 OR group_id = ANY($2)`)).
+		WithArgs(int64(7), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(int64(2), "push", int64(7), nil, false, now, now))
+	list, err := repo.ListForUser(ctx, 7, []int64{4})
+	if err != nil || len(list) != 1 || list[0].UserID == nil || *list[0].UserID != 7 || list[0].Enabled {
+		t.Fatalf("ListForUser() = %+v, %v", list, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
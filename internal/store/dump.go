@@ -0,0 +1,523 @@
+package store
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DumpFormat names the archive written by Dump. Its version changes only
+// when an older Restore could not read the result.
+const (
+	DumpFormat        = "calcard-dump"
+	DumpFormatVersion = 1
+)
+
+// ErrRestoreNotEmpty is returned when Restore is pointed at a database that
+// already holds users.
+var ErrRestoreNotEmpty = errors.New("restore target already has data")
+
+// dumpTables lists the tables a dump carries, parents before the tables
+// that reference them. locks, idempotency_keys and collection_maintenance
+// are left out because they expire within a day, and application because
+// it belongs to the schema.
+var dumpTables = []string{
+	"users",
+	"user_email_aliases",
+	"calendars",
+	"address_books",
+	"content_bodies",
+	"events",
+	"event_revisions",
+	"contact_revisions",
+	"contacts",
+	"app_passwords",
+	"sessions",
+	"user_groups",
+	"user_group_members",
+	"acl_entries",
+	"deleted_resources",
+	"held_deletions",
+	"collection_syncs",
+	"client_repairs",
+	"collection_tombstones",
+	"freebusy_links",
+	"task_feed_links",
+	"booking_pages",
+	"conference_hooks",
+	"scheduling_resources",
+	"auth_events",
+	"impersonations",
+	"impersonation_requests",
+	"jobs",
+	"job_journal",
+	"locations",
+	"digest_settings",
+	"collection_notifications",
+	"push_subscriptions",
+	"event_links",
+	"usage_stats",
+	"calendar_retention",
+	"retention_deletions",
+	"calendar_transfers",
+	"notification_providers",
+	"alarm_deliveries",
+	"quota_warnings",
+	"focus_settings",
+	"focus_blocks",
+	"feature_flag_overrides",
+}
+
+// restoreEventHistory runs after a restore, which loads the archived event
+// history instead of recording the restored events as new revisions. Events
+// the archive has no open revision for, as in archives from before history
+// was kept, open one now, and history is taken to start at the archive's
+// earliest revision.
+const restoreEventHistory = `
+INSERT INTO event_revisions (calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by)
+SELECT calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by
+FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM event_revisions r WHERE r.calendar_id = e.calendar_id AND r.uid = e.uid AND r.valid_to IS NULL);
+UPDATE application SET value = LEAST(value::timestamptz, (SELECT MIN(valid_from) FROM event_revisions))::text
+WHERE key = 'event_history_since'`
+
+// restoreContactHistory does the same for contact history, so contacts
+// from an archive without it can be compared with their next change. Those
+// revisions are dated before any change is listed from.
+const restoreContactHistory = `
+INSERT INTO contact_revisions (address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, valid_from)
+SELECT address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, '-infinity'
+FROM contacts c
+WHERE NOT EXISTS (SELECT 1 FROM contact_revisions r WHERE r.address_book_id = c.address_book_id AND r.uid = c.uid AND r.valid_to IS NULL)`
+
+// dumpSkippedColumns are change-feed positions, which only mean something
+// in the database that assigned them, and content_bodies reference counts,
+// which the target's triggers recount as events and contacts are restored.
+var dumpSkippedColumns = map[string]bool{"change_txid": true, "change_seq": true, "ref_count": true}
+
+// Column kinds in an archive. They name values, not Postgres types, so
+// another backend can read the same archive.
+const (
+	dumpInteger   = "integer"
+	dumpFloat     = "float"
+	dumpBoolean   = "boolean"
+	dumpText      = "text"
+	dumpDate      = "date"
+	dumpTimestamp = "timestamp"
+	dumpJSON      = "json"
+	dumpTextList  = "text[]"
+)
+
+// DumpSummary reports what Dump wrote or Restore read.
+type DumpSummary struct {
+	SchemaVersion string
+	CreatedAt     time.Time
+	// Rows counts the rows of each table.
+	Rows map[string]int64
+}
+
+// dumpRecord is one line of an archive: the header, a table, a row of the
+// table before it, or the trailer.
+type dumpRecord struct {
+	Format    string            `json:"format,omitempty"`
+	Version   int               `json:"version,omitempty"`
+	Schema    string            `json:"schema,omitempty"`
+	CreatedAt *time.Time        `json:"createdAt,omitempty"`
+	Table     string            `json:"table,omitempty"`
+	Columns   []dumpColumn      `json:"columns,omitempty"`
+	Row       []json.RawMessage `json:"row,omitempty"`
+	End       *dumpEnd          `json:"end,omitempty"`
+}
+
+type dumpColumn struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// dumpEnd closes an archive, so a truncated one is never restored.
+type dumpEnd struct {
+	Rows int64 `json:"rows"`
+}
+
+type dumpQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Dump writes every user, collection, resource, share and token to w as a
+// gzip-compressed archive of JSON lines, read from one consistent snapshot.
+// Unlike pg_dump output it holds data only, so Restore can load it into a
+// database of the same or a newer schema.
+func (s *Store) Dump(ctx context.Context, w io.Writer) (*DumpSummary, error) {
+	tx, err := s.pool.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return writeDump(ctx, tx, dumpTables, w, time.Now().UTC())
+}
+
+// Restore loads an archive written by Dump into an empty database whose
+// schema is already in place, in a single transaction. Row IDs are kept,
+// and ID sequences are moved past them.
+func (s *Store) Restore(ctx context.Context, r io.Reader) (*DumpSummary, error) {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SET LOCAL calcard.restoring = 'on'`); err != nil {
+		return nil, err
+	}
+	summary, err := readDump(ctx, tx, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, restoreEventHistory); err != nil {
+		return nil, fmt.Errorf("restore event history: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, restoreContactHistory); err != nil {
+		return nil, fmt.Errorf("restore contact history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func writeDump(ctx context.Context, q dumpQuerier, tables []string, w io.Writer, now time.Time) (*DumpSummary, error) {
+	summary := &DumpSummary{CreatedAt: now, Rows: map[string]int64{}}
+	if err := q.QueryRowContext(ctx, `SELECT value FROM application WHERE key = 'version'`).Scan(&summary.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(dumpRecord{Format: DumpFormat, Version: DumpFormatVersion, Schema: summary.SchemaVersion, CreatedAt: &now}); err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, table := range tables {
+		n, err := dumpTable(ctx, q, table, enc)
+		if err != nil {
+			return nil, fmt.Errorf("dump %s: %w", table, err)
+		}
+		summary.Rows[table] = n
+		total += n
+	}
+	if err := enc.Encode(dumpRecord{End: &dumpEnd{Rows: total}}); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func dumpTable(ctx context.Context, q dumpQuerier, table string, enc *json.Encoder) (int64, error) {
+	columns, err := tableColumns(ctx, q, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("table not found")
+	}
+	if err := enc.Encode(dumpRecord{Table: table, Columns: columns}); err != nil {
+		return 0, err
+	}
+	exprs := make([]string, len(columns))
+	for i, col := range columns {
+		name := pq.QuoteIdentifier(col.Name)
+		switch col.Kind {
+		case dumpTextList:
+			exprs[i] = "array_to_json(" + name + ")::text"
+		case dumpJSON, dumpDate:
+			exprs[i] = name + "::text"
+		default:
+			exprs[i] = name
+		}
+	}
+	rows, err := q.QueryContext(ctx, "SELECT "+strings.Join(exprs, ", ")+" FROM "+pq.QuoteIdentifier(table)+" ORDER BY 1")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		row := make([]json.RawMessage, len(columns))
+		for i, col := range columns {
+			if row[i], err = encodeDumpValue(col.Kind, values[i]); err != nil {
+				return n, fmt.Errorf("column %s: %w", col.Name, err)
+			}
+		}
+		if err := enc.Encode(dumpRecord{Row: row}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// tableColumns returns the archived columns of a table in the current
+// schema, or none when it does not exist.
+func tableColumns(ctx context.Context, q dumpQuerier, table string) ([]dumpColumn, error) {
+	rows, err := q.QueryContext(ctx, `SELECT column_name, data_type, udt_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []dumpColumn
+	for rows.Next() {
+		var name, dataType, udtName string
+		if err := rows.Scan(&name, &dataType, &udtName); err != nil {
+			return nil, err
+		}
+		if dumpSkippedColumns[name] {
+			continue
+		}
+		kind := dumpKind(dataType, udtName)
+		if kind == "" {
+			return nil, fmt.Errorf("column %s has unsupported type %s", name, dataType)
+		}
+		columns = append(columns, dumpColumn{Name: name, Kind: kind})
+	}
+	return columns, rows.Err()
+}
+
+func dumpKind(dataType, udtName string) string {
+	switch dataType {
+	case "smallint", "integer", "bigint":
+		return dumpInteger
+	case "real", "double precision", "numeric":
+		return dumpFloat
+	case "boolean":
+		return dumpBoolean
+	case "text", "character varying", "character":
+		return dumpText
+	case "date":
+		return dumpDate
+	case "timestamp with time zone", "timestamp without time zone":
+		return dumpTimestamp
+	case "json", "jsonb":
+		return dumpJSON
+	case "ARRAY":
+		if udtName == "_text" || udtName == "_varchar" {
+			return dumpTextList
+		}
+	}
+	return ""
+}
+
+func encodeDumpValue(kind string, v any) (json.RawMessage, error) {
+	if v == nil {
+		return json.RawMessage("null"), nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	switch kind {
+	case dumpJSON, dumpTextList:
+		s, ok := v.(string)
+		if !ok || !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("unexpected %s value %T", kind, v)
+		}
+		return json.RawMessage(s), nil
+	case dumpTimestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("unexpected timestamp value %T", v)
+		}
+		v = t.UTC().Format(time.RFC3339Nano)
+	}
+	return json.Marshal(v)
+}
+
+func readDump(ctx context.Context, tx *sql.Tx, r io.Reader) (*DumpSummary, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a CalCard dump: %w", err)
+	}
+	dec := json.NewDecoder(zr)
+	var header dumpRecord
+	if err := dec.Decode(&header); err != nil || header.Format != DumpFormat || header.CreatedAt == nil {
+		return nil, errors.New("not a CalCard dump")
+	}
+	if header.Version != DumpFormatVersion {
+		return nil, fmt.Errorf("unsupported dump version %d", header.Version)
+	}
+	summary := &DumpSummary{SchemaVersion: header.Schema, CreatedAt: *header.CreatedAt, Rows: map[string]int64{}}
+
+	var populated bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users)`).Scan(&populated); err != nil {
+		return nil, err
+	}
+	if populated {
+		return nil, ErrRestoreNotEmpty
+	}
+
+	known := make(map[string]bool, len(dumpTables))
+	for _, table := range dumpTables {
+		known[table] = true
+	}
+	var (
+		table   string
+		columns []dumpColumn
+		insert  *sql.Stmt
+		total   int64
+		withIDs []string
+	)
+	defer func() {
+		if insert != nil {
+			insert.Close()
+		}
+	}()
+	for {
+		var rec dumpRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("dump is truncated")
+			}
+			return nil, err
+		}
+		switch {
+		case rec.End != nil:
+			if rec.End.Rows != total {
+				return nil, fmt.Errorf("dump holds %d rows, trailer says %d", total, rec.End.Rows)
+			}
+			for _, t := range withIDs {
+				quoted := pq.QuoteIdentifier(t)
+				if _, err := tx.ExecContext(ctx, `SELECT setval(pg_get_serial_sequence($1, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM `+quoted, t); err != nil {
+					return nil, fmt.Errorf("reset %s ids: %w", t, err)
+				}
+			}
+			return summary, nil
+		case rec.Table != "":
+			if !known[rec.Table] {
+				return nil, fmt.Errorf("dump has unknown table %s", rec.Table)
+			}
+			if _, seen := summary.Rows[rec.Table]; seen {
+				return nil, fmt.Errorf("dump repeats table %s", rec.Table)
+			}
+			if insert != nil {
+				insert.Close()
+				insert = nil
+			}
+			table, columns = rec.Table, rec.Columns
+			summary.Rows[table] = 0
+			if insert, err = prepareRestore(ctx, tx, table, columns); err != nil {
+				return nil, fmt.Errorf("restore %s: %w", table, err)
+			}
+			for _, col := range columns {
+				if col.Name == "id" && col.Kind == dumpInteger {
+					withIDs = append(withIDs, table)
+				}
+			}
+		case rec.Row != nil:
+			if insert == nil {
+				return nil, errors.New("dump has a row before its table")
+			}
+			if len(rec.Row) != len(columns) {
+				return nil, fmt.Errorf("restore %s: row has %d values, want %d", table, len(rec.Row), len(columns))
+			}
+			args := make([]any, len(columns))
+			for i, col := range columns {
+				if args[i], err = decodeDumpValue(col.Kind, rec.Row[i]); err != nil {
+					return nil, fmt.Errorf("restore %s.%s: %w", table, col.Name, err)
+				}
+			}
+			if _, err := insert.ExecContext(ctx, args...); err != nil {
+				return nil, fmt.Errorf("restore %s: %w", table, err)
+			}
+			summary.Rows[table]++
+			total++
+		default:
+			return nil, errors.New("dump has an unrecognized record")
+		}
+	}
+}
+
+// prepareRestore checks that the target has every archived column, with
+// the same kind, and prepares the insert for the table's rows.
+func prepareRestore(ctx context.Context, tx *sql.Tx, table string, columns []dumpColumn) (*sql.Stmt, error) {
+	target, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return nil, err
+	}
+	kinds := make(map[string]string, len(target))
+	for _, col := range target {
+		kinds[col.Name] = col.Kind
+	}
+	names := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, col := range columns {
+		kind, ok := kinds[col.Name]
+		if !ok {
+			return nil, fmt.Errorf("column %s does not exist here; upgrade CalCard before restoring", col.Name)
+		}
+		if kind != col.Kind {
+			return nil, fmt.Errorf("column %s is %s here but %s in the dump", col.Name, kind, col.Kind)
+		}
+		names[i] = pq.QuoteIdentifier(col.Name)
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return tx.PrepareContext(ctx, "INSERT INTO "+pq.QuoteIdentifier(table)+" ("+strings.Join(names, ", ")+") VALUES ("+strings.Join(params, ", ")+")")
+}
+
+func decodeDumpValue(kind string, raw json.RawMessage) (any, error) {
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	switch kind {
+	case dumpInteger:
+		var n int64
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	case dumpFloat:
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return f, err
+	case dumpBoolean:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	case dumpText, dumpDate:
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case dumpTimestamp:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case dumpJSON:
+		return string(raw), nil
+	case dumpTextList:
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		return pq.Array(list), nil
+	}
+	return nil, fmt.Errorf("unknown kind %s", kind)
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestDumpTablesCoverSchema(t *testing.T) {
+	schema, err := os.ReadFile("../../db.sql")
+	if err != nil {
+		t.Fatalf("read db.sql: %v", err)
+	}
+	for _, m := range regexp.MustCompile(`CREATE TABLE (?:IF NOT EXISTS )?(\w+)`).FindAllStringSubmatch(string(schema), -1) {
+		if table := m[1]; table != "application" && table != "locks" && table != "idempotency_keys" && table != "collection_maintenance" && !slices.Contains(dumpTables, table) {
+			t.Errorf("table %s is missing from dumpTables", table)
+		}
+	}
+}
+
+func TestDumpAndRestore(t *testing.T) {
+	ctx := context.Background()
+	columnsQuery := regexp.QuoteMeta(`SELECT column_name, data_type, udt_name FROM information_schema.columns`)
+	columnRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"column_name", "data_type", "udt_name"}).
+			AddRow("id", "bigint", "int8").
+			AddRow("uid", "text", "text").
+			AddRow("birthday", "date", "date").
+			AddRow("email_keys", "ARRAY", "_text").
+			AddRow("change_txid", "xid8", "xid8").
+			AddRow("last_modified", "timestamp with time zone", "timestamptz")
+	}
+	modified := time.Date(2026, 3, 1, 9, 15, 0, 123000000, time.UTC)
+
+	src, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer src.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM application`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("v1.1.28"))
+	mock.ExpectQuery(columnsQuery).WithArgs("contacts").WillReturnRows(columnRows())
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id", "uid", "birthday"::text, array_to_json("email_keys")::text, "last_modified" FROM "contacts" ORDER BY 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "uid", "birthday", "email_keys", "last_modified"}).
+			AddRow(int64(4), []byte("ann"), "1990-05-17", `["ann@example.com"]`, modified).
+			AddRow(int64(9), "bob", nil, `[]`, modified))
+
+	var archive bytes.Buffer
+	summary, err := writeDump(ctx, src, []string{"contacts"}, &archive, modified)
+	if err != nil {
+		t.Fatalf("writeDump() error = %v", err)
+	}
+	if summary.SchemaVersion != "v1.1.28" || summary.Rows["contacts"] != 2 {
+		t.Fatalf("writeDump() summary = %+v", summary)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("dump expectations: %v", err)
+	}
+
+	dst, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer dst.Close()
+	restore := func(data []byte) (*DumpSummary, error) {
+		tx, err := dst.Begin()
+		if err != nil {
+			t.Fatalf("Begin() error = %v", err)
+		}
+		mock.ExpectRollback()
+		defer tx.Rollback()
+		return readDump(ctx, tx, bytes.NewReader(data))
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM users)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(columnsQuery).WithArgs("contacts").WillReturnRows(columnRows())
+	insert := mock.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO "contacts" ("id", "uid", "birthday", "email_keys", "last_modified") VALUES ($1, $2, $3, $4, $5)`))
+	insert.ExpectExec().WithArgs(int64(4), "ann", "1990-05-17", pq.Array([]string{"ann@example.com"}), modified).WillReturnResult(sqlmock.NewResult(4, 1))
+	insert.ExpectExec().WithArgs(int64(9), "bob", nil, pq.Array([]string{}), modified).WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT setval(pg_get_serial_sequence($1, 'id'), COALESCE(MAX(id), 0) + 1, false) FROM "contacts"`)).
+		WithArgs("contacts").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	restored, err := restore(archive.Bytes())
+	if err != nil {
+		t.Fatalf("readDump() error = %v", err)
+	}
+	if restored.SchemaVersion != "v1.1.28" || !restored.CreatedAt.Equal(modified) || restored.Rows["contacts"] != 2 {
+		t.Fatalf("readDump() summary = %+v", restored)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("restore expectations: %v", err)
+	}
+
+	// A populated target and a file that is not a dump are both refused.
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM users)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	if _, err := restore(archive.Bytes()); !errors.Is(err, ErrRestoreNotEmpty) {
+		t.Fatalf("readDump() into a populated database error = %v", err)
+	}
+	mock.ExpectBegin()
+	if _, err := restore([]byte("not gzip")); err == nil || !strings.Contains(err.Error(), "not a CalCard dump") {
+		t.Fatalf("readDump() of garbage error = %v", err)
+	}
+}
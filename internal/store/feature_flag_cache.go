@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// featureFlagCacheTTL is how long a user's overrides are kept before they
+// are loaded again. Changes made through the same Store apply at once;
+// other replicas see them once their entries expire.
+const featureFlagCacheTTL = 30 * time.Second
+
+// maxCachedFeatureFlagUsers bounds the cache; once it is full the entries
+// are dropped and it fills again.
+const maxCachedFeatureFlagUsers = 10000
+
+// featureFlagCache remembers each user's overrides for a short while, since
+// flags are resolved on DAV writes and push PROPFINDs. Set and Delete clear
+// it, as any override can change what any user gets.
+type featureFlagCache struct {
+	FeatureFlagRepository
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedFeatureFlags
+	// generation counts the writes, so a load that raced one is not cached.
+	generation uint64
+}
+
+type cachedFeatureFlags struct {
+	overrides []FeatureFlagOverride
+	expiresAt time.Time
+}
+
+func newFeatureFlagCache(repo FeatureFlagRepository, ttl time.Duration) *featureFlagCache {
+	return &featureFlagCache{FeatureFlagRepository: repo, ttl: ttl, now: time.Now, entries: map[string]cachedFeatureFlags{}}
+}
+
+func (c *featureFlagCache) ListForUser(ctx context.Context, userID int64, groupIDs []int64) ([]FeatureFlagOverride, error) {
+	// A user who joins or leaves a group gets a new key, and so their
+	// groups' overrides.
+	key := fmt.Sprint(userID, groupIDs)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.overrides, nil
+	}
+
+	overrides, err := c.FeatureFlagRepository.ListForUser(ctx, userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		if len(c.entries) >= maxCachedFeatureFlagUsers {
+			c.entries = map[string]cachedFeatureFlags{}
+		}
+		c.entries[key] = cachedFeatureFlags{overrides: overrides, expiresAt: c.now().Add(c.ttl)}
+	}
+	return overrides, nil
+}
+
+func (c *featureFlagCache) Set(ctx context.Context, override FeatureFlagOverride) (*FeatureFlagOverride, error) {
+	defer c.clear()
+	return c.FeatureFlagRepository.Set(ctx, override)
+}
+
+func (c *featureFlagCache) Delete(ctx context.Context, flag string, userID, groupID int64) (bool, error) {
+	defer c.clear()
+	return c.FeatureFlagRepository.Delete(ctx, flag, userID, groupID)
+}
+
+func (c *featureFlagCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cachedFeatureFlags{}
+	c.generation++
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type countingFeatureFlags struct {
+	FeatureFlagRepository
+	overrides []FeatureFlagOverride
+	loads     int
+}
+
+func (f *countingFeatureFlags) ListForUser(ctx context.Context, userID int64, groupIDs []int64) ([]FeatureFlagOverride, error) {
+	f.loads++
+	return f.overrides, nil
+}
+
+func (f *countingFeatureFlags) Set(ctx context.Context, override FeatureFlagOverride) (*FeatureFlagOverride, error) {
+	f.overrides = append(f.overrides, override)
+	return &override, nil
+}
+
+func TestFeatureFlagCacheLoadsOncePerTTLUntilAWrite(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingFeatureFlags{}
+	now := time.Unix(1000, 0)
+	cache := newFeatureFlagCache(repo, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := cache.ListForUser(ctx, 1, []int64{10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.loads != 1 {
+		t.Fatalf("loads = %d, want 1", repo.loads)
+	}
+	if _, err := cache.ListForUser(ctx, 1, []int64{10, 11}); err != nil || repo.loads != 2 {
+		t.Fatalf("loads = %d, %v; joining a group must load the overrides again", repo.loads, err)
+	}
+
+	if _, err := cache.Set(ctx, FeatureFlagOverride{Flag: "push", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.ListForUser(ctx, 1, []int64{10})
+	if err != nil || len(got) != 1 || repo.loads != 3 {
+		t.Fatalf("ListForUser() after Set = %v, %v with %d loads", got, err, repo.loads)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := cache.ListForUser(ctx, 1, []int64{10}); err != nil || repo.loads != 4 {
+		t.Fatalf("loads = %d, %v; an expired entry must be loaded again", repo.loads, err)
+	}
+}
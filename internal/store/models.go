@@ -1,6 +1,13 @@
 package store
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/util"
+)
 
 // User represents a person authenticated via OAuth.
 type User struct {
@@ -10,6 +17,360 @@ type User struct {
 	CreatedAt             time.Time
 	LastLoginAt           time.Time
 	OnboardingCompletedAt *time.Time
+	// SyncHideCancelled withholds cancelled events, and events the user has
+	// declined, from CalDAV calendar-query and sync-collection reports.
+	SyncHideCancelled bool
+	// SyncPrefsUpdatedAt is when SyncHideCancelled last changed. Sync tokens
+	// issued before it are rejected so clients re-fetch the full collection.
+	SyncPrefsUpdatedAt *time.Time
+	// Locale is a BCP 47 language tag such as "en-GB"; empty uses the
+	// server default.
+	Locale string
+	// Timezone is an IANA timezone name; empty means UTC.
+	Timezone string
+	// WeekStart is the first day of the user's week; nil follows Locale.
+	WeekStart *time.Weekday
+	// GroupIDs are the sharing groups the user belongs to.
+	GroupIDs []int64
+	// EmailAliases are the user's verified alias addresses. They sign in
+	// and match scheduling addresses like PrimaryEmail.
+	EmailAliases []string
+	// Internal marks an account the server uses itself, such as the
+	// synthetic probe's. It is left out of user listings, usage statistics
+	// and digests, and grants to DAV:authenticated do not reach it.
+	Internal bool
+}
+
+// Emails returns the primary address followed by the verified aliases.
+func (u User) Emails() []string {
+	return append([]string{u.PrimaryEmail}, u.EmailAliases...)
+}
+
+// HasEmail reports whether addr, with or without a mailto: prefix, is one of
+// the user's addresses, ignoring case and Unicode normalization.
+func (u User) HasEmail(addr string) bool {
+	key := util.EmailLookupKey(addr)
+	if key == "" {
+		return false
+	}
+	for _, email := range u.Emails() {
+		if util.EmailLookupKey(email) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailAlias is an additional address a user has claimed. It takes effect
+// once VerifiedAt is set.
+type EmailAlias struct {
+	ID         int64
+	UserID     int64
+	Email      string
+	VerifiedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// UserGroup is a named group of users that calendars and address books can
+// be shared with in one step. Members get whatever the group is granted.
+type UserGroup struct {
+	ID          int64
+	OwnerUserID int64
+	Name        string
+	MemberIDs   []int64
+	CreatedAt   time.Time
+}
+
+// Location is a named place a user reuses across events. Latitude and
+// Longitude are set together or not at all.
+type Location struct {
+	ID        int64
+	UserID    int64
+	Name      string
+	Address   string
+	Latitude  *float64
+	Longitude *float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// EventLink is an expiring read-only URL for one event. Anyone holding
+// Token can download the event until ExpiresAt, unless the link is revoked
+// or its creator loses access to the event first.
+type EventLink struct {
+	ID             int64
+	UserID         int64
+	CalendarID     int64
+	UID            string
+	Token          string
+	ExpiresAt      time.Time
+	AccessCount    int64
+	LastAccessedAt *time.Time
+	CreatedAt      time.Time
+}
+
+// CollectionTombstone records that a user lost a calendar or address book,
+// because it was deleted or no longer shared with them. Slug and OwnerUserID
+// rebuild the href the user knew the collection by.
+type CollectionTombstone struct {
+	Cursor         ChangeCursor
+	CollectionType string // "calendar" or "addressbook"
+	CollectionID   int64
+	UserID         int64
+	OwnerUserID    int64
+	Slug           *string
+	DeletedAt      time.Time
+}
+
+// CollectionMaintenance puts a calendar or address book into maintenance
+// while an admin runs a bulk operation on it: clients can still read it,
+// but their writes are refused until it ends or ExpiresAt passes.
+type CollectionMaintenance struct {
+	CollectionType string // "calendar" or "addressbook"
+	CollectionID   int64
+	Reason         string
+	// StartedBy is the email of the admin who started it.
+	StartedBy string
+	StartedAt time.Time
+	ExpiresAt time.Time
+}
+
+// CalendarTransfer records a calendar handed from one owner to another.
+// Users are kept by email too, so the record outlives their accounts.
+type CalendarTransfer struct {
+	ID         int64
+	CalendarID int64
+	FromUserID int64
+	FromEmail  string
+	ToUserID   int64
+	ToEmail    string
+	// TransferredBy is the email of the admin who made the transfer.
+	TransferredBy string
+	TransferredAt time.Time
+}
+
+// Notification provider kinds.
+const (
+	ProviderGotify   = "gotify"
+	ProviderNtfy     = "ntfy"
+	ProviderPushover = "pushover"
+	ProviderWebhook  = "webhook"
+)
+
+// NotificationProvider is a service a user has their event alarms pushed
+// to. What URL, Token and Recipient hold depends on Kind: the Gotify server
+// and application token, the ntfy topic URL and optional access token, the
+// Pushover application token and user key, or the webhook URL and optional
+// bearer token.
+type NotificationProvider struct {
+	ID        int64
+	UserID    int64
+	Kind      string
+	Name      string
+	URL       string
+	Token     string
+	Recipient string
+	CreatedAt time.Time
+}
+
+// Alarm delivery statuses.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// AlarmDelivery is one alarm of one event occurrence sent to one provider.
+// A pending delivery is sent at NextAttemptAt; Attempts counts the tries so
+// far and LastError describes the last failed one.
+type AlarmDelivery struct {
+	ID            int64
+	ProviderID    int64
+	UserID        int64
+	CalendarID    int64
+	UID           string
+	AlarmID       string
+	FireAt        time.Time
+	Title         string
+	Message       string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// StorageUsage is the data a user keeps, with the quota warning they were
+// last sent: "warning", "critical", or "" for none.
+type StorageUsage struct {
+	UserID    int64
+	UsedBytes int64
+	Warned    string
+}
+
+// CollectionNotification subscribes a user to email about changes other
+// users make in one calendar or address book. Cursor is the position in
+// the change feed up to which they have been told.
+type CollectionNotification struct {
+	UserID         int64
+	CollectionType string // "calendar" or "addressbook"
+	CollectionID   int64
+	Cursor         ChangeCursor
+	LastNotifiedAt *time.Time
+	CreatedAt      time.Time
+}
+
+// PushSubscription is a WebDAV-Push subscription: a Web Push endpoint a
+// client registered to hear when one calendar or address book changes.
+// Cursor is the position in the change feed up to which it has been told.
+type PushSubscription struct {
+	ID             int64
+	UserID         int64
+	CollectionType string // "calendar" or "addressbook"
+	CollectionID   int64
+	// Token names the subscription's URL, through which the client removes
+	// it.
+	Token        string
+	PushResource string
+	// PublicKey and AuthSecret are the client's p256dh key and auth secret,
+	// base64url-encoded, that push messages are encrypted for.
+	PublicKey    string
+	AuthSecret   string
+	Cursor       ChangeCursor
+	ExpiresAt    time.Time
+	LastPushedAt *time.Time
+	CreatedAt    time.Time
+}
+
+// IdempotencyKey is a client's Idempotency-Key for a REST write, with the
+// response to replay when the request is retried. Scope keeps one client's
+// keys apart from another's, and Fingerprint identifies the request the key
+// was first used with. Status is zero while that request is still running.
+type IdempotencyKey struct {
+	ID          int64
+	Scope       string
+	Key         string
+	Fingerprint string
+	Status      int
+	Headers     map[string][]string
+	Body        []byte
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestSettings schedule a user's agenda digest email. It is sent at
+// SendMinute minutes past midnight in the user's timezone, every day or,
+// for a weekly digest, on Weekday.
+type DigestSettings struct {
+	UserID     int64
+	Frequency  string
+	SendMinute int
+	Weekday    time.Weekday
+	// WorkingDaysOnly skips daily digests on the weekend of the user's
+	// region.
+	WorkingDaysOnly bool
+	LastSentAt      *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// FocusSettings opt a user in to focus time: the free stretches of at least
+// MinMinutes within their protected hours, DayStartMinute to DayEndMinute
+// in their timezone, on their working days, are blocked as tentative events
+// on their default calendar.
+type FocusSettings struct {
+	UserID         int64
+	MinMinutes     int
+	DayStartMinute int
+	DayEndMinute   int
+	// PlannedUntil is the end of the last day planned. Days are planned
+	// once, so blocks the user deletes stay deleted.
+	PlannedUntil *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// FocusBlock is one focus time event created for a user.
+type FocusBlock struct {
+	ID         int64
+	UserID     int64
+	CalendarID int64
+	UID        string
+	Start      time.Time
+	End        time.Time
+	CreatedAt  time.Time
+}
+
+// FeatureFlagOverride turns a feature flag on or off for one user, or for
+// the members of a group, whatever the instance-wide setting. Exactly one
+// of UserID and GroupID is set.
+type FeatureFlagOverride struct {
+	ID        int64
+	Flag      string
+	UserID    *int64
+	GroupID   *int64
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// UsageSnapshot is one set of server-wide counts. It holds no names,
+// addresses or content, only totals.
+type UsageSnapshot struct {
+	ID           int64
+	CollectedAt  time.Time
+	Users        int64
+	ActiveUsers  int64
+	Calendars    int64
+	AddressBooks int64
+	Events       int64
+	Contacts     int64
+	// ClientFamilies counts recently syncing devices by client family,
+	// such as "davx5" or "apple".
+	ClientFamilies map[string]int64
+}
+
+// UserPrincipalHref is the DAV principal URL of a user.
+func UserPrincipalHref(userID int64) string {
+	return "/dav/principals/" + strconv.FormatInt(userID, 10) + "/"
+}
+
+// GroupPrincipalHref is the ACL principal URL of a sharing group.
+func GroupPrincipalHref(groupID int64) string {
+	return "/dav/principals/groups/" + strconv.FormatInt(groupID, 10) + "/"
+}
+
+// ACLPrincipals returns the ACL principals whose grants and denials apply to
+// user: everyone, authenticated users, the user and the user's groups. A
+// nil user is anonymous and only matches DAV:all, and an internal one is not
+// counted among authenticated users.
+func ACLPrincipals(user *User) []string {
+	principals := []string{"DAV:all"}
+	if user == nil {
+		return principals
+	}
+	if !user.Internal {
+		principals = append(principals, "DAV:authenticated")
+	}
+	principals = append(principals, UserPrincipalHref(user.ID))
+	for _, id := range user.GroupIDs {
+		principals = append(principals, GroupPrincipalHref(id))
+	}
+	return principals
+}
+
+// BootstrapSubject is the subject of a user the bootstrap file or an admin
+// created before they ever signed in. Their first sign-in with that email
+// claims the account; see UserRepository.UpsertOAuthUser.
+func BootstrapSubject(email string) string {
+	return "bootstrap:" + strings.ToLower(strings.TrimSpace(email))
 }
 
 // Calendar is a CalDAV calendar belonging to a user.
@@ -98,6 +459,8 @@ type CalendarAccess struct {
 	Editor             bool
 	Privileges         CalendarPrivileges
 	PrivilegesResolved bool
+	// EventCount is only filled in by ListAccessiblePage.
+	EventCount *int
 }
 
 func (c CalendarAccess) EffectivePrivileges() CalendarPrivileges {
@@ -202,8 +565,26 @@ type AppPassword struct {
 	ExpiresAt  *time.Time
 	RevokedAt  *time.Time
 	LastUsedAt *time.Time
+	// LastUsedUserAgent and LastUsedIP describe the device that last
+	// authenticated with the password.
+	LastUsedUserAgent *string
+	LastUsedIP        *string
+	// APIRole limits what the password may do through the REST API; DAV
+	// access is governed by collection shares alone.
+	APIRole string
 }
 
+// REST API roles of an AppPassword, each allowing what the one before it
+// does.
+const (
+	// APIRoleViewer allows only requests that do not change data.
+	APIRoleViewer = "viewer"
+	// APIRoleEditor also allows changing events, contacts and collections.
+	APIRoleEditor = "editor"
+	// APIRoleAdmin also allows managing shares, groups and devices.
+	APIRoleAdmin = "admin"
+)
+
 // DeletedResource tracks tombstones for sync reporting.
 type DeletedResource struct {
 	ID           int64
@@ -214,6 +595,233 @@ type DeletedResource struct {
 	DeletedAt    time.Time
 }
 
+// OrphanedResource is an event or contact whose calendar or address book no
+// longer exists.
+type OrphanedResource struct {
+	ResourceType string // "event" or "contact"
+	CollectionID int64
+	UID          string
+	ResourceName string
+}
+
+// ChangeCursor is a position in the change feed: the transaction that made a
+// change and its place in the shared change sequence. The zero cursor is the
+// start of the feed.
+type ChangeCursor struct {
+	TxID int64
+	Seq  int64
+}
+
+// Change is the latest write to one event or contact, or its deletion, as
+// listed by the change feed.
+type Change struct {
+	Cursor       ChangeCursor
+	ResourceType string // "event" or "contact"
+	CollectionID int64
+	UID          string
+	ResourceName string
+	ETag         string // empty for deletions
+	// Created is set when the latest write created the resource rather than
+	// updating it.
+	Created   bool
+	Deleted   bool
+	ChangedAt time.Time
+	// ChangedBy is the user the change was made for, or zero when the
+	// server made it or the content did not change.
+	ChangedBy int64
+}
+
+// HeldDeletion is a DAV DELETE withheld by mass-deletion protection. The
+// resource stays in its collection until the owner confirms or releases it.
+type HeldDeletion struct {
+	ID           int64
+	ResourceType string // "event" or "contact"
+	CollectionID int64
+	UID          string
+	ResourceName string
+	OwnerID      int64
+	DeletedBy    int64
+	Client       string
+	HeldAt       time.Time
+}
+
+// CollectionSync records the most recent REPORT one device made against a
+// calendar or address book. A device is its app password when known, falling
+// back to its User-Agent.
+type CollectionSync struct {
+	CollectionType   string // "calendar" or "addressbook"
+	CollectionID     int64
+	UserID           int64
+	DeviceKey        string
+	AppPasswordID    *int64
+	AppPasswordLabel string
+	UserAgent        string
+	LastSyncedAt     time.Time
+	SyncCount        int64
+}
+
+// ClientRepair counts the times a user's device sent data that had to be
+// repaired in one way, such as by adding a missing PRODID.
+type ClientRepair struct {
+	UserID           int64
+	DeviceKey        string
+	AppPasswordID    *int64
+	AppPasswordLabel string
+	UserAgent        string
+	Repair           string
+	Count            int64
+	// LastResource is the path of the last resource repaired.
+	LastResource string
+	FirstSeenAt  time.Time
+	LastSeenAt   time.Time
+}
+
+// CollectionUsage summarizes the resources stored in a collection.
+type CollectionUsage struct {
+	ResourceCount int64
+	DataSize      int64
+}
+
+// FreeBusyLink is a user's public free/busy URL. Anyone holding Token can
+// read when the user is busy over the next WindowDays days, but nothing else.
+type FreeBusyLink struct {
+	UserID     int64
+	Token      string
+	WindowDays int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// TaskFeedLink is a user's task feed URL. Anyone holding Token can read the
+// user's open tasks, but nothing else.
+type TaskFeedLink struct {
+	UserID    int64
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ConferenceHook is the webhook a calendar calls to provision a meeting link
+// for events that ask for one. When Secret is set, requests are signed with it.
+type ConferenceHook struct {
+	CalendarID int64
+	URL        string
+	Secret     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// RetentionPolicy makes a calendar delete its events once they have been
+// over for Months months.
+type RetentionPolicy struct {
+	CalendarID int64
+	Months     int
+	// LastRunAt is when the policy was last enforced.
+	LastRunAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RetentionDeletion records an event a retention policy deleted.
+type RetentionDeletion struct {
+	ID         int64
+	CalendarID int64
+	UID        string
+	Summary    *string
+	DTStart    *time.Time
+	DTEnd      *time.Time
+	// Cutoff is the time the event had to have ended by.
+	Cutoff    time.Time
+	DeletedAt time.Time
+}
+
+// EventRevision is a version of an event as it stood from ValidFrom until
+// ValidTo, or until now while ValidTo is nil.
+type EventRevision struct {
+	ID           int64
+	CalendarID   int64
+	UID          string
+	ResourceName string
+	RawICAL      string
+	ETag         string
+	Summary      *string
+	Location     *string
+	DTStart      *time.Time
+	DTEnd        *time.Time
+	AllDay       bool
+	// ChangedBy is the user whose write made this revision, when known.
+	ChangedBy *int64
+	ValidFrom time.Time
+	ValidTo   *time.Time
+}
+
+// ContactChange is a change to a contact found in its revision history.
+// Before is the vCard the change replaced, empty when it created the
+// contact, and After the vCard it left, empty when it deleted it.
+type ContactChange struct {
+	AddressBookID int64
+	UID           string
+	ResourceName  string
+	DisplayName   *string
+	Before        string
+	After         string
+	// ChangedBy is the user who made the change, when known.
+	ChangedBy *int64
+	ChangedAt time.Time
+}
+
+// Scheduling policies of a SchedulingResource.
+const (
+	// ResourcePolicyFirstCome declines any request that overlaps a booking.
+	ResourcePolicyFirstCome = "first-come"
+	// ResourcePolicyPriority lets organizers earlier in PriorityOrganizers
+	// take the resource from organizers later in the list or not in it.
+	ResourcePolicyPriority = "priority"
+)
+
+// SchedulingResource makes a calendar a bookable room or other resource.
+// Events listing Email as an attendee are booked into the calendar when it is
+// free and declined when it is not. Kind is the attendee CUTYPE, ROOM or
+// RESOURCE.
+type SchedulingResource struct {
+	CalendarID         int64
+	Email              string
+	Kind               string
+	Policy             string
+	PriorityOrganizers []string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// ResourceReservation is what a reservation writes to a resource calendar:
+// a booking to store, and the UIDs of bookings to remove.
+type ResourceReservation struct {
+	Book    *Event
+	Release []string
+}
+
+// BookingPage is a public appointment page. Visitors can book DurationMinutes
+// slots on Weekdays (bit 0 is Sunday) between DayStartMinute and DayEndMinute
+// in Timezone, within WindowDays and no sooner than NoticeMinutes from now,
+// wherever the owner is free for BufferMinutes either side.
+type BookingPage struct {
+	ID              int64
+	UserID          int64
+	CalendarID      int64
+	Slug            string
+	Title           string
+	Description     string
+	DurationMinutes int
+	BufferMinutes   int
+	NoticeMinutes   int
+	WindowDays      int
+	DayStartMinute  int
+	DayEndMinute    int
+	Weekdays        int
+	Timezone        string
+	CreatedAt       time.Time
+}
+
 // Session represents a database-backed user session.
 type Session struct {
 	ID         string
@@ -225,6 +833,117 @@ type Session struct {
 	LastSeenAt time.Time
 }
 
+// Outcomes of an AuthEvent.
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+	// AuthOutcomeLocked is a correct password refused because the account
+	// was locked against new addresses.
+	AuthOutcomeLocked = "locked"
+	// AuthOutcomeImpersonated marks an admin starting to act as the user.
+	AuthOutcomeImpersonated = "impersonated"
+)
+
+// AuthEvent is a DAV Basic-auth sign-in, or a refused one, for a user.
+type AuthEvent struct {
+	ID            int64
+	UserID        int64
+	AppPasswordID *int64
+	Outcome       string
+	// NewAddress marks the first successful sign-in from IPAddress.
+	NewAddress bool
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time
+}
+
+// Scopes of an Impersonation.
+const (
+	// ImpersonationRead allows only requests that do not change data.
+	ImpersonationRead  = "read"
+	ImpersonationWrite = "write"
+)
+
+// Impersonation lets an admin act as another user for a limited time,
+// without their password, to debug their sync.
+type Impersonation struct {
+	ID int64
+	// AdminUserID is nil once the admin's account is deleted; AdminEmail
+	// keeps who it was.
+	AdminUserID *int64
+	AdminEmail  string
+	UserID      int64
+	Scope       string
+	Reason      string
+	TokenHash   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	EndedAt     *time.Time
+}
+
+// Active reports whether the impersonation can still be used at now.
+func (i Impersonation) Active(now time.Time) bool {
+	return i.EndedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// ImpersonationRequest is one request made while impersonating.
+type ImpersonationRequest struct {
+	ID              int64
+	ImpersonationID int64
+	Method          string
+	Path            string
+	Status          int
+	IPAddress       string
+	UserAgent       string
+	CreatedAt       time.Time
+}
+
+// Statuses of a Job.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	// JobInterrupted is a job the server restarted in the middle of.
+	JobInterrupted = "interrupted"
+	// JobAborted is a job its owner stopped before it finished.
+	JobAborted = "aborted"
+)
+
+// Job is a long-running background operation and its progress.
+type Job struct {
+	ID        string
+	UserID    int64
+	Kind      string
+	Status    string
+	Total     int
+	Processed int
+	// Errors lists the items that failed without failing the job.
+	Errors []JobItemError
+	// Result is the operation's JSON result once it succeeded.
+	Result     json.RawMessage
+	Error      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// JournalEntry is one write a job intends to make, recorded before it is
+// applied so the job can be resumed after a crash. AppliedAt is set once
+// the write has been made or has failed.
+type JournalEntry struct {
+	JobID        string
+	Seq          int
+	CollectionID int64
+	Payload      string
+	AppliedAt    *time.Time
+}
+
+// JobItemError is one item a job could not process.
+type JobItemError struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
 // Lock represents a WebDAV lock on a resource (RFC 4918).
 type Lock struct {
 	ID             int64
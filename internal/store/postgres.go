@@ -2,10 +2,15 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"math"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,32 +21,50 @@ import (
 
 // userRepo implements UserRepository.
 type userRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
+// userColumns lists the users columns scanUser reads, in order.
+const userColumns = `id, oauth_subject, primary_email, created_at, last_login_at, onboarding_completed_at, sync_hide_cancelled, sync_prefs_updated_at, locale, timezone, week_start, internal,
+    ARRAY(SELECT m.group_id FROM user_group_members m WHERE m.user_id = users.id ORDER BY m.group_id) AS group_ids,
+    ARRAY(SELECT a.email FROM user_email_aliases a WHERE a.user_id = users.id AND a.verified_at IS NOT NULL ORDER BY a.email_key) AS email_aliases`
+
+// UpsertOAuthUser returns the user with subject, creating it if needed. A
+// user the bootstrap file or an admin created for email, and nobody has
+// signed in as yet, is claimed instead: it takes the subject, keeping its
+// calendars and shares.
 func (r *userRepo) UpsertOAuthUser(ctx context.Context, subject, email string) (*User, error) {
 	const q = `
-INSERT INTO users (oauth_subject, primary_email)
-VALUES ($1, $2)
-ON CONFLICT (oauth_subject) DO UPDATE SET
-        primary_email = EXCLUDED.primary_email,
-        last_login_at = NOW()
-RETURNING id, oauth_subject, primary_email, created_at, last_login_at, onboarding_completed_at
+WITH claimed AS (
+    UPDATE users SET oauth_subject = $1, primary_email = $2, last_login_at = NOW()
+    WHERE oauth_subject = $3
+      AND NOT EXISTS (SELECT 1 FROM users WHERE oauth_subject = $1)
+    RETURNING ` + userColumns + `
+), upserted AS (
+    INSERT INTO users (oauth_subject, primary_email)
+    SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM claimed)
+    ON CONFLICT (oauth_subject) DO UPDATE SET
+            primary_email = EXCLUDED.primary_email,
+            last_login_at = NOW()
+    RETURNING ` + userColumns + `
+)
+SELECT * FROM claimed
+UNION ALL
+SELECT * FROM upserted
 `
 	defer observeDB(ctx, "users.upsert_oauth")()
-	row := r.pool.QueryRowContext(ctx, q, subject, email)
-	var u User
-	if err := row.Scan(&u.ID, &u.OAuthSubject, &u.PrimaryEmail, &u.CreatedAt, &u.LastLoginAt, &u.OnboardingCompletedAt); err != nil {
+	u, err := scanUser(r.pool.QueryRowContext(ctx, q, subject, email, BootstrapSubject(email)).Scan)
+	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
 func (r *userRepo) GetByID(ctx context.Context, id int64) (*User, error) {
-	const q = `SELECT id, oauth_subject, primary_email, created_at, last_login_at, onboarding_completed_at FROM users WHERE id=$1`
+	const q = `SELECT ` + userColumns + ` FROM users WHERE id=$1`
 	defer observeDB(ctx, "users.get_by_id")()
-	var u User
-	if err := r.pool.QueryRowContext(ctx, q, id).Scan(&u.ID, &u.OAuthSubject, &u.PrimaryEmail, &u.CreatedAt, &u.LastLoginAt, &u.OnboardingCompletedAt); err != nil {
+	u, err := scanUser(r.pool.QueryRowContext(ctx, q, id).Scan)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -50,11 +73,19 @@ func (r *userRepo) GetByID(ctx context.Context, id int64) (*User, error) {
 	return &u, nil
 }
 
+// GetByEmail returns the user whose primary address or verified alias is
+// email, ignoring case and Unicode normalization. A primary address wins
+// over another account's alias.
 func (r *userRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
-	const q = `SELECT id, oauth_subject, primary_email, created_at, last_login_at, onboarding_completed_at FROM users WHERE primary_email=$1`
+	const q = `
+SELECT ` + userColumns + ` FROM users
+WHERE lower(primary_email) = lower($1)
+   OR id IN (SELECT user_id FROM user_email_aliases WHERE email_key = $2 AND verified_at IS NOT NULL)
+ORDER BY lower(primary_email) = lower($1) DESC, id
+LIMIT 1`
 	defer observeDB(ctx, "users.get_by_email")()
-	var u User
-	if err := r.pool.QueryRowContext(ctx, q, email).Scan(&u.ID, &u.OAuthSubject, &u.PrimaryEmail, &u.CreatedAt, &u.LastLoginAt, &u.OnboardingCompletedAt); err != nil {
+	u, err := scanUser(r.pool.QueryRowContext(ctx, q, strings.TrimSpace(email), util.EmailLookupKey(email)).Scan)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -63,8 +94,10 @@ func (r *userRepo) GetByEmail(ctx context.Context, email string) (*User, error)
 	return &u, nil
 }
 
+// ListActive returns the users who have signed in, leaving out internal
+// accounts.
 func (r *userRepo) ListActive(ctx context.Context) ([]User, error) {
-	const q = `SELECT id, oauth_subject, primary_email, created_at, last_login_at, onboarding_completed_at FROM users WHERE last_login_at IS NOT NULL ORDER BY primary_email`
+	const q = `SELECT ` + userColumns + ` FROM users WHERE last_login_at IS NOT NULL AND NOT internal ORDER BY primary_email`
 	defer observeDB(ctx, "users.list_active")()
 	rows, err := r.pool.QueryContext(ctx, q)
 	if err != nil {
@@ -74,8 +107,8 @@ func (r *userRepo) ListActive(ctx context.Context) ([]User, error) {
 
 	var users []User
 	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.ID, &u.OAuthSubject, &u.PrimaryEmail, &u.CreatedAt, &u.LastLoginAt, &u.OnboardingCompletedAt); err != nil {
+		u, err := scanUser(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -92,9 +125,52 @@ func (r *userRepo) MarkOnboardingComplete(ctx context.Context, userID int64) err
 	return err
 }
 
+// SetSyncHideCancelled stores whether cancelled and declined events are
+// withheld from the user's calendar-query and sync-collection responses.
+func (r *userRepo) SetSyncHideCancelled(ctx context.Context, userID int64, hide bool) error {
+	const q = `UPDATE users SET sync_hide_cancelled = $1, sync_prefs_updated_at = NOW() WHERE id=$2 AND sync_hide_cancelled <> $1`
+	defer observeDB(ctx, "users.set_sync_hide_cancelled")()
+	_, err := r.pool.ExecContext(ctx, q, hide, userID)
+	return err
+}
+
+// MarkInternal marks the user as an internal account.
+func (r *userRepo) MarkInternal(ctx context.Context, userID int64) error {
+	const q = `UPDATE users SET internal = TRUE WHERE id=$1 AND NOT internal`
+	defer observeDB(ctx, "users.mark_internal")()
+	_, err := r.pool.ExecContext(ctx, q, userID)
+	return err
+}
+
+// SetPreferences stores the user's locale, timezone and first day of the
+// week. Callers validate them first.
+func (r *userRepo) SetPreferences(ctx context.Context, userID int64, locale, timezone string, weekStart *time.Weekday) error {
+	const q = `UPDATE users SET locale = $1, timezone = $2, week_start = $3 WHERE id=$4`
+	defer observeDB(ctx, "users.set_preferences")()
+	var start any
+	if weekStart != nil {
+		start = int64(*weekStart)
+	}
+	_, err := r.pool.ExecContext(ctx, q, locale, timezone, start, userID)
+	return err
+}
+
+func scanUser(scan rowScanner) (User, error) {
+	var u User
+	var weekStart sql.NullInt16
+	if err := scan(&u.ID, &u.OAuthSubject, &u.PrimaryEmail, &u.CreatedAt, &u.LastLoginAt, &u.OnboardingCompletedAt, &u.SyncHideCancelled, &u.SyncPrefsUpdatedAt, &u.Locale, &u.Timezone, &weekStart, &u.Internal, pq.Array(&u.GroupIDs), pq.Array(&u.EmailAliases)); err != nil {
+		return u, err
+	}
+	if weekStart.Valid {
+		day := time.Weekday(weekStart.Int16)
+		u.WeekStart = &day
+	}
+	return u, nil
+}
+
 // calendarRepo implements CalendarRepository.
 type calendarRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func sqlLiteralList(items ...string) string {
@@ -126,8 +202,16 @@ func calendarACLBooleanExpr(userParam string, privileges ...string) string {
    )`
 }
 
+// aclPrincipalListExpr mirrors ACLPrincipals: it lists the principals that
+// apply to the user, including the sharing groups they are in.
 func aclPrincipalListExpr(userParam string) string {
-	return "('DAV:all', 'DAV:authenticated', '/dav/principals/' || " + userParam + "::text || '/')"
+	return `(
+               SELECT 'DAV:all'
+               UNION ALL SELECT 'DAV:authenticated' FROM users iu WHERE iu.id = ` + userParam + ` AND NOT iu.internal
+               UNION ALL SELECT '/dav/principals/' || ` + userParam + `::text || '/'
+               UNION ALL SELECT '/dav/principals/groups/' || gm.group_id::text || '/'
+                   FROM user_group_members gm WHERE gm.user_id = ` + userParam + `
+           )`
 }
 
 func calendarEventACLPathListExpr() string {
@@ -235,8 +319,23 @@ func (r *calendarRepo) GetByID(ctx context.Context, id int64) (*Calendar, error)
 	return &c, nil
 }
 
-func (r *calendarRepo) ListAccessible(ctx context.Context, userID int64) ([]CalendarAccess, error) {
-	q := `
+// calendarAccessibleQuery selects the calendars the user in $1 owns or has
+// been granted access to, with their owner and effective privileges. Only
+// calendars named by an ACL grant to one of the user's principals are
+// candidates, so the per-calendar privilege checks below run against those
+// rather than every calendar on the server. extraColumns is appended to the
+// select list and tail follows the WHERE clause.
+func calendarAccessibleQuery(extraColumns, tail string) string {
+	return `
+WITH candidates AS (
+    SELECT id FROM calendars WHERE user_id = $1
+    UNION
+    SELECT substring(a.resource_path FROM '^/dav/calendars/([0-9]+)(?:/|$)')::bigint
+    FROM acl_entries a
+    WHERE a.is_grant = TRUE
+      AND a.principal_href IN ` + aclPrincipalListExpr("$1") + `
+      AND a.resource_path ~ '^/dav/calendars/[0-9]+(/|$)'
+)
 SELECT c.id, c.user_id, c.name, c.slug, c.description, c.timezone, c.color, c.ctag, c.created_at, c.updated_at,
        u.primary_email as owner_email,
        CASE WHEN c.user_id = $1 THEN FALSE ELSE TRUE END as shared,
@@ -246,8 +345,9 @@ SELECT c.id, c.user_id, c.name, c.slug, c.description, c.timezone, c.color, c.ct
        CASE WHEN c.user_id = $1 THEN TRUE ELSE ` + calendarACLBooleanExpr("$1", "write-content", "write", "all") + ` END as can_write_content,
        CASE WHEN c.user_id = $1 THEN TRUE ELSE ` + calendarACLBooleanExpr("$1", "write-properties", "write", "all") + ` END as can_write_properties,
        CASE WHEN c.user_id = $1 THEN TRUE ELSE ` + calendarACLBooleanExpr("$1", "bind", "write", "all") + ` END as can_bind,
-       CASE WHEN c.user_id = $1 THEN TRUE ELSE ` + calendarACLBooleanExpr("$1", "unbind", "write", "all") + ` END as can_unbind
+       CASE WHEN c.user_id = $1 THEN TRUE ELSE ` + calendarACLBooleanExpr("$1", "unbind", "write", "all") + ` END as can_unbind` + extraColumns + `
 FROM calendars c
+JOIN candidates k ON k.id = c.id
 JOIN users u ON u.id = c.user_id
 WHERE c.user_id = $1
    OR (
@@ -255,8 +355,32 @@ WHERE c.user_id = $1
        AND (` + calendarACLAnyAccessExpr("$1") + `
             OR ` + calendarObjectACLAnyAccessExpr("$1") + `)
    )
-ORDER BY shared, name
+ORDER BY shared, name` + tail + `
 `
+}
+
+func scanCalendarAccess(scan rowScanner, extra ...any) (CalendarAccess, error) {
+	var c CalendarAccess
+	var slug, description, timezone, color sql.NullString
+	dest := []any{
+		&c.ID, &c.UserID, &c.Name, &slug, &description, &timezone, &color, &c.CTag, &c.CreatedAt, &c.UpdatedAt, &c.OwnerEmail, &c.Shared,
+		&c.Privileges.Read, &c.Privileges.ReadFreeBusy, &c.Privileges.Write, &c.Privileges.WriteContent, &c.Privileges.WriteProperties, &c.Privileges.Bind, &c.Privileges.Unbind,
+	}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return c, err
+	}
+	c.Slug = nullableString(slug)
+	c.Description = nullableString(description)
+	c.Timezone = nullableString(timezone)
+	c.Color = nullableString(color)
+	c.PrivilegesResolved = true
+	c.Privileges = c.Privileges.Normalized()
+	c.Editor = c.Privileges.AllowsEventEditing()
+	return c, nil
+}
+
+func (r *calendarRepo) ListAccessible(ctx context.Context, userID int64) ([]CalendarAccess, error) {
+	q := calendarAccessibleQuery("", "")
 	defer observeDB(ctx, "calendars.list_accessible")()
 	rows, err := r.pool.QueryContext(ctx, q, userID)
 	if err != nil {
@@ -266,26 +390,54 @@ ORDER BY shared, name
 
 	var result []CalendarAccess
 	for rows.Next() {
-		var c CalendarAccess
-		var slug, description, timezone, color sql.NullString
-		if err := rows.Scan(
-			&c.ID, &c.UserID, &c.Name, &slug, &description, &timezone, &color, &c.CTag, &c.CreatedAt, &c.UpdatedAt, &c.OwnerEmail, &c.Shared,
-			&c.Privileges.Read, &c.Privileges.ReadFreeBusy, &c.Privileges.Write, &c.Privileges.WriteContent, &c.Privileges.WriteProperties, &c.Privileges.Bind, &c.Privileges.Unbind,
-		); err != nil {
+		c, err := scanCalendarAccess(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
-		c.Slug = nullableString(slug)
-		c.Description = nullableString(description)
-		c.Timezone = nullableString(timezone)
-		c.Color = nullableString(color)
-		c.PrivilegesResolved = true
-		c.Privileges = c.Privileges.Normalized()
-		c.Editor = c.Privileges.AllowsEventEditing()
 		result = append(result, c)
 	}
 	return result, rows.Err()
 }
 
+// ListAccessiblePage returns one page of ListAccessible, in the same order,
+// along with the total number of accessible calendars and each calendar's
+// event count.
+func (r *calendarRepo) ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*PaginatedResult[CalendarAccess], error) {
+	q := calendarAccessibleQuery(`,
+       (SELECT COUNT(*) FROM events ev WHERE ev.calendar_id = c.id) as event_count,
+       COUNT(*) OVER () as total_count`, `
+LIMIT $2 OFFSET $3`)
+	defer observeDB(ctx, "calendars.list_accessible_page")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &PaginatedResult[CalendarAccess]{Limit: limit, Offset: offset}
+	for rows.Next() {
+		var eventCount int
+		c, err := scanCalendarAccess(rows.Scan, &eventCount, &page.TotalCount)
+		if err != nil {
+			return nil, err
+		}
+		c.EventCount = &eventCount
+		page.Items = append(page.Items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Items) == 0 && offset > 0 {
+		// Past the last page there is no row to carry the window count.
+		all, err := r.ListAccessible(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = len(all)
+	}
+	return page, nil
+}
+
 func (r *calendarRepo) GetAccessible(ctx context.Context, calendarID, userID int64) (*CalendarAccess, error) {
 	q := `
 SELECT c.id, c.user_id, c.name, c.slug, c.description, c.timezone, c.color, c.ctag, c.created_at, c.updated_at,
@@ -311,24 +463,13 @@ WHERE c.id = $1
   )
 `
 	defer observeDB(ctx, "calendars.get_accessible")()
-	var c CalendarAccess
-	var slug, description, timezone, color sql.NullString
-	if err := r.pool.QueryRowContext(ctx, q, calendarID, userID).Scan(
-		&c.ID, &c.UserID, &c.Name, &slug, &description, &timezone, &color, &c.CTag, &c.CreatedAt, &c.UpdatedAt, &c.OwnerEmail, &c.Shared,
-		&c.Privileges.Read, &c.Privileges.ReadFreeBusy, &c.Privileges.Write, &c.Privileges.WriteContent, &c.Privileges.WriteProperties, &c.Privileges.Bind, &c.Privileges.Unbind,
-	); err != nil {
+	c, err := scanCalendarAccess(r.pool.QueryRowContext(ctx, q, calendarID, userID).Scan)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	c.Slug = nullableString(slug)
-	c.Description = nullableString(description)
-	c.Timezone = nullableString(timezone)
-	c.Color = nullableString(color)
-	c.PrivilegesResolved = true
-	c.Privileges = c.Privileges.Normalized()
-	c.Editor = c.Privileges.AllowsEventEditing()
 	return &c, nil
 }
 
@@ -418,21 +559,36 @@ func (r *calendarRepo) Delete(ctx context.Context, userID, id int64) error {
 
 // eventRepo implements EventRepository.
 type eventRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func (r *eventRepo) Upsert(ctx context.Context, event Event) (*Event, error) {
+	defer observeDB(ctx, "events.upsert")()
+	var saved *Event
+	err := attributed(ctx, r.pool, func(db rowWriter) (err error) {
+		saved, err = upsertEvent(ctx, db, event)
+		return err
+	})
+	return saved, err
+}
+
+// upsertEvent stores event through db, which is the pool or a transaction.
+func upsertEvent(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, event Event) (*Event, error) {
 	summary, description, location, dtstart, dtend, allDay := parseICalFields(event.RawICAL)
 	if event.ResourceName == "" {
 		event.ResourceName = event.UID
 	}
 
 	const q = `
-INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, canonical_ical, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
 ON CONFLICT (calendar_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_ical = EXCLUDED.raw_ical,
+        raw_ical_hash = EXCLUDED.raw_ical_hash,
+        canonical_ical = EXCLUDED.canonical_ical,
         etag = EXCLUDED.etag,
         summary = EXCLUDED.summary,
         description = EXCLUDED.description,
@@ -441,10 +597,9 @@ ON CONFLICT (calendar_id, uid) DO UPDATE SET
         dtend = EXCLUDED.dtend,
         all_day = EXCLUDED.all_day,
         last_modified = NOW()
-RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified
+RETURNING id, calendar_id, uid, resource_name, $4, etag, summary, description, location, dtstart, dtend, all_day, last_modified
 `
-	defer observeDB(ctx, "events.upsert")()
-	row := r.pool.QueryRowContext(ctx, q, event.CalendarID, event.UID, event.ResourceName, event.RawICAL, event.ETag, summary, description, location, dtstart, dtend, allDay)
+	row := db.QueryRowContext(ctx, q, event.CalendarID, event.UID, event.ResourceName, event.RawICAL, event.ETag, summary, description, location, dtstart, dtend, allDay, util.Canonicalize(event.RawICAL))
 	ev, err := scanEvent(row.Scan)
 	if err != nil {
 		return nil, err
@@ -455,12 +610,51 @@ RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, descript
 func (r *eventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid string) error {
 	const q = `DELETE FROM events WHERE calendar_id=$1 AND uid=$2`
 	defer observeDB(ctx, "events.delete_by_uid")()
-	_, err := r.pool.ExecContext(ctx, q, calendarID, uid)
-	return err
+	return attributed(ctx, r.pool, func(db rowWriter) error {
+		_, err := db.ExecContext(ctx, q, calendarID, uid)
+		return err
+	})
+}
+
+func (r *eventRepo) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	if len(uids) == 0 {
+		return 0, nil
+	}
+	defer observeDB(ctx, "events.delete_by_uids")()
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The ctag trigger still writes a tombstone per row but leaves the ctag
+	// alone while this is set, so the batch bumps it once below.
+	if _, err := tx.ExecContext(ctx, `SET LOCAL calcard.batch_ctag = 'on'`); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM events WHERE calendar_id=$1 AND uid = ANY($2)`, calendarID, pq.Array(uids))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		const incrementCtagQuery = `UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, incrementCtagQuery, calendarID); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
 }
 
 func (r *eventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*Event, error) {
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`
 	defer observeDB(ctx, "events.get_by_uid")()
 	row := r.pool.QueryRowContext(ctx, q, calendarID, uid)
 	ev, err := scanEvent(row.Scan)
@@ -474,7 +668,7 @@ func (r *eventRepo) GetByUID(ctx context.Context, calendarID int64, uid string)
 }
 
 func (r *eventRepo) GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*Event, error) {
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND resource_name=$2`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND resource_name=$2`
 	defer observeDB(ctx, "events.get_by_resource_name")()
 	row := r.pool.QueryRowContext(ctx, q, calendarID, resourceName)
 	ev, err := scanEvent(row.Scan)
@@ -487,11 +681,45 @@ func (r *eventRepo) GetByResourceName(ctx context.Context, calendarID int64, res
 	return &ev, nil
 }
 
+func (r *eventRepo) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*Event, *Body, error) {
+	const q = `
+SELECT e.id, e.calendar_id, e.uid, e.resource_name,
+       CASE WHEN e.raw_ical IS NOT NULL OR octet_length(b.body) <= $3 THEN COALESCE(e.raw_ical, b.body) END,
+       e.raw_ical_hash, COALESCE(octet_length(e.raw_ical), octet_length(b.body), 0),
+       e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified
+FROM events e LEFT JOIN content_bodies b ON b.hash = e.raw_ical_hash
+WHERE e.calendar_id=$1 AND e.resource_name=$2`
+	defer observeDB(ctx, "events.open_by_resource_name")()
+	var ev Event
+	var body, hash, summary, description, location sql.NullString
+	var dtstart, dtend sql.NullTime
+	var size int64
+	err := r.pool.QueryRowContext(ctx, q, calendarID, resourceName, inlineBodyBytes).Scan(
+		&ev.ID, &ev.CalendarID, &ev.UID, &ev.ResourceName, &body, &hash, &size,
+		&ev.ETag, &summary, &description, &location, &dtstart, &dtend, &ev.AllDay, &ev.LastModified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	ev.Summary = nullableString(summary)
+	ev.Description = nullableString(description)
+	ev.Location = nullableString(location)
+	ev.DTStart = nullableTime(dtstart)
+	ev.DTEnd = nullableTime(dtend)
+	// Rows written with the body triggers disabled keep it inline.
+	if body.Valid || !hash.Valid {
+		return &ev, NewBody(body.String), nil
+	}
+	return &ev, &Body{Reader: &chunkedBody{ctx: ctx, pool: r.pool, hash: hash.String, next: 1}, Size: size}, nil
+}
+
 func (r *eventRepo) ListByUIDs(ctx context.Context, calendarID int64, uids []string) ([]Event, error) {
 	if len(uids) == 0 {
 		return []Event{}, nil
 	}
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid = ANY($2)`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid = ANY($2)`
 	defer observeDB(ctx, "events.list_by_uids")()
 	rows, err := r.pool.QueryContext(ctx, q, calendarID, pq.Array(uids))
 	if err != nil {
@@ -511,7 +739,7 @@ func (r *eventRepo) ListByUIDs(ctx context.Context, calendarID int64, uids []str
 }
 
 func (r *eventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]Event, error) {
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC`
 	defer observeDB(ctx, "events.list_for_calendar")()
 	rows, err := r.pool.QueryContext(ctx, q, calendarID)
 	if err != nil {
@@ -530,8 +758,18 @@ func (r *eventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]Ev
 	return result, rows.Err()
 }
 
+// eventBody and contactBody read a row's iCalendar or vCard body. Triggers
+// move each body into content_bodies on write, where identical bodies are
+// stored once, so raw_ical and raw_vcard are NULL for all but rows written
+// with those triggers disabled. Upserts return the body they were given
+// ($4) instead, since the trigger's insert is not visible to RETURNING.
+const (
+	eventBody   = `COALESCE(raw_ical, (SELECT body FROM content_bodies WHERE hash = raw_ical_hash))`
+	contactBody = `COALESCE(raw_vcard, (SELECT body FROM content_bodies WHERE hash = raw_vcard_hash))`
+)
+
 // eventColumns is the canonical select list shared by event queries.
-const eventColumns = `id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified`
+const eventColumns = `id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified`
 
 // likeEscape escapes characters with special meaning in a LIKE/ILIKE pattern so
 // user-supplied search text is matched literally (using the default '\' escape).
@@ -608,7 +846,7 @@ func (r *eventRepo) ListForCalendarPaginated(ctx context.Context, calendarID int
 		return nil, err
 	}
 
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC LIMIT $2 OFFSET $3`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY last_modified DESC LIMIT $2 OFFSET $3`
 	rows, err := r.pool.QueryContext(ctx, q, calendarID, limit, offset)
 	if err != nil {
 		return nil, err
@@ -636,7 +874,7 @@ func (r *eventRepo) ListForCalendarPaginated(ctx context.Context, calendarID int
 }
 
 func (r *eventRepo) ListModifiedSince(ctx context.Context, calendarID int64, since time.Time) ([]Event, error) {
-	const q = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`
 	defer observeDB(ctx, "events.list_modified_since")()
 	rows, err := r.pool.QueryContext(ctx, q, calendarID, since)
 	if err != nil {
@@ -657,7 +895,7 @@ func (r *eventRepo) ListModifiedSince(ctx context.Context, calendarID int64, sin
 
 func (r *eventRepo) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]Event, error) {
 	q := `
-SELECT e.id, e.calendar_id, e.uid, e.resource_name, e.raw_ical, e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified
+SELECT e.id, e.calendar_id, e.uid, e.resource_name, ` + eventBody + `, e.etag, e.summary, e.description, e.location, e.dtstart, e.dtend, e.all_day, e.last_modified
 FROM events e
 JOIN calendars c ON c.id = e.calendar_id
 WHERE c.user_id = $1
@@ -782,6 +1020,129 @@ func (r *eventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toCalend
 	return tx.Commit()
 }
 
+func (r *eventRepo) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	if fromCalendarID == toCalendarID {
+		return 0, nil
+	}
+	defer observeDB(ctx, "events.move_all_to_calendar")()
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	moved, err := moveEventsTx(ctx, tx, fromCalendarID, toCalendarID, uids)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+func (r *eventRepo) SplitToNewCalendar(ctx context.Context, cal Calendar, fromCalendarID int64, uids []string) (*Calendar, int, error) {
+	defer observeDB(ctx, "events.split_to_new_calendar")()
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	const createQ = `INSERT INTO calendars (user_id, name, slug, description, timezone, color) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, user_id, name, slug, description, timezone, color, ctag, created_at, updated_at`
+	var created Calendar
+	var slug, description, timezone, color sql.NullString
+	if err := tx.QueryRowContext(ctx, createQ, cal.UserID, cal.Name, cal.Slug, cal.Description, cal.Timezone, cal.Color).
+		Scan(&created.ID, &created.UserID, &created.Name, &slug, &description, &timezone, &color, &created.CTag, &created.CreatedAt, &created.UpdatedAt); err != nil {
+		return nil, 0, err
+	}
+	created.Slug = nullableString(slug)
+	created.Description = nullableString(description)
+	created.Timezone = nullableString(timezone)
+	created.Color = nullableString(color)
+
+	moved, err := moveEventsTx(ctx, tx, fromCalendarID, created.ID, uids)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+	if moved > 0 {
+		created.CTag++
+	}
+	return &created, moved, nil
+}
+
+// moveEventsTx moves the events with the given UIDs, or every event when
+// uids is nil, keeping their UIDs and resource names. The source gets a
+// tombstone per event, both calendars' ctags move once, and per-event ACL
+// entries are dropped so the destination's grants apply. It returns
+// ErrConflict, moving nothing, when the destination already has one of the
+// UIDs or resource names.
+func moveEventsTx(ctx context.Context, tx *sql.Tx, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	var filter any
+	if uids != nil {
+		filter = pq.Array(uids)
+	}
+	const conflictQ = `SELECT 1 FROM events s JOIN events d ON d.calendar_id=$2 AND (d.uid=s.uid OR d.resource_name=s.resource_name) WHERE s.calendar_id=$1 AND ($3::text[] IS NULL OR s.uid = ANY($3)) LIMIT 1`
+	var conflict int
+	switch err := tx.QueryRowContext(ctx, conflictQ, fromCalendarID, toCalendarID, filter).Scan(&conflict); {
+	case err == nil:
+		return 0, ErrConflict
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, err
+	}
+
+	// The ctag trigger leaves the ctag alone while this is set, so each
+	// calendar's ctag is bumped once below rather than once per event.
+	if _, err := tx.ExecContext(ctx, `SET LOCAL calcard.batch_ctag = 'on'`); err != nil {
+		return 0, err
+	}
+	const moveQ = `UPDATE events SET calendar_id=$2, last_modified=NOW() WHERE calendar_id=$1 AND ($3::text[] IS NULL OR uid = ANY($3)) RETURNING uid, resource_name`
+	rows, err := tx.QueryContext(ctx, moveQ, fromCalendarID, toCalendarID, filter)
+	if err != nil {
+		return 0, err
+	}
+	var movedUIDs, movedNames, aclPaths []string
+	for rows.Next() {
+		var uid, name string
+		if err := rows.Scan(&uid, &name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		movedUIDs = append(movedUIDs, uid)
+		movedNames = append(movedNames, name)
+		base := "/dav/calendars/" + strconv.FormatInt(fromCalendarID, 10) + "/" + strings.TrimSuffix(name, ".ics")
+		aclPaths = append(aclPaths, base, base+".ics")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(movedUIDs) == 0 {
+		return 0, nil
+	}
+
+	const tombstoneQ = `INSERT INTO deleted_resources (resource_type, collection_id, uid, resource_name) SELECT 'event', $1, uid, name FROM unnest($2::text[], $3::text[]) AS moved(uid, name)`
+	if _, err := tx.ExecContext(ctx, tombstoneQ, fromCalendarID, pq.Array(movedUIDs), pq.Array(movedNames)); err != nil {
+		return 0, err
+	}
+	const aclQ = `DELETE FROM acl_entries WHERE resource_path = ANY($1)`
+	if _, err := tx.ExecContext(ctx, aclQ, pq.Array(aclPaths)); err != nil {
+		return 0, err
+	}
+	const incrementCtagQuery = `UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = ANY($1)`
+	if _, err := tx.ExecContext(ctx, incrementCtagQuery, pq.Array([]int64{fromCalendarID, toCalendarID})); err != nil {
+		return 0, err
+	}
+	return len(movedUIDs), nil
+}
+
 func (r *eventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*Event, error) {
 	defer observeDB(ctx, "events.copy_to_calendar")()
 
@@ -791,7 +1152,7 @@ func (r *eventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalend
 	}
 	defer tx.Rollback()
 
-	const selectQ = `SELECT id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`
+	const selectQ = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 AND uid=$2`
 	row := tx.QueryRowContext(ctx, selectQ, fromCalendarID, uid)
 	src, err := scanEvent(row.Scan)
 	if err != nil {
@@ -833,11 +1194,13 @@ func (r *eventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalend
 	}
 
 	const insertQ = `
-INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+INSERT INTO events (calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, canonical_ical, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
 ON CONFLICT (calendar_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_ical = EXCLUDED.raw_ical,
+        raw_ical_hash = EXCLUDED.raw_ical_hash,
+        canonical_ical = EXCLUDED.canonical_ical,
         etag = EXCLUDED.etag,
         summary = EXCLUDED.summary,
         description = EXCLUDED.description,
@@ -846,9 +1209,9 @@ ON CONFLICT (calendar_id, uid) DO UPDATE SET
         dtend = EXCLUDED.dtend,
         all_day = EXCLUDED.all_day,
         last_modified = NOW()
-RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, description, location, dtstart, dtend, all_day, last_modified
+RETURNING id, calendar_id, uid, resource_name, $4, etag, summary, description, location, dtstart, dtend, all_day, last_modified
 `
-	insertRow := tx.QueryRowContext(ctx, insertQ, toCalendarID, src.UID, destResourceName, src.RawICAL, newETag, src.Summary, src.Description, src.Location, src.DTStart, src.DTEnd, src.AllDay)
+	insertRow := tx.QueryRowContext(ctx, insertQ, toCalendarID, src.UID, destResourceName, src.RawICAL, newETag, src.Summary, src.Description, src.Location, src.DTStart, src.DTEnd, src.AllDay, util.Canonicalize(src.RawICAL))
 	ev, err := scanEvent(insertRow.Scan)
 	if err != nil {
 		return nil, err
@@ -862,7 +1225,7 @@ RETURNING id, calendar_id, uid, resource_name, raw_ical, etag, summary, descript
 
 // addressBookRepo implements AddressBookRepository.
 type addressBookRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func isAddressBookNameConflict(err error) bool {
@@ -1007,7 +1370,7 @@ func (r *addressBookRepo) Delete(ctx context.Context, userID, id int64) error {
 
 // contactRepo implements ContactRepository.
 type contactRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func (r *contactRepo) Upsert(ctx context.Context, contact Contact) (*Contact, error) {
@@ -1018,21 +1381,30 @@ func (r *contactRepo) Upsert(ctx context.Context, contact Contact) (*Contact, er
 	}
 
 	const q = `
-INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, canonical_vcard, email_keys, phone_keys, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 ON CONFLICT (address_book_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_vcard = EXCLUDED.raw_vcard,
+        raw_vcard_hash = EXCLUDED.raw_vcard_hash,
+        canonical_vcard = EXCLUDED.canonical_vcard,
         etag = EXCLUDED.etag,
         display_name = EXCLUDED.display_name,
         primary_email = EXCLUDED.primary_email,
         birthday = EXCLUDED.birthday,
+        email_keys = EXCLUDED.email_keys,
+        phone_keys = EXCLUDED.phone_keys,
         last_modified = NOW()
-RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified
+RETURNING id, address_book_id, uid, resource_name, $4, etag, display_name, primary_email, birthday, last_modified
 `
 	defer observeDB(ctx, "contacts.upsert")()
-	row := r.pool.QueryRowContext(ctx, q, contact.AddressBookID, contact.UID, contact.ResourceName, contact.RawVCard, contact.ETag, displayName, primaryEmail, birthday)
-	c, err := scanContact(row.Scan)
+	emailKeys, phoneKeys := parseVCardLookupKeys(contact.RawVCard)
+	var c Contact
+	err := attributed(ctx, r.pool, func(db rowWriter) (err error) {
+		row := db.QueryRowContext(ctx, q, contact.AddressBookID, contact.UID, contact.ResourceName, contact.RawVCard, contact.ETag, displayName, primaryEmail, birthday, util.Canonicalize(contact.RawVCard), pq.Array(emailKeys), pq.Array(phoneKeys))
+		c, err = scanContact(row.Scan)
+		return err
+	})
 	if err != nil {
 		if isContactResourceNameConflict(err) {
 			return nil, ErrConflict
@@ -1045,8 +1417,10 @@ RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name
 func (r *contactRepo) DeleteByUID(ctx context.Context, addressBookID int64, uid string) error {
 	const q = `DELETE FROM contacts WHERE address_book_id=$1 AND uid=$2`
 	defer observeDB(ctx, "contacts.delete_by_uid")()
-	_, err := r.pool.ExecContext(ctx, q, addressBookID, uid)
-	return err
+	return attributed(ctx, r.pool, func(db rowWriter) error {
+		_, err := db.ExecContext(ctx, q, addressBookID, uid)
+		return err
+	})
 }
 
 func (r *contactRepo) MoveToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName string) error {
@@ -1120,7 +1494,7 @@ func (r *contactRepo) MoveToAddressBook(ctx context.Context, fromAddressBookID,
 }
 
 func (r *contactRepo) GetByUID(ctx context.Context, addressBookID int64, uid string) (*Contact, error) {
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`
 	defer observeDB(ctx, "contacts.get_by_uid")()
 	row := r.pool.QueryRowContext(ctx, q, addressBookID, uid)
 	c, err := scanContact(row.Scan)
@@ -1137,7 +1511,7 @@ func (r *contactRepo) ListByUIDs(ctx context.Context, addressBookID int64, uids
 	if len(uids) == 0 {
 		return []Contact{}, nil
 	}
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid = ANY($2)`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid = ANY($2)`
 	defer observeDB(ctx, "contacts.list_by_uids")()
 	rows, err := r.pool.QueryContext(ctx, q, addressBookID, pq.Array(uids))
 	if err != nil {
@@ -1157,7 +1531,7 @@ func (r *contactRepo) ListByUIDs(ctx context.Context, addressBookID int64, uids
 }
 
 func (r *contactRepo) ListForBook(ctx context.Context, addressBookID int64) ([]Contact, error) {
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY last_modified DESC`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY last_modified DESC`
 	defer observeDB(ctx, "contacts.list_for_book")()
 	rows, err := r.pool.QueryContext(ctx, q, addressBookID)
 	if err != nil {
@@ -1177,7 +1551,7 @@ func (r *contactRepo) ListForBook(ctx context.Context, addressBookID int64) ([]C
 }
 
 // contactColumns is the canonical select list shared by contact queries.
-const contactColumns = `id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified`
+const contactColumns = `id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified`
 
 // ListForBookFiltered returns contacts in an address book matching f. Every
 // query is scoped to a single address_book_id (served by the
@@ -1228,6 +1602,36 @@ func (r *contactRepo) ListForBookFiltered(ctx context.Context, addressBookID int
 	return result, rows.Err()
 }
 
+// FindByLookupKey returns contacts in the given address books with an email
+// or phone lookup key equal to email or phone. Stored keys are never empty,
+// so an empty argument matches nothing. The containment checks are served by
+// the GIN indexes on the key columns.
+func (r *contactRepo) FindByLookupKey(ctx context.Context, addressBookIDs []int64, email, phone string, limit int) ([]Contact, error) {
+	if len(addressBookIDs) == 0 || (email == "" && phone == "") {
+		return nil, nil
+	}
+	q := `SELECT ` + contactColumns + ` FROM contacts
+WHERE address_book_id = ANY($1) AND (email_keys @> ARRAY[$2]::text[] OR phone_keys @> ARRAY[$3]::text[])
+ORDER BY LOWER(COALESCE(display_name, '')) ASC, id ASC
+LIMIT $4`
+	defer observeDB(ctx, "contacts.find_by_lookup_key")()
+	rows, err := r.pool.QueryContext(ctx, q, pq.Array(addressBookIDs), email, phone, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Contact
+	for rows.Next() {
+		c, err := scanContact(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
 func (r *contactRepo) ListForBookPaginated(ctx context.Context, addressBookID int64, limit, offset int) (*PaginatedResult[Contact], error) {
 	defer observeDB(ctx, "contacts.list_for_book_paginated")()
 
@@ -1238,7 +1642,7 @@ func (r *contactRepo) ListForBookPaginated(ctx context.Context, addressBookID in
 		return nil, err
 	}
 
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY LOWER(COALESCE(display_name, '')) ASC, id ASC LIMIT $2 OFFSET $3`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 ORDER BY LOWER(COALESCE(display_name, '')) ASC, id ASC LIMIT $2 OFFSET $3`
 	rows, err := r.pool.QueryContext(ctx, q, addressBookID, limit, offset)
 	if err != nil {
 		return nil, err
@@ -1266,7 +1670,7 @@ func (r *contactRepo) ListForBookPaginated(ctx context.Context, addressBookID in
 }
 
 func (r *contactRepo) ListModifiedSince(ctx context.Context, addressBookID int64, since time.Time) ([]Contact, error) {
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND last_modified > $2 ORDER BY last_modified DESC`
 	defer observeDB(ctx, "contacts.list_modified_since")()
 	rows, err := r.pool.QueryContext(ctx, q, addressBookID, since)
 	if err != nil {
@@ -1287,7 +1691,7 @@ func (r *contactRepo) ListModifiedSince(ctx context.Context, addressBookID int64
 
 func (r *contactRepo) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]Contact, error) {
 	const q = `
-SELECT c.id, c.address_book_id, c.uid, c.resource_name, c.raw_vcard, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
+SELECT c.id, c.address_book_id, c.uid, c.resource_name, ` + contactBody + `, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
 FROM contacts c
 JOIN address_books ab ON ab.id = c.address_book_id
 WHERE ab.user_id = $1
@@ -1324,7 +1728,7 @@ func (r *contactRepo) MaxLastModified(ctx context.Context, addressBookID int64)
 
 func (r *contactRepo) ListWithBirthdaysByUser(ctx context.Context, userID int64) ([]Contact, error) {
 	const q = `
-SELECT c.id, c.address_book_id, c.uid, c.resource_name, c.raw_vcard, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
+SELECT c.id, c.address_book_id, c.uid, c.resource_name, ` + contactBody + `, c.etag, c.display_name, c.primary_email, c.birthday, c.last_modified
 FROM contacts c
 JOIN address_books ab ON ab.id = c.address_book_id
 WHERE ab.user_id = $1 AND c.birthday IS NOT NULL
@@ -1349,7 +1753,7 @@ ORDER BY c.display_name
 }
 
 func (r *contactRepo) GetByResourceName(ctx context.Context, addressBookID int64, resourceName string) (*Contact, error) {
-	const q = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND resource_name=$2`
+	const q = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND resource_name=$2`
 	defer observeDB(ctx, "contacts.get_by_resource_name")()
 	row := r.pool.QueryRowContext(ctx, q, addressBookID, resourceName)
 	c, err := scanContact(row.Scan)
@@ -1371,7 +1775,7 @@ func (r *contactRepo) CopyToAddressBook(ctx context.Context, fromAddressBookID,
 	}
 	defer tx.Rollback()
 
-	const selectQ = `SELECT id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`
+	const selectQ = `SELECT id, address_book_id, uid, resource_name, ` + contactBody + `, etag, display_name, primary_email, birthday, last_modified FROM contacts WHERE address_book_id=$1 AND uid=$2`
 	row := tx.QueryRowContext(ctx, selectQ, fromAddressBookID, uid)
 	src, err := scanContact(row.Scan)
 	if err != nil {
@@ -1410,19 +1814,24 @@ func (r *contactRepo) CopyToAddressBook(ctx context.Context, fromAddressBookID,
 	}
 
 	const insertQ = `
-INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+INSERT INTO contacts (address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, canonical_vcard, email_keys, phone_keys, last_modified)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
 ON CONFLICT (address_book_id, uid) DO UPDATE SET
         resource_name = EXCLUDED.resource_name,
         raw_vcard = EXCLUDED.raw_vcard,
+        raw_vcard_hash = EXCLUDED.raw_vcard_hash,
+        canonical_vcard = EXCLUDED.canonical_vcard,
         etag = EXCLUDED.etag,
         display_name = EXCLUDED.display_name,
         primary_email = EXCLUDED.primary_email,
         birthday = EXCLUDED.birthday,
+        email_keys = EXCLUDED.email_keys,
+        phone_keys = EXCLUDED.phone_keys,
         last_modified = NOW()
-RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name, primary_email, birthday, last_modified
+RETURNING id, address_book_id, uid, resource_name, $4, etag, display_name, primary_email, birthday, last_modified
 `
-	insertRow := tx.QueryRowContext(ctx, insertQ, toAddressBookID, src.UID, destResourceName, src.RawVCard, newETag, src.DisplayName, src.PrimaryEmail, src.Birthday)
+	emailKeys, phoneKeys := parseVCardLookupKeys(src.RawVCard)
+	insertRow := tx.QueryRowContext(ctx, insertQ, toAddressBookID, src.UID, destResourceName, src.RawVCard, newETag, src.DisplayName, src.PrimaryEmail, src.Birthday, util.Canonicalize(src.RawVCard), pq.Array(emailKeys), pq.Array(phoneKeys))
 	c, err := scanContact(insertRow.Scan)
 	if err != nil {
 		return nil, err
@@ -1434,53 +1843,144 @@ RETURNING id, address_book_id, uid, resource_name, raw_vcard, etag, display_name
 	return &c, nil
 }
 
-// appPasswordRepo implements AppPasswordRepository.
-type appPasswordRepo struct {
-	pool *sql.DB
+// emailAliasRepo implements EmailAliasRepository.
+type emailAliasRepo struct {
+	pool dbPool
 }
 
-func (r *appPasswordRepo) Create(ctx context.Context, token AppPassword) (*AppPassword, error) {
-	const q = `
-INSERT INTO app_passwords (user_id, label, token_hash, expires_at)
-VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at
-`
-	defer observeDB(ctx, "app_passwords.create")()
-	row := r.pool.QueryRowContext(ctx, q, token.UserID, token.Label, token.TokenHash, token.ExpiresAt)
-	t, err := scanAppPassword(row.Scan)
-	if err != nil {
-		return nil, err
-	}
-	return &t, nil
-}
+const emailAliasColumns = `id, user_id, email, verified_at, created_at`
 
-func (r *appPasswordRepo) FindValidByUser(ctx context.Context, userID int64) ([]AppPassword, error) {
-	const q = `
-SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at
-FROM app_passwords
-WHERE user_id=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
-ORDER BY created_at DESC
-`
-	defer observeDB(ctx, "app_passwords.find_valid_by_user")()
+func (r *emailAliasRepo) ListByUser(ctx context.Context, userID int64) ([]EmailAlias, error) {
+	const q = `SELECT ` + emailAliasColumns + ` FROM user_email_aliases WHERE user_id=$1 ORDER BY email_key`
+	defer observeDB(ctx, "user_email_aliases.list_by_user")()
 	rows, err := r.pool.QueryContext(ctx, q, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var result []AppPassword
+	var aliases []EmailAlias
 	for rows.Next() {
-		t, err := scanAppPassword(rows.Scan)
+		alias, err := scanEmailAlias(rows.Scan)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, t)
+		aliases = append(aliases, alias)
 	}
-	return result, rows.Err()
+	return aliases, rows.Err()
 }
 
-func (r *appPasswordRepo) ListByUser(ctx context.Context, userID int64) ([]AppPassword, error) {
-	const q = `SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at FROM app_passwords WHERE user_id=$1 ORDER BY created_at DESC`
+func (r *emailAliasRepo) Create(ctx context.Context, userID int64, email, tokenHash string, verified bool) (*EmailAlias, error) {
+	const q = `
+INSERT INTO user_email_aliases (user_id, email, email_key, token_hash, verified_at)
+VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN NOW() END)
+ON CONFLICT (user_id, email_key) DO UPDATE SET
+    email = EXCLUDED.email,
+    token_hash = CASE WHEN user_email_aliases.verified_at IS NULL AND EXCLUDED.verified_at IS NULL THEN EXCLUDED.token_hash END,
+    verified_at = COALESCE(user_email_aliases.verified_at, EXCLUDED.verified_at),
+    created_at = CASE WHEN user_email_aliases.verified_at IS NULL THEN NOW() ELSE user_email_aliases.created_at END
+RETURNING ` + emailAliasColumns
+	defer observeDB(ctx, "user_email_aliases.create")()
+	token := sql.NullString{String: tokenHash, Valid: tokenHash != "" && !verified}
+	alias, err := scanEmailAlias(r.pool.QueryRowContext(ctx, q, userID, strings.TrimSpace(email), util.EmailLookupKey(email), token, verified).Scan)
+	if err != nil {
+		if isVerifiedAliasConflict(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &alias, nil
+}
+
+func (r *emailAliasRepo) Verify(ctx context.Context, userID int64, tokenHash string, notBefore time.Time) (*EmailAlias, error) {
+	const q = `
+UPDATE user_email_aliases SET verified_at = NOW(), token_hash = NULL
+WHERE user_id = $1 AND token_hash = $2 AND verified_at IS NULL AND created_at >= $3
+RETURNING ` + emailAliasColumns
+	defer observeDB(ctx, "user_email_aliases.verify")()
+	alias, err := scanEmailAlias(r.pool.QueryRowContext(ctx, q, userID, tokenHash, notBefore).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if isVerifiedAliasConflict(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &alias, nil
+}
+
+func (r *emailAliasRepo) Delete(ctx context.Context, userID, id int64) error {
+	const q = `DELETE FROM user_email_aliases WHERE user_id=$1 AND id=$2`
+	defer observeDB(ctx, "user_email_aliases.delete")()
+	res, err := r.pool.ExecContext(ctx, q, userID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isVerifiedAliasConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_user_email_aliases_verified"
+}
+
+func scanEmailAlias(scan rowScanner) (EmailAlias, error) {
+	var alias EmailAlias
+	err := scan(&alias.ID, &alias.UserID, &alias.Email, &alias.VerifiedAt, &alias.CreatedAt)
+	return alias, err
+}
+
+// appPasswordRepo implements AppPasswordRepository.
+type appPasswordRepo struct {
+	pool dbPool
+}
+
+func (r *appPasswordRepo) Create(ctx context.Context, token AppPassword) (*AppPassword, error) {
+	const q = `
+INSERT INTO app_passwords (user_id, label, token_hash, expires_at, api_role)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role
+`
+	defer observeDB(ctx, "app_passwords.create")()
+	row := r.pool.QueryRowContext(ctx, q, token.UserID, token.Label, token.TokenHash, token.ExpiresAt, token.APIRole)
+	t, err := scanAppPassword(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *appPasswordRepo) FindValidByUser(ctx context.Context, userID int64) ([]AppPassword, error) {
+	const q = `
+SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role
+FROM app_passwords
+WHERE user_id=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC
+`
+	defer observeDB(ctx, "app_passwords.find_valid_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AppPassword
+	for rows.Next() {
+		t, err := scanAppPassword(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+func (r *appPasswordRepo) ListByUser(ctx context.Context, userID int64) ([]AppPassword, error) {
+	const q = `SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role FROM app_passwords WHERE user_id=$1 ORDER BY created_at DESC`
 	defer observeDB(ctx, "app_passwords.list_by_user")()
 	rows, err := r.pool.QueryContext(ctx, q, userID)
 	if err != nil {
@@ -1499,91 +1999,2637 @@ func (r *appPasswordRepo) ListByUser(ctx context.Context, userID int64) ([]AppPa
 	return result, rows.Err()
 }
 
-func (r *appPasswordRepo) GetByID(ctx context.Context, id int64) (*AppPassword, error) {
-	const q = `SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at FROM app_passwords WHERE id=$1`
-	defer observeDB(ctx, "app_passwords.get_by_id")()
-	row := r.pool.QueryRowContext(ctx, q, id)
-	t, err := scanAppPassword(row.Scan)
+func (r *appPasswordRepo) GetByID(ctx context.Context, id int64) (*AppPassword, error) {
+	const q = `SELECT id, user_id, label, token_hash, created_at, expires_at, revoked_at, last_used_at, last_used_user_agent, last_used_ip, api_role FROM app_passwords WHERE id=$1`
+	defer observeDB(ctx, "app_passwords.get_by_id")()
+	row := r.pool.QueryRowContext(ctx, q, id)
+	t, err := scanAppPassword(row.Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *appPasswordRepo) Revoke(ctx context.Context, id int64) error {
+	const q = `UPDATE app_passwords SET revoked_at = NOW() WHERE id=$1`
+	defer observeDB(ctx, "app_passwords.revoke")()
+	_, err := r.pool.ExecContext(ctx, q, id)
+	return err
+}
+
+func (r *appPasswordRepo) DeleteRevoked(ctx context.Context, id int64) error {
+	const q = `DELETE FROM app_passwords WHERE id=$1 AND revoked_at IS NOT NULL`
+	defer observeDB(ctx, "app_passwords.delete_revoked")()
+	_, err := r.pool.ExecContext(ctx, q, id)
+	return err
+}
+
+func (r *appPasswordRepo) TouchLastUsed(ctx context.Context, id int64, userAgent, ipAddress string) error {
+	const q = `UPDATE app_passwords SET last_used_at = NOW(), last_used_user_agent = COALESCE(NULLIF($2, ''), last_used_user_agent), last_used_ip = COALESCE(NULLIF($3, ''), last_used_ip) WHERE id=$1`
+	defer observeDB(ctx, "app_passwords.touch_last_used")()
+	_, err := r.pool.ExecContext(ctx, q, id, userAgent, ipAddress)
+	return err
+}
+
+// deletedResourceRepo implements DeletedResourceRepository.
+type deletedResourceRepo struct {
+	pool dbPool
+}
+
+func (r *deletedResourceRepo) ListDeletedSince(ctx context.Context, resourceType string, collectionID int64, since time.Time) ([]DeletedResource, error) {
+	const q = `SELECT id, resource_type, collection_id, uid, resource_name, deleted_at FROM deleted_resources WHERE resource_type=$1 AND collection_id=$2 AND deleted_at > $3 ORDER BY deleted_at DESC`
+	defer observeDB(ctx, "deleted_resources.list_deleted_since")()
+	rows, err := r.pool.QueryContext(ctx, q, resourceType, collectionID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []DeletedResource
+	for rows.Next() {
+		var d DeletedResource
+		if err := rows.Scan(&d.ID, &d.ResourceType, &d.CollectionID, &d.UID, &d.ResourceName, &d.DeletedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+func (r *deletedResourceRepo) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
+	const q = `DELETE FROM deleted_resources WHERE resource_type=$1 AND collection_id=$2 AND uid=$3 AND resource_name=$4`
+	defer observeDB(ctx, "deleted_resources.delete_by_identity")()
+	_, err := r.pool.ExecContext(ctx, q, resourceType, collectionID, uid, resourceName)
+	return err
+}
+
+func (r *deletedResourceRepo) Cleanup(ctx context.Context, olderThan time.Duration) (int64, error) {
+	const q = `DELETE FROM deleted_resources WHERE deleted_at < $1`
+	defer observeDB(ctx, "deleted_resources.cleanup")()
+	cutoff := time.Now().Add(-olderThan)
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rows, nil
+}
+
+func (r *deletedResourceRepo) ListOrphaned(ctx context.Context) ([]DeletedResource, error) {
+	const q = `
+SELECT d.id, d.resource_type, d.collection_id, d.uid, d.resource_name, d.deleted_at
+FROM deleted_resources d
+WHERE (d.resource_type = 'event' AND (
+        NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = d.collection_id)
+        OR EXISTS (SELECT 1 FROM events e WHERE e.calendar_id = d.collection_id AND e.resource_name = d.resource_name)))
+   OR (d.resource_type = 'contact' AND (
+        NOT EXISTS (SELECT 1 FROM address_books b WHERE b.id = d.collection_id)
+        OR EXISTS (SELECT 1 FROM contacts ct WHERE ct.address_book_id = d.collection_id AND ct.resource_name = d.resource_name)))
+ORDER BY d.id`
+	defer observeDB(ctx, "deleted_resources.list_orphaned")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []DeletedResource
+	for rows.Next() {
+		var d DeletedResource
+		if err := rows.Scan(&d.ID, &d.ResourceType, &d.CollectionID, &d.UID, &d.ResourceName, &d.DeletedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// orphanRepo implements OrphanRepository.
+type orphanRepo struct {
+	pool dbPool
+}
+
+func (r *orphanRepo) List(ctx context.Context) ([]OrphanedResource, error) {
+	const q = `
+SELECT 'event', e.calendar_id, e.uid, e.resource_name
+FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = e.calendar_id)
+UNION ALL
+SELECT 'contact', ct.address_book_id, ct.uid, ct.resource_name
+FROM contacts ct
+WHERE NOT EXISTS (SELECT 1 FROM address_books b WHERE b.id = ct.address_book_id)
+ORDER BY 1, 2, 3`
+	defer observeDB(ctx, "orphans.list")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []OrphanedResource
+	for rows.Next() {
+		var o OrphanedResource
+		if err := rows.Scan(&o.ResourceType, &o.CollectionID, &o.UID, &o.ResourceName); err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, rows.Err()
+}
+
+func (r *orphanRepo) Delete(ctx context.Context, resourceType string, collectionID int64, uid string) error {
+	var q string
+	switch resourceType {
+	case "event":
+		q = `DELETE FROM events e WHERE e.calendar_id = $1 AND e.uid = $2
+AND NOT EXISTS (SELECT 1 FROM calendars c WHERE c.id = e.calendar_id)`
+	case "contact":
+		q = `DELETE FROM contacts ct WHERE ct.address_book_id = $1 AND ct.uid = $2
+AND NOT EXISTS (SELECT 1 FROM address_books b WHERE b.id = ct.address_book_id)`
+	default:
+		return errors.New("unknown resource type " + resourceType)
+	}
+	defer observeDB(ctx, "orphans.delete")()
+	_, err := r.pool.ExecContext(ctx, q, collectionID, uid)
+	return err
+}
+
+// canonicalFormRepo implements CanonicalFormRepository.
+type canonicalFormRepo struct {
+	pool dbPool
+}
+
+func (r *canonicalFormRepo) BackfillEvents(ctx context.Context, limit int) (int, error) {
+	defer observeDB(ctx, "events.backfill_canonical")()
+	return r.backfill(ctx, limit,
+		`SELECT id, `+eventBody+` FROM events WHERE canonical_ical IS NULL ORDER BY id LIMIT $1`,
+		`UPDATE events SET canonical_ical=$2, etag=$3, last_modified = CASE WHEN etag=$3 THEN last_modified ELSE NOW() END WHERE id=$1 AND `+eventBody+`=$4`)
+}
+
+func (r *canonicalFormRepo) BackfillContacts(ctx context.Context, limit int) (int, error) {
+	defer observeDB(ctx, "contacts.backfill_canonical")()
+	return r.backfill(ctx, limit,
+		`SELECT id, `+contactBody+` FROM contacts WHERE canonical_vcard IS NULL ORDER BY id LIMIT $1`,
+		`UPDATE contacts SET canonical_vcard=$2, etag=$3, last_modified = CASE WHEN etag=$3 THEN last_modified ELSE NOW() END WHERE id=$1 AND `+contactBody+`=$4`)
+}
+
+// backfill updates each pending row unless its payload changed since it was
+// read. A changed ETag bumps last_modified so sync clients refetch it.
+func (r *canonicalFormRepo) backfill(ctx context.Context, limit int, selectQ, updateQ string) (int, error) {
+	rows, err := r.pool.QueryContext(ctx, selectQ, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id  int64
+		raw string
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, p := range batch {
+		canonical := util.Canonicalize(p.raw)
+		res, err := r.pool.ExecContext(ctx, updateQ, p.id, canonical, util.CanonicalETag(p.raw), p.raw)
+		if err != nil {
+			return updated, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// heldDeletionRepo implements HeldDeletionRepository.
+type heldDeletionRepo struct {
+	pool dbPool
+}
+
+func (r *heldDeletionRepo) Hold(ctx context.Context, held HeldDeletion) error {
+	const q = `
+INSERT INTO held_deletions (resource_type, collection_id, uid, resource_name, owner_id, deleted_by, client)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (resource_type, collection_id, uid) DO NOTHING`
+	defer observeDB(ctx, "held_deletions.hold")()
+	_, err := r.pool.ExecContext(ctx, q, held.ResourceType, held.CollectionID, held.UID, held.ResourceName, held.OwnerID, held.DeletedBy, held.Client)
+	return err
+}
+
+func (r *heldDeletionRepo) IsHeld(ctx context.Context, resourceType string, collectionID int64, uid string) (bool, error) {
+	const q = `SELECT EXISTS (SELECT 1 FROM held_deletions WHERE resource_type=$1 AND collection_id=$2 AND uid=$3)`
+	defer observeDB(ctx, "held_deletions.is_held")()
+	var held bool
+	if err := r.pool.QueryRowContext(ctx, q, resourceType, collectionID, uid).Scan(&held); err != nil {
+		return false, err
+	}
+	return held, nil
+}
+
+// ListByOwner skips holds whose resource has since been removed by another
+// path (web UI, another device), since there is nothing left to review.
+func (r *heldDeletionRepo) ListByOwner(ctx context.Context, ownerID int64) ([]HeldDeletion, error) {
+	const q = `
+SELECT h.id, h.resource_type, h.collection_id, h.uid, h.resource_name, h.owner_id, h.deleted_by, h.client, h.held_at
+FROM held_deletions h
+WHERE h.owner_id=$1 AND (
+    (h.resource_type = 'event' AND EXISTS (SELECT 1 FROM events e WHERE e.calendar_id = h.collection_id AND e.uid = h.uid))
+    OR (h.resource_type = 'contact' AND EXISTS (SELECT 1 FROM contacts c WHERE c.address_book_id = h.collection_id AND c.uid = h.uid))
+)
+ORDER BY h.held_at DESC, h.id`
+	defer observeDB(ctx, "held_deletions.list_by_owner")()
+	rows, err := r.pool.QueryContext(ctx, q, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []HeldDeletion
+	for rows.Next() {
+		var h HeldDeletion
+		if err := rows.Scan(&h.ID, &h.ResourceType, &h.CollectionID, &h.UID, &h.ResourceName, &h.OwnerID, &h.DeletedBy, &h.Client, &h.HeldAt); err != nil {
+			return nil, err
+		}
+		result = append(result, h)
+	}
+	return result, rows.Err()
+}
+
+func (r *heldDeletionRepo) Remove(ctx context.Context, ownerID int64, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `DELETE FROM held_deletions WHERE owner_id=$1 AND id = ANY($2)`
+	defer observeDB(ctx, "held_deletions.remove")()
+	_, err := r.pool.ExecContext(ctx, q, ownerID, pq.Array(ids))
+	return err
+}
+
+// collectionSyncRepo implements CollectionSyncRepository.
+type collectionSyncRepo struct {
+	pool dbPool
+}
+
+const collectionSyncColumns = `s.collection_type, s.collection_id, s.user_id, s.device_key, s.app_password_id, COALESCE(ap.label, ''), s.user_agent, s.last_synced_at, s.sync_count`
+
+func (r *collectionSyncRepo) Record(ctx context.Context, sync CollectionSync) error {
+	const q = `
+INSERT INTO collection_syncs (collection_type, collection_id, user_id, device_key, app_password_id, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (collection_type, collection_id, user_id, device_key) DO UPDATE
+SET app_password_id = EXCLUDED.app_password_id, user_agent = EXCLUDED.user_agent, last_synced_at = NOW(), sync_count = collection_syncs.sync_count + 1`
+	defer observeDB(ctx, "collection_syncs.record")()
+	_, err := r.pool.ExecContext(ctx, q, sync.CollectionType, sync.CollectionID, sync.UserID, sync.DeviceKey, sync.AppPasswordID, sync.UserAgent)
+	return err
+}
+
+func (r *collectionSyncRepo) ListForCollection(ctx context.Context, userID int64, collectionType string, collectionID int64) ([]CollectionSync, error) {
+	q := `SELECT ` + collectionSyncColumns + ` FROM collection_syncs s LEFT JOIN app_passwords ap ON ap.id = s.app_password_id
+WHERE s.user_id=$1 AND s.collection_type=$2 AND s.collection_id=$3 ORDER BY s.last_synced_at DESC`
+	defer observeDB(ctx, "collection_syncs.list_for_collection")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, collectionType, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCollectionSyncs(rows)
+}
+
+func (r *collectionSyncRepo) ListByUser(ctx context.Context, userID int64, since time.Time) ([]CollectionSync, error) {
+	q := `SELECT ` + collectionSyncColumns + ` FROM collection_syncs s LEFT JOIN app_passwords ap ON ap.id = s.app_password_id
+WHERE s.user_id=$1 AND s.last_synced_at >= $2 ORDER BY s.collection_type, s.collection_id, s.last_synced_at DESC`
+	defer observeDB(ctx, "collection_syncs.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return scanCollectionSyncs(rows)
+}
+
+func scanCollectionSyncs(rows *sql.Rows) ([]CollectionSync, error) {
+	defer rows.Close()
+	var result []CollectionSync
+	for rows.Next() {
+		var s CollectionSync
+		var appPasswordID sql.NullInt64
+		if err := rows.Scan(&s.CollectionType, &s.CollectionID, &s.UserID, &s.DeviceKey, &appPasswordID, &s.AppPasswordLabel, &s.UserAgent, &s.LastSyncedAt, &s.SyncCount); err != nil {
+			return nil, err
+		}
+		if appPasswordID.Valid {
+			id := appPasswordID.Int64
+			s.AppPasswordID = &id
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+func (r *collectionSyncRepo) Usage(ctx context.Context, collectionType string, collectionID int64) (CollectionUsage, error) {
+	q := `SELECT COUNT(*), COALESCE(SUM(octet_length(` + eventBody + `)), 0) FROM events WHERE calendar_id=$1`
+	if collectionType == "addressbook" {
+		q = `SELECT COUNT(*), COALESCE(SUM(octet_length(` + contactBody + `)), 0) FROM contacts WHERE address_book_id=$1`
+	}
+	defer observeDB(ctx, "collection_syncs.usage")()
+	var usage CollectionUsage
+	err := r.pool.QueryRowContext(ctx, q, collectionID).Scan(&usage.ResourceCount, &usage.DataSize)
+	return usage, err
+}
+
+func (r *collectionSyncRepo) Checksum(ctx context.Context, collectionType string, collectionID int64) (string, error) {
+	q := `SELECT uid, etag FROM events WHERE calendar_id=$1`
+	if collectionType == "addressbook" {
+		q = `SELECT uid, etag FROM contacts WHERE address_book_id=$1`
+	}
+	defer observeDB(ctx, "collection_syncs.checksum")()
+	rows, err := r.pool.QueryContext(ctx, q, collectionID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var pairs [][2]string
+	for rows.Next() {
+		var uid, etag string
+		if err := rows.Scan(&uid, &etag); err != nil {
+			return "", err
+		}
+		pairs = append(pairs, [2]string{uid, etag})
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return ContentChecksum(pairs), nil
+}
+
+// ContentChecksum returns the hex SHA-256 of "UID NUL ETag LF" for each
+// pair, sorted by UID byte order, so it does not depend on the database's
+// collation or row order.
+func ContentChecksum(pairs [][2]string) string {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	h := sha256.New()
+	for _, p := range pairs {
+		h.Write([]byte(p[0] + "\x00" + p[1] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// clientRepairRepo implements ClientRepairRepository.
+type clientRepairRepo struct {
+	pool dbPool
+}
+
+func (r *clientRepairRepo) Record(ctx context.Context, repair ClientRepair) error {
+	const q = `
+INSERT INTO client_repairs (user_id, device_key, app_password_id, user_agent, repair, last_resource)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, device_key, repair) DO UPDATE
+SET app_password_id = EXCLUDED.app_password_id, user_agent = EXCLUDED.user_agent, last_resource = EXCLUDED.last_resource,
+    last_seen_at = NOW(), repair_count = client_repairs.repair_count + 1`
+	defer observeDB(ctx, "client_repairs.record")()
+	_, err := r.pool.ExecContext(ctx, q, repair.UserID, repair.DeviceKey, repair.AppPasswordID, repair.UserAgent, repair.Repair, repair.LastResource)
+	return err
+}
+
+func (r *clientRepairRepo) ListByUser(ctx context.Context, userID int64) ([]ClientRepair, error) {
+	const q = `SELECT c.user_id, c.device_key, c.app_password_id, COALESCE(ap.label, ''), c.user_agent, c.repair, c.repair_count, c.last_resource, c.first_seen_at, c.last_seen_at
+FROM client_repairs c LEFT JOIN app_passwords ap ON ap.id = c.app_password_id
+WHERE c.user_id = $1 ORDER BY c.last_seen_at DESC, c.repair`
+	defer observeDB(ctx, "client_repairs.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ClientRepair
+	for rows.Next() {
+		var c ClientRepair
+		var appPasswordID sql.NullInt64
+		if err := rows.Scan(&c.UserID, &c.DeviceKey, &appPasswordID, &c.AppPasswordLabel, &c.UserAgent, &c.Repair, &c.Count, &c.LastResource, &c.FirstSeenAt, &c.LastSeenAt); err != nil {
+			return nil, err
+		}
+		if appPasswordID.Valid {
+			id := appPasswordID.Int64
+			c.AppPasswordID = &id
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func (r *clientRepairRepo) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM client_repairs WHERE last_seen_at < $1`
+	defer observeDB(ctx, "client_repairs.delete_older_than")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// changeRepo implements ChangeRepository.
+type changeRepo struct {
+	pool dbPool
+}
+
+// changeHorizon is the oldest transaction that may still be running. Every
+// change made by an earlier transaction has committed or rolled back, and
+// every transaction still to commit has a later ID.
+const changeHorizon = `pg_snapshot_xmin(pg_current_snapshot())`
+
+func (r *changeRepo) ListSince(ctx context.Context, calendarIDs, addressBookIDs []int64, after ChangeCursor, limit int) ([]Change, error) {
+	const q = `
+SELECT change_txid::text::bigint, change_seq, resource_type, collection_id, uid, resource_name, etag, created, deleted, changed_at, COALESCE(changed_by, 0) FROM (
+	SELECT change_txid, change_seq, 'event' AS resource_type, calendar_id AS collection_id, uid, resource_name, etag, change_created AS created, FALSE AS deleted, last_modified AS changed_at, changed_by
+	FROM events WHERE calendar_id = ANY($1)
+	UNION ALL
+	SELECT change_txid, change_seq, 'contact', address_book_id, uid, resource_name, etag, change_created, FALSE, last_modified, changed_by
+	FROM contacts WHERE address_book_id = ANY($2)
+	UNION ALL
+	SELECT change_txid, change_seq, resource_type, collection_id, uid, resource_name, '', FALSE, TRUE, deleted_at, changed_by
+	FROM deleted_resources
+	WHERE (resource_type = 'event' AND collection_id = ANY($1)) OR (resource_type = 'contact' AND collection_id = ANY($2))
+) c
+WHERE (change_txid, change_seq) > ($3::text::xid8, $4) AND change_txid < ` + changeHorizon + `
+ORDER BY change_txid, change_seq
+LIMIT $5`
+	defer observeDB(ctx, "changes.list_since")()
+	rows, err := r.pool.QueryContext(ctx, q, pq.Array(calendarIDs), pq.Array(addressBookIDs), after.TxID, after.Seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Cursor.TxID, &c.Cursor.Seq, &c.ResourceType, &c.CollectionID, &c.UID, &c.ResourceName, &c.ETag, &c.Created, &c.Deleted, &c.ChangedAt, &c.ChangedBy); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+func (r *changeRepo) Latest(ctx context.Context) (ChangeCursor, error) {
+	const q = `SELECT ` + changeHorizon + `::text::bigint - 1`
+	defer observeDB(ctx, "changes.latest")()
+	var cursor ChangeCursor
+	if err := r.pool.QueryRowContext(ctx, q).Scan(&cursor.TxID); err != nil {
+		return ChangeCursor{}, err
+	}
+	// Every change of the transaction before the horizon comes before it.
+	cursor.Seq = math.MaxInt64
+	return cursor, nil
+}
+
+// freeBusyLinkRepo implements FreeBusyLinkRepository.
+type freeBusyLinkRepo struct {
+	pool dbPool
+}
+
+const freeBusyLinkColumns = `user_id, token, window_days, created_at, updated_at`
+
+func (r *freeBusyLinkRepo) GetByUser(ctx context.Context, userID int64) (*FreeBusyLink, error) {
+	const q = `SELECT ` + freeBusyLinkColumns + ` FROM freebusy_links WHERE user_id=$1`
+	defer observeDB(ctx, "freebusy_links.get_by_user")()
+	return scanFreeBusyLink(r.pool.QueryRowContext(ctx, q, userID))
+}
+
+func (r *freeBusyLinkRepo) GetByToken(ctx context.Context, token string) (*FreeBusyLink, error) {
+	const q = `SELECT ` + freeBusyLinkColumns + ` FROM freebusy_links WHERE token=$1`
+	defer observeDB(ctx, "freebusy_links.get_by_token")()
+	return scanFreeBusyLink(r.pool.QueryRowContext(ctx, q, token))
+}
+
+func (r *freeBusyLinkRepo) Upsert(ctx context.Context, link FreeBusyLink) (*FreeBusyLink, error) {
+	const q = `
+INSERT INTO freebusy_links (user_id, token, window_days)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET token = EXCLUDED.token, window_days = EXCLUDED.window_days, updated_at = NOW()
+RETURNING ` + freeBusyLinkColumns
+	defer observeDB(ctx, "freebusy_links.upsert")()
+	return scanFreeBusyLink(r.pool.QueryRowContext(ctx, q, link.UserID, link.Token, link.WindowDays))
+}
+
+func (r *freeBusyLinkRepo) Delete(ctx context.Context, userID int64) error {
+	const q = `DELETE FROM freebusy_links WHERE user_id=$1`
+	defer observeDB(ctx, "freebusy_links.delete")()
+	_, err := r.pool.ExecContext(ctx, q, userID)
+	return err
+}
+
+func scanFreeBusyLink(row *sql.Row) (*FreeBusyLink, error) {
+	var link FreeBusyLink
+	if err := row.Scan(&link.UserID, &link.Token, &link.WindowDays, &link.CreatedAt, &link.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// taskFeedLinkRepo implements TaskFeedLinkRepository.
+type taskFeedLinkRepo struct {
+	pool dbPool
+}
+
+const taskFeedLinkColumns = `user_id, token, created_at, updated_at`
+
+func (r *taskFeedLinkRepo) GetByUser(ctx context.Context, userID int64) (*TaskFeedLink, error) {
+	const q = `SELECT ` + taskFeedLinkColumns + ` FROM task_feed_links WHERE user_id=$1`
+	defer observeDB(ctx, "task_feed_links.get_by_user")()
+	return scanTaskFeedLink(r.pool.QueryRowContext(ctx, q, userID))
+}
+
+func (r *taskFeedLinkRepo) GetByToken(ctx context.Context, token string) (*TaskFeedLink, error) {
+	const q = `SELECT ` + taskFeedLinkColumns + ` FROM task_feed_links WHERE token=$1`
+	defer observeDB(ctx, "task_feed_links.get_by_token")()
+	return scanTaskFeedLink(r.pool.QueryRowContext(ctx, q, token))
+}
+
+func (r *taskFeedLinkRepo) Upsert(ctx context.Context, link TaskFeedLink) (*TaskFeedLink, error) {
+	const q = `
+INSERT INTO task_feed_links (user_id, token)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET token = EXCLUDED.token, updated_at = NOW()
+RETURNING ` + taskFeedLinkColumns
+	defer observeDB(ctx, "task_feed_links.upsert")()
+	return scanTaskFeedLink(r.pool.QueryRowContext(ctx, q, link.UserID, link.Token))
+}
+
+func (r *taskFeedLinkRepo) Delete(ctx context.Context, userID int64) error {
+	const q = `DELETE FROM task_feed_links WHERE user_id=$1`
+	defer observeDB(ctx, "task_feed_links.delete")()
+	_, err := r.pool.ExecContext(ctx, q, userID)
+	return err
+}
+
+func scanTaskFeedLink(row *sql.Row) (*TaskFeedLink, error) {
+	var link TaskFeedLink
+	if err := row.Scan(&link.UserID, &link.Token, &link.CreatedAt, &link.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// conferenceHookRepo implements ConferenceHookRepository.
+type conferenceHookRepo struct {
+	pool dbPool
+}
+
+const conferenceHookColumns = `calendar_id, url, secret, created_at, updated_at`
+
+func (r *conferenceHookRepo) GetByCalendar(ctx context.Context, calendarID int64) (*ConferenceHook, error) {
+	const q = `SELECT ` + conferenceHookColumns + ` FROM conference_hooks WHERE calendar_id=$1`
+	defer observeDB(ctx, "conference_hooks.get_by_calendar")()
+	return scanConferenceHook(r.pool.QueryRowContext(ctx, q, calendarID))
+}
+
+func (r *conferenceHookRepo) Upsert(ctx context.Context, hook ConferenceHook) (*ConferenceHook, error) {
+	const q = `
+INSERT INTO conference_hooks (calendar_id, url, secret)
+VALUES ($1, $2, $3)
+ON CONFLICT (calendar_id) DO UPDATE
+SET url = EXCLUDED.url, secret = EXCLUDED.secret, updated_at = NOW()
+RETURNING ` + conferenceHookColumns
+	defer observeDB(ctx, "conference_hooks.upsert")()
+	return scanConferenceHook(r.pool.QueryRowContext(ctx, q, hook.CalendarID, hook.URL, hook.Secret))
+}
+
+func (r *conferenceHookRepo) Delete(ctx context.Context, calendarID int64) error {
+	const q = `DELETE FROM conference_hooks WHERE calendar_id=$1`
+	defer observeDB(ctx, "conference_hooks.delete")()
+	_, err := r.pool.ExecContext(ctx, q, calendarID)
+	return err
+}
+
+func scanConferenceHook(row *sql.Row) (*ConferenceHook, error) {
+	var hook ConferenceHook
+	if err := row.Scan(&hook.CalendarID, &hook.URL, &hook.Secret, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// retentionRepo implements RetentionRepository.
+type retentionRepo struct {
+	pool dbPool
+}
+
+const retentionColumns = `calendar_id, months, last_run_at, created_at, updated_at`
+
+func (r *retentionRepo) GetByCalendar(ctx context.Context, calendarID int64) (*RetentionPolicy, error) {
+	const q = `SELECT ` + retentionColumns + ` FROM calendar_retention WHERE calendar_id=$1`
+	defer observeDB(ctx, "calendar_retention.get_by_calendar")()
+	policy, err := scanRetentionPolicy(r.pool.QueryRowContext(ctx, q, calendarID).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *retentionRepo) Upsert(ctx context.Context, policy RetentionPolicy) (*RetentionPolicy, error) {
+	const q = `
+INSERT INTO calendar_retention (calendar_id, months)
+VALUES ($1, $2)
+ON CONFLICT (calendar_id) DO UPDATE
+SET months = EXCLUDED.months, updated_at = NOW()
+RETURNING ` + retentionColumns
+	defer observeDB(ctx, "calendar_retention.upsert")()
+	saved, err := scanRetentionPolicy(r.pool.QueryRowContext(ctx, q, policy.CalendarID, policy.Months).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *retentionRepo) Delete(ctx context.Context, calendarID int64) error {
+	const q = `DELETE FROM calendar_retention WHERE calendar_id=$1`
+	defer observeDB(ctx, "calendar_retention.delete")()
+	_, err := r.pool.ExecContext(ctx, q, calendarID)
+	return err
+}
+
+func (r *retentionRepo) ListAll(ctx context.Context) ([]RetentionPolicy, error) {
+	const q = `SELECT ` + retentionColumns + ` FROM calendar_retention ORDER BY calendar_id`
+	defer observeDB(ctx, "calendar_retention.list_all")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []RetentionPolicy
+	for rows.Next() {
+		policy, err := scanRetentionPolicy(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, policy)
+	}
+	return all, rows.Err()
+}
+
+func (r *retentionRepo) ListEndedBefore(ctx context.Context, calendarID int64, cutoff time.Time, afterID int64, limit int) ([]Event, error) {
+	const q = `SELECT ` + eventColumns + ` FROM events WHERE calendar_id=$1 AND COALESCE(dtend, dtstart) < $2 AND id > $3 ORDER BY id LIMIT $4`
+	defer observeDB(ctx, "calendar_retention.list_ended_before")()
+	rows, err := r.pool.QueryContext(ctx, q, calendarID, cutoff, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Event
+	for rows.Next() {
+		ev, err := scanEvent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ev)
+	}
+	return result, rows.Err()
+}
+
+func (r *retentionRepo) Expire(ctx context.Context, calendarID int64, events []Event, cutoff time.Time) (int, error) {
+	defer observeDB(ctx, "calendar_retention.expire")()
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var deleted int64
+	if len(events) > 0 {
+		uids := make([]string, len(events))
+		etags := make([]string, len(events))
+		for i, ev := range events {
+			uids[i], etags[i] = ev.UID, ev.ETag
+		}
+		// As in DeleteByUIDs, the ctag trigger tombstones each row and the
+		// ctag is bumped once below.
+		if _, err := tx.ExecContext(ctx, `SET LOCAL calcard.batch_ctag = 'on'`); err != nil {
+			return 0, err
+		}
+		const q = `
+WITH gone AS (
+    DELETE FROM events
+    WHERE calendar_id = $1 AND (uid, etag) IN (SELECT * FROM unnest($2::text[], $3::text[]))
+    RETURNING uid, summary, dtstart, dtend
+)
+INSERT INTO retention_deletions (calendar_id, uid, summary, dtstart, dtend, cutoff)
+SELECT $1, uid, summary, dtstart, dtend, $4 FROM gone`
+		res, err := tx.ExecContext(ctx, q, calendarID, pq.Array(uids), pq.Array(etags), cutoff)
+		if err != nil {
+			return 0, err
+		}
+		if deleted, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+		if deleted > 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = $1`, calendarID); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE calendar_retention SET last_run_at = NOW() WHERE calendar_id = $1`, calendarID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+func (r *retentionRepo) ListDeletions(ctx context.Context, calendarID int64, limit int) ([]RetentionDeletion, error) {
+	const q = `SELECT id, calendar_id, uid, summary, dtstart, dtend, cutoff, deleted_at FROM retention_deletions WHERE calendar_id=$1 ORDER BY deleted_at DESC, id DESC LIMIT $2`
+	defer observeDB(ctx, "retention_deletions.list")()
+	rows, err := r.pool.QueryContext(ctx, q, calendarID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RetentionDeletion
+	for rows.Next() {
+		var d RetentionDeletion
+		var summary sql.NullString
+		var dtstart, dtend sql.NullTime
+		if err := rows.Scan(&d.ID, &d.CalendarID, &d.UID, &summary, &dtstart, &dtend, &d.Cutoff, &d.DeletedAt); err != nil {
+			return nil, err
+		}
+		d.Summary = nullableString(summary)
+		d.DTStart = nullableTime(dtstart)
+		d.DTEnd = nullableTime(dtend)
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+func (r *retentionRepo) DeleteDeletionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM retention_deletions WHERE deleted_at < $1`
+	defer observeDB(ctx, "retention_deletions.delete_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanRetentionPolicy(scan rowScanner) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	var lastRun sql.NullTime
+	if err := scan(&policy.CalendarID, &policy.Months, &lastRun, &policy.CreatedAt, &policy.UpdatedAt); err != nil {
+		return policy, err
+	}
+	policy.LastRunAt = nullableTime(lastRun)
+	return policy, nil
+}
+
+// eventRevisionRepo implements EventRevisionRepository.
+type eventRevisionRepo struct {
+	pool dbPool
+}
+
+func (r *eventRevisionRepo) ListAsOf(ctx context.Context, calendarID int64, at time.Time) ([]EventRevision, error) {
+	const q = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, location, dtstart, dtend, all_day, changed_by, valid_from, valid_to
+FROM event_revisions
+WHERE calendar_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+ORDER BY uid`
+	defer observeDB(ctx, "event_revisions.list_as_of")()
+	rows, err := r.pool.QueryContext(ctx, q, calendarID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []EventRevision
+	for rows.Next() {
+		var rev EventRevision
+		var raw, summary, location sql.NullString
+		var start, end, validTo sql.NullTime
+		var changedBy sql.NullInt64
+		if err := rows.Scan(&rev.ID, &rev.CalendarID, &rev.UID, &rev.ResourceName, &raw, &rev.ETag, &summary, &location, &start, &end, &rev.AllDay, &changedBy, &rev.ValidFrom, &validTo); err != nil {
+			return nil, err
+		}
+		rev.RawICAL = raw.String
+		rev.Summary = nullableString(summary)
+		rev.Location = nullableString(location)
+		rev.DTStart = nullableTime(start)
+		rev.DTEnd = nullableTime(end)
+		rev.ValidTo = nullableTime(validTo)
+		if changedBy.Valid {
+			rev.ChangedBy = &changedBy.Int64
+		}
+		result = append(result, rev)
+	}
+	return result, rows.Err()
+}
+
+func (r *eventRevisionRepo) HistorySince(ctx context.Context) (time.Time, error) {
+	const q = `SELECT value::timestamptz FROM application WHERE key = 'event_history_since'`
+	defer observeDB(ctx, "event_revisions.history_since")()
+	var since time.Time
+	err := r.pool.QueryRowContext(ctx, q).Scan(&since)
+	return since, err
+}
+
+func (r *eventRevisionRepo) DeleteClosedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM event_revisions WHERE valid_to < $1`
+	defer observeDB(ctx, "event_revisions.delete_closed_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// contactRevisionRepo implements ContactRevisionRepository.
+type contactRevisionRepo struct {
+	pool dbPool
+}
+
+func (r *contactRevisionRepo) ListChangedSince(ctx context.Context, addressBookIDs []int64, since time.Time, limit int) ([]ContactChange, error) {
+	// A revision opened in the window is a create or an update, compared
+	// with the revision it closed. One closed in the window that nothing
+	// replaced is a deletion, made by whoever closed it.
+	const q = `
+SELECT address_book_id, uid, resource_name, display_name, before, after, changed_by, changed_at FROM (
+    SELECT r.address_book_id, r.uid, r.resource_name, r.display_name, p.body AS before,
+        COALESCE(r.raw_vcard, (SELECT body FROM content_bodies WHERE hash = r.raw_vcard_hash)) AS after,
+        r.changed_by, r.valid_from AS changed_at
+    FROM contact_revisions r
+    LEFT JOIN LATERAL (
+        SELECT COALESCE(p.raw_vcard, (SELECT body FROM content_bodies WHERE hash = p.raw_vcard_hash)) AS body
+        FROM contact_revisions p
+        WHERE p.address_book_id = r.address_book_id AND p.uid = r.uid AND p.valid_to = r.valid_from
+        ORDER BY p.id DESC LIMIT 1
+    ) p ON TRUE
+    WHERE r.address_book_id = ANY($1) AND r.valid_from >= $2
+    UNION ALL
+    SELECT r.address_book_id, r.uid, r.resource_name, r.display_name,
+        COALESCE(r.raw_vcard, (SELECT body FROM content_bodies WHERE hash = r.raw_vcard_hash)), NULL,
+        r.closed_by, r.valid_to
+    FROM contact_revisions r
+    WHERE r.address_book_id = ANY($1) AND r.valid_to >= $2
+        AND NOT EXISTS (SELECT 1 FROM contact_revisions n WHERE n.address_book_id = r.address_book_id AND n.uid = r.uid AND n.valid_from = r.valid_to)
+) c
+ORDER BY changed_at DESC
+LIMIT $3`
+	defer observeDB(ctx, "contact_revisions.list_changed_since")()
+	rows, err := r.pool.QueryContext(ctx, q, pq.Array(addressBookIDs), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ContactChange
+	for rows.Next() {
+		var change ContactChange
+		var displayName, before, after sql.NullString
+		var changedBy sql.NullInt64
+		if err := rows.Scan(&change.AddressBookID, &change.UID, &change.ResourceName, &displayName, &before, &after, &changedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		change.DisplayName = nullableString(displayName)
+		change.Before, change.After = before.String, after.String
+		if changedBy.Valid {
+			change.ChangedBy = &changedBy.Int64
+		}
+		result = append(result, change)
+	}
+	return result, rows.Err()
+}
+
+func (r *contactRevisionRepo) DeleteClosedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM contact_revisions WHERE valid_to < $1`
+	defer observeDB(ctx, "contact_revisions.delete_closed_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// userGroupRepo implements UserGroupRepository.
+type userGroupRepo struct {
+	pool dbPool
+}
+
+const userGroupColumns = `g.id, g.owner_user_id, g.name, g.created_at,
+    ARRAY(SELECT m.user_id FROM user_group_members m WHERE m.group_id = g.id ORDER BY m.user_id)`
+
+// Create adds an empty group. A name the owner already uses returns
+// ErrConflict.
+func (r *userGroupRepo) Create(ctx context.Context, ownerUserID int64, name string) (*UserGroup, error) {
+	const q = `INSERT INTO user_groups (owner_user_id, name) VALUES ($1, $2) RETURNING id, owner_user_id, name, created_at`
+	defer observeDB(ctx, "user_groups.create")()
+	var g UserGroup
+	if err := r.pool.QueryRowContext(ctx, q, ownerUserID, name).Scan(&g.ID, &g.OwnerUserID, &g.Name, &g.CreatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *userGroupRepo) GetByID(ctx context.Context, id int64) (*UserGroup, error) {
+	const q = `SELECT ` + userGroupColumns + ` FROM user_groups g WHERE g.id = $1`
+	defer observeDB(ctx, "user_groups.get_by_id")()
+	g, err := scanUserGroup(r.pool.QueryRowContext(ctx, q, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *userGroupRepo) ListByOwner(ctx context.Context, ownerUserID int64) ([]UserGroup, error) {
+	const q = `SELECT ` + userGroupColumns + ` FROM user_groups g WHERE g.owner_user_id = $1 ORDER BY g.name`
+	defer observeDB(ctx, "user_groups.list_by_owner")()
+	rows, err := r.pool.QueryContext(ctx, q, ownerUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []UserGroup
+	for rows.Next() {
+		g, err := scanUserGroup(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// Delete removes the group and, with it, the ACL entries granted to it.
+func (r *userGroupRepo) Delete(ctx context.Context, ownerUserID, id int64) error {
+	const q = `
+WITH deleted AS (
+    DELETE FROM user_groups WHERE id = $1 AND owner_user_id = $2 RETURNING id
+)
+DELETE FROM acl_entries
+WHERE principal_href IN (SELECT '/dav/principals/groups/' || id::text || '/' FROM deleted)`
+	defer observeDB(ctx, "user_groups.delete")()
+	_, err := r.pool.ExecContext(ctx, q, id, ownerUserID)
+	return err
+}
+
+func (r *userGroupRepo) AddMember(ctx context.Context, groupID, userID int64) error {
+	const q = `INSERT INTO user_group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	defer observeDB(ctx, "user_groups.add_member")()
+	_, err := r.pool.ExecContext(ctx, q, groupID, userID)
+	return err
+}
+
+func (r *userGroupRepo) RemoveMember(ctx context.Context, groupID, userID int64) error {
+	const q = `DELETE FROM user_group_members WHERE group_id = $1 AND user_id = $2`
+	defer observeDB(ctx, "user_groups.remove_member")()
+	_, err := r.pool.ExecContext(ctx, q, groupID, userID)
+	return err
+}
+
+func scanUserGroup(scan rowScanner) (UserGroup, error) {
+	var g UserGroup
+	err := scan(&g.ID, &g.OwnerUserID, &g.Name, &g.CreatedAt, pq.Array(&g.MemberIDs))
+	return g, err
+}
+
+// locationRepo implements LocationRepository.
+type locationRepo struct {
+	pool dbPool
+}
+
+const locationColumns = `id, user_id, name, address, latitude, longitude, created_at, updated_at`
+
+// Create saves a place. A name the user already uses returns ErrConflict.
+func (r *locationRepo) Create(ctx context.Context, loc Location) (*Location, error) {
+	const q = `INSERT INTO locations (user_id, name, address, latitude, longitude) VALUES ($1, $2, $3, $4, $5) RETURNING ` + locationColumns
+	defer observeDB(ctx, "locations.create")()
+	created, err := scanLocation(r.pool.QueryRowContext(ctx, q, loc.UserID, loc.Name, loc.Address, loc.Latitude, loc.Longitude).Scan)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *locationRepo) GetByID(ctx context.Context, id int64) (*Location, error) {
+	const q = `SELECT ` + locationColumns + ` FROM locations WHERE id = $1`
+	defer observeDB(ctx, "locations.get_by_id")()
+	loc, err := scanLocation(r.pool.QueryRowContext(ctx, q, id).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &loc, nil
+}
+
+func (r *locationRepo) ListByUser(ctx context.Context, userID int64) ([]Location, error) {
+	const q = `SELECT ` + locationColumns + ` FROM locations WHERE user_id = $1 ORDER BY name`
+	defer observeDB(ctx, "locations.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locs []Location
+	for rows.Next() {
+		loc, err := scanLocation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, rows.Err()
+}
+
+// Update replaces a place's details. It returns ErrNotFound unless the
+// user owns the place, and ErrConflict for a name already in use.
+func (r *locationRepo) Update(ctx context.Context, loc Location) (*Location, error) {
+	const q = `UPDATE locations SET name = $3, address = $4, latitude = $5, longitude = $6, updated_at = NOW()
+WHERE id = $1 AND user_id = $2 RETURNING ` + locationColumns
+	defer observeDB(ctx, "locations.update")()
+	updated, err := scanLocation(r.pool.QueryRowContext(ctx, q, loc.ID, loc.UserID, loc.Name, loc.Address, loc.Latitude, loc.Longitude).Scan)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete removes a place. Events it was written into keep their location.
+func (r *locationRepo) Delete(ctx context.Context, userID, id int64) error {
+	const q = `DELETE FROM locations WHERE id = $1 AND user_id = $2`
+	defer observeDB(ctx, "locations.delete")()
+	_, err := r.pool.ExecContext(ctx, q, id, userID)
+	return err
+}
+
+func (r *locationRepo) History(ctx context.Context, userID int64, query string, limit int) ([]string, error) {
+	const q = `
+SELECT e.location
+FROM events e
+JOIN calendars c ON c.id = e.calendar_id
+WHERE c.user_id = $1 AND e.location <> '' AND e.location ILIKE $2
+GROUP BY e.location
+ORDER BY MAX(e.last_modified) DESC, e.location
+LIMIT $3`
+	defer observeDB(ctx, "locations.history")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, "%"+likeEscape(query)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func scanLocation(scan rowScanner) (Location, error) {
+	var loc Location
+	var lat, lon sql.NullFloat64
+	if err := scan(&loc.ID, &loc.UserID, &loc.Name, &loc.Address, &lat, &lon, &loc.CreatedAt, &loc.UpdatedAt); err != nil {
+		return loc, err
+	}
+	if lat.Valid && lon.Valid {
+		loc.Latitude, loc.Longitude = &lat.Float64, &lon.Float64
+	}
+	return loc, nil
+}
+
+// eventLinkRepo implements EventLinkRepository.
+type eventLinkRepo struct {
+	pool dbPool
+}
+
+const eventLinkColumns = `id, user_id, calendar_id, uid, token, expires_at, access_count, last_accessed_at, created_at`
+
+func (r *eventLinkRepo) Create(ctx context.Context, link EventLink) (*EventLink, error) {
+	const q = `
+INSERT INTO event_links (user_id, calendar_id, uid, token, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING ` + eventLinkColumns
+	defer observeDB(ctx, "event_links.create")()
+	created, err := scanEventLink(r.pool.QueryRowContext(ctx, q, link.UserID, link.CalendarID, link.UID, link.Token, link.ExpiresAt).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *eventLinkRepo) GetByToken(ctx context.Context, token string) (*EventLink, error) {
+	const q = `SELECT ` + eventLinkColumns + ` FROM event_links WHERE token = $1 AND expires_at > NOW()`
+	defer observeDB(ctx, "event_links.get_by_token")()
+	link, err := scanEventLink(r.pool.QueryRowContext(ctx, q, token).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *eventLinkRepo) ListForEvent(ctx context.Context, userID, calendarID int64, uid string) ([]EventLink, error) {
+	const q = `SELECT ` + eventLinkColumns + ` FROM event_links
+WHERE user_id = $1 AND calendar_id = $2 AND uid = $3 AND expires_at > NOW()
+ORDER BY created_at DESC, id DESC`
+	defer observeDB(ctx, "event_links.list_for_event")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, calendarID, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []EventLink
+	for rows.Next() {
+		link, err := scanEventLink(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *eventLinkRepo) Delete(ctx context.Context, userID, id int64) error {
+	const q = `DELETE FROM event_links WHERE id = $1 AND user_id = $2`
+	defer observeDB(ctx, "event_links.delete")()
+	res, err := r.pool.ExecContext(ctx, q, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *eventLinkRepo) RecordAccess(ctx context.Context, id int64) error {
+	const q = `UPDATE event_links SET access_count = access_count + 1, last_accessed_at = NOW() WHERE id = $1`
+	defer observeDB(ctx, "event_links.record_access")()
+	_, err := r.pool.ExecContext(ctx, q, id)
+	return err
+}
+
+func (r *eventLinkRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const q = `DELETE FROM event_links WHERE expires_at <= NOW()`
+	defer observeDB(ctx, "event_links.delete_expired")()
+	res, err := r.pool.ExecContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanEventLink(scan rowScanner) (EventLink, error) {
+	var link EventLink
+	var lastAccessed sql.NullTime
+	if err := scan(&link.ID, &link.UserID, &link.CalendarID, &link.UID, &link.Token, &link.ExpiresAt, &link.AccessCount, &lastAccessed, &link.CreatedAt); err != nil {
+		return link, err
+	}
+	if lastAccessed.Valid {
+		link.LastAccessedAt = &lastAccessed.Time
+	}
+	return link, nil
+}
+
+// collectionTombstoneRepo implements CollectionTombstoneRepository.
+type collectionTombstoneRepo struct {
+	pool dbPool
+}
+
+const collectionTombstoneColumns = `change_txid::text::bigint, change_seq, collection_type, collection_id, user_id, owner_user_id, slug, deleted_at`
+
+func (r *collectionTombstoneRepo) ListSince(ctx context.Context, userID int64, collectionType string, since time.Time) ([]CollectionTombstone, error) {
+	const q = `SELECT ` + collectionTombstoneColumns + ` FROM (
+	SELECT DISTINCT ON (collection_id) * FROM collection_tombstones
+	WHERE user_id = $1 AND collection_type = $2 AND deleted_at > $3
+	ORDER BY collection_id, deleted_at DESC, id DESC
+) t
+ORDER BY deleted_at, collection_id`
+	defer observeDB(ctx, "collection_tombstones.list_since")()
+	return r.list(ctx, q, userID, collectionType, since)
+}
+
+func (r *collectionTombstoneRepo) LatestDeletedAt(ctx context.Context, userID int64, collectionType string) (time.Time, error) {
+	const q = `SELECT MAX(deleted_at) FROM collection_tombstones WHERE user_id = $1 AND collection_type = $2`
+	defer observeDB(ctx, "collection_tombstones.latest_deleted_at")()
+	var latest sql.NullTime
+	if err := r.pool.QueryRowContext(ctx, q, userID, collectionType).Scan(&latest); err != nil {
+		return time.Time{}, err
+	}
+	return latest.Time, nil
+}
+
+func (r *collectionTombstoneRepo) ListAfter(ctx context.Context, userID int64, after ChangeCursor, limit int) ([]CollectionTombstone, error) {
+	const q = `SELECT ` + collectionTombstoneColumns + ` FROM collection_tombstones
+WHERE user_id = $1 AND (change_txid, change_seq) > ($2::text::xid8, $3) AND change_txid < ` + changeHorizon + `
+ORDER BY change_txid, change_seq
+LIMIT $4`
+	defer observeDB(ctx, "collection_tombstones.list_after")()
+	return r.list(ctx, q, userID, after.TxID, after.Seq, limit)
+}
+
+func (r *collectionTombstoneRepo) list(ctx context.Context, q string, args ...any) ([]CollectionTombstone, error) {
+	rows, err := r.pool.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tombstones []CollectionTombstone
+	for rows.Next() {
+		var t CollectionTombstone
+		var slug sql.NullString
+		if err := rows.Scan(&t.Cursor.TxID, &t.Cursor.Seq, &t.CollectionType, &t.CollectionID, &t.UserID, &t.OwnerUserID, &slug, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		if slug.Valid {
+			t.Slug = &slug.String
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+// collectionMaintenanceRepo implements CollectionMaintenanceRepository.
+type collectionMaintenanceRepo struct {
+	pool dbPool
+}
+
+const collectionMaintenanceColumns = `collection_type, collection_id, reason, started_by, started_at, expires_at`
+
+func (r *collectionMaintenanceRepo) Start(ctx context.Context, m CollectionMaintenance) (*CollectionMaintenance, error) {
+	const q = `
+INSERT INTO collection_maintenance (collection_type, collection_id, reason, started_by, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (collection_type, collection_id) DO UPDATE
+SET reason = EXCLUDED.reason, started_by = EXCLUDED.started_by, expires_at = EXCLUDED.expires_at,
+    started_at = CASE WHEN collection_maintenance.expires_at > NOW() THEN collection_maintenance.started_at ELSE NOW() END
+RETURNING ` + collectionMaintenanceColumns
+	defer observeDB(ctx, "collection_maintenance.start")()
+	var saved CollectionMaintenance
+	if err := scanCollectionMaintenance(r.pool.QueryRowContext(ctx, q, m.CollectionType, m.CollectionID, m.Reason, m.StartedBy, m.ExpiresAt).Scan, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *collectionMaintenanceRepo) Get(ctx context.Context, collectionType string, collectionID int64) (*CollectionMaintenance, error) {
+	const q = `SELECT ` + collectionMaintenanceColumns + ` FROM collection_maintenance
+WHERE collection_type = $1 AND collection_id = $2 AND expires_at > NOW()`
+	defer observeDB(ctx, "collection_maintenance.get")()
+	var m CollectionMaintenance
+	err := scanCollectionMaintenance(r.pool.QueryRowContext(ctx, q, collectionType, collectionID).Scan, &m)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *collectionMaintenanceRepo) ListActive(ctx context.Context) ([]CollectionMaintenance, error) {
+	const q = `SELECT ` + collectionMaintenanceColumns + ` FROM collection_maintenance
+WHERE expires_at > NOW()
+ORDER BY started_at, collection_type, collection_id`
+	defer observeDB(ctx, "collection_maintenance.list_active")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []CollectionMaintenance
+	for rows.Next() {
+		var m CollectionMaintenance
+		if err := scanCollectionMaintenance(rows.Scan, &m); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (r *collectionMaintenanceRepo) End(ctx context.Context, collectionType string, collectionID int64) (bool, error) {
+	// An expired row is removed too, but was not running.
+	const q = `DELETE FROM collection_maintenance WHERE collection_type = $1 AND collection_id = $2
+RETURNING expires_at > NOW()`
+	defer observeDB(ctx, "collection_maintenance.end")()
+	var running bool
+	err := r.pool.QueryRowContext(ctx, q, collectionType, collectionID).Scan(&running)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return running, err
+}
+
+func scanCollectionMaintenance(scan func(...any) error, m *CollectionMaintenance) error {
+	return scan(&m.CollectionType, &m.CollectionID, &m.Reason, &m.StartedBy, &m.StartedAt, &m.ExpiresAt)
+}
+
+// calendarTransferRepo implements CalendarTransferRepository.
+type calendarTransferRepo struct {
+	pool dbPool
+}
+
+const calendarTransferColumns = `id, calendar_id, from_user_id, from_email, to_user_id, to_email, transferred_by, transferred_at`
+
+func (r *calendarTransferRepo) Transfer(ctx context.Context, calendarID, toUserID int64, transferredBy string) (*CalendarTransfer, error) {
+	defer observeDB(ctx, "calendar_transfers.transfer")()
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	const ownerQ = `SELECT c.user_id, c.slug FROM calendars c WHERE c.id = $1 FOR UPDATE`
+	var fromUserID int64
+	var slug sql.NullString
+	if err := tx.QueryRowContext(ctx, ownerQ, calendarID).Scan(&fromUserID, &slug); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if fromUserID == toUserID {
+		return nil, ErrConflict
+	}
+
+	// ctag and updated_at are left alone: the calendar's contents did not
+	// change, and sync tokens are built from updated_at.
+	const moveQ = `UPDATE calendars SET user_id = $2 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, moveQ, calendarID, toUserID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrConflict
+		}
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	// The old owner keeps no access, and the new one needs no grants.
+	resourcePath := "/dav/calendars/" + strconv.FormatInt(calendarID, 10)
+	const revokeQ = `DELETE FROM acl_entries WHERE principal_href = ANY($1) AND (resource_path = $2 OR resource_path LIKE $3)`
+	principals := []string{
+		"/dav/principals/" + strconv.FormatInt(fromUserID, 10) + "/",
+		"/dav/principals/" + strconv.FormatInt(toUserID, 10) + "/",
+	}
+	if _, err := tx.ExecContext(ctx, revokeQ, pq.Array(principals), resourcePath, resourcePath+"/%"); err != nil {
+		return nil, err
+	}
+
+	// The old owner's clients drop the calendar from their home set.
+	const tombstoneQ = `INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+SELECT 'calendar', $1, id, id, $3 FROM users WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, tombstoneQ, calendarID, fromUserID, slug); err != nil {
+		return nil, err
+	}
+
+	const recordQ = `INSERT INTO calendar_transfers (calendar_id, from_user_id, from_email, to_user_id, to_email, transferred_by)
+SELECT $1, $2, COALESCE((SELECT primary_email FROM users WHERE id = $2), ''), u.id, u.primary_email, $4
+FROM users u WHERE u.id = $3
+RETURNING ` + calendarTransferColumns
+	var t CalendarTransfer
+	if err := scanCalendarTransfer(tx.QueryRowContext(ctx, recordQ, calendarID, fromUserID, toUserID, transferredBy).Scan, &t); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *calendarTransferRepo) ListByCalendar(ctx context.Context, calendarID int64) ([]CalendarTransfer, error) {
+	const q = `SELECT ` + calendarTransferColumns + ` FROM calendar_transfers WHERE calendar_id = $1 ORDER BY transferred_at, id`
+	defer observeDB(ctx, "calendar_transfers.list_by_calendar")()
+	rows, err := r.pool.QueryContext(ctx, q, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []CalendarTransfer
+	for rows.Next() {
+		var t CalendarTransfer
+		if err := scanCalendarTransfer(rows.Scan, &t); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func scanCalendarTransfer(scan func(...any) error, t *CalendarTransfer) error {
+	return scan(&t.ID, &t.CalendarID, &t.FromUserID, &t.FromEmail, &t.ToUserID, &t.ToEmail, &t.TransferredBy, &t.TransferredAt)
+}
+
+// notificationProviderRepo implements NotificationProviderRepository.
+type notificationProviderRepo struct {
+	pool dbPool
+}
+
+const notificationProviderColumns = `id, user_id, kind, name, url, token, recipient, created_at`
+
+func (r *notificationProviderRepo) Create(ctx context.Context, p NotificationProvider) (*NotificationProvider, error) {
+	const q = `INSERT INTO notification_providers (user_id, kind, name, url, token, recipient)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING ` + notificationProviderColumns
+	defer observeDB(ctx, "notification_providers.create")()
+	var saved NotificationProvider
+	if err := scanNotificationProvider(r.pool.QueryRowContext(ctx, q, p.UserID, p.Kind, p.Name, p.URL, p.Token, p.Recipient).Scan, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *notificationProviderRepo) Get(ctx context.Context, userID, id int64) (*NotificationProvider, error) {
+	const q = `SELECT ` + notificationProviderColumns + ` FROM notification_providers WHERE id = $1 AND user_id = $2`
+	defer observeDB(ctx, "notification_providers.get")()
+	var p NotificationProvider
+	if err := scanNotificationProvider(r.pool.QueryRowContext(ctx, q, id, userID).Scan, &p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *notificationProviderRepo) ListByUser(ctx context.Context, userID int64) ([]NotificationProvider, error) {
+	const q = `SELECT ` + notificationProviderColumns + ` FROM notification_providers WHERE user_id = $1 ORDER BY id`
+	defer observeDB(ctx, "notification_providers.list_by_user")()
+	return r.list(ctx, q, userID)
+}
+
+func (r *notificationProviderRepo) ListAll(ctx context.Context) ([]NotificationProvider, error) {
+	const q = `SELECT ` + notificationProviderColumns + ` FROM notification_providers ORDER BY user_id, id`
+	defer observeDB(ctx, "notification_providers.list_all")()
+	return r.list(ctx, q)
+}
+
+func (r *notificationProviderRepo) list(ctx context.Context, q string, args ...any) ([]NotificationProvider, error) {
+	rows, err := r.pool.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []NotificationProvider
+	for rows.Next() {
+		var p NotificationProvider
+		if err := scanNotificationProvider(rows.Scan, &p); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+func (r *notificationProviderRepo) Delete(ctx context.Context, userID, id int64) (bool, error) {
+	const q = `DELETE FROM notification_providers WHERE id = $1 AND user_id = $2`
+	defer observeDB(ctx, "notification_providers.delete")()
+	res, err := r.pool.ExecContext(ctx, q, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanNotificationProvider(scan func(...any) error, p *NotificationProvider) error {
+	return scan(&p.ID, &p.UserID, &p.Kind, &p.Name, &p.URL, &p.Token, &p.Recipient, &p.CreatedAt)
+}
+
+// alarmDeliveryRepo implements AlarmDeliveryRepository.
+type alarmDeliveryRepo struct {
+	pool dbPool
+}
+
+const alarmDeliveryColumns = `id, provider_id, user_id, calendar_id, uid, alarm_id, fire_at, title, message, status, attempts, last_error, next_attempt_at, sent_at, created_at`
+
+func (r *alarmDeliveryRepo) Enqueue(ctx context.Context, d AlarmDelivery) (bool, error) {
+	const q = `INSERT INTO alarm_deliveries (provider_id, user_id, calendar_id, uid, alarm_id, fire_at, title, message, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (provider_id, calendar_id, uid, alarm_id, fire_at) DO NOTHING`
+	defer observeDB(ctx, "alarm_deliveries.enqueue")()
+	res, err := r.pool.ExecContext(ctx, q, d.ProviderID, d.UserID, d.CalendarID, d.UID, d.AlarmID, d.FireAt, d.Title, d.Message, d.NextAttemptAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *alarmDeliveryRepo) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]AlarmDelivery, error) {
+	const q = `
+UPDATE alarm_deliveries
+SET attempts = attempts + 1, next_attempt_at = $2
+WHERE id IN (
+    SELECT id FROM alarm_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= $1
+    ORDER BY next_attempt_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + alarmDeliveryColumns
+	defer observeDB(ctx, "alarm_deliveries.claim_due")()
+	return r.list(ctx, q, now, leaseUntil, limit)
+}
+
+func (r *alarmDeliveryRepo) MarkSent(ctx context.Context, id int64, sentAt time.Time) error {
+	const q = `UPDATE alarm_deliveries SET status = 'sent', sent_at = $2, last_error = '' WHERE id = $1`
+	defer observeDB(ctx, "alarm_deliveries.mark_sent")()
+	_, err := r.pool.ExecContext(ctx, q, id, sentAt)
+	return err
+}
+
+func (r *alarmDeliveryRepo) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	const q = `
+UPDATE alarm_deliveries
+SET status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+    last_error = $2, next_attempt_at = COALESCE($3, next_attempt_at)
+WHERE id = $1`
+	defer observeDB(ctx, "alarm_deliveries.mark_failed")()
+	_, err := r.pool.ExecContext(ctx, q, id, lastError, retryAt)
+	return err
+}
+
+func (r *alarmDeliveryRepo) ListByUser(ctx context.Context, userID int64, limit int) ([]AlarmDelivery, error) {
+	const q = `SELECT ` + alarmDeliveryColumns + ` FROM alarm_deliveries WHERE user_id = $1 ORDER BY fire_at DESC, id DESC LIMIT $2`
+	defer observeDB(ctx, "alarm_deliveries.list_by_user")()
+	return r.list(ctx, q, userID, limit)
+}
+
+func (r *alarmDeliveryRepo) list(ctx context.Context, q string, args ...any) ([]AlarmDelivery, error) {
+	rows, err := r.pool.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []AlarmDelivery
+	for rows.Next() {
+		var d AlarmDelivery
+		if err := scanAlarmDelivery(rows.Scan, &d); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (r *alarmDeliveryRepo) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM alarm_deliveries WHERE fire_at < $1`
+	defer observeDB(ctx, "alarm_deliveries.delete_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanAlarmDelivery(scan func(...any) error, d *AlarmDelivery) error {
+	var sent sql.NullTime
+	if err := scan(&d.ID, &d.ProviderID, &d.UserID, &d.CalendarID, &d.UID, &d.AlarmID, &d.FireAt, &d.Title, &d.Message,
+		&d.Status, &d.Attempts, &d.LastError, &d.NextAttemptAt, &sent, &d.CreatedAt); err != nil {
+		return err
+	}
+	if sent.Valid {
+		d.SentAt = &sent.Time
+	}
+	return nil
+}
+
+// quotaRepo implements StorageQuotaRepository.
+type quotaRepo struct {
+	pool dbPool
+}
+
+// quotaUsage returns the expression for the bytes of calendar and contact
+// data in the collections of the user the SQL expression userID names.
+func quotaUsage(userID string) string {
+	return `COALESCE((SELECT SUM(octet_length(` + eventBody + `)) FROM events JOIN calendars c ON c.id = events.calendar_id WHERE c.user_id = ` + userID + `), 0)
+    + COALESCE((SELECT SUM(octet_length(` + contactBody + `)) FROM contacts JOIN address_books b ON b.id = contacts.address_book_id WHERE b.user_id = ` + userID + `), 0)`
+}
+
+func (r *quotaRepo) Usage(ctx context.Context, userID int64) (int64, error) {
+	q := `SELECT ` + quotaUsage("$1")
+	defer observeDB(ctx, "quota_warnings.usage")()
+	var used int64
+	err := r.pool.QueryRowContext(ctx, q, userID).Scan(&used)
+	return used, err
+}
+
+func (r *quotaRepo) ListUsage(ctx context.Context) ([]StorageUsage, error) {
+	q := `SELECT u.id, ` + quotaUsage("u.id") + `, COALESCE(w.level, '')
+FROM users u LEFT JOIN quota_warnings w ON w.user_id = u.id
+WHERE NOT u.internal
+ORDER BY u.id`
+	defer observeDB(ctx, "quota_warnings.list_usage")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []StorageUsage
+	for rows.Next() {
+		var u StorageUsage
+		if err := rows.Scan(&u.UserID, &u.UsedBytes, &u.Warned); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+func (r *quotaRepo) SetWarned(ctx context.Context, userID int64, from, to string) (bool, error) {
+	var res sql.Result
+	var err error
+	defer observeDB(ctx, "quota_warnings.set_warned")()
+	switch {
+	case to == "":
+		res, err = r.pool.ExecContext(ctx, `DELETE FROM quota_warnings WHERE user_id = $1 AND level = $2`, userID, from)
+	case from == "":
+		res, err = r.pool.ExecContext(ctx, `INSERT INTO quota_warnings (user_id, level) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING`, userID, to)
+	default:
+		res, err = r.pool.ExecContext(ctx, `UPDATE quota_warnings SET level = $3, updated_at = NOW() WHERE user_id = $1 AND level = $2`, userID, from, to)
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// featureFlagRepo implements FeatureFlagRepository.
+type featureFlagRepo struct {
+	pool dbPool
+}
+
+const featureFlagColumns = `id, flag, user_id, group_id, enabled, created_at, updated_at`
+
+func (r *featureFlagRepo) List(ctx context.Context) ([]FeatureFlagOverride, error) {
+	const q = `SELECT ` + featureFlagColumns + ` FROM feature_flag_overrides ORDER BY flag, user_id NULLS LAST, group_id`
+	defer observeDB(ctx, "feature_flag_overrides.list")()
+	return r.query(ctx, q)
+}
+
+func (r *featureFlagRepo) ListForUser(ctx context.Context, userID int64, groupIDs []int64) ([]FeatureFlagOverride, error) {
+	const q = `SELECT ` + featureFlagColumns + ` FROM feature_flag_overrides WHERE user_id = $1 OR group_id = ANY($2) ORDER BY flag, id`
+	defer observeDB(ctx, "feature_flag_overrides.list_for_user")()
+	return r.query(ctx, q, userID, pq.Array(groupIDs))
+}
+
+func (r *featureFlagRepo) AnyEnabled(ctx context.Context, flag string) (bool, error) {
+	const q = `SELECT EXISTS (SELECT 1 FROM feature_flag_overrides WHERE flag = $1 AND enabled)`
+	defer observeDB(ctx, "feature_flag_overrides.any_enabled")()
+	var enabled bool
+	err := r.pool.QueryRowContext(ctx, q, flag).Scan(&enabled)
+	return enabled, err
+}
+
+func (r *featureFlagRepo) Set(ctx context.Context, override FeatureFlagOverride) (*FeatureFlagOverride, error) {
+	// The unique indexes are partial, so each target needs its own
+	// conflict clause.
+	q := `
+INSERT INTO feature_flag_overrides (flag, user_id, group_id, enabled) VALUES ($1, $2, $3, $4)
+ON CONFLICT (flag, user_id) WHERE user_id IS NOT NULL DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+RETURNING ` + featureFlagColumns
+	if override.GroupID != nil {
+		q = `
+INSERT INTO feature_flag_overrides (flag, user_id, group_id, enabled) VALUES ($1, $2, $3, $4)
+ON CONFLICT (flag, group_id) WHERE group_id IS NOT NULL DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+RETURNING ` + featureFlagColumns
+	}
+	defer observeDB(ctx, "feature_flag_overrides.set")()
+	return scanFeatureFlagOverride(r.pool.QueryRowContext(ctx, q, override.Flag, override.UserID, override.GroupID, override.Enabled).Scan)
+}
+
+func (r *featureFlagRepo) Delete(ctx context.Context, flag string, userID, groupID int64) (bool, error) {
+	q := `DELETE FROM feature_flag_overrides WHERE flag = $1 AND user_id = $2`
+	target := userID
+	if groupID != 0 {
+		q = `DELETE FROM feature_flag_overrides WHERE flag = $1 AND group_id = $2`
+		target = groupID
+	}
+	defer observeDB(ctx, "feature_flag_overrides.delete")()
+	res, err := r.pool.ExecContext(ctx, q, flag, target)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *featureFlagRepo) query(ctx context.Context, q string, args ...any) ([]FeatureFlagOverride, error) {
+	rows, err := r.pool.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []FeatureFlagOverride
+	for rows.Next() {
+		o, err := scanFeatureFlagOverride(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *o)
+	}
+	return list, rows.Err()
+}
+
+func scanFeatureFlagOverride(scan func(...any) error) (*FeatureFlagOverride, error) {
+	var o FeatureFlagOverride
+	var userID, groupID sql.NullInt64
+	if err := scan(&o.ID, &o.Flag, &userID, &groupID, &o.Enabled, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		o.UserID = &userID.Int64
+	}
+	if groupID.Valid {
+		o.GroupID = &groupID.Int64
+	}
+	return &o, nil
+}
+
+// focusRepo implements FocusRepository.
+type focusRepo struct {
+	pool dbPool
+}
+
+const focusSettingsColumns = `user_id, min_minutes, day_start_minute, day_end_minute, planned_until, created_at, updated_at`
+
+const focusBlockColumns = `id, user_id, calendar_id, uid, start_at, end_at, created_at`
+
+func (r *focusRepo) GetSettings(ctx context.Context, userID int64) (*FocusSettings, error) {
+	const q = `SELECT ` + focusSettingsColumns + ` FROM focus_settings WHERE user_id = $1`
+	defer observeDB(ctx, "focus_settings.get")()
+	var settings FocusSettings
+	err := scanFocusSettings(r.pool.QueryRowContext(ctx, q, userID).Scan, &settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *focusRepo) UpsertSettings(ctx context.Context, settings FocusSettings) (*FocusSettings, error) {
+	const q = `
+INSERT INTO focus_settings (user_id, min_minutes, day_start_minute, day_end_minute)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE
+SET min_minutes = EXCLUDED.min_minutes, day_start_minute = EXCLUDED.day_start_minute,
+    day_end_minute = EXCLUDED.day_end_minute, planned_until = NULL, updated_at = NOW()
+RETURNING ` + focusSettingsColumns
+	defer observeDB(ctx, "focus_settings.upsert")()
+	var saved FocusSettings
+	if err := scanFocusSettings(r.pool.QueryRowContext(ctx, q, settings.UserID, settings.MinMinutes, settings.DayStartMinute, settings.DayEndMinute).Scan, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *focusRepo) DeleteSettings(ctx context.Context, userID int64) error {
+	const q = `DELETE FROM focus_settings WHERE user_id = $1`
+	defer observeDB(ctx, "focus_settings.delete")()
+	_, err := r.pool.ExecContext(ctx, q, userID)
+	return err
+}
+
+func (r *focusRepo) ListSettings(ctx context.Context) ([]FocusSettings, error) {
+	const q = `SELECT ` + focusSettingsColumns + ` FROM focus_settings ORDER BY user_id`
+	defer observeDB(ctx, "focus_settings.list")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all []FocusSettings
+	for rows.Next() {
+		var settings FocusSettings
+		if err := scanFocusSettings(rows.Scan, &settings); err != nil {
+			return nil, err
+		}
+		all = append(all, settings)
+	}
+	return all, rows.Err()
+}
+
+func (r *focusRepo) ClaimPlanning(ctx context.Context, userID int64, from *time.Time, until time.Time) (bool, error) {
+	const q = `UPDATE focus_settings SET planned_until = $3 WHERE user_id = $1 AND planned_until IS NOT DISTINCT FROM $2`
+	defer observeDB(ctx, "focus_settings.claim_planning")()
+	res, err := r.pool.ExecContext(ctx, q, userID, from, until)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *focusRepo) AddBlock(ctx context.Context, block FocusBlock) error {
+	const q = `INSERT INTO focus_blocks (user_id, calendar_id, uid, start_at, end_at) VALUES ($1, $2, $3, $4, $5)`
+	defer observeDB(ctx, "focus_blocks.add")()
+	_, err := r.pool.ExecContext(ctx, q, block.UserID, block.CalendarID, block.UID, block.Start, block.End)
+	return err
+}
+
+func (r *focusRepo) ListBlocks(ctx context.Context, userID int64, after time.Time) ([]FocusBlock, error) {
+	const q = `SELECT ` + focusBlockColumns + ` FROM focus_blocks WHERE user_id = $1 AND end_at > $2 ORDER BY start_at, id`
+	defer observeDB(ctx, "focus_blocks.list")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var blocks []FocusBlock
+	for rows.Next() {
+		var b FocusBlock
+		if err := rows.Scan(&b.ID, &b.UserID, &b.CalendarID, &b.UID, &b.Start, &b.End, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, rows.Err()
+}
+
+func (r *focusRepo) DeleteBlock(ctx context.Context, userID, id int64) error {
+	const q = `DELETE FROM focus_blocks WHERE id = $1 AND user_id = $2`
+	defer observeDB(ctx, "focus_blocks.delete")()
+	_, err := r.pool.ExecContext(ctx, q, id, userID)
+	return err
+}
+
+func scanFocusSettings(scan func(...any) error, s *FocusSettings) error {
+	var planned sql.NullTime
+	if err := scan(&s.UserID, &s.MinMinutes, &s.DayStartMinute, &s.DayEndMinute, &planned, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return err
+	}
+	if planned.Valid {
+		s.PlannedUntil = &planned.Time
+	}
+	return nil
+}
+
+// digestRepo implements DigestRepository.
+type digestRepo struct {
+	pool dbPool
+}
+
+const digestColumns = `user_id, frequency, send_minute, weekday, working_days_only, last_sent_at, created_at, updated_at`
+
+func (r *digestRepo) GetByUser(ctx context.Context, userID int64) (*DigestSettings, error) {
+	const q = `SELECT ` + digestColumns + ` FROM digest_settings WHERE user_id = $1`
+	defer observeDB(ctx, "digest_settings.get_by_user")()
+	settings, err := scanDigestSettings(r.pool.QueryRowContext(ctx, q, userID).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *digestRepo) Upsert(ctx context.Context, settings DigestSettings) (*DigestSettings, error) {
+	const q = `
+INSERT INTO digest_settings (user_id, frequency, send_minute, weekday, working_days_only)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET frequency = EXCLUDED.frequency, send_minute = EXCLUDED.send_minute, weekday = EXCLUDED.weekday,
+    working_days_only = EXCLUDED.working_days_only, updated_at = NOW()
+RETURNING ` + digestColumns
+	defer observeDB(ctx, "digest_settings.upsert")()
+	saved, err := scanDigestSettings(r.pool.QueryRowContext(ctx, q, settings.UserID, settings.Frequency, settings.SendMinute, int(settings.Weekday), settings.WorkingDaysOnly).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *digestRepo) Delete(ctx context.Context, userID int64) error {
+	const q = `DELETE FROM digest_settings WHERE user_id = $1`
+	defer observeDB(ctx, "digest_settings.delete")()
+	_, err := r.pool.ExecContext(ctx, q, userID)
+	return err
+}
+
+func (r *digestRepo) ListAll(ctx context.Context) ([]DigestSettings, error) {
+	const q = `SELECT ` + digestColumns + ` FROM digest_settings ORDER BY user_id`
+	defer observeDB(ctx, "digest_settings.list_all")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []DigestSettings
+	for rows.Next() {
+		settings, err := scanDigestSettings(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, settings)
+	}
+	return all, rows.Err()
+}
+
+func (r *digestRepo) MarkSent(ctx context.Context, userID int64, at time.Time) error {
+	const q = `UPDATE digest_settings SET last_sent_at = $2 WHERE user_id = $1`
+	defer observeDB(ctx, "digest_settings.mark_sent")()
+	_, err := r.pool.ExecContext(ctx, q, userID, at)
+	return err
+}
+
+func scanDigestSettings(scan rowScanner) (DigestSettings, error) {
+	var settings DigestSettings
+	var weekday int
+	var lastSent sql.NullTime
+	if err := scan(&settings.UserID, &settings.Frequency, &settings.SendMinute, &weekday, &settings.WorkingDaysOnly, &lastSent, &settings.CreatedAt, &settings.UpdatedAt); err != nil {
+		return settings, err
+	}
+	settings.Weekday = time.Weekday(weekday)
+	if lastSent.Valid {
+		settings.LastSentAt = &lastSent.Time
+	}
+	return settings, nil
+}
+
+// collectionNotificationRepo implements CollectionNotificationRepository.
+type collectionNotificationRepo struct {
+	pool dbPool
+}
+
+const collectionNotificationColumns = `user_id, collection_type, collection_id, cursor_txid, cursor_seq, last_notified_at, created_at`
+
+func (r *collectionNotificationRepo) ListByUser(ctx context.Context, userID int64) ([]CollectionNotification, error) {
+	const q = `SELECT ` + collectionNotificationColumns + ` FROM collection_notifications WHERE user_id = $1 ORDER BY collection_type, collection_id`
+	defer observeDB(ctx, "collection_notifications.list_by_user")()
+	return r.list(ctx, q, userID)
+}
+
+func (r *collectionNotificationRepo) ListAll(ctx context.Context) ([]CollectionNotification, error) {
+	const q = `SELECT ` + collectionNotificationColumns + ` FROM collection_notifications ORDER BY user_id, collection_type, collection_id`
+	defer observeDB(ctx, "collection_notifications.list_all")()
+	return r.list(ctx, q)
+}
+
+func (r *collectionNotificationRepo) list(ctx context.Context, q string, args ...any) ([]CollectionNotification, error) {
+	rows, err := r.pool.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []CollectionNotification
+	for rows.Next() {
+		n, err := scanCollectionNotification(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, n)
+	}
+	return all, rows.Err()
+}
+
+func (r *collectionNotificationRepo) Subscribe(ctx context.Context, n CollectionNotification) (*CollectionNotification, error) {
+	// The no-op update makes RETURNING yield an existing subscription too.
+	const q = `
+INSERT INTO collection_notifications (user_id, collection_type, collection_id, cursor_txid, cursor_seq)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, collection_type, collection_id) DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING ` + collectionNotificationColumns
+	defer observeDB(ctx, "collection_notifications.subscribe")()
+	saved, err := scanCollectionNotification(r.pool.QueryRowContext(ctx, q, n.UserID, n.CollectionType, n.CollectionID, n.Cursor.TxID, n.Cursor.Seq).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *collectionNotificationRepo) Unsubscribe(ctx context.Context, userID int64, collectionType string, collectionID int64) error {
+	const q = `DELETE FROM collection_notifications WHERE user_id = $1 AND collection_type = $2 AND collection_id = $3`
+	defer observeDB(ctx, "collection_notifications.unsubscribe")()
+	_, err := r.pool.ExecContext(ctx, q, userID, collectionType, collectionID)
+	return err
+}
+
+func (r *collectionNotificationRepo) Advance(ctx context.Context, userID int64, collectionType string, collectionID int64, cursor ChangeCursor, notifiedAt *time.Time) error {
+	const q = `
+UPDATE collection_notifications
+SET cursor_txid = $4, cursor_seq = $5, last_notified_at = COALESCE($6, last_notified_at)
+WHERE user_id = $1 AND collection_type = $2 AND collection_id = $3`
+	defer observeDB(ctx, "collection_notifications.advance")()
+	_, err := r.pool.ExecContext(ctx, q, userID, collectionType, collectionID, cursor.TxID, cursor.Seq, notifiedAt)
+	return err
+}
+
+func scanCollectionNotification(scan rowScanner) (CollectionNotification, error) {
+	var n CollectionNotification
+	var notified sql.NullTime
+	if err := scan(&n.UserID, &n.CollectionType, &n.CollectionID, &n.Cursor.TxID, &n.Cursor.Seq, &notified, &n.CreatedAt); err != nil {
+		return n, err
+	}
+	if notified.Valid {
+		n.LastNotifiedAt = &notified.Time
+	}
+	return n, nil
+}
+
+// pushSubscriptionRepo implements PushSubscriptionRepository.
+type pushSubscriptionRepo struct {
+	pool dbPool
+}
+
+const pushSubscriptionColumns = `id, user_id, collection_type, collection_id, token, push_resource, public_key, auth_secret, cursor_txid, cursor_seq, expires_at, last_pushed_at, created_at`
+
+func (r *pushSubscriptionRepo) Register(ctx context.Context, sub PushSubscription) (*PushSubscription, bool, error) {
+	// xmax is zero only for a row this statement inserted.
+	const q = `
+INSERT INTO push_subscriptions (user_id, collection_type, collection_id, token, push_resource, public_key, auth_secret, cursor_txid, cursor_seq, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (collection_type, collection_id, push_resource) DO UPDATE
+SET user_id = EXCLUDED.user_id, public_key = EXCLUDED.public_key, auth_secret = EXCLUDED.auth_secret, expires_at = EXCLUDED.expires_at
+RETURNING ` + pushSubscriptionColumns + `, xmax = 0`
+	defer observeDB(ctx, "push_subscriptions.register")()
+	var created bool
+	saved, err := scanPushSubscription(r.pool.QueryRowContext(ctx, q, sub.UserID, sub.CollectionType, sub.CollectionID, sub.Token, sub.PushResource,
+		sub.PublicKey, sub.AuthSecret, sub.Cursor.TxID, sub.Cursor.Seq, sub.ExpiresAt).Scan, &created)
+	if err != nil {
+		return nil, false, err
+	}
+	return &saved, created, nil
+}
+
+func (r *pushSubscriptionRepo) GetByToken(ctx context.Context, token string) (*PushSubscription, error) {
+	const q = `SELECT ` + pushSubscriptionColumns + ` FROM push_subscriptions WHERE token = $1`
+	defer observeDB(ctx, "push_subscriptions.get_by_token")()
+	sub, err := scanPushSubscription(r.pool.QueryRowContext(ctx, q, token).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *pushSubscriptionRepo) ListActive(ctx context.Context) ([]PushSubscription, error) {
+	const q = `SELECT ` + pushSubscriptionColumns + ` FROM push_subscriptions WHERE expires_at > NOW() ORDER BY user_id, id`
+	defer observeDB(ctx, "push_subscriptions.list_active")()
+	rows, err := r.pool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []PushSubscription
+	for rows.Next() {
+		sub, err := scanPushSubscription(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, sub)
+	}
+	return all, rows.Err()
+}
+
+func (r *pushSubscriptionRepo) Advance(ctx context.Context, id int64, from, to ChangeCursor, pushedAt *time.Time) (bool, error) {
+	const q = `
+UPDATE push_subscriptions
+SET cursor_txid = $4, cursor_seq = $5, last_pushed_at = COALESCE($6, last_pushed_at)
+WHERE id = $1 AND cursor_txid = $2 AND cursor_seq = $3`
+	defer observeDB(ctx, "push_subscriptions.advance")()
+	res, err := r.pool.ExecContext(ctx, q, id, from.TxID, from.Seq, to.TxID, to.Seq, pushedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *pushSubscriptionRepo) Delete(ctx context.Context, id int64) error {
+	const q = `DELETE FROM push_subscriptions WHERE id = $1`
+	defer observeDB(ctx, "push_subscriptions.delete")()
+	_, err := r.pool.ExecContext(ctx, q, id)
+	return err
+}
+
+func (r *pushSubscriptionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const q = `DELETE FROM push_subscriptions WHERE expires_at <= NOW()`
+	defer observeDB(ctx, "push_subscriptions.delete_expired")()
+	res, err := r.pool.ExecContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *pushSubscriptionRepo) VAPIDKey(ctx context.Context, candidate string) (string, error) {
+	// The insert is invisible to the second SELECT, so whichever replica
+	// stores its key first, every caller reads that one.
+	const q = `
+WITH stored AS (
+    INSERT INTO application (key, value) VALUES ('vapid_private_key', $1)
+    ON CONFLICT (key) DO NOTHING
+    RETURNING value
+)
+SELECT value FROM stored
+UNION ALL
+SELECT value FROM application WHERE key = 'vapid_private_key'
+LIMIT 1`
+	defer observeDB(ctx, "push_subscriptions.vapid_key")()
+	var key string
+	err := r.pool.QueryRowContext(ctx, q, candidate).Scan(&key)
+	return key, err
+}
+
+// scanPushSubscription scans a row of pushSubscriptionColumns followed by
+// extra.
+func scanPushSubscription(scan rowScanner, extra ...any) (PushSubscription, error) {
+	var sub PushSubscription
+	var pushed sql.NullTime
+	dest := []any{&sub.ID, &sub.UserID, &sub.CollectionType, &sub.CollectionID, &sub.Token, &sub.PushResource, &sub.PublicKey, &sub.AuthSecret,
+		&sub.Cursor.TxID, &sub.Cursor.Seq, &sub.ExpiresAt, &pushed, &sub.CreatedAt}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return sub, err
+	}
+	if pushed.Valid {
+		sub.LastPushedAt = &pushed.Time
+	}
+	return sub, nil
+}
+
+// idempotencyKeyRepo implements IdempotencyKeyRepository.
+type idempotencyKeyRepo struct {
+	pool dbPool
+}
+
+const idempotencyKeyColumns = `id, scope, key, fingerprint, status, headers, body, expires_at, created_at`
+
+func (r *idempotencyKeyRepo) Claim(ctx context.Context, key IdempotencyKey, staleBefore time.Time) (*IdempotencyKey, bool, error) {
+	// The conflicting row is only taken over, and so only returned, when it
+	// has expired or its request was abandoned.
+	const claim = `
+INSERT INTO idempotency_keys (scope, key, fingerprint, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (scope, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL, expires_at = EXCLUDED.expires_at, created_at = NOW()
+WHERE idempotency_keys.expires_at <= NOW() OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < $5)
+RETURNING ` + idempotencyKeyColumns
+	const existing = `SELECT ` + idempotencyKeyColumns + ` FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	defer observeDB(ctx, "idempotency_keys.claim")()
+	saved, err := scanIdempotencyKey(r.pool.QueryRowContext(ctx, claim, key.Scope, key.Key, key.Fingerprint, key.ExpiresAt, staleBefore).Scan)
+	if err == nil {
+		return &saved, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+	saved, err = scanIdempotencyKey(r.pool.QueryRowContext(ctx, existing, key.Scope, key.Key).Scan)
+	if err != nil {
+		return nil, false, err
+	}
+	return &saved, false, nil
+}
+
+func (r *idempotencyKeyRepo) Complete(ctx context.Context, id int64, status int, headers map[string][]string, body []byte) error {
+	const q = `UPDATE idempotency_keys SET status = $2, headers = $3, body = $4 WHERE id = $1`
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	defer observeDB(ctx, "idempotency_keys.complete")()
+	_, err = r.pool.ExecContext(ctx, q, id, status, string(encoded), body)
+	return err
+}
+
+func (r *idempotencyKeyRepo) Release(ctx context.Context, id int64) error {
+	const q = `DELETE FROM idempotency_keys WHERE id = $1`
+	defer observeDB(ctx, "idempotency_keys.release")()
+	_, err := r.pool.ExecContext(ctx, q, id)
+	return err
+}
+
+func (r *idempotencyKeyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	const q = `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`
+	defer observeDB(ctx, "idempotency_keys.delete_expired")()
+	res, err := r.pool.ExecContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanIdempotencyKey(scan rowScanner) (IdempotencyKey, error) {
+	var key IdempotencyKey
+	var status sql.NullInt64
+	var headers sql.NullString
+	if err := scan(&key.ID, &key.Scope, &key.Key, &key.Fingerprint, &status, &headers, &key.Body, &key.ExpiresAt, &key.CreatedAt); err != nil {
+		return key, err
+	}
+	key.Status = int(status.Int64)
+	if headers.Valid {
+		if err := json.Unmarshal([]byte(headers.String), &key.Headers); err != nil {
+			return key, err
+		}
+	}
+	return key, nil
+}
+
+// usageStatsRepo implements UsageStatsRepository.
+type usageStatsRepo struct {
+	pool dbPool
+}
+
+const usageStatsColumns = `id, collected_at, users, active_users, calendars, address_books, events, contacts, client_families`
+
+func (r *usageStatsRepo) Measure(ctx context.Context, activeSince time.Time) (*UsageSnapshot, error) {
+	const q = `
+SELECT
+    (SELECT COUNT(*) FROM users WHERE NOT internal),
+    (SELECT COUNT(*) FROM users u WHERE NOT u.internal AND (u.last_login_at >= $1
+        OR EXISTS (SELECT 1 FROM collection_syncs s WHERE s.user_id = u.id AND s.last_synced_at >= $1))),
+    (SELECT COUNT(*) FROM calendars WHERE user_id NOT IN (SELECT id FROM users WHERE internal)),
+    (SELECT COUNT(*) FROM address_books WHERE user_id NOT IN (SELECT id FROM users WHERE internal)),
+    (SELECT COUNT(*) FROM events WHERE calendar_id NOT IN (
+        SELECT c.id FROM calendars c JOIN users u ON u.id = c.user_id WHERE u.internal)),
+    (SELECT COUNT(*) FROM contacts WHERE address_book_id NOT IN (
+        SELECT b.id FROM address_books b JOIN users u ON u.id = b.user_id WHERE u.internal))`
+	defer observeDB(ctx, "usage_stats.measure")()
+	var snapshot UsageSnapshot
+	if err := r.pool.QueryRowContext(ctx, q, activeSince).Scan(&snapshot.Users, &snapshot.ActiveUsers, &snapshot.Calendars, &snapshot.AddressBooks, &snapshot.Events, &snapshot.Contacts); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (r *usageStatsRepo) DeviceUserAgents(ctx context.Context, since time.Time) (map[string]int64, error) {
+	const q = `
+SELECT user_agent, COUNT(DISTINCT (user_id, device_key))
+FROM collection_syncs
+WHERE last_synced_at >= $1 AND user_id NOT IN (SELECT id FROM users WHERE internal)
+GROUP BY user_agent`
+	defer observeDB(ctx, "usage_stats.device_user_agents")()
+	rows, err := r.pool.QueryContext(ctx, q, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	agents := map[string]int64{}
+	for rows.Next() {
+		var agent string
+		var devices int64
+		if err := rows.Scan(&agent, &devices); err != nil {
+			return nil, err
+		}
+		agents[agent] = devices
+	}
+	return agents, rows.Err()
+}
+
+func (r *usageStatsRepo) Insert(ctx context.Context, snapshot UsageSnapshot) (*UsageSnapshot, error) {
+	const q = `
+INSERT INTO usage_stats (collected_at, users, active_users, calendars, address_books, events, contacts, client_families)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING ` + usageStatsColumns
+	families, err := json.Marshal(snapshot.ClientFamilies)
+	if err != nil {
+		return nil, err
+	}
+	defer observeDB(ctx, "usage_stats.insert")()
+	saved, err := scanUsageSnapshot(r.pool.QueryRowContext(ctx, q, snapshot.CollectedAt, snapshot.Users, snapshot.ActiveUsers, snapshot.Calendars, snapshot.AddressBooks, snapshot.Events, snapshot.Contacts, families).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *usageStatsRepo) List(ctx context.Context, limit int) ([]UsageSnapshot, error) {
+	const q = `SELECT ` + usageStatsColumns + ` FROM usage_stats ORDER BY collected_at DESC, id DESC LIMIT $1`
+	defer observeDB(ctx, "usage_stats.list")()
+	rows, err := r.pool.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snapshots []UsageSnapshot
+	for rows.Next() {
+		snapshot, err := scanUsageSnapshot(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func (r *usageStatsRepo) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM usage_stats WHERE collected_at < $1`
+	defer observeDB(ctx, "usage_stats.delete_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanUsageSnapshot(scan rowScanner) (UsageSnapshot, error) {
+	var snapshot UsageSnapshot
+	var families []byte
+	if err := scan(&snapshot.ID, &snapshot.CollectedAt, &snapshot.Users, &snapshot.ActiveUsers, &snapshot.Calendars, &snapshot.AddressBooks, &snapshot.Events, &snapshot.Contacts, &families); err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(families, &snapshot.ClientFamilies); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// schedulingResourceRepo implements SchedulingResourceRepository.
+type schedulingResourceRepo struct {
+	pool dbPool
+}
+
+const schedulingResourceColumns = `calendar_id, email, kind, policy, priority_organizers, created_at, updated_at`
+
+func (r *schedulingResourceRepo) GetByCalendar(ctx context.Context, calendarID int64) (*SchedulingResource, error) {
+	const q = `SELECT ` + schedulingResourceColumns + ` FROM scheduling_resources WHERE calendar_id=$1`
+	defer observeDB(ctx, "scheduling_resources.get_by_calendar")()
+	res, err := scanSchedulingResource(r.pool.QueryRowContext(ctx, q, calendarID).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return res, err
+}
+
+func (r *schedulingResourceRepo) ListByEmails(ctx context.Context, emails []string) ([]SchedulingResource, error) {
+	lowered := make([]string, 0, len(emails))
+	for _, email := range emails {
+		lowered = append(lowered, strings.ToLower(email))
+	}
+	const q = `SELECT ` + schedulingResourceColumns + ` FROM scheduling_resources WHERE email = ANY($1) ORDER BY calendar_id`
+	defer observeDB(ctx, "scheduling_resources.list_by_emails")()
+	rows, err := r.pool.QueryContext(ctx, q, pq.Array(lowered))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []SchedulingResource
+	for rows.Next() {
+		res, err := scanSchedulingResource(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *res)
+	}
+	return result, rows.Err()
+}
+
+func (r *schedulingResourceRepo) Upsert(ctx context.Context, resource SchedulingResource) (*SchedulingResource, error) {
+	const q = `
+INSERT INTO scheduling_resources (calendar_id, email, kind, policy, priority_organizers)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (calendar_id) DO UPDATE
+SET email = EXCLUDED.email, kind = EXCLUDED.kind, policy = EXCLUDED.policy,
+    priority_organizers = EXCLUDED.priority_organizers, updated_at = NOW()
+RETURNING ` + schedulingResourceColumns
+	defer observeDB(ctx, "scheduling_resources.upsert")()
+	res, err := scanSchedulingResource(r.pool.QueryRowContext(ctx, q, resource.CalendarID, strings.ToLower(resource.Email), resource.Kind, resource.Policy, pq.Array(resource.PriorityOrganizers)).Scan)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "scheduling_resources_email_key" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *schedulingResourceRepo) Delete(ctx context.Context, calendarID int64) error {
+	const q = `DELETE FROM scheduling_resources WHERE calendar_id=$1`
+	defer observeDB(ctx, "scheduling_resources.delete")()
+	_, err := r.pool.ExecContext(ctx, q, calendarID)
+	return err
+}
+
+func (r *schedulingResourceRepo) Reserve(ctx context.Context, calendarID int64, decide func(bookings []Event) (ResourceReservation, error)) error {
+	defer observeDB(ctx, "scheduling_resources.reserve")()
+
+	tx, err := r.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "resource:"+strconv.FormatInt(calendarID, 10)); err != nil {
+		return err
+	}
+
+	const listQ = `SELECT id, calendar_id, uid, resource_name, ` + eventBody + `, etag, summary, description, location, dtstart, dtend, all_day, last_modified FROM events WHERE calendar_id=$1 ORDER BY id`
+	rows, err := tx.QueryContext(ctx, listQ, calendarID)
+	if err != nil {
+		return err
+	}
+	var bookings []Event
+	for rows.Next() {
+		ev, err := scanEvent(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		bookings = append(bookings, ev)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reservation, err := decide(bookings)
+	if err != nil {
+		return err
+	}
+	for _, uid := range reservation.Release {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM events WHERE calendar_id=$1 AND uid=$2`, calendarID, uid); err != nil {
+			return err
+		}
+	}
+	if reservation.Book != nil {
+		book := *reservation.Book
+		book.CalendarID = calendarID
+		if _, err := upsertEvent(ctx, tx, book); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scanSchedulingResource(scan rowScanner) (*SchedulingResource, error) {
+	var res SchedulingResource
+	var organizers pq.StringArray
+	if err := scan(&res.CalendarID, &res.Email, &res.Kind, &res.Policy, &organizers, &res.CreatedAt, &res.UpdatedAt); err != nil {
+		return nil, err
+	}
+	res.PriorityOrganizers = []string(organizers)
+	return &res, nil
+}
+
+// bookingPageRepo implements BookingPageRepository.
+type bookingPageRepo struct {
+	pool dbPool
+}
+
+const bookingPageColumns = `id, user_id, calendar_id, slug, title, description, duration_minutes, buffer_minutes, notice_minutes, window_days, day_start_minute, day_end_minute, weekdays, timezone, created_at`
+
+func (r *bookingPageRepo) Create(ctx context.Context, page BookingPage) (*BookingPage, error) {
+	const q = `
+INSERT INTO booking_pages (user_id, calendar_id, slug, title, description, duration_minutes, buffer_minutes, notice_minutes, window_days, day_start_minute, day_end_minute, weekdays, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING ` + bookingPageColumns
+	defer observeDB(ctx, "booking_pages.create")()
+	row := r.pool.QueryRowContext(ctx, q, page.UserID, page.CalendarID, page.Slug, page.Title, page.Description, page.DurationMinutes,
+		page.BufferMinutes, page.NoticeMinutes, page.WindowDays, page.DayStartMinute, page.DayEndMinute, page.Weekdays, page.Timezone)
+	created, err := scanBookingPage(row.Scan)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "booking_pages_slug_key" {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *bookingPageRepo) GetBySlug(ctx context.Context, slug string) (*BookingPage, error) {
+	const q = `SELECT ` + bookingPageColumns + ` FROM booking_pages WHERE slug=$1`
+	defer observeDB(ctx, "booking_pages.get_by_slug")()
+	page, err := scanBookingPage(r.pool.QueryRowContext(ctx, q, slug).Scan)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &t, nil
-}
-
-func (r *appPasswordRepo) Revoke(ctx context.Context, id int64) error {
-	const q = `UPDATE app_passwords SET revoked_at = NOW() WHERE id=$1`
-	defer observeDB(ctx, "app_passwords.revoke")()
-	_, err := r.pool.ExecContext(ctx, q, id)
-	return err
-}
-
-func (r *appPasswordRepo) DeleteRevoked(ctx context.Context, id int64) error {
-	const q = `DELETE FROM app_passwords WHERE id=$1 AND revoked_at IS NOT NULL`
-	defer observeDB(ctx, "app_passwords.delete_revoked")()
-	_, err := r.pool.ExecContext(ctx, q, id)
-	return err
-}
-
-func (r *appPasswordRepo) TouchLastUsed(ctx context.Context, id int64) error {
-	const q = `UPDATE app_passwords SET last_used_at = NOW() WHERE id=$1`
-	defer observeDB(ctx, "app_passwords.touch_last_used")()
-	_, err := r.pool.ExecContext(ctx, q, id)
-	return err
-}
-
-// deletedResourceRepo implements DeletedResourceRepository.
-type deletedResourceRepo struct {
-	pool *sql.DB
+	return &page, nil
 }
 
-func (r *deletedResourceRepo) ListDeletedSince(ctx context.Context, resourceType string, collectionID int64, since time.Time) ([]DeletedResource, error) {
-	const q = `SELECT id, resource_type, collection_id, uid, resource_name, deleted_at FROM deleted_resources WHERE resource_type=$1 AND collection_id=$2 AND deleted_at > $3 ORDER BY deleted_at DESC`
-	defer observeDB(ctx, "deleted_resources.list_deleted_since")()
-	rows, err := r.pool.QueryContext(ctx, q, resourceType, collectionID, since)
+func (r *bookingPageRepo) ListByUser(ctx context.Context, userID int64) ([]BookingPage, error) {
+	const q = `SELECT ` + bookingPageColumns + ` FROM booking_pages WHERE user_id=$1 ORDER BY created_at`
+	defer observeDB(ctx, "booking_pages.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var result []DeletedResource
+	var result []BookingPage
 	for rows.Next() {
-		var d DeletedResource
-		if err := rows.Scan(&d.ID, &d.ResourceType, &d.CollectionID, &d.UID, &d.ResourceName, &d.DeletedAt); err != nil {
+		page, err := scanBookingPage(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
-		result = append(result, d)
+		result = append(result, page)
 	}
 	return result, rows.Err()
 }
 
-func (r *deletedResourceRepo) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
-	const q = `DELETE FROM deleted_resources WHERE resource_type=$1 AND collection_id=$2 AND uid=$3 AND resource_name=$4`
-	defer observeDB(ctx, "deleted_resources.delete_by_identity")()
-	_, err := r.pool.ExecContext(ctx, q, resourceType, collectionID, uid, resourceName)
-	return err
-}
-
-func (r *deletedResourceRepo) Cleanup(ctx context.Context, olderThan time.Duration) (int64, error) {
-	const q = `DELETE FROM deleted_resources WHERE deleted_at < $1`
-	defer observeDB(ctx, "deleted_resources.cleanup")()
-	cutoff := time.Now().Add(-olderThan)
-	res, err := r.pool.ExecContext(ctx, q, cutoff)
+func (r *bookingPageRepo) Delete(ctx context.Context, userID, id int64) error {
+	const q = `DELETE FROM booking_pages WHERE id=$1 AND user_id=$2`
+	defer observeDB(ctx, "booking_pages.delete")()
+	res, err := r.pool.ExecContext(ctx, q, id, userID)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	rows, err := res.RowsAffected()
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return rows, nil
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanBookingPage(scan rowScanner) (BookingPage, error) {
+	var p BookingPage
+	err := scan(&p.ID, &p.UserID, &p.CalendarID, &p.Slug, &p.Title, &p.Description, &p.DurationMinutes, &p.BufferMinutes,
+		&p.NoticeMinutes, &p.WindowDays, &p.DayStartMinute, &p.DayEndMinute, &p.Weekdays, &p.Timezone, &p.CreatedAt)
+	return p, err
 }
 
 // sessionRepo implements SessionRepository.
 type sessionRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func (r *sessionRepo) Create(ctx context.Context, session Session) (*Session, error) {
@@ -1635,10 +4681,10 @@ func (r *sessionRepo) ListByUser(ctx context.Context, userID int64) ([]Session,
 	return result, rows.Err()
 }
 
-func (r *sessionRepo) TouchLastSeen(ctx context.Context, id string) error {
-	const q = `UPDATE sessions SET last_seen_at = NOW() WHERE id=$1`
+func (r *sessionRepo) TouchLastSeen(ctx context.Context, id string, expiresAt time.Time) error {
+	const q = `UPDATE sessions SET last_seen_at = NOW(), expires_at = $2 WHERE id=$1`
 	defer observeDB(ctx, "sessions.touch_last_seen")()
-	_, err := r.pool.ExecContext(ctx, q, id)
+	_, err := r.pool.ExecContext(ctx, q, id, expiresAt)
 	return err
 }
 
@@ -1670,9 +4716,362 @@ func (r *sessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
 	return rows, nil
 }
 
+// authEventRepo implements AuthEventRepository.
+type authEventRepo struct {
+	pool dbPool
+}
+
+func (r *authEventRepo) Record(ctx context.Context, event AuthEvent) error {
+	const q = `
+INSERT INTO auth_events (user_id, app_password_id, outcome, new_address, ip_address, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+	defer observeDB(ctx, "auth_events.record")()
+	_, err := r.pool.ExecContext(ctx, q, event.UserID, event.AppPasswordID, event.Outcome, event.NewAddress, event.IPAddress, event.UserAgent)
+	return err
+}
+
+func (r *authEventRepo) ListByUser(ctx context.Context, userID int64, limit int) ([]AuthEvent, error) {
+	const q = `SELECT id, user_id, app_password_id, outcome, new_address, ip_address, user_agent, created_at FROM auth_events WHERE user_id=$1 ORDER BY created_at DESC, id DESC LIMIT $2`
+	defer observeDB(ctx, "auth_events.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AuthEvent
+	for rows.Next() {
+		var e AuthEvent
+		var appPasswordID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.UserID, &appPasswordID, &e.Outcome, &e.NewAddress, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if appPasswordID.Valid {
+			e.AppPasswordID = &appPasswordID.Int64
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+func (r *authEventRepo) PriorSignIns(ctx context.Context, userID int64, ip string) (bool, bool, error) {
+	const q = `SELECT COALESCE(bool_or(ip_address=$2), FALSE), COUNT(*) > 0 FROM auth_events WHERE user_id=$1 AND outcome='success'`
+	defer observeDB(ctx, "auth_events.prior_sign_ins")()
+	var fromIP, any bool
+	if err := r.pool.QueryRowContext(ctx, q, userID, ip).Scan(&fromIP, &any); err != nil {
+		return false, false, err
+	}
+	return fromIP, any, nil
+}
+
+func (r *authEventRepo) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM auth_events WHERE created_at < $1`
+	defer observeDB(ctx, "auth_events.delete_older_than")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// impersonationRepo implements ImpersonationRepository.
+type impersonationRepo struct {
+	pool dbPool
+}
+
+const impersonationColumns = `id, admin_user_id, admin_email, user_id, scope, reason, token_hash, created_at, expires_at, ended_at`
+
+func scanImpersonation(scan func(dest ...any) error) (Impersonation, error) {
+	var imp Impersonation
+	var adminID sql.NullInt64
+	var endedAt sql.NullTime
+	if err := scan(&imp.ID, &adminID, &imp.AdminEmail, &imp.UserID, &imp.Scope, &imp.Reason, &imp.TokenHash, &imp.CreatedAt, &imp.ExpiresAt, &endedAt); err != nil {
+		return Impersonation{}, err
+	}
+	if adminID.Valid {
+		imp.AdminUserID = &adminID.Int64
+	}
+	if endedAt.Valid {
+		imp.EndedAt = &endedAt.Time
+	}
+	return imp, nil
+}
+
+func (r *impersonationRepo) Create(ctx context.Context, imp Impersonation) (*Impersonation, error) {
+	const q = `
+INSERT INTO impersonations (admin_user_id, admin_email, user_id, scope, reason, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING ` + impersonationColumns
+	defer observeDB(ctx, "impersonations.create")()
+	created, err := scanImpersonation(r.pool.QueryRowContext(ctx, q, imp.AdminUserID, imp.AdminEmail, imp.UserID, imp.Scope, imp.Reason, imp.TokenHash, imp.ExpiresAt).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *impersonationRepo) GetByTokenHash(ctx context.Context, tokenHash string) (*Impersonation, error) {
+	const q = `SELECT ` + impersonationColumns + ` FROM impersonations WHERE token_hash = $1`
+	defer observeDB(ctx, "impersonations.get_by_token_hash")()
+	imp, err := scanImpersonation(r.pool.QueryRowContext(ctx, q, tokenHash).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+func (r *impersonationRepo) GetByID(ctx context.Context, id int64) (*Impersonation, error) {
+	const q = `SELECT ` + impersonationColumns + ` FROM impersonations WHERE id = $1`
+	defer observeDB(ctx, "impersonations.get_by_id")()
+	imp, err := scanImpersonation(r.pool.QueryRowContext(ctx, q, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+func (r *impersonationRepo) End(ctx context.Context, id int64) (bool, error) {
+	const q = `UPDATE impersonations SET ended_at = NOW() WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()`
+	defer observeDB(ctx, "impersonations.end")()
+	res, err := r.pool.ExecContext(ctx, q, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *impersonationRepo) List(ctx context.Context, limit int) ([]Impersonation, error) {
+	const q = `SELECT ` + impersonationColumns + ` FROM impersonations ORDER BY created_at DESC, id DESC LIMIT $1`
+	defer observeDB(ctx, "impersonations.list")()
+	rows, err := r.pool.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Impersonation
+	for rows.Next() {
+		imp, err := scanImpersonation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, imp)
+	}
+	return result, rows.Err()
+}
+
+func (r *impersonationRepo) RecordRequest(ctx context.Context, req ImpersonationRequest) error {
+	const q = `
+INSERT INTO impersonation_requests (impersonation_id, method, path, status, ip_address, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+	defer observeDB(ctx, "impersonations.record_request")()
+	_, err := r.pool.ExecContext(ctx, q, req.ImpersonationID, req.Method, req.Path, req.Status, req.IPAddress, req.UserAgent)
+	return err
+}
+
+func (r *impersonationRepo) ListRequests(ctx context.Context, impersonationID int64, limit int) ([]ImpersonationRequest, error) {
+	const q = `SELECT id, impersonation_id, method, path, status, ip_address, user_agent, created_at FROM impersonation_requests WHERE impersonation_id = $1 ORDER BY created_at, id LIMIT $2`
+	defer observeDB(ctx, "impersonations.list_requests")()
+	rows, err := r.pool.QueryContext(ctx, q, impersonationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ImpersonationRequest
+	for rows.Next() {
+		var req ImpersonationRequest
+		if err := rows.Scan(&req.ID, &req.ImpersonationID, &req.Method, &req.Path, &req.Status, &req.IPAddress, &req.UserAgent, &req.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, req)
+	}
+	return result, rows.Err()
+}
+
+// jobRepo implements JobRepository.
+type jobRepo struct {
+	pool dbPool
+}
+
+const jobColumns = `id, user_id, kind, status, total, processed, item_errors, result, error, created_at, updated_at, finished_at`
+
+func (r *jobRepo) Create(ctx context.Context, job Job) (*Job, error) {
+	const q = `
+INSERT INTO jobs (id, user_id, kind, status, total)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING ` + jobColumns
+	defer observeDB(ctx, "jobs.create")()
+	created, err := scanJob(r.pool.QueryRowContext(ctx, q, job.ID, job.UserID, job.Kind, job.Status, job.Total).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *jobRepo) Update(ctx context.Context, job Job) error {
+	const q = `UPDATE jobs SET status=$2, total=$3, processed=$4, item_errors=$5, result=$6, error=$7, finished_at=$8, updated_at=NOW() WHERE id=$1`
+	itemErrors := job.Errors
+	if itemErrors == nil {
+		itemErrors = []JobItemError{}
+	}
+	rawErrors, err := json.Marshal(itemErrors)
+	if err != nil {
+		return err
+	}
+	var result []byte
+	if len(job.Result) > 0 {
+		result = job.Result
+	}
+	defer observeDB(ctx, "jobs.update")()
+	_, err = r.pool.ExecContext(ctx, q, job.ID, job.Status, job.Total, job.Processed, rawErrors, result, job.Error, job.FinishedAt)
+	return err
+}
+
+func (r *jobRepo) GetByID(ctx context.Context, id string) (*Job, error) {
+	const q = `SELECT ` + jobColumns + ` FROM jobs WHERE id=$1`
+	defer observeDB(ctx, "jobs.get_by_id")()
+	job, err := scanJob(r.pool.QueryRowContext(ctx, q, id).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *jobRepo) ListByUser(ctx context.Context, userID int64, limit int) ([]Job, error) {
+	const q = `SELECT ` + jobColumns + ` FROM jobs WHERE user_id=$1 ORDER BY created_at DESC, id LIMIT $2`
+	defer observeDB(ctx, "jobs.list_by_user")()
+	rows, err := r.pool.QueryContext(ctx, q, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Job
+	for rows.Next() {
+		job, err := scanJob(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, job)
+	}
+	return result, rows.Err()
+}
+
+func (r *jobRepo) MarkInterrupted(ctx context.Context) (int64, error) {
+	const q = `UPDATE jobs SET status='interrupted', finished_at=NOW(), updated_at=NOW() WHERE status='running'`
+	defer observeDB(ctx, "jobs.mark_interrupted")()
+	res, err := r.pool.ExecContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *jobRepo) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM jobs WHERE finished_at < $1`
+	defer observeDB(ctx, "jobs.delete_finished_before")()
+	res, err := r.pool.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanJob(scan rowScanner) (Job, error) {
+	var job Job
+	var rawErrors, result []byte
+	var finishedAt sql.NullTime
+	if err := scan(&job.ID, &job.UserID, &job.Kind, &job.Status, &job.Total, &job.Processed, &rawErrors, &result, &job.Error, &job.CreatedAt, &job.UpdatedAt, &finishedAt); err != nil {
+		return Job{}, err
+	}
+	if len(rawErrors) > 0 {
+		if err := json.Unmarshal(rawErrors, &job.Errors); err != nil {
+			return Job{}, err
+		}
+	}
+	if len(result) > 0 {
+		job.Result = json.RawMessage(result)
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
+
+// jobJournalRepo implements JobJournalRepository.
+type jobJournalRepo struct {
+	pool dbPool
+}
+
+func (r *jobJournalRepo) Append(ctx context.Context, jobID string, entries []JournalEntry) error {
+	const q = `
+INSERT INTO job_journal (job_id, seq, collection_id, payload)
+SELECT $1, seq, collection_id, payload FROM unnest($2::int[], $3::bigint[], $4::text[]) AS e(seq, collection_id, payload)`
+	seqs := make([]int64, len(entries))
+	collections := make([]int64, len(entries))
+	payloads := make([]string, len(entries))
+	for i, e := range entries {
+		seqs[i], collections[i], payloads[i] = int64(e.Seq), e.CollectionID, e.Payload
+	}
+	defer observeDB(ctx, "job_journal.append")()
+	_, err := r.pool.ExecContext(ctx, q, jobID, pq.Array(seqs), pq.Array(collections), pq.Array(payloads))
+	return err
+}
+
+func (r *jobJournalRepo) ListPending(ctx context.Context, jobID string) ([]JournalEntry, error) {
+	const q = `SELECT job_id, seq, collection_id, payload, applied_at FROM job_journal WHERE job_id=$1 AND applied_at IS NULL ORDER BY seq`
+	defer observeDB(ctx, "job_journal.list_pending")()
+	rows, err := r.pool.QueryContext(ctx, q, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []JournalEntry
+	for rows.Next() {
+		var e JournalEntry
+		var applied sql.NullTime
+		if err := rows.Scan(&e.JobID, &e.Seq, &e.CollectionID, &e.Payload, &applied); err != nil {
+			return nil, err
+		}
+		if applied.Valid {
+			e.AppliedAt = &applied.Time
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+func (r *jobJournalRepo) MarkApplied(ctx context.Context, jobID string, seq int) error {
+	const q = `UPDATE job_journal SET applied_at=NOW() WHERE job_id=$1 AND seq=$2`
+	defer observeDB(ctx, "job_journal.mark_applied")()
+	_, err := r.pool.ExecContext(ctx, q, jobID, seq)
+	return err
+}
+
+func (r *jobJournalRepo) Discard(ctx context.Context, jobID string) error {
+	const q = `DELETE FROM job_journal WHERE job_id=$1`
+	defer observeDB(ctx, "job_journal.discard")()
+	_, err := r.pool.ExecContext(ctx, q, jobID)
+	return err
+}
+
 // lockRepo implements LockRepository.
 type lockRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func validateLockDepth(depth string) error {
@@ -1963,7 +5362,7 @@ func (r *lockRepo) Refresh(ctx context.Context, token string, newTimeout int, ne
 
 // aclRepo implements ACLRepository.
 type aclRepo struct {
-	pool *sql.DB
+	pool dbPool
 }
 
 func (r *aclRepo) SetACL(ctx context.Context, resourcePath string, entries []ACLEntry) error {
@@ -2403,12 +5802,16 @@ func scanAppPassword(scan rowScanner) (AppPassword, error) {
 	var expiresAt sql.NullTime
 	var revokedAt sql.NullTime
 	var lastUsedAt sql.NullTime
-	if err := scan(&t.ID, &t.UserID, &t.Label, &t.TokenHash, &t.CreatedAt, &expiresAt, &revokedAt, &lastUsedAt); err != nil {
+	var lastUsedUserAgent sql.NullString
+	var lastUsedIP sql.NullString
+	if err := scan(&t.ID, &t.UserID, &t.Label, &t.TokenHash, &t.CreatedAt, &expiresAt, &revokedAt, &lastUsedAt, &lastUsedUserAgent, &lastUsedIP, &t.APIRole); err != nil {
 		return AppPassword{}, err
 	}
 	t.ExpiresAt = nullableTime(expiresAt)
 	t.RevokedAt = nullableTime(revokedAt)
 	t.LastUsedAt = nullableTime(lastUsedAt)
+	t.LastUsedUserAgent = nullableString(lastUsedUserAgent)
+	t.LastUsedIP = nullableString(lastUsedIP)
 	return t, nil
 }
 
@@ -2605,6 +6008,38 @@ func parseVCardFields(vcard string) (*string, *string, *time.Time) {
 	return displayName, primaryEmail, birthday
 }
 
+// parseVCardLookupKeys returns the distinct lookup keys of every EMAIL and
+// TEL property of a vCard. Both are empty rather than nil so they can be
+// stored in NOT NULL array columns.
+func parseVCardLookupKeys(vcard string) ([]string, []string) {
+	emails, phones := []string{}, []string{}
+	seen := map[string]bool{}
+	for _, line := range unfoldVCardLines(vcard) {
+		colonIdx := strings.Index(line, ":")
+		if colonIdx == -1 {
+			continue
+		}
+		key, _, _ := strings.Cut(line[:colonIdx], ";")
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			key = key[dot+1:]
+		}
+		value := unescapeVCardValue(line[colonIdx+1:])
+		switch strings.ToUpper(key) {
+		case "EMAIL":
+			if k := util.EmailLookupKey(value); k != "" && !seen["e"+k] {
+				seen["e"+k] = true
+				emails = append(emails, k)
+			}
+		case "TEL":
+			if k := util.PhoneLookupKey(value); k != "" && !seen["t"+k] {
+				seen["t"+k] = true
+				phones = append(phones, k)
+			}
+		}
+	}
+	return emails, phones
+}
+
 // parseVCardBirthday parses birthday from various vCard formats.
 func parseVCardBirthday(value string) *time.Time {
 	value = strings.TrimSpace(value)
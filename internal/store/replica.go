@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+)
+
+// dbPool is the part of *sql.DB the repositories use.
+type dbPool interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PingContext(ctx context.Context) error
+}
+
+type replicaReadsKey struct{}
+
+// replicaReads marks a request whose plain reads may be served by the
+// replica. Once the request writes, its later reads go to the primary so it
+// always sees its own changes.
+type replicaReads struct {
+	wrote atomic.Bool
+}
+
+// WithReplicaReads returns a context whose SELECT queries may be answered by
+// the read replica, if one is configured. Use it for read-mostly requests
+// that tolerate a little replication lag.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, &replicaReads{})
+}
+
+// WithPrimaryReads returns a context whose queries all go to the primary,
+// undoing WithReplicaReads.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, (*replicaReads)(nil))
+}
+
+// routedPool sends the reads of contexts marked with WithReplicaReads to a
+// replica and everything else to the primary.
+type routedPool struct {
+	*sql.DB
+	replica *sql.DB
+}
+
+// useReplica reports whether query may run on the replica for ctx. Only
+// plain SELECTs qualify; locking reads and anything that writes stay on the
+// primary and send the rest of the request there too.
+func (p *routedPool) useReplica(ctx context.Context, query string) bool {
+	reads, _ := ctx.Value(replicaReadsKey{}).(*replicaReads)
+	if reads == nil || reads.wrote.Load() {
+		return false
+	}
+	if isReadQuery(query) {
+		return true
+	}
+	reads.wrote.Store(true)
+	return false
+}
+
+// isReadQuery reports whether query is a plain SELECT that neither writes nor
+// locks rows.
+func isReadQuery(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(q, "SELECT") && !strings.Contains(q, " FOR UPDATE") && !strings.Contains(q, " FOR SHARE")
+}
+
+func (p *routedPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if p.useReplica(ctx, query) {
+		return p.replica.QueryContext(ctx, query, args...)
+	}
+	return p.DB.QueryContext(ctx, query, args...)
+}
+
+func (p *routedPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if p.useReplica(ctx, query) {
+		return p.replica.QueryRowContext(ctx, query, args...)
+	}
+	return p.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (p *routedPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	p.markWrite(ctx)
+	return p.DB.ExecContext(ctx, query, args...)
+}
+
+func (p *routedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	p.markWrite(ctx)
+	return p.DB.BeginTx(ctx, opts)
+}
+
+func (p *routedPool) markWrite(ctx context.Context) {
+	if reads, _ := ctx.Value(replicaReadsKey{}).(*replicaReads); reads != nil {
+		reads.wrote.Store(true)
+	}
+}
+
+// ReadsFromReplica reports whether reads made with ctx may be served by a
+// replica, so callers can guard against replication lag.
+func (s *Store) ReadsFromReplica(ctx context.Context) bool {
+	if s == nil {
+		return false
+	}
+	if !s.replicated {
+		return false
+	}
+	reads, _ := ctx.Value(replicaReadsKey{}).(*replicaReads)
+	return reads != nil && !reads.wrote.Load()
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicaRoutingReadsYourWrites(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer replica.Close()
+
+	st := NewWithReplica(primary, replica)
+	get := regexp.QuoteMeta(`SELECT id, user_id, name, slug, description, timezone, color, ctag, created_at, updated_at FROM calendars WHERE id=$1`)
+	row := func(name string) *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows([]string{"id", "user_id", "name", "slug", "description", "timezone", "color", "ctag", "created_at", "updated_at"}).
+			AddRow(int64(1), int64(2), name, nil, nil, nil, nil, int64(3), now, now)
+	}
+
+	// Unmarked contexts read from the primary.
+	primaryMock.ExpectQuery(get).WithArgs(int64(1)).WillReturnRows(row("primary"))
+	if cal, err := st.Calendars.GetByID(context.Background(), 1); err != nil || cal.Name != "primary" {
+		t.Fatalf("GetByID() = %+v, %v; want the primary row", cal, err)
+	}
+
+	ctx := WithReplicaReads(context.Background())
+	if !st.ReadsFromReplica(ctx) {
+		t.Fatal("expected a marked context to read from the replica")
+	}
+	replicaMock.ExpectQuery(get).WithArgs(int64(1)).WillReturnRows(row("replica"))
+	if cal, err := st.Calendars.GetByID(ctx, 1); err != nil || cal.Name != "replica" {
+		t.Fatalf("GetByID() = %+v, %v; want the replica row", cal, err)
+	}
+
+	// After a write the request reads from the primary again.
+	primaryMock.ExpectExec(regexp.QuoteMeta(`UPDATE calendars SET name=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3`)).
+		WithArgs("renamed", int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := st.Calendars.Rename(ctx, 2, 1, "renamed"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	primaryMock.ExpectQuery(get).WithArgs(int64(1)).WillReturnRows(row("renamed"))
+	if cal, err := st.Calendars.GetByID(ctx, 1); err != nil || cal.Name != "renamed" {
+		t.Fatalf("GetByID() = %+v, %v; want the primary row after a write", cal, err)
+	}
+	if st.ReadsFromReplica(ctx) || st.ReadsFromReplica(WithPrimaryReads(WithReplicaReads(context.Background()))) {
+		t.Fatal("expected writes and WithPrimaryReads to pin reads to the primary")
+	}
+
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
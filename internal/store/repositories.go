@@ -10,8 +10,14 @@ type UserRepository interface {
 	UpsertOAuthUser(ctx context.Context, subject, email string) (*User, error)
 	GetByID(ctx context.Context, id int64) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
+	// ListActive returns the users who have signed in, leaving out
+	// internal accounts.
 	ListActive(ctx context.Context) ([]User, error)
+	// MarkInternal marks the user as an internal account; see User.Internal.
+	MarkInternal(ctx context.Context, userID int64) error
 	MarkOnboardingComplete(ctx context.Context, userID int64) error
+	SetSyncHideCancelled(ctx context.Context, userID int64, hide bool) error
+	SetPreferences(ctx context.Context, userID int64, locale, timezone string, weekStart *time.Weekday) error
 }
 
 // CalendarRepository handles calendars lifecycle.
@@ -19,6 +25,7 @@ type CalendarRepository interface {
 	GetByID(ctx context.Context, id int64) (*Calendar, error)
 	ListByUser(ctx context.Context, userID int64) ([]Calendar, error)
 	ListAccessible(ctx context.Context, userID int64) ([]CalendarAccess, error)
+	ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*PaginatedResult[CalendarAccess], error)
 	GetAccessible(ctx context.Context, calendarID, userID int64) (*CalendarAccess, error)
 	Create(ctx context.Context, cal Calendar) (*Calendar, error)
 	Update(ctx context.Context, userID, id int64, name string, description, timezone, color *string) error
@@ -31,8 +38,15 @@ type CalendarRepository interface {
 type EventRepository interface {
 	Upsert(ctx context.Context, event Event) (*Event, error)
 	DeleteByUID(ctx context.Context, calendarID int64, uid string) error
+	// DeleteByUIDs deletes the events in one transaction, tombstoning each
+	// but bumping the calendar's ctag once, and returns how many it removed.
+	DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error)
 	GetByUID(ctx context.Context, calendarID int64, uid string) (*Event, error)
 	GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*Event, error)
+	// OpenByResourceName is GetByResourceName for serving an event: RawICAL
+	// is left empty and the body is returned as a Body, which reads a large
+	// one in chunks. It returns nil, nil, nil when there is no such event.
+	OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*Event, *Body, error)
 	ListForCalendar(ctx context.Context, calendarID int64) ([]Event, error)
 	ListForCalendarFiltered(ctx context.Context, calendarID int64, f EventFilter) ([]Event, error)
 	ListForCalendarPaginated(ctx context.Context, calendarID int64, limit, offset int) (*PaginatedResult[Event], error)
@@ -42,6 +56,14 @@ type EventRepository interface {
 	MaxLastModified(ctx context.Context, calendarID int64) (time.Time, error)
 	MoveToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName string) error
 	CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*Event, error)
+	// MoveAllToCalendar moves the events with the given UIDs, or all of them
+	// when uids is nil, in one transaction, keeping UIDs and tombstoning each
+	// in the source. It returns ErrConflict when the destination already has
+	// one of the UIDs or resource names.
+	MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error)
+	// SplitToNewCalendar creates cal and moves the events with the given
+	// UIDs into it, in one transaction, as MoveAllToCalendar does.
+	SplitToNewCalendar(ctx context.Context, cal Calendar, fromCalendarID int64, uids []string) (*Calendar, int, error)
 }
 
 // AddressBookRepository manages address books.
@@ -71,6 +93,25 @@ type ContactRepository interface {
 	MoveToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName string) error
 	GetByResourceName(ctx context.Context, addressBookID int64, resourceName string) (*Contact, error)
 	CopyToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName, newETag string) (*Contact, error)
+	// FindByLookupKey returns up to limit contacts in the given address books
+	// with the email or phone lookup key (see util.EmailLookupKey and
+	// util.PhoneLookupKey).
+	FindByLookupKey(ctx context.Context, addressBookIDs []int64, email, phone string, limit int) ([]Contact, error)
+}
+
+// EmailAliasRepository stores the alias addresses users claim. Verification
+// tokens are kept only as hashes.
+type EmailAliasRepository interface {
+	ListByUser(ctx context.Context, userID int64) ([]EmailAlias, error)
+	// Create claims email for userID, or renews the token of an existing
+	// unverified claim. Verified creates a verified alias without a token,
+	// as the bootstrap file does.
+	Create(ctx context.Context, userID int64, email, tokenHash string, verified bool) (*EmailAlias, error)
+	// Verify marks the alias with tokenHash verified and returns it, or
+	// nil when no unverified alias of userID created since notBefore has it.
+	// It returns ErrConflict when another user has verified the address.
+	Verify(ctx context.Context, userID int64, tokenHash string, notBefore time.Time) (*EmailAlias, error)
+	Delete(ctx context.Context, userID, id int64) error
 }
 
 // AppPasswordRepository handles Basic Auth token storage.
@@ -81,7 +122,9 @@ type AppPasswordRepository interface {
 	GetByID(ctx context.Context, id int64) (*AppPassword, error)
 	Revoke(ctx context.Context, id int64) error
 	DeleteRevoked(ctx context.Context, id int64) error
-	TouchLastUsed(ctx context.Context, id int64) error
+	// TouchLastUsed records a successful authentication from the given
+	// client; empty values keep the previously recorded ones.
+	TouchLastUsed(ctx context.Context, id int64, userAgent, ipAddress string) error
 }
 
 // DeletedResourceRepository handles tombstone tracking for sync.
@@ -89,6 +132,392 @@ type DeletedResourceRepository interface {
 	ListDeletedSince(ctx context.Context, resourceType string, collectionID int64, since time.Time) ([]DeletedResource, error)
 	DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error
 	Cleanup(ctx context.Context, olderThan time.Duration) (int64, error)
+	// ListOrphaned returns tombstones whose collection no longer exists or
+	// whose resource name is held by a live resource again.
+	ListOrphaned(ctx context.Context) ([]DeletedResource, error)
+}
+
+// CanonicalFormRepository backfills the canonical forms of resources stored
+// before ETags were computed from them.
+type CanonicalFormRepository interface {
+	// BackfillEvents and BackfillContacts fill the canonical form of up to
+	// limit resources that lack one and replace their ETag with the
+	// canonical ETag, returning how many were updated.
+	BackfillEvents(ctx context.Context, limit int) (int, error)
+	BackfillContacts(ctx context.Context, limit int) (int, error)
+}
+
+// OrphanRepository finds events and contacts left behind by a calendar or
+// address book that was deleted without its cascade, such as by a manual
+// database edit.
+type OrphanRepository interface {
+	List(ctx context.Context) ([]OrphanedResource, error)
+	// Delete removes an orphaned resource. Resources whose collection
+	// exists are left alone.
+	Delete(ctx context.Context, resourceType string, collectionID int64, uid string) error
+}
+
+// HeldDeletionRepository tracks deletions quarantined for owner review.
+type HeldDeletionRepository interface {
+	Hold(ctx context.Context, held HeldDeletion) error
+	IsHeld(ctx context.Context, resourceType string, collectionID int64, uid string) (bool, error)
+	ListByOwner(ctx context.Context, ownerID int64) ([]HeldDeletion, error)
+	Remove(ctx context.Context, ownerID int64, ids []int64) error
+}
+
+// ChangeRepository reads the change feed across calendars and address books.
+type ChangeRepository interface {
+	// ListSince returns up to limit changes after cursor in the given
+	// collections, oldest first. Changes from transactions that may still
+	// commit are held back so a later page never goes back in time.
+	ListSince(ctx context.Context, calendarIDs, addressBookIDs []int64, after ChangeCursor, limit int) ([]Change, error)
+	// Latest returns the cursor that follows every change listed so far.
+	Latest(ctx context.Context) (ChangeCursor, error)
+}
+
+// CollectionSyncRepository tracks which devices sync each collection.
+type CollectionSyncRepository interface {
+	Record(ctx context.Context, sync CollectionSync) error
+	ListForCollection(ctx context.Context, userID int64, collectionType string, collectionID int64) ([]CollectionSync, error)
+	ListByUser(ctx context.Context, userID int64, since time.Time) ([]CollectionSync, error)
+	Usage(ctx context.Context, collectionType string, collectionID int64) (CollectionUsage, error)
+	// Checksum hashes the UID and ETag of every resource in a collection,
+	// so two replicas with the same checksum hold the same data.
+	Checksum(ctx context.Context, collectionType string, collectionID int64) (string, error)
+}
+
+// ClientRepairRepository counts the repairs made to data each user's
+// devices write.
+type ClientRepairRepository interface {
+	// Record counts one repair for the device, noting the resource.
+	Record(ctx context.Context, repair ClientRepair) error
+	// ListByUser returns the user's repairs, most recently seen first.
+	ListByUser(ctx context.Context, userID int64) ([]ClientRepair, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// BookingPageRepository manages public appointment booking pages.
+type BookingPageRepository interface {
+	Create(ctx context.Context, page BookingPage) (*BookingPage, error)
+	GetBySlug(ctx context.Context, slug string) (*BookingPage, error)
+	ListByUser(ctx context.Context, userID int64) ([]BookingPage, error)
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+// FreeBusyLinkRepository manages public free/busy links.
+type FreeBusyLinkRepository interface {
+	GetByUser(ctx context.Context, userID int64) (*FreeBusyLink, error)
+	GetByToken(ctx context.Context, token string) (*FreeBusyLink, error)
+	Upsert(ctx context.Context, link FreeBusyLink) (*FreeBusyLink, error)
+	Delete(ctx context.Context, userID int64) error
+}
+
+// TaskFeedLinkRepository manages task feed links.
+type TaskFeedLinkRepository interface {
+	GetByUser(ctx context.Context, userID int64) (*TaskFeedLink, error)
+	GetByToken(ctx context.Context, token string) (*TaskFeedLink, error)
+	Upsert(ctx context.Context, link TaskFeedLink) (*TaskFeedLink, error)
+	Delete(ctx context.Context, userID int64) error
+}
+
+// UserGroupRepository manages sharing groups and their members.
+type UserGroupRepository interface {
+	Create(ctx context.Context, ownerUserID int64, name string) (*UserGroup, error)
+	GetByID(ctx context.Context, id int64) (*UserGroup, error)
+	ListByOwner(ctx context.Context, ownerUserID int64) ([]UserGroup, error)
+	Delete(ctx context.Context, ownerUserID, id int64) error
+	AddMember(ctx context.Context, groupID, userID int64) error
+	RemoveMember(ctx context.Context, groupID, userID int64) error
+}
+
+// LocationRepository manages users' saved places.
+type LocationRepository interface {
+	Create(ctx context.Context, loc Location) (*Location, error)
+	GetByID(ctx context.Context, id int64) (*Location, error)
+	ListByUser(ctx context.Context, userID int64) ([]Location, error)
+	Update(ctx context.Context, loc Location) (*Location, error)
+	Delete(ctx context.Context, userID, id int64) error
+	// History returns the distinct LOCATION values of events in the user's
+	// calendars containing query, most recently used first.
+	History(ctx context.Context, userID int64, query string, limit int) ([]string, error)
+}
+
+// EventLinkRepository manages expiring links to single events.
+type EventLinkRepository interface {
+	Create(ctx context.Context, link EventLink) (*EventLink, error)
+	// GetByToken returns the link with token, or nil when there is none or
+	// it has expired.
+	GetByToken(ctx context.Context, token string) (*EventLink, error)
+	ListForEvent(ctx context.Context, userID, calendarID int64, uid string) ([]EventLink, error)
+	// Delete revokes one of the user's links, returning ErrNotFound when
+	// they have no such link.
+	Delete(ctx context.Context, userID, id int64) error
+	RecordAccess(ctx context.Context, id int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// CollectionTombstoneRepository lists the collections users have lost.
+type CollectionTombstoneRepository interface {
+	// ListSince returns the user's tombstones of collectionType recorded
+	// after since, newest per collection.
+	ListSince(ctx context.Context, userID int64, collectionType string, since time.Time) ([]CollectionTombstone, error)
+	// LatestDeletedAt returns when the user last lost a collection of
+	// collectionType, or the zero time.
+	LatestDeletedAt(ctx context.Context, userID int64, collectionType string) (time.Time, error)
+	// ListAfter returns up to limit of the user's tombstones after cursor in
+	// the change feed, oldest first.
+	ListAfter(ctx context.Context, userID int64, after ChangeCursor, limit int) ([]CollectionTombstone, error)
+}
+
+// CollectionMaintenanceRepository keeps the collections in maintenance.
+type CollectionMaintenanceRepository interface {
+	// Start puts a collection into maintenance, replacing the reason and
+	// expiry of maintenance already running on it.
+	Start(ctx context.Context, m CollectionMaintenance) (*CollectionMaintenance, error)
+	// Get returns the collection's maintenance, or nil when none is running.
+	Get(ctx context.Context, collectionType string, collectionID int64) (*CollectionMaintenance, error)
+	// ListActive returns the maintenance running on any collection, oldest
+	// first.
+	ListActive(ctx context.Context) ([]CollectionMaintenance, error)
+	// End ends a collection's maintenance and reports whether any was
+	// running.
+	End(ctx context.Context, collectionType string, collectionID int64) (bool, error)
+}
+
+// CalendarTransferRepository hands calendars to new owners and keeps the
+// record of it.
+type CalendarTransferRepository interface {
+	// Transfer makes toUserID the owner of a calendar in one transaction.
+	// The calendar keeps its ID, events, ctag and the other users' grants, so
+	// hrefs, UIDs and sync tokens stay valid for them; grants to the old and
+	// new owner are removed. It returns ErrNotFound for an unknown calendar
+	// and ErrConflict when toUserID already owns it or a calendar with the
+	// same slug.
+	Transfer(ctx context.Context, calendarID, toUserID int64, transferredBy string) (*CalendarTransfer, error)
+	// ListByCalendar returns a calendar's transfers, oldest first.
+	ListByCalendar(ctx context.Context, calendarID int64) ([]CalendarTransfer, error)
+}
+
+// UsageStatsRepository keeps the anonymous usage snapshots admins can opt
+// in to.
+type UsageStatsRepository interface {
+	// Measure counts users, collections and resources now. Active users
+	// signed in or synced since activeSince.
+	Measure(ctx context.Context, activeSince time.Time) (*UsageSnapshot, error)
+	// DeviceUserAgents returns how many devices synced since since with
+	// each User-Agent.
+	DeviceUserAgents(ctx context.Context, since time.Time) (map[string]int64, error)
+	Insert(ctx context.Context, snapshot UsageSnapshot) (*UsageSnapshot, error)
+	// List returns up to limit snapshots, newest first.
+	List(ctx context.Context, limit int) ([]UsageSnapshot, error)
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// FeatureFlagRepository keeps the per-user and per-group feature flag
+// overrides.
+type FeatureFlagRepository interface {
+	// List returns every override, by flag.
+	List(ctx context.Context) ([]FeatureFlagOverride, error)
+	// ListForUser returns the overrides for the user and for the groups
+	// given, those the user belongs to.
+	ListForUser(ctx context.Context, userID int64, groupIDs []int64) ([]FeatureFlagOverride, error)
+	// AnyEnabled reports whether the flag is turned on for any user or
+	// group.
+	AnyEnabled(ctx context.Context, flag string) (bool, error)
+	// Set creates or replaces the override of the flag for its user or
+	// group.
+	Set(ctx context.Context, override FeatureFlagOverride) (*FeatureFlagOverride, error)
+	// Delete removes the override of the flag for the user or the group,
+	// whichever is non-zero, reporting false when there was none.
+	Delete(ctx context.Context, flag string, userID, groupID int64) (bool, error)
+}
+
+// FocusRepository keeps users' focus time settings and the blocks planned
+// from them.
+type FocusRepository interface {
+	// GetSettings returns the user's settings, or nil when they have not
+	// opted in.
+	GetSettings(ctx context.Context, userID int64) (*FocusSettings, error)
+	// UpsertSettings saves the user's settings, to be planned afresh.
+	UpsertSettings(ctx context.Context, settings FocusSettings) (*FocusSettings, error)
+	DeleteSettings(ctx context.Context, userID int64) error
+	ListSettings(ctx context.Context) ([]FocusSettings, error)
+	// ClaimPlanning moves the user's PlannedUntil from from to until,
+	// reporting false when another replica has moved it first.
+	ClaimPlanning(ctx context.Context, userID int64, from *time.Time, until time.Time) (bool, error)
+	AddBlock(ctx context.Context, block FocusBlock) error
+	// ListBlocks returns the user's blocks ending after after, by start.
+	ListBlocks(ctx context.Context, userID int64, after time.Time) ([]FocusBlock, error)
+	DeleteBlock(ctx context.Context, userID, id int64) error
+}
+
+// DigestRepository manages agenda digest schedules.
+type DigestRepository interface {
+	GetByUser(ctx context.Context, userID int64) (*DigestSettings, error)
+	Upsert(ctx context.Context, settings DigestSettings) (*DigestSettings, error)
+	Delete(ctx context.Context, userID int64) error
+	// ListAll returns every schedule, for the sender to check which are due.
+	ListAll(ctx context.Context) ([]DigestSettings, error)
+	MarkSent(ctx context.Context, userID int64, at time.Time) error
+}
+
+// CollectionNotificationRepository manages per-collection change
+// notification subscriptions.
+type CollectionNotificationRepository interface {
+	ListByUser(ctx context.Context, userID int64) ([]CollectionNotification, error)
+	// ListAll returns every subscription, for the notifier to check.
+	ListAll(ctx context.Context) ([]CollectionNotification, error)
+	// Subscribe starts notifications about changes after the subscription's
+	// cursor. An existing subscription is left as it is.
+	Subscribe(ctx context.Context, n CollectionNotification) (*CollectionNotification, error)
+	Unsubscribe(ctx context.Context, userID int64, collectionType string, collectionID int64) error
+	// Advance moves the subscription's cursor, and records notifiedAt when
+	// it is not nil.
+	Advance(ctx context.Context, userID int64, collectionType string, collectionID int64, cursor ChangeCursor, notifiedAt *time.Time) error
+}
+
+// NotificationProviderRepository manages the providers users have their
+// alarms pushed to.
+type NotificationProviderRepository interface {
+	Create(ctx context.Context, p NotificationProvider) (*NotificationProvider, error)
+	// Get returns the user's provider with the ID, or nil.
+	Get(ctx context.Context, userID, id int64) (*NotificationProvider, error)
+	ListByUser(ctx context.Context, userID int64) ([]NotificationProvider, error)
+	// ListAll returns every provider, by user.
+	ListAll(ctx context.Context) ([]NotificationProvider, error)
+	// Delete removes the user's provider, reporting false when there is no
+	// such provider.
+	Delete(ctx context.Context, userID, id int64) (bool, error)
+}
+
+// StorageQuotaRepository measures the data users keep and remembers the
+// quota warnings they have been sent.
+type StorageQuotaRepository interface {
+	// Usage returns the bytes of calendar and contact data in the user's own
+	// calendars and address books.
+	Usage(ctx context.Context, userID int64) (int64, error)
+	// ListUsage returns every user's usage with the warning last sent.
+	ListUsage(ctx context.Context) ([]StorageUsage, error)
+	// SetWarned replaces the warning last sent, "" for none, only if it is
+	// still from, so that of several replicas only one sends each warning.
+	SetWarned(ctx context.Context, userID int64, from, to string) (bool, error)
+}
+
+// AlarmDeliveryRepository tracks the alarms sent to notification providers.
+type AlarmDeliveryRepository interface {
+	// Enqueue records a pending delivery due at its NextAttemptAt. It
+	// reports false when the provider already has one for the same alarm
+	// firing at the same time.
+	Enqueue(ctx context.Context, d AlarmDelivery) (bool, error)
+	// ClaimDue returns up to limit pending deliveries due by now, counting an
+	// attempt for each and putting off their next one until leaseUntil, so
+	// that no other replica sends them meanwhile.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]AlarmDelivery, error)
+	MarkSent(ctx context.Context, id int64, sentAt time.Time) error
+	// MarkFailed records a failed attempt. The delivery is tried again at
+	// retryAt, or given up on when retryAt is nil.
+	MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error
+	// ListByUser returns the user's most recent deliveries, newest first.
+	ListByUser(ctx context.Context, userID int64, limit int) ([]AlarmDelivery, error)
+	// DeleteBefore removes deliveries of alarms that fired before cutoff.
+	DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PushSubscriptionRepository manages WebDAV-Push subscriptions.
+type PushSubscriptionRepository interface {
+	// Register saves the subscription, or renews the one already held for
+	// its push resource on the collection, keeping that one's token and
+	// cursor. created reports whether it is new.
+	Register(ctx context.Context, sub PushSubscription) (saved *PushSubscription, created bool, err error)
+	GetByToken(ctx context.Context, token string) (*PushSubscription, error)
+	// ListActive returns the subscriptions that have not expired.
+	ListActive(ctx context.Context) ([]PushSubscription, error)
+	// Advance moves the subscription's cursor from from to to, recording
+	// pushedAt when it is not nil. It reports false when the cursor was no
+	// longer at from, so that only one replica pushes each change.
+	Advance(ctx context.Context, id int64, from, to ChangeCursor, pushedAt *time.Time) (bool, error)
+	Delete(ctx context.Context, id int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+	// VAPIDKey returns the server's stored VAPID signing key, storing
+	// candidate first when there is none yet.
+	VAPIDKey(ctx context.Context, candidate string) (string, error)
+}
+
+// IdempotencyKeyRepository stores the Idempotency-Keys of REST writes.
+type IdempotencyKeyRepository interface {
+	// Claim records the key for a request about to run and returns it with
+	// claimed set. When the key is already held it returns that record
+	// instead, unless the record has expired or its request started before
+	// staleBefore without finishing, in which case it is claimed afresh.
+	Claim(ctx context.Context, key IdempotencyKey, staleBefore time.Time) (saved *IdempotencyKey, claimed bool, err error)
+	// Complete stores the response of the claimed request.
+	Complete(ctx context.Context, id int64, status int, headers map[string][]string, body []byte) error
+	// Release forgets a claim, so that the request can be retried.
+	Release(ctx context.Context, id int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// RetentionRepository manages per-calendar retention policies and the
+// deletions they make.
+type RetentionRepository interface {
+	GetByCalendar(ctx context.Context, calendarID int64) (*RetentionPolicy, error)
+	Upsert(ctx context.Context, policy RetentionPolicy) (*RetentionPolicy, error)
+	Delete(ctx context.Context, calendarID int64) error
+	ListAll(ctx context.Context) ([]RetentionPolicy, error)
+	// ListEndedBefore returns up to limit events of the calendar with IDs
+	// above afterID whose end, or start when they have none, is before
+	// cutoff, by ID. Recurring events are listed by their first
+	// occurrence, so callers must check the rest.
+	ListEndedBefore(ctx context.Context, calendarID int64, cutoff time.Time, afterID int64, limit int) ([]Event, error)
+	// Expire deletes the events in one transaction, tombstoning and logging
+	// each, and marks the policy run. Events changed since they were read
+	// are kept. It returns how many it deleted.
+	Expire(ctx context.Context, calendarID int64, events []Event, cutoff time.Time) (int, error)
+	// ListDeletions returns the calendar's newest logged deletions first.
+	ListDeletions(ctx context.Context, calendarID int64, limit int) ([]RetentionDeletion, error)
+	DeleteDeletionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// EventRevisionRepository reads the event history that triggers record on
+// every write.
+type EventRevisionRepository interface {
+	// ListAsOf returns the revisions of the calendar's events that were
+	// current at at, by UID.
+	ListAsOf(ctx context.Context, calendarID int64, at time.Time) ([]EventRevision, error)
+	// HistorySince returns when recording began; earlier times are not
+	// covered.
+	HistorySince(ctx context.Context) (time.Time, error)
+	// DeleteClosedBefore removes revisions superseded before cutoff.
+	DeleteClosedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ContactRevisionRepository reads the contact history that triggers record
+// on every write.
+type ContactRevisionRepository interface {
+	// ListChangedSince returns up to limit changes made to contacts in the
+	// address books since since, newest first.
+	ListChangedSince(ctx context.Context, addressBookIDs []int64, since time.Time, limit int) ([]ContactChange, error)
+	// DeleteClosedBefore removes revisions superseded before cutoff.
+	DeleteClosedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ConferenceHookRepository manages per-calendar conferencing webhooks.
+type ConferenceHookRepository interface {
+	GetByCalendar(ctx context.Context, calendarID int64) (*ConferenceHook, error)
+	Upsert(ctx context.Context, hook ConferenceHook) (*ConferenceHook, error)
+	Delete(ctx context.Context, calendarID int64) error
+}
+
+// SchedulingResourceRepository manages bookable resource calendars.
+type SchedulingResourceRepository interface {
+	GetByCalendar(ctx context.Context, calendarID int64) (*SchedulingResource, error)
+	ListByEmails(ctx context.Context, emails []string) ([]SchedulingResource, error)
+	Upsert(ctx context.Context, resource SchedulingResource) (*SchedulingResource, error)
+	Delete(ctx context.Context, calendarID int64) error
+	// Reserve locks the resource calendar, passes decide its events, and
+	// applies the reservation decide returns in the same transaction, so
+	// concurrent requests for the resource see each other's bookings.
+	Reserve(ctx context.Context, calendarID int64, decide func(bookings []Event) (ResourceReservation, error)) error
 }
 
 // SessionRepository handles database-backed sessions.
@@ -96,12 +525,67 @@ type SessionRepository interface {
 	Create(ctx context.Context, session Session) (*Session, error)
 	GetByID(ctx context.Context, id string) (*Session, error)
 	ListByUser(ctx context.Context, userID int64) ([]Session, error)
-	TouchLastSeen(ctx context.Context, id string) error
+	// TouchLastSeen records activity on the session and moves its expiry to
+	// expiresAt, sliding it forward.
+	TouchLastSeen(ctx context.Context, id string, expiresAt time.Time) error
 	Delete(ctx context.Context, id string) error
 	DeleteByUser(ctx context.Context, userID int64) error
 	DeleteExpired(ctx context.Context) (int64, error)
 }
 
+// AuthEventRepository keeps the DAV sign-in history.
+type AuthEventRepository interface {
+	Record(ctx context.Context, event AuthEvent) error
+	// ListByUser returns the user's newest events first.
+	ListByUser(ctx context.Context, userID int64, limit int) ([]AuthEvent, error)
+	// PriorSignIns reports whether the user has signed in successfully from
+	// ip, and from any address at all.
+	PriorSignIns(ctx context.Context, userID int64, ip string) (fromIP, any bool, err error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ImpersonationRepository keeps admin impersonation grants and the requests
+// made with them.
+type ImpersonationRepository interface {
+	Create(ctx context.Context, imp Impersonation) (*Impersonation, error)
+	// GetByTokenHash returns the grant with the token, active or not, or nil.
+	GetByTokenHash(ctx context.Context, tokenHash string) (*Impersonation, error)
+	GetByID(ctx context.Context, id int64) (*Impersonation, error)
+	// End ends an active grant early and reports whether it was active.
+	End(ctx context.Context, id int64) (bool, error)
+	// List returns the newest grants first.
+	List(ctx context.Context, limit int) ([]Impersonation, error)
+	RecordRequest(ctx context.Context, req ImpersonationRequest) error
+	// ListRequests returns a grant's requests, oldest first.
+	ListRequests(ctx context.Context, impersonationID int64, limit int) ([]ImpersonationRequest, error)
+}
+
+// JobRepository keeps background jobs and their progress.
+type JobRepository interface {
+	Create(ctx context.Context, job Job) (*Job, error)
+	// Update saves the job's status, progress, errors and result.
+	Update(ctx context.Context, job Job) error
+	GetByID(ctx context.Context, id string) (*Job, error)
+	// ListByUser returns the user's newest jobs first.
+	ListByUser(ctx context.Context, userID int64, limit int) ([]Job, error)
+	// MarkInterrupted marks every running job interrupted, for use at
+	// startup.
+	MarkInterrupted(ctx context.Context) (int64, error)
+	DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// JobJournalRepository keeps the writes bulk jobs intend to make.
+type JobJournalRepository interface {
+	// Append records entries for the job in one statement, so either all
+	// of them are journaled or none are.
+	Append(ctx context.Context, jobID string, entries []JournalEntry) error
+	// ListPending returns the job's entries not yet applied, in order.
+	ListPending(ctx context.Context, jobID string) ([]JournalEntry, error)
+	MarkApplied(ctx context.Context, jobID string, seq int) error
+	// Discard removes the job's journal.
+	Discard(ctx context.Context, jobID string) error
+}
+
 // LockRepository handles WebDAV lock storage.
 type LockRepository interface {
 	Create(ctx context.Context, lock Lock) (*Lock, error)
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/metrics"
+)
+
+// ErrUnavailable reports that the database could not be reached. Callers
+// should answer 503 rather than treating the data as missing.
+var ErrUnavailable = errors.New("database unavailable")
+
+const (
+	// retryAttempts is how many times a call failing with a connection error
+	// is tried in total.
+	retryAttempts = 3
+	// retryBackoff is the wait before the first retry; it doubles after each.
+	retryBackoff = 100 * time.Millisecond
+	// breakerThreshold consecutive connection failures open the breaker.
+	breakerThreshold = 5
+	// breakerCooldown is how long the open breaker fails calls fast before
+	// letting calls through again to probe the database.
+	breakerCooldown = 10 * time.Second
+)
+
+// unavailableError wraps a connection error so it matches ErrUnavailable
+// while keeping the driver error for logs.
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string   { return e.err.Error() }
+func (e *unavailableError) Unwrap() []error { return []error{ErrUnavailable, e.err} }
+
+type availabilityKey struct{}
+
+type availability struct {
+	unavailable atomic.Bool
+}
+
+// WithAvailabilityTracking returns a context that records whether any query
+// made with it failed because the database was unreachable.
+func WithAvailabilityTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, availabilityKey{}, &availability{})
+}
+
+// Unavailable reports whether a query made with ctx, a context from
+// WithAvailabilityTracking, failed because the database was unreachable.
+func Unavailable(ctx context.Context) bool {
+	a, _ := ctx.Value(availabilityKey{}).(*availability)
+	return a != nil && a.unavailable.Load()
+}
+
+func markUnavailable(ctx context.Context) {
+	if a, _ := ctx.Value(availabilityKey{}).(*availability); a != nil {
+		a.unavailable.Store(true)
+	}
+}
+
+// breaker stops calling a database that keeps failing to connect, so
+// requests fail fast instead of queueing on dead connections.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+func (b *breaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// allow reports whether a call may go to the database.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures < breakerThreshold || !b.clock().Before(b.openUntil)
+}
+
+// record updates the breaker with the outcome of a call.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isConnError(err) {
+		if b.failures >= breakerThreshold {
+			metrics.SetDBCircuitOpen(false)
+			queryLogger.Info("db.circuit", "database reachable again, closing circuit")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		if b.failures == breakerThreshold {
+			metrics.SetDBCircuitOpen(true)
+			queryLogger.Error("db.circuit", "database unreachable after %d attempts, failing fast for %s: %v", b.failures, breakerCooldown, err)
+		}
+		b.openUntil = b.clock().Add(breakerCooldown)
+	}
+}
+
+// resilientPool retries calls that fail to reach the database and trips a
+// circuit breaker when it stays unreachable. Statements that may have reached
+// the server are not retried unless they only read.
+type resilientPool struct {
+	dbPool
+	breaker breaker
+	sleep   func(context.Context, time.Duration) error
+}
+
+func newResilientPool(pool dbPool) *resilientPool {
+	return &resilientPool{dbPool: pool, sleep: sleepContext}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// run calls fn until it succeeds, fails for another reason, or the attempts
+// run out. retryable decides which connection errors are safe to retry.
+func (p *resilientPool) run(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	var err error
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		if !p.breaker.allow() {
+			metrics.IncDBCircuitRejection()
+			markUnavailable(ctx)
+			return &unavailableError{err: ErrUnavailable}
+		}
+		err = fn()
+		p.breaker.record(err)
+		if !isConnError(err) {
+			return err
+		}
+		if attempt >= retryAttempts || !retryable(err) || p.sleep(ctx, backoff) != nil {
+			markUnavailable(ctx)
+			return &unavailableError{err: err}
+		}
+		metrics.IncDBRetry()
+		backoff *= 2
+	}
+}
+
+// anyConnError allows retrying every connection error; used for reads.
+func anyConnError(error) bool { return true }
+
+// notSent reports whether err shows the statement never reached the server,
+// which makes retrying a write safe.
+func notSent(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (p *resilientPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	retryable := notSent
+	if isReadQuery(query) {
+		retryable = anyConnError
+	}
+	err := p.run(ctx, retryable, func() error {
+		var err error
+		rows, err = p.dbPool.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (p *resilientPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	var row *sql.Row
+	retryable := notSent
+	if isReadQuery(query) {
+		retryable = anyConnError
+	}
+	err := p.run(ctx, retryable, func() error {
+		row = p.dbPool.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	if err != nil && row == nil {
+		// The breaker refused the call. sql.Row cannot carry our error, so
+		// hand back one that fails without contacting the database; the
+		// request is already marked unavailable.
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		return p.dbPool.QueryRowContext(cancelled, query, args...)
+	}
+	return row
+}
+
+func (p *resilientPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := p.run(ctx, notSent, func() error {
+		var err error
+		res, err = p.dbPool.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *resilientPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	var tx *sql.Tx
+	// Nothing has run yet, so a failed begin is always safe to retry.
+	err := p.run(ctx, anyConnError, func() error {
+		var err error
+		tx, err = p.dbPool.BeginTx(ctx, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (p *resilientPool) PingContext(ctx context.Context) error {
+	return p.run(ctx, anyConnError, func() error {
+		return p.dbPool.PingContext(ctx)
+	})
+}
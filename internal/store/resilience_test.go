@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type flakyPool struct {
+	dbPool
+	err   error
+	pings int
+}
+
+func (p *flakyPool) PingContext(ctx context.Context) error {
+	p.pings++
+	return p.err
+}
+
+func TestResilientPoolRetriesAndTripsBreaker(t *testing.T) {
+	inner := &flakyPool{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool := newResilientPool(inner)
+	pool.breaker.now = func() time.Time { return now }
+	pool.sleep = func(context.Context, time.Duration) error { return nil }
+
+	ctx := WithAvailabilityTracking(context.Background())
+	if err := pool.PingContext(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("PingContext() error = %v, want ErrUnavailable", err)
+	}
+	if inner.pings != retryAttempts || !Unavailable(ctx) {
+		t.Fatalf("pings = %d, unavailable = %v; want %d attempts and the request marked", inner.pings, Unavailable(ctx), retryAttempts)
+	}
+
+	// The next call reaches the threshold; after that calls fail fast.
+	_ = pool.PingContext(context.Background())
+	before := inner.pings
+	if err := pool.PingContext(context.Background()); !errors.Is(err, ErrUnavailable) || inner.pings != before {
+		t.Fatalf("expected the open breaker to refuse without calling the database, got %v after %d pings", err, inner.pings-before)
+	}
+
+	// Once the cooldown passes a successful call closes the breaker.
+	now = now.Add(breakerCooldown)
+	inner.err = nil
+	if err := pool.PingContext(context.Background()); err != nil {
+		t.Fatalf("PingContext() after recovery error = %v", err)
+	}
+	if !pool.breaker.allow() {
+		t.Fatal("expected the breaker to close after a success")
+	}
+}
+
+func TestNotSentOnlyRetriesUnsentStatements(t *testing.T) {
+	if !notSent(&net.OpError{Op: "dial", Err: errors.New("refused")}) {
+		t.Fatal("expected a dial failure to be safe to retry")
+	}
+	if notSent(&net.OpError{Op: "read", Err: errors.New("reset")}) {
+		t.Fatal("expected a read failure to be unsafe to retry for writes")
+	}
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SchemaExistsQuery reports whether CalCard's tables have been created in
+// the schema the connection's search_path names, for the database manager
+// to decide whether db.sql must be loaded.
+const SchemaExistsQuery = `SELECT to_regclass('users') IS NOT NULL`
+
+// EnsureSchema creates the named Postgres schema when it does not exist, so
+// that the tables can then be created in it. The name must be one that
+// needs no quoting, as config.Load checks for APP_DB_SCHEMA. An existing
+// schema is left alone, so the user needs no CREATE privilege on the
+// database once an administrator has created it.
+func EnsureSchema(ctx context.Context, db *sql.DB, schema string) error {
+	if schema == "" {
+		return nil
+	}
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`, schema).Scan(&exists); err != nil || exists {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+schema)
+	return err
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEnsureSchemaCreatesOnlyMissingSchemas(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	exists := regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`)
+
+	if err := EnsureSchema(ctx, db, ""); err != nil {
+		t.Fatalf("EnsureSchema() without a schema = %v", err)
+	}
+
+	mock.ExpectQuery(exists).WithArgs("calcard").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	if err := EnsureSchema(ctx, db, "calcard"); err != nil {
+		t.Fatalf("EnsureSchema() on an existing schema = %v", err)
+	}
+
+	mock.ExpectQuery(exists).WithArgs("calcard").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE SCHEMA IF NOT EXISTS calcard`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := EnsureSchema(ctx, db, "calcard"); err != nil {
+		t.Fatalf("EnsureSchema() = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
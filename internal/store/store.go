@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"database/sql"
+
+	"github.com/jw6ventures/calcard/internal/scan"
 )
 
 type txPool interface {
@@ -12,34 +14,125 @@ type txPool interface {
 
 // Store aggregates repositories backed by PostgreSQL.
 type Store struct {
-	pool txPool
+	pool       txPool
+	replicated bool
+
+	Users             UserRepository
+	Calendars         CalendarRepository
+	Events            EventRepository
+	AddressBooks      AddressBookRepository
+	Contacts          ContactRepository
+	AppPasswords      AppPasswordRepository
+	EmailAliases      EmailAliasRepository
+	DeletedResources  DeletedResourceRepository
+	HeldDeletions     HeldDeletionRepository
+	CollectionSyncs   CollectionSyncRepository
+	ClientRepairs     ClientRepairRepository
+	Changes           ChangeRepository
+	FreeBusyLinks     FreeBusyLinkRepository
+	TaskFeedLinks     TaskFeedLinkRepository
+	BookingPages      BookingPageRepository
+	ConferenceHooks   ConferenceHookRepository
+	Retention         RetentionRepository
+	EventRevisions    EventRevisionRepository
+	ContactRevisions  ContactRevisionRepository
+	Resources         SchedulingResourceRepository
+	CanonicalForms    CanonicalFormRepository
+	Orphans           OrphanRepository
+	Sessions          SessionRepository
+	AuthEvents        AuthEventRepository
+	Impersonations    ImpersonationRepository
+	Jobs              JobRepository
+	JobJournal        JobJournalRepository
+	Locks             LockRepository
+	ACLEntries        ACLRepository
+	UserGroups        UserGroupRepository
+	Locations         LocationRepository
+	Digests           DigestRepository
+	Notifications     CollectionNotificationRepository
+	PushSubscriptions PushSubscriptionRepository
+	IdempotencyKeys   IdempotencyKeyRepository
+	EventLinks        EventLinkRepository
+	Tombstones        CollectionTombstoneRepository
+	UsageStats        UsageStatsRepository
+	Maintenance       CollectionMaintenanceRepository
+	Transfers         CalendarTransferRepository
+	Providers         NotificationProviderRepository
+	AlarmDeliveries   AlarmDeliveryRepository
+	Quota             StorageQuotaRepository
+	Focus             FocusRepository
+	FeatureFlags      FeatureFlagRepository
 
-	Users            UserRepository
-	Calendars        CalendarRepository
-	Events           EventRepository
-	AddressBooks     AddressBookRepository
-	Contacts         ContactRepository
-	AppPasswords     AppPasswordRepository
-	DeletedResources DeletedResourceRepository
-	Sessions         SessionRepository
-	Locks            LockRepository
-	ACLEntries       ACLRepository
+	// Blobs keeps attachments and contact photos; nil when no storage is
+	// configured.
+	Blobs BlobStore
+	// Scanner checks inline attachments and uploaded contact photos for
+	// malware before they are stored; nil when scanning is off.
+	Scanner scan.Scanner
 }
 
 // New wires concrete repository implementations with shared connection pool.
 func New(pool *sql.DB) *Store {
+	return NewWithReplica(pool, nil)
+}
+
+// NewWithReplica is New with a read-only replica that serves the reads of
+// contexts marked with WithReplicaReads. A nil replica sends every query to
+// the primary.
+func NewWithReplica(primary, replica *sql.DB) *Store {
+	var routed dbPool = primary
+	if replica != nil {
+		routed = &routedPool{DB: primary, replica: replica}
+	}
+	pool := actorPool{newResilientPool(routed)}
 	return &Store{
-		pool:             pool,
-		Users:            &userRepo{pool: pool},
-		Calendars:        &calendarRepo{pool: pool},
-		Events:           &eventRepo{pool: pool},
-		AddressBooks:     &addressBookRepo{pool: pool},
-		Contacts:         &contactRepo{pool: pool},
-		AppPasswords:     &appPasswordRepo{pool: pool},
-		DeletedResources: &deletedResourceRepo{pool: pool},
-		Sessions:         &sessionRepo{pool: pool},
-		Locks:            &lockRepo{pool: pool},
-		ACLEntries:       &aclRepo{pool: pool},
+		pool:              pool,
+		replicated:        replica != nil,
+		Users:             &userRepo{pool: pool},
+		Calendars:         &calendarRepo{pool: pool},
+		Events:            &eventRepo{pool: pool},
+		AddressBooks:      &addressBookRepo{pool: pool},
+		Contacts:          &contactRepo{pool: pool},
+		AppPasswords:      &appPasswordRepo{pool: pool},
+		EmailAliases:      &emailAliasRepo{pool: pool},
+		DeletedResources:  &deletedResourceRepo{pool: pool},
+		HeldDeletions:     &heldDeletionRepo{pool: pool},
+		CollectionSyncs:   &collectionSyncRepo{pool: pool},
+		ClientRepairs:     &clientRepairRepo{pool: pool},
+		Changes:           &changeRepo{pool: pool},
+		FreeBusyLinks:     &freeBusyLinkRepo{pool: pool},
+		TaskFeedLinks:     &taskFeedLinkRepo{pool: pool},
+		BookingPages:      &bookingPageRepo{pool: pool},
+		ConferenceHooks:   &conferenceHookRepo{pool: pool},
+		Retention:         &retentionRepo{pool: pool},
+		EventRevisions:    &eventRevisionRepo{pool: pool},
+		ContactRevisions:  &contactRevisionRepo{pool: pool},
+		Resources:         &schedulingResourceRepo{pool: pool},
+		CanonicalForms:    &canonicalFormRepo{pool: pool},
+		Orphans:           &orphanRepo{pool: pool},
+		Sessions:          &sessionRepo{pool: pool},
+		AuthEvents:        &authEventRepo{pool: pool},
+		Impersonations:    &impersonationRepo{pool: pool},
+		Jobs:              &jobRepo{pool: pool},
+		JobJournal:        &jobJournalRepo{pool: pool},
+		Locks:             &lockRepo{pool: pool},
+		ACLEntries:        &aclRepo{pool: pool},
+		UserGroups:        &userGroupRepo{pool: pool},
+		Locations:         &locationRepo{pool: pool},
+		Digests:           &digestRepo{pool: pool},
+		Notifications:     &collectionNotificationRepo{pool: pool},
+		PushSubscriptions: &pushSubscriptionRepo{pool: pool},
+		IdempotencyKeys:   &idempotencyKeyRepo{pool: pool},
+		EventLinks:        &eventLinkRepo{pool: pool},
+		Tombstones:        &collectionTombstoneRepo{pool: pool},
+		UsageStats:        &usageStatsRepo{pool: pool},
+		Maintenance:       &collectionMaintenanceRepo{pool: pool},
+		Transfers:         &calendarTransferRepo{pool: pool},
+		Providers:         &notificationProviderRepo{pool: pool},
+		AlarmDeliveries:   &alarmDeliveryRepo{pool: pool},
+		Quota:             &quotaRepo{pool: pool},
+		Focus:             &focusRepo{pool: pool},
+		FeatureFlags:      newFeatureFlagCache(&featureFlagRepo{pool: pool}, featureFlagCacheTTL),
 	}
 }
 
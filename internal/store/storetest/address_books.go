@@ -0,0 +1,126 @@
+package storetest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// AddressBooks is an in-memory store.AddressBookRepository. Like the
+// database, it refuses two books of one user with the same name.
+type AddressBooks struct {
+	Books map[int64]*store.AddressBook
+}
+
+// NewAddressBooks returns an AddressBooks holding books.
+func NewAddressBooks(books ...store.AddressBook) *AddressBooks {
+	f := &AddressBooks{Books: map[int64]*store.AddressBook{}}
+	for _, book := range books {
+		f.Books[book.ID] = &book
+	}
+	return f
+}
+
+func (f *AddressBooks) hasDuplicateName(userID, excludeID int64, name string) bool {
+	for id, book := range f.Books {
+		if id == excludeID || book.UserID != userID {
+			continue
+		}
+		if strings.EqualFold(book.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *AddressBooks) GetByID(ctx context.Context, id int64) (*store.AddressBook, error) {
+	if f.Books == nil {
+		return nil, nil
+	}
+	return f.Books[id], nil
+}
+
+func (f *AddressBooks) ListByUser(ctx context.Context, userID int64) ([]store.AddressBook, error) {
+	var res []store.AddressBook
+	for _, b := range f.Books {
+		if b.UserID == userID {
+			copy := *b
+			res = append(res, copy)
+		}
+	}
+	return res, nil
+}
+
+func (f *AddressBooks) Create(ctx context.Context, book store.AddressBook) (*store.AddressBook, error) {
+	if f.Books == nil {
+		f.Books = map[int64]*store.AddressBook{}
+	}
+	if f.hasDuplicateName(book.UserID, 0, book.Name) {
+		return nil, store.ErrConflict
+	}
+	book.ID = int64(len(f.Books) + 1)
+	copy := book
+	f.Books[copy.ID] = &copy
+	return &copy, nil
+}
+
+func (f *AddressBooks) Update(ctx context.Context, userID, id int64, name string, description *string) error {
+	book, ok := f.Books[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if book.UserID != userID {
+		return store.ErrNotFound
+	}
+	if f.hasDuplicateName(userID, id, name) {
+		return store.ErrConflict
+	}
+	book.Name = name
+	if description != nil {
+		book.Description = description
+	}
+	return nil
+}
+
+func (f *AddressBooks) UpdateProperties(ctx context.Context, id int64, name string, description *string) error {
+	book, ok := f.Books[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if f.hasDuplicateName(book.UserID, id, name) {
+		return store.ErrConflict
+	}
+	book.Name = name
+	if description != nil {
+		book.Description = description
+	}
+	return nil
+}
+
+func (f *AddressBooks) Rename(ctx context.Context, userID, id int64, name string) error {
+	book, ok := f.Books[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if book.UserID != userID {
+		return store.ErrNotFound
+	}
+	if f.hasDuplicateName(userID, id, name) {
+		return store.ErrConflict
+	}
+	book.Name = name
+	return nil
+}
+
+func (f *AddressBooks) Delete(ctx context.Context, userID, id int64) error {
+	book, ok := f.Books[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if book.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(f.Books, id)
+	return nil
+}
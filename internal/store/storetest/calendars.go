@@ -0,0 +1,144 @@
+package storetest
+
+import (
+	"context"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Calendars is an in-memory store.CalendarRepository. GetByID and the
+// writes use Calendars, while the access queries use AccessibleByUser when
+// it is set and Accessible otherwise.
+type Calendars struct {
+	Accessible       []store.CalendarAccess
+	AccessibleByUser map[int64][]store.CalendarAccess
+	Calendars        map[int64]*store.Calendar
+}
+
+// NewCalendars returns a Calendars in which every user can reach access and
+// whose calendars are known by ID.
+func NewCalendars(access ...store.CalendarAccess) *Calendars {
+	f := &Calendars{Accessible: access, Calendars: map[int64]*store.Calendar{}}
+	for _, a := range access {
+		cal := a.Calendar
+		f.Calendars[cal.ID] = &cal
+	}
+	return f
+}
+
+func (f *Calendars) GetByID(ctx context.Context, id int64) (*store.Calendar, error) {
+	if f.Calendars == nil {
+		return nil, nil
+	}
+	return f.Calendars[id], nil
+}
+
+func (f *Calendars) ListByUser(ctx context.Context, userID int64) ([]store.Calendar, error) {
+	if f.Calendars == nil {
+		return nil, nil
+	}
+	var result []store.Calendar
+	for _, cal := range f.Calendars {
+		if cal.UserID == userID {
+			result = append(result, *cal)
+		}
+	}
+	return result, nil
+}
+
+func (f *Calendars) ListAccessible(ctx context.Context, userID int64) ([]store.CalendarAccess, error) {
+	if f.AccessibleByUser != nil {
+		return f.AccessibleByUser[userID], nil
+	}
+	return f.Accessible, nil
+}
+
+func (f *Calendars) ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*store.PaginatedResult[store.CalendarAccess], error) {
+	all, err := f.ListAccessible(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	page := &store.PaginatedResult[store.CalendarAccess]{TotalCount: len(all), Limit: limit, Offset: offset}
+	if offset < len(all) {
+		page.Items = all[offset:min(offset+limit, len(all))]
+	}
+	return page, nil
+}
+
+func (f *Calendars) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
+	if f.AccessibleByUser != nil {
+		for _, c := range f.AccessibleByUser[userID] {
+			if c.ID == calendarID {
+				if c.UserID == 0 && !c.Shared {
+					c.UserID = userID
+				}
+				copy := c
+				return &copy, nil
+			}
+		}
+		return nil, nil
+	}
+	for _, c := range f.Accessible {
+		if c.ID == calendarID {
+			if c.UserID == 0 && !c.Shared {
+				c.UserID = userID
+			}
+			copy := c
+			return &copy, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Calendars) Create(ctx context.Context, cal store.Calendar) (*store.Calendar, error) {
+	if f.Calendars == nil {
+		f.Calendars = map[int64]*store.Calendar{}
+	}
+	cal.ID = int64(len(f.Calendars) + 1)
+	copy := cal
+	f.Calendars[copy.ID] = &copy
+	if cal.UserID != 0 {
+		f.Accessible = append(f.Accessible, store.CalendarAccess{Calendar: copy, Editor: true})
+	}
+	return &copy, nil
+}
+
+func (f *Calendars) Update(ctx context.Context, userID, id int64, name string, description, timezone, color *string) error {
+	cal, ok := f.Calendars[id]
+	if !ok || cal.UserID != userID {
+		return store.ErrNotFound
+	}
+	cal.Name = name
+	cal.Description = description
+	cal.Timezone = timezone
+	cal.Color = color
+	return nil
+}
+
+func (f *Calendars) UpdateProperties(ctx context.Context, id int64, name string, description, timezone, color *string) error {
+	cal, ok := f.Calendars[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	cal.Name = name
+	cal.Description = description
+	cal.Timezone = timezone
+	cal.Color = color
+	return nil
+}
+
+func (f *Calendars) Rename(ctx context.Context, userID, id int64, name string) error {
+	return nil
+}
+
+func (f *Calendars) Delete(ctx context.Context, userID, id int64) error {
+	cal, ok := f.Calendars[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if cal.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(f.Calendars, id)
+	return nil
+}
@@ -0,0 +1,263 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Contacts is an in-memory store.ContactRepository. Contacts are keyed by
+// Key.
+type Contacts struct {
+	Contacts map[string]*store.Contact
+	// Deleted lists the keys of deleted contacts, in order.
+	Deleted   []string
+	CopyErr   error
+	MoveErr   error
+	UpsertErr error
+	// GetByUIDErr fails GetByUID for the key GetByUIDErrKey, or for every
+	// key when it is empty.
+	GetByUIDErr    error
+	GetByUIDErrKey string
+	// GetByResourceNameErr fails GetByResourceName likewise.
+	GetByResourceNameErr    error
+	GetByResourceNameErrKey string
+	// ResourceLookupCount counts GetByResourceName calls.
+	ResourceLookupCount int
+	// OverwriteMoveDeleted, when set, gets a tombstone for each contact a
+	// move overwrites, as the database trigger writes.
+	OverwriteMoveDeleted *DeletedResources
+}
+
+// NewContacts returns a Contacts holding contacts.
+func NewContacts(contacts ...store.Contact) *Contacts {
+	f := &Contacts{Contacts: map[string]*store.Contact{}}
+	for _, contact := range contacts {
+		_, _ = f.Upsert(context.Background(), contact)
+	}
+	return f
+}
+
+// Key returns the key of the contact uid in addressBookID.
+func (f *Contacts) Key(bookID int64, uid string) string {
+	return fmt.Sprintf("%d:%s", bookID, uid)
+}
+
+func (f *Contacts) Upsert(ctx context.Context, contact store.Contact) (*store.Contact, error) {
+	if f.UpsertErr != nil {
+		return nil, f.UpsertErr
+	}
+	if f.Contacts == nil {
+		f.Contacts = map[string]*store.Contact{}
+	}
+	if contact.ResourceName == "" {
+		contact.ResourceName = contact.UID
+	}
+	copy := contact
+	f.Contacts[f.Key(contact.AddressBookID, contact.UID)] = &copy
+	return &copy, nil
+}
+
+func (f *Contacts) DeleteByUID(ctx context.Context, addressBookID int64, uid string) error {
+	f.Deleted = append(f.Deleted, f.Key(addressBookID, uid))
+	delete(f.Contacts, f.Key(addressBookID, uid))
+	return nil
+}
+
+func (f *Contacts) GetByUID(ctx context.Context, addressBookID int64, uid string) (*store.Contact, error) {
+	if f.GetByUIDErr != nil && (f.GetByUIDErrKey == "" || f.GetByUIDErrKey == f.Key(addressBookID, uid)) {
+		return nil, f.GetByUIDErr
+	}
+	if c, ok := f.Contacts[f.Key(addressBookID, uid)]; ok {
+		copy := *c
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+func (f *Contacts) ListForBook(ctx context.Context, addressBookID int64) ([]store.Contact, error) {
+	var result []store.Contact
+	for _, c := range f.Contacts {
+		if c.AddressBookID != addressBookID {
+			continue
+		}
+		copy := *c
+		result = append(result, copy)
+	}
+	return result, nil
+}
+
+func (f *Contacts) ListForBookFiltered(ctx context.Context, addressBookID int64, _ store.ContactFilter) ([]store.Contact, error) {
+	return f.ListForBook(ctx, addressBookID)
+}
+
+func (f *Contacts) ListForBookPaginated(ctx context.Context, addressBookID int64, limit, offset int) (*store.PaginatedResult[store.Contact], error) {
+	contacts, _ := f.ListForBook(ctx, addressBookID)
+	return &store.PaginatedResult[store.Contact]{
+		Items:      contacts,
+		TotalCount: len(contacts),
+		Limit:      limit,
+		Offset:     offset,
+	}, nil
+}
+
+func (f *Contacts) ListByUIDs(ctx context.Context, addressBookID int64, uids []string) ([]store.Contact, error) {
+	uidSet := make(map[string]struct{}, len(uids))
+	for _, uid := range uids {
+		uidSet[uid] = struct{}{}
+	}
+	var result []store.Contact
+	for _, c := range f.Contacts {
+		if c.AddressBookID != addressBookID {
+			continue
+		}
+		if _, ok := uidSet[c.UID]; ok {
+			result = append(result, *c)
+		}
+	}
+	return result, nil
+}
+
+func (f *Contacts) ListModifiedSince(ctx context.Context, addressBookID int64, since time.Time) ([]store.Contact, error) {
+	var result []store.Contact
+	for _, c := range f.Contacts {
+		if c.AddressBookID != addressBookID {
+			continue
+		}
+		if c.LastModified.After(since) {
+			copy := *c
+			result = append(result, copy)
+		}
+	}
+	return result, nil
+}
+
+func (f *Contacts) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]store.Contact, error) {
+	return nil, nil
+}
+
+func (f *Contacts) MaxLastModified(ctx context.Context, addressBookID int64) (time.Time, error) {
+	var max time.Time
+	for _, c := range f.Contacts {
+		if c.AddressBookID != addressBookID {
+			continue
+		}
+		if c.LastModified.After(max) {
+			max = c.LastModified
+		}
+	}
+	return max, nil
+}
+
+func (f *Contacts) ListWithBirthdaysByUser(ctx context.Context, userID int64) ([]store.Contact, error) {
+	if f.Contacts == nil {
+		return nil, nil
+	}
+	var result []store.Contact
+	for _, c := range f.Contacts {
+		if c.Birthday != nil {
+			result = append(result, *c)
+		}
+	}
+	return result, nil
+}
+
+func (f *Contacts) GetByResourceName(ctx context.Context, addressBookID int64, resourceName string) (*store.Contact, error) {
+	f.ResourceLookupCount++
+	if f.GetByResourceNameErr != nil && (f.GetByResourceNameErrKey == "" || f.GetByResourceNameErrKey == f.Key(addressBookID, resourceName)) {
+		return nil, f.GetByResourceNameErr
+	}
+	for _, c := range f.Contacts {
+		if c.AddressBookID != addressBookID {
+			continue
+		}
+		name := c.ResourceName
+		if name == "" {
+			name = c.UID
+		}
+		if name == resourceName {
+			copy := *c
+			return &copy, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Contacts) CopyToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName, newETag string) (*store.Contact, error) {
+	if f.CopyErr != nil {
+		return nil, f.CopyErr
+	}
+	src, ok := f.Contacts[f.Key(fromAddressBookID, uid)]
+	if !ok {
+		return nil, nil
+	}
+	copy := *src
+	copy.AddressBookID = toAddressBookID
+	if destResourceName != "" {
+		copy.ResourceName = destResourceName
+	}
+	for key, existing := range f.Contacts {
+		if existing.AddressBookID != toAddressBookID || existing.UID == copy.UID {
+			continue
+		}
+		name := existing.ResourceName
+		if name == "" {
+			name = existing.UID
+		}
+		if name == copy.ResourceName {
+			delete(f.Contacts, key)
+		}
+	}
+	copy.ETag = newETag
+	f.Contacts[f.Key(toAddressBookID, copy.UID)] = &copy
+	return &copy, nil
+}
+
+func (f *Contacts) FindByLookupKey(context.Context, []int64, string, string, int) ([]store.Contact, error) {
+	return nil, nil
+}
+
+func (f *Contacts) MoveToAddressBook(ctx context.Context, fromAddressBookID, toAddressBookID int64, uid, destResourceName string) error {
+	if f.MoveErr != nil {
+		return f.MoveErr
+	}
+	oldKey := f.Key(fromAddressBookID, uid)
+	contact, ok := f.Contacts[oldKey]
+	if !ok {
+		return nil // Contact not found
+	}
+	// Remove from old location
+	delete(f.Contacts, oldKey)
+	// Update address book ID
+	contact.AddressBookID = toAddressBookID
+	if destResourceName != "" {
+		contact.ResourceName = destResourceName
+	}
+	for key, existing := range f.Contacts {
+		if existing.AddressBookID != toAddressBookID || existing.UID == contact.UID {
+			continue
+		}
+		name := existing.ResourceName
+		if name == "" {
+			name = existing.UID
+		}
+		if name == contact.ResourceName {
+			if f.OverwriteMoveDeleted != nil {
+				f.OverwriteMoveDeleted.Deleted = append(f.OverwriteMoveDeleted.Deleted, store.DeletedResource{
+					ResourceType: "contact",
+					CollectionID: toAddressBookID,
+					UID:          existing.UID,
+					ResourceName: name,
+					DeletedAt:    time.Now(),
+				})
+			}
+			delete(f.Contacts, key)
+		}
+	}
+	// Add to new location
+	newKey := f.Key(toAddressBookID, uid)
+	f.Contacts[newKey] = contact
+	return nil
+}
@@ -0,0 +1,43 @@
+package storetest
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// DeletedResources is an in-memory store.DeletedResourceRepository.
+type DeletedResources struct {
+	Deleted []store.DeletedResource
+}
+
+func (f *DeletedResources) ListDeletedSince(ctx context.Context, resourceType string, collectionID int64, since time.Time) ([]store.DeletedResource, error) {
+	var result []store.DeletedResource
+	for _, d := range f.Deleted {
+		if d.ResourceType == resourceType && d.CollectionID == collectionID && d.DeletedAt.After(since) {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+func (f *DeletedResources) DeleteByIdentity(ctx context.Context, resourceType string, collectionID int64, uid, resourceName string) error {
+	filtered := f.Deleted[:0]
+	for _, d := range f.Deleted {
+		if d.ResourceType == resourceType && d.CollectionID == collectionID && d.UID == uid && d.ResourceName == resourceName {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+	f.Deleted = filtered
+	return nil
+}
+
+func (f *DeletedResources) Cleanup(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (f *DeletedResources) ListOrphaned(ctx context.Context) ([]store.DeletedResource, error) {
+	return nil, nil
+}
@@ -0,0 +1,243 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Events is an in-memory store.EventRepository. Events are keyed by Key.
+type Events struct {
+	Events map[string]*store.Event
+	// Deleted lists the keys of deleted events, in order.
+	Deleted []string
+	CopyErr error
+	MoveErr error
+	// GetByUIDErr fails GetByUID for the key GetByUIDErrKey, or for every
+	// key when it is empty.
+	GetByUIDErr    error
+	GetByUIDErrKey string
+	// GetByResourceNameErr fails GetByResourceName likewise.
+	GetByResourceNameErr error
+	GetByResourceNameKey string
+	// ResourceLookupCount counts GetByResourceName calls.
+	ResourceLookupCount int
+	// OverwriteMoveDeleted, when set, gets a tombstone for each event a
+	// move overwrites, as the database trigger writes.
+	OverwriteMoveDeleted *DeletedResources
+}
+
+// NewEvents returns an Events holding events.
+func NewEvents(events ...store.Event) *Events {
+	f := &Events{Events: map[string]*store.Event{}}
+	for _, ev := range events {
+		_, _ = f.Upsert(context.Background(), ev)
+	}
+	return f
+}
+
+// Key returns the key of the event uid in calendarID.
+func (f *Events) Key(calendarID int64, uid string) string {
+	return fmt.Sprintf("%d:%s", calendarID, uid)
+}
+
+func (f *Events) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
+	if f.Events == nil {
+		f.Events = map[string]*store.Event{}
+	}
+	if event.ResourceName == "" {
+		event.ResourceName = event.UID
+	}
+	copy := event
+	f.Events[f.Key(event.CalendarID, event.UID)] = &copy
+	return &copy, nil
+}
+
+func (f *Events) DeleteByUID(ctx context.Context, calendarID int64, uid string) error {
+	f.Deleted = append(f.Deleted, f.Key(calendarID, uid))
+	delete(f.Events, f.Key(calendarID, uid))
+	return nil
+}
+
+func (f *Events) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	deleted := 0
+	for _, uid := range uids {
+		if err := f.DeleteByUID(ctx, calendarID, uid); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (f *Events) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
+	if f.GetByUIDErr != nil && (f.GetByUIDErrKey == "" || f.GetByUIDErrKey == f.Key(calendarID, uid)) {
+		return nil, f.GetByUIDErr
+	}
+	if ev, ok := f.Events[f.Key(calendarID, uid)]; ok {
+		copy := *ev
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+func (f *Events) GetByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, error) {
+	f.ResourceLookupCount++
+	if f.GetByResourceNameErr != nil && (f.GetByResourceNameKey == "" || f.GetByResourceNameKey == f.Key(calendarID, resourceName)) {
+		return nil, f.GetByResourceNameErr
+	}
+	for _, ev := range f.Events {
+		if ev.CalendarID != calendarID {
+			continue
+		}
+		name := ev.ResourceName
+		if name == "" {
+			name = ev.UID
+		}
+		if name == resourceName {
+			copy := *ev
+			return &copy, nil
+		}
+	}
+	return nil, nil
+}
+
+// OpenByResourceName returns the event found by GetByResourceName with its
+// body moved into a store.Body.
+func (f *Events) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, *store.Body, error) {
+	ev, err := f.GetByResourceName(ctx, calendarID, resourceName)
+	if err != nil || ev == nil {
+		return nil, nil, err
+	}
+	body := store.NewBody(ev.RawICAL)
+	ev.RawICAL = ""
+	return ev, body, nil
+}
+
+func (f *Events) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
+	var result []store.Event
+	for _, ev := range f.Events {
+		if ev.CalendarID != calendarID {
+			continue
+		}
+		copy := *ev
+		result = append(result, copy)
+	}
+	return result, nil
+}
+
+func (f *Events) ListForCalendarFiltered(ctx context.Context, calendarID int64, _ store.EventFilter) ([]store.Event, error) {
+	return f.ListForCalendar(ctx, calendarID)
+}
+
+func (f *Events) ListForCalendarPaginated(ctx context.Context, calendarID int64, limit, offset int) (*store.PaginatedResult[store.Event], error) {
+	events, _ := f.ListForCalendar(ctx, calendarID)
+	return &store.PaginatedResult[store.Event]{
+		Items:      events,
+		TotalCount: len(events),
+		Limit:      limit,
+		Offset:     offset,
+	}, nil
+}
+
+func (f *Events) ListByUIDs(ctx context.Context, calendarID int64, uids []string) ([]store.Event, error) {
+	return nil, nil
+}
+
+func (f *Events) ListModifiedSince(ctx context.Context, calendarID int64, since time.Time) ([]store.Event, error) {
+	var result []store.Event
+	for _, ev := range f.Events {
+		if ev.CalendarID != calendarID {
+			continue
+		}
+		if ev.LastModified.After(since) {
+			copy := *ev
+			result = append(result, copy)
+		}
+	}
+	return result, nil
+}
+
+func (f *Events) ListRecentByUser(ctx context.Context, userID int64, limit int) ([]store.Event, error) {
+	return nil, nil
+}
+
+func (f *Events) MaxLastModified(ctx context.Context, calendarID int64) (time.Time, error) {
+	var max time.Time
+	for _, ev := range f.Events {
+		if ev.CalendarID != calendarID {
+			continue
+		}
+		if ev.LastModified.After(max) {
+			max = ev.LastModified
+		}
+	}
+	return max, nil
+}
+
+func (f *Events) MoveToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName string) error {
+	if f.MoveErr != nil {
+		return f.MoveErr
+	}
+	oldKey := f.Key(fromCalendarID, uid)
+	ev, ok := f.Events[oldKey]
+	if !ok {
+		return nil
+	}
+	for key, existing := range f.Events {
+		if existing.CalendarID != toCalendarID || existing.UID == uid {
+			continue
+		}
+		name := existing.ResourceName
+		if name == "" {
+			name = existing.UID
+		}
+		if destResourceName != "" && name == destResourceName {
+			if f.OverwriteMoveDeleted != nil {
+				f.OverwriteMoveDeleted.Deleted = append(f.OverwriteMoveDeleted.Deleted, store.DeletedResource{
+					ResourceType: "event",
+					CollectionID: toCalendarID,
+					UID:          existing.UID,
+					ResourceName: name,
+					DeletedAt:    time.Now(),
+				})
+			}
+			delete(f.Events, key)
+		}
+	}
+	delete(f.Events, oldKey)
+	ev.CalendarID = toCalendarID
+	if destResourceName != "" {
+		ev.ResourceName = destResourceName
+	}
+	f.Events[f.Key(toCalendarID, uid)] = ev
+	return nil
+}
+
+func (f *Events) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
+	if f.CopyErr != nil {
+		return nil, f.CopyErr
+	}
+	src, ok := f.Events[f.Key(fromCalendarID, uid)]
+	if !ok {
+		return nil, nil
+	}
+	copy := *src
+	copy.CalendarID = toCalendarID
+	if destResourceName != "" {
+		copy.ResourceName = destResourceName
+	}
+	copy.ETag = newETag
+	f.Events[f.Key(toCalendarID, copy.UID)] = &copy
+	return &copy, nil
+}
+
+func (f *Events) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	return 0, nil
+}
+
+func (f *Events) SplitToNewCalendar(ctx context.Context, cal store.Calendar, fromCalendarID int64, uids []string) (*store.Calendar, int, error) {
+	return nil, 0, nil
+}
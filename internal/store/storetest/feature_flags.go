@@ -0,0 +1,73 @@
+package storetest
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// FeatureFlags is an in-memory store.FeatureFlagRepository. Overrides holds
+// the saved overrides in the order they were made.
+type FeatureFlags struct {
+	Overrides []store.FeatureFlagOverride
+	nextID    int64
+}
+
+func (f *FeatureFlags) List(ctx context.Context) ([]store.FeatureFlagOverride, error) {
+	return slices.Clone(f.Overrides), nil
+}
+
+func (f *FeatureFlags) ListForUser(ctx context.Context, userID int64, groupIDs []int64) ([]store.FeatureFlagOverride, error) {
+	var list []store.FeatureFlagOverride
+	for _, o := range f.Overrides {
+		if (o.UserID != nil && *o.UserID == userID) || (o.GroupID != nil && slices.Contains(groupIDs, *o.GroupID)) {
+			list = append(list, o)
+		}
+	}
+	return list, nil
+}
+
+func (f *FeatureFlags) AnyEnabled(ctx context.Context, flag string) (bool, error) {
+	for _, o := range f.Overrides {
+		if o.Flag == flag && o.Enabled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *FeatureFlags) Set(ctx context.Context, override store.FeatureFlagOverride) (*store.FeatureFlagOverride, error) {
+	now := store.Now()
+	override.UpdatedAt = now
+	if i := f.index(override.Flag, override.UserID, override.GroupID); i >= 0 {
+		override.ID, override.CreatedAt = f.Overrides[i].ID, f.Overrides[i].CreatedAt
+		f.Overrides[i] = override
+		return &override, nil
+	}
+	f.nextID++
+	override.ID, override.CreatedAt = f.nextID, now
+	f.Overrides = append(f.Overrides, override)
+	return &override, nil
+}
+
+func (f *FeatureFlags) Delete(ctx context.Context, flag string, userID, groupID int64) (bool, error) {
+	var i int
+	if groupID != 0 {
+		i = f.index(flag, nil, &groupID)
+	} else {
+		i = f.index(flag, &userID, nil)
+	}
+	if i < 0 {
+		return false, nil
+	}
+	f.Overrides = slices.Delete(f.Overrides, i, i+1)
+	return true, nil
+}
+
+func (f *FeatureFlags) index(flag string, userID, groupID *int64) int {
+	same := func(a, b *int64) bool { return (a == nil) == (b == nil) && (a == nil || *a == *b) }
+	return slices.IndexFunc(f.Overrides, func(o store.FeatureFlagOverride) bool {
+		return o.Flag == flag && same(o.UserID, userID) && same(o.GroupID, groupID)
+	})
+}
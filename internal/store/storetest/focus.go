@@ -0,0 +1,98 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Focus is an in-memory store.FocusRepository. Settings holds each user's
+// focus time settings and Blocks the blocks planned from them.
+type Focus struct {
+	Settings map[int64]*store.FocusSettings
+	Blocks   []store.FocusBlock
+	nextID   int64
+}
+
+func (f *Focus) GetSettings(ctx context.Context, userID int64) (*store.FocusSettings, error) {
+	settings, ok := f.Settings[userID]
+	if !ok {
+		return nil, nil
+	}
+	copy := *settings
+	return &copy, nil
+}
+
+func (f *Focus) UpsertSettings(ctx context.Context, settings store.FocusSettings) (*store.FocusSettings, error) {
+	if f.Settings == nil {
+		f.Settings = map[int64]*store.FocusSettings{}
+	}
+	now := store.Now()
+	settings.PlannedUntil = nil
+	settings.CreatedAt, settings.UpdatedAt = now, now
+	if existing, ok := f.Settings[settings.UserID]; ok {
+		settings.CreatedAt = existing.CreatedAt
+	}
+	f.Settings[settings.UserID] = &settings
+	copy := settings
+	return &copy, nil
+}
+
+func (f *Focus) DeleteSettings(ctx context.Context, userID int64) error {
+	delete(f.Settings, userID)
+	return nil
+}
+
+func (f *Focus) ListSettings(ctx context.Context) ([]store.FocusSettings, error) {
+	var list []store.FocusSettings
+	for _, settings := range f.Settings {
+		list = append(list, *settings)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+	return list, nil
+}
+
+func (f *Focus) ClaimPlanning(ctx context.Context, userID int64, from *time.Time, until time.Time) (bool, error) {
+	settings, ok := f.Settings[userID]
+	if !ok {
+		return false, nil
+	}
+	switch {
+	case from == nil && settings.PlannedUntil != nil,
+		from != nil && (settings.PlannedUntil == nil || !settings.PlannedUntil.Equal(*from)):
+		return false, nil
+	}
+	settings.PlannedUntil = &until
+	return true, nil
+}
+
+func (f *Focus) AddBlock(ctx context.Context, block store.FocusBlock) error {
+	f.nextID++
+	block.ID = f.nextID
+	block.CreatedAt = store.Now()
+	f.Blocks = append(f.Blocks, block)
+	return nil
+}
+
+func (f *Focus) ListBlocks(ctx context.Context, userID int64, after time.Time) ([]store.FocusBlock, error) {
+	var list []store.FocusBlock
+	for _, b := range f.Blocks {
+		if b.UserID == userID && b.End.After(after) {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	return list, nil
+}
+
+func (f *Focus) DeleteBlock(ctx context.Context, userID, id int64) error {
+	for i, b := range f.Blocks {
+		if b.ID == id && b.UserID == userID {
+			f.Blocks = append(f.Blocks[:i], f.Blocks[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
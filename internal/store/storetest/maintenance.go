@@ -0,0 +1,62 @@
+package storetest
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Maintenance is an in-memory store.CollectionMaintenanceRepository.
+// Entries past their ExpiresAt are treated as not running.
+type Maintenance struct {
+	Running []store.CollectionMaintenance
+}
+
+func (f *Maintenance) Start(ctx context.Context, m store.CollectionMaintenance) (*store.CollectionMaintenance, error) {
+	m.StartedAt = time.Now()
+	if i := f.index(m.CollectionType, m.CollectionID); i >= 0 {
+		f.Running[i] = m
+	} else {
+		f.Running = append(f.Running, m)
+	}
+	return &m, nil
+}
+
+func (f *Maintenance) Get(ctx context.Context, collectionType string, collectionID int64) (*store.CollectionMaintenance, error) {
+	i := f.index(collectionType, collectionID)
+	if i < 0 || !time.Now().Before(f.Running[i].ExpiresAt) {
+		return nil, nil
+	}
+	m := f.Running[i]
+	return &m, nil
+}
+
+func (f *Maintenance) ListActive(ctx context.Context) ([]store.CollectionMaintenance, error) {
+	var list []store.CollectionMaintenance
+	for _, m := range f.Running {
+		if time.Now().Before(m.ExpiresAt) {
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+func (f *Maintenance) End(ctx context.Context, collectionType string, collectionID int64) (bool, error) {
+	i := f.index(collectionType, collectionID)
+	if i < 0 {
+		return false, nil
+	}
+	running := time.Now().Before(f.Running[i].ExpiresAt)
+	f.Running = append(f.Running[:i], f.Running[i+1:]...)
+	return running, nil
+}
+
+func (f *Maintenance) index(collectionType string, collectionID int64) int {
+	for i, m := range f.Running {
+		if m.CollectionType == collectionType && m.CollectionID == collectionID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,142 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Providers is an in-memory store.NotificationProviderRepository.
+type Providers struct {
+	List []store.NotificationProvider
+}
+
+func (f *Providers) Create(ctx context.Context, p store.NotificationProvider) (*store.NotificationProvider, error) {
+	p.ID = int64(len(f.List) + 1)
+	for _, existing := range f.List {
+		p.ID = max(p.ID, existing.ID+1)
+	}
+	p.CreatedAt = time.Now()
+	f.List = append(f.List, p)
+	return &p, nil
+}
+
+func (f *Providers) Get(ctx context.Context, userID, id int64) (*store.NotificationProvider, error) {
+	for _, p := range f.List {
+		if p.ID == id && p.UserID == userID {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *Providers) ListByUser(ctx context.Context, userID int64) ([]store.NotificationProvider, error) {
+	var list []store.NotificationProvider
+	for _, p := range f.List {
+		if p.UserID == userID {
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
+
+func (f *Providers) ListAll(ctx context.Context) ([]store.NotificationProvider, error) {
+	list := append([]store.NotificationProvider(nil), f.List...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+	return list, nil
+}
+
+func (f *Providers) Delete(ctx context.Context, userID, id int64) (bool, error) {
+	for i, p := range f.List {
+		if p.ID == id && p.UserID == userID {
+			f.List = append(f.List[:i], f.List[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AlarmDeliveries is an in-memory store.AlarmDeliveryRepository.
+type AlarmDeliveries struct {
+	List []store.AlarmDelivery
+}
+
+func (f *AlarmDeliveries) Enqueue(ctx context.Context, d store.AlarmDelivery) (bool, error) {
+	for _, existing := range f.List {
+		if existing.ProviderID == d.ProviderID && existing.CalendarID == d.CalendarID && existing.UID == d.UID &&
+			existing.AlarmID == d.AlarmID && existing.FireAt.Equal(d.FireAt) {
+			return false, nil
+		}
+	}
+	d.ID = int64(len(f.List) + 1)
+	d.Status = store.DeliveryPending
+	d.CreatedAt = time.Now()
+	f.List = append(f.List, d)
+	return true, nil
+}
+
+func (f *AlarmDeliveries) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]store.AlarmDelivery, error) {
+	var claimed []store.AlarmDelivery
+	for i := range f.List {
+		d := &f.List[i]
+		if len(claimed) == limit || d.Status != store.DeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt = leaseUntil
+		claimed = append(claimed, *d)
+	}
+	return claimed, nil
+}
+
+func (f *AlarmDeliveries) MarkSent(ctx context.Context, id int64, sentAt time.Time) error {
+	if d := f.find(id); d != nil {
+		d.Status, d.SentAt, d.LastError = store.DeliverySent, &sentAt, ""
+	}
+	return nil
+}
+
+func (f *AlarmDeliveries) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	if d := f.find(id); d != nil {
+		d.LastError = lastError
+		if retryAt == nil {
+			d.Status = store.DeliveryFailed
+		} else {
+			d.NextAttemptAt = *retryAt
+		}
+	}
+	return nil
+}
+
+func (f *AlarmDeliveries) ListByUser(ctx context.Context, userID int64, limit int) ([]store.AlarmDelivery, error) {
+	var list []store.AlarmDelivery
+	for i := len(f.List) - 1; i >= 0 && len(list) < limit; i-- {
+		if f.List[i].UserID == userID {
+			list = append(list, f.List[i])
+		}
+	}
+	return list, nil
+}
+
+func (f *AlarmDeliveries) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	kept := f.List[:0]
+	for _, d := range f.List {
+		if !d.FireAt.Before(cutoff) {
+			kept = append(kept, d)
+		}
+	}
+	n := int64(len(f.List) - len(kept))
+	f.List = kept
+	return n, nil
+}
+
+func (f *AlarmDeliveries) find(id int64) *store.AlarmDelivery {
+	for i := range f.List {
+		if f.List[i].ID == id {
+			return &f.List[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Quota is an in-memory store.StorageQuotaRepository. Used holds each
+// user's usage and Warned the warning each was last sent.
+type Quota struct {
+	Used   map[int64]int64
+	Warned map[int64]string
+}
+
+func (f *Quota) Usage(ctx context.Context, userID int64) (int64, error) {
+	return f.Used[userID], nil
+}
+
+func (f *Quota) ListUsage(ctx context.Context) ([]store.StorageUsage, error) {
+	var list []store.StorageUsage
+	for userID, used := range f.Used {
+		list = append(list, store.StorageUsage{UserID: userID, UsedBytes: used, Warned: f.Warned[userID]})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+	return list, nil
+}
+
+func (f *Quota) SetWarned(ctx context.Context, userID int64, from, to string) (bool, error) {
+	if f.Warned[userID] != from {
+		return false, nil
+	}
+	if f.Warned == nil {
+		f.Warned = map[int64]string{}
+	}
+	if to == "" {
+		delete(f.Warned, userID)
+	} else {
+		f.Warned[userID] = to
+	}
+	return true, nil
+}
@@ -0,0 +1,170 @@
+// Package storetest provides in-memory fakes of the store repositories for
+// tests of the packages built on them, so that DAV, the REST API and other
+// subsystems exercise the same fake behaviour rather than each keeping a
+// copy that drifts.
+//
+// The fakes keep their state in exported fields, so a test can set up a
+// scenario directly and inspect what a handler wrote. They model what
+// callers rely on, such as resource names defaulting to UIDs and moves
+// tombstoning what they overwrite, and not SQL details such as ordering.
+package storetest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+var (
+	_ store.UserRepository                  = (*Users)(nil)
+	_ store.CalendarRepository              = (*Calendars)(nil)
+	_ store.EventRepository                 = (*Events)(nil)
+	_ store.AddressBookRepository           = (*AddressBooks)(nil)
+	_ store.ContactRepository               = (*Contacts)(nil)
+	_ store.DeletedResourceRepository       = (*DeletedResources)(nil)
+	_ store.CollectionMaintenanceRepository = (*Maintenance)(nil)
+	_ store.CalendarTransferRepository      = (*Transfers)(nil)
+	_ store.NotificationProviderRepository  = (*Providers)(nil)
+	_ store.AlarmDeliveryRepository         = (*AlarmDeliveries)(nil)
+	_ store.StorageQuotaRepository          = (*Quota)(nil)
+	_ store.FocusRepository                 = (*Focus)(nil)
+	_ store.FeatureFlagRepository           = (*FeatureFlags)(nil)
+)
+
+// Fixture is a store.Store backed by fakes, with the fakes at hand.
+type Fixture struct {
+	Calendars        *Calendars
+	Events           *Events
+	AddressBooks     *AddressBooks
+	Contacts         *Contacts
+	DeletedResources *DeletedResources
+	// Store uses the fakes above; set its other repositories as the test
+	// needs them.
+	Store *store.Store
+}
+
+// New returns a Fixture with empty fakes. Moves tombstone what they
+// overwrite into its DeletedResources.
+func New() *Fixture {
+	f := &Fixture{
+		Calendars:        NewCalendars(),
+		Events:           NewEvents(),
+		AddressBooks:     NewAddressBooks(),
+		Contacts:         NewContacts(),
+		DeletedResources: &DeletedResources{},
+	}
+	f.Events.OverwriteMoveDeleted = f.DeletedResources
+	f.Contacts.OverwriteMoveDeleted = f.DeletedResources
+	f.Store = &store.Store{
+		Calendars:        f.Calendars,
+		Events:           f.Events,
+		AddressBooks:     f.AddressBooks,
+		Contacts:         f.Contacts,
+		DeletedResources: f.DeletedResources,
+	}
+	return f
+}
+
+// AddCalendar makes access reachable by every user and returns it.
+func (f *Fixture) AddCalendar(access store.CalendarAccess) store.CalendarAccess {
+	f.Calendars.Accessible = append(f.Calendars.Accessible, access)
+	cal := access.Calendar
+	f.Calendars.Calendars[cal.ID] = &cal
+	return access
+}
+
+// AddEvent stores ev and returns it as stored.
+func (f *Fixture) AddEvent(ev store.Event) store.Event {
+	if ev.ResourceName == "" {
+		ev.ResourceName = ev.UID
+	}
+	stored := ev
+	f.Events.Events[f.Events.Key(ev.CalendarID, ev.UID)] = &stored
+	return ev
+}
+
+// AddAddressBook stores book and returns it.
+func (f *Fixture) AddAddressBook(book store.AddressBook) store.AddressBook {
+	stored := book
+	f.AddressBooks.Books[book.ID] = &stored
+	return book
+}
+
+// AddContact stores contact and returns it as stored.
+func (f *Fixture) AddContact(contact store.Contact) store.Contact {
+	if contact.ResourceName == "" {
+		contact.ResourceName = contact.UID
+	}
+	stored := contact
+	f.Contacts.Contacts[f.Contacts.Key(contact.AddressBookID, contact.UID)] = &stored
+	return contact
+}
+
+// OwnedCalendar returns the access an owner has to their calendar.
+func OwnedCalendar(id, ownerID int64, name string) store.CalendarAccess {
+	return store.CalendarAccess{
+		Calendar: store.Calendar{ID: id, UserID: ownerID, Name: name, CTag: 1, UpdatedAt: store.Now()},
+		Editor:   true,
+	}
+}
+
+// SharedCalendar returns the access a sharee has to another user's
+// calendar, read-write when editor is set.
+func SharedCalendar(id, ownerID int64, name, ownerEmail string, editor bool) store.CalendarAccess {
+	return store.CalendarAccess{
+		Calendar:   store.Calendar{ID: id, UserID: ownerID, Name: name, CTag: 1, UpdatedAt: store.Now()},
+		OwnerEmail: ownerEmail,
+		Shared:     true,
+		Editor:     editor,
+	}
+}
+
+// Event returns a one-hour event in calendarID starting at start, with a
+// minimal iCalendar body and an ETag derived from it.
+func Event(calendarID int64, uid, summary string, start time.Time) store.Event {
+	start = start.UTC()
+	end := start.Add(time.Hour)
+	raw := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//calcard//storetest//EN\r\nBEGIN:VEVENT\r\n" +
+		"UID:" + uid + "\r\n" +
+		"DTSTAMP:" + start.Format("20060102T150405Z") + "\r\n" +
+		"DTSTART:" + start.Format("20060102T150405Z") + "\r\n" +
+		"DTEND:" + end.Format("20060102T150405Z") + "\r\n" +
+		"SUMMARY:" + summary + "\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	return store.Event{
+		CalendarID:   calendarID,
+		UID:          uid,
+		ResourceName: uid,
+		RawICAL:      raw,
+		ETag:         etag(raw),
+		Summary:      &summary,
+		DTStart:      &start,
+		DTEnd:        &end,
+		LastModified: store.Now(),
+	}
+}
+
+// Contact returns a contact in addressBookID with a minimal vCard 3.0 body
+// and an ETag derived from it.
+func Contact(addressBookID int64, uid, name string) store.Contact {
+	raw := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:" + uid + "\r\nFN:" + name + "\r\nN:" + name + ";;;;\r\nEND:VCARD\r\n"
+	return store.Contact{
+		AddressBookID: addressBookID,
+		UID:           uid,
+		ResourceName:  uid,
+		RawVCard:      raw,
+		ETag:          etag(raw),
+		DisplayName:   &name,
+		LastModified:  store.Now(),
+	}
+}
+
+// etag returns a stable, content-derived ETag; tests compare ETags for
+// equality only.
+func etag(raw string) string {
+	h := fnv.New64a()
+	h.Write([]byte(raw))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
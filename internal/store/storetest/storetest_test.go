@@ -0,0 +1,38 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFixtureScenario(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	f.AddCalendar(OwnedCalendar(1, 7, "Work"))
+	f.AddCalendar(SharedCalendar(2, 8, "Team", "owner@example.com", false))
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	f.AddEvent(Event(1, "standup", "Standup", start))
+	f.AddEvent(Event(2, "standup", "Team standup", start))
+
+	access, err := f.Store.Calendars.GetAccessible(ctx, 2, 7)
+	if err != nil || access == nil || !access.Shared || access.Editor {
+		t.Fatalf("GetAccessible() = %+v, %v, want read-only shared access", access, err)
+	}
+	ev, err := f.Store.Events.GetByResourceName(ctx, 1, "standup")
+	if err != nil || ev == nil || *ev.Summary != "Standup" || ev.ETag == "" {
+		t.Fatalf("GetByResourceName() = %+v, %v", ev, err)
+	}
+	if other := Event(1, "standup", "Moved", start); other.ETag == ev.ETag {
+		t.Fatal("events with different bodies share an ETag")
+	}
+
+	f.AddEvent(Event(1, "other", "Other", start))
+	if err := f.Store.Events.MoveToCalendar(ctx, 1, 2, "other", "standup"); err != nil {
+		t.Fatalf("MoveToCalendar() error = %v", err)
+	}
+	tombstones, _ := f.Store.DeletedResources.ListDeletedSince(ctx, "event", 2, time.Time{})
+	if len(tombstones) != 1 || tombstones[0].UID != "standup" {
+		t.Fatalf("tombstones after an overwriting move = %+v, want the overwritten event", tombstones)
+	}
+}
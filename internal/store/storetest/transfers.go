@@ -0,0 +1,46 @@
+package storetest
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Transfers is an in-memory store.CalendarTransferRepository. Owners maps
+// each calendar it knows to its owner; Done keeps the transfers made.
+type Transfers struct {
+	Owners map[int64]int64
+	Done   []store.CalendarTransfer
+}
+
+func (f *Transfers) Transfer(ctx context.Context, calendarID, toUserID int64, transferredBy string) (*store.CalendarTransfer, error) {
+	fromUserID, ok := f.Owners[calendarID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if fromUserID == toUserID {
+		return nil, store.ErrConflict
+	}
+	f.Owners[calendarID] = toUserID
+	t := store.CalendarTransfer{
+		ID:            int64(len(f.Done) + 1),
+		CalendarID:    calendarID,
+		FromUserID:    fromUserID,
+		ToUserID:      toUserID,
+		TransferredBy: transferredBy,
+		TransferredAt: time.Now(),
+	}
+	f.Done = append(f.Done, t)
+	return &t, nil
+}
+
+func (f *Transfers) ListByCalendar(ctx context.Context, calendarID int64) ([]store.CalendarTransfer, error) {
+	var list []store.CalendarTransfer
+	for _, t := range f.Done {
+		if t.CalendarID == calendarID {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
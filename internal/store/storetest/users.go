@@ -0,0 +1,127 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Users is an in-memory store.UserRepository keyed by ID. Users signing in
+// get IDs counting up from the largest one present.
+type Users struct {
+	Users map[int64]*store.User
+}
+
+// NewUsers returns a Users holding users.
+func NewUsers(users ...store.User) *Users {
+	f := &Users{Users: map[int64]*store.User{}}
+	for _, u := range users {
+		stored := u
+		f.Users[u.ID] = &stored
+	}
+	return f
+}
+
+// UpsertOAuthUser returns the user with subject, creating it if needed. Like
+// the store, a user created for email under store.BootstrapSubject is
+// claimed instead.
+func (f *Users) UpsertOAuthUser(ctx context.Context, subject, email string) (*store.User, error) {
+	var claimable *store.User
+	for _, u := range f.Users {
+		if u.OAuthSubject == subject {
+			u.PrimaryEmail = email
+			u.LastLoginAt = store.Now()
+			copy := *u
+			return &copy, nil
+		}
+		if u.OAuthSubject == store.BootstrapSubject(email) {
+			claimable = u
+		}
+	}
+	if claimable == nil {
+		var id int64
+		for existing := range f.Users {
+			id = max(id, existing)
+		}
+		claimable = &store.User{ID: id + 1, CreatedAt: store.Now()}
+		f.Users[claimable.ID] = claimable
+	}
+	claimable.OAuthSubject = subject
+	claimable.PrimaryEmail = email
+	claimable.LastLoginAt = store.Now()
+	copy := *claimable
+	return &copy, nil
+}
+
+func (f *Users) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if u, ok := f.Users[id]; ok {
+		copy := *u
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+// GetByEmail matches the primary address or an alias, ignoring case.
+func (f *Users) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	var alias *store.User
+	for _, u := range f.Users {
+		if strings.EqualFold(u.PrimaryEmail, email) {
+			copy := *u
+			return &copy, nil
+		}
+		if alias == nil && u.HasEmail(email) {
+			alias = u
+		}
+	}
+	if alias == nil {
+		return nil, nil
+	}
+	copy := *alias
+	return &copy, nil
+}
+
+// ListActive returns the users who have signed in, by primary address,
+// leaving out internal accounts.
+func (f *Users) ListActive(ctx context.Context) ([]store.User, error) {
+	var users []store.User
+	for _, u := range f.Users {
+		if !u.LastLoginAt.IsZero() && !u.Internal {
+			users = append(users, *u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].PrimaryEmail < users[j].PrimaryEmail })
+	return users, nil
+}
+
+func (f *Users) MarkInternal(ctx context.Context, userID int64) error {
+	if u, ok := f.Users[userID]; ok {
+		u.Internal = true
+	}
+	return nil
+}
+
+func (f *Users) MarkOnboardingComplete(ctx context.Context, userID int64) error {
+	if u, ok := f.Users[userID]; ok && u.OnboardingCompletedAt == nil {
+		now := store.Now()
+		u.OnboardingCompletedAt = &now
+	}
+	return nil
+}
+
+func (f *Users) SetSyncHideCancelled(ctx context.Context, userID int64, hide bool) error {
+	if u, ok := f.Users[userID]; ok && u.SyncHideCancelled != hide {
+		now := store.Now()
+		u.SyncHideCancelled, u.SyncPrefsUpdatedAt = hide, &now
+	}
+	return nil
+}
+
+func (f *Users) SetPreferences(ctx context.Context, userID int64, locale, timezone string, weekStart *time.Weekday) error {
+	if u, ok := f.Users[userID]; ok {
+		u.Locale, u.Timezone, u.WeekStart = locale, timezone, weekStart
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package telemetry records anonymous, server-wide usage counts for capacity
+// planning when an admin opts in. Snapshots stay in the server's own
+// database; nothing is sent anywhere.
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/logging"
+	"github.com/jw6ventures/calcard/internal/metrics"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+const (
+	// ActiveWindow is how recently a user must have signed in or synced,
+	// and a device synced, to be counted as active.
+	ActiveWindow = 30 * 24 * time.Hour
+	// Retention is how long snapshots are kept.
+	Retention = 400 * 24 * time.Hour
+)
+
+// Service takes usage snapshots on a schedule.
+type Service struct {
+	store    *store.Store
+	enabled  bool
+	interval time.Duration
+	log      *logging.Logger
+	now      func() time.Time
+}
+
+// New returns the telemetry service configured by cfg.
+func New(cfg *config.Config, st *store.Store, sink logging.Sink) *Service {
+	s := &Service{
+		store:    st,
+		enabled:  cfg.Telemetry.Enabled,
+		interval: cfg.Telemetry.Interval,
+		log:      logging.New(sink, "Telemetry"),
+		now:      time.Now,
+	}
+	if s.interval <= 0 {
+		s.interval = 24 * time.Hour
+	}
+	return s
+}
+
+// Start takes a snapshot at once and then every interval until ctx is
+// cancelled. It returns at once unless telemetry is enabled.
+func (s *Service) Start(ctx context.Context) {
+	if !s.enabled {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Collect(ctx); err != nil {
+			s.log.Error("Start", "failed to record usage snapshot: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect records one snapshot and drops those older than Retention.
+// User-Agents are reduced to client families before anything is stored.
+func (s *Service) Collect(ctx context.Context) (*store.UsageSnapshot, error) {
+	now := s.now().UTC()
+	snapshot, err := s.store.UsageStats.Measure(ctx, now.Add(-ActiveWindow))
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.store.UsageStats.DeviceUserAgents(ctx, now.Add(-ActiveWindow))
+	if err != nil {
+		return nil, err
+	}
+	snapshot.CollectedAt = now
+	snapshot.ClientFamilies = map[string]int64{}
+	for agent, devices := range agents {
+		snapshot.ClientFamilies[metrics.ClientFamily(agent)] += devices
+	}
+	saved, err := s.store.UsageStats.Insert(ctx, *snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.store.UsageStats.DeleteBefore(ctx, now.Add(-Retention)); err != nil {
+		s.log.Warn("Collect", "failed to prune usage snapshots: %v", err)
+	}
+	return saved, nil
+}
@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/config"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+type fakeUsageStats struct {
+	agents       map[string]int64
+	activeSince  time.Time
+	inserted     []store.UsageSnapshot
+	deleteCutoff time.Time
+}
+
+func (f *fakeUsageStats) Measure(ctx context.Context, activeSince time.Time) (*store.UsageSnapshot, error) {
+	f.activeSince = activeSince
+	return &store.UsageSnapshot{Users: 3, ActiveUsers: 2, Calendars: 4, Events: 40}, nil
+}
+
+func (f *fakeUsageStats) DeviceUserAgents(ctx context.Context, since time.Time) (map[string]int64, error) {
+	return f.agents, nil
+}
+
+func (f *fakeUsageStats) Insert(ctx context.Context, snapshot store.UsageSnapshot) (*store.UsageSnapshot, error) {
+	snapshot.ID = int64(len(f.inserted) + 1)
+	f.inserted = append(f.inserted, snapshot)
+	return &snapshot, nil
+}
+
+func (f *fakeUsageStats) List(ctx context.Context, limit int) ([]store.UsageSnapshot, error) {
+	return f.inserted, nil
+}
+
+func (f *fakeUsageStats) DeleteBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	f.deleteCutoff = cutoff
+	return 0, nil
+}
+
+func TestCollectStoresClientFamiliesOnly(t *testing.T) {
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	repo := &fakeUsageStats{agents: map[string]int64{
+		"iOS/17.4 (21E219) dataaccessd/1.0":                                2,
+		"iOS/18.0 (22A3354) dataaccessd/1.0":                               1,
+		"DAVx5/4.3.13-ose (2024/02/13; dav4jvm; okhttp/4.12.0) Android/14": 1,
+		"": 1,
+	}}
+	cfg := &config.Config{}
+	cfg.Telemetry.Enabled = true
+	svc := New(cfg, &store.Store{UsageStats: repo}, nil)
+	svc.now = func() time.Time { return now }
+
+	saved, err := svc.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if saved.ID != 1 || saved.Users != 3 || !saved.CollectedAt.Equal(now) {
+		t.Fatalf("Collect() = %+v", saved)
+	}
+	want := map[string]int64{"ios": 3, "davx5": 1, "unknown": 1}
+	if len(saved.ClientFamilies) != len(want) {
+		t.Fatalf("ClientFamilies = %v, want %v", saved.ClientFamilies, want)
+	}
+	for family, n := range want {
+		if saved.ClientFamilies[family] != n {
+			t.Fatalf("ClientFamilies = %v, want %v", saved.ClientFamilies, want)
+		}
+	}
+	if !repo.activeSince.Equal(now.Add(-ActiveWindow)) || !repo.deleteCutoff.Equal(now.Add(-Retention)) {
+		t.Fatalf("activeSince = %v, deleteCutoff = %v", repo.activeSince, repo.deleteCutoff)
+	}
+	if svc.interval != 24*time.Hour {
+		t.Fatalf("default interval = %v, want 24h", svc.interval)
+	}
+}
+
+func TestStartDoesNothingWhenDisabled(t *testing.T) {
+	repo := &fakeUsageStats{}
+	New(&config.Config{}, &store.Store{UsageStats: repo}, nil).Start(context.Background())
+	if len(repo.inserted) != 0 {
+		t.Fatalf("disabled telemetry recorded %d snapshots", len(repo.inserted))
+	}
+}
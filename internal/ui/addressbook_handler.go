@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/contacts"
 	"github.com/jw6ventures/calcard/internal/store"
 	"github.com/jw6ventures/calcard/internal/ui/utils"
@@ -324,7 +325,7 @@ func (h *Handler) ViewAddressBook(w http.ResponseWriter, r *http.Request) {
 
 // CreateContact creates a new contact in an address book.
 func (h *Handler) CreateContact(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
+	if err := parseContactForm(r); err != nil {
 		http.Error(w, "invalid form", http.StatusBadRequest)
 		return
 	}
@@ -353,8 +354,19 @@ func (h *Handler) CreateContact(w http.ResponseWriter, r *http.Request) {
 	notes := strings.TrimSpace(r.FormValue("notes"))
 	company := strings.TrimSpace(r.FormValue("company"))
 
+	extras, errMsg := contactFormExtras(r, nil)
+	if errMsg != "" {
+		h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"error": errMsg})
+		return
+	}
+
 	uid := utils.GenerateUID()
 	vcard := utils.BuildVCard(uid, displayName, firstName, lastName, email, phone, birthday, notes, company)
+	vcard = utils.AppendVCardLines(vcard, extras...)
+	if err := h.validateContactFields(vcard); err != nil {
+		h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"error": err.Error()})
+		return
+	}
 	etag := utils.GenerateETag(vcard)
 
 	if _, err := h.store.Contacts.Upsert(r.Context(), store.Contact{
@@ -370,6 +382,15 @@ func (h *Handler) CreateContact(w http.ResponseWriter, r *http.Request) {
 	h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"status": "contact_created"})
 }
 
+// validateContactFields rejects invalid TEL, EMAIL and URL values when
+// APP_CONTACT_VALIDATION is strict.
+func (h *Handler) validateContactFields(vcard string) error {
+	if h.cfg == nil || h.cfg.ContactValidation != config.ContactValidationStrict {
+		return nil
+	}
+	return contacts.ValidateStrict(vcard)
+}
+
 // requireEditableAddressBook resolves an address book the current user may
 // modify (owner or editor share). It writes the appropriate error response and
 // returns ok=false when access is denied.
@@ -394,7 +415,7 @@ func (h *Handler) requireEditableAddressBook(w http.ResponseWriter, r *http.Requ
 
 // UpdateContact updates an existing contact.
 func (h *Handler) UpdateContact(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
+	if err := parseContactForm(r); err != nil {
 		http.Error(w, "invalid form", http.StatusBadRequest)
 		return
 	}
@@ -439,7 +460,18 @@ func (h *Handler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	notes := strings.TrimSpace(r.FormValue("notes"))
 	company := strings.TrimSpace(r.FormValue("company"))
 
+	extras, errMsg := contactFormExtras(r, existing)
+	if errMsg != "" {
+		h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"error": errMsg})
+		return
+	}
+
 	vcard := utils.BuildVCard(uid, displayName, firstName, lastName, email, phone, birthday, notes, company)
+	vcard = utils.AppendVCardLines(vcard, extras...)
+	if err := h.validateContactFields(vcard); err != nil {
+		h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"error": err.Error()})
+		return
+	}
 	etag := utils.GenerateETag(vcard)
 
 	if _, err := h.store.Contacts.Upsert(r.Context(), store.Contact{
@@ -455,6 +487,61 @@ func (h *Handler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	h.redirect(w, r, fmt.Sprintf("/addressbooks/%d", bookID), map[string]string{"status": "contact_updated"})
 }
 
+// maxContactPhotoBytes caps uploaded contact photos; they are stored inline in
+// the vCard and synced to every client.
+const maxContactPhotoBytes = 1 << 20
+
+var contactPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// parseContactForm parses urlencoded or multipart contact forms; the latter
+// carry photo uploads.
+func parseContactForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(10 << 20)
+	}
+	return r.ParseForm()
+}
+
+// contactFormExtras returns the CATEGORIES and PHOTO lines for a submitted
+// contact form. Without a new upload the existing card's photo is kept unless
+// remove_photo is set. A non-empty message reports a user-facing form error.
+func contactFormExtras(r *http.Request, existing *store.Contact) ([]string, string) {
+	var lines []string
+	if categories := utils.BuildVCardCategories(splitListField(r.FormValue("categories"))); categories != "" {
+		lines = append(lines, categories)
+	}
+
+	file, _, err := r.FormFile("photo")
+	switch {
+	case err == nil:
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxContactPhotoBytes+1))
+		if err != nil {
+			return nil, "failed to read photo"
+		}
+		if len(data) > maxContactPhotoBytes {
+			return nil, "photo must be 1 MB or smaller"
+		}
+		mediaType := http.DetectContentType(data)
+		if !contactPhotoTypes[mediaType] {
+			return nil, "photo must be a JPEG, PNG, GIF, or WebP image"
+		}
+		lines = append(lines, utils.BuildVCardPhoto(mediaType, data))
+	case err != http.ErrMissingFile && err != http.ErrNotMultipart:
+		return nil, "invalid photo upload"
+	default:
+		if existing != nil && r.FormValue("remove_photo") == "" {
+			lines = append(lines, utils.ExtractVCardPropertyLines(existing.RawVCard, "PHOTO")...)
+		}
+	}
+	return lines, ""
+}
+
 // DeleteContact removes a contact from an address book.
 func (h *Handler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 	bookID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
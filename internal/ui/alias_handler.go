@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// AddEmailAlias claims an alias address for the current user and emails it
+// a confirmation link.
+func (h *Handler) AddEmailAlias(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	alias, err := h.authService.AddEmailAlias(r.Context(), user, r.FormValue("email"))
+	switch {
+	case errors.Is(err, auth.ErrInvalidAlias):
+		h.redirect(w, r, "/app-passwords", map[string]string{"error": err.Error()})
+	case errors.Is(err, auth.ErrAliasTaken), errors.Is(err, auth.ErrAliasEmailDisabled):
+		h.redirect(w, r, "/app-passwords", map[string]string{"error": "alias not added: " + err.Error()})
+	case err != nil:
+		http.Error(w, "failed to add email alias", http.StatusInternalServerError)
+	default:
+		h.redirect(w, r, "/app-passwords", map[string]string{"status": "confirmation email sent to " + alias.Email})
+	}
+}
+
+// VerifyEmailAlias confirms an alias from the link in its confirmation
+// email. The link only works for the user who added the alias.
+func (h *Handler) VerifyEmailAlias(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	alias, err := h.authService.VerifyEmailAlias(r.Context(), user.ID, r.URL.Query().Get("token"))
+	switch {
+	case errors.Is(err, auth.ErrAliasTaken):
+		h.redirect(w, r, "/app-passwords", map[string]string{"error": "alias not confirmed: " + err.Error()})
+	case err != nil:
+		http.Error(w, "failed to confirm email alias", http.StatusInternalServerError)
+	case alias == nil:
+		h.redirect(w, r, "/app-passwords", map[string]string{"error": "this confirmation link is invalid or has expired, or belongs to another account"})
+	default:
+		h.redirect(w, r, "/app-passwords", map[string]string{"status": alias.Email + " confirmed"})
+	}
+}
+
+// DeleteEmailAlias removes one of the current user's aliases.
+func (h *Handler) DeleteEmailAlias(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid alias id", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.EmailAliases.Delete(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to remove email alias", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "alias removed"})
+}
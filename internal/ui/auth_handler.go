@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"net/http"
 	"strconv"
 	"strings"
@@ -8,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/locale"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -132,8 +135,14 @@ func (h *Handler) CreateAppPassword(w http.ResponseWriter, r *http.Request) {
 		expiresAt = &parsed
 	}
 
+	apiRole := r.FormValue("api_role")
+	if apiRole != "" && !auth.ValidAPIRole(apiRole) {
+		http.Error(w, "invalid API role", http.StatusBadRequest)
+		return
+	}
+
 	user, _ := auth.UserFromContext(r.Context())
-	token, _, err := h.authService.CreateAppPassword(r.Context(), user.ID, label, expiresAt)
+	token, _, err := h.authService.CreateAppPassword(r.Context(), user.ID, label, apiRole, expiresAt)
 	if err != nil {
 		http.Error(w, "create failed", http.StatusInternalServerError)
 		return
@@ -142,7 +151,8 @@ func (h *Handler) CreateAppPassword(w http.ResponseWriter, r *http.Request) {
 	h.renderAppPasswords(w, r, user, token)
 }
 
-// RevokeAppPassword revokes an app password.
+// RevokeAppPassword revokes an app password and aborts any requests the
+// device is still making with it.
 func (h *Handler) RevokeAppPassword(w http.ResponseWriter, r *http.Request) {
 	user, _ := auth.UserFromContext(r.Context())
 
@@ -168,6 +178,7 @@ func (h *Handler) RevokeAppPassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	h.authService.DisconnectAppPassword(id)
 
 	http.Redirect(w, r, "/app-passwords", http.StatusFound)
 }
@@ -224,15 +235,25 @@ func (h *Handler) renderAppPasswords(w http.ResponseWriter, r *http.Request, use
 		} else if expired {
 			status = "expired"
 		}
+		lastUserAgent, lastIP := "", ""
+		if p.LastUsedUserAgent != nil {
+			lastUserAgent = *p.LastUsedUserAgent
+		}
+		if p.LastUsedIP != nil {
+			lastIP = *p.LastUsedIP
+		}
 		view = append(view, map[string]any{
-			"id":         p.ID,
-			"label":      p.Label,
-			"created_at": p.CreatedAt,
-			"expires_at": p.ExpiresAt,
-			"last_used":  p.LastUsedAt,
-			"status":     status,
-			"revoked":    revoked,
-			"expired":    expired,
+			"id":              p.ID,
+			"label":           p.Label,
+			"created_at":      p.CreatedAt,
+			"expires_at":      p.ExpiresAt,
+			"last_used":       p.LastUsedAt,
+			"last_user_agent": lastUserAgent,
+			"last_ip":         lastIP,
+			"api_role":        p.APIRole,
+			"status":          status,
+			"revoked":         revoked,
+			"expired":         expired,
 		})
 	}
 	data := h.withFlash(r, map[string]any{
@@ -240,7 +261,44 @@ func (h *Handler) renderAppPasswords(w http.ResponseWriter, r *http.Request, use
 		"User":         user,
 		"AppPasswords": view,
 		"DAVEndpoint":  h.davEndpoint(),
+		"WeekStarts":   weekStartOptions(user),
 	})
+	if h.store.EmailAliases != nil {
+		aliases, err := h.store.EmailAliases.ListByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load email aliases", http.StatusInternalServerError)
+			return
+		}
+		cfg := h.config()
+		data["EmailAliasesEnabled"] = true
+		data["EmailAliases"] = aliases
+		data["EmailAliasMail"] = cfg != nil && cfg.SMTP.Host != ""
+	}
+	if h.store.FreeBusyLinks != nil {
+		link, err := h.store.FreeBusyLinks.GetByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load free/busy link", http.StatusInternalServerError)
+			return
+		}
+		data["FreeBusyEnabled"] = true
+		data["FreeBusyWindowDays"] = defaultFreeBusyWindowDays
+		if link != nil {
+			data["FreeBusyURL"] = h.baseURL() + "/freebusy/" + link.Token + ".ifb"
+			data["FreeBusyWindowDays"] = link.WindowDays
+		}
+	}
+	if h.store.TaskFeedLinks != nil {
+		link, err := h.store.TaskFeedLinks.GetByUser(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "failed to load task feed link", http.StatusInternalServerError)
+			return
+		}
+		data["TaskFeedEnabled"] = true
+		if link != nil {
+			data["TaskFeedICSURL"] = h.baseURL() + "/tasks/" + link.Token + ".ics"
+			data["TaskFeedJSONURL"] = h.baseURL() + "/tasks/" + link.Token + ".json"
+		}
+	}
 	if plaintext != "" {
 		data["PlainToken"] = plaintext
 		data["FlashMessage"] = "created"
@@ -249,17 +307,25 @@ func (h *Handler) renderAppPasswords(w http.ResponseWriter, r *http.Request, use
 }
 
 func (h *Handler) davEndpoint() string {
-	if h.cfg == nil || strings.TrimSpace(h.cfg.BaseURL) == "" {
-		return "/dav"
+	return h.baseURL() + "/dav"
+}
+
+// baseURL is the configured public URL without a trailing slash, or "" to
+// produce host-relative links.
+func (h *Handler) baseURL() string {
+	cfg := h.config()
+	if cfg == nil || strings.TrimSpace(cfg.BaseURL) == "" {
+		return ""
 	}
-	return strings.TrimRight(h.cfg.BaseURL, "/") + "/dav"
+	return strings.TrimRight(cfg.BaseURL, "/")
 }
 
 func (h *Handler) communityURL() string {
-	if h.cfg == nil || strings.TrimSpace(h.cfg.CommunityURL) == "" {
+	cfg := h.config()
+	if cfg == nil || strings.TrimSpace(cfg.CommunityURL) == "" {
 		return "https://github.com/jw6ventures/calcard/issues"
 	}
-	return strings.TrimSpace(h.cfg.CommunityURL)
+	return strings.TrimSpace(cfg.CommunityURL)
 }
 
 // CompleteOnboarding records that the current user has finished (or skipped) the
@@ -278,6 +344,168 @@ func (h *Handler) CompleteOnboarding(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// UpdateSyncPreferences saves the user's CalDAV sync filtering choices
+// from the app passwords page.
+func (h *Handler) UpdateSyncPreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	hide := r.FormValue("hide_cancelled") == "on"
+	if err := h.store.Users.SetSyncHideCancelled(r.Context(), user.ID, hide); err != nil {
+		http.Error(w, "failed to save sync preferences", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "sync preferences saved"})
+}
+
+// weekStartOptions lists the choices for the first day of the week, with
+// the user's own selected; "" follows the locale.
+func weekStartOptions(user *store.User) []map[string]any {
+	options := []map[string]any{{"Value": "", "Label": "From locale", "Selected": user.WeekStart == nil}}
+	for _, d := range []time.Weekday{time.Monday, time.Sunday, time.Saturday} {
+		options = append(options, map[string]any{
+			"Value":    strconv.Itoa(int(d)),
+			"Label":    d.String(),
+			"Selected": user.WeekStart != nil && *user.WeekStart == d,
+		})
+	}
+	return options
+}
+
+// UpdatePreferences saves the user's locale, timezone and first day of the
+// week from the app passwords page. Empty fields fall back to the defaults.
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	var weekStart *time.Weekday
+	if raw := r.FormValue("week_start"); raw != "" {
+		d, err := strconv.Atoi(raw)
+		if err != nil {
+			h.redirect(w, r, "/app-passwords", map[string]string{"error": "invalid week start"})
+			return
+		}
+		day := time.Weekday(d)
+		weekStart = &day
+	}
+	lang, timezone, err := locale.Normalize(r.FormValue("locale"), r.FormValue("timezone"), weekStart)
+	if err != nil {
+		h.redirect(w, r, "/app-passwords", map[string]string{"error": strings.TrimPrefix(err.Error(), locale.ErrInvalid.Error()+": ")})
+		return
+	}
+	if err := h.store.Users.SetPreferences(r.Context(), user.ID, lang, timezone, weekStart); err != nil {
+		http.Error(w, "failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "regional preferences saved"})
+}
+
+const (
+	defaultFreeBusyWindowDays = 60
+	maxFreeBusyWindowDays     = 365
+)
+
+// UpdateFreeBusyLink enables the user's public free/busy URL, or changes its
+// window. A new token is issued on first use and when "regenerate" is set,
+// which invalidates the old URL.
+func (h *Handler) UpdateFreeBusyLink(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	days := defaultFreeBusyWindowDays
+	if raw := strings.TrimSpace(r.FormValue("window_days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxFreeBusyWindowDays {
+			h.redirect(w, r, "/app-passwords", map[string]string{"error": "free/busy window must be between 1 and 365 days"})
+			return
+		}
+		days = v
+	}
+
+	link, err := h.store.FreeBusyLinks.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load free/busy link", http.StatusInternalServerError)
+		return
+	}
+	token := ""
+	if link != nil && r.FormValue("regenerate") == "" {
+		token = link.Token
+	} else if token, err = newLinkToken(); err != nil {
+		http.Error(w, "failed to create free/busy link", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.store.FreeBusyLinks.Upsert(r.Context(), store.FreeBusyLink{UserID: user.ID, Token: token, WindowDays: days}); err != nil {
+		http.Error(w, "failed to save free/busy link", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "free/busy link saved"})
+}
+
+// DeleteFreeBusyLink disables the user's public free/busy URL.
+func (h *Handler) DeleteFreeBusyLink(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	if err := h.store.FreeBusyLinks.Delete(r.Context(), user.ID); err != nil {
+		http.Error(w, "failed to disable free/busy link", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "free/busy link disabled"})
+}
+
+// UpdateTaskFeedLink enables the user's task feed URL. A new token is issued
+// on first use and when "regenerate" is set, which invalidates the old URL.
+func (h *Handler) UpdateTaskFeedLink(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	link, err := h.store.TaskFeedLinks.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load task feed link", http.StatusInternalServerError)
+		return
+	}
+	if link != nil && r.FormValue("regenerate") == "" {
+		h.redirect(w, r, "/app-passwords", map[string]string{"status": "task feed link saved"})
+		return
+	}
+	token, err := newLinkToken()
+	if err != nil {
+		http.Error(w, "failed to create task feed link", http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.store.TaskFeedLinks.Upsert(r.Context(), store.TaskFeedLink{UserID: user.ID, Token: token}); err != nil {
+		http.Error(w, "failed to save task feed link", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "task feed link saved"})
+}
+
+// DeleteTaskFeedLink disables the user's task feed URL.
+func (h *Handler) DeleteTaskFeedLink(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	if err := h.store.TaskFeedLinks.Delete(r.Context(), user.ID); err != nil {
+		http.Error(w, "failed to disable task feed link", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/app-passwords", map[string]string{"status": "task feed link disabled"})
+}
+
+// newLinkToken returns a random token for a link whose URL is its only
+// credential.
+func newLinkToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
 // Logout logs the user out.
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	h.authService.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,325 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/booking"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// BookingPages lists the user's appointment booking pages with a form to add
+// one. Weekdays are listed from the user's first day of the week, and a new
+// page offers their working week.
+func (h *Handler) BookingPages(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	prefs := locale.ForUser(user)
+	pages, err := h.store.BookingPages.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load booking pages", http.StatusInternalServerError)
+		return
+	}
+	calendars, err := h.store.Calendars.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+		return
+	}
+	calendarNames := make(map[int64]string, len(calendars))
+	for _, cal := range calendars {
+		calendarNames[cal.ID] = cal.Name
+	}
+
+	var pageData []map[string]any
+	for _, p := range pages {
+		var days []string
+		for _, d := range prefs.Weekdays() {
+			if p.Weekdays&(1<<d) != 0 {
+				days = append(days, d.String()[:3])
+			}
+		}
+		pageData = append(pageData, map[string]any{
+			"ID":       p.ID,
+			"Title":    p.Title,
+			"URL":      h.baseURL() + "/book/" + p.Slug,
+			"Calendar": calendarNames[p.CalendarID],
+			"Duration": p.DurationMinutes,
+			"Buffer":   p.BufferMinutes,
+			"Hours":    formatMinuteOfDay(p.DayStartMinute) + "–" + formatMinuteOfDay(p.DayEndMinute),
+			"Days":     strings.Join(days, ", "),
+			"Timezone": p.Timezone,
+		})
+	}
+
+	var weekdays []map[string]any
+	working := prefs.WorkingDays()
+	for _, d := range prefs.Weekdays() {
+		weekdays = append(weekdays, map[string]any{"Value": int(d), "Label": d.String(), "Checked": working&(1<<d) != 0})
+	}
+
+	data := h.withFlash(r, map[string]any{
+		"Title":     "Booking Pages",
+		"User":      user,
+		"Pages":     pageData,
+		"Calendars": calendars,
+		"Weekdays":  weekdays,
+	})
+	h.render(w, r, "booking_pages.html", data)
+}
+
+// CreateBookingPage adds a public booking page for one of the user's own calendars.
+func (h *Handler) CreateBookingPage(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	fail := func(msg string) {
+		h.redirect(w, r, "/booking-pages", map[string]string{"error": msg})
+	}
+
+	calendarID, err := strconv.ParseInt(r.FormValue("calendar_id"), 10, 64)
+	if err != nil {
+		fail("choose a calendar")
+		return
+	}
+	cal, err := h.store.Calendars.GetByID(r.Context(), calendarID)
+	if err != nil {
+		http.Error(w, "failed to load calendar", http.StatusInternalServerError)
+		return
+	}
+	if cal == nil || cal.UserID != user.ID {
+		fail("choose one of your own calendars")
+		return
+	}
+
+	page := store.BookingPage{
+		UserID:      user.ID,
+		CalendarID:  calendarID,
+		Slug:        r.FormValue("slug"),
+		Title:       r.FormValue("title"),
+		Description: r.FormValue("description"),
+		Timezone:    r.FormValue("timezone"),
+	}
+	for _, field := range []struct {
+		name string
+		dst  *int
+	}{
+		{"duration_minutes", &page.DurationMinutes},
+		{"buffer_minutes", &page.BufferMinutes},
+		{"notice_minutes", &page.NoticeMinutes},
+		{"window_days", &page.WindowDays},
+	} {
+		if raw := strings.TrimSpace(r.FormValue(field.name)); raw != "" {
+			if *field.dst, err = strconv.Atoi(raw); err != nil {
+				fail("invalid " + strings.ReplaceAll(field.name, "_", " "))
+				return
+			}
+		}
+	}
+	if page.DayStartMinute, err = parseMinuteOfDay(r.FormValue("day_start")); err != nil {
+		fail("invalid start of day")
+		return
+	}
+	if page.DayEndMinute, err = parseMinuteOfDay(r.FormValue("day_end")); err != nil {
+		fail("invalid end of day")
+		return
+	}
+	for _, raw := range r.Form["weekday"] {
+		d, err := strconv.Atoi(raw)
+		if err != nil || d < 0 || d > 6 {
+			fail("invalid weekday")
+			return
+		}
+		page.Weekdays |= 1 << d
+	}
+	if page.Weekdays == 0 {
+		fail("choose at least one weekday")
+		return
+	}
+
+	if err := booking.Normalize(&page); err != nil {
+		fail(strings.TrimPrefix(err.Error(), booking.ErrInvalidPage.Error()+": "))
+		return
+	}
+	if _, err := h.store.BookingPages.Create(r.Context(), page); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			fail("that link name is taken")
+			return
+		}
+		http.Error(w, "failed to create booking page", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/booking-pages", map[string]string{"status": "booking page created"})
+}
+
+// DeleteBookingPage removes a booking page. Appointments already booked stay
+// in the calendar.
+func (h *Handler) DeleteBookingPage(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid booking page id", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.BookingPages.Delete(r.Context(), user.ID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete booking page", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/booking-pages", map[string]string{"status": "booking page deleted"})
+}
+
+// PublicBookingPage serves GET /book/{slug}: the page's open slots for
+// anyone with the link.
+func (h *Handler) PublicBookingPage(w http.ResponseWriter, r *http.Request) {
+	page := h.loadPublicBookingPage(w, r)
+	if page == nil {
+		return
+	}
+	h.renderPublicBookingPage(w, r, page, http.StatusOK, map[string]any{})
+}
+
+// SubmitBooking serves POST /book/{slug}: it books the chosen slot for the
+// visitor and shows the confirmation.
+func (h *Handler) SubmitBooking(w http.ResponseWriter, r *http.Request) {
+	page := h.loadPublicBookingPage(w, r)
+	if page == nil {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	prefs := h.publicBookingPrefs(r, page)
+	form := map[string]any{"Name": r.FormValue("name"), "Email": r.FormValue("email"), "Selected": r.FormValue("start")}
+
+	start, err := time.Parse(time.RFC3339, r.FormValue("start"))
+	if err != nil {
+		form["Error"] = prefs.T("booking.page.chooseTime")
+		h.renderPublicBookingPage(w, r, page, http.StatusBadRequest, form)
+		return
+	}
+	confirmation, err := h.booking.Book(r.Context(), page, start, r.FormValue("name"), r.FormValue("email"))
+	switch {
+	case errors.Is(err, booking.ErrInvalidAttendee):
+		form["Error"] = prefs.T("booking.page.invalid")
+		h.renderPublicBookingPage(w, r, page, http.StatusBadRequest, form)
+		return
+	case errors.Is(err, booking.ErrSlotUnavailable):
+		form["Error"] = prefs.T("booking.page.taken")
+		delete(form, "Selected")
+		h.renderPublicBookingPage(w, r, page, http.StatusConflict, form)
+		return
+	case err != nil:
+		http.Error(w, "failed to book appointment", http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, "booking_public.html", map[string]any{
+		"Prefs":     prefs,
+		"Title":     page.Title,
+		"Page":      page,
+		"Confirmed": true,
+		"When":      prefs.FormatDate(confirmation.Slot.Start) + " " + prefs.FormatTime(confirmation.Slot.Start) + "–" + prefs.FormatTime(confirmation.Slot.End) + " " + confirmation.Slot.End.In(prefs.Location).Format("MST"),
+		"Email":     r.FormValue("email"),
+		"Emailed":   confirmation.Emailed,
+	})
+}
+
+func (h *Handler) loadPublicBookingPage(w http.ResponseWriter, r *http.Request) *store.BookingPage {
+	if h.store.BookingPages == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	page, err := h.store.BookingPages.GetBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "failed to load booking page", http.StatusInternalServerError)
+		return nil
+	}
+	if page == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	return page
+}
+
+func (h *Handler) renderPublicBookingPage(w http.ResponseWriter, r *http.Request, page *store.BookingPage, status int, data map[string]any) {
+	slots, err := h.booking.Slots(r.Context(), page)
+	if err != nil {
+		http.Error(w, "failed to load available times", http.StatusInternalServerError)
+		return
+	}
+
+	type slotOption struct {
+		Value string
+		Label string
+	}
+	type slotDay struct {
+		Label string
+		Slots []slotOption
+	}
+	prefs := h.publicBookingPrefs(r, page)
+	var days []slotDay
+	for _, s := range slots {
+		start := s.Start.In(prefs.Location)
+		label := prefs.FormatMonthDay(start)
+		if len(days) == 0 || days[len(days)-1].Label != label {
+			days = append(days, slotDay{Label: label})
+		}
+		days[len(days)-1].Slots = append(days[len(days)-1].Slots, slotOption{Value: start.Format(time.RFC3339), Label: prefs.FormatTime(start)})
+	}
+
+	for _, key := range []string{"Name", "Email", "Selected", "Error"} {
+		if _, ok := data[key]; !ok {
+			data[key] = ""
+		}
+	}
+	data["Prefs"] = prefs
+	data["Title"] = page.Title
+	data["Page"] = page
+	data["Days"] = days
+	data["Timezone"] = prefs.Location.String()
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(status)
+	h.render(w, r, "booking_public.html", data)
+}
+
+// publicBookingPrefs returns the preferences of the page's owner, which
+// format its times, in the visitor's language when it is translated. A
+// failed owner lookup falls back to the default preferences.
+func (h *Handler) publicBookingPrefs(r *http.Request, page *store.BookingPage) locale.Preferences {
+	owner, err := h.store.Users.GetByID(r.Context(), page.UserID)
+	if err != nil {
+		owner = nil
+	}
+	return booking.Preferences(page, owner).WithAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// parseMinuteOfDay parses an HH:MM time into minutes after midnight;
+// "24:00" is allowed as the end of the day.
+func parseMinuteOfDay(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatMinuteOfDay(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
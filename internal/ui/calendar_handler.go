@@ -15,6 +15,8 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/richtext"
 	"github.com/jw6ventures/calcard/internal/store"
 	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
@@ -43,11 +45,20 @@ func (h *Handler) Calendars(w http.ResponseWriter, r *http.Request) {
 	for _, u := range users {
 		userMap[u.ID] = u
 	}
+	var groups []store.UserGroup
+	if h.store.UserGroups != nil {
+		if groups, err = h.store.UserGroups.ListByOwner(r.Context(), user.ID); err != nil {
+			http.Error(w, "failed to load groups", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	type calendarView struct {
 		Access          store.CalendarAccess
 		Shares          []calendarShareView
 		ShareCandidates []store.User
+		GroupShares     []store.UserGroup
+		GroupCandidates []store.UserGroup
 	}
 
 	var items []calendarView
@@ -74,6 +85,13 @@ func (h *Handler) Calendars(w http.ResponseWriter, r *http.Request) {
 				}
 				cv.ShareCandidates = append(cv.ShareCandidates, candidate)
 			}
+
+			if len(groups) > 0 {
+				if cv.GroupShares, cv.GroupCandidates, err = h.calendarGroupShares(r.Context(), cal.ID, groups); err != nil {
+					http.Error(w, "failed to load shares", http.StatusInternalServerError)
+					return
+				}
+			}
 		}
 		items = append(items, cv)
 	}
@@ -197,7 +215,8 @@ func (h *Handler) DeleteCalendar(w http.ResponseWriter, r *http.Request) {
 	h.redirect(w, r, "/calendars", map[string]string{"status": "deleted"})
 }
 
-// ShareCalendar shares a calendar with another user.
+// ShareCalendar shares a calendar with another user, or with one of the
+// owner's sharing groups when the form names a group_id.
 func (h *Handler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid form"})
@@ -209,6 +228,10 @@ func (h *Handler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
 		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid calendar"})
 		return
 	}
+	if r.FormValue("group_id") != "" {
+		h.shareCalendarWithGroup(w, r, user, calendarID)
+		return
+	}
 	targetID, err := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
 	if err != nil || targetID == 0 {
 		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid user"})
@@ -231,7 +254,7 @@ func (h *Handler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.setCalendarShare(r.Context(), cal.ID, targetUser.ID, true); err != nil {
+	if err := h.setCalendarShare(r.Context(), cal.ID, calendarSharePrincipalHref(targetUser.ID), true); err != nil {
 		h.redirect(w, r, "/calendars", map[string]string{"error": "failed to share"})
 		return
 	}
@@ -239,6 +262,67 @@ func (h *Handler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
 	h.redirect(w, r, "/calendars", map[string]string{"status": "shared"})
 }
 
+func (h *Handler) shareCalendarWithGroup(w http.ResponseWriter, r *http.Request, user *store.User, calendarID int64) {
+	groupID, err := strconv.ParseInt(r.FormValue("group_id"), 10, 64)
+	if err != nil || groupID == 0 {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid group"})
+		return
+	}
+	cal, err := h.store.Calendars.GetByID(r.Context(), calendarID)
+	if err != nil || cal == nil || cal.UserID != user.ID {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "not found"})
+		return
+	}
+	group, err := h.ownedGroup(r.Context(), user, groupID)
+	if err != nil || group == nil {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "group not found"})
+		return
+	}
+	if err := h.setCalendarShare(r.Context(), cal.ID, store.GroupPrincipalHref(group.ID), true); err != nil {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "failed to share"})
+		return
+	}
+	h.redirect(w, r, "/calendars", map[string]string{"status": "shared"})
+}
+
+// UnshareCalendarGroup removes a calendar's share with a sharing group.
+func (h *Handler) UnshareCalendarGroup(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	calendarID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid calendar"})
+		return
+	}
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "groupId"), 10, 64)
+	if err != nil || groupID == 0 {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "invalid group"})
+		return
+	}
+	cal, err := h.store.Calendars.GetByID(r.Context(), calendarID)
+	if err != nil || cal == nil || cal.UserID != user.ID {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "not found"})
+		return
+	}
+	if err := h.removeCalendarShare(r.Context(), cal.ID, store.GroupPrincipalHref(groupID)); err != nil {
+		h.redirect(w, r, "/calendars", map[string]string{"error": "failed to unshare"})
+		return
+	}
+	h.redirect(w, r, "/calendars", map[string]string{"status": "updated"})
+}
+
+// ownedGroup returns the user's sharing group with the given ID, or nil if
+// it does not exist or belongs to someone else.
+func (h *Handler) ownedGroup(ctx context.Context, user *store.User, groupID int64) (*store.UserGroup, error) {
+	if h.store.UserGroups == nil {
+		return nil, nil
+	}
+	group, err := h.store.UserGroups.GetByID(ctx, groupID)
+	if err != nil || group == nil || group.OwnerUserID != user.ID {
+		return nil, err
+	}
+	return group, nil
+}
+
 // UnshareCalendar removes a share or allows a user to leave a shared calendar.
 func (h *Handler) UnshareCalendar(w http.ResponseWriter, r *http.Request) {
 	user, _ := auth.UserFromContext(r.Context())
@@ -261,7 +345,7 @@ func (h *Handler) UnshareCalendar(w http.ResponseWriter, r *http.Request) {
 
 	if calAccess.UserID == user.ID {
 		// Owner removing a share
-		if err := h.removeCalendarShare(r.Context(), calendarID, targetID); err != nil {
+		if err := h.removeCalendarShare(r.Context(), calendarID, calendarSharePrincipalHref(targetID)); err != nil {
 			h.redirect(w, r, "/calendars", map[string]string{"error": "failed to unshare"})
 			return
 		}
@@ -271,7 +355,7 @@ func (h *Handler) UnshareCalendar(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
-		if err := h.removeCalendarShare(r.Context(), calendarID, user.ID); err != nil {
+		if err := h.removeCalendarShare(r.Context(), calendarID, calendarSharePrincipalHref(user.ID)); err != nil {
 			h.redirect(w, r, "/calendars", map[string]string{"error": "failed to leave"})
 			return
 		}
@@ -357,14 +441,13 @@ func calendarShareVisiblePrivilege(privilege string) bool {
 	}
 }
 
-func calendarSharePresetEntries(calendarID, userID int64, editor bool) []store.ACLEntry {
+func calendarSharePresetEntries(calendarID int64, principalHref string, editor bool) []store.ACLEntry {
 	privileges := []string{"read", "read-free-busy"}
 	if editor {
 		privileges = append(privileges, "write")
 	}
 
 	resourcePath := calendarACLResourcePath(calendarID)
-	principalHref := calendarSharePrincipalHref(userID)
 	entries := make([]store.ACLEntry, 0, len(privileges))
 	for _, privilege := range privileges {
 		entries = append(entries, store.ACLEntry{
@@ -404,10 +487,9 @@ func (h *Handler) requireCalendarPrivilege(ctx context.Context, user *store.User
 		return store.ErrNotFound
 	}
 
-	applicablePrincipals := map[string]struct{}{
-		"DAV:all":                           {},
-		"DAV:authenticated":                 {},
-		calendarSharePrincipalHref(user.ID): {},
+	applicablePrincipals := map[string]struct{}{}
+	for _, href := range store.ACLPrincipals(user) {
+		applicablePrincipals[href] = struct{}{}
 	}
 	candidates := calendarACLLookupPaths(resourcePath)
 	candidates = append(candidates, calendarACLResourcePath(cal.ID))
@@ -504,13 +586,38 @@ func (h *Handler) calendarShareViews(ctx context.Context, calendarID int64, user
 	return shares, nil
 }
 
-func (h *Handler) setCalendarShare(ctx context.Context, calendarID, userID int64, editor bool) error {
+// calendarGroupShares splits the owner's groups into those the calendar is
+// shared with and those it is not.
+func (h *Handler) calendarGroupShares(ctx context.Context, calendarID int64, groups []store.UserGroup) ([]store.UserGroup, []store.UserGroup, error) {
+	entries, err := h.store.ACLEntries.ListByResource(ctx, calendarACLResourcePath(calendarID))
+	if err != nil {
+		return nil, nil, err
+	}
+	granted := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsGrant && calendarShareVisiblePrivilege(entry.Privilege) {
+			granted[entry.PrincipalHref] = true
+		}
+	}
+	var shared, candidates []store.UserGroup
+	for _, g := range groups {
+		if granted[store.GroupPrincipalHref(g.ID)] {
+			shared = append(shared, g)
+		} else {
+			candidates = append(candidates, g)
+		}
+	}
+	return shared, candidates, nil
+}
+
+// setCalendarShare grants a user or sharing group access to a calendar,
+// replacing any share the principal already had.
+func (h *Handler) setCalendarShare(ctx context.Context, calendarID int64, principalHref string, editor bool) error {
 	resourcePath := calendarACLResourcePath(calendarID)
 	entries, err := h.store.ACLEntries.ListByResource(ctx, resourcePath)
 	if err != nil {
 		return err
 	}
-	principalHref := calendarSharePrincipalHref(userID)
 	filtered := make([]store.ACLEntry, 0, len(entries))
 	for _, entry := range entries {
 		if entry.PrincipalHref == principalHref && entry.IsGrant && calendarShareManagedPrivilege(entry.Privilege) {
@@ -518,12 +625,12 @@ func (h *Handler) setCalendarShare(ctx context.Context, calendarID, userID int64
 		}
 		filtered = append(filtered, entry)
 	}
-	filtered = append(filtered, calendarSharePresetEntries(calendarID, userID, editor)...)
+	filtered = append(filtered, calendarSharePresetEntries(calendarID, principalHref, editor)...)
 	return h.store.ACLEntries.SetACL(ctx, resourcePath, filtered)
 }
 
-func (h *Handler) removeCalendarShare(ctx context.Context, calendarID, userID int64) error {
-	return h.store.ACLEntries.DeletePrincipalEntriesByResourcePrefix(ctx, calendarSharePrincipalHref(userID), calendarACLResourcePath(calendarID))
+func (h *Handler) removeCalendarShare(ctx context.Context, calendarID int64, principalHref string) error {
+	return h.store.ACLEntries.DeletePrincipalEntriesByResourcePrefix(ctx, principalHref, calendarACLResourcePath(calendarID))
 }
 
 // ViewCalendar displays a calendar and its events.
@@ -563,13 +670,10 @@ func (h *Handler) GetCalendarEventsJSON(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Parse month and year from query params
-	year, _ := strconv.Atoi(r.URL.Query().Get("year"))
-	month, _ := strconv.Atoi(r.URL.Query().Get("month"))
-	if year == 0 || month < 1 || month > 12 {
-		now := time.Now()
-		year = now.Year()
-		month = int(now.Month())
+	rangeStart, rangeEnd, err := eventRangeFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	user, _ := auth.UserFromContext(r.Context())
@@ -595,10 +699,7 @@ func (h *Handler) GetCalendarEventsJSON(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Filter events relevant to the requested month
-	monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	monthEnd := monthStart.AddDate(0, 1, 0).Add(-time.Second)
-	relevantEvents := filterEventsForMonth(allEvents, monthStart, monthEnd)
+	relevantEvents := filterEventsForMonth(allEvents, rangeStart, rangeEnd)
 
 	// Build JSON response
 	eventsJSONData := make([]map[string]any, 0, len(relevantEvents))
@@ -612,6 +713,46 @@ func (h *Handler) GetCalendarEventsJSON(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// maxEventRangeDays bounds explicit start/end queries so a single request
+// cannot force expansion across years of events.
+const maxEventRangeDays = 62
+
+// eventRangeFromQuery resolves the inclusive UTC range for the events.json
+// endpoints. An explicit start/end (YYYY-MM-DD, end inclusive) takes precedence
+// over year/month; missing or invalid month parameters fall back to the current month.
+func eventRangeFromQuery(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	startParam := strings.TrimSpace(q.Get("start"))
+	endParam := strings.TrimSpace(q.Get("end"))
+	if startParam != "" || endParam != "" {
+		start, err := time.Parse("2006-01-02", startParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date")
+		}
+		end, err := time.Parse("2006-01-02", endParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date")
+		}
+		if end.Before(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("end date must not be before start date")
+		}
+		if end.Sub(start) > maxEventRangeDays*24*time.Hour {
+			return time.Time{}, time.Time{}, fmt.Errorf("date range must not exceed %d days", maxEventRangeDays)
+		}
+		return start, end.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+
+	year, _ := strconv.Atoi(q.Get("year"))
+	month, _ := strconv.Atoi(q.Get("month"))
+	if year == 0 || month < 1 || month > 12 {
+		now := time.Now()
+		year = now.Year()
+		month = int(now.Month())
+	}
+	monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	return monthStart, monthStart.AddDate(0, 1, 0).Add(-time.Second), nil
+}
+
 // defaultCalendarColors is a palette used when a calendar has no color set.
 var defaultCalendarColors = []string{
 	"#3b82f6", "#ef4444", "#10b981", "#f59e0b",
@@ -657,14 +798,13 @@ func (h *Handler) ViewAllCalendars(w http.ResponseWriter, r *http.Request) {
 	h.render(w, r, "all_calendars_view.html", data)
 }
 
-// GetAllCalendarEventsJSON returns events across all accessible calendars for a given month.
+// GetAllCalendarEventsJSON returns events across all accessible calendars for
+// a given month, or for the start/end date range used by the week and agenda views.
 func (h *Handler) GetAllCalendarEventsJSON(w http.ResponseWriter, r *http.Request) {
-	year, _ := strconv.Atoi(r.URL.Query().Get("year"))
-	month, _ := strconv.Atoi(r.URL.Query().Get("month"))
-	if year == 0 || month < 1 || month > 12 {
-		now := time.Now()
-		year = now.Year()
-		month = int(now.Month())
+	rangeStart, rangeEnd, err := eventRangeFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	user, _ := auth.UserFromContext(r.Context())
@@ -674,9 +814,6 @@ func (h *Handler) GetAllCalendarEventsJSON(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	monthStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	monthEnd := monthStart.AddDate(0, 1, 0).Add(-time.Second)
-
 	var result = make([]map[string]any, 0)
 	for i, cal := range calendars {
 		allEvents, err := h.store.Events.ListForCalendar(r.Context(), cal.ID)
@@ -690,7 +827,7 @@ func (h *Handler) GetAllCalendarEventsJSON(w http.ResponseWriter, r *http.Reques
 		}
 
 		color := calendarColor(cal.Calendar.Color, i)
-		for _, ev := range filterEventsForMonth(allEvents, monthStart, monthEnd) {
+		for _, ev := range filterEventsForMonth(allEvents, rangeStart, rangeEnd) {
 			payload := calendarEventJSON(ev)
 			payload["calendarId"] = cal.ID
 			payload["calendarName"] = cal.Name
@@ -742,7 +879,7 @@ func (h *Handler) ExportCalendar(w http.ResponseWriter, r *http.Request) {
 	filename := calendarExportFilename(cal.Name)
 	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
-	_, _ = w.Write([]byte(buildCalendarExport(cal.Name, events)))
+	_, _ = w.Write([]byte(buildCalendarExport(cal.Calendar, events)))
 }
 
 func calendarExportFilename(name string) string {
@@ -771,68 +908,23 @@ func calendarExportFilename(name string) string {
 	return base + ".ics"
 }
 
-func buildCalendarExport(name string, events []store.Event) string {
-	var b strings.Builder
-	b.WriteString("BEGIN:VCALENDAR\r\n")
-	b.WriteString("VERSION:2.0\r\n")
-	b.WriteString("PRODID:-//CalCard//Calendar Export//EN\r\n")
-	b.WriteString("CALSCALE:GREGORIAN\r\n")
-	if name = strings.TrimSpace(name); name != "" {
-		b.WriteString("X-WR-CALNAME:")
-		b.WriteString(utils.EscapeICalValue(name))
-		b.WriteString("\r\n")
-	}
+// calendarExportRefreshInterval is advertised to clients that subscribe to
+// an exported calendar by URL.
+const calendarExportRefreshInterval = time.Hour
 
-	seenTimezones := make(map[string]struct{})
+func buildCalendarExport(cal store.Calendar, events []store.Event) string {
+	raw := make([]string, 0, len(events))
 	for _, event := range events {
-		for _, timezone := range rawICalComponents(event.RawICAL, "VTIMEZONE") {
-			if _, ok := seenTimezones[timezone]; ok {
-				continue
-			}
-			seenTimezones[timezone] = struct{}{}
-			b.WriteString(timezone)
-		}
+		raw = append(raw, event.RawICAL)
 	}
-	for _, event := range events {
-		for _, component := range rawICalComponents(event.RawICAL, "VEVENT") {
-			b.WriteString(component)
-		}
+	info := utils.CalendarExportInfo{Name: cal.Name, RefreshInterval: calendarExportRefreshInterval}
+	if cal.Description != nil {
+		info.Description = *cal.Description
 	}
-
-	b.WriteString("END:VCALENDAR\r\n")
-	return b.String()
-}
-
-func rawICalComponents(raw, componentName string) []string {
-	componentName = strings.ToUpper(componentName)
-	var components []string
-	var current []string
-	depth := 0
-
-	for _, line := range utils.UnfoldLines(raw) {
-		upperLine := strings.ToUpper(strings.TrimSpace(line))
-		if depth == 0 {
-			if upperLine == "BEGIN:"+componentName {
-				current = []string{line}
-				depth = 1
-			}
-			continue
-		}
-
-		current = append(current, line)
-		if strings.HasPrefix(upperLine, "BEGIN:") {
-			depth++
-			continue
-		}
-		if strings.HasPrefix(upperLine, "END:") {
-			depth--
-			if depth == 0 {
-				components = append(components, strings.Join(current, "\r\n")+"\r\n")
-				current = nil
-			}
-		}
+	if cal.Color != nil {
+		info.Color = *cal.Color
 	}
-	return components
+	return utils.BuildCalendarExport(info, raw)
 }
 
 // ImportCalendar imports events from an ICS file into an existing calendar.
@@ -888,6 +980,7 @@ func (h *Handler) ImportCalendar(w http.ResponseWriter, r *http.Request) {
 	}
 	pending := make([]pendingImport, 0, len(events))
 	for _, eventICAL := range events {
+		eventICAL = utils.NormalizeExchangeICal(eventICAL)
 		uid := utils.ExtractUID(eventICAL)
 		if uid == "" {
 			uid = utils.GenerateUID()
@@ -999,7 +1092,11 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
-	ical := utils.BuildEvent(uid, summary, dtstart, dtend, allDay, location, description, recurrence, opts)
+	ical, _, err := events.NewService(h.store).ScheduleResources(r.Context(), calendarID, utils.BuildEvent(uid, summary, dtstart, dtend, allDay, location, description, recurrence, opts), "")
+	if err != nil {
+		h.redirect(w, r, fmt.Sprintf("/calendars/%d", calendarID), map[string]string{"error": "failed to book resources"})
+		return
+	}
 	etag := utils.GenerateETag(ical)
 
 	if _, err := h.store.Events.Upsert(r.Context(), store.Event{
@@ -1134,6 +1231,15 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 		}
 		ical = utils.BuildFromComponents(header, components, footer)
 	}
+	previous := ""
+	if existing != nil {
+		previous = existing.RawICAL
+	}
+	ical = utils.CarryAlarmState(previous, ical)
+	if ical, _, err = events.NewService(h.store).ScheduleResources(r.Context(), calendarID, ical, previous); err != nil {
+		h.redirect(w, r, fmt.Sprintf("/calendars/%d", calendarID), map[string]string{"error": "failed to book resources"})
+		return
+	}
 	etag := utils.GenerateETag(ical)
 
 	resourceName := uid
@@ -1274,6 +1380,10 @@ func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
+		if err := events.NewService(h.store).ReleaseResources(r.Context(), calendarID, existing.RawICAL); err != nil {
+			h.redirect(w, r, fmt.Sprintf("/calendars/%d", calendarID), map[string]string{"error": "failed to delete event"})
+			return
+		}
 		if err := h.store.Events.DeleteByUID(r.Context(), calendarID, uid); err != nil {
 			h.redirect(w, r, fmt.Sprintf("/calendars/%d", calendarID), map[string]string{"error": "failed to delete event"})
 			return
@@ -1294,6 +1404,11 @@ func parseEventOptions(r *http.Request) *utils.EventOptions {
 	attendees := splitListField(r.FormValue("attendees"))
 	attachments := splitListField(r.FormValue("attachments"))
 	reminders := parseReminderMinutes(r.Form["reminder_minutes"])
+	descFormat := strings.TrimSpace(r.FormValue("description_format"))
+	descHTML := ""
+	if descFormat == utils.DescriptionHTML {
+		descHTML = r.FormValue("description_html")
+	}
 
 	return &utils.EventOptions{
 		Timezone:     timezone,
@@ -1306,6 +1421,9 @@ func parseEventOptions(r *http.Request) *utils.EventOptions {
 		Attendees:    attendees,
 		Attachments:  attachments,
 		Reminders:    reminders,
+
+		DescriptionFormat: descFormat,
+		DescriptionHTML:   descHTML,
 	}
 }
 
@@ -1518,6 +1636,17 @@ func calendarEventJSON(ev store.Event) map[string]any {
 
 	parsed := parseCalendarEventMetadata(ev)
 	parsed.addToPayload(payload)
+	if link, provider := utils.ExtractJoinLink(ev.RawICAL); link != "" {
+		payload["joinUrl"] = link
+		payload["joinProvider"] = provider
+	}
+	props := utils.ParseEventProperties(ev.RawICAL)
+	if props.Color != "" {
+		payload["color"] = props.Color
+	}
+	if props.Image != "" {
+		payload["image"] = props.Image
+	}
 	return payload
 }
 
@@ -1526,6 +1655,7 @@ type calendarEventMetadata struct {
 	Summary            string
 	Description        string
 	HTMLDescription    string
+	DescriptionFormat  string
 	Location           string
 	DTStart            *time.Time
 	DTEnd              *time.Time
@@ -1542,6 +1672,7 @@ type calendarEventMetadata struct {
 	Transparency       string
 	Organizer          string
 	Attendees          []string
+	Responses          utils.AttendeeSummary
 	Attachments        []string
 	Reminders          []int
 	RecurrenceID       *time.Time
@@ -1561,6 +1692,7 @@ func (m calendarEventMetadata) addToPayload(payload map[string]any) {
 	}
 	if m.HTMLDescription != "" {
 		payload["htmlDescription"] = m.HTMLDescription
+		payload["descriptionFormat"] = m.DescriptionFormat
 	}
 	if m.Location != "" {
 		payload["location"] = m.Location
@@ -1608,6 +1740,14 @@ func (m calendarEventMetadata) addToPayload(payload map[string]any) {
 	if len(m.Attendees) > 0 {
 		payload["attendees"] = m.Attendees
 	}
+	if m.Responses.Total() > 0 {
+		payload["attendeeSummary"] = map[string]int{
+			"accepted":  m.Responses.Accepted,
+			"declined":  m.Responses.Declined,
+			"tentative": m.Responses.Tentative,
+			"pending":   m.Responses.Pending,
+		}
+	}
 	if len(m.Attachments) > 0 {
 		payload["attachments"] = m.Attachments
 	}
@@ -1742,7 +1882,11 @@ func parseCalendarEventComponent(lines []string) calendarEventMetadata {
 			}
 		case "X-ALT-DESC":
 			if strings.EqualFold(params["FMTTYPE"], "text/html") {
-				event.HTMLDescription = value
+				event.HTMLDescription = richtext.Sanitize(unescapeICalText(value))
+				event.DescriptionFormat = utils.DescriptionHTML
+				if strings.EqualFold(params["X-CALCARD-FORMAT"], utils.DescriptionMarkdown) {
+					event.DescriptionFormat = utils.DescriptionMarkdown
+				}
 			}
 		case "LOCATION":
 			event.Location = unescapeICalText(value)
@@ -1782,6 +1926,9 @@ func parseCalendarEventComponent(lines []string) calendarEventMetadata {
 			event.Organizer = formatICalEmailValue(value, params)
 		case "ATTENDEE":
 			event.Attendees = append(event.Attendees, formatICalEmailValue(value, params))
+			if !strings.EqualFold(params["ROLE"], "NON-PARTICIPANT") {
+				event.Responses.Add(params["PARTSTAT"])
+			}
 		case "ATTACH":
 			event.Attachments = append(event.Attachments, value)
 		}
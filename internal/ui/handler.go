@@ -1,15 +1,22 @@
 package ui
 
 import (
+	"context"
+	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/booking"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/contacts"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/mail"
+	"github.com/jw6ventures/calcard/internal/rsvp"
 	"github.com/jw6ventures/calcard/internal/store"
 )
 
@@ -19,7 +26,11 @@ type Handler struct {
 	store       *store.Store
 	authService *auth.Service
 	contacts    *contacts.Service
+	booking     *booking.Service
+	rsvp        *rsvp.Service
 	templates   map[string]*template.Template
+	// live holds the most recently reloaded configuration, if any.
+	live atomic.Pointer[config.Config]
 }
 
 const (
@@ -29,7 +40,26 @@ const (
 
 // NewHandler creates a new Handler instance.
 func NewHandler(cfg *config.Config, store *store.Store, authService *auth.Service) *Handler {
-	return &Handler{cfg: cfg, store: store, authService: authService, contacts: contacts.NewService(store), templates: templates}
+	mailer := mail.New(cfg)
+	links := rsvp.NewService(cfg, store, mailer)
+	h := &Handler{cfg: cfg, store: store, authService: authService, contacts: contacts.NewService(store), booking: booking.NewService(store, mailer, links), rsvp: links, templates: templates}
+	if cfg != nil {
+		h.contacts.SetStrictValidation(cfg.ContactValidation == config.ContactValidationStrict)
+	}
+	return h
+}
+
+// Reconfigure applies reloaded public URLs to the links the UI renders.
+func (h *Handler) Reconfigure(cfg *config.Config) {
+	h.live.Store(cfg)
+}
+
+// config returns the configuration currently in effect.
+func (h *Handler) config() *config.Config {
+	if cfg := h.live.Load(); cfg != nil {
+		return cfg
+	}
+	return h.cfg
 }
 
 // Dashboard displays the main dashboard.
@@ -171,14 +201,22 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	heldGroups, err := h.heldDeletionGroups(r.Context(), user.ID, calendarNames, bookNames)
+	if err != nil {
+		http.Error(w, "failed to load held deletions", http.StatusInternalServerError)
+		return
+	}
+
 	data := h.withFlash(r, map[string]any{
 		"Title":           "Dashboard",
 		"User":            user,
 		"CalendarCount":   len(calendars),
 		"BookCount":       len(books),
 		"AppPwdCount":     len(passwords),
+		"IsAdmin":         h.isAdmin(user),
 		"RecentEvents":    eventData,
 		"RecentContacts":  contactData,
+		"HeldDeletions":   heldGroups,
 		"ShowWelcomeTour": user.OnboardingCompletedAt == nil || r.URL.Query().Get("tour") == "1",
 		"DAVEndpoint":     h.davEndpoint(),
 	})
@@ -196,9 +234,164 @@ func dashboardEventURL(ev store.Event) string {
 	return "/calendars/" + strconv.FormatInt(ev.CalendarID, 10) + "?" + values.Encode()
 }
 
-// ViewBirthdays shows the virtual birthdays calendar.
+// heldDeletionGroup is one client's held deletions from one collection, as
+// shown on the dashboard.
+type heldDeletionGroup struct {
+	Kind           string
+	CollectionName string
+	Client         string
+	IDs            []int64
+	HeldAt         time.Time
+}
+
+func (h *Handler) heldDeletionGroups(ctx context.Context, userID int64, calendarNames, bookNames map[int64]string) ([]heldDeletionGroup, error) {
+	if h.store.HeldDeletions == nil {
+		return nil, nil
+	}
+	held, err := h.store.HeldDeletions.ListByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	type groupKey struct {
+		resourceType string
+		collectionID int64
+		client       string
+	}
+	var groups []heldDeletionGroup
+	index := make(map[groupKey]int)
+	for _, d := range held {
+		key := groupKey{d.ResourceType, d.CollectionID, d.Client}
+		i, ok := index[key]
+		if !ok {
+			g := heldDeletionGroup{Kind: "events", CollectionName: calendarNames[d.CollectionID], Client: d.Client, HeldAt: d.HeldAt}
+			if d.ResourceType == "contact" {
+				g.Kind = "contacts"
+				g.CollectionName = bookNames[d.CollectionID]
+			}
+			if g.Client == "" {
+				g.Client = "Unknown client"
+			}
+			groups = append(groups, g)
+			i = len(groups) - 1
+			index[key] = i
+		}
+		groups[i].IDs = append(groups[i].IDs, d.ID)
+	}
+	return groups, nil
+}
+
+// selectedHeldDeletions returns the caller's held deletions named by the
+// form's id values.
+func (h *Handler) selectedHeldDeletions(r *http.Request, userID int64) ([]store.HeldDeletion, error) {
+	wanted := make(map[int64]bool)
+	for _, raw := range r.Form["id"] {
+		if id, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			wanted[id] = true
+		}
+	}
+	held, err := h.store.HeldDeletions.ListByOwner(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	var selected []store.HeldDeletion
+	for _, d := range held {
+		if wanted[d.ID] {
+			selected = append(selected, d)
+		}
+	}
+	return selected, nil
+}
+
+// ConfirmHeldDeletions applies deletions that mass-deletion protection held
+// back, tombstoning the resources for every device.
+func (h *Handler) ConfirmHeldDeletions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	selected, err := h.selectedHeldDeletions(r, user.ID)
+	if err != nil {
+		http.Error(w, "failed to load held deletions", http.StatusInternalServerError)
+		return
+	}
+	ids := make([]int64, 0, len(selected))
+	for _, d := range selected {
+		if d.ResourceType == "contact" {
+			err = h.store.Contacts.DeleteByUID(r.Context(), d.CollectionID, d.UID)
+		} else {
+			err = h.store.Events.DeleteByUID(r.Context(), d.CollectionID, d.UID)
+		}
+		if err != nil && err != store.ErrNotFound {
+			http.Error(w, "failed to delete", http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, d.ID)
+	}
+	if err := h.store.HeldDeletions.Remove(r.Context(), user.ID, ids); err != nil {
+		http.Error(w, "failed to clear held deletions", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/", map[string]string{"status": fmt.Sprintf("%d held deletions applied", len(ids))})
+}
+
+// ReleaseHeldDeletions discards held deletions and touches the resources so
+// the client that deleted them downloads them again on its next sync.
+func (h *Handler) ReleaseHeldDeletions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	selected, err := h.selectedHeldDeletions(r, user.ID)
+	if err != nil {
+		http.Error(w, "failed to load held deletions", http.StatusInternalServerError)
+		return
+	}
+	ids := make([]int64, 0, len(selected))
+	for _, d := range selected {
+		if d.ResourceType == "contact" {
+			err = h.touchContact(r.Context(), d.CollectionID, d.UID)
+		} else {
+			err = h.touchEvent(r.Context(), d.CollectionID, d.UID)
+		}
+		if err != nil {
+			http.Error(w, "failed to restore", http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, d.ID)
+	}
+	if err := h.store.HeldDeletions.Remove(r.Context(), user.ID, ids); err != nil {
+		http.Error(w, "failed to clear held deletions", http.StatusInternalServerError)
+		return
+	}
+	h.redirect(w, r, "/", map[string]string{"status": fmt.Sprintf("%d items kept and resent to the client", len(ids))})
+}
+
+func (h *Handler) touchEvent(ctx context.Context, calendarID int64, uid string) error {
+	ev, err := h.store.Events.GetByUID(ctx, calendarID, uid)
+	if err != nil || ev == nil {
+		return err
+	}
+	_, err = h.store.Events.Upsert(ctx, *ev)
+	return err
+}
+
+func (h *Handler) touchContact(ctx context.Context, addressBookID int64, uid string) error {
+	c, err := h.store.Contacts.GetByUID(ctx, addressBookID, uid)
+	if err != nil || c == nil {
+		return err
+	}
+	_, err = h.store.Contacts.Upsert(ctx, *c)
+	return err
+}
+
+// ViewBirthdays shows the virtual birthdays calendar. Dates follow the user's
+// timezone, locale and first day of the week, so "today" and the summary of
+// each upcoming birthday match their calendar.
 func (h *Handler) ViewBirthdays(w http.ResponseWriter, r *http.Request) {
 	user, _ := auth.UserFromContext(r.Context())
+	prefs := locale.ForUser(user)
 
 	contacts, err := h.store.Contacts.ListWithBirthdaysByUser(r.Context(), user.ID)
 	if err != nil {
@@ -208,7 +401,9 @@ func (h *Handler) ViewBirthdays(w http.ResponseWriter, r *http.Request) {
 
 	// Generate birthday events
 	var birthdayEvents []map[string]any
-	currentYear := time.Now().Year()
+	now := time.Now().In(prefs.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, prefs.Location)
+	currentYear := today.Year()
 
 	for _, c := range contacts {
 		if c.Birthday == nil {
@@ -222,12 +417,18 @@ func (h *Handler) ViewBirthdays(w http.ResponseWriter, r *http.Request) {
 
 		// Create birthday event for current year
 		bdayThisYear := time.Date(currentYear, c.Birthday.Month(), c.Birthday.Day(), 0, 0, 0, 0, time.UTC)
+		next := time.Date(currentYear, c.Birthday.Month(), c.Birthday.Day(), 0, 0, 0, 0, prefs.Location)
+		if next.Before(today) {
+			next = next.AddDate(1, 0, 0)
+		}
 
 		// Calculate age if birth year is known (year > 1900, since older years are likely placeholders)
 		var age *int
+		summary := fmt.Sprintf("%s's birthday is on %s", displayName, prefs.FormatMonthDay(next))
 		if c.Birthday.Year() > 1900 {
 			a := currentYear - c.Birthday.Year()
 			age = &a
+			summary = fmt.Sprintf("%s turns %d on %s", displayName, next.Year()-c.Birthday.Year(), prefs.FormatMonthDay(next))
 		}
 
 		birthdayEvents = append(birthdayEvents, map[string]any{
@@ -237,6 +438,7 @@ func (h *Handler) ViewBirthdays(w http.ResponseWriter, r *http.Request) {
 			"Age":         age,
 			"Month":       int(c.Birthday.Month()),
 			"Day":         c.Birthday.Day(),
+			"Summary":     summary,
 		})
 	}
 
@@ -244,6 +446,9 @@ func (h *Handler) ViewBirthdays(w http.ResponseWriter, r *http.Request) {
 		"Title":          "Birthdays",
 		"User":           user,
 		"BirthdayEvents": birthdayEvents,
+		"Locale":         prefs.Tag.String(),
+		"WeekStart":      int(prefs.WeekStart),
+		"Today":          today.Format("2006-01-02"),
 	})
 	h.render(w, r, "birthdays.html", data)
 }
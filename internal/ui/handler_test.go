@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"github.com/jw6ventures/calcard/internal/auth"
 	"github.com/jw6ventures/calcard/internal/config"
 	"github.com/jw6ventures/calcard/internal/store"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
 )
 
 func TestViewCalendarHandler(t *testing.T) {
@@ -313,6 +315,8 @@ func TestTemplatesIncludeNewViews(t *testing.T) {
 		"addressbook_view.html",
 		"sessions.html",
 		"birthdays.html",
+		"journal.html",
+		"usage.html",
 	}
 	for _, name := range names {
 		if _, err := templateFS.Open("templates/" + name); err != nil {
@@ -796,6 +800,113 @@ func TestCreateContactHandler(t *testing.T) {
 	}
 }
 
+func TestUpdateContactPhotoAndGroups(t *testing.T) {
+	existingPhoto := "PHOTO;ENCODING=b;TYPE=JPEG:/9j/4AAQ"
+	pngData := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 32)
+
+	testCases := []struct {
+		name        string
+		fields      map[string]string
+		photo       string
+		wantError   string
+		wantLines   []string
+		absentLines []string
+	}{
+		{
+			name:        "keeps existing photo and sets groups",
+			fields:      map[string]string{"categories": "Family, Work"},
+			wantLines:   []string{existingPhoto, "CATEGORIES:Family,Work"},
+			absentLines: []string{"CATEGORIES:Old"},
+		},
+		{
+			name:        "removes photo on request",
+			fields:      map[string]string{"remove_photo": "1"},
+			absentLines: []string{"PHOTO", "CATEGORIES"},
+		},
+		{
+			name:        "replaces photo with upload",
+			photo:       pngData,
+			wantLines:   []string{"PHOTO;ENCODING=b;TYPE=PNG:"},
+			absentLines: []string{existingPhoto},
+		},
+		{
+			name:      "rejects non-image upload",
+			photo:     "hello, not an image",
+			wantError: "photo must be a JPEG, PNG, GIF, or WebP image",
+		},
+		{
+			name:      "rejects oversized upload",
+			photo:     "\xff\xd8\xff" + strings.Repeat("x", maxContactPhotoBytes),
+			wantError: "photo must be 1 MB or smaller",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contactRepo := &fakeContactRepoWithUpsert{
+				fakeContactRepo: fakeContactRepo{contacts: map[string]*store.Contact{
+					"1:contact-1": {AddressBookID: 1, UID: "contact-1", RawVCard: "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:contact-1\r\nFN:Jane\r\nCATEGORIES:Old\r\n" + existingPhoto + "\r\nEND:VCARD\r\n"},
+				}},
+			}
+			handler := NewHandler(&config.Config{}, &store.Store{
+				AddressBooks: &fakeAddressBookRepo{books: map[int64]*store.AddressBook{1: {ID: 1, UserID: 100, Name: "Contacts"}}},
+				Contacts:     contactRepo,
+			}, nil)
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			_ = mw.WriteField("display_name", "Jane")
+			for k, v := range tc.fields {
+				_ = mw.WriteField(k, v)
+			}
+			if tc.photo != "" {
+				fw, err := mw.CreateFormFile("photo", "photo.bin")
+				if err != nil {
+					t.Fatalf("CreateFormFile() error = %v", err)
+				}
+				_, _ = fw.Write([]byte(tc.photo))
+			}
+			_ = mw.Close()
+
+			req := httptest.NewRequest(http.MethodPut, "/addressbooks/1/contacts/contact-1", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			rctx.URLParams.Add("uid", "contact-1")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "owner@example.com"}))
+			w := httptest.NewRecorder()
+
+			handler.UpdateContact(w, req)
+
+			if w.Code != http.StatusFound {
+				t.Fatalf("UpdateContact() status = %d, want %d", w.Code, http.StatusFound)
+			}
+			location := w.Header().Get("Location")
+			if tc.wantError != "" {
+				if !strings.Contains(location, url.Values{"error": {tc.wantError}}.Encode()) {
+					t.Fatalf("expected error %q in redirect, got %q", tc.wantError, location)
+				}
+				return
+			}
+			if !strings.Contains(location, "status=contact_updated") {
+				t.Fatalf("expected success redirect, got %q", location)
+			}
+			saved := strings.Join(utils.UnfoldLines(contactRepo.contacts["1:contact-1"].RawVCard), "\n")
+			for _, want := range tc.wantLines {
+				if !strings.Contains(saved, want) {
+					t.Errorf("saved vCard missing %q:\n%s", want, saved)
+				}
+			}
+			for _, absent := range tc.absentLines {
+				if strings.Contains(saved, absent) {
+					t.Errorf("saved vCard should not contain %q:\n%s", absent, saved)
+				}
+			}
+		})
+	}
+}
+
 func TestViewBirthdaysHandler(t *testing.T) {
 	bday := time.Date(1990, 5, 15, 0, 0, 0, 0, time.UTC)
 	displayName := "John Doe"
@@ -1150,6 +1261,410 @@ func TestCompleteOnboardingMarksUser(t *testing.T) {
 	}
 }
 
+func TestUpdateSyncPreferencesTogglesHideCancelled(t *testing.T) {
+	userRepo := &fakeUserRepo{users: map[int64]*store.User{100: {ID: 100, PrimaryEmail: "user@example.com"}}}
+	handler := NewHandler(&config.Config{}, &store.Store{Users: userRepo}, nil)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sync-preferences", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "user@example.com"}))
+		w := httptest.NewRecorder()
+		handler.UpdateSyncPreferences(w, req)
+		return w
+	}
+
+	w := post(url.Values{"hide_cancelled": {"on"}})
+	if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), "/app-passwords") {
+		t.Fatalf("UpdateSyncPreferences() = %d %q, want redirect to /app-passwords", w.Code, w.Header().Get("Location"))
+	}
+	if user := userRepo.users[100]; !user.SyncHideCancelled || user.SyncPrefsUpdatedAt == nil {
+		t.Fatalf("expected preference enabled with change time, got %+v", user)
+	}
+
+	post(url.Values{})
+	if userRepo.users[100].SyncHideCancelled {
+		t.Fatal("expected unchecked box to disable the preference")
+	}
+}
+
+func TestUpdatePreferencesValidatesAndStores(t *testing.T) {
+	userRepo := &fakeUserRepo{users: map[int64]*store.User{100: {ID: 100, PrimaryEmail: "user@example.com"}}}
+	handler := NewHandler(&config.Config{}, &store.Store{Users: userRepo}, nil)
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/preferences", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100}))
+		w := httptest.NewRecorder()
+		handler.UpdatePreferences(w, req)
+		return w
+	}
+
+	w := post(url.Values{"locale": {"en-gb"}, "timezone": {"Europe/London"}, "week_start": {"0"}})
+	if w.Code != http.StatusFound || !strings.Contains(w.Header().Get("Location"), "status=") {
+		t.Fatalf("UpdatePreferences() = %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if user := userRepo.users[100]; user.Locale != "en-GB" || user.Timezone != "Europe/London" || user.WeekStart == nil || *user.WeekStart != time.Sunday {
+		t.Fatalf("stored %+v", user)
+	}
+
+	w = post(url.Values{"timezone": {"Mars/Olympus"}})
+	if !strings.Contains(w.Header().Get("Location"), "error=") || userRepo.users[100].Timezone != "Europe/London" {
+		t.Fatalf("invalid timezone: %q, stored %q", w.Header().Get("Location"), userRepo.users[100].Timezone)
+	}
+}
+
+type fakeFreeBusyLinkRepo struct {
+	links map[int64]*store.FreeBusyLink
+}
+
+func (f *fakeFreeBusyLinkRepo) GetByUser(ctx context.Context, userID int64) (*store.FreeBusyLink, error) {
+	return f.links[userID], nil
+}
+
+func (f *fakeFreeBusyLinkRepo) GetByToken(ctx context.Context, token string) (*store.FreeBusyLink, error) {
+	return nil, nil
+}
+
+func (f *fakeFreeBusyLinkRepo) Upsert(ctx context.Context, link store.FreeBusyLink) (*store.FreeBusyLink, error) {
+	f.links[link.UserID] = &link
+	return &link, nil
+}
+
+func (f *fakeFreeBusyLinkRepo) Delete(ctx context.Context, userID int64) error {
+	delete(f.links, userID)
+	return nil
+}
+
+func TestFreeBusyLinkEnableUpdateRegenerateAndDisable(t *testing.T) {
+	links := &fakeFreeBusyLinkRepo{links: map[int64]*store.FreeBusyLink{}}
+	handler := NewHandler(&config.Config{}, &store.Store{FreeBusyLinks: links}, nil)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100}))
+		w := httptest.NewRecorder()
+		if path == "/freebusy-link/delete" {
+			handler.DeleteFreeBusyLink(w, req)
+		} else {
+			handler.UpdateFreeBusyLink(w, req)
+		}
+		return w
+	}
+
+	post("/freebusy-link", url.Values{})
+	first := links.links[100]
+	if first == nil || len(first.Token) < 32 || first.WindowDays != 60 {
+		t.Fatalf("expected a new link with the default window, got %+v", first)
+	}
+
+	post("/freebusy-link", url.Values{"window_days": {"14"}})
+	if got := links.links[100]; got.Token != first.Token || got.WindowDays != 14 {
+		t.Fatalf("expected the window updated under the same token, got %+v", got)
+	}
+
+	if w := post("/freebusy-link", url.Values{"window_days": {"400"}}); !strings.Contains(w.Header().Get("Location"), "error=") {
+		t.Fatalf("expected an out-of-range window rejected, got %q", w.Header().Get("Location"))
+	}
+
+	post("/freebusy-link", url.Values{"window_days": {"14"}, "regenerate": {"1"}})
+	if got := links.links[100]; got.Token == first.Token {
+		t.Fatal("expected regenerate to issue a new token")
+	}
+
+	post("/freebusy-link/delete", url.Values{})
+	if links.links[100] != nil {
+		t.Fatal("expected the link disabled")
+	}
+}
+
+type fakeTaskFeedLinkRepo struct {
+	store.TaskFeedLinkRepository
+	links map[int64]*store.TaskFeedLink
+}
+
+func (f *fakeTaskFeedLinkRepo) GetByUser(ctx context.Context, userID int64) (*store.TaskFeedLink, error) {
+	return f.links[userID], nil
+}
+
+func (f *fakeTaskFeedLinkRepo) Upsert(ctx context.Context, link store.TaskFeedLink) (*store.TaskFeedLink, error) {
+	f.links[link.UserID] = &link
+	return &link, nil
+}
+
+func (f *fakeTaskFeedLinkRepo) Delete(ctx context.Context, userID int64) error {
+	delete(f.links, userID)
+	return nil
+}
+
+func TestTaskFeedLinkEnableRegenerateAndDisable(t *testing.T) {
+	links := &fakeTaskFeedLinkRepo{links: map[int64]*store.TaskFeedLink{}}
+	handler := NewHandler(&config.Config{}, &store.Store{TaskFeedLinks: links}, nil)
+
+	post := func(path string, form url.Values) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100}))
+		w := httptest.NewRecorder()
+		if path == "/task-feed-link/delete" {
+			handler.DeleteTaskFeedLink(w, req)
+		} else {
+			handler.UpdateTaskFeedLink(w, req)
+		}
+	}
+
+	post("/task-feed-link", url.Values{})
+	first := links.links[100]
+	if first == nil || len(first.Token) < 32 {
+		t.Fatalf("expected a new link, got %+v", first)
+	}
+
+	post("/task-feed-link", url.Values{})
+	if got := links.links[100]; got.Token != first.Token {
+		t.Fatal("expected the token kept without regenerate")
+	}
+
+	post("/task-feed-link", url.Values{"regenerate": {"1"}})
+	if got := links.links[100]; got.Token == first.Token {
+		t.Fatal("expected regenerate to issue a new token")
+	}
+
+	post("/task-feed-link/delete", url.Values{})
+	if links.links[100] != nil {
+		t.Fatal("expected the link disabled")
+	}
+}
+
+type fakeBookingPageRepo struct {
+	pages []store.BookingPage
+}
+
+func (f *fakeBookingPageRepo) Create(ctx context.Context, page store.BookingPage) (*store.BookingPage, error) {
+	for _, p := range f.pages {
+		if p.Slug == page.Slug {
+			return nil, store.ErrConflict
+		}
+	}
+	page.ID = int64(len(f.pages) + 1)
+	f.pages = append(f.pages, page)
+	return &page, nil
+}
+
+func (f *fakeBookingPageRepo) GetBySlug(ctx context.Context, slug string) (*store.BookingPage, error) {
+	for i := range f.pages {
+		if f.pages[i].Slug == slug {
+			return &f.pages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeBookingPageRepo) ListByUser(ctx context.Context, userID int64) ([]store.BookingPage, error) {
+	return f.pages, nil
+}
+
+func (f *fakeBookingPageRepo) Delete(ctx context.Context, userID, id int64) error {
+	return nil
+}
+
+func TestJournalPageAndCreate(t *testing.T) {
+	events := &fakeEventRepoWithUpsert{fakeEventRepo: fakeEventRepo{events: map[string]*store.Event{}}}
+	st := &store.Store{
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.Calendar{
+			1: {ID: 1, UserID: 100, Name: "Notes"},
+		}},
+		Events: events,
+	}
+	handler := NewHandler(&config.Config{}, st, nil)
+	user := &store.User{ID: 100}
+
+	req := httptest.NewRequest(http.MethodPost, "/journal", strings.NewReader(url.Values{
+		"date": {"2026-03-20"}, "calendar_id": {"1"}, "summary": {"Retro"}, "description": {"Went <well>"},
+	}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.WithUser(req.Context(), user))
+	w := httptest.NewRecorder()
+	handler.CreateJournal(w, req)
+	if loc := w.Header().Get("Location"); strings.Contains(loc, "error=") || !strings.Contains(loc, "date=2026-03-20") {
+		t.Fatalf("expected note added, got %q", loc)
+	}
+
+	page := func(date string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/journal?date="+date, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		handler.Journal(w, req)
+		return w
+	}
+	w = page("2026-03-20")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "Retro") || !strings.Contains(body, "Went &lt;well&gt;") || !strings.Contains(body, "date=2026-03-21") {
+		t.Fatalf("expected the note on its day, got %d %s", w.Code, body)
+	}
+	if w = page("2026-03-21"); strings.Contains(w.Body.String(), "Retro") {
+		t.Fatal("expected the note only on its own day")
+	}
+	if w = page("20-03-2026"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid date rejected, got %d", w.Code)
+	}
+}
+
+func TestBookingPageCreateAndPublicBooking(t *testing.T) {
+	pages := &fakeBookingPageRepo{}
+	events := &fakeEventRepo{events: map[string]*store.Event{}}
+	st := &store.Store{
+		BookingPages: pages,
+		Users:        &fakeUserRepo{users: map[int64]*store.User{100: {ID: 100, PrimaryEmail: "owner@example.com"}}},
+		Calendars: &fakeCalendarRepo{calendars: map[int64]*store.Calendar{
+			1: {ID: 1, UserID: 100, Name: "Work"},
+			2: {ID: 2, UserID: 200, Name: "Someone else's"},
+		}},
+		Events: events,
+	}
+	handler := NewHandler(&config.Config{BaseURL: "https://cal.example.com"}, st, nil)
+
+	create := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/booking-pages", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100}))
+		w := httptest.NewRecorder()
+		handler.CreateBookingPage(w, req)
+		return w
+	}
+	form := url.Values{
+		"title": {"Intro call"}, "slug": {"intro"}, "calendar_id": {"1"}, "timezone": {"UTC"},
+		"duration_minutes": {"30"}, "notice_minutes": {"0"}, "window_days": {"7"},
+		"day_start": {"00:00"}, "day_end": {"24:00"}, "weekday": {"0", "1", "2", "3", "4", "5", "6"},
+	}
+	if w := create(form); strings.Contains(w.Header().Get("Location"), "error=") || len(pages.pages) != 1 {
+		t.Fatalf("expected page created, got %q", w.Header().Get("Location"))
+	}
+	if got := pages.pages[0]; got.Weekdays != 127 || got.DayEndMinute != 24*60 || got.UserID != 100 {
+		t.Fatalf("unexpected page %+v", got)
+	}
+	if w := create(form); !strings.Contains(w.Header().Get("Location"), "taken") {
+		t.Fatalf("expected duplicate link name rejected, got %q", w.Header().Get("Location"))
+	}
+	form.Set("slug", "other")
+	form.Set("calendar_id", "2")
+	if w := create(form); !strings.Contains(w.Header().Get("Location"), "error=") || len(pages.pages) != 1 {
+		t.Fatalf("expected another user's calendar rejected, got %q", w.Header().Get("Location"))
+	}
+
+	routed := func(method, target string, body url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("slug", strings.TrimPrefix(target, "/book/"))
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		if method == http.MethodGet {
+			handler.PublicBookingPage(w, req)
+		} else {
+			handler.SubmitBooking(w, req)
+		}
+		return w
+	}
+
+	w := routed(http.MethodGet, "/book/intro", nil)
+	match := regexp.MustCompile(`name="start" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if w.Code != http.StatusOK || match == nil {
+		t.Fatalf("expected open slots, got %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "owner@example.com") {
+		t.Fatal("public page must not reveal the owner's address")
+	}
+
+	german := httptest.NewRequest(http.MethodGet, "/book/intro", nil)
+	german.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.5")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("slug", "intro")
+	w = httptest.NewRecorder()
+	handler.PublicBookingPage(w, german.WithContext(context.WithValue(german.Context(), chi.RouteCtxKey, rctx)))
+	if body := w.Body.String(); !strings.Contains(body, `<html lang="de">`) || !strings.Contains(body, "30 Minuten · Zeiten in UTC") || !strings.Contains(body, ">Buchen<") {
+		t.Fatalf("expected the page in German, got %s", body)
+	}
+
+	if w := routed(http.MethodPost, "/book/intro", url.Values{"start": {match[1]}, "name": {"Visitor"}, "email": {"bad"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid email rejected, got %d", w.Code)
+	}
+	w = routed(http.MethodPost, "/book/intro", url.Values{"start": {match[1]}, "name": {"Visitor"}, "email": {"visitor@example.com"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "booked for") || len(events.upserted) != 1 {
+		t.Fatalf("expected booking confirmed, got %d %s (upserted %v)", w.Code, w.Body.String(), events.upserted)
+	}
+
+	if w := routed(http.MethodGet, "/book/missing", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown page 404, got %d", w.Code)
+	}
+}
+
+func TestDashboardListsHeldDeletionsByCollectionAndClient(t *testing.T) {
+	st := newDashboardTestStore()
+	st.Calendars = &fakeCalendarRepo{listAccessible: []store.CalendarAccess{{Calendar: store.Calendar{ID: 2, UserID: 100, Name: "Work"}}}}
+	st.HeldDeletions = &fakeHeldDeletionRepo{held: []store.HeldDeletion{
+		{ID: 1, ResourceType: "event", CollectionID: 2, UID: "a", OwnerID: 100, Client: "DAVx5/4.3", HeldAt: time.Now()},
+		{ID: 2, ResourceType: "event", CollectionID: 2, UID: "b", OwnerID: 100, Client: "DAVx5/4.3", HeldAt: time.Now()},
+	}}
+	handler := NewHandler(&config.Config{}, st, nil)
+
+	completed := time.Now()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "user@example.com", OnboardingCompletedAt: &completed}))
+	w := httptest.NewRecorder()
+	handler.Dashboard(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "2 events from <strong>Work</strong> by DAVx5/4.3") {
+		t.Fatalf("expected grouped held deletions, got %d %s", w.Code, body)
+	}
+	if !strings.Contains(body, `action="/held-deletions/release"`) {
+		t.Fatal("expected a form to keep the held items")
+	}
+}
+
+func TestHeldDeletionsConfirmAndRelease(t *testing.T) {
+	eventRepo := &fakeEventRepo{events: map[string]*store.Event{
+		"2:a": {CalendarID: 2, UID: "a"},
+		"2:b": {CalendarID: 2, UID: "b"},
+	}}
+	heldRepo := &fakeHeldDeletionRepo{held: []store.HeldDeletion{
+		{ID: 1, ResourceType: "event", CollectionID: 2, UID: "a", OwnerID: 100},
+		{ID: 2, ResourceType: "event", CollectionID: 2, UID: "b", OwnerID: 100},
+		{ID: 3, ResourceType: "event", CollectionID: 9, UID: "z", OwnerID: 200},
+	}}
+	handler := NewHandler(&config.Config{}, &store.Store{Events: eventRepo, HeldDeletions: heldRepo}, nil)
+
+	post := func(target string, h http.HandlerFunc, ids ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{"id": ids}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "user@example.com"}))
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	// Another owner's hold (3) is ignored.
+	if w := post("/held-deletions/confirm", handler.ConfirmHeldDeletions, "1", "3"); w.Code != http.StatusFound {
+		t.Fatalf("ConfirmHeldDeletions() = %d", w.Code)
+	}
+	if len(eventRepo.deleted) != 1 || eventRepo.deleted[0] != "2:a" {
+		t.Fatalf("expected only event a deleted, got %v", eventRepo.deleted)
+	}
+
+	if w := post("/held-deletions/release", handler.ReleaseHeldDeletions, "2"); w.Code != http.StatusFound {
+		t.Fatalf("ReleaseHeldDeletions() = %d", w.Code)
+	}
+	if len(eventRepo.upserted) != 1 || eventRepo.upserted[0] != "2:b" {
+		t.Fatalf("expected event b touched, got %v", eventRepo.upserted)
+	}
+	if len(eventRepo.deleted) != 1 {
+		t.Fatalf("release must not delete, got %v", eventRepo.deleted)
+	}
+	if fmt.Sprint(heldRepo.removed) != "[1 2]" {
+		t.Fatalf("expected holds 1 and 2 cleared, got %v", heldRepo.removed)
+	}
+}
+
 func TestCreateCalendarPersistsSelectedColor(t *testing.T) {
 	calRepo := &fakeCalendarRepo{calendars: map[int64]*store.Calendar{}}
 	handler := NewHandler(&config.Config{}, &store.Store{Calendars: calRepo}, nil)
@@ -1426,10 +1941,11 @@ func TestCalendarEventJSONReturnsEmptyArrayWhenNoEventsMatch(t *testing.T) {
 }
 
 func TestExportCalendarDownloadsReadableEventsAsICS(t *testing.T) {
+	color := "#3B82F6"
 	handler := NewHandler(&config.Config{}, &store.Store{
 		Calendars: &fakeCalendarRepo{
 			accessible: map[string]*store.CalendarAccess{
-				"1:100": {Calendar: store.Calendar{ID: 1, UserID: 100, Name: "Work Calendar"}, Shared: false, Editor: true},
+				"1:100": {Calendar: store.Calendar{ID: 1, UserID: 100, Name: "Work Calendar", Color: &color}, Shared: false, Editor: true},
 			},
 		},
 		Events: &fakeEventRepo{events: map[string]*store.Event{
@@ -1470,6 +1986,9 @@ func TestExportCalendarDownloadsReadableEventsAsICS(t *testing.T) {
 		"BEGIN:VCALENDAR\r\n",
 		"PRODID:-//CalCard//Calendar Export//EN\r\n",
 		"X-WR-CALNAME:Work Calendar\r\n",
+		"NAME:Work Calendar\r\n",
+		"COLOR:royalblue\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H\r\n",
 		"BEGIN:VTIMEZONE\r\nTZID:America/Chicago\r\nEND:VTIMEZONE\r\n",
 		"BEGIN:VEVENT\r\nUID:event-1\r\nSUMMARY:Planning\r\nDTSTART;TZID=America/Chicago:20260401T090000\r\nEND:VEVENT\r\n",
 		"BEGIN:VEVENT\r\nUID:event-2\r\nSUMMARY:Review\r\nEND:VEVENT\r\n",
@@ -1881,6 +2400,61 @@ func TestAllCalendarEventsJSONReturnsEmptyArrayWhenNoEventsMatch(t *testing.T) {
 	}
 }
 
+func TestAllCalendarEventsJSONDateRange(t *testing.T) {
+	inWeek := time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)
+	nextWeek := time.Date(2026, 4, 8, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantUIDs   []string
+		omitUIDs   []string
+	}{
+		{name: "week spanning months", query: "start=2026-03-29&end=2026-04-04", wantStatus: http.StatusOK, wantUIDs: []string{"in-week"}, omitUIDs: []string{"next-week"}},
+		{name: "end date is inclusive", query: "start=2026-04-01&end=2026-04-08", wantStatus: http.StatusOK, wantUIDs: []string{"next-week"}, omitUIDs: []string{"in-week"}},
+		{name: "invalid start", query: "start=2026-3-29&end=2026-04-04", wantStatus: http.StatusBadRequest},
+		{name: "missing end", query: "start=2026-03-29", wantStatus: http.StatusBadRequest},
+		{name: "end before start", query: "start=2026-04-04&end=2026-03-29", wantStatus: http.StatusBadRequest},
+		{name: "range too long", query: "start=2026-01-01&end=2026-06-01", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(&config.Config{}, &store.Store{
+				Calendars: &fakeCalendarRepo{listAccessible: []store.CalendarAccess{
+					{Calendar: store.Calendar{ID: 1, UserID: 100, Name: "Home"}, Editor: true},
+				}},
+				Events: &fakeEventRepo{events: map[string]*store.Event{
+					"1:in-week":   {CalendarID: 1, UID: "in-week", ResourceName: "in-week", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:in-week\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", DTStart: &inWeek},
+					"1:next-week": {CalendarID: 1, UID: "next-week", ResourceName: "next-week", RawICAL: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:next-week\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", DTStart: &nextWeek},
+				}},
+			}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/calendars/all/events.json?"+tt.query, nil)
+			req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "owner@example.com"}))
+			w := httptest.NewRecorder()
+
+			handler.GetAllCalendarEventsJSON(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GetAllCalendarEventsJSON() status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			body := w.Body.String()
+			for _, uid := range tt.wantUIDs {
+				if !strings.Contains(body, `"uid":"`+uid+`"`) {
+					t.Fatalf("expected %q in response, got %s", uid, body)
+				}
+			}
+			for _, uid := range tt.omitUIDs {
+				if strings.Contains(body, `"uid":"`+uid+`"`) {
+					t.Fatalf("expected %q to be omitted, got %s", uid, body)
+				}
+			}
+		})
+	}
+}
+
 func TestShareCalendarStoresACLEntries(t *testing.T) {
 	calRepo := &fakeCalendarRepo{
 		calendars: map[int64]*store.Calendar{
@@ -2225,6 +2799,56 @@ func TestCalendarShareViewsIncludePrincipalsWithCustomAccess(t *testing.T) {
 	}
 }
 
+type fakeUserGroupRepo struct {
+	store.UserGroupRepository
+	groups map[int64]*store.UserGroup
+}
+
+func (f *fakeUserGroupRepo) GetByID(ctx context.Context, id int64) (*store.UserGroup, error) {
+	if g, ok := f.groups[id]; ok {
+		copy := *g
+		return &copy, nil
+	}
+	return nil, nil
+}
+
+func TestShareCalendarWithGroupGrantsMembers(t *testing.T) {
+	aclRepo := &fakeACLRepo{}
+	h := NewHandler(&config.Config{}, &store.Store{
+		Calendars:  &fakeCalendarRepo{calendars: map[int64]*store.Calendar{1: {ID: 1, UserID: 100, Name: "Family"}}},
+		ACLEntries: aclRepo,
+		UserGroups: &fakeUserGroupRepo{groups: map[int64]*store.UserGroup{
+			7: {ID: 7, OwnerUserID: 100, Name: "family"},
+			8: {ID: 8, OwnerUserID: 300, Name: "theirs"},
+		}},
+	}, nil)
+	share := func(groupID string) {
+		req := httptest.NewRequest(http.MethodPost, "/calendars/1/shares", strings.NewReader(url.Values{"group_id": {groupID}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRouteID(req, "1")
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100}))
+		h.ShareCalendar(httptest.NewRecorder(), req)
+	}
+
+	share("8")
+	if len(aclRepo.entries) != 0 {
+		t.Fatalf("shared with another user's group: %#v", aclRepo.entries)
+	}
+	share("7")
+	if len(aclRepo.entries) == 0 || aclRepo.entries[0].PrincipalHref != "/dav/principals/groups/7/" {
+		t.Fatalf("expected grants to the group principal, got %#v", aclRepo.entries)
+	}
+
+	cal := &store.CalendarAccess{Calendar: store.Calendar{ID: 1, UserID: 100}, Shared: true}
+	member := &store.User{ID: 200, GroupIDs: []int64{7}}
+	if err := h.requireCalendarPrivilege(context.Background(), member, cal, "/dav/calendars/1", "write-content"); err != nil {
+		t.Fatalf("expected group member to get write access, got %v", err)
+	}
+	if err := h.requireCalendarPrivilege(context.Background(), &store.User{ID: 201}, cal, "/dav/calendars/1", "read"); err == nil {
+		t.Fatal("expected non-member to be refused")
+	}
+}
+
 func buildHiddenRecentEventACLs(hiddenCount int) *fakeACLRepo {
 	entries := []store.ACLEntry{
 		{ResourcePath: "/dav/calendars/1", PrincipalHref: "/dav/principals/100/", IsGrant: true, Privilege: "read"},
@@ -2311,6 +2935,18 @@ func (f *fakeCalendarRepo) ListAccessible(ctx context.Context, userID int64) ([]
 	return result, nil
 }
 
+func (f *fakeCalendarRepo) ListAccessiblePage(ctx context.Context, userID int64, limit, offset int) (*store.PaginatedResult[store.CalendarAccess], error) {
+	all, err := f.ListAccessible(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	page := &store.PaginatedResult[store.CalendarAccess]{TotalCount: len(all), Limit: limit, Offset: offset}
+	if offset < len(all) {
+		page.Items = all[offset:min(offset+limit, len(all))]
+	}
+	return page, nil
+}
+
 func (f *fakeCalendarRepo) GetAccessible(ctx context.Context, calendarID, userID int64) (*store.CalendarAccess, error) {
 	if f.accessible != nil {
 		key := fmt.Sprintf("%d:%d", calendarID, userID)
@@ -2377,8 +3013,10 @@ func (f *fakeCalendarRepo) Delete(ctx context.Context, userID, id int64) error {
 }
 
 type fakeEventRepo struct {
-	events map[string]*store.Event
-	recent []store.Event
+	events   map[string]*store.Event
+	recent   []store.Event
+	upserted []string
+	deleted  []string
 }
 
 func (f *fakeEventRepo) key(calendarID int64, uid string) string {
@@ -2386,13 +3024,25 @@ func (f *fakeEventRepo) key(calendarID int64, uid string) string {
 }
 
 func (f *fakeEventRepo) Upsert(ctx context.Context, event store.Event) (*store.Event, error) {
+	f.upserted = append(f.upserted, f.key(event.CalendarID, event.UID))
 	return nil, nil
 }
 
 func (f *fakeEventRepo) DeleteByUID(ctx context.Context, calendarID int64, uid string) error {
+	f.deleted = append(f.deleted, f.key(calendarID, uid))
 	return nil
 }
 
+func (f *fakeEventRepo) DeleteByUIDs(ctx context.Context, calendarID int64, uids []string) (int, error) {
+	deleted := 0
+	for _, uid := range uids {
+		if err := f.DeleteByUID(ctx, calendarID, uid); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
 func (f *fakeEventRepo) GetByUID(ctx context.Context, calendarID int64, uid string) (*store.Event, error) {
 	if ev, ok := f.events[f.key(calendarID, uid)]; ok {
 		copy := *ev
@@ -2418,6 +3068,16 @@ func (f *fakeEventRepo) GetByResourceName(ctx context.Context, calendarID int64,
 	return nil, nil
 }
 
+func (f *fakeEventRepo) OpenByResourceName(ctx context.Context, calendarID int64, resourceName string) (*store.Event, *store.Body, error) {
+	ev, err := f.GetByResourceName(ctx, calendarID, resourceName)
+	if err != nil || ev == nil {
+		return nil, nil, err
+	}
+	body := store.NewBody(ev.RawICAL)
+	ev.RawICAL = ""
+	return ev, body, nil
+}
+
 func (f *fakeEventRepo) ListForCalendar(ctx context.Context, calendarID int64) ([]store.Event, error) {
 	var result []store.Event
 	for _, ev := range f.events {
@@ -2471,6 +3131,12 @@ func (f *fakeEventRepo) MoveToCalendar(ctx context.Context, fromCalendarID, toCa
 func (f *fakeEventRepo) CopyToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uid, destResourceName, newETag string) (*store.Event, error) {
 	return nil, nil
 }
+func (f *fakeEventRepo) MoveAllToCalendar(ctx context.Context, fromCalendarID, toCalendarID int64, uids []string) (int, error) {
+	return 0, nil
+}
+func (f *fakeEventRepo) SplitToNewCalendar(ctx context.Context, cal store.Calendar, fromCalendarID int64, uids []string) (*store.Calendar, int, error) {
+	return nil, 0, nil
+}
 
 type fakeAddressBookRepo struct {
 	books map[int64]*store.AddressBook
@@ -2605,6 +3271,39 @@ func (f *fakeContactRepo) CopyToAddressBook(ctx context.Context, fromAddressBook
 	return nil, nil
 }
 
+func (f *fakeContactRepo) FindByLookupKey(context.Context, []int64, string, string, int) ([]store.Contact, error) {
+	return nil, nil
+}
+
+type fakeHeldDeletionRepo struct {
+	held    []store.HeldDeletion
+	removed []int64
+}
+
+func (f *fakeHeldDeletionRepo) Hold(ctx context.Context, held store.HeldDeletion) error {
+	f.held = append(f.held, held)
+	return nil
+}
+
+func (f *fakeHeldDeletionRepo) IsHeld(ctx context.Context, resourceType string, collectionID int64, uid string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeHeldDeletionRepo) ListByOwner(ctx context.Context, ownerID int64) ([]store.HeldDeletion, error) {
+	var out []store.HeldDeletion
+	for _, h := range f.held {
+		if h.OwnerID == ownerID {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeHeldDeletionRepo) Remove(ctx context.Context, ownerID int64, ids []int64) error {
+	f.removed = append(f.removed, ids...)
+	return nil
+}
+
 type fakeUserRepo struct {
 	users map[int64]*store.User
 }
@@ -2635,7 +3334,7 @@ func (f *fakeAppPasswordRepo) DeleteRevoked(ctx context.Context, id int64) error
 	return nil
 }
 
-func (f *fakeAppPasswordRepo) TouchLastUsed(ctx context.Context, id int64) error {
+func (f *fakeAppPasswordRepo) TouchLastUsed(ctx context.Context, id int64, userAgent, ipAddress string) error {
 	return nil
 }
 
@@ -2669,6 +3368,10 @@ func (f *fakeUserRepo) ListActive(ctx context.Context) ([]store.User, error) {
 	return result, nil
 }
 
+func (f *fakeUserRepo) MarkInternal(ctx context.Context, userID int64) error {
+	return nil
+}
+
 func (f *fakeUserRepo) MarkOnboardingComplete(ctx context.Context, userID int64) error {
 	if user, ok := f.users[userID]; ok {
 		now := time.Now()
@@ -2677,6 +3380,22 @@ func (f *fakeUserRepo) MarkOnboardingComplete(ctx context.Context, userID int64)
 	return nil
 }
 
+func (f *fakeUserRepo) SetSyncHideCancelled(ctx context.Context, userID int64, hide bool) error {
+	if user, ok := f.users[userID]; ok && user.SyncHideCancelled != hide {
+		now := time.Now()
+		user.SyncHideCancelled = hide
+		user.SyncPrefsUpdatedAt = &now
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) SetPreferences(ctx context.Context, userID int64, locale, timezone string, weekStart *time.Weekday) error {
+	if user, ok := f.users[userID]; ok {
+		user.Locale, user.Timezone, user.WeekStart = locale, timezone, weekStart
+	}
+	return nil
+}
+
 type fakeACLRepo struct {
 	entries                                   []store.ACLEntry
 	deletePrincipalEntriesByResourcePrefixErr error
@@ -3215,3 +3934,72 @@ func TestUpdateEventRequiresDates(t *testing.T) {
 		t.Error("event summary should remain unchanged when dates are missing")
 	}
 }
+
+type fakeUsageStatsRepo struct {
+	store.UsageStatsRepository
+	snapshots []store.UsageSnapshot
+}
+
+func (f *fakeUsageStatsRepo) List(ctx context.Context, limit int) ([]store.UsageSnapshot, error) {
+	return f.snapshots, nil
+}
+
+func TestUsagePageIsAdminOnly(t *testing.T) {
+	cfg := &config.Config{AdminEmails: []string{"admin@example.com"}}
+	st := &store.Store{UsageStats: &fakeUsageStatsRepo{snapshots: []store.UsageSnapshot{{
+		CollectedAt: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), Users: 12, ActiveUsers: 7,
+		ClientFamilies: map[string]int64{"ios": 2, "davx5": 5},
+	}}}}
+	handler := NewHandler(cfg, st, nil)
+	page := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/usage", nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 1, PrimaryEmail: email}))
+		w := httptest.NewRecorder()
+		handler.Usage(w, req)
+		return w
+	}
+
+	if w := page("user@example.com"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for non-admins, got %d", w.Code)
+	}
+	w := page("admin@example.com")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "APP_TELEMETRY_ENABLED") || !strings.Contains(body, ">12<") {
+		t.Fatalf("expected the latest counts and a disabled notice, got %d %s", w.Code, body)
+	}
+	if i, j := strings.Index(body, "davx5"), strings.Index(body, "ios"); i < 0 || j < 0 || i > j {
+		t.Fatal("expected client families sorted by device count")
+	}
+}
+
+type fakeEmailAliasRepo struct {
+	store.EmailAliasRepository
+	aliases []store.EmailAlias
+}
+
+func (f *fakeEmailAliasRepo) ListByUser(ctx context.Context, userID int64) ([]store.EmailAlias, error) {
+	return f.aliases, nil
+}
+
+func TestAppPasswordsListsEmailAliases(t *testing.T) {
+	verified := time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)
+	handler := NewHandler(&config.Config{}, &store.Store{
+		AppPasswords: &fakeAppPasswordRepo{},
+		EmailAliases: &fakeEmailAliasRepo{aliases: []store.EmailAlias{
+			{ID: 1, Email: "me@work.example", VerifiedAt: &verified},
+			{ID: 2, Email: "me@home.example"},
+		}},
+	}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/app-passwords", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), &store.User{ID: 100, PrimaryEmail: "user@example.com"}))
+	w := httptest.NewRecorder()
+	handler.AppPasswords(w, req)
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "me@work.example") || !strings.Contains(body, "awaiting confirmation") {
+		t.Fatalf("expected aliases listed, got %d %s", w.Code, body)
+	}
+	if strings.Contains(body, `action="/email-aliases"`) || !strings.Contains(body, "added by an administrator") {
+		t.Fatal("expected no add form without SMTP")
+	}
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// Journal shows one day of journal entries from all the user's calendars,
+// with a form to add a note. The day defaults to today in the user's time
+// zone.
+func (h *Handler) Journal(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	prefs := locale.ForUser(user)
+	day := time.Now().In(prefs.Location)
+	if raw := r.URL.Query().Get("date"); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+	date := day.Format("2006-01-02")
+
+	calendars, err := h.store.Calendars.ListAccessible(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "failed to load calendars", http.StatusInternalServerError)
+		return
+	}
+	svc := events.NewService(h.store)
+	var entries []map[string]any
+	var writable []store.CalendarAccess
+	for _, cal := range calendars {
+		if cal.UserID == user.ID || cal.Editor {
+			writable = append(writable, cal)
+		}
+		journals, err := svc.ListJournals(r.Context(), user, cal.ID, events.JournalFilter{From: date, To: date, Location: prefs.Location})
+		if errors.Is(err, events.ErrNotFound) || errors.Is(err, events.ErrForbidden) {
+			continue
+		}
+		if err != nil {
+			http.Error(w, "failed to load journal", http.StatusInternalServerError)
+			return
+		}
+		for _, j := range journals {
+			entries = append(entries, map[string]any{
+				"Calendar":    cal.Name,
+				"Summary":     j.Summary,
+				"Description": j.Description,
+				"Categories":  strings.Join(j.Categories, ", "),
+				"Status":      j.Status,
+			})
+		}
+	}
+
+	data := h.withFlash(r, map[string]any{
+		"Title":     "Journal",
+		"User":      user,
+		"Date":      date,
+		"DayLabel":  day.Format("Monday, January 2, 2006"),
+		"Prev":      day.AddDate(0, 0, -1).Format("2006-01-02"),
+		"Next":      day.AddDate(0, 0, 1).Format("2006-01-02"),
+		"Entries":   entries,
+		"Calendars": writable,
+	})
+	h.render(w, r, "journal.html", data)
+}
+
+// CreateJournal adds a note for a day to one of the user's calendars.
+func (h *Handler) CreateJournal(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	date := r.FormValue("date")
+	back := map[string]string{"date": date}
+	calendarID, err := strconv.ParseInt(r.FormValue("calendar_id"), 10, 64)
+	if err != nil {
+		back["error"] = "choose a calendar"
+		h.redirect(w, r, "/journal", back)
+		return
+	}
+	_, err = events.NewService(h.store).CreateJournal(r.Context(), user, calendarID, events.JournalInput{
+		Date:        date,
+		Summary:     r.FormValue("summary"),
+		Description: r.FormValue("description"),
+		Categories:  strings.Split(r.FormValue("categories"), ","),
+	})
+	switch {
+	case errors.Is(err, events.ErrBadRequest):
+		back["error"] = strings.TrimPrefix(err.Error(), events.ErrBadRequest.Error()+": ")
+	case errors.Is(err, events.ErrNotFound) || errors.Is(err, events.ErrForbidden):
+		back["error"] = "you cannot add notes to that calendar"
+	case err != nil:
+		http.Error(w, "failed to save note", http.StatusInternalServerError)
+		return
+	default:
+		back["status"] = "Note added"
+	}
+	h.redirect(w, r, "/journal", back)
+}
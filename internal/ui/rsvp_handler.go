@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jw6ventures/calcard/internal/events"
+	"github.com/jw6ventures/calcard/internal/locale"
+	"github.com/jw6ventures/calcard/internal/rsvp"
+	"github.com/jw6ventures/calcard/internal/ui/utils"
+)
+
+// RSVPPage serves GET /rsvp/{token}: the invitation an emailed RSVP link
+// answers, with buttons to accept, decline or answer tentatively, in the
+// language the visitor's browser asks for.
+func (h *Handler) RSVPPage(w http.ResponseWriter, r *http.Request) {
+	inv, err := h.rsvp.Lookup(r.Context(), chi.URLParam(r, "token"))
+	if !h.checkRSVPLookup(w, r, err) {
+		return
+	}
+	h.renderRSVPPage(w, r, inv, false)
+}
+
+// SubmitRSVP serves POST /rsvp/{token}: it records the attendee's answer
+// and lets the organizer know.
+func (h *Handler) SubmitRSVP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	inv, err := h.rsvp.Respond(r.Context(), chi.URLParam(r, "token"), r.FormValue("response"))
+	if errors.Is(err, events.ErrBadRequest) {
+		http.Error(w, "choose accept, tentative or decline", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, events.ErrPreconditionFailed) {
+		http.Error(w, "the event changed while you were answering, please try again", http.StatusConflict)
+		return
+	}
+	if !h.checkRSVPLookup(w, r, err) {
+		return
+	}
+	h.renderRSVPPage(w, r, inv, true)
+}
+
+func (h *Handler) checkRSVPLookup(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, rsvp.ErrInvalidLink):
+		http.Error(w, "this invitation link is invalid or the event no longer exists", http.StatusNotFound)
+		return false
+	case err != nil:
+		http.Error(w, "failed to load invitation", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) renderRSVPPage(w http.ResponseWriter, r *http.Request, inv *rsvp.Invitation, answered bool) {
+	prefs := locale.ForUser(nil).WithAcceptLanguage(r.Header.Get("Accept-Language"))
+	summary := prefs.T("rsvp.page.invitation")
+	if inv.Event.Summary != nil && strings.TrimSpace(*inv.Event.Summary) != "" {
+		summary = *inv.Event.Summary
+	}
+	data := map[string]any{
+		"Prefs":     prefs,
+		"Title":     summary,
+		"Summary":   summary,
+		"Attendee":  inv.Attendee,
+		"Organizer": events.OrganizerAddress(inv.Event.RawICAL),
+		"Partstat":  inv.Partstat,
+		"Answered":  answered,
+		"Notified":  inv.Notified,
+		"Answer":    prefs.T("rsvp.answer." + inv.Partstat),
+		"Current":   "",
+		"When":      "",
+		"Location":  "",
+	}
+	switch inv.Partstat {
+	case "ACCEPTED", "TENTATIVE", "DECLINED":
+		data["Current"] = "rsvp.current." + inv.Partstat
+	}
+	if start := inv.Event.DTStart; start != nil {
+		// Times are shown in the event's own timezone.
+		prefs.Location = start.Location()
+		if inv.Event.AllDay {
+			data["When"] = prefs.FormatDate(*start)
+		} else {
+			data["When"] = prefs.FormatDateTime(*start)
+		}
+	}
+	if inv.Event.Location != nil {
+		data["Location"] = *inv.Event.Location
+	}
+	// The HTML description is sanitized when parsed, so it is trusted here.
+	if alt := utils.ParseAltDescription(inv.Event.RawICAL); alt.HTML != "" {
+		data["DescriptionHTML"] = template.HTML(alt.HTML)
+	} else if inv.Event.Description != nil {
+		data["Description"] = strings.TrimSpace(*inv.Event.Description)
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	h.render(w, r, "rsvp_public.html", data)
+}
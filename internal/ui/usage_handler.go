@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/auth"
+	"github.com/jw6ventures/calcard/internal/store"
+)
+
+// usageHistoryLimit is how many snapshots the usage page lists.
+const usageHistoryLimit = 30
+
+// Usage shows admins the anonymous usage snapshots: the latest counts, the
+// client families of recently syncing devices, and recent history.
+func (h *Handler) Usage(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	if !h.isAdmin(user) {
+		http.NotFound(w, r)
+		return
+	}
+	snapshots, err := h.store.UsageStats.List(r.Context(), usageHistoryLimit)
+	if err != nil {
+		http.Error(w, "failed to load usage stats", http.StatusInternalServerError)
+		return
+	}
+	cfg := h.config()
+	data := h.withFlash(r, map[string]any{
+		"Title":     "Server Usage",
+		"User":      user,
+		"Enabled":   cfg.Telemetry.Enabled,
+		"Interval":  cfg.Telemetry.Interval.String(),
+		"Snapshots": snapshots,
+	})
+	if len(snapshots) > 0 {
+		latest := snapshots[0]
+		type family struct {
+			Name    string
+			Devices int64
+		}
+		var families []family
+		for name, devices := range latest.ClientFamilies {
+			families = append(families, family{name, devices})
+		}
+		sort.Slice(families, func(i, j int) bool {
+			if families[i].Devices != families[j].Devices {
+				return families[i].Devices > families[j].Devices
+			}
+			return families[i].Name < families[j].Name
+		})
+		data["Latest"] = latest
+		data["Families"] = families
+	}
+	h.render(w, r, "usage.html", data)
+}
+
+// isAdmin reports whether user is listed in APP_ADMIN_EMAILS.
+func (h *Handler) isAdmin(user *store.User) bool {
+	cfg := h.config()
+	if user == nil || cfg == nil {
+		return false
+	}
+	for _, email := range cfg.AdminEmails {
+		if strings.EqualFold(email, user.PrimaryEmail) {
+			return true
+		}
+	}
+	return false
+}
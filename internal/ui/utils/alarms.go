@@ -0,0 +1,445 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alarm is a VALARM of an event.
+type Alarm struct {
+	// ID identifies the alarm to AcknowledgeAlarm and SnoozeAlarm: its UID,
+	// or its position among the event's alarms when it has none.
+	ID string
+	// UID is the RFC 9074 alarm UID, if any.
+	UID     string
+	Action  string
+	Trigger string
+	// RelatedEnd is set when a relative Trigger counts from the event's end
+	// (RELATED=END) instead of its start.
+	RelatedEnd bool
+	// Acknowledged is when the alarm was last dismissed (RFC 9074
+	// ACKNOWLEDGED), or zero.
+	Acknowledged time.Time
+	// Snoozes is the UID of the alarm this one snoozes, from RFC 9074
+	// RELATED-TO;RELTYPE=SNOOZE.
+	Snoozes string
+	// Proximity is the RFC 9074 PROXIMITY, such as ARRIVE, of a
+	// location-based alarm.
+	Proximity string
+}
+
+// ParseAlarms returns the VALARMs of the first VEVENT.
+func ParseAlarms(ical string) []Alarm {
+	var alarms []Alarm
+	var current *Alarm
+	depth := 0
+	inEvent := false
+	for _, line := range UnfoldLines(ical) {
+		name, params, value := splitICalProperty(line)
+		value = strings.TrimSpace(value)
+		switch name {
+		case "BEGIN":
+			if !inEvent {
+				if strings.EqualFold(value, "VEVENT") {
+					inEvent = true
+					depth = 1
+				}
+				continue
+			}
+			depth++
+			if depth == 2 && strings.EqualFold(value, "VALARM") {
+				current = &Alarm{}
+			}
+			continue
+		case "END":
+			if !inEvent {
+				continue
+			}
+			depth--
+			if depth == 1 && current != nil {
+				current.ID = current.UID
+				if current.ID == "" {
+					current.ID = strconv.Itoa(len(alarms))
+				}
+				alarms = append(alarms, *current)
+				current = nil
+			}
+			if depth == 0 {
+				return alarms
+			}
+			continue
+		}
+		if current == nil || depth != 2 {
+			continue
+		}
+		switch name {
+		case "UID":
+			current.UID = value
+		case "ACTION":
+			current.Action = strings.ToUpper(value)
+		case "TRIGGER":
+			current.Trigger = value
+			current.RelatedEnd = hasParam(params, "RELATED", "END")
+		case "ACKNOWLEDGED":
+			current.Acknowledged = parseICalUTC(value)
+		case "RELATED-TO":
+			if isSnoozeRelation(params) {
+				current.Snoozes = value
+			}
+		case "PROXIMITY":
+			current.Proximity = strings.ToUpper(value)
+		}
+	}
+	return alarms
+}
+
+// FireTime returns when the alarm fires for an occurrence running from start
+// to end: at its absolute TRIGGER, or its TRIGGER duration from the start or,
+// with RELATED=END, the end. It reports false for a TRIGGER it cannot read.
+func (a Alarm) FireTime(start, end time.Time) (time.Time, bool) {
+	if d, ok := ParseICalDuration(a.Trigger); ok {
+		if a.RelatedEnd {
+			return end.Add(d), true
+		}
+		return start.Add(d), true
+	}
+	if t := parseICalUTC(a.Trigger); !t.IsZero() {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// LastAcknowledged returns the X-MOZ-LASTACK of the first VEVENT: when the
+// user last dismissed any of its alarms, as Thunderbird records it.
+func LastAcknowledged(ical string) time.Time {
+	for _, prop := range eventProperties(ical) {
+		if prop.name == "X-MOZ-LASTACK" {
+			return parseICalUTC(strings.TrimSpace(prop.value))
+		}
+	}
+	return time.Time{}
+}
+
+// AcknowledgeAlarm dismisses the alarm of the first VEVENT with the given
+// ID, as of at. Dismissing a snooze alarm dismisses the alarm it snoozed.
+// Any snooze alarms of the dismissed alarm are removed (RFC 9074 §6). It
+// reports false when the event has no such alarm.
+func AcknowledgeAlarm(ical, id string, at time.Time) (string, bool) {
+	return editAlarm(ical, id, at, time.Time{})
+}
+
+// SnoozeAlarm dismisses the alarm of the first VEVENT with the given ID, as
+// of at, and adds an RFC 9074 snooze alarm that fires at until instead.
+// Snoozing a snooze alarm replaces it. It reports false when the event has
+// no such alarm.
+func SnoozeAlarm(ical, id string, until, at time.Time) (string, bool) {
+	return editAlarm(ical, id, at, until)
+}
+
+func editAlarm(ical, id string, at, until time.Time) (string, bool) {
+	header, events, footer := SplitComponents(ical)
+	if len(events) == 0 {
+		return ical, false
+	}
+	lines := events[0]
+	_, alarms := componentAlarmState(lines)
+	target := -1
+	for i, a := range alarms {
+		if a.uid != "" && a.uid == id {
+			target = i
+			break
+		}
+	}
+	if n, err := strconv.Atoi(id); target < 0 && err == nil && n >= 0 && n < len(alarms) && alarms[n].uid == "" {
+		target = n
+	}
+	if target < 0 {
+		return ical, false
+	}
+	// A snooze alarm stands in for the alarm it snoozes.
+	if snoozed := alarms[target].snoozes; snoozed != "" {
+		for i, a := range alarms {
+			if a.uid == snoozed {
+				target = i
+				break
+			}
+		}
+	}
+	uid := alarms[target].uid
+	if uid == "" {
+		uid = GenerateUID()
+	}
+	stamp := at.UTC().Format("20060102T150405Z")
+
+	var out []string
+	lastAck := "X-MOZ-LASTACK:" + stamp
+	for i, a := range alarms {
+		if i == 0 {
+			out = append(out, topLevelLines(lines[:a.start], "X-MOZ-LASTACK")...)
+			out = append(out, lastAck)
+			lastAck = ""
+		} else {
+			out = append(out, lines[alarms[i-1].end:a.start]...)
+		}
+		switch {
+		case i == target:
+			block := []string{a.lines[0], "UID:" + uid}
+			block = append(block, alarmBody(a.lines, "UID", "ACKNOWLEDGED")...)
+			out = append(out, append(block, "ACKNOWLEDGED:"+stamp, "END:VALARM")...)
+		case a.snoozes != "" && a.snoozes == uid:
+			// Superseded by the acknowledgment or the new snooze.
+		default:
+			out = append(out, a.lines...)
+		}
+	}
+	out = append(out, lines[alarms[len(alarms)-1].end:]...)
+	if !until.IsZero() {
+		snooze := []string{"BEGIN:VALARM", "UID:" + GenerateUID(), "RELATED-TO;RELTYPE=SNOOZE:" + uid}
+		snooze = append(snooze, alarmBody(alarms[target].lines, "UID", "ACKNOWLEDGED", "TRIGGER", "RELATED-TO", "REPEAT", "DURATION", "PROXIMITY")...)
+		out = append(out, append(snooze, "TRIGGER;VALUE=DATE-TIME:"+until.UTC().Format("20060102T150405Z"), "END:VALARM")...)
+	}
+	events[0] = out
+	return BuildFromComponents(header, events, footer), true
+}
+
+// topLevelLines returns lines, which precede any sub-component, without the
+// named properties.
+func topLevelLines(lines []string, drop ...string) []string {
+	var out []string
+	for _, line := range lines {
+		if name, _, _ := splitICalProperty(line); !containsFold(drop, name) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// alarmBody returns the properties of a VALARM's lines, BEGIN and END
+// excluded, without the named ones.
+func alarmBody(lines []string, drop ...string) []string {
+	if len(lines) < 2 {
+		return nil
+	}
+	return topLevelLines(lines[1:len(lines)-1], drop...)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasParam reports whether params sets key to value, ignoring case.
+func hasParam(params []string, key, value string) bool {
+	for _, p := range params {
+		k, v, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) && strings.EqualFold(strings.Trim(v, `"`), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSnoozeRelation(params []string) bool {
+	return hasParam(params, "RELTYPE", "SNOOZE")
+}
+
+// CarryAlarmState copies alarm state from previous onto next, so an edit
+// that rebuilds an event does not re-arm reminders already dismissed on
+// some device. Components are matched by RECURRENCE-ID and alarms by UID,
+// or else by TRIGGER; matched alarms keep their UID and acknowledgment.
+// Snooze and proximity alarms, which the edit cannot express, are kept.
+// Values already present in next win.
+func CarryAlarmState(previous, next string) string {
+	if previous == "" {
+		return next
+	}
+	_, previousEvents, _ := SplitComponents(previous)
+	byRecurrenceID := map[string][]string{}
+	for _, comp := range previousEvents {
+		recurrenceID := RecurrenceIDValue(comp)
+		if _, ok := byRecurrenceID[recurrenceID]; !ok {
+			byRecurrenceID[recurrenceID] = comp
+		}
+	}
+
+	header, events, footer := SplitComponents(next)
+	changed := false
+	for i, comp := range events {
+		prev, ok := byRecurrenceID[RecurrenceIDValue(comp)]
+		if !ok {
+			continue
+		}
+		if merged, ok := carryComponentAlarmState(prev, comp); ok {
+			events[i] = merged
+			changed = true
+		}
+	}
+	if !changed {
+		return next
+	}
+	return BuildFromComponents(header, events, footer)
+}
+
+type alarmState struct {
+	// start and end bound the alarm's lines within its VEVENT.
+	start, end   int
+	lines        []string
+	uid          string
+	trigger      string
+	acknowledged string
+	snoozes      string
+	proximity    bool
+	used         bool
+}
+
+// special reports whether the alarm is one a rebuilt event cannot express.
+func (a *alarmState) special() bool {
+	return a.snoozes != "" || a.proximity
+}
+
+// carryComponentAlarmState merges the alarm state of prev into the lines of
+// one VEVENT, reporting whether anything was added.
+func carryComponentAlarmState(prev, next []string) ([]string, bool) {
+	lastAck, prevAlarms := componentAlarmState(prev)
+	nextLastAck, nextAlarms := componentAlarmState(next)
+	if nextLastAck != "" {
+		lastAck = ""
+	}
+
+	var out []string
+	changed := false
+	uids := map[string]bool{}
+	pos := 0
+	for i, a := range nextAlarms {
+		if i == 0 && lastAck != "" {
+			out = append(out, next[:a.start]...)
+			out = append(out, lastAck)
+			lastAck = ""
+			changed = true
+		} else {
+			out = append(out, next[pos:a.start]...)
+		}
+		pos = a.end
+		block := a.lines
+		if match := matchAlarmState(prevAlarms, a); match != nil {
+			if a.uid == "" && match.uid != "" {
+				block = append([]string{block[0], "UID:" + match.uid}, block[1:]...)
+				a.uid = match.uid
+				changed = true
+			}
+			if a.acknowledged == "" && match.acknowledged != "" {
+				// The full slice expression copies, as block may alias next.
+				block = append(block[:len(block)-1:len(block)-1], match.acknowledged, block[len(block)-1])
+				changed = true
+			}
+		}
+		uids[a.uid] = true
+		out = append(out, block...)
+	}
+	out = append(out, next[pos:]...)
+	if lastAck != "" {
+		out = append(out, lastAck)
+		changed = true
+	}
+	for _, a := range prevAlarms {
+		if a.used || !a.special() || (a.snoozes != "" && !uids[a.snoozes]) {
+			continue
+		}
+		out = append(out, a.lines...)
+		changed = true
+	}
+	return out, changed
+}
+
+// componentAlarmState returns the X-MOZ-LASTACK line and the VALARMs of a
+// VEVENT's lines.
+func componentAlarmState(lines []string) (string, []*alarmState) {
+	lastAck := ""
+	var alarms []*alarmState
+	var current *alarmState
+	depth := 0
+	for i, line := range lines {
+		name, params, value := splitICalProperty(line)
+		switch name {
+		case "BEGIN":
+			depth++
+			if depth == 1 && strings.EqualFold(strings.TrimSpace(value), "VALARM") {
+				current = &alarmState{start: i}
+			}
+			continue
+		case "END":
+			depth--
+			if depth == 0 && current != nil {
+				current.end = i + 1
+				current.lines = lines[current.start:current.end]
+				alarms = append(alarms, current)
+				current = nil
+			}
+			continue
+		}
+		switch {
+		case depth == 0 && name == "X-MOZ-LASTACK":
+			lastAck = line
+		case depth != 1 || current == nil:
+		case name == "UID":
+			current.uid = strings.TrimSpace(value)
+		case name == "TRIGGER":
+			current.trigger = alarmTriggerKey(params, value)
+		case name == "ACKNOWLEDGED":
+			current.acknowledged = line
+		case name == "RELATED-TO" && isSnoozeRelation(params):
+			current.snoozes = strings.TrimSpace(value)
+		case name == "PROXIMITY":
+			current.proximity = true
+		}
+	}
+	return lastAck, alarms
+}
+
+// matchAlarmState finds the unused previous alarm that next corresponds
+// to: the one with its UID or, for ordinary alarms, its TRIGGER.
+func matchAlarmState(alarms []*alarmState, next *alarmState) *alarmState {
+	if next.uid != "" {
+		for _, a := range alarms {
+			if !a.used && a.uid == next.uid {
+				a.used = true
+				return a
+			}
+		}
+	}
+	if next.special() || next.trigger == "" {
+		return nil
+	}
+	for _, a := range alarms {
+		if !a.used && !a.special() && a.trigger == next.trigger {
+			a.used = true
+			return a
+		}
+	}
+	return nil
+}
+
+// alarmTriggerKey normalizes a TRIGGER for matching alarms across edits.
+func alarmTriggerKey(params []string, value string) string {
+	key := strings.ToUpper(strings.TrimSpace(value))
+	for _, p := range params {
+		k, v, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "RELATED") && strings.EqualFold(strings.Trim(v, `"`), "END") {
+			return "END:" + key
+		}
+	}
+	return key
+}
+
+func parseICalUTC(value string) time.Time {
+	t, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlarms(t *testing.T) {
+	ical := exchangeEvent(
+		"X-MOZ-LASTACK:20260301T091500Z",
+		"BEGIN:VALARM",
+		"UID:alarm-1",
+		"ACTION:display",
+		"TRIGGER:-PT15M",
+		"ACKNOWLEDGED:20260301T091500Z",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:AUDIO",
+		"TRIGGER;RELATED=END:PT0S",
+		"END:VALARM",
+	)
+	alarms := ParseAlarms(ical)
+	if len(alarms) != 2 {
+		t.Fatalf("ParseAlarms() = %+v, want 2 alarms", alarms)
+	}
+	acked := time.Date(2026, 3, 1, 9, 15, 0, 0, time.UTC)
+	if a := alarms[0]; a.UID != "alarm-1" || a.Action != "DISPLAY" || a.Trigger != "-PT15M" || !a.Acknowledged.Equal(acked) {
+		t.Fatalf("first alarm = %+v", a)
+	}
+	if a := alarms[1]; a.UID != "" || a.Trigger != "PT0S" || !a.Acknowledged.IsZero() {
+		t.Fatalf("second alarm = %+v", a)
+	}
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	if got, ok := alarms[0].FireTime(start, end); !ok || !got.Equal(start.Add(-15*time.Minute)) {
+		t.Fatalf("first alarm FireTime() = %v, %v", got, ok)
+	}
+	if got, ok := alarms[1].FireTime(start, end); !ok || !got.Equal(end) {
+		t.Fatalf("RELATED=END alarm FireTime() = %v, %v", got, ok)
+	}
+	absolute := Alarm{Trigger: "20260301T083000Z"}
+	if got, ok := absolute.FireTime(start, end); !ok || !got.Equal(start.Add(-30*time.Minute)) {
+		t.Fatalf("absolute alarm FireTime() = %v, %v", got, ok)
+	}
+	if got := LastAcknowledged(ical); !got.Equal(acked) {
+		t.Fatalf("LastAcknowledged() = %v, want %v", got, acked)
+	}
+	if got := LastAcknowledged(exchangeEvent()); !got.IsZero() {
+		t.Fatalf("LastAcknowledged() without X-MOZ-LASTACK = %v", got)
+	}
+}
+
+func TestCarryAlarmState(t *testing.T) {
+	previous := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nSUMMARY:Old\r\nX-MOZ-LASTACK:20260301T091500Z\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nACKNOWLEDGED:20260301T091500Z\r\nEND:VALARM\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT1H\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nRECURRENCE-ID:20260308T090000Z\r\n" +
+		"BEGIN:VALARM\r\nUID:a2\r\nACTION:DISPLAY\r\nTRIGGER:-PT5M\r\nACKNOWLEDGED:20260308T085500Z\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	next := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nSUMMARY:New\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT1H\r\nEND:VALARM\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nRECURRENCE-ID:20260308T090000Z\r\n" +
+		"BEGIN:VALARM\r\nUID:a2\r\nACTION:DISPLAY\r\nTRIGGER:-PT10M\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	got := CarryAlarmState(previous, next)
+	want := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nSUMMARY:New\r\nX-MOZ-LASTACK:20260301T091500Z\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT1H\r\nEND:VALARM\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nACKNOWLEDGED:20260301T091500Z\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:e1\r\nRECURRENCE-ID:20260308T090000Z\r\n" +
+		"BEGIN:VALARM\r\nUID:a2\r\nACTION:DISPLAY\r\nTRIGGER:-PT10M\r\nACKNOWLEDGED:20260308T085500Z\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\nEND:VCALENDAR\r\n"
+	if got != want {
+		t.Fatalf("CarryAlarmState() =\n%s\nwant\n%s", got, want)
+	}
+
+	// A newer acknowledgment in the edit is kept, and events without
+	// acknowledged alarms pass through untouched.
+	newer := strings.Replace(next, "TRIGGER:-PT15M\r\n", "TRIGGER:-PT15M\r\nACKNOWLEDGED:20260302T091500Z\r\n", 1)
+	if got := CarryAlarmState(previous, newer); strings.Contains(got, "ACKNOWLEDGED:20260301T091500Z") {
+		t.Fatalf("expected the newer acknowledgment to win, got\n%s", got)
+	}
+	plain := exchangeEvent("BEGIN:VALARM", "ACTION:DISPLAY", "TRIGGER:-PT15M", "END:VALARM")
+	if got := CarryAlarmState(plain, plain); got != plain {
+		t.Fatalf("expected an unchanged event, got\n%s", got)
+	}
+}
+
+func TestSnoozeAndAcknowledgeAlarm(t *testing.T) {
+	ical := exchangeEvent(
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Stand-up",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"UID:arrive",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:At the office",
+		"TRIGGER;VALUE=DATE-TIME:19760401T005545Z",
+		"PROXIMITY:ARRIVE",
+		"END:VALARM",
+	)
+	at := time.Date(2026, 3, 1, 8, 45, 0, 0, time.UTC)
+	until := at.Add(10 * time.Minute)
+
+	if _, ok := SnoozeAlarm(ical, "missing", until, at); ok {
+		t.Fatal("expected an unknown alarm to be reported")
+	}
+	snoozed, ok := SnoozeAlarm(ical, "0", until, at)
+	if !ok {
+		t.Fatal("expected the first alarm to be snoozed")
+	}
+	alarms := ParseAlarms(snoozed)
+	if len(alarms) != 3 {
+		t.Fatalf("ParseAlarms() after snooze = %+v, want 3 alarms", alarms)
+	}
+	original, proximity, snooze := alarms[0], alarms[1], alarms[2]
+	if original.UID == "" || original.ID != original.UID || !original.Acknowledged.Equal(at) {
+		t.Fatalf("snoozed alarm = %+v", original)
+	}
+	if proximity.Proximity != "ARRIVE" || !proximity.Acknowledged.IsZero() {
+		t.Fatalf("proximity alarm = %+v", proximity)
+	}
+	if snooze.Snoozes != original.UID || snooze.Trigger != "20260301T085500Z" || snooze.Action != "DISPLAY" {
+		t.Fatalf("snooze alarm = %+v", snooze)
+	}
+	if !strings.Contains(snoozed, "DESCRIPTION:Stand-up\r\nTRIGGER;VALUE=DATE-TIME:20260301T085500Z") || !LastAcknowledged(snoozed).Equal(at) {
+		t.Fatalf("unexpected snoozed event:\n%s", snoozed)
+	}
+
+	// Snoozing again replaces the snooze alarm.
+	later := at.Add(10 * time.Minute)
+	resnoozed, ok := SnoozeAlarm(snoozed, snooze.ID, later.Add(5*time.Minute), later)
+	if alarms := ParseAlarms(resnoozed); !ok || len(alarms) != 3 || alarms[2].Trigger != "20260301T090000Z" || !alarms[0].Acknowledged.Equal(later) {
+		t.Fatalf("unexpected re-snoozed alarms: %+v", alarms)
+	}
+
+	// Dismissing the snooze alarm dismisses the original and drops it.
+	dismissed, ok := AcknowledgeAlarm(resnoozed, ParseAlarms(resnoozed)[2].ID, later)
+	if alarms := ParseAlarms(dismissed); !ok || len(alarms) != 2 || alarms[0].UID != original.UID || !alarms[0].Acknowledged.Equal(later) {
+		t.Fatalf("unexpected alarms after dismissal: %+v", alarms)
+	}
+}
+
+func TestCarryAlarmStateKeepsSnoozeAndProximityAlarms(t *testing.T) {
+	previous := exchangeEvent(
+		"BEGIN:VALARM", "UID:a1", "ACTION:DISPLAY", "TRIGGER:-PT15M", "ACKNOWLEDGED:20260301T084500Z", "END:VALARM",
+		"BEGIN:VALARM", "UID:s1", "RELATED-TO;RELTYPE=SNOOZE:a1", "ACTION:DISPLAY", "TRIGGER;VALUE=DATE-TIME:20260301T085500Z", "END:VALARM",
+		"BEGIN:VALARM", "UID:p1", "ACTION:DISPLAY", "TRIGGER;VALUE=DATE-TIME:19760401T005545Z", "PROXIMITY:DEPART", "END:VALARM",
+	)
+	rebuilt := exchangeEvent("SUMMARY:Edited", "BEGIN:VALARM", "ACTION:DISPLAY", "TRIGGER:-PT15M", "END:VALARM")
+	alarms := ParseAlarms(CarryAlarmState(previous, rebuilt))
+	if len(alarms) != 3 || alarms[0].UID != "a1" || alarms[0].Acknowledged.IsZero() || alarms[1].Snoozes != "a1" || alarms[2].Proximity != "DEPART" {
+		t.Fatalf("unexpected carried alarms: %+v", alarms)
+	}
+
+	// A snooze alarm is dropped with the alarm it snoozed.
+	rebuilt = exchangeEvent("SUMMARY:Edited", "BEGIN:VALARM", "ACTION:DISPLAY", "TRIGGER:-PT1H", "END:VALARM")
+	alarms = ParseAlarms(CarryAlarmState(previous, rebuilt))
+	if len(alarms) != 2 || alarms[0].UID != "" || alarms[1].Proximity != "DEPART" {
+		t.Fatalf("unexpected carried alarms: %+v", alarms)
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/richtext"
+)
+
+// Description formats. Plain text is stored in DESCRIPTION alone; Markdown
+// keeps its source in DESCRIPTION and the rendered HTML in X-ALT-DESC; HTML
+// from a client keeps a plain text rendering in DESCRIPTION.
+const (
+	DescriptionText     = "text"
+	DescriptionMarkdown = "markdown"
+	DescriptionHTML     = "html"
+)
+
+// markdownFormatParam marks an X-ALT-DESC rendered from Markdown, so the
+// source in DESCRIPTION is what gets edited.
+const markdownFormatParam = "X-CALCARD-FORMAT=markdown"
+
+// AltDescription is the formatted description of an event.
+type AltDescription struct {
+	// HTML is the sanitized HTML, safe to insert into a page.
+	HTML string
+	// Format is DescriptionMarkdown or DescriptionHTML, or empty when the
+	// event only has a plain text description.
+	Format string
+}
+
+// ParseAltDescription returns the HTML description (X-ALT-DESC with
+// FMTTYPE=text/html) of the first VEVENT in ical.
+func ParseAltDescription(ical string) AltDescription {
+	for _, prop := range eventProperties(ical) {
+		if prop.name != "X-ALT-DESC" || !isHTMLAltDesc(prop.params) {
+			continue
+		}
+		alt := AltDescription{HTML: richtext.Sanitize(unescapeICalText(prop.value)), Format: DescriptionHTML}
+		for _, p := range prop.params {
+			if strings.EqualFold(strings.TrimSpace(p), markdownFormatParam) {
+				alt.Format = DescriptionMarkdown
+			}
+		}
+		return alt
+	}
+	return AltDescription{}
+}
+
+func isHTMLAltDesc(params []string) bool {
+	for _, p := range params {
+		key, val, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "FMTTYPE") {
+			return strings.EqualFold(strings.Trim(strings.TrimSpace(val), `"`), "text/html")
+		}
+	}
+	return false
+}
+
+// descriptionLines returns the DESCRIPTION and X-ALT-DESC content lines for
+// a description in the given format; either may be empty.
+func descriptionLines(description, format, htmlDescription string) (string, string) {
+	var desc, alt string
+	switch format {
+	case DescriptionMarkdown:
+		if rendered := richtext.Markdown(description); rendered != "" {
+			alt = "X-ALT-DESC;FMTTYPE=text/html;" + markdownFormatParam + ":" + EscapeICalValue(rendered)
+		}
+	case DescriptionHTML:
+		if sanitized := richtext.Sanitize(htmlDescription); strings.TrimSpace(sanitized) != "" {
+			alt = "X-ALT-DESC;FMTTYPE=text/html:" + EscapeICalValue(sanitized)
+			if description == "" {
+				description = richtext.PlainText(sanitized)
+			}
+		}
+	}
+	if description != "" {
+		desc = "DESCRIPTION:" + EscapeICalValue(description)
+	}
+	return desc, alt
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownDescriptionRoundTrip(t *testing.T) {
+	ical := BuildEvent("md-1", "Planning", "2026-03-20T10:00", "2026-03-20T11:00", false, "", "Bring **notes**\n- one", nil,
+		&EventOptions{DescriptionFormat: DescriptionMarkdown})
+	if !strings.Contains(ical, `DESCRIPTION:Bring **notes**\n- one`) {
+		t.Fatalf("DESCRIPTION should keep the Markdown source:\n%s", ical)
+	}
+	alt := ParseAltDescription(ical)
+	if alt.Format != DescriptionMarkdown || alt.HTML != "<p>Bring <strong>notes</strong></p><ul><li>one</li></ul>" {
+		t.Fatalf("ParseAltDescription() = %+v", alt)
+	}
+}
+
+func TestHTMLDescriptionIsSanitizedWithPlainText(t *testing.T) {
+	ical := BuildEvent("html-1", "Review", "2026-03-20T10:00", "2026-03-20T11:00", false, "", "", nil,
+		&EventOptions{DescriptionFormat: DescriptionHTML, DescriptionHTML: `<p>Hi, <b>team</b></p><img src=x onerror=alert(1)><script>x()</script>`})
+	if strings.Contains(ical, "onerror") || strings.Contains(ical, "script") {
+		t.Fatalf("unsafe HTML stored:\n%s", ical)
+	}
+	if !strings.Contains(ical, `DESCRIPTION:Hi\, team`) {
+		t.Fatalf("missing plain text DESCRIPTION:\n%s", ical)
+	}
+	if alt := ParseAltDescription(ical); alt.Format != DescriptionHTML || alt.HTML != "<p>Hi, <b>team</b></p>" {
+		t.Fatalf("ParseAltDescription() = %+v", alt)
+	}
+}
+
+func TestParseAltDescriptionSanitizesClientHTML(t *testing.T) {
+	ical := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:x\r\nX-ALT-DESC;FMTTYPE=text/html:<a href=\"javascript:alert(1)\">x</a>\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if alt := ParseAltDescription(ical); alt.HTML != "<a>x</a>" {
+		t.Fatalf("ParseAltDescription() = %+v", alt)
+	}
+	if alt := ParseAltDescription(strings.Replace(ical, "text/html", "text/plain", 1)); alt != (AltDescription{}) {
+		t.Fatalf("non-HTML X-ALT-DESC = %+v", alt)
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// conferenceProviders maps meeting hosts to the provider names surfaced to
+// clients. Subdomains match too, so "acme.zoom.us" is reported as zoom.
+var conferenceProviders = []struct {
+	host     string
+	provider string
+}{
+	{"zoom.us", "zoom"},
+	{"zoomgov.com", "zoom"},
+	{"meet.google.com", "meet"},
+	{"teams.microsoft.com", "teams"},
+	{"teams.live.com", "teams"},
+	{"webex.com", "webex"},
+	{"meet.jit.si", "jitsi"},
+}
+
+var conferenceURLPattern = regexp.MustCompile(`https?://[^\s<>"'\\]+`)
+
+// ExtractJoinLink finds the online meeting link of the first VEVENT in an
+// iCalendar payload. Explicit sources are preferred over scraped ones:
+// CONFERENCE (RFC 7986), X-GOOGLE-CONFERENCE, URL, then links embedded in
+// LOCATION and DESCRIPTION. URL, LOCATION and DESCRIPTION only count when
+// they point at a known meeting provider. The provider is "" when nothing
+// was found and "other" for explicit conference links on unknown hosts.
+func ExtractJoinLink(ical string) (string, string) {
+	props := map[string][]string{}
+	for _, prop := range eventProperties(ical) {
+		if prop.name == "CONFERENCE" && !conferenceHasVideo(prop.params) {
+			// Dial-in numbers and chat rooms are not join links.
+			continue
+		}
+		props[prop.name] = append(props[prop.name], prop.value)
+	}
+	return pickJoinLink(props)
+}
+
+func pickJoinLink(props map[string][]string) (string, string) {
+	for _, name := range []string{"CONFERENCE", "X-GOOGLE-CONFERENCE"} {
+		for _, value := range props[name] {
+			if link := normalizeJoinURL(value); link != "" {
+				if provider := ConferenceProvider(link); provider != "" {
+					return link, provider
+				}
+				return link, "other"
+			}
+		}
+	}
+	for _, value := range props["URL"] {
+		if link := normalizeJoinURL(value); link != "" && ConferenceProvider(link) != "" {
+			return link, ConferenceProvider(link)
+		}
+	}
+	for _, name := range []string{"LOCATION", "DESCRIPTION"} {
+		for _, value := range props[name] {
+			for _, match := range conferenceURLPattern.FindAllString(unescapeICalText(value), -1) {
+				link := normalizeJoinURL(strings.TrimRight(match, ".,;)]>"))
+				if provider := ConferenceProvider(link); provider != "" {
+					return link, provider
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// ConferenceProvider names the meeting service hosting link, or returns ""
+// when the host is not a known provider.
+func ConferenceProvider(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, p := range conferenceProviders {
+		if host == p.host || strings.HasSuffix(host, "."+p.host) {
+			return p.provider
+		}
+	}
+	return ""
+}
+
+// conferenceHasVideo reports whether a CONFERENCE property is usable as a
+// join link: it must be a URI and, when FEATURE is given, include VIDEO.
+func conferenceHasVideo(params []string) bool {
+	for _, p := range params {
+		key, val, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "VALUE":
+			if !strings.EqualFold(strings.Trim(val, `"`), "URI") {
+				return false
+			}
+		case "FEATURE":
+			found := false
+			for _, f := range strings.Split(strings.Trim(val, `"`), ",") {
+				if strings.EqualFold(strings.TrimSpace(f), "VIDEO") {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normalizeJoinURL returns link when it is an absolute http(s) URL.
+func normalizeJoinURL(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return link
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestExtractJoinLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		props        []string
+		wantURL      string
+		wantProvider string
+	}{
+		{
+			name:         "conference property",
+			props:        []string{"CONFERENCE;VALUE=URI;FEATURE=VIDEO:https://example.com/room/1", "URL:https://zoom.us/j/1"},
+			wantURL:      "https://example.com/room/1",
+			wantProvider: "other",
+		},
+		{
+			name:         "audio-only conference skipped",
+			props:        []string{"CONFERENCE;VALUE=URI;FEATURE=PHONE:tel:+1-555-0100", "LOCATION:https://acme.zoom.us/j/123"},
+			wantURL:      "https://acme.zoom.us/j/123",
+			wantProvider: "zoom",
+		},
+		{
+			name:         "google conference extension",
+			props:        []string{"X-GOOGLE-CONFERENCE:https://meet.google.com/abc-defg-hij"},
+			wantURL:      "https://meet.google.com/abc-defg-hij",
+			wantProvider: "meet",
+		},
+		{
+			name:    "plain url ignored",
+			props:   []string{"URL:https://example.com/agenda"},
+			wantURL: "",
+		},
+		{
+			name:         "teams link in escaped description",
+			props:        []string{`DESCRIPTION:Join Microsoft Teams Meeting\n<https://teams.microsoft.com/l/meetup-join/19%3ameeting>\, see you`},
+			wantURL:      "https://teams.microsoft.com/l/meetup-join/19%3ameeting",
+			wantProvider: "teams",
+		},
+		{
+			name:    "alarm description ignored",
+			props:   []string{"BEGIN:VALARM", "DESCRIPTION:https://zoom.us/j/9", "END:VALARM"},
+			wantURL: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotURL, gotProvider := ExtractJoinLink(exchangeEvent(tt.props...))
+			if gotURL != tt.wantURL || gotProvider != tt.wantProvider {
+				t.Fatalf("ExtractJoinLink() = (%q, %q), want (%q, %q)", gotURL, gotProvider, tt.wantURL, tt.wantProvider)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// NormalizeExchangeICal rewrites common Exchange/Outlook export quirks into
+// RFC 5545 form so recurring and all-day events behave the same on every
+// client:
+//
+//   - X-MICROSOFT-CDO-ALLDAYEVENT:TRUE events stored as midnight (or
+//     offset-shifted UTC) date-times become VALUE=DATE events.
+//   - X-MICROSOFT-CDO-BUSYSTATUS is mirrored into TRANSP when TRANSP is absent.
+//   - RRULE UNTIL values are coerced to the value type of DTSTART, and to UTC
+//     when DTSTART is zoned, as RFC 5545 §3.3.10 requires.
+//
+// The input is returned unchanged when nothing needed rewriting, so stored
+// ETags only change for payloads that were actually normalized.
+func NormalizeExchangeICal(ical string) string {
+	lines := UnfoldLines(ical)
+	changed := false
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if !strings.EqualFold(strings.TrimSpace(lines[i]), "BEGIN:VEVENT") {
+			out = append(out, lines[i])
+			continue
+		}
+		end := i + 1
+		depth := 1
+		for ; end < len(lines); end++ {
+			upper := strings.ToUpper(strings.TrimSpace(lines[end]))
+			if strings.HasPrefix(upper, "BEGIN:") {
+				depth++
+			} else if strings.HasPrefix(upper, "END:") {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if end >= len(lines) {
+			// Unterminated component: leave it for the validator to reject.
+			return ical
+		}
+		event, eventChanged := normalizeExchangeEvent(lines[i : end+1])
+		changed = changed || eventChanged
+		out = append(out, event...)
+		i = end
+	}
+	if !changed {
+		return ical
+	}
+	var sb strings.Builder
+	for _, line := range out {
+		writeICalLine(&sb, line)
+	}
+	return sb.String()
+}
+
+// normalizeExchangeEvent rewrites a single BEGIN:VEVENT..END:VEVENT block.
+// Only top-level properties are considered; nested VALARMs are left alone.
+func normalizeExchangeEvent(lines []string) ([]string, bool) {
+	event := append([]string(nil), lines...)
+	idx := map[string]int{}
+	depth := 0
+	for i, line := range event {
+		upper := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(upper, "BEGIN:"):
+			depth++
+			continue
+		case strings.HasPrefix(upper, "END:"):
+			depth--
+			continue
+		}
+		if depth != 1 {
+			continue
+		}
+		name, _, _ := splitICalProperty(line)
+		if _, seen := idx[name]; !seen {
+			idx[name] = i
+		}
+	}
+	changed := false
+
+	if i, ok := idx["X-MICROSOFT-CDO-ALLDAYEVENT"]; ok {
+		if _, _, value := splitICalProperty(event[i]); strings.EqualFold(strings.TrimSpace(value), "TRUE") {
+			for _, prop := range []string{"DTSTART", "DTEND"} {
+				j, ok := idx[prop]
+				if !ok {
+					continue
+				}
+				if line, ok := exchangeAllDayLine(event[j]); ok {
+					event[j] = line
+					changed = true
+				}
+			}
+		}
+	}
+
+	if i, ok := idx["X-MICROSOFT-CDO-BUSYSTATUS"]; ok {
+		if _, hasTransp := idx["TRANSP"]; !hasTransp {
+			_, _, value := splitICalProperty(event[i])
+			transp := "TRANSP:OPAQUE"
+			if strings.EqualFold(strings.TrimSpace(value), "FREE") {
+				transp = "TRANSP:TRANSPARENT"
+			}
+			event = append(event[:i+1], append([]string{transp}, event[i+1:]...)...)
+			changed = true
+			// Indices after the insertion point shift by one.
+			for name, j := range idx {
+				if j > i {
+					idx[name] = j + 1
+				}
+			}
+		}
+	}
+
+	if i, ok := idx["RRULE"]; ok {
+		if j, ok := idx["DTSTART"]; ok {
+			if line, ok := normalizeRRuleUntil(event[i], event[j]); ok {
+				event[i] = line
+				changed = true
+			}
+		}
+	}
+	return event, changed
+}
+
+// exchangeAllDayLine converts an Exchange all-day DTSTART/DTEND date-time into
+// a DATE value. UTC values carry the exporter's zone offset, so the nearest
+// midnight is used rather than the UTC calendar date.
+func exchangeAllDayLine(line string) (string, bool) {
+	name, params, value := splitICalProperty(line)
+	value = strings.TrimSpace(value)
+	if len(value) < 15 || value[8] != 'T' {
+		return "", false
+	}
+	date := value[:8]
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return "", false
+		}
+		date = t.Add(12 * time.Hour).Format("20060102")
+	} else if value[9:15] != "000000" {
+		return "", false
+	}
+	var kept []string
+	for _, p := range params {
+		upper := strings.ToUpper(p)
+		if strings.HasPrefix(upper, "TZID=") || strings.HasPrefix(upper, "VALUE=") {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	kept = append(kept, "VALUE=DATE")
+	return name + ";" + strings.Join(kept, ";") + ":" + date, true
+}
+
+// normalizeRRuleUntil coerces the UNTIL part of an RRULE to the form RFC 5545
+// requires for the event's DTSTART.
+func normalizeRRuleUntil(rrule, dtstart string) (string, bool) {
+	name, params, value := splitICalProperty(rrule)
+	parts := strings.Split(value, ";")
+	untilIdx := -1
+	for i, part := range parts {
+		if strings.HasPrefix(strings.ToUpper(part), "UNTIL=") {
+			untilIdx = i
+			break
+		}
+	}
+	if untilIdx < 0 {
+		return "", false
+	}
+	original := parts[untilIdx][len("UNTIL="):]
+	until := strings.NewReplacer("-", "", ":", "").Replace(strings.TrimSpace(original))
+
+	_, startParams, startValue := splitICalProperty(dtstart)
+	startIsDate := !strings.Contains(startValue, "T")
+	tzid := ""
+	for _, p := range startParams {
+		if strings.HasPrefix(strings.ToUpper(p), "TZID=") {
+			tzid = strings.Trim(p[len("TZID="):], `"`)
+		}
+	}
+	zoned := tzid != "" || strings.HasSuffix(strings.TrimSpace(startValue), "Z")
+
+	switch {
+	case len(until) < 8:
+		return "", false
+	case startIsDate:
+		until = until[:8]
+	case len(until) == 8:
+		until = exchangeLocalToUTC(until+"T235959", tzid, zoned)
+	case !strings.HasSuffix(until, "Z"):
+		until = exchangeLocalToUTC(until, tzid, zoned)
+	}
+	if until == original {
+		return "", false
+	}
+	parts[untilIdx] = "UNTIL=" + until
+	prefix := name
+	if len(params) > 0 {
+		prefix += ";" + strings.Join(params, ";")
+	}
+	return prefix + ":" + strings.Join(parts, ";"), true
+}
+
+// exchangeLocalToUTC converts a floating UNTIL date-time to UTC using the
+// DTSTART zone. Exchange often uses Windows zone names the runtime cannot
+// load; those fall back to treating the wall-clock time as UTC.
+func exchangeLocalToUTC(value, tzid string, zoned bool) string {
+	if !zoned {
+		return value
+	}
+	loc := time.UTC
+	if tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return value + "Z"
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// splitICalProperty splits a content line into its upper-cased name, raw
+// parameters and value, honouring quoted parameter values.
+func splitICalProperty(line string) (string, []string, string) {
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(strings.TrimSpace(line)), nil, ""
+	}
+	head, value := line[:colon], line[colon+1:]
+	fields := strings.Split(head, ";")
+	return strings.ToUpper(strings.TrimSpace(fields[0])), fields[1:], value
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func exchangeEvent(props ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:Microsoft Exchange Server 2010\r\nBEGIN:VEVENT\r\nUID:ex-1\r\n" +
+		strings.Join(props, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+func TestNormalizeExchangeICal(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "all-day midnight in zone",
+			input: exchangeEvent(
+				"DTSTART;TZID=W. Europe Standard Time:20260105T000000",
+				"DTEND;TZID=W. Europe Standard Time:20260106T000000",
+				"X-MICROSOFT-CDO-ALLDAYEVENT:TRUE",
+			),
+			want:    []string{"DTSTART;VALUE=DATE:20260105", "DTEND;VALUE=DATE:20260106"},
+			notWant: []string{"TZID="},
+		},
+		{
+			name: "all-day shifted into UTC",
+			input: exchangeEvent(
+				"DTSTART:20260104T230000Z",
+				"DTEND:20260105T230000Z",
+				"X-MICROSOFT-CDO-ALLDAYEVENT:TRUE",
+			),
+			want: []string{"DTSTART;VALUE=DATE:20260105", "DTEND;VALUE=DATE:20260106"},
+		},
+		{
+			name:  "busy status mapped to transparency",
+			input: exchangeEvent("DTSTART:20260105T090000Z", "X-MICROSOFT-CDO-BUSYSTATUS:FREE"),
+			want:  []string{"X-MICROSOFT-CDO-BUSYSTATUS:FREE\r\nTRANSP:TRANSPARENT"},
+		},
+		{
+			name:    "existing transparency wins",
+			input:   exchangeEvent("DTSTART:20260105T090000Z", "TRANSP:OPAQUE", "X-MICROSOFT-CDO-BUSYSTATUS:FREE"),
+			notWant: []string{"TRANSP:TRANSPARENT"},
+		},
+		{
+			name:  "floating until on zoned start",
+			input: exchangeEvent("DTSTART;TZID=America/New_York:20260105T090000", "RRULE:FREQ=WEEKLY;UNTIL=20260301T090000;BYDAY=MO"),
+			want:  []string{"RRULE:FREQ=WEEKLY;UNTIL=20260301T140000Z;BYDAY=MO"},
+		},
+		{
+			name:  "date until on date-time start",
+			input: exchangeEvent("DTSTART:20260105T090000Z", "RRULE:FREQ=DAILY;UNTIL=2026-03-01"),
+			want:  []string{"RRULE:FREQ=DAILY;UNTIL=20260301T235959Z"},
+		},
+		{
+			name: "date-time until after all-day conversion",
+			input: exchangeEvent(
+				"DTSTART;TZID=W. Europe Standard Time:20260105T000000",
+				"RRULE:FREQ=YEARLY;UNTIL=20300105T000000Z",
+				"X-MICROSOFT-CDO-ALLDAYEVENT:TRUE",
+			),
+			want: []string{"RRULE:FREQ=YEARLY;UNTIL=20300105\r\n"},
+		},
+		{
+			name:    "alarm properties untouched",
+			input:   exchangeEvent("DTSTART:20260105T090000Z", "BEGIN:VALARM", "X-MICROSOFT-CDO-BUSYSTATUS:FREE", "ACTION:DISPLAY", "END:VALARM"),
+			notWant: []string{"TRANSP:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeExchangeICal(tt.input)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in output:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("unexpected %q in output:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeExchangeICalLeavesCompliantInputUntouched(t *testing.T) {
+	input := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:a\nDTSTART:20260105T090000Z\nRRULE:FREQ=DAILY;UNTIL=20260110T090000Z\nEND:VEVENT\nEND:VCALENDAR\n"
+	if got := NormalizeExchangeICal(input); got != input {
+		t.Fatalf("expected byte-identical output, got:\n%q", got)
+	}
+}
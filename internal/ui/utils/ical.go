@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"net/mail"
@@ -9,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jw6ventures/calcard/internal/util"
 )
 
 // GenerateUID creates a unique identifier for calendar/contact objects.
@@ -16,10 +17,10 @@ func GenerateUID() string {
 	return fmt.Sprintf("%d-%s@calcard", time.Now().UnixNano(), RandomString(8))
 }
 
-// GenerateETag creates an ETag from content.
+// GenerateETag creates an ETag from the canonical form of content, so
+// payloads that differ only in folding, ordering or line endings share it.
 func GenerateETag(content string) string {
-	h := sha256.Sum256([]byte(content))
-	return fmt.Sprintf("%x", h)
+	return util.CanonicalETag(content)
 }
 
 // RecurrenceOptions holds recurrence rule parameters.
@@ -37,6 +38,9 @@ type RecurrenceOptions struct {
 type EventOptions struct {
 	Timezone     string
 	URL          string
+	Conference   string
+	Color        string
+	Image        string
 	Status       string
 	Categories   []string
 	Class        string
@@ -45,6 +49,10 @@ type EventOptions struct {
 	Attendees    []string
 	Attachments  []string
 	Reminders    []int
+	// DescriptionFormat is DescriptionText (or empty), DescriptionMarkdown
+	// or DescriptionHTML; DescriptionHTML holds the HTML for the latter.
+	DescriptionFormat string
+	DescriptionHTML   string
 }
 
 // ParseRecurrenceOptions extracts recurrence options from form data.
@@ -169,14 +177,31 @@ func BuildEventComponent(uid, summary, dtstart, dtend string, allDay bool, locat
 		lines = append(lines, fmt.Sprintf("LOCATION:%s", EscapeICalValue(location)))
 	}
 
-	if description != "" {
-		lines = append(lines, fmt.Sprintf("DESCRIPTION:%s", EscapeICalValue(description)))
+	var descFormat, descHTML string
+	if opts != nil {
+		descFormat, descHTML = opts.DescriptionFormat, opts.DescriptionHTML
+	}
+	descLine, altLine := descriptionLines(description, descFormat, descHTML)
+	if descLine != "" {
+		lines = append(lines, descLine)
+	}
+	if altLine != "" {
+		lines = append(lines, altLine)
 	}
 
 	if opts != nil {
 		if urlVal := sanitizeICalURI(opts.URL); urlVal != "" {
 			lines = append(lines, fmt.Sprintf("URL:%s", urlVal))
 		}
+		if confVal := sanitizeICalURI(opts.Conference); confVal != "" {
+			lines = append(lines, fmt.Sprintf("CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:%s", confVal))
+		}
+		if color := CSSColorName(opts.Color); color != "" {
+			lines = append(lines, fmt.Sprintf("COLOR:%s", color))
+		}
+		if imageVal := sanitizeICalURI(opts.Image); imageVal != "" {
+			lines = append(lines, fmt.Sprintf("IMAGE;VALUE=URI;DISPLAY=BADGE:%s", imageVal))
+		}
 		if opts.Status != "" {
 			lines = append(lines, fmt.Sprintf("STATUS:%s", strings.ToUpper(opts.Status)))
 		}
@@ -751,7 +776,8 @@ func ParseICSFile(icsContent string) ([]string, error) {
 		}
 
 		if currentEventDepth > 0 {
-			if upper == "END:VEVENT" {
+			if strings.HasPrefix(upper, "END:") {
+				// Nested components such as VALARM close inside the event.
 				currentEventDepth--
 				if currentEventDepth == 0 {
 					uid := extractUIDFromLines(currentEvent)
@@ -765,10 +791,7 @@ func ParseICSFile(icsContent string) ([]string, error) {
 					currentEvent = nil
 					continue
 				}
-				currentEvent = append(currentEvent, trimmed)
-				continue
-			}
-			if strings.HasPrefix(upper, "BEGIN:") {
+			} else if strings.HasPrefix(upper, "BEGIN:") {
 				currentEventDepth++
 			}
 			currentEvent = append(currentEvent, trimmed)
@@ -830,12 +853,20 @@ func ExtractUID(ical string) string {
 	return extractUIDFromLines(lines)
 }
 
+// extractUIDFromLines returns the first UID outside a VALARM, whose RFC 9074
+// UID identifies the alarm rather than the event.
 func extractUIDFromLines(lines []string) string {
+	inAlarm := false
 	for _, line := range lines {
-		if !strings.HasPrefix(strings.ToUpper(line), "UID:") {
-			continue
+		upper := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case upper == "BEGIN:VALARM":
+			inAlarm = true
+		case upper == "END:VALARM":
+			inAlarm = false
+		case !inAlarm && strings.HasPrefix(upper, "UID:"):
+			return strings.TrimSpace(line[4:])
 		}
-		return strings.TrimSpace(line[4:])
 	}
 	return ""
 }
@@ -926,3 +957,67 @@ func validateICSContent(icsContent string) error {
 	}
 	return nil
 }
+
+// BuildCalendarExport bundles stored iCalendar objects into a single
+// VCALENDAR, de-duplicating VTIMEZONE definitions shared between events.
+func BuildCalendarExport(info CalendarExportInfo, rawICals []string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//CalCard//Calendar Export//EN\r\n")
+	b.WriteString("CALSCALE:GREGORIAN\r\n")
+	writeCalendarExportHeader(&b, info)
+
+	seenTimezones := make(map[string]struct{})
+	for _, raw := range rawICals {
+		for _, timezone := range ICalComponents(raw, "VTIMEZONE") {
+			if _, ok := seenTimezones[timezone]; ok {
+				continue
+			}
+			seenTimezones[timezone] = struct{}{}
+			b.WriteString(timezone)
+		}
+	}
+	for _, raw := range rawICals {
+		for _, component := range ICalComponents(raw, "VEVENT") {
+			b.WriteString(component)
+		}
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+// ICalComponents returns each top-level component with the given name from raw
+// iCalendar data, CRLF-terminated and including nested sub-components.
+func ICalComponents(raw, componentName string) []string {
+	componentName = strings.ToUpper(componentName)
+	var components []string
+	var current []string
+	depth := 0
+
+	for _, line := range UnfoldLines(raw) {
+		upperLine := strings.ToUpper(strings.TrimSpace(line))
+		if depth == 0 {
+			if upperLine == "BEGIN:"+componentName {
+				current = []string{line}
+				depth = 1
+			}
+			continue
+		}
+
+		current = append(current, line)
+		if strings.HasPrefix(upperLine, "BEGIN:") {
+			depth++
+			continue
+		}
+		if strings.HasPrefix(upperLine, "END:") {
+			depth--
+			if depth == 0 {
+				components = append(components, strings.Join(current, "\r\n")+"\r\n")
+				current = nil
+			}
+		}
+	}
+	return components
+}
@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/jw6ventures/calcard/internal/icalgen"
 )
 
 func TestGenerateUID(t *testing.T) {
@@ -341,6 +343,9 @@ func TestBuildEvent_WithOptions(t *testing.T) {
 	opts := &EventOptions{
 		Timezone:     "America/New_York",
 		URL:          "https://example.com/event",
+		Conference:   "https://meet.google.com/abc-defg-hij",
+		Color:        "#1E90FF",
+		Image:        "https://example.com/badge.png",
 		Status:       "confirmed",
 		Categories:   []string{"Team", "Planning"},
 		Class:        "private",
@@ -357,6 +362,9 @@ func TestBuildEvent_WithOptions(t *testing.T) {
 		"DTSTART;TZID=America/New_York:20250115T140000",
 		"DTEND;TZID=America/New_York:20250115T150000",
 		"URL:https://example.com/event",
+		"CONFERENCE;VALUE=URI;FEATURE=AUDIO,VIDEO:https://meet.google.com/abc-defg-hij",
+		"COLOR:dodgerblue",
+		"IMAGE;VALUE=URI;DISPLAY=BADGE:https://example.com/badge.png",
 		"STATUS:CONFIRMED",
 		"CLASS:PRIVATE",
 		"TRANSP:OPAQUE",
@@ -732,6 +740,23 @@ END:VCALENDAR`
 	}
 }
 
+func TestParseICSFileKeepsEventAlarms(t *testing.T) {
+	icsContent := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:alarm@example.com\r\nDTSTART:20250115T140000Z\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:plain@example.com\r\nDTSTART:20250116T140000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	events, err := ParseICSFile(icsContent)
+	if err != nil {
+		t.Fatalf("ParseICSFile() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("ParseICSFile() should return 2 events, got %d", len(events))
+	}
+	if !strings.Contains(events[0], "BEGIN:VALARM") || !strings.Contains(events[0], "END:VALARM") {
+		t.Fatalf("expected the alarm to stay in its event, got: %s", events[0])
+	}
+}
+
 func TestParseICSFileRejectsMalformedInput(t *testing.T) {
 	tests := []struct {
 		name string
@@ -814,3 +839,20 @@ func TestResourceNameForUID(t *testing.T) {
 		t.Fatalf("ResourceNameForUID() = %q", got)
 	}
 }
+
+func FuzzParseICSFile(f *testing.F) {
+	for seed := int64(1); seed <= 4; seed++ {
+		f.Add(icalgen.Calendar(icalgen.Options{Seed: seed, Events: 20}))
+	}
+	f.Fuzz(func(t *testing.T, ics string) {
+		events, err := ParseICSFile(ics)
+		if err != nil {
+			return
+		}
+		for _, event := range events {
+			if _, err := ParseICSFile(event); err != nil {
+				t.Fatalf("resource split from valid input does not parse: %v\n%s", err, event)
+			}
+		}
+	})
+}
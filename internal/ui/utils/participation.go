@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/jw6ventures/calcard/internal/util"
+)
+
+// IsCancelledOrDeclined reports whether the first VEVENT in ical is
+// STATUS:CANCELLED, or lists any of emails as an ATTENDEE with
+// PARTSTAT=DECLINED. Addresses match ignoring case and Unicode
+// normalization.
+func IsCancelledOrDeclined(ical string, emails ...string) bool {
+	keys := map[string]bool{}
+	for _, email := range emails {
+		if key := util.EmailLookupKey(email); key != "" {
+			keys[key] = true
+		}
+	}
+	for _, prop := range eventProperties(ical) {
+		switch prop.name {
+		case "STATUS":
+			if strings.EqualFold(strings.TrimSpace(prop.value), "CANCELLED") {
+				return true
+			}
+		case "ATTENDEE":
+			if len(keys) > 0 && attendeeDeclined(prop.params) && keys[util.EmailLookupKey(prop.value)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attendeeDeclined(params []string) bool {
+	return attendeePartstat(params) == "DECLINED"
+}
+
+// attendeePartstat returns the upper-cased PARTSTAT parameter, or "" when
+// there is none.
+func attendeePartstat(params []string) string {
+	for _, p := range params {
+		key, val, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "PARTSTAT") {
+			return strings.ToUpper(strings.TrimSpace(strings.Trim(val, `"`)))
+		}
+	}
+	return ""
+}
+
+// AttendeeSummary counts the responses of an event's attendees.
+type AttendeeSummary struct {
+	Accepted  int
+	Declined  int
+	Tentative int
+	// Pending counts attendees that have not answered: NEEDS-ACTION, no
+	// PARTSTAT, or a delegated invitation.
+	Pending int
+}
+
+// Total returns the number of attendees counted.
+func (s AttendeeSummary) Total() int {
+	return s.Accepted + s.Declined + s.Tentative + s.Pending
+}
+
+// Add counts one attendee with the given PARTSTAT.
+func (s *AttendeeSummary) Add(partstat string) {
+	switch strings.ToUpper(strings.TrimSpace(partstat)) {
+	case "ACCEPTED":
+		s.Accepted++
+	case "DECLINED":
+		s.Declined++
+	case "TENTATIVE":
+		s.Tentative++
+	default:
+		s.Pending++
+	}
+}
+
+// SummarizeAttendees counts the responses of the ATTENDEEs of the first
+// VEVENT in ical. Attendees with ROLE=NON-PARTICIPANT are left out. It
+// returns nil when the event has no attendees.
+func SummarizeAttendees(ical string) *AttendeeSummary {
+	var summary AttendeeSummary
+	for _, prop := range eventProperties(ical) {
+		if prop.name != "ATTENDEE" || nonParticipant(prop.params) {
+			continue
+		}
+		summary.Add(attendeePartstat(prop.params))
+	}
+	if summary.Total() == 0 {
+		return nil
+	}
+	return &summary
+}
+
+func nonParticipant(params []string) bool {
+	for _, p := range params {
+		key, val, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "ROLE") {
+			return strings.EqualFold(strings.Trim(strings.TrimSpace(val), `"`), "NON-PARTICIPANT")
+		}
+	}
+	return false
+}
+
+// IsTransparent reports whether the first VEVENT in ical is marked
+// TRANSP:TRANSPARENT, meaning it does not block time in free/busy lookups.
+func IsTransparent(ical string) bool {
+	for _, prop := range eventProperties(ical) {
+		if prop.name == "TRANSP" {
+			return strings.EqualFold(strings.TrimSpace(prop.value), "TRANSPARENT")
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestIsCancelledOrDeclined(t *testing.T) {
+	tests := []struct {
+		name  string
+		props []string
+		want  bool
+	}{
+		{"cancelled", []string{"STATUS:CANCELLED"}, true},
+		{"confirmed", []string{"STATUS:CONFIRMED"}, false},
+		{"declined by user", []string{"ATTENDEE;CN=Me;PARTSTAT=DECLINED:MAILTO:Me@Example.com"}, true},
+		{"declined by someone else", []string{"ATTENDEE;PARTSTAT=DECLINED:mailto:other@example.com"}, false},
+		{"accepted by user", []string{"ATTENDEE;PARTSTAT=ACCEPTED:mailto:me@example.com"}, false},
+		{"declined by alias", []string{"ATTENDEE;PARTSTAT=DECLINED:mailto:ME.Work@example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCancelledOrDeclined(exchangeEvent(tt.props...), "me@example.com", "me.work@example.com"); got != tt.want {
+				t.Fatalf("IsCancelledOrDeclined() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTransparent(t *testing.T) {
+	if !IsTransparent(exchangeEvent("TRANSP:TRANSPARENT")) {
+		t.Fatal("expected TRANSP:TRANSPARENT to be transparent")
+	}
+	if IsTransparent(exchangeEvent("TRANSP:OPAQUE")) || IsTransparent(exchangeEvent()) {
+		t.Fatal("expected opaque and unmarked events to block time")
+	}
+}
+
+func TestSummarizeAttendees(t *testing.T) {
+	ical := exchangeEvent(
+		"ORGANIZER:mailto:me@example.com",
+		"ATTENDEE;PARTSTAT=ACCEPTED:mailto:me@example.com",
+		"ATTENDEE;PARTSTAT=accepted:mailto:a@example.com",
+		"ATTENDEE;PARTSTAT=DECLINED:mailto:b@example.com",
+		"ATTENDEE;PARTSTAT=TENTATIVE:mailto:c@example.com",
+		"ATTENDEE;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:d@example.com",
+		"ATTENDEE:mailto:e@example.com",
+		"ATTENDEE;ROLE=NON-PARTICIPANT:mailto:f@example.com",
+	)
+	got := SummarizeAttendees(ical)
+	want := AttendeeSummary{Accepted: 2, Declined: 1, Tentative: 1, Pending: 2}
+	if got == nil || *got != want {
+		t.Fatalf("SummarizeAttendees() = %+v, want %+v", got, want)
+	}
+	if got.Total() != 6 {
+		t.Fatalf("Total() = %d, want 6", got.Total())
+	}
+	if got := SummarizeAttendees(exchangeEvent("ORGANIZER:mailto:me@example.com")); got != nil {
+		t.Fatalf("SummarizeAttendees() without attendees = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import "strings"
+
+// Repairs made to calendar data written by clients, as reported back to
+// them and kept in each user's client data quality report.
+const (
+	// RepairCharacters: characters XML cannot carry were removed.
+	RepairCharacters = "characters"
+	// RepairExchange: Exchange/Outlook quirks were rewritten into RFC 5545
+	// form.
+	RepairExchange = "exchange"
+	// RepairLineEndings: bare line feeds or carriage returns became CRLF.
+	RepairLineEndings = "line-endings"
+	// RepairProdID: a missing PRODID was added.
+	RepairProdID = "prodid"
+)
+
+// RepairDescriptions explains each repair for the people whose clients
+// needed it.
+var RepairDescriptions = map[string]string{
+	RepairCharacters:  "removed characters that are not allowed in calendar data",
+	RepairExchange:    "rewrote Exchange/Outlook date and recurrence quirks",
+	RepairLineEndings: "converted line endings to CRLF",
+	RepairProdID:      "added the missing PRODID property",
+}
+
+// repairedProdID identifies calendar objects whose client sent no PRODID.
+const repairedProdID = "PRODID:-//CalCard//Repaired//EN"
+
+// RepairICal fixes mistakes RFC 5545 forbids but that cost nothing to
+// correct: lines must end in CRLF, and a VCALENDAR must carry a PRODID. It
+// returns the repaired object and the repairs made, in order; the input is
+// returned unchanged when none were needed. Objects without BEGIN:VCALENDAR
+// are left for the validator to reject.
+func RepairICal(ical string) (string, []string) {
+	var repairs []string
+	if normalized := normalizeLineEndings(ical); normalized != ical {
+		ical = normalized
+		repairs = append(repairs, RepairLineEndings)
+	}
+	if start := calendarStart(ical); start >= 0 && !hasCalendarProperty(ical, "PRODID") {
+		ical = ical[:start] + repairedProdID + "\r\n" + ical[start:]
+		repairs = append(repairs, RepairProdID)
+	}
+	return ical, repairs
+}
+
+func normalizeLineEndings(s string) string {
+	if !strings.ContainsAny(strings.ReplaceAll(s, "\r\n", ""), "\r\n") {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// calendarStart returns the offset just past the BEGIN:VCALENDAR line, or
+// -1 when there is none.
+func calendarStart(ical string) int {
+	offset := 0
+	for offset < len(ical) {
+		end := strings.Index(ical[offset:], "\r\n")
+		if end < 0 {
+			return -1
+		}
+		if strings.EqualFold(strings.TrimSpace(ical[offset:offset+end]), "BEGIN:VCALENDAR") {
+			return offset + end + 2
+		}
+		offset += end + 2
+	}
+	return -1
+}
+
+// hasCalendarProperty reports whether the VCALENDAR itself, rather than a
+// component inside it, has the named property.
+func hasCalendarProperty(ical, name string) bool {
+	depth := 0
+	for _, line := range UnfoldLines(ical) {
+		upper := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(upper, "BEGIN:"):
+			depth++
+		case strings.HasPrefix(upper, "END:"):
+			depth--
+		case depth == 1 && (strings.HasPrefix(upper, name+":") || strings.HasPrefix(upper, name+";")):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRepairICal(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		repairs []string
+	}{
+		{
+			"clean",
+			"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Example//EN\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Example//EN\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			nil,
+		},
+		{
+			"bare line feeds",
+			"BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:-//Example//EN\nBEGIN:VEVENT\r\nUID:a\rEND:VEVENT\nEND:VCALENDAR\n",
+			"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Example//EN\r\nBEGIN:VEVENT\r\nUID:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			[]string{RepairLineEndings},
+		},
+		{
+			"missing PRODID",
+			"BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:a\nPRODID:-//Not the calendar's//EN\nEND:VEVENT\nEND:VCALENDAR\n",
+			"BEGIN:VCALENDAR\r\nPRODID:-//CalCard//Repaired//EN\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:a\r\nPRODID:-//Not the calendar's//EN\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			[]string{RepairLineEndings, RepairProdID},
+		},
+		{"not a calendar", "BEGIN:VCARD\r\nEND:VCARD\r\n", "BEGIN:VCARD\r\nEND:VCARD\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, repairs := RepairICal(tt.in)
+			if got != tt.want || !slices.Equal(repairs, tt.repairs) {
+				t.Fatalf("RepairICal(%q) = %q, %v, want %q, %v", tt.in, got, repairs, tt.want, tt.repairs)
+			}
+			for _, r := range repairs {
+				if RepairDescriptions[r] == "" {
+					t.Fatalf("repair %q has no description", r)
+				}
+			}
+		})
+	}
+}
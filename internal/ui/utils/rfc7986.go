@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarExportInfo describes the VCALENDAR-level properties written by
+// BuildCalendarExport. Empty fields are omitted.
+type CalendarExportInfo struct {
+	Name        string
+	Description string
+	// Color is a stored calendar color in #RRGGBB or #RRGGBBAA form.
+	Color string
+	// RefreshInterval hints subscribing clients how often to re-fetch.
+	RefreshInterval time.Duration
+}
+
+// EventProperties holds the RFC 7986 properties of an event.
+type EventProperties struct {
+	Color      string
+	Image      string
+	Conference string
+}
+
+// cssColors is the subset of CSS3 named colors used when mapping stored hex
+// colors onto the named value RFC 7986 COLOR requires.
+var cssColors = []struct {
+	name    string
+	r, g, b int
+}{
+	{"black", 0x00, 0x00, 0x00},
+	{"dimgray", 0x69, 0x69, 0x69},
+	{"gray", 0x80, 0x80, 0x80},
+	{"silver", 0xc0, 0xc0, 0xc0},
+	{"white", 0xff, 0xff, 0xff},
+	{"maroon", 0x80, 0x00, 0x00},
+	{"firebrick", 0xb2, 0x22, 0x22},
+	{"red", 0xff, 0x00, 0x00},
+	{"crimson", 0xdc, 0x14, 0x3c},
+	{"tomato", 0xff, 0x63, 0x47},
+	{"salmon", 0xfa, 0x80, 0x72},
+	{"orangered", 0xff, 0x45, 0x00},
+	{"darkorange", 0xff, 0x8c, 0x00},
+	{"orange", 0xff, 0xa5, 0x00},
+	{"gold", 0xff, 0xd7, 0x00},
+	{"yellow", 0xff, 0xff, 0x00},
+	{"khaki", 0xf0, 0xe6, 0x8c},
+	{"olive", 0x80, 0x80, 0x00},
+	{"yellowgreen", 0x9a, 0xcd, 0x32},
+	{"lime", 0x00, 0xff, 0x00},
+	{"limegreen", 0x32, 0xcd, 0x32},
+	{"green", 0x00, 0x80, 0x00},
+	{"seagreen", 0x2e, 0x8b, 0x57},
+	{"mediumseagreen", 0x3c, 0xb3, 0x71},
+	{"teal", 0x00, 0x80, 0x80},
+	{"lightseagreen", 0x20, 0xb2, 0xaa},
+	{"turquoise", 0x40, 0xe0, 0xd0},
+	{"aqua", 0x00, 0xff, 0xff},
+	{"deepskyblue", 0x00, 0xbf, 0xff},
+	{"skyblue", 0x87, 0xce, 0xeb},
+	{"dodgerblue", 0x1e, 0x90, 0xff},
+	{"royalblue", 0x41, 0x69, 0xe1},
+	{"steelblue", 0x46, 0x82, 0xb4},
+	{"blue", 0x00, 0x00, 0xff},
+	{"mediumblue", 0x00, 0x00, 0xcd},
+	{"navy", 0x00, 0x00, 0x80},
+	{"slateblue", 0x6a, 0x5a, 0xcd},
+	{"blueviolet", 0x8a, 0x2b, 0xe2},
+	{"indigo", 0x4b, 0x00, 0x82},
+	{"purple", 0x80, 0x00, 0x80},
+	{"mediumorchid", 0xba, 0x55, 0xd3},
+	{"violet", 0xee, 0x82, 0xee},
+	{"fuchsia", 0xff, 0x00, 0xff},
+	{"deeppink", 0xff, 0x14, 0x93},
+	{"hotpink", 0xff, 0x69, 0xb4},
+	{"pink", 0xff, 0xc0, 0xcb},
+	{"sienna", 0xa0, 0x52, 0x2d},
+	{"chocolate", 0xd2, 0x69, 0x1e},
+	{"peru", 0xcd, 0x85, 0x3f},
+	{"tan", 0xd2, 0xb4, 0x8c},
+	{"brown", 0xa5, 0x2a, 0x2a},
+}
+
+// CSSColorName maps a value onto a CSS3 color name for RFC 7986 COLOR.
+// Names are passed through lower-cased; #RRGGBB[AA] values map to the
+// nearest named color. Anything else yields "".
+func CSSColorName(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if !strings.HasPrefix(value, "#") {
+		name := strings.ToLower(value)
+		for _, r := range name {
+			if r < 'a' || r > 'z' {
+				return ""
+			}
+		}
+		return name
+	}
+	if len(value) != 7 && len(value) != 9 {
+		return ""
+	}
+	rgb, err := strconv.ParseUint(value[1:7], 16, 32)
+	if err != nil {
+		return ""
+	}
+	r, g, b := int(rgb>>16&0xff), int(rgb>>8&0xff), int(rgb&0xff)
+	best, bestDist := "", math.MaxInt
+	for _, c := range cssColors {
+		dr, dg, db := r-c.r, g-c.g, b-c.b
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = c.name, dist
+		}
+	}
+	return best
+}
+
+// FormatICalDuration renders d as an RFC 5545 DURATION value, e.g. PT1H.
+func FormatICalDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	secs := int64(d / time.Second)
+	days := secs / 86400
+	secs %= 86400
+	var b strings.Builder
+	b.WriteString("P")
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if secs > 0 {
+		b.WriteString("T")
+		if h := secs / 3600; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m := secs % 3600 / 60; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s := secs % 60; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	if b.Len() == 1 {
+		return ""
+	}
+	return b.String()
+}
+
+// ParseICalDuration reads an RFC 5545 DURATION value, such as -PT15M or
+// P1W. It reports false for anything else.
+func ParseICalDuration(value string) (time.Duration, bool) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign, value = -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	rest, ok := strings.CutPrefix(value, "P")
+	if !ok || rest == "" {
+		return 0, false
+	}
+	var d time.Duration
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime || len(rest) == 1 {
+				return 0, false
+			}
+			inTime, rest = true, rest[1:]
+			continue
+		}
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(rest) {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		var unit time.Duration
+		switch {
+		case !inTime && rest[i] == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && rest[i] == 'D':
+			unit = 24 * time.Hour
+		case inTime && rest[i] == 'H':
+			unit = time.Hour
+		case inTime && rest[i] == 'M':
+			unit = time.Minute
+		case inTime && rest[i] == 'S':
+			unit = time.Second
+		default:
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	return sign * d, true
+}
+
+// writeCalendarExportHeader writes the RFC 7986 calendar properties along
+// with the X-WR-* and Apple equivalents older clients still read.
+func writeCalendarExportHeader(b *strings.Builder, info CalendarExportInfo) {
+	if name := strings.TrimSpace(info.Name); name != "" {
+		writeICalLine(b, "NAME:"+EscapeICalValue(name))
+		writeICalLine(b, "X-WR-CALNAME:"+EscapeICalValue(name))
+	}
+	if desc := strings.TrimSpace(info.Description); desc != "" {
+		writeICalLine(b, "DESCRIPTION:"+EscapeICalValue(desc))
+		writeICalLine(b, "X-WR-CALDESC:"+EscapeICalValue(desc))
+	}
+	if color := strings.TrimSpace(info.Color); color != "" {
+		if name := CSSColorName(color); name != "" {
+			writeICalLine(b, "COLOR:"+name)
+		}
+		if strings.HasPrefix(color, "#") {
+			writeICalLine(b, "X-APPLE-CALENDAR-COLOR:"+color)
+		}
+	}
+	if interval := FormatICalDuration(info.RefreshInterval); interval != "" {
+		writeICalLine(b, "REFRESH-INTERVAL;VALUE=DURATION:"+interval)
+		writeICalLine(b, "X-PUBLISHED-TTL:"+interval)
+	}
+}
+
+// ParseEventProperties extracts the RFC 7986 COLOR, IMAGE and CONFERENCE
+// properties of the first VEVENT. Only the first of each is returned.
+func ParseEventProperties(ical string) EventProperties {
+	var props EventProperties
+	for _, prop := range eventProperties(ical) {
+		value := strings.TrimSpace(prop.value)
+		switch prop.name {
+		case "COLOR":
+			if props.Color == "" {
+				props.Color = value
+			}
+		case "IMAGE":
+			if props.Image == "" && isURIValued(prop.params) {
+				props.Image = value
+			}
+		case "CONFERENCE":
+			if props.Conference == "" && isURIValued(prop.params) {
+				props.Conference = value
+			}
+		}
+	}
+	return props
+}
+
+// isURIValued reports whether the VALUE parameter is absent or URI; inline
+// BINARY images are not surfaced.
+func isURIValued(params []string) bool {
+	for _, p := range params {
+		key, val, ok := strings.Cut(p, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "VALUE") {
+			return strings.EqualFold(strings.Trim(val, `"`), "URI")
+		}
+	}
+	return true
+}
+
+type icalProperty struct {
+	name   string
+	params []string
+	value  string
+}
+
+// eventProperties returns the top-level properties of the first VEVENT,
+// skipping nested components such as VALARM.
+func eventProperties(ical string) []icalProperty {
+	var props []icalProperty
+	depth := 0
+	inEvent := false
+	for _, line := range UnfoldLines(ical) {
+		name, params, value := splitICalProperty(line)
+		switch name {
+		case "BEGIN":
+			if !inEvent && strings.EqualFold(strings.TrimSpace(value), "VEVENT") {
+				inEvent = true
+				depth = 0
+			}
+			depth++
+			continue
+		case "END":
+			depth--
+			if inEvent && depth == 0 {
+				return props
+			}
+			continue
+		}
+		if inEvent && depth == 1 {
+			props = append(props, icalProperty{name: name, params: params, value: value})
+		}
+	}
+	return nil
+}
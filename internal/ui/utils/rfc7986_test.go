@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSSColorName(t *testing.T) {
+	tests := map[string]string{
+		"#FF0000":   "red",
+		"#3B82F6FF": "royalblue",
+		"#22CC88":   "mediumseagreen",
+		"Teal":      "teal",
+		"#12":       "",
+		"not a col": "",
+		"":          "",
+	}
+	for input, want := range tests {
+		if got := CSSColorName(input); got != want {
+			t.Errorf("CSSColorName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFormatICalDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:                     "PT1H",
+		90 * time.Minute:              "PT1H30M",
+		36*time.Hour + 15*time.Second: "P1DT12H15S",
+		24 * time.Hour:                "P1D",
+		0:                             "",
+	}
+	for input, want := range tests {
+		if got := FormatICalDuration(input); got != want {
+			t.Errorf("FormatICalDuration(%v) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParseICalDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"-PT15M":     -15 * time.Minute,
+		"PT0S":       0,
+		"+P1DT12H":   36 * time.Hour,
+		"P1W":        7 * 24 * time.Hour,
+		"-P1DT1H30M": -(25*time.Hour + 30*time.Minute),
+	}
+	for input, want := range tests {
+		if got, ok := ParseICalDuration(input); !ok || got != want {
+			t.Errorf("ParseICalDuration(%q) = %v, %v, want %v", input, got, ok, want)
+		}
+	}
+	for _, input := range []string{"", "P", "PT", "15M", "PT15", "P1H", "PT1D", "20260301T090000Z"} {
+		if got, ok := ParseICalDuration(input); ok {
+			t.Errorf("ParseICalDuration(%q) = %v, want false", input, got)
+		}
+	}
+}
+
+func TestBuildCalendarExportWritesRFC7986Header(t *testing.T) {
+	got := BuildCalendarExport(CalendarExportInfo{
+		Name:            "Team, Ops",
+		Description:     "On-call rota",
+		Color:           "#FF0000",
+		RefreshInterval: time.Hour,
+	}, nil)
+	for _, want := range []string{
+		"NAME:Team\\, Ops\r\n",
+		"X-WR-CALNAME:Team\\, Ops\r\n",
+		"DESCRIPTION:On-call rota\r\n",
+		"X-WR-CALDESC:On-call rota\r\n",
+		"COLOR:red\r\n",
+		"X-APPLE-CALENDAR-COLOR:#FF0000\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in export:\n%s", want, got)
+		}
+	}
+	if got := BuildCalendarExport(CalendarExportInfo{}, nil); strings.Contains(got, "NAME:") || strings.Contains(got, "REFRESH-INTERVAL") {
+		t.Fatalf("expected empty header fields to be omitted, got:\n%s", got)
+	}
+}
+
+func TestParseEventProperties(t *testing.T) {
+	ical := exchangeEvent(
+		"COLOR:turquoise",
+		"IMAGE;VALUE=BINARY;ENCODING=BASE64:AAAA",
+		"IMAGE;VALUE=URI;DISPLAY=BADGE:https://example.com/badge.png",
+		"CONFERENCE;VALUE=URI;FEATURE=PHONE:tel:+1-555-0100",
+		"BEGIN:VALARM",
+		"COLOR:red",
+		"END:VALARM",
+	)
+	got := ParseEventProperties(ical)
+	want := EventProperties{Color: "turquoise", Image: "https://example.com/badge.png", Conference: "tel:+1-555-0100"}
+	if got != want {
+		t.Fatalf("ParseEventProperties() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SanitizeText removes what XML 1.0 cannot carry from calendar or contact
+// data: control characters other than tab, line feed and carriage return,
+// and the noncharacters U+FFFE and U+FFFF are dropped, and bytes that are not
+// valid UTF-8, such as the halves of a surrogate pair encoded on their own,
+// become U+FFFD. Emoji, right-to-left text and every other character are
+// kept. The input is returned unchanged when it is already clean.
+func SanitizeText(s string) string {
+	if IsCleanText(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	invalid := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// A run of invalid bytes becomes one replacement character.
+			if !invalid {
+				b.WriteRune(utf8.RuneError)
+			}
+			invalid = true
+			i += size
+			continue
+		case isXMLChar(r):
+			b.WriteString(s[i : i+size])
+		}
+		invalid = false
+		i += size
+	}
+	return b.String()
+}
+
+// IsCleanText reports whether SanitizeText would leave s unchanged.
+func IsCleanText(s string) bool {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || !isXMLChar(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+func isXMLChar(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return true
+	case r < 0x20:
+		return false
+	case r >= 0xD800 && r <= 0xDFFF, r == 0xFFFE, r == 0xFFFF:
+		return false
+	}
+	return r <= utf8.MaxRune
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "testing"
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"emoji", "SUMMARY:Party 🎉👩‍👩‍👧\r\n", "SUMMARY:Party 🎉👩‍👩‍👧\r\n"},
+		{"right-to-left", "SUMMARY:‫פגישה‬ مع الفريق‏\r\n", "SUMMARY:‫פגישה‬ مع الفريق‏\r\n"},
+		{"tab and folding", "DESCRIPTION:a\tb\r\n c\r\n", "DESCRIPTION:a\tb\r\n c\r\n"},
+		{"control characters", "SUMMARY:Lunch\x00\x08\x1b\x7f\r\n", "SUMMARY:Lunch\x7f\r\n"},
+		{"noncharacters", "SUMMARY:a￾b￿\r\n", "SUMMARY:ab\r\n"},
+		{"unpaired surrogate", "SUMMARY:x\xed\xa0\xbdy\r\n", "SUMMARY:x�y\r\n"},
+		{"truncated emoji", "SUMMARY:\xf0\x9f\x8e\r\n", "SUMMARY:�\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeText(tt.in); got != tt.want {
+				t.Fatalf("SanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if clean := IsCleanText(tt.in); clean != (tt.in == tt.want) {
+				t.Fatalf("IsCleanText(%q) = %v", tt.in, clean)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
@@ -112,3 +113,246 @@ func ExtractVCardUID(vcard string) string {
 	}
 	return ""
 }
+
+// BuildVCardCategories returns a vCard 3.0 CATEGORIES line for the given
+// group names, or "" when no non-empty names remain.
+func BuildVCardCategories(categories []string) string {
+	var escaped []string
+	seen := make(map[string]struct{})
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		key := strings.ToLower(c)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		escaped = append(escaped, EscapeVCardValue(c))
+	}
+	if len(escaped) == 0 {
+		return ""
+	}
+	return "CATEGORIES:" + strings.Join(escaped, ",")
+}
+
+// BuildVCardPhoto returns an inline vCard 3.0 PHOTO line for image data.
+// mediaType is an image MIME type such as "image/jpeg".
+func BuildVCardPhoto(mediaType string, data []byte) string {
+	imageType := strings.ToUpper(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(mediaType)), "image/"))
+	return fmt.Sprintf("PHOTO;ENCODING=b;TYPE=%s:%s", imageType, base64.StdEncoding.EncodeToString(data))
+}
+
+// ExtractVCardPropertyLines returns the unfolded lines for the named property
+// (parameters included), so callers can carry them over when rebuilding a card.
+func ExtractVCardPropertyLines(vcard, name string) []string {
+	name = strings.ToUpper(name)
+	var lines []string
+	for _, line := range UnfoldLines(vcard) {
+		key := line
+		if idx := strings.IndexAny(key, ":;"); idx >= 0 {
+			key = key[:idx]
+		} else {
+			continue
+		}
+		// Grouped properties look like "item1.PHOTO".
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			key = key[dot+1:]
+		}
+		if strings.EqualFold(key, name) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// RemoveVCardPropertyLines drops every line of the named property, grouped
+// ones included, and refolds the card.
+func RemoveVCardPropertyLines(vcard, name string) string {
+	var sb strings.Builder
+	for _, line := range UnfoldLines(vcard) {
+		key := line
+		if idx := strings.IndexAny(key, ":;"); idx >= 0 {
+			key = key[:idx]
+		}
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			key = key[dot+1:]
+		}
+		if strings.EqualFold(key, name) || line == "" {
+			continue
+		}
+		writeFoldedVCardLine(&sb, line)
+	}
+	return sb.String()
+}
+
+// AppendVCardLines inserts property lines before END:VCARD, folding them at
+// 75 octets as required by RFC 6350 section 3.2.
+func AppendVCardLines(vcard string, lines ...string) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		writeFoldedVCardLine(&sb, line)
+	}
+	if sb.Len() == 0 {
+		return vcard
+	}
+	idx := strings.LastIndex(strings.ToUpper(vcard), "END:VCARD")
+	if idx < 0 {
+		return vcard
+	}
+	return vcard[:idx] + sb.String() + vcard[idx:]
+}
+
+func writeFoldedVCardLine(sb *strings.Builder, line string) {
+	// Continuation lines spend one octet on the leading space.
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Avoid splitting a multi-byte UTF-8 sequence.
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+}
+
+// vcard4Only are vCard 4.0 properties with no vCard 3.0 equivalent, and
+// vcard4Renamed those kept under the extension names 3.0 clients read.
+var (
+	vcard4Only    = map[string]bool{"CLIENTPIDMAP": true, "XML": true}
+	vcard4Renamed = map[string]string{
+		"KIND":        "X-ADDRESSBOOKSERVER-KIND",
+		"MEMBER":      "X-ADDRESSBOOKSERVER-MEMBER",
+		"GENDER":      "X-GENDER",
+		"ANNIVERSARY": "X-ANNIVERSARY",
+	}
+)
+
+// ConvertVCardTo3 rewrites a vCard 4.0 card as vCard 3.0 for clients that
+// only read the older format, following RFC 6350 appendix A. Cards in any
+// other version are returned unchanged.
+func ConvertVCardTo3(vcard string) string {
+	lines := UnfoldLines(vcard)
+	is4 := false
+	for _, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), "VERSION:4.0") {
+			is4 = true
+			break
+		}
+	}
+	if !is4 {
+		return vcard
+	}
+	var sb strings.Builder
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if converted := convertVCardLineTo3(line); converted != "" {
+			writeFoldedVCardLine(&sb, converted)
+		}
+	}
+	return sb.String()
+}
+
+func convertVCardLineTo3(line string) string {
+	head, value, ok := splitVCardLine(line)
+	if !ok {
+		return line
+	}
+	params := splitVCardParams(head)
+	group, name := "", strings.ToUpper(params[0])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		group, name = params[0][:dot+1], name[dot+1:]
+	}
+	switch {
+	case name == "VERSION":
+		return "VERSION:3.0"
+	case vcard4Only[name]:
+		return ""
+	case vcard4Renamed[name] != "":
+		name = vcard4Renamed[name]
+	}
+	out := []string{group + name}
+	var types []string
+	for _, p := range params[1:] {
+		key, val, _ := strings.Cut(p, "=")
+		val = strings.Trim(val, `"`)
+		switch strings.ToUpper(key) {
+		case "PREF":
+			types = append(types, "pref")
+		case "TYPE":
+			types = append(types, val)
+		case "VALUE":
+			if !strings.EqualFold(val, "uri") || !(name == "TEL" || strings.HasPrefix(strings.ToLower(value), "data:")) {
+				out = append(out, p)
+			}
+		case "PID", "ALTID", "CALSCALE", "SORT-AS", "GEO", "TZ", "LABEL", "MEDIATYPE":
+		default:
+			out = append(out, p)
+		}
+	}
+	if name == "TEL" && strings.HasPrefix(strings.ToLower(value), "tel:") {
+		value = value[len("tel:"):]
+	}
+	if strings.HasPrefix(strings.ToLower(value), "data:") {
+		if meta, data, ok := strings.Cut(value[len("data:"):], ","); ok && strings.HasSuffix(strings.ToLower(meta), ";base64") {
+			mediaType := strings.TrimSuffix(strings.ToLower(meta), ";base64")
+			out = append(out, "ENCODING=b")
+			if sub := strings.TrimPrefix(mediaType, "image/"); sub != "" && sub != mediaType {
+				types = append(types, strings.ToUpper(sub))
+			}
+			value = data
+		}
+	}
+	if len(types) > 0 {
+		out = append(out, "TYPE="+strings.Join(types, ","))
+	}
+	return strings.Join(out, ";") + ":" + value
+}
+
+// splitVCardLine splits a content line at the first colon outside a quoted
+// parameter value.
+func splitVCardLine(line string) (string, string, bool) {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				return line[:i], line[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// splitVCardParams splits a property name and its parameters at semicolons
+// outside quoted values.
+func splitVCardParams(head string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(head); i++ {
+		switch head[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, head[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, head[start:])
+}
@@ -400,3 +400,85 @@ func TestBuildVCard_Company(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildVCardCategories(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories []string
+		want       string
+	}{
+		{name: "empty", categories: nil, want: ""},
+		{name: "blank entries only", categories: []string{" ", ""}, want: ""},
+		{name: "single", categories: []string{"Family"}, want: "CATEGORIES:Family"},
+		{name: "dedupes case-insensitively", categories: []string{"Family", " family ", "Work"}, want: "CATEGORIES:Family,Work"},
+		{name: "escapes separators", categories: []string{"Friends, close"}, want: "CATEGORIES:Friends\\, close"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildVCardCategories(tt.categories); got != tt.want {
+				t.Errorf("BuildVCardCategories() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendVCardLinesFoldsLongLines(t *testing.T) {
+	base := BuildVCard("test-uid", "Test User", "Test", "User", "", "", "", "", "")
+	photo := BuildVCardPhoto("image/png", []byte(strings.Repeat("x", 200)))
+	if !strings.HasPrefix(photo, "PHOTO;ENCODING=b;TYPE=PNG:") {
+		t.Fatalf("BuildVCardPhoto() = %q, want PNG inline photo", photo)
+	}
+
+	vcard := AppendVCardLines(base, photo, "", "CATEGORIES:Family")
+
+	if !strings.HasSuffix(vcard, "CATEGORIES:Family\r\nEND:VCARD\r\n") {
+		t.Fatalf("expected appended lines before END:VCARD, got:\n%s", vcard)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(vcard, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line exceeds 75 octets (%d): %q", len(line), line)
+		}
+	}
+	got := ExtractVCardPropertyLines(vcard, "photo")
+	if len(got) != 1 || got[0] != photo {
+		t.Fatalf("ExtractVCardPropertyLines() = %q, want [%q]", got, photo)
+	}
+}
+
+func TestExtractVCardPropertyLinesMatchesGroupedProperties(t *testing.T) {
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nitem1.PHOTO;VALUE=uri:https://example.com/a.jpg\r\nPHOTOGRAPHER:ignored\r\nEND:VCARD\r\n"
+
+	got := ExtractVCardPropertyLines(vcard, "PHOTO")
+	if len(got) != 1 || got[0] != "item1.PHOTO;VALUE=uri:https://example.com/a.jpg" {
+		t.Fatalf("ExtractVCardPropertyLines() = %q", got)
+	}
+}
+
+func TestRemoveVCardPropertyLines(t *testing.T) {
+	vcard := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:A\r\nPHOTO;ENCODING=b;TYPE=PNG:AAAA\r\n BBBB\r\nitem1.PHOTO;VALUE=uri:https://example.com/a.jpg\r\nPHOTOGRAPHER:kept\r\nEND:VCARD\r\n"
+
+	got := RemoveVCardPropertyLines(vcard, "photo")
+	want := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:A\r\nPHOTOGRAPHER:kept\r\nEND:VCARD\r\n"
+	if got != want {
+		t.Fatalf("RemoveVCardPropertyLines() = %q, want %q", got, want)
+	}
+}
+
+func TestConvertVCardTo3(t *testing.T) {
+	card := "BEGIN:VCARD\r\nVERSION:4.0\r\nUID:1\r\nFN:Ann\r\nKIND:individual\r\n" +
+		"TEL;VALUE=uri;TYPE=\"cell,voice\";PREF=1:tel:+1-555-0100\r\n" +
+		"PHOTO:data:image/png;base64,iVBOR\r\nEMAIL;PID=1.1:ann@example.com\r\n" +
+		"CLIENTPIDMAP:1;urn:uuid:x\r\nitem1.ANNIVERSARY:20100612\r\nEND:VCARD\r\n"
+	want := "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:1\r\nFN:Ann\r\nX-ADDRESSBOOKSERVER-KIND:individual\r\n" +
+		"TEL;TYPE=cell,voice,pref:+1-555-0100\r\n" +
+		"PHOTO;ENCODING=b;TYPE=PNG:iVBOR\r\nEMAIL:ann@example.com\r\n" +
+		"item1.X-ANNIVERSARY:20100612\r\nEND:VCARD\r\n"
+	if got := ConvertVCardTo3(card); got != want {
+		t.Fatalf("ConvertVCardTo3() =\n%q\nwant\n%q", got, want)
+	}
+	v3 := "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Bob\r\nEND:VCARD\r\n"
+	if got := ConvertVCardTo3(v3); got != v3 {
+		t.Fatalf("3.0 card changed: %q", got)
+	}
+}
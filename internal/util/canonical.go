@@ -0,0 +1,144 @@
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CanonicalETag returns the ETag of a calendar object or vCard: the SHA-256
+// of its canonical form.
+func CanonicalETag(raw string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(Canonicalize(raw))))
+}
+
+// Canonicalize returns the deterministic serialization of an iCalendar or
+// vCard payload that ETags are computed from. Lines are unfolded and end in
+// CRLF, property and parameter names are upper-cased, parameters are sorted,
+// and the properties and sub-components of every component are sorted, so
+// refolding, reordering or line-ending changes leave it unchanged. Values
+// are kept exactly as sent.
+func Canonicalize(raw string) string {
+	type component struct {
+		name     string
+		lines    []string
+		children []string
+	}
+	serialize := func(c *component) string {
+		sort.Strings(c.lines)
+		sort.Strings(c.children)
+		var b strings.Builder
+		b.WriteString("BEGIN:" + c.name + "\r\n")
+		for _, line := range c.lines {
+			b.WriteString(line + "\r\n")
+		}
+		for _, child := range c.children {
+			b.WriteString(child)
+		}
+		b.WriteString("END:" + c.name + "\r\n")
+		return b.String()
+	}
+
+	root := &component{}
+	stack := []*component{root}
+	for _, line := range unfoldContentLines(raw) {
+		line = canonicalContentLine(line)
+		name, value, _ := strings.Cut(line, ":")
+		current := stack[len(stack)-1]
+		switch {
+		case name == "BEGIN":
+			stack = append(stack, &component{name: strings.ToUpper(value)})
+		case name == "END" && len(stack) > 1:
+			stack = stack[:len(stack)-1]
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, serialize(current))
+		default:
+			current.lines = append(current.lines, line)
+		}
+	}
+	// Close components the payload left open.
+	for len(stack) > 1 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, serialize(current))
+	}
+
+	sort.Strings(root.lines)
+	sort.Strings(root.children)
+	var b strings.Builder
+	for _, line := range root.lines {
+		b.WriteString(line + "\r\n")
+	}
+	for _, child := range root.children {
+		b.WriteString(child)
+	}
+	return b.String()
+}
+
+// unfoldContentLines splits a payload into logical lines, joining folded
+// continuations and dropping blank lines.
+func unfoldContentLines(raw string) []string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	raw = strings.ReplaceAll(raw, "\r", "\n")
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// canonicalContentLine upper-cases the property and parameter names of one
+// unfolded line and sorts its parameters. Quoted parameter values may
+// contain ';' and ':'.
+func canonicalContentLine(line string) string {
+	var parts []string
+	start, inQuotes := 0, false
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !inQuotes {
+				end = i
+			}
+		}
+		if end != len(line) {
+			break
+		}
+	}
+	parts = append(parts, line[start:end])
+
+	params := parts[1:]
+	for i, param := range params {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			params[i] = strings.ToUpper(key) + "=" + value
+		} else {
+			params[i] = strings.ToUpper(param)
+		}
+	}
+	sort.Strings(params)
+
+	head := strings.ToUpper(parts[0])
+	if len(params) > 0 {
+		head += ";" + strings.Join(params, ";")
+	}
+	if end == len(line) {
+		return head
+	}
+	return head + line[end:]
+}
@@ -0,0 +1,34 @@
+package util
+
+import "testing"
+
+func TestCanonicalETagIgnoresSerializationDetails(t *testing.T) {
+	base := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\r\nSUMMARY:Planning meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	variants := map[string]string{
+		"LF line endings":   "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:1\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\nSUMMARY:Planning meeting\nEND:VEVENT\nEND:VCALENDAR\n",
+		"folded line":       "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\r\nSUMMARY:Planning\r\n  meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"reordered":         "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Planning meeting\r\nUID:1\r\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\r\nEND:VEVENT\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n",
+		"parameter order":   "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;VALUE=DATE-TIME;TZID=Europe/Berlin:20260105T090000\r\nSUMMARY:Planning meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"name case":         "BEGIN:VCALENDAR\r\nversion:2.0\r\nBEGIN:VEVENT\r\nuid:1\r\ndtstart;tzid=Europe/Berlin;value=DATE-TIME:20260105T090000\r\nSummary:Planning meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"missing final END": "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\r\nSUMMARY:Planning meeting\r\nEND:VEVENT\r\n",
+	}
+	want := CanonicalETag(base)
+	for name, raw := range variants {
+		if got := CanonicalETag(raw); got != want {
+			t.Errorf("%s: ETag = %s, want %s\ncanonical:\n%s", name, got, want, Canonicalize(raw))
+		}
+	}
+
+	changed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART;TZID=Europe/Berlin;VALUE=DATE-TIME:20260105T090000\r\nSUMMARY:planning meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if CanonicalETag(changed) == want {
+		t.Fatal("a changed value must change the ETag")
+	}
+}
+
+func TestCanonicalizeKeepsQuotedParameters(t *testing.T) {
+	got := Canonicalize("ATTENDEE;cn=\"Doe; Jane: PhD\";ROLE=CHAIR:mailto:jane@example.com\n")
+	want := "ATTENDEE;CN=\"Doe; Jane: PhD\";ROLE=CHAIR:mailto:jane@example.com\r\n"
+	if got != want {
+		t.Fatalf("Canonicalize() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// EmailLookupKey returns the form of an email address that contacts and
+// users are indexed and looked up by: trimmed, without a mailto: prefix,
+// lower-cased and in Unicode normal form C, so a composed and a decomposed
+// "é" give the same key.
+func EmailLookupKey(value string) string {
+	s := strings.TrimSpace(value)
+	if len(s) > 7 && strings.EqualFold(s[:7], "mailto:") {
+		s = s[7:]
+	}
+	if !strings.Contains(s, "@") {
+		return ""
+	}
+	return norm.NFC.String(strings.ToLower(s))
+}
+
+// PhoneLookupKey returns the form of a phone number that contacts are
+// indexed and looked up by: its digits without an extension or international
+// prefix, so "+1 (415) 555-0100" and "0014155550100" share a key. Values
+// with fewer than three digits have no key.
+func PhoneLookupKey(value string) string {
+	s := strings.TrimSpace(value)
+	if len(s) >= 4 && strings.EqualFold(s[:4], "tel:") {
+		s = s[4:]
+	}
+	lower := strings.ToLower(s)
+	for _, marker := range []string{";ext=", "ext", "x"} {
+		if idx := strings.Index(lower, marker); idx >= 0 {
+			s = s[:idx]
+			break
+		}
+	}
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	key := digits.String()
+	if !strings.HasPrefix(strings.TrimSpace(s), "+") {
+		key = strings.TrimPrefix(key, "00")
+	}
+	if len(key) < 3 {
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestEmailLookupKey(t *testing.T) {
+	for in, want := range map[string]string{
+		" Ann@Example.COM ":      "ann@example.com",
+		"mailto:ann@example.com": "ann@example.com",
+		"not-an-address":         "",
+		"Rene\u0301@Example.com": "ren\u00e9@example.com",
+	} {
+		if got := EmailLookupKey(in); got != want {
+			t.Errorf("EmailLookupKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPhoneLookupKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"+1 (415) 555-0100":         "14155550100",
+		"0014155550100":             "14155550100",
+		"tel:+1-415-555-0100;ext=7": "14155550100",
+		"415.555.0100 x12":          "4155550100",
+		"12":                        "",
+	} {
+		if got := PhoneLookupKey(in); got != want {
+			t.Errorf("PhoneLookupKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+-- v1.1.10: public appointment booking pages. Visitors pick a free slot within
+-- the owner's working hours and the booking is added to calendar_id.
+
+CREATE TABLE IF NOT EXISTS booking_pages (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    duration_minutes INT NOT NULL,
+    buffer_minutes INT NOT NULL DEFAULT 0,
+    notice_minutes INT NOT NULL DEFAULT 60,
+    window_days INT NOT NULL DEFAULT 30,
+    day_start_minute INT NOT NULL DEFAULT 540,
+    day_end_minute INT NOT NULL DEFAULT 1020,
+    weekdays INT NOT NULL DEFAULT 62,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_pages_user ON booking_pages(user_id);
+
+UPDATE application SET value = 'v1.1.10' WHERE key = 'version';
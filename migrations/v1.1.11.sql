@@ -0,0 +1,12 @@
+-- v1.1.11: canonical payload forms. ETags are now computed from a canonical
+-- serialization of each calendar object and vCard so refolding or reordering
+-- does not change them. Rows written before this stay NULL until backfilled
+-- by a repairing consistency check, which also replaces their byte-hash ETags.
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS canonical_ical TEXT;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS canonical_vcard TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_canonical_pending ON events(id) WHERE canonical_ical IS NULL;
+CREATE INDEX IF NOT EXISTS idx_contacts_canonical_pending ON contacts(id) WHERE canonical_vcard IS NULL;
+
+UPDATE application SET value = 'v1.1.11' WHERE key = 'version';
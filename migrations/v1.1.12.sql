@@ -0,0 +1,41 @@
+-- v1.1.12: change feed. Every event and contact write and every tombstone
+-- records the transaction that made it and a position from one shared
+-- sequence, so GET /api/changes can list changes across collections in
+-- order. Existing rows all get the position of this migration.
+
+CREATE SEQUENCE IF NOT EXISTS change_seq;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+ALTER TABLE events ADD COLUMN IF NOT EXISTS change_created BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS change_created BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS change_txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('change_seq');
+
+CREATE OR REPLACE FUNCTION record_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_txid = pg_current_xact_id();
+    NEW.change_seq = nextval('change_seq');
+    NEW.change_created = (TG_OP = 'INSERT');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_events_record_change ON events;
+CREATE TRIGGER trg_events_record_change
+BEFORE INSERT OR UPDATE ON events
+FOR EACH ROW EXECUTE FUNCTION record_change();
+
+DROP TRIGGER IF EXISTS trg_contacts_record_change ON contacts;
+CREATE TRIGGER trg_contacts_record_change
+BEFORE INSERT OR UPDATE ON contacts
+FOR EACH ROW EXECUTE FUNCTION record_change();
+
+CREATE INDEX IF NOT EXISTS idx_events_change ON events(change_txid, change_seq);
+CREATE INDEX IF NOT EXISTS idx_contacts_change ON contacts(change_txid, change_seq);
+CREATE INDEX IF NOT EXISTS idx_deleted_resources_change ON deleted_resources(change_txid, change_seq);
+
+UPDATE application SET value = 'v1.1.12' WHERE key = 'version';
@@ -0,0 +1,13 @@
+-- v1.1.13: conferencing webhooks. A calendar can name a webhook that
+-- provisions a meeting link for events asking for one; the link is written
+-- into the event's CONFERENCE property before it is stored.
+
+CREATE TABLE IF NOT EXISTS conference_hooks (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.13' WHERE key = 'version';
@@ -0,0 +1,15 @@
+-- v1.1.14: scheduling resources. A calendar can stand for a room or other
+-- resource with its own address; events inviting that address are booked
+-- into the calendar when it is free and declined when it is not.
+
+CREATE TABLE IF NOT EXISTS scheduling_resources (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    email TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL DEFAULT 'ROOM',
+    policy TEXT NOT NULL DEFAULT 'first-come',
+    priority_organizers TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.14' WHERE key = 'version';
@@ -0,0 +1,26 @@
+-- v1.1.15: contact lookup keys. Every email address and phone number of a
+-- contact is stored in normalized form so callers can find contacts by them
+-- without reading each vCard. New writes fill the keys from Go; existing
+-- rows are backfilled here with the same normalization. Folded lines are not
+-- unfolded by the backfill and are picked up the next time a contact is saved.
+
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS email_keys TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS phone_keys TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE contacts SET
+    email_keys = ARRAY(
+        SELECT DISTINCT lower(regexp_replace(btrim(m[1]), '^mailto:', '', 'i'))
+        FROM regexp_matches(raw_vcard, '(?:^|\n)(?:[A-Za-z0-9-]+\.)?EMAIL(?:;[^:\r\n]*)?:([^\r\n]*@[^\r\n]*)', 'gi') AS m
+    ),
+    phone_keys = ARRAY(
+        SELECT DISTINCT k FROM (
+            SELECT regexp_replace(regexp_replace(regexp_replace(m[1], '(;ext=|x).*$', '', 'i'), '[^0-9+]', '', 'g'), '^(\+|00)', '') AS k
+            FROM regexp_matches(raw_vcard, '(?:^|\n)(?:[A-Za-z0-9-]+\.)?TEL(?:;[^:\r\n]*)?:([^\r\n]*)', 'gi') AS m
+        ) keys
+        WHERE length(k) >= 3
+    );
+
+CREATE INDEX IF NOT EXISTS idx_contacts_email_keys ON contacts USING GIN (email_keys);
+CREATE INDEX IF NOT EXISTS idx_contacts_phone_keys ON contacts USING GIN (phone_keys);
+
+UPDATE application SET value = 'v1.1.15' WHERE key = 'version';
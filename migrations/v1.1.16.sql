@@ -0,0 +1,11 @@
+-- v1.1.16: task feed links. Like free/busy links, the token in the URL is the
+-- only credential, so each user has at most one and can regenerate it.
+
+CREATE TABLE IF NOT EXISTS task_feed_links (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.16' WHERE key = 'version';
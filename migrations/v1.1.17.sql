@@ -0,0 +1,23 @@
+-- v1.1.17: batch event deletion. Deleting many events in one statement still
+-- tombstones each of them, but the calendar's ctag is bumped once by the
+-- deleting transaction instead of once per row. The transaction signals this
+-- with the calcard.batch_delete setting.
+
+CREATE OR REPLACE FUNCTION increment_calendar_ctag()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF current_setting('calcard.batch_delete', true) IS DISTINCT FROM 'on' THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = OLD.calendar_id;
+        END IF;
+        INSERT INTO deleted_resources (resource_type, collection_id, uid, resource_name)
+        VALUES ('event', OLD.calendar_id, OLD.uid, OLD.resource_name);
+        RETURN OLD;
+    ELSE
+        UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = NEW.calendar_id;
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE application SET value = 'v1.1.17' WHERE key = 'version';
@@ -0,0 +1,27 @@
+-- v1.1.18: calendar merge and split. Moving many events between calendars
+-- bumps each calendar's ctag once, like batch deletion does, so the ctag
+-- trigger now skips inserts and updates as well as deletes while the
+-- transaction sets calcard.batch_ctag. This replaces calcard.batch_delete.
+
+CREATE OR REPLACE FUNCTION increment_calendar_ctag()
+RETURNS TRIGGER AS $$
+DECLARE
+    batched BOOLEAN := current_setting('calcard.batch_ctag', true) IS NOT DISTINCT FROM 'on';
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF NOT batched THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = OLD.calendar_id;
+        END IF;
+        INSERT INTO deleted_resources (resource_type, collection_id, uid, resource_name)
+        VALUES ('event', OLD.calendar_id, OLD.uid, OLD.resource_name);
+        RETURN OLD;
+    ELSE
+        IF NOT batched THEN
+            UPDATE calendars SET ctag = ctag + 1, updated_at = NOW() WHERE id = NEW.calendar_id;
+        END IF;
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE application SET value = 'v1.1.18' WHERE key = 'version';
@@ -0,0 +1,19 @@
+-- v1.1.19: DAV sign-in history. A row records a user's sign-in from an
+-- address, a refused password, or a sign-in refused by the soft lockout, so
+-- users can spot a leaked app password. Rows are kept for 90 days.
+
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    app_password_id BIGINT REFERENCES app_passwords(id) ON DELETE SET NULL,
+    outcome TEXT NOT NULL,
+    new_address BOOLEAN NOT NULL DEFAULT FALSE,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_user_created ON auth_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_events_created ON auth_events(created_at);
+
+UPDATE application SET value = 'v1.1.19' WHERE key = 'version';
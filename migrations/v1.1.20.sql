@@ -0,0 +1,23 @@
+-- v1.1.20: background jobs. Long operations such as imports and backups run
+-- in the background and keep their progress here, so clients can poll it and
+-- a restart does not lose it. Jobs a restart cut short are marked
+-- interrupted at startup. Finished jobs are kept for 30 days.
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    item_errors JSONB NOT NULL DEFAULT '[]',
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_created ON jobs(user_id, created_at DESC);
+
+UPDATE application SET value = 'v1.1.20' WHERE key = 'version';
@@ -0,0 +1,9 @@
+-- v1.1.21: per-user regional preferences. The locale, timezone and first day
+-- of the week shape dates in server-generated pages and email. Empty values,
+-- and a NULL week start, fall back to the server defaults.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS week_start SMALLINT NULL CHECK (week_start BETWEEN 0 AND 6);
+
+UPDATE application SET value = 'v1.1.21' WHERE key = 'version';
@@ -0,0 +1,24 @@
+-- v1.1.22: sharing groups. A user can gather other users into a named group,
+-- such as "family", and share a calendar or address book with the whole
+-- group at once. The group is an ACL principal, /dav/principals/groups/<id>/,
+-- so adding or removing a member changes their access to everything shared
+-- with the group without touching the ACLs.
+
+CREATE TABLE IF NOT EXISTS user_groups (
+    id BIGSERIAL PRIMARY KEY,
+    owner_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (owner_user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS user_group_members (
+    group_id BIGINT NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_group_members_user ON user_group_members(user_id);
+
+UPDATE application SET value = 'v1.1.22' WHERE key = 'version';
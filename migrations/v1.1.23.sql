@@ -0,0 +1,6 @@
+-- v1.1.23: index ACL grants by principal. Listing a user's calendars starts
+-- from the grants made to their principals, so this lets it find the shared
+-- calendars without reading every ACL entry.
+CREATE INDEX IF NOT EXISTS idx_acl_grants_principal ON acl_entries(principal_href, resource_path) WHERE is_grant = TRUE;
+
+UPDATE application SET value = 'v1.1.23' WHERE key = 'version';
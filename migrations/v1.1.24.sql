@@ -0,0 +1,24 @@
+-- v1.1.24: restore the foreign keys from events to calendars and contacts to
+-- address books on databases where they were dropped by hand. They are added
+-- NOT VALID so rows already orphaned do not block the upgrade; new writes are
+-- checked at once, and fsck reports and removes the existing orphans.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'events'::regclass AND confrelid = 'calendars'::regclass AND contype = 'f'
+    ) THEN
+        ALTER TABLE events ADD CONSTRAINT events_calendar_id_fkey
+            FOREIGN KEY (calendar_id) REFERENCES calendars(id) ON DELETE CASCADE NOT VALID;
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'contacts'::regclass AND confrelid = 'address_books'::regclass AND contype = 'f'
+    ) THEN
+        ALTER TABLE contacts ADD CONSTRAINT contacts_address_book_id_fkey
+            FOREIGN KEY (address_book_id) REFERENCES address_books(id) ON DELETE CASCADE NOT VALID;
+    END IF;
+END $$;
+
+UPDATE application SET value = 'v1.1.24' WHERE key = 'version';
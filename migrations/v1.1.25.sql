@@ -0,0 +1,17 @@
+-- v1.1.25: saved places that users reuse across events, with an address and
+-- optional coordinates for map links.
+
+CREATE TABLE IF NOT EXISTS locations (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name),
+    CHECK ((latitude IS NULL) = (longitude IS NULL))
+);
+
+UPDATE application SET value = 'v1.1.25' WHERE key = 'version';
@@ -0,0 +1,15 @@
+-- v1.1.26: agenda digest schedules. Each user can have one daily or weekly
+-- digest, sent at a minute of the day in their timezone.
+
+CREATE TABLE IF NOT EXISTS digest_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    frequency TEXT NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    send_minute INT NOT NULL CHECK (send_minute BETWEEN 0 AND 1439),
+    weekday SMALLINT NOT NULL DEFAULT 1 CHECK (weekday BETWEEN 0 AND 6),
+    working_days_only BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sent_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.26' WHERE key = 'version';
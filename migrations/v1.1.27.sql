@@ -0,0 +1,18 @@
+-- v1.1.27: expiring read-only links to single events, with a count of how
+-- often each was opened.
+
+CREATE TABLE IF NOT EXISTS event_links (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    access_count BIGINT NOT NULL DEFAULT 0,
+    last_accessed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_links_event ON event_links(calendar_id, uid);
+
+UPDATE application SET value = 'v1.1.27' WHERE key = 'version';
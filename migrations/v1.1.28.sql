@@ -0,0 +1,117 @@
+-- v1.1.28: tombstones for calendars and address books a user lost, by
+-- deletion or unsharing, so home-set sync can report them.
+
+CREATE TABLE IF NOT EXISTS collection_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    owner_user_id BIGINT NOT NULL,
+    slug TEXT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    change_txid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+    change_seq BIGINT NOT NULL DEFAULT nextval('change_seq')
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_tombstones_user ON collection_tombstones(user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_collection_tombstones_change ON collection_tombstones(user_id, change_txid, change_seq);
+
+-- The users an ACL principal stands for: a user, or the members of a group.
+-- Users being deleted are left out.
+CREATE OR REPLACE FUNCTION collection_tombstone_users(principals TEXT[])
+RETURNS TABLE (user_id BIGINT) AS $$
+    SELECT u.id FROM unnest(principals) p
+    JOIN users u ON u.id = substring(p FROM '^/dav/principals/([0-9]+)/$')::bigint
+    UNION
+    SELECT gm.user_id FROM unnest(principals) p
+    JOIN user_group_members gm ON gm.group_id = substring(p FROM '^/dav/principals/groups/([0-9]+)/$')::bigint
+    JOIN users u ON u.id = gm.user_id
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION record_calendar_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', OLD.id, t.user_id, OLD.user_id, OLD.slug
+    FROM collection_tombstone_users(ARRAY['/dav/principals/' || OLD.user_id::text || '/'] || ARRAY(
+        SELECT principal_href FROM acl_entries
+        WHERE resource_path = '/dav/calendars/' || OLD.id::text AND is_grant
+    )) t;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_calendars_tombstones ON calendars;
+CREATE TRIGGER trg_calendars_tombstones
+AFTER DELETE ON calendars
+FOR EACH ROW EXECUTE FUNCTION record_calendar_tombstones();
+
+CREATE OR REPLACE FUNCTION record_address_book_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id)
+    SELECT 'addressbook', OLD.id, t.user_id, OLD.user_id
+    FROM collection_tombstone_users(ARRAY['/dav/principals/' || OLD.user_id::text || '/'] || ARRAY(
+        SELECT principal_href FROM acl_entries
+        WHERE resource_path = '/dav/addressbooks/' || OLD.id::text AND is_grant
+    )) t;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_address_books_tombstones ON address_books;
+CREATE TRIGGER trg_address_books_tombstones
+AFTER DELETE ON address_books
+FOR EACH ROW EXECUTE FUNCTION record_address_book_tombstones();
+
+-- Revoked collection grants. Readers skip tombstones for collections the
+-- user can still reach, so ACL rewrites that keep a grant are harmless.
+CREATE OR REPLACE FUNCTION record_acl_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT OLD.is_grant THEN
+        RETURN OLD;
+    END IF;
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', c.id, t.user_id, c.user_id, c.slug
+    FROM calendars c, collection_tombstone_users(ARRAY[OLD.principal_href]) t
+    WHERE c.id = substring(OLD.resource_path FROM '^/dav/calendars/([0-9]+)/?$')::bigint AND t.user_id <> c.user_id
+    UNION ALL
+    SELECT 'addressbook', b.id, t.user_id, b.user_id, NULL
+    FROM address_books b, collection_tombstone_users(ARRAY[OLD.principal_href]) t
+    WHERE b.id = substring(OLD.resource_path FROM '^/dav/addressbooks/([0-9]+)/?$')::bigint AND t.user_id <> b.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_acl_entries_tombstones ON acl_entries;
+CREATE TRIGGER trg_acl_entries_tombstones
+AFTER DELETE ON acl_entries
+FOR EACH ROW EXECUTE FUNCTION record_acl_tombstones();
+
+-- Users leaving a sharing group lose the collections granted to it.
+CREATE OR REPLACE FUNCTION record_group_member_tombstones()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO collection_tombstones (collection_type, collection_id, user_id, owner_user_id, slug)
+    SELECT 'calendar', c.id, OLD.user_id, c.user_id, c.slug
+    FROM acl_entries a
+    JOIN calendars c ON c.id = substring(a.resource_path FROM '^/dav/calendars/([0-9]+)/?$')::bigint
+    WHERE a.principal_href = '/dav/principals/groups/' || OLD.group_id::text || '/' AND a.is_grant
+      AND c.user_id <> OLD.user_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id)
+    UNION ALL
+    SELECT 'addressbook', b.id, OLD.user_id, b.user_id, NULL
+    FROM acl_entries a
+    JOIN address_books b ON b.id = substring(a.resource_path FROM '^/dav/addressbooks/([0-9]+)/?$')::bigint
+    WHERE a.principal_href = '/dav/principals/groups/' || OLD.group_id::text || '/' AND a.is_grant
+      AND b.user_id <> OLD.user_id AND EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_user_group_members_tombstones ON user_group_members;
+CREATE TRIGGER trg_user_group_members_tombstones
+AFTER DELETE ON user_group_members
+FOR EACH ROW EXECUTE FUNCTION record_group_member_tombstones();
+
+UPDATE application SET value = 'v1.1.28' WHERE key = 'version';
@@ -0,0 +1,18 @@
+-- v1.1.29: anonymous usage snapshots for the admin usage page, written only
+-- when APP_TELEMETRY_ENABLED is set.
+
+CREATE TABLE IF NOT EXISTS usage_stats (
+    id BIGSERIAL PRIMARY KEY,
+    collected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    users BIGINT NOT NULL DEFAULT 0,
+    active_users BIGINT NOT NULL DEFAULT 0,
+    calendars BIGINT NOT NULL DEFAULT 0,
+    address_books BIGINT NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    contacts BIGINT NOT NULL DEFAULT 0,
+    client_families JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_stats_collected ON usage_stats(collected_at DESC);
+
+UPDATE application SET value = 'v1.1.29' WHERE key = 'version';
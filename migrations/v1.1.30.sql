@@ -0,0 +1,21 @@
+-- v1.1.30: alias email addresses users can sign in and be scheduled by,
+-- and case-insensitive lookup of primary addresses.
+
+CREATE TABLE IF NOT EXISTS user_email_aliases (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    email_key TEXT NOT NULL,
+    token_hash TEXT NULL,
+    verified_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, email_key)
+);
+
+-- An address belongs to at most one account once verified; unverified
+-- claims do not block its owner.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_aliases_verified ON user_email_aliases(email_key) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email_aliases_token ON user_email_aliases(token_hash) WHERE token_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_primary_email_lower ON users(lower(primary_email));
+
+UPDATE application SET value = 'v1.1.30' WHERE key = 'version';
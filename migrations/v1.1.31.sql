@@ -0,0 +1,41 @@
+-- v1.1.31: who made each change, and per-collection email notifications
+-- of changes made by other users.
+
+-- The user a write acts for, taken from the transaction-local calcard.actor
+-- setting; NULL for writes made by the server itself.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL;
+ALTER TABLE deleted_resources ADD COLUMN IF NOT EXISTS changed_by BIGINT NULL DEFAULT NULLIF(current_setting('calcard.actor', true), '')::bigint;
+
+-- Writes that leave the content as it was, such as the touches that follow
+-- ACL changes, are not attributed to anyone.
+CREATE OR REPLACE FUNCTION record_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_txid = pg_current_xact_id();
+    NEW.change_seq = nextval('change_seq');
+    NEW.change_created = (TG_OP = 'INSERT');
+    IF TG_OP = 'INSERT' THEN
+        NEW.changed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint;
+    ELSIF NEW.etag IS DISTINCT FROM OLD.etag THEN
+        NEW.changed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint;
+    ELSE
+        NEW.changed_by = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Per-collection email notifications of changes made by other users
+CREATE TABLE IF NOT EXISTS collection_notifications (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    cursor_txid BIGINT NOT NULL DEFAULT 0,
+    cursor_seq BIGINT NOT NULL DEFAULT 0,
+    last_notified_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, collection_type, collection_id)
+);
+
+UPDATE application SET value = 'v1.1.31' WHERE key = 'version';
@@ -0,0 +1,13 @@
+-- v1.1.32: write-ahead journal of the writes bulk jobs intend to make, so
+-- an import interrupted by a restart can be resumed or aborted.
+
+CREATE TABLE IF NOT EXISTS job_journal (
+    job_id TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    collection_id BIGINT NOT NULL,
+    payload TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NULL,
+    PRIMARY KEY (job_id, seq)
+);
+
+UPDATE application SET value = 'v1.1.32' WHERE key = 'version';
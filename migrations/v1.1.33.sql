@@ -0,0 +1,134 @@
+-- v1.1.33: store each distinct iCalendar and vCard body once, shared by
+-- every event and contact that carries it and counted by reference.
+
+CREATE TABLE IF NOT EXISTS content_bodies (
+    hash TEXT PRIMARY KEY,
+    body TEXT NOT NULL,
+    ref_count BIGINT NOT NULL DEFAULT 0
+);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS raw_ical_hash TEXT NULL;
+ALTER TABLE events ALTER COLUMN raw_ical DROP NOT NULL;
+ALTER TABLE contacts ADD COLUMN IF NOT EXISTS raw_vcard_hash TEXT NULL;
+ALTER TABLE contacts ALTER COLUMN raw_vcard DROP NOT NULL;
+
+-- content_body_put stores body once and returns its hash. The no-op update
+-- locks an existing row until the caller commits, so a concurrent release
+-- cannot delete it before the caller's reference is counted.
+CREATE OR REPLACE FUNCTION content_body_put(body TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    h TEXT := encode(sha256(convert_to(body, 'UTF8')), 'hex');
+BEGIN
+    INSERT INTO content_bodies (hash, body) VALUES (h, body)
+    ON CONFLICT (hash) DO UPDATE SET ref_count = content_bodies.ref_count;
+    RETURN h;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION content_body_acquire(h TEXT)
+RETURNS VOID AS $$
+BEGIN
+    UPDATE content_bodies SET ref_count = ref_count + 1 WHERE hash = h;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION content_body_release(h TEXT)
+RETURNS VOID AS $$
+BEGIN
+    UPDATE content_bodies SET ref_count = ref_count - 1 WHERE hash = h;
+    DELETE FROM content_bodies WHERE hash = h AND ref_count <= 0;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Bodies written to raw_ical or raw_vcard move to content_bodies, leaving
+-- the column NULL and its hash behind.
+CREATE OR REPLACE FUNCTION store_event_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.raw_ical IS NOT NULL THEN
+        NEW.raw_ical_hash = content_body_put(NEW.raw_ical);
+        NEW.raw_ical = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION store_contact_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.raw_vcard IS NOT NULL THEN
+        NEW.raw_vcard_hash = content_body_put(NEW.raw_vcard);
+        NEW.raw_vcard = NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- References are counted once the row is written, so an upsert that ends
+-- up updating counts only the body it keeps.
+CREATE OR REPLACE FUNCTION count_event_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    ELSIF NEW.raw_ical_hash IS DISTINCT FROM OLD.raw_ical_hash THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION count_contact_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    ELSIF NEW.raw_vcard_hash IS DISTINCT FROM OLD.raw_vcard_hash THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Move the bodies already stored, without touching change feeds or ctags.
+ALTER TABLE events DISABLE TRIGGER USER;
+ALTER TABLE contacts DISABLE TRIGGER USER;
+INSERT INTO content_bodies (hash, body, ref_count)
+SELECT encode(sha256(convert_to(raw_ical, 'UTF8')), 'hex'), MIN(raw_ical), COUNT(*) FROM events WHERE raw_ical IS NOT NULL GROUP BY 1
+ON CONFLICT (hash) DO UPDATE SET ref_count = content_bodies.ref_count + EXCLUDED.ref_count;
+UPDATE events SET raw_ical_hash = encode(sha256(convert_to(raw_ical, 'UTF8')), 'hex'), raw_ical = NULL WHERE raw_ical IS NOT NULL;
+INSERT INTO content_bodies (hash, body, ref_count)
+SELECT encode(sha256(convert_to(raw_vcard, 'UTF8')), 'hex'), MIN(raw_vcard), COUNT(*) FROM contacts WHERE raw_vcard IS NOT NULL GROUP BY 1
+ON CONFLICT (hash) DO UPDATE SET ref_count = content_bodies.ref_count + EXCLUDED.ref_count;
+UPDATE contacts SET raw_vcard_hash = encode(sha256(convert_to(raw_vcard, 'UTF8')), 'hex'), raw_vcard = NULL WHERE raw_vcard IS NOT NULL;
+ALTER TABLE events ENABLE TRIGGER USER;
+ALTER TABLE contacts ENABLE TRIGGER USER;
+
+DROP TRIGGER IF EXISTS trg_events_store_body ON events;
+CREATE TRIGGER trg_events_store_body
+BEFORE INSERT OR UPDATE OF raw_ical ON events
+FOR EACH ROW EXECUTE FUNCTION store_event_body();
+
+DROP TRIGGER IF EXISTS trg_events_count_body ON events;
+CREATE TRIGGER trg_events_count_body
+AFTER INSERT OR UPDATE OF raw_ical_hash OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION count_event_body();
+
+DROP TRIGGER IF EXISTS trg_contacts_store_body ON contacts;
+CREATE TRIGGER trg_contacts_store_body
+BEFORE INSERT OR UPDATE OF raw_vcard ON contacts
+FOR EACH ROW EXECUTE FUNCTION store_contact_body();
+
+DROP TRIGGER IF EXISTS trg_contacts_count_body ON contacts;
+CREATE TRIGGER trg_contacts_count_body
+AFTER INSERT OR UPDATE OF raw_vcard_hash OR DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION count_contact_body();
+
+UPDATE application SET value = 'v1.1.33' WHERE key = 'version';
@@ -0,0 +1,32 @@
+-- v1.1.34: time-limited admin impersonation grants, and an audit trail of
+-- every request made with one.
+
+CREATE TABLE IF NOT EXISTS impersonations (
+    id BIGSERIAL PRIMARY KEY,
+    admin_user_id BIGINT NULL REFERENCES users(id) ON DELETE SET NULL,
+    admin_email TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'write')),
+    reason TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonations_created ON impersonations(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS impersonation_requests (
+    id BIGSERIAL PRIMARY KEY,
+    impersonation_id BIGINT NOT NULL REFERENCES impersonations(id) ON DELETE CASCADE,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_impersonation_requests_grant ON impersonation_requests(impersonation_id, created_at);
+
+UPDATE application SET value = 'v1.1.34' WHERE key = 'version';
@@ -0,0 +1,26 @@
+-- v1.1.35: per-calendar retention policies that delete events once they
+-- are old enough, and a log of the events they deleted.
+
+CREATE TABLE IF NOT EXISTS calendar_retention (
+    calendar_id BIGINT PRIMARY KEY REFERENCES calendars(id) ON DELETE CASCADE,
+    months INTEGER NOT NULL CHECK (months BETWEEN 1 AND 1200),
+    last_run_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS retention_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    summary TEXT NULL,
+    dtstart TIMESTAMPTZ NULL,
+    dtend TIMESTAMPTZ NULL,
+    cutoff TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_deletions_calendar ON retention_deletions(calendar_id, deleted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_retention_deletions_deleted ON retention_deletions(deleted_at);
+
+UPDATE application SET value = 'v1.1.35' WHERE key = 'version';
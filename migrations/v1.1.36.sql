@@ -0,0 +1,89 @@
+-- v1.1.36: a history of event revisions, recorded by trigger, so a
+-- calendar can be viewed as it stood at a past time.
+
+CREATE TABLE IF NOT EXISTS event_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    raw_ical TEXT NULL,
+    raw_ical_hash TEXT NULL,
+    summary TEXT NULL,
+    location TEXT NULL,
+    dtstart TIMESTAMPTZ NULL,
+    dtend TIMESTAMPTZ NULL,
+    all_day BOOLEAN NOT NULL DEFAULT FALSE,
+    changed_by BIGINT NULL,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_revisions_calendar ON event_revisions(calendar_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_event_revisions_open ON event_revisions(calendar_id, uid) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_revisions_closed ON event_revisions(valid_to) WHERE valid_to IS NOT NULL;
+
+-- Each write that changes an event's content or location closes its open
+-- revision and opens a new one. Restores load the archived history instead.
+CREATE OR REPLACE FUNCTION record_event_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('calcard.restoring', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.etag IS NOT DISTINCT FROM OLD.etag
+        AND NEW.calendar_id = OLD.calendar_id AND NEW.uid = OLD.uid
+        AND NEW.resource_name = OLD.resource_name THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE event_revisions SET valid_to = NOW()
+        WHERE calendar_id = OLD.calendar_id AND uid = OLD.uid AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO event_revisions (calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+            summary, location, dtstart, dtend, all_day, changed_by)
+        VALUES (NEW.calendar_id, NEW.uid, NEW.resource_name, NEW.etag, NEW.raw_ical, NEW.raw_ical_hash,
+            NEW.summary, NEW.location, NEW.dtstart, NEW.dtend, NEW.all_day, NEW.changed_by);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revisions hold a reference to their body, so it outlives the event.
+CREATE OR REPLACE FUNCTION count_event_revision_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_ical_hash);
+    ELSE
+        PERFORM content_body_release(OLD.raw_ical_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_events_record_revision ON events;
+CREATE TRIGGER trg_events_record_revision
+AFTER INSERT OR UPDATE OR DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION record_event_revision();
+
+DROP TRIGGER IF EXISTS trg_event_revisions_count_body ON event_revisions;
+CREATE TRIGGER trg_event_revisions_count_body
+AFTER INSERT OR DELETE ON event_revisions
+FOR EACH ROW EXECUTE FUNCTION count_event_revision_body();
+
+-- History starts now: existing events open their first revision, and
+-- earlier times cannot be viewed.
+INSERT INTO event_revisions (calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by)
+SELECT calendar_id, uid, resource_name, etag, raw_ical, raw_ical_hash,
+    summary, location, dtstart, dtend, all_day, changed_by
+FROM events e
+WHERE NOT EXISTS (SELECT 1 FROM event_revisions r WHERE r.calendar_id = e.calendar_id AND r.uid = e.uid AND r.valid_to IS NULL);
+
+INSERT INTO application (key, value)
+VALUES ('event_history_since', NOW()::text)
+ON CONFLICT (key) DO NOTHING;
+
+UPDATE application SET value = 'v1.1.36' WHERE key = 'version';
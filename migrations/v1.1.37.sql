@@ -0,0 +1,19 @@
+-- v1.1.37: repairs made to data written by each user's clients, for the
+-- client data quality report.
+
+CREATE TABLE IF NOT EXISTS client_repairs (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_key TEXT NOT NULL,
+    app_password_id BIGINT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    repair TEXT NOT NULL,
+    repair_count BIGINT NOT NULL DEFAULT 1,
+    last_resource TEXT NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, device_key, repair)
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_repairs_last_seen ON client_repairs(last_seen_at);
+
+UPDATE application SET value = 'v1.1.37' WHERE key = 'version';
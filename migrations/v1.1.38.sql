@@ -0,0 +1,24 @@
+-- v1.1.38: WebDAV-Push subscriptions. Each one is a Web Push endpoint a
+-- client registered for a calendar or address book, told when the
+-- collection changes after its cursor. The token names its URL.
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    push_resource TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    auth_secret TEXT NOT NULL,
+    cursor_txid BIGINT NOT NULL DEFAULT 0,
+    cursor_seq BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_pushed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (collection_type, collection_id, push_resource)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_expires ON push_subscriptions(expires_at);
+
+UPDATE application SET value = 'v1.1.38' WHERE key = 'version';
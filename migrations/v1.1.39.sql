@@ -0,0 +1,20 @@
+-- v1.1.39: Idempotency keys for REST writes. A retried POST or PUT with
+-- the same Idempotency-Key gets the stored response instead of being
+-- applied again. Keys expire after a day.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status INT NULL,
+    headers TEXT NULL,
+    body BYTEA NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+UPDATE application SET value = 'v1.1.39' WHERE key = 'version';
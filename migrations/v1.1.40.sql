@@ -0,0 +1,85 @@
+-- v1.1.40: a history of contact revisions, recorded by trigger like
+-- event_revisions, so the contacts changed recently can be listed with the
+-- fields each change touched and who made it.
+
+CREATE TABLE IF NOT EXISTS contact_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    address_book_id BIGINT NOT NULL REFERENCES address_books(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL,
+    etag TEXT NOT NULL,
+    raw_vcard TEXT NULL,
+    raw_vcard_hash TEXT NULL,
+    display_name TEXT NULL,
+    changed_by BIGINT NULL,
+    closed_by BIGINT NULL,
+    valid_from TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_book_from ON contact_revisions(address_book_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_book_to ON contact_revisions(address_book_id, valid_to) WHERE valid_to IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_contact_revisions_open ON contact_revisions(address_book_id, uid) WHERE valid_to IS NULL;
+
+-- Each write that changes a contact closes its open revision, recording who
+-- closed it, and opens a new one. Restores load the archived history
+-- instead.
+CREATE OR REPLACE FUNCTION record_contact_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('calcard.restoring', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.etag IS NOT DISTINCT FROM OLD.etag
+        AND NEW.address_book_id = OLD.address_book_id AND NEW.uid = OLD.uid
+        AND NEW.resource_name = OLD.resource_name THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        UPDATE contact_revisions
+        SET valid_to = NOW(), closed_by = NULLIF(current_setting('calcard.actor', true), '')::bigint
+        WHERE address_book_id = OLD.address_book_id AND uid = OLD.uid AND valid_to IS NULL;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO contact_revisions (address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+            display_name, changed_by)
+        VALUES (NEW.address_book_id, NEW.uid, NEW.resource_name, NEW.etag, NEW.raw_vcard, NEW.raw_vcard_hash,
+            NEW.display_name, NEW.changed_by);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Revisions hold a reference to their body, so it outlives the contact.
+CREATE OR REPLACE FUNCTION count_contact_revision_body()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM content_body_acquire(NEW.raw_vcard_hash);
+    ELSE
+        PERFORM content_body_release(OLD.raw_vcard_hash);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_contacts_record_revision ON contacts;
+CREATE TRIGGER trg_contacts_record_revision
+AFTER INSERT OR UPDATE OR DELETE ON contacts
+FOR EACH ROW EXECUTE FUNCTION record_contact_revision();
+
+DROP TRIGGER IF EXISTS trg_contact_revisions_count_body ON contact_revisions;
+CREATE TRIGGER trg_contact_revisions_count_body
+AFTER INSERT OR DELETE ON contact_revisions
+FOR EACH ROW EXECUTE FUNCTION count_contact_revision_body();
+
+-- History starts now: existing contacts open a first revision, dated before
+-- any change is listed from, so their next change can be compared with it.
+INSERT INTO contact_revisions (address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, valid_from)
+SELECT address_book_id, uid, resource_name, etag, raw_vcard, raw_vcard_hash,
+    display_name, changed_by, '-infinity'
+FROM contacts c
+WHERE NOT EXISTS (SELECT 1 FROM contact_revisions r WHERE r.address_book_id = c.address_book_id AND r.uid = c.uid AND r.valid_to IS NULL);
+
+UPDATE application SET value = 'v1.1.40' WHERE key = 'version';
@@ -0,0 +1,15 @@
+-- v1.1.41: REST API roles for app passwords. A viewer password may only
+-- read through /api, an editor may also change events and contacts, and an
+-- admin may also manage shares, groups and devices. Existing passwords keep
+-- full access; new ones default to editor. DAV access is unaffected.
+
+ALTER TABLE app_passwords ADD COLUMN IF NOT EXISTS api_role TEXT
+    CHECK (api_role IN ('viewer', 'editor', 'admin'));
+
+UPDATE app_passwords SET api_role = 'admin' WHERE api_role IS NULL;
+
+ALTER TABLE app_passwords
+    ALTER COLUMN api_role SET DEFAULT 'editor',
+    ALTER COLUMN api_role SET NOT NULL;
+
+UPDATE application SET value = 'v1.1.41' WHERE key = 'version';
@@ -0,0 +1,16 @@
+-- v1.1.42: maintenance mode for calendars and address books. While an admin
+-- runs a bulk operation on a collection, such as a merge or a restore,
+-- clients can still read it but their writes are refused until the
+-- maintenance ends or expires.
+
+CREATE TABLE IF NOT EXISTS collection_maintenance (
+    collection_type TEXT NOT NULL CHECK (collection_type IN ('calendar', 'addressbook')),
+    collection_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    started_by TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (collection_type, collection_id)
+);
+
+UPDATE application SET value = 'v1.1.42' WHERE key = 'version';
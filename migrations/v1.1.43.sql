@@ -0,0 +1,18 @@
+-- v1.1.43: calendar ownership transfers. An admin can hand a calendar to
+-- another user, such as when an employee leaves, keeping its ID, events and
+-- the other users' shares. Each transfer is kept as an audit record.
+
+CREATE TABLE IF NOT EXISTS calendar_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    from_user_id BIGINT NOT NULL,
+    from_email TEXT NOT NULL,
+    to_user_id BIGINT NOT NULL,
+    to_email TEXT NOT NULL,
+    transferred_by TEXT NOT NULL,
+    transferred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_transfers_calendar ON calendar_transfers(calendar_id, transferred_at);
+
+UPDATE application SET value = 'v1.1.43' WHERE key = 'version';
@@ -0,0 +1,40 @@
+-- v1.1.44: alarm notification providers. Users can have their event alarms
+-- pushed to Gotify, ntfy, Pushover or a webhook of their own, and each
+-- delivery is tracked so failed ones are retried.
+
+CREATE TABLE IF NOT EXISTS notification_providers (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('gotify', 'ntfy', 'pushover', 'webhook')),
+    name TEXT NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_providers_user ON notification_providers(user_id);
+
+CREATE TABLE IF NOT EXISTS alarm_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    provider_id BIGINT NOT NULL REFERENCES notification_providers(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL,
+    uid TEXT NOT NULL,
+    alarm_id TEXT NOT NULL,
+    fire_at TIMESTAMPTZ NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider_id, calendar_id, uid, alarm_id, fire_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alarm_deliveries_pending ON alarm_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_alarm_deliveries_user ON alarm_deliveries(user_id, fire_at);
+
+UPDATE application SET value = 'v1.1.44' WHERE key = 'version';
@@ -0,0 +1,10 @@
+-- v1.1.45: storage quota warnings. Remembers the highest quota warning each
+-- user has been sent, so crossing 80% or 95% of the quota is reported once.
+
+CREATE TABLE IF NOT EXISTS quota_warnings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    level TEXT NOT NULL CHECK (level IN ('warning', 'critical')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.45' WHERE key = 'version';
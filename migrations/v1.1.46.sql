@@ -0,0 +1,29 @@
+-- v1.1.46: focus time. Users can opt in to having the free stretches of
+-- their protected hours blocked as tentative focus time, and the blocks
+-- created are tracked so they can be removed in one go.
+
+CREATE TABLE IF NOT EXISTS focus_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    min_minutes INT NOT NULL CHECK (min_minutes BETWEEN 15 AND 480),
+    day_start_minute INT NOT NULL CHECK (day_start_minute BETWEEN 0 AND 1439),
+    day_end_minute INT NOT NULL CHECK (day_end_minute BETWEEN 1 AND 1440),
+    planned_until TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (day_start_minute < day_end_minute)
+);
+
+CREATE TABLE IF NOT EXISTS focus_blocks (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    calendar_id BIGINT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    uid TEXT NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (calendar_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_focus_blocks_user ON focus_blocks(user_id, end_at);
+
+UPDATE application SET value = 'v1.1.46' WHERE key = 'version';
@@ -0,0 +1,19 @@
+-- v1.1.47: feature flag overrides. Admins can turn a feature flag on or
+-- off for one user or a group ahead of, or instead of, the instance-wide
+-- setting in APP_FEATURES.
+
+CREATE TABLE IF NOT EXISTS feature_flag_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    flag TEXT NOT NULL,
+    user_id BIGINT NULL REFERENCES users(id) ON DELETE CASCADE,
+    group_id BIGINT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (group_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_overrides_user ON feature_flag_overrides(flag, user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feature_flag_overrides_group ON feature_flag_overrides(flag, group_id) WHERE group_id IS NOT NULL;
+
+UPDATE application SET value = 'v1.1.47' WHERE key = 'version';
@@ -0,0 +1,9 @@
+-- v1.1.48: internal accounts. An account the server uses itself, such as the
+-- synthetic probe's, is left out of user listings, usage statistics and
+-- digests, and grants to DAV:authenticated do not reach it.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS internal BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET internal = TRUE WHERE oauth_subject = 'calcard:probe';
+
+UPDATE application SET value = 'v1.1.48' WHERE key = 'version';
@@ -0,0 +1,7 @@
+-- v1.1.49: new app passwords get the editor REST API role unless another is
+-- asked for. Passwords that already exist keep the role they have; those
+-- created before roles existed were made admins by v1.1.41.
+
+ALTER TABLE app_passwords ALTER COLUMN api_role SET DEFAULT 'editor';
+
+UPDATE application SET value = 'v1.1.49' WHERE key = 'version';
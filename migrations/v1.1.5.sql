@@ -0,0 +1,9 @@
+-- v1.1.5: per-user preference to withhold cancelled and declined events from
+-- CalDAV calendar-query and sync-collection responses.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sync_hide_cancelled BOOLEAN NOT NULL DEFAULT FALSE;
+-- Sync tokens older than the last preference change are rejected so clients
+-- resync and pick up events that became visible or hidden.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sync_prefs_updated_at TIMESTAMPTZ NULL;
+
+UPDATE application SET value = 'v1.1.5' WHERE key = 'version';
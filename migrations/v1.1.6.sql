@@ -0,0 +1,20 @@
+-- v1.1.6: mass-deletion protection. DAV DELETEs that look like a client
+-- wiping a collection are held here instead of being applied, so other
+-- devices keep the resources until the owner confirms or releases them.
+
+CREATE TABLE IF NOT EXISTS held_deletions (
+    id BIGSERIAL PRIMARY KEY,
+    resource_type TEXT NOT NULL,
+    collection_id BIGINT NOT NULL,
+    uid TEXT NOT NULL,
+    resource_name TEXT NOT NULL DEFAULT '',
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    deleted_by BIGINT NOT NULL,
+    client TEXT NOT NULL DEFAULT '',
+    held_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (resource_type, collection_id, uid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_held_deletions_owner ON held_deletions(owner_id, held_at DESC);
+
+UPDATE application SET value = 'v1.1.6' WHERE key = 'version';
@@ -0,0 +1,20 @@
+-- v1.1.7: per-collection sync activity, keyed by device (app password, or
+-- User-Agent when no app password identifies the client), so users can see
+-- which devices still sync each calendar and address book.
+
+CREATE TABLE IF NOT EXISTS collection_syncs (
+    id BIGSERIAL PRIMARY KEY,
+    collection_type TEXT NOT NULL,
+    collection_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_key TEXT NOT NULL,
+    app_password_id BIGINT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    last_synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sync_count BIGINT NOT NULL DEFAULT 1,
+    UNIQUE (collection_type, collection_id, user_id, device_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_syncs_user ON collection_syncs(user_id, last_synced_at DESC);
+
+UPDATE application SET value = 'v1.1.7' WHERE key = 'version';
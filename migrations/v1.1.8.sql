@@ -0,0 +1,7 @@
+-- v1.1.8: remember where each app password was last used from, so users can
+-- recognise a lost device and revoke it.
+
+ALTER TABLE app_passwords ADD COLUMN IF NOT EXISTS last_used_user_agent TEXT NULL;
+ALTER TABLE app_passwords ADD COLUMN IF NOT EXISTS last_used_ip TEXT NULL;
+
+UPDATE application SET value = 'v1.1.8' WHERE key = 'version';
@@ -0,0 +1,12 @@
+-- v1.1.9: opt-in public free/busy links. The token in the URL is the only
+-- credential, so each user has at most one and can regenerate it.
+
+CREATE TABLE IF NOT EXISTS freebusy_links (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    window_days INT NOT NULL DEFAULT 60,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+UPDATE application SET value = 'v1.1.9' WHERE key = 'version';